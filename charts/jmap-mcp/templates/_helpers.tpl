@@ -99,3 +99,14 @@ Create the name of the service account to use
 {{- end }}
 {{- end }}
 
+
+{{/*
+Render jmap.endpoints as the JMAP_ENDPOINTS value (name=url,name=url)
+*/}}
+{{- define "jmap-mcp.endpoints" -}}
+{{- $items := list }}
+{{- range $name, $url := . }}
+{{- $items = append $items (printf "%s=%s" $name $url) }}
+{{- end }}
+{{- join "," $items }}
+{{- end }}
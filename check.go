@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/pkg/jmapmcp"
+)
+
+// checkTimeout bounds the whole of jmap-mcp check.
+const checkTimeout = time.Minute
+
+// runCheck is the check subcommand: it connects an in-process MCP client to
+// srv, the way any MCP client would, calls read-only tools against the
+// configured JMAP server, and writes a deployment report to w. It returns
+// the number of failed steps.
+func runCheck(srv *jmapmcp.Server, w io.Writer) int {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	failed := 0
+	report := func(step, status, detail string) {
+		if status == "FAIL" {
+			failed++
+		}
+		fmt.Fprintf(w, "%-5s %-12s %s\n", status, step, detail)
+	}
+	fmt.Fprintf(w, "jmap-mcp %s deployment check\n\n", version)
+
+	st, ct := mcp.NewInMemoryTransports()
+	if _, err := srv.MCP().Connect(ctx, st, nil); err != nil {
+		report("connect", "FAIL", err.Error())
+		return failed
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "jmap-mcp-check", Version: version}, nil)
+	cs, err := client.Connect(ctx, ct, nil)
+	if err != nil {
+		report("connect", "FAIL", err.Error())
+		return failed
+	}
+	defer cs.Close()
+
+	tools, err := cs.ListTools(ctx, nil)
+	if err != nil {
+		report("tools", "FAIL", err.Error())
+		return failed
+	}
+	report("tools", "OK", fmt.Sprintf("%d registered", len(tools.Tools)))
+
+	// call runs a tool and reports what summary makes of its text (by
+	// default the first line), or its error.
+	call := func(name string, args map[string]any, summary func(string) string) *mcp.CallToolResult {
+		res, err := cs.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: args})
+		if err != nil {
+			report(name, "FAIL", err.Error())
+			return nil
+		}
+		text := checkText(res)
+		if res.IsError {
+			report(name, "FAIL", text)
+			return nil
+		}
+		if summary == nil {
+			summary = func(text string) string {
+				first, _, _ := strings.Cut(text, "\n")
+				return first
+			}
+		}
+		report(name, "OK", summary(text))
+		return res
+	}
+
+	if res := call("server_info", nil, nil); res != nil {
+		// The backend section says which server the report is about.
+		if _, backend, ok := strings.Cut(checkText(res), "\nBackend:\n"); ok {
+			for _, line := range strings.Split(strings.TrimSpace(backend), "\n") {
+				fmt.Fprintf(w, "%18s %s\n", "", strings.TrimSpace(line))
+			}
+		}
+	}
+	call("mailbox_get", nil, func(text string) string {
+		return fmt.Sprintf("%d mailbox(es)", strings.Count(text, "[id: "))
+	})
+	var emailID string
+	if res := call("email_query", map[string]any{"limit": 3, "fields": []string{"subject"}}, nil); res != nil {
+		emailID = firstEmailID(res)
+	}
+	if emailID != "" {
+		call("email_get", map[string]any{"email_ids": []string{emailID}, "max_chars": 2000}, nil)
+	} else {
+		report("email_get", "SKIP", "no email to fetch")
+	}
+
+	fmt.Fprintln(w)
+	if failed > 0 {
+		fmt.Fprintf(w, "%d check(s) failed\n", failed)
+	} else {
+		fmt.Fprintln(w, "All checks passed")
+	}
+	return failed
+}
+
+// checkText joins the text content of a tool result.
+func checkText(res *mcp.CallToolResult) string {
+	var sb strings.Builder
+	for _, c := range res.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			sb.WriteString(tc.Text)
+		}
+	}
+	return sb.String()
+}
+
+// firstEmailID returns the first email ID an email_query result lists: from
+// its structured content when the server sends one, or else from the first
+// result line, which starts with the ID.
+func firstEmailID(res *mcp.CallToolResult) string {
+	if res.StructuredContent != nil {
+		var out struct {
+			Emails []struct {
+				ID string `json:"id"`
+			} `json:"emails"`
+		}
+		if data, err := json.Marshal(res.StructuredContent); err == nil && json.Unmarshal(data, &out) == nil && len(out.Emails) > 0 {
+			return out.Emails[0].ID
+		}
+	}
+	_, list, ok := strings.Cut(checkText(res), "\n\n")
+	if !ok {
+		return ""
+	}
+	if fields := strings.Fields(list); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
@@ -3,15 +3,16 @@ module github.com/mikluko/jmap-mcp
 go 1.25.0
 
 require (
+	github.com/google/jsonschema-go v0.4.2
+	github.com/k3a/html2text v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/mikluko/jmap v0.26.0
 	github.com/modelcontextprotocol/go-sdk v1.3.0
+	github.com/web-ridge/email-reply-parser v0.0.0-20230428184542-95e2a82fa6bd
+	golang.org/x/net v0.50.0
 )
 
 require (
-	github.com/google/jsonschema-go v0.4.2 // indirect
-	github.com/k3a/html2text v1.3.0 // indirect
-	github.com/web-ridge/email-reply-parser v0.0.0-20230428184542-95e2a82fa6bd // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	golang.org/x/net v0.50.0 // indirect
 	golang.org/x/oauth2 v0.35.0 // indirect
 )
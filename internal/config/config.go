@@ -4,46 +4,385 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 )
 
+// Endpoint is an additional named JMAP backend.
+type Endpoint struct {
+	Name       string
+	SessionURL string
+	AuthToken  string // JMAP_AUTH_TOKEN_<NAME>; empty reuses JMAP_AUTH_TOKEN
+}
+
 // Config holds the application configuration.
 type Config struct {
-	Mode                  string // "stdio" or "http"
-	ListenAddr            string // for HTTP mode
-	SessionURL            string // JMAP session URL
-	AuthToken             string // JMAP bearer token (optional in http mode)
-	EnableEmailSubmission bool   // enable email_submission_set tool
-	EnableSieve           bool   // enable sieve tools
-	AttachmentURLSecret   string // secret for sealing URL claims (ATTACHMENT_URL_SECRET)
-	ExternalURL           string // explicit external base URL for signed links
+	Mode                   string        // "stdio", "http", or "daemon"
+	ListenAddr             string        // for HTTP mode
+	SessionURL             string        // JMAP session URL
+	Account                string        // email address or domain to discover the session URL from (JMAP_ACCOUNT)
+	AuthToken              string        // JMAP bearer token from file, keychain, or env (optional in http mode)
+	AuthTokenReload        TokenReload   // rereads AuthToken from its file or keychain entry; nil for env
+	Endpoints              []Endpoint    // additional named backends (JMAP_ENDPOINTS)
+	EnableEmailSubmission  bool          // enable email_submission_set tool
+	SendQueue              bool          // queue submissions for approval instead of sending
+	AllowedDomains         []string      // recipient domains allowed by the send policy
+	BlockedRecipients      []string      // recipient addresses refused by the send policy
+	IdentityDomains        []string      // sender identity domains allowed by the send policy
+	MaxRecipients          int           // max recipients per message (0: unlimited)
+	MaxSendsPerHour        int           // max submissions per credential per hour (0: unlimited)
+	ResendWindow           time.Duration // refuse submitting the same email again within this window (0: off)
+	PreSendCommand         string        // external command reviewing every outgoing message
+	PreSendURL             string        // HTTP endpoint reviewing every outgoing message
+	ConfirmDestructive     bool          // require a confirmation token for permanent destruction
+	DeleteDelay            time.Duration // how long permanently deleted emails wait in Trash (0: destroy at once)
+	EnableMailMerge        bool          // enable the mail_merge tool
+	MailMergeMax           int           // max recipients per mail_merge call
+	MailMergeBatchSize     int           // mail_merge messages per batch
+	MailMergeBatchInterval time.Duration // pause between mail_merge batches
+	AbuseAddress           string        // where email_report_abuse sends reports
+	AutoCC                 []string      // addresses added as CC to every composed draft
+	AutoBCC                []string      // addresses added as BCC to every composed draft
+	AllowedAttachmentTypes []string      // media types the attachment policy allows
+	BlockedAttachmentTypes []string      // media types the attachment policy refuses
+	BlockedAttachmentExts  []string      // file extensions the attachment policy refuses
+	MaxAttachmentSize      int           // max bytes per attachment (0: unlimited)
+	ScanClamd              string        // clamd socket path or host:port scanning attachments handed out
+	ScanCommand            string        // external command scanning attachments handed out
+	ScanWarnOnly           bool          // flag infected or unscannable attachments instead of withholding them
+	MaxFetchBytes          int64         // max bytes one tool call may read from JMAP (0: unlimited)
+	QueryLimit             int           // default email_query result count
+	MaxChars               int           // default email_get response budget in characters
+	MaxBodyChars           int           // per-email body cap in email_get
+	ToolCosts              []string      // tool=class[:seconds] overrides of the cost hints in tool metadata
+	ImageMaxDimension      int           // longest side of images email_attachment_image returns (0: as attached)
+	ImageJPEGQuality       int           // JPEG quality of downscaled images
+	FullHeadersInclude     []string      // header name patterns full_headers shows (empty: all)
+	FullHeadersExclude     []string      // header name patterns full_headers leaves out
+	WatchPollInterval      time.Duration // Email/changes polling interval of watches without push (0: push only)
+	TenantCacheBytes       int64         // estimated bytes of cached results kept per tenant (0: no caching)
+	MaxTenants             int           // tenants with cached results (0: unlimited)
+	WebSocket              bool          // use JMAP over WebSocket (RFC 8887) when advertised
+	DebugJMAP              string        // where raw JMAP exchanges go: off, log, result, or call
+	RecordJMAP             string        // fixture file JMAP traffic is recorded to
+	ReplayJMAP             string        // fixture file JMAP traffic is served from instead of a server
+	ThreadFallback         string        // when threads are reconstructed client-side: auto, always, or off
+	Locale                 string        // how tool outputs write dates and sizes
+	DateFormat             string        // locale, offset, or rfc3339
+	RelativeDates          bool          // add the age to dates in tool outputs
+	StructuredOutput       bool          // listing tools also return structured content with address objects
+	Redact                 []string      // builtin redaction rule sets (api_keys, credit_cards, private_keys)
+	RedactPatterns         []string      // custom regular expressions masked in email bodies
+	EnableSieve            bool          // enable sieve tools
+	LabelMode              bool          // backend is label-based; enable label tools
+	EnableStalwartAdmin    bool          // enable Stalwart management API tools
+	AttachmentURLSecret    string        // secret for sealing URL claims (ATTACHMENT_URL_SECRET)
+	AttachmentURLTTL       time.Duration // life of a signed attachment URL
+	ExternalURL            string        // explicit external base URL for signed links
+	LocalFiles             bool          // stdio mode: tools may use files inside the client's roots
+	TranslateURL           string        // LibreTranslate-compatible endpoint for email_get translation
+	TranslateTarget        string        // language to translate bodies into
+	TranslateAPIKey        string        // API key for the translation endpoint (TRANSLATE_API_KEY)
+	ClassifyCategories     []string      // categories email_classify sorts messages into; empty disables it
+	ClassifyURL            string        // endpoint classifying messages for clients without sampling and for automations
+	PDFRenderer            string        // external HTML-to-PDF command for email_render
+	PGPCommand             string        // external command decrypting and verifying PGP/MIME in email_get
+	SenderListsFile        string        // JSON file keeping the VIP and muted sender lists; empty keeps them in memory
+	SieveHistoryFile       string        // JSON file keeping earlier Sieve script versions; empty keeps them in memory
+	MailboxSnapshotsFile   string        // JSON file keeping mailbox_snapshot snapshots; empty keeps them in memory
+	AutomationsFile        string        // JSON file keeping automation_create automations; empty keeps them in memory
+	SchedulesFile          string        // JSON file keeping schedule_create schedules; empty keeps them in memory
+	JournalsFile           string        // JSON file keeping each tenant's undo_last journal; empty keeps one per MCP session in memory
+	Store                  string        // where the documents of the *-file options are kept: "file", "memory", or "sqlite:PATH"
+	RunAutomations         bool          // run the configured token's automations on push and its schedules in the background
+	ScriptsFile            string        // JSON file listing operator scripts exposed as tools and automation actions
+	QueryPresetsFile       string        // JSON file listing named searches exposed as query_<name> tools
+	MailboxViewsFile       string        // JSON file listing email_query defaults by mailbox role
+	APIKeys                []string      // static MCP server API keys (MCP_API_KEYS, MCP_API_KEYS_FILE)
+	CredentialsFile        string        // JSON store mapping MCP keys to JMAP tokens (MCP_CREDENTIALS_FILE)
+	OIDCIssuer             string        // OpenID Connect issuer whose tokens authenticate MCP clients
+	OIDCAudience           string        // required audience of OIDC tokens
+	RejectQueryToken       bool          // refuse the jmap_token query parameter in http mode
 }
 
 // LoadConfig parses command-line flags and environment variables.
 func LoadConfig() (*Config, error) {
 	cfg := &Config{}
 
-	flag.StringVar(&cfg.Mode, "mode", "stdio", "Server mode: stdio or http")
+	flag.StringVar(&cfg.Mode, "mode", "stdio", "Server mode: stdio, http, or daemon (run automations only, without MCP)")
 	flag.StringVar(&cfg.ListenAddr, "listen", ":8080", "HTTP listen address (http mode only)")
 	flag.BoolVar(&cfg.EnableEmailSubmission, "enable-send", false, "Enable email_submission_set tool (disabled by default for safety)")
+	flag.BoolVar(&cfg.SendQueue, "send-queue", false, "Queue email_submission_set drafts in a local outbox until approved with outbox_approve (requires -enable-send)")
+	allowedDomains := flag.String("allowed-recipient-domains", "", "Comma-separated recipient domains allowed by the send policy (subdomains included; default: any)")
+	blockedRecipients := flag.String("blocked-recipients", "", "Comma-separated recipient addresses refused by the send policy (*@domain blocks a domain)")
+	identityDomains := flag.String("identity-domains", "", "Comma-separated domains sender identities must belong to (subdomains included; default: any)")
+	flag.IntVar(&cfg.MaxRecipients, "max-recipients", 0, "Maximum To+CC+BCC recipients per message (0: unlimited)")
+	flag.IntVar(&cfg.MaxSendsPerHour, "max-sends-per-hour", 0, "Maximum submissions per JMAP credential in a sliding hour (0: unlimited)")
+	flag.DurationVar(&cfg.ResendWindow, "resend-window", 10*time.Minute, "Refuse submitting the same email twice within this window unless the call sets resend (0: off)")
+	flag.StringVar(&cfg.PreSendCommand, "pre-send-command", "", "Command reviewing every outgoing message before submission: the raw message on stdin, a verdict (allow, reject, or replace) on stdout (see README)")
+	flag.StringVar(&cfg.PreSendURL, "pre-send-url", "", "HTTP endpoint reviewing every outgoing message before submission: the raw message is POSTed, the verdict read from the response (see README)")
+	flag.BoolVar(&cfg.ConfirmDestructive, "confirm-destructive", false, "Make permanent email deletion and mailbox destruction two-phase: the first call returns a summary and a confirmation token that a repeat call must carry")
+	flag.DurationVar(&cfg.DeleteDelay, "delete-delay", 0, "Stage permanent email_delete: move the emails to Trash and destroy them by a schedule after this delay, cancellable with email_delete_cancel (0: destroy at once; schedules run with -run-automations or -mode daemon)")
+	flag.BoolVar(&cfg.EnableMailMerge, "enable-mail-merge", false, "Enable the mail_merge bulk personalized send tool (requires -enable-send and -max-sends-per-hour)")
+	flag.IntVar(&cfg.MailMergeMax, "mail-merge-max-recipients", 50, "Maximum recipients per mail_merge call")
+	flag.IntVar(&cfg.MailMergeBatchSize, "mail-merge-batch-size", 10, "Messages mail_merge creates and submits per batch")
+	flag.DurationVar(&cfg.MailMergeBatchInterval, "mail-merge-batch-interval", 10*time.Second, "Pause between mail_merge batches")
+	flag.StringVar(&cfg.AbuseAddress, "abuse-address", "", "Address email_report_abuse forwards reported phishing and spam to, e.g. a security team or abuse desk (requires -enable-send)")
+	autoCC := flag.String("auto-cc", "", "Comma-separated addresses added as CC to every draft composed by email_create, email_reply, and email_forward")
+	autoBCC := flag.String("auto-bcc", "", "Comma-separated addresses added as BCC to every composed draft (e.g. an archive mailbox)")
+	allowedAttachmentTypes := flag.String("allowed-attachment-types", "", "Comma-separated media types allowed for attachments (type/* wildcards; default: any)")
+	blockedAttachmentTypes := flag.String("blocked-attachment-types", "", "Comma-separated media types refused for attachments (type/* wildcards)")
+	blockedAttachmentExts := flag.String("blocked-attachment-extensions", "", "Comma-separated file extensions refused for attachments (e.g. exe,bat,js)")
+	flag.IntVar(&cfg.MaxAttachmentSize, "max-attachment-size", 0, "Maximum bytes per uploaded or forwarded attachment (0: server limit only)")
+	flag.StringVar(&cfg.ScanClamd, "scan-clamd", "", "clamd Unix socket path or host:port that scans attachments before email_attachment_image, attachment_download, or email_attachment_url hands them out")
+	flag.StringVar(&cfg.ScanCommand, "scan-command", "", "Command scanning attachments before they are handed out: the content on stdin, exit 0 clean, 1 infected with the threat on stdout (e.g. clamdscan --no-summary -)")
+	flag.BoolVar(&cfg.ScanWarnOnly, "scan-warn-only", false, "Hand out infected or unscannable attachments with a warning instead of withholding them")
+	flag.Int64Var(&cfg.MaxFetchBytes, "max-fetch-bytes", 64<<20, "Maximum bytes one tool call may read from the JMAP server; larger calls fail with advice to narrow them (0: unlimited)")
+	flag.IntVar(&cfg.QueryLimit, "query-limit", 20, "Default number of email_query results when a call sets no limit")
+	flag.IntVar(&cfg.MaxChars, "max-chars", 50000, "Default email_get response budget in characters when a call sets no max_chars")
+	flag.IntVar(&cfg.MaxBodyChars, "max-body-chars", 4000, "Maximum characters of each email body returned by email_get, after stripping quotes and signatures")
+	flag.IntVar(&cfg.ImageMaxDimension, "image-max-dimension", 1568, "Longest side in pixels of images email_attachment_image returns; larger ones are downscaled to JPEG (0: return images as attached)")
+	flag.IntVar(&cfg.ImageJPEGQuality, "image-jpeg-quality", 85, "JPEG quality (1-100) of images email_attachment_image downscales")
+	fullHeadersInclude := flag.String("full-headers-include", "", "Comma-separated header names, with * wildcards, email_get full_headers shows (default: all)")
+	fullHeadersExclude := flag.String("full-headers-exclude", "", "Comma-separated header names, with * wildcards, email_get full_headers leaves out (e.g. DKIM-Signature,ARC-*)")
+	toolCosts := flag.String("tool-cost", "", "Comma-separated tool=class[:seconds] overrides of the latency hints in tool metadata; class is instant, fast, or slow (e.g. email_query=slow:60)")
+	flag.DurationVar(&cfg.WatchPollInterval, "watch-poll-interval", time.Minute, "How often watch_create watches poll Email/changes on servers without push (0: require push)")
+	flag.Int64Var(&cfg.TenantCacheBytes, "tenant-cache-bytes", 8<<20, "Estimated bytes of cached results (thread digests, email_query results, correspondents_top scans, media probes) kept per JMAP token; the least recently used are dropped (0: no caching)")
+	flag.IntVar(&cfg.MaxTenants, "max-tenants", 1000, "JMAP tokens whose cached results are kept; the least recently active tenant's are dropped (0: unlimited)")
+	flag.BoolVar(&cfg.WebSocket, "websocket", true, "Send method calls and watch push over JMAP WebSocket (RFC 8887) when the server advertises it; -websocket=false forces HTTP")
+	flag.StringVar(&cfg.Locale, "locale", "default", "Locale of dates, sizes, and weekday names in tool outputs: default (ISO dates, exact byte counts) or a tag such as en, en-US, en-GB, de, fr, es, it, nl, pt, ru")
+	flag.StringVar(&cfg.DateFormat, "date-format", "locale", "How tool outputs write dates and times: locale (the -locale layout), offset (the -locale layout with the UTC offset), or rfc3339")
+	flag.BoolVar(&cfg.StructuredOutput, "structured-output", false, "Also return the results of email_query, email_get, identity_get, and thread_participants as structured content, with addresses as {name, email} objects")
+	flag.BoolVar(&cfg.RelativeDates, "relative-dates", false, "Follow dates in tool outputs with their age, such as (3h ago)")
+	flag.StringVar(&cfg.RecordJMAP, "record-jmap", "", "Record the session, JMAP method calls and responses, and blobs transferred to this fixture file, for -replay-jmap")
+	flag.StringVar(&cfg.ReplayJMAP, "replay-jmap", "", "Answer JMAP calls from a fixture file recorded with -record-jmap instead of a mail server (for offline, reproducible tests); no session URL or token is needed")
+	flag.StringVar(&cfg.ThreadFallback, "thread-fallback", "auto", "When to reconstruct conversations from Message-ID, In-Reply-To, and References headers instead of Thread/get: auto (once the server refuses Thread/get), always (servers whose threading is unreliable), or off")
+	flag.StringVar(&cfg.DebugJMAP, "debug-jmap", "off", "Record raw JMAP method calls and responses of tool calls: off, log (to the server log), result (appended to every tool result), or call (tools gain a debug parameter for full-permission credentials)")
+	redact := flag.String("redact", "", "Comma-separated builtin secret patterns masked in email bodies returned to the model: api_keys, credit_cards, private_keys")
+	flag.Func("redact-regex", "Custom regular expression masked in email bodies (repeatable)", func(v string) error {
+		cfg.RedactPatterns = append(cfg.RedactPatterns, v)
+		return nil
+	})
 	flag.BoolVar(&cfg.EnableSieve, "enable-sieve", false, "Enable Sieve script tools (disabled by default, requires server support)")
+	flag.BoolVar(&cfg.EnableStalwartAdmin, "enable-stalwart-admin", false, "Enable Stalwart account, quota, and undelete administration tools (requires a Stalwart backend and admin token)")
+	flag.BoolVar(&cfg.LabelMode, "label-mode", false, "Declare the backend label-based and enable label_apply/label_remove tools")
+	flag.BoolVar(&cfg.LocalFiles, "local-files", true, "In stdio mode, let attachment_download, attachment_upload, email_render, and thread_export read and write files inside the MCP client's filesystem roots")
 	flag.StringVar(&cfg.ExternalURL, "external-url", "", "External base URL for signed attachment links (default: derived from the request)")
+	flag.DurationVar(&cfg.AttachmentURLTTL, "attachment-url-ttl", 30*time.Second, "How long a signed, single-use attachment link stays valid; raise it when web clients offer links to users (at most 1h)")
+	flag.StringVar(&cfg.TranslateURL, "translate-url", "", "LibreTranslate-compatible endpoint enabling email_get translation (e.g. https://libretranslate.example.com/translate)")
+	flag.StringVar(&cfg.TranslateTarget, "translate-target", "en", "Language (ISO 639-1) email_get translates bodies into")
+	classifyCategories := flag.String("classify-categories", "", "Comma-separated categories enabling email_classify, which records each message's category as a category-<name> keyword (e.g. work,personal,newsletter,receipt)")
+	flag.StringVar(&cfg.ClassifyURL, "classify-url", "", "Endpoint classifying messages for MCP clients without sampling and for classify automations (POST JSON {categories, emails} → {categories: {id: category}})")
+	flag.StringVar(&cfg.PDFRenderer, "pdf-renderer", "", "Command converting HTML on stdin to PDF on stdout, enabling email_render pdf output (e.g. \"wkhtmltopdf --quiet - -\")")
+	flag.StringVar(&cfg.PGPCommand, "pgp-command", "", "Command decrypting and verifying PGP/MIME messages for email_get: the raw message on stdin, result headers and the decrypted entity on stdout (see README)")
+	flag.StringVar(&cfg.SenderListsFile, "sender-lists-file", defaultConfigFile("senders.json"), "JSON file keeping each user's VIP and muted senders across restarts (empty: in memory only)")
+	flag.StringVar(&cfg.SieveHistoryFile, "sieve-history-file", defaultConfigFile("sieve-history.json"), "JSON file keeping earlier versions of each user's Sieve scripts for sieve_rollback (empty: in memory only)")
+	flag.StringVar(&cfg.MailboxSnapshotsFile, "mailbox-snapshots-file", defaultConfigFile("mailbox-snapshots.json"), "JSON file keeping each user's mailbox_snapshot snapshots for mailbox_diff (empty: in memory only)")
+	flag.StringVar(&cfg.AutomationsFile, "automations-file", defaultConfigFile("automations.json"), "JSON file keeping each user's automations, shared with a -mode daemon process (empty: in memory only)")
+	flag.StringVar(&cfg.SchedulesFile, "schedules-file", defaultConfigFile("schedules.json"), "JSON file keeping each user's scheduled actions, shared with a -mode daemon process (empty: in memory only)")
+	flag.StringVar(&cfg.JournalsFile, "journals-file", "", "JSON file keeping each user's undo_last journal across sessions and restarts; processes sharing it do not lock it, so the last to save wins (empty: in memory, one per MCP session)")
+	flag.StringVar(&cfg.Store, "store", "file", "Where sender lists, Sieve history, mailbox snapshots, automations, schedules, and journals are kept: 'file' (at the -*-file paths), 'sqlite:PATH' (in the SQLite database at PATH, the -*-file paths naming documents; needs a cgo build), or 'memory' (lost on exit)")
+	flag.StringVar(&cfg.ScriptsFile, "scripts-file", "", "JSON file listing external scripts exposed as script_<name> tools and automation actions (see README)")
+	flag.StringVar(&cfg.QueryPresetsFile, "query-presets-file", "", "JSON file listing named email searches exposed as read-only query_<name> tools (see README)")
+	flag.StringVar(&cfg.MailboxViewsFile, "mailbox-views-file", "", "JSON file listing the fields and sort email_query uses by default in mailboxes with a given role, replacing the built-in Sent and Drafts views (see README)")
+	flag.BoolVar(&cfg.RunAutomations, "run-automations", false, "Run the automations (on push) and schedules of the JMAP_AUTH_TOKEN account in the background, whether or not an MCP client is connected")
+	flag.StringVar(&cfg.OIDCIssuer, "oidc-issuer", "", "OpenID Connect issuer URL; require MCP clients to present a token it signed (http mode only)")
+	flag.StringVar(&cfg.OIDCAudience, "oidc-audience", "", "Audience OIDC tokens must be issued for (default: not checked)")
+	flag.BoolVar(&cfg.RejectQueryToken, "reject-query-token", false, "Reject the jmap_token query parameter so JMAP tokens never appear in URLs (http mode only)")
 	flag.Parse()
 
+	cfg.AllowedDomains = splitList(*allowedDomains)
+	cfg.BlockedRecipients = splitList(*blockedRecipients)
+	cfg.IdentityDomains = splitList(*identityDomains)
+	cfg.AutoCC = splitList(*autoCC)
+	cfg.AutoBCC = splitList(*autoBCC)
+	cfg.Redact = splitList(*redact)
+	cfg.ToolCosts = splitList(*toolCosts)
+	cfg.AllowedAttachmentTypes = splitList(*allowedAttachmentTypes)
+	cfg.BlockedAttachmentTypes = splitList(*blockedAttachmentTypes)
+	cfg.BlockedAttachmentExts = splitList(*blockedAttachmentExts)
+	cfg.FullHeadersInclude = splitList(*fullHeadersInclude)
+	cfg.FullHeadersExclude = splitList(*fullHeadersExclude)
+	cfg.ClassifyCategories = splitList(strings.ToLower(*classifyCategories))
+
+	if cfg.RecordJMAP != "" && cfg.ReplayJMAP != "" {
+		return nil, fmt.Errorf("-record-jmap and -replay-jmap are mutually exclusive")
+	}
 	cfg.SessionURL = os.Getenv("JMAP_SESSION_URL")
-	if cfg.SessionURL == "" {
-		return nil, fmt.Errorf("JMAP_SESSION_URL environment variable is required")
+	cfg.Account = os.Getenv("JMAP_ACCOUNT")
+	if cfg.SessionURL == "" && cfg.ReplayJMAP != "" {
+		cfg.SessionURL = "replay:" + cfg.ReplayJMAP // the fixture holds the session
+	}
+	if cfg.SessionURL == "" && cfg.Account == "" {
+		return nil, fmt.Errorf("JMAP_SESSION_URL (or JMAP_ACCOUNT for discovery) environment variable is required")
+	}
+
+	token, reload, err := loadAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	cfg.AuthToken, cfg.AuthTokenReload = token, reload
+	if cfg.AuthToken == "" && cfg.ReplayJMAP != "" {
+		cfg.AuthToken = "replay" // the fixture answers any token
 	}
 
-	cfg.AuthToken = os.Getenv("JMAP_AUTH_TOKEN")
+	endpoints, err := parseEndpoints(os.Getenv("JMAP_ENDPOINTS"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Endpoints = endpoints
+	apiKeys, err := loadAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	cfg.APIKeys = apiKeys
+	cfg.CredentialsFile = os.Getenv("MCP_CREDENTIALS_FILE")
 	cfg.AttachmentURLSecret = os.Getenv("ATTACHMENT_URL_SECRET")
+	cfg.TranslateAPIKey = os.Getenv("TRANSLATE_API_KEY")
 
 	if cfg.Mode == "stdio" && cfg.AuthToken == "" {
-		return nil, fmt.Errorf("JMAP_AUTH_TOKEN environment variable is required in stdio mode")
+		return nil, fmt.Errorf("JMAP_AUTH_TOKEN_FILE, JMAP_AUTH_TOKEN_KEYCHAIN, or JMAP_AUTH_TOKEN is required in stdio mode")
+	}
+
+	if cfg.Mode == "daemon" {
+		cfg.RunAutomations = true
+	}
+	if cfg.RunAutomations && cfg.AuthToken == "" {
+		return nil, fmt.Errorf("-run-automations and -mode daemon require JMAP_AUTH_TOKEN_FILE, JMAP_AUTH_TOKEN_KEYCHAIN, or JMAP_AUTH_TOKEN")
+	}
+
+	if cfg.SendQueue && !cfg.EnableEmailSubmission {
+		return nil, fmt.Errorf("-send-queue requires -enable-send")
+	}
+
+	if cfg.PreSendCommand != "" && cfg.PreSendURL != "" {
+		return nil, fmt.Errorf("-pre-send-command and -pre-send-url are mutually exclusive")
+	}
+
+	if (cfg.PreSendCommand != "" || cfg.PreSendURL != "") && !cfg.EnableEmailSubmission {
+		return nil, fmt.Errorf("-pre-send-command and -pre-send-url require -enable-send")
+	}
+
+	if cfg.AbuseAddress != "" && !cfg.EnableEmailSubmission {
+		return nil, fmt.Errorf("-abuse-address requires -enable-send")
+	}
+
+	if cfg.AbuseAddress != "" && !strings.Contains(cfg.AbuseAddress, "@") {
+		return nil, fmt.Errorf("-abuse-address %q is not an email address", cfg.AbuseAddress)
+	}
+
+	if cfg.ScanClamd != "" && cfg.ScanCommand != "" {
+		return nil, fmt.Errorf("-scan-clamd and -scan-command are mutually exclusive")
+	}
+
+	if cfg.EnableMailMerge && (!cfg.EnableEmailSubmission || cfg.MaxSendsPerHour <= 0) {
+		return nil, fmt.Errorf("-enable-mail-merge requires -enable-send and -max-sends-per-hour")
+	}
+
+	if cfg.DeleteDelay < 0 {
+		return nil, fmt.Errorf("-delete-delay must not be negative")
 	}
 
-	if cfg.Mode != "stdio" && cfg.Mode != "http" {
-		return nil, fmt.Errorf("mode must be 'stdio' or 'http', got: %s", cfg.Mode)
+	if cfg.AttachmentURLTTL <= 0 || cfg.AttachmentURLTTL > time.Hour {
+		return nil, fmt.Errorf("-attachment-url-ttl must be positive and at most 1h")
+	}
+
+	if cfg.ImageMaxDimension < 0 || cfg.ImageJPEGQuality < 1 || cfg.ImageJPEGQuality > 100 {
+		return nil, fmt.Errorf("-image-max-dimension must not be negative and -image-jpeg-quality must be 1 to 100")
+	}
+
+	for _, p := range append(slices.Clip(cfg.FullHeadersInclude), cfg.FullHeadersExclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("-full-headers-include/-full-headers-exclude: bad pattern %q", p)
+		}
+	}
+
+	for _, c := range cfg.ClassifyCategories {
+		if strings.Trim(c, "abcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+			return nil, fmt.Errorf("-classify-categories: %q is not a category name (letters, digits, '-' and '_')", c)
+		}
+	}
+	if cfg.ClassifyURL != "" && len(cfg.ClassifyCategories) == 0 {
+		return nil, fmt.Errorf("-classify-url requires -classify-categories")
+	}
+
+	if path, ok := strings.CutPrefix(cfg.Store, "sqlite:"); cfg.Store != "file" && cfg.Store != "memory" && (!ok || path == "") {
+		return nil, fmt.Errorf("store must be 'file', 'memory', or 'sqlite:PATH', got: %s", cfg.Store)
+	}
+
+	if cfg.Mode != "stdio" && cfg.Mode != "http" && cfg.Mode != "daemon" {
+		return nil, fmt.Errorf("mode must be 'stdio', 'http', or 'daemon', got: %s", cfg.Mode)
+	}
+
+	if cfg.Mode != "http" && (cfg.HasServerAuth() || cfg.RejectQueryToken) {
+		return nil, fmt.Errorf("MCP_API_KEYS, MCP_CREDENTIALS_FILE, -oidc-issuer, and -reject-query-token require -mode http")
 	}
 
 	return cfg, nil
 }
+
+// HasServerAuth reports whether MCP clients must authenticate to the server
+// itself, separately from the JMAP token.
+func (c *Config) HasServerAuth() bool {
+	return len(c.APIKeys) > 0 || c.CredentialsFile != "" || c.OIDCIssuer != ""
+}
+
+// HasSendPolicy reports whether any send policy rule is configured.
+func (c *Config) HasSendPolicy() bool {
+	return len(c.AllowedDomains) > 0 || len(c.BlockedRecipients) > 0 || len(c.IdentityDomains) > 0 || c.MaxRecipients > 0 || c.MaxSendsPerHour > 0
+}
+
+// HasAttachmentPolicy reports whether any attachment policy rule is configured.
+func (c *Config) HasAttachmentPolicy() bool {
+	return len(c.AllowedAttachmentTypes) > 0 || len(c.BlockedAttachmentTypes) > 0 || len(c.BlockedAttachmentExts) > 0 || c.MaxAttachmentSize > 0
+}
+
+// parseEndpoints parses JMAP_ENDPOINTS ("name=url,name=url") and picks up
+// each endpoint's JMAP_AUTH_TOKEN_<NAME>, with the name upper-cased and
+// dashes turned into underscores. The token variables are removed from the
+// environment once read, like JMAP_AUTH_TOKEN.
+func parseEndpoints(v string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	// "file" and "keychain" would collide with JMAP_AUTH_TOKEN_FILE and
+	// JMAP_AUTH_TOKEN_KEYCHAIN.
+	seen := map[string]bool{"default": true, "file": true, "keychain": true}
+	for _, item := range splitList(v) {
+		name, url, ok := strings.Cut(item, "=")
+		name, url = strings.TrimSpace(name), strings.TrimSpace(url)
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("JMAP_ENDPOINTS entry %q: expected name=session-url", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("JMAP_ENDPOINTS: duplicate or reserved endpoint name %q", name)
+		}
+		seen[name] = true
+		envName := "JMAP_AUTH_TOKEN_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		endpoints = append(endpoints, Endpoint{Name: name, SessionURL: url, AuthToken: os.Getenv(envName)})
+		os.Unsetenv(envName)
+	}
+	return endpoints, nil
+}
+
+// defaultConfigFile is the named file in the jmap-mcp directory of the
+// user's configuration directory, or "" when there is none.
+func defaultConfigFile(name string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "jmap-mcp", name)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
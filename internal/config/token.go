@@ -0,0 +1,126 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// keychainService is the service name tokens are stored under in the OS
+// keychain.
+const keychainService = "jmap-mcp"
+
+// keychainLookup reads a secret from the OS keychain; replaced in tests.
+var keychainLookup = lookupKeychain
+
+// TokenReload rereads a JMAP token from the file or keychain entry it was
+// loaded from.
+type TokenReload func() (string, error)
+
+// loadAuthToken resolves the JMAP token from, in order, JMAP_AUTH_TOKEN_FILE,
+// the OS keychain entry named by JMAP_AUTH_TOKEN_KEYCHAIN, and
+// JMAP_AUTH_TOKEN. The variables are removed from the process environment
+// afterwards so child processes (e.g. the PDF renderer) do not inherit them.
+// For a file or keychain entry it also returns a function rereading it, so
+// a rotated token is picked up without a restart; nil for JMAP_AUTH_TOKEN.
+func loadAuthToken() (string, TokenReload, error) {
+	defer func() {
+		os.Unsetenv("JMAP_AUTH_TOKEN")
+		os.Unsetenv("JMAP_AUTH_TOKEN_FILE")
+		os.Unsetenv("JMAP_AUTH_TOKEN_KEYCHAIN")
+	}()
+
+	if path := os.Getenv("JMAP_AUTH_TOKEN_FILE"); path != "" {
+		reload := func() (string, error) { return readTokenFile(path) }
+		token, err := reload()
+		return token, reload, err
+	}
+	if account := os.Getenv("JMAP_AUTH_TOKEN_KEYCHAIN"); account != "" {
+		reload := func() (string, error) {
+			token, err := keychainLookup(account)
+			if err != nil {
+				return "", fmt.Errorf("JMAP_AUTH_TOKEN_KEYCHAIN: %w", err)
+			}
+			return token, nil
+		}
+		token, err := reload()
+		return token, reload, err
+	}
+	return os.Getenv("JMAP_AUTH_TOKEN"), nil, nil
+}
+
+// readTokenFile reads a token from path, ignoring surrounding whitespace.
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("JMAP_AUTH_TOKEN_FILE: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("JMAP_AUTH_TOKEN_FILE: %s is empty", path)
+	}
+	return token, nil
+}
+
+// lookupKeychain reads the generic password stored for account under
+// keychainService, using the platform's keychain CLI: security(1) on macOS
+// and secret-tool(1) (libsecret) elsewhere.
+func lookupKeychain(account string) (string, error) {
+	var name string
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		name, args = "security", []string{"find-generic-password", "-s", keychainService, "-a", account, "-w"}
+	case "windows":
+		return "", fmt.Errorf("OS keychain is not supported on windows; use JMAP_AUTH_TOKEN_FILE")
+	default:
+		name, args = "secret-tool", []string{"lookup", "service", keychainService, "account", account}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("no %s keychain entry for account %q", keychainService, account)
+	}
+	return token, nil
+}
+
+// loadAPIKeys reads static MCP server API keys from MCP_API_KEYS
+// (comma-separated) and MCP_API_KEYS_FILE (one per line, # comments), then
+// removes both variables from the environment.
+func loadAPIKeys() ([]string, error) {
+	defer func() {
+		os.Unsetenv("MCP_API_KEYS")
+		os.Unsetenv("MCP_API_KEYS_FILE")
+	}()
+
+	keys := splitList(os.Getenv("MCP_API_KEYS"))
+	if path := os.Getenv("MCP_API_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("MCP_API_KEYS_FILE: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				keys = append(keys, line)
+			}
+		}
+	}
+	return keys, nil
+}
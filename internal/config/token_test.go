@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAuthToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := keychainLookup
+	defer func() { keychainLookup = orig }()
+	keychainLookup = func(account string) (string, error) {
+		if account == "me@example.com" {
+			return "keychain-token", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"env", map[string]string{"JMAP_AUTH_TOKEN": "env-token"}, "env-token", false},
+		{"file wins", map[string]string{"JMAP_AUTH_TOKEN_FILE": tokenFile, "JMAP_AUTH_TOKEN": "env-token"}, "file-token", false},
+		{"keychain wins over env", map[string]string{"JMAP_AUTH_TOKEN_KEYCHAIN": "me@example.com", "JMAP_AUTH_TOKEN": "env-token"}, "keychain-token", false},
+		{"missing file", map[string]string{"JMAP_AUTH_TOKEN_FILE": filepath.Join(dir, "nope")}, "", true},
+		{"empty file", map[string]string{"JMAP_AUTH_TOKEN_FILE": emptyFile}, "", true},
+		{"keychain miss", map[string]string{"JMAP_AUTH_TOKEN_KEYCHAIN": "other"}, "", true},
+		{"none", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"JMAP_AUTH_TOKEN", "JMAP_AUTH_TOKEN_FILE", "JMAP_AUTH_TOKEN_KEYCHAIN"} {
+				t.Setenv(k, tt.env[k])
+			}
+			got, reload, err := loadAuthToken()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("token = %q, want %q", got, tt.want)
+			}
+			if (reload != nil) != (tt.env["JMAP_AUTH_TOKEN_FILE"] != "" || tt.env["JMAP_AUTH_TOKEN_KEYCHAIN"] != "") {
+				t.Errorf("reload = %v for %v", reload != nil, tt.env)
+			}
+			if v, ok := os.LookupEnv("JMAP_AUTH_TOKEN"); ok {
+				t.Errorf("JMAP_AUTH_TOKEN still set to %q", v)
+			}
+		})
+	}
+}
+
+func TestAuthTokenReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JMAP_AUTH_TOKEN_FILE", path)
+	token, reload, err := loadAuthToken()
+	if err != nil || token != "first" || reload == nil {
+		t.Fatalf("loadAuthToken = %q, %v", token, err)
+	}
+	if err := os.WriteFile(path, []byte("second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := reload(); err != nil || token != "second" {
+		t.Errorf("reload = %q, %v", token, err)
+	}
+}
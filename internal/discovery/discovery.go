@@ -0,0 +1,235 @@
+// Package discovery resolves a JMAP session URL from an email address or
+// bare domain, following RFC 8620 §2.2 (SRV and .well-known/jmap) with a
+// fallback to Thunderbird-style autoconfig documents.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRedirects bounds how many .well-known redirects are followed.
+const maxRedirects = 5
+
+// Resolver discovers JMAP session URLs. The zero value uses
+// http.DefaultClient semantics with a 10 s timeout and the system resolver.
+type Resolver struct {
+	HTTPClient *http.Client
+	LookupSRV  func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SessionURL returns the JMAP session URL for account, which may be an email
+// address (user@example.com), a bare hostname, or an https URL. Candidates
+// are tried in order: SRV _jmap._tcp records, https://domain/.well-known/jmap,
+// then autoconfig documents advertising a jmap incoming server.
+func (r *Resolver) SessionURL(ctx context.Context, account string) (string, error) {
+	domain, err := domainOf(account)
+	if err != nil {
+		return "", err
+	}
+
+	var tried []string
+	try := func(candidate string) (string, bool) {
+		tried = append(tried, candidate)
+		sessionURL, err := r.probe(ctx, candidate)
+		return sessionURL, err == nil
+	}
+
+	for _, candidate := range r.srvCandidates(ctx, domain) {
+		if u, ok := try(candidate); ok {
+			return u, nil
+		}
+	}
+	if u, ok := try("https://" + domain + "/.well-known/jmap"); ok {
+		return u, nil
+	}
+	for _, candidate := range r.autoconfigCandidates(ctx, domain, account) {
+		if u, ok := try(candidate); ok {
+			return u, nil
+		}
+	}
+	return "", fmt.Errorf("no JMAP session found for %s (tried %s)", account, strings.Join(tried, ", "))
+}
+
+// domainOf extracts the host to discover from an address, hostname, or URL.
+func domainOf(account string) (string, error) {
+	account = strings.TrimSpace(account)
+	if account == "" {
+		return "", errors.New("empty account")
+	}
+	if strings.Contains(account, "://") {
+		u, err := url.Parse(account)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("invalid account URL %q", account)
+		}
+		return u.Host, nil
+	}
+	if i := strings.LastIndexByte(account, '@'); i >= 0 {
+		account = account[i+1:]
+	}
+	if account == "" || strings.ContainsAny(account, "/ ") {
+		return "", fmt.Errorf("invalid account %q: expected an email address or domain", account)
+	}
+	return strings.ToLower(account), nil
+}
+
+// srvCandidates returns .well-known URLs for the _jmap._tcp SRV targets in
+// priority order (RFC 8620 §2.2). Lookup failures yield no candidates.
+func (r *Resolver) srvCandidates(ctx context.Context, domain string) []string {
+	lookup := r.LookupSRV
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupSRV
+	}
+	host, _, err := net.SplitHostPort(domain)
+	if err != nil {
+		host = domain
+	}
+	_, records, err := lookup(ctx, "jmap", "tcp", host)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, srv := range records {
+		target := strings.TrimSuffix(srv.Target, ".")
+		if target == "" {
+			continue // "." target: service explicitly unavailable
+		}
+		if srv.Port != 443 {
+			target = net.JoinHostPort(target, strconv.Itoa(int(srv.Port)))
+		}
+		out = append(out, "https://"+target+"/.well-known/jmap")
+	}
+	return out
+}
+
+// autoconfigDoc is the subset of a Thunderbird autoconfig document needed to
+// find a JMAP server.
+type autoconfigDoc struct {
+	Servers []struct {
+		Type     string `xml:"type,attr"`
+		Hostname string `xml:"hostname"`
+		Port     int    `xml:"port"`
+	} `xml:"emailProvider>incomingServer"`
+}
+
+// autoconfigCandidates fetches the domain's autoconfig documents and returns
+// .well-known URLs for any incoming server of type "jmap".
+func (r *Resolver) autoconfigCandidates(ctx context.Context, domain, account string) []string {
+	docs := []string{
+		"https://autoconfig." + domain + "/mail/config-v1.1.xml?emailaddress=" + url.QueryEscape(account),
+		"https://" + domain + "/.well-known/autoconfig/mail/config-v1.1.xml",
+	}
+	var out []string
+	for _, docURL := range docs {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, docURL, nil)
+		if err != nil {
+			continue
+		}
+		resp, err := r.client().Do(req)
+		if err != nil {
+			continue
+		}
+		var doc autoconfigDoc
+		err = xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			continue
+		}
+		for _, srv := range doc.Servers {
+			if !strings.EqualFold(srv.Type, "jmap") || srv.Hostname == "" {
+				continue
+			}
+			host := srv.Hostname
+			if srv.Port != 0 && srv.Port != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(srv.Port))
+			}
+			out = append(out, "https://"+host+"/.well-known/jmap")
+		}
+	}
+	return out
+}
+
+// probe requests candidate without credentials, following redirects by
+// hand so the final session URL (not the .well-known alias) is returned.
+// A 200 carrying a JMAP session object, or a 401 with an authentication
+// challenge, confirms a JMAP endpoint; sites that answer any path with a
+// page do not.
+func (r *Resolver) probe(ctx context.Context, candidate string) (string, error) {
+	client := *r.client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	current := candidate
+	for range maxRedirects + 1 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, current, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		var session error
+		if resp.StatusCode == http.StatusOK {
+			session = checkSession(resp.Body)
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400:
+			loc, err := resp.Location()
+			if err != nil {
+				return "", fmt.Errorf("%s: redirect without location", current)
+			}
+			if loc.Scheme != "https" {
+				return "", fmt.Errorf("%s: refusing redirect to non-https %s", current, loc)
+			}
+			current = loc.String()
+		case resp.StatusCode == http.StatusOK:
+			if session != nil {
+				return "", fmt.Errorf("%s: %w", current, session)
+			}
+			return current, nil
+		case resp.StatusCode == http.StatusUnauthorized:
+			if resp.Header.Get("WWW-Authenticate") == "" {
+				return "", fmt.Errorf("%s: HTTP 401 without an authentication challenge", current)
+			}
+			return current, nil
+		default:
+			return "", fmt.Errorf("%s: HTTP %d", current, resp.StatusCode)
+		}
+	}
+	return "", fmt.Errorf("%s: too many redirects", candidate)
+}
+
+// checkSession reports whether body is a JMAP session object (RFC 8620
+// §2), judged by the apiUrl and capabilities every session carries.
+func checkSession(body io.Reader) error {
+	var session struct {
+		APIURL       string                     `json:"apiUrl"`
+		Capabilities map[string]json.RawMessage `json:"capabilities"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&session); err != nil {
+		return fmt.Errorf("not a JMAP session: %w", err)
+	}
+	if session.APIURL == "" || len(session.Capabilities) == 0 {
+		return errors.New("not a JMAP session: apiUrl or capabilities missing")
+	}
+	return nil
+}
+
+func (r *Resolver) client() *http.Client {
+	if r.HTTPClient != nil {
+		return r.HTTPClient
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
@@ -0,0 +1,166 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// jmapSession is a minimal JMAP session object.
+const jmapSession = `{"apiUrl": "https://example.com/jmap/api", "capabilities": {"urn:ietf:params:jmap:core": {}}}`
+
+func noSRV(context.Context, string, string, string) (string, []*net.SRV, error) {
+	return "", nil, errors.New("no such host")
+}
+
+func TestDomainOf(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"user@Example.com", "example.com", false},
+		{"example.com", "example.com", false},
+		{"https://mail.example.com/some/path", "mail.example.com", false},
+		{"", "", true},
+		{"user@", "", true},
+		{"not a domain", "", true},
+	}
+	for _, tt := range tests {
+		got, err := domainOf(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("domainOf(%q) = %q, %v; want %q, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSessionURLWellKnown(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/jmap":
+			http.Redirect(w, r, "/jmap/session", http.StatusTemporaryRedirect)
+		case "/jmap/session":
+			w.Header().Set("WWW-Authenticate", `Basic realm="jmap"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	r := &Resolver{HTTPClient: srv.Client(), LookupSRV: noSRV}
+	got, err := r.SessionURL(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/jmap/session"; got != want {
+		t.Errorf("SessionURL = %q, want %q", got, want)
+	}
+}
+
+func TestSessionURLSRV(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/jmap" {
+			w.Write([]byte(jmapSession))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	r := &Resolver{
+		HTTPClient: srv.Client(),
+		LookupSRV: func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			if service != "jmap" || proto != "tcp" || name != "example.com" {
+				t.Errorf("unexpected SRV lookup %s %s %s", service, proto, name)
+			}
+			return "", []*net.SRV{{Target: "127.0.0.1.", Port: uint16(port)}}, nil
+		},
+	}
+	got, err := r.SessionURL(context.Background(), "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/.well-known/jmap"; got != want {
+		t.Errorf("SessionURL = %q, want %q", got, want)
+	}
+}
+
+func TestSessionURLAutoconfig(t *testing.T) {
+	// Every host dials the test server; the certificate covers example.com,
+	// and the Host header tells the domain apart from the JMAP host.
+	const jmapHost = "example.com:8443"
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/.well-known/autoconfig/mail/config-v1.1.xml":
+			w.Write([]byte(`<clientConfig version="1.1"><emailProvider id="example.com">
+				<incomingServer type="imap"><hostname>imap.example.com</hostname><port>993</port></incomingServer>
+				<incomingServer type="jmap"><hostname>example.com</hostname><port>8443</port></incomingServer>
+			</emailProvider></clientConfig>`))
+		case r.URL.Path == "/.well-known/jmap" && r.Host == jmapHost:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := srv.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	client.Transport = transport
+
+	r := &Resolver{HTTPClient: client, LookupSRV: noSRV}
+	got, err := r.SessionURL(context.Background(), "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://" + jmapHost + "/.well-known/jmap"; got != want {
+		t.Errorf("SessionURL = %q, want %q", got, want)
+	}
+}
+
+func TestSessionURLNotFound(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	r := &Resolver{HTTPClient: srv.Client(), LookupSRV: noSRV}
+	if _, err := r.SessionURL(context.Background(), srv.URL); err == nil {
+		t.Error("expected error when nothing serves JMAP")
+	}
+}
+
+func TestSessionURLRejectsCatchAll(t *testing.T) {
+	// A site answering every path with a page or a bare status is not JMAP.
+	for name, h := range map[string]http.HandlerFunc{
+		"html page": func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte("<html><body>Welcome</body></html>"))
+		},
+		"json without session": func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(`{"status": "ok"}`))
+		},
+		"401 without challenge": func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		},
+		"403": func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		},
+	} {
+		srv := httptest.NewTLSServer(h)
+		r := &Resolver{HTTPClient: srv.Client(), LookupSRV: noSRV}
+		if got, err := r.SessionURL(context.Background(), srv.URL); err == nil {
+			t.Errorf("%s: SessionURL = %q, want an error", name, got)
+		}
+		srv.Close()
+	}
+}
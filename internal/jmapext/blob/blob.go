@@ -0,0 +1,47 @@
+// Package blob implements the Blob/lookup method of JMAP Blob Management
+// (RFC 9404), which finds the objects referencing a blob. The upstream jmap
+// library does not provide this capability, so the method and response
+// types are registered here.
+package blob
+
+import "github.com/mikluko/jmap"
+
+// URI is the JMAP Blob Management capability.
+const URI jmap.URI = "urn:ietf:params:jmap:blob"
+
+func init() {
+	jmap.RegisterCapability(&Capability{})
+	jmap.RegisterMethod("Blob/lookup", newLookupResponse)
+}
+
+// Capability is the (empty) session-level blob capability object.
+type Capability struct{}
+
+func (c *Capability) URI() jmap.URI        { return URI }
+func (c *Capability) New() jmap.Capability { return &Capability{} }
+
+// Lookup is Blob/lookup (RFC 9404 §4.2).
+type Lookup struct {
+	Account   jmap.ID   `json:"accountId,omitempty"`
+	TypeNames []string  `json:"typeNames"`
+	IDs       []jmap.ID `json:"ids"`
+}
+
+func (m *Lookup) Name() string         { return "Blob/lookup" }
+func (m *Lookup) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// Match lists, per type name, the objects that reference a blob.
+type Match struct {
+	ID         jmap.ID              `json:"id"`
+	MatchedIDs map[string][]jmap.ID `json:"matchedIds"`
+}
+
+// LookupResponse is the Blob/lookup response. Blobs that do not exist are
+// in NotFound.
+type LookupResponse struct {
+	Account  jmap.ID   `json:"accountId,omitempty"`
+	List     []*Match  `json:"list"`
+	NotFound []jmap.ID `json:"notFound,omitempty"`
+}
+
+func newLookupResponse() jmap.MethodResponse { return &LookupResponse{} }
@@ -0,0 +1,167 @@
+// Package calendars implements the subset of JMAP for Calendars
+// (draft-ietf-jmap-calendars) that jmap-mcp needs: listing calendars,
+// querying events over a time range for availability, and creating
+// events. The upstream jmap library does not provide this capability, so
+// the method and response types are registered here.
+package calendars
+
+import "github.com/mikluko/jmap"
+
+// URI is the JMAP Calendars capability.
+const URI jmap.URI = "urn:ietf:params:jmap:calendars"
+
+func init() {
+	jmap.RegisterCapability(&Capability{})
+	jmap.RegisterMethod("Calendar/get", newCalendarGetResponse)
+	jmap.RegisterMethod("CalendarEvent/query", newEventQueryResponse)
+	jmap.RegisterMethod("CalendarEvent/get", newEventGetResponse)
+	jmap.RegisterMethod("CalendarEvent/set", newEventSetResponse)
+}
+
+// Capability is the session-level calendars capability object; jmap-mcp
+// reads none of its fields.
+type Capability struct{}
+
+func (c *Capability) URI() jmap.URI        { return URI }
+func (c *Capability) New() jmap.Capability { return &Capability{} }
+
+// Calendar is a Calendar object, reduced to the properties jmap-mcp uses.
+type Calendar struct {
+	ID        jmap.ID `json:"id,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	IsDefault bool    `json:"isDefault,omitempty"`
+
+	// IncludeInAvailability is "all", "attending", or "none": which of the
+	// calendar's events count towards the user's free/busy time.
+	IncludeInAvailability string `json:"includeInAvailability,omitempty"`
+}
+
+// Event is a CalendarEvent (a JSCalendar Event, RFC 8984), reduced to the
+// properties needed to place it in time and to create simple events.
+type Event struct {
+	Type        string               `json:"@type,omitempty"` // "Event" when creating
+	ID          jmap.ID              `json:"id,omitempty"`
+	CalendarIDs map[jmap.ID]bool     `json:"calendarIds,omitempty"`
+	Title       string               `json:"title,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Locations   map[string]*Location `json:"locations,omitempty"`
+
+	// Start is a LocalDateTime ("2025-01-02T10:00:00") in TimeZone, or
+	// floating when TimeZone is nil.
+	Start           string  `json:"start,omitempty"`
+	Duration        string  `json:"duration,omitempty"` // ISO 8601 duration, e.g. PT1H30M
+	TimeZone        *string `json:"timeZone,omitempty"`
+	ShowWithoutTime bool    `json:"showWithoutTime,omitempty"`
+
+	Status         string `json:"status,omitempty"`         // confirmed, tentative, cancelled
+	FreeBusyStatus string `json:"freeBusyStatus,omitempty"` // busy (default), free
+
+	// UTCStart and UTCEnd are computed by servers that support them.
+	UTCStart string `json:"utcStart,omitempty"`
+	UTCEnd   string `json:"utcEnd,omitempty"`
+}
+
+// Location is a JSCalendar Location; Name is free text such as a room,
+// an address, or a meeting link.
+type Location struct {
+	Type string `json:"@type,omitempty"` // "Location"
+	Name string `json:"name,omitempty"`
+}
+
+// CalendarGet is Calendar/get.
+type CalendarGet struct {
+	Account    jmap.ID   `json:"accountId,omitempty"`
+	IDs        []jmap.ID `json:"ids,omitempty"`
+	Properties []string  `json:"properties,omitempty"`
+}
+
+func (m *CalendarGet) Name() string         { return "Calendar/get" }
+func (m *CalendarGet) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// CalendarGetResponse is the Calendar/get response.
+type CalendarGetResponse struct {
+	Account  jmap.ID     `json:"accountId,omitempty"`
+	State    string      `json:"state,omitempty"`
+	List     []*Calendar `json:"list,omitempty"`
+	NotFound []jmap.ID   `json:"notFound,omitempty"`
+}
+
+func newCalendarGetResponse() jmap.MethodResponse { return &CalendarGetResponse{} }
+
+// EventFilterCondition is a CalendarEvent/query filter. After and Before
+// are UTCDateTimes: events ending after After and starting before Before
+// match.
+type EventFilterCondition struct {
+	InCalendars []jmap.ID `json:"inCalendars,omitempty"`
+	After       string    `json:"after,omitempty"`
+	Before      string    `json:"before,omitempty"`
+}
+
+// EventQuery is CalendarEvent/query. With ExpandRecurrences, each
+// occurrence of a recurring event in the range is returned as its own ID.
+type EventQuery struct {
+	Account           jmap.ID               `json:"accountId,omitempty"`
+	Filter            *EventFilterCondition `json:"filter,omitempty"`
+	ExpandRecurrences bool                  `json:"expandRecurrences,omitempty"`
+	TimeZone          string                `json:"timeZone,omitempty"`
+	Limit             uint64                `json:"limit,omitempty"`
+}
+
+func (m *EventQuery) Name() string         { return "CalendarEvent/query" }
+func (m *EventQuery) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// EventQueryResponse is the CalendarEvent/query response.
+type EventQueryResponse struct {
+	Account jmap.ID   `json:"accountId,omitempty"`
+	IDs     []jmap.ID `json:"ids,omitempty"`
+	Total   uint64    `json:"total,omitempty"`
+}
+
+func newEventQueryResponse() jmap.MethodResponse { return &EventQueryResponse{} }
+
+// EventGet is CalendarEvent/get.
+type EventGet struct {
+	Account      jmap.ID               `json:"accountId,omitempty"`
+	IDs          []jmap.ID             `json:"ids,omitempty"`
+	Properties   []string              `json:"properties,omitempty"`
+	ReferenceIDs *jmap.ResultReference `json:"#ids,omitempty"`
+}
+
+func (m *EventGet) Name() string         { return "CalendarEvent/get" }
+func (m *EventGet) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// EventGetResponse is the CalendarEvent/get response.
+type EventGetResponse struct {
+	Account  jmap.ID   `json:"accountId,omitempty"`
+	State    string    `json:"state,omitempty"`
+	List     []*Event  `json:"list,omitempty"`
+	NotFound []jmap.ID `json:"notFound,omitempty"`
+}
+
+func newEventGetResponse() jmap.MethodResponse { return &EventGetResponse{} }
+
+// EventSet is CalendarEvent/set.
+type EventSet struct {
+	Account jmap.ID                `json:"accountId,omitempty"`
+	Create  map[jmap.ID]*Event     `json:"create,omitempty"`
+	Update  map[jmap.ID]jmap.Patch `json:"update,omitempty"`
+	Destroy []jmap.ID              `json:"destroy,omitempty"`
+}
+
+func (m *EventSet) Name() string         { return "CalendarEvent/set" }
+func (m *EventSet) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// EventSetResponse is the CalendarEvent/set response.
+type EventSetResponse struct {
+	Account      jmap.ID                    `json:"accountId,omitempty"`
+	OldState     string                     `json:"oldState,omitempty"`
+	NewState     string                     `json:"newState,omitempty"`
+	Created      map[jmap.ID]*Event         `json:"created,omitempty"`
+	Updated      map[jmap.ID]*Event         `json:"updated,omitempty"`
+	Destroyed    []jmap.ID                  `json:"destroyed,omitempty"`
+	NotCreated   map[jmap.ID]*jmap.SetError `json:"notCreated,omitempty"`
+	NotUpdated   map[jmap.ID]*jmap.SetError `json:"notUpdated,omitempty"`
+	NotDestroyed map[jmap.ID]*jmap.SetError `json:"notDestroyed,omitempty"`
+}
+
+func newEventSetResponse() jmap.MethodResponse { return &EventSetResponse{} }
@@ -0,0 +1,146 @@
+// Package contacts implements the subset of JMAP for Contacts (RFC 9610)
+// that jmap-mcp needs: looking up ContactCards by query and fetching them,
+// including group cards and their members.
+// The upstream jmap library does not provide this capability, so the method
+// and response types are registered here.
+package contacts
+
+import "github.com/mikluko/jmap"
+
+// URI is the JMAP Contacts capability.
+const URI jmap.URI = "urn:ietf:params:jmap:contacts"
+
+func init() {
+	jmap.RegisterCapability(&Capability{})
+	jmap.RegisterMethod("ContactCard/get", newGetResponse)
+	jmap.RegisterMethod("ContactCard/query", newQueryResponse)
+}
+
+// Capability is the (empty) session-level contacts capability object.
+type Capability struct{}
+
+func (c *Capability) URI() jmap.URI        { return URI }
+func (c *Capability) New() jmap.Capability { return &Capability{} }
+
+// Card is a JSContact Card (RFC 9553), reduced to the properties jmap-mcp
+// renders.
+type Card struct {
+	ID            jmap.ID                  `json:"id,omitempty"`
+	UID           string                   `json:"uid,omitempty"`
+	Kind          string                   `json:"kind,omitempty"`
+	Name          *Name                    `json:"name,omitempty"`
+	Emails        map[string]*EmailAddress `json:"emails,omitempty"`
+	Phones        map[string]*Phone        `json:"phones,omitempty"`
+	Organizations map[string]*Organization `json:"organizations,omitempty"`
+	Titles        map[string]*Title        `json:"titles,omitempty"`
+	Media         map[string]*Media        `json:"media,omitempty"`
+	Notes         map[string]*Note         `json:"notes,omitempty"`
+
+	// Members lists the UIDs of a group card's members (kind "group").
+	Members map[string]bool `json:"members,omitempty"`
+}
+
+// Name is a JSContact Name; Full is the display form.
+type Name struct {
+	Full string `json:"full,omitempty"`
+}
+
+// EmailAddress is a JSContact EmailAddress.
+type EmailAddress struct {
+	Address string `json:"address,omitempty"`
+	Pref    int    `json:"pref,omitempty"` // 1 is most preferred; 0 means unset
+}
+
+// Phone is a JSContact Phone.
+type Phone struct {
+	Number string `json:"number,omitempty"`
+}
+
+// Organization is a JSContact Organization.
+type Organization struct {
+	Name string `json:"name,omitempty"`
+}
+
+// Title is a JSContact Title (job title or role).
+type Title struct {
+	Name string `json:"name,omitempty"`
+}
+
+// Media is a JSContact Media entry; Kind "photo" carries the contact photo.
+type Media struct {
+	Kind      string `json:"kind,omitempty"`
+	URI       string `json:"uri,omitempty"`
+	BlobID    string `json:"blobId,omitempty"`
+	MediaType string `json:"mediaType,omitempty"`
+}
+
+// Note is a JSContact Note.
+type Note struct {
+	Note string `json:"note,omitempty"`
+}
+
+// Filter is a ContactCard/query filter: a FilterCondition or a
+// FilterOperator combining them.
+type Filter interface {
+	implementsFilter()
+}
+
+// FilterOperator combines filters with AND, OR, or NOT.
+type FilterOperator struct {
+	Operator   jmap.Operator `json:"operator"`
+	Conditions []Filter      `json:"conditions,omitempty"`
+}
+
+func (fo *FilterOperator) implementsFilter() {}
+
+// FilterCondition is a ContactCard/query filter (RFC 9610 §3.3).
+type FilterCondition struct {
+	InAddressBook jmap.ID `json:"inAddressBook,omitempty"`
+	UID           string  `json:"uid,omitempty"`
+	Kind          string  `json:"kind,omitempty"`
+	Text          string  `json:"text,omitempty"`
+	Name          string  `json:"name,omitempty"`
+	Email         string  `json:"email,omitempty"`
+}
+
+func (fc *FilterCondition) implementsFilter() {}
+
+// Query is ContactCard/query.
+type Query struct {
+	Account jmap.ID `json:"accountId,omitempty"`
+	Filter  Filter  `json:"filter,omitempty"`
+	Limit   uint64  `json:"limit,omitempty"`
+}
+
+func (m *Query) Name() string         { return "ContactCard/query" }
+func (m *Query) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// QueryResponse is the ContactCard/query response.
+type QueryResponse struct {
+	Account jmap.ID   `json:"accountId,omitempty"`
+	IDs     []jmap.ID `json:"ids,omitempty"`
+	Total   uint64    `json:"total,omitempty"`
+}
+
+func newQueryResponse() jmap.MethodResponse { return &QueryResponse{} }
+
+// Get is ContactCard/get.
+type Get struct {
+	Account      jmap.ID               `json:"accountId,omitempty"`
+	IDs          []jmap.ID             `json:"ids,omitempty"`
+	Properties   []string              `json:"properties,omitempty"`
+	ReferenceIDs *jmap.ResultReference `json:"#ids,omitempty"`
+}
+
+func (m *Get) Name() string         { return "ContactCard/get" }
+func (m *Get) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// GetResponse is the ContactCard/get response.
+type GetResponse struct {
+	Account  jmap.ID   `json:"accountId,omitempty"`
+	State    string    `json:"state,omitempty"`
+	List     []*Card   `json:"list,omitempty"`
+	NotFound []jmap.ID `json:"notFound,omitempty"`
+}
+
+func newGetResponse() jmap.MethodResponse { return &GetResponse{} }
@@ -0,0 +1,91 @@
+// Package maskedemail implements Fastmail's MaskedEmail JMAP extension
+// (https://www.fastmail.com/dev/maskedemail): per-site forwarding addresses
+// that can be created, listed, and disabled. The upstream jmap library does
+// not provide this capability, so the method and response types are
+// registered here.
+package maskedemail
+
+import (
+	"time"
+
+	"github.com/mikluko/jmap"
+)
+
+// URI is the MaskedEmail capability.
+const URI jmap.URI = "https://www.fastmail.com/dev/maskedemail"
+
+func init() {
+	jmap.RegisterCapability(&Capability{})
+	jmap.RegisterMethod("MaskedEmail/get", newGetResponse)
+	jmap.RegisterMethod("MaskedEmail/set", newSetResponse)
+}
+
+// Capability is the (empty) session-level MaskedEmail capability object.
+type Capability struct{}
+
+func (c *Capability) URI() jmap.URI        { return URI }
+func (c *Capability) New() jmap.Capability { return &Capability{} }
+
+// Masked email states.
+const (
+	StatePending  = "pending" // created but not yet used; deleted if unused within 24h
+	StateEnabled  = "enabled"
+	StateDisabled = "disabled" // mail is accepted but moved to Trash
+	StateDeleted  = "deleted"  // mail bounces
+)
+
+// MaskedEmail is a forwarding address tied to a site or purpose.
+type MaskedEmail struct {
+	ID            jmap.ID    `json:"id,omitempty"`
+	Email         string     `json:"email,omitempty"`
+	State         string     `json:"state,omitempty"`
+	ForDomain     string     `json:"forDomain,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	URL           string     `json:"url,omitempty"`
+	EmailPrefix   string     `json:"emailPrefix,omitempty"` // create only
+	CreatedBy     string     `json:"createdBy,omitempty"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	LastMessageAt *time.Time `json:"lastMessageAt,omitempty"`
+}
+
+// Get is MaskedEmail/get.
+type Get struct {
+	Account jmap.ID   `json:"accountId,omitempty"`
+	IDs     []jmap.ID `json:"ids,omitempty"`
+}
+
+func (m *Get) Name() string         { return "MaskedEmail/get" }
+func (m *Get) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// GetResponse is the MaskedEmail/get response.
+type GetResponse struct {
+	Account  jmap.ID        `json:"accountId,omitempty"`
+	State    string         `json:"state,omitempty"`
+	List     []*MaskedEmail `json:"list,omitempty"`
+	NotFound []jmap.ID      `json:"notFound,omitempty"`
+}
+
+func newGetResponse() jmap.MethodResponse { return &GetResponse{} }
+
+// Set is MaskedEmail/set.
+type Set struct {
+	Account jmap.ID                  `json:"accountId,omitempty"`
+	Create  map[jmap.ID]*MaskedEmail `json:"create,omitempty"`
+	Update  map[jmap.ID]jmap.Patch   `json:"update,omitempty"`
+}
+
+func (m *Set) Name() string         { return "MaskedEmail/set" }
+func (m *Set) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// SetResponse is the MaskedEmail/set response.
+type SetResponse struct {
+	Account    jmap.ID                    `json:"accountId,omitempty"`
+	OldState   string                     `json:"oldState,omitempty"`
+	NewState   string                     `json:"newState,omitempty"`
+	Created    map[jmap.ID]*MaskedEmail   `json:"created,omitempty"`
+	Updated    map[jmap.ID]*MaskedEmail   `json:"updated,omitempty"`
+	NotCreated map[jmap.ID]*jmap.SetError `json:"notCreated,omitempty"`
+	NotUpdated map[jmap.ID]*jmap.SetError `json:"notUpdated,omitempty"`
+}
+
+func newSetResponse() jmap.MethodResponse { return &SetResponse{} }
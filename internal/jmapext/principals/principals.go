@@ -0,0 +1,51 @@
+// Package principals implements the Principal/get method of JMAP Sharing
+// (RFC 9670), which lists the users, groups, and resources data can be
+// shared with. The upstream jmap library does not provide this capability,
+// so the method and response types are registered here.
+package principals
+
+import "github.com/mikluko/jmap"
+
+// URI is the JMAP Sharing principals capability.
+const URI jmap.URI = "urn:ietf:params:jmap:principals"
+
+func init() {
+	jmap.RegisterCapability(&Capability{})
+	jmap.RegisterMethod("Principal/get", newGetResponse)
+}
+
+// Capability is the (empty) session-level principals capability object.
+type Capability struct{}
+
+func (c *Capability) URI() jmap.URI        { return URI }
+func (c *Capability) New() jmap.Capability { return &Capability{} }
+
+// Principal is a Principal object (RFC 9670 §2).
+type Principal struct {
+	ID          jmap.ID `json:"id,omitempty"`
+	Type        string  `json:"type,omitempty"` // individual, group, resource, location, other
+	Name        string  `json:"name,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Email       string  `json:"email,omitempty"`
+	TimeZone    string  `json:"timeZone,omitempty"`
+}
+
+// Get is Principal/get.
+type Get struct {
+	Account    jmap.ID   `json:"accountId,omitempty"`
+	IDs        []jmap.ID `json:"ids,omitempty"`
+	Properties []string  `json:"properties,omitempty"`
+}
+
+func (m *Get) Name() string         { return "Principal/get" }
+func (m *Get) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// GetResponse is the Principal/get response.
+type GetResponse struct {
+	Account  jmap.ID      `json:"accountId,omitempty"`
+	State    string       `json:"state,omitempty"`
+	List     []*Principal `json:"list,omitempty"`
+	NotFound []jmap.ID    `json:"notFound,omitempty"`
+}
+
+func newGetResponse() jmap.MethodResponse { return &GetResponse{} }
@@ -0,0 +1,53 @@
+// Package quota implements the JMAP for Quotas (RFC 9425) Quota/get method.
+// The upstream jmap library does not provide this capability, so the method
+// and response types are registered here.
+package quota
+
+import "github.com/mikluko/jmap"
+
+// URI is the JMAP Quotas capability.
+const URI jmap.URI = "urn:ietf:params:jmap:quota"
+
+func init() {
+	jmap.RegisterCapability(&Capability{})
+	jmap.RegisterMethod("Quota/get", newGetResponse)
+}
+
+// Capability is the (empty) session-level quota capability object.
+type Capability struct{}
+
+func (c *Capability) URI() jmap.URI        { return URI }
+func (c *Capability) New() jmap.Capability { return &Capability{} }
+
+// Quota is a Quota object (RFC 9425 §4).
+type Quota struct {
+	ID           jmap.ID  `json:"id,omitempty"`
+	ResourceType string   `json:"resourceType,omitempty"` // count, octets
+	Used         uint64   `json:"used"`
+	HardLimit    uint64   `json:"hardLimit"`
+	WarnLimit    *uint64  `json:"warnLimit,omitempty"`
+	SoftLimit    *uint64  `json:"softLimit,omitempty"`
+	Scope        string   `json:"scope,omitempty"` // account, domain, global
+	Name         string   `json:"name,omitempty"`
+	Types        []string `json:"types,omitempty"` // data types counted, e.g. Mail
+	Description  string   `json:"description,omitempty"`
+}
+
+// Get is Quota/get.
+type Get struct {
+	Account jmap.ID   `json:"accountId,omitempty"`
+	IDs     []jmap.ID `json:"ids,omitempty"`
+}
+
+func (m *Get) Name() string         { return "Quota/get" }
+func (m *Get) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// GetResponse is the Quota/get response.
+type GetResponse struct {
+	Account  jmap.ID   `json:"accountId,omitempty"`
+	State    string    `json:"state,omitempty"`
+	List     []*Quota  `json:"list,omitempty"`
+	NotFound []jmap.ID `json:"notFound,omitempty"`
+}
+
+func newGetResponse() jmap.MethodResponse { return &GetResponse{} }
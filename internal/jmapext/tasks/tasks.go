@@ -0,0 +1,144 @@
+// Package tasks implements the subset of JMAP for Tasks
+// (draft-ietf-jmap-tasks) that jmap-mcp needs: listing task lists and
+// tasks, and creating tasks. The upstream jmap library does not provide
+// this capability, so the method and response types are registered here.
+package tasks
+
+import "github.com/mikluko/jmap"
+
+// URI is the JMAP Tasks capability.
+const URI jmap.URI = "urn:ietf:params:jmap:tasks"
+
+func init() {
+	jmap.RegisterCapability(&Capability{})
+	jmap.RegisterMethod("TaskList/get", newTaskListGetResponse)
+	jmap.RegisterMethod("Task/query", newQueryResponse)
+	jmap.RegisterMethod("Task/get", newGetResponse)
+	jmap.RegisterMethod("Task/set", newSetResponse)
+}
+
+// Capability is the session-level tasks capability object; jmap-mcp reads
+// none of its fields.
+type Capability struct{}
+
+func (c *Capability) URI() jmap.URI        { return URI }
+func (c *Capability) New() jmap.Capability { return &Capability{} }
+
+// TaskList is a TaskList object, reduced to the properties jmap-mcp uses.
+type TaskList struct {
+	ID        jmap.ID `json:"id,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	IsDefault bool    `json:"isDefault,omitempty"`
+}
+
+// Task is a Task (a JSCalendar Task, RFC 8984), reduced to the properties
+// needed to list and create simple tasks.
+type Task struct {
+	Type        string  `json:"@type,omitempty"` // "Task" when creating
+	ID          jmap.ID `json:"id,omitempty"`
+	TaskListID  jmap.ID `json:"taskListId,omitempty"`
+	Title       string  `json:"title,omitempty"`
+	Description string  `json:"description,omitempty"`
+
+	// Due is a LocalDateTime ("2025-01-02T17:00:00") in TimeZone, or
+	// floating when TimeZone is nil; ShowWithoutTime marks a due date.
+	Due             string  `json:"due,omitempty"`
+	TimeZone        *string `json:"timeZone,omitempty"`
+	ShowWithoutTime bool    `json:"showWithoutTime,omitempty"`
+
+	// Progress is needs-action, in-process, completed, failed, or
+	// cancelled.
+	Progress string `json:"progress,omitempty"`
+	Priority int    `json:"priority,omitempty"` // 1 highest to 9 lowest, 0 undefined
+}
+
+// TaskListGet is TaskList/get.
+type TaskListGet struct {
+	Account    jmap.ID   `json:"accountId,omitempty"`
+	IDs        []jmap.ID `json:"ids,omitempty"`
+	Properties []string  `json:"properties,omitempty"`
+}
+
+func (m *TaskListGet) Name() string         { return "TaskList/get" }
+func (m *TaskListGet) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// TaskListGetResponse is the TaskList/get response.
+type TaskListGetResponse struct {
+	Account  jmap.ID     `json:"accountId,omitempty"`
+	State    string      `json:"state,omitempty"`
+	List     []*TaskList `json:"list,omitempty"`
+	NotFound []jmap.ID   `json:"notFound,omitempty"`
+}
+
+func newTaskListGetResponse() jmap.MethodResponse { return &TaskListGetResponse{} }
+
+// FilterCondition is a Task/query filter.
+type FilterCondition struct {
+	InTaskLists []jmap.ID `json:"inTaskLists,omitempty"`
+}
+
+// Query is Task/query.
+type Query struct {
+	Account jmap.ID          `json:"accountId,omitempty"`
+	Filter  *FilterCondition `json:"filter,omitempty"`
+	Limit   uint64           `json:"limit,omitempty"`
+}
+
+func (m *Query) Name() string         { return "Task/query" }
+func (m *Query) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// QueryResponse is the Task/query response.
+type QueryResponse struct {
+	Account jmap.ID   `json:"accountId,omitempty"`
+	IDs     []jmap.ID `json:"ids,omitempty"`
+	Total   uint64    `json:"total,omitempty"`
+}
+
+func newQueryResponse() jmap.MethodResponse { return &QueryResponse{} }
+
+// Get is Task/get.
+type Get struct {
+	Account      jmap.ID               `json:"accountId,omitempty"`
+	IDs          []jmap.ID             `json:"ids,omitempty"`
+	Properties   []string              `json:"properties,omitempty"`
+	ReferenceIDs *jmap.ResultReference `json:"#ids,omitempty"`
+}
+
+func (m *Get) Name() string         { return "Task/get" }
+func (m *Get) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// GetResponse is the Task/get response.
+type GetResponse struct {
+	Account  jmap.ID   `json:"accountId,omitempty"`
+	State    string    `json:"state,omitempty"`
+	List     []*Task   `json:"list,omitempty"`
+	NotFound []jmap.ID `json:"notFound,omitempty"`
+}
+
+func newGetResponse() jmap.MethodResponse { return &GetResponse{} }
+
+// Set is Task/set.
+type Set struct {
+	Account jmap.ID                `json:"accountId,omitempty"`
+	Create  map[jmap.ID]*Task      `json:"create,omitempty"`
+	Update  map[jmap.ID]jmap.Patch `json:"update,omitempty"`
+	Destroy []jmap.ID              `json:"destroy,omitempty"`
+}
+
+func (m *Set) Name() string         { return "Task/set" }
+func (m *Set) Requires() []jmap.URI { return []jmap.URI{URI} }
+
+// SetResponse is the Task/set response.
+type SetResponse struct {
+	Account      jmap.ID                    `json:"accountId,omitempty"`
+	OldState     string                     `json:"oldState,omitempty"`
+	NewState     string                     `json:"newState,omitempty"`
+	Created      map[jmap.ID]*Task          `json:"created,omitempty"`
+	Updated      map[jmap.ID]*Task          `json:"updated,omitempty"`
+	Destroyed    []jmap.ID                  `json:"destroyed,omitempty"`
+	NotCreated   map[jmap.ID]*jmap.SetError `json:"notCreated,omitempty"`
+	NotUpdated   map[jmap.ID]*jmap.SetError `json:"notUpdated,omitempty"`
+	NotDestroyed map[jmap.ID]*jmap.SetError `json:"notDestroyed,omitempty"`
+}
+
+func newSetResponse() jmap.MethodResponse { return &SetResponse{} }
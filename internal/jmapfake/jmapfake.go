@@ -0,0 +1,1234 @@
+// Package jmapfake is an in-process JMAP server for unit-testing tool
+// handlers without network access. It keeps objects of any type as JSON
+// maps and implements the generic /get, /set, /query, /queryChanges, and
+// /changes methods over them, plus the Email/query filters,
+// maxBodyValueBytes, result references, blob uploads and downloads,
+// Email/import, an event source, and RFC 8887 WebSocket (see
+// EnableWebSocket) the tools rely on. Dial returns a *jmap.Client wired to the fake, so a Server configured
+// with it runs handlers unchanged.
+//
+// The fake is deliberately shallow: it does not validate arguments, enforce
+// capabilities, or derive objects from each other (adding an Email does not
+// touch its Thread). Every Add and /set advances the single state counter
+// that /changes reports against. Tests seed objects with Add, script
+// failures with FailNext, and inspect results with Object and Calls.
+package jmapfake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+)
+
+// AccountID is the single account the fake serves.
+const AccountID = "a1"
+
+// Base URL of the fake. It is never resolved: requests are served in
+// process by the client's transport.
+const baseURL = "http://jmapfake.invalid"
+
+// SessionURL is the session endpoint to configure the Server with.
+const SessionURL = baseURL + "/session"
+
+// Capability URIs advertised by default.
+const (
+	CoreURI       = "urn:ietf:params:jmap:core"
+	MailURI       = "urn:ietf:params:jmap:mail"
+	SubmissionURI = "urn:ietf:params:jmap:submission"
+)
+
+// Server is an in-memory JMAP server.
+type Server struct {
+	mu           sync.Mutex
+	capabilities map[string]any
+	objects      map[string]map[string]map[string]any // type → id → object
+	blobs        map[string][]byte
+	failures     map[string][]*MethodError // method → errors to return, in order
+	injected     map[string][]invocation   // method → responses to send before its next response
+	calls        []string
+	nextID       int
+	state        int
+	changes      []change      // in state order, for /changes
+	pushed       int           // state last sent on the event source
+	noPush       bool          // omit eventSourceUrl from the session
+	ws           *wsListener   // WebSocket endpoint, started by the first DialWebSocket
+	wsRequests   int           // requests served over WebSocket
+	stateChanged chan struct{} // closed and replaced when state advances
+}
+
+// change records that an object was created, updated, or destroyed when
+// the server moved to state.
+type change struct {
+	state int
+	typ   string
+	id    string
+	kind  string // created, updated, destroyed
+}
+
+// MethodError is a scripted method-level error.
+type MethodError struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// New returns an empty server advertising core, mail, and submission.
+func New() *Server {
+	return &Server{
+		capabilities: map[string]any{
+			CoreURI: map[string]any{
+				"maxSizeUpload":         50000000,
+				"maxConcurrentUpload":   4,
+				"maxSizeRequest":        10000000,
+				"maxConcurrentRequests": 4,
+				"maxCallsInRequest":     16,
+				"maxObjectsInGet":       500,
+				"maxObjectsInSet":       500,
+				"collationAlgorithms":   []string{"i;ascii-casemap"},
+			},
+			MailURI:       map[string]any{},
+			SubmissionURI: map[string]any{},
+		},
+		objects:  make(map[string]map[string]map[string]any),
+		blobs:    make(map[string][]byte),
+		failures: make(map[string][]*MethodError),
+		injected: make(map[string][]invocation),
+	}
+}
+
+// AddCapability advertises an extra capability (e.g. sieve or a vendor
+// extension) with the given session value.
+func (s *Server) AddCapability(uri string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value == nil {
+		value = map[string]any{}
+	}
+	s.capabilities[uri] = value
+}
+
+// DisablePush drops eventSourceUrl from the session, as on servers
+// without push.
+func (s *Server) DisablePush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noPush = true
+}
+
+// Add stores an object of typ ("Email", "Mailbox", ...) and returns its ID,
+// taken from obj["id"] or assigned. obj is stored as its JSON form, so
+// structs from the jmap packages and plain maps both work.
+func (s *Server) Add(typ string, obj any) string {
+	m, err := toMap(obj)
+	if err != nil {
+		panic(fmt.Sprintf("jmapfake: add %s: %v", typ, err))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kind := "created"
+	if id, _ := m["id"].(string); s.objects[typ][id] != nil {
+		kind = "updated"
+	}
+	s.bump()
+	id := s.put(typ, m)
+	s.logChange(typ, id, kind)
+	return id
+}
+
+// Object returns a copy of the stored object, or nil.
+func (s *Server) Object(typ, id string) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[typ][id]
+	if !ok {
+		return nil
+	}
+	return clone(obj)
+}
+
+// Objects returns the IDs of all stored objects of typ, sorted.
+func (s *Server) Objects(typ string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.objects[typ]))
+	for id := range s.objects[typ] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// AddBlob stores downloadable content and returns its blob ID.
+func (s *Server) AddBlob(data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	id := "B" + strconv.Itoa(s.nextID)
+	s.blobs[id] = data
+	return id
+}
+
+// Blob returns stored or uploaded content, or nil.
+func (s *Server) Blob(id string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blobs[id]
+}
+
+// FailNext makes the next call of method return a method error of errType.
+// Repeated calls queue further failures.
+func (s *Server) FailNext(method, errType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], &MethodError{Type: errType})
+}
+
+// InjectNext makes the response to the next call of method be preceded by
+// an invocation of name with args under the same call ID, as servers that
+// add implicit or vendor responses do. name need not be a known method.
+func (s *Server) InjectNext(method, name string, args map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injected[method] = append(s.injected[method], invocation{name: name, args: args})
+}
+
+// Calls returns the names of methods called so far, in order.
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+// Dial returns a client whose requests are served by s in process. The
+// session URL and token are ignored.
+func (s *Server) Dial(_ context.Context, _, _ string) (*jmap.Client, error) {
+	client := &jmap.Client{
+		SessionEndpoint: SessionURL,
+		HttpClient:      &http.Client{Transport: transport{s}},
+	}
+	if err := client.Authenticate(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// transport serves requests with the fake's handler.
+type transport struct{ h http.Handler }
+
+func (t transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+// ServeHTTP implements the session, API, and download endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/session":
+		s.serveSession(w)
+	case r.URL.Path == "/api" && r.Method == http.MethodPost:
+		s.serveAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/download/"):
+		s.serveDownload(w, r)
+	case strings.HasPrefix(r.URL.Path, "/upload/") && r.Method == http.MethodPost:
+		s.serveUpload(w, r)
+	case r.URL.Path == "/events":
+		s.serveEvents(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveSession(w http.ResponseWriter) {
+	s.mu.Lock()
+	caps := make(map[string]any, len(s.capabilities))
+	accountCaps := make(map[string]any, len(s.capabilities))
+	primary := make(map[string]string, len(s.capabilities))
+	for uri, v := range s.capabilities {
+		caps[uri] = v
+		accountCaps[uri] = map[string]any{}
+		primary[uri] = AccountID
+	}
+	eventSourceURL := baseURL + "/events"
+	if s.noPush {
+		eventSourceURL = ""
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"capabilities": caps,
+		"accounts": map[string]any{
+			AccountID: map[string]any{
+				"name":                "test@example.org",
+				"isPersonal":          true,
+				"isReadOnly":          false,
+				"accountCapabilities": accountCaps,
+			},
+		},
+		"primaryAccounts": primary,
+		"username":        "test@example.org",
+		"apiUrl":          baseURL + "/api",
+		"downloadUrl":     baseURL + "/download/{accountId}/{blobId}/{name}?type={type}",
+		"uploadUrl":       baseURL + "/upload/{accountId}/",
+		"eventSourceUrl":  eventSourceURL,
+		"state":           "fake",
+	})
+}
+
+// eventWait is how long the event source waits for a state change before
+// closing the stream; clients reconnect.
+const eventWait = 500 * time.Millisecond
+
+// serveEvents answers an event source connection with one StateChange for
+// the types changed since the last one sent, waiting up to eventWait for a
+// change. Changes made while no client is connected are sent on the next
+// connection, so a test cannot lose them to reconnect timing.
+func (s *Server) serveEvents(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	deadline := time.After(eventWait)
+	for {
+		s.mu.Lock()
+		if s.state > s.pushed {
+			types := map[string]string{}
+			for _, c := range s.changes {
+				if c.state > s.pushed {
+					types[c.typ] = strconv.Itoa(s.state)
+				}
+			}
+			s.pushed = s.state
+			s.mu.Unlock()
+			data, _ := json.Marshal(map[string]any{
+				"@type":   "StateChange",
+				"changed": map[string]any{AccountID: types},
+			})
+			fmt.Fprintf(w, "event: state\ndata: %s\n\n", data)
+			return
+		}
+		ch := s.stateChangedLocked()
+		s.mu.Unlock()
+		select {
+		case <-ch:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// bump advances the state and wakes event source connections. The caller
+// holds s.mu.
+func (s *Server) bump() {
+	s.state++
+	if s.stateChanged != nil {
+		close(s.stateChanged)
+		s.stateChanged = nil
+	}
+}
+
+func (s *Server) stateChangedLocked() chan struct{} {
+	if s.stateChanged == nil {
+		s.stateChanged = make(chan struct{})
+	}
+	return s.stateChanged
+}
+
+func (s *Server) serveDownload(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+	if len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	data, ok := s.blobs[parts[1]]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := s.AddBlob(data)
+	writeJSON(w, map[string]any{
+		"accountId": AccountID,
+		"blobId":    id,
+		"type":      r.Header.Get("Content-Type"),
+		"size":      len(data),
+	})
+}
+
+// invocation is a method call or response: [name, args, callId].
+type invocation struct {
+	name   string
+	args   map[string]any
+	callID string
+}
+
+func (i invocation) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{i.name, i.args, i.callID})
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MethodCalls [][]json.RawMessage `json:"methodCalls"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := s.execute(req.MethodCalls)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// execute runs a request's method calls and returns the response object.
+func (s *Server) execute(methodCalls [][]json.RawMessage) (map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var responses []invocation
+	for _, raw := range methodCalls {
+		if len(raw) != 3 {
+			return nil, fmt.Errorf("malformed invocation")
+		}
+		var call invocation
+		if err := json.Unmarshal(raw[0], &call.name); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw[1], &call.args); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw[2], &call.callID); err != nil {
+			return nil, err
+		}
+		s.calls = append(s.calls, call.name)
+		if queue := s.injected[call.name]; len(queue) > 0 {
+			s.injected[call.name] = queue[1:]
+			inv := queue[0]
+			inv.callID = call.callID
+			responses = append(responses, inv)
+		}
+		responses = append(responses, s.call(call, responses)...)
+	}
+	return map[string]any{"methodResponses": responses, "sessionState": "fake"}, nil
+}
+
+// call executes one method, returning its response and any implicit ones.
+func (s *Server) call(call invocation, prior []invocation) []invocation {
+	errorResponse := func(me *MethodError) []invocation {
+		args, _ := toMap(me)
+		return []invocation{{name: "error", args: args, callID: call.callID}}
+	}
+
+	if queue := s.failures[call.name]; len(queue) > 0 {
+		s.failures[call.name] = queue[1:]
+		return errorResponse(queue[0])
+	}
+	if err := resolveReferences(call.args, prior); err != nil {
+		return errorResponse(&MethodError{Type: "invalidResultReference", Description: err.Error()})
+	}
+
+	typ, method, _ := strings.Cut(call.name, "/")
+	var args map[string]any
+	var extra []invocation
+	switch method {
+	case "get":
+		args = s.get(typ, call.args)
+	case "set":
+		args = s.set(typ, call.args)
+		switch typ {
+		case "EmailSubmission":
+			if update := s.onSuccessUpdateEmail(call.args, args); update != nil {
+				extra = append(extra, invocation{name: "Email/set", args: update, callID: call.callID})
+			}
+		case "SieveScript":
+			s.onSuccessActivateScript(call.args, args)
+		}
+	case "query":
+		var me *MethodError
+		if args, me = s.query(typ, call.args); me != nil {
+			return errorResponse(me)
+		}
+	case "queryChanges":
+		var me *MethodError
+		if args, me = s.queryChanges(typ, call.args); me != nil {
+			return errorResponse(me)
+		}
+	case "lookup":
+		args = s.lookup(call.args)
+	case "import":
+		args = s.importEmails(call.args)
+	case "changes":
+		var me *MethodError
+		if args, me = s.changesSince(typ, call.args); me != nil {
+			return errorResponse(me)
+		}
+	default:
+		return errorResponse(&MethodError{Type: "unknownMethod"})
+	}
+	return append([]invocation{{name: call.name, args: args, callID: call.callID}}, extra...)
+}
+
+// lookup implements Blob/lookup: a blob exists if it was uploaded or an
+// object of one of typeNames references it by a blobId property at any
+// depth, and each such object is listed under its type.
+func (s *Server) lookup(args map[string]any) map[string]any {
+	typeNames, _ := args["typeNames"].([]any)
+	ids, _ := args["ids"].([]any)
+	list := []any{}
+	notFound := []any{}
+	for _, v := range ids {
+		blobID, _ := v.(string)
+		matched := map[string]any{}
+		found := s.blobs[blobID] != nil
+		for _, t := range typeNames {
+			typ, _ := t.(string)
+			objIDs := []string{}
+			for id, obj := range s.objects[typ] {
+				if referencesBlob(obj, blobID) {
+					objIDs = append(objIDs, id)
+				}
+			}
+			sort.Strings(objIDs)
+			matchedIDs := []any{}
+			for _, id := range objIDs {
+				matchedIDs = append(matchedIDs, id)
+			}
+			matched[typ] = matchedIDs
+			found = found || len(objIDs) > 0
+		}
+		if !found {
+			notFound = append(notFound, blobID)
+			continue
+		}
+		list = append(list, map[string]any{"id": blobID, "matchedIds": matched})
+	}
+	return map[string]any{"accountId": AccountID, "list": list, "notFound": notFound}
+}
+
+// importEmails implements Email/import: each entry whose blob was uploaded
+// becomes an Email with its blobId, mailboxIds, keywords, and the Subject
+// header of the blob; nothing else of the message is parsed.
+func (s *Server) importEmails(args map[string]any) map[string]any {
+	oldState := strconv.Itoa(s.state)
+	s.bump()
+	created := map[string]any{}
+	notCreated := map[string]any{}
+	emails, _ := args["emails"].(map[string]any)
+	for cid, v := range emails {
+		entry, _ := v.(map[string]any)
+		blobID, _ := entry["blobId"].(string)
+		data, ok := s.blobs[blobID]
+		if !ok {
+			notCreated[cid] = map[string]any{"type": "blobNotFound", "notFound": []any{blobID}}
+			continue
+		}
+		obj := map[string]any{
+			"blobId":     blobID,
+			"mailboxIds": entry["mailboxIds"],
+			"keywords":   entry["keywords"],
+			"size":       len(data),
+		}
+		if msg, err := mail.ReadMessage(bytes.NewReader(data)); err == nil {
+			obj["subject"] = msg.Header.Get("Subject")
+		}
+		id := s.put("Email", obj)
+		s.logChange("Email", id, "created")
+		created[cid] = map[string]any{"id": id, "blobId": blobID, "size": len(data)}
+	}
+	resp := map[string]any{
+		"accountId": AccountID,
+		"oldState":  oldState,
+		"newState":  strconv.Itoa(s.state),
+		"created":   created,
+	}
+	if len(notCreated) > 0 {
+		resp["notCreated"] = notCreated
+	}
+	return resp
+}
+
+// referencesBlob reports whether v holds a "blobId": blobID at any depth.
+func referencesBlob(v any, blobID string) bool {
+	switch node := v.(type) {
+	case map[string]any:
+		if id, _ := node["blobId"].(string); id == blobID {
+			return true
+		}
+		for _, child := range node {
+			if referencesBlob(child, blobID) {
+				return true
+			}
+		}
+	case []any:
+		for _, child := range node {
+			if referencesBlob(child, blobID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) get(typ string, args map[string]any) map[string]any {
+	store := s.objects[typ]
+	var ids []string
+	if raw, ok := args["ids"].([]any); ok {
+		ids = stringsOf(raw)
+	} else {
+		for id := range store {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+	}
+	var props map[string]bool
+	if raw, ok := args["properties"].([]any); ok {
+		props = map[string]bool{"id": true}
+		for _, p := range stringsOf(raw) {
+			props[p] = true
+		}
+	}
+
+	list := []any{}
+	notFound := []any{}
+	for _, id := range ids {
+		obj, ok := store[id]
+		if !ok {
+			notFound = append(notFound, id)
+			continue
+		}
+		out := clone(obj)
+		if props != nil {
+			for k := range out {
+				if !props[k] {
+					delete(out, k)
+				}
+			}
+		}
+		if limit := intArg(args["maxBodyValueBytes"]); limit > 0 {
+			truncateBodyValues(out, limit)
+		}
+		list = append(list, out)
+	}
+	return map[string]any{
+		"accountId": AccountID,
+		"state":     strconv.Itoa(s.state),
+		"list":      list,
+		"notFound":  notFound,
+	}
+}
+
+func (s *Server) set(typ string, args map[string]any) map[string]any {
+	oldState := strconv.Itoa(s.state)
+	s.bump()
+	resp := map[string]any{
+		"accountId": AccountID,
+		"oldState":  oldState,
+		"newState":  strconv.Itoa(s.state),
+	}
+
+	if create, ok := args["create"].(map[string]any); ok {
+		created := map[string]any{}
+		notCreated := map[string]any{}
+		// A parentId of "#creation-id" refers to another create of this
+		// call; those wait until their parent exists.
+		pending := slices.Sorted(maps.Keys(create))
+		for len(pending) > 0 {
+			var waiting []string
+			for _, cid := range pending {
+				obj, _ := create[cid].(map[string]any)
+				if ref, ok := obj["parentId"].(string); ok && strings.HasPrefix(ref, "#") {
+					parent, ok := created[ref[1:]].(map[string]any)
+					if !ok {
+						waiting = append(waiting, cid)
+						continue
+					}
+					obj["parentId"] = parent["id"]
+				}
+				delete(obj, "id")
+				id := s.put(typ, obj)
+				s.logChange(typ, id, "created")
+				out := map[string]any{"id": id}
+				if blobID, ok := obj["blobId"]; ok {
+					out["blobId"] = blobID
+				}
+				created[cid] = out
+			}
+			if len(waiting) == len(pending) {
+				for _, cid := range waiting {
+					notCreated[cid] = map[string]any{"type": "invalidProperties", "properties": []any{"parentId"}}
+				}
+				break
+			}
+			pending = waiting
+		}
+		resp["created"] = created
+		if len(notCreated) > 0 {
+			resp["notCreated"] = notCreated
+		}
+	}
+	if update, ok := args["update"].(map[string]any); ok {
+		updated := map[string]any{}
+		notUpdated := map[string]any{}
+		for id, v := range update {
+			obj, ok := s.objects[typ][id]
+			if !ok {
+				notUpdated[id] = map[string]any{"type": "notFound"}
+				continue
+			}
+			patch, _ := v.(map[string]any)
+			applyPatch(obj, patch)
+			s.logChange(typ, id, "updated")
+			updated[id] = nil
+		}
+		resp["updated"] = updated
+		if len(notUpdated) > 0 {
+			resp["notUpdated"] = notUpdated
+		}
+	}
+	if destroy, ok := args["destroy"].([]any); ok {
+		destroyed := []any{}
+		notDestroyed := map[string]any{}
+		for _, id := range stringsOf(destroy) {
+			if _, ok := s.objects[typ][id]; !ok {
+				notDestroyed[id] = map[string]any{"type": "notFound"}
+				continue
+			}
+			delete(s.objects[typ], id)
+			s.logChange(typ, id, "destroyed")
+			destroyed = append(destroyed, id)
+		}
+		resp["destroyed"] = destroyed
+		if len(notDestroyed) > 0 {
+			resp["notDestroyed"] = notDestroyed
+		}
+	}
+	return resp
+}
+
+// onSuccessUpdateEmail applies an EmailSubmission/set's email patches for
+// the submissions it created and returns the implicit Email/set response.
+func (s *Server) onSuccessUpdateEmail(args, resp map[string]any) map[string]any {
+	patches, ok := args["onSuccessUpdateEmail"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	created, _ := resp["created"].(map[string]any)
+	create, _ := args["create"].(map[string]any)
+	update := map[string]any{}
+	for ref, patch := range patches {
+		cid, ok := strings.CutPrefix(ref, "#")
+		if !ok || created[cid] == nil {
+			continue
+		}
+		sub, _ := create[cid].(map[string]any)
+		emailID, _ := sub["emailId"].(string)
+		update[emailID] = patch
+	}
+	return s.set("Email", map[string]any{"update": update})
+}
+
+// onSuccessActivateScript makes the script a SieveScript/set names the only
+// active one, resolving a "#creation-id" against the scripts it created.
+func (s *Server) onSuccessActivateScript(args, resp map[string]any) {
+	ref, ok := args["onSuccessActivateScript"].(string)
+	if !ok {
+		return
+	}
+	id := ref
+	if cid, ok := strings.CutPrefix(ref, "#"); ok {
+		created, _ := resp["created"].(map[string]any)
+		out, _ := created[cid].(map[string]any)
+		id, _ = out["id"].(string)
+	}
+	if s.objects["SieveScript"][id] == nil {
+		return
+	}
+	for sid, script := range s.objects["SieveScript"] {
+		script["isActive"] = sid == id
+	}
+}
+
+func (s *Server) logChange(typ, id, kind string) {
+	s.changes = append(s.changes, change{state: s.state, typ: typ, id: id, kind: kind})
+}
+
+// changesSince answers Foo/changes from the change log. An object created
+// and then updated since the given state is reported as created only.
+func (s *Server) changesSince(typ string, args map[string]any) (map[string]any, *MethodError) {
+	since, _ := args["sinceState"].(string)
+	n, err := strconv.Atoi(since)
+	if err != nil || n > s.state {
+		return nil, &MethodError{Type: "cannotCalculateChanges"}
+	}
+	kinds := map[string]string{}
+	var order []string
+	for _, c := range s.changes {
+		if c.typ != typ || c.state <= n {
+			continue
+		}
+		prev, seen := kinds[c.id]
+		if !seen {
+			order = append(order, c.id)
+		}
+		switch {
+		case prev == "created" && c.kind == "updated":
+		case prev == "created" && c.kind == "destroyed":
+			delete(kinds, c.id)
+		default:
+			kinds[c.id] = c.kind
+		}
+	}
+	created, updated, destroyed := []any{}, []any{}, []any{}
+	for _, id := range order {
+		switch kinds[id] {
+		case "created":
+			created = append(created, id)
+		case "updated":
+			updated = append(updated, id)
+		case "destroyed":
+			destroyed = append(destroyed, id)
+		}
+	}
+	return map[string]any{
+		"accountId":      AccountID,
+		"oldState":       since,
+		"newState":       strconv.Itoa(s.state),
+		"hasMoreChanges": false,
+		"created":        created,
+		"updated":        updated,
+		"destroyed":      destroyed,
+	}, nil
+}
+
+func (s *Server) query(typ string, args map[string]any) (map[string]any, *MethodError) {
+	var matched []map[string]any
+	for _, obj := range s.objects[typ] {
+		if matches(obj, args["filter"]) {
+			matched = append(matched, obj)
+		}
+	}
+	sortObjects(matched, args["sort"])
+
+	position := intArg(args["position"])
+	if anchor, _ := args["anchor"].(string); anchor != "" {
+		i := slices.IndexFunc(matched, func(obj map[string]any) bool { return obj["id"] == anchor })
+		if i < 0 {
+			return nil, &MethodError{Type: "anchorNotFound"}
+		}
+		position = max(i+intArg(args["anchorOffset"]), 0)
+	}
+	if position > len(matched) {
+		position = len(matched)
+	}
+	page := matched[position:]
+	if limit := intArg(args["limit"]); limit > 0 && limit < len(page) {
+		page = page[:limit]
+	}
+	ids := []any{}
+	for _, obj := range page {
+		ids = append(ids, obj["id"])
+	}
+
+	resp := map[string]any{
+		"accountId":           AccountID,
+		"queryState":          strconv.Itoa(s.state),
+		"canCalculateChanges": true,
+		"position":            position,
+		"ids":                 ids,
+	}
+	if calc, _ := args["calculateTotal"].(bool); calc {
+		resp["total"] = len(matched)
+	}
+	return resp, nil
+}
+
+// queryChanges answers Foo/queryChanges. The fake keeps no query history:
+// a query is unchanged while the state is the one given, and anything else
+// is cannotCalculateChanges.
+func (s *Server) queryChanges(typ string, args map[string]any) (map[string]any, *MethodError) {
+	since, _ := args["sinceQueryState"].(string)
+	if since != strconv.Itoa(s.state) {
+		return nil, &MethodError{Type: "cannotCalculateChanges"}
+	}
+	resp := map[string]any{
+		"accountId":     AccountID,
+		"oldQueryState": since,
+		"newQueryState": since,
+		"removed":       []any{},
+		"added":         []any{},
+	}
+	if calc, _ := args["calculateTotal"].(bool); calc {
+		n := 0
+		for _, obj := range s.objects[typ] {
+			if matches(obj, args["filter"]) {
+				n++
+			}
+		}
+		resp["total"] = n
+	}
+	return resp, nil
+}
+
+// matches evaluates a FilterOperator or FilterCondition against obj.
+// Unknown conditions match everything.
+func matches(obj map[string]any, filter any) bool {
+	f, ok := filter.(map[string]any)
+	if !ok {
+		return true
+	}
+	if op, ok := f["operator"].(string); ok {
+		conds, _ := f["conditions"].([]any)
+		switch op {
+		case "AND":
+			for _, c := range conds {
+				if !matches(obj, c) {
+					return false
+				}
+			}
+			return true
+		case "OR":
+			for _, c := range conds {
+				if matches(obj, c) {
+					return true
+				}
+			}
+			return false
+		case "NOT":
+			for _, c := range conds {
+				if matches(obj, c) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	for key, want := range f {
+		switch key {
+		case "inMailbox":
+			boxes, _ := obj["mailboxIds"].(map[string]any)
+			if boxes[fmt.Sprint(want)] != true {
+				return false
+			}
+		case "inMailboxOtherThan":
+			boxes, _ := obj["mailboxIds"].(map[string]any)
+			excluded, _ := want.([]any)
+			other := false
+			for id := range boxes {
+				if !slices.Contains(stringsOf(excluded), id) {
+					other = true
+				}
+			}
+			if !other {
+				return false
+			}
+		case "hasKeyword", "notKeyword":
+			keywords, _ := obj["keywords"].(map[string]any)
+			if (keywords[fmt.Sprint(want)] == true) != (key == "hasKeyword") {
+				return false
+			}
+		case "subject":
+			if !containsFold(fmt.Sprint(obj["subject"]), fmt.Sprint(want)) {
+				return false
+			}
+		case "from", "to", "cc", "bcc":
+			if !containsFold(addressText(obj[key]), fmt.Sprint(want)) {
+				return false
+			}
+		case "text":
+			text := fmt.Sprint(obj["subject"]) + " " + fmt.Sprint(obj["preview"]) + " " + addressText(obj["from"]) + " " + addressText(obj["to"])
+			if !containsFold(text, fmt.Sprint(want)) {
+				return false
+			}
+		case "header":
+			// [name] matches presence, [name, value] a value substring.
+			cond := stringsOf(want.([]any))
+			headers, _ := obj["headers"].([]any)
+			found := false
+			for _, h := range headers {
+				h, _ := h.(map[string]any)
+				if len(cond) > 0 && strings.EqualFold(fmt.Sprint(h["name"]), cond[0]) &&
+					(len(cond) < 2 || containsFold(fmt.Sprint(h["value"]), cond[1])) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		case "after", "before":
+			if _, ok := obj["utcStart"]; ok {
+				// CalendarEvent: it ends after after and starts before before.
+				bound, err := time.Parse(time.RFC3339, fmt.Sprint(want))
+				start, err1 := time.Parse(time.RFC3339, fmt.Sprint(obj["utcStart"]))
+				end, err2 := time.Parse(time.RFC3339, fmt.Sprint(obj["utcEnd"]))
+				if err != nil || err1 != nil || err2 != nil ||
+					key == "after" && !end.After(bound) || key == "before" && !start.Before(bound) {
+					return false
+				}
+				continue
+			}
+			// after is inclusive, before exclusive (RFC 8621 section 4.4.1).
+			at, err1 := time.Parse(time.RFC3339, fmt.Sprint(obj["receivedAt"]))
+			bound, err2 := time.Parse(time.RFC3339, fmt.Sprint(want))
+			if err1 != nil || err2 != nil {
+				return false
+			}
+			if key == "after" && at.Before(bound) || key == "before" && !at.Before(bound) {
+				return false
+			}
+		case "kind", "uid":
+			if fmt.Sprint(obj[key]) != fmt.Sprint(want) {
+				return false
+			}
+		case "name":
+			// A ContactCard name is an object; match its full form.
+			name := obj["name"]
+			if n, ok := name.(map[string]any); ok {
+				name = n["full"]
+			}
+			if !containsFold(fmt.Sprint(name), fmt.Sprint(want)) {
+				return false
+			}
+		case "inCalendars":
+			cals, _ := obj["calendarIds"].(map[string]any)
+			ids, _ := want.([]any)
+			if !slices.ContainsFunc(stringsOf(ids), func(id string) bool { return cals[id] == true }) {
+				return false
+			}
+		case "inTaskLists":
+			ids, _ := want.([]any)
+			if !slices.Contains(stringsOf(ids), fmt.Sprint(obj["taskListId"])) {
+				return false
+			}
+		case "emailIds":
+			ids, _ := want.([]any)
+			if !slices.Contains(stringsOf(ids), fmt.Sprint(obj["emailId"])) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortObjects orders objects by the first comparator, newest receivedAt
+// first by default, breaking ties by ID.
+func sortObjects(list []map[string]any, comparators any) {
+	property, ascending := "receivedAt", false
+	if cs, ok := comparators.([]any); ok && len(cs) > 0 {
+		if c, ok := cs[0].(map[string]any); ok {
+			property = fmt.Sprint(c["property"])
+			ascending, _ = c["isAscending"].(bool)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := fmt.Sprint(list[i][property]), fmt.Sprint(list[j][property])
+		if a == b {
+			return fmt.Sprint(list[i]["id"]) < fmt.Sprint(list[j]["id"])
+		}
+		return (a < b) == ascending
+	})
+}
+
+// resolveReferences replaces "#name" arguments (result references) with
+// the values they point to in earlier responses.
+func resolveReferences(args map[string]any, prior []invocation) error {
+	for key, v := range args {
+		name, ok := strings.CutPrefix(key, "#")
+		if !ok {
+			continue
+		}
+		ref, _ := v.(map[string]any)
+		resultOf, _ := ref["resultOf"].(string)
+		method, _ := ref["name"].(string)
+		path, _ := ref["path"].(string)
+
+		var source map[string]any
+		for _, p := range prior {
+			if p.callID == resultOf && p.name == method {
+				source = p.args
+				break
+			}
+		}
+		if source == nil {
+			return fmt.Errorf("no %s response for call %s", method, resultOf)
+		}
+		value := evalPointer(source, splitPointer(path))
+		if value == nil {
+			return fmt.Errorf("path %s not found in %s response", path, method)
+		}
+		delete(args, key)
+		args[name] = value
+	}
+	return nil
+}
+
+// evalPointer evaluates a JSON pointer with the JMAP "*" extension, which
+// maps over arrays and flattens the results.
+func evalPointer(v any, path []string) any {
+	if len(path) == 0 {
+		return v
+	}
+	switch node := v.(type) {
+	case map[string]any:
+		return evalPointer(node[unescapePointer(path[0])], path[1:])
+	case []any:
+		if path[0] != "*" {
+			i, err := strconv.Atoi(path[0])
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			return evalPointer(node[i], path[1:])
+		}
+		out := []any{}
+		for _, item := range node {
+			switch r := evalPointer(item, path[1:]).(type) {
+			case nil:
+			case []any:
+				out = append(out, r...)
+			default:
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// applyPatch applies a PatchObject: each key is a JSON pointer into obj,
+// and a null value removes the property.
+func applyPatch(obj map[string]any, patch map[string]any) {
+	for path, value := range patch {
+		parts := strings.Split(path, "/")
+		node := obj
+		for _, p := range parts[:len(parts)-1] {
+			p = unescapePointer(p)
+			next, ok := node[p].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				node[p] = next
+			}
+			node = next
+		}
+		last := unescapePointer(parts[len(parts)-1])
+		if value == nil {
+			delete(node, last)
+		} else {
+			node[last] = value
+		}
+	}
+}
+
+// truncateBodyValues cuts Email bodyValues to limit bytes, as a server does
+// for maxBodyValueBytes (without regard for UTF-8 boundaries).
+func truncateBodyValues(obj map[string]any, limit int) {
+	values, _ := obj["bodyValues"].(map[string]any)
+	for _, v := range values {
+		bv, _ := v.(map[string]any)
+		if text, ok := bv["value"].(string); ok && len(text) > limit {
+			bv["value"] = text[:limit]
+			bv["isTruncated"] = true
+		}
+	}
+}
+
+// put stores obj under its ID, assigning one when missing. Callers hold mu.
+func (s *Server) put(typ string, obj map[string]any) string {
+	id, _ := obj["id"].(string)
+	if id == "" {
+		s.nextID++
+		id = "M" + strconv.Itoa(s.nextID)
+		obj["id"] = id
+	}
+	if s.objects[typ] == nil {
+		s.objects[typ] = make(map[string]map[string]any)
+	}
+	s.objects[typ][id] = obj
+	return id
+}
+
+func toMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func clone(obj map[string]any) map[string]any {
+	out, _ := toMap(obj)
+	return out
+}
+
+func stringsOf(list []any) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		out = append(out, fmt.Sprint(v))
+	}
+	return out
+}
+
+func intArg(v any) int {
+	f, _ := v.(float64)
+	return int(f)
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// addressText flattens an EmailAddress list to "name <email>" text.
+func addressText(v any) string {
+	list, _ := v.([]any)
+	var sb strings.Builder
+	for _, a := range list {
+		addr, _ := a.(map[string]any)
+		fmt.Fprintf(&sb, "%v <%v> ", addr["name"], addr["email"])
+	}
+	return sb.String()
+}
+
+// splitPointer splits a JSON pointer into its (still escaped) tokens.
+func splitPointer(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func unescapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.Copy(w, &buf)
+}
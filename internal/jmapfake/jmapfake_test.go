@@ -0,0 +1,113 @@
+package jmapfake
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestEvalPointer(t *testing.T) {
+	resp := map[string]any{
+		"ids": []any{"a", "b"},
+		"list": []any{
+			map[string]any{"id": "a", "threadId": "t1"},
+			map[string]any{"id": "b", "threadId": "t2"},
+		},
+	}
+	tests := []struct {
+		path string
+		want any
+	}{
+		{"/ids", []any{"a", "b"}},
+		{"/list/*/threadId", []any{"t1", "t2"}},
+		{"/list/1/id", "b"},
+		{"/missing", nil},
+	}
+	for _, tt := range tests {
+		if got := evalPointer(resp, splitPointer(tt.path)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	obj := map[string]any{
+		"keywords":   map[string]any{"$seen": true},
+		"mailboxIds": map[string]any{"inbox": true},
+	}
+	applyPatch(obj, map[string]any{
+		"keywords/$seen":    nil,
+		"keywords/$flagged": true,
+		"mailboxIds":        map[string]any{"archive": true},
+	})
+	want := map[string]any{
+		"keywords":   map[string]any{"$flagged": true},
+		"mailboxIds": map[string]any{"archive": true},
+	}
+	if !reflect.DeepEqual(obj, want) {
+		t.Errorf("got %v, want %v", obj, want)
+	}
+}
+
+func TestChangesSince(t *testing.T) {
+	s := New()
+	s.Add("Thread", map[string]any{"id": "T1"})
+	since := strconv.Itoa(s.state)
+	s.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1"}})
+	s.Add("Thread", map[string]any{"id": "T2"})
+	s.Add("Email", map[string]any{"id": "e1"})
+
+	got, me := s.changesSince("Thread", map[string]any{"sinceState": since})
+	if me != nil {
+		t.Fatalf("changes: %s", me.Type)
+	}
+	if !reflect.DeepEqual(got["updated"], []any{"T1"}) || !reflect.DeepEqual(got["created"], []any{"T2"}) {
+		t.Errorf("changes = %v", got)
+	}
+
+	if _, me := s.changesSince("Thread", map[string]any{"sinceState": "999"}); me == nil || me.Type != "cannotCalculateChanges" {
+		t.Errorf("future state accepted: %v", me)
+	}
+}
+
+func TestImportEmails(t *testing.T) {
+	s := New()
+	blobID := s.AddBlob([]byte("Subject: Hello\r\n\r\nBody\r\n"))
+
+	got := s.importEmails(map[string]any{"emails": map[string]any{
+		"a": map[string]any{"blobId": blobID, "mailboxIds": map[string]any{"drafts": true}},
+		"b": map[string]any{"blobId": "missing"},
+	}})
+	created, _ := got["created"].(map[string]any)
+	a, _ := created["a"].(map[string]any)
+	id, _ := a["id"].(string)
+	if obj := s.Object("Email", id); obj == nil || obj["subject"] != "Hello" || obj["blobId"] != blobID {
+		t.Errorf("imported email = %v", obj)
+	}
+	notCreated, _ := got["notCreated"].(map[string]any)
+	if b, _ := notCreated["b"].(map[string]any); b["type"] != "blobNotFound" {
+		t.Errorf("notCreated = %v", got["notCreated"])
+	}
+}
+
+func TestSetCreationReferences(t *testing.T) {
+	s := New()
+	got := s.set("Mailbox", map[string]any{"create": map[string]any{
+		"a": map[string]any{"name": "Leaf", "parentId": "#z"},
+		"z": map[string]any{"name": "Root"},
+		"x": map[string]any{"name": "Orphan", "parentId": "#missing"},
+	}})
+	created, _ := got["created"].(map[string]any)
+	a, _ := created["a"].(map[string]any)
+	z, _ := created["z"].(map[string]any)
+	if a == nil || z == nil {
+		t.Fatalf("created = %v", created)
+	}
+	if leaf := s.Object("Mailbox", a["id"].(string)); leaf["parentId"] != z["id"] {
+		t.Errorf("leaf parentId = %v, want %v", leaf["parentId"], z["id"])
+	}
+	notCreated, _ := got["notCreated"].(map[string]any)
+	if x, _ := notCreated["x"].(map[string]any); x["type"] != "invalidProperties" {
+		t.Errorf("notCreated = %v", got["notCreated"])
+	}
+}
@@ -0,0 +1,160 @@
+package jmapfake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketURI is the RFC 8887 capability EnableWebSocket advertises.
+const WebSocketURI = "urn:ietf:params:jmap:websocket"
+
+// WebSocketURL is the fake's WebSocket endpoint. Like the base URL it is
+// never resolved: DialWebSocket connects in memory.
+const WebSocketURL = "ws://jmapfake.invalid/ws"
+
+// EnableWebSocket advertises RFC 8887 JMAP over WebSocket with push.
+// Connections are opened with DialWebSocket.
+func (s *Server) EnableWebSocket() {
+	s.AddCapability(WebSocketURI, map[string]any{"url": WebSocketURL, "supportsPush": true})
+}
+
+// WebSocketRequests returns how many requests were served over WebSocket.
+func (s *Server) WebSocketRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wsRequests
+}
+
+// DialWebSocket returns the client end of an in-memory connection to the
+// WebSocket endpoint; the handshake is left to the caller.
+func (s *Server) DialWebSocket(_ context.Context, url string) (net.Conn, error) {
+	if url != WebSocketURL {
+		return nil, fmt.Errorf("jmapfake: no WebSocket endpoint at %s", url)
+	}
+	s.mu.Lock()
+	if s.ws == nil {
+		s.ws = &wsListener{conns: make(chan net.Conn)}
+		srv := &http.Server{Handler: websocket.Server{
+			Handshake: func(cfg *websocket.Config, _ *http.Request) error {
+				if !slices.Contains(cfg.Protocol, "jmap") {
+					return fmt.Errorf("jmap subprotocol not requested")
+				}
+				cfg.Protocol = []string{"jmap"}
+				return nil
+			},
+			Handler: s.serveWebSocket,
+		}}
+		go srv.Serve(s.ws)
+	}
+	l := s.ws
+	s.mu.Unlock()
+
+	client, server := net.Pipe()
+	l.conns <- server
+	return client, nil
+}
+
+// wsListener hands in-memory connections to the WebSocket http.Server.
+type wsListener struct {
+	conns chan net.Conn
+}
+
+func (l *wsListener) Accept() (net.Conn, error) { return <-l.conns, nil }
+func (l *wsListener) Close() error              { return nil }
+func (l *wsListener) Addr() net.Addr            { return pipeAddr{} }
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "jmapfake" }
+
+// serveWebSocket answers Request objects with Response objects and, after
+// WebSocketPushEnable, sends a StateChange whenever the state advances.
+func (s *Server) serveWebSocket(ws *websocket.Conn) {
+	var sendMu sync.Mutex
+	send := func(v any) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		websocket.JSON.Send(ws, v)
+	}
+	done := make(chan struct{})
+	defer close(done)
+
+	pushing := false
+	for {
+		var msg map[string]json.RawMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+		var typ, id string
+		json.Unmarshal(msg["@type"], &typ)
+		json.Unmarshal(msg["id"], &id)
+
+		switch typ {
+		case "Request":
+			var methodCalls [][]json.RawMessage
+			json.Unmarshal(msg["methodCalls"], &methodCalls)
+			s.mu.Lock()
+			s.wsRequests++
+			s.mu.Unlock()
+			resp, err := s.execute(methodCalls)
+			if err != nil {
+				send(map[string]any{"@type": "RequestError", "requestId": id, "type": "urn:ietf:params:jmap:error:notRequest", "status": 400, "detail": err.Error()})
+				continue
+			}
+			resp["@type"] = "Response"
+			if id != "" {
+				resp["requestId"] = id
+			}
+			send(resp)
+		case "WebSocketPushEnable":
+			if !pushing {
+				pushing = true
+				s.mu.Lock()
+				from := s.state
+				s.mu.Unlock()
+				go s.pushStateChanges(from, send, done)
+			}
+		default:
+			send(map[string]any{"@type": "RequestError", "requestId": id, "type": "urn:ietf:params:jmap:error:notRequest", "status": 400, "detail": "unknown @type " + strconv.Quote(typ)})
+		}
+	}
+}
+
+// pushStateChanges sends a StateChange for the types changed after state
+// from each time the state advances, until done is closed.
+func (s *Server) pushStateChanges(from int, send func(any), done <-chan struct{}) {
+	for {
+		s.mu.Lock()
+		if s.state > from {
+			types := map[string]string{}
+			for _, c := range s.changes {
+				if c.state > from {
+					types[c.typ] = strconv.Itoa(s.state)
+				}
+			}
+			from = s.state
+			s.mu.Unlock()
+			send(map[string]any{
+				"@type":   "StateChange",
+				"changed": map[string]any{AccountID: types},
+			})
+			continue
+		}
+		ch := s.stateChangedLocked()
+		s.mu.Unlock()
+		select {
+		case <-ch:
+		case <-done:
+			return
+		}
+	}
+}
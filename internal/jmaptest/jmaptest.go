@@ -0,0 +1,379 @@
+// Package jmaptest runs jmap-mcp tools end to end against a real JMAP
+// server. Start launches Stalwart in a container, provisions a test
+// account, and returns its session URL and access token; Connect attaches
+// an in-memory MCP client to a server so tests call tools the way an agent
+// does.
+//
+// Setting JMAPTEST_SESSION_URL and JMAPTEST_TOKEN (and optionally
+// JMAPTEST_ADDRESS) points the harness at an existing server instead, e.g. a
+// Cyrus instance; the account should be disposable.
+package jmaptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// DefaultImage is the Stalwart image started when JMAPTEST_IMAGE is unset.
+const DefaultImage = "stalwartlabs/stalwart:latest"
+
+// startTimeout bounds container start and account provisioning.
+const startTimeout = 2 * time.Minute
+
+// Test account provisioned in the container.
+const (
+	domain   = "example.org"
+	user     = "jmaptest"
+	password = "jmaptest-password"
+)
+
+// Env is a JMAP account ready for tool calls.
+type Env struct {
+	SessionURL string
+	Token      string // bearer token for the account
+	Address    string // the account's email address
+}
+
+// Start returns a JMAP account to test against: the server named by
+// JMAPTEST_SESSION_URL if set, otherwise a fresh Stalwart container that is
+// removed when the test ends. The test is skipped when neither is available.
+func Start(t testing.TB) *Env {
+	t.Helper()
+	if sessionURL := os.Getenv("JMAPTEST_SESSION_URL"); sessionURL != "" {
+		token := os.Getenv("JMAPTEST_TOKEN")
+		if token == "" {
+			t.Fatal("JMAPTEST_SESSION_URL is set but JMAPTEST_TOKEN is empty")
+		}
+		return &Env{SessionURL: sessionURL, Token: token, Address: os.Getenv("JMAPTEST_ADDRESS")}
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker not found; set JMAPTEST_SESSION_URL and JMAPTEST_TOKEN to test against an existing server")
+	}
+
+	image := os.Getenv("JMAPTEST_IMAGE")
+	if image == "" {
+		image = DefaultImage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	c, err := startContainer(ctx, image)
+	if err != nil {
+		t.Fatalf("start %s: %v", image, err)
+	}
+	t.Cleanup(c.stop)
+
+	env, err := c.provision(ctx)
+	if err != nil {
+		t.Fatalf("provision test account: %v", err)
+	}
+	return env
+}
+
+// container is a running Stalwart container.
+type container struct {
+	id        string
+	baseURL   string // http://127.0.0.1:<port> mapped to the HTTP listener
+	adminUser string
+	adminPass string
+}
+
+// adminRE matches the fallback admin credentials Stalwart logs on first start.
+var adminRE = regexp.MustCompile(`administrator account is '([^']+)' with password '([^']+)'`)
+
+func startContainer(ctx context.Context, image string) (*container, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.CommandContext(ctx, "docker", "run", "-d", "--rm",
+		"-p", fmt.Sprintf("127.0.0.1:%d:8080", port), image).Output()
+	if err != nil {
+		return nil, commandError(err)
+	}
+	c := &container{
+		id:      strings.TrimSpace(string(out)),
+		baseURL: fmt.Sprintf("http://127.0.0.1:%d", port),
+	}
+
+	// Wait for the generated admin password, then for the HTTP listener.
+	for {
+		logs, _ := exec.CommandContext(ctx, "docker", "logs", c.id).CombinedOutput()
+		if m := adminRE.FindSubmatch(logs); m != nil {
+			c.adminUser, c.adminPass = string(m[1]), string(m[2])
+			break
+		}
+		if err := sleep(ctx, 500*time.Millisecond); err != nil {
+			c.stop()
+			return nil, fmt.Errorf("admin credentials not logged: %w", err)
+		}
+	}
+	for {
+		resp, err := http.Get(c.baseURL + "/.well-known/jmap")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if err := sleep(ctx, 500*time.Millisecond); err != nil {
+			c.stop()
+			return nil, fmt.Errorf("HTTP listener not ready: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (c *container) stop() {
+	exec.Command("docker", "rm", "-f", c.id).Run()
+}
+
+// provision points the server's public URL at the mapped port (so session
+// URLs resolve from the host), creates the test domain and account, and
+// obtains an access token for it.
+func (c *container) provision(ctx context.Context) (*Env, error) {
+	settings := []map[string]any{{
+		"type":         "insert",
+		"prefix":       nil,
+		"values":       [][]string{{"http.url", fmt.Sprintf("'%s'", c.baseURL)}},
+		"assert_empty": false,
+	}}
+	if err := c.admin(ctx, http.MethodPost, "/api/settings", settings); err != nil {
+		return nil, fmt.Errorf("set http.url: %w", err)
+	}
+	if err := c.admin(ctx, http.MethodGet, "/api/reload", nil); err != nil {
+		return nil, fmt.Errorf("reload: %w", err)
+	}
+	if err := c.admin(ctx, http.MethodPost, "/api/principal", map[string]any{
+		"type": "domain",
+		"name": domain,
+	}); err != nil {
+		return nil, fmt.Errorf("create domain: %w", err)
+	}
+	address := user + "@" + domain
+	if err := c.admin(ctx, http.MethodPost, "/api/principal", map[string]any{
+		"type":    "individual",
+		"name":    user,
+		"secrets": []string{password},
+		"emails":  []string{address},
+		"roles":   []string{"user"},
+	}); err != nil {
+		return nil, fmt.Errorf("create account: %w", err)
+	}
+
+	token, err := c.accessToken(ctx, user, password)
+	if err != nil {
+		return nil, fmt.Errorf("access token: %w", err)
+	}
+	return &Env{SessionURL: c.baseURL + "/.well-known/jmap", Token: token, Address: address}, nil
+}
+
+// admin calls the management API as the fallback administrator.
+func (c *container) admin(ctx context.Context, method, path string, body any) error {
+	return c.call(ctx, method, path, c.adminUser, c.adminPass, body, nil)
+}
+
+// accessToken runs Stalwart's OAuth code flow with password credentials,
+// as its web admin does, and returns a bearer token usable for JMAP.
+func (c *container) accessToken(ctx context.Context, name, secret string) (string, error) {
+	const clientID, redirectURI = "jmaptest", "stalwart://auth"
+	var code struct {
+		Code string `json:"code"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/api/oauth", name, secret, map[string]any{
+		"type":         "code",
+		"client_id":    clientID,
+		"redirect_uri": redirectURI,
+	}, &code); err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"client_id":    {clientID},
+		"code":         {code.Code},
+		"redirect_uri": {redirectURI},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/auth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint: %s (%s)", resp.Status, tok.Error)
+	}
+	return tok.AccessToken, nil
+}
+
+// call performs a management API request with basic auth and decodes the
+// {"data": ...} envelope into out.
+func (c *container) call(ctx context.Context, method, path, name, secret string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(name, secret)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	var envelope struct {
+		Data  json.RawMessage `json:"data"`
+		Error string          `json:"error"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if envelope.Error != "" {
+		return fmt.Errorf("%s %s: %s", method, path, envelope.Error)
+	}
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// Session is an MCP client connected to a jmap-mcp server in memory.
+type Session struct {
+	t  testing.TB
+	cs *mcp.ClientSession
+}
+
+// Connect attaches a client to srv over in-memory transports. The session
+// is closed when the test ends.
+func Connect(t testing.TB, srv *mcp.Server) *Session {
+	t.Helper()
+	ctx := context.Background()
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	ss, err := srv.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("connect server: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "jmaptest"}, nil)
+	cs, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("connect client: %v", err)
+	}
+	t.Cleanup(func() {
+		cs.Close()
+		ss.Wait()
+	})
+	return &Session{t: t, cs: cs}
+}
+
+// Call invokes a tool and returns its text, failing the test if the call
+// or the tool fails.
+func (s *Session) Call(name string, args any) string {
+	s.t.Helper()
+	text, isError := s.Try(name, args)
+	if isError {
+		s.t.Fatalf("%s: %s", name, text)
+	}
+	return text
+}
+
+// Try invokes a tool and returns its text and whether the tool reported an
+// error. Protocol failures fail the test.
+func (s *Session) Try(name string, args any) (string, bool) {
+	s.t.Helper()
+	res, err := s.cs.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		s.t.Fatalf("%s: %v", name, err)
+	}
+	var sb strings.Builder
+	for _, c := range res.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			sb.WriteString(tc.Text)
+		}
+	}
+	return sb.String(), res.IsError
+}
+
+// idRE matches the "[id: X]" suffix tools print after created or listed
+// objects.
+var idRE = regexp.MustCompile(`\[id: ([^\]]+)\]`)
+
+// FindID returns the ID on the first line of text containing substr, or ""
+// when no such line carries one.
+func FindID(text, substr string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.Contains(line, substr) {
+			continue
+		}
+		if m := idRE.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// freePort returns a loopback TCP port that was free a moment ago.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// commandError includes a failed command's stderr in its error.
+func commandError(err error) error {
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(ee.Stderr))
+	}
+	return err
+}
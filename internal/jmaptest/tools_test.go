@@ -0,0 +1,121 @@
+//go:build integration
+
+package jmaptest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/jmaptest"
+	"github.com/mikluko/jmap-mcp/pkg/jmapmcp"
+)
+
+// TestTools exercises the core tool suite against a live server. Steps run
+// in order and share state, so the first failure stops the test.
+func TestTools(t *testing.T) {
+	env := jmaptest.Start(t)
+	srv := jmapmcp.NewServer("test", env.SessionURL,
+		jmapmcp.WithToken(env.Token),
+		jmapmcp.WithSieve(),
+		jmapmcp.WithEmailSubmission(),
+	)
+	s := jmaptest.Connect(t, srv.MCP())
+
+	mailboxes := s.Call("mailbox_get", map[string]any{})
+	inboxID := jmaptest.FindID(mailboxes, "(inbox)")
+	if inboxID == "" {
+		t.Fatalf("no inbox in:\n%s", mailboxes)
+	}
+
+	folderName := fmt.Sprintf("jmaptest-%d", time.Now().UnixNano())
+	folderID := jmaptest.FindID(s.Call("mailbox_set", map[string]any{
+		"create": map[string]any{"f": map[string]any{"name": folderName}},
+	}), "Created mailbox")
+	if folderID == "" {
+		t.Fatal("mailbox_set did not report the new mailbox ID")
+	}
+	defer s.Try("mailbox_set", map[string]any{"destroy": []string{folderID}, "on_destroy_remove_emails": true})
+
+	to := env.Address
+	if to == "" {
+		to = "jmaptest@example.org"
+	}
+	subject := "jmaptest " + folderName
+	draftID := jmaptest.FindID(s.Call("email_create", map[string]any{
+		"to":      []string{to},
+		"subject": subject,
+		"body":    "Integration test body.",
+	}), "Created draft")
+	if draftID == "" {
+		t.Fatal("email_create did not report the draft ID")
+	}
+
+	if out := s.Call("email_query", map[string]any{"subject": subject}); !strings.Contains(out, draftID) {
+		t.Errorf("email_query by subject missing %s:\n%s", draftID, out)
+	}
+	if out := s.Call("email_get", map[string]any{"email_ids": []string{draftID}}); !strings.Contains(out, "Integration test body.") {
+		t.Errorf("email_get missing body:\n%s", out)
+	}
+
+	s.Call("email_flag", map[string]any{"email_ids": []string{draftID}, "flagged": true})
+	s.Call("email_move", map[string]any{"email_ids": []string{draftID}, "mailbox_id": folderID})
+	if out := s.Call("email_query", map[string]any{"mailbox_id": folderID}); !strings.Contains(out, draftID) {
+		t.Errorf("moved email not in %s:\n%s", folderName, out)
+	}
+
+	s.Call("email_delete", map[string]any{"email_ids": []string{draftID}})
+	s.Call("email_delete", map[string]any{"email_ids": []string{draftID}, "permanent": true})
+	if out, _ := s.Try("email_get", map[string]any{"email_ids": []string{draftID}}); strings.Contains(out, subject) {
+		t.Errorf("destroyed email still returned:\n%s", out)
+	}
+
+	testSieve(t, s, folderName)
+	testSubmission(t, s, to, subject+" sent", inboxID)
+}
+
+// testSieve validates, creates, lists, and destroys a script.
+func testSieve(t *testing.T, s *jmaptest.Session, name string) {
+	if out := s.Call("sieve_validate", map[string]any{"content": `require "fileinto"; fileinto "INBOX";`}); !strings.Contains(out, "is valid") {
+		t.Errorf("sieve_validate: %s", out)
+	}
+	if out := s.Call("sieve_validate", map[string]any{"content": "this is not sieve"}); !strings.Contains(out, "Validation failed") {
+		t.Errorf("sieve_validate accepted garbage: %s", out)
+	}
+	created := s.Call("sieve_set", map[string]any{"name": name, "content": "keep;"})
+	scriptID := jmaptest.FindID(created, "Created sieve script")
+	if scriptID == "" {
+		t.Fatalf("sieve_set did not report the script ID:\n%s", created)
+	}
+	if out := s.Call("sieve_get", map[string]any{}); !strings.Contains(out, scriptID) {
+		t.Errorf("sieve_get list missing %s:\n%s", scriptID, out)
+	}
+	s.Call("sieve_set", map[string]any{"destroy": []string{scriptID}})
+}
+
+// testSubmission sends an email to the account itself and waits for it to
+// arrive in the inbox.
+func testSubmission(t *testing.T, s *jmaptest.Session, to, subject, inboxID string) {
+	draftID := jmaptest.FindID(s.Call("email_create", map[string]any{
+		"to":      []string{to},
+		"subject": subject,
+		"body":    "Delivered to self.",
+	}), "Created draft")
+	if draftID == "" {
+		t.Fatal("email_create did not report the draft ID")
+	}
+	s.Call("email_submission_set", map[string]any{"email_id": draftID})
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		out := s.Call("email_query", map[string]any{"mailbox_id": inboxID, "subject": subject})
+		if !strings.Contains(out, "(returning 0)") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("submitted email not delivered to the inbox:\n%s", out)
+		}
+		time.Sleep(time.Second)
+	}
+}
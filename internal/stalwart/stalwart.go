@@ -0,0 +1,254 @@
+// Package stalwart is a minimal client for the Stalwart mail server's
+// management REST API (/api/...), covering the principal, quota, and
+// undelete endpoints jmap-mcp exposes as admin tools. Stalwart serves the
+// API on the same origin as JMAP and accepts the same bearer tokens; only
+// principals with admin permissions may call it.
+package stalwart
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrNotStalwart is returned when the server does not expose the Stalwart
+// management API.
+var ErrNotStalwart = errors.New("server does not expose the Stalwart management API")
+
+// ErrForbidden is returned when the token's principal lacks admin rights.
+var ErrForbidden = errors.New("principal is not authorized for Stalwart administration")
+
+// maxResponseBytes caps management API response bodies.
+const maxResponseBytes = 4 << 20
+
+// Client calls the management API at BaseURL (scheme://host) with Token.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the Stalwart server hosting sessionURL.
+func NewClient(sessionURL, token string) (*Client, error) {
+	u, err := url.Parse(sessionURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid session URL %q", sessionURL)
+	}
+	return &Client{BaseURL: u.Scheme + "://" + u.Host, Token: token}, nil
+}
+
+// StringList decodes a JSON string or array of strings; Stalwart uses
+// either form for multi-valued principal fields.
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*l = StringList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*l = many
+	return nil
+}
+
+// Principal is an account, group, list, or domain principal.
+type Principal struct {
+	Type        string     `json:"type"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Emails      StringList `json:"emails,omitempty"`
+	Quota       int64      `json:"quota,omitempty"`
+	UsedQuota   int64      `json:"usedQuota,omitempty"`
+	MemberOf    StringList `json:"memberOf,omitempty"`
+	Roles       StringList `json:"roles,omitempty"`
+}
+
+// PrincipalList is one page of ListPrincipals.
+type PrincipalList struct {
+	Items []*Principal `json:"items"`
+	Total int          `json:"total"`
+}
+
+// ListPrincipals returns principals of the given types (e.g. "individual")
+// whose name or email matches filter.
+func (c *Client) ListPrincipals(ctx context.Context, types []string, filter string, page, limit int) (*PrincipalList, error) {
+	q := url.Values{}
+	if len(types) > 0 {
+		q.Set("types", strings.Join(types, ","))
+	}
+	if filter != "" {
+		q.Set("filter", filter)
+	}
+	if page > 0 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out PrincipalList
+	if err := c.do(ctx, http.MethodGet, "/api/principal?"+q.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPrincipal returns the principal called name.
+func (c *Client) GetPrincipal(ctx context.Context, name string) (*Principal, error) {
+	var out Principal
+	if err := c.do(ctx, http.MethodGet, "/api/principal/"+url.PathEscape(name), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetQuota sets the disk quota of principal name in bytes (0: unlimited).
+func (c *Client) SetQuota(ctx context.Context, name string, bytes int64) error {
+	body := []map[string]any{{"action": "set", "field": "quota", "value": bytes}}
+	return c.do(ctx, http.MethodPatch, "/api/principal/"+url.PathEscape(name), body, nil)
+}
+
+// DeletedItem is a deleted blob still held for recovery.
+type DeletedItem struct {
+	Hash       string `json:"hash"`
+	Size       int64  `json:"size"`
+	DeletedAt  int64  `json:"deletedAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+	Collection string `json:"collection"`
+}
+
+// DeletedList is one page of ListDeleted.
+type DeletedList struct {
+	Items []*DeletedItem `json:"items"`
+	Total int            `json:"total"`
+}
+
+// ListDeleted returns recoverable deleted items of account (Stalwart
+// Enterprise).
+func (c *Client) ListDeleted(ctx context.Context, account string, page, limit int) (*DeletedList, error) {
+	q := url.Values{}
+	if page > 0 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out DeletedList
+	if err := c.do(ctx, http.MethodGet, "/api/store/undelete/"+url.PathEscape(account)+"?"+q.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RestoreResult is the outcome of restoring one item.
+type RestoreResult struct {
+	Type   string `json:"type"` // success, notFound, error
+	Reason string `json:"reason,omitempty"`
+}
+
+// Restore undeletes items of account, identified by hash and collection
+// from ListDeleted.
+func (c *Client) Restore(ctx context.Context, account string, items []*DeletedItem) ([]RestoreResult, error) {
+	body := make([]map[string]any, len(items))
+	for i, it := range items {
+		body[i] = map[string]any{
+			"hash":           it.Hash,
+			"collection":     it.Collection,
+			"restoreTime":    it.DeletedAt,
+			"cancelDeletion": it.ExpiresAt,
+		}
+	}
+	var out []RestoreResult
+	if err := c.do(ctx, http.MethodPost, "/api/store/undelete/"+url.PathEscape(account), body, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// do performs a management API call. Responses wrap their payload as
+// {"data": ...}; failures are reported with an "error" field or a non-2xx
+// status.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case resp.StatusCode == http.StatusNotFound && !json.Valid(data):
+		return ErrNotStalwart
+	}
+
+	var envelope struct {
+		Data    json.RawMessage `json:"data"`
+		Error   string          `json:"error"`
+		Details string          `json:"details"`
+		Item    string          `json:"item"`
+		Title   string          `json:"title"`
+		Detail  string          `json:"detail"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return ErrNotStalwart
+	}
+	if envelope.Error != "" {
+		msg := envelope.Error
+		for _, s := range []string{envelope.Item, envelope.Details} {
+			if s != "" {
+				msg += ": " + s
+			}
+		}
+		return fmt.Errorf("stalwart: %s", msg)
+	}
+	if resp.StatusCode/100 != 2 {
+		if envelope.Detail != "" {
+			return fmt.Errorf("stalwart: %s: %s", resp.Status, envelope.Detail)
+		}
+		return fmt.Errorf("stalwart: %s", resp.Status)
+	}
+	if out == nil || len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
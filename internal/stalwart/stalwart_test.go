@@ -0,0 +1,104 @@
+package stalwart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	c, err := NewClient(ts.URL+"/jmap/session", "admin-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestListPrincipals(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/principal" || r.URL.Query().Get("types") != "individual" || r.URL.Query().Get("filter") != "jane" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		io.WriteString(w, `{"data":{"items":[{"type":"individual","name":"jane","emails":"jane@example.com","quota":1000,"usedQuota":250},{"type":"individual","name":"jo","emails":["jo@example.com","j@example.com"]}],"total":2}}`)
+	})
+
+	list, err := c.ListPrincipals(context.Background(), []string{"individual"}, "jane", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 2 || len(list.Items) != 2 {
+		t.Fatalf("got %+v", list)
+	}
+	if got := list.Items[0].Emails; len(got) != 1 || got[0] != "jane@example.com" {
+		t.Errorf("single email = %v", got)
+	}
+	if got := list.Items[1].Emails; len(got) != 2 {
+		t.Errorf("email list = %v", got)
+	}
+	if list.Items[0].UsedQuota != 250 {
+		t.Errorf("usedQuota = %d", list.Items[0].UsedQuota)
+	}
+}
+
+func TestSetQuota(t *testing.T) {
+	var body []map[string]any
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/principal/jane" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		io.WriteString(w, `{"data":null}`)
+	})
+
+	if err := c.SetQuota(context.Background(), "jane", 5000); err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 1 || body[0]["field"] != "quota" || body[0]["value"] != float64(5000) {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    error
+		wantAny bool
+	}{
+		{"not admin", http.StatusForbidden, "", ErrForbidden, false},
+		{"not stalwart", http.StatusNotFound, "404 page not found", ErrNotStalwart, false},
+		{"html", http.StatusOK, "<html></html>", ErrNotStalwart, false},
+		{"api error", http.StatusOK, `{"error":"notFound","item":"nobody"}`, nil, true},
+		{"problem details", http.StatusNotFound, `{"type":"about:blank","status":404,"title":"Not Found","detail":"principal not found"}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+			_, err := c.GetPrincipal(context.Background(), "nobody")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+			if tt.wantAny && (errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotStalwart)) {
+				t.Errorf("err = %v, want API error", err)
+			}
+		})
+	}
+}
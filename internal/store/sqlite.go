@@ -0,0 +1,111 @@
+//go:build cgo
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLite keeps every document as a row of one table in an SQLite database
+// file, so a single file holds all of them. Writes are transactions, and
+// processes opening the same file share its documents as they would a
+// directory of files.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens, creating it when missing, the SQLite database at path.
+// It needs a binary built with cgo.
+func OpenSQLite(path string) (*SQLite, error) {
+	if path == "" {
+		return nil, errors.New("sqlite store needs a database path")
+	}
+	// WAL lets readers in other processes go on while one writes; the busy
+	// timeout makes a writer wait for another instead of failing.
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite store %s: %w", path, err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS documents (
+		name     TEXT PRIMARY KEY,
+		data     BLOB NOT NULL,
+		modified INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite store %s: %w", path, err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func openSQLite(path string) (Store, error) {
+	return OpenSQLite(path)
+}
+
+func (s *SQLite) Read(name string) ([]byte, time.Time, error) {
+	var data []byte
+	var modified int64
+	err := s.db.QueryRow(`SELECT data, modified FROM documents WHERE name = ?`, name).Scan(&data, &modified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, time.Time{}, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, time.Unix(0, modified), nil
+}
+
+func (s *SQLite) Stat(name string) (time.Time, error) {
+	var modified int64
+	err := s.db.QueryRow(`SELECT modified FROM documents WHERE name = ?`, name).Scan(&modified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, modified), nil
+}
+
+func (s *SQLite) Write(name string, data []byte) (time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	// Every write gets its own time, later than the one it replaces, so a
+	// reader comparing times never misses a change.
+	modified := time.Now().UnixNano()
+	var prev int64
+	err = tx.QueryRow(`SELECT modified FROM documents WHERE name = ?`, name).Scan(&prev)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return time.Time{}, err
+	case modified <= prev:
+		modified = prev + 1
+	}
+	_, err = tx.Exec(`INSERT INTO documents (name, data, modified) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data, modified = excluded.modified`, name, data, modified)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, modified), nil
+}
+
+// Close closes the database.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !cgo
+
+package store
+
+import "errors"
+
+// openSQLite refuses: the SQLite driver needs cgo, and this binary was
+// built with CGO_ENABLED=0.
+func openSQLite(path string) (Store, error) {
+	return nil, errors.New("the sqlite store needs a binary built with cgo (CGO_ENABLED=1)")
+}
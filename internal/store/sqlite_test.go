@@ -0,0 +1,32 @@
+//go:build cgo
+
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	st, err := Open("sqlite:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.(*SQLite).Close()
+	testStore(t, st)
+
+	// Another process opening the same database sees the documents.
+	other, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	written, err := other.Write("shared.json", []byte("[]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, modified, err := st.Read("shared.json"); err != nil || string(data) != "[]\n" || !modified.Equal(written) {
+		t.Errorf("read %q at %v, %v; written at %v", data, modified, err, written)
+	}
+}
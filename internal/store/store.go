@@ -0,0 +1,165 @@
+// Package store keeps the documents jmap-mcp saves between calls and
+// restarts: sender lists, Sieve history, mailbox snapshots, automations,
+// schedules, undo journals.
+// Each feature reads and writes whole JSON documents by name through a
+// Store, so where they live is the operator's choice (-store): files, the
+// default, survive restarts and can be shared with a daemon process; an
+// SQLite database does the same in a single file; memory keeps them for
+// the life of the process. Another backend only has to implement Store.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store keeps named documents. Implementations are safe for concurrent use.
+type Store interface {
+	// Read returns the document saved as name and when it was written. A
+	// missing document is an error wrapping fs.ErrNotExist.
+	Read(name string) ([]byte, time.Time, error)
+	// Stat returns when the document saved as name was written, so callers
+	// can tell whether another process changed it without reading it.
+	Stat(name string) (time.Time, error)
+	// Write replaces the document saved as name as a whole, so readers see
+	// the old or the new document and never part of one, and returns when
+	// it was written.
+	Write(name string, data []byte) (time.Time, error)
+}
+
+// Open returns the store kind names: "file" for Dir(""), where document
+// names are file paths, "memory", or "sqlite:PATH" for the SQLite database
+// at PATH, where document names are keys.
+func Open(kind string) (Store, error) {
+	switch kind {
+	case "file":
+		return Dir(""), nil
+	case "memory":
+		return &Memory{}, nil
+	}
+	if path, ok := strings.CutPrefix(kind, "sqlite:"); ok && path != "" {
+		return openSQLite(path)
+	}
+	return nil, fmt.Errorf("store must be 'file', 'memory', or 'sqlite:PATH', got: %s", kind)
+}
+
+// WriteJSON writes v as an indented JSON document.
+func WriteJSON(st Store, name string, v any) (time.Time, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return st.Write(name, append(data, '\n'))
+}
+
+// Dir keeps each document in a file, its name relative to the directory;
+// absolute names are used as they are. Files are replaced through a
+// temporary file in the same directory, so a crash never leaves a partial
+// one.
+type Dir string
+
+func (d Dir) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(string(d), name)
+}
+
+func (d Dir) Read(name string) ([]byte, time.Time, error) {
+	path := d.path(name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, info.ModTime(), nil
+}
+
+func (d Dir) Stat(name string) (time.Time, error) {
+	info, err := os.Stat(d.path(name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (d Dir) Write(name string, data []byte) (time.Time, error) {
+	path := d.path(name)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return time.Time{}, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return time.Time{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return time.Time{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return time.Time{}, err
+	}
+	return d.Stat(name)
+}
+
+// Memory keeps documents in memory, for the life of the process. The zero
+// value is an empty store.
+type Memory struct {
+	mu   sync.Mutex
+	docs map[string]memoryDoc
+	last time.Time // of the latest write, so every write has its own time
+}
+
+type memoryDoc struct {
+	data     []byte
+	modified time.Time
+}
+
+func (m *Memory) Read(name string) ([]byte, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc, ok := m.docs[name]
+	if !ok {
+		return nil, time.Time{}, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), doc.data...), doc.modified, nil
+}
+
+func (m *Memory) Stat(name string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc, ok := m.docs[name]
+	if !ok {
+		return time.Time{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return doc.modified, nil
+}
+
+func (m *Memory) Write(name string, data []byte) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if !now.After(m.last) {
+		now = m.last.Add(time.Nanosecond)
+	}
+	m.last = now
+	if m.docs == nil {
+		m.docs = make(map[string]memoryDoc)
+	}
+	m.docs[name] = memoryDoc{data: append([]byte(nil), data...), modified: now}
+	return now, nil
+}
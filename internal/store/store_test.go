@@ -0,0 +1,74 @@
+package store
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+	dir := t.TempDir()
+	for name, st := range map[string]Store{"dir": Dir(dir), "memory": &Memory{}} {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, st)
+		})
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "sub")); len(entries) != 1 {
+		t.Errorf("files left behind: %v", entries)
+	}
+
+	// Absolute names are paths of their own.
+	abs := filepath.Join(t.TempDir(), "abs.json")
+	if _, err := Dir(dir).Write(abs, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(abs); err != nil || string(data) != "x" {
+		t.Errorf("absolute name wrote %q, %v", data, err)
+	}
+}
+
+// testStore checks the Store contract on st, which has no document
+// "sub/doc.json" yet.
+func testStore(t *testing.T, st Store) {
+	t.Helper()
+	if _, _, err := st.Read("sub/doc.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing document: %v", err)
+	}
+	if _, err := st.Stat("sub/doc.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing document stat: %v", err)
+	}
+	first, err := WriteJSON(st, "sub/doc.json", map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, modified, err := st.Read("sub/doc.json")
+	if err != nil || string(data) != "{\n  \"a\": 1\n}\n" || !modified.Equal(first) {
+		t.Fatalf("read %q at %v, %v; written at %v", data, modified, err, first)
+	}
+	second, err := st.Write("sub/doc.json", []byte("{}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.After(first) {
+		t.Errorf("second write at %v, not after the first at %v", second, first)
+	}
+	if stat, err := st.Stat("sub/doc.json"); err != nil || !stat.Equal(second) {
+		t.Errorf("stat = %v, %v; written at %v", stat, err, second)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if st, err := Open("file"); err != nil || st != Dir("") {
+		t.Errorf("file = %v, %v", st, err)
+	}
+	if st, err := Open("memory"); err != nil {
+		t.Errorf("memory = %v, %v", st, err)
+	}
+	for _, kind := range []string{"sqlite", "sqlite:", "bolt"} {
+		if _, err := Open(kind); err == nil {
+			t.Errorf("store %q opened", kind)
+		}
+	}
+}
@@ -6,52 +6,317 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
 	"github.com/mikluko/jmap-mcp/internal/config"
-	"github.com/mikluko/jmap-mcp/internal/server"
+	"github.com/mikluko/jmap-mcp/internal/discovery"
+	"github.com/mikluko/jmap-mcp/internal/store"
+	"github.com/mikluko/jmap-mcp/pkg/jmapmcp"
 )
 
 var version = "0.0.0"
 
 func main() {
+	// "jmap-mcp check" takes the same flags and environment as the server.
+	check := len(os.Args) > 1 && os.Args[1] == "check"
+	if check {
+		os.Args = append(os.Args[:1:1], os.Args[2:]...)
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
 		os.Exit(1)
 	}
 
-	var opts []server.Option
+	if cfg.SessionURL == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		sessionURL, err := (&discovery.Resolver{}).SessionURL(ctx, cfg.Account)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Session discovery error: %v\n", err)
+			os.Exit(1)
+		}
+		log.Printf("Discovered JMAP session URL for %s: %s", cfg.Account, sessionURL)
+		cfg.SessionURL = sessionURL
+	}
+
+	var opts []jmapmcp.Option
 	if cfg.AuthToken != "" {
-		opts = append(opts, server.WithToken(cfg.AuthToken))
+		opts = append(opts, jmapmcp.WithToken(cfg.AuthToken), jmapmcp.WithTokenReload(cfg.AuthTokenReload))
+	}
+	for _, ep := range cfg.Endpoints {
+		opts = append(opts, jmapmcp.WithEndpoint(ep.Name, ep.SessionURL, ep.AuthToken))
+	}
+	opts = append(opts,
+		jmapmcp.WithMaxFetchBytes(cfg.MaxFetchBytes),
+		jmapmcp.WithQueryLimit(cfg.QueryLimit),
+		jmapmcp.WithMaxChars(cfg.MaxChars),
+		jmapmcp.WithMaxBodyChars(cfg.MaxBodyChars),
+		jmapmcp.WithImageThumbnails(cfg.ImageMaxDimension, cfg.ImageJPEGQuality),
+		jmapmcp.WithHeaderPatterns(cfg.FullHeadersInclude, cfg.FullHeadersExclude),
+		jmapmcp.WithWatchPollInterval(cfg.WatchPollInterval),
+		jmapmcp.WithTenantQuota(jmapmcp.TenantQuota{MaxBytes: cfg.TenantCacheBytes, MaxTenants: cfg.MaxTenants}),
+		jmapmcp.WithWebSocket(cfg.WebSocket),
+	)
+	if cfg.StructuredOutput {
+		opts = append(opts, jmapmcp.WithStructuredOutput())
 	}
 	if cfg.EnableEmailSubmission {
-		opts = append(opts, server.WithEmailSubmission())
+		opts = append(opts, jmapmcp.WithEmailSubmission(), jmapmcp.WithResendWindow(cfg.ResendWindow))
+	}
+	switch {
+	case cfg.PreSendCommand != "":
+		opts = append(opts, jmapmcp.WithPreSendHook(jmapmcp.PreSendCommand(strings.Fields(cfg.PreSendCommand))))
+	case cfg.PreSendURL != "":
+		opts = append(opts, jmapmcp.WithPreSendHook(jmapmcp.PreSendHTTP{URL: cfg.PreSendURL}))
+	}
+	if cfg.HasSendPolicy() {
+		opts = append(opts, jmapmcp.WithSendPolicy(jmapmcp.SendPolicy{
+			AllowedDomains:   cfg.AllowedDomains,
+			BlockedAddresses: cfg.BlockedRecipients,
+			MaxRecipients:    cfg.MaxRecipients,
+			MaxSendsPerHour:  cfg.MaxSendsPerHour,
+			IdentityDomains:  cfg.IdentityDomains,
+		}))
+	}
+	if cfg.AbuseAddress != "" {
+		opts = append(opts, jmapmcp.WithAbuseAddress(cfg.AbuseAddress))
+	}
+	if cfg.EnableMailMerge {
+		opts = append(opts, jmapmcp.WithMailMerge(jmapmcp.MailMergePolicy{
+			MaxRecipients: cfg.MailMergeMax,
+			BatchSize:     cfg.MailMergeBatchSize,
+			BatchInterval: cfg.MailMergeBatchInterval,
+		}))
+	}
+	if cfg.ConfirmDestructive {
+		opts = append(opts, jmapmcp.WithDestructiveConfirmation())
+	}
+	if cfg.DeleteDelay > 0 {
+		opts = append(opts, jmapmcp.WithDeleteDelay(cfg.DeleteDelay))
+	}
+	if len(cfg.AutoCC) > 0 || len(cfg.AutoBCC) > 0 {
+		opts = append(opts, jmapmcp.WithAutoRecipients(jmapmcp.AutoRecipients{CC: cfg.AutoCC, BCC: cfg.AutoBCC}))
+	}
+	if cfg.HasAttachmentPolicy() {
+		opts = append(opts, jmapmcp.WithAttachmentPolicy(jmapmcp.AttachmentPolicy{
+			AllowedTypes:      cfg.AllowedAttachmentTypes,
+			BlockedTypes:      cfg.BlockedAttachmentTypes,
+			BlockedExtensions: cfg.BlockedAttachmentExts,
+			MaxSize:           cfg.MaxAttachmentSize,
+		}))
+	}
+	switch {
+	case cfg.ScanClamd != "":
+		opts = append(opts, jmapmcp.WithAttachmentScan(jmapmcp.AttachmentScan{Scanner: jmapmcp.ClamdScanner{Address: cfg.ScanClamd}, WarnOnly: cfg.ScanWarnOnly}))
+	case cfg.ScanCommand != "":
+		opts = append(opts, jmapmcp.WithAttachmentScan(jmapmcp.AttachmentScan{Scanner: jmapmcp.ScanCommand(strings.Fields(cfg.ScanCommand)), WarnOnly: cfg.ScanWarnOnly}))
+	}
+	if len(cfg.Redact) > 0 || len(cfg.RedactPatterns) > 0 {
+		redactor, err := jmapmcp.NewRedactor(cfg.Redact, cfg.RedactPatterns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithRedactor(redactor))
+	}
+	locale, err := jmapmcp.ParseLocale(cfg.Locale)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	opts = append(opts, jmapmcp.WithLocale(locale))
+	dateFormat, err := jmapmcp.ParseDateFormat(cfg.DateFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	opts = append(opts, jmapmcp.WithDateFormat(dateFormat, cfg.RelativeDates))
+	toolCosts, err := jmapmcp.ParseToolCosts(cfg.ToolCosts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	opts = append(opts, jmapmcp.WithToolCosts(toolCosts))
+	debugJMAP, err := jmapmcp.ParseDebugJMAP(cfg.DebugJMAP)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	opts = append(opts, jmapmcp.WithDebugJMAP(debugJMAP))
+	threadFallback, err := jmapmcp.ParseThreadFallback(cfg.ThreadFallback)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	opts = append(opts, jmapmcp.WithThreadFallback(threadFallback))
+	if cfg.ReplayJMAP != "" {
+		replay, err := jmapmcp.NewReplayDialer(cfg.ReplayJMAP)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithDialer(replay))
+	}
+	if cfg.RecordJMAP != "" {
+		opts = append(opts, jmapmcp.WithJMAPRecording(cfg.RecordJMAP))
+	}
+	if cfg.SendQueue {
+		opts = append(opts, jmapmcp.WithSendQueue())
 	}
 	if cfg.EnableSieve {
-		opts = append(opts, server.WithSieve())
+		opts = append(opts, jmapmcp.WithSieve())
+	}
+	if cfg.EnableStalwartAdmin {
+		opts = append(opts, jmapmcp.WithStalwartAdmin())
+	}
+	if cfg.LabelMode {
+		opts = append(opts, jmapmcp.WithLabels())
+	}
+	if cfg.TranslateURL != "" {
+		opts = append(opts, jmapmcp.WithTranslator(cfg.TranslateURL, cfg.TranslateAPIKey, cfg.TranslateTarget))
+	}
+	if len(cfg.ClassifyCategories) > 0 {
+		opts = append(opts, jmapmcp.WithClassifier(cfg.ClassifyCategories, cfg.ClassifyURL))
+	}
+	if cfg.PDFRenderer != "" {
+		opts = append(opts, jmapmcp.WithPDFRenderer(strings.Fields(cfg.PDFRenderer)))
+	}
+	if cfg.PGPCommand != "" {
+		opts = append(opts, jmapmcp.WithPGP(jmapmcp.PGPCommand(strings.Fields(cfg.PGPCommand))))
+	}
+	st, err := store.Open(cfg.Store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.SenderListsFile != "" {
+		senders, err := jmapmcp.LoadSenderLists(st, cfg.SenderListsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithSenderLists(senders))
+	}
+	if cfg.EnableSieve && cfg.SieveHistoryFile != "" {
+		history, err := jmapmcp.LoadSieveHistory(st, cfg.SieveHistoryFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithSieveHistory(history))
+	}
+	if cfg.MailboxSnapshotsFile != "" {
+		snapshots, err := jmapmcp.LoadMailboxSnapshots(st, cfg.MailboxSnapshotsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithMailboxSnapshots(snapshots))
+	}
+	if cfg.AutomationsFile != "" {
+		automations, err := jmapmcp.LoadAutomations(st, cfg.AutomationsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithAutomations(automations))
+	}
+	if cfg.SchedulesFile != "" {
+		schedules, err := jmapmcp.LoadSchedules(st, cfg.SchedulesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithSchedules(schedules))
+	}
+	if cfg.JournalsFile != "" {
+		journals, err := jmapmcp.LoadJournals(st, cfg.JournalsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithJournals(journals))
+	}
+	if cfg.ScriptsFile != "" {
+		scripts, err := jmapmcp.LoadScripts(cfg.ScriptsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithScripts(scripts))
+	}
+	if cfg.QueryPresetsFile != "" {
+		presets, err := jmapmcp.LoadQueryPresets(cfg.QueryPresetsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithQueryPresets(presets))
+	}
+	if cfg.MailboxViewsFile != "" {
+		views, err := jmapmcp.LoadMailboxViews(cfg.MailboxViewsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, jmapmcp.WithMailboxViews(views))
 	}
 	if cfg.Mode == "http" {
-		opts = append(opts, server.WithAttachmentURL(cfg.AttachmentURLSecret, cfg.ExternalURL))
+		opts = append(opts, jmapmcp.WithAttachmentURL(cfg.AttachmentURLSecret, cfg.ExternalURL), jmapmcp.WithAttachmentURLTTL(cfg.AttachmentURLTTL))
+	}
+	if cfg.Mode == "stdio" && cfg.LocalFiles {
+		opts = append(opts, jmapmcp.WithLocalFiles())
+	}
+	srv := jmapmcp.NewServer(version, cfg.SessionURL, opts...)
+
+	if check {
+		if runCheck(srv, os.Stdout) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.RunAutomations && cfg.Mode != "daemon" {
+		go func() {
+			if err := srv.RunAutomations(context.Background()); err != nil {
+				log.Printf("Automations stopped: %v", err)
+			}
+		}()
 	}
-	srv := server.NewServer(version, cfg.SessionURL, opts...)
 
 	switch cfg.Mode {
 	case "stdio":
 		runStdio(srv)
 	case "http":
-		runHTTP(srv, cfg.ListenAddr)
+		runHTTP(srv, cfg)
+	case "daemon":
+		runDaemon(srv)
+	}
+}
+
+// runDaemon runs the automations until interrupted, with no MCP transport.
+func runDaemon(srv *jmapmcp.Server) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := srv.RunAutomations(ctx); err != nil {
+		log.Fatalf("Automations error: %v", err)
 	}
 }
 
-func runStdio(srv *server.Server) {
+func runStdio(srv *jmapmcp.Server) {
 	if err := srv.MCP().Run(context.Background(), &mcp.StdioTransport{}); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
 
-func runHTTP(srv *server.Server, addr string) {
+func runHTTP(srv *jmapmcp.Server, cfg *config.Config) {
 	mcpHandler := mcp.NewStreamableHTTPHandler(
 		func(*http.Request) *mcp.Server { return srv.MCP() },
 		nil,
@@ -64,10 +329,36 @@ func runHTTP(srv *server.Server, addr string) {
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 	mux.Handle("/attachments/", srv.AttachmentHandler())
-	mux.Handle("/", server.BaseURLMiddleware(server.TokenMiddleware(mcpHandler)))
 
-	log.Printf("Starting HTTP server on %s", addr)
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	// With server auth, Authorization belongs to the MCP client credential and
+	// the JMAP token travels in X-JMAP-Token (or the query parameter).
+	tokens := jmapmcp.TokenSources{Query: !cfg.RejectQueryToken, Authorization: !cfg.HasServerAuth()}
+	var handler http.Handler = jmapmcp.BaseURLMiddleware(jmapmcp.EndpointMiddleware(tokens.Middleware(mcpHandler)))
+	// Watch status is for dashboards; with server auth it takes the same credentials.
+	watchStatus := srv.WatchStatusHandler()
+	if cfg.HasServerAuth() {
+		var auths []jmapmcp.Authenticator
+		if len(cfg.APIKeys) > 0 {
+			auths = append(auths, jmapmcp.NewAPIKeys(cfg.APIKeys))
+		}
+		if cfg.CredentialsFile != "" {
+			store, err := jmapmcp.LoadCredentialStore(cfg.CredentialsFile)
+			if err != nil {
+				log.Fatalf("Configuration error: %v", err)
+			}
+			auths = append(auths, store)
+		}
+		if cfg.OIDCIssuer != "" {
+			auths = append(auths, jmapmcp.NewOIDC(cfg.OIDCIssuer, cfg.OIDCAudience))
+		}
+		handler = jmapmcp.AuthMiddleware(handler, auths...)
+		watchStatus = jmapmcp.AuthMiddleware(watchStatus, auths...)
+	}
+	mux.Handle("/watch-status", watchStatus)
+	mux.Handle("/", handler)
+
+	log.Printf("Starting HTTP server on %s", cfg.ListenAddr)
+	if err := http.ListenAndServe(cfg.ListenAddr, mux); err != nil {
 		log.Fatalf("HTTP server error: %v", err)
 	}
 }
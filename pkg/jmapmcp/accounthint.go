@@ -0,0 +1,124 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Account hints: with named endpoints configured, one conversation acts on
+// several accounts, and an ID from one means nothing (or something else) in
+// another. withAccountHint heads every result with the account the call
+// acted on; withIDCheck (ids.go) uses the same record to tell a not-found
+// ID another endpoint returned from a typo.
+
+// accountMetaKey is the result _meta key naming the acting account.
+const accountMetaKey = "jmapAccount"
+
+// actingAccount is the account a tool call used.
+type actingAccount struct {
+	Endpoint  string `json:"endpoint"`
+	Username  string `json:"username"`
+	AccountID string `json:"accountId,omitempty"`
+}
+
+func (a actingAccount) String() string {
+	if a.AccountID == "" {
+		return a.Endpoint + "/" + a.Username
+	}
+	return fmt.Sprintf("%s/%s [account: %s]", a.Endpoint, a.Username, a.AccountID)
+}
+
+// accountHeader is the line heading a result that acted on accts.
+func accountHeader(accts []*actingAccount) string {
+	if len(accts) == 1 {
+		return "Account: " + accts[0].String()
+	}
+	labels := make([]string, len(accts))
+	for i, a := range accts {
+		labels[i] = a.String()
+	}
+	return "Accounts: " + strings.Join(labels, ", ")
+}
+
+// accountRecorder collects the accounts of the clients a call dials, once
+// per endpoint; inbox_unified and friends dial several.
+type accountRecorder struct {
+	mu    sync.Mutex
+	accts []*actingAccount
+}
+
+var accountRecorderKey = contextKey{"acting-account"}
+
+func accountRecorderFromContext(ctx context.Context) *accountRecorder {
+	r, _ := ctx.Value(accountRecorderKey).(*accountRecorder)
+	return r
+}
+
+func (r *accountRecorder) record(ctx context.Context, session *jmap.Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := endpointName(ctx)
+	for _, a := range r.accts {
+		if a.Endpoint == name {
+			return
+		}
+	}
+	r.accts = append(r.accts, &actingAccount{
+		Endpoint:  name,
+		Username:  session.Username,
+		AccountID: string(session.PrimaryAccounts[mail.URI]),
+	})
+}
+
+func (r *accountRecorder) accounts() []*actingAccount {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.accts)
+}
+
+// endpointName is the name of the endpoint selected in ctx.
+func endpointName(ctx context.Context) string {
+	if name := EndpointFromContext(ctx); name != "" {
+		return name
+	}
+	return DefaultEndpoint
+}
+
+// withAccountHint lets the call's clients note their accounts (see
+// withIDCheck) and, with named endpoints configured, heads the result with
+// them.
+func withAccountHint[In any](s *Server, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		r := &accountRecorder{}
+		res, out, err := h(context.WithValue(ctx, accountRecorderKey, r), req, in)
+		accts := r.accounts()
+		if res == nil || len(accts) == 0 || len(s.endpoints) == 0 {
+			return res, out, err
+		}
+		if res.Meta == nil {
+			res.Meta = mcp.Meta{}
+		}
+		if len(accts) == 1 {
+			res.Meta[accountMetaKey] = accts[0]
+		} else {
+			res.Meta[accountMetaKey] = accts
+		}
+
+		header := accountHeader(accts)
+		for _, c := range res.Content {
+			if tc, ok := c.(*mcp.TextContent); ok {
+				tc.Text = header + "\n" + tc.Text
+				return res, out, err
+			}
+		}
+		res.Content = append([]mcp.Content{&mcp.TextContent{Text: header}}, res.Content...)
+		return res, out, err
+	}
+}
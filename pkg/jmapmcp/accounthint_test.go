@@ -0,0 +1,54 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func TestAccountHintNamesAccountAndFlagsForeignIDs(t *testing.T) {
+	personal, work := jmapfake.New(), jmapfake.New()
+	addEmail(personal, "p1", "Dinner", "Hi", 1)
+	addEmail(work, "w1", "Budget", "Hi", 2)
+	s := NewServer("test", "https://personal.example/session",
+		WithToken("test"),
+		WithEndpoint("work", "https://work.example/session", ""),
+		WithDialer(routingDialer{
+			"https://personal.example/session": personal,
+			"https://work.example/session":     work,
+		}),
+	)
+	get := withAccountHint(s, withIDCheck(s, s.handleEmailGet))
+
+	res, _, _ := get(ContextWithEndpoint(context.Background(), "work"), nil, EmailGetInput{EmailIDs: []string{"w1"}})
+	out := resultText(res)
+	if res.IsError || !strings.HasPrefix(out, "Account: work/test@example.org [account: "+jmapfake.AccountID+"]\n") {
+		t.Fatalf("email_get on work:\n%s", out)
+	}
+	acct, ok := res.Meta[accountMetaKey].(*actingAccount)
+	if !ok || acct.Endpoint != "work" || acct.Username != "test@example.org" {
+		t.Errorf("meta = %#v", res.Meta[accountMetaKey])
+	}
+
+	res, _, _ = get(context.Background(), nil, EmailGetInput{EmailIDs: []string{"w1"}})
+	out = resultText(res)
+	if !res.IsError || !strings.HasPrefix(out, "Account: default/") || !strings.Contains(out, `w1 was returned by endpoint "work", not "default"; pass endpoint="work"`) {
+		t.Errorf("foreign ID not flagged:\n%s", out)
+	}
+
+	res, _, _ = get(context.Background(), nil, EmailGetInput{EmailIDs: []string{"nope"}})
+	if out := resultText(res); !res.IsError || strings.Contains(out, "returned by endpoint") {
+		t.Errorf("unknown ID flagged:\n%s", out)
+	}
+}
+
+func TestAccountHintOffWithoutEndpoints(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Hello", "Hi", 1)
+	res, _, _ := withAccountHint(s, s.handleEmailGet)(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e1"}})
+	if out := resultText(res); res.IsError || strings.Contains(out, "Account:") || res.Meta[accountMetaKey] != nil {
+		t.Errorf("hint without endpoints:\n%s", out)
+	}
+}
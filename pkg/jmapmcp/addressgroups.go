@@ -0,0 +1,107 @@
+package jmapmcp
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"strings"
+)
+
+// Recipients may be written as RFC 5322 address groups: "Team: a@x.org,
+// Bob <b@y.org>;" or the empty "undisclosed-recipients:;". The To, CC,
+// and BCC properties of a JMAP Email hold addresses only, so on compose a
+// group is replaced by its members and an empty group by nothing. On
+// output, the entries servers leave for a group (an address without "@"
+// holding the group syntax, or a name without an address) are shown as a
+// group again rather than as a broken address.
+
+// addressGroup records an address group named as a recipient and the
+// addresses it was replaced with.
+type addressGroup struct {
+	name    string
+	members []string
+}
+
+// expandAddressGroups replaces every recipient in lists written as an
+// address group with the group's member addresses, and returns the groups.
+func expandAddressGroups(lists ...*[]string) ([]addressGroup, error) {
+	var groups []addressGroup
+	for _, list := range lists {
+		var out []string
+		for _, r := range *list {
+			name, body, ok := splitAddressGroup(r)
+			if !ok {
+				out = append(out, r)
+				continue
+			}
+			g := addressGroup{name: name}
+			if strings.TrimSpace(body) != "" {
+				addrs, err := netmail.ParseAddressList(body)
+				if err != nil {
+					return nil, invalidArgument("address group %q: %v", name, err)
+				}
+				for _, a := range addrs {
+					g.members = append(g.members, a.Address)
+				}
+			}
+			out = append(out, g.members...)
+			groups = append(groups, g)
+		}
+		*list = out
+	}
+	return groups, nil
+}
+
+// splitAddressGroup splits s, written as "name: members;", into the group
+// name and its member list.
+func splitAddressGroup(s string) (name, members string, ok bool) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexByte(s, ':')
+	if i <= 0 || !strings.HasSuffix(s, ";") {
+		return "", "", false
+	}
+	name = strings.TrimSpace(s[:i])
+	if strings.ContainsAny(name, "@<>,;") {
+		return "", "", false
+	}
+	if len(name) >= 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		name = name[1 : len(name)-1]
+	}
+	if name == "" {
+		return "", "", false
+	}
+	return name, s[i+1 : len(s)-1], true
+}
+
+// formatGroupSyntax renders the entry a server returned for an address
+// group, and reports whether name and addr are one.
+func formatGroupSyntax(name, addr string) (string, bool) {
+	if addr == "" && name != "" {
+		return name + ":;", true
+	}
+	if strings.Contains(addr, "@") && !strings.HasSuffix(strings.TrimSpace(addr), ";") {
+		return "", false
+	}
+	if g, members, ok := splitAddressGroup(addr); ok {
+		if members = strings.TrimSpace(members); members != "" {
+			return g + ": " + members + ";", true
+		}
+		return g + ":;", true
+	}
+	if g, ok := strings.CutSuffix(strings.TrimSpace(addr), ":"); ok && g != "" && !strings.Contains(g, ":") {
+		return g + ":;", true
+	}
+	return "", false
+}
+
+// formatAddressGroups describes the groups expandAddressGroups replaced.
+func formatAddressGroups(groups []addressGroup) string {
+	var sb strings.Builder
+	for _, g := range groups {
+		if len(g.members) == 0 {
+			fmt.Fprintf(&sb, "Address group %s is empty and added no recipient; JMAP recipients are addresses only, so the group name is not kept\n", g.name)
+			continue
+		}
+		fmt.Fprintf(&sb, "Expanded address group %s to %d member(s): %s\n", g.name, len(g.members), strings.Join(g.members, ", "))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
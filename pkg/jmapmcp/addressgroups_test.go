@@ -0,0 +1,75 @@
+package jmapmcp
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail"
+)
+
+func TestExpandAddressGroups(t *testing.T) {
+	to := []string{"Team: alice@example.org, Bob <bob@example.org>;", "carol@example.org"}
+	bcc := []string{"undisclosed-recipients:;", "dave@example.org"}
+	groups, err := expandAddressGroups(&to, &bcc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice@example.org", "bob@example.org", "carol@example.org"}; !slices.Equal(to, want) {
+		t.Errorf("to = %v, want %v", to, want)
+	}
+	if want := []string{"dave@example.org"}; !slices.Equal(bcc, want) {
+		t.Errorf("bcc = %v, want %v", bcc, want)
+	}
+	if len(groups) != 2 || groups[0].name != "Team" || len(groups[0].members) != 2 || groups[1].name != "undisclosed-recipients" || groups[1].members != nil {
+		t.Errorf("groups = %+v", groups)
+	}
+
+	bad := []string{"Team: not an address;"}
+	if _, err := expandAddressGroups(&bad); err == nil {
+		t.Error("a group with an invalid member was accepted")
+	}
+	plain := []string{"Team", "a@example.org"}
+	if groups, _ := expandAddressGroups(&plain); groups != nil || len(plain) != 2 {
+		t.Errorf("contact group names taken for address groups: %+v, %v", groups, plain)
+	}
+}
+
+func TestFormatAddressesGroups(t *testing.T) {
+	for _, tt := range []struct {
+		addr mail.Address
+		want string
+	}{
+		{mail.Address{Email: "undisclosed-recipients:;"}, "undisclosed-recipients:;"},
+		{mail.Address{Name: "undisclosed-recipients"}, "undisclosed-recipients:;"},
+		{mail.Address{Name: "x", Email: "Team: a@example.org,  b@example.org ;"}, "Team: a@example.org,  b@example.org;"},
+		{mail.Address{Email: "Friends:"}, "Friends:;"},
+		{mail.Address{Name: "Alice", Email: "alice@example.org"}, "Alice <alice@example.org>"},
+		{mail.Address{Email: "MAILER-DAEMON"}, "MAILER-DAEMON"},
+	} {
+		if got := formatAddresses([]*mail.Address{&tt.addr}); got != tt.want {
+			t.Errorf("formatAddresses(%+v) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestEmailCreateAddressGroups(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	res, _, _ := s.handleEmailCreate(context.Background(), nil, EmailCreateInput{
+		To:      []string{"undisclosed-recipients:;"},
+		BCC:     []string{"Team: alice@example.org, bob@example.org;"},
+		Subject: "Hello",
+		Body:    "Hi",
+	})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("email_create: %s", out)
+	}
+	for _, want := range []string{"Address group undisclosed-recipients is empty", "Expanded address group Team to 2 member(s): alice@example.org, bob@example.org"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("result lacks %q:\n%s", want, out)
+		}
+	}
+}
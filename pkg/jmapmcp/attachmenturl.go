@@ -1,4 +1,4 @@
-package server
+package jmapmcp
 
 import (
 	"crypto/aes"
@@ -11,32 +11,42 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mikluko/jmap"
 )
 
-// attachmentURLTTL bounds the life of a signed attachment URL. Deliberately
-// short: the URL is an unauthenticated capability carrying a sealed JMAP
-// token, meant to be fetched immediately after issuance, not shared or stored.
-const attachmentURLTTL = 30 * time.Second
+// A signed attachment URL is an unauthenticated capability carrying a
+// sealed JMAP token, so it is disposable: it expires shortly after issuance
+// and serves one download. The default life is meant for an agent fetching
+// it at once; a web client offering the link to its user as a download
+// button needs longer, up to MaxAttachmentURLTTL.
+const (
+	DefaultAttachmentURLTTL = 30 * time.Second
+	MaxAttachmentURLTTL     = time.Hour
+)
 
 // attachmentURLer seals attachment download claims into opaque expiring URL
 // tokens and opens them back. Claims are AES-GCM encrypted: they carry the
 // JMAP bearer token, which must never appear in a URL in the clear.
 type attachmentURLer struct {
 	aead cipher.AEAD
+
+	mu    sync.Mutex
+	spent map[string]int64 // nonces of tokens already downloaded → their expiry
 }
 
 // attachmentClaims is the sealed payload of a signed attachment URL. Short
 // JSON keys keep the resulting URL compact.
 type attachmentClaims struct {
-	Token   string `json:"t"` // JMAP bearer token
-	Account string `json:"a"`
-	Blob    string `json:"b"`
-	Name    string `json:"n"`
-	Type    string `json:"c"`
-	Exp     int64  `json:"e"` // unix seconds
+	Token    string `json:"t"`           // JMAP bearer token
+	Endpoint string `json:"x,omitempty"` // named endpoint; empty for the default
+	Account  string `json:"a"`
+	Blob     string `json:"b"`
+	Name     string `json:"n"`
+	Type     string `json:"c"`
+	Exp      int64  `json:"e"` // unix seconds
 }
 
 // newAttachmentURLer derives an AES-256 key from secret, or generates a
@@ -58,7 +68,7 @@ func newAttachmentURLer(secret string) (*attachmentURLer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &attachmentURLer{aead: aead}, nil
+	return &attachmentURLer{aead: aead, spent: make(map[string]int64)}, nil
 }
 
 // seal encrypts claims into a URL-safe opaque token.
@@ -94,9 +104,36 @@ func (u *attachmentURLer) open(token string) (*attachmentClaims, error) {
 	return c, nil
 }
 
+// spend marks token, expiring at exp, as used and reports whether it was
+// still unused. Spent tokens are remembered until they expire, and only by
+// this process: replicas sharing a secret each honour a link once.
+func (u *attachmentURLer) spend(token string, exp int64) bool {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(sealed) < u.aead.NonceSize() {
+		return false
+	}
+	nonce := string(sealed[:u.aead.NonceSize()])
+	now := time.Now().Unix()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for n, e := range u.spent {
+		if e < now {
+			delete(u.spent, n)
+		}
+	}
+	if _, ok := u.spent[nonce]; ok {
+		return false
+	}
+	u.spent[nonce] = exp
+	return true
+}
+
 // AttachmentHandler serves GET /attachments/{token} by streaming the blob
 // from the JMAP server to the client. The sealed token is the sole access
-// control; no other authentication is applied.
+// control; no other authentication is applied. Each token serves one
+// download: it is spent once the JMAP server starts sending the blob, so a
+// failed upstream download leaves the link usable.
 func (s *Server) AttachmentHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if s.attachmentURL == nil {
@@ -107,7 +144,8 @@ func (s *Server) AttachmentHandler() http.Handler {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		claims, err := s.attachmentURL.open(strings.TrimPrefix(r.URL.Path, "/attachments/"))
+		opaque := strings.TrimPrefix(r.URL.Path, "/attachments/")
+		claims, err := s.attachmentURL.open(opaque)
 		if err != nil {
 			http.Error(w, "invalid token", http.StatusForbidden)
 			return
@@ -117,13 +155,26 @@ func (s *Server) AttachmentHandler() http.Handler {
 			return
 		}
 
-		client := (&jmap.Client{SessionEndpoint: s.sessionURL}).WithAccessToken(claims.Token)
-		body, err := client.DownloadWithContext(r.Context(), jmap.ID(claims.Account), jmap.ID(claims.Blob))
+		ep, err := s.endpointFor(ContextWithEndpoint(r.Context(), claims.Endpoint))
+		if err != nil {
+			http.Error(w, "unknown endpoint", http.StatusNotFound)
+			return
+		}
+		client, err := s.dial(r.Context(), ep.sessionURL, claims.Token)
+		if err != nil {
+			http.Error(w, "upstream session failed", http.StatusBadGateway)
+			return
+		}
+		body, err := client.Download(r.Context(), jmap.ID(claims.Account), jmap.ID(claims.Blob))
 		if err != nil {
 			http.Error(w, "upstream download failed", http.StatusBadGateway)
 			return
 		}
 		defer body.Close()
+		if !s.attachmentURL.spend(opaque, claims.Exp) {
+			http.Error(w, "link already used", http.StatusGone)
+			return
+		}
 
 		contentType := claims.Type
 		if contentType == "" {
@@ -131,6 +182,7 @@ func (s *Server) AttachmentHandler() http.Handler {
 		}
 		w.Header().Set("Content-Type", contentType)
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sanitizeFilename(claims.Name)))
+		w.Header().Set("Cache-Control", "no-store")
 		io.Copy(w, body)
 	})
 }
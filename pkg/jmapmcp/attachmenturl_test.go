@@ -1,10 +1,13 @@
-package server
+package jmapmcp
 
 import (
+	"context"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func testClaims(exp int64) *attachmentClaims {
@@ -132,6 +135,35 @@ func TestAttachmentHandlerAuth(t *testing.T) {
 	})
 }
 
+func TestAttachmentURLSingleUse(t *testing.T) {
+	s, fake := newFakeServer(t, WithAttachmentURL("test-secret", "https://mcp.example/"), WithAttachmentURLTTL(10*time.Minute))
+	blob := fake.AddBlob([]byte("%PDF-1.4"))
+	fake.Add("Email", map[string]any{"id": "m1", "attachments": []any{
+		map[string]any{"blobId": blob, "name": "report.pdf", "type": "application/pdf", "size": 8}}})
+
+	res, _, _ := s.handleEmailAttachmentURL(context.Background(), nil, EmailAttachmentURLInput{EmailID: "m1"})
+	if res.IsError || len(res.Content) != 2 || !strings.Contains(resultText(res), "one download in the next 600 seconds") {
+		t.Fatalf("email_attachment_url = %s", resultText(res))
+	}
+	link, ok := res.Content[1].(*mcp.ResourceLink)
+	if !ok || !strings.HasPrefix(link.URI, "https://mcp.example/attachments/") || link.Name != "report.pdf" ||
+		link.MIMEType != "application/pdf" || link.Size == nil || *link.Size != 8 {
+		t.Fatalf("resource link = %#v", res.Content[1])
+	}
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.AttachmentHandler().ServeHTTP(rec, httptest.NewRequest("GET", strings.TrimPrefix(link.URI, "https://mcp.example"), nil))
+		return rec
+	}
+	if rec := get(); rec.Code != 200 || rec.Body.String() != "%PDF-1.4" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("first download = %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if rec := get(); rec.Code != 410 || !strings.Contains(rec.Body.String(), "already used") {
+		t.Errorf("second download = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
 func TestSanitizeFilename(t *testing.T) {
 	tests := []struct{ in, want string }{
 		{"", "attachment"},
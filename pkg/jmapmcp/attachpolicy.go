@@ -0,0 +1,79 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// AttachmentPolicy restricts what may be uploaded or attached to outgoing
+// mail. Zero values disable the corresponding rule.
+type AttachmentPolicy struct {
+	AllowedTypes      []string // media types allowed ("image/*" wildcards); empty allows all
+	BlockedTypes      []string // media types refused ("application/x-msdownload", "video/*")
+	BlockedExtensions []string // file name extensions refused (".exe", "bat")
+	MaxSize           int      // max bytes per attachment
+}
+
+// attachmentPolicy enforces an AttachmentPolicy. A nil *attachmentPolicy
+// permits everything.
+type attachmentPolicy struct {
+	AttachmentPolicy
+}
+
+func newAttachmentPolicy(p AttachmentPolicy) *attachmentPolicy {
+	return &attachmentPolicy{AttachmentPolicy: p}
+}
+
+// check validates one attachment by file name, media type, and size in
+// bytes. A negative size skips the size rule (size unknown).
+func (p *attachmentPolicy) check(name, mediaType string, size int) error {
+	if p == nil {
+		return nil
+	}
+	label := name
+	if label == "" {
+		label = "(unnamed)"
+	}
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if base, _, ok := strings.Cut(mediaType, ";"); ok {
+		mediaType = strings.TrimSpace(base)
+	}
+
+	if ext := strings.ToLower(path.Ext(name)); ext != "" {
+		for _, blocked := range p.BlockedExtensions {
+			if ext == "."+strings.TrimPrefix(strings.ToLower(blocked), ".") {
+				return &policyError{Policy: "attachment", Rule: "blocked_extension",
+					Detail: fmt.Sprintf("%s: %s files are not allowed", label, ext)}
+			}
+		}
+	}
+	if matchesMediaType(mediaType, p.BlockedTypes) {
+		return &policyError{Policy: "attachment", Rule: "blocked_type",
+			Detail: fmt.Sprintf("%s: type %s is not allowed", label, mediaType)}
+	}
+	if len(p.AllowedTypes) > 0 && !matchesMediaType(mediaType, p.AllowedTypes) {
+		return &policyError{Policy: "attachment", Rule: "allowed_types",
+			Detail: fmt.Sprintf("%s: type %s is outside the allowed types (%s)", label, mediaType, strings.Join(p.AllowedTypes, ", "))}
+	}
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return &policyError{Policy: "attachment", Rule: "max_size",
+			Detail: fmt.Sprintf("%s: %d bytes exceeds the limit of %d bytes", label, size, p.MaxSize)}
+	}
+	return nil
+}
+
+// matchesMediaType reports whether mediaType matches one of patterns, where
+// "type/*" matches any subtype.
+func matchesMediaType(mediaType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
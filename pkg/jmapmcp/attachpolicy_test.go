@@ -0,0 +1,56 @@
+package jmapmcp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAttachmentPolicyCheck(t *testing.T) {
+	p := newAttachmentPolicy(AttachmentPolicy{
+		AllowedTypes:      []string{"image/*", "application/pdf", "application/octet-stream"},
+		BlockedTypes:      []string{"image/svg+xml"},
+		BlockedExtensions: []string{"exe", ".BAT"},
+		MaxSize:           1000,
+	})
+
+	tests := []struct {
+		name      string
+		file      string
+		mediaType string
+		size      int
+		wantRule  string
+	}{
+		{"allowed pdf", "report.pdf", "application/pdf", 500, ""},
+		{"allowed wildcard", "photo.jpg", "image/jpeg", 500, ""},
+		{"type parameters ignored", "photo.png", "Image/PNG; name=photo.png", 500, ""},
+		{"unknown size", "report.pdf", "application/pdf", -1, ""},
+		{"blocked extension", "setup.exe", "application/octet-stream", 10, "blocked_extension"},
+		{"blocked extension case", "run.bat", "application/octet-stream", 10, "blocked_extension"},
+		{"blocked type within allowed wildcard", "logo.svg", "image/svg+xml", 10, "blocked_type"},
+		{"outside allowlist", "notes.txt", "text/plain", 10, "allowed_types"},
+		{"too large", "big.pdf", "application/pdf", 1001, "max_size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.check(tt.file, tt.mediaType, tt.size)
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var pe *policyError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got %v, want policy error", err)
+			}
+			if pe.Policy != "attachment" || pe.Rule != tt.wantRule {
+				t.Errorf("got %s/%s, want attachment/%s", pe.Policy, pe.Rule, tt.wantRule)
+			}
+		})
+	}
+
+	var none *attachmentPolicy
+	if err := none.check("setup.exe", "application/x-msdownload", 1<<30); err != nil {
+		t.Errorf("nil policy refused attachment: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package jmapmcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Attachment scanning: an operator-supplied virus scanner (clamd, or any
+// command following clamscan's exit codes) that sees every attachment
+// before its content reaches the model or the user's machine, in
+// email_attachment_image, attachment_download, and email_attachment_url.
+// A positive is refused, or only flagged when the operator asked for
+// warnings; so is an attachment the scanner could not check.
+
+const (
+	// scanTimeout bounds one scan.
+	scanTimeout = 60 * time.Second
+	// maxScanBytes caps the attachment handed to the scanner; clamd's
+	// default StreamMaxLength is 25 MiB.
+	maxScanBytes = 64 << 20
+	// clamdChunk is the size of the INSTREAM chunks sent to clamd.
+	clamdChunk = 64 << 10
+)
+
+// AttachmentScanner checks attachments for malware.
+type AttachmentScanner interface {
+	// Scan checks data, the content of attachment name, and returns the
+	// name of the threat found, or "" when it is clean.
+	Scan(ctx context.Context, name string, data []byte) (threat string, err error)
+}
+
+// AttachmentScan configures attachment scanning.
+type AttachmentScan struct {
+	Scanner  AttachmentScanner
+	WarnOnly bool // flag positives and failed scans instead of refusing the attachment
+}
+
+// ClamdScanner scans attachments with a clamd daemon over its INSTREAM
+// command. Address is a Unix socket path or a host:port.
+type ClamdScanner struct {
+	Address string
+}
+
+func (c ClamdScanner) Scan(ctx context.Context, _ string, data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+
+	network, address := "tcp", c.Address
+	if rest, ok := strings.CutPrefix(address, "unix:"); ok {
+		network, address = "unix", rest
+	} else if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	var size [4]byte
+	for len(data) > 0 {
+		n := min(len(data), clamdChunk)
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		w.Write(size[:])
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", err
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply reads clamd's answer to INSTREAM: "stream: OK",
+// "stream: <threat> FOUND", or an error such as "INSTREAM size limit
+// exceeded. ERROR".
+func parseClamdReply(reply string) (string, error) {
+	_, result, _ := strings.Cut(reply, ": ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// ScanCommand scans attachments with an external program and its
+// arguments, such as clamdscan --no-summary -. The attachment is written
+// to its stdin and its file name is in JMAP_MCP_ATTACHMENT_NAME. As with
+// clamscan, exit status 0 means clean and 1 means infected, with the
+// threat named on the last line of stdout ("stdin: <threat> FOUND", or
+// the bare name); any other status is a failure, reported with its
+// stderr.
+type ScanCommand []string
+
+func (c ScanCommand) Scan(ctx context.Context, name string, data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c[0], c[1:]...)
+	cmd.Env = append(os.Environ(), "JMAP_MCP_ATTACHMENT_NAME="+name)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		return "", nil
+	case errors.As(err, &exit) && exit.ExitCode() == 1:
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		threat := strings.TrimSpace(lines[len(lines)-1])
+		if _, after, ok := strings.Cut(threat, ": "); ok {
+			threat = after
+		}
+		threat = strings.TrimSuffix(threat, " FOUND")
+		if threat == "" {
+			threat = "unnamed threat"
+		}
+		return threat, nil
+	default:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+}
+
+// scanAttachment runs the attachment scanner on part and returns a note
+// for the result: empty without a scanner, the verdict otherwise. A
+// positive or a failed scan is a policy refusal unless the scan only
+// warns, in which case the note says so. data is the attachment's
+// content when the caller already downloaded it.
+func (s *Server) scanAttachment(ctx context.Context, client JMAPClient, accountID jmap.ID, part *email.BodyPart, data []byte) (string, error) {
+	if s.scan == nil {
+		return "", nil
+	}
+	name := part.Name
+	if name == "" {
+		name = "(unnamed)"
+	}
+	var threat string
+	var err error
+	if data == nil {
+		data, err = downloadBlob(ctx, client, accountID, part.BlobID, maxScanBytes)
+	}
+	if err == nil {
+		threat, err = s.scan.Scanner.Scan(ctx, name, data)
+	}
+	switch {
+	case err != nil && s.scan.WarnOnly:
+		return fmt.Sprintf("WARNING: %s could not be scanned for viruses (%v); treat it with care", name, err), nil
+	case err != nil:
+		return "", &policyError{Policy: "attachment", Rule: "virus_scan",
+			Detail: fmt.Sprintf("%s could not be scanned for viruses, so it is withheld: %v", name, err)}
+	case threat != "" && s.scan.WarnOnly:
+		return fmt.Sprintf("WARNING: the virus scanner flagged %s as %s; do not open it", name, threat), nil
+	case threat != "":
+		return "", &policyError{Policy: "attachment", Rule: "virus_scan",
+			Detail: fmt.Sprintf("%s is infected (%s) and is withheld", name, threat)}
+	}
+	return "Virus scan: clean", nil
+}
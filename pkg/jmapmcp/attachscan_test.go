@@ -0,0 +1,133 @@
+package jmapmcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeClamd serves clamd's INSTREAM command on a Unix socket, finding a
+// threat in any stream that contains "EICAR".
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "clamd.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+				conn.Close()
+				continue
+			}
+			var data []byte
+			for {
+				var size uint32
+				if binary.Read(r, binary.BigEndian, &size) != nil || size == 0 {
+					break
+				}
+				chunk := make([]byte, size)
+				io.ReadFull(r, chunk)
+				data = append(data, chunk...)
+			}
+			if bytes.Contains(data, []byte("EICAR")) {
+				conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+	return sock
+}
+
+func TestClamdScanner(t *testing.T) {
+	c := ClamdScanner{Address: fakeClamd(t)}
+	ctx := context.Background()
+	if threat, err := c.Scan(ctx, "a.txt", bytes.Repeat([]byte("clean "), 50000)); err != nil || threat != "" {
+		t.Errorf("clean stream = %q, %v", threat, err)
+	}
+	if threat, err := c.Scan(ctx, "a.txt", []byte("X5O!P%@AP EICAR")); err != nil || threat != "Eicar-Test-Signature" {
+		t.Errorf("infected stream = %q, %v", threat, err)
+	}
+	if _, err := parseClamdReply("INSTREAM size limit exceeded. ERROR"); err == nil {
+		t.Error("clamd error reply accepted")
+	}
+}
+
+func TestScanCommand(t *testing.T) {
+	cmd := ScanCommand{"sh", "-c", `if grep -q EICAR; then echo "stdin: Eicar-Signature FOUND"; exit 1; fi; test "$JMAP_MCP_ATTACHMENT_NAME" = a.txt`}
+	ctx := context.Background()
+	if threat, err := cmd.Scan(ctx, "a.txt", []byte("hello")); err != nil || threat != "" {
+		t.Errorf("clean = %q, %v", threat, err)
+	}
+	if threat, err := cmd.Scan(ctx, "a.txt", []byte("EICAR")); err != nil || threat != "Eicar-Signature" {
+		t.Errorf("infected = %q, %v", threat, err)
+	}
+	_, err := ScanCommand{"sh", "-c", "echo database missing >&2; exit 2"}.Scan(ctx, "a.txt", nil)
+	if err == nil || !strings.Contains(err.Error(), "database missing") {
+		t.Errorf("scanner failure = %v", err)
+	}
+}
+
+// scanFunc adapts a function to AttachmentScanner.
+type scanFunc func(data []byte) (string, error)
+
+func (f scanFunc) Scan(_ context.Context, _ string, data []byte) (string, error) { return f(data) }
+
+func TestAttachmentScanPolicy(t *testing.T) {
+	scanner := scanFunc(func(data []byte) (string, error) {
+		switch {
+		case bytes.Contains(data, []byte("EICAR")):
+			return "Eicar-Signature", nil
+		case bytes.Contains(data, []byte("BROKEN")):
+			return "", errors.New("scanner unavailable")
+		}
+		return "", nil
+	})
+	setup := func(warnOnly bool) *Server {
+		s, fake := newFakeServer(t, WithAttachmentScan(AttachmentScan{Scanner: scanner, WarnOnly: warnOnly}))
+		fake.Add("Email", map[string]any{"id": "clean", "attachments": []any{map[string]any{
+			"blobId": fake.AddBlob(pngImage(t, 10, 10, color.NRGBA{R: 255, A: 255})), "name": "dot.png", "type": "image/png", "size": 100}}})
+		fake.Add("Email", map[string]any{"id": "infected", "attachments": []any{map[string]any{
+			"blobId": fake.AddBlob(append(pngImage(t, 10, 10, color.NRGBA{A: 255}), "EICAR"...)), "name": "bad.png", "type": "image/png", "size": 100}}})
+		fake.Add("Email", map[string]any{"id": "unscannable", "attachments": []any{map[string]any{
+			"blobId": fake.AddBlob(append(pngImage(t, 10, 10, color.NRGBA{A: 255}), "BROKEN"...)), "name": "odd.png", "type": "image/png", "size": 100}}})
+		return s
+	}
+	ctx := context.Background()
+
+	s := setup(false)
+	res, _, _ := s.handleEmailAttachmentImage(ctx, nil, EmailAttachmentImageInput{EmailID: "clean"})
+	if res.IsError || !strings.Contains(resultText(res), "Virus scan: clean") {
+		t.Errorf("clean image = %s", resultText(res))
+	}
+	for id, want := range map[string]string{"infected": "infected (Eicar-Signature)", "unscannable": "could not be scanned"} {
+		res, _, _ = s.handleEmailAttachmentImage(ctx, nil, EmailAttachmentImageInput{EmailID: id})
+		te, ok := res.StructuredContent.(*toolError)
+		if !res.IsError || !ok || te.Code != codePolicyViolation || te.Rule != "virus_scan" || !strings.Contains(te.Message, want) || len(res.Content) != 1 {
+			t.Errorf("%s image = %s", id, resultText(res))
+		}
+	}
+
+	s = setup(true)
+	res, _, _ = s.handleEmailAttachmentImage(ctx, nil, EmailAttachmentImageInput{EmailID: "infected"})
+	if res.IsError || len(res.Content) != 2 || !strings.Contains(resultText(res), "WARNING: the virus scanner flagged bad.png as Eicar-Signature") {
+		t.Errorf("infected image with warnings only = %s", resultText(res))
+	}
+}
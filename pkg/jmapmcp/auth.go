@@ -0,0 +1,394 @@
+package jmapmcp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errUnauthenticated is returned for credentials no authenticator accepts.
+var errUnauthenticated = errors.New("invalid credentials")
+
+// Authenticator verifies the bearer credential presented to the MCP server
+// itself, independently of the JMAP token passed through to the backend. On
+// success it returns ctx, possibly extended with what the credential grants.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (context.Context, error)
+}
+
+// AuthMiddleware is HTTP middleware that requires Authorization: Bearer
+// <credential> accepted by any of auths before calling next. The JMAP token
+// must then arrive via TokenSources without the Authorization source.
+func AuthMiddleware(next http.Handler, auths ...Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || credential == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="jmap-mcp"`)
+			http.Error(w, "missing bearer credential", http.StatusUnauthorized)
+			return
+		}
+		for _, a := range auths {
+			if ctx, err := a.Authenticate(r.Context(), credential); err == nil {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="jmap-mcp", error="invalid_token"`)
+		http.Error(w, errUnauthenticated.Error(), http.StatusUnauthorized)
+	})
+}
+
+// APIKeys authenticates callers against a fixed set of static keys. Only
+// digests are kept, and comparison is constant-time.
+type APIKeys struct {
+	digests [][sha256.Size]byte
+}
+
+// NewAPIKeys returns an authenticator accepting any of keys.
+func NewAPIKeys(keys []string) *APIKeys {
+	a := &APIKeys{}
+	for _, k := range keys {
+		a.digests = append(a.digests, sha256.Sum256([]byte(k)))
+	}
+	return a
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeys) Authenticate(ctx context.Context, credential string) (context.Context, error) {
+	sum := sha256.Sum256([]byte(credential))
+	match := 0
+	for _, d := range a.digests {
+		match |= subtle.ConstantTimeCompare(sum[:], d[:])
+	}
+	if match != 1 {
+		return nil, errUnauthenticated
+	}
+	return ctx, nil
+}
+
+// oidcLeeway tolerates clock skew when checking exp and nbf.
+const oidcLeeway = time.Minute
+
+// oidcRefreshInterval limits how often an unknown key ID triggers a JWKS
+// refetch.
+const oidcRefreshInterval = time.Minute
+
+// OIDC authenticates callers by verifying JWT access or ID tokens signed by
+// an OpenID Connect issuer. Signing keys are discovered from the issuer's
+// /.well-known/openid-configuration and cached.
+type OIDC struct {
+	Issuer     string
+	Audience   string // required "aud" value; empty skips the check
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewOIDC returns an authenticator for tokens from issuer intended for
+// audience.
+func NewOIDC(issuer, audience string) *OIDC {
+	return &OIDC{Issuer: strings.TrimSuffix(issuer, "/"), Audience: audience}
+}
+
+// Authenticate implements Authenticator.
+func (o *OIDC) Authenticate(ctx context.Context, credential string) (context.Context, error) {
+	header, claims, signed, sig, err := splitJWT(credential)
+	if err != nil {
+		return nil, err
+	}
+	key, err := o.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Algorithm, key, signed, sig); err != nil {
+		return nil, err
+	}
+	if err := o.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// jwtHeader is the JOSE header of a JWS.
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// jwtClaims holds the registered claims checked by OIDC.
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+}
+
+// splitJWT decodes a compact JWS into its header, claims, signing input, and
+// signature.
+func splitJWT(token string) (*jwtHeader, *jwtClaims, []byte, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, nil, errUnauthenticated
+	}
+	var header jwtHeader
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("decode signature: %w", err)
+	}
+	return &header, &claims, []byte(parts[0] + "." + parts[1]), sig, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("decode token: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode token: %w", err)
+	}
+	return nil
+}
+
+// ecdsaCurves is the curve each ES algorithm signs with.
+var ecdsaCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// verifyJWTSignature checks sig over signed with key for alg. Only
+// asymmetric algorithms are accepted, so "none" and HMAC confusion attacks
+// are refused, and an EC key must be on the curve its algorithm names.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var h crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		h = crypto.SHA256
+	case "RS384", "ES384":
+		h = crypto.SHA384
+	case "RS512", "ES512":
+		h = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	hasher := h.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' {
+			return fmt.Errorf("algorithm %s does not match RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, h, digest, sig); err != nil {
+			return errUnauthenticated
+		}
+	case *ecdsa.PublicKey:
+		// Each ES algorithm fixes its curve (RFC 7518, section 3.4).
+		if want := ecdsaCurves[alg]; want == nil || k.Curve != want {
+			return fmt.Errorf("algorithm %s does not match EC key on %s", alg, k.Curve.Params().Name)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errUnauthenticated
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errUnauthenticated
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// checkClaims validates issuer, audience, and validity window at now.
+func (o *OIDC) checkClaims(c *jwtClaims, now time.Time) error {
+	if c.Issuer != o.Issuer {
+		return fmt.Errorf("token issuer %q is not %q", c.Issuer, o.Issuer)
+	}
+	if c.ExpiresAt == nil || now.After(time.Unix(int64(*c.ExpiresAt), 0).Add(oidcLeeway)) {
+		return errors.New("token expired")
+	}
+	if c.NotBefore != nil && now.Add(oidcLeeway).Before(time.Unix(int64(*c.NotBefore), 0)) {
+		return errors.New("token not yet valid")
+	}
+	if o.Audience == "" {
+		return nil
+	}
+	var auds []string
+	if err := json.Unmarshal(c.Audience, &auds); err != nil {
+		var aud string
+		if json.Unmarshal(c.Audience, &aud) != nil {
+			return errors.New("token has no audience")
+		}
+		auds = []string{aud}
+	}
+	for _, a := range auds {
+		if a == o.Audience {
+			return nil
+		}
+	}
+	return fmt.Errorf("token audience does not include %q", o.Audience)
+}
+
+// key returns the signing key kid, refreshing the JWKS when it is unknown.
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if k, ok := o.lookupKey(kid); ok {
+		return k, nil
+	}
+	if !o.fetchedAt.IsZero() && time.Since(o.fetchedAt) < oidcRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	keys, err := o.fetchKeys(ctx)
+	o.fetchedAt = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	o.keys = keys
+	if k, ok := o.lookupKey(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds kid in the cached set. A token without kid matches the
+// only key when the set has exactly one.
+func (o *OIDC) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(o.keys) == 1 {
+		for _, k := range o.keys {
+			return k, true
+		}
+	}
+	k, ok := o.keys[kid]
+	return k, ok
+}
+
+// fetchKeys resolves jwks_uri from the issuer's discovery document and
+// parses its RSA and EC signing keys.
+func (o *OIDC) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := o.getJSON(ctx, o.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: no jwks_uri")
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := o.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.KeyID] = pub
+		}
+	}
+	return keys, nil
+}
+
+func (o *OIDC) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	client := o.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jwk is a JSON Web Key (RFC 7517) with the RSA and EC public parameters.
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("EC point not on curve")
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
@@ -0,0 +1,232 @@
+package jmapmcp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIKeys(t *testing.T) {
+	a := NewAPIKeys([]string{"key-one", "key-two"})
+	for _, k := range []string{"key-one", "key-two"} {
+		if _, err := a.Authenticate(context.Background(), k); err != nil {
+			t.Errorf("Authenticate(%q) = %v", k, err)
+		}
+	}
+	for _, k := range []string{"", "key-three", "key-one "} {
+		if _, err := a.Authenticate(context.Background(), k); err == nil {
+			t.Errorf("Authenticate(%q) succeeded", k)
+		}
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	h := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), NewAPIKeys([]string{"secret"}))
+
+	tests := []struct {
+		name string
+		auth string
+		want int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "Bearer nope", http.StatusUnauthorized},
+		{"basic", "Basic c2VjcmV0", http.StatusUnauthorized},
+		{"ok", "Bearer secret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSources(t *testing.T) {
+	tests := []struct {
+		name       string
+		sources    TokenSources
+		url        string
+		header     string
+		auth       string
+		wantToken  string
+		wantStatus int
+	}{
+		{"query", TokenSources{Query: true, Authorization: true}, "/mcp?jmap_token=q", "", "Bearer a", "q", http.StatusOK},
+		{"authorization", TokenSources{Query: true, Authorization: true}, "/mcp", "", "Bearer a", "a", http.StatusOK},
+		{"header beats authorization", TokenSources{Query: true, Authorization: true}, "/mcp", "h", "Bearer a", "h", http.StatusOK},
+		{"authorization disabled", TokenSources{Query: true}, "/mcp", "", "Bearer a", "", http.StatusOK},
+		{"query rejected", TokenSources{}, "/mcp?jmap_token=q", "h", "", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := tt.sources.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = TokenFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodPost, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set(TokenHeader, tt.header)
+			}
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got != tt.wantToken {
+				t.Errorf("token = %q, want %q", got, tt.wantToken)
+			}
+		})
+	}
+}
+
+// signJWT builds a compact JWS over claims with the given key.
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := enc(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDC(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		}})
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	issuer = ts.URL
+
+	o := NewOIDC(issuer, "jmap-mcp")
+	now := time.Now().Unix()
+	valid := map[string]any{"iss": issuer, "aud": "jmap-mcp", "exp": now + 300}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"rsa", signJWT(t, "RS256", "rsa1", rsaKey, valid), false},
+		{"ec", signJWT(t, "ES256", "ec1", ecKey, valid), false},
+		{"aud array", signJWT(t, "RS256", "rsa1", rsaKey, map[string]any{"iss": issuer, "aud": []string{"other", "jmap-mcp"}, "exp": now + 300}), false},
+		{"wrong audience", signJWT(t, "RS256", "rsa1", rsaKey, map[string]any{"iss": issuer, "aud": "other", "exp": now + 300}), true},
+		{"wrong issuer", signJWT(t, "RS256", "rsa1", rsaKey, map[string]any{"iss": "https://evil.example", "aud": "jmap-mcp", "exp": now + 300}), true},
+		{"expired", signJWT(t, "RS256", "rsa1", rsaKey, map[string]any{"iss": issuer, "aud": "jmap-mcp", "exp": now - 3600}), true},
+		{"no exp", signJWT(t, "RS256", "rsa1", rsaKey, map[string]any{"iss": issuer, "aud": "jmap-mcp"}), true},
+		{"bad signature", signJWT(t, "RS256", "rsa1", otherKey, valid), true},
+		{"alg mismatch", signJWT(t, "ES256", "rsa1", ecKey, valid), true},
+		{"not a jwt", "static-key", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := o.Authenticate(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authenticate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyJWTSignatureCurve(t *testing.T) {
+	sign := func(t *testing.T, k *ecdsa.PrivateKey, h crypto.Hash, signed []byte) []byte {
+		t.Helper()
+		hasher := h.New()
+		hasher.Write(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, k, hasher.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	signed := []byte("header.claims")
+	tests := []struct {
+		name    string
+		curve   elliptic.Curve
+		alg     string
+		hash    crypto.Hash
+		wantErr bool
+	}{
+		{"ES256 on P-256", elliptic.P256(), "ES256", crypto.SHA256, false},
+		{"ES384 on P-384", elliptic.P384(), "ES384", crypto.SHA384, false},
+		{"ES512 on P-521", elliptic.P521(), "ES512", crypto.SHA512, false},
+		{"ES256 on P-384", elliptic.P384(), "ES256", crypto.SHA256, true},
+		{"ES512 on P-256", elliptic.P256(), "ES512", crypto.SHA512, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ecdsa.GenerateKey(tt.curve, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = verifyJWTSignature(tt.alg, &k.PublicKey, signed, sign(t, k, tt.hash, signed))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyJWTSignature() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,616 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+// Automations act on new mail without an MCP client: each names a mailbox
+// to watch, optional from/to/subject conditions, and an action. They are
+// configured with the automation_* tools and run by RunAutomations, which
+// registers one watch per automation of the configured token's user and,
+// instead of notifying a session, applies the action to the new messages
+// that match. A daemon (-mode daemon) runs them alone and rereads the store
+// when another process changes it.
+
+// Automation actions.
+const (
+	automationFile     = "file"     // move to the target mailbox
+	automationFlag     = "flag"     // set the target keyword
+	automationForward  = "forward"  // forward to the target address and send
+	automationWebhook  = "webhook"  // POST the matches to the target URL
+	automationScript   = "script"   // run the target script on the matches
+	automationClassify = "classify" // record the category the classification endpoint picks
+)
+
+const (
+	maxAutomations           = 50 // per user
+	automationReloadInterval = time.Minute
+	automationWebhookTimeout = 15 * time.Second
+)
+
+// automation is one configured rule.
+type automation struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	MailboxID jmap.ID   `json:"mailboxId"`
+	From      string    `json:"from,omitempty"`    // sender contains, case-insensitively
+	To        string    `json:"to,omitempty"`      // a To or Cc recipient contains
+	Subject   string    `json:"subject,omitempty"` // subject contains
+	Action    string    `json:"action"`
+	Target    string    `json:"target"` // mailbox ID, keyword, address, URL, or script name; empty for classify
+	Created   time.Time `json:"created"`
+}
+
+// matches reports whether e meets a's conditions.
+func (a *automation) matches(e *email.Email) bool {
+	contains := func(addrs []*mail.Address, s string) bool {
+		return slices.ContainsFunc(addrs, func(addr *mail.Address) bool {
+			return strings.Contains(strings.ToLower(addr.Name+" "+addr.Email), strings.ToLower(s))
+		})
+	}
+	if a.From != "" && !contains(e.From, a.From) {
+		return false
+	}
+	if a.To != "" && !contains(e.To, a.To) && !contains(e.CC, a.To) {
+		return false
+	}
+	return a.Subject == "" || strings.Contains(strings.ToLower(e.Subject), strings.ToLower(a.Subject))
+}
+
+// Automations keeps each JMAP user's automations, keyed by the session
+// username. When it has a store, they are saved there as JSON after every
+// change so they survive restarts and reach a daemon sharing the file; the
+// zero value keeps them in memory.
+type Automations struct {
+	mu       sync.Mutex
+	store    store.Store
+	doc      string    // the store's document name
+	modTime  time.Time // of the document as last read or written
+	users    map[string][]*automation
+	changed  chan struct{} // wakes RunAutomations after a change
+	running  string        // user RunAutomations runs for; empty when it does not run
+	statuses map[string]*automationStatus
+}
+
+// automationStatus is what running an automation did last, in memory.
+type automationStatus struct {
+	runs      int // messages acted on
+	last      time.Time
+	lastError string
+}
+
+// LoadAutomations reads the automations saved in st as doc, a JSON object
+// of the form {"user": [automation, ...]}. A missing document has none and
+// is created on the first one.
+func LoadAutomations(st store.Store, doc string) (*Automations, error) {
+	a := &Automations{store: st, doc: doc}
+	if _, err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// reload rereads the document when it changed since it was last read or
+// written, and reports whether it did.
+func (a *Automations) reload() (bool, error) {
+	if a.store == nil {
+		return false, nil
+	}
+	modTime, err := a.store.Stat(a.doc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("automations: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if modTime.Equal(a.modTime) {
+		return false, nil
+	}
+	data, modTime, err := a.store.Read(a.doc)
+	if err != nil {
+		return false, fmt.Errorf("automations: %w", err)
+	}
+	var users map[string][]*automation
+	if err := json.Unmarshal(data, &users); err != nil {
+		return false, fmt.Errorf("automations %s: %w", a.doc, err)
+	}
+	for user, list := range users {
+		for _, auto := range list {
+			if err := checkAutomation(auto); err != nil {
+				return false, fmt.Errorf("automations %s: %s of %s: %w", a.doc, auto.ID, user, err)
+			}
+		}
+	}
+	a.users, a.modTime = users, modTime
+	return true, nil
+}
+
+// list returns copies of user's automations, oldest first.
+func (a *Automations) list(user string) []automation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]automation, len(a.users[user]))
+	for i, auto := range a.users[user] {
+		out[i] = *auto
+	}
+	return out
+}
+
+// get returns a copy of user's automation id, or nil.
+func (a *Automations) get(user, id string) *automation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, auto := range a.users[user] {
+		if auto.ID == id {
+			c := *auto
+			return &c
+		}
+	}
+	return nil
+}
+
+// add gives auto an ID, appends it to user's automations, and saves the
+// store.
+func (a *Automations) add(user string, auto *automation) error {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	auto.ID = "auto-" + hex.EncodeToString(b[:])
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	current := a.users[user]
+	if len(current) >= maxAutomations {
+		return invalidArgument("%s already has %d automations, the limit", user, maxAutomations)
+	}
+	if err := a.replace(user, append(slices.Clone(current), auto)); err != nil {
+		return err
+	}
+	a.wake()
+	return nil
+}
+
+// remove deletes user's automation id and saves the store, reporting
+// whether it existed.
+func (a *Automations) remove(user, id string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	current := a.users[user]
+	next := slices.DeleteFunc(slices.Clone(current), func(auto *automation) bool { return auto.ID == id })
+	if len(next) == len(current) {
+		return false, nil
+	}
+	if err := a.replace(user, next); err != nil {
+		return false, err
+	}
+	delete(a.statuses, id)
+	a.wake()
+	return true, nil
+}
+
+// replace sets user's automations and saves the store, restoring the
+// previous ones when saving fails. The caller holds a.mu.
+func (a *Automations) replace(user string, list []*automation) error {
+	if a.users == nil {
+		a.users = make(map[string][]*automation)
+	}
+	previous, existed := a.users[user]
+	if len(list) == 0 {
+		delete(a.users, user)
+	} else {
+		a.users[user] = list
+	}
+	if err := a.save(); err != nil {
+		if existed {
+			a.users[user] = previous
+		} else {
+			delete(a.users, user)
+		}
+		return err
+	}
+	return nil
+}
+
+// wake tells a running RunAutomations to pick up a change. The caller
+// holds a.mu.
+func (a *Automations) wake() {
+	if a.changed == nil {
+		return
+	}
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+}
+
+// record notes that automation id acted on n messages, or failed.
+func (a *Automations) record(id string, n int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.statuses == nil {
+		a.statuses = make(map[string]*automationStatus)
+	}
+	st := a.statuses[id]
+	if st == nil {
+		st = &automationStatus{}
+		a.statuses[id] = st
+	}
+	st.last = time.Now()
+	st.lastError = ""
+	if err != nil {
+		st.lastError = err.Error()
+	} else {
+		st.runs += n
+	}
+}
+
+// runningFor reports whether RunAutomations runs user's automations in
+// this process.
+func (a *Automations) runningFor(user string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.running != "" && a.running == user
+}
+
+// status returns a copy of automation id's status, or nil when it has not
+// run in this process.
+func (a *Automations) status(id string) *automationStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if st, ok := a.statuses[id]; ok {
+		c := *st
+		return &c
+	}
+	return nil
+}
+
+// save writes the automations to their document. The caller holds a.mu.
+func (a *Automations) save() error {
+	if a.store == nil {
+		return nil
+	}
+	modTime, err := store.WriteJSON(a.store, a.doc, a.users)
+	if err != nil {
+		return fmt.Errorf("saving automations: %w", err)
+	}
+	a.modTime = modTime
+	return nil
+}
+
+// checkAutomation validates an automation's action and target.
+func checkAutomation(a *automation) error {
+	if a.MailboxID == "" {
+		return invalidArgument("mailbox_id is required")
+	}
+	if a.Target == "" && a.Action != automationClassify {
+		return invalidArgument("target is required")
+	}
+	switch a.Action {
+	case automationFile, automationFlag:
+	case automationForward:
+		if _, err := normalizeSender(a.Target); err != nil || strings.HasPrefix(a.Target, "*@") {
+			return invalidArgument("forward target must be an email address, got %q", a.Target)
+		}
+	case automationWebhook:
+		if !strings.HasPrefix(a.Target, "https://") && !strings.HasPrefix(a.Target, "http://") {
+			return invalidArgument("webhook target must be an http or https URL, got %q", a.Target)
+		}
+	case automationScript:
+		if !scriptNameRe.MatchString(a.Target) {
+			return invalidArgument("script target must be a script name, got %q", a.Target)
+		}
+	case automationClassify:
+		if a.Target != "" {
+			return invalidArgument("classify takes no target, got %q", a.Target)
+		}
+	default:
+		return invalidArgument("action must be file, flag, forward, webhook, script, or classify, got %q", a.Action)
+	}
+	return nil
+}
+
+// RunAutomations runs the automations of the configured token's user on
+// the default endpoint until ctx is done, following changes made with the
+// automation tools and, every minute, by other processes sharing the file.
+// It runs the user's schedules alongside.
+func (s *Server) RunAutomations(ctx context.Context) error {
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return err
+	}
+	ep, err := s.endpointFor(ctx)
+	if err != nil {
+		return err
+	}
+	c, err := s.dialer.Dial(ctx, ep.sessionURL, token)
+	if err != nil {
+		return err
+	}
+	client := s.wrapClient(ctx, c, token)
+	user := client.Session().Username
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return fmt.Errorf("no primary mail account")
+	}
+	if !s.hasPush(client.Session()) && s.watchPollInterval == 0 {
+		return fmt.Errorf("server does not support push (no eventSourceUrl or WebSocket push) and watch polling is disabled")
+	}
+
+	changed := make(chan struct{}, 1)
+	s.automations.mu.Lock()
+	s.automations.changed, s.automations.running = changed, user
+	s.automations.mu.Unlock()
+	owner := s.ownerOf(token)
+	defer func() {
+		s.watches.remove(func(w *watch) bool { return w.Owner == owner && w.automation != "" })
+		s.automations.mu.Lock()
+		s.automations.changed, s.automations.running = nil, ""
+		s.automations.mu.Unlock()
+	}()
+	log.Printf("Running automations of %s", user)
+	go s.runSchedules(ctx, user)
+
+	ticker := time.NewTicker(automationReloadInterval)
+	defer ticker.Stop()
+	for {
+		if t := s.currentToken(token); t != token {
+			if c, err := s.dialer.Dial(ctx, ep.sessionURL, t); err != nil {
+				log.Printf("Automations: dialing with the rotated token: %v", err)
+			} else {
+				token, client = t, s.wrapClient(ctx, c, t)
+			}
+		}
+		s.syncAutomations(ctx, client, ep.sessionURL, token, user, accountID)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-ticker.C:
+			if _, err := s.automations.reload(); err != nil {
+				log.Printf("Automations: %v", err)
+			}
+		}
+	}
+}
+
+// syncAutomations registers a watch for each of user's automations that
+// has none and removes the watches of deleted ones.
+func (s *Server) syncAutomations(ctx context.Context, client JMAPClient, sessionURL, token, user string, accountID jmap.ID) {
+	owner := s.ownerOf(token)
+	autos := s.automations.list(user)
+	s.watches.remove(func(w *watch) bool {
+		return w.Owner == owner && w.automation != "" &&
+			!slices.ContainsFunc(autos, func(a automation) bool { return a.ID == w.automation })
+	})
+	for _, a := range autos {
+		if s.watches.hasAutomation(owner, a.ID) {
+			continue
+		}
+		state, _, err := watchBaseline(ctx, client, accountID, a.MailboxID, "")
+		if err != nil {
+			s.automations.record(a.ID, 0, err)
+			log.Printf("Automation %s: %v", a.ID, err)
+			continue
+		}
+		w := &watch{
+			Owner:      owner,
+			AccountID:  accountID,
+			MailboxID:  a.MailboxID,
+			CreatedAt:  time.Now(),
+			automation: a.ID,
+			state:      state,
+		}
+		if _, start := s.watches.add(w); start {
+			s.startWatchListener(w.listenerKey(), sessionURL, token)
+		}
+	}
+}
+
+// runAutomation applies automation w.automation to the new messages in
+// events that match it.
+func (s *Server) runAutomation(ctx context.Context, client JMAPClient, w *watch, events []watchEvent) {
+	var ids []jmap.ID
+	for _, ev := range events {
+		if ev.Kind == watchNewMessages {
+			ids = append(ids, ev.EmailIDs...)
+		}
+	}
+	a := s.automations.get(client.Session().Username, w.automation)
+	if a == nil || len(ids) == 0 {
+		return
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{Account: w.AccountID, IDs: ids, Properties: []string{"id", "threadId", "from", "to", "cc", "subject", "receivedAt", "preview"}})
+	resp, err := client.Do(req)
+	if err == nil && len(resp.Responses) == 0 {
+		err = fmt.Errorf("empty response for Email/get")
+	}
+	var matched []*email.Email
+	if err == nil {
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.GetResponse:
+			for _, e := range args.List {
+				if a.matches(e) {
+					matched = append(matched, e)
+				}
+			}
+		case *jmap.MethodError:
+			err = args
+		default:
+			err = fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+	if err == nil && len(matched) > 0 {
+		err = s.applyAutomation(ctx, client, w.AccountID, a, matched)
+	}
+	if err == nil && len(matched) == 0 {
+		return
+	}
+	s.automations.record(a.ID, len(matched), err)
+	if err != nil {
+		log.Printf("Automation %s (%s %s): %v", a.ID, a.Action, a.Target, err)
+	}
+}
+
+// applyAutomation performs a's action on emails.
+func (s *Server) applyAutomation(ctx context.Context, client JMAPClient, accountID jmap.ID, a *automation, emails []*email.Email) error {
+	switch a.Action {
+	case automationFile, automationFlag:
+		patch := jmap.Patch{"keywords/" + a.Target: true}
+		if a.Action == automationFile {
+			patch = jmap.Patch{"mailboxIds": map[jmap.ID]bool{jmap.ID(a.Target): true}}
+		}
+		updates := make(map[jmap.ID]jmap.Patch, len(emails))
+		for _, e := range emails {
+			updates[e.ID] = patch
+		}
+		return updateEmails(ctx, client, accountID, updates, a.Action+" failed")
+
+	case automationForward:
+		if !s.enableEmailSubmission {
+			return fmt.Errorf("forwarding requires -enable-send")
+		}
+		for _, e := range emails {
+			fwd, err := s.createForward(ctx, client, EmailForwardInput{EmailID: string(e.ID), To: []string{a.Target}})
+			if err != nil {
+				return err
+			}
+			if fwd.id == "" {
+				return fmt.Errorf("the server did not report the forward draft of %s", e.ID)
+			}
+			if s.outbox != nil {
+				if res, _, _ := s.enqueueSubmission(ctx, client, accountID, fwd.id, "", false); res.IsError {
+					if te, ok := res.StructuredContent.(*toolError); ok {
+						return errors.New(te.Message)
+					}
+					return fmt.Errorf("queueing the forward of %s failed", e.ID)
+				}
+				continue
+			}
+			if err := s.submitDraft(ctx, client, accountID, fwd.id, "", false); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case automationWebhook:
+		return postAutomationWebhook(ctx, a, emails)
+
+	case automationScript:
+		sc := s.script(a.Target)
+		if sc == nil {
+			return fmt.Errorf("no script %q is configured", a.Target)
+		}
+		_, err := s.runScript(ctx, sc, automationPayload(a, emails))
+		return err
+
+	case automationClassify:
+		if s.classifier == nil || s.classifier.url == "" {
+			return fmt.Errorf("classify automations need a classification endpoint (-classify-url)")
+		}
+		categories, _, err := s.classify(ctx, nil, emails)
+		if err != nil || len(categories) == 0 {
+			return err
+		}
+		updates := make(map[jmap.ID]jmap.Patch, len(categories))
+		for id, cat := range categories {
+			updates[id] = s.classifier.patch(cat)
+		}
+		return updateEmails(ctx, client, accountID, updates, "classify failed")
+	}
+	return fmt.Errorf("unknown action %q", a.Action)
+}
+
+// updateEmails applies updates with Email/set, reporting the emails not
+// updated as what failed.
+func updateEmails(ctx context.Context, client JMAPClient, accountID jmap.ID, updates map[jmap.ID]jmap.Patch, what string) error {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Set{Account: accountID, Update: updates})
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses) == 0 {
+		return fmt.Errorf("empty response for Email/set")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		return setFailures(what, args.NotUpdated)
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// automationEmail summarizes a matching email for a webhook or script.
+type automationEmail struct {
+	ID         jmap.ID   `json:"id"`
+	ThreadID   jmap.ID   `json:"threadId"`
+	From       string    `json:"from"`
+	Subject    string    `json:"subject"`
+	ReceivedAt time.Time `json:"receivedAt"`
+}
+
+// automationMatches is what a webhook or script gets when a runs.
+type automationMatches struct {
+	Automation string            `json:"automation"`
+	Name       string            `json:"name,omitempty"`
+	Emails     []automationEmail `json:"emails"`
+}
+
+// automationPayload summarizes the emails a matched.
+func automationPayload(a *automation, emails []*email.Email) *automationMatches {
+	p := &automationMatches{Automation: a.ID, Name: a.Name}
+	for _, e := range emails {
+		p.Emails = append(p.Emails, automationEmail{ID: e.ID, ThreadID: e.ThreadID, From: formatAddresses(e.From), Subject: e.Subject, ReceivedAt: receivedAt(e)})
+	}
+	return p
+}
+
+// postAutomationWebhook POSTs the matching emails' summaries to a's URL.
+func postAutomationWebhook(ctx context.Context, a *automation, emails []*email.Email) error {
+	body, err := json.Marshal(automationPayload(a, emails))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, automationWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
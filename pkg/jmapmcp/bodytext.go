@@ -0,0 +1,276 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	erp "github.com/web-ridge/email-reply-parser"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultMaxBodyChars is the per-email body size cap applied when maxChars is 0.
+const DefaultMaxBodyChars = 4000
+
+const truncationMarker = "\n\n[... body truncated ...]"
+
+const headTailMarker = "\n\n[... middle of body omitted ...]\n\n"
+
+// Truncation strategies accepted by email_get's truncate_strategy.
+const (
+	// TruncateHead fills the response budget email by email and cuts each
+	// body's tail (default).
+	TruncateHead = "head"
+	// TruncateProportional splits the response budget evenly across all
+	// requested emails so later ones are not crowded out by earlier ones.
+	TruncateProportional = "proportional"
+	// TruncateHeadTail keeps the beginning and the end of each body, cutting
+	// the middle, so closing action items and sign-offs survive.
+	TruncateHeadTail = "head_tail"
+)
+
+// validTruncateStrategy reports whether s names a known strategy; empty
+// selects the default.
+func validTruncateStrategy(s string) bool {
+	switch s {
+	case "", TruncateHead, TruncateProportional, TruncateHeadTail:
+		return true
+	}
+	return false
+}
+
+// StripBlockquotes parses HTML and removes all <blockquote> elements and their
+// children, returning the remaining HTML. This structurally removes quoted
+// replies before they get flattened into plain text by html2text.
+func StripBlockquotes(rawHTML string) string {
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		return rawHTML
+	}
+	removeBlockquotes(doc)
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return rawHTML
+	}
+	return buf.String()
+}
+
+// removeBlockquotes walks the node tree and removes blockquote elements in place.
+func removeBlockquotes(n *html.Node) {
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type == html.ElementNode && c.DataAtom == atom.Blockquote {
+			n.RemoveChild(c)
+			continue
+		}
+		removeBlockquotes(c)
+	}
+}
+
+// PrepareBody strips text-level quoted replies and signatures, then truncates
+// to maxChars. Pass 0 for maxChars to use DefaultMaxBodyChars.
+func PrepareBody(text string, maxChars int) string {
+	return prepareBody(text, maxChars, TruncateHead, quotesStrip)
+}
+
+// prepareBody is PrepareBody with an explicit truncation strategy and
+// quoted text handling (quotesStrip, quotesFold, or quotesInclude).
+func prepareBody(text string, maxChars int, strategy, quotes string) string {
+	if maxChars <= 0 {
+		maxChars = DefaultMaxBodyChars
+	}
+	switch quotes {
+	case quotesInclude:
+	case quotesFold:
+		text = erp.Parse(FoldQuotes(parserWindow(text, maxChars, strategy)))
+	default:
+		text = erp.Parse(parserWindow(text, maxChars, strategy))
+	}
+	return truncateWith(text, maxChars, strategy)
+}
+
+// parserWindowFactor bounds the text the reply parser reads to this many
+// times the body cap. The parser takes milliseconds per line, and text past
+// the window would be cut by truncation anyway.
+const parserWindowFactor = 4
+
+// parserWindow returns the beginning of text the reply parser needs to
+// produce a body of maxChars, cut at a line end. head_tail keeps the end
+// of the body, so its text is returned whole.
+func parserWindow(text string, maxChars int, strategy string) string {
+	limit := parserWindowFactor * maxChars
+	if strategy == TruncateHeadTail || len(text) <= limit {
+		return text
+	}
+	if cut := strings.LastIndex(text[:limit], "\n"); cut > 0 {
+		return text[:cut]
+	}
+	return text[:clusterStart(text, limit)]
+}
+
+// truncateWith cuts text to limit using the given strategy's cut shape.
+// Only head_tail changes the shape; the other strategies cut the tail.
+func truncateWith(text string, limit int, strategy string) string {
+	if strategy == TruncateHeadTail {
+		return TruncateBodyHeadTail(text, limit)
+	}
+	return TruncateBody(text, limit)
+}
+
+// TruncateBody cuts text to fit within limit bytes. When truncation is
+// needed, it cuts at the last newline before the limit (reserving space for
+// the marker), or in text without one, such as CJK paragraphs, after the
+// last sentence, and appends a truncation advisory. Cuts fall between
+// grapheme clusters, so multi-byte characters, combining marks (Arabic and
+// Hebrew vowel signs included), and emoji sequences stay whole; the marker
+// starts a new paragraph, ending any right-to-left run before it.
+func TruncateBody(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	budget := limit - len(truncationMarker)
+	if budget <= 0 {
+		return truncationMarker
+	}
+	cut := strings.LastIndex(text[:budget], "\n")
+	if cut <= 0 {
+		cut = sentenceCut(text, budget)
+	}
+	return text[:cut] + truncationMarker
+}
+
+// TruncateBodyHeadTail cuts text to fit within limit bytes, keeping about
+// two thirds of the budget from the start and one third from the end. Cuts
+// snap to line boundaries where possible, otherwise to sentence or grapheme
+// cluster boundaries as in TruncateBody, and the omitted middle is marked.
+func TruncateBodyHeadTail(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	budget := limit - len(headTailMarker)
+	if budget <= 0 {
+		return TruncateBody(text, limit)
+	}
+	headBudget := budget * 2 / 3
+	tailBudget := budget - headBudget
+
+	head := sentenceCut(text, headBudget)
+	if cut := strings.LastIndex(text[:headBudget], "\n"); cut > 0 {
+		head = cut
+	}
+	tail := len(text) - tailBudget
+	if cut := strings.Index(text[tail:], "\n"); cut >= 0 && cut < tailBudget-1 {
+		tail += cut + 1
+	} else {
+		tail = nextCluster(text, tail)
+	}
+	return text[:head] + headTailMarker + text[tail:]
+}
+
+// sentenceTerminators end sentences without a following space: CJK full
+// stops and marks, and the Arabic and Urdu ones.
+const sentenceTerminators = "。！？．｡؟۔"
+
+// sentenceCut returns where to cut text to keep at most budget bytes: after
+// the last sentence that ends in the second half of the budget, or else at
+// the last grapheme cluster boundary.
+func sentenceCut(text string, budget int) int {
+	head := text[:clusterStart(text, budget)]
+	best := -1
+	for i, r := range head {
+		end := i + utf8.RuneLen(r)
+		switch {
+		case strings.ContainsRune(sentenceTerminators, r):
+		case (r == '.' || r == '!' || r == '?') && end < len(head) && head[end] == ' ':
+		default:
+			continue
+		}
+		// Keep closing quotes and brackets with their sentence.
+		for end < len(head) {
+			q, n := utf8.DecodeRuneInString(head[end:])
+			if !strings.ContainsRune("」』）】〕\"'”’)", q) {
+				break
+			}
+			end += n
+		}
+		best = end
+	}
+	if best >= budget/2 {
+		return best
+	}
+	return len(head)
+}
+
+// clusterStart moves i back to the start of the grapheme cluster it falls
+// in, so text[:i] splits no character or cluster.
+func clusterStart(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+	for i > 0 {
+		if !utf8.RuneStart(text[i]) {
+			i--
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(text[i:])
+		prev, n := utf8.DecodeLastRuneInString(text[:i])
+		if !extendsCluster(text[:i-n], prev, r) {
+			break
+		}
+		i -= n
+	}
+	return i
+}
+
+// nextCluster moves i forward to the start of the next grapheme cluster,
+// unless it already is one.
+func nextCluster(text string, i int) int {
+	for i < len(text) && clusterStart(text, i) != i {
+		_, n := utf8.DecodeRuneInString(text[i:])
+		if !utf8.RuneStart(text[i]) {
+			n = 1
+		}
+		i += n
+	}
+	return i
+}
+
+// extendsCluster approximates the grapheme cluster rules of UAX #29 for
+// the boundary between prev and r, before being the text ahead of prev:
+// CR LF, combining marks, joiners, variation selectors and emoji
+// modifiers, Hangul jamo, and regional indicator pairs stay together.
+func extendsCluster(before string, prev, r rune) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r == '\u200d' || prev == '\u200d' || r == '\u200c':
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff, r >= 0xe0020 && r <= 0xe007f:
+		// Emoji modifiers and tag sequences.
+		return true
+	case r >= 0x1160 && r <= 0x11ff && (prev >= 0x1100 && prev <= 0x11ff || prev >= 0xac00 && prev <= 0xd7a3):
+		return true
+	case isRegionalIndicator(r) && isRegionalIndicator(prev):
+		// Flags pair up from the start of a run.
+		n := 1
+		for len(before) > 0 {
+			q, size := utf8.DecodeLastRuneInString(before)
+			if !isRegionalIndicator(q) {
+				break
+			}
+			n++
+			before = before[:len(before)-size]
+		}
+		return n%2 == 1
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
@@ -0,0 +1,370 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStripBlockquotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantIn   string // substring that must be present
+		wantOut  string // substring that must be absent
+	}{
+		{
+			name:    "removes blockquote content",
+			input:   `<html><body><p>Hello</p><blockquote><p>Quoted reply</p></blockquote><p>Goodbye</p></body></html>`,
+			wantIn:  "Hello",
+			wantOut: "Quoted reply",
+		},
+		{
+			name:    "removes nested blockquotes",
+			input:   `<html><body><p>Original</p><blockquote><p>Level 1</p><blockquote><p>Level 2</p></blockquote></blockquote></body></html>`,
+			wantIn:  "Original",
+			wantOut: "Level 2",
+		},
+		{
+			name:    "preserves html without blockquotes",
+			input:   `<html><body><p>Just a paragraph</p><div>And a div</div></body></html>`,
+			wantIn:  "Just a paragraph",
+			wantOut: "",
+		},
+		{
+			name:    "handles empty input",
+			input:   "",
+			wantIn:  "",
+			wantOut: "",
+		},
+		{
+			name:    "handles plain text (no HTML tags)",
+			input:   "Just plain text, no tags here.",
+			wantIn:  "Just plain text",
+			wantOut: "",
+		},
+		{
+			name:    "preserves content around blockquote",
+			input:   `<div>Before</div><blockquote>Quoted</blockquote><div>After</div>`,
+			wantIn:  "After",
+			wantOut: "Quoted",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StripBlockquotes(tt.input)
+			if tt.wantIn != "" && !strings.Contains(got, tt.wantIn) {
+				t.Errorf("expected output to contain %q, got:\n%s", tt.wantIn, got)
+			}
+			if tt.wantOut != "" && strings.Contains(got, tt.wantOut) {
+				t.Errorf("expected output NOT to contain %q, got:\n%s", tt.wantOut, got)
+			}
+		})
+	}
+}
+
+func TestTruncateBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		limit int
+		check func(t *testing.T, got string)
+	}{
+		{
+			name:  "short text unchanged",
+			input: "Hello world",
+			limit: 100,
+			check: func(t *testing.T, got string) {
+				if got != "Hello world" {
+					t.Errorf("expected no truncation, got: %q", got)
+				}
+			},
+		},
+		{
+			name:  "exact limit unchanged",
+			input: "12345",
+			limit: 5,
+			check: func(t *testing.T, got string) {
+				if got != "12345" {
+					t.Errorf("expected no truncation, got: %q", got)
+				}
+			},
+		},
+		{
+			name:  "truncates at newline boundary",
+			input: "Line one\nLine two\nLine three\nLine four\nLine five\nLine six",
+			limit: 50,
+			check: func(t *testing.T, got string) {
+				if !strings.Contains(got, "[... body truncated ...]") {
+					t.Error("expected truncation marker")
+				}
+				if strings.Contains(got, "Line four") {
+					t.Error("expected Line four to be truncated")
+				}
+				if !strings.Contains(got, "Line one") {
+					t.Error("expected Line one to be preserved")
+				}
+			},
+		},
+		{
+			name:  "very small limit degrades gracefully",
+			input: "This is a longer text that cannot fit",
+			limit: 5,
+			check: func(t *testing.T, got string) {
+				// With limit smaller than marker, just return marker
+				if got != truncationMarker {
+					t.Errorf("expected just marker for tiny limit, got: %q", got)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateBody(tt.input, tt.limit)
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestPrepareBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxChars int
+		check    func(t *testing.T, got string)
+	}{
+		{
+			name: "strips text-level quotes",
+			input: `Hey, this is the original message.
+
+On Mon, Jan 1, 2024 at 10:00 AM Someone <someone@example.com> wrote:
+> This is the quoted reply.
+> It should be removed.`,
+			maxChars: 0,
+			check: func(t *testing.T, got string) {
+				if !strings.Contains(got, "original message") {
+					t.Error("expected original message preserved")
+				}
+				if strings.Contains(got, "quoted reply") {
+					t.Error("expected quoted reply to be stripped")
+				}
+			},
+		},
+		{
+			name: "preserves forwarded messages",
+			input: `Check out this forwarded message:
+
+---------- Forwarded message ---------
+From: Someone <someone@example.com>
+Subject: Important thing
+
+The forwarded content here.`,
+			maxChars: 0,
+			check: func(t *testing.T, got string) {
+				if !strings.Contains(got, "forwarded") {
+					t.Error("expected forwarded content to be preserved")
+				}
+			},
+		},
+		{
+			name:     "truncates after stripping",
+			input:    strings.Repeat("word ", 2000),
+			maxChars: 100,
+			check: func(t *testing.T, got string) {
+				if len(got) > 100 {
+					t.Errorf("expected body <= 100 chars, got %d", len(got))
+				}
+				if !strings.Contains(got, "[... body truncated ...]") {
+					t.Error("expected truncation marker")
+				}
+			},
+		},
+		{
+			name:     "uses default limit when 0 passed",
+			input:    strings.Repeat("x", DefaultMaxBodyChars+100),
+			maxChars: 0,
+			check: func(t *testing.T, got string) {
+				if len(got) > DefaultMaxBodyChars {
+					t.Errorf("expected body <= %d chars, got %d", DefaultMaxBodyChars, len(got))
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PrepareBody(tt.input, tt.maxChars)
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestTruncateBodyHeadTail(t *testing.T) {
+	t.Run("short text unchanged", func(t *testing.T) {
+		if got := TruncateBodyHeadTail("Hello world", 100); got != "Hello world" {
+			t.Errorf("expected no truncation, got: %q", got)
+		}
+	})
+
+	t.Run("keeps head and tail", func(t *testing.T) {
+		var lines []string
+		for i := 0; i < 40; i++ {
+			lines = append(lines, fmt.Sprintf("Line %02d of the message", i))
+		}
+		input := "Hi Bob,\n" + strings.Join(lines, "\n") + "\nPlease sign by Friday.\nThanks"
+		got := TruncateBodyHeadTail(input, 200)
+		if len(got) > 200 {
+			t.Errorf("expected result <= 200 chars, got %d", len(got))
+		}
+		if !strings.HasPrefix(got, "Hi Bob,") {
+			t.Error("expected greeting preserved")
+		}
+		if !strings.HasSuffix(got, "Please sign by Friday.\nThanks") {
+			t.Errorf("expected closing preserved, got:\n%s", got)
+		}
+		if !strings.Contains(got, "middle of body omitted") {
+			t.Error("expected middle marker")
+		}
+		if strings.Contains(got, "Line 20") {
+			t.Error("expected middle lines to be cut")
+		}
+	})
+
+	t.Run("tiny limit falls back to head cut", func(t *testing.T) {
+		if got := TruncateBodyHeadTail(strings.Repeat("x", 100), 5); got != truncationMarker {
+			t.Errorf("expected just marker for tiny limit, got: %q", got)
+		}
+	})
+}
+
+func TestTruncateBodyScripts(t *testing.T) {
+	marker := len(truncationMarker)
+
+	t.Run("CJK cuts after a sentence", func(t *testing.T) {
+		input := strings.Repeat("今日は会議があります。", 3) + strings.Repeat("資料", 40)
+		got := TruncateBody(input, marker+len(strings.Repeat("今日は会議があります。", 3))+10)
+		if want := strings.Repeat("今日は会議があります。", 3) + truncationMarker; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("closing bracket stays with its sentence", func(t *testing.T) {
+		sentence := "彼は「了解です。」"
+		got := TruncateBody(sentence+strings.Repeat("あ", 50), marker+len(sentence)+5)
+		if want := sentence + truncationMarker; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("combining marks stay with their letters", func(t *testing.T) {
+		for _, input := range []string{
+			strings.Repeat("e\u0301", 30),            // e + combining acute
+			strings.Repeat("\u0628\u064e\u0651", 30), // Arabic beh + fatha + shadda
+			strings.Repeat("\u05e9\u05c1\u05b8", 30), // Hebrew shin + shin dot + qamats
+		} {
+			unit := input[:len(input)/30]
+			for limit := marker + 1; limit < len(input); limit++ {
+				body := strings.TrimSuffix(TruncateBody(input, limit), truncationMarker)
+				if len(body)%len(unit) != 0 {
+					t.Fatalf("limit %d split a cluster of %q: %q", limit, unit, body)
+				}
+			}
+		}
+	})
+
+	t.Run("emoji sequences stay whole", func(t *testing.T) {
+		family := "\U0001F468\u200D\U0001F469\u200D\U0001F467"
+		for _, unit := range []string{family, "\U0001F44D\U0001F3FD", "\U0001F1EF\U0001F1F5"} {
+			input := strings.Repeat(unit, 10)
+			for limit := marker + 1; limit < len(input); limit++ {
+				body := strings.TrimSuffix(TruncateBody(input, limit), truncationMarker)
+				if len(body)%len(unit) != 0 {
+					t.Fatalf("limit %d split %q: %q", limit, unit, body)
+				}
+			}
+		}
+	})
+
+	t.Run("output is valid UTF-8", func(t *testing.T) {
+		input := strings.Repeat("مرحبا بالعالم، كيف حالك؟ 你好世界 🙂 ", 20)
+		for limit := 0; limit < len(input); limit += 7 {
+			for name, got := range map[string]string{
+				"TruncateBody":         TruncateBody(input, limit),
+				"TruncateBodyHeadTail": TruncateBodyHeadTail(input, limit),
+			} {
+				if !utf8.ValidString(got) {
+					t.Fatalf("%s(%d) = %q, not valid UTF-8", name, limit, got)
+				}
+				if len(got) > max(limit, len(truncationMarker)) {
+					t.Fatalf("%s(%d) is %d bytes", name, limit, len(got))
+				}
+			}
+		}
+	})
+}
+
+// largeHTML builds an HTML email of about n bytes, like a long newsletter
+// answered a few times: paragraphs with links and images, a table, and a
+// chain of nested quoted replies.
+func largeHTML(n int) string {
+	var sb strings.Builder
+	sb.WriteString("<html><head><style>p { margin: 0 }</style></head><body>")
+	for i := 0; sb.Len() < n/2; i++ {
+		fmt.Fprintf(&sb, `<p>Paragraph %d with <a href="https://example.org/article/%d?utm_source=news">a link</a> and <b>bold</b> text.<img src="https://track.example.org/p/%d.gif" width="1" height="1"></p>`, i, i, i)
+		if i%20 == 0 {
+			fmt.Fprintf(&sb, "<table><tr><td>Item %d</td><td>%d.00</td></tr></table>", i, i)
+		}
+	}
+	depth := 0
+	for sb.Len() < n {
+		sb.WriteString("<blockquote><p>On Monday someone wrote:</p><p>" + strings.Repeat("Quoted text of an earlier message. ", 20) + "</p>")
+		depth++
+	}
+	sb.WriteString(strings.Repeat("</blockquote>", depth))
+	sb.WriteString("</body></html>")
+	return sb.String()
+}
+
+func BenchmarkStripBlockquotes(b *testing.B) {
+	for _, size := range []int{10 << 10, 1 << 20} {
+		doc := largeHTML(size)
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(doc)))
+			b.ReportAllocs()
+			for b.Loop() {
+				StripBlockquotes(doc)
+			}
+		})
+	}
+}
+
+func BenchmarkPrepareBody(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 1<<20; i++ {
+		fmt.Fprintf(&sb, "Line %d of a long plain text message, wrapped at a sensible width.\n", i)
+		if i%50 == 49 {
+			sb.WriteString("\n> quoted line of an earlier message\n> and another\n\n")
+		}
+	}
+	sb.WriteString("\n-- \nAlice\nExample Corp\n")
+	text := sb.String()
+	b.SetBytes(int64(len(text)))
+	b.ReportAllocs()
+	for b.Loop() {
+		PrepareBody(text, 0)
+	}
+}
+
+func TestParserWindow(t *testing.T) {
+	text := strings.Repeat("line of text\n", 2000)
+	got := parserWindow(text, 100, TruncateHead)
+	if len(got) > parserWindowFactor*100 || !strings.HasSuffix(got, "line of text") {
+		t.Errorf("head window: %d bytes ending %q", len(got), got[max(len(got)-20, 0):])
+	}
+	if got := parserWindow(text, 100, TruncateHeadTail); got != text {
+		t.Error("head_tail text cut")
+	}
+	if got := parserWindow("short", 100, TruncateHead); got != "short" {
+		t.Errorf("short text = %q", got)
+	}
+}
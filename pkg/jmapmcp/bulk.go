@@ -0,0 +1,388 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Bulk mode: email_move, email_flag, and email_delete (and with them
+// marking many emails read and the scheduler's cleanup) change emails in
+// chunks sized to the backend instead of one Email/set of everything.
+// bulkPlanFor reads the session's maxObjectsInSet, maxObjectsInGet,
+// maxCallsInRequest, and maxConcurrentRequests; chunks are packed into
+// requests of up to maxCallsInRequest calls, a few requests in flight at
+// once. rateLimitTransport notes every response's rate-limit headers
+// (Retry-After, RateLimit-Remaining and -Reset, their X- forms, and the
+// combined RateLimit field) per user and backend: bulk requests wait out a
+// pause the server asked for, go one at a time while few requests remain,
+// and are retried after a rate-limit refusal. email_batch_iterator's
+// batches and email_estimate's counts use the same limits.
+
+const (
+	defaultMaxObjectsInSet       = 100 // when the server does not advertise maxObjectsInSet
+	defaultMaxObjectsInGet       = 100 // when the server does not advertise maxObjectsInGet
+	defaultMaxConcurrentRequests = 4   // RFC 8620's suggested minimum
+	// maxBulkConcurrency bounds the requests one bulk change has in flight;
+	// it takes at most half the server's maxConcurrentRequests, leaving
+	// the rest to the user's other clients.
+	maxBulkConcurrency = 4
+	// bulkLowRemaining is the number of requests left in the server's
+	// rate-limit window below which bulk requests go one at a time.
+	bulkLowRemaining = 10
+	// bulkRetries is how often a request refused for the rate limit is
+	// retried, after the server's Retry-After or bulkBackoff doubling.
+	bulkRetries = 3
+	bulkBackoff = time.Second
+	// maxThrottleWait is the longest pause a bulk change waits out; a
+	// longer one fails the call as rate limited.
+	maxThrottleWait = time.Minute
+	// throttleFresh is how long a reported remaining count is trusted.
+	throttleFresh = time.Minute
+)
+
+// bulkPlan is how a bulk change is split for one backend.
+type bulkPlan struct {
+	setSize     int // emails per Email/set
+	getSize     int // emails per Email/get
+	calls       int // method calls per request
+	concurrency int // requests in flight at once
+}
+
+// bulkPlanFor returns the plan for client's backend, one request at a time
+// while the caller's rate-limit window is nearly spent.
+func (s *Server) bulkPlanFor(ctx context.Context, client JMAPClient) bulkPlan {
+	p := bulkPlan{
+		setSize:     defaultMaxObjectsInSet,
+		getSize:     defaultMaxObjectsInGet,
+		calls:       defaultMaxCallsInRequest,
+		concurrency: defaultMaxConcurrentRequests,
+	}
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok {
+		if c.MaxObjectsInSet > 0 {
+			p.setSize = int(c.MaxObjectsInSet)
+		}
+		if c.MaxObjectsInGet > 0 {
+			p.getSize = int(c.MaxObjectsInGet)
+		}
+		if c.MaxCallsInRequest > 0 {
+			p.calls = int(c.MaxCallsInRequest)
+		}
+		if c.MaxConcurrentRequests > 0 {
+			p.concurrency = int(c.MaxConcurrentRequests)
+		}
+	}
+	p.concurrency = min(max(p.concurrency/2, 1), maxBulkConcurrency)
+	if s.throttleFor(ctx, client).low(time.Now()) {
+		p.concurrency = 1
+	}
+	return p
+}
+
+// bulkSet changes the emails ids in chunks per the plan: patch gives each
+// email's update, or nil destroys them. With getProperties, each chunk's
+// Email/set is preceded by an Email/get of those properties, as a record
+// of the emails before the change. The set responses are merged; a chunk
+// whose request or call failed lists its emails as not updated (or not
+// destroyed) with the error, unless no chunk succeeded, when the first
+// error is returned.
+func (s *Server) bulkSet(ctx context.Context, client JMAPClient, accountID jmap.ID, ids []jmap.ID, patch func(jmap.ID) jmap.Patch, getProperties []string) (*email.SetResponse, []*email.Email, error) {
+	plan := s.bulkPlanFor(ctx, client)
+	size, callsPerChunk := plan.setSize, 1
+	if getProperties != nil {
+		size, callsPerChunk = min(size, plan.getSize), 2
+	}
+	chunks := slices.Collect(slices.Chunk(ids, max(size, 1)))
+	requests := slices.Collect(slices.Chunk(chunks, max(plan.calls/callsPerChunk, 1)))
+
+	out := &email.SetResponse{
+		Account:      accountID,
+		Updated:      make(map[jmap.ID]*email.Email),
+		NotUpdated:   make(map[jmap.ID]*jmap.SetError),
+		NotDestroyed: make(map[jmap.ID]*jmap.SetError),
+	}
+	var (
+		mu        sync.Mutex
+		list      []*email.Email
+		succeeded int
+		firstErr  error
+		wg        sync.WaitGroup
+	)
+	// fail records that chunk was not changed because of err.
+	fail := func(chunk []jmap.ID, err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+		se := bulkSetError(err)
+		for _, id := range chunk {
+			if patch == nil {
+				out.NotDestroyed[id] = se
+			} else {
+				out.NotUpdated[id] = se
+			}
+		}
+	}
+	sem := make(chan struct{}, plan.concurrency)
+	for _, group := range requests {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			req := &jmap.Request{Context: ctx}
+			for _, chunk := range group {
+				if getProperties != nil {
+					req.Invoke(&email.Get{Account: accountID, IDs: chunk, Properties: getProperties})
+				}
+				set := &email.Set{Account: accountID}
+				if patch == nil {
+					set.Destroy = chunk
+				} else {
+					set.Update = make(map[jmap.ID]jmap.Patch, len(chunk))
+					for _, id := range chunk {
+						set.Update[id] = patch(id)
+					}
+				}
+				req.Invoke(set)
+			}
+			resp, err := s.bulkDo(ctx, client, req)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for i, chunk := range group {
+				if err != nil {
+					fail(chunk, err)
+					continue
+				}
+				at := i * callsPerChunk
+				if at+callsPerChunk > len(resp.Responses) {
+					fail(chunk, errors.New("incomplete response for Email/set"))
+					continue
+				}
+				if getProperties != nil {
+					if args, ok := resp.Responses[at].Args.(*email.GetResponse); ok {
+						list = append(list, args.List...)
+					}
+				}
+				switch args := resp.Responses[at+callsPerChunk-1].Args.(type) {
+				case *email.SetResponse:
+					succeeded++
+					out.NewState = args.NewState
+					for id, e := range args.Updated {
+						out.Updated[id] = e
+					}
+					out.Destroyed = append(out.Destroyed, args.Destroyed...)
+					for id, se := range args.NotUpdated {
+						out.NotUpdated[id] = se
+					}
+					for id, se := range args.NotDestroyed {
+						out.NotDestroyed[id] = se
+					}
+				case *jmap.MethodError:
+					fail(chunk, args)
+				default:
+					fail(chunk, errors.New("unexpected response type for Email/set"))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded == 0 && firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return out, list, nil
+}
+
+// bulkSetError describes err as the set error of each email it kept from
+// being changed.
+func bulkSetError(err error) *jmap.SetError {
+	desc := err.Error()
+	se := &jmap.SetError{Type: "serverFail", Description: &desc}
+	var me *jmap.MethodError
+	switch {
+	case errors.As(err, &me):
+		se.Type = me.Type
+	case classifyError(err).Code == codeRateLimited:
+		se.Type = "rateLimit"
+	}
+	return se
+}
+
+// bulkDo sends req after any pause the backend asked for, retrying up to
+// bulkRetries times when the backend refuses it for its rate limit.
+func (s *Server) bulkDo(ctx context.Context, client JMAPClient, req *jmap.Request) (*jmap.Response, error) {
+	t := s.throttleFor(ctx, client)
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+		sent := time.Now()
+		resp, err := client.Do(req)
+		if err == nil || attempt == bulkRetries || classifyError(err).Code != codeRateLimited {
+			return resp, err
+		}
+		t.backoff(sent, bulkBackoff<<attempt)
+	}
+}
+
+// throttle is what a backend's rate-limit headers said about one user's
+// requests.
+type throttle struct {
+	mu        sync.Mutex
+	until     time.Time // no requests before this
+	paused    time.Time // when until was last set
+	remaining int       // requests left in the window, as last reported
+	reported  time.Time // when remaining was reported; zero: never
+}
+
+// throttleFor returns the throttle of the caller's requests to client's
+// backend.
+func (s *Server) throttleFor(ctx context.Context, client JMAPClient) *throttle {
+	token, _ := s.resolveToken(ctx)
+	return s.throttleOf(s.ownerOf(token), client.Session().APIURL)
+}
+
+// throttleOf returns the throttle of owner's requests to apiURL.
+func (s *Server) throttleOf(owner, apiURL string) *throttle {
+	t, _ := s.throttles.LoadOrStore(owner+" "+apiURL, &throttle{})
+	return t.(*throttle)
+}
+
+// note records the rate-limit headers of a response received at now.
+func (t *throttle) note(h http.Header, now time.Time) {
+	pause, remaining := rateLimitAdvice(h, now)
+	if pause <= 0 && remaining < 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if pause > 0 {
+		t.until, t.paused = now.Add(pause), now
+	}
+	if remaining >= 0 {
+		t.remaining, t.reported = remaining, now
+	}
+}
+
+// wait returns once no pause is in effect, or fails when the pause is
+// longer than maxThrottleWait or ctx ends first.
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	d := time.Until(t.until)
+	t.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	if d > maxThrottleWait {
+		return &toolError{Code: codeRateLimited, Retryable: true,
+			Message: "the mail server asks to wait " + d.Round(time.Second).String() + " before more requests; retry later"}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff pauses for d after a refused request sent at sent, unless the
+// refusal itself said how long to wait.
+func (t *throttle) backoff(sent time.Time, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.paused.Before(sent) {
+		t.until, t.paused = time.Now().Add(d), time.Now()
+	}
+}
+
+// low reports whether few requests are left in the rate-limit window.
+func (t *throttle) low(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.reported.IsZero() && now.Sub(t.reported) < throttleFresh && t.remaining < bulkLowRemaining
+}
+
+// rateLimitAdvice reads the rate-limit headers of a response received at
+// now: the pause asked for (Retry-After, or the window's reset once no
+// requests remain; 0 for none) and the requests remaining (-1 when not
+// reported).
+func rateLimitAdvice(h http.Header, now time.Time) (time.Duration, int) {
+	remaining, reset := -1, time.Duration(0)
+	for _, name := range []string{"RateLimit-Remaining", "X-RateLimit-Remaining"} {
+		if n, err := strconv.Atoi(strings.TrimSpace(h.Get(name))); err == nil && n >= 0 {
+			remaining = n
+			break
+		}
+	}
+	for _, name := range []string{"RateLimit-Reset", "X-RateLimit-Reset"} {
+		if d, ok := resetDelay(h.Get(name), now); ok {
+			reset = d
+			break
+		}
+	}
+	// The combined field of the IETF draft: limit=100, remaining=0, reset=30.
+	for _, param := range strings.FieldsFunc(h.Get("RateLimit"), func(r rune) bool { return r == ',' || r == ';' }) {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch key {
+		case "remaining", "r":
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				remaining = n
+			}
+		case "reset", "t":
+			if d, ok := resetDelay(value, now); ok {
+				reset = d
+			}
+		}
+	}
+
+	var pause time.Duration
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			pause = time.Duration(secs) * time.Second
+		} else if at, err := http.ParseTime(v); err == nil {
+			pause = at.Sub(now)
+		}
+	}
+	if pause <= 0 && remaining == 0 {
+		pause = reset
+	}
+	return pause, remaining
+}
+
+// resetDelay parses a rate-limit reset, in seconds from now or, as some
+// servers send it, a Unix time.
+func resetDelay(v string, now time.Time) (time.Duration, bool) {
+	n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	switch {
+	case err != nil || n < 0:
+		return 0, false
+	case n > 1_000_000_000:
+		return time.Unix(n, 0).Sub(now), true
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// rateLimitTransport notes the rate-limit headers of every response in a
+// throttle.
+type rateLimitTransport struct {
+	base     http.RoundTripper
+	throttle *throttle
+}
+
+func (t rateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	if err == nil {
+		t.throttle.note(resp.Header, time.Now())
+	}
+	return resp, err
+}
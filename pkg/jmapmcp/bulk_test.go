@@ -0,0 +1,190 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+// limitedFake returns a fake server taking at most two emails per
+// Email/set and two calls per request.
+func limitedFake() *jmapfake.Server {
+	fake := jmapfake.New()
+	fake.AddCapability(jmapfake.CoreURI, map[string]any{
+		"maxSizeUpload":         50000000,
+		"maxConcurrentUpload":   4,
+		"maxSizeRequest":        10000000,
+		"maxConcurrentRequests": 4,
+		"maxCallsInRequest":     2,
+		"maxObjectsInGet":       500,
+		"maxObjectsInSet":       2,
+		"collationAlgorithms":   []string{"i;ascii-casemap"},
+	})
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "archive", "name": "Archive", "role": "archive"})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	for _, id := range []string{"e1", "e2", "e3", "e4", "e5"} {
+		addEmail(fake, id, "Hello", "", 1)
+	}
+	return fake
+}
+
+func countCalls(calls []string, method string) int {
+	n := 0
+	for _, c := range calls {
+		if c == method {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBulkChunks(t *testing.T) {
+	fake := limitedFake()
+	s := NewServer("test", jmapfake.SessionURL, WithToken("test"), WithDialer(fake))
+	ctx := context.Background()
+	ids := []string{"e1", "e2", "e3", "e4", "e5"}
+
+	before := len(fake.Calls())
+	res, _, _ := s.handleEmailMove(ctx, nil, EmailMoveInput{EmailIDs: ids, MailboxID: "archive"})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if n := countCalls(fake.Calls()[before:], "Email/set"); n != 3 {
+		t.Errorf("email_move made %d Email/set calls, want 3", n)
+	}
+	for _, id := range ids {
+		if mb := fake.Object("Email", id)["mailboxIds"].(map[string]any); !mb["archive"].(bool) || len(mb) != 1 {
+			t.Errorf("%s in %v", id, mb)
+		}
+	}
+
+	seen := true
+	before = len(fake.Calls())
+	if res, _, _ := s.handleEmailFlag(ctx, nil, EmailFlagInput{EmailIDs: ids, Seen: &seen}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	calls := fake.Calls()[before:]
+	if countCalls(calls, "Email/get") != 3 || countCalls(calls, "Email/set") != 3 {
+		t.Errorf("email_flag calls %v, want 3 Email/get and 3 Email/set", calls)
+	}
+	if kw := fake.Object("Email", "e5")["keywords"].(map[string]any); kw["$seen"] != true {
+		t.Errorf("e5 keywords %v", kw)
+	}
+	if res, _, _ := s.handleUndoLast(ctx, nil, UndoLastInput{}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if kw := fake.Object("Email", "e5")["keywords"].(map[string]any); kw["$seen"] == true {
+		t.Errorf("undo left e5 seen: %v", kw)
+	}
+
+	res, _, _ = s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: slices.Concat(ids, []string{"gone"})})
+	if !res.IsError || !slices.Equal(res.StructuredContent.(*toolError).IDs, []string{"gone"}) {
+		t.Errorf("deleting a missing email: %s", resultText(res))
+	}
+	if mb := fake.Object("Email", "e4")["mailboxIds"].(map[string]any); !mb["trash"].(bool) {
+		t.Errorf("e4 not trashed: %v", mb)
+	}
+}
+
+// throttlingDialer dials the fake through a transport that refuses the
+// first refuse Email/set requests with 429 and reports remaining requests.
+type throttlingDialer struct {
+	*jmapfake.Server
+	refuse    *atomic.Int32
+	remaining string
+}
+
+func (d throttlingDialer) Dial(ctx context.Context, sessionURL, token string) (*jmap.Client, error) {
+	c, err := d.Server.Dial(ctx, sessionURL, token)
+	if err != nil {
+		return nil, err
+	}
+	base := c.HttpClient.Transport
+	c.HttpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if bytes.Contains(body, []byte(`"Email/set"`)) && d.refuse.Add(-1) >= 0 {
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Retry-After", "1")
+			rec.WriteHeader(http.StatusTooManyRequests)
+			return rec.Result(), nil
+		}
+		resp, err := base.RoundTrip(r)
+		if err == nil && d.remaining != "" {
+			resp.Header.Set("X-RateLimit-Remaining", d.remaining)
+		}
+		return resp, err
+	})}
+	return c, nil
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestBulkRateLimit(t *testing.T) {
+	fake := limitedFake()
+	var refuse atomic.Int32
+	refuse.Store(1)
+	s := NewServer("test", jmapfake.SessionURL, WithToken("test"), WithDialer(throttlingDialer{fake, &refuse, "3"}))
+	ctx := context.Background()
+
+	start := time.Now()
+	res, _, _ := s.handleEmailMove(ctx, nil, EmailMoveInput{EmailIDs: []string{"e1", "e2", "e3"}, MailboxID: "archive"})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if time.Since(start) < time.Second {
+		t.Error("the refused request was retried without waiting for Retry-After")
+	}
+	if mb := fake.Object("Email", "e3")["mailboxIds"].(map[string]any); !mb["archive"].(bool) {
+		t.Errorf("e3 not moved: %v", mb)
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := s.bulkPlanFor(ctx, client); p.concurrency != 1 || p.setSize != 2 || p.calls != 2 {
+		t.Errorf("plan with 3 requests remaining = %+v", p)
+	}
+}
+
+func TestRateLimitAdvice(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		headers   map[string]string
+		pause     time.Duration
+		remaining int
+	}{
+		{nil, 0, -1},
+		{map[string]string{"Retry-After": "30"}, 30 * time.Second, -1},
+		{map[string]string{"Retry-After": "Wed, 01 Jan 2025 12:01:00 GMT"}, time.Minute, -1},
+		{map[string]string{"RateLimit-Remaining": "5", "RateLimit-Reset": "20"}, 0, 5},
+		{map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1735732810"}, 10 * time.Second, 0},
+		{map[string]string{"RateLimit": "limit=100, remaining=0, reset=7"}, 7 * time.Second, 0},
+	} {
+		h := http.Header{}
+		for k, v := range tc.headers {
+			h.Set(k, v)
+		}
+		pause, remaining := rateLimitAdvice(h, now)
+		if pause != tc.pause || remaining != tc.remaining {
+			t.Errorf("%v: pause %v, remaining %d; want %v, %d", tc.headers, pause, remaining, tc.pause, tc.remaining)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/calendars"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/maskedemail"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/principals"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/quota"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/tasks"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+	"github.com/mikluko/jmap/sieve"
+)
+
+// A tool that needs an optional JMAP capability the server does not
+// advertise fails with code capability_missing, and its structured content
+// says which capability, what the server does advertise, and what the
+// agent can use instead, so it can change course without a round of
+// server_info and guesswork.
+
+// capabilityFallback is how a missing capability is described.
+type capabilityFallback struct {
+	name         string   // shown in the message, e.g. JMAP calendars
+	alternatives []string // tools or steps that work without it
+}
+
+// capabilityFallbacks are the optional capabilities tools depend on, by URI.
+var capabilityFallbacks = map[jmap.URI]capabilityFallback{
+	emailsubmission.URI: {"email submission", []string{
+		"email_create to save a draft the user sends from their mail client",
+	}},
+	sieve.URI: {"Sieve", []string{
+		"automation_create for filing, flagging, and forwarding rules jmap-mcp runs itself",
+		"sender_mute without sieve to hide senders from email_query",
+	}},
+	calendars.URI: {"JMAP calendars", []string{
+		"calendar_rsvp to answer an invitation by email",
+		"note_create to keep the event as a note",
+	}},
+	tasks.URI: {"JMAP tasks", []string{
+		"email_flag with flagged to mark the email as a to-do",
+		"note_create to keep the task as a note",
+	}},
+	quota.URI: {"JMAP quotas", []string{
+		"mailbox_report for how much mail each mailbox holds",
+	}},
+	maskedemail.URI: {"masked email, a Fastmail extension", nil},
+	principals.URI:  {"mailbox sharing (JMAP Sharing)", nil},
+}
+
+// capabilityMissing reports that the server behind session does not
+// advertise uri.
+func capabilityMissing(session *jmap.Session, uri jmap.URI) error {
+	fb := capabilityFallbacks[uri]
+	name := fb.name
+	if name == "" {
+		name = string(uri)
+	}
+	msg := fmt.Sprintf("server does not support %s (%s)", name, uri)
+	if len(fb.alternatives) > 0 {
+		msg += "; instead use " + strings.Join(fb.alternatives, ", or ")
+	}
+	var advertised []string
+	for u := range session.RawCapabilities {
+		advertised = append(advertised, string(u))
+	}
+	slices.Sort(advertised)
+	return &toolError{
+		Code:         codeCapabilityMissing,
+		Message:      msg,
+		Capability:   string(uri),
+		Advertised:   advertised,
+		Alternatives: fb.alternatives,
+	}
+}
+
+// requiredCapabilityRe matches the error of a request that uses a
+// capability the session lacks.
+var requiredCapabilityRe = regexp.MustCompile(`doesn't support required capability '([^']+)'`)
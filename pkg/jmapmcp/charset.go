@@ -0,0 +1,351 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Legacy encodings: JMAP servers are to return headers and body values as
+// UTF-8, but some leave RFC 2047 encoded words in subjects and names, and
+// some cannot decode a body's declared charset (ISO-2022-JP is the usual
+// one) and return mojibake with isEncodingProblem. tolerantClient decodes
+// leftover encoded words in every Email/get response, and fetchBodies
+// re-decodes such bodies from their blobs with the decoders here: the
+// single-byte ISO-8859 and Windows code pages, KOI8, IBM866, and the
+// Japanese ISO-2022-JP, EUC-JP, and Shift_JIS.
+
+// charsetAliases maps other names of the supported charsets to the
+// canonical ones.
+var charsetAliases = map[string]string{
+	"latin1": "iso-8859-1", "l1": "iso-8859-1", "iso8859-1": "iso-8859-1", "iso_8859-1": "iso-8859-1",
+	"latin2": "iso-8859-2", "latin9": "iso-8859-15", "cyrillic": "iso-8859-5", "greek": "iso-8859-7",
+	"cp866": "ibm866", "866": "ibm866", "koi8": "koi8-r",
+	"sjis": "shift_jis", "shift-jis": "shift_jis", "x-sjis": "shift_jis", "ms_kanji": "shift_jis",
+	"cp932": "shift_jis", "windows-31j": "shift_jis", "csshiftjis": "shift_jis",
+	"eucjp": "euc-jp", "x-euc-jp": "euc-jp", "cseucpkdfmtjapanese": "euc-jp",
+	"csiso2022jp": "iso-2022-jp", "iso-2022-jp-1": "iso-2022-jp", "iso-2022-jp-2": "iso-2022-jp",
+	"us-ascii": "utf-8", "ascii": "utf-8", "utf8": "utf-8",
+}
+
+// canonicalCharset returns the canonical name of charset, lower case.
+func canonicalCharset(charset string) string {
+	c := strings.ToLower(strings.TrimSpace(charset))
+	if a, ok := charsetAliases[c]; ok {
+		return a
+	}
+	for _, p := range []string{"cp", "windows"} {
+		// cp1252, windows1252
+		if n, ok := strings.CutPrefix(c, p); ok && len(n) == 4 && strings.HasPrefix(n, "125") {
+			return "windows-" + n
+		}
+	}
+	if n, ok := strings.CutPrefix(c, "iso8859-"); ok {
+		return "iso-8859-" + n
+	}
+	if n, ok := strings.CutPrefix(c, "iso_8859-"); ok {
+		return "iso-8859-" + n
+	}
+	return c
+}
+
+// legacyCharset reports whether data in charset can be decoded here and is
+// not already UTF-8.
+func legacyCharset(charset string) bool {
+	c := canonicalCharset(charset)
+	switch c {
+	case "iso-8859-1", "shift_jis", "euc-jp", "iso-2022-jp":
+		return true
+	}
+	_, ok := singleByteCharsets[c]
+	return ok
+}
+
+// decodeCharset decodes data from charset to UTF-8. It reports false for
+// charsets it does not know.
+func decodeCharset(charset string, data []byte) (string, bool) {
+	switch c := canonicalCharset(charset); c {
+	case "utf-8":
+		return strings.ToValidUTF8(string(data), "�"), true
+	case "iso-8859-1":
+		var sb strings.Builder
+		for _, b := range data {
+			sb.WriteRune(rune(b))
+		}
+		return sb.String(), true
+	case "shift_jis":
+		return decodeShiftJIS(data), true
+	case "euc-jp":
+		return decodeEUCJP(data), true
+	case "iso-2022-jp":
+		return decodeISO2022JP(data), true
+	default:
+		table, ok := singleByteCharsets[c]
+		if !ok {
+			return "", false
+		}
+		upper := []rune(table)
+		var sb strings.Builder
+		for _, b := range data {
+			if b < 0x80 {
+				sb.WriteByte(b)
+			} else {
+				sb.WriteRune(upper[b-0x80])
+			}
+		}
+		return sb.String(), true
+	}
+}
+
+// jisRune returns the JIS X 0208 character at 0-based row and column.
+func jisRune(row, col int) rune {
+	if row < 0 || row >= len(jis0208) || col < 0 || col >= 94 {
+		return utf8.RuneError
+	}
+	return jisRows[row][col]
+}
+
+// jisRows is jis0208 as runes, indexed by row.
+var jisRows = func() [][]rune {
+	rows := make([][]rune, len(jis0208))
+	for i, r := range jis0208 {
+		rows[i] = []rune(r)
+	}
+	return rows
+}()
+
+// decodeISO2022JP decodes ISO-2022-JP (RFC 1468) with the JIS X 0201
+// katakana set, leaving JIS X 0212 characters as U+FFFD.
+func decodeISO2022JP(data []byte) string {
+	const (
+		ascii = iota
+		jis
+		katakana
+		jis0212
+	)
+	var sb strings.Builder
+	mode := ascii
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		if b == 0x1b && i+2 < len(data) {
+			switch seq := string(data[i+1 : i+3]); seq {
+			case "(B", "(J":
+				mode, i = ascii, i+2
+				continue
+			case "$@", "$B":
+				mode, i = jis, i+2
+				continue
+			case "(I":
+				mode, i = katakana, i+2
+				continue
+			case "$(":
+				if i+3 < len(data) {
+					mode = jis
+					if data[i+3] == 'D' {
+						mode = jis0212
+					}
+					i += 3
+					continue
+				}
+			}
+		}
+		switch {
+		case b == '\r' || b == '\n':
+			sb.WriteByte(b)
+		case mode == katakana && b >= 0x21 && b <= 0x5f:
+			sb.WriteRune(0xff61 + rune(b-0x21))
+		case (mode == jis || mode == jis0212) && b >= 0x21 && b <= 0x7e && i+1 < len(data):
+			r := utf8.RuneError
+			if mode == jis {
+				r = jisRune(int(b-0x21), int(data[i+1])-0x21)
+			}
+			sb.WriteRune(r)
+			i++
+		case b < 0x80:
+			sb.WriteByte(b)
+		default:
+			sb.WriteRune(utf8.RuneError)
+		}
+	}
+	return sb.String()
+}
+
+// decodeEUCJP decodes EUC-JP, leaving JIS X 0212 characters as U+FFFD.
+func decodeEUCJP(data []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			sb.WriteByte(b)
+		case b == 0x8e && i+1 < len(data):
+			// JIS X 0201 katakana
+			sb.WriteRune(0xff61 + rune(data[i+1]) - 0xa1)
+			i++
+		case b == 0x8f && i+2 < len(data):
+			sb.WriteRune(utf8.RuneError)
+			i += 2
+		case b >= 0xa1 && b <= 0xfe && i+1 < len(data):
+			sb.WriteRune(jisRune(int(b)-0xa1, int(data[i+1])-0xa1))
+			i++
+		default:
+			sb.WriteRune(utf8.RuneError)
+		}
+	}
+	return sb.String()
+}
+
+// decodeShiftJIS decodes Shift_JIS with the Windows-31J extensions.
+func decodeShiftJIS(data []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			sb.WriteByte(b)
+		case b >= 0xa1 && b <= 0xdf:
+			sb.WriteRune(0xff61 + rune(b-0xa1))
+		case (b >= 0x81 && b <= 0x9f || b >= 0xe0 && b <= 0xfc) && i+1 < len(data):
+			t := int(data[i+1])
+			i++
+			row := int(b-0x81) * 2
+			if b >= 0xe0 {
+				row = int(b-0xc1) * 2
+			}
+			var col int
+			switch {
+			case t >= 0x40 && t <= 0x7e:
+				col = t - 0x40
+			case t >= 0x80 && t <= 0x9e:
+				col = t - 0x41
+			case t >= 0x9f && t <= 0xfc:
+				row, col = row+1, t-0x9f
+			default:
+				sb.WriteRune(utf8.RuneError)
+				continue
+			}
+			sb.WriteRune(jisRune(row, col))
+		default:
+			sb.WriteRune(utf8.RuneError)
+		}
+	}
+	return sb.String()
+}
+
+// headerDecoder decodes RFC 2047 encoded words in any charset
+// decodeCharset knows.
+var headerDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := decodeCharset(charset, data)
+		if !ok {
+			return nil, fmt.Errorf("unsupported charset %q", charset)
+		}
+		return strings.NewReader(s), nil
+	},
+}
+
+// decodeHeader decodes the encoded words left in a header value, returning
+// v unchanged when it has none or they do not decode.
+func decodeHeader(v string) string {
+	if !strings.Contains(v, "=?") {
+		return v
+	}
+	d, err := headerDecoder.DecodeHeader(v)
+	if err != nil {
+		return v
+	}
+	return d
+}
+
+// decodeEmailHeaders decodes leftover encoded words in the subjects and
+// address names of the emails in resp's Email/get responses.
+func decodeEmailHeaders(resp *jmap.Response) {
+	for _, inv := range resp.Responses {
+		get, ok := inv.Args.(*email.GetResponse)
+		if !ok {
+			continue
+		}
+		for _, e := range get.List {
+			e.Subject = decodeHeader(e.Subject)
+			for _, addrs := range [][]*mail.Address{e.From, e.Sender, e.To, e.CC, e.BCC, e.ReplyTo} {
+				for _, a := range addrs {
+					if a != nil {
+						a.Name = decodeHeader(a.Name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// misdecoded reports whether the server's decoding of a body part in a
+// legacy charset failed: it says so, left replacement characters, or left
+// ISO-2022-JP escape sequences in place.
+func misdecoded(part *email.BodyPart, bv *email.BodyValue) bool {
+	if !legacyCharset(part.Charset) {
+		return false
+	}
+	return bv.IsEncodingProblem || strings.ContainsRune(bv.Value, utf8.RuneError) || strings.Contains(bv.Value, "\x1b$B")
+}
+
+// redecodeBodies replaces the misdecoded text body values of list with
+// their parts' blobs decoded here, cut to maxBytes when it is not 0. A blob
+// that fails to download keeps the server's value.
+func redecodeBodies(ctx context.Context, client JMAPClient, accountID jmap.ID, list []*email.Email, maxBytes uint64) {
+	for _, e := range list {
+		for _, part := range append(append([]*email.BodyPart(nil), e.TextBody...), e.HTMLBody...) {
+			bv, ok := e.BodyValues[part.PartID]
+			if !ok || part.BlobID == "" || !misdecoded(part, bv) {
+				continue
+			}
+			body, err := client.Download(ctx, accountID, part.BlobID)
+			if err != nil {
+				continue
+			}
+			limit := int64(maxFetchPartBytes)
+			if maxBytes > 0 {
+				limit = min(limit, int64(maxBytes))
+			}
+			data, err := io.ReadAll(io.LimitReader(body, limit+1))
+			body.Close()
+			if err != nil {
+				continue
+			}
+			truncated := int64(len(data)) > limit
+			if truncated {
+				data = data[:limit]
+			}
+			value, _ := decodeCharset(part.Charset, data)
+			if maxBytes > 0 && uint64(len(value)) > maxBytes {
+				value, truncated = truncateUTF8(value, int(maxBytes)), true
+			}
+			e.BodyValues[part.PartID] = &email.BodyValue{Value: value, IsTruncated: truncated}
+		}
+	}
+}
+
+// maxFetchPartBytes caps the blob redecodeBodies reads for a body part
+// without a byte limit.
+const maxFetchPartBytes = 8 << 20
+
+// truncateUTF8 cuts s to at most n bytes at a character boundary.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
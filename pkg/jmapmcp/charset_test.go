@@ -0,0 +1,80 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	for _, tc := range []struct {
+		charset string
+		data    string
+		want    string
+	}{
+		{"ISO-2022-JP", "\x1b$B$3$s$K$A$O!\"@$3&!#\x1b(B ok", "こんにちは、世界。 ok"},
+		{"Shift_JIS", "\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd\x81A\x90\xa2\x8aE\x81B\xb6\xc0\xb6\xc5\x87@", "こんにちは、世界。ｶﾀｶﾅ①"},
+		{"euc-jp", "\xa4\xb3\xa4\xf3\xa4\xcb\xa4\xc1\xa4\xcf\xa1\xa2\xc0\xa4\xb3\xa6\xa1\xa3\x8e\xb6\x8e\xc0\x8e\xb6\x8e\xc5", "こんにちは、世界。ｶﾀｶﾅ"},
+		{"KOI8-R", "\xf0\xd2\xc9\xd7\xc5\xd4", "Привет"},
+		{"cp1251", "\xcf\xf0\xe8\xe2\xe5\xf2", "Привет"},
+		{"windows-1254", "\xd6l\xe7\xfcm \x80", "Ölçüm €"},
+		{"latin1", "caf\xe9", "café"},
+	} {
+		got, ok := decodeCharset(tc.charset, []byte(tc.data))
+		if !ok || got != tc.want {
+			t.Errorf("decodeCharset(%s) = %q, %v, want %q", tc.charset, got, ok, tc.want)
+		}
+	}
+	if _, ok := decodeCharset("gb2312", []byte("x")); ok {
+		t.Error("gb2312 decoded")
+	}
+}
+
+func TestCharsetTables(t *testing.T) {
+	for name, table := range singleByteCharsets {
+		if n := utf8.RuneCountInString(table); n != 128 {
+			t.Errorf("%s has %d characters", name, n)
+		}
+	}
+	for i, row := range jis0208 {
+		if n := utf8.RuneCountInString(row); n != 94 {
+			t.Errorf("JIS X 0208 row %d has %d characters", i+1, n)
+		}
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	for in, want := range map[string]string{
+		"=?ISO-2022-JP?B?GyRCMnE1RCRON28bKEI=?=": "会議の件",
+		"Re: =?koi8-r?B?8NLJ18XU?= again":        "Re: Привет again",
+		"=?x-unknown?Q?abc?=":                    "=?x-unknown?Q?abc?=",
+		"plain subject":                          "plain subject",
+	} {
+		if got := decodeHeader(in); got != want {
+			t.Errorf("decodeHeader(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEmailGetLegacyEncodings(t *testing.T) {
+	s, fake := newFakeServer(t)
+	raw := fake.AddBlob([]byte("\x1b$B$3$s$K$A$O!\"@$3&!#\x1b(B"))
+	fake.Add("Email", map[string]any{
+		"id":         "m1",
+		"subject":    "=?ISO-2022-JP?B?GyRCMnE1RCRON28bKEI=?=",
+		"from":       []any{map[string]any{"name": "=?koi8-r?B?8NLJ18XU?=", "email": "ivan@example.org"}},
+		"receivedAt": "2025-01-01T10:00:00Z",
+		"mailboxIds": map[string]any{"inbox": true},
+		"textBody":   []any{map[string]any{"partId": "1", "blobId": raw, "type": "text/plain", "charset": "iso-2022-jp", "size": 28}},
+		"bodyValues": map[string]any{"1": map[string]any{"value": "$3$s$K$A$O!\"@$3&!#", "isEncodingProblem": true}},
+	})
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1"}})
+	out := resultText(res)
+	for _, want := range []string{"会議の件", "Привет", "こんにちは、世界。"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("email_get lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Classification sorts messages into the operator's categories
+// (-classify-categories) and records each message's category as the
+// keyword classifyKeywordPrefix+category, which email_query can filter on
+// and other mail clients show as a tag. email_classify asks the MCP
+// client's model through sampling when the client offers it and the
+// configured endpoint (-classify-url) otherwise; classify automations,
+// which run without a client, use the endpoint.
+
+const (
+	classifyKeywordPrefix = "category-"
+	classifyBatch         = 20  // emails per sampling request or endpoint call
+	maxClassify           = 100 // emails per email_classify call
+	classifyPreviewBytes  = 300
+	classifyTimeout       = 30 * time.Second
+	classifySampleTokens  = 1000
+)
+
+// classifier holds the categories and, when configured, the endpoint
+// (POST JSON {categories, emails: [{id, from, subject, preview}]} →
+// {categories: {id: category}}).
+type classifier struct {
+	categories []string
+	url        string
+	client     *http.Client
+}
+
+func newClassifier(categories []string, url string) *classifier {
+	return &classifier{
+		categories: categories,
+		url:        url,
+		client:     &http.Client{Timeout: classifyTimeout},
+	}
+}
+
+// classifyEmail is what a classifier sees of a message.
+type classifyEmail struct {
+	ID      jmap.ID `json:"id"`
+	From    string  `json:"from"`
+	Subject string  `json:"subject"`
+	Preview string  `json:"preview"`
+}
+
+// classifyRequest is the body POSTed to the endpoint.
+type classifyRequest struct {
+	Categories []string        `json:"categories"`
+	Emails     []classifyEmail `json:"emails"`
+}
+
+// classifyResponse is the endpoint's answer, and the JSON sampling is asked
+// for: each email ID mapped to its category.
+type classifyResponse struct {
+	Categories map[jmap.ID]string `json:"categories"`
+}
+
+// keyword is the keyword recording category.
+func (c *classifier) keyword(category string) string {
+	return classifyKeywordPrefix + category
+}
+
+// categoryOf returns the category whose keyword keywords holds, or "".
+func (c *classifier) categoryOf(keywords map[string]bool) string {
+	for _, cat := range c.categories {
+		if keywords[c.keyword(cat)] {
+			return cat
+		}
+	}
+	return ""
+}
+
+// patch sets category's keyword on an email and removes the other
+// categories' keywords.
+func (c *classifier) patch(category string) jmap.Patch {
+	patch := jmap.Patch{}
+	for _, cat := range c.categories {
+		if cat == category {
+			patch["keywords/"+c.keyword(cat)] = true
+		} else {
+			patch["keywords/"+c.keyword(cat)] = nil
+		}
+	}
+	return patch
+}
+
+// classify returns the category of each of emails that the client's
+// model, when req offers sampling, or else the endpoint put in one of the
+// configured categories, and which of the two it used. Emails given no
+// known category are left out.
+func (s *Server) classify(ctx context.Context, req *mcp.CallToolRequest, emails []*email.Email) (map[jmap.ID]string, string, error) {
+	c := s.classifier
+	source := "endpoint"
+	switch {
+	case canSample(req):
+		source = "sampling"
+	case c.url == "":
+		return nil, "", invalidArgument("the MCP client does not offer sampling and no classification endpoint is configured (-classify-url)")
+	}
+
+	out := make(map[jmap.ID]string, len(emails))
+	for start := 0; start < len(emails); start += classifyBatch {
+		batch := make([]classifyEmail, 0, classifyBatch)
+		for _, e := range emails[start:min(start+classifyBatch, len(emails))] {
+			preview, _ := s.redactor.redact(truncateUTF8(strings.Join(strings.Fields(e.Preview), " "), classifyPreviewBytes))
+			batch = append(batch, classifyEmail{ID: e.ID, From: formatAddresses(e.From), Subject: e.Subject, Preview: preview})
+		}
+		var answer map[jmap.ID]string
+		var err error
+		if source == "sampling" {
+			answer, err = c.sample(ctx, req, batch)
+		} else {
+			answer, err = c.post(ctx, batch)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		for _, e := range batch {
+			cat := strings.ToLower(strings.TrimSpace(answer[e.ID]))
+			if slices.Contains(c.categories, cat) {
+				out[e.ID] = cat
+			}
+		}
+	}
+	return out, source, nil
+}
+
+// sample asks the client's model to classify emails.
+func (c *classifier) sample(ctx context.Context, req *mcp.CallToolRequest, emails []classifyEmail) (map[jmap.ID]string, error) {
+	listing, err := json.MarshalIndent(emails, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	system := "You sort email into categories. Answer with JSON only, no prose."
+	prompt := fmt.Sprintf("Categories: %s\n\nPut each of these emails in exactly one of the categories. Answer with a JSON object mapping each email's id to its category, such as {\"categories\": {\"%s\": \"%s\"}}.\n\nEmails:\n%s",
+		strings.Join(c.categories, ", "), emails[0].ID, c.categories[0], listing)
+	text, err := sampleText(ctx, req, system, prompt, classifySampleTokens)
+	if err != nil {
+		return nil, err
+	}
+	// Models like to wrap JSON in a code fence or a sentence.
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("sampling: the answer holds no JSON object")
+	}
+	var out classifyResponse
+	if err := json.Unmarshal([]byte(text[start:end+1]), &out); err != nil {
+		return nil, fmt.Errorf("sampling: decode the answer: %w", err)
+	}
+	return out.Categories, nil
+}
+
+// post asks the endpoint to classify emails.
+func (c *classifier) post(ctx context.Context, emails []classifyEmail) (map[jmap.ID]string, error) {
+	body, err := json.Marshal(classifyRequest{Categories: c.categories, Emails: emails})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("classification endpoint returned %s", resp.Status)
+	}
+	var out classifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode classification: %w", err)
+	}
+	return out.Categories, nil
+}
@@ -0,0 +1,50 @@
+package jmapmcp
+
+import (
+	"context"
+	"io"
+
+	"github.com/mikluko/jmap"
+)
+
+// JMAPClient is the JMAP connection tool handlers use. The default wraps a
+// *jmap.Client; WithClientWrapper layers caching, retries, or
+// instrumentation on top without touching handlers.
+type JMAPClient interface {
+	// Session returns the authenticated session resource.
+	Session() *jmap.Session
+	// Do sends a request and returns its method responses.
+	Do(req *jmap.Request) (*jmap.Response, error)
+	// Upload stores blob in the account and returns its blob ID and type.
+	Upload(ctx context.Context, accountID jmap.ID, blob io.Reader) (*jmap.UploadResponse, error)
+	// Download streams the content of a blob.
+	Download(ctx context.Context, accountID, blobID jmap.ID) (io.ReadCloser, error)
+}
+
+// NewJMAPClient adapts an authenticated *jmap.Client to JMAPClient.
+func NewJMAPClient(c *jmap.Client) JMAPClient {
+	return libClient{c}
+}
+
+// libClient is the JMAPClient backed by the go-jmap library.
+type libClient struct {
+	c *jmap.Client
+}
+
+func (l libClient) Session() *jmap.Session {
+	l.c.Lock()
+	defer l.c.Unlock()
+	return l.c.Session
+}
+
+func (l libClient) Do(req *jmap.Request) (*jmap.Response, error) {
+	return l.c.Do(req)
+}
+
+func (l libClient) Upload(ctx context.Context, accountID jmap.ID, blob io.Reader) (*jmap.UploadResponse, error) {
+	return l.c.UploadWithContext(ctx, accountID, blob)
+}
+
+func (l libClient) Download(ctx context.Context, accountID, blobID jmap.ID) (io.ReadCloser, error) {
+	return l.c.DownloadWithContext(ctx, accountID, blobID)
+}
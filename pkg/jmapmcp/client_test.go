@@ -0,0 +1,35 @@
+package jmapmcp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mikluko/jmap"
+)
+
+// countingClient counts requests passing through it.
+type countingClient struct {
+	JMAPClient
+	calls *int
+}
+
+func (c countingClient) Do(req *jmap.Request) (*jmap.Response, error) {
+	*c.calls++
+	return c.JMAPClient.Do(req)
+}
+
+func TestClientWrapper(t *testing.T) {
+	var calls int
+	s, fake := newFakeServer(t, WithClientWrapper(func(c JMAPClient) JMAPClient {
+		return countingClient{c, &calls}
+	}))
+	addEmail(fake, "e1", "Hello", "body", 1)
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	if calls != 1 {
+		t.Errorf("wrapper saw %d requests, want 1", calls)
+	}
+}
@@ -0,0 +1,243 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+)
+
+// AutoRecipients are added to every draft composed by email_create,
+// email_reply, and email_forward, like a desktop client's "always BCC
+// myself" rule.
+type AutoRecipients struct {
+	CC  []string
+	BCC []string
+}
+
+// defaultIdentity returns the identity whose Reply-To and BCC apply to new
+// drafts: the first one, which submitDraft also selects when no identity is
+// given. It returns nil when the account has no identities or the server
+// does not offer Identity/get (no submission capability).
+func defaultIdentity(ctx context.Context, client JMAPClient, accountID jmap.ID) (*identity.Identity, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&identity.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Identity/get")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *identity.GetResponse:
+		return firstIdentity(args.List), nil
+	case *jmap.MethodError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected identity response type: %T", args)
+	}
+}
+
+// draftTarget returns the Drafts mailbox and the identity a new draft is
+// sent with (see defaultIdentity and sendPolicy.senderIdentity) from one
+// request, so composing a draft takes two round trips: this and the
+// Email/set.
+func (s *Server) draftTarget(ctx context.Context, client JMAPClient, accountID jmap.ID) (jmap.ID, *identity.Identity, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "role"}})
+	req.Invoke(&identity.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(resp.Responses) < 2 {
+		return "", nil, fmt.Errorf("expected 2 discovery responses, got %d", len(resp.Responses))
+	}
+
+	var draftsID jmap.ID
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		draftsID = draftsMailbox(args.List)
+	case *jmap.MethodError:
+		return "", nil, args
+	default:
+		return "", nil, fmt.Errorf("unexpected mailbox response type: %T", args)
+	}
+	if draftsID == "" {
+		return "", nil, fmt.Errorf("no mailbox with role %q found", mailbox.RoleDrafts)
+	}
+
+	var id *identity.Identity
+	switch args := resp.Responses[1].Args.(type) {
+	case *identity.GetResponse:
+		if id, err = s.sendPolicy.senderIdentity(args.List); err != nil {
+			return "", nil, err
+		}
+	case *jmap.MethodError:
+	default:
+		return "", nil, fmt.Errorf("unexpected identity response type: %T", args)
+	}
+	return draftsID, id, nil
+}
+
+// draftsMailbox returns the ID of the Drafts mailbox in list, or "".
+func draftsMailbox(list []*mailbox.Mailbox) jmap.ID {
+	for _, mb := range list {
+		if mb.Role == mailbox.RoleDrafts {
+			return mb.ID
+		}
+	}
+	return ""
+}
+
+func firstIdentity(identities []*identity.Identity) *identity.Identity {
+	if len(identities) == 0 {
+		return nil
+	}
+	return identities[0]
+}
+
+// applyDraftDefaults adds the identity's Reply-To and BCC addresses and the
+// configured auto recipients to draft, skipping addresses already on it.
+// Reply-To is only set when the draft has none. Under the identity domain
+// rule the identity is also set as From, so the draft names the sender the
+// policy chose. It returns a description of each addition (e.g. "BCC
+// archive@example.com") for the tool result.
+func (s *Server) applyDraftDefaults(draft *email.Email, id *identity.Identity) []string {
+	var added []string
+	present := make(map[string]bool)
+	for _, a := range draftRecipients(draft) {
+		present[strings.ToLower(a.Email)] = true
+	}
+	add := func(field string, list *[]*mail.Address, addr *mail.Address) {
+		key := strings.ToLower(addr.Email)
+		if key == "" || present[key] {
+			return
+		}
+		present[key] = true
+		*list = append(*list, addr)
+		added = append(added, field+" "+addr.Email)
+	}
+
+	if id != nil {
+		if len(draft.From) == 0 && s.sendPolicy != nil && len(s.sendPolicy.IdentityDomains) > 0 {
+			draft.From = []*mail.Address{{Name: id.Name, Email: id.Email}}
+			added = append(added, "From "+id.Email)
+		}
+		if len(draft.ReplyTo) == 0 && len(id.ReplyTo) > 0 {
+			draft.ReplyTo = id.ReplyTo
+			added = append(added, "Reply-To "+formatAddresses(id.ReplyTo))
+		}
+		for _, a := range id.Bcc {
+			add("BCC", &draft.BCC, a)
+		}
+	}
+	for _, a := range toMailAddresses(s.autoRecipients.CC) {
+		add("CC", &draft.CC, a)
+	}
+	for _, a := range toMailAddresses(s.autoRecipients.BCC) {
+		add("BCC", &draft.BCC, a)
+	}
+	return added
+}
+
+// formatDraftDefaults renders the additions made by applyDraftDefaults as a
+// result line, or "" when there were none.
+func formatDraftDefaults(added []string) string {
+	if len(added) == 0 {
+		return ""
+	}
+	return "Added automatically: " + strings.Join(added, ", ") + "\n"
+}
+
+// setDraftBody gives draft a plain text body and, when htmlBody is set,
+// an HTML alternative cleaned by sanitizeOutboundHTML. Without text the
+// plain part is converted from the cleaned HTML. It returns the sanitizer's
+// changes.
+func setDraftBody(draft *email.Email, text, htmlBody string) []string {
+	draft.BodyValues = map[string]*email.BodyValue{"body": {Value: text}}
+	draft.TextBody = []*email.BodyPart{{PartID: "body", Type: "text/plain"}}
+	if htmlBody == "" {
+		return nil
+	}
+	cleaned, changes := sanitizeOutboundHTML(htmlBody)
+	if text == "" {
+		draft.BodyValues["body"].Value = htmlToText(cleaned)
+	}
+	draft.BodyValues["html"] = &email.BodyValue{Value: cleaned}
+	draft.HTMLBody = []*email.BodyPart{{PartID: "html", Type: "text/html"}}
+	return changes
+}
+
+// Inline images: an attachment given a content ID is sent inline, and an
+// HTML body showing it as <img src="cid:..."> makes the server place the
+// two in a multipart/related part (RFC 8621, section 4.6), so the image
+// appears in the message rather than beside it.
+
+// cidReference matches a cid: URL in an HTML body, in an attribute or a
+// CSS url().
+var cidReference = regexp.MustCompile(`(?i)\bcid:([^"'\s()<>]+)`)
+
+// inlineCID returns cid without the angle brackets of its header form.
+func inlineCID(cid string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(cid), "<"), ">")
+}
+
+// checkInlineImages checks that the inline attachments of refs and the
+// cid: references of htmlBody match: each reference names an inline
+// attachment and each inline attachment is referenced, since a broken or
+// orphaned inline image is invisible to the agent but not to recipients.
+func checkInlineImages(htmlBody string, refs []AttachmentRef) error {
+	inline := make(map[string]bool)
+	for _, ref := range refs {
+		if ref.CID == "" {
+			continue
+		}
+		cid := inlineCID(ref.CID)
+		if cid == "" || strings.ContainsAny(cid, " \t\r\n<>\"'()") {
+			return invalidArgument("attachment cid %q is not a valid content ID", ref.CID)
+		}
+		if inline[strings.ToLower(cid)] {
+			return invalidArgument("attachment cid %q is used twice", cid)
+		}
+		inline[strings.ToLower(cid)] = true
+	}
+	if len(inline) > 0 && htmlBody == "" {
+		return invalidArgument("inline attachments (cid) need an HTML body to show them")
+	}
+	referenced := make(map[string]bool)
+	for _, m := range cidReference.FindAllStringSubmatch(htmlBody, -1) {
+		cid, err := url.PathUnescape(m[1])
+		if err != nil {
+			cid = m[1]
+		}
+		if !inline[strings.ToLower(cid)] {
+			return invalidArgument("the HTML body shows cid:%s, but no attachment has that cid", m[1])
+		}
+		referenced[strings.ToLower(cid)] = true
+	}
+	for _, ref := range refs {
+		if cid := inlineCID(ref.CID); cid != "" && !referenced[strings.ToLower(cid)] {
+			return invalidArgument("attachment cid %q is not shown by the HTML body; reference it as <img src=\"cid:%s\"> or drop the cid to attach the file normally", cid, cid)
+		}
+	}
+	return nil
+}
+
+func formatHTMLChanges(changes []string) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	return "Warning: HTML body modified: " + strings.Join(changes, "; ") + "\n"
+}
@@ -0,0 +1,190 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+)
+
+func TestApplyDraftDefaults(t *testing.T) {
+	s := &Server{autoRecipients: AutoRecipients{
+		CC:  []string{"team@example.com"},
+		BCC: []string{"archive@example.com", "bob@example.com"},
+	}}
+	id := &identity.Identity{
+		Email:   "me@example.com",
+		ReplyTo: addrs("replies@example.com"),
+		Bcc:     addrs("Archive@example.com", "self@example.com"),
+	}
+	draft := &email.Email{To: addrs("bob@example.com")}
+
+	added := s.applyDraftDefaults(draft, id)
+
+	if got := emailsOf(draft.ReplyTo); len(got) != 1 || got[0] != "replies@example.com" {
+		t.Errorf("ReplyTo = %v", got)
+	}
+	if got := strings.Join(emailsOf(draft.CC), ","); got != "team@example.com" {
+		t.Errorf("CC = %s", got)
+	}
+	// bob is already in To and the configured archive address repeats the
+	// identity's (case-insensitively), so neither is added twice.
+	if got := strings.Join(emailsOf(draft.BCC), ","); got != "Archive@example.com,self@example.com" {
+		t.Errorf("BCC = %s", got)
+	}
+	if len(added) != 4 {
+		t.Errorf("added = %v, want Reply-To, two BCC, one CC", added)
+	}
+
+	// An explicit Reply-To is kept.
+	draft = &email.Email{ReplyTo: addrs("mine@example.com")}
+	s.applyDraftDefaults(draft, id)
+	if got := emailsOf(draft.ReplyTo); len(got) != 1 || got[0] != "mine@example.com" {
+		t.Errorf("explicit ReplyTo replaced: %v", got)
+	}
+}
+
+func TestEmailCreateAutoBCC(t *testing.T) {
+	s, fake := newFakeServer(t, WithAutoRecipients(AutoRecipients{BCC: []string{"archive@example.com"}}))
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Identity", map[string]any{
+		"id":      "i1",
+		"email":   "me@example.com",
+		"replyTo": []any{map[string]any{"email": "replies@example.com"}},
+	})
+
+	res, _, _ := s.handleEmailCreate(context.Background(), nil, EmailCreateInput{
+		To:      []string{"bob@example.com"},
+		Subject: "Hello",
+		Body:    "Hi Bob",
+	})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	if !strings.Contains(out, "Added automatically: Reply-To replies@example.com, BCC archive@example.com") {
+		t.Errorf("additions not reported:\n%s", out)
+	}
+
+	ids := fake.Objects("Email")
+	if len(ids) != 1 {
+		t.Fatalf("emails = %v, want one draft", ids)
+	}
+	draft := fake.Object("Email", ids[0])
+	if bcc, _ := draft["bcc"].([]any); len(bcc) != 1 {
+		t.Errorf("draft bcc = %v", draft["bcc"])
+	}
+	if replyTo, _ := draft["replyTo"].([]any); len(replyTo) != 1 {
+		t.Errorf("draft replyTo = %v", draft["replyTo"])
+	}
+}
+
+func TestEmailCreateHTMLBody(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+
+	res, _, _ := s.handleEmailCreate(context.Background(), nil, EmailCreateInput{
+		To:      []string{"bob@example.com"},
+		Subject: "Invoice",
+		HTML:    `<p>Pay at <a href="https://evil.example">bank.example.com</a></p><script>x()</script>`,
+	})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	if !strings.Contains(out, `Warning: HTML body modified: removed 1 script, form, or embedding element(s); unlinked "bank.example.com", which pointed to https://evil.example`) {
+		t.Errorf("changes not reported:\n%s", out)
+	}
+
+	draft := fake.Object("Email", fake.Objects("Email")[0])
+	values, _ := draft["bodyValues"].(map[string]any)
+	htmlValue, _ := values["html"].(map[string]any)
+	textValue, _ := values["body"].(map[string]any)
+	if html, _ := htmlValue["value"].(string); strings.Contains(html, "script") || strings.Contains(html, "evil.example") {
+		t.Errorf("draft HTML = %q", html)
+	}
+	if text, _ := textValue["value"].(string); !strings.Contains(text, "Pay at bank.example.com") {
+		t.Errorf("derived text body = %q", text)
+	}
+	if parts, _ := draft["htmlBody"].([]any); len(parts) != 1 {
+		t.Errorf("draft htmlBody = %v", draft["htmlBody"])
+	}
+}
+
+func TestCheckInlineImages(t *testing.T) {
+	chart := []AttachmentRef{{BlobID: "b1", CID: "<chart1>"}, {BlobID: "b2"}}
+	for _, tc := range []struct {
+		html string
+		refs []AttachmentRef
+		want string
+	}{
+		{`<img src="cid:chart1"><div style="background: url(cid:chart1)">`, chart, ""},
+		{`<p>no images</p>`, []AttachmentRef{{BlobID: "b2"}}, ""},
+		{`<img src="cid:chart2">`, chart, "no attachment has that cid"},
+		{`<p>forgot it</p>`, chart, "is not shown by the HTML body"},
+		{"", chart, "need an HTML body"},
+		{`<img src="cid:a">`, []AttachmentRef{{BlobID: "b1", CID: "a"}, {BlobID: "b2", CID: "A"}}, "used twice"},
+		{`<img src="cid:a b">`, []AttachmentRef{{BlobID: "b1", CID: "a b"}}, "not a valid content ID"},
+	} {
+		err := checkInlineImages(tc.html, tc.refs)
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("checkInlineImages(%q) = %v, want %q", tc.html, err, tc.want)
+		}
+	}
+}
+
+func TestEmailCreateInlineImage(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	blob := fake.AddBlob([]byte("\x89PNG"))
+
+	res, _, _ := s.handleEmailCreate(context.Background(), nil, EmailCreateInput{
+		To:          []string{"bob@example.com"},
+		Subject:     "Sales",
+		HTML:        `<p>This quarter:</p><img src="cid:chart1" alt="chart">`,
+		Attachments: []AttachmentRef{{BlobID: string(blob), Name: "chart.png", Type: "image/png", CID: "chart1"}},
+	})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	draft := fake.Object("Email", fake.Objects("Email")[0])
+	parts, _ := draft["attachments"].([]any)
+	if len(parts) != 1 {
+		t.Fatalf("draft attachments = %v", draft["attachments"])
+	}
+	if part := parts[0].(map[string]any); part["cid"] != "chart1" || part["disposition"] != "inline" {
+		t.Errorf("inline part = %v", part)
+	}
+	values, _ := draft["bodyValues"].(map[string]any)
+	if html, _ := values["html"].(map[string]any)["value"].(string); !strings.Contains(html, `src="cid:chart1"`) {
+		t.Errorf("draft HTML = %q", html)
+	}
+}
+
+func TestEmailCreateIdentityDomains(t *testing.T) {
+	s, fake := newFakeServer(t, WithSendPolicy(SendPolicy{IdentityDomains: []string{"work.example"}}))
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@home.example"})
+	fake.Add("Identity", map[string]any{"id": "i2", "name": "Me", "email": "me@work.example"})
+	ctx := context.Background()
+	in := EmailCreateInput{To: []string{"bob@example.com"}, Subject: "Hello", Body: "Hi Bob"}
+
+	res, _, _ := s.handleEmailCreate(ctx, nil, in)
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Added automatically: From me@work.example") {
+		t.Fatalf("email_create:\n%s", out)
+	}
+	draft := fake.Object("Email", fake.Objects("Email")[0])
+	if from, _ := draft["from"].([]any); len(from) != 1 || from[0].(map[string]any)["email"] != "me@work.example" {
+		t.Errorf("draft from = %v", draft["from"])
+	}
+
+	s, fake = newFakeServer(t, WithSendPolicy(SendPolicy{IdentityDomains: []string{"work.example"}}))
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@home.example"})
+	res, _, _ = s.handleEmailCreate(ctx, nil, in)
+	if te, _ := res.StructuredContent.(*toolError); !res.IsError || te == nil || te.Rule != "identity_domains" {
+		t.Errorf("email_create without an allowed identity: %s", resultText(res))
+	}
+}
@@ -0,0 +1,68 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentToolCalls runs tool calls of one server in parallel, as
+// the MCP SDK does for a session's requests; run with -race.
+func TestConcurrentToolCalls(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.EnableWebSocket()
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	for i := range 20 {
+		addEmail(fake, fmt.Sprintf("e%d", i), fmt.Sprintf("Message %d", i), "body", i%5+1)
+	}
+
+	call := func(name string, args any) (string, error) {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return "", err
+		}
+		res, err := s.toolCalls[name].call(context.Background(), data)
+		if err != nil {
+			return "", err
+		}
+		if res.IsError {
+			return "", fmt.Errorf("%s: %s", name, resultText(res))
+		}
+		return resultText(res), nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("e%d", i)
+			if out, err := call("email_query", map[string]any{}); err != nil || !strings.Contains(out, "Message") {
+				errs <- fmt.Errorf("email_query = %q, %v", out, err)
+			}
+			if out, err := call("email_get", map[string]any{"email_ids": []string{id}}); err != nil || !strings.Contains(out, "Message") {
+				errs <- fmt.Errorf("email_get %s = %q, %v", id, out, err)
+			}
+			if _, err := call("email_flag", map[string]any{"email_ids": []string{id}, "flagged": true}); err != nil {
+				errs <- err
+			}
+			if _, err := call("mailbox_get", map[string]any{}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	for i := range 10 {
+		if kw, _ := fake.Object("Email", fmt.Sprintf("e%d", i))["keywords"].(map[string]any); kw["$flagged"] != true {
+			t.Errorf("e%d not flagged", i)
+		}
+	}
+}
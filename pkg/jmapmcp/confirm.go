@@ -0,0 +1,109 @@
+package jmapmcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Two-phase destruction: with WithDestructiveConfirmation, a call that
+// would permanently destroy emails or mailboxes first returns a summary and
+// a short-lived token instead of acting, and only a second call with the
+// same arguments and that token goes ahead. Unlike elicitation this needs
+// nothing from the client, and the summary gives the user a last look at
+// what is about to go.
+
+// confirmationTTL is how long a confirmation token stays valid.
+const confirmationTTL = 5 * time.Minute
+
+// pendingConfirmation is an issued, unused confirmation token.
+type pendingConfirmation struct {
+	owner    string
+	endpoint string
+	call     string // fingerprint of the tool call it confirms
+	expires  time.Time
+}
+
+type confirmations struct {
+	mu sync.Mutex
+	m  map[string]*pendingConfirmation // token → what it confirms
+}
+
+// issue returns a new token confirming call for owner on endpoint.
+func (c *confirmations) issue(owner, endpoint, call string, now time.Time) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	token := hex.EncodeToString(b[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = make(map[string]*pendingConfirmation)
+	}
+	for t, p := range c.m {
+		if now.After(p.expires) {
+			delete(c.m, t)
+		}
+	}
+	c.m[token] = &pendingConfirmation{owner: owner, endpoint: endpoint, call: call, expires: now.Add(confirmationTTL)}
+	return token
+}
+
+// redeem consumes token if it confirms call for owner on endpoint and has
+// not expired.
+func (c *confirmations) redeem(token, owner, endpoint, call string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.m[token]
+	if !ok || p.owner != owner || p.endpoint != endpoint || p.call != call || now.After(p.expires) {
+		return false
+	}
+	delete(c.m, token)
+	return true
+}
+
+// listSome lists up to 10 of items.
+func listSome(items []string) string {
+	if len(items) <= 10 {
+		return strings.Join(items, ", ")
+	}
+	return strings.Join(items[:10], ", ") + fmt.Sprintf(" (+%d more)", len(items)-10)
+}
+
+// callFingerprint identifies the tool call of tool with arguments in, which
+// must not include the confirmation token itself.
+func callFingerprint(tool string, in any) string {
+	data, _ := json.Marshal(in)
+	sum := sha256.Sum256(append([]byte(tool+"\x00"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// confirmDestructive gates a destructive call of tool with arguments in
+// (token blanked) described by summary. It returns nil when the call may go
+// ahead: confirmation is off, or token confirms this very call. Otherwise
+// it returns the result to send instead: a new token for the call, or an
+// error for a token that does not confirm it.
+func (s *Server) confirmDestructive(ctx context.Context, tool string, in any, token, summary string) *mcp.CallToolResult {
+	if !s.confirmDestroy {
+		return nil
+	}
+	owner, endpoint, call := s.idOwner(ctx), endpointName(ctx), callFingerprint(tool, in)
+	now := time.Now()
+	if token != "" {
+		if s.confirmations.redeem(token, owner, endpoint, call, now) {
+			return nil
+		}
+		return errorResult(invalidArgument("confirmation token %q is unknown, expired, already used, or for different arguments; call %s again without confirm to get a new one", token, tool))
+	}
+	token = s.confirmations.issue(owner, endpoint, call, now)
+	return textResult(fmt.Sprintf("Confirmation required: this will %s. It cannot be undone.\nNothing has changed yet. After the user agrees, call %s again with the same arguments and confirm=%q within %d minutes.",
+		summary, tool, token, int(confirmationTTL.Minutes())))
+}
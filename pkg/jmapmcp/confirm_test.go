@@ -0,0 +1,97 @@
+package jmapmcp
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var confirmToken = regexp.MustCompile(`confirm="([0-9a-f]+)"`)
+
+func TestConfirmations(t *testing.T) {
+	var c confirmations
+	now := time.Now()
+	token := c.issue("alice", "default", "call", now)
+	if c.redeem(token, "bob", "default", "call", now) || c.redeem(token, "alice", "other", "call", now) || c.redeem(token, "alice", "default", "other call", now) {
+		t.Error("token redeemed for another owner, endpoint, or call")
+	}
+	if c.redeem(token, "alice", "default", "call", now.Add(confirmationTTL+time.Second)) {
+		t.Error("expired token redeemed")
+	}
+	if !c.redeem(token, "alice", "default", "call", now) {
+		t.Fatal("valid token refused")
+	}
+	if c.redeem(token, "alice", "default", "call", now) {
+		t.Error("token redeemed twice")
+	}
+}
+
+func TestEmailDeleteRequiresConfirmation(t *testing.T) {
+	s, fake := newFakeServer(t, WithDestructiveConfirmation())
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	addEmail(fake, "e1", "One", "", 1)
+	addEmail(fake, "e2", "Two", "", 2)
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}, Permanent: true})
+	out := resultText(res)
+	m := confirmToken.FindStringSubmatch(out)
+	if res.IsError || m == nil || !strings.Contains(out, "permanently destroy 1 email(s): e1") {
+		t.Fatalf("first call:\n%s", out)
+	}
+	if fake.Object("Email", "e1") == nil {
+		t.Fatal("e1 destroyed without confirmation")
+	}
+
+	res, _, _ = s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e2"}, Permanent: true, Confirm: m[1]})
+	if te, _ := res.StructuredContent.(*toolError); !res.IsError || te == nil || te.Code != codeInvalidArguments {
+		t.Errorf("token accepted for other arguments: %s", resultText(res))
+	}
+	if fake.Object("Email", "e2") == nil {
+		t.Error("e2 destroyed with a token issued for e1")
+	}
+
+	if res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}, Permanent: true, Confirm: m[1]}); res.IsError {
+		t.Fatalf("confirmed call: %s", resultText(res))
+	}
+	if fake.Object("Email", "e1") != nil {
+		t.Error("e1 kept after the confirmed call")
+	}
+
+	// Moving to Trash stays one call.
+	if res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e2"}}); res.IsError || confirmToken.MatchString(resultText(res)) {
+		t.Errorf("soft delete gated: %s", resultText(res))
+	}
+}
+
+func TestMailboxDestroyRequiresConfirmation(t *testing.T) {
+	s, fake := newFakeServer(t, WithDestructiveConfirmation())
+	fake.Add("Mailbox", map[string]any{"id": "old", "name": "Old projects"})
+	ctx := context.Background()
+
+	in := MailboxSetInput{Destroy: []string{"old"}, OnDestroyRemoveEmails: true}
+	res, _, _ := s.handleMailboxSet(ctx, nil, in)
+	out := resultText(res)
+	m := confirmToken.FindStringSubmatch(out)
+	if res.IsError || m == nil || !strings.Contains(out, "destroy 1 mailbox(es): Old projects, and the emails that are only in them") {
+		t.Fatalf("first call:\n%s", out)
+	}
+	if fake.Object("Mailbox", "old") == nil {
+		t.Fatal("mailbox destroyed without confirmation")
+	}
+
+	in.Confirm = m[1]
+	if res, _, _ := s.handleMailboxSet(ctx, nil, in); res.IsError {
+		t.Fatalf("confirmed call: %s", resultText(res))
+	}
+	if fake.Object("Mailbox", "old") != nil {
+		t.Error("mailbox kept after the confirmed call")
+	}
+
+	// Creating and renaming need no token.
+	if res, _, _ := s.handleMailboxSet(ctx, nil, MailboxSetInput{Create: map[string]MailboxSetCreate{"n": {Name: "New"}}}); res.IsError || confirmToken.MatchString(resultText(res)) {
+		t.Errorf("create gated: %s", resultText(res))
+	}
+}
@@ -0,0 +1,227 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/contacts"
+)
+
+// groupExpansion records a contact group named as a recipient and the
+// addresses it was replaced with.
+type groupExpansion struct {
+	name    string
+	members []string
+	skipped int // members without an email address
+}
+
+// expandContactGroups replaces every recipient in lists that is not an
+// email address (has no "@") with the addresses of the contact group of
+// that name, and returns the expansions made. Without the JMAP Contacts
+// capability such a recipient is an error.
+func expandContactGroups(ctx context.Context, client JMAPClient, lists ...*[]string) ([]groupExpansion, error) {
+	var names []string
+	for _, list := range lists {
+		for _, r := range *list {
+			if !strings.Contains(r, "@") && !slices.Contains(names, r) {
+				names = append(names, r)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[contacts.URI]
+	if accountID == "" {
+		return nil, fmt.Errorf("recipient %q is not an email address; contact groups need a server advertising %s", names[0], contacts.URI)
+	}
+
+	groups, err := fetchContactGroups(ctx, client, accountID, names)
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, g := range groups {
+		for uid, ok := range g.Members {
+			if ok && !slices.Contains(uids, uid) {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	slices.Sort(uids)
+	members, err := fetchCardsByUID(ctx, client, accountID, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	expansions := make([]groupExpansion, 0, len(names))
+	byName := make(map[string][]string, len(names))
+	for _, name := range names {
+		exp := groupExpansion{name: name}
+		for _, uid := range sortedKeys(groups[name].Members) {
+			if addr := preferredEmail(members[uid]); addr != "" {
+				exp.members = append(exp.members, addr)
+			} else {
+				exp.skipped++
+			}
+		}
+		if len(exp.members) == 0 {
+			return nil, fmt.Errorf("contact group %q has no members with an email address", name)
+		}
+		byName[name] = exp.members
+		expansions = append(expansions, exp)
+	}
+
+	// Rewrite each list in place, dropping addresses a group repeats.
+	for _, list := range lists {
+		var out []string
+		add := func(addr string) {
+			if !slices.ContainsFunc(out, func(o string) bool { return strings.EqualFold(o, addr) }) {
+				out = append(out, addr)
+			}
+		}
+		for _, r := range *list {
+			if members, ok := byName[r]; ok {
+				for _, addr := range members {
+					add(addr)
+				}
+			} else {
+				add(r)
+			}
+		}
+		*list = out
+	}
+	return expansions, nil
+}
+
+// fetchContactGroups looks up a group card for each name in one request,
+// matching names case-insensitively.
+func fetchContactGroups(ctx context.Context, client JMAPClient, accountID jmap.ID, names []string) (map[string]*contacts.Card, error) {
+	req := &jmap.Request{Context: ctx}
+	for _, name := range names {
+		queryCallID := req.Invoke(&contacts.Query{
+			Account: accountID,
+			Filter:  &contacts.FilterCondition{Kind: "group", Name: name},
+		})
+		req.Invoke(&contacts.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "ContactCard/query", Path: "/ids"},
+			Properties:   []string{"id", "uid", "kind", "name", "members"},
+		})
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 2*len(names) {
+		return nil, fmt.Errorf("expected %d ContactCard responses, got %d", 2*len(names), len(resp.Responses))
+	}
+
+	groups := make(map[string]*contacts.Card, len(names))
+	for i, name := range names {
+		if me, ok := resp.Responses[2*i].Args.(*jmap.MethodError); ok {
+			return nil, me
+		}
+		switch args := resp.Responses[2*i+1].Args.(type) {
+		case *contacts.GetResponse:
+			for _, card := range args.List {
+				if card.Kind == "group" && card.Name != nil && strings.EqualFold(card.Name.Full, name) {
+					groups[name] = card
+					break
+				}
+			}
+			if groups[name] == nil {
+				return nil, fmt.Errorf("recipient %q is neither an email address nor a contact group", name)
+			}
+		case *jmap.MethodError:
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+	return groups, nil
+}
+
+// fetchCardsByUID returns the cards with the given UIDs, keyed by UID.
+func fetchCardsByUID(ctx context.Context, client JMAPClient, accountID jmap.ID, uids []string) (map[string]*contacts.Card, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	conds := make([]contacts.Filter, len(uids))
+	for i, uid := range uids {
+		conds[i] = &contacts.FilterCondition{UID: uid}
+	}
+
+	req := &jmap.Request{Context: ctx}
+	queryCallID := req.Invoke(&contacts.Query{
+		Account: accountID,
+		Filter:  &contacts.FilterOperator{Operator: jmap.OperatorOR, Conditions: conds},
+	})
+	req.Invoke(&contacts.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "ContactCard/query", Path: "/ids"},
+		Properties:   []string{"id", "uid", "name", "emails"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 2 {
+		return nil, fmt.Errorf("missing ContactCard/get response in query chain")
+	}
+	if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+		return nil, me
+	}
+
+	switch args := resp.Responses[1].Args.(type) {
+	case *contacts.GetResponse:
+		cards := make(map[string]*contacts.Card, len(args.List))
+		for _, card := range args.List {
+			cards[card.UID] = card
+		}
+		return cards, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// preferredEmail returns the card's most preferred email address (lowest
+// pref, unset last, ties by key), or "".
+func preferredEmail(card *contacts.Card) string {
+	if card == nil {
+		return ""
+	}
+	best, bestPref := "", 0
+	for _, key := range sortedKeys(card.Emails) {
+		e := card.Emails[key]
+		if e == nil || e.Address == "" {
+			continue
+		}
+		if best == "" || e.Pref > 0 && (bestPref == 0 || e.Pref < bestPref) {
+			best, bestPref = e.Address, e.Pref
+		}
+	}
+	return best
+}
+
+// formatGroupExpansions renders one line per expanded contact group.
+func formatGroupExpansions(expansions []groupExpansion) string {
+	var sb strings.Builder
+	for _, exp := range expansions {
+		fmt.Fprintf(&sb, "Expanded contact group %s to %d member(s): %s", exp.name, len(exp.members), strings.Join(exp.members, ", "))
+		if exp.skipped > 0 {
+			fmt.Fprintf(&sb, " (%d member(s) without an email address skipped)", exp.skipped)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
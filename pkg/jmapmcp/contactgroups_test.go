@@ -0,0 +1,92 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/contacts"
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func TestPreferredEmail(t *testing.T) {
+	card := &contacts.Card{Emails: map[string]*contacts.EmailAddress{
+		"a": {Address: "home@example.org"},
+		"b": {Address: "work@example.org", Pref: 2},
+		"c": {Address: "main@example.org", Pref: 1},
+	}}
+	if got := preferredEmail(card); got != "main@example.org" {
+		t.Errorf("preferredEmail = %q, want main@example.org", got)
+	}
+	delete(card.Emails, "b")
+	delete(card.Emails, "c")
+	if got := preferredEmail(card); got != "home@example.org" {
+		t.Errorf("preferredEmail = %q, want home@example.org", got)
+	}
+	if got := preferredEmail(nil); got != "" {
+		t.Errorf("preferredEmail(nil) = %q", got)
+	}
+}
+
+// addTeamGroup seeds a "Team" group of Alice, Bob (two addresses), and a
+// member without email.
+func addTeamGroup(fake *jmapfake.Server) {
+	fake.AddCapability(string(contacts.URI), nil)
+	fake.Add("ContactCard", map[string]any{"id": "c1", "uid": "u-alice", "kind": "individual", "name": map[string]any{"full": "Alice"},
+		"emails": map[string]any{"e": map[string]any{"address": "alice@example.org"}}})
+	fake.Add("ContactCard", map[string]any{"id": "c2", "uid": "u-bob", "kind": "individual", "name": map[string]any{"full": "Bob"},
+		"emails": map[string]any{"home": map[string]any{"address": "bob@home.example", "pref": 2}, "work": map[string]any{"address": "bob@example.org", "pref": 1}}})
+	fake.Add("ContactCard", map[string]any{"id": "c3", "uid": "u-carol", "kind": "individual", "name": map[string]any{"full": "Carol"}})
+	fake.Add("ContactCard", map[string]any{"id": "g1", "uid": "u-team", "kind": "group", "name": map[string]any{"full": "Team"},
+		"members": map[string]any{"u-alice": true, "u-bob": true, "u-carol": true}})
+	fake.Add("ContactCard", map[string]any{"id": "g2", "uid": "u-team2", "kind": "group", "name": map[string]any{"full": "Team Leads"},
+		"members": map[string]any{"u-bob": true}})
+}
+
+func TestEmailCreateExpandsContactGroup(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	addTeamGroup(fake)
+
+	res, _, _ := s.handleEmailCreate(context.Background(), nil, EmailCreateInput{
+		To:      []string{"team", "alice@example.org", "dave@example.org"},
+		Subject: "Offsite",
+	})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	if want := "Expanded contact group team to 2 member(s): alice@example.org, bob@example.org (1 member(s) without an email address skipped)"; !strings.Contains(resultText(res), want) {
+		t.Errorf("missing %q:\n%s", want, resultText(res))
+	}
+
+	ids := fake.Objects("Email")
+	if len(ids) != 1 {
+		t.Fatalf("emails = %v, want one draft", ids)
+	}
+	var to []string
+	for _, a := range fake.Object("Email", ids[0])["to"].([]any) {
+		to = append(to, a.(map[string]any)["email"].(string))
+	}
+	if got := strings.Join(to, ", "); got != "alice@example.org, bob@example.org, dave@example.org" {
+		t.Errorf("to = %s", got)
+	}
+}
+
+func TestEmailCreateUnknownContactGroup(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+
+	res, _, _ := s.handleEmailCreate(context.Background(), nil, EmailCreateInput{To: []string{"Team"}, Subject: "Offsite"})
+	if !res.IsError || !strings.Contains(resultText(res), "contact groups need") {
+		t.Errorf("without contacts: %s", resultText(res))
+	}
+
+	addTeamGroup(fake)
+	res, _, _ = s.handleEmailCreate(context.Background(), nil, EmailCreateInput{To: []string{"Board"}, Subject: "Offsite"})
+	if !res.IsError || !strings.Contains(resultText(res), `"Board" is neither an email address nor a contact group`) {
+		t.Errorf("unknown group: %s", resultText(res))
+	}
+	if len(fake.Objects("Email")) != 0 {
+		t.Errorf("draft created despite errors")
+	}
+}
@@ -1,4 +1,4 @@
-package server
+package jmapmcp
 
 import (
 	"context"
@@ -24,14 +24,48 @@ func TokenFromContext(ctx context.Context) string {
 	return v
 }
 
+// TokenHeader carries the JMAP token when the Authorization header is used
+// for MCP server authentication.
+const TokenHeader = "X-JMAP-Token"
+
 // TokenMiddleware is HTTP middleware that extracts the JMAP auth token from
 // the request and stores it in the request context. It checks, in order:
 //  1. jmap_token query parameter
-//  2. Authorization: Bearer <token> header
+//  2. X-JMAP-Token header
+//  3. Authorization: Bearer <token> header
 func TokenMiddleware(next http.Handler) http.Handler {
+	return TokenSources{Query: true, Authorization: true}.Middleware(next)
+}
+
+// TokenSources selects where the JMAP token may be taken from. The
+// X-JMAP-Token header is always accepted.
+type TokenSources struct {
+	// Query accepts the jmap_token query parameter. When false, requests
+	// carrying it are rejected so the token is not silently ignored after
+	// already appearing in proxy and access logs.
+	Query bool
+	// Authorization accepts Authorization: Bearer. It must be false when the
+	// header authenticates the caller to the MCP server itself.
+	Authorization bool
+}
+
+// Middleware returns TokenMiddleware restricted to the enabled sources. A
+// token already bound to the context by a CredentialStore is kept.
+func (ts TokenSources) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var token string
-		if token = r.URL.Query().Get("jmap_token"); token == "" {
+		token := r.URL.Query().Get("jmap_token")
+		if token != "" && !ts.Query {
+			http.Error(w, "jmap_token query parameter is disabled; send the JMAP token in the "+TokenHeader+" header", http.StatusBadRequest)
+			return
+		}
+		if TokenFromContext(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token == "" {
+			token = r.Header.Get(TokenHeader)
+		}
+		if token == "" && ts.Authorization {
 			if v := r.Header.Get("Authorization"); v != "" {
 				token, _ = strings.CutPrefix(v, "Bearer ")
 			}
@@ -0,0 +1,86 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// continuationTTL is how long an unused email_get continuation is kept.
+	continuationTTL = 30 * time.Minute
+	// maxContinuations bounds the continuations of all callers; the least
+	// recently made of the tenant holding the most is dropped to make room.
+	maxContinuations = 100
+)
+
+// continuation is the rest of an email_get batch that did not fit in
+// max_chars: the emails still to return, with the options of the first
+// call, and how much of the first one's body earlier segments returned.
+// Owner is a hash of the JMAP token, as for batch cursors.
+type continuation struct {
+	ID       string
+	Owner    string
+	Endpoint string
+	In       EmailGetInput // EmailIDs holds the emails left
+	Skip     int           // body bytes of In.EmailIDs[0] already returned
+	Made     time.Time
+}
+
+// continuations holds the open continuations of email_get.
+type continuations struct {
+	mu  sync.Mutex
+	seq int
+	m   map[string]*continuation
+}
+
+// add registers c under a new ID, dropping expired continuations and, when
+// full, one picked by evictionVictim.
+func (cs *continuations) add(c *continuation, now time.Time) string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.m == nil {
+		cs.m = make(map[string]*continuation)
+	}
+	for id, e := range cs.m {
+		if now.Sub(e.Made) > continuationTTL {
+			delete(cs.m, id)
+		}
+	}
+	if len(cs.m) >= maxContinuations {
+		delete(cs.m, evictionVictim(cs.m,
+			func(e *continuation) string { return e.Owner },
+			func(e *continuation) time.Time { return e.Made }))
+	}
+	cs.seq++
+	c.ID = fmt.Sprintf("seg-%d", cs.seq)
+	c.Made = now
+	cs.m[c.ID] = c
+	return c.ID
+}
+
+// take removes and returns owner's continuation id; each segment is
+// returned once.
+func (cs *continuations) take(owner, id string, now time.Time) (*continuation, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	c, ok := cs.m[id]
+	if !ok || c.Owner != owner || now.Sub(c.Made) > continuationTTL {
+		return nil, notFoundError([]string{id}, "no open continuation %q (it was used, or expired after %s); call email_get with the email IDs again", id, continuationTTL)
+	}
+	delete(cs.m, id)
+	return c, nil
+}
+
+// resumeBody returns body after the first skip bytes, moved forward to a
+// character boundary.
+func resumeBody(body string, skip int) string {
+	if skip >= len(body) {
+		return ""
+	}
+	for skip < len(body) && !utf8.RuneStart(body[skip]) {
+		skip++
+	}
+	return body[skip:]
+}
@@ -0,0 +1,189 @@
+package jmapmcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Permission limits which tools a credential may call.
+type Permission string
+
+const (
+	// PermissionFull allows every registered tool.
+	PermissionFull Permission = "full"
+	// PermissionNoSend allows everything except tools that deliver mail.
+	PermissionNoSend Permission = "no-send"
+	// PermissionReadOnly allows only tools annotated read-only.
+	PermissionReadOnly Permission = "read-only"
+)
+
+// sendingTools deliver mail to recipients and are refused under
+// PermissionNoSend.
+var sendingTools = map[string]bool{
+	"email_submission_set": true,
+	"outbox_approve":       true,
+	"mail_merge":           true,
+	"calendar_rsvp":        true,
+	"email_report_abuse":   true,
+	"respond_and_file":     true,
+}
+
+var permissionKey = contextKey{"permission"}
+
+// checkMaySend refuses, under PermissionNoSend, setting up what (such as
+// "forward automations") that later carries mail out of the account on the
+// credential's behalf, so sendingTools cannot be bypassed by deferring the
+// send.
+func checkMaySend(ctx context.Context, what string) error {
+	if p := PermissionFromContext(ctx); p == PermissionNoSend {
+		return &policyError{
+			Policy: "permission",
+			Rule:   string(p),
+			Detail: fmt.Sprintf("%s are not allowed for this credential (%s)", what, p),
+		}
+	}
+	return nil
+}
+
+// ContextWithPermission returns a new context restricting tool calls to p.
+func ContextWithPermission(ctx context.Context, p Permission) context.Context {
+	return context.WithValue(ctx, permissionKey, p)
+}
+
+// PermissionFromContext returns the permission bound to ctx, or
+// PermissionFull when none is.
+func PermissionFromContext(ctx context.Context) Permission {
+	if p, ok := ctx.Value(permissionKey).(Permission); ok {
+		return p
+	}
+	return PermissionFull
+}
+
+// allows reports whether p permits calling t.
+func (p Permission) allows(t *mcp.Tool) bool {
+	switch p {
+	case PermissionReadOnly:
+		return t.Annotations != nil && t.Annotations.ReadOnlyHint
+	case PermissionNoSend:
+		return !sendingTools[t.Name]
+	default:
+		return true
+	}
+}
+
+// withPermission wraps h to refuse calls the context's permission forbids.
+func withPermission[In any](t *mcp.Tool, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		if p := PermissionFromContext(ctx); !p.allows(t) {
+			return errorResult(&policyError{
+				Policy: "permission",
+				Rule:   string(p),
+				Detail: fmt.Sprintf("tool %s is not allowed for this credential (%s)", t.Name, p),
+			}), nil, nil
+		}
+		return h(ctx, req, in)
+	}
+}
+
+// Credential maps an operator-issued MCP key to a server-held JMAP token.
+type Credential struct {
+	Name string `json:"name"`
+	// Key is the MCP key in clear text; KeySHA256 its hex SHA-256 digest.
+	// Exactly one must be set. Prefer KeySHA256 so the file does not hold
+	// usable MCP keys.
+	Key        string     `json:"key,omitempty"`
+	KeySHA256  string     `json:"key_sha256,omitempty"`
+	JMAPToken  string     `json:"jmap_token"`
+	Permission Permission `json:"permission,omitempty"`
+}
+
+// CredentialStore authenticates MCP keys and binds the mapped JMAP token and
+// permission to the request, so clients never handle mailbox credentials.
+type CredentialStore struct {
+	entries []credentialEntry
+}
+
+type credentialEntry struct {
+	digest     [sha256.Size]byte
+	jmapToken  string
+	permission Permission
+}
+
+// LoadCredentialStore reads a JSON credential file of the form
+// {"credentials": [Credential, ...]}.
+func LoadCredentialStore(path string) (*CredentialStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("credential store: %w", err)
+	}
+	var file struct {
+		Credentials []Credential `json:"credentials"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("credential store %s: %w", path, err)
+	}
+	return NewCredentialStore(file.Credentials)
+}
+
+// NewCredentialStore validates creds and returns a store for them.
+func NewCredentialStore(creds []Credential) (*CredentialStore, error) {
+	cs := &CredentialStore{}
+	for i, c := range creds {
+		label := c.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		var digest [sha256.Size]byte
+		switch {
+		case c.Key != "" && c.KeySHA256 != "":
+			return nil, fmt.Errorf("credential %s: set only one of key and key_sha256", label)
+		case c.Key != "":
+			digest = sha256.Sum256([]byte(c.Key))
+		case c.KeySHA256 != "":
+			b, err := hex.DecodeString(c.KeySHA256)
+			if err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("credential %s: key_sha256 must be 64 hex characters", label)
+			}
+			copy(digest[:], b)
+		default:
+			return nil, fmt.Errorf("credential %s: key or key_sha256 is required", label)
+		}
+		if c.JMAPToken == "" {
+			return nil, fmt.Errorf("credential %s: jmap_token is required", label)
+		}
+		p := c.Permission
+		switch p {
+		case "":
+			p = PermissionFull
+		case PermissionFull, PermissionNoSend, PermissionReadOnly:
+		default:
+			return nil, fmt.Errorf("credential %s: unknown permission %q (want %s, %s, or %s)", label, p, PermissionFull, PermissionNoSend, PermissionReadOnly)
+		}
+		cs.entries = append(cs.entries, credentialEntry{digest: digest, jmapToken: c.JMAPToken, permission: p})
+	}
+	return cs, nil
+}
+
+// Authenticate implements Authenticator. The returned context carries the
+// mapped JMAP token, which TokenSources will not override.
+func (cs *CredentialStore) Authenticate(ctx context.Context, credential string) (context.Context, error) {
+	sum := sha256.Sum256([]byte(credential))
+	var found *credentialEntry
+	for i := range cs.entries {
+		if subtle.ConstantTimeCompare(sum[:], cs.entries[i].digest[:]) == 1 && found == nil {
+			found = &cs.entries[i]
+		}
+	}
+	if found == nil {
+		return nil, errUnauthenticated
+	}
+	ctx = ContextWithToken(ctx, found.jmapToken)
+	return ContextWithPermission(ctx, found.permission), nil
+}
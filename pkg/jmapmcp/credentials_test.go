@@ -0,0 +1,115 @@
+package jmapmcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestNewCredentialStore(t *testing.T) {
+	digest := sha256.Sum256([]byte("hashed-key"))
+	tests := []struct {
+		name    string
+		creds   []Credential
+		wantErr bool
+	}{
+		{"plain key", []Credential{{Key: "k", JMAPToken: "t"}}, false},
+		{"hashed key", []Credential{{KeySHA256: hex.EncodeToString(digest[:]), JMAPToken: "t", Permission: PermissionReadOnly}}, false},
+		{"both keys", []Credential{{Key: "k", KeySHA256: hex.EncodeToString(digest[:]), JMAPToken: "t"}}, true},
+		{"no key", []Credential{{JMAPToken: "t"}}, true},
+		{"bad digest", []Credential{{KeySHA256: "abc", JMAPToken: "t"}}, true},
+		{"no token", []Credential{{Key: "k"}}, true},
+		{"bad permission", []Credential{{Key: "k", JMAPToken: "t", Permission: "admin"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCredentialStore(tt.creds)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewCredentialStore() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCredentialStoreAuthenticate(t *testing.T) {
+	digest := sha256.Sum256([]byte("reader-key"))
+	cs, err := NewCredentialStore([]Credential{
+		{Name: "agent", Key: "agent-key", JMAPToken: "agent-token", Permission: PermissionNoSend},
+		{Name: "reader", KeySHA256: hex.EncodeToString(digest[:]), JMAPToken: "reader-token", Permission: PermissionReadOnly},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key       string
+		wantToken string
+		wantPerm  Permission
+		wantErr   bool
+	}{
+		{"agent-key", "agent-token", PermissionNoSend, false},
+		{"reader-key", "reader-token", PermissionReadOnly, false},
+		{"agent-token", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			ctx, err := cs.Authenticate(context.Background(), tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := TokenFromContext(ctx); got != tt.wantToken {
+				t.Errorf("token = %q, want %q", got, tt.wantToken)
+			}
+			if got := PermissionFromContext(ctx); got != tt.wantPerm {
+				t.Errorf("permission = %q, want %q", got, tt.wantPerm)
+			}
+		})
+	}
+}
+
+func TestCredentialTokenNotOverridden(t *testing.T) {
+	cs, err := NewCredentialStore([]Credential{{Key: "k", JMAPToken: "stored"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	h := AuthMiddleware(TokenSources{Query: true}.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = TokenFromContext(r.Context())
+	})), cs)
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp?jmap_token=client", nil)
+	req.Header.Set("Authorization", "Bearer k")
+	req.Header.Set(TokenHeader, "client")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "stored" {
+		t.Errorf("token = %q, want stored", got)
+	}
+}
+
+func TestPermissionAllows(t *testing.T) {
+	tests := []struct {
+		perm Permission
+		tool *mcp.Tool
+		want bool
+	}{
+		{PermissionFull, emailSubmissionSetTool, true},
+		{PermissionNoSend, emailSubmissionSetTool, false},
+		{PermissionNoSend, outboxApproveTool, false},
+		{PermissionNoSend, emailCreateTool, true},
+		{PermissionReadOnly, emailCreateTool, false},
+		{PermissionReadOnly, emailGetTool, true},
+	}
+	for _, tt := range tests {
+		if got := tt.perm.allows(tt.tool); got != tt.want {
+			t.Errorf("%s.allows(%s) = %v, want %v", tt.perm, tt.tool.Name, got, tt.want)
+		}
+	}
+}
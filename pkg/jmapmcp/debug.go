@@ -0,0 +1,211 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// JMAP debugging (-debug-jmap). The method calls a tool call sends and the
+// responses it receives are recorded verbatim, so a Set the server rejects
+// for unclear reasons can be inspected. Only API request and response
+// bodies are recorded: headers, and with them the Authorization header,
+// the session resource, uploads, and downloads are not.
+
+// DebugJMAP selects where recorded JMAP exchanges go.
+type DebugJMAP string
+
+const (
+	// DebugJMAPOff records nothing.
+	DebugJMAPOff DebugJMAP = ""
+	// DebugJMAPLog writes every tool call's exchanges to the server log.
+	DebugJMAPLog DebugJMAP = "log"
+	// DebugJMAPResult appends every tool call's exchanges to its result.
+	DebugJMAPResult DebugJMAP = "result"
+	// DebugJMAPCall gives tools a "debug" parameter that appends the
+	// exchanges to that call's result. Only credentials with full
+	// permission may set it.
+	DebugJMAPCall DebugJMAP = "call"
+)
+
+// ParseDebugJMAP validates a -debug-jmap value; "off" is DebugJMAPOff.
+func ParseDebugJMAP(v string) (DebugJMAP, error) {
+	switch m := DebugJMAP(v); m {
+	case "off":
+		return DebugJMAPOff, nil
+	case DebugJMAPOff, DebugJMAPLog, DebugJMAPResult, DebugJMAPCall:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown -debug-jmap mode %q (want off, log, result, or call)", v)
+}
+
+// debugBodyLimit truncates each recorded body, so that fetching large
+// emails in debug mode does not multiply the result size.
+const debugBodyLimit = 32 << 10
+
+// jmapExchange is one recorded request and its response.
+type jmapExchange struct {
+	Transport string `json:"transport"` // "http" or "websocket"
+	Status    int    `json:"status,omitempty"`
+	Request   string `json:"request"`
+	Response  string `json:"response,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// jmapTrace collects the exchanges of one tool call.
+type jmapTrace struct {
+	mu        sync.Mutex
+	exchanges []jmapExchange
+}
+
+func (t *jmapTrace) add(e jmapExchange) {
+	e.Request = truncateDebugBody(e.Request)
+	e.Response = truncateDebugBody(e.Response)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exchanges = append(t.exchanges, e)
+}
+
+func (t *jmapTrace) list() []jmapExchange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]jmapExchange(nil), t.exchanges...)
+}
+
+func truncateDebugBody(s string) string {
+	if len(s) <= debugBodyLimit {
+		return s
+	}
+	n := debugBodyLimit
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s… (%d bytes truncated)", s[:n], len(s)-n)
+}
+
+var jmapTraceKey = contextKey{"jmap-trace"}
+
+func jmapTraceFromContext(ctx context.Context) *jmapTrace {
+	t, _ := ctx.Value(jmapTraceKey).(*jmapTrace)
+	return t
+}
+
+// withJMAPDebug records the JMAP exchanges of calls of h as the server's
+// debug mode directs.
+func withJMAPDebug[In any](s *Server, t *mcp.Tool, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	if s.debugJMAP == DebugJMAPOff {
+		return h
+	}
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		attach := s.debugJMAP == DebugJMAPResult
+		if s.debugJMAP == DebugJMAPCall {
+			var sel struct {
+				Debug bool `json:"debug"`
+			}
+			if req != nil && req.Params != nil && len(req.Params.Arguments) > 0 {
+				_ = json.Unmarshal(req.Params.Arguments, &sel)
+			}
+			if !sel.Debug {
+				return h(ctx, req, in)
+			}
+			if p := PermissionFromContext(ctx); p != PermissionFull {
+				return errorResult(&toolError{
+					Code:    codeForbidden,
+					Message: fmt.Sprintf("debug requires a credential with %s permission (this one has %s)", PermissionFull, p),
+				}), nil, nil
+			}
+			attach = true
+		}
+
+		trace := &jmapTrace{}
+		res, out, err := h(context.WithValue(ctx, jmapTraceKey, trace), req, in)
+		exchanges := trace.list()
+		if len(exchanges) == 0 {
+			return res, out, err
+		}
+		if !attach {
+			for i, e := range exchanges {
+				log.Printf("jmap debug: %s %d/%d: %s", t.Name, i+1, len(exchanges), formatExchange(e))
+			}
+			return res, out, err
+		}
+		if res != nil {
+			res.Content = append(res.Content, &mcp.TextContent{Text: formatJMAPTrace(exchanges)})
+		}
+		return res, out, err
+	}
+}
+
+// formatJMAPTrace renders exchanges for a tool result.
+func formatJMAPTrace(exchanges []jmapExchange) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "JMAP debug: %d request(s)", len(exchanges))
+	for i, e := range exchanges {
+		fmt.Fprintf(&sb, "\n\n[%d] %s", i+1, formatExchange(e))
+	}
+	return sb.String()
+}
+
+func formatExchange(e jmapExchange) string {
+	head := e.Transport
+	if e.Status != 0 {
+		head += fmt.Sprintf(" %d", e.Status)
+	}
+	text := fmt.Sprintf("%s\n> %s", head, e.Request)
+	if e.Response != "" {
+		text += "\n< " + e.Response
+	}
+	if e.Error != "" {
+		text += "\n! " + e.Error
+	}
+	return text
+}
+
+// debugTransport records the bodies of requests to the JMAP API URL and of
+// their responses.
+type debugTransport struct {
+	base   http.RoundTripper
+	apiURL string
+	trace  *jmapTrace
+}
+
+func (t debugTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodPost || r.URL.String() != t.apiURL || r.Body == nil {
+		return t.base.RoundTrip(r)
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	e := jmapExchange{Transport: "http", Request: string(body)}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		e.Error = err.Error()
+		t.trace.add(e)
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	e.Status = resp.StatusCode
+	e.Response = string(data)
+	if err != nil {
+		e.Error = err.Error()
+		t.trace.add(e)
+		return nil, err
+	}
+	t.trace.add(e)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
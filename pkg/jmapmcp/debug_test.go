@@ -0,0 +1,78 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// debugRequest returns a tool call request with the given arguments.
+func debugRequest(args string) *mcp.CallToolRequest {
+	return &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "email_query", Arguments: json.RawMessage(args)}}
+}
+
+func TestDebugJMAPResult(t *testing.T) {
+	s, fake := newFakeServer(t, WithDebugJMAP(DebugJMAPResult))
+	addEmail(fake, "e1", "Hello", "body", 1)
+	h := withJMAPDebug(s, emailQueryTool, s.handleEmailQuery)
+
+	res, _, _ := h(context.Background(), debugRequest(`{}`), EmailQueryInput{})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	out := resultText(res)
+	if !strings.Contains(out, "JMAP debug: 1 request(s)") || !strings.Contains(out, `> {"using"`) || !strings.Contains(out, `"Email/query"`) {
+		t.Errorf("result lacks the JMAP exchange:\n%s", out)
+	}
+	if strings.Contains(out, "Bearer") || strings.Contains(out, "Authorization") {
+		t.Errorf("result leaks auth:\n%s", out)
+	}
+}
+
+func TestDebugJMAPCall(t *testing.T) {
+	s, fake := newFakeServer(t, WithDebugJMAP(DebugJMAPCall))
+	addEmail(fake, "e1", "Hello", "body", 1)
+	h := withJMAPDebug(s, emailQueryTool, s.handleEmailQuery)
+	ctx := context.Background()
+
+	res, _, _ := h(ctx, debugRequest(`{}`), EmailQueryInput{})
+	if strings.Contains(resultText(res), "JMAP debug") {
+		t.Errorf("exchanges attached without debug:\n%s", resultText(res))
+	}
+
+	res, _, _ = h(ctx, debugRequest(`{"debug":true}`), EmailQueryInput{})
+	if !strings.Contains(resultText(res), "JMAP debug: 1 request(s)") {
+		t.Errorf("debug call lacks the exchange:\n%s", resultText(res))
+	}
+
+	res, _, _ = h(ContextWithPermission(ctx, PermissionReadOnly), debugRequest(`{"debug":true}`), EmailQueryInput{})
+	if !res.IsError || !strings.Contains(resultText(res), "full permission") {
+		t.Errorf("read-only debug call = %s, want refusal", resultText(res))
+	}
+}
+
+func TestDebugJMAPWebSocket(t *testing.T) {
+	s, fake := newFakeServer(t, WithDebugJMAP(DebugJMAPResult))
+	fake.EnableWebSocket()
+	addEmail(fake, "e1", "Hello", "body", 1)
+	h := withJMAPDebug(s, emailQueryTool, s.handleEmailQuery)
+
+	res, _, _ := h(context.Background(), debugRequest(`{}`), EmailQueryInput{})
+	if out := resultText(res); !strings.Contains(out, "[1] websocket\n") || !strings.Contains(out, `"Email/query"`) {
+		t.Errorf("result lacks the WebSocket exchange:\n%s", out)
+	}
+}
+
+func TestTruncateDebugBody(t *testing.T) {
+	s := strings.Repeat("é", debugBodyLimit)
+	got := truncateDebugBody(s)
+	if !strings.HasSuffix(got, "bytes truncated)") || !strings.HasPrefix(got, "éé") {
+		t.Errorf("truncated = …%s", got[len(got)-40:])
+	}
+	if strings.ContainsRune(got, '�') {
+		t.Error("truncation split a rune")
+	}
+}
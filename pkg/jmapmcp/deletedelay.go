@@ -0,0 +1,193 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Staged permanent deletion: with -delete-delay, email_delete with
+// permanent=true moves the emails to Trash at once and leaves the destroy
+// to a one-off schedule (kind destroy) due after the delay, so a mistaken
+// call can still be taken back with email_delete_cancel. The schedule
+// runs like any other (-run-automations or -mode daemon) and destroys
+// only the emails still in Trash and nowhere else; one restored or moved
+// out in the meantime is kept. The caller cannot skip the delay: it
+// protects against the agent, not just the user.
+
+// WithDeleteDelay stages permanent email deletion: emails go to Trash and
+// are destroyed by a schedule after d. Zero destroys at once.
+func WithDeleteDelay(d time.Duration) Option {
+	return func(s *Server) { s.deleteDelay = d }
+}
+
+// stageEmailDestroy moves the emails of in to Trash and schedules their
+// destruction after s.deleteDelay.
+func (s *Server) stageEmailDestroy(ctx context.Context, req *mcp.CallToolRequest, client JMAPClient, accountID jmap.ID, in EmailDeleteInput) (*mcp.CallToolResult, any, error) {
+	mailboxes, emails, err := fetchMoveRights(ctx, client, accountID, in.EmailIDs)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	trashID, err := mailboxWithRole(mailboxes, mailbox.RoleTrash)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if err := checkMoveRights(mailboxes, emails, trashID); err != nil {
+		return errorResult(err), nil, nil
+	}
+	gated := in
+	gated.Selection, gated.Confirm = "", ""
+	summary := fmt.Sprintf("permanently destroy %d email(s) after %s in Trash: %s", len(in.EmailIDs), s.deleteDelay, listSome(in.EmailIDs))
+	if res := s.confirmDestructive(ctx, "email_delete", gated, in.Confirm, summary); res != nil {
+		return res, nil, nil
+	}
+
+	trash := jmap.Patch{"mailboxIds": map[string]bool{string(trashID): true}}
+	set, _, err := s.bulkSet(ctx, client, accountID, toJMAPIDSlice(in.EmailIDs), func(jmap.ID) jmap.Patch { return trash }, nil)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	s.recordTombstones(ctx, emails, trashID, set.NotUpdated)
+	s.journal(ctx, req, &journalEntry{tool: "email_delete", accountID: accountID, mailboxes: priorMailboxes(emails)}, set.NotUpdated)
+	var moved []jmap.ID
+	for _, id := range toJMAPIDSlice(in.EmailIDs) {
+		if _, failed := set.NotUpdated[id]; !failed {
+			moved = append(moved, id)
+		}
+	}
+	failed := setFailures("trash failed", set.NotUpdated)
+	if len(moved) == 0 {
+		return errorResult(failed), nil, nil
+	}
+
+	now := time.Now().UTC()
+	a := &scheduledAction{
+		Name:      "email_delete",
+		Kind:      scheduleDestroy,
+		Next:      now.Add(s.deleteDelay),
+		EmailIDs:  moved,
+		MailboxID: trashID,
+		Created:   now,
+	}
+	user := client.Session().Username
+	if err := s.schedules.add(user, a); err != nil {
+		return errorResult(fmt.Errorf("moved %d email(s) to Trash, but scheduling their destruction failed: %w", len(moved), err)), nil, nil
+	}
+	text := fmt.Sprintf("Moved %d email(s) to Trash; they are permanently destroyed %s (schedule %s). Until then, email_delete_cancel with schedule_id %s keeps them and moves them back.",
+		len(moved), s.locale.dateTime(a.Next), a.ID, a.ID)
+	if failed != nil {
+		text += "\nNot moved: " + failed.Error()
+	}
+	if !s.schedules.runningFor(user) {
+		text += "\n" + schedulesNotRunning
+	}
+	return textResult(text), nil, nil
+}
+
+// destroyTrashed permanently destroys those of ids that are only in
+// trashID, leaving emails that were restored, moved, or are already gone.
+func (s *Server) destroyTrashed(ctx context.Context, trashID jmap.ID, ids []jmap.ID) error {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return err
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return fmt.Errorf("no primary mail account")
+	}
+	var trashed []jmap.ID
+	for chunk := range slices.Chunk(ids, maxCleanupPerRun) {
+		req := &jmap.Request{Context: ctx}
+		req.Invoke(&email.Get{Account: accountID, IDs: chunk, Properties: []string{"id", "mailboxIds"}})
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		if len(resp.Responses) == 0 {
+			return fmt.Errorf("empty response for Email/get")
+		}
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.GetResponse:
+			for _, e := range args.List {
+				if len(e.MailboxIDs) == 1 && e.MailboxIDs[trashID] {
+					trashed = append(trashed, e.ID)
+				}
+			}
+		case *jmap.MethodError:
+			return args
+		default:
+			return fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+	if len(trashed) == 0 {
+		return nil
+	}
+	set, _, err := s.bulkSet(ctx, client, accountID, trashed, nil, nil)
+	if err != nil {
+		return err
+	}
+	return setFailures("destroy failed", set.NotDestroyed)
+}
+
+// --- email_delete_cancel ---
+
+type EmailDeleteCancelInput struct {
+	ScheduleID  string `json:"schedule_id" jsonschema:"Schedule ID email_delete returned for a staged permanent deletion (schedule_list lists them)"`
+	KeepInTrash bool   `json:"keep_in_trash,omitempty" jsonschema:"Only cancel the destruction and leave the emails in Trash, instead of moving them back where they were"`
+}
+
+var emailDeleteCancelTool = &mcp.Tool{
+	Name:        "email_delete_cancel",
+	Description: "Take back a permanent email_delete while it waits in Trash: cancel the scheduled destruction and move the emails back to the mailboxes they came from (as email_restore does), or leave them in Trash with keep_in_trash.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailDeleteCancel(ctx context.Context, req *mcp.CallToolRequest, in EmailDeleteCancelInput) (*mcp.CallToolResult, any, error) {
+	if in.ScheduleID == "" {
+		return errorResult(invalidArgument("schedule_id is required")), nil, nil
+	}
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	user := client.Session().Username
+	list, err := s.schedules.list(user)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	i := slices.IndexFunc(list, func(a scheduledAction) bool { return a.ID == in.ScheduleID })
+	if i < 0 {
+		return errorResult(notFoundError([]string{in.ScheduleID}, "no schedule %q; it may have run already", in.ScheduleID)), nil, nil
+	}
+	a := list[i]
+	if a.Kind != scheduleDestroy {
+		return errorResult(invalidArgument("schedule %s is not a staged deletion; cancel it with schedule_cancel", a.ID)), nil, nil
+	}
+	removed, err := s.schedules.remove(user, a.ID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if !removed {
+		return errorResult(notFoundError([]string{a.ID}, "schedule %s ran or was cancelled meanwhile", a.ID)), nil, nil
+	}
+	text := fmt.Sprintf("Cancelled the permanent deletion of %d email(s) (%s)", len(a.EmailIDs), a.ID)
+	if in.KeepInTrash {
+		return textResult(text + "; they stay in Trash"), nil, nil
+	}
+	ids := make([]string, len(a.EmailIDs))
+	for i, id := range a.EmailIDs {
+		ids[i] = string(id)
+	}
+	res, _, _ := s.handleEmailRestore(ctx, req, EmailRestoreInput{EmailIDs: ids})
+	if err := toolResultError(res, nil, nil); err != nil {
+		return errorResult(fmt.Errorf("%s, but moving them back failed (they stay in Trash): %w", text, err)), nil, nil
+	}
+	return textResult(text + ". " + resultTextOf(res)), nil, nil
+}
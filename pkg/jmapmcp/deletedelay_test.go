@@ -0,0 +1,78 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteDelay(t *testing.T) {
+	s, fake := newFakeServer(t, WithDeleteDelay(time.Hour))
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	for i, id := range []string{"e1", "e2", "e3"} {
+		addEmail(fake, id, "Note "+id, "Body", i+1)
+	}
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1", "e2"}, Permanent: true})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Moved 2 email(s) to Trash") || !strings.Contains(out, "email_delete_cancel") {
+		t.Fatalf("staged delete:\n%s", out)
+	}
+	list, _ := s.schedules.list("test@example.org")
+	if len(list) != 1 || list[0].Kind != scheduleDestroy || time.Until(list[0].Next) < 59*time.Minute {
+		t.Fatalf("schedules = %+v, want one destroy due in an hour", list)
+	}
+	if mbs := fake.Object("Email", "e1")["mailboxIds"].(map[string]any); mbs["trash"] != true || mbs["inbox"] != nil {
+		t.Errorf("e1 mailboxIds = %v, want Trash only", mbs)
+	}
+
+	// e2 is restored before the schedule runs, so only e1 is destroyed.
+	if res, _, _ := s.handleEmailRestore(ctx, nil, EmailRestoreInput{EmailIDs: []string{"e2"}}); res.IsError {
+		t.Fatalf("email_restore: %s", resultText(res))
+	}
+	if err := s.runScheduled(ctx, &list[0]); err != nil {
+		t.Fatalf("running the destroy: %v", err)
+	}
+	if fake.Object("Email", "e1") != nil || fake.Object("Email", "e2") == nil {
+		t.Errorf("destroy kept e1 or took e2: %v, %v", fake.Object("Email", "e1"), fake.Object("Email", "e2"))
+	}
+
+	if res, _, _ := s.handleScheduleCreate(ctx, nil, ScheduleCreateInput{Kind: scheduleDestroy, EmailIDs: []string{"e3"}, MailboxID: "trash"}); !res.IsError || !strings.Contains(resultText(res), "kind must be send, wake, digest, or cleanup") {
+		t.Errorf("schedule_create made a destroy schedule: %s", resultText(res))
+	}
+}
+
+func TestEmailDeleteCancel(t *testing.T) {
+	s, fake := newFakeServer(t, WithDeleteDelay(time.Hour))
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	addEmail(fake, "e1", "Note", "Body", 1)
+	ctx := context.Background()
+
+	if res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}, Permanent: true}); res.IsError {
+		t.Fatalf("email_delete: %s", resultText(res))
+	}
+	list, _ := s.schedules.list("test@example.org")
+	if len(list) != 1 {
+		t.Fatalf("schedules = %+v, want one", list)
+	}
+
+	res, _, _ := s.handleEmailDeleteCancel(ctx, nil, EmailDeleteCancelInput{ScheduleID: list[0].ID})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Cancelled the permanent deletion of 1 email(s)") || !strings.Contains(out, "e1: restored to Inbox") {
+		t.Fatalf("email_delete_cancel:\n%s", out)
+	}
+	if list, _ := s.schedules.list("test@example.org"); len(list) != 0 {
+		t.Errorf("schedules after cancelling = %+v", list)
+	}
+	if mbs := fake.Object("Email", "e1")["mailboxIds"].(map[string]any); mbs["inbox"] != true || mbs["trash"] != nil {
+		t.Errorf("e1 mailboxIds = %v, want Inbox only", mbs)
+	}
+
+	res, _, _ = s.handleEmailDeleteCancel(ctx, nil, EmailDeleteCancelInput{ScheduleID: list[0].ID})
+	if !res.IsError {
+		t.Errorf("cancelling twice succeeded: %s", resultText(res))
+	}
+}
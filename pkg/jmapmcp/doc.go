@@ -0,0 +1,27 @@
+// Package jmapmcp is the jmap-mcp server: an MCP server whose tools,
+// resources, and automations work on a mailbox over JMAP (RFC 8620, RFC
+// 8621). The jmap-mcp binary is a thin main around it, and other Go
+// programs can embed it the same way instead of running the binary.
+//
+// NewServer builds a server for a JMAP session URL; its options configure
+// every subsystem: the credential (WithToken, WithEndpoint), limits and
+// caching (WithQueryLimit, WithMaxChars, WithTenantQuota), sending and its
+// guardrails (WithEmailSubmission, WithSendQueue, WithSendPolicy,
+// WithPreSendHook), and saved state (WithAutomations, WithSchedules, and
+// the other Load functions, which read their documents from a Store). MCP
+// returns the underlying *mcp.Server for any go-sdk transport:
+//
+//	srv := jmapmcp.NewServer("1.0", "https://api.fastmail.com/jmap/session",
+//		jmapmcp.WithToken(os.Getenv("JMAP_AUTH_TOKEN")),
+//		jmapmcp.WithSendPolicy(jmapmcp.SendPolicy{MaxRecipients: 10}),
+//	)
+//	err := srv.MCP().Run(ctx, &mcp.StdioTransport{})
+//
+// AddTool registers tools of the embedding program next to the built-in
+// ones, with the same permission, ID, and endpoint handling; their handlers
+// reach the mailbox of the calling credential through Server.Client.
+//
+// In HTTP mode, wrap the handler from mcp.NewStreamableHTTPHandler in
+// TokenSources.Middleware (and AuthMiddleware when MCP clients must
+// authenticate), as the jmap-mcp binary does.
+package jmapmcp
@@ -0,0 +1,95 @@
+package jmapmcp
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+)
+
+// Draft origins: mail clients set $answered on a message once a reply to
+// it is sent, and $forwarded once it is forwarded, which is what
+// email_awaiting_reply and other clients' "replied" icons go by.
+// email_reply and email_forward record here which email each draft they
+// create answers or forwards, per credential and endpoint, and
+// submitDraft sets the keyword on it once the draft is submitted. The
+// record is held in memory: a draft sent after a restart, or one composed
+// by another client, leaves its original unmarked.
+
+const (
+	maxDraftOrigins     = 1000 // per owner; the oldest are dropped first
+	draftOriginLifetime = 7 * 24 * time.Hour
+)
+
+// draftOrigin records the email a draft replies to or forwards.
+type draftOrigin struct {
+	endpoint string
+	draftID  jmap.ID
+	original jmap.ID
+	keyword  string // $answered or $forwarded
+	at       time.Time
+}
+
+type draftOrigins struct {
+	mu sync.Mutex
+	m  map[string][]*draftOrigin // owner → origins, oldest first
+}
+
+// record adds o to owner's log.
+func (d *draftOrigins) record(owner string, o *draftOrigin) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.m == nil {
+		d.m = make(map[string][]*draftOrigin)
+	}
+	cutoff := time.Now().Add(-draftOriginLifetime)
+	log := slices.DeleteFunc(d.m[owner], func(old *draftOrigin) bool {
+		return old.at.Before(cutoff) || old.endpoint == o.endpoint && old.draftID == o.draftID
+	})
+	log = append(log, o)
+	if len(log) > maxDraftOrigins {
+		log = slices.Delete(log, 0, len(log)-maxDraftOrigins)
+	}
+	d.m[owner] = log
+}
+
+// take removes and returns owner's origin of draftID on endpoint, or nil.
+func (d *draftOrigins) take(owner, endpoint string, draftID jmap.ID) *draftOrigin {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, o := range d.m[owner] {
+		if o.endpoint == endpoint && o.draftID == draftID {
+			d.m[owner] = slices.Delete(d.m[owner], i, i+1)
+			return o
+		}
+	}
+	return nil
+}
+
+// recordDraftOrigin notes that draftID, just created, answers or forwards
+// original, as keyword says.
+func (s *Server) recordDraftOrigin(ctx context.Context, draftID, original jmap.ID, keyword string) {
+	if draftID == "" {
+		return
+	}
+	s.draftOrigins.record(s.idOwner(ctx), &draftOrigin{
+		endpoint: endpointName(ctx),
+		draftID:  draftID,
+		original: original,
+		keyword:  keyword,
+		at:       time.Now(),
+	})
+}
+
+// markDraftOrigin sets the recorded keyword on the email draftID answers
+// or forwards, now that it was submitted. The draft is already sent, so a
+// failure here is not reported; the original may have been deleted since.
+func (s *Server) markDraftOrigin(ctx context.Context, client JMAPClient, accountID, draftID jmap.ID) {
+	o := s.draftOrigins.take(s.idOwner(ctx), endpointName(ctx), draftID)
+	if o == nil {
+		return
+	}
+	updateEmails(ctx, client, accountID, map[jmap.ID]jmap.Patch{o.original: {"keywords/" + o.keyword: true}}, "marking the original")
+}
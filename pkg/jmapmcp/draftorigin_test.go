@@ -0,0 +1,64 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap"
+)
+
+func TestDraftOriginMarkedOnSubmit(t *testing.T) {
+	s, fake := newFakeServer(t, WithEmailSubmission())
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	addEmail(fake, "e1", "Lunch", "Are you free Friday?", 1)
+	addEmail(fake, "e2", "Report", "Numbers attached.", 2)
+	ctx := context.Background()
+
+	draftID := func(out string) string {
+		_, rest, _ := strings.Cut(out, "[id: ")
+		id, _, _ := strings.Cut(rest, "]")
+		return id
+	}
+	res, _, _ := s.handleEmailReply(ctx, nil, EmailReplyInput{EmailID: "e1", Body: "Yes."})
+	reply := draftID(resultText(res))
+	res, _, _ = s.handleEmailForward(ctx, nil, EmailForwardInput{EmailID: "e2", To: []string{"carol@example.com"}})
+	forward := draftID(resultText(res))
+	if reply == "" || forward == "" {
+		t.Fatalf("drafts not created: %q, %q", reply, forward)
+	}
+	if kw := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["$answered"] != nil {
+		t.Fatalf("e1 marked answered before the reply was sent: %v", kw)
+	}
+
+	for _, id := range []string{reply, forward} {
+		if res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: id}); res.IsError {
+			t.Fatal(resultText(res))
+		}
+	}
+	if kw := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["$answered"] != true {
+		t.Errorf("e1 keywords = %v, want $answered", kw)
+	}
+	if kw := fake.Object("Email", "e2")["keywords"].(map[string]any); kw["$forwarded"] != true || kw["$answered"] != nil {
+		t.Errorf("e2 keywords = %v, want $forwarded", kw)
+	}
+}
+
+func TestDraftOriginsTake(t *testing.T) {
+	var d draftOrigins
+	d.record("alice", &draftOrigin{endpoint: "default", draftID: "d1", original: "e1", keyword: "$answered"})
+	if o := d.take("bob", "default", "d1"); o != nil {
+		t.Errorf("another owner took %+v", o)
+	}
+	if o := d.take("alice", "work", "d1"); o != nil {
+		t.Errorf("another endpoint took %+v", o)
+	}
+	if o := d.take("alice", "default", "d1"); o == nil || o.original != jmap.ID("e1") {
+		t.Errorf("take = %+v", o)
+	}
+	if o := d.take("alice", "default", "d1"); o != nil {
+		t.Errorf("second take = %+v", o)
+	}
+}
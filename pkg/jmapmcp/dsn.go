@@ -0,0 +1,137 @@
+package jmapmcp
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// dsnReport is a parsed message/delivery-status body (RFC 3464): one block
+// of per-message fields followed by one block per recipient.
+type dsnReport struct {
+	ReportingMTA       string
+	OriginalEnvelopeID string
+	ArrivalDate        string
+	Recipients         []dsnRecipient
+}
+
+// dsnRecipient holds the per-recipient fields of a DSN.
+type dsnRecipient struct {
+	FinalRecipient    string
+	OriginalRecipient string
+	Action            string // failed, delayed, delivered, relayed, expanded
+	Status            string // enhanced status code, e.g. 5.1.1
+	RemoteMTA         string
+	DiagnosticCode    string
+	LastAttemptDate   string
+}
+
+// parseDSN parses a delivery-status body. Field blocks are separated by blank
+// lines; folded continuation lines are joined. Type prefixes such as "rfc822;"
+// or "dns;" are stripped from address and MTA fields.
+func parseDSN(text string) *dsnReport {
+	var blocks []map[string]string
+	cur := map[string]string{}
+	var lastKey string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			if len(cur) > 0 {
+				blocks = append(blocks, cur)
+				cur = map[string]string{}
+			}
+			lastKey = ""
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && lastKey != "" {
+			cur[lastKey] += " " + strings.TrimSpace(line)
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		lastKey = strings.ToLower(strings.TrimSpace(key))
+		cur[lastKey] = strings.TrimSpace(value)
+	}
+	if len(cur) > 0 {
+		blocks = append(blocks, cur)
+	}
+
+	report := &dsnReport{}
+	for _, b := range blocks {
+		if _, ok := b["final-recipient"]; ok {
+			report.Recipients = append(report.Recipients, dsnRecipient{
+				FinalRecipient:    stripDSNType(b["final-recipient"]),
+				OriginalRecipient: stripDSNType(b["original-recipient"]),
+				Action:            strings.ToLower(b["action"]),
+				Status:            b["status"],
+				RemoteMTA:         stripDSNType(b["remote-mta"]),
+				DiagnosticCode:    stripDSNType(b["diagnostic-code"]),
+				LastAttemptDate:   b["last-attempt-date"],
+			})
+			continue
+		}
+		if v, ok := b["reporting-mta"]; ok {
+			report.ReportingMTA = stripDSNType(v)
+		}
+		if v, ok := b["original-envelope-id"]; ok {
+			report.OriginalEnvelopeID = v
+		}
+		if v, ok := b["arrival-date"]; ok {
+			report.ArrivalDate = v
+		}
+	}
+	return report
+}
+
+// stripDSNType removes the "type;" prefix of DSN address and MTA fields
+// (e.g. "rfc822; user@example.com" → "user@example.com").
+func stripDSNType(v string) string {
+	if _, rest, ok := strings.Cut(v, ";"); ok {
+		return strings.TrimSpace(rest)
+	}
+	return v
+}
+
+// dsnStatusClasses describes the class digit of an enhanced status code
+// (RFC 3463 §3.1).
+var dsnStatusClasses = map[byte]string{
+	'2': "success",
+	'4': "temporary failure",
+	'5': "permanent failure",
+}
+
+// dsnStatusSubjects describes common subject.detail pairs (RFC 3463 §3.3+).
+var dsnStatusSubjects = map[string]string{
+	"1.1":  "bad destination mailbox address (user unknown)",
+	"1.2":  "bad destination system address (domain unknown)",
+	"1.10": "recipient address has null MX",
+	"2.1":  "mailbox disabled",
+	"2.2":  "mailbox full",
+	"3.4":  "message too big for system",
+	"4.1":  "no answer from host",
+	"4.4":  "unable to route",
+	"4.7":  "delivery time expired",
+	"5.3":  "too many recipients",
+	"7.1":  "delivery not authorized, message refused",
+	"7.26": "multiple authentication checks failed (SPF/DKIM/DMARC)",
+}
+
+// describeDSNStatus renders a human explanation of an enhanced status code.
+func describeDSNStatus(status string) string {
+	if status == "" {
+		return ""
+	}
+	class, ok := dsnStatusClasses[status[0]]
+	if !ok {
+		return ""
+	}
+	if i := strings.IndexByte(status, '.'); i > 0 {
+		if subj, ok := dsnStatusSubjects[status[i+1:]]; ok {
+			return fmt.Sprintf("%s: %s", class, subj)
+		}
+	}
+	return class
+}
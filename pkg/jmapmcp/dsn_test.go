@@ -0,0 +1,69 @@
+package jmapmcp
+
+import "testing"
+
+func TestParseDSN(t *testing.T) {
+	text := "Reporting-MTA: dns; mx.example.com\r\n" +
+		"Arrival-Date: Mon, 1 Jan 2024 10:00:00 +0000\r\n" +
+		"\r\n" +
+		"Final-Recipient: rfc822; nobody@example.org\r\n" +
+		"Action: Failed\r\n" +
+		"Status: 5.1.1\r\n" +
+		"Remote-MTA: dns; mx.example.org\r\n" +
+		"Diagnostic-Code: smtp; 550 5.1.1 <nobody@example.org>:\r\n" +
+		"  Recipient address rejected\r\n" +
+		"\r\n" +
+		"Final-Recipient: rfc822; slow@example.net\r\n" +
+		"Action: delayed\r\n" +
+		"Status: 4.4.1\r\n"
+
+	r := parseDSN(text)
+	if r.ReportingMTA != "mx.example.com" {
+		t.Errorf("ReportingMTA = %q", r.ReportingMTA)
+	}
+	if len(r.Recipients) != 2 {
+		t.Fatalf("got %d recipients, want 2", len(r.Recipients))
+	}
+	first := r.Recipients[0]
+	if first.FinalRecipient != "nobody@example.org" || first.Action != "failed" || first.Status != "5.1.1" {
+		t.Errorf("first recipient = %+v", first)
+	}
+	if first.RemoteMTA != "mx.example.org" {
+		t.Errorf("RemoteMTA = %q", first.RemoteMTA)
+	}
+	if want := "550 5.1.1 <nobody@example.org>: Recipient address rejected"; first.DiagnosticCode != want {
+		t.Errorf("DiagnosticCode = %q, want %q", first.DiagnosticCode, want)
+	}
+	if r.Recipients[1].Action != "delayed" {
+		t.Errorf("second action = %q", r.Recipients[1].Action)
+	}
+}
+
+func TestDescribeDSNStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{"5.1.1", "permanent failure: bad destination mailbox address (user unknown)"},
+		{"4.2.2", "temporary failure: mailbox full"},
+		{"2.0.0", "success"},
+		{"5.9.99", "permanent failure"},
+		{"", ""},
+		{"x.1.1", ""},
+	}
+	for _, tt := range tests {
+		if got := describeDSNStatus(tt.status); got != tt.want {
+			t.Errorf("describeDSNStatus(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderMessageID(t *testing.T) {
+	headers := "From: me@example.com\r\nMessage-ID: <abc@example.com>\r\nSubject: hi"
+	if got := headerMessageID(headers); got != "abc@example.com" {
+		t.Errorf("headerMessageID = %q", got)
+	}
+	if got := headerMessageID("garbage"); got != "" {
+		t.Errorf("headerMessageID(garbage) = %q", got)
+	}
+}
@@ -0,0 +1,316 @@
+package jmapmcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+)
+
+// Internationalized email (EAI, RFC 6530-6533): an address may have a
+// UTF-8 local part and an internationalized domain, which DNS, and SMTP
+// without SMTPUTF8, only know in its ASCII form of "xn--" labels (RFC
+// 3492). Addresses are shown with their Unicode domains, matched in both
+// forms by queries and the send policy, and submitted with an explicit
+// envelope of ASCII domains that asks for SMTPUTF8 when a local part
+// needs it.
+
+// isASCII reports whether s holds only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// splitAddress splits addr at its last "@".
+func splitAddress(addr string) (local, domain string, ok bool) {
+	i := strings.LastIndexByte(addr, '@')
+	if i < 0 {
+		return addr, "", false
+	}
+	return addr[:i], addr[i+1:], true
+}
+
+// asciiDomain returns domain with its non-ASCII labels in their "xn--"
+// form, or domain unchanged when a label cannot be encoded.
+func asciiDomain(domain string) string {
+	if isASCII(domain) {
+		return domain
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := punycodeEncode(strings.ToLower(label))
+		if err != nil {
+			return domain
+		}
+		labels[i] = "xn--" + encoded
+	}
+	return strings.Join(labels, ".")
+}
+
+// unicodeDomain returns domain with its "xn--" labels decoded; labels that
+// do not decode to non-ASCII text are kept.
+func unicodeDomain(domain string) string {
+	if !strings.Contains(strings.ToLower(domain), "xn--") {
+		return domain
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if len(label) < 4 || !strings.EqualFold(label[:4], "xn--") {
+			continue
+		}
+		if decoded, err := punycodeDecode(strings.ToLower(label[4:])); err == nil && !isASCII(decoded) {
+			labels[i] = decoded
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// asciiAddress returns addr with its domain in ASCII form.
+func asciiAddress(addr string) string {
+	local, domain, ok := splitAddress(addr)
+	if !ok {
+		return addr
+	}
+	return local + "@" + asciiDomain(domain)
+}
+
+// displayAddress returns addr with its domain in Unicode form.
+func displayAddress(addr string) string {
+	local, domain, ok := splitAddress(addr)
+	if !ok {
+		return addr
+	}
+	return local + "@" + unicodeDomain(domain)
+}
+
+// addressForms returns addr and, when its domain is internationalized, the
+// same address with the domain in its other form.
+func addressForms(addr string) []string {
+	forms := []string{addr}
+	for _, f := range []string{asciiAddress(addr), displayAddress(addr)} {
+		if !slices.Contains(forms, f) {
+			forms = append(forms, f)
+		}
+	}
+	return forms
+}
+
+// needsSMTPUTF8 reports whether addr has a non-ASCII local part, which
+// only SMTPUTF8 can carry.
+func needsSMTPUTF8(addr string) bool {
+	local, _, _ := splitAddress(addr)
+	return !isASCII(local)
+}
+
+// offersSMTPUTF8 reports whether the submission capability of accountID
+// lists the SMTPUTF8 extension. A session that does not list the
+// extensions at all is given the benefit of the doubt.
+func offersSMTPUTF8(session *jmap.Session, accountID jmap.ID) bool {
+	raw, ok := session.Accounts[accountID].RawCapabilities[emailsubmission.URI]
+	if !ok {
+		return true
+	}
+	var c struct {
+		SubmissionExtensions map[string]json.RawMessage `json:"submissionExtensions"`
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.SubmissionExtensions == nil {
+		return true
+	}
+	for ext := range c.SubmissionExtensions {
+		if strings.EqualFold(ext, "SMTPUTF8") {
+			return true
+		}
+	}
+	return false
+}
+
+// submissionEnvelope returns the envelope to submit a message from the
+// identity address from to recipients with, or nil when every address is
+// ASCII and the server can derive the envelope itself. Domains are given
+// in ASCII form, and SMTPUTF8 is requested when a local part is not ASCII;
+// a server that does not offer it is refused up front.
+func submissionEnvelope(session *jmap.Session, accountID jmap.ID, from string, recipients []*mail.Address) (*emailsubmission.Envelope, error) {
+	addrs := []string{from}
+	for _, a := range recipients {
+		addrs = append(addrs, strings.TrimSpace(a.Email))
+	}
+	if !slices.ContainsFunc(addrs, func(a string) bool { return !isASCII(a) }) {
+		return nil, nil
+	}
+	if i := slices.IndexFunc(addrs, needsSMTPUTF8); i >= 0 && !offersSMTPUTF8(session, accountID) {
+		return nil, &toolError{Code: codeCapabilityMissing, Message: fmt.Sprintf(
+			"%s has a non-ASCII local part, which needs SMTPUTF8, and this server's submission does not offer it", addrs[i])}
+	}
+
+	env := &emailsubmission.Envelope{MailFrom: &emailsubmission.Address{Email: asciiAddress(from)}}
+	if slices.ContainsFunc(addrs, needsSMTPUTF8) {
+		env.MailFrom.Parameters = map[string]any{"SMTPUTF8": nil}
+	}
+	seen := make(map[string]bool)
+	for _, a := range addrs[1:] {
+		rcpt := asciiAddress(a)
+		if key := strings.ToLower(rcpt); !seen[key] {
+			seen[key] = true
+			env.RcptTo = append(env.RcptTo, &emailsubmission.Address{Email: rcpt})
+		}
+	}
+	return env, nil
+}
+
+// Punycode (RFC 3492) parameters.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > (punyBase-punyTMin)*punyTMax/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyThreshold(k, bias int) int {
+	return min(max(k-bias, punyTMin), punyTMax)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// punycodeEncode encodes label, without the "xn--" prefix.
+func punycodeEncode(label string) (string, error) {
+	runes := []rune(label)
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for h := basic; h < len(runes); {
+		m := math.MaxInt32
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		if (m - n) > (math.MaxInt32-delta)/(h+1) {
+			return "", fmt.Errorf("punycode: %q overflows", label)
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out), nil
+}
+
+// punycodeDecode decodes label, without the "xn--" prefix.
+func punycodeDecode(label string) (string, error) {
+	var output []rune
+	start := 0
+	if i := strings.LastIndexByte(label, '-'); i >= 0 {
+		for _, c := range []byte(label[:i]) {
+			if c >= 0x80 {
+				return "", fmt.Errorf("punycode: %q is not ASCII", label)
+			}
+			output = append(output, rune(c))
+		}
+		start = i + 1
+	}
+	n, i, bias := punyInitialN, 0, punyInitialBias
+	for pos := start; pos < len(label); {
+		oldi, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(label) {
+				return "", fmt.Errorf("punycode: %q is truncated", label)
+			}
+			c := label[pos]
+			pos++
+			var d int
+			switch {
+			case c >= 'a' && c <= 'z':
+				d = int(c - 'a')
+			case c >= 'A' && c <= 'Z':
+				d = int(c - 'A')
+			case c >= '0' && c <= '9':
+				d = int(c-'0') + 26
+			default:
+				return "", fmt.Errorf("punycode: bad digit %q in %q", c, label)
+			}
+			if d > (math.MaxInt32-i)/w {
+				return "", fmt.Errorf("punycode: %q overflows", label)
+			}
+			i += d * w
+			t := punyThreshold(k, bias)
+			if d < t {
+				break
+			}
+			w *= punyBase - t
+		}
+		x := len(output) + 1
+		bias = punyAdapt(i-oldi, x, oldi == 0)
+		n += i / x
+		i %= x
+		if n > 0x10FFFF {
+			return "", fmt.Errorf("punycode: %q decodes outside Unicode", label)
+		}
+		output = slices.Insert(output, i, rune(n))
+		i++
+	}
+	return string(output), nil
+}
@@ -0,0 +1,134 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+)
+
+func TestPunycode(t *testing.T) {
+	for unicode, ascii := range map[string]string{
+		"bücher":  "bcher-kva",
+		"münchen": "mnchen-3ya",
+		"例子":      "fsqu00a",
+		"пример":  "e1afmkfd",
+	} {
+		if got, err := punycodeEncode(unicode); err != nil || got != ascii {
+			t.Errorf("punycodeEncode(%s) = %q, %v; want %q", unicode, got, err, ascii)
+		}
+		if got, err := punycodeDecode(ascii); err != nil || got != unicode {
+			t.Errorf("punycodeDecode(%s) = %q, %v; want %q", ascii, got, err, unicode)
+		}
+	}
+	if _, err := punycodeDecode("bcher-k!a"); err == nil {
+		t.Error("bad digit decoded")
+	}
+}
+
+func TestAddressForms(t *testing.T) {
+	if got := asciiAddress("José@Bücher.de"); got != "José@xn--bcher-kva.de" {
+		t.Errorf("asciiAddress = %s", got)
+	}
+	if got := displayAddress("info@XN--BCHER-KVA.de"); got != "info@bücher.de" {
+		t.Errorf("displayAddress = %s", got)
+	}
+	if got := displayAddress("a@xn--zz.example"); got != "a@xn--zz.example" {
+		t.Errorf("displayAddress of an invalid label = %s", got)
+	}
+	if got := addressForms("a@example.org"); len(got) != 1 {
+		t.Errorf("addressForms of an ASCII address = %v", got)
+	}
+	if got := strings.Join(addressForms("a@例子.广告"), " "); got != "a@例子.广告 a@xn--fsqu00a.xn--4rr70v" {
+		t.Errorf("addressForms = %s", got)
+	}
+	if got := formatAddresses([]*mail.Address{{Name: "Info", Email: "info@xn--bcher-kva.de"}}); got != "Info <info@bücher.de>" {
+		t.Errorf("formatAddresses = %s", got)
+	}
+}
+
+func TestSubmissionEnvelope(t *testing.T) {
+	session := func(extensions string) *jmap.Session {
+		return &jmap.Session{Accounts: map[jmap.ID]jmap.Account{"A": {
+			RawCapabilities: map[jmap.URI]json.RawMessage{emailsubmission.URI: json.RawMessage(`{"submissionExtensions": ` + extensions + `}`)},
+		}}}
+	}
+	with, without := session(`{"SMTPUTF8": [], "SIZE": ["1000"]}`), session(`{"SIZE": ["1000"]}`)
+	to := func(addrs ...string) []*mail.Address { return toMailAddresses(addrs) }
+
+	if env, err := submissionEnvelope(without, "A", "me@example.com", to("bob@example.org")); env != nil || err != nil {
+		t.Errorf("ASCII addresses = %+v, %v; want no envelope", env, err)
+	}
+
+	env, err := submissionEnvelope(without, "A", "me@example.com", to("info@bücher.de", "Info@Bücher.de", "bob@example.org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.MailFrom.Email != "me@example.com" || env.MailFrom.Parameters != nil || len(env.RcptTo) != 2 ||
+		env.RcptTo[0].Email != "info@xn--bcher-kva.de" || env.RcptTo[1].Email != "bob@example.org" {
+		t.Errorf("IDN envelope = %+v %+v", env.MailFrom, env.RcptTo)
+	}
+
+	if _, err := submissionEnvelope(without, "A", "me@example.com", to("用户@例子.广告")); err == nil || !strings.Contains(err.Error(), "SMTPUTF8") {
+		t.Errorf("UTF-8 local part without SMTPUTF8 = %v", err)
+	}
+	env, err = submissionEnvelope(with, "A", "me@example.com", to("用户@例子.广告"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := env.MailFrom.Parameters.(map[string]any)["SMTPUTF8"]; !ok || env.RcptTo[0].Email != "用户@xn--fsqu00a.xn--4rr70v" {
+		t.Errorf("EAI envelope = %+v %+v", env.MailFrom, env.RcptTo[0])
+	}
+}
+
+func TestSendPolicyIDN(t *testing.T) {
+	p := newSendPolicy(SendPolicy{AllowedDomains: []string{"bücher.de"}, BlockedAddresses: []string{"*@xn--mnchen-3ya.de"}})
+	if err := p.checkRecipients(toMailAddresses([]string{"info@xn--bcher-kva.de", "shop@Bücher.de"})); err != nil {
+		t.Errorf("allowed IDN domain refused: %v", err)
+	}
+	p = newSendPolicy(SendPolicy{BlockedAddresses: []string{"*@xn--mnchen-3ya.de"}})
+	if err := p.checkRecipients(toMailAddresses([]string{"a@münchen.de"})); err == nil {
+		t.Error("blocked IDN domain allowed in its Unicode form")
+	}
+}
+
+func TestEmailQueryIDN(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Email", map[string]any{"id": "e1", "subject": "Order", "receivedAt": "2025-01-02T10:00:00Z",
+		"from": []any{map[string]any{"email": "shop@xn--bcher-kva.de"}}})
+	fake.Add("Email", map[string]any{"id": "e2", "subject": "Other", "receivedAt": "2025-01-03T10:00:00Z",
+		"from": []any{map[string]any{"email": "a@example.org"}}})
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{From: "shop@bücher.de"})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "e1") || strings.Contains(out, "e2") || !strings.Contains(out, "shop@bücher.de") {
+		t.Errorf("email_query from a Unicode domain = %s", out)
+	}
+}
+
+func TestEmailSubmissionEnvelopeIDN(t *testing.T) {
+	s, fake := newFakeServer(t, WithEmailSubmission())
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	fake.Add("Email", map[string]any{"id": "d1", "subject": "Hi", "mailboxIds": map[string]any{"drafts": true},
+		"keywords": map[string]any{"$draft": true}, "to": []any{map[string]any{"email": "info@bücher.de"}}})
+
+	res, _, _ := s.handleEmailSubmissionSet(context.Background(), nil, EmailSubmissionSetInput{EmailID: "d1"})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	subs := fake.Objects("EmailSubmission")
+	if len(subs) != 1 {
+		t.Fatalf("submissions = %v", subs)
+	}
+	env, _ := fake.Object("EmailSubmission", subs[0])["envelope"].(map[string]any)
+	rcpt, _ := env["rcptTo"].([]any)
+	if len(rcpt) != 1 || rcpt[0].(map[string]any)["email"] != "info@xn--bcher-kva.de" {
+		t.Errorf("envelope = %v", env)
+	}
+}
@@ -0,0 +1,54 @@
+package jmapmcp
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+// What programs embedding the server need beyond NewServer and its
+// options: their own tools, the JMAP client those tools work with, and the
+// stores the Load functions read from.
+
+// AddTool registers a tool of the embedding program on s. It is wrapped like
+// the built-in tools: calls from credentials whose permission excludes it
+// are refused (read-only credentials reach only tools with ReadOnlyHint),
+// ID arguments are checked, and with named endpoints it gains the endpoint
+// parameter. A tool with a built-in tool's name replaces it. Call it before
+// serving.
+func AddTool[In any](s *Server, t *mcp.Tool, h mcp.ToolHandlerFor[In, any]) {
+	addTool(s, t, h)
+}
+
+// Client returns a JMAP client for the credential and endpoint of ctx, the
+// context a tool handler is called with: the same client the built-in
+// tools use.
+func (s *Server) Client(ctx context.Context) (JMAPClient, error) {
+	return s.jmapClient(ctx)
+}
+
+// Store keeps the named JSON documents of automations, schedules, sender
+// lists, Sieve history, and mailbox snapshots. Implementations are safe for
+// concurrent use.
+type Store = store.Store
+
+// DirStore returns a Store that keeps each document in a file under dir
+// (absolute document names are used as they are; "" leaves names as
+// paths, as the binary does).
+func DirStore(dir string) Store {
+	return store.Dir(dir)
+}
+
+// SQLiteStore returns a Store that keeps documents in the SQLite database
+// at path, created when missing. It needs a binary built with cgo.
+func SQLiteStore(path string) (Store, error) {
+	return store.Open("sqlite:" + path)
+}
+
+// MemoryStore returns a Store that keeps documents in memory for the life
+// of the process.
+func MemoryStore() Store {
+	return &store.Memory{}
+}
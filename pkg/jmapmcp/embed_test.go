@@ -0,0 +1,53 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestAddTool(t *testing.T) {
+	s, _ := newFakeServer(t)
+	type labelInput struct {
+		Label string `json:"label"`
+	}
+	AddTool(s, &mcp.Tool{Name: "account_label", Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true}},
+		func(ctx context.Context, _ *mcp.CallToolRequest, in labelInput) (*mcp.CallToolResult, any, error) {
+			client, err := s.Client(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			text := fmt.Sprintf("%s: %s", in.Label, client.Session().PrimaryAccounts[mail.URI])
+			return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: text}}}, nil, nil
+		})
+	AddTool(s, &mcp.Tool{Name: "account_reset"},
+		func(context.Context, *mcp.CallToolRequest, struct{}) (*mcp.CallToolResult, any, error) {
+			return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "reset"}}}, nil, nil
+		})
+
+	cs := rootsSession(t, s)
+	if out, isErr := callTool(t, cs, "account_label", map[string]any{"label": "primary"}); isErr || !strings.HasPrefix(out, "primary: ") {
+		t.Errorf("account_label = %q (error %v)", out, isErr)
+	}
+
+	ctx := ContextWithPermission(context.Background(), PermissionReadOnly)
+	res, err := s.toolCalls["account_reset"].call(ctx, nil)
+	if err != nil || !res.IsError || !strings.Contains(resultText(res), "not allowed") {
+		t.Errorf("read-only credential ran a custom mutating tool: %v %s", err, resultText(res))
+	}
+}
+
+func TestStores(t *testing.T) {
+	for name, st := range map[string]Store{"memory": MemoryStore(), "dir": DirStore(t.TempDir())} {
+		if _, err := st.Write("doc.json", []byte("{}")); err != nil {
+			t.Fatalf("%s: write: %v", name, err)
+		}
+		if data, _, err := st.Read("doc.json"); err != nil || string(data) != "{}" {
+			t.Errorf("%s: read = %q, %v", name, data, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// DefaultEndpoint names the backend given to NewServer.
+const DefaultEndpoint = "default"
+
+// EndpointHeader selects a named endpoint per HTTP request.
+const EndpointHeader = "X-JMAP-Endpoint"
+
+// endpoint is a named JMAP backend.
+type endpoint struct {
+	sessionURL string
+	token      string // static token for stdio mode; empty falls back to the default token
+}
+
+var endpointKey = contextKey{"jmap-endpoint"}
+
+// ContextWithEndpoint returns a new context selecting the named endpoint.
+func ContextWithEndpoint(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, endpointKey, name)
+}
+
+// EndpointFromContext returns the endpoint name selected in the context, or
+// empty string for the default.
+func EndpointFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(endpointKey).(string)
+	return v
+}
+
+// EndpointMiddleware is HTTP middleware that selects a named endpoint from
+// the X-JMAP-Endpoint header or an /endpoint/{name} path prefix, which is
+// stripped before the request reaches next.
+func EndpointMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get(EndpointHeader)
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/endpoint/"); ok {
+			pathName, tail, _ := strings.Cut(rest, "/")
+			name = pathName
+			r = r.Clone(r.Context())
+			r.URL.Path = "/" + tail
+			r.URL.RawPath = ""
+		}
+		if name != "" {
+			r = r.WithContext(ContextWithEndpoint(r.Context(), name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// endpointFor resolves the endpoint selected in ctx.
+func (s *Server) endpointFor(ctx context.Context) (*endpoint, error) {
+	name := EndpointFromContext(ctx)
+	if name == "" || name == DefaultEndpoint {
+		return &endpoint{sessionURL: s.sessionURL, token: s.token}, nil
+	}
+	ep, ok := s.endpoints[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown endpoint %q (configured: %s)", name, strings.Join(s.endpointNames(), ", "))
+	}
+	return ep, nil
+}
+
+// endpointNames lists the selectable endpoint names, default first.
+func (s *Server) endpointNames() []string {
+	names := make([]string, 0, len(s.endpoints))
+	for name := range s.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultEndpoint}, names...)
+}
+
+// addTool registers a tool. With named endpoints configured, the tool gains
+// an optional "endpoint" parameter that routes the call (taking precedence
+// over the HTTP header or path selection); with -debug-jmap=call, an
+// optional "debug" parameter; listing tools take "max_tokens" (see
+// withOutputBudget). Calls are checked against the permission
+// bound to the request's credential, their ID arguments are checked (see
+// withIDCheck), and with named endpoints results are headed by the account
+// they acted on. The tool's _meta gains its cost hint (see toolCost).
+func addTool[In any](s *Server, t *mcp.Tool, h mcp.ToolHandlerFor[In, any]) {
+	t = s.withToolCost(t)
+	h = withIDManifest(t, withFetchMeter(s, withAccountHint(s, withIDCheck(s, withPermission(t, withJMAPDebug(s, t, withOutputBudget(t, withResponseWarnings(h))))))))
+	if s.toolCalls == nil {
+		s.toolCalls = make(toolCalls)
+	}
+	s.toolCalls[t.Name] = toolCall{tool: t, call: func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+		var in In
+		if len(args) > 0 {
+			if err := json.Unmarshal(args, &in); err != nil {
+				return errorResult(invalidArgument("arguments of %s: %w", t.Name, err)), nil
+			}
+		}
+		res, _, err := h(ctx, nil, in)
+		return res, err
+	}}
+	if len(s.endpoints) == 0 && s.debugJMAP != DebugJMAPCall && !isListingTool(t.Name) {
+		mcp.AddTool(s.mcp, t, h)
+		return
+	}
+
+	schema, err := jsonschema.For[In](nil)
+	if err != nil {
+		panic(fmt.Sprintf("tool %q: input schema: %v", t.Name, err))
+	}
+	if schema.Properties == nil {
+		schema.Properties = make(map[string]*jsonschema.Schema)
+	}
+	if s.debugJMAP == DebugJMAPCall {
+		schema.Properties["debug"] = &jsonschema.Schema{
+			Type:        "boolean",
+			Description: "Append the raw JMAP requests and responses of this call to the result (full-permission credentials only)",
+		}
+	}
+	if isListingTool(t.Name) {
+		schema.Properties["max_tokens"] = &jsonschema.Schema{
+			Type:        "integer",
+			Description: "Approximate token budget for the result text: detail lines, middle columns, and finally entries past it are dropped, and the result says what was left out (default: no budget)",
+		}
+	}
+	if len(s.endpoints) == 0 {
+		tt := *t
+		tt.InputSchema = schema
+		mcp.AddTool(s.mcp, &tt, h)
+		return
+	}
+	var enum []any
+	for _, name := range s.endpointNames() {
+		enum = append(enum, name)
+	}
+	schema.Properties["endpoint"] = &jsonschema.Schema{
+		Type:        "string",
+		Enum:        enum,
+		Description: "Named JMAP backend to use (default: " + DefaultEndpoint + ")",
+	}
+
+	tt := *t
+	tt.InputSchema = schema
+	mcp.AddTool(s.mcp, &tt, func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		var sel struct {
+			Endpoint string `json:"endpoint"`
+		}
+		if req != nil && req.Params != nil && len(req.Params.Arguments) > 0 {
+			_ = json.Unmarshal(req.Params.Arguments, &sel)
+		}
+		if sel.Endpoint != "" {
+			ctx = ContextWithEndpoint(ctx, sel.Endpoint)
+		}
+		return h(ctx, req, in)
+	})
+}
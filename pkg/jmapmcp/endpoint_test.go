@@ -0,0 +1,88 @@
+package jmapmcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpointMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		wantName string
+		wantPath string
+	}{
+		{"none", "/mcp", "", "", "/mcp"},
+		{"header", "/mcp", "stalwart", "stalwart", "/mcp"},
+		{"path prefix", "/endpoint/stalwart/mcp", "", "stalwart", "/mcp"},
+		{"path prefix root", "/endpoint/stalwart", "", "stalwart", "/"},
+		{"path beats header", "/endpoint/fastmail/", "stalwart", "fastmail", "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotName, gotPath string
+			h := EndpointMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotName = EndpointFromContext(r.Context())
+				gotPath = r.URL.Path
+			}))
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(EndpointHeader, tt.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if gotName != tt.wantName || gotPath != tt.wantPath {
+				t.Errorf("got (%q, %q), want (%q, %q)", gotName, gotPath, tt.wantName, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestEndpointResolution(t *testing.T) {
+	s := NewServer("test", "https://default.example/session",
+		WithToken("default-token"),
+		WithEndpoint("stalwart", "https://stalwart.example/session", "stalwart-token"),
+		WithEndpoint("shared", "https://shared.example/session", ""),
+	)
+
+	tests := []struct {
+		endpoint  string
+		wantURL   string
+		wantToken string
+		wantErr   bool
+	}{
+		{"", "https://default.example/session", "default-token", false},
+		{DefaultEndpoint, "https://default.example/session", "default-token", false},
+		{"stalwart", "https://stalwart.example/session", "stalwart-token", false},
+		{"shared", "https://shared.example/session", "default-token", false},
+		{"missing", "", "", true},
+	}
+	for _, tt := range tests {
+		ctx := ContextWithEndpoint(context.Background(), tt.endpoint)
+		ep, err := s.endpointFor(ctx)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("endpoint %q: expected error", tt.endpoint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("endpoint %q: %v", tt.endpoint, err)
+		}
+		token, err := s.resolveToken(ctx)
+		if err != nil {
+			t.Fatalf("endpoint %q: resolveToken: %v", tt.endpoint, err)
+		}
+		if ep.sessionURL != tt.wantURL || token != tt.wantToken {
+			t.Errorf("endpoint %q = (%s, %s), want (%s, %s)", tt.endpoint, ep.sessionURL, token, tt.wantURL, tt.wantToken)
+		}
+	}
+
+	// HTTP-mode tokens in the context win over static endpoint tokens.
+	ctx := ContextWithToken(ContextWithEndpoint(context.Background(), "stalwart"), "request-token")
+	if token, _ := s.resolveToken(ctx); token != "request-token" {
+		t.Errorf("context token not preferred: %s", token)
+	}
+}
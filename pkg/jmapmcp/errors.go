@@ -0,0 +1,252 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mikluko/jmap"
+)
+
+// Error results carry a machine-readable error in their structured
+// content, next to the text the model reads, so agents can branch on the
+// kind of failure (retry later, fix the arguments, give up) without parsing
+// prose. errorResult classifies any error: toolErrors built by the helpers
+// below keep the code and IDs they were given, and JMAP method, set, and
+// request errors are mapped from their RFC 8620/8621 types.
+
+// errorCode is the machine-readable kind of a failed call.
+type errorCode string
+
+const (
+	codeInvalidArguments  errorCode = "invalid_arguments"  // the call's arguments are wrong; fix them
+	codeNotFound          errorCode = "not_found"          // an object the call names does not exist
+	codeForbidden         errorCode = "forbidden"          // the account or mailbox rights deny it
+	codeOverQuota         errorCode = "over_quota"         // the account is out of storage
+	codeRateLimited       errorCode = "rate_limited"       // too many calls or sends; retry later
+	codeTooLarge          errorCode = "too_large"          // a request, object, or result exceeds a limit
+	codeCapabilityMissing errorCode = "capability_missing" // the server lacks the needed JMAP capability
+	codePolicyViolation   errorCode = "policy_violation"   // an operator-configured policy refused it
+	codeConflict          errorCode = "conflict"           // the object changed or already exists
+	codeUnavailable       errorCode = "server_unavailable" // the server could not be reached or is down
+	codeFailed            errorCode = "failed"             // anything else
+)
+
+// toolError is the structured content of an error result.
+type toolError struct {
+	Code        errorCode         `json:"code"`
+	Message     string            `json:"message"`
+	Retryable   bool              `json:"retryable"`
+	IDs         []string          `json:"ids,omitempty"`         // the offending object IDs, when known
+	Properties  []string          `json:"properties,omitempty"`  // invalid properties named by the server
+	Suggestions map[string]string `json:"suggestions,omitempty"` // not-found ID → a recently returned ID it likely meant
+	Policy      string            `json:"policy,omitempty"`      // refusals by a configured policy: which policy
+	Rule        string            `json:"rule,omitempty"`        // and the violated rule
+
+	// capability_missing: the capability, what the server advertises, and
+	// what to use instead.
+	Capability   string   `json:"capability,omitempty"`
+	Advertised   []string `json:"advertised,omitempty"`
+	Alternatives []string `json:"alternatives,omitempty"`
+
+	err error
+}
+
+func (e *toolError) Error() string { return e.Message }
+func (e *toolError) Unwrap() error { return e.err }
+
+// invalidArgument reports a call whose arguments are wrong, formatted as
+// fmt.Errorf does.
+func invalidArgument(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	return &toolError{Code: codeInvalidArguments, Message: err.Error(), err: errors.Unwrap(err)}
+}
+
+// notFoundError reports that the objects with ids do not exist.
+func notFoundError[T ~string](ids []T, format string, args ...any) error {
+	return &toolError{Code: codeNotFound, Message: fmt.Sprintf(format, args...), IDs: idList(ids)}
+}
+
+// setFailures reports the records a /set call did not change as
+// "what: id: detail; …" (see setErrorText), classified by the first
+// failure's type. It returns nil when failures is empty.
+func setFailures(what string, failures map[jmap.ID]*jmap.SetError) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(failures))
+	for id := range failures {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+	parts := make([]string, len(ids))
+	var properties []string
+	for i, id := range ids {
+		se := failures[jmap.ID(id)]
+		parts[i] = fmt.Sprintf("%s: %s", id, setErrorText(se))
+		properties = appendProperties(properties, se)
+	}
+	code, retryable := jmapErrorCode(failures[jmap.ID(ids[0])].Type)
+	return &toolError{
+		Code:       code,
+		Message:    fmt.Sprintf("%s: %s", what, strings.Join(parts, "; ")),
+		Retryable:  retryable,
+		IDs:        ids,
+		Properties: properties,
+	}
+}
+
+// setFailure reports one record a /set call did not create as
+// "what: detail".
+func setFailure(what string, se *jmap.SetError) error {
+	code, retryable := jmapErrorCode(se.Type)
+	return &toolError{
+		Code:       code,
+		Message:    fmt.Sprintf("%s: %s", what, setErrorText(se)),
+		Retryable:  retryable,
+		Properties: appendProperties(nil, se),
+	}
+}
+
+// setErrorText renders a SetError with everything the server said about
+// it: the type, the description, the invalid properties, and for
+// alreadyExists the existing record, e.g. "invalidProperties: bad address
+// (properties: to, from)". The type alone rarely says what to correct.
+func setErrorText(se *jmap.SetError) string {
+	text := se.Type
+	if se.Description != nil && *se.Description != "" {
+		text += ": " + *se.Description
+	}
+	if se.Properties != nil && len(*se.Properties) > 0 {
+		text += " (properties: " + strings.Join(*se.Properties, ", ") + ")"
+	}
+	if se.ExistingID != nil {
+		text += fmt.Sprintf(" (existing: %s)", *se.ExistingID)
+	}
+	return text
+}
+
+// appendProperties adds the invalid properties of se not yet in list.
+func appendProperties(list []string, se *jmap.SetError) []string {
+	if se.Properties == nil {
+		return list
+	}
+	for _, p := range *se.Properties {
+		if !slices.Contains(list, p) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+func idList[T ~string](ids []T) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = string(id)
+	}
+	return out
+}
+
+// classifyError returns the structured form of err.
+func classifyError(err error) *toolError {
+	var te *toolError
+	if errors.As(err, &te) {
+		if te.Message != err.Error() {
+			// Wrapped with context; keep the full text.
+			c := *te
+			c.Message = err.Error()
+			return &c
+		}
+		return te
+	}
+	out := &toolError{Code: codeFailed, Message: err.Error(), err: err}
+
+	var pe *policyError
+	var me *jmap.MethodError
+	var re *jmap.RequestError
+	var ne net.Error
+	switch {
+	case errors.As(err, &pe):
+		out.Code, out.Policy, out.Rule = codePolicyViolation, pe.Policy, pe.Rule
+		switch {
+		case pe.Policy == "permission":
+			out.Code = codeForbidden
+		case pe.Policy == "fetch":
+			out.Code = codeTooLarge
+		case pe.Rule == "max_sends_per_hour":
+			out.Code, out.Retryable = codeRateLimited, true
+		}
+	case errors.As(err, &me):
+		out.Code, out.Retryable = jmapErrorCode(me.Type)
+	case errors.As(err, &re):
+		out.Code, out.Retryable = requestErrorCode(re)
+	case strings.Contains(err.Error(), "doesn't support required capability"):
+		out.Code = codeCapabilityMissing
+		if m := requiredCapabilityRe.FindStringSubmatch(err.Error()); m != nil {
+			out.Capability = m[1]
+			out.Alternatives = capabilityFallbacks[jmap.URI(m[1])].alternatives
+		}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne):
+		out.Code, out.Retryable = codeUnavailable, true
+	case strings.HasPrefix(err.Error(), "HTTP 429"):
+		out.Code, out.Retryable = codeRateLimited, true
+	case strings.HasPrefix(err.Error(), "HTTP 5"):
+		out.Code, out.Retryable = codeUnavailable, true
+	}
+	return out
+}
+
+// jmapErrorCode maps a JMAP method or set error type to a code and whether
+// the call may succeed if retried unchanged.
+func jmapErrorCode(typ string) (errorCode, bool) {
+	switch typ {
+	case "invalidArguments", "invalidResultReference", "unknownMethod", "unsupportedFilter",
+		"unsupportedSort", "anchorNotFound", "invalidProperties", "invalidPatch", "invalidEmail",
+		"invalidRecipients", "noRecipients", "invalidScript", "invalidSieve", "singleton",
+		"willDestroy", "mailboxHasChild", "mailboxHasEmail":
+		return codeInvalidArguments, false
+	case "notFound", "accountNotFound", "blobNotFound":
+		return codeNotFound, false
+	case "forbidden", "forbiddenFrom", "forbiddenMailFrom", "forbiddenToSend",
+		"accountReadOnly", "accountNotSupportedByMethod":
+		return codeForbidden, false
+	case "overQuota":
+		return codeOverQuota, false
+	case "rateLimit":
+		return codeRateLimited, true
+	case "tooLarge", "requestTooLarge", "tooManyChanges", "tooManyKeywords",
+		"tooManyMailboxes", "tooManyRecipients":
+		return codeTooLarge, false
+	case "unknownCapability":
+		return codeCapabilityMissing, false
+	case "alreadyExists", "cannotCalculateChanges":
+		return codeConflict, false
+	case "stateMismatch":
+		return codeConflict, true
+	case "serverUnavailable":
+		return codeUnavailable, true
+	}
+	return codeFailed, false
+}
+
+// requestErrorCode maps an RFC 8620 request-level error.
+func requestErrorCode(re *jmap.RequestError) (errorCode, bool) {
+	switch {
+	case strings.HasSuffix(re.Type, ":unknownCapability"):
+		return codeCapabilityMissing, false
+	case strings.HasSuffix(re.Type, ":limit"):
+		if re.Limit != nil && *re.Limit == "maxConcurrentRequests" {
+			return codeRateLimited, true
+		}
+		return codeTooLarge, false
+	case re.Status == 429:
+		return codeRateLimited, true
+	case re.Status >= 500:
+		return codeUnavailable, true
+	}
+	return codeFailed, false
+}
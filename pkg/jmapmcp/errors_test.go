@@ -0,0 +1,99 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap"
+)
+
+func TestClassifyError(t *testing.T) {
+	limit := "maxConcurrentRequests"
+	desc := "bad filter"
+	for _, tc := range []struct {
+		err       error
+		code      errorCode
+		retryable bool
+	}{
+		{invalidArgument("email_ids is required"), codeInvalidArguments, false},
+		{fmt.Errorf("lookup: %w", notFoundError([]string{"e1"}, "email not found: e1")), codeNotFound, false},
+		{&jmap.MethodError{Type: "invalidArguments", Description: &desc}, codeInvalidArguments, false},
+		{&jmap.MethodError{Type: "stateMismatch"}, codeConflict, true},
+		{&jmap.MethodError{Type: "serverUnavailable"}, codeUnavailable, true},
+		{&jmap.RequestError{Type: "urn:ietf:params:jmap:error:limit", Status: 400, Limit: &limit}, codeRateLimited, true},
+		{&jmap.RequestError{Type: "urn:ietf:params:jmap:error:unknownCapability", Status: 400}, codeCapabilityMissing, false},
+		{fmt.Errorf("server doesn't support required capability 'urn:ietf:params:jmap:calendars'"), codeCapabilityMissing, false},
+		{&policyError{Policy: "send", Rule: "blocked_address"}, codePolicyViolation, false},
+		{&policyError{Policy: "send", Rule: "max_sends_per_hour"}, codeRateLimited, true},
+		{fmt.Errorf("jmap: %w", context.DeadlineExceeded), codeUnavailable, true},
+		{errors.New("something odd"), codeFailed, false},
+	} {
+		got := classifyError(tc.err)
+		if got.Code != tc.code || got.Retryable != tc.retryable {
+			t.Errorf("%v: code %s retryable %v, want %s %v", tc.err, got.Code, got.Retryable, tc.code, tc.retryable)
+		}
+		if got.Message != tc.err.Error() {
+			t.Errorf("%v: message %q", tc.err, got.Message)
+		}
+	}
+	got := classifyError(fmt.Errorf("server doesn't support required capability 'urn:ietf:params:jmap:calendars'"))
+	if got.Capability != "urn:ietf:params:jmap:calendars" || len(got.Alternatives) == 0 {
+		t.Errorf("capability_missing without the capability or alternatives: %+v", got)
+	}
+}
+
+func TestSetFailures(t *testing.T) {
+	if err := setFailures("move failed", nil); err != nil {
+		t.Fatalf("no failures = %v", err)
+	}
+	err := setFailures("move failed", map[jmap.ID]*jmap.SetError{
+		"e2": {Type: "overQuota"},
+		"e1": {Type: "overQuota"},
+	})
+	te := classifyError(err)
+	if te.Code != codeOverQuota || strings.Join(te.IDs, ",") != "e1,e2" || te.Message != "move failed: e1: overQuota; e2: overQuota" {
+		t.Errorf("setFailures = %+v", te)
+	}
+}
+
+func TestErrorResultStructuredContent(t *testing.T) {
+	s, _ := newFakeServer(t)
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailMove(ctx, nil, EmailMoveInput{})
+	if te, ok := res.StructuredContent.(*toolError); !ok || te.Code != codeInvalidArguments {
+		t.Errorf("email_move without IDs = %#v, want invalid_arguments", res.StructuredContent)
+	}
+
+	res, _, _ = s.handleEmailReply(ctx, nil, EmailReplyInput{EmailID: "nope", Body: "hi"})
+	te, ok := res.StructuredContent.(*toolError)
+	if !ok || te.Code != codeNotFound || len(te.IDs) != 1 || te.IDs[0] != "nope" {
+		t.Errorf("email_reply of a missing email = %#v, want not_found [nope]", res.StructuredContent)
+	}
+}
+
+func TestSetErrorText(t *testing.T) {
+	desc := "address is not valid"
+	props := []string{"to", "from"}
+	existing := jmap.ID("m1")
+	for _, tc := range []struct {
+		se   *jmap.SetError
+		want string
+	}{
+		{&jmap.SetError{Type: "notFound"}, "notFound"},
+		{&jmap.SetError{Type: "invalidProperties", Description: &desc, Properties: &props}, "invalidProperties: address is not valid (properties: to, from)"},
+		{&jmap.SetError{Type: "alreadyExists", ExistingID: &existing}, "alreadyExists (existing: m1)"},
+	} {
+		if got := setErrorText(tc.se); got != tc.want {
+			t.Errorf("setErrorText = %q, want %q", got, tc.want)
+		}
+	}
+
+	te := classifyError(setFailure("draft creation failed", &jmap.SetError{Type: "invalidProperties", Properties: &props}))
+	if te.Code != codeInvalidArguments || strings.Join(te.Properties, ",") != "to,from" {
+		t.Errorf("setFailure = %+v, want invalid_arguments with properties", te)
+	}
+}
@@ -0,0 +1,326 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+const maxSourceBodyBytes = 64 * 1024 // body text scanned by email_to_event and email_to_task
+
+// eventDetails is what could be learned about an event from a message.
+// A zero start means no date was found; allDay means a date without a
+// time; a zero duration means none was stated.
+type eventDetails struct {
+	title    string
+	start    time.Time
+	allDay   bool
+	duration time.Duration
+	location string
+	source   string // where the details came from, for the result
+}
+
+// fetchSourceEmail gets an email with the properties and body values the
+// extractors read.
+func fetchSourceEmail(ctx context.Context, client JMAPClient, accountID jmap.ID, emailID string) (*email.Email, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account:             accountID,
+		IDs:                 []jmap.ID{jmap.ID(emailID)},
+		Properties:          []string{"id", "subject", "from", "receivedAt", "bodyStructure", "textBody", "htmlBody", "bodyValues"},
+		FetchTextBodyValues: true,
+		FetchHTMLBodyValues: true,
+		MaxBodyValueBytes:   maxSourceBodyBytes,
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Email/get")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.List) == 0 {
+			return nil, notFoundError([]string{emailID}, "email not found: %s", emailID)
+		}
+		return args.List[0], nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// sourceEmailText is the subject and body text the extractors scan.
+func sourceEmailText(e *email.Email) string {
+	return e.Subject + "\n" + extractBody(e, maxSourceBodyBytes, "", quotesStrip)
+}
+
+// sourceEmailNote links a created event or task back to its email.
+func sourceEmailNote(e *email.Email) string {
+	note := fmt.Sprintf("Created from email %s", e.ID)
+	if e.Subject != "" {
+		note += fmt.Sprintf(" %q", e.Subject)
+	}
+	if len(e.From) > 0 {
+		note += " from " + formatAddresses(e.From)
+	}
+	if e.ReceivedAt != nil {
+		note += ", received " + e.ReceivedAt.Format("2006-01-02 15:04")
+	}
+	return note + "."
+}
+
+// --- iCalendar (text/calendar parts) ---
+
+// parseICSEvent reads the first VEVENT of an iCalendar object. Times with
+// a TZID are read in that zone when known; floating times in loc.
+func parseICSEvent(text string, loc *time.Location) (eventDetails, bool) {
+	d := eventDetails{source: "calendar invitation"}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.NewReplacer("\n ", "", "\n\t", "").Replace(text) // unfold
+
+	var end time.Time
+	inEvent, found := false, false
+	for _, line := range strings.Split(text, "\n") {
+		switch strings.ToUpper(strings.TrimSpace(line)) {
+		case "BEGIN:VEVENT":
+			inEvent = true
+			continue
+		case "END:VEVENT":
+			if inEvent {
+				if !d.start.IsZero() && !end.IsZero() && end.After(d.start) {
+					d.duration = end.Sub(d.start)
+				}
+				return d, found
+			}
+		}
+		if !inEvent {
+			continue
+		}
+		nameParams, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		parts := strings.Split(nameParams, ";")
+		params := make(map[string]string)
+		for _, p := range parts[1:] {
+			if k, v, ok := strings.Cut(p, "="); ok {
+				params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+			}
+		}
+		switch strings.ToUpper(parts[0]) {
+		case "SUMMARY":
+			d.title = icsUnescape(value)
+		case "LOCATION":
+			d.location = icsUnescape(value)
+		case "DTSTART":
+			if t, allDay, ok := icsTime(value, params["TZID"], loc); ok {
+				d.start, d.allDay, found = t, allDay, true
+			}
+		case "DTEND":
+			if t, _, ok := icsTime(value, params["TZID"], loc); ok {
+				end = t
+			}
+		case "DURATION":
+			if days, clock, err := parseISODuration(strings.TrimPrefix(value, "+")); err == nil {
+				d.duration = time.Duration(days)*24*time.Hour + clock
+			}
+		}
+	}
+	return d, false
+}
+
+// icsTime parses an iCalendar DATE or DATE-TIME value.
+func icsTime(value, tzid string, loc *time.Location) (t time.Time, allDay, ok bool) {
+	value = strings.TrimSpace(value)
+	if l, err := time.LoadLocation(tzid); tzid != "" && err == nil {
+		loc = l
+	}
+	if len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err == nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err == nil
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err == nil
+}
+
+func icsUnescape(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(strings.TrimSpace(s))
+}
+
+// --- message text ---
+
+var (
+	months = map[string]time.Month{
+		"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+		"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+		"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+	}
+	monthPattern = `(january|february|march|april|may|june|july|august|september|october|november|december|jan|feb|mar|apr|jun|jul|aug|sept|sep|oct|nov|dec)\b\.?`
+
+	isoDateRE   = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	monthDayRE  = regexp.MustCompile(`(?i)\b` + monthPattern + `\s+(\d{1,2})(?:st|nd|rd|th)?\b(?:,?\s+(\d{4})\b)?`)
+	dayMonthRE  = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + monthPattern + `(?:,?\s+(\d{4})\b)?`)
+	clockRE     = regexp.MustCompile(`(?i)\b(\d{1,2})(?::(\d{2}))?\s*([ap])\.?m\b\.?|\b(\d{1,2}):(\d{2})\b`)
+	rangeSepRE  = regexp.MustCompile(`(?i)^\s*(?:-|–|—|to|until|till)\s*`)
+	dueCueRE    = regexp.MustCompile(`(?i)\b(?:due|by|deadline|before|until|no later than)\b:?`)
+	locationRE  = regexp.MustCompile(`(?im)^\s*(?:location|where|venue|place|address|room)\s*:\s*(.+?)\s*$`)
+	meetingURLs = regexp.MustCompile(`https?://\S*(?:zoom\.us|meet\.google\.com|teams\.microsoft\.com|webex\.com|whereby\.com|meet\.jit\.si)\S*`)
+)
+
+// extractEventFromText finds the first date in text, a time near it (or
+// anywhere), an end time given as a range, and a location line or meeting
+// link. Dates without a year take the next occurrence on or after ref.
+func extractEventFromText(text string, ref time.Time, loc *time.Location) eventDetails {
+	d := eventDetails{source: "message text"}
+	d.start, d.duration, d.allDay, _ = findDateTime(text, ref, loc)
+
+	if m := locationRE.FindStringSubmatch(text); m != nil {
+		d.location = m[1]
+	} else if u := meetingURLs.FindString(text); u != "" {
+		d.location = strings.TrimRight(u, ".,;)>")
+	}
+	return d
+}
+
+// extractDueFromText finds a deadline: the first date following a cue such
+// as "due" or "by", else the first date in text.
+func extractDueFromText(text string, ref time.Time, loc *time.Location) (due time.Time, allDay, ok bool) {
+	for _, m := range dueCueRE.FindAllStringIndex(text, -1) {
+		window := text[m[1]:min(m[1]+60, len(text))]
+		if due, _, allDay, ok = findDateTime(window, ref, loc); ok {
+			return due, allDay, true
+		}
+	}
+	due, _, allDay, ok = findDateTime(text, ref, loc)
+	return due, allDay, ok
+}
+
+// findDateTime combines the first date in text with a time near it (or
+// anywhere in text); without a time the result is allDay.
+func findDateTime(text string, ref time.Time, loc *time.Location) (start time.Time, duration time.Duration, allDay, ok bool) {
+	date, dateEnd, ok := findDate(text, ref.In(loc))
+	if !ok {
+		return time.Time{}, 0, false, false
+	}
+	clock, duration, found := findClock(text[dateEnd:min(dateEnd+80, len(text))])
+	if !found {
+		clock, duration, found = findClock(text)
+	}
+	if !found {
+		return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc), 0, true, true
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, int(clock/time.Minute), 0, 0, loc), duration, false, true
+}
+
+// findDate returns the earliest date in text and the offset just past it.
+func findDate(text string, ref time.Time) (time.Time, int, bool) {
+	best, bestAt, bestEnd := time.Time{}, -1, 0
+	consider := func(at, end, year int, month time.Month, day int, hasYear bool) {
+		if bestAt >= 0 && at >= bestAt || month < time.January || month > time.December || day < 1 || day > 31 {
+			return
+		}
+		if !hasYear {
+			year = ref.Year()
+			if time.Date(year, month, day, 0, 0, 0, 0, ref.Location()).Before(time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location())) {
+				year++
+			}
+		}
+		t := time.Date(year, month, day, 0, 0, 0, 0, ref.Location())
+		if t.Day() != day { // e.g. February 30
+			return
+		}
+		best, bestAt, bestEnd = t, at, end
+	}
+
+	if m := isoDateRE.FindStringSubmatchIndex(text); m != nil {
+		year, _ := strconv.Atoi(text[m[2]:m[3]])
+		month, _ := strconv.Atoi(text[m[4]:m[5]])
+		day, _ := strconv.Atoi(text[m[6]:m[7]])
+		consider(m[0], m[1], year, time.Month(month), day, true)
+	}
+	if m := monthDayRE.FindStringSubmatchIndex(text); m != nil {
+		day, _ := strconv.Atoi(text[m[4]:m[5]])
+		year, hasYear := 0, m[6] >= 0
+		if hasYear {
+			year, _ = strconv.Atoi(text[m[6]:m[7]])
+		}
+		consider(m[0], m[1], year, months[strings.ToLower(text[m[2]:m[2]+3])], day, hasYear)
+	}
+	if m := dayMonthRE.FindStringSubmatchIndex(text); m != nil {
+		day, _ := strconv.Atoi(text[m[2]:m[3]])
+		year, hasYear := 0, m[6] >= 0
+		if hasYear {
+			year, _ = strconv.Atoi(text[m[6]:m[7]])
+		}
+		consider(m[0], m[1], year, months[strings.ToLower(text[m[4]:m[4]+3])], day, hasYear)
+	}
+	return best, bestEnd, bestAt >= 0
+}
+
+// findClock returns the first time of day in text as an offset from
+// midnight and, when it opens a range ("10:00-11:30", "2pm to 3pm"), the
+// range's length. A bare hour counts only with am/pm.
+func findClock(text string) (time.Duration, time.Duration, bool) {
+	m := clockRE.FindStringSubmatchIndex(text)
+	if m == nil {
+		return 0, 0, false
+	}
+	start, ok := clockOffset(text, m)
+	if !ok {
+		return 0, 0, false
+	}
+
+	var dur time.Duration
+	rest := text[m[1]:]
+	if sep := rangeSepRE.FindStringIndex(rest); sep != nil {
+		if e := clockRE.FindStringSubmatchIndex(rest[sep[1]:]); e != nil && e[0] == 0 {
+			if end, ok := clockOffset(rest[sep[1]:], e); ok && end > start {
+				dur = end - start
+			}
+		}
+	}
+	return start, dur, true
+}
+
+// clockOffset converts a clockRE match to an offset from midnight.
+func clockOffset(text string, m []int) (time.Duration, bool) {
+	group := func(i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+		return text[m[2*i]:m[2*i+1]]
+	}
+	hourText, minText, meridiem := group(1), group(2), strings.ToLower(group(3))
+	if hourText == "" {
+		hourText, minText = group(4), group(5)
+	}
+	hour, _ := strconv.Atoi(hourText)
+	minute, _ := strconv.Atoi(minText)
+	switch {
+	case meridiem != "" && (hour < 1 || hour > 12):
+		return 0, false
+	case meridiem == "p" && hour != 12:
+		hour += 12
+	case meridiem == "a" && hour == 12:
+		hour = 0
+	}
+	if hour > 23 || minute > 59 {
+		return 0, false
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, true
+}
@@ -0,0 +1,90 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// DefaultMaxFetchBytes is the default ceiling on bytes one tool call may
+// read from the JMAP server.
+const DefaultMaxFetchBytes = 64 << 20
+
+// fetchMeterMetaKey names the result _meta entry reporting bytes fetched.
+const fetchMeterMetaKey = "jmapBytesFetched"
+
+// fetchMeter counts the bytes one tool call reads from the JMAP server
+// across all of its requests and downloads.
+type fetchMeter struct {
+	limit int64 // 0: unlimited
+	used  atomic.Int64
+}
+
+var fetchMeterKey = contextKey{"jmap-fetch-meter"}
+
+func fetchMeterFromContext(ctx context.Context) *fetchMeter {
+	m, _ := ctx.Value(fetchMeterKey).(*fetchMeter)
+	return m
+}
+
+// withFetchMeter gives each call of h a fresh meter, bounded by the
+// server's limit, and reports the bytes fetched in the result's _meta.
+func withFetchMeter[In any](s *Server, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		m := &fetchMeter{limit: s.maxFetchBytes}
+		res, out, err := h(context.WithValue(ctx, fetchMeterKey, m), req, in)
+		if res != nil {
+			if res.Meta == nil {
+				res.Meta = mcp.Meta{}
+			}
+			res.Meta[fetchMeterMetaKey] = m.used.Load()
+		}
+		return res, out, err
+	}
+}
+
+// fetchLimitError reports that a call read more than the limit.
+func fetchLimitError(limit int64) error {
+	return &policyError{
+		Policy: "fetch",
+		Rule:   "max_fetch_bytes",
+		Detail: fmt.Sprintf("this call read more than %d bytes from the mail server; narrow it (fewer email_ids per email_get, a lower limit, a more specific query, or a smaller max_chars)", limit),
+	}
+}
+
+// meteredTransport counts response body bytes against a meter and fails
+// reads once the meter's limit is exceeded.
+type meteredTransport struct {
+	base  http.RoundTripper
+	meter *fetchMeter
+}
+
+func (t meteredTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.meter.limit > 0 && t.meter.used.Load() > t.meter.limit {
+		return nil, fetchLimitError(t.meter.limit)
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &meteredBody{ReadCloser: resp.Body, meter: t.meter}
+	return resp, nil
+}
+
+type meteredBody struct {
+	io.ReadCloser
+	meter *fetchMeter
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	used := b.meter.used.Add(int64(n))
+	if b.meter.limit > 0 && used > b.meter.limit {
+		return n, fetchLimitError(b.meter.limit)
+	}
+	return n, err
+}
@@ -0,0 +1,45 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFetchLimit(t *testing.T) {
+	s, fake := newFakeServer(t, WithMaxFetchBytes(5000))
+	for _, id := range []string{"e1", "e2", "e3"} {
+		addEmail(fake, id, "Big", strings.Repeat("x", 4000), 1)
+	}
+	get := withFetchMeter(s, s.handleEmailGet)
+
+	res, _, _ := get(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e1", "e2", "e3"}})
+	if !res.IsError {
+		t.Fatalf("expected fetch limit error, got:\n%s", resultText(res))
+	}
+	te, ok := res.StructuredContent.(*toolError)
+	if !ok || te.Code != codeTooLarge || te.Policy != "fetch" || te.Rule != "max_fetch_bytes" {
+		t.Errorf("structured content = %#v, want too_large max_fetch_bytes policy error", res.StructuredContent)
+	}
+	if used, _ := res.Meta[fetchMeterMetaKey].(int64); used <= 5000 {
+		t.Errorf("bytes fetched = %d, want > 5000", used)
+	}
+
+	res, _, _ = get(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e1"}})
+	if res.IsError {
+		t.Fatalf("single email over the limit: %s", resultText(res))
+	}
+	if used, _ := res.Meta[fetchMeterMetaKey].(int64); used == 0 {
+		t.Error("bytes fetched not reported")
+	}
+}
+
+func TestFetchLimitUnlimited(t *testing.T) {
+	s, fake := newFakeServer(t, WithMaxFetchBytes(0))
+	addEmail(fake, "e1", "Big", strings.Repeat("x", 100000), 1)
+
+	res, _, _ := withFetchMeter(s, s.handleEmailGet)(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e1"}})
+	if res.IsError {
+		t.Fatalf("error with no limit: %s", resultText(res))
+	}
+}
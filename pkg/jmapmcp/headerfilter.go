@@ -0,0 +1,72 @@
+package jmapmcp
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// full_headers returns every header, and DKIM signatures and ARC sets alone
+// can run to thousands of characters of base64. Header patterns, set for the
+// server with -full-headers-include and -full-headers-exclude and per call
+// with header_include and header_exclude, narrow what is shown.
+
+// headerFilter selects headers by name pattern: "*" matches any run of
+// characters, and matching ignores case.
+type headerFilter struct {
+	Include []string // when set, only headers matching one of these
+	Exclude []string // headers never shown
+}
+
+// matchHeaderPattern reports whether name matches pattern.
+func matchHeaderPattern(pattern, name string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return err == nil && ok
+}
+
+// matchesAnyHeader reports whether name matches one of patterns.
+func matchesAnyHeader(patterns []string, name string) bool {
+	return slices.ContainsFunc(patterns, func(p string) bool { return matchHeaderPattern(p, name) })
+}
+
+// allows reports whether the filter shows a header named name.
+func (f headerFilter) allows(name string) bool {
+	if len(f.Include) > 0 && !matchesAnyHeader(f.Include, name) {
+		return false
+	}
+	return !matchesAnyHeader(f.Exclude, name)
+}
+
+// forCall combines the server filter with a call's patterns: a call's
+// includes replace the server's patterns, and its excludes add to them.
+func (f headerFilter) forCall(include, exclude []string) headerFilter {
+	if len(include) > 0 {
+		return headerFilter{Include: include, Exclude: exclude}
+	}
+	return headerFilter{Include: f.Include, Exclude: append(slices.Clip(f.Exclude), exclude...)}
+}
+
+// filter returns the headers the filter allows and the distinct names of
+// those it drops, in message order.
+func (f headerFilter) filter(headers []*email.Header) (kept []*email.Header, omitted []string) {
+	for _, h := range headers {
+		if f.allows(h.Name) {
+			kept = append(kept, h)
+		} else if !slices.ContainsFunc(omitted, func(n string) bool { return strings.EqualFold(n, h.Name) }) {
+			omitted = append(omitted, h.Name)
+		}
+	}
+	return kept, omitted
+}
+
+// validHeaderPatterns returns an error for the first malformed pattern.
+func validHeaderPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil || p == "" {
+			return invalidArgument("header pattern %q: want a header name, optionally with * wildcards", p)
+		}
+	}
+	return nil
+}
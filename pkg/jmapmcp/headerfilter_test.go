@@ -0,0 +1,72 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestHeaderFilter(t *testing.T) {
+	headers := []*email.Header{
+		{Name: "Received", Value: "from a"},
+		{Name: "DKIM-Signature", Value: "v=1; b=AAAA"},
+		{Name: "ARC-Seal", Value: "i=1"},
+		{Name: "arc-message-signature", Value: "i=1"},
+		{Name: "Subject", Value: "Hi"},
+	}
+	names := func(hs []*email.Header) string {
+		var out []string
+		for _, h := range hs {
+			out = append(out, h.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	server := headerFilter{Exclude: []string{"dkim-signature", "ARC-*"}}
+	kept, omitted := server.filter(headers)
+	if got := names(kept); got != "Received,Subject" {
+		t.Errorf("kept %s", got)
+	}
+	if got := strings.Join(omitted, ","); got != "DKIM-Signature,ARC-Seal,arc-message-signature" {
+		t.Errorf("omitted %s", got)
+	}
+
+	kept, _ = server.forCall(nil, []string{"Received"}).filter(headers)
+	if got := names(kept); got != "Subject" {
+		t.Errorf("call exclude: kept %s", got)
+	}
+	kept, _ = server.forCall([]string{"DKIM-*", "Subject"}, nil).filter(headers)
+	if got := names(kept); got != "DKIM-Signature,Subject" {
+		t.Errorf("call include: kept %s", got)
+	}
+
+	if err := validHeaderPatterns([]string{"X-[", "ok"}); err == nil {
+		t.Error("malformed pattern accepted")
+	}
+}
+
+func TestEmailGetHeaderPatterns(t *testing.T) {
+	s, fake := newFakeServer(t, WithHeaderPatterns(nil, []string{"DKIM-Signature"}))
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Hi",
+		"mailboxIds": map[string]any{"inbox": true},
+		"headers": []any{
+			map[string]any{"name": "DKIM-Signature", "value": " v=1; b=" + strings.Repeat("A", 400)},
+			map[string]any{"name": "Subject", "value": " Hi"},
+		},
+	})
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1"}, FullHeaders: true})
+	out := resultText(res)
+	if res.IsError || strings.Contains(out, "AAAA") || !strings.Contains(out, "Subject: Hi\n") ||
+		!strings.Contains(out, "Headers omitted: DKIM-Signature (request them with header_include)") {
+		t.Errorf("email_get:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1"}, FullHeaders: true, HeaderInclude: []string{"dkim-*"}})
+	if out := resultText(res); !strings.Contains(out, "DKIM-Signature: v=1") || strings.Contains(out, "Subject: Hi\n") {
+		t.Errorf("email_get with header_include:\n%s", out)
+	}
+}
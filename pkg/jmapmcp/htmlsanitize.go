@@ -0,0 +1,321 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// dangerousElements are removed with their content: they execute code, load
+// active content, submit data, or redirect the viewer.
+var dangerousElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Textarea: true,
+	atom.Select:   true,
+	atom.Link:     true,
+	atom.Base:     true,
+	atom.Meta:     true,
+	atom.Noscript: true,
+}
+
+// urlAttributes carry URLs whose scheme must be checked.
+var urlAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"action":     true,
+	"formaction": true,
+	"background": true,
+	"poster":     true,
+	"xlink:href": true,
+}
+
+// trackerURLMarkers are URL fragments used by common open-tracking services.
+var trackerURLMarkers = []string{
+	"/track/open",
+	"/trk/open",
+	"/wf/open",
+	"/e/o/",
+	"list-manage.com/track",
+	"mandrillapp.com/track",
+	"mailtrack.io",
+	"/pixel.gif",
+	"/open.gif",
+	"/beacon",
+}
+
+// sanitizeReport counts what sanitization removed or found.
+type sanitizeReport struct {
+	Dangerous      int // active-content elements removed
+	TrackingPixels int // tracking images removed
+	RemoteImages   int // remaining images loaded from remote hosts
+	Attributes     int // event handlers and executable URLs removed
+}
+
+// String renders a one-line summary suitable for an email header block.
+func (r sanitizeReport) String() string {
+	return fmt.Sprintf("%d tracking pixel(s) removed, %d remote image(s), %d active element(s) removed",
+		r.TrackingPixels, r.RemoteImages, r.Dangerous)
+}
+
+// SanitizeHTML parses an email HTML body and removes active content (script
+// and embedding elements, event-handler attributes, javascript:/vbscript:
+// URLs) and tracking pixels. It returns the sanitized document, or the input
+// escaped as text if it cannot be parsed.
+func SanitizeHTML(rawHTML string) string {
+	out, _ := sanitizeHTMLReport(rawHTML)
+	return out
+}
+
+// sanitizeHTMLReport is SanitizeHTML that also reports what was found.
+func sanitizeHTMLReport(rawHTML string) (string, sanitizeReport) {
+	var report sanitizeReport
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		return html.EscapeString(rawHTML), report
+	}
+	sanitizeNode(doc, &report)
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return html.EscapeString(rawHTML), report
+	}
+	return buf.String(), report
+}
+
+// sanitizeNode walks the tree removing dangerous elements, tracking pixels,
+// and unsafe attributes in place, tallying them in report.
+func sanitizeNode(n *html.Node, report *sanitizeReport) {
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type == html.ElementNode && dangerousElements[c.DataAtom] {
+			n.RemoveChild(c)
+			report.Dangerous++
+			continue
+		}
+		if c.Type == html.ElementNode && c.DataAtom == atom.Img {
+			if isTrackingPixel(c) {
+				n.RemoveChild(c)
+				report.TrackingPixels++
+				continue
+			}
+			if isRemoteURL(attrValue(c, "src")) {
+				report.RemoteImages++
+			}
+		}
+		if c.Type == html.ElementNode {
+			before := len(c.Attr)
+			c.Attr = sanitizeAttrs(c.Attr)
+			report.Attributes += before - len(c.Attr)
+		}
+		sanitizeNode(c, report)
+	}
+}
+
+// isTrackingPixel reports whether an <img> looks like an open tracker: a
+// remote image that is tiny, hidden, or served from a known tracking path.
+func isTrackingPixel(img *html.Node) bool {
+	src := attrValue(img, "src")
+	if !isRemoteURL(src) {
+		return false
+	}
+	if tinyDimension(attrValue(img, "width")) || tinyDimension(attrValue(img, "height")) {
+		return true
+	}
+	style := strings.ToLower(strings.ReplaceAll(attrValue(img, "style"), " ", ""))
+	if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") ||
+		strings.Contains(style, "width:1px") || strings.Contains(style, "height:1px") ||
+		strings.Contains(style, "width:0") || strings.Contains(style, "height:0") {
+		return true
+	}
+	lower := strings.ToLower(src)
+	for _, marker := range trackerURLMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// tinyDimension reports whether a width/height attribute is 0 or 1 pixel.
+func tinyDimension(v string) bool {
+	v = strings.TrimSuffix(strings.TrimSpace(v), "px")
+	if v == "" {
+		return false
+	}
+	n, err := strconv.Atoi(v)
+	return err == nil && n <= 1
+}
+
+// isRemoteURL reports whether u is fetched from the network when displayed.
+func isRemoteURL(u string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "//")
+}
+
+// attrValue returns the value of the named attribute, or empty.
+func attrValue(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// sanitizeAttrs drops event handlers, srcdoc, and URL attributes with
+// executable schemes.
+func sanitizeAttrs(attrs []html.Attribute) []html.Attribute {
+	out := attrs[:0]
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if strings.HasPrefix(key, "on") || key == "srcdoc" {
+			continue
+		}
+		if urlAttributes[key] && unsafeURL(a.Val) {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// unsafeURL reports whether u uses a scheme that executes code when followed.
+func unsafeURL(u string) bool {
+	u = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, u))
+	return strings.HasPrefix(u, "javascript:") ||
+		strings.HasPrefix(u, "vbscript:") ||
+		strings.HasPrefix(u, "data:text/html")
+}
+
+// Outbound HTML. Bodies composed by the agent go through the same
+// sanitizer as received mail, and links whose text reads as an address
+// (a URL, domain, or email address) must lead there: one that does not is
+// the shape of a phishing link and is replaced by its text. The changes
+// are reported so the agent can see what it sent differs from what it
+// wrote.
+
+// linkTextDomain matches anchor text that reads as a bare domain, with an
+// optional path.
+var linkTextDomain = regexp.MustCompile(`^(?i)(www\.)?[a-z0-9-]+(\.[a-z0-9-]+)*\.[a-z]{2,}(/\S*)?$`)
+
+// sanitizeOutboundHTML sanitizes an HTML body composed for sending and
+// unlinks links whose text names another target. It returns the body and
+// a description of each change.
+func sanitizeOutboundHTML(rawHTML string) (string, []string) {
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		return html.EscapeString(rawHTML), []string{"HTML could not be parsed and was escaped as text"}
+	}
+	var report sanitizeReport
+	sanitizeNode(doc, &report)
+	var unlinked []string
+	unlinkMismatched(doc, &unlinked)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return html.EscapeString(rawHTML), []string{"HTML could not be rendered and was escaped as text"}
+	}
+
+	var changes []string
+	if report.Dangerous > 0 {
+		changes = append(changes, fmt.Sprintf("removed %d script, form, or embedding element(s)", report.Dangerous))
+	}
+	if report.Attributes > 0 {
+		changes = append(changes, fmt.Sprintf("removed %d event handler or executable URL attribute(s)", report.Attributes))
+	}
+	if report.TrackingPixels > 0 {
+		changes = append(changes, fmt.Sprintf("removed %d tracking pixel(s)", report.TrackingPixels))
+	}
+	changes = append(changes, unlinked...)
+	return buf.String(), changes
+}
+
+// unlinkMismatched replaces each <a> whose text names a target other than
+// its href with the link's content, describing each in unlinked.
+func unlinkMismatched(n *html.Node, unlinked *[]string) {
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			text := strings.TrimSpace(nodeText(c))
+			href := strings.TrimSpace(attrValue(c, "href"))
+			if href != "" && !linkMatchesText(text, href) {
+				*unlinked = append(*unlinked, fmt.Sprintf("unlinked %q, which pointed to %s", text, href))
+				for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+					c.RemoveChild(gc)
+					n.InsertBefore(gc, c)
+				}
+				n.RemoveChild(c)
+				continue
+			}
+		}
+		unlinkMismatched(c, unlinked)
+	}
+}
+
+// nodeText concatenates the text under n.
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(nodeText(c))
+	}
+	return sb.String()
+}
+
+// linkMatchesText reports whether href leads where text says. Text that
+// does not read as an address matches any href; an email address must be
+// the mailto: target, and a URL or domain must be href's host or a parent
+// of it, ignoring a leading "www.".
+func linkMatchesText(text, href string) bool {
+	lower := strings.ToLower(text)
+	if strings.Contains(lower, " ") || lower == "" {
+		return true
+	}
+	target, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	if strings.Contains(lower, "@") && !strings.Contains(lower, "/") {
+		addr := strings.TrimPrefix(lower, "mailto:")
+		return strings.EqualFold(target.Scheme, "mailto") && strings.EqualFold(target.Opaque, addr)
+	}
+	var textHost string
+	switch {
+	case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+		u, err := url.Parse(lower)
+		if err != nil {
+			return false
+		}
+		textHost = u.Hostname()
+	case linkTextDomain.MatchString(lower):
+		textHost, _, _ = strings.Cut(lower, "/")
+	default:
+		return true
+	}
+	textHost = strings.TrimPrefix(textHost, "www.")
+	host := strings.TrimPrefix(strings.ToLower(target.Hostname()), "www.")
+	return host == textHost || strings.HasSuffix(host, "."+textHost)
+}
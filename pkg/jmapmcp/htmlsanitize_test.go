@@ -0,0 +1,134 @@
+package jmapmcp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantIn  string // substring that must be present
+		wantOut string // substring that must be absent
+	}{
+		{
+			name:    "removes script",
+			input:   `<p>Hello</p><script>alert(1)</script>`,
+			wantIn:  "Hello",
+			wantOut: "alert",
+		},
+		{
+			name:    "removes event handlers",
+			input:   `<img src="a.png" onerror="steal()">`,
+			wantIn:  `src="a.png"`,
+			wantOut: "onerror",
+		},
+		{
+			name:    "removes javascript links",
+			input:   `<a href=" javascript:evil()">click</a>`,
+			wantIn:  "click",
+			wantOut: "javascript",
+		},
+		{
+			name:    "removes iframes and forms",
+			input:   `<iframe src="https://x"></iframe><form action="https://x"><input name="pw"></form><p>ok</p>`,
+			wantIn:  "ok",
+			wantOut: "iframe",
+		},
+		{
+			name:    "keeps ordinary links",
+			input:   `<a href="https://example.com">site</a>`,
+			wantIn:  `href="https://example.com"`,
+			wantOut: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeHTML(tt.input)
+			if tt.wantIn != "" && !strings.Contains(got, tt.wantIn) {
+				t.Errorf("expected output to contain %q, got:\n%s", tt.wantIn, got)
+			}
+			if tt.wantOut != "" && strings.Contains(got, tt.wantOut) {
+				t.Errorf("expected output NOT to contain %q, got:\n%s", tt.wantOut, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLReport(t *testing.T) {
+	input := `<p>News</p>
+<img src="https://cdn.example.com/hero.jpg" width="600">
+<img src="https://t.example.com/o.gif" width="1" height="1">
+<img src="https://x.list-manage.com/track/open.php?u=1">
+<img src="https://t.example.com/p.png" style="display: none">
+<img src="cid:logo">
+<script>track()</script>`
+	got, report := sanitizeHTMLReport(input)
+	if report.TrackingPixels != 3 {
+		t.Errorf("TrackingPixels = %d, want 3", report.TrackingPixels)
+	}
+	if report.RemoteImages != 1 {
+		t.Errorf("RemoteImages = %d, want 1", report.RemoteImages)
+	}
+	if report.Dangerous != 1 {
+		t.Errorf("Dangerous = %d, want 1", report.Dangerous)
+	}
+	if strings.Contains(got, "o.gif") || strings.Contains(got, "list-manage") {
+		t.Errorf("expected tracking pixels stripped, got:\n%s", got)
+	}
+	if !strings.Contains(got, "hero.jpg") || !strings.Contains(got, "cid:logo") {
+		t.Errorf("expected regular images kept, got:\n%s", got)
+	}
+}
+
+func TestSanitizedBodyFragmentResolvesCID(t *testing.T) {
+	images := map[string]string{"logo@x": "data:image/png;base64,AAAA"}
+	got := sanitizedBodyFragment(`<html><body><img src="cid:logo@x"><img src="cid:missing"></body></html>`, images, nil)
+	if !strings.Contains(got, `src="data:image/png;base64,AAAA"`) {
+		t.Errorf("expected cid resolved to data URI, got:\n%s", got)
+	}
+	if !strings.Contains(got, `src="cid:missing"`) {
+		t.Errorf("expected unknown cid left as-is, got:\n%s", got)
+	}
+	if strings.Contains(got, "<body>") {
+		t.Errorf("expected body inner content only, got:\n%s", got)
+	}
+}
+
+func TestSanitizeOutboundHTML(t *testing.T) {
+	in := `<p onclick="steal()">Pay at <a href="https://evil.example/login">paypal.com</a>, ` +
+		`see <a href="https://docs.example.org/guide">https://example.org/guide</a>, ` +
+		`write <a href="mailto:bob@example.org">bob@example.org</a> or <a href="mailto:eve@evil.example">alice@example.org</a>, ` +
+		`<a href="https://evil.example/x">click here</a></p><script>alert(1)</script><form><input name="q"></form>`
+	out, changes := sanitizeOutboundHTML(in)
+
+	for _, bad := range []string{"<script", "<form", "onclick", "evil.example/login", "eve@evil.example"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("output still contains %q:\n%s", bad, out)
+		}
+	}
+	for _, kept := range []string{"paypal.com", `href="https://docs.example.org/guide"`, `href="mailto:bob@example.org"`, `href="https://evil.example/x"`} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("output lost %q:\n%s", kept, out)
+		}
+	}
+	got := strings.Join(changes, "\n")
+	for _, want := range []string{
+		"removed 2 script, form, or embedding element(s)",
+		"removed 1 event handler or executable URL attribute(s)",
+		`unlinked "paypal.com", which pointed to https://evil.example/login`,
+		`unlinked "alice@example.org", which pointed to mailto:eve@evil.example`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("changes missing %q:\n%s", want, got)
+		}
+	}
+	if len(changes) != 4 {
+		t.Errorf("changes = %q, want 4", changes)
+	}
+
+	if _, changes := sanitizeOutboundHTML(`<p>Hello <a href="https://www.example.com/a">example.com</a></p>`); len(changes) != 0 {
+		t.Errorf("clean HTML reported changes: %q", changes)
+	}
+}
@@ -0,0 +1,226 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/k3a/html2text"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// html2text flattens a table into a stream of cell text, which loses the
+// rows and columns order confirmations, invoices, and reports depend on.
+// htmlToText renders data tables as markdown tables first and lets html2text
+// convert the rest; layout tables (nested, single column, or holding
+// paragraphs of text) are left to html2text.
+
+const (
+	// maxTableCellRunes is the longest cell text of a data table; longer
+	// cells mean a layout table.
+	maxTableCellRunes = 200
+	// maxTableColumnPad is the widest a column is padded to; longer cells
+	// overflow their column rather than widening every row.
+	maxTableColumnPad = 40
+	// maxTableColumnSpan caps the columns a cell's colspan adds.
+	maxTableColumnSpan = 20
+	// tablePlaceholder marks where a rendered table goes in the converted
+	// text: a bare word html2text passes through unchanged.
+	tablePlaceholder = "jmapmcptable%dplaceholder"
+)
+
+// htmlToText converts an HTML body to plain text, rendering data tables as
+// markdown tables.
+func htmlToText(rawHTML string) string {
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		return html2text.HTML2Text(rawHTML)
+	}
+	tables := replaceDataTables(doc)
+	if len(tables) == 0 {
+		return html2text.HTML2Text(rawHTML)
+	}
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return html2text.HTML2Text(rawHTML)
+	}
+	text := html2text.HTML2Text(buf.String())
+	pairs := make([]string, 0, 2*len(tables))
+	for i, t := range tables {
+		pairs = append(pairs, fmt.Sprintf(tablePlaceholder, i), t)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// replaceDataTables replaces each data table under n with a paragraph
+// holding a placeholder and returns the tables rendered as markdown, in
+// placeholder order.
+func replaceDataTables(n *html.Node) []string {
+	var tables []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		var next *html.Node
+		for c := n.FirstChild; c != nil; c = next {
+			next = c.NextSibling
+			if c.Type != html.ElementNode || c.DataAtom != atom.Table {
+				walk(c)
+				continue
+			}
+			rows, ok := dataTableRows(c)
+			if !ok {
+				walk(c)
+				continue
+			}
+			p := &html.Node{Type: html.ElementNode, Data: "p", DataAtom: atom.P}
+			p.AppendChild(&html.Node{Type: html.TextNode, Data: fmt.Sprintf(tablePlaceholder, len(tables))})
+			n.InsertBefore(p, c)
+			n.RemoveChild(c)
+			tables = append(tables, markdownTable(rows))
+		}
+	}
+	walk(n)
+	return tables
+}
+
+// dataTableRows returns the cell texts of table, row by row, with empty rows
+// dropped and column spans expanded. It reports false for layout tables: ones
+// holding another table, with fewer than two rows of two filled cells, or with
+// a cell longer than maxTableCellRunes.
+func dataTableRows(table *html.Node) ([][]string, bool) {
+	var rows [][]string
+	filled := 0
+	var walk func(n *html.Node) bool
+	walk = func(n *html.Node) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Table:
+				return false
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				if !walk(c) {
+					return false
+				}
+			case atom.Tr:
+				row, ok := tableRow(c)
+				if !ok {
+					return false
+				}
+				n := 0
+				for _, cell := range row {
+					if cell != "" {
+						n++
+					}
+				}
+				if n == 0 {
+					continue
+				}
+				if n >= 2 {
+					filled++
+				}
+				rows = append(rows, row)
+			}
+		}
+		return true
+	}
+	if !walk(table) || filled < 2 {
+		return nil, false
+	}
+	return rows, true
+}
+
+// tableRow returns the cell texts of tr, repeating an empty cell for each
+// extra column a cell spans. It reports false when a cell holds a table or
+// is too long for a data table.
+func tableRow(tr *html.Node) ([]string, bool) {
+	var row []string
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+			continue
+		}
+		var sb strings.Builder
+		if !cellText(c, &sb) {
+			return nil, false
+		}
+		text := strings.Join(strings.Fields(sb.String()), " ")
+		if utf8.RuneCountInString(text) > maxTableCellRunes {
+			return nil, false
+		}
+		row = append(row, strings.ReplaceAll(text, "|", `\|`))
+		if span, err := strconv.Atoi(strings.TrimSpace(attrValue(c, "colspan"))); err == nil {
+			for i := 1; i < min(span, maxTableColumnSpan); i++ {
+				row = append(row, "")
+			}
+		}
+	}
+	return row, true
+}
+
+// cellText writes the text under n to sb, with line breaks and images'
+// alternative text as spaces and words. It reports false if n holds a table.
+func cellText(n *html.Node, sb *strings.Builder) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.TextNode:
+			sb.WriteString(c.Data)
+		case c.Type != html.ElementNode:
+		case c.DataAtom == atom.Table:
+			return false
+		case c.DataAtom == atom.Style || c.DataAtom == atom.Script:
+		case c.DataAtom == atom.Img:
+			sb.WriteString(" " + attrValue(c, "alt") + " ")
+		default:
+			sb.WriteByte(' ')
+			if !cellText(c, sb) {
+				return false
+			}
+			sb.WriteByte(' ')
+		}
+	}
+	return true
+}
+
+// markdownTable renders rows as a markdown table with the first row as its
+// header and columns padded to align.
+func markdownTable(rows [][]string) string {
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	widths := make([]int, cols)
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], min(utf8.RuneCountInString(cell), maxTableColumnPad))
+		}
+	}
+	for i := range widths {
+		widths[i] = max(widths[i], 3)
+	}
+	var sb strings.Builder
+	writeRow := func(row []string) {
+		sb.WriteString("|")
+		for i, w := range widths {
+			var cell string
+			if i < len(row) {
+				cell = row[i]
+			}
+			pad := max(w-utf8.RuneCountInString(cell), 0)
+			sb.WriteString(" " + cell + strings.Repeat(" ", pad) + " |")
+		}
+		sb.WriteString("\n")
+	}
+	writeRow(rows[0])
+	sb.WriteString("|")
+	for _, w := range widths {
+		sb.WriteString(" " + strings.Repeat("-", w) + " |")
+	}
+	sb.WriteString("\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,72 @@
+package jmapmcp
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// dataTables returns the markdown of the data tables in rawHTML.
+func dataTables(t *testing.T, rawHTML string) []string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return replaceDataTables(doc)
+}
+
+func TestReplaceDataTables(t *testing.T) {
+	t.Run("order confirmation", func(t *testing.T) {
+		got := dataTables(t, `<table>
+<thead><tr><th>Item</th><th>Qty</th><th>Price</th></tr></thead>
+<tbody>
+<tr><td><b>Widget</b> (blue)</td><td>2</td><td>$10.00</td></tr>
+<tr><td></td><td></td><td></td></tr>
+<tr><td>Gadget | large</td><td>1</td><td>$149.99</td></tr>
+<tr><td colspan="2">Total</td><td>$169.99</td></tr>
+</tbody></table>`)
+		want := strings.Join([]string{
+			"| Item            | Qty | Price   |",
+			"| --------------- | --- | ------- |",
+			"| Widget (blue)   | 2   | $10.00  |",
+			`| Gadget \| large | 1   | $149.99 |`,
+			"| Total           |     | $169.99 |",
+		}, "\n")
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("got %q, want\n%s", got, want)
+		}
+	})
+
+	t.Run("innermost table of a layout", func(t *testing.T) {
+		got := dataTables(t, `<table><tr><td><img alt="Logo"></td></tr><tr><td>
+<table><tr><td>Date</td><td>Amount</td></tr><tr><td>May 3</td><td>€20</td></tr></table>
+</td></tr></table>`)
+		if len(got) != 1 || !strings.HasPrefix(got[0], "| Date  | Amount |\n") {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("layout tables left alone", func(t *testing.T) {
+		for _, doc := range []string{
+			`<table><tr><td>Only</td></tr><tr><td>one column</td></tr></table>`,
+			`<table><tr><td>One</td><td>row</td></tr></table>`,
+			`<table><tr><td>A</td><td>` + strings.Repeat("Long paragraph text. ", 20) + `</td></tr><tr><td>B</td><td>C</td></tr></table>`,
+		} {
+			if got := dataTables(t, doc); len(got) != 0 {
+				t.Errorf("%s: rendered %q", doc, got)
+			}
+		}
+	})
+}
+
+func TestExtractBodyHTMLTable(t *testing.T) {
+	got := extractBody(htmlEmail(`<p>Thanks for your order.</p>
+<table><tr><th>Item</th><th>Price</th></tr><tr><td>Widget</td><td>$10.00</td></tr></table>
+<p>See you soon.</p>`), 0, TruncateHead, quotesStrip)
+	want := "| Item   | Price  |\n| ------ | ------ |\n| Widget | $10.00 |"
+	if !strings.Contains(got, want) || !strings.Contains(got, "Thanks for your order.") || !strings.Contains(got, "See you soon.") {
+		t.Errorf("got %q, want the table as\n%s", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ID manifest: a successful result that mentions object IDs ends with a
+// fenced JSON block listing them, so clients reading only text can take
+// IDs without parsing each tool's lines:
+//
+//	```json jmap-ids
+//	{"v":1,"ids":["M1a2"],"blobs":["B9"]}
+//	```
+//
+// IDs a tool labels by kind ("[blob: X]", "[mailbox: X]", "Thread: X")
+// are listed under that kind, the ID column of email listings under
+// emails, and the rest ("[id: X]", "ID: X" lines) under ids. v is
+// idManifestVersion: fields are only added within a version, and it
+// changes when one changes meaning.
+
+// idManifestVersion is the "v" of the manifest.
+const idManifestVersion = 1
+
+// idManifestFence opens the manifest block.
+const idManifestFence = "```json jmap-ids\n"
+
+// idManifest is the JSON of the manifest block.
+type idManifest struct {
+	Version    int      `json:"v"`
+	IDs        []string `json:"ids,omitempty"`
+	Emails     []string `json:"emails,omitempty"`
+	Threads    []string `json:"threads,omitempty"`
+	Mailboxes  []string `json:"mailboxes,omitempty"`
+	Blobs      []string `json:"blobs,omitempty"`
+	Principals []string `json:"principals,omitempty"`
+	Contacts   []string `json:"contacts,omitempty"`
+}
+
+var (
+	// manifestBracketRE matches a bracketed annotation such as "[id: X]" or
+	// "[email: X, thread: Y, 3 message(s)]".
+	manifestBracketRE = regexp.MustCompile(`\[([^\[\]\n]+)\]`)
+	// manifestLabelRE matches one "label: ID" entry of an annotation.
+	manifestLabelRE = regexp.MustCompile(`^(id|email|thread|mailbox|blob|principal|contact id): ([A-Za-z0-9_-]+)$`)
+	// manifestLineRE matches "ID: X" and "Thread: X" header lines.
+	manifestLineRE = regexp.MustCompile(`(?m)^\s*(ID|Thread): ([A-Za-z0-9_-]+)\b`)
+	// manifestColumnRE matches the ID column of listings, which only the
+	// tools in emailColumnTools print; elsewhere it could match body text.
+	manifestColumnRE = regexp.MustCompile(`(?m)^([A-Za-z0-9_-]+)  `)
+)
+
+// emailColumnTools print one email per line, its ID first.
+var emailColumnTools = map[string]bool{"email_query": true, "email_triage_suggest": true}
+
+// buildIDManifest collects the IDs text mentions, each once per kind, or
+// returns nil when it mentions none. columns says whether lines start with
+// email IDs.
+func buildIDManifest(text string, columns bool) *idManifest {
+	m := &idManifest{Version: idManifestVersion}
+	seen := make(map[string]bool)
+	add := func(label, id string) {
+		if seen[label+"\x00"+id] {
+			return
+		}
+		seen[label+"\x00"+id] = true
+		list := map[string]*[]string{
+			"id": &m.IDs, "email": &m.Emails, "thread": &m.Threads, "mailbox": &m.Mailboxes,
+			"blob": &m.Blobs, "principal": &m.Principals, "contact id": &m.Contacts,
+		}[label]
+		*list = append(*list, id)
+	}
+	for _, b := range manifestBracketRE.FindAllStringSubmatch(text, -1) {
+		for _, entry := range strings.Split(b[1], ", ") {
+			if e := manifestLabelRE.FindStringSubmatch(entry); e != nil {
+				add(e[1], e[2])
+			}
+		}
+	}
+	for _, l := range manifestLineRE.FindAllStringSubmatch(text, -1) {
+		if l[1] == "Thread" {
+			add("thread", l[2])
+		} else {
+			add("id", l[2])
+		}
+	}
+	if columns {
+		for _, l := range manifestColumnRE.FindAllStringSubmatch(text, -1) {
+			add("email", l[1])
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	return m
+}
+
+// String renders the manifest as its fenced block.
+func (m *idManifest) String() string {
+	b, _ := json.Marshal(m)
+	return idManifestFence + string(b) + "\n```"
+}
+
+// withIDManifest appends the ID manifest of a successful result's text.
+func withIDManifest[In any](t *mcp.Tool, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	columns := emailColumnTools[t.Name]
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		res, out, err := h(ctx, req, in)
+		if res == nil || res.IsError {
+			return res, out, err
+		}
+		var text []string
+		for _, c := range res.Content {
+			if tc, ok := c.(*mcp.TextContent); ok {
+				text = append(text, tc.Text)
+			}
+		}
+		if m := buildIDManifest(strings.Join(text, "\n"), columns); m != nil {
+			res.Content = append(res.Content, &mcp.TextContent{Text: m.String()})
+		}
+		return res, out, err
+	}
+}
@@ -0,0 +1,65 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildIDManifest(t *testing.T) {
+	text := `Account: default/me [account: A1]
+ID: M1
+Thread: T1 (digest: jmap://thread/T1/summary)
+Attachments:
+  report.pdf (application/pdf, 10 bytes) [blob: B1]
+Moved to Archive [mailbox: mb2]
+1. Alice — waiting 2d since 2025-01-02 [email: M2, thread: T2, 3 message(s)]
+Created draft [id: D1]
+See [id: M1] again
+`
+	got := buildIDManifest(text, false)
+	want := &idManifest{
+		Version:   idManifestVersion,
+		IDs:       []string{"D1", "M1"},
+		Emails:    []string{"M2"},
+		Threads:   []string{"T2", "T1"},
+		Mailboxes: []string{"mb2"},
+		Blobs:     []string{"B1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	listing := "Total: 2 (returning 2)\n\nM1  2025-01-02 10:00  Alice  Lunch\nM2  2025-01-01 09:00  Bob  Report\n"
+	if got := buildIDManifest(listing, true); !reflect.DeepEqual(got.Emails, []string{"M1", "M2"}) {
+		t.Errorf("listing emails = %v", got.Emails)
+	}
+	if got := buildIDManifest("Hello  there\n", false); got != nil {
+		t.Errorf("body text read as IDs: %+v", got)
+	}
+}
+
+func TestIDManifestAppended(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Invoice", "", 1)
+	ctx := context.Background()
+
+	res, err := s.toolCalls["email_query"].call(ctx, nil)
+	if err != nil || res.IsError {
+		t.Fatalf("email_query: %v %s", err, resultText(res))
+	}
+	last := resultText(res)
+	last = last[strings.LastIndex(last, idManifestFence):]
+	body, ok := strings.CutSuffix(strings.TrimPrefix(last, idManifestFence), "\n```")
+	var m idManifest
+	if !ok || json.Unmarshal([]byte(body), &m) != nil || m.Version != idManifestVersion || !reflect.DeepEqual(m.Emails, []string{"e1"}) {
+		t.Errorf("manifest block %q", last)
+	}
+
+	res, _ = s.toolCalls["email_get"].call(ctx, json.RawMessage(`{"email_ids":["nope"]}`))
+	if strings.Contains(resultText(res), idManifestFence) {
+		t.Errorf("manifest on error:\n%s", resultText(res))
+	}
+}
@@ -0,0 +1,284 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ID checks: JMAP IDs (RFC 8620, section 1.2) are 1 to 255 characters from
+// the URL-safe base64 alphabet, so an argument outside it cannot name
+// anything and is refused before any request. IDs that tool results print
+// are remembered per owner, with the endpoint that returned them; when a
+// call fails with not_found, the error points at the endpoint that did
+// return the ID, or at a remembered ID one or two edits away, so a
+// mistyped character does not turn into a retry loop.
+
+// resultIDPattern finds the object IDs tool outputs print: "[id: X]" and
+// its kin, "ID: X" header lines, and the leading column of email_query
+// and triage listings.
+var resultIDPattern = regexp.MustCompile(`(?m)\[(?:id|blob|mailbox|principal|contact id): ([A-Za-z0-9_-]+)\]|^ID: ([A-Za-z0-9_-]+)$|^([A-Za-z0-9_-]+)  `)
+
+// maxRecentIDs bounds the IDs remembered per owner.
+const maxRecentIDs = 5000
+
+// recentIDs remembers, per owner, the endpoint whose results last
+// mentioned each ID, oldest dropped first.
+type recentIDs struct {
+	mu sync.Mutex
+	m  map[string]*ownerIDs // by ownerKey
+}
+
+type ownerIDs struct {
+	endpoint map[string]string
+	order    []string
+}
+
+// add records the IDs text mentions as returned by endpoint.
+func (r *recentIDs) add(owner, endpoint, text string) {
+	matches := resultIDPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m == nil {
+		r.m = make(map[string]*ownerIDs)
+	}
+	o := r.m[owner]
+	if o == nil {
+		o = &ownerIDs{endpoint: make(map[string]string)}
+		r.m[owner] = o
+	}
+	for _, m := range matches {
+		id := m[1] + m[2] + m[3]
+		if _, seen := o.endpoint[id]; !seen {
+			o.order = append(o.order, id)
+		}
+		o.endpoint[id] = endpoint
+	}
+	for len(o.order) > maxRecentIDs {
+		delete(o.endpoint, o.order[0])
+		o.order = o.order[1:]
+	}
+}
+
+// endpointOf returns the endpoint that last returned id to owner.
+func (r *recentIDs) endpointOf(owner, id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o := r.m[owner]
+	if o == nil {
+		return "", false
+	}
+	ep, ok := o.endpoint[id]
+	return ep, ok
+}
+
+// idOwner keys recentIDs by the caller's own credential, not the token of
+// the endpoint a call selected, so IDs from every endpoint land together.
+func (s *Server) idOwner(ctx context.Context) string {
+	if t := TokenFromContext(ctx); t != "" {
+		return ownerKey(t)
+	}
+	return ownerKey(s.token)
+}
+
+// similar returns the remembered ID of endpoint closest to id, when it is
+// within typoDistance and no other is as close.
+func (r *recentIDs) similar(owner, endpoint, id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o := r.m[owner]
+	if o == nil {
+		return "", false
+	}
+	limit := typoDistance(id)
+	best, bestDist, ties := "", limit+1, 0
+	for _, cand := range o.order {
+		if cand == id || o.endpoint[cand] != endpoint {
+			continue
+		}
+		d := editDistance(id, cand, limit)
+		switch {
+		case d < bestDist:
+			best, bestDist, ties = cand, d, 0
+		case d == bestDist:
+			ties++
+		}
+	}
+	if best == "" || ties > 0 {
+		return "", false
+	}
+	return best, true
+}
+
+// typoDistance is how many edits apart a remembered ID may be to be
+// suggested for id: one for short IDs, two for long ones.
+func typoDistance(id string) int {
+	if len(id) < 10 {
+		return 1
+	}
+	return 2
+}
+
+// editDistance is the optimal string alignment distance of a and b (edits
+// are insertions, deletions, substitutions, and swaps of neighbours),
+// or limit+1 when it exceeds limit.
+func editDistance(a, b string, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return min(prev[len(b)], limit+1)
+}
+
+// validID reports whether id is a well-formed JMAP ID.
+func validID(id string) bool {
+	if len(id) == 0 || len(id) > 255 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// notIDFields are "_id" arguments that are not JMAP IDs.
+var notIDFields = map[string]bool{"message_id": true, "list_id": true}
+
+// idField is a top-level input field holding JMAP IDs.
+type idField struct {
+	name  string
+	index int
+}
+
+// idFields returns the string and []string fields of the input struct t
+// whose JSON names are id, ids, or end in _id or _ids.
+func idFields(t reflect.Type) []idField {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []idField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || notIDFields[name] {
+			continue
+		}
+		if name != "id" && name != "ids" && !strings.HasSuffix(name, "_id") && !strings.HasSuffix(name, "_ids") {
+			continue
+		}
+		if f.Type.Kind() == reflect.String || f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String {
+			fields = append(fields, idField{name: name, index: i})
+		}
+	}
+	return fields
+}
+
+// badID returns the first malformed ID in in's ID fields.
+func badID(in reflect.Value, fields []idField) (field, id string, ok bool) {
+	for _, f := range fields {
+		v := in.Field(f.index)
+		if v.Kind() == reflect.String {
+			if id := v.String(); id != "" && !validID(id) {
+				return f.name, id, true
+			}
+			continue
+		}
+		for j := range v.Len() {
+			if id := v.Index(j).String(); !validID(id) {
+				return f.name, id, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// withIDCheck refuses malformed IDs in the call's ID arguments, remembers
+// the IDs a single-account result mentions, and annotates not_found errors
+// with where their IDs were returned or which remembered ID they resemble.
+// It relies on the accountRecorder withAccountHint puts in the context.
+func withIDCheck[In any](s *Server, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	fields := idFields(reflect.TypeFor[In]())
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		owner := s.idOwner(ctx)
+		if name, id, bad := badID(reflect.ValueOf(in), fields); bad {
+			msg := fmt.Sprintf("%s: %q is not a JMAP ID (1 to 255 letters, digits, \"-\", or \"_\")", name, id)
+			if guess, ok := s.recentIDs.similar(owner, endpointName(ctx), strings.TrimSpace(strings.Trim(id, `"'<>[]`))); ok {
+				msg += fmt.Sprintf("; did you mean %s?", guess)
+			}
+			return errorResult(invalidArgument("%s", msg)), nil, nil
+		}
+
+		res, out, err := h(ctx, req, in)
+		r := accountRecorderFromContext(ctx)
+		if res == nil || r == nil {
+			return res, out, err
+		}
+		// IDs can only be attributed when the call used one account.
+		accts := r.accounts()
+		if len(accts) != 1 {
+			return res, out, err
+		}
+		endpoint := accts[0].Endpoint
+		if !res.IsError {
+			for _, c := range res.Content {
+				if tc, ok := c.(*mcp.TextContent); ok {
+					s.recentIDs.add(owner, endpoint, tc.Text)
+				}
+			}
+			return res, out, err
+		}
+		te, ok := res.StructuredContent.(*toolError)
+		if !ok || te.Code != codeNotFound {
+			return res, out, err
+		}
+		for _, id := range te.IDs {
+			if ep, ok := s.recentIDs.endpointOf(owner, id); ok && ep != endpoint {
+				res.Content = append(res.Content, &mcp.TextContent{Text: fmt.Sprintf("Note: %s was returned by endpoint %q, not %q; pass endpoint=%q to act on it there.", id, ep, endpoint, ep)})
+				continue
+			}
+			if guess, ok := s.recentIDs.similar(owner, endpoint, id); ok {
+				if te.Suggestions == nil {
+					te.Suggestions = make(map[string]string)
+				}
+				te.Suggestions[id] = guess
+				res.Content = append(res.Content, &mcp.TextContent{Text: fmt.Sprintf("Note: %s was not found; did you mean %s (returned earlier in this session)?", id, guess)})
+			}
+		}
+		return res, out, err
+	}
+}
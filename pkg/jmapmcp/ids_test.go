@@ -0,0 +1,80 @@
+package jmapmcp
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResultIDPattern(t *testing.T) {
+	var r recentIDs
+	r.add("o", "work", "Total: 2 (returning 2)\n\nm1  2025-01-01  Alice  Hi\nCreated draft [id: d2]\nID: e3\n")
+	for _, id := range []string{"m1", "d2", "e3"} {
+		if ep, ok := r.endpointOf("o", id); !ok || ep != "work" {
+			t.Errorf("%s: %q, %v", id, ep, ok)
+		}
+	}
+	if _, ok := r.endpointOf("o", "Total"); ok {
+		t.Error("Total recorded as an ID")
+	}
+}
+
+func TestRecentIDsSimilar(t *testing.T) {
+	var r recentIDs
+	r.add("o", "default", "[id: Mabc123] [id: Mxyz789] [id: Ma1b2c3d4e5f6]")
+	tests := []struct {
+		id, want string
+	}{
+		{"Mabc124", "Mabc123"},           // substitution
+		{"Mbac123", "Mabc123"},           // swapped neighbours
+		{"Mabc12", "Mabc123"},            // dropped character
+		{"Ma1b2c3d4e5", "Ma1b2c3d4e5f6"}, // two edits on a long ID
+		{"Mabc999", ""},
+		{"Mabc123", ""}, // the ID itself is not a suggestion
+	}
+	for _, tt := range tests {
+		got, _ := r.similar("o", "default", tt.id)
+		if got != tt.want {
+			t.Errorf("similar(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+	if got, ok := r.similar("o", "work", "Mabc124"); ok {
+		t.Errorf("suggested %q from another endpoint", got)
+	}
+
+	r.add("o", "default", "[id: Mabc125]")
+	if got, ok := r.similar("o", "default", "Mabc124"); ok {
+		t.Errorf("ambiguous guess suggested: %q", got)
+	}
+}
+
+func TestIDCheckRefusesMalformedIDs(t *testing.T) {
+	s, _ := newFakeServer(t)
+	get := withAccountHint(s, withIDCheck(s, s.handleEmailGet))
+	res, _, _ := get(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e1", "e 2"}})
+	te, _ := res.StructuredContent.(*toolError)
+	if !res.IsError || te == nil || te.Code != codeInvalidArguments || !strings.Contains(resultText(res), `email_ids: "e 2" is not a JMAP ID`) {
+		t.Errorf("malformed ID accepted:\n%s", resultText(res))
+	}
+
+	if fields := idFields(reflect.TypeFor[EmailFindByMessageIDInput]()); len(fields) != 0 {
+		t.Errorf("message_id treated as a JMAP ID: %v", fields)
+	}
+}
+
+func TestIDCheckSuggestsRecentID(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "Mabc123", "Hello", "Hi", 1)
+	get := withAccountHint(s, withIDCheck(s, s.handleEmailGet))
+
+	if res, _, _ := get(context.Background(), nil, EmailGetInput{EmailIDs: []string{"Mabc123"}}); res.IsError {
+		t.Fatalf("email_get: %s", resultText(res))
+	}
+	res, _, _ := get(context.Background(), nil, EmailGetInput{EmailIDs: []string{"Mabc132"}})
+	out := resultText(res)
+	te, _ := res.StructuredContent.(*toolError)
+	if !res.IsError || te == nil || te.Suggestions["Mabc132"] != "Mabc123" || !strings.Contains(out, "did you mean Mabc123") {
+		t.Errorf("no suggestion:\n%s\n%#v", out, te)
+	}
+}
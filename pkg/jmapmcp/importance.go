@@ -0,0 +1,86 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Importance levels accepted by the compose tools and reported by
+// email_query and email_get.
+const (
+	ImportanceHigh   = "high"
+	ImportanceNormal = "normal"
+	ImportanceLow    = "low"
+)
+
+// importantKeyword is the JMAP keyword clients use for important mail
+// (RFC 8457).
+const importantKeyword = "$important"
+
+// checkImportance rejects unknown importance levels; "" means normal.
+func checkImportance(level string) error {
+	switch level {
+	case "", ImportanceHigh, ImportanceNormal, ImportanceLow:
+		return nil
+	}
+	return fmt.Errorf("unknown importance %q: expected high, normal, or low", level)
+}
+
+// applyImportance marks a draft high or low importance the way desktop
+// clients do: X-Priority and Importance headers, plus the $important
+// keyword for high. Normal leaves the draft unchanged.
+func applyImportance(draft *email.Email, level string) {
+	var priority, importance string
+	switch level {
+	case ImportanceHigh:
+		priority, importance = "1 (Highest)", "High"
+		draft.Keywords[importantKeyword] = true
+	case ImportanceLow:
+		priority, importance = "5 (Lowest)", "Low"
+	default:
+		return
+	}
+	draft.Headers = append(draft.Headers,
+		&email.Header{Name: "X-Priority", Value: " " + priority},
+		&email.Header{Name: "Importance", Value: " " + importance},
+	)
+}
+
+// emailImportance derives an email's importance from the $important keyword
+// and the X-Priority, Importance, and Priority headers, returning "" for
+// normal. Headers are only consulted when they were fetched.
+func emailImportance(e *email.Email) string {
+	if e.Keywords[importantKeyword] {
+		return ImportanceHigh
+	}
+	for _, h := range e.Headers {
+		v := strings.ToLower(strings.TrimSpace(h.Value))
+		switch strings.ToLower(h.Name) {
+		case "x-priority":
+			// "1 (Highest)" .. "5 (Lowest)"; 3 is normal.
+			switch {
+			case strings.HasPrefix(v, "1"), strings.HasPrefix(v, "2"):
+				return ImportanceHigh
+			case strings.HasPrefix(v, "4"), strings.HasPrefix(v, "5"):
+				return ImportanceLow
+			}
+		case "importance":
+			switch v {
+			case "high":
+				return ImportanceHigh
+			case "low":
+				return ImportanceLow
+			}
+		case "priority":
+			switch v {
+			case "urgent":
+				return ImportanceHigh
+			case "non-urgent":
+				return ImportanceLow
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestEmailImportance(t *testing.T) {
+	header := func(name, value string) *email.Email {
+		return &email.Email{Headers: []*email.Header{{Name: name, Value: value}}}
+	}
+	tests := []struct {
+		name string
+		e    *email.Email
+		want string
+	}{
+		{"keyword", &email.Email{Keywords: map[string]bool{"$important": true}}, ImportanceHigh},
+		{"x-priority highest", header("X-Priority", " 1 (Highest)"), ImportanceHigh},
+		{"x-priority high", header("X-Priority", "2"), ImportanceHigh},
+		{"x-priority normal", header("X-Priority", " 3 (Normal)"), ""},
+		{"x-priority lowest", header("X-Priority", " 5 (Lowest)"), ImportanceLow},
+		{"importance header", header("importance", " High"), ImportanceHigh},
+		{"priority non-urgent", header("Priority", " non-urgent"), ImportanceLow},
+		{"nothing", &email.Email{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := emailImportance(tt.e); got != tt.want {
+				t.Errorf("emailImportance() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailCreateImportance(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+
+	res, _, _ := s.handleEmailCreate(context.Background(), nil, EmailCreateInput{To: []string{"bob@example.com"}, Subject: "Outage", Importance: "urgent"})
+	if !res.IsError || !strings.Contains(resultText(res), `unknown importance "urgent"`) {
+		t.Errorf("expected unknown importance error, got %q", resultText(res))
+	}
+
+	res, _, _ = s.handleEmailCreate(context.Background(), nil, EmailCreateInput{To: []string{"bob@example.com"}, Subject: "Outage", Importance: ImportanceHigh})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	id := fake.Objects("Email")[0]
+
+	res, _, _ = s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{id}})
+	if out := resultText(res); !strings.Contains(out, "Importance: high") {
+		t.Errorf("email_get does not report importance:\n%s", out)
+	}
+	draft := fake.Object("Email", id)
+	if keywords, _ := draft["keywords"].(map[string]any); keywords["$important"] != true {
+		t.Errorf("$important not set: %v", draft["keywords"])
+	}
+	if headers, _ := draft["headers"].([]any); len(headers) != 2 {
+		t.Errorf("priority headers not set: %v", draft["headers"])
+	}
+}
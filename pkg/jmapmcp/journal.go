@@ -0,0 +1,296 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+// The operation journal keeps, per MCP session, what the last few bulk
+// mutations changed: the mailboxes emails were in before email_move,
+// email_delete (to Trash), label_apply, and label_remove, and the keywords
+// email_flag and email_classify changed. undo_last puts the most recent
+// batch back. Like selections, the journal belongs to the session and
+// tenant (calls outside a session share one journal per tenant) and ends
+// with the session. Kept in a store (-journals-file), it belongs to the
+// tenant instead and outlives sessions and restarts. Permanent deletion is
+// never journaled: it cannot be undone.
+
+const maxJournalEntries = 20 // per journal; the oldest are dropped first
+
+// journalEntry is one journaled batch.
+type journalEntry struct {
+	tool      string
+	endpoint  string
+	accountID jmap.ID
+	at        time.Time
+	mailboxes map[jmap.ID]map[jmap.ID]bool // email → its mailboxIds before the batch
+	keywords  map[jmap.ID]map[string]bool  // email → the changed keywords' prior values
+}
+
+// journalEntryJSON is how a journalEntry is saved in the store.
+type journalEntryJSON struct {
+	Tool      string                       `json:"tool"`
+	Endpoint  string                       `json:"endpoint,omitempty"`
+	AccountID jmap.ID                      `json:"accountId"`
+	At        time.Time                    `json:"at"`
+	Mailboxes map[jmap.ID]map[jmap.ID]bool `json:"mailboxes,omitempty"`
+	Keywords  map[jmap.ID]map[string]bool  `json:"keywords,omitempty"`
+}
+
+func (e *journalEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(journalEntryJSON{e.tool, e.endpoint, e.accountID, e.at, e.mailboxes, e.keywords})
+}
+
+func (e *journalEntry) UnmarshalJSON(data []byte) error {
+	var v journalEntryJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = journalEntry{v.Tool, v.Endpoint, v.AccountID, v.At, v.Mailboxes, v.Keywords}
+	return nil
+}
+
+// emailCount is how many emails the batch changed.
+func (e *journalEntry) emailCount() int {
+	return len(e.mailboxes) + len(e.keywords)
+}
+
+// sameBatch reports whether x and e record the same batch; once the store
+// is reread they are different values.
+func sameBatch(x, e *journalEntry) bool {
+	return x == e || x.tool == e.tool && x.accountID == e.accountID && x.at.Equal(e.at)
+}
+
+// Journals keeps the operation journals. The zero value keeps one per MCP
+// session in memory. With a store, there is one per tenant, keyed by its
+// owner key, saved there as JSON after every change and reread when
+// another process changed it. Processes sharing the store do not lock it,
+// so when two change the journals at once the last to save wins.
+type Journals struct {
+	mu       sync.Mutex
+	m        map[callSession][]*journalEntry // oldest first
+	sessions map[*mcp.ServerSession]bool     // sessions whose end is awaited
+	store    store.Store
+	doc      string    // the store's document name
+	modTime  time.Time // of the document as last read or written
+}
+
+// LoadJournals reads the journals saved in st as doc, a JSON object of the
+// form {"owner": [entry, ...]}. A missing document has none and is created
+// on the first batch.
+func LoadJournals(st store.Store, doc string) (*Journals, error) {
+	j := &Journals{store: st, doc: doc}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.reloadLocked(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// reloadLocked rereads the document when another process changed it since
+// it was last read or written. The caller holds j.mu.
+func (j *Journals) reloadLocked() error {
+	if j.store == nil {
+		return nil
+	}
+	modTime, err := j.store.Stat(j.doc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("journals: %w", err)
+	}
+	if modTime.Equal(j.modTime) {
+		return nil
+	}
+	data, modTime, err := j.store.Read(j.doc)
+	if err != nil {
+		return fmt.Errorf("journals: %w", err)
+	}
+	var owners map[string][]*journalEntry
+	if err := json.Unmarshal(data, &owners); err != nil {
+		return fmt.Errorf("journals %s: %w", j.doc, err)
+	}
+	j.m = make(map[callSession][]*journalEntry, len(owners))
+	for owner, entries := range owners {
+		j.m[callSession{owner: owner}] = entries
+	}
+	j.modTime = modTime
+	return nil
+}
+
+// saveLocked writes the journals to the store, if there is one. The caller
+// holds j.mu.
+func (j *Journals) saveLocked() error {
+	if j.store == nil {
+		return nil
+	}
+	owners := make(map[string][]*journalEntry, len(j.m))
+	for cs, entries := range j.m {
+		if len(entries) > 0 {
+			owners[cs.owner] = entries
+		}
+	}
+	modTime, err := store.WriteJSON(j.store, j.doc, owners)
+	if err != nil {
+		return fmt.Errorf("journals %s: %w", j.doc, err)
+	}
+	j.modTime = modTime
+	return nil
+}
+
+// key returns the journal the calls of cs record in: its session's, or
+// with a store its tenant's.
+func (j *Journals) key(cs callSession) callSession {
+	if j.store != nil {
+		return callSession{owner: cs.owner}
+	}
+	return cs
+}
+
+// push adds e as the most recent batch of cs. The call that made the batch
+// has succeeded, so failing to record it is only logged. When the store
+// cannot be reread, e is not recorded: saving the stale journals would
+// overwrite what another process saved.
+func (j *Journals) push(cs callSession, e *journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.reloadLocked(); err != nil {
+		log.Printf("Undo journal: %v; %s batch not recorded", err, e.tool)
+		return
+	}
+	if j.m == nil {
+		j.m = make(map[callSession][]*journalEntry)
+	}
+	cs = j.key(cs)
+	entries := append(j.m[cs], e)
+	if len(entries) > maxJournalEntries {
+		entries = slices.Delete(entries, 0, len(entries)-maxJournalEntries)
+	}
+	j.m[cs] = entries
+	if err := j.saveLocked(); err != nil {
+		log.Printf("Undo journal: %v", err)
+	}
+
+	if ss := cs.session; ss != nil && !j.sessions[ss] {
+		if j.sessions == nil {
+			j.sessions = make(map[*mcp.ServerSession]bool)
+		}
+		j.sessions[ss] = true
+		go func() {
+			ss.Wait()
+			j.drop(ss)
+		}()
+	}
+}
+
+// last returns the most recent batch of cs, or nil.
+func (j *Journals) last(cs callSession) (*journalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.reloadLocked(); err != nil {
+		return nil, err
+	}
+	entries := j.m[j.key(cs)]
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return entries[len(entries)-1], nil
+}
+
+// take removes e from the journal of cs before it is undone, and reports
+// whether it was still there, so two concurrent undo_last calls of this
+// process cannot undo the same batch twice.
+func (j *Journals) take(cs callSession, e *journalEntry) (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.reloadLocked(); err != nil {
+		return false, err
+	}
+	cs = j.key(cs)
+	entries := j.m[cs]
+	i := slices.IndexFunc(entries, func(x *journalEntry) bool { return sameBatch(x, e) })
+	if i < 0 {
+		return false, nil
+	}
+	taken := entries[i]
+	j.m[cs] = slices.Delete(entries, i, i+1)
+	if err := j.saveLocked(); err != nil {
+		j.m[cs] = slices.Insert(j.m[cs], i, taken)
+		return false, err
+	}
+	return true, nil
+}
+
+// restore puts back an entry whose undo failed, in order of when it was
+// recorded. Like push, it gives up when the store cannot be reread.
+func (j *Journals) restore(cs callSession, e *journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.reloadLocked(); err != nil {
+		log.Printf("Undo journal: %v; %s batch not restored", err, e.tool)
+		return
+	}
+	cs = j.key(cs)
+	if _, ok := j.m[cs]; !ok && j.store == nil {
+		return // the session ended meanwhile
+	}
+	if j.m == nil {
+		j.m = make(map[callSession][]*journalEntry)
+	}
+	entries := j.m[cs]
+	i, _ := slices.BinarySearchFunc(entries, e, func(x, e *journalEntry) int { return x.at.Compare(e.at) })
+	j.m[cs] = slices.Insert(entries, i, e)
+	if err := j.saveLocked(); err != nil {
+		log.Printf("Undo journal: %v", err)
+	}
+}
+
+// drop forgets the journals of an ended session.
+func (j *Journals) drop(ss *mcp.ServerSession) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for cs := range j.m {
+		if cs.session == ss {
+			delete(j.m, cs)
+		}
+	}
+	delete(j.sessions, ss)
+}
+
+// journal records the batch e of the call req, leaving out the emails the
+// server did not update. A batch that changed nothing is not recorded.
+func (s *Server) journal(ctx context.Context, req *mcp.CallToolRequest, e *journalEntry, notUpdated map[jmap.ID]*jmap.SetError) {
+	for id := range notUpdated {
+		delete(e.mailboxes, id)
+		delete(e.keywords, id)
+	}
+	if e.emailCount() == 0 {
+		return
+	}
+	e.endpoint, e.at = endpointName(ctx), time.Now()
+	s.journals.push(s.callSessionOf(ctx, req), e)
+}
+
+// priorMailboxes returns the mailboxes of emails, for a journal entry.
+func priorMailboxes(emails []*email.Email) map[jmap.ID]map[jmap.ID]bool {
+	out := make(map[jmap.ID]map[jmap.ID]bool, len(emails))
+	for _, e := range emails {
+		out[e.ID] = e.MailboxIDs
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package jmapmcp
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageSampleChars bounds how much of a body detectLanguage inspects.
+const languageSampleChars = 2000
+
+// scriptLanguages maps Unicode scripts that (nearly) identify a language on
+// their own to its ISO 639-1 code.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Thai, "th"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Cyrillic, "ru"},
+}
+
+// stopwords holds frequent function words for Latin-script languages; the
+// language with the most hits wins.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "to", "of", "for", "with", "this", "that", "have", "will", "please", "thanks"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "sie", "ich", "mit", "für", "auf", "wir", "bitte", "danke", "ein"},
+	"fr": {"le", "la", "les", "et", "est", "vous", "pour", "avec", "une", "des", "pas", "nous", "merci", "que", "dans"},
+	"es": {"el", "la", "los", "las", "y", "es", "para", "con", "una", "por", "que", "gracias", "usted", "del", "pero"},
+	"it": {"il", "lo", "gli", "e", "è", "per", "con", "una", "che", "non", "grazie", "sono", "della", "anche", "questo"},
+	"pt": {"o", "os", "as", "e", "é", "para", "com", "uma", "não", "obrigado", "você", "que", "do", "da", "mais"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "van", "voor", "met", "ik", "wij", "bedankt", "dat", "zijn", "ook"},
+}
+
+// detectLanguage returns a best-guess ISO 639-1 code for text, or empty when
+// there is too little signal. It is a cheap heuristic — script detection for
+// non-Latin alphabets, stopword frequency for Latin ones — not a classifier.
+func detectLanguage(text string) string {
+	if len(text) > languageSampleChars {
+		text = text[:languageSampleChars]
+	}
+
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, sl := range scriptLanguages {
+			if unicode.Is(sl.table, r) {
+				counts[sl.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	// Kana marks Japanese even though most characters may be Han.
+	if counts["ja"] > 0 && counts["ja"]+counts["zh"] > letters/3 {
+		return "ja"
+	}
+	best, bestN := "", 0
+	for lang, n := range counts {
+		if n > bestN || (n == bestN && lang < best) {
+			best, bestN = lang, n
+		}
+	}
+	if bestN > letters/3 {
+		return best
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	hits := make(map[string]int)
+	for _, w := range words {
+		for lang, list := range stopwords {
+			for _, sw := range list {
+				if w == sw {
+					hits[lang]++
+					break
+				}
+			}
+		}
+	}
+	best, bestN = "", 0
+	for lang, n := range hits {
+		if n > bestN || (n == bestN && lang < best) {
+			best, bestN = lang, n
+		}
+	}
+	// Require a minimum of evidence so short bodies stay "unknown".
+	if bestN < 3 {
+		return ""
+	}
+	return best
+}
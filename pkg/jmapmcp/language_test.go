@@ -0,0 +1,27 @@
+package jmapmcp
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"english", "Hi team, please review the attached report and send your comments to me by Friday. Thanks for your help with this.", "en"},
+		{"german", "Hallo zusammen, bitte prüft den Bericht und schickt mir eure Kommentare. Ich danke euch für die Hilfe, das ist nicht dringend.", "de"},
+		{"french", "Bonjour, merci pour votre message. Nous avons bien reçu les documents et nous reviendrons vers vous dans les prochains jours.", "fr"},
+		{"russian", "Здравствуйте! Пожалуйста, посмотрите отчёт и пришлите замечания до пятницы.", "ru"},
+		{"japanese", "お世話になっております。資料を確認してください。", "ja"},
+		{"korean", "안녕하세요. 보고서를 검토해 주세요.", "ko"},
+		{"too short", "OK", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLanguage(tt.input); got != tt.want {
+				t.Errorf("detectLanguage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Output locale (-locale). Dates in listings, byte sizes, and the few
+// words tool outputs generate around them (weekday names, "to" in time
+// ranges) follow the locale, so a deployment whose model works in German
+// is not handed a mix of German mail and English scaffolding. Timestamps
+// meant to be passed back to tools (RFC 3339 values, the Date header of
+// forwarded mail) are unaffected, as are error messages. -date-format adds
+// the UTC offset to every date and time shown, or writes them as RFC 3339,
+// and -relative-dates their age, so downstream date math has one
+// unambiguous form to parse.
+
+// Locale selects the output locale; see ParseLocale.
+type Locale string
+
+// LocaleDefault writes ISO 8601 dates and exact byte counts, as tool
+// outputs did before locales.
+const LocaleDefault Locale = ""
+
+// localeFormat is how one locale writes dates and sizes.
+type localeFormat struct {
+	dateTime string    // time.Format layout of a date and time
+	date     string    // time.Format layout of a date
+	weekdays [7]string // abbreviated weekday names, Sunday first; empty for English
+	to       string    // joins the ends of a range
+	decimal  string    // decimal separator of sizes; empty for exact byte counts
+}
+
+var localeFormats = map[Locale]localeFormat{
+	LocaleDefault: {dateTime: "2006-01-02 15:04", date: "2006-01-02", to: "to"},
+	"en":          {dateTime: "2006-01-02 15:04", date: "2006-01-02", to: "to", decimal: "."},
+	"en-US":       {dateTime: "01/02/2006 3:04 PM", date: "01/02/2006", to: "to", decimal: "."},
+	"en-GB":       {dateTime: "02/01/2006 15:04", date: "02/01/2006", to: "to", decimal: "."},
+	"de": {dateTime: "02.01.2006 15:04", date: "02.01.2006", to: "bis", decimal: ",",
+		weekdays: [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"}},
+	"fr": {dateTime: "02/01/2006 15:04", date: "02/01/2006", to: "au", decimal: ",",
+		weekdays: [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."}},
+	"es": {dateTime: "02/01/2006 15:04", date: "02/01/2006", to: "a", decimal: ",",
+		weekdays: [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"}},
+	"it": {dateTime: "02/01/2006 15:04", date: "02/01/2006", to: "a", decimal: ",",
+		weekdays: [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"}},
+	"nl": {dateTime: "02-01-2006 15:04", date: "02-01-2006", to: "tot", decimal: ",",
+		weekdays: [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"}},
+	"pt": {dateTime: "02/01/2006 15:04", date: "02/01/2006", to: "a", decimal: ",",
+		weekdays: [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"}},
+	"ru": {dateTime: "02.01.2006 15:04", date: "02.01.2006", to: "по", decimal: ",",
+		weekdays: [7]string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"}},
+}
+
+// ParseLocale validates a -locale value. Tags are matched case-insensitively
+// with "-" or "_" between language and region, and a region the table
+// lacks falls back to the language ("de-AT" is "de"); "default" and ""
+// are LocaleDefault.
+func ParseLocale(v string) (Locale, error) {
+	tag := strings.ReplaceAll(strings.TrimSpace(v), "_", "-")
+	if tag == "" || strings.EqualFold(tag, "default") {
+		return LocaleDefault, nil
+	}
+	lang, region, _ := strings.Cut(tag, "-")
+	lang = strings.ToLower(lang)
+	if region != "" {
+		l := Locale(lang + "-" + strings.ToUpper(region))
+		if _, ok := localeFormats[l]; ok {
+			return l, nil
+		}
+	}
+	if _, ok := localeFormats[Locale(lang)]; ok {
+		return Locale(lang), nil
+	}
+	return "", fmt.Errorf("unknown -locale %q (want default or one of %s)", v, strings.Join(localeNames(), ", "))
+}
+
+func localeNames() []string {
+	var names []string
+	for l := range localeFormats {
+		if l != LocaleDefault {
+			names = append(names, string(l))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (l Locale) format() localeFormat {
+	if f, ok := localeFormats[l]; ok {
+		return f
+	}
+	return localeFormats[LocaleDefault]
+}
+
+// dateTime writes t as a date and time to the minute.
+func (l Locale) dateTime(t time.Time) string {
+	return t.Format(l.format().dateTime)
+}
+
+// date writes the date of t.
+func (l Locale) date(t time.Time) string {
+	return t.Format(l.format().date)
+}
+
+// weekdayDateTime writes dateTime(t) after the abbreviated weekday.
+func (l Locale) weekdayDateTime(t time.Time) string {
+	return l.weekday(t) + " " + l.dateTime(t)
+}
+
+func (l Locale) weekday(t time.Time) string {
+	if name := l.format().weekdays[t.Weekday()]; name != "" {
+		return name
+	}
+	return t.Format("Mon")
+}
+
+// clock writes the time of day of t as dateTime does.
+func (l Locale) clock(t time.Time) string {
+	layout := l.format().dateTime
+	if i := strings.Index(layout, " "); i >= 0 {
+		layout = layout[i+1:]
+	}
+	return t.Format(layout)
+}
+
+// rangeText joins the ends of a range: "a to b".
+func (l Locale) rangeText(from, to string) string {
+	return from + " " + l.format().to + " " + to
+}
+
+// size writes a byte count: "N bytes" for LocaleDefault, otherwise in
+// binary units with one decimal and the locale's separator ("1,5 MB").
+func (l Locale) size(n int64) string {
+	f := l.format()
+	if f.decimal == "" {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	v := float64(n)
+	unit := ""
+	for _, u := range []string{"KB", "MB", "GB", "TB"} {
+		v /= 1024
+		unit = u
+		if v < 1024 {
+			break
+		}
+	}
+	return strings.Replace(fmt.Sprintf("%.1f %s", v, unit), ".", f.decimal, 1)
+}
+
+// DateFormat selects how tool outputs write a date and time, on top of the
+// locale; see ParseDateFormat.
+type DateFormat string
+
+const (
+	// DateFormatLocale writes the locale's date and time, without offset.
+	DateFormatLocale DateFormat = ""
+	// DateFormatOffset appends the UTC offset to the locale's date and
+	// time: "2025-03-04 15:30 +01:00".
+	DateFormatOffset DateFormat = "offset"
+	// DateFormatRFC3339 writes RFC 3339 timestamps whatever the locale:
+	// "2025-03-04T15:30:00+01:00".
+	DateFormatRFC3339 DateFormat = "rfc3339"
+)
+
+// ParseDateFormat validates a -date-format value: locale (or ""), offset,
+// or rfc3339.
+func ParseDateFormat(v string) (DateFormat, error) {
+	switch f := DateFormat(strings.ToLower(strings.TrimSpace(v))); f {
+	case "", "locale":
+		return DateFormatLocale, nil
+	case DateFormatOffset, DateFormatRFC3339:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown -date-format %q (want locale, offset, or rfc3339)", v)
+}
+
+// textStyle is how a server's tool outputs write dates and sizes: the
+// locale, the date format, and whether dates carry their age ("3h ago").
+// Ages are in English whatever the locale, like error messages.
+type textStyle struct {
+	Locale
+	dates    DateFormat
+	relative bool
+	now      func() time.Time // nil is time.Now
+}
+
+// dateTime writes t as a date and time in the date format, with its age
+// when relative.
+func (st textStyle) dateTime(t time.Time) string {
+	var out string
+	switch st.dates {
+	case DateFormatRFC3339:
+		out = t.Format(time.RFC3339)
+	case DateFormatOffset:
+		out = st.Locale.dateTime(t) + t.Format(" -07:00")
+	default:
+		out = st.Locale.dateTime(t)
+	}
+	if st.relative {
+		now := time.Now
+		if st.now != nil {
+			now = st.now
+		}
+		out += " (" + relativeAge(t, now()) + ")"
+	}
+	return out
+}
+
+// weekdayDateTime writes dateTime(t) after the abbreviated weekday.
+func (st textStyle) weekdayDateTime(t time.Time) string {
+	return st.weekday(t) + " " + st.dateTime(t)
+}
+
+// clock writes the time of day of t, with its offset unless the date
+// format is the locale's.
+func (st textStyle) clock(t time.Time) string {
+	switch st.dates {
+	case DateFormatRFC3339:
+		return t.Format("15:04:05Z07:00")
+	case DateFormatOffset:
+		return st.Locale.clock(t) + t.Format(" -07:00")
+	}
+	return st.Locale.clock(t)
+}
+
+// relativeAge writes how long before now t was, or how long after: "just
+// now", "5m ago", "3h ago", "2d ago", "in 4h".
+func relativeAge(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	var amount string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		amount = fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 48*time.Hour:
+		amount = fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		amount = fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+	if future {
+		return "in " + amount
+	}
+	return amount + " ago"
+}
@@ -0,0 +1,99 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLocale(t *testing.T) {
+	for in, want := range map[string]Locale{
+		"":        LocaleDefault,
+		"default": LocaleDefault,
+		"de":      "de",
+		"de_AT":   "de",
+		"en-us":   "en-US",
+		"FR-fr":   "fr",
+	} {
+		got, err := ParseLocale(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLocale(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseLocale("xx"); err == nil {
+		t.Error("ParseLocale(xx) succeeded")
+	}
+}
+
+func TestLocaleFormat(t *testing.T) {
+	at := time.Date(2025, 3, 4, 15, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		l                 Locale
+		dateTime, weekday string
+		size, span        string
+	}{
+		{LocaleDefault, "2025-03-04 15:30", "Tue 2025-03-04 15:30", "1536 bytes", "Tue 2025-03-04 15:30 to Wed 2025-03-05 15:30"},
+		{"en-US", "03/04/2025 3:30 PM", "Tue 03/04/2025 3:30 PM", "1.5 KB", "Tue 03/04/2025 3:30 PM to Wed 03/05/2025 3:30 PM"},
+		{"de", "04.03.2025 15:30", "Di 04.03.2025 15:30", "1,5 KB", "Di 04.03.2025 15:30 bis Mi 05.03.2025 15:30"},
+	} {
+		if got := tc.l.dateTime(at); got != tc.dateTime {
+			t.Errorf("%q dateTime = %q, want %q", tc.l, got, tc.dateTime)
+		}
+		if got := tc.l.weekdayDateTime(at); got != tc.weekday {
+			t.Errorf("%q weekdayDateTime = %q, want %q", tc.l, got, tc.weekday)
+		}
+		if got := tc.l.size(1536); got != tc.size {
+			t.Errorf("%q size = %q, want %q", tc.l, got, tc.size)
+		}
+		if got := formatSpan(textStyle{Locale: tc.l}, at, at.AddDate(0, 0, 1)); got != tc.span {
+			t.Errorf("%q span = %q, want %q", tc.l, got, tc.span)
+		}
+	}
+	if got := Locale("de").size(512); got != "512 B" {
+		t.Errorf("small size = %q", got)
+	}
+}
+
+func TestEmailQueryLocale(t *testing.T) {
+	s, fake := newFakeServer(t, WithLocale("de"))
+	addEmail(fake, "e1", "Hallo", "body", 4)
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{})
+	if out := resultText(res); !strings.Contains(out, "04.01.2025 10:00") {
+		t.Errorf("email_query with locale de:\n%s", out)
+	}
+}
+
+func TestDateFormat(t *testing.T) {
+	at := time.Date(2025, 3, 4, 15, 30, 0, 0, time.FixedZone("", 3600))
+	now := func() time.Time { return at.Add(3 * time.Hour) }
+	for _, tc := range []struct {
+		st              textStyle
+		dateTime, clock string
+	}{
+		{textStyle{}, "2025-03-04 15:30", "15:30"},
+		{textStyle{dates: DateFormatOffset}, "2025-03-04 15:30 +01:00", "15:30 +01:00"},
+		{textStyle{Locale: "en-US", dates: DateFormatOffset}, "03/04/2025 3:30 PM +01:00", "3:30 PM +01:00"},
+		{textStyle{Locale: "de", dates: DateFormatRFC3339}, "2025-03-04T15:30:00+01:00", "15:30:00+01:00"},
+		{textStyle{dates: DateFormatOffset, relative: true, now: now}, "2025-03-04 15:30 +01:00 (3h ago)", "15:30 +01:00"},
+	} {
+		if got := tc.st.dateTime(at); got != tc.dateTime {
+			t.Errorf("%+v dateTime = %q, want %q", tc.st, got, tc.dateTime)
+		}
+		if got := tc.st.clock(at); got != tc.clock {
+			t.Errorf("%+v clock = %q, want %q", tc.st, got, tc.clock)
+		}
+	}
+	for d, want := range map[time.Duration]string{
+		20 * time.Second: "just now", 5 * time.Minute: "5m ago", 47 * time.Hour: "47h ago",
+		72 * time.Hour: "3d ago", -4 * time.Hour: "in 4h",
+	} {
+		if got := relativeAge(at.Add(-d), at); got != want {
+			t.Errorf("relativeAge(%v) = %q, want %q", d, got, want)
+		}
+	}
+	if _, err := ParseDateFormat("iso"); err == nil {
+		t.Error("ParseDateFormat(iso) succeeded")
+	}
+}
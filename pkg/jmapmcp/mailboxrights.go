@@ -0,0 +1,176 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+)
+
+// Mailbox rights (myRights, RFC 8621 section 2) are checked before Email/set
+// and Mailbox/set, so that changing a shared mailbox the user has limited
+// access to fails with a clear error instead of the server's generic
+// forbidden. Mailboxes without myRights (servers that omit it) are not
+// checked.
+
+// mailboxRights lists the rights in display order with the action each one
+// permits, for error messages. maySubmit is left out: it concerns sending,
+// and many servers deny it on every mailbox.
+var mailboxRights = []struct {
+	name, short, action string
+	granted             func(*mailbox.Rights) bool
+}{
+	{"mayReadItems", "read", "read emails in", func(r *mailbox.Rights) bool { return r.MayReadItems }},
+	{"mayAddItems", "add", "add emails to", func(r *mailbox.Rights) bool { return r.MayAddItems }},
+	{"mayRemoveItems", "remove", "remove emails from", func(r *mailbox.Rights) bool { return r.MayRemoveItems }},
+	{"maySetSeen", "set-seen", "mark emails read in", func(r *mailbox.Rights) bool { return r.MaySetSeen }},
+	{"maySetKeywords", "set-keywords", "flag emails in", func(r *mailbox.Rights) bool { return r.MaySetKeywords }},
+	{"mayCreateChild", "create-child", "create mailboxes in", func(r *mailbox.Rights) bool { return r.MayCreateChild }},
+	{"mayRename", "rename", "rename or move", func(r *mailbox.Rights) bool { return r.MayRename }},
+	{"mayDelete", "delete", "delete", func(r *mailbox.Rights) bool { return r.MayDelete }},
+}
+
+// deniedRights returns the short names of the rights mb lacks, or nil when
+// the server did not report myRights.
+func deniedRights(mb *mailbox.Mailbox) []string {
+	if mb.Rights == nil {
+		return nil
+	}
+	var denied []string
+	for _, r := range mailboxRights {
+		if !r.granted(mb.Rights) {
+			denied = append(denied, r.short)
+		}
+	}
+	return denied
+}
+
+// checkMailboxRight returns an error naming the mailbox when its myRights
+// deny the given right (e.g. "mayRemoveItems").
+func checkMailboxRight(mb *mailbox.Mailbox, right string) error {
+	if mb == nil || mb.Rights == nil {
+		return nil
+	}
+	for _, r := range mailboxRights {
+		if r.name == right {
+			if r.granted(mb.Rights) {
+				return nil
+			}
+			return &toolError{
+				Code:    codeForbidden,
+				Message: fmt.Sprintf("no permission to %s shared mailbox %q [id: %s]: myRights.%s is false", r.action, mb.Name, mb.ID, right),
+				IDs:     []string{string(mb.ID)},
+			}
+		}
+	}
+	return nil
+}
+
+// checkEmailMoveRights checks that emails may leave their current mailboxes
+// for dest (mayRemoveItems on each one left, mayAddItems on dest), or, with
+// an empty dest, be destroyed (mayRemoveItems on every mailbox they are in).
+// Emails that are not found are left for Email/set to report.
+func checkEmailMoveRights(ctx context.Context, client JMAPClient, accountID jmap.ID, emailIDs []string, dest jmap.ID) error {
+	mailboxes, emails, err := fetchMoveRights(ctx, client, accountID, emailIDs)
+	if err != nil {
+		return err
+	}
+	return checkMoveRights(mailboxes, emails, dest)
+}
+
+// fetchMoveRights fetches, in one request, the account's mailboxes with
+// their roles and myRights and the current mailboxes of emailIDs. Callers
+// that also need a mailbox by role take it from the result rather than
+// asking again.
+func fetchMoveRights(ctx context.Context, client JMAPClient, accountID jmap.ID, emailIDs []string) (map[jmap.ID]*mailbox.Mailbox, []*email.Email, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "name", "role", "myRights"}})
+	req.Invoke(&email.Get{Account: accountID, IDs: toJMAPIDSlice(emailIDs), Properties: []string{"id", "mailboxIds"}})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rights check: %w", err)
+	}
+
+	mailboxes := make(map[jmap.ID]*mailbox.Mailbox)
+	var emails []*email.Email
+	for _, inv := range callResponses(req, resp) {
+		switch args := inv.Args.(type) {
+		case *mailbox.GetResponse:
+			for _, mb := range args.List {
+				mailboxes[mb.ID] = mb
+			}
+		case *email.GetResponse:
+			emails = args.List
+		case *jmap.MethodError:
+			return nil, nil, args
+		default:
+			return nil, nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+	return mailboxes, emails, nil
+}
+
+// checkMoveRights is checkEmailMoveRights on already fetched mailboxes and
+// emails.
+func checkMoveRights(mailboxes map[jmap.ID]*mailbox.Mailbox, emails []*email.Email, dest jmap.ID) error {
+	for _, e := range emails {
+		for _, id := range sortedKeys(idStrings(e.MailboxIDs)) {
+			if jmap.ID(id) == dest {
+				continue
+			}
+			if err := checkMailboxRight(mailboxes[jmap.ID(id)], "mayRemoveItems"); err != nil {
+				return fmt.Errorf("email %s: %w", e.ID, err)
+			}
+		}
+		if dest != "" && !e.MailboxIDs[dest] {
+			if err := checkMailboxRight(mailboxes[dest], "mayAddItems"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mailboxWithRole returns the ID of the mailbox in mailboxes with role, as
+// findMailboxByRole does for a fresh Mailbox/get.
+func mailboxWithRole(mailboxes map[jmap.ID]*mailbox.Mailbox, role mailbox.Role) (jmap.ID, error) {
+	for _, id := range sortedKeys(mailboxIDStrings(mailboxes)) {
+		if mailboxes[jmap.ID(id)].Role == role {
+			return jmap.ID(id), nil
+		}
+	}
+	return "", fmt.Errorf("no mailbox with role %q found", role)
+}
+
+// mailboxIDStrings returns the IDs of mailboxes as string keys for sortedKeys.
+func mailboxIDStrings(mailboxes map[jmap.ID]*mailbox.Mailbox) map[string]bool {
+	out := make(map[string]bool, len(mailboxes))
+	for id := range mailboxes {
+		out[string(id)] = true
+	}
+	return out
+}
+
+// idStrings converts an ID set to string keys for sortedKeys.
+func idStrings(ids map[jmap.ID]bool) map[string]bool {
+	out := make(map[string]bool, len(ids))
+	for id, in := range ids {
+		if in {
+			out[string(id)] = true
+		}
+	}
+	return out
+}
+
+// formatDeniedRights renders the rights a mailbox lacks for mailbox_get.
+func formatDeniedRights(mb *mailbox.Mailbox) string {
+	denied := deniedRights(mb)
+	if len(denied) == 0 {
+		return ""
+	}
+	return " (may not: " + strings.Join(denied, ", ") + ")"
+}
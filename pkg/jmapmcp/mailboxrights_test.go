@@ -0,0 +1,94 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+// addSharedMailboxes seeds a full-rights Archive, a read-only shared
+// mailbox holding e1, and a label the user may add to but not remove from.
+func addSharedMailboxes(fake *jmapfake.Server) {
+	all := map[string]any{"mayReadItems": true, "mayAddItems": true, "mayRemoveItems": true, "maySetSeen": true,
+		"maySetKeywords": true, "mayCreateChild": true, "mayRename": true, "mayDelete": true}
+	fake.Add("Mailbox", map[string]any{"id": "archive", "name": "Archive", "role": "archive", "myRights": all})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash", "myRights": all})
+	fake.Add("Mailbox", map[string]any{"id": "team", "name": "Team", "myRights": map[string]any{"mayReadItems": true, "maySetSeen": true}})
+	fake.Add("Mailbox", map[string]any{"id": "dropbox", "name": "Dropbox", "myRights": map[string]any{"mayReadItems": true, "mayAddItems": true}})
+	fake.Add("Email", map[string]any{"id": "e1", "subject": "Shared", "mailboxIds": map[string]any{"team": true}})
+	fake.Add("Email", map[string]any{"id": "e2", "subject": "Mine", "mailboxIds": map[string]any{"archive": true}})
+}
+
+func TestMailboxGetShowsDeniedRights(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addSharedMailboxes(fake)
+	res, _, _ := s.handleMailboxGet(context.Background(), nil, MailboxGetInput{})
+	out := resultText(res)
+	if !strings.Contains(out, "Team (folder) — 0 emails, 0 unread [id: team] (may not: add, remove, set-keywords, create-child, rename, delete)") {
+		t.Errorf("missing rights:\n%s", out)
+	}
+	if strings.Contains(out, "[id: archive] (may not") {
+		t.Errorf("full-rights mailbox annotated:\n%s", out)
+	}
+}
+
+func TestRightsPrecheck(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addSharedMailboxes(fake)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() string
+		want string
+	}{
+		{"move out of read-only", func() string {
+			res, _, _ := s.handleEmailMove(ctx, nil, EmailMoveInput{EmailIDs: []string{"e1"}, MailboxID: "archive"})
+			return resultText(res)
+		}, `email e1: no permission to remove emails from shared mailbox "Team" [id: team]: myRights.mayRemoveItems is false`},
+		{"move into read-only", func() string {
+			res, _, _ := s.handleEmailMove(ctx, nil, EmailMoveInput{EmailIDs: []string{"e2"}, MailboxID: "team"})
+			return resultText(res)
+		}, `no permission to add emails to shared mailbox "Team"`},
+		{"trash", func() string {
+			res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}})
+			return resultText(res)
+		}, "no permission to remove emails from"},
+		{"destroy", func() string {
+			res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}, Permanent: true})
+			return resultText(res)
+		}, "no permission to remove emails from"},
+		{"label remove", func() string {
+			res, _, _ := s.handleLabelRemove(ctx, nil, LabelInput{EmailIDs: []string{"e2"}, MailboxIDs: []string{"dropbox"}})
+			return resultText(res)
+		}, `no permission to remove emails from shared mailbox "Dropbox"`},
+		{"mailbox destroy", func() string {
+			res, _, _ := s.handleMailboxSet(ctx, nil, MailboxSetInput{Destroy: []string{"team"}})
+			return resultText(res)
+		}, `no permission to delete shared mailbox "Team"`},
+		{"mailbox create child", func() string {
+			res, _, _ := s.handleMailboxSet(ctx, nil, MailboxSetInput{Create: map[string]MailboxSetCreate{"c": {Name: "Sub", ParentID: "team"}}})
+			return resultText(res)
+		}, `no permission to create mailboxes in shared mailbox "Team"`},
+	}
+	for _, tt := range tests {
+		if got := tt.call(); !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if boxes := fake.Object("Email", "e1")["mailboxIds"].(map[string]any); boxes["team"] != true || len(boxes) != 1 {
+		t.Errorf("e1 changed despite refusal: %v", boxes)
+	}
+
+	// Allowed operations still go through.
+	res, _, _ := s.handleEmailMove(ctx, nil, EmailMoveInput{EmailIDs: []string{"e2"}, MailboxID: "trash"})
+	if res.IsError {
+		t.Errorf("move refused: %s", resultText(res))
+	}
+	res, _, _ = s.handleLabelApply(ctx, nil, LabelInput{EmailIDs: []string{"e2"}, MailboxIDs: []string{"dropbox"}})
+	if res.IsError {
+		t.Errorf("label apply refused: %s", resultText(res))
+	}
+}
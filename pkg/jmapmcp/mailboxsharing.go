@@ -0,0 +1,196 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/mailbox"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/principals"
+)
+
+// Mailbox sharing (JMAP Sharing, RFC 9670): servers advertising the
+// principals capability list who else may access a mailbox in its
+// shareWith property, a map from principal ID to rights in the myRights
+// format. mailbox_get shows it and mailbox_set changes it one principal at
+// a time with "shareWith/<principal>" patches, so other grants are left as
+// they are.
+
+// shareRightNames are the rights a share may grant, by short name:
+// mailboxRights plus sending as the mailbox owner.
+var shareRightNames = func() map[string]string {
+	names := map[string]string{"submit": "maySubmit"}
+	for _, r := range mailboxRights {
+		names[r.short] = r.name
+	}
+	return names
+}()
+
+// sharingSupported reports whether the server implements JMAP Sharing.
+func sharingSupported(session *jmap.Session) bool {
+	_, ok := session.Capabilities[principals.URI]
+	return ok
+}
+
+// shareRights turns short right names into the rights object of a
+// shareWith patch, with every right present. "all" grants every right but
+// submit.
+func shareRights(names []string) (map[string]bool, error) {
+	rights := make(map[string]bool, len(shareRightNames))
+	for _, name := range shareRightNames {
+		rights[name] = false
+	}
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "all" {
+			for _, r := range mailboxRights {
+				rights[r.name] = true
+			}
+			continue
+		}
+		name, ok := shareRightNames[n]
+		if !ok {
+			return nil, invalidArgument("unknown mailbox right %q (use %s, or all)", n, strings.Join(slices.Sorted(maps.Keys(shareRightNames)), ", "))
+		}
+		rights[name] = true
+	}
+	return rights, nil
+}
+
+// grantedRights returns the short names of the rights r grants.
+func grantedRights(r *mailbox.Rights) []string {
+	var granted []string
+	for _, right := range mailboxRights {
+		if right.granted(r) {
+			granted = append(granted, right.short)
+		}
+	}
+	if r.MaySubmit {
+		granted = append(granted, "submit")
+	}
+	return granted
+}
+
+// fetchPrincipals returns the principals the server lists, by ID.
+func fetchPrincipals(ctx context.Context, client JMAPClient) (map[jmap.ID]*principals.Principal, error) {
+	accountID := client.Session().PrimaryAccounts[principals.URI]
+	if accountID == "" {
+		return nil, capabilityMissing(client.Session(), principals.URI)
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&principals.Get{Account: accountID, Properties: []string{"id", "type", "name", "email"}})
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Principal/get")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *principals.GetResponse:
+		out := make(map[jmap.ID]*principals.Principal, len(args.List))
+		for _, p := range args.List {
+			out[p.ID] = p
+		}
+		return out, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// resolvePrincipal returns the ID of the principal named by who: a
+// principal ID, or the email address of one.
+func resolvePrincipal(who string, known map[jmap.ID]*principals.Principal) (jmap.ID, error) {
+	if p := known[jmap.ID(who)]; p != nil {
+		return p.ID, nil
+	}
+	if strings.Contains(who, "@") {
+		for _, id := range slices.Sorted(maps.Keys(known)) {
+			if strings.EqualFold(known[id].Email, who) {
+				return id, nil
+			}
+		}
+	}
+	return "", notFoundError([]string{who}, "no principal %q on this server; use an ID or email address from mailbox_get's sharing list or the server's directory", who)
+}
+
+// principalLabel names a principal for display.
+func principalLabel(id jmap.ID, known map[jmap.ID]*principals.Principal) string {
+	p := known[id]
+	switch {
+	case p == nil:
+		return string(id)
+	case p.Email != "" && p.Name != "":
+		return fmt.Sprintf("%s <%s> [principal: %s]", p.Name, p.Email, id)
+	case p.Email != "":
+		return fmt.Sprintf("%s [principal: %s]", p.Email, id)
+	case p.Name != "":
+		return fmt.Sprintf("%s [principal: %s]", p.Name, id)
+	}
+	return string(id)
+}
+
+// mailboxShares reads the shareWith of each mailbox in the Mailbox/get
+// responses among the raw response bodies, by mailbox ID. go-jmap's
+// Mailbox has no shareWith, so it cannot come from the decoded response.
+func mailboxShares(bodies [][]byte) map[jmap.ID]map[jmap.ID]*mailbox.Rights {
+	out := map[jmap.ID]map[jmap.ID]*mailbox.Rights{}
+	for _, body := range bodies {
+		var resp struct {
+			MethodResponses []json.RawMessage `json:"methodResponses"`
+		}
+		if json.Unmarshal(body, &resp) != nil {
+			continue
+		}
+		for _, raw := range resp.MethodResponses {
+			var inv []json.RawMessage
+			var name string
+			if json.Unmarshal(raw, &inv) != nil || len(inv) < 2 || json.Unmarshal(inv[0], &name) != nil || name != "Mailbox/get" {
+				continue
+			}
+			var args struct {
+				List []struct {
+					ID        jmap.ID                     `json:"id"`
+					ShareWith map[jmap.ID]*mailbox.Rights `json:"shareWith"`
+				} `json:"list"`
+			}
+			if json.Unmarshal(inv[1], &args) != nil {
+				continue
+			}
+			for _, mb := range args.List {
+				if len(mb.ShareWith) > 0 {
+					out[mb.ID] = mb.ShareWith
+				}
+			}
+		}
+	}
+	return out
+}
+
+// formatShares renders a mailbox's shareWith for mailbox_get, one indented
+// line per principal, or "" when the mailbox is not shared.
+func formatShares(shares map[jmap.ID]*mailbox.Rights, known map[jmap.ID]*principals.Principal) string {
+	if len(shares) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, id := range slices.Sorted(maps.Keys(shares)) {
+		r := shares[id]
+		if r == nil {
+			continue
+		}
+		granted := grantedRights(r)
+		if len(granted) == 0 {
+			granted = []string{"nothing"}
+		}
+		fmt.Fprintf(&sb, "  shared with %s: %s\n", principalLabel(id, known), strings.Join(granted, ", "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,63 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/principals"
+)
+
+func TestMailboxSharing(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	fake.AddCapability(string(principals.URI), nil)
+	fake.Add("Principal", map[string]any{"id": "p1", "type": "individual", "name": "Ann", "email": "ann@example.org"})
+	fake.Add("Principal", map[string]any{"id": "p2", "type": "individual", "name": "Ben", "email": "ben@example.org"})
+	fake.Add("Mailbox", map[string]any{"id": "team", "name": "Team", "shareWith": map[string]any{
+		"p1": map[string]any{"mayReadItems": true, "maySetSeen": true},
+	}})
+
+	res, _, _ := s.handleMailboxSet(ctx, nil, MailboxSetInput{Update: map[string]MailboxSetUpdate{
+		"team": {Share: map[string][]string{"Ben@Example.org": {"read", "add"}, "p1": {}}},
+	}})
+	if res.IsError {
+		t.Fatalf("share: %s", resultText(res))
+	}
+	shares, _ := fake.Object("Mailbox", "team")["shareWith"].(map[string]any)
+	if _, ok := shares["p1"]; ok || len(shares) != 1 {
+		t.Fatalf("shareWith = %v, want only p2", shares)
+	}
+	if ben, _ := shares["p2"].(map[string]any); ben["mayReadItems"] != true || ben["mayAddItems"] != true || ben["mayDelete"] != false {
+		t.Errorf("p2 rights = %v", shares["p2"])
+	}
+
+	res, _, _ = s.handleMailboxGet(ctx, nil, MailboxGetInput{})
+	if out := resultText(res); !strings.Contains(out, "  shared with Ben <ben@example.org> [principal: p2]: read, add\n") {
+		t.Errorf("mailbox_get:\n%s", out)
+	}
+
+	res, _, _ = s.handleMailboxSet(ctx, nil, MailboxSetInput{Update: map[string]MailboxSetUpdate{
+		"team": {Share: map[string][]string{"cat@example.org": {"read"}}},
+	}})
+	if !res.IsError || !strings.Contains(resultText(res), `no principal "cat@example.org"`) {
+		t.Errorf("unknown principal:\n%s", resultText(res))
+	}
+	res, _, _ = s.handleMailboxSet(ctx, nil, MailboxSetInput{Update: map[string]MailboxSetUpdate{
+		"team": {Share: map[string][]string{"p1": {"fly"}}},
+	}})
+	if !res.IsError || !strings.Contains(resultText(res), `unknown mailbox right "fly"`) {
+		t.Errorf("unknown right:\n%s", resultText(res))
+	}
+}
+
+func TestMailboxSharingUnsupported(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "team", "name": "Team"})
+	res, _, _ := s.handleMailboxSet(context.Background(), nil, MailboxSetInput{Update: map[string]MailboxSetUpdate{
+		"team": {Share: map[string][]string{"p1": {"read"}}},
+	}})
+	if te, ok := res.StructuredContent.(*toolError); !ok || te.Code != codeCapabilityMissing {
+		t.Errorf("share without JMAP Sharing: %s", resultText(res))
+	}
+}
@@ -0,0 +1,128 @@
+package jmapmcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+// maxMailboxSnapshots caps the named snapshots kept per user; the oldest
+// are dropped first.
+const maxMailboxSnapshots = 20
+
+// snapshotMailbox is one mailbox as a snapshot saw it.
+type snapshotMailbox struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID string `json:"parentId,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// mailboxSnapshot is a saved mailbox hierarchy.
+type mailboxSnapshot struct {
+	Saved     time.Time         `json:"saved"`
+	Endpoint  string            `json:"endpoint"`
+	Mailboxes []snapshotMailbox `json:"mailboxes"`
+}
+
+// MailboxSnapshots keeps named snapshots of each JMAP user's mailbox
+// hierarchy, keyed by the session username, for mailbox_diff to compare
+// the live tree against. When it has a store, the snapshots are saved there
+// as JSON after every change so they survive restarts; the zero value keeps
+// them in memory.
+type MailboxSnapshots struct {
+	mu    sync.Mutex
+	store store.Store
+	doc   string                                 // the store's document name
+	users map[string]map[string]*mailboxSnapshot // username → snapshot name → snapshot
+}
+
+// LoadMailboxSnapshots reads the snapshots saved in st as doc, a JSON
+// object of the form {"user": {"name": snapshot}}. A missing document has
+// no snapshots and is created on the first one.
+func LoadMailboxSnapshots(st store.Store, doc string) (*MailboxSnapshots, error) {
+	m := &MailboxSnapshots{store: st, doc: doc, users: make(map[string]map[string]*mailboxSnapshot)}
+	data, _, err := st.Read(doc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mailbox snapshots: %w", err)
+	}
+	if err := json.Unmarshal(data, &m.users); err != nil {
+		return nil, fmt.Errorf("mailbox snapshots %s: %w", doc, err)
+	}
+	return m, nil
+}
+
+// get returns user's snapshot called name, or nil.
+func (m *MailboxSnapshots) get(user, name string) *mailboxSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.users[user][name]
+}
+
+// names returns the names of user's snapshots, oldest first.
+func (m *MailboxSnapshots) names(user string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.namesLocked(user)
+}
+
+func (m *MailboxSnapshots) namesLocked(user string) []string {
+	names := make([]string, 0, len(m.users[user]))
+	for name := range m.users[user] {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return m.users[user][a].Saved.Compare(m.users[user][b].Saved)
+	})
+	return names
+}
+
+// put saves snap as user's snapshot called name, replacing one of the same
+// name and dropping the oldest beyond maxMailboxSnapshots, and saves the
+// store. When saving fails the previous snapshots are restored.
+func (m *MailboxSnapshots) put(user, name string, snap *mailboxSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.users == nil {
+		m.users = make(map[string]map[string]*mailboxSnapshot)
+	}
+	previous := m.users[user]
+	next := make(map[string]*mailboxSnapshot, len(previous)+1)
+	for n, s := range previous {
+		next[n] = s
+	}
+	next[name] = snap
+	m.users[user] = next
+	for names := m.namesLocked(user); len(names) > maxMailboxSnapshots; names = names[1:] {
+		delete(next, names[0])
+	}
+	if err := m.save(); err != nil {
+		if previous == nil {
+			delete(m.users, user)
+		} else {
+			m.users[user] = previous
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the snapshots to their document. The caller holds m.mu.
+func (m *MailboxSnapshots) save() error {
+	if m.store == nil {
+		return nil
+	}
+	if _, err := store.WriteJSON(m.store, m.doc, m.users); err != nil {
+		return fmt.Errorf("saving mailbox snapshots: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package jmapmcp
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+func TestMailboxSnapshotsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "mailbox-snapshots.json")
+	m, err := LoadMailboxSnapshots(store.Dir(""), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range maxMailboxSnapshots + 2 {
+		snap := &mailboxSnapshot{Saved: time.Unix(int64(i), 0), Endpoint: DefaultEndpoint, Mailboxes: []snapshotMailbox{{ID: "m1", Name: "Inbox", Role: "inbox"}}}
+		if err := m.put("me@example.org", "s"+strconv.Itoa(i), snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := LoadMailboxSnapshots(store.Dir(""), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := reloaded.names("me@example.org")
+	if len(names) != maxMailboxSnapshots || names[0] != "s2" || names[len(names)-1] != "s21" {
+		t.Errorf("names = %v", names)
+	}
+	if snap := reloaded.get("me@example.org", "s21"); snap == nil || len(snap.Mailboxes) != 1 || snap.Mailboxes[0].Role != "inbox" {
+		t.Errorf("s21 = %+v", snap)
+	}
+	if got := reloaded.names("other@example.org"); len(got) != 0 {
+		t.Errorf("other user's snapshots = %v", got)
+	}
+}
@@ -0,0 +1,164 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+)
+
+// Mailbox views are the columns and sort email_query uses by default in a
+// mailbox with a given role, so that listing Sent shows whom mail went to
+// and when it was sent rather than the user's own address. A call that
+// names its fields or a preset that names its sort keeps them. Operators
+// replace or add views with -mailbox-views-file, a JSON array such as
+//
+//	[{"role": "sent", "sort": "sent", "columns": ["sentAt", "to", "subject"]},
+//	 {"role": "archive", "sort": "oldest"}]
+//
+// and a view with neither sort nor columns turns a role's defaults off.
+
+// mailboxRoleTTL is how long the role of a mailbox is cached.
+const mailboxRoleTTL = 10 * time.Minute
+
+// mailboxRoleSize is the cache estimate of one mailbox role.
+const mailboxRoleSize = 64
+
+// MailboxView is the email_query display default of one mailbox role.
+type MailboxView struct {
+	Role    string   `json:"role"`              // a mailbox role, e.g. sent or drafts
+	Sort    string   `json:"sort,omitempty"`    // a key of queryPresetSorts; empty is newest
+	Columns []string `json:"columns,omitempty"` // email_query fields; empty is its default
+}
+
+// defaultMailboxViews are the views of roles whose mail reads better
+// without the inbox defaults. Drafts are replaced on every save, so their
+// receivedAt is when they were last changed.
+var defaultMailboxViews = []MailboxView{
+	{Role: "sent", Sort: "sent", Columns: []string{"sentAt", "to", "size", "subject"}},
+	{Role: "drafts", Columns: []string{"receivedAt", "to", "subject"}},
+}
+
+var mailboxRoleRe = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// LoadMailboxViews reads the views listed at path, a JSON array of views.
+func LoadMailboxViews(path string) ([]MailboxView, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("mailbox views: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var views []MailboxView
+	if err := dec.Decode(&views); err != nil {
+		return nil, fmt.Errorf("mailbox views %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(views))
+	for _, v := range views {
+		switch {
+		case !mailboxRoleRe.MatchString(v.Role):
+			return nil, fmt.Errorf("mailbox views %s: role %q must be a lower case mailbox role", path, v.Role)
+		case seen[v.Role]:
+			return nil, fmt.Errorf("mailbox views %s: duplicate role %q", path, v.Role)
+		case v.Sort != "" && queryPresetSorts[v.Sort] == nil:
+			return nil, fmt.Errorf("mailbox views %s: %s has unknown sort %q (want %s)", path, v.Role, v.Sort, strings.Join(sortedKeys(queryPresetSorts), ", "))
+		}
+		for _, c := range v.Columns {
+			if !slices.Contains(queryPresetColumns, c) {
+				return nil, fmt.Errorf("mailbox views %s: %s has unknown column %q (want %s)", path, v.Role, c, strings.Join(queryPresetColumns, ", "))
+			}
+		}
+		seen[v.Role] = true
+	}
+	return views, nil
+}
+
+// WithMailboxViews sets the email_query defaults of the views' roles,
+// replacing the built-in ones of the same role.
+func WithMailboxViews(views []MailboxView) Option {
+	return func(s *Server) {
+		for i := range views {
+			if len(views[i].Columns) == 0 && views[i].Sort == "" {
+				delete(s.mailboxViews, views[i].Role)
+				continue
+			}
+			s.mailboxViews[views[i].Role] = &views[i]
+		}
+	}
+}
+
+// mailboxViews are email_query defaults by mailbox role.
+type mailboxViews map[string]*MailboxView
+
+// mailboxViewsOf returns views by role.
+func mailboxViewsOf(views []MailboxView) mailboxViews {
+	out := make(mailboxViews, len(views))
+	for i := range views {
+		out[views[i].Role] = &views[i]
+	}
+	return out
+}
+
+// applyMailboxView sets the columns and sort of in from the view of the
+// role of its mailbox, where the call left them open, and returns a note
+// saying so; it returns "" when no view applies.
+func (s *Server) applyMailboxView(ctx context.Context, client JMAPClient, accountID jmap.ID, in *EmailQueryInput) string {
+	if in.MailboxID == "" || len(s.mailboxViews) == 0 || len(in.Fields) > 0 && in.sort != nil {
+		return ""
+	}
+	role := s.mailboxRole(ctx, client, accountID, jmap.ID(in.MailboxID))
+	v := s.mailboxViews[role]
+	if v == nil {
+		return ""
+	}
+	var applied []string
+	if len(in.Fields) == 0 && len(v.Columns) > 0 {
+		in.Fields = slices.Clone(v.Columns)
+		applied = append(applied, "fields "+strings.Join(v.Columns, ", "))
+	}
+	if in.sort == nil && v.Sort != "" {
+		in.sort = []*email.SortComparator{queryPresetSorts[v.Sort]}
+		applied = append(applied, "sorted "+v.Sort)
+	}
+	if len(applied) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s mailbox view: %s (set fields to choose others)", role, strings.Join(applied, ", "))
+}
+
+// mailboxRole returns the role of the mailbox with id, cached, or "" when
+// it has none or cannot be fetched.
+func (s *Server) mailboxRole(ctx context.Context, client JMAPClient, accountID, id jmap.ID) string {
+	type cachedRole struct {
+		role    string
+		fetched time.Time
+	}
+	owner, key := s.idOwner(ctx), "mailbox-role:"+endpointName(ctx)+" "+string(accountID)+" "+string(id)
+	if v, ok := s.tenants.get(owner, key); ok {
+		if r := v.(cachedRole); time.Since(r.fetched) < mailboxRoleTTL {
+			return r.role
+		}
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, IDs: []jmap.ID{id}, Properties: []string{"id", "role"}})
+	resp, err := client.Do(req)
+	if err != nil || len(resp.Responses) == 0 {
+		return ""
+	}
+	args, ok := resp.Responses[0].Args.(*mailbox.GetResponse)
+	if !ok || len(args.List) == 0 {
+		return ""
+	}
+	role := string(args.List[0].Role)
+	s.tenants.put(owner, key, cachedRole{role: role, fetched: time.Now()}, mailboxRoleSize)
+	return role
+}
@@ -0,0 +1,83 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func TestLoadMailboxViews(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		json string
+		ok   bool
+	}{
+		{`[{"role": "sent", "sort": "sent", "columns": ["sentAt", "to"]}, {"role": "drafts"}]`, true},
+		{`[{"role": "Sent"}]`, false},
+		{`[{"role": "sent"}, {"role": "sent"}]`, false},
+		{`[{"role": "sent", "sort": "random"}]`, false},
+		{`[{"role": "sent", "columns": ["body"]}]`, false},
+		{`[{"role": "sent", "fields": ["to"]}]`, false},
+	} {
+		path := filepath.Join(dir, "views.json")
+		if err := os.WriteFile(path, []byte(tc.json), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadMailboxViews(path); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.json, err)
+		}
+	}
+}
+
+// addSentEmail seeds an email in Sent, sent on sentDay and stored on
+// receivedDay of January 2025.
+func addSentEmail(fake *jmapfake.Server, id, subject, to string, sentDay, receivedDay int) {
+	fake.Add("Email", map[string]any{
+		"id":         id,
+		"subject":    subject,
+		"from":       []any{map[string]any{"email": "me@example.com"}},
+		"to":         []any{map[string]any{"email": to}},
+		"sentAt":     fmt.Sprintf("2025-01-%02dT09:00:00Z", sentDay),
+		"receivedAt": fmt.Sprintf("2025-01-%02dT10:00:00Z", receivedDay),
+		"mailboxIds": map[string]any{"sent": true},
+		"keywords":   map[string]any{"$seen": true},
+	})
+}
+
+func TestMailboxViews(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	addSentEmail(fake, "s1", "Quote", "bob@example.org", 3, 1)
+	addSentEmail(fake, "s2", "Invoice", "carol@example.org", 2, 2)
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{MailboxID: "sent"})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "to bob@example.org") || strings.Contains(out, "me@example.com") ||
+		!strings.Contains(out, "Note: sent mailbox view: fields sentAt, to, size, subject, sorted sent") {
+		t.Errorf("sent view:\n%s", out)
+	}
+	if strings.Index(out, "Quote") > strings.Index(out, "Invoice") {
+		t.Errorf("sent view not newest sent first:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{MailboxID: "sent", Fields: []string{"from", "subject"}})
+	out = resultText(res)
+	if !strings.Contains(out, "me@example.com") || strings.Contains(out, "to bob") ||
+		!strings.Contains(out, "sorted sent") || strings.Contains(out, "fields sentAt") {
+		t.Errorf("explicit fields with the sent view:\n%s", out)
+	}
+
+	s, fake = newFakeServer(t, WithMailboxViews([]MailboxView{{Role: "sent"}}))
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	addSentEmail(fake, "s1", "Quote", "bob@example.org", 3, 1)
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{MailboxID: "sent"})
+	if out := resultText(res); strings.Contains(out, "mailbox view") || !strings.Contains(out, "me@example.com") {
+		t.Errorf("sent view turned off:\n%s", out)
+	}
+}
@@ -0,0 +1,421 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Media metadata: attachment listings show the duration, codecs, and
+// dimensions of audio and video attachments, so learning that a voicemail
+// runs 40 seconds takes no 40 MB download. The prober reads at most the
+// first maxMediaProbeBytes of the blob and understands WAV, MP3, MP4/MOV
+// (when the moov box comes first), Ogg (Opus, Vorbis), and AMR; what it
+// cannot tell is left out. Results are cached per tenant and blob, which
+// JMAP never changes.
+
+const (
+	// maxMediaProbeBytes is how much of an attachment the prober reads.
+	maxMediaProbeBytes = 1 << 20
+	// maxMediaProbes caps the attachments one call probes; the rest are
+	// listed without metadata.
+	maxMediaProbes = 5
+	// mediaInfoSize estimates the bytes a probe result holds in the tenant
+	// cache.
+	mediaInfoSize = 128
+)
+
+// mediaInfo is what the prober learned about an audio or video file. Zero
+// fields are unknown.
+type mediaInfo struct {
+	Duration   time.Duration
+	VideoCodec string
+	Width      int
+	Height     int
+	AudioCodec string
+	Channels   int
+	SampleRate int
+}
+
+// String renders m for attachment listings, e.g. "0:40, AMR mono 8 kHz".
+func (m *mediaInfo) String() string {
+	var parts []string
+	if m.Duration > 0 {
+		parts = append(parts, formatMediaDuration(m.Duration))
+	}
+	if m.VideoCodec != "" || m.Width > 0 {
+		v := m.VideoCodec
+		if m.Width > 0 && m.Height > 0 {
+			v = strings.TrimSpace(fmt.Sprintf("%s %dx%d", v, m.Width, m.Height))
+		}
+		parts = append(parts, v)
+	}
+	if m.AudioCodec != "" {
+		a := m.AudioCodec
+		switch m.Channels {
+		case 0:
+		case 1:
+			a += " mono"
+		case 2:
+			a += " stereo"
+		default:
+			a += fmt.Sprintf(" %dch", m.Channels)
+		}
+		if m.SampleRate > 0 {
+			a += fmt.Sprintf(" %g kHz", float64(m.SampleRate)/1000)
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMediaDuration writes d as m:ss or h:mm:ss.
+func formatMediaDuration(d time.Duration) string {
+	secs := int(d.Round(time.Second) / time.Second)
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+// isMedia reports whether an attachment of media type typ is probed.
+func isMedia(typ string) bool {
+	return strings.HasPrefix(typ, "audio/") || strings.HasPrefix(typ, "video/")
+}
+
+// attachmentMedia probes the audio and video attachments among parts and
+// returns their descriptions by blob ID. Blobs not yet cached are probed
+// while *budget lasts, each taking one; failed downloads are skipped and the
+// listing shows the rest.
+func (s *Server) attachmentMedia(ctx context.Context, client JMAPClient, accountID jmap.ID, parts []*email.BodyPart, budget *int) map[jmap.ID]string {
+	owner := s.idOwner(ctx)
+	media := make(map[jmap.ID]string)
+	for _, part := range parts {
+		if !isMedia(part.Type) || part.BlobID == "" {
+			continue
+		}
+		// Probe results are cached per tenant by account and blob; nil
+		// records a blob the prober could not read.
+		key := "media\x00" + string(accountID) + "\x00" + string(part.BlobID)
+		v, ok := s.tenants.get(owner, key)
+		info, _ := v.(*mediaInfo)
+		if !ok {
+			if *budget <= 0 {
+				continue
+			}
+			*budget--
+			body, err := client.Download(ctx, accountID, part.BlobID)
+			if err != nil {
+				continue
+			}
+			info = probeMedia(body, int64(part.Size))
+			body.Close()
+			s.tenants.put(owner, key, info, mediaInfoSize)
+		}
+		if info != nil {
+			if desc := info.String(); desc != "" {
+				media[part.BlobID] = desc
+			}
+		}
+	}
+	return media
+}
+
+// probeMedia reads the start of a media file of size bytes and returns what
+// it tells, or nil when the format is not recognized.
+func probeMedia(r io.Reader, size int64) *mediaInfo {
+	data, err := io.ReadAll(io.LimitReader(r, maxMediaProbeBytes))
+	if err != nil || len(data) < 12 {
+		return nil
+	}
+	if size <= 0 {
+		size = int64(len(data))
+	}
+	whole := int64(len(data)) >= size
+	switch {
+	case bytes.HasPrefix(data, []byte("RIFF")) && string(data[8:12]) == "WAVE":
+		return probeWAV(data, size)
+	case string(data[4:8]) == "ftyp":
+		return probeMP4(data)
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return probeOgg(data, whole)
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return probeAMR(data, size)
+	case bytes.HasPrefix(data, []byte("ID3")) || data[0] == 0xff && data[1]&0xe0 == 0xe0:
+		return probeMP3(data, size)
+	}
+	return nil
+}
+
+// wavCodecs names the WAVE format tags seen in mail.
+var wavCodecs = map[uint16]string{
+	1: "PCM", 3: "PCM float", 6: "A-law", 7: "µ-law", 0x11: "IMA ADPCM",
+	0x31: "GSM 6.10", 0x55: "MP3", 0xfffe: "PCM",
+}
+
+// probeWAV reads the fmt and data chunks of a RIFF WAVE file.
+func probeWAV(data []byte, size int64) *mediaInfo {
+	m := &mediaInfo{AudioCodec: "WAV"}
+	var byteRate uint32
+	for off := 12; off+8 <= len(data); {
+		id, n := string(data[off:off+4]), int64(binary.LittleEndian.Uint32(data[off+4:]))
+		body := data[off+8:]
+		switch id {
+		case "fmt ":
+			if len(body) >= 16 {
+				if c, ok := wavCodecs[binary.LittleEndian.Uint16(body)]; ok {
+					m.AudioCodec = c
+				}
+				m.Channels = int(binary.LittleEndian.Uint16(body[2:]))
+				m.SampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+				byteRate = binary.LittleEndian.Uint32(body[8:])
+			}
+		case "data":
+			// Streamed recordings leave the size unset; the file size
+			// bounds it.
+			n = min(n, size-int64(off)-8)
+			if byteRate > 0 && n > 0 {
+				m.Duration = time.Duration(n) * time.Second / time.Duration(byteRate)
+			}
+			return m
+		}
+		off += 8 + int(n+n&1) // chunks are padded to even sizes
+	}
+	return m
+}
+
+// mp3Bitrates are the Layer III bitrates in kbit/s of MPEG-1 and of
+// MPEG-2 and 2.5, by bitrate index.
+var mp3Bitrates = [2][15]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// probeMP3 reads the first MPEG audio frame after any ID3v2 tag. The
+// duration comes from a Xing or Info header, or the bitrate of a constant
+// bitrate file.
+func probeMP3(data []byte, size int64) *mediaInfo {
+	m := &mediaInfo{AudioCodec: "MP3"}
+	off := 0
+	if bytes.HasPrefix(data, []byte("ID3")) && len(data) >= 10 {
+		tag := int(data[6]&0x7f)<<21 | int(data[7]&0x7f)<<14 | int(data[8]&0x7f)<<7 | int(data[9]&0x7f)
+		off = 10 + tag
+		if data[5]&0x10 != 0 {
+			off += 10 // footer
+		}
+	}
+	for ; off+4 <= len(data) && !(data[off] == 0xff && data[off+1]&0xe0 == 0xe0); off++ {
+	}
+	if off+4 > len(data) {
+		return m
+	}
+	h := data[off : off+4]
+	version, layer := h[1]>>3&3, h[1]>>1&3
+	rateIdx, bitIdx := h[2]>>2&3, h[2]>>4
+	if layer != 1 || version == 1 || rateIdx == 3 || bitIdx == 0 || bitIdx == 15 {
+		m.AudioCodec = "MPEG audio"
+		return m
+	}
+	mpeg1 := version == 3
+	m.SampleRate = []int{44100, 48000, 32000}[rateIdx]
+	table, spf, side := 0, 1152, 32
+	if !mpeg1 {
+		m.SampleRate /= 2
+		if version == 0 {
+			m.SampleRate /= 2
+		}
+		table, spf, side = 1, 576, 17
+	}
+	m.Channels = 2
+	if h[3]>>6 == 3 {
+		m.Channels = 1
+		side = 9
+		if mpeg1 {
+			side = 17
+		}
+	}
+	if x := off + 4 + side; x+12 <= len(data) && (string(data[x:x+4]) == "Xing" || string(data[x:x+4]) == "Info") {
+		if binary.BigEndian.Uint32(data[x+4:])&1 != 0 {
+			frames := int64(binary.BigEndian.Uint32(data[x+8:]))
+			m.Duration = time.Duration(frames*int64(spf)) * time.Second / time.Duration(m.SampleRate)
+			return m
+		}
+	}
+	bitrate := int64(mp3Bitrates[table][bitIdx]) * 1000
+	m.Duration = time.Duration((size-int64(off))*8) * time.Second / time.Duration(bitrate)
+	return m
+}
+
+// mp4Codecs names the sample entry types of ISO base media files.
+var mp4Codecs = map[string]string{
+	"avc1": "H.264", "avc3": "H.264", "hvc1": "HEVC", "hev1": "HEVC",
+	"vp08": "VP8", "vp09": "VP9", "av01": "AV1", "mp4v": "MPEG-4 video",
+	"mp4a": "AAC", "Opus": "Opus", "samr": "AMR", "sawb": "AMR-WB",
+	"alac": "ALAC", "ac-3": "AC-3", "ec-3": "E-AC-3", "s263": "H.263",
+}
+
+// probeMP4 walks the moov box of an MP4, MOV, M4A, or 3GP file. A file
+// whose moov box comes after the media data yields no metadata.
+func probeMP4(data []byte) *mediaInfo {
+	m := &mediaInfo{}
+	var handler string
+	var walk func(b []byte)
+	walk = func(b []byte) {
+		for len(b) >= 8 {
+			n, typ, hdr := uint64(binary.BigEndian.Uint32(b)), string(b[4:8]), 8
+			switch n {
+			case 0:
+				n = uint64(len(b))
+			case 1:
+				if len(b) < 16 {
+					return
+				}
+				n, hdr = binary.BigEndian.Uint64(b[8:]), 16
+			}
+			if n < uint64(hdr) || n > uint64(len(b)) {
+				// Truncated by the probe window: read what is there.
+				n = uint64(len(b))
+			}
+			body := b[hdr:n]
+			switch typ {
+			case "moov", "trak", "mdia", "minf", "stbl":
+				walk(body)
+			case "mvhd":
+				if len(body) >= 32 && body[0] == 1 {
+					scale, dur := binary.BigEndian.Uint32(body[20:]), binary.BigEndian.Uint64(body[24:])
+					m.Duration = mp4Duration(dur, scale)
+				} else if len(body) >= 20 {
+					scale, dur := binary.BigEndian.Uint32(body[12:]), uint64(binary.BigEndian.Uint32(body[16:]))
+					m.Duration = mp4Duration(dur, scale)
+				}
+			case "hdlr":
+				if len(body) >= 12 {
+					handler = string(body[8:12])
+				}
+			case "tkhd":
+				if len(body) >= 84 && m.Width == 0 {
+					// Width and height, 16.16 fixed point, end the box.
+					w, h := binary.BigEndian.Uint32(body[len(body)-8:]), binary.BigEndian.Uint32(body[len(body)-4:])
+					m.Width, m.Height = int(w>>16), int(h>>16)
+				}
+			case "stsd":
+				if len(body) >= 16 {
+					probeSampleEntry(m, handler, body[8:])
+				}
+			}
+			b = b[n:]
+		}
+	}
+	walk(data)
+	if *m == (mediaInfo{}) {
+		return nil
+	}
+	return m
+}
+
+// probeSampleEntry reads the codec of the first sample entry of a track
+// whose handler is handler.
+func probeSampleEntry(m *mediaInfo, handler string, e []byte) {
+	typ := string(e[4:8])
+	codec, ok := mp4Codecs[typ]
+	if !ok {
+		codec = strings.TrimSpace(typ)
+	}
+	switch handler {
+	case "vide":
+		if m.VideoCodec == "" {
+			m.VideoCodec = codec
+			if len(e) >= 36 && m.Width == 0 {
+				m.Width, m.Height = int(binary.BigEndian.Uint16(e[32:])), int(binary.BigEndian.Uint16(e[34:]))
+			}
+		}
+	case "soun":
+		if m.AudioCodec == "" {
+			m.AudioCodec = codec
+			if len(e) >= 34 {
+				m.Channels = int(binary.BigEndian.Uint16(e[24:]))
+				m.SampleRate = int(binary.BigEndian.Uint16(e[32:]))
+			}
+		}
+	}
+}
+
+func mp4Duration(dur uint64, scale uint32) time.Duration {
+	if scale == 0 || dur == 0 || dur == 1<<64-1 || dur == 1<<32-1 {
+		return 0
+	}
+	return time.Duration(dur * uint64(time.Second) / uint64(scale))
+}
+
+// probeOgg reads the identification header of an Opus or Vorbis stream;
+// when the whole file was read, the last page gives the duration.
+func probeOgg(data []byte, whole bool) *mediaInfo {
+	if len(data) < 28 {
+		return nil
+	}
+	segs := int(data[26])
+	p := data[27+segs:]
+	m := &mediaInfo{}
+	var preSkip uint64
+	var rate int
+	switch {
+	case len(p) >= 16 && bytes.HasPrefix(p, []byte("OpusHead")):
+		m.AudioCodec, m.Channels = "Opus", int(p[9])
+		preSkip, rate = uint64(binary.LittleEndian.Uint16(p[10:])), 48000
+		m.SampleRate = int(binary.LittleEndian.Uint32(p[12:]))
+	case len(p) >= 16 && bytes.HasPrefix(p, []byte("\x01vorbis")):
+		m.AudioCodec, m.Channels = "Vorbis", int(p[11])
+		m.SampleRate = int(binary.LittleEndian.Uint32(p[12:]))
+		rate = m.SampleRate
+	default:
+		return &mediaInfo{AudioCodec: "Ogg"}
+	}
+	if last := bytes.LastIndex(data, []byte("OggS")); whole && last >= 0 && last+14 <= len(data) && rate > 0 {
+		if granule := binary.LittleEndian.Uint64(data[last+6:]); granule > preSkip && granule != 1<<64-1 {
+			m.Duration = time.Duration((granule - preSkip) * uint64(time.Second) / uint64(rate))
+		}
+	}
+	return m
+}
+
+// amrFrameBytes are the speech frame sizes, without the header byte, of
+// AMR and AMR-WB by frame type; 0 ends the scan.
+var amrFrameBytes = map[bool][16]int{
+	false: {12, 13, 15, 17, 19, 20, 26, 31, 5},
+	true:  {17, 23, 32, 36, 40, 46, 50, 58, 60, 5},
+}
+
+// probeAMR counts the 20 ms frames of an AMR or AMR-WB file, extrapolating
+// from the probed bytes to the file size.
+func probeAMR(data []byte, size int64) *mediaInfo {
+	wb := bytes.HasPrefix(data, []byte("#!AMR-WB\n"))
+	m := &mediaInfo{AudioCodec: "AMR", Channels: 1, SampleRate: 8000}
+	off := len("#!AMR\n")
+	if wb {
+		m.AudioCodec, m.SampleRate, off = "AMR-WB", 16000, len("#!AMR-WB\n")
+	}
+	start, frames := off, 0
+	for off < len(data) {
+		ft := data[off] >> 3 & 0x0f
+		n := amrFrameBytes[wb][ft]
+		if n == 0 && ft != 15 {
+			break
+		}
+		if off+1+n > len(data) {
+			break
+		}
+		off += 1 + n
+		frames++
+	}
+	if frames > 0 && off > start {
+		m.Duration = time.Duration(int64(frames)*(size-int64(start))/int64(off-start)) * 20 * time.Millisecond
+	}
+	return m
+}
@@ -0,0 +1,124 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// box encodes an ISO base media box.
+func box(typ string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(8+len(b))), append([]byte(typ), b...)...)
+}
+
+// wavHeader returns the header of a PCM WAVE file with dataBytes of data.
+func wavHeader(channels, rate, bits, dataBytes int) []byte {
+	le := binary.LittleEndian
+	b := []byte("RIFF")
+	b = le.AppendUint32(b, uint32(36+dataBytes))
+	b = append(b, "WAVEfmt "...)
+	b = le.AppendUint32(b, 16)
+	b = le.AppendUint16(b, 1)
+	b = le.AppendUint16(b, uint16(channels))
+	b = le.AppendUint32(b, uint32(rate))
+	b = le.AppendUint32(b, uint32(rate*channels*bits/8))
+	b = le.AppendUint16(b, uint16(channels*bits/8))
+	b = le.AppendUint16(b, uint16(bits))
+	b = append(b, "data"...)
+	return le.AppendUint32(b, uint32(dataBytes))
+}
+
+func TestProbeMedia(t *testing.T) {
+	be, le := binary.BigEndian, binary.LittleEndian
+
+	wav := wavHeader(1, 8000, 16, 320000)
+
+	mp3 := append([]byte{0xff, 0xfb, 0x90, 0x64}, make([]byte, 100)...) // MPEG-1 Layer III, 128 kbit/s, 44.1 kHz
+
+	amr := []byte("#!AMR\n")
+	for range 150 {
+		amr = append(amr, append([]byte{0x3c}, make([]byte, 31)...)...) // 12.2 kbit/s frames
+	}
+
+	tkhd := make([]byte, 84)
+	be.PutUint32(tkhd[76:], 640<<16)
+	be.PutUint32(tkhd[80:], 480<<16)
+	avc1 := make([]byte, 78)
+	copy(avc1[4:], "avc1")
+	be.PutUint16(avc1[32:], 640)
+	be.PutUint16(avc1[34:], 480)
+	mvhd := make([]byte, 100)
+	be.PutUint32(mvhd[12:], 1000)
+	be.PutUint32(mvhd[16:], 42000)
+	mp4 := append(box("ftyp", []byte("isom\x00\x00\x02\x00")),
+		box("moov", box("mvhd", mvhd), box("trak",
+			box("tkhd", tkhd),
+			box("mdia",
+				box("hdlr", []byte{0, 0, 0, 0, 0, 0, 0, 0}, []byte("vide"), make([]byte, 12)),
+				box("minf", box("stbl", box("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 1}, avc1))),
+			),
+		))...)
+
+	oggPage := func(granule uint64, packet []byte) []byte {
+		p := append([]byte("OggS\x00\x02"), le.AppendUint64(nil, granule)...)
+		p = append(p, make([]byte, 12)...)
+		return append(append(p, 1, byte(len(packet))), packet...)
+	}
+	opusHead := append([]byte("OpusHead\x01\x02"), le.AppendUint16(nil, 312)...)
+	opusHead = append(le.AppendUint32(opusHead, 48000), 0, 0, 0)
+	ogg := append(oggPage(0, opusHead), oggPage(312+5*48000, []byte{0})...)
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		size int64
+		want string
+	}{
+		{"wav", wav, int64(len(wav)) + 320000, "0:20, PCM mono 8 kHz"},
+		{"mp3", mp3, 960000, "1:00, MP3 stereo 44.1 kHz"},
+		{"amr", amr, int64(len(amr)), "0:03, AMR mono 8 kHz"},
+		{"amr extrapolated", amr, int64(len(amr)-6)*10 + 6, "0:30, AMR mono 8 kHz"},
+		{"mp4", mp4, int64(len(mp4)), "0:42, H.264 640x480"},
+		{"ogg", ogg, int64(len(ogg)), "0:05, Opus stereo 48 kHz"},
+	} {
+		m := probeMedia(bytes.NewReader(tc.data), tc.size)
+		if m == nil {
+			t.Errorf("%s: not recognized", tc.name)
+			continue
+		}
+		if got := m.String(); got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	if m := probeMedia(strings.NewReader("plain text, not media"), 21); m != nil {
+		t.Errorf("text probed as %v", m)
+	}
+}
+
+func TestEmailGetAttachmentMedia(t *testing.T) {
+	s, fake := newFakeServer(t)
+	wav := fake.AddBlob(append(wavHeader(1, 8000, 16, 640000), make([]byte, 4096)...))
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Voicemail",
+		"mailboxIds": map[string]any{"inbox": true},
+		"attachments": []any{
+			map[string]any{"blobId": wav, "name": "message.wav", "type": "audio/wav", "size": 640044},
+			map[string]any{"blobId": "Gpdf", "name": "a.pdf", "type": "application/pdf", "size": 8},
+		},
+	})
+
+	for range 2 {
+		res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1"}})
+		out := resultText(res)
+		if res.IsError || !strings.Contains(out, "message.wav (audio/wav, 640044 bytes) [blob: "+wav+"] — 0:40, PCM mono 8 kHz") {
+			t.Fatalf("email_get:\n%s", out)
+		}
+		if strings.Contains(out, "a.pdf (application/pdf, 8 bytes) [blob: Gpdf] —") {
+			t.Errorf("non-media attachment probed:\n%s", out)
+		}
+	}
+}
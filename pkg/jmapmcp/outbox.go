@@ -0,0 +1,102 @@
+package jmapmcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+)
+
+// outbox is the local pending-submission queue used when sending requires
+// approval. Entries live in memory only: a restart drops pending approvals
+// but never the drafts themselves, which stay in the Drafts mailbox.
+type outbox struct {
+	mu      sync.Mutex
+	seq     int
+	entries map[string]*outboxEntry
+}
+
+// outboxEntry is one draft awaiting approval. Owner is a hash of the JMAP
+// token that queued it, so in multi-user HTTP mode a caller only sees and
+// approves its own entries.
+type outboxEntry struct {
+	ID         string
+	Owner      string
+	Endpoint   string // named endpoint the draft lives on; empty for the default
+	AccountID  jmap.ID
+	EmailID    jmap.ID
+	IdentityID jmap.ID // empty: resolved at approval time
+	Subject    string
+	To         string
+	QueuedAt   time.Time
+	Resend     bool // queued with resend: approval skips double-send protection
+}
+
+func newOutbox() *outbox {
+	return &outbox{entries: make(map[string]*outboxEntry)}
+}
+
+// ownerKey derives the queue owner from a JMAP token without retaining it.
+func ownerKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// add queues e and returns its ID. Queuing an email that is already pending
+// for the same owner returns the existing entry's ID.
+func (o *outbox) add(e *outboxEntry) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, existing := range o.entries {
+		if existing.Owner == e.Owner && existing.Endpoint == e.Endpoint && existing.AccountID == e.AccountID && existing.EmailID == e.EmailID {
+			return existing.ID
+		}
+	}
+	o.seq++
+	e.ID = fmt.Sprintf("out-%d", o.seq)
+	o.entries[e.ID] = e
+	return e.ID
+}
+
+// list returns owner's pending entries, oldest first.
+func (o *outbox) list(owner string) []*outboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var out []*outboxEntry
+	for _, e := range o.entries {
+		if e.Owner == owner {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].QueuedAt.Equal(out[j].QueuedAt) {
+			return out[i].QueuedAt.Before(out[j].QueuedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// take removes and returns owner's entry id. Removing before submission
+// keeps two concurrent approvals from sending the same draft twice.
+func (o *outbox) take(owner, id string) (*outboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, ok := o.entries[id]
+	if !ok || e.Owner != owner {
+		return nil, fmt.Errorf("no pending outbox entry %q", id)
+	}
+	delete(o.entries, id)
+	return e, nil
+}
+
+// restore puts back an entry whose approval failed.
+func (o *outbox) restore(e *outboxEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entries[e.ID] = e
+}
@@ -0,0 +1,135 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestOutbox(t *testing.T) {
+	o := newOutbox()
+	alice, bob := ownerKey("alice-token"), ownerKey("bob-token")
+	now := time.Now()
+
+	id1 := o.add(&outboxEntry{Owner: alice, AccountID: "a", EmailID: "e1", QueuedAt: now})
+	id2 := o.add(&outboxEntry{Owner: alice, AccountID: "a", EmailID: "e2", QueuedAt: now.Add(time.Second)})
+	o.add(&outboxEntry{Owner: bob, AccountID: "b", EmailID: "e3", QueuedAt: now})
+
+	if dup := o.add(&outboxEntry{Owner: alice, AccountID: "a", EmailID: "e1", QueuedAt: now}); dup != id1 {
+		t.Errorf("re-queuing e1 returned %s, want existing %s", dup, id1)
+	}
+
+	got := o.list(alice)
+	if len(got) != 2 || got[0].ID != id1 || got[1].ID != id2 {
+		t.Fatalf("list(alice) = %+v, want [%s %s]", got, id1, id2)
+	}
+
+	if _, err := o.take(bob, id1); err == nil {
+		t.Error("bob took alice's entry")
+	}
+
+	e, err := o.take(alice, id1)
+	if err != nil {
+		t.Fatalf("take: %v", err)
+	}
+	if _, err := o.take(alice, id1); err == nil {
+		t.Error("second take of the same entry succeeded")
+	}
+
+	o.restore(e)
+	if got := o.list(alice); len(got) != 2 {
+		t.Errorf("after restore list(alice) has %d entries, want 2", len(got))
+	}
+}
+
+// elicitingSession connects an in-memory client to s whose user answers
+// every elicitation with answer, recording the prompts in asked.
+func elicitingSession(t *testing.T, s *Server, answer *mcp.ElicitResult, asked *[]string) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, &mcp.ClientOptions{
+		ElicitationHandler: func(_ context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			*asked = append(*asked, req.Params.Message)
+			return answer, nil
+		},
+	})
+	st, ct := mcp.NewInMemoryTransports()
+	if _, err := s.MCP().Connect(ctx, st, nil); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := client.Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+func TestOutboxApproveNeedsUserConfirmation(t *testing.T) {
+	s, fake := newFakeServer(t, WithEmailSubmission(), WithSendQueue())
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "name": "Me", "email": "me@example.com"})
+	fake.Add("Email", map[string]any{
+		"id": "d1", "subject": "Hello", "to": []any{map[string]any{"email": "bob@example.com"}},
+		"mailboxIds": map[string]any{"drafts": true}, "keywords": map[string]any{"$draft": true},
+	})
+	ctx := context.Background()
+	submitted := func() bool {
+		for _, c := range fake.Calls() {
+			if c == "EmailSubmission/set" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1"}); res.IsError {
+		t.Fatalf("queue: %s", resultText(res))
+	}
+	entries := s.outbox.list(ownerKey("test"))
+	if len(entries) != 1 {
+		t.Fatalf("outbox has %d entries, want 1", len(entries))
+	}
+	id := entries[0].ID
+
+	// The calling agent alone cannot approve: without a client that asks its
+	// user, the entry stays queued.
+	res, _, _ := s.handleOutboxApprove(ctx, nil, OutboxApproveInput{ID: id})
+	if te, _ := res.StructuredContent.(*toolError); !res.IsError || te == nil || te.Rule != "approval" {
+		t.Errorf("approve without elicitation: %s", resultText(res))
+	}
+
+	var asked []string
+	for _, answer := range []*mcp.ElicitResult{
+		{Action: "decline"},
+		{Action: "accept", Content: map[string]any{"send": false}},
+	} {
+		cs := elicitingSession(t, s, answer, &asked)
+		res, err := cs.CallTool(ctx, &mcp.CallToolParams{Name: "outbox_approve", Arguments: map[string]any{"id": id}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.IsError || !strings.Contains(resultText(res), "did not confirm") {
+			t.Errorf("answer %+v: %s", answer, resultText(res))
+		}
+	}
+	if submitted() || len(s.outbox.list(ownerKey("test"))) != 1 {
+		t.Fatal("unconfirmed approval sent the email or dropped it from the outbox")
+	}
+
+	cs := elicitingSession(t, s, &mcp.ElicitResult{Action: "accept", Content: map[string]any{"send": true}}, &asked)
+	res, err := cs.CallTool(ctx, &mcp.CallToolParams{Name: "outbox_approve", Arguments: map[string]any{"id": id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.IsError || !submitted() {
+		t.Errorf("confirmed approval: %s", resultText(res))
+	}
+	if len(asked) != 3 || !strings.Contains(asked[2], "Subject: Hello") || !strings.Contains(asked[2], "bob@example.com") {
+		t.Errorf("prompts = %q", asked)
+	}
+}
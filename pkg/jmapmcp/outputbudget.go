@@ -0,0 +1,184 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Output budgets: listing tools take max_tokens, an approximate token
+// budget for their text result, so an orchestrator can ask for "about 1k
+// tokens" instead of tuning each tool's limits. withOutputBudget fits the
+// rendered text after the fact, in stages that lose the least first:
+// indented "Field: value" detail lines go, then the middle columns of
+// tabular rows (the first, usually an ID, and the last, usually a subject
+// or name, stay), then long lines are shortened, and last the lines past
+// the budget are elided with a count. The result says which stages ran.
+// Structured content is left whole.
+
+const (
+	charsPerToken   = 4   // rough average for English text and IDs
+	minOutputTokens = 100 // smaller budgets are raised to this
+	budgetLineChars = 100 // the length lines are shortened to
+	budgetNoteChars = 120 // room kept for the note saying what was elided
+)
+
+// listingTools take max_tokens.
+var listingTools = map[string]bool{
+	"email_query": true, "mailbox_get": true, "mailbox_report": true, "keyword_list": true,
+	"identity_get": true, "thread_participants": true, "correspondents_top": true,
+	"email_awaiting_reply": true, "email_sent_unanswered": true, "inbox_unified": true,
+	"email_ref_query": true, "note_search": true, "task_list": true, "masked_email_list": true,
+	"automation_list": true, "schedule_list": true, "watch_list": true, "vip_list": true,
+	"outbox_list": true, "sieve_history": true, "stalwart_principal_list": true,
+	"stalwart_undelete_list": true,
+}
+
+var (
+	detailLine = regexp.MustCompile(`^\s+[A-Za-z][\w -]{0,30}: `)
+	columnGap  = regexp.MustCompile(` {2,}|\t`)
+)
+
+// withOutputBudget fits the text result of a listing tool into the
+// max_tokens the call passed.
+func withOutputBudget[In any](t *mcp.Tool, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	if !isListingTool(t.Name) {
+		return h
+	}
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		var sel struct {
+			MaxTokens int `json:"max_tokens"`
+		}
+		if req != nil && req.Params != nil && len(req.Params.Arguments) > 0 {
+			_ = json.Unmarshal(req.Params.Arguments, &sel)
+		}
+		res, out, err := h(ctx, req, in)
+		if sel.MaxTokens <= 0 || err != nil || res == nil || res.IsError {
+			return res, out, err
+		}
+		limit := max(sel.MaxTokens, minOutputTokens) * charsPerToken
+		for _, c := range res.Content {
+			tc, ok := c.(*mcp.TextContent)
+			if !ok {
+				continue
+			}
+			tc.Text = fitText(tc.Text, limit, max(sel.MaxTokens, minOutputTokens))
+			limit = max(limit-len(tc.Text), 0)
+		}
+		return res, out, err
+	}
+}
+
+// fitText shortens text to at most limit bytes, noting what it left out
+// and the token budget it was fitted to.
+func fitText(text string, limit, tokens int) string {
+	if len(text) <= limit {
+		return text
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	room := max(limit-budgetNoteChars, 0)
+	var stages []string
+
+	if kept := dropDetailLines(lines); len(kept) < len(lines) {
+		stages = append(stages, fmt.Sprintf("%d detail lines dropped", len(lines)-len(kept)))
+		lines = kept
+	}
+	if linesSize(lines) > room {
+		if narrowed, n := dropColumns(lines, room); n > 0 {
+			stages = append(stages, fmt.Sprintf("%d columns dropped", n))
+			lines = narrowed
+		}
+	}
+	if linesSize(lines) > room {
+		n := 0
+		for i, line := range lines {
+			if utf8.RuneCountInString(line) > budgetLineChars {
+				lines[i] = string([]rune(line)[:budgetLineChars-1]) + "…"
+				n++
+			}
+		}
+		if n > 0 {
+			stages = append(stages, fmt.Sprintf("%d long lines shortened", n))
+		}
+	}
+	if linesSize(lines) > room {
+		keep, total := 0, 0
+		for keep < len(lines) && total+len(lines[keep])+1 <= room {
+			total += len(lines[keep]) + 1
+			keep++
+		}
+		stages = append(stages, fmt.Sprintf("last %d lines elided", len(lines)-keep))
+		lines = lines[:keep]
+	}
+
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	fmt.Fprintf(&sb, "[Fitted to about %d tokens: %s; raise max_tokens or narrow the request for more]\n", tokens, strings.Join(stages, ", "))
+	return sb.String()
+}
+
+// dropDetailLines leaves out indented "Field: value" lines, unless every
+// line is one, in which case there is nothing they detail.
+func dropDetailLines(lines []string) []string {
+	var kept []string
+	for _, line := range lines {
+		if !detailLine.MatchString(line) {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == 0 {
+		return lines
+	}
+	return kept
+}
+
+// dropColumns removes the second-to-last column of every tabular row (one
+// with at least three columns separated by runs of spaces) until the
+// lines fit room or the rows are down to two columns. It returns the
+// lines and the number of columns dropped.
+func dropColumns(lines []string, room int) ([]string, int) {
+	rows := make([][]string, len(lines))
+	indent := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if cols := columnGap.Split(strings.TrimRight(trimmed, " "), -1); len(cols) >= 3 {
+			indent[i] = line[:len(line)-len(trimmed)]
+			rows[i] = cols
+		}
+	}
+	out := append([]string(nil), lines...)
+	dropped := 0
+	for linesSize(out) > room {
+		narrowed := false
+		for i, cols := range rows {
+			if len(cols) < 3 {
+				continue
+			}
+			rows[i] = append(cols[:len(cols)-2:len(cols)-2], cols[len(cols)-1])
+			out[i] = indent[i] + strings.Join(rows[i], "  ")
+			narrowed = true
+		}
+		if !narrowed {
+			break
+		}
+		dropped++
+	}
+	return out, dropped
+}
+
+// linesSize is the length of lines joined by newlines.
+func linesSize(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		n += len(line) + 1
+	}
+	return n
+}
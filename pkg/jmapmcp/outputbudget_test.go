@@ -0,0 +1,56 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFitText(t *testing.T) {
+	var sb strings.Builder
+	for i := range 40 {
+		fmt.Fprintf(&sb, "e%d  2025-01-02 10:00  Alice <alice@example.org>  [12 KB]  Invoice %d\n", i, i)
+	}
+	rows := sb.String()
+
+	if got := fitText(rows, len(rows), 1000); got != rows {
+		t.Errorf("text within the budget changed")
+	}
+
+	got := fitText(rows, 2400, 600)
+	if len(got) > 2400 || strings.Contains(got, "12 KB") || !strings.Contains(got, "e39  2025-01-02 10:00  Invoice 39") || !strings.Contains(got, "2 columns dropped") {
+		t.Errorf("columns not dropped first:\n%s", got)
+	}
+
+	got = fitText(rows, 400, 100)
+	if len(got) > 400 || !strings.HasPrefix(got, "e0  Invoice 0\n") || !strings.Contains(got, "lines elided") {
+		t.Errorf("rows not elided:\n%s", got)
+	}
+
+	var outbox strings.Builder
+	for i := range 20 {
+		fmt.Fprintf(&outbox, "[q%d] email e%d\n  Subject: Quarterly numbers\n  Recipients: bob@example.com\n", i, i)
+	}
+	got = fitText(outbox.String(), 800, 200)
+	if strings.Contains(got, "Subject:") || !strings.Contains(got, "[q19] email e19") || !strings.Contains(got, "40 detail lines dropped") {
+		t.Errorf("detail lines not dropped:\n%s", got)
+	}
+}
+
+func TestOutputBudgetParameter(t *testing.T) {
+	s, fake := newFakeServer(t)
+	for i := range 60 {
+		addEmail(fake, fmt.Sprintf("e%d", i), fmt.Sprintf("Monthly statement %d", i), "", 1+i%28)
+	}
+	cs := rootsSession(t, s)
+
+	full, isErr := callTool(t, cs, "email_query", map[string]any{"limit": 60})
+	if isErr {
+		t.Fatal(full)
+	}
+	fitted, isErr := callTool(t, cs, "email_query", map[string]any{"limit": 60, "max_tokens": 300})
+	fitted, _, _ = strings.Cut(fitted, "```json jmap-ids") // the ID manifest lists the IDs left
+	if isErr || len(fitted) > 1200 || len(fitted) >= len(full) || !strings.Contains(fitted, "[Fitted to about 300 tokens") {
+		t.Errorf("email_query with max_tokens (%d bytes, %d unfitted):\n%s", len(fitted), len(full), fitted)
+	}
+}
@@ -0,0 +1,311 @@
+package jmapmcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// PGP/MIME (RFC 3156) support for email_get. The server holds no keys:
+// decryption and signature checks are delegated to a PGP hook, by default
+// an external command (-pgp-command) that is set up with the user's keys.
+// Messages whose Content-Type is multipart/encrypted or multipart/signed
+// with a PGP protocol are downloaded raw and passed to the hook before
+// their body is extracted; a decrypted entity replaces the body, and the
+// outcome is annotated in a "PGP:" header line. A failing hook leaves the
+// message as the server delivered it.
+
+const (
+	// pgpTimeout bounds one run of the PGP hook.
+	pgpTimeout = 30 * time.Second
+	// maxPGPMessageBytes caps the raw message handed to the hook.
+	maxPGPMessageBytes = 32 << 20
+	// pgpPartID is the synthetic body part holding decrypted text.
+	pgpPartID = "pgp"
+)
+
+// PGP decrypts and verifies PGP/MIME messages.
+type PGP interface {
+	// Open processes raw, a complete RFC 5322 message received by user.
+	Open(ctx context.Context, user string, raw []byte) (*PGPResult, error)
+}
+
+// PGPResult is the outcome of opening one message.
+type PGPResult struct {
+	Decrypted bool   // Content holds the decrypted entity
+	Signature string // "good", "bad", "unknown_key", or empty when unsigned
+	Signer    string // who made the signature, e.g. a user ID and fingerprint
+	Content   []byte // decrypted MIME entity (headers and body); nil when only verified
+}
+
+// PGPCommand is a PGP hook running an external program and its arguments.
+// The raw message is written to its stdin and the JMAP username is in the
+// JMAP_MCP_USER environment variable, so one command can serve several
+// keyrings. The program writes result headers to stdout:
+//
+//	Decrypted: yes
+//	Signature: good
+//	Signer: Alice <alice@example.org> 0123ABCD
+//
+// then a blank line and, when decrypted, the decrypted MIME entity in
+// UTF-8. A non-zero exit is a failure, reported with its stderr.
+type PGPCommand []string
+
+func (c PGPCommand) Open(ctx context.Context, user string, raw []byte) (*PGPResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, pgpTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c[0], c[1:]...)
+	cmd.Env = append(os.Environ(), "JMAP_MCP_USER="+user)
+	cmd.Stdin = bytes.NewReader(raw)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return parsePGPOutput(stdout.Bytes())
+}
+
+// parsePGPOutput reads the result headers and decrypted entity written by
+// a PGPCommand.
+func parsePGPOutput(out []byte) (*PGPResult, error) {
+	r := bufio.NewReader(bytes.NewReader(out))
+	hdr, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading PGP command output: %w", err)
+	}
+	res := &PGPResult{
+		Decrypted: strings.EqualFold(hdr.Get("Decrypted"), "yes"),
+		Signature: strings.ToLower(hdr.Get("Signature")),
+		Signer:    hdr.Get("Signer"),
+	}
+	switch res.Signature {
+	case "", "none":
+		res.Signature = ""
+	case "good", "bad", "unknown_key":
+	default:
+		return nil, fmt.Errorf("PGP command reported unknown signature status %q", res.Signature)
+	}
+	if res.Decrypted {
+		res.Content, _ = io.ReadAll(r)
+		if len(bytes.TrimSpace(res.Content)) == 0 {
+			return nil, fmt.Errorf("PGP command reported decryption but wrote no content")
+		}
+	}
+	return res, nil
+}
+
+// pgpKind returns "encrypted" or "signed" for a PGP/MIME message, judged
+// by its top-level Content-Type header, and "" otherwise.
+func pgpKind(e *email.Email) string {
+	for _, h := range e.Headers {
+		if !strings.EqualFold(h.Name, "Content-Type") {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(h.Value))
+		if err != nil {
+			return ""
+		}
+		protocol := strings.ToLower(params["protocol"])
+		switch {
+		case mediaType == "multipart/encrypted" && protocol == "application/pgp-encrypted":
+			return "encrypted"
+		case mediaType == "multipart/signed" && protocol == "application/pgp-signature":
+			return "signed"
+		}
+		return ""
+	}
+	return ""
+}
+
+// openPGP runs the PGP hook on e if it is a PGP/MIME message and returns
+// the "PGP:" annotation, or "" for other messages. A decrypted body
+// replaces e's text and HTML bodies so extractBody renders it, and the
+// encrypted payload parts are dropped from e's attachments.
+func (s *Server) openPGP(ctx context.Context, client JMAPClient, accountID jmap.ID, e *email.Email) string {
+	kind := pgpKind(e)
+	if s.pgp == nil || kind == "" || e.BlobID == "" {
+		return ""
+	}
+	if e.Size > maxPGPMessageBytes {
+		return fmt.Sprintf("%s message not checked: larger than %d bytes", kind, maxPGPMessageBytes)
+	}
+	raw, err := downloadBlob(ctx, client, accountID, e.BlobID, maxPGPMessageBytes)
+	if err != nil {
+		return fmt.Sprintf("%s message not checked: %v", kind, err)
+	}
+	res, err := s.pgp.Open(ctx, client.Session().Username, raw)
+	if err != nil {
+		return fmt.Sprintf("%s message not checked: %v", kind, err)
+	}
+
+	var notes []string
+	if kind == "encrypted" && !res.Decrypted {
+		notes = append(notes, "not decrypted")
+	}
+	if res.Decrypted {
+		text, isHTML, attachments, err := pgpContentBody(res.Content)
+		if err != nil {
+			return fmt.Sprintf("decrypted, but the content could not be read: %v", err)
+		}
+		partType := "text/plain"
+		if isHTML {
+			partType = "text/html"
+		}
+		part := []*email.BodyPart{{PartID: pgpPartID, Type: partType}}
+		e.TextBody, e.HTMLBody = part, part
+		e.BodyValues = map[string]*email.BodyValue{pgpPartID: {Value: text}}
+		// RFC 3156 encrypted messages hold only the control part and the
+		// ciphertext, neither of which is worth listing once decrypted.
+		e.Attachments = nil
+		note := "decrypted"
+		if len(attachments) > 0 {
+			note += fmt.Sprintf(" (encrypted attachments, not downloadable: %s)", strings.Join(attachments, ", "))
+		}
+		notes = append(notes, note)
+	}
+	switch res.Signature {
+	case "":
+		if kind == "signed" || res.Decrypted {
+			notes = append(notes, "not signed")
+		}
+	case "good":
+		notes = append(notes, "signature good by "+orUnknown(res.Signer))
+	case "bad":
+		notes = append(notes, "signature BAD, claimed signer "+orUnknown(res.Signer))
+	case "unknown_key":
+		notes = append(notes, "signature by unknown key "+orUnknown(res.Signer))
+	}
+	if kind == "signed" {
+		e.Attachments = slices.DeleteFunc(slices.Clone(e.Attachments), func(part *email.BodyPart) bool {
+			return strings.EqualFold(part.Type, "application/pgp-signature")
+		})
+	}
+	return strings.Join(notes, "; ")
+}
+
+func orUnknown(signer string) string {
+	if signer == "" {
+		return "(unknown signer)"
+	}
+	return signer
+}
+
+// downloadBlob downloads a blob of at most limit bytes.
+func downloadBlob(ctx context.Context, client JMAPClient, accountID, blobID jmap.ID, limit int64) ([]byte, error) {
+	body, err := client.Download(ctx, accountID, blobID)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("blob exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// pgpContentBody extracts the body of a decrypted MIME entity: the first
+// text/plain part, else the first text/html part, plus the file names of
+// its attachments.
+func pgpContentBody(content []byte) (text string, isHTML bool, attachments []string, err error) {
+	msg, err := netmail.ReadMessage(bytes.NewReader(content))
+	if err != nil {
+		return "", false, nil, err
+	}
+	var plain, html string
+	var walk func(header textproto.MIMEHeader, body io.Reader) error
+	walk = func(header textproto.MIMEHeader, body io.Reader) error {
+		mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+		if err != nil {
+			mediaType = "text/plain"
+		}
+		if strings.HasPrefix(mediaType, "multipart/") {
+			mr := multipart.NewReader(body, params["boundary"])
+			for {
+				p, err := mr.NextRawPart()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := walk(p.Header, p); err != nil {
+					return err
+				}
+			}
+		}
+		disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+		name := dparams["filename"]
+		if name == "" {
+			name = params["name"]
+		}
+		if disposition == "attachment" || (name != "" && !strings.HasPrefix(mediaType, "text/")) {
+			if mediaType != "application/pgp-signature" {
+				attachments = append(attachments, orUnknownName(name, mediaType))
+			}
+			return nil
+		}
+		if mediaType != "text/plain" && mediaType != "text/html" {
+			return nil
+		}
+		data, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+		if err != nil {
+			return err
+		}
+		if mediaType == "text/plain" && plain == "" {
+			plain = string(data)
+		} else if mediaType == "text/html" && html == "" {
+			html = string(data)
+		}
+		return nil
+	}
+	if err := walk(textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return "", false, nil, err
+	}
+	if plain == "" && html != "" {
+		return html, true, attachments, nil
+	}
+	return plain, false, attachments, nil
+}
+
+func orUnknownName(name, mediaType string) string {
+	if name == "" {
+		return "(unnamed " + mediaType + ")"
+	}
+	return name
+}
+
+// decodeTransfer undoes a Content-Transfer-Encoding.
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
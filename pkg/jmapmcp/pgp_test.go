@@ -0,0 +1,106 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakePGP records the messages it opens and returns a fixed result.
+type fakePGP struct {
+	res    *PGPResult
+	err    error
+	opened []string
+}
+
+func (p *fakePGP) Open(_ context.Context, user string, raw []byte) (*PGPResult, error) {
+	p.opened = append(p.opened, user+":"+string(raw))
+	return p.res, p.err
+}
+
+func TestEmailGetDecryptsPGP(t *testing.T) {
+	pgp := &fakePGP{res: &PGPResult{
+		Decrypted: true,
+		Signature: "good",
+		Signer:    "Alice <alice@example.org>",
+		Content:   []byte("Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nThe launch code is=20secret.\r\n--b\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=plan.pdf\r\n\r\n%PDF\r\n--b--\r\n"),
+	}}
+	s, fake := newFakeServer(t, WithPGP(pgp))
+	blobID := fake.AddBlob([]byte("raw encrypted message"))
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Secret", "blobId": blobID,
+		"headers": []any{map[string]any{"name": "Content-Type", "value": ` multipart/encrypted; protocol="application/pgp-encrypted"; boundary="x"`}},
+		"attachments": []any{
+			map[string]any{"blobId": "Gv", "type": "application/pgp-encrypted"},
+			map[string]any{"blobId": "Gc", "type": "application/octet-stream", "name": "encrypted.asc"},
+		},
+	})
+	addEmail(fake, "m2", "Plain", "Hello", 1)
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1", "m2"}})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("email_get: %s", out)
+	}
+	if !strings.Contains(out, "The launch code is secret.") {
+		t.Errorf("decrypted body missing:\n%s", out)
+	}
+	if !strings.Contains(out, "PGP: decrypted (encrypted attachments, not downloadable: plan.pdf); signature good by Alice <alice@example.org>") {
+		t.Errorf("PGP annotation missing:\n%s", out)
+	}
+	if strings.Contains(out, "encrypted.asc") {
+		t.Errorf("ciphertext part still listed:\n%s", out)
+	}
+	if len(pgp.opened) != 1 || !strings.HasSuffix(pgp.opened[0], ":raw encrypted message") {
+		t.Errorf("hook opened %q, want only the encrypted message", pgp.opened)
+	}
+}
+
+func TestEmailGetPGPFailureKeepsMessage(t *testing.T) {
+	s, fake := newFakeServer(t, WithPGP(&fakePGP{err: errors.New("no secret key")}))
+	blobID := fake.AddBlob([]byte("raw"))
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Signed", "blobId": blobID,
+		"headers": []any{map[string]any{"name": "Content-Type", "value": `multipart/signed; micalg=pgp-sha256; protocol="application/pgp-signature"; boundary="s"`}},
+	})
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1"}})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "PGP: signed message not checked: no secret key") {
+		t.Errorf("email_get:\n%s", out)
+	}
+}
+
+func TestParsePGPOutput(t *testing.T) {
+	res, err := parsePGPOutput([]byte("Decrypted: no\nSignature: unknown_key\nSigner: 0123ABCD\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Decrypted || res.Signature != "unknown_key" || res.Signer != "0123ABCD" || res.Content != nil {
+		t.Errorf("verify-only result = %+v", res)
+	}
+
+	if _, err := parsePGPOutput([]byte("Decrypted: yes\n\n")); err == nil {
+		t.Error("decryption without content accepted")
+	}
+	if _, err := parsePGPOutput([]byte("Signature: maybe\n\n")); err == nil {
+		t.Error("unknown signature status accepted")
+	}
+}
+
+func TestPGPCommand(t *testing.T) {
+	cmd := PGPCommand{"sh", "-c", `read line; printf 'Decrypted: yes\nSignature: none\n\nContent-Type: text/plain\n\n%s for %s\n' "$line" "$JMAP_MCP_USER"`}
+	res, err := cmd.Open(context.Background(), "me@example.com", []byte("ciphertext\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, isHTML, _, err := pgpContentBody(res.Content)
+	if err != nil || isHTML || strings.TrimSpace(text) != "ciphertext for me@example.com" || res.Signature != "" {
+		t.Errorf("result = %+v, body %q (%v)", res, text, err)
+	}
+
+	_, err = PGPCommand{"sh", "-c", "echo bad passphrase >&2; exit 2"}.Open(context.Background(), "me", nil)
+	if err == nil || !strings.Contains(err.Error(), "bad passphrase") {
+		t.Errorf("failing command error = %v", err)
+	}
+}
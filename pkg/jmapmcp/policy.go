@@ -0,0 +1,16 @@
+package jmapmcp
+
+import "fmt"
+
+// policyError is a refusal by an operator-configured policy, as opposed to a
+// JMAP or transport failure. errorResult attaches its fields as structured
+// content so clients can tell the two apart.
+type policyError struct {
+	Policy string `json:"policy"` // which policy refused, e.g. "send"
+	Rule   string `json:"rule"`   // the violated rule, e.g. "max_recipients"
+	Detail string `json:"detail"`
+}
+
+func (e *policyError) Error() string {
+	return fmt.Sprintf("%s policy violation (%s): %s", e.Policy, e.Rule, e.Detail)
+}
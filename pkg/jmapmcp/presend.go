@@ -0,0 +1,260 @@
+package jmapmcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Pre-send hooks: an operator-supplied check (DLP scanning, disclaimer
+// injection) that sees every outgoing message before EmailSubmission/set
+// and may allow it, reject it, or replace it. The hook gets the raw RFC
+// 5322 message of the draft; a replacement is imported into Drafts in
+// place of the draft and submitted instead. The hook failing refuses the
+// send: a content policy that cannot run must not be skipped.
+
+const (
+	// preSendTimeout bounds one run of the pre-send hook.
+	preSendTimeout = 30 * time.Second
+	// maxPreSendMessageBytes caps the raw message handed to the hook.
+	maxPreSendMessageBytes = 32 << 20
+)
+
+// PreSendHook reviews outgoing messages.
+type PreSendHook interface {
+	// Check reviews raw, the complete RFC 5322 message user is about to
+	// send to recipients.
+	Check(ctx context.Context, user string, recipients []string, raw []byte) (*PreSendVerdict, error)
+}
+
+// PreSendVerdict is a pre-send hook's decision on one message.
+type PreSendVerdict struct {
+	Reject  string // non-empty refuses the send, with this reason
+	Message []byte // replacement RFC 5322 message; nil sends the original
+}
+
+// PreSendCommand is a pre-send hook running an external program and its
+// arguments. The raw message is written to its stdin, the JMAP username is
+// in JMAP_MCP_USER and the envelope recipients, comma-separated, in
+// JMAP_MCP_RECIPIENTS. The program writes a verdict to stdout:
+//
+//	Verdict: reject
+//	Reason: contains a card number
+//
+// Verdict is allow, reject, or replace; replace is followed by a blank
+// line and the replacement message. Empty output allows the message. A
+// non-zero exit is a failure, reported with its stderr.
+type PreSendCommand []string
+
+func (c PreSendCommand) Check(ctx context.Context, user string, recipients []string, raw []byte) (*PreSendVerdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, preSendTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c[0], c[1:]...)
+	cmd.Env = append(os.Environ(), "JMAP_MCP_USER="+user, "JMAP_MCP_RECIPIENTS="+strings.Join(recipients, ","))
+	cmd.Stdin = bytes.NewReader(raw)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return parsePreSendVerdict(stdout.Bytes())
+}
+
+// PreSendHTTP is a pre-send hook served over HTTP. The raw message is
+// POSTed to URL as message/rfc822 with the username and recipients in the
+// X-JMAP-MCP-User and X-JMAP-MCP-Recipients headers; a 200 response
+// carries a verdict in the PreSendCommand output format. Any other status
+// is a failure.
+type PreSendHTTP struct {
+	URL    string
+	Client *http.Client // nil uses a client with preSendTimeout
+}
+
+func (h PreSendHTTP) Check(ctx context.Context, user string, recipients []string, raw []byte) (*PreSendVerdict, error) {
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: preSendTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "message/rfc822")
+	req.Header.Set("X-JMAP-MCP-User", user)
+	req.Header.Set("X-JMAP-MCP-Recipients", strings.Join(recipients, ","))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pre-send endpoint returned %s", resp.Status)
+	}
+	out, err := io.ReadAll(io.LimitReader(resp.Body, maxPreSendMessageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	return parsePreSendVerdict(out)
+}
+
+// parsePreSendVerdict reads the verdict written by a pre-send hook.
+func parsePreSendVerdict(out []byte) (*PreSendVerdict, error) {
+	if len(bytes.TrimSpace(out)) == 0 {
+		return &PreSendVerdict{}, nil
+	}
+	r := bufio.NewReader(bytes.NewReader(out))
+	hdr, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading pre-send hook verdict: %w", err)
+	}
+	switch verdict := strings.ToLower(hdr.Get("Verdict")); verdict {
+	case "allow":
+		return &PreSendVerdict{}, nil
+	case "reject":
+		reason := hdr.Get("Reason")
+		if reason == "" {
+			reason = "rejected by the pre-send hook"
+		}
+		return &PreSendVerdict{Reject: reason}, nil
+	case "replace":
+		msg, _ := io.ReadAll(r)
+		if len(bytes.TrimSpace(msg)) == 0 {
+			return nil, fmt.Errorf("pre-send hook replaced the message but wrote none")
+		}
+		if len(msg) > maxPreSendMessageBytes {
+			return nil, fmt.Errorf("pre-send hook replacement exceeds %d bytes", maxPreSendMessageBytes)
+		}
+		return &PreSendVerdict{Message: msg}, nil
+	default:
+		return nil, fmt.Errorf("pre-send hook gave unknown verdict %q", verdict)
+	}
+}
+
+// applyPreSendHook runs the pre-send hook on the raw message of draft
+// emailID (blobID, when known, saves looking it up) and returns the ID of
+// the email to submit: emailID, or the replacement imported into draftsID,
+// in which case the original draft is destroyed. A rejection or a failing
+// hook is a send policy refusal. Without a hook it returns emailID.
+func (s *Server) applyPreSendHook(ctx context.Context, client JMAPClient, accountID, emailID, blobID, draftsID jmap.ID, recipients []*mail.Address) (jmap.ID, error) {
+	if s.preSend == nil {
+		return emailID, nil
+	}
+	if blobID == "" {
+		var err error
+		if blobID, err = emailBlobID(ctx, client, accountID, emailID); err != nil {
+			return "", err
+		}
+	}
+	raw, err := downloadBlob(ctx, client, accountID, blobID, maxPreSendMessageBytes)
+	if err != nil {
+		return "", fmt.Errorf("download message for the pre-send hook: %w", err)
+	}
+	addrs := make([]string, len(recipients))
+	for i, a := range recipients {
+		addrs[i] = a.Email
+	}
+	verdict, err := s.preSend.Check(ctx, client.Session().Username, addrs, raw)
+	if err != nil {
+		return "", &policyError{Policy: "send", Rule: "pre_send_hook",
+			Detail: fmt.Sprintf("the pre-send hook failed, so email %s was not sent: %v", emailID, err)}
+	}
+	if verdict.Reject != "" {
+		return "", &policyError{Policy: "send", Rule: "pre_send_hook",
+			Detail: fmt.Sprintf("email %s not sent: %s", emailID, verdict.Reject)}
+	}
+	if verdict.Message == nil {
+		return emailID, nil
+	}
+
+	if err := checkQuota(ctx, client, accountID, mailGrowth{what: "importing the pre-send replacement", octets: uint64(len(verdict.Message)), emails: 1}); err != nil {
+		return "", err
+	}
+	upload, err := client.Upload(ctx, accountID, bytes.NewReader(verdict.Message))
+	if err != nil {
+		return "", fmt.Errorf("upload pre-send replacement: %w", err)
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Import{
+		Account: accountID,
+		Emails: map[string]*email.EmailImport{
+			"replacement": {
+				BlobID:     upload.ID,
+				MailboxIDs: map[jmap.ID]bool{draftsID: true},
+				Keywords:   map[string]bool{"$draft": true, "$seen": true},
+			},
+		},
+	})
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for Email/import")
+	}
+	var replacement jmap.ID
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.ImportResponse:
+		if se, ok := args.NotCreated["replacement"]; ok {
+			return "", setFailure("import pre-send replacement", se)
+		}
+		created, ok := args.Created["replacement"]
+		if !ok || created.ID == "" {
+			return "", fmt.Errorf("no ID returned for the imported pre-send replacement")
+		}
+		replacement = created.ID
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	// The replacement is what gets sent; the original draft would only
+	// linger as a near-duplicate. Failing to remove it does not stop the
+	// send.
+	req = &jmap.Request{Context: ctx}
+	req.Invoke(&email.Set{Account: accountID, Destroy: []jmap.ID{emailID}})
+	_, _ = client.Do(req)
+	return replacement, nil
+}
+
+// emailBlobID returns the blob ID of email emailID's raw message.
+func emailBlobID(ctx context.Context, client JMAPClient, accountID, emailID jmap.ID) (jmap.ID, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{Account: accountID, IDs: []jmap.ID{emailID}, Properties: []string{"id", "blobId"}})
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for Email/get")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.List) == 0 || args.List[0].BlobID == "" {
+			return "", notFoundError([]jmap.ID{emailID}, "email not found: %s", emailID)
+		}
+		return args.List[0].BlobID, nil
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+}
@@ -0,0 +1,234 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+// fakePreSend records the messages it reviews and returns a fixed verdict.
+type fakePreSend struct {
+	verdict  *PreSendVerdict
+	err      error
+	reviewed []string
+}
+
+func (h *fakePreSend) Check(_ context.Context, user string, recipients []string, raw []byte) (*PreSendVerdict, error) {
+	h.reviewed = append(h.reviewed, user+" → "+strings.Join(recipients, ",")+": "+string(raw))
+	return h.verdict, h.err
+}
+
+// newPreSendServer returns a server with submission enabled, hook h, any
+// further opts, and draft d1 to bob@example.com whose raw message is
+// "original".
+func newPreSendServer(t *testing.T, h PreSendHook, opts ...Option) (*Server, *jmapfake.Server) {
+	s, fake := newFakeServer(t, append([]Option{WithEmailSubmission(), WithPreSendHook(h)}, opts...)...)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "name": "Me", "email": "me@example.com"})
+	fake.Add("Email", map[string]any{
+		"id": "d1", "subject": "Hello", "blobId": fake.AddBlob([]byte("original")),
+		"to":         []any{map[string]any{"email": "bob@example.com"}},
+		"mailboxIds": map[string]any{"drafts": true}, "keywords": map[string]any{"$draft": true},
+	})
+	return s, fake
+}
+
+func submittedEmailIDs(fake *jmapfake.Server) []string {
+	var ids []string
+	for _, id := range fake.Objects("EmailSubmission") {
+		if emailID, _ := fake.Object("EmailSubmission", id)["emailId"].(string); emailID != "" {
+			ids = append(ids, emailID)
+		}
+	}
+	return ids
+}
+
+func TestPreSendHookAllows(t *testing.T) {
+	hook := &fakePreSend{verdict: &PreSendVerdict{}}
+	s, fake := newPreSendServer(t, hook)
+
+	res, _, _ := s.handleEmailSubmissionSet(context.Background(), nil, EmailSubmissionSetInput{EmailID: "d1"})
+	if res.IsError {
+		t.Fatalf("submission: %s", resultText(res))
+	}
+	if len(hook.reviewed) != 1 || hook.reviewed[0] != "test@example.org → bob@example.com: original" {
+		t.Errorf("hook reviewed %q", hook.reviewed)
+	}
+	if got := submittedEmailIDs(fake); len(got) != 1 || got[0] != "d1" {
+		t.Errorf("submitted %v, want [d1]", got)
+	}
+}
+
+func TestPreSendHookRejects(t *testing.T) {
+	for name, hook := range map[string]*fakePreSend{
+		"reject": {verdict: &PreSendVerdict{Reject: "contains a card number"}},
+		"error":  {err: errors.New("scanner unreachable")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, fake := newPreSendServer(t, hook)
+			ctx := context.Background()
+
+			res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1"})
+			te, _ := res.StructuredContent.(*toolError)
+			if !res.IsError || te == nil || te.Code != codePolicyViolation || te.Rule != "pre_send_hook" {
+				t.Fatalf("submission not refused: %s", resultText(res))
+			}
+			if want := map[string]string{"reject": "contains a card number", "error": "scanner unreachable"}[name]; !strings.Contains(resultText(res), want) {
+				t.Errorf("refusal %q does not mention %q", resultText(res), want)
+			}
+			if got := submittedEmailIDs(fake); len(got) != 0 {
+				t.Errorf("submitted %v despite the hook", got)
+			}
+
+			// The refused submission must not count against the resend window.
+			hook.verdict, hook.err = &PreSendVerdict{}, nil
+			if res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1"}); res.IsError {
+				t.Errorf("submission after the hook allowed: %s", resultText(res))
+			}
+		})
+	}
+}
+
+func TestPreSendHookReplaces(t *testing.T) {
+	hook := &fakePreSend{verdict: &PreSendVerdict{Message: []byte("Subject: Hello\r\n\r\nBody\r\n-- \r\nConfidential.\r\n")}}
+	s, fake := newPreSendServer(t, hook)
+
+	res, _, _ := s.handleEmailSubmissionSet(context.Background(), nil, EmailSubmissionSetInput{EmailID: "d1"})
+	if res.IsError {
+		t.Fatalf("submission: %s", resultText(res))
+	}
+	got := submittedEmailIDs(fake)
+	if len(got) != 1 || got[0] == "d1" {
+		t.Fatalf("submitted %v, want the replacement", got)
+	}
+	replacement := fake.Object("Email", got[0])
+	if !strings.Contains(string(fake.Blob(replacement["blobId"].(string))), "Confidential.") {
+		t.Errorf("replacement blob = %q", fake.Blob(replacement["blobId"].(string)))
+	}
+	// Imported into Drafts, then filed in Sent on submission like any draft.
+	if mailboxes, _ := replacement["mailboxIds"].(map[string]any); mailboxes["sent"] != true || mailboxes["drafts"] == true {
+		t.Errorf("replacement not sent from Drafts: %v", replacement["mailboxIds"])
+	}
+	if fake.Object("Email", "d1") != nil {
+		t.Error("original draft kept")
+	}
+}
+
+func TestPreSendHookRateLimit(t *testing.T) {
+	hook := &fakePreSend{verdict: &PreSendVerdict{Reject: "contains a card number"}}
+	s, fake := newPreSendServer(t, hook, WithSendPolicy(SendPolicy{MaxSendsPerHour: 1}))
+	ctx := context.Background()
+
+	// A send the hook refuses does not use up the hourly limit.
+	if res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1"}); !res.IsError {
+		t.Fatal("submission not refused by the hook")
+	}
+	hook.verdict = &PreSendVerdict{Message: []byte("Subject: Hello\r\n\r\nReplaced\r\n")}
+	if res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1"}); res.IsError {
+		t.Fatalf("submission after the refusal: %s", resultText(res))
+	}
+
+	// Over the limit, the hook never sees the draft, so it is not replaced.
+	fake.Add("Email", map[string]any{
+		"id": "d2", "subject": "Again", "blobId": fake.AddBlob([]byte("second")),
+		"to":         []any{map[string]any{"email": "bob@example.com"}},
+		"mailboxIds": map[string]any{"drafts": true}, "keywords": map[string]any{"$draft": true},
+	})
+	hook.reviewed = nil
+	res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d2"})
+	if te, _ := res.StructuredContent.(*toolError); !res.IsError || te == nil || te.Rule != "max_sends_per_hour" {
+		t.Fatalf("submission over the limit: %s", resultText(res))
+	}
+	if len(hook.reviewed) != 0 || fake.Object("Email", "d2") == nil {
+		t.Errorf("hook ran over the limit: reviewed %q, d2 kept %v", hook.reviewed, fake.Object("Email", "d2") != nil)
+	}
+}
+
+func TestPreSendHookReplacementNotSent(t *testing.T) {
+	hook := &fakePreSend{verdict: &PreSendVerdict{Message: []byte("Subject: Hello\r\n\r\nReplaced\r\n")}}
+	s, fake := newPreSendServer(t, hook)
+	fake.FailNext("EmailSubmission/set", "forbiddenFrom")
+
+	res, _, _ := s.handleEmailSubmissionSet(context.Background(), nil, EmailSubmissionSetInput{EmailID: "d1"})
+	if !res.IsError {
+		t.Fatal("submission did not fail")
+	}
+	var replacement string
+	for _, id := range fake.Objects("Email") {
+		if id != "d1" {
+			replacement = id
+		}
+	}
+	if replacement == "" || !strings.Contains(resultText(res), "replaced draft d1 with "+replacement) {
+		t.Errorf("error %q does not name the replacement %q", resultText(res), replacement)
+	}
+}
+
+func TestParsePreSendVerdict(t *testing.T) {
+	for _, tc := range []struct {
+		out     string
+		want    PreSendVerdict
+		wantErr bool
+	}{
+		{out: "", want: PreSendVerdict{}},
+		{out: "Verdict: allow\n", want: PreSendVerdict{}},
+		{out: "Verdict: Reject\nReason: contains a card number\n", want: PreSendVerdict{Reject: "contains a card number"}},
+		{out: "Verdict: reject\n", want: PreSendVerdict{Reject: "rejected by the pre-send hook"}},
+		{out: "Verdict: replace\n\nSubject: Hi\r\n\r\nBody\r\n", want: PreSendVerdict{Message: []byte("Subject: Hi\r\n\r\nBody\r\n")}},
+		{out: "Verdict: replace\n\n", wantErr: true},
+		{out: "Verdict: maybe\n", wantErr: true},
+	} {
+		got, err := parsePreSendVerdict([]byte(tc.out))
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: no error", tc.out)
+			}
+			continue
+		}
+		if err != nil || got.Reject != tc.want.Reject || string(got.Message) != string(tc.want.Message) {
+			t.Errorf("%q = %+v (%v), want %+v", tc.out, got, err, tc.want)
+		}
+	}
+}
+
+func TestPreSendCommand(t *testing.T) {
+	cmd := PreSendCommand{"sh", "-c", `read line; printf 'Verdict: reject\nReason: %s from %s to %s\n' "$line" "$JMAP_MCP_USER" "$JMAP_MCP_RECIPIENTS"`}
+	v, err := cmd.Check(context.Background(), "me@example.com", []string{"a@example.com", "b@example.com"}, []byte("secret\n"))
+	if err != nil || v.Reject != "secret from me@example.com to a@example.com,b@example.com" {
+		t.Errorf("verdict = %+v (%v)", v, err)
+	}
+
+	_, err = PreSendCommand{"sh", "-c", "echo policy file missing >&2; exit 2"}.Check(context.Background(), "me", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "policy file missing") {
+		t.Errorf("failing command error = %v", err)
+	}
+}
+
+func TestPreSendHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-JMAP-MCP-User") != "me@example.com" || r.Header.Get("X-JMAP-MCP-Recipients") != "bob@example.com" {
+			http.Error(w, "missing headers", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, "Verdict: replace\n\n"+string(body)+"-- \nDisclaimer\n")
+	}))
+	defer srv.Close()
+
+	v, err := PreSendHTTP{URL: srv.URL}.Check(context.Background(), "me@example.com", []string{"bob@example.com"}, []byte("Body\n"))
+	if err != nil || string(v.Message) != "Body\n-- \nDisclaimer\n" {
+		t.Errorf("verdict = %+v (%v)", v, err)
+	}
+
+	_, err = PreSendHTTP{URL: srv.URL}.Check(context.Background(), "someone else", nil, []byte("Body\n"))
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("non-200 response error = %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package jmapmcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// queryResult is a cached Email/query answer: the IDs and total the server
+// returned at queryState.
+type queryResult struct {
+	queryState string
+	ids        []jmap.ID
+	total      uint64
+}
+
+// size estimates the bytes r holds in the tenant cache.
+func (r queryResult) size() int64 {
+	n := int64(len(r.queryState)) + 16
+	for _, id := range r.ids {
+		n += int64(len(id)) + 16
+	}
+	return n
+}
+
+// queryCacheKey identifies q (filter, sort, position, limit, total) on the
+// backend and for the user behind client, within the caller's tenant.
+func queryCacheKey(client JMAPClient, q *email.Query) string {
+	b, _ := json.Marshal(q)
+	sum := sha256.Sum256(b)
+	return "query\x00" + client.Session().APIURL + "\x00" + client.Session().Username + "\x00" + hex.EncodeToString(sum[:])
+}
+
+// rememberQuery caches res under key for the caller when the server can
+// tell later whether it changed. Empty results are not worth a second call.
+func (s *Server) rememberQuery(ctx context.Context, key string, res *email.QueryResponse) {
+	if !res.CanCalculateChanges || res.QueryState == "" || len(res.IDs) == 0 {
+		return
+	}
+	r := queryResult{queryState: res.QueryState, ids: res.IDs, total: res.Total}
+	s.tenants.put(s.idOwner(ctx), key, r, r.size())
+}
+
+// reuseQuery answers q from the cache when Email/queryChanges reports no
+// change since the cached queryState, fetching properties of the cached
+// IDs in the same request. It returns false (and forgets the entry) when
+// nothing is cached, the results changed, or the server cannot tell; the
+// caller then runs the query.
+func (s *Server) reuseQuery(ctx context.Context, client JMAPClient, key string, q *email.Query, properties []string) (uint64, []*email.Email, bool) {
+	owner := s.idOwner(ctx)
+	v, ok := s.tenants.get(owner, key)
+	if !ok {
+		return 0, nil, false
+	}
+	cached := v.(queryResult)
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.QueryChanges{
+		Account:         q.Account,
+		Filter:          q.Filter,
+		Sort:            q.Sort,
+		SinceQueryState: cached.queryState,
+	})
+	req.Invoke(&email.Get{
+		Account:    q.Account,
+		IDs:        cached.ids,
+		Properties: properties,
+	})
+	resp, err := client.Do(req)
+	if err != nil || len(resp.Responses) < 2 {
+		s.tenants.drop(owner, key)
+		return 0, nil, false
+	}
+	changes, okChanges := resp.Responses[0].Args.(*email.QueryChangesResponse)
+	got, okGet := resp.Responses[1].Args.(*email.GetResponse)
+	if !okChanges || !okGet || len(changes.Removed) > 0 || len(changes.Added) > 0 || len(got.NotFound) > 0 {
+		s.tenants.drop(owner, key)
+		return 0, nil, false
+	}
+
+	// Nothing was added or removed, so the cached total still holds.
+	if changes.NewQueryState != "" && changes.NewQueryState != cached.queryState {
+		cached.queryState = changes.NewQueryState
+		s.tenants.put(owner, key, cached, cached.size())
+	}
+	return cached.total, orderByIDs(got.List, cached.ids), true
+}
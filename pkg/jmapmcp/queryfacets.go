@@ -0,0 +1,238 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Facets give an overview of the set an email_query matches before its
+// messages are fetched: exact counts per mailbox and per week from extra
+// Email/query calls with calculateTotal, and sender domains counted over a
+// sample of the newest matches.
+
+const (
+	facetWeeks  = 8   // weeks counted, the current one first
+	facetSample = 500 // newest matches whose sender domains are counted
+	facetTop    = 10  // mailboxes and domains listed
+)
+
+var queryFacetNames = []string{"mailbox", "sender_domain", "week"}
+
+// emailQueryStructured is the structured content of an email_query result
+// that carries narrowing guidance, facets, or, with structured output, the
+// emails.
+type emailQueryStructured struct {
+	Emails    []emailSummary  `json:"emails,omitempty"`
+	Narrowing *queryNarrowing `json:"narrowing,omitempty"`
+	Facets    *queryFacets    `json:"facets,omitempty"`
+}
+
+// queryFacets holds the requested facets of a query.
+type queryFacets struct {
+	Mailboxes     []facetCount `json:"mailboxes,omitempty"`
+	SenderDomains []facetCount `json:"senderDomains,omitempty"`
+	SenderSample  int          `json:"senderSample,omitempty"`
+	Weeks         []facetCount `json:"weeks,omitempty"`
+}
+
+// facetCount is the number of matches with one value of a facet.
+type facetCount struct {
+	Value string `json:"value"`
+	ID    string `json:"id,omitempty"`
+	Count uint64 `json:"count"`
+}
+
+// checkFacets validates the facets input of email_query.
+func checkFacets(facets []string) error {
+	for _, f := range facets {
+		if !slices.Contains(queryFacetNames, f) {
+			return invalidArgument("unknown facet %q: expected %s", f, strings.Join(queryFacetNames, ", "))
+		}
+	}
+	return nil
+}
+
+// queryFacetsOf computes facets for the emails filter matches. Counting
+// facets need calculateTotal, which withTotal reports the server supports.
+func queryFacetsOf(ctx context.Context, client JMAPClient, accountID jmap.ID, filter email.Filter, facets []string, withTotal bool, now time.Time) (*queryFacets, error) {
+	out := &queryFacets{}
+	if (slices.Contains(facets, "mailbox") || slices.Contains(facets, "week")) && !withTotal {
+		return nil, fmt.Errorf("the server cannot count query results, so mailbox and week facets are unavailable")
+	}
+
+	if slices.Contains(facets, "mailbox") {
+		mailboxes, err := fetchMailboxes(ctx, client, accountID)
+		if err != nil {
+			return nil, err
+		}
+		var ids []jmap.ID
+		var filters []email.Filter
+		for id := range mailboxes {
+			ids = append(ids, id)
+			filters = append(filters, andFilter(filter, &email.FilterCondition{InMailbox: id}))
+		}
+		counts, err := countQueries(ctx, client, accountID, filters)
+		if err != nil {
+			return nil, err
+		}
+		for i, id := range ids {
+			if counts[i] > 0 {
+				out.Mailboxes = append(out.Mailboxes, facetCount{Value: mailboxPath(mailboxes[id], mailboxes), ID: string(id), Count: counts[i]})
+			}
+		}
+		out.Mailboxes = topFacets(out.Mailboxes)
+	}
+
+	if slices.Contains(facets, "week") {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		var filters []email.Filter
+		var starts []time.Time
+		for i := range facetWeeks {
+			start := monday.AddDate(0, 0, -7*i)
+			end := start.AddDate(0, 0, 7)
+			starts = append(starts, start)
+			filters = append(filters, andFilter(filter, &email.FilterCondition{After: &start, Before: &end}))
+		}
+		oldest := starts[len(starts)-1]
+		filters = append(filters, andFilter(filter, &email.FilterCondition{Before: &oldest}))
+		counts, err := countQueries(ctx, client, accountID, filters)
+		if err != nil {
+			return nil, err
+		}
+		for i, start := range starts {
+			out.Weeks = append(out.Weeks, facetCount{Value: "week of " + start.Format(time.DateOnly), Count: counts[i]})
+		}
+		if earlier := counts[len(starts)]; earlier > 0 {
+			out.Weeks = append(out.Weeks, facetCount{Value: "before " + oldest.Format(time.DateOnly), Count: earlier})
+		}
+	}
+
+	if slices.Contains(facets, "sender_domain") {
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(&email.Query{
+			Account: accountID,
+			Filter:  filter,
+			Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Limit:   facetSample,
+		})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+			Properties:   []string{"id", "from"},
+		})
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < 2 {
+			return nil, fmt.Errorf("missing Email/get response in query chain")
+		}
+		if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+			return nil, me
+		}
+		gr, ok := resp.Responses[1].Args.(*email.GetResponse)
+		if !ok {
+			return nil, fmt.Errorf("unexpected response type: %T", resp.Responses[1].Args)
+		}
+		out.SenderSample = len(gr.List)
+		domains := make(map[string]uint64)
+		for _, e := range gr.List {
+			if len(e.From) > 0 {
+				if d := addressDomain(e.From[0].Email); d != "" {
+					domains[d]++
+				}
+			}
+		}
+		for d, n := range domains {
+			out.SenderDomains = append(out.SenderDomains, facetCount{Value: unicodeDomain(d), Count: n})
+		}
+		out.SenderDomains = topFacets(out.SenderDomains)
+	}
+	return out, nil
+}
+
+// andFilter matches emails both a and b match.
+func andFilter(a, b email.Filter) email.Filter {
+	return &email.FilterOperator{Operator: jmap.OperatorAND, Conditions: []email.Filter{a, b}}
+}
+
+// topFacets sorts counts by count, highest first, and keeps the first
+// facetTop.
+func topFacets(counts []facetCount) []facetCount {
+	slices.SortFunc(counts, func(a, b facetCount) int {
+		if a.Count != b.Count {
+			if a.Count > b.Count {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Value, b.Value)
+	})
+	return counts[:min(len(counts), facetTop)]
+}
+
+// countQueries returns how many emails each of filters matches, packing as
+// many counting queries into each request as the server allows.
+func countQueries(ctx context.Context, client JMAPClient, accountID jmap.ID, filters []email.Filter) ([]uint64, error) {
+	perTrip := defaultMaxCallsInRequest
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxCallsInRequest > 0 {
+		perTrip = int(c.MaxCallsInRequest)
+	}
+
+	counts := make([]uint64, 0, len(filters))
+	for start := 0; start < len(filters); start += perTrip {
+		trip := filters[start:min(start+perTrip, len(filters))]
+		req := &jmap.Request{Context: ctx}
+		for _, f := range trip {
+			req.Invoke(&email.Query{Account: accountID, Filter: f, Limit: 1, CalculateTotal: true})
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < len(trip) {
+			return nil, fmt.Errorf("expected %d responses, got %d", len(trip), len(resp.Responses))
+		}
+		for i := range trip {
+			switch args := resp.Responses[i].Args.(type) {
+			case *email.QueryResponse:
+				counts = append(counts, args.Total)
+			case *jmap.MethodError:
+				return nil, args
+			default:
+				return nil, fmt.Errorf("unexpected response type: %T", args)
+			}
+		}
+	}
+	return counts, nil
+}
+
+// format renders f as the facet section of an email_query result.
+func (f *queryFacets) format() string {
+	var sb strings.Builder
+	section := func(title string, counts []facetCount) {
+		if len(counts) == 0 {
+			return
+		}
+		fmt.Fprintf(&sb, "\n%s:\n", title)
+		for _, c := range counts {
+			if c.ID != "" {
+				fmt.Fprintf(&sb, "- %s [id: %s]: %d\n", c.Value, c.ID, c.Count)
+			} else {
+				fmt.Fprintf(&sb, "- %s: %d\n", c.Value, c.Count)
+			}
+		}
+	}
+	section("Matches by mailbox", f.Mailboxes)
+	section(fmt.Sprintf("Sender domains among the newest %d matches", f.SenderSample), f.SenderDomains)
+	section("Matches by week", f.Weeks)
+	return sb.String()
+}
@@ -0,0 +1,57 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailQueryFacets(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "arch", "name": "Archive"})
+	fake.Add("Mailbox", map[string]any{"id": "empty", "name": "Empty"})
+	now := time.Now().UTC()
+	for i := range 30 {
+		mailbox, from := "inbox", "news@example.org"
+		if i%3 == 0 {
+			mailbox, from = "arch", "shop@xn--bcher-kva.de"
+		}
+		fake.Add("Email", map[string]any{
+			"id": fmt.Sprintf("e%d", i), "subject": "Update", "mailboxIds": map[string]any{mailbox: true},
+			"receivedAt": now.Add(-time.Duration(i) * 72 * time.Hour).Format(time.RFC3339),
+			"from":       []any{map[string]any{"email": from}},
+		})
+	}
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{Limit: 5, Facets: []string{"mailbox", "sender_domain", "week"}})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "- Inbox [id: inbox]: 20") || !strings.Contains(out, "- Archive [id: arch]: 10") ||
+		strings.Contains(out, "Empty") || !strings.Contains(out, "- bücher.de: 10") || !strings.Contains(out, "newest 30 matches") {
+		t.Fatalf("email_query facets = %s", out)
+	}
+	sc, ok := res.StructuredContent.(*emailQueryStructured)
+	if !ok || sc.Facets == nil || sc.Narrowing != nil {
+		t.Fatalf("structured content = %#v", res.StructuredContent)
+	}
+	var sum uint64
+	for _, w := range sc.Facets.Weeks {
+		sum += w.Count
+	}
+	if len(sc.Facets.Weeks) != facetWeeks+1 || sum != 30 || !strings.HasPrefix(sc.Facets.Weeks[facetWeeks].Value, "before ") {
+		t.Errorf("weeks = %+v", sc.Facets.Weeks)
+	}
+
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{MailboxID: "arch", Facets: []string{"week"}})
+	if sc, _ := res.StructuredContent.(*emailQueryStructured); sc == nil || len(sc.Facets.Weeks) == 0 || sc.Facets.Mailboxes != nil {
+		t.Errorf("facets within a mailbox = %s", resultText(res))
+	}
+
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{Facets: []string{"label"}})
+	if !res.IsError || !strings.Contains(resultText(res), `unknown facet "label"`) {
+		t.Errorf("unknown facet = %s", resultText(res))
+	}
+}
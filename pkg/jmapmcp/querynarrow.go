@@ -0,0 +1,172 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// An email_query whose total dwarfs the page it returns comes with ways to
+// narrow it: how many matches fall in a few date ranges, and the senders
+// most frequent among the newest matches. Agents can then refine the query
+// instead of paging through the same newest results.
+
+const (
+	narrowThreshold = 1000 // totals above which email_query suggests narrowing
+	narrowSample    = 200  // newest matches whose senders are counted
+	narrowSenders   = 5    // senders suggested
+)
+
+// queryNarrowing is the guidance for a large query, also returned in the
+// structured content of the result.
+type queryNarrowing struct {
+	Total   uint64        `json:"total"`
+	Periods []periodFacet `json:"periods,omitempty"`
+	Senders []senderFacet `json:"senders,omitempty"`
+	Sample  int           `json:"senderSample,omitempty"`
+}
+
+// periodFacet counts the matches received in a date range, given as the
+// after and before values of email_query that select it.
+type periodFacet struct {
+	Label  string `json:"label"`
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
+	Count  uint64 `json:"count"`
+}
+
+// senderFacet counts the messages from one sender among the sample.
+type senderFacet struct {
+	From  string `json:"from"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+}
+
+// narrowQuery computes the narrowing guidance for filter, matching total
+// emails, in one request: a count per date range and the senders of the
+// newest narrowSample matches.
+func narrowQuery(ctx context.Context, client JMAPClient, accountID jmap.ID, filter email.Filter, total uint64, now time.Time) (*queryNarrowing, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	day := func(daysAgo int) time.Time { return today.AddDate(0, 0, -daysAgo) }
+	date := func(t time.Time) string { return t.Format(time.DateOnly) }
+	// Bounds are midnights; email_query reads a bare before date as the
+	// end of that day, so a range ending at b is given as before b-1.
+	ranges := []struct {
+		label    string
+		from, to time.Time
+	}{
+		{"last 7 days", day(6), time.Time{}},
+		{"8 to 30 days ago", day(29), day(6)},
+		{"1 to 12 months ago", day(364), day(29)},
+		{"over a year ago", time.Time{}, day(364)},
+	}
+
+	req := &jmap.Request{Context: ctx}
+	for _, r := range ranges {
+		cond := &email.FilterCondition{}
+		if !r.from.IsZero() {
+			cond.After = &r.from
+		}
+		if !r.to.IsZero() {
+			cond.Before = &r.to
+		}
+		req.Invoke(&email.Query{
+			Account:        accountID,
+			Filter:         &email.FilterOperator{Operator: jmap.OperatorAND, Conditions: []email.Filter{filter, cond}},
+			Limit:          1,
+			CalculateTotal: true,
+		})
+	}
+	sampleCallID := req.Invoke(&email.Query{
+		Account: accountID,
+		Filter:  filter,
+		Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+		Limit:   narrowSample,
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: sampleCallID, Name: "Email/query", Path: "/ids"},
+		Properties:   []string{"id", "from"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < len(ranges)+2 {
+		return nil, fmt.Errorf("expected %d responses, got %d", len(ranges)+2, len(resp.Responses))
+	}
+
+	out := &queryNarrowing{Total: total}
+	for i, r := range ranges {
+		qr, ok := resp.Responses[i].Args.(*email.QueryResponse)
+		if !ok {
+			return nil, fmt.Errorf("unexpected response type: %T", resp.Responses[i].Args)
+		}
+		if qr.Total == 0 {
+			continue
+		}
+		p := periodFacet{Label: r.label, Count: qr.Total}
+		if !r.from.IsZero() {
+			p.After = date(r.from)
+		}
+		if !r.to.IsZero() {
+			p.Before = date(r.to.AddDate(0, 0, -1))
+		}
+		out.Periods = append(out.Periods, p)
+	}
+
+	gr, ok := resp.Responses[len(ranges)+1].Args.(*email.GetResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type: %T", resp.Responses[len(ranges)+1].Args)
+	}
+	out.Sample = len(gr.List)
+	counts := make(map[string]*senderFacet)
+	for _, e := range gr.List {
+		if len(e.From) == 0 {
+			continue
+		}
+		key := strings.ToLower(asciiAddress(strings.TrimSpace(e.From[0].Email)))
+		if counts[key] == nil {
+			counts[key] = &senderFacet{From: displayAddress(strings.TrimSpace(e.From[0].Email)), Name: e.From[0].Name}
+		}
+		counts[key].Count++
+	}
+	for _, f := range counts {
+		out.Senders = append(out.Senders, *f)
+	}
+	slices.SortFunc(out.Senders, func(a, b senderFacet) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.From, b.From)
+	})
+	out.Senders = out.Senders[:min(len(out.Senders), narrowSenders)]
+	return out, nil
+}
+
+// format renders n as the note email_query appends to a large result.
+func (n *queryNarrowing) format() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\nToo many matches (%d) to page through; narrow the query instead:\n", n.Total)
+	for _, p := range n.Periods {
+		var args []string
+		if p.After != "" {
+			args = append(args, "after "+p.After)
+		}
+		if p.Before != "" {
+			args = append(args, "before "+p.Before)
+		}
+		fmt.Fprintf(&sb, "- %s (%s): %d\n", p.Label, strings.Join(args, ", "), p.Count)
+	}
+	for _, f := range n.Senders {
+		fmt.Fprintf(&sb, "- from %s: %d of the newest %d\n", (&mail.Address{Name: f.Name, Email: f.From}).String(), f.Count, n.Sample)
+	}
+	return sb.String()
+}
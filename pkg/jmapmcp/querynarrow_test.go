@@ -0,0 +1,55 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailQueryNarrowing(t *testing.T) {
+	s, fake := newFakeServer(t)
+	now := time.Now().UTC()
+	for i := range narrowThreshold + 50 {
+		from := "list@example.org"
+		if i%4 == 0 {
+			from = "boss@example.com"
+		}
+		// Two messages a day, going back well over a year.
+		fake.Add("Email", map[string]any{
+			"id": fmt.Sprintf("e%d", i), "subject": "Update", "receivedAt": now.Add(-time.Duration(i) * 12 * time.Hour).Format(time.RFC3339),
+			"from": []any{map[string]any{"email": from}},
+		})
+	}
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{Limit: 20})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Too many matches (1050)") {
+		t.Fatalf("email_query = %s", out)
+	}
+	sc, ok := res.StructuredContent.(*emailQueryStructured)
+	if !ok || sc.Narrowing == nil {
+		t.Fatalf("structured content = %#v", res.StructuredContent)
+	}
+	n := sc.Narrowing
+	var sum uint64
+	for _, p := range n.Periods {
+		sum += p.Count
+	}
+	if len(n.Periods) != 4 || sum != 1050 || n.Periods[0].Count != 13 && n.Periods[0].Count != 14 || n.Periods[0].After == "" || n.Periods[3].Before == "" {
+		t.Errorf("periods = %+v", n.Periods)
+	}
+	if len(n.Senders) != 2 || n.Senders[0].From != "list@example.org" || n.Senders[0].Count != 150 || n.Sample != narrowSample {
+		t.Errorf("senders = %+v (sample %d)", n.Senders, n.Sample)
+	}
+	if !strings.Contains(out, "- from list@example.org: 150 of the newest 200") {
+		t.Errorf("email_query = %s", out)
+	}
+
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{From: "boss@example.com"})
+	if res.StructuredContent != nil || strings.Contains(resultText(res), "Too many matches") {
+		t.Errorf("a narrow query got guidance: %s", resultText(res))
+	}
+}
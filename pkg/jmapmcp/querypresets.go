@@ -0,0 +1,161 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Query presets give a deployment its own search tools without code. Each
+// is a named email_query, listed in a JSON file (-query-presets-file),
+// that becomes a read-only tool named query_<name>:
+//
+//	[{"name": "invoices",
+//	  "description": "Invoices and receipts with attachments",
+//	  "filter": {"query": "invoice", "has_attachment": true},
+//	  "sort": "newest", "columns": ["receivedAt", "from", "subject"], "limit": 50}]
+//
+// The filter takes email_query's arguments. A call may narrow the preset
+// by date and limit, and search text when the preset has none; it runs as
+// email_query, with its checks, muting, caching, and output.
+
+// queryPresetPrefix starts the tool name of every query preset.
+const queryPresetPrefix = "query_"
+
+// queryPresetSorts are the sort orders a preset may name.
+var queryPresetSorts = map[string]*email.SortComparator{
+	"newest":   {Property: "receivedAt", IsAscending: false},
+	"sent":     {Property: "sentAt", IsAscending: false},
+	"oldest":   {Property: "receivedAt", IsAscending: true},
+	"largest":  {Property: "size", IsAscending: false},
+	"smallest": {Property: "size", IsAscending: true},
+	"sender":   {Property: "from", IsAscending: true},
+	"subject":  {Property: "subject", IsAscending: true},
+}
+
+// queryPresetColumns are the email_query fields a preset may show.
+var queryPresetColumns = []string{"subject", "from", "to", "receivedAt", "sentAt", "size", "importance"}
+
+// QueryPreset is one configured query preset.
+type QueryPreset struct {
+	Name        string          `json:"name"`        // tool query_<name>; lower case letters, digits, and _
+	Description string          `json:"description"` // tool description shown to clients
+	Filter      EmailQueryInput `json:"filter"`
+	Sort        string          `json:"sort,omitempty"`    // a key of queryPresetSorts; empty is newest
+	Columns     []string        `json:"columns,omitempty"` // email_query fields; empty is its default
+	Limit       int             `json:"limit,omitempty"`   // 0 is the server's query limit
+}
+
+// LoadQueryPresets reads the presets listed at path, a JSON array of
+// presets.
+func LoadQueryPresets(path string) ([]QueryPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("query presets: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var presets []QueryPreset
+	if err := dec.Decode(&presets); err != nil {
+		return nil, fmt.Errorf("query presets %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(presets))
+	for _, p := range presets {
+		switch {
+		case !scriptNameRe.MatchString(p.Name):
+			return nil, fmt.Errorf("query presets %s: name %q must be lower case letters, digits, and _", path, p.Name)
+		case seen[p.Name]:
+			return nil, fmt.Errorf("query presets %s: duplicate name %q", path, p.Name)
+		case p.Sort != "" && queryPresetSorts[p.Sort] == nil:
+			return nil, fmt.Errorf("query presets %s: %s has unknown sort %q (want newest, oldest, sent, largest, smallest, sender, or subject)", path, p.Name, p.Sort)
+		case p.Limit < 0:
+			return nil, fmt.Errorf("query presets %s: %s has a negative limit", path, p.Name)
+		case len(p.Filter.Fields) > 0:
+			return nil, fmt.Errorf("query presets %s: %s sets fields in its filter; use columns", path, p.Name)
+		}
+		for _, c := range p.Columns {
+			if !slices.Contains(queryPresetColumns, c) {
+				return nil, fmt.Errorf("query presets %s: %s has unknown column %q (want %s)", path, p.Name, c, strings.Join(queryPresetColumns, ", "))
+			}
+		}
+		if _, err := p.Filter.filter(); err != nil {
+			return nil, fmt.Errorf("query presets %s: %s: %w", path, p.Name, err)
+		}
+		if err := checkFacets(p.Filter.Facets); err != nil {
+			return nil, fmt.Errorf("query presets %s: %s: %w", path, p.Name, err)
+		}
+		seen[p.Name] = true
+	}
+	return presets, nil
+}
+
+// isListingTool reports whether the tool named name takes max_tokens.
+func isListingTool(name string) bool {
+	return listingTools[name] || strings.HasPrefix(name, queryPresetPrefix)
+}
+
+// --- query_<name> ---
+
+type QueryPresetInput struct {
+	Query  string `json:"query,omitempty" jsonschema:"Full-text search within the preset's results (only for presets without search text of their own)"`
+	After  string `json:"after,omitempty" jsonschema:"Emails after this date (RFC 3339 or YYYY-MM-DD), replacing the preset's"`
+	Before string `json:"before,omitempty" jsonschema:"Emails before this date (RFC 3339 or YYYY-MM-DD), replacing the preset's"`
+	Limit  int    `json:"limit,omitempty" jsonschema:"Maximum number of results, replacing the preset's"`
+}
+
+// registerQueryPresets adds a tool for each configured query preset.
+func (s *Server) registerQueryPresets() {
+	for _, p := range s.queryPresets {
+		t := &mcp.Tool{
+			Name:        queryPresetPrefix + p.Name,
+			Description: p.Description,
+			Annotations: readOnlyAnnotations,
+		}
+		if t.Description == "" {
+			t.Description = fmt.Sprintf("Run the operator's %s email search.", p.Name)
+		}
+		t.Description += " Returns ID plus the preset's fields per match, as email_query does; query, after, before, and limit narrow it."
+		addTool(s, t, func(ctx context.Context, req *mcp.CallToolRequest, in QueryPresetInput) (*mcp.CallToolResult, any, error) {
+			q, err := p.input(in)
+			if err != nil {
+				return errorResult(err), nil, nil
+			}
+			return s.handleEmailQuery(ctx, req, q)
+		})
+	}
+}
+
+// input is the email_query input of a call of p with in.
+func (p *QueryPreset) input(in QueryPresetInput) (EmailQueryInput, error) {
+	q := p.Filter
+	q.Fields = slices.Clone(p.Columns)
+	q.Headers = slices.Clone(p.Filter.Headers)
+	q.Facets = slices.Clone(p.Filter.Facets)
+	q.Limit = p.Limit
+	if p.Sort != "" {
+		q.sort = []*email.SortComparator{queryPresetSorts[p.Sort]}
+	}
+	if in.Query != "" {
+		if q.Query != "" {
+			return q, invalidArgument("query_%s has its own search text; narrow it by date or limit instead", p.Name)
+		}
+		q.Query = in.Query
+	}
+	if in.After != "" {
+		q.After = in.After
+	}
+	if in.Before != "" {
+		q.Before = in.Before
+	}
+	if in.Limit > 0 {
+		q.Limit = in.Limit
+	}
+	return q, nil
+}
@@ -0,0 +1,55 @@
+package jmapmcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadQueryPresets(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		json string
+		ok   bool
+	}{
+		{`[{"name": "invoices", "filter": {"query": "invoice", "has_attachment": true}, "sort": "oldest", "columns": ["subject"], "limit": 5}]`, true},
+		{`[{"name": "Invoices"}]`, false},
+		{`[{"name": "a"}, {"name": "a"}]`, false},
+		{`[{"name": "a", "sort": "random"}]`, false},
+		{`[{"name": "a", "columns": ["body"]}]`, false},
+		{`[{"name": "a", "filter": {"after": "last week"}}]`, false},
+		{`[{"name": "a", "filter": {"folder": "inbox"}}]`, false},
+	} {
+		path := filepath.Join(dir, "presets.json")
+		if err := os.WriteFile(path, []byte(tc.json), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadQueryPresets(path); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.json, err)
+		}
+	}
+}
+
+func TestQueryPresetTool(t *testing.T) {
+	s, fake := newFakeServer(t, WithQueryPresets([]QueryPreset{{
+		Name:    "invoices",
+		Filter:  EmailQueryInput{Subject: "Invoice"},
+		Sort:    "oldest",
+		Columns: []string{"receivedAt", "subject"},
+	}}))
+	addEmail(fake, "e1", "Invoice January", "", 1)
+	addEmail(fake, "e2", "Lunch", "", 2)
+	addEmail(fake, "e3", "Invoice February", "", 3)
+	cs := rootsSession(t, s)
+
+	out, isErr := callTool(t, cs, "query_invoices", nil)
+	if isErr || strings.Contains(out, "Lunch") || strings.Contains(out, "alice@") ||
+		strings.Index(out, "Invoice January") > strings.Index(out, "Invoice February") {
+		t.Errorf("query_invoices:\n%s", out)
+	}
+	out, isErr = callTool(t, cs, "query_invoices", map[string]any{"after": "2025-01-02", "max_tokens": 500})
+	if isErr || strings.Contains(out, "January") || !strings.Contains(out, "Invoice February") {
+		t.Errorf("query_invoices after 2025-01-02:\n%s", out)
+	}
+}
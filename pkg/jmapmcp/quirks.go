@@ -0,0 +1,185 @@
+package jmapmcp
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/sieve"
+)
+
+// quirk is a known deviation of a JMAP backend from what the tools assume.
+type quirk uint
+
+const (
+	// quirkNoCalculateTotal: Email/query rejects or ignores calculateTotal.
+	quirkNoCalculateTotal quirk = 1 << iota
+	// quirkNoTextSearch: no full-text index, so the "text" filter is refused.
+	quirkNoTextSearch
+	// quirkNoHeaderFilter: the "header" filter condition is refused.
+	quirkNoHeaderFilter
+	// quirkNoHeadersProperty: Email/get does not return the "headers"
+	// property; headers are parsed from the raw message instead.
+	quirkNoHeadersProperty
+	// quirkNoThreads: Thread/get is an unknown method, so threads are
+	// reconstructed client-side (see threadFallback).
+	quirkNoThreads
+)
+
+// knownQuirks lists deviations known up front for each detected backend.
+// Others are learned from method errors at runtime.
+var knownQuirks = map[string]quirk{
+	"james": quirkNoCalculateTotal | quirkNoHeaderFilter,
+}
+
+// quirks tracks the deviations of one backend (keyed by API URL). Detection
+// from the session seeds it; tools add quirks as they hit unsupported
+// features, so a retry or fallback happens once rather than on every call.
+type quirks struct {
+	backend string
+
+	mu  sync.Mutex
+	set quirk
+}
+
+func (q *quirks) has(k quirk) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.set&k != 0
+}
+
+func (q *quirks) learn(k quirk) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.set |= k
+}
+
+// quirksFor returns the quirks of the backend behind client.
+func (s *Server) quirksFor(client JMAPClient) *quirks {
+	key := client.Session().APIURL
+	if v, ok := s.quirks.Load(key); ok {
+		return v.(*quirks)
+	}
+	backend := detectBackend(client.Session())
+	v, _ := s.quirks.LoadOrStore(key, &quirks{backend: backend, set: knownQuirks[backend]})
+	return v.(*quirks)
+}
+
+// detectBackend guesses the server implementation from vendor capability
+// URIs and the Sieve implementation string. It returns "unknown" when
+// nothing identifies the server.
+func detectBackend(session *jmap.Session) string {
+	uris := make([]string, 0, len(session.RawCapabilities))
+	for uri := range session.RawCapabilities {
+		uris = append(uris, string(uri))
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		switch {
+		case strings.HasPrefix(uri, "https://www.fastmail.com/"):
+			return "fastmail"
+		case strings.HasPrefix(uri, "https://cyrusimap.org/"):
+			return "cyrus"
+		case strings.HasPrefix(uri, "urn:apache:james:"):
+			return "james"
+		}
+	}
+	if c, ok := session.Capabilities[sieve.URI].(*sieve.Capability); ok {
+		impl := strings.ToLower(c.Implementation)
+		switch {
+		case strings.Contains(impl, "stalwart"):
+			return "stalwart"
+		case strings.Contains(impl, "cyrus"):
+			return "cyrus"
+		case strings.Contains(impl, "james"):
+			return "james"
+		}
+	}
+	return "unknown"
+}
+
+// isUnsupportedFilter reports whether err is the method error a server
+// returns for a filter condition it cannot evaluate.
+func isUnsupportedFilter(err *jmap.MethodError) bool {
+	return err.Type == "unsupportedFilter"
+}
+
+// isCalculateTotalRefusal reports whether err looks like a rejection of
+// calculateTotal rather than of the query itself.
+func isCalculateTotalRefusal(err *jmap.MethodError) bool {
+	if err.Type != "invalidArguments" && err.Type != "unknownArgument" {
+		return false
+	}
+	return err.Description == nil || strings.Contains(strings.ToLower(*err.Description), "total")
+}
+
+// fillRawHeaders supplies headers for emails fetched with the "headers"
+// property when the backend left them all empty, reading them from each
+// raw message (which requires blobId to have been fetched too).
+func (s *Server) fillRawHeaders(ctx context.Context, client JMAPClient, q *quirks, accountID jmap.ID, list []*email.Email) {
+	if len(list) == 0 {
+		return
+	}
+	if !q.has(quirkNoHeadersProperty) {
+		for _, e := range list {
+			if len(e.Headers) > 0 {
+				return
+			}
+		}
+		q.learn(quirkNoHeadersProperty)
+	}
+	for _, e := range list {
+		if len(e.Headers) > 0 || e.BlobID == "" {
+			continue
+		}
+		if headers, err := rawHeaders(ctx, client, accountID, e.BlobID); err == nil {
+			e.Headers = headers
+		}
+	}
+}
+
+// maxRawHeaderBytes caps how much of a raw message is downloaded to read its
+// header block.
+const maxRawHeaderBytes = 64 << 10
+
+// rawHeaders reads the header block of a message from its raw blob, for
+// backends that do not return the "headers" property.
+func rawHeaders(ctx context.Context, client JMAPClient, accountID, blobID jmap.ID) ([]*email.Header, error) {
+	body, err := client.Download(ctx, accountID, blobID)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxRawHeaderBytes))
+	if err != nil {
+		return nil, err
+	}
+	return parseHeaderBlock(string(data)), nil
+}
+
+// parseHeaderBlock parses RFC 5322 headers up to the first blank line, in
+// message order. Folded lines are unfolded.
+func parseHeaderBlock(raw string) []*email.Header {
+	var out []*email.Header
+	for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		if line == "" {
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(out) > 0 {
+				out[len(out)-1].Value += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		out = append(out, &email.Header{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package jmapmcp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/sieve"
+)
+
+func TestDetectBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		session *jmap.Session
+		want    string
+	}{
+		{
+			name: "fastmail capability",
+			session: &jmap.Session{RawCapabilities: map[jmap.URI]json.RawMessage{
+				"urn:ietf:params:jmap:core":                {},
+				"https://www.fastmail.com/dev/maskedemail": {},
+			}},
+			want: "fastmail",
+		},
+		{
+			name: "cyrus capability",
+			session: &jmap.Session{RawCapabilities: map[jmap.URI]json.RawMessage{
+				"https://cyrusimap.org/ns/jmap/performance": {},
+			}},
+			want: "cyrus",
+		},
+		{
+			name: "james capability",
+			session: &jmap.Session{RawCapabilities: map[jmap.URI]json.RawMessage{
+				"urn:apache:james:params:jmap:mail:quota": {},
+			}},
+			want: "james",
+		},
+		{
+			name: "stalwart sieve implementation",
+			session: &jmap.Session{Capabilities: map[jmap.URI]jmap.Capability{
+				sieve.URI: &sieve.Capability{Implementation: "Stalwart v0.11"},
+			}},
+			want: "stalwart",
+		},
+		{
+			name:    "nothing identifying",
+			session: &jmap.Session{},
+			want:    "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectBackend(tt.session); got != tt.want {
+				t.Errorf("detectBackend() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuirksFor(t *testing.T) {
+	s := &Server{}
+	james := NewJMAPClient(&jmap.Client{Session: &jmap.Session{
+		APIURL:          "https://james.example/jmap",
+		RawCapabilities: map[jmap.URI]json.RawMessage{"urn:apache:james:params:jmap:mail:quota": {}},
+	}})
+	q := s.quirksFor(james)
+	if !q.has(quirkNoCalculateTotal) || !q.has(quirkNoHeaderFilter) {
+		t.Errorf("james quirks not seeded: %b", q.set)
+	}
+	if q.has(quirkNoTextSearch) {
+		t.Error("james should not start with quirkNoTextSearch")
+	}
+
+	q.learn(quirkNoTextSearch)
+	if !s.quirksFor(james).has(quirkNoTextSearch) {
+		t.Error("learned quirk not kept for the same API URL")
+	}
+
+	other := NewJMAPClient(&jmap.Client{Session: &jmap.Session{APIURL: "https://other.example/jmap"}})
+	if s.quirksFor(other).has(quirkNoTextSearch) {
+		t.Error("learned quirk leaked to another backend")
+	}
+}
+
+func TestIsCalculateTotalRefusal(t *testing.T) {
+	desc := func(s string) *string { return &s }
+	tests := []struct {
+		err  *jmap.MethodError
+		want bool
+	}{
+		{&jmap.MethodError{Type: "unknownArgument"}, true},
+		{&jmap.MethodError{Type: "invalidArguments", Description: desc("calculateTotal is not supported")}, true},
+		{&jmap.MethodError{Type: "invalidArguments", Description: desc("bad mailbox id")}, false},
+		{&jmap.MethodError{Type: "unsupportedFilter"}, false},
+	}
+	for _, tt := range tests {
+		if got := isCalculateTotalRefusal(tt.err); got != tt.want {
+			t.Errorf("isCalculateTotalRefusal(%s) = %v, want %v", tt.err.Type, got, tt.want)
+		}
+	}
+}
+
+func TestParseHeaderBlock(t *testing.T) {
+	raw := "Received: from a\r\n\tby b\r\nSubject: Hello\r\nReceived: from c\r\nX-Empty:\r\n\r\nBody: not a header\r\n"
+	got := parseHeaderBlock(raw)
+	want := [][2]string{
+		{"Received", "from a by b"},
+		{"Subject", "Hello"},
+		{"Received", "from c"},
+		{"X-Empty", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d headers, want %d", len(got), len(want))
+	}
+	for i, h := range got {
+		if h.Name != want[i][0] || h.Value != want[i][1] {
+			t.Errorf("header %d = %s: %q, want %s: %q", i, h.Name, h.Value, want[i][0], want[i][1])
+		}
+	}
+}
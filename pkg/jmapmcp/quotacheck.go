@@ -0,0 +1,88 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/mikluko/jmap"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/quota"
+)
+
+// Quota pre-checks: a batch that runs out of storage halfway fails with an
+// overQuota SetError after part of it has already been written (or sent).
+// Operations that add a known amount of mail ask Quota/get (RFC 9425) first,
+// refuse what would cross a hard limit, and warn when a soft or warn limit
+// would be crossed. Servers without the quota capability are not checked,
+// and a failing Quota/get lets the operation proceed: the server still
+// enforces its quotas.
+
+// minQuotaCheckUpload is the upload size from which attachment_upload checks
+// quotas first; smaller uploads are not worth the extra round trip.
+const minQuotaCheckUpload = 1 << 20
+
+// mailGrowth is what an operation adds to an account's mail.
+type mailGrowth struct {
+	what   string // the operation, for messages: "upload of report.pdf"
+	octets uint64
+	emails uint64
+}
+
+// checkQuota refuses g when it would take a mail quota of accountID past its
+// hard limit, and adds a warning to the call's result when it would pass a
+// soft or warn limit.
+func checkQuota(ctx context.Context, client JMAPClient, accountID jmap.ID, g mailGrowth) error {
+	if _, ok := client.Session().RawCapabilities[quota.URI]; !ok {
+		return nil
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&quota.Get{Account: accountID})
+	resp, err := client.Do(req)
+	if err != nil || len(resp.Responses) == 0 {
+		return nil
+	}
+	args, ok := resp.Responses[0].Args.(*quota.GetResponse)
+	if !ok {
+		return nil
+	}
+
+	for _, q := range args.List {
+		if len(q.Types) > 0 && !slices.Contains(q.Types, "Mail") {
+			continue
+		}
+		var add uint64
+		unit := ""
+		switch q.ResourceType {
+		case "octets":
+			add, unit = g.octets, " bytes"
+		case "count":
+			add, unit = g.emails, " emails"
+		}
+		if add == 0 {
+			continue
+		}
+		name := q.Name
+		if name == "" {
+			name = string(q.ID)
+		}
+		after := q.Used + add
+		if q.HardLimit > 0 && after > q.HardLimit {
+			return &toolError{Code: codeOverQuota, Message: fmt.Sprintf(
+				"%s would use %d of the %d%s left in quota %s (%d of %d used); free space (e.g. empty Trash) or ask for a larger quota",
+				g.what, add, q.HardLimit-min(q.Used, q.HardLimit), unit, name, q.Used, q.HardLimit)}
+		}
+		for _, limit := range []struct {
+			kind  string
+			value *uint64
+		}{{"soft", q.SoftLimit}, {"warn", q.WarnLimit}} {
+			if limit.value != nil && q.Used <= *limit.value && after > *limit.value {
+				responseWarningsFromContext(ctx).note(fmt.Sprintf(
+					"Warning: %s takes quota %s past its %s limit of %d%s (%d used afterwards).",
+					g.what, name, limit.kind, *limit.value, unit, after))
+				break
+			}
+		}
+	}
+	return nil
+}
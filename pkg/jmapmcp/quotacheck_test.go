@@ -0,0 +1,108 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/quota"
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+// addQuotas advertises the quota capability with a 10 000-byte mail
+// storage quota (soft limit 8 000) of which used bytes are taken, a
+// 100-email count quota, and a calendar quota that never applies to mail.
+func addQuotas(fake *jmapfake.Server, used int) {
+	fake.AddCapability(string(quota.URI), nil)
+	fake.Add("Quota", map[string]any{
+		"id": "q1", "name": "Mail storage", "resourceType": "octets", "scope": "account",
+		"used": used, "hardLimit": 10000, "softLimit": 8000, "types": []any{"Mail"},
+	})
+	fake.Add("Quota", map[string]any{
+		"id": "q2", "name": "Messages", "resourceType": "count", "scope": "account",
+		"used": 10, "hardLimit": 100,
+	})
+	fake.Add("Quota", map[string]any{
+		"id": "q3", "name": "Calendar", "resourceType": "octets", "scope": "account",
+		"used": 0, "hardLimit": 1, "types": []any{"CalendarEvent"},
+	})
+}
+
+func TestCheckQuota(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addQuotas(fake, 7000)
+	w := &responseWarnings{}
+	ctx := context.WithValue(context.Background(), responseWarningsKey, w)
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkQuota(ctx, client, jmapfake.AccountID, mailGrowth{what: "upload", octets: 500}); err != nil || len(w.list()) != 0 {
+		t.Errorf("growth within limits: %v, warnings %q", err, w.list())
+	}
+	if err := checkQuota(ctx, client, jmapfake.AccountID, mailGrowth{what: "upload", octets: 2000}); err != nil {
+		t.Errorf("growth past the soft limit refused: %v", err)
+	}
+	if got := w.list(); len(got) != 1 || !strings.Contains(got[0], "past its soft limit of 8000 bytes (9000 used afterwards)") {
+		t.Errorf("warnings = %q", got)
+	}
+
+	err = checkQuota(ctx, client, jmapfake.AccountID, mailGrowth{what: "upload", octets: 4000})
+	te, _ := err.(*toolError)
+	if te == nil || te.Code != codeOverQuota || !strings.Contains(te.Message, "3000 bytes left in quota Mail storage") {
+		t.Errorf("growth past the hard limit: %v", err)
+	}
+	err = checkQuota(ctx, client, jmapfake.AccountID, mailGrowth{what: "import", emails: 91})
+	if te, _ := err.(*toolError); te == nil || !strings.Contains(te.Message, "quota Messages") {
+		t.Errorf("growth past the count limit: %v", err)
+	}
+}
+
+func TestCheckQuotaWithoutCapability(t *testing.T) {
+	s, fake := newFakeServer(t)
+	client, err := s.jmapClient(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkQuota(context.Background(), client, jmapfake.AccountID, mailGrowth{what: "upload", octets: 1 << 30}); err != nil {
+		t.Errorf("checked without the quota capability: %v", err)
+	}
+	for _, c := range fake.Calls() {
+		if c == "Quota/get" {
+			t.Error("Quota/get called without the quota capability")
+		}
+	}
+}
+
+func TestMailMergeRefusedOverQuota(t *testing.T) {
+	s, fake := newMergeServer(t)
+	addQuotas(fake, 9000)
+
+	res, _, _ := s.handleMailMerge(context.Background(), nil, mergeInput(true))
+	te, _ := res.StructuredContent.(*toolError)
+	if !res.IsError || te == nil || te.Code != codeOverQuota || !strings.Contains(te.Message, "mail_merge of 3 messages") {
+		t.Fatalf("merge not refused: %s", resultText(res))
+	}
+	if n := len(fake.Objects("Email")); n != 0 {
+		t.Errorf("refused merge created %d emails", n)
+	}
+}
+
+func TestAttachmentUploadRefusedOverQuota(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addQuotas(fake, 0)
+	fake.Add("Quota", map[string]any{"id": "q1", "name": "Mail storage", "resourceType": "octets", "used": 0, "hardLimit": minQuotaCheckUpload})
+
+	data := base64.StdEncoding.EncodeToString(make([]byte, minQuotaCheckUpload+1))
+	res, _, _ := s.handleAttachmentUpload(context.Background(), nil, AttachmentUploadInput{Name: "big.bin", Type: "application/octet-stream", ContentBase64: data})
+	if te, _ := res.StructuredContent.(*toolError); !res.IsError || te == nil || te.Code != codeOverQuota {
+		t.Errorf("upload not refused: %s", resultText(res))
+	}
+
+	res, _, _ = s.handleAttachmentUpload(context.Background(), nil, AttachmentUploadInput{Name: "small.txt", Type: "text/plain", ContentBase64: base64.StdEncoding.EncodeToString([]byte("hi"))})
+	if res.IsError {
+		t.Errorf("small upload refused: %s", resultText(res))
+	}
+}
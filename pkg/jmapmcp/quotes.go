@@ -0,0 +1,228 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Quoted text handling of extractBody. By default quoted replies are
+// removed; folding replaces each quoted section with a one-line marker that
+// tells the agent how much was left out and whose it was, so it can ask for
+// the full body.
+const (
+	quotesStrip   = ""        // remove quoted replies and signatures
+	quotesFold    = "fold"    // replace each quoted section with a marker
+	quotesInclude = "include" // keep the body whole
+)
+
+// attributionVerbRE matches the verb of a reply attribution line ("Alice
+// wrote:") in the languages mail clients commonly use.
+var attributionVerbRE = regexp.MustCompile(`(?i)\s*\b(wrote|writes|schrieb|a écrit|escribió|escreveu|scrisse|schreef|skrev|napisał|написал|написала)\b\s*`)
+
+// attributionDateRE matches the date and time leading an attribution line,
+// up to the last digit or AM/PM.
+var attributionDateRE = regexp.MustCompile(`^.*(\d|\b[AaPp]\.?[Mm]\.?)[,.]?\s+`)
+
+// forwardSeparatorRE matches the line Outlook and others put above the
+// message they quote in full.
+var forwardSeparatorRE = regexp.MustCompile(`(?i)^\s*(-{2,}\s*original message\s*-{2,}|_{20,})\s*$`)
+
+// maxAttributionBytes is the longest line taken for an attribution.
+const maxAttributionBytes = 200
+
+// quoteMarker is the line that replaces a quoted section of lines lines
+// written by name (empty when unknown).
+func quoteMarker(lines int, name string) string {
+	from := ""
+	if name != "" {
+		from = " from " + name
+	}
+	noun := "lines"
+	if lines == 1 {
+		noun = "line"
+	}
+	return fmt.Sprintf("[> %d quoted %s%s, expand with include_quotes]", lines, noun, from)
+}
+
+// isAttribution reports whether line introduces a quote: it is short, ends
+// with a colon, and names the verb or an address.
+func isAttribution(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) <= maxAttributionBytes && strings.HasSuffix(line, ":") && (attributionVerbRE.MatchString(line) || strings.Contains(line, "@"))
+}
+
+// attributionName returns the author an attribution line names, or empty.
+func attributionName(line string) string {
+	s := strings.TrimSuffix(strings.TrimSpace(line), ":")
+	addr := ""
+	if i := strings.LastIndex(s, "<"); i >= 0 {
+		addr = strings.Trim(s[i:], "<> ")
+		s = s[:i]
+	}
+	s = attributionVerbRE.ReplaceAllString(s, " ")
+	s = attributionDateRE.ReplaceAllString(s, "")
+	if rest, ok := strings.CutPrefix(s, "On "); ok {
+		// On Monday, Alice
+		s = rest[strings.LastIndex(rest, ",")+1:]
+	}
+	name := strings.Trim(strings.TrimSpace(s), `"',`)
+	if name == "" || strings.ContainsAny(name, "<>") {
+		name = addr
+	}
+	if len(name) > 80 {
+		return ""
+	}
+	return name
+}
+
+// senderName returns the name of an Outlook-style "From: Name <address>"
+// header line, or empty.
+func senderName(line string) string {
+	v, ok := strings.CutPrefix(strings.TrimSpace(line), "From:")
+	if !ok {
+		return ""
+	}
+	v = strings.TrimSpace(v)
+	if i := strings.IndexAny(v, "<["); i > 0 {
+		v = v[:i]
+	}
+	return strings.Trim(strings.TrimSpace(v), `"'`)
+}
+
+// FoldQuotes replaces each block of ">" quoted lines in a plain text body,
+// with the attribution line above it, and everything below an "Original
+// Message" separator with a one-line marker.
+func FoldQuotes(text string) string {
+	lines := strings.Split(text, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if forwardSeparatorRE.MatchString(line) {
+			name := ""
+			for _, l := range lines[i+1 : min(i+6, len(lines))] {
+				if name = senderName(l); name != "" {
+					break
+				}
+			}
+			out = append(out, quoteMarker(countLines(lines[i+1:]), name))
+			break
+		}
+		if !isQuoted(line) {
+			out = append(out, line)
+			continue
+		}
+		j := i
+		for j < len(lines) && (isQuoted(lines[j]) || strings.TrimSpace(lines[j]) == "" && j+1 < len(lines) && isQuoted(lines[j+1])) {
+			j++
+		}
+		// The attribution above the quote, possibly wrapped over two lines
+		// and followed by a blank one.
+		k := len(out)
+		for k > 0 && strings.TrimSpace(out[k-1]) == "" {
+			k--
+		}
+		name := ""
+		if k > 0 && isAttribution(out[k-1]) {
+			attribution := out[k-1]
+			k--
+			if k > 0 && strings.HasPrefix(strings.TrimSpace(out[k-1]), "On ") && !strings.HasSuffix(strings.TrimSpace(out[k-1]), ":") {
+				attribution = out[k-1] + " " + attribution
+				k--
+			}
+			name = attributionName(attribution)
+			out = out[:k]
+		}
+		out = append(out, quoteMarker(countLines(lines[i:j]), name))
+		i = j - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// isQuoted reports whether line is quoted with ">".
+func isQuoted(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " \t"), ">")
+}
+
+// countLines counts the lines of lines that are not blank.
+func countLines(lines []string) int {
+	n := 0
+	for _, l := range lines {
+		if strings.TrimSpace(strings.TrimLeft(l, "> \t")) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// FoldBlockquotes is StripBlockquotes that replaces each top-level
+// <blockquote>, with the attribution just above it, by a paragraph holding
+// a quote marker instead of removing it.
+func FoldBlockquotes(rawHTML string) string {
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		return rawHTML
+	}
+	foldBlockquotes(doc)
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return rawHTML
+	}
+	return buf.String()
+}
+
+// foldBlockquotes walks the node tree and folds blockquote elements in place.
+func foldBlockquotes(n *html.Node) {
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type != html.ElementNode || c.DataAtom != atom.Blockquote {
+			foldBlockquotes(c)
+			continue
+		}
+		var buf bytes.Buffer
+		lines := 0
+		if html.Render(&buf, c) == nil {
+			lines = countLines(strings.Split(htmlToText(buf.String()), "\n"))
+		}
+		name := ""
+		if prev := attributionNode(c); prev != nil {
+			var sb strings.Builder
+			cellText(prev, &sb)
+			name = attributionName(strings.Join(strings.Fields(sb.String()), " "))
+			prev.Parent.RemoveChild(prev)
+		}
+		p := &html.Node{Type: html.ElementNode, Data: "p", DataAtom: atom.P}
+		p.AppendChild(&html.Node{Type: html.TextNode, Data: quoteMarker(lines, name)})
+		n.InsertBefore(p, c)
+		n.RemoveChild(c)
+	}
+}
+
+// attributionNode returns the sibling before quote, skipping whitespace and
+// line breaks, when it is an attribution line such as Gmail's gmail_attr.
+func attributionNode(quote *html.Node) *html.Node {
+	for prev := quote.PrevSibling; prev != nil; prev = prev.PrevSibling {
+		switch {
+		case prev.Type == html.TextNode && strings.TrimSpace(prev.Data) == "":
+			continue
+		case prev.Type == html.ElementNode && prev.DataAtom == atom.Br:
+			continue
+		case prev.Type == html.TextNode:
+			if isAttribution(prev.Data) {
+				return prev
+			}
+		case prev.Type == html.ElementNode:
+			var sb strings.Builder
+			if cellText(prev, &sb) && isAttribution(strings.Join(strings.Fields(sb.String()), " ")) {
+				return prev
+			}
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package jmapmcp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFoldQuotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "gmail attribution",
+			input: "Sounds good.\n\nOn Mon, Jan 5, 2026 at 10:00 AM Alice Smith <alice@example.com> wrote:\n> Can we meet?\n>\n> Alice",
+			want:  "Sounds good.\n\n[> 2 quoted lines from Alice Smith, expand with include_quotes]",
+		},
+		{
+			name:  "wrapped attribution",
+			input: "Yes.\n\nOn Mon, Jan 5, 2026 at 10:00 AM Alice Smith <\nalice@example.com> wrote:\n\n> Ready?",
+			want:  "Yes.\n\n[> 1 quoted line from Alice Smith, expand with include_quotes]",
+		},
+		{
+			name:  "german attribution",
+			input: "Ja.\nAm 05.01.2026 um 10:00 schrieb Bob Meyer <bob@example.de>:\n> Passt das?",
+			want:  "Ja.\n[> 1 quoted line from Bob Meyer, expand with include_quotes]",
+		},
+		{
+			name:  "interleaved replies",
+			input: "> First question?\nAnswer one.\n> Second question?\n> More.\nAnswer two.",
+			want:  "[> 1 quoted line, expand with include_quotes]\nAnswer one.\n[> 2 quoted lines, expand with include_quotes]\nAnswer two.",
+		},
+		{
+			name:  "outlook original message",
+			input: "Approved.\n\n-----Original Message-----\nFrom: Carol Jones [mailto:carol@example.com]\nSent: Monday\nSubject: Budget\n\nPlease approve.",
+			want:  "Approved.\n\n[> 4 quoted lines from Carol Jones, expand with include_quotes]",
+		},
+		{
+			name:  "no quotes unchanged",
+			input: "Line one\nLine two: details",
+			want:  "Line one\nLine two: details",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FoldQuotes(tt.input); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractBodyQuotes(t *testing.T) {
+	e := htmlEmail(`<div>Sounds good.</div><div class="gmail_quote"><div class="gmail_attr">On Mon, Jan 5, 2026 at 10:00 AM Alice Smith &lt;alice@example.com&gt; wrote:<br></div><blockquote class="gmail_quote"><div>Can we meet on Tuesday?<br>Alice</div></blockquote></div>`)
+
+	if got := extractBody(e, 0, TruncateHead, quotesStrip); strings.Contains(got, "Tuesday") || strings.Contains(got, "quoted") {
+		t.Errorf("strip: %q", got)
+	}
+	got := extractBody(e, 0, TruncateHead, quotesFold)
+	if !strings.Contains(got, "Sounds good.") || !strings.Contains(got, "[> 2 quoted lines from Alice Smith, expand with include_quotes]") || strings.Contains(got, "wrote:") {
+		t.Errorf("fold: %q", got)
+	}
+	if got := extractBody(e, 0, TruncateHead, quotesInclude); !strings.Contains(got, "Can we meet on Tuesday?") {
+		t.Errorf("include: %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+package jmapmcp
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Each server that relays a message prepends a Received header naming the
+// host it got the message from, itself, the protocol, and when, so read
+// bottom up the headers are the delivery chain. email_trace lists the hops
+// in delivery order with the delay each added.
+
+// receivedHop is one parsed Received header.
+type receivedHop struct {
+	From string // sending host as it introduced itself
+	IP   string // sending host's address, from the receiver's comment
+	By   string // receiving host
+	With string // protocol, e.g. ESMTPS
+	Time time.Time
+	Raw  string // header value, when no clause could be parsed
+}
+
+// receivedIPRE matches the bracketed address receivers put in the comment
+// after the from clause.
+var receivedIPRE = regexp.MustCompile(`\[(?:IPv6:)?([0-9A-Fa-f.:]+)\]`)
+
+// parseReceived parses a Received header value (RFC 5321 section 4.4):
+// "from" host with a comment, "by" host, "with" protocol, and the date after
+// the last semicolon. Unknown clauses are skipped.
+func parseReceived(value string) receivedHop {
+	value = strings.Join(strings.Fields(value), " ")
+	h := receivedHop{}
+	clauses := value
+	if i := strings.LastIndex(value, ";"); i >= 0 {
+		clauses = value[:i]
+		if t, err := netmail.ParseDate(stripComments(value[i+1:])); err == nil {
+			h.Time = t
+		}
+	}
+	// Comments may hold clause words; read them only for the sender's IP.
+	var key string
+	depth := 0
+	var comment strings.Builder
+	for _, tok := range strings.Fields(clauses) {
+		if depth > 0 || strings.HasPrefix(tok, "(") {
+			if depth == 0 {
+				comment.Reset()
+			}
+			depth += strings.Count(tok, "(") - strings.Count(tok, ")")
+			comment.WriteString(tok + " ")
+			if key == "from" && h.IP == "" {
+				if m := receivedIPRE.FindStringSubmatch(comment.String()); m != nil {
+					h.IP = m[1]
+				}
+			}
+			continue
+		}
+		switch lower := strings.ToLower(tok); lower {
+		case "from", "by", "via", "with", "id", "for":
+			key = lower
+			continue
+		}
+		switch key {
+		case "from":
+			if h.From == "" {
+				h.From = tok
+			}
+		case "by":
+			if h.By == "" {
+				h.By = tok
+			}
+		case "with":
+			if h.With == "" {
+				h.With = tok
+			}
+		}
+	}
+	if h.From == "" && h.By == "" {
+		h.Raw = value
+	}
+	return h
+}
+
+// stripComments removes parenthesized comments from a header value.
+func stripComments(s string) string {
+	var sb strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// deliveryChain returns the hops of an email's Received headers in delivery
+// order, first relay first.
+func deliveryChain(headers []*email.Header) []receivedHop {
+	var hops []receivedHop
+	for i := len(headers) - 1; i >= 0; i-- {
+		if strings.EqualFold(headers[i].Name, "Received") {
+			hops = append(hops, parseReceived(headers[i].Value))
+		}
+	}
+	return hops
+}
+
+// formatHopDelay renders a delay between hops to the second, marking
+// negative ones, which mean the two hosts' clocks disagree.
+func formatHopDelay(d time.Duration) string {
+	if d < 0 {
+		return "-" + formatHopDelay(-d) + " (clock skew)"
+	}
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%02dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,48 @@
+package jmapmcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestParseReceived(t *testing.T) {
+	h := parseReceived("from mail.example.com (mail.example.com [203.0.113.5])\r\n\tby mx.example.org (Postfix, from userid 1000) with ESMTPS id 4F2A1\r\n\tfor <bob@example.org>; Tue, 6 Jan 2026 10:00:05 +0100 (CET)")
+	want := receivedHop{
+		From: "mail.example.com", IP: "203.0.113.5", By: "mx.example.org", With: "ESMTPS",
+		Time: time.Date(2026, 1, 6, 9, 0, 5, 0, time.UTC),
+	}
+	if h.From != want.From || h.IP != want.IP || h.By != want.By || h.With != want.With || !h.Time.Equal(want.Time) || h.Raw != "" {
+		t.Errorf("got %+v, want %+v", h, want)
+	}
+
+	if h := parseReceived("(qmail 1234 invoked by uid 89); 6 Jan 2026 09:00:00 -0000"); h.Raw == "" || h.Time.IsZero() {
+		t.Errorf("comment-only header: %+v", h)
+	}
+}
+
+func TestDeliveryChain(t *testing.T) {
+	hops := deliveryChain([]*email.Header{
+		{Name: "Received", Value: "by mx2.example.org with LMTP; Tue, 6 Jan 2026 09:05:00 +0000"},
+		{Name: "Subject", Value: "Hi"},
+		{Name: "received", Value: "from laptop by mx1.example.org with ESMTPSA; Tue, 6 Jan 2026 09:00:00 +0000"},
+	})
+	if len(hops) != 2 || hops[0].By != "mx1.example.org" || hops[1].By != "mx2.example.org" {
+		t.Errorf("hops = %+v", hops)
+	}
+}
+
+func TestFormatHopDelay(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		4 * time.Second:                "4s",
+		3*time.Minute + 12*time.Second: "3m12s",
+		2*time.Hour + 5*time.Minute:    "2h05m",
+		50 * time.Hour:                 "2d02h",
+		-7 * time.Second:               "-7s (clock skew)",
+	} {
+		if got := formatHopDelay(d); got != want {
+			t.Errorf("formatHopDelay(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
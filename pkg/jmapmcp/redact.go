@@ -0,0 +1,119 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactRule masks every match of re (that passes valid, when set) with a
+// "[REDACTED:label]" marker, so the reader still sees where a secret was.
+type redactRule struct {
+	label string
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+// builtinRedactions are the named rule sets selectable with -redact.
+var builtinRedactions = map[string]redactRule{
+	"api_keys": {
+		label: "api_key",
+		re: regexp.MustCompile(`\b(?:` +
+			`AKIA[0-9A-Z]{16}` + // AWS access key ID
+			`|gh[pousr]_[A-Za-z0-9]{36,}` + // GitHub token
+			`|github_pat_[A-Za-z0-9_]{40,}` +
+			`|xox[abprs]-[A-Za-z0-9-]{10,}` + // Slack token
+			`|[sr]k_(?:live|test)_[A-Za-z0-9]{16,}` + // Stripe key
+			`|sk-[A-Za-z0-9_-]{20,}` + // OpenAI/Anthropic-style key
+			`|AIza[0-9A-Za-z_-]{35}` + // Google API key
+			`|eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}` + // JWT
+			`)`),
+	},
+	"credit_cards": {
+		label: "credit_card",
+		re:    regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid: luhnValid,
+	},
+	"private_keys": {
+		label: "private_key",
+		re:    regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	},
+}
+
+// Redactor masks configured secret patterns in email content before it is
+// returned to the model. A nil *Redactor leaves text unchanged.
+type Redactor struct {
+	rules []redactRule
+}
+
+// NewRedactor builds a Redactor from builtin rule set names (see
+// RedactionNames) and custom regular expressions, which are masked as
+// "[REDACTED:custom]".
+func NewRedactor(builtins, patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, name := range builtins {
+		rule, ok := builtinRedactions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction %q (available: %s)", name, strings.Join(RedactionNames(), ", "))
+		}
+		r.rules = append(r.rules, rule)
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", p, err)
+		}
+		r.rules = append(r.rules, redactRule{label: "custom", re: re})
+	}
+	return r, nil
+}
+
+// RedactionNames lists the builtin redaction rule sets.
+func RedactionNames() []string {
+	return sortedKeys(builtinRedactions)
+}
+
+// redact masks all matches in text and returns the result with the number
+// of masked spans.
+func (r *Redactor) redact(text string) (string, int) {
+	if r == nil {
+		return text, 0
+	}
+	count := 0
+	for _, rule := range r.rules {
+		text = rule.re.ReplaceAllStringFunc(text, func(m string) string {
+			if rule.valid != nil && !rule.valid(m) {
+				return m
+			}
+			count++
+			return "[REDACTED:" + rule.label + "]"
+		})
+	}
+	return text, count
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum, which
+// filters out most phone numbers and IDs matched by the card pattern.
+func luhnValid(s string) bool {
+	var digits []int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,388 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/mikluko/jmap"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+// Record and replay (-record-jmap, -replay-jmap). Recording saves the
+// session resource, every JMAP method call with the responses answering
+// it, and the blobs downloaded and uploaded to a fixture file; replaying
+// serves calls from that file instead of a mail server, so an agent flow
+// recorded once against a real mailbox runs again offline, and the same
+// way, in end-to-end tests. Calls are matched by method and normalized
+// arguments: keys sorted and dates and times masked, since tools compute
+// filters and timestamps from the clock. A call recorded several times is
+// answered in recorded order, the last answer repeating; a call with no
+// recording gets a serverFail method error naming it. Push and WebSocket
+// are not recorded: a recorded session advertises neither, so traffic
+// takes HTTP while recording, and watches fall back to polling.
+
+// jmapFixture is the content of a fixture file.
+type jmapFixture struct {
+	Session   json.RawMessage            `json:"session"`
+	Calls     []fixtureCall              `json:"calls"`
+	Downloads map[string][]byte          `json:"downloads,omitempty"` // URL path → content
+	Uploads   map[string]json.RawMessage `json:"uploads,omitempty"`   // URL path and content SHA-256 → upload response
+}
+
+// fixtureCall is one recorded method call.
+type fixtureCall struct {
+	Method    string            `json:"method"`
+	Args      json.RawMessage   `json:"args"`      // normalized
+	Responses []json.RawMessage `json:"responses"` // [name, arguments] of each response to the call
+}
+
+// fixtureTimeRe matches the RFC 3339 date-times normalization masks.
+var fixtureTimeRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+
+// normalizeArgs returns args as matched against a fixture: keys sorted
+// and date-times masked.
+func normalizeArgs(args json.RawMessage) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var mask func(any) any
+	mask = func(v any) any {
+		switch v := v.(type) {
+		case string:
+			if fixtureTimeRe.MatchString(v) {
+				return "<time>"
+			}
+		case map[string]any:
+			for k, e := range v {
+				v[k] = mask(e)
+			}
+		case []any:
+			for i, e := range v {
+				v[i] = mask(e)
+			}
+		}
+		return v
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(mask(v)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// uploadKey is the fixture key of an upload of content to path.
+func uploadKey(path string, content []byte) string {
+	sum := sha256.Sum256(content)
+	return path + " " + hex.EncodeToString(sum[:])
+}
+
+// apiRequest and apiResponse are JMAP API bodies with their invocations
+// left as JSON triples.
+type apiRequest struct {
+	Calls [][]json.RawMessage `json:"methodCalls"`
+}
+
+type apiResponse struct {
+	Responses    [][]json.RawMessage `json:"methodResponses"`
+	SessionState string              `json:"sessionState"`
+}
+
+// --- recording ---
+
+// recordingDialer dials with base and records the traffic of every client
+// to path.
+type recordingDialer struct {
+	base Dialer
+	path string
+
+	mu      sync.Mutex
+	fixture jmapFixture
+}
+
+// WithJMAPRecording records the JMAP traffic of the server's clients to a
+// fixture file at path, replayable with NewReplayDialer. It wraps the
+// dialer in effect once all options are applied.
+func WithJMAPRecording(path string) Option {
+	return func(s *Server) { s.recordPath = path }
+}
+
+func (d *recordingDialer) Dial(ctx context.Context, sessionURL, token string) (*jmap.Client, error) {
+	c, err := d.base.Dial(ctx, sessionURL, token)
+	if err != nil {
+		return nil, err
+	}
+	if c.Session != nil {
+		// Keep the traffic on HTTP, where it is recorded.
+		delete(c.Session.RawCapabilities, websocketURI)
+		c.Session.EventSourceURL = ""
+		d.mu.Lock()
+		if d.fixture.Session == nil {
+			d.fixture.Session, err = json.Marshal(c.Session)
+		}
+		d.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	hc := http.Client{}
+	if c.HttpClient != nil {
+		hc = *c.HttpClient
+	}
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = recordTransport{base: base, dialer: d}
+	c.HttpClient = &hc
+	return c, nil
+}
+
+// recordTransport records the exchanges of a client.
+type recordTransport struct {
+	base   http.RoundTripper
+	dialer *recordingDialer
+}
+
+func (t recordTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err != nil || resp.StatusCode/100 != 2 {
+		return resp, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return resp, nil
+	}
+	if err := t.dialer.record(r, body, data); err != nil {
+		return nil, fmt.Errorf("recording JMAP traffic: %w", err)
+	}
+	return resp, nil
+}
+
+// record adds an exchange to the fixture and saves it.
+func (d *recordingDialer) record(r *http.Request, reqBody, respBody []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var req apiRequest
+	switch {
+	case r.Method == http.MethodGet:
+		if d.fixture.Downloads == nil {
+			d.fixture.Downloads = make(map[string][]byte)
+		}
+		d.fixture.Downloads[r.URL.Path] = respBody
+	case json.Unmarshal(reqBody, &req) == nil && req.Calls != nil:
+		var resp apiResponse
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return err
+		}
+		for _, call := range req.Calls {
+			if len(call) != 3 {
+				return fmt.Errorf("malformed method call")
+			}
+			var method, callID string
+			_ = json.Unmarshal(call[0], &method)
+			_ = json.Unmarshal(call[2], &callID)
+			args, err := normalizeArgs(call[1])
+			if err != nil {
+				return err
+			}
+			fc := fixtureCall{Method: method, Args: args}
+			for _, inv := range resp.Responses {
+				var id string
+				if len(inv) == 3 && json.Unmarshal(inv[2], &id) == nil && id == callID {
+					pair, _ := json.Marshal(inv[:2])
+					fc.Responses = append(fc.Responses, pair)
+				}
+			}
+			d.fixture.Calls = append(d.fixture.Calls, fc)
+		}
+	default:
+		if d.fixture.Uploads == nil {
+			d.fixture.Uploads = make(map[string]json.RawMessage)
+		}
+		d.fixture.Uploads[uploadKey(r.URL.Path, reqBody)] = respBody
+	}
+	_, err := store.WriteJSON(store.Dir(""), d.path, d.fixture)
+	return err
+}
+
+// --- replay ---
+
+// ReplayDialer serves JMAP clients from a recorded fixture.
+type ReplayDialer struct {
+	fixture jmapFixture
+
+	mu   sync.Mutex
+	used []bool
+}
+
+// NewReplayDialer loads the fixture at path, recorded with
+// WithJMAPRecording.
+func NewReplayDialer(path string) (*ReplayDialer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	d := &ReplayDialer{}
+	if err := json.Unmarshal(data, &d.fixture); err != nil {
+		return nil, fmt.Errorf("replay %s: %w", path, err)
+	}
+	if d.fixture.Session == nil {
+		return nil, fmt.Errorf("replay %s: no session recorded", path)
+	}
+	// The file is indented, and may have been edited by hand.
+	for i := range d.fixture.Calls {
+		args, err := normalizeArgs(d.fixture.Calls[i].Args)
+		if err != nil {
+			return nil, fmt.Errorf("replay %s: call %d: %w", path, i, err)
+		}
+		d.fixture.Calls[i].Args = args
+	}
+	d.used = make([]bool, len(d.fixture.Calls))
+	return d, nil
+}
+
+// Dial returns a client with the recorded session whose requests are
+// answered from the fixture. The session URL and token are ignored.
+func (d *ReplayDialer) Dial(_ context.Context, sessionURL, _ string) (*jmap.Client, error) {
+	session := &jmap.Session{}
+	if err := json.Unmarshal(d.fixture.Session, session); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	return &jmap.Client{
+		SessionEndpoint: sessionURL,
+		Session:         session,
+		HttpClient:      &http.Client{Transport: replayTransport{d}},
+	}, nil
+}
+
+// replayTransport answers a client's requests from its dialer's fixture.
+type replayTransport struct{ d *ReplayDialer }
+
+func (t replayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body.Close()
+	}
+	var req apiRequest
+	switch {
+	case r.Method == http.MethodGet:
+		data, ok := t.d.fixture.Downloads[r.URL.Path]
+		if !ok {
+			return replayResponse(http.StatusNotFound, "text/plain", []byte("replay: no recorded download of "+r.URL.Path)), nil
+		}
+		return replayResponse(http.StatusOK, "application/octet-stream", data), nil
+	case json.Unmarshal(body, &req) == nil && req.Calls != nil:
+		resp, err := t.d.answer(req)
+		if err != nil {
+			return nil, err
+		}
+		return replayResponse(http.StatusOK, "application/json", resp), nil
+	default:
+		data, ok := t.d.fixture.Uploads[uploadKey(r.URL.Path, body)]
+		if !ok {
+			return replayResponse(http.StatusNotFound, "text/plain", []byte("replay: no recorded upload of this content to "+r.URL.Path)), nil
+		}
+		return replayResponse(http.StatusOK, "application/json", data), nil
+	}
+}
+
+// answer builds the response to req from the recorded calls.
+func (d *ReplayDialer) answer(req apiRequest) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	resp := apiResponse{Responses: [][]json.RawMessage{}}
+	var session struct {
+		State string `json:"state"`
+	}
+	_ = json.Unmarshal(d.fixture.Session, &session)
+	resp.SessionState = session.State
+	for _, call := range req.Calls {
+		if len(call) != 3 {
+			return nil, fmt.Errorf("replay: malformed method call")
+		}
+		var method string
+		_ = json.Unmarshal(call[0], &method)
+		args, err := normalizeArgs(call[1])
+		if err != nil {
+			return nil, fmt.Errorf("replay: %w", err)
+		}
+		fc := d.match(method, args)
+		if fc == nil {
+			desc, _ := json.Marshal(map[string]string{
+				"type":        "serverFail",
+				"description": fmt.Sprintf("replay: no recorded %s call with arguments %s", method, args),
+			})
+			resp.Responses = append(resp.Responses, []json.RawMessage{json.RawMessage(`"error"`), desc, call[2]})
+			continue
+		}
+		for _, pair := range fc.Responses {
+			var inv []json.RawMessage
+			if err := json.Unmarshal(pair, &inv); err != nil || len(inv) != 2 {
+				return nil, fmt.Errorf("replay: malformed recorded response to %s", method)
+			}
+			resp.Responses = append(resp.Responses, append(inv, call[2]))
+		}
+	}
+	return json.Marshal(resp)
+}
+
+// match returns the first unused recorded call of method with args, marking
+// it used, or else the last used one; nil when none was recorded.
+func (d *ReplayDialer) match(method string, args json.RawMessage) *fixtureCall {
+	last := -1
+	for i := range d.fixture.Calls {
+		fc := &d.fixture.Calls[i]
+		if fc.Method != method || !bytes.Equal(fc.Args, args) {
+			continue
+		}
+		if !d.used[i] {
+			d.used[i] = true
+			return fc
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil
+	}
+	return &d.fixture.Calls[last]
+}
+
+// replayResponse is an HTTP response with body.
+func replayResponse(status int, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
@@ -0,0 +1,61 @@
+package jmapmcp
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	fake := jmapfake.New()
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	addEmail(fake, "e1", "Invoice January", "Please pay.", 1)
+	addEmail(fake, "e2", "Lunch", "Noon?", 2)
+
+	// flow runs an agent's calls and returns what each printed.
+	flow := func(s *Server) []string {
+		ctx := context.Background()
+		seen := true
+		query, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{After: "2025-01-01"})
+		flag, _, _ := s.handleEmailFlag(ctx, nil, EmailFlagInput{EmailIDs: []string{"e1"}, Seen: &seen})
+		get, _, _ := s.handleEmailGet(ctx, nil, EmailGetInput{EmailIDs: []string{"e1"}})
+		return []string{resultText(query), resultText(flag), resultText(get)}
+	}
+
+	recorded := flow(NewServer("test", jmapfake.SessionURL, WithToken("test"), WithDialer(fake), WithJMAPRecording(path)))
+
+	replay, err := NewReplayDialer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer("test", "replay:"+path, WithToken("other"), WithDialer(replay))
+	replayed := flow(s)
+	for i := range recorded {
+		if recorded[i] != replayed[i] {
+			t.Errorf("call %d replayed as\n%s\nwant\n%s", i, replayed[i], recorded[i])
+		}
+	}
+	if !strings.Contains(replayed[2], "Please pay.") {
+		t.Errorf("email_get replayed as %q", replayed[2])
+	}
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e2"}})
+	if !res.IsError || !strings.Contains(resultText(res), "replay: no recorded Email/get call") {
+		t.Errorf("unrecorded call: %s", resultText(res))
+	}
+}
+
+func TestNormalizeArgs(t *testing.T) {
+	a, err := normalizeArgs([]byte(`{"filter": {"after": "2025-01-01T10:00:00Z", "text": "x"}, "accountId": "A"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := normalizeArgs([]byte(`{"accountId":"A","filter":{"text":"x","after":"2026-10-16T08:30:00+02:00"}}`))
+	if string(a) != string(b) || string(a) != `{"accountId":"A","filter":{"after":"<time>","text":"x"}}` {
+		t.Errorf("normalized %s and %s", a, b)
+	}
+}
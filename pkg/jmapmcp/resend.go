@@ -0,0 +1,80 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Double-send protection: an agent that retries a submission it believes
+// failed, or loses track of what it already sent, would deliver the same
+// message twice. Submissions refuse an email that is no longer a draft
+// (a successful submission clears $draft) or that the same credential
+// submitted within the resend window, unless the call sets resend.
+
+// DefaultResendWindow is how long a submitted email is refused a second
+// submission.
+const DefaultResendWindow = 10 * time.Minute
+
+// recentSubmissions records when each owner last submitted each email.
+type recentSubmissions struct {
+	mu sync.Mutex
+	m  map[string]time.Time // owner + "/" + email ID → submission time
+}
+
+// reserve records a submission of emailID by owner at now, refusing it
+// unless resend when the email was submitted less than window ago. A window
+// of 0 records nothing and refuses nothing.
+func (r *recentSubmissions) reserve(owner string, emailID jmap.ID, window time.Duration, now time.Time, resend bool) error {
+	if window <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m == nil {
+		r.m = make(map[string]time.Time)
+	}
+	for k, t := range r.m {
+		if now.Sub(t) >= window {
+			delete(r.m, k)
+		}
+	}
+	key := owner + "/" + string(emailID)
+	if t, ok := r.m[key]; ok && !resend {
+		return resendError(emailID, fmt.Sprintf("it was submitted %s ago", now.Sub(t).Round(time.Second)))
+	}
+	r.m[key] = now
+	return nil
+}
+
+// release forgets a reservation whose submission failed.
+func (r *recentSubmissions) release(owner string, emailID jmap.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.m, owner+"/"+string(emailID))
+}
+
+// submittedWithin reports whether owner submitted emailID less than window
+// before now.
+func (r *recentSubmissions) submittedWithin(owner string, emailID jmap.ID, window time.Duration, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.m[owner+"/"+string(emailID)]
+	return ok && now.Sub(t) < window
+}
+
+// checkStillDraft refuses to submit e once it has lost its $draft keyword.
+func checkStillDraft(e *email.Email) error {
+	if e.Keywords["$draft"] {
+		return nil
+	}
+	return resendError(e.ID, "it is no longer a draft (no $draft keyword), so it was most likely sent already")
+}
+
+func resendError(emailID jmap.ID, why string) error {
+	return &policyError{Policy: "send", Rule: "resend",
+		Detail: fmt.Sprintf("email %s not submitted: %s; check the Sent mailbox, and pass resend=true only if it must go out again", emailID, why)}
+}
@@ -0,0 +1,76 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecentSubmissionsWindow(t *testing.T) {
+	var r recentSubmissions
+	now := time.Now()
+	if err := r.reserve("alice", "e1", time.Minute, now, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reserve("alice", "e1", time.Minute, now.Add(30*time.Second), false); err == nil || !strings.Contains(err.Error(), "submitted 30s ago") {
+		t.Errorf("second submission within the window: %v", err)
+	}
+	if err := r.reserve("bob", "e1", time.Minute, now, false); err != nil {
+		t.Errorf("other owner refused: %v", err)
+	}
+	if err := r.reserve("alice", "e1", time.Minute, now.Add(30*time.Second), true); err != nil {
+		t.Errorf("resend refused: %v", err)
+	}
+	if err := r.reserve("alice", "e1", time.Minute, now.Add(2*time.Minute), false); err != nil {
+		t.Errorf("refused after the window: %v", err)
+	}
+
+	r.release("alice", "e1")
+	if r.submittedWithin("alice", "e1", time.Minute, now.Add(2*time.Minute)) {
+		t.Error("released submission still recorded")
+	}
+	if err := r.reserve("alice", "e2", 0, now, false); err != nil || r.submittedWithin("alice", "e2", time.Minute, now) {
+		t.Errorf("window 0 recorded or refused: %v", err)
+	}
+}
+
+func TestSubmissionRefusesSecondSend(t *testing.T) {
+	s, fake := newFakeServer(t, WithEmailSubmission())
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "name": "Me", "email": "me@example.com"})
+	fake.Add("Email", map[string]any{
+		"id": "d1", "subject": "Hello", "to": []any{map[string]any{"email": "bob@example.com"}},
+		"mailboxIds": map[string]any{"drafts": true}, "keywords": map[string]any{"$draft": true},
+	})
+	ctx := context.Background()
+	submissions := func() int {
+		n := 0
+		for _, c := range fake.Calls() {
+			if c == "EmailSubmission/set" {
+				n++
+			}
+		}
+		return n
+	}
+
+	if res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1"}); res.IsError {
+		t.Fatalf("first submission: %s", resultText(res))
+	}
+	res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1"})
+	te, _ := res.StructuredContent.(*toolError)
+	if !res.IsError || te == nil || te.Rule != "resend" || !strings.Contains(resultText(res), "resend=true") {
+		t.Errorf("second submission not refused: %s", resultText(res))
+	}
+	if n := submissions(); n != 1 {
+		t.Errorf("EmailSubmission/set called %d times, want 1", n)
+	}
+
+	if res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1", Resend: true}); res.IsError {
+		t.Errorf("resend refused: %s", resultText(res))
+	}
+	if n := submissions(); n != 2 {
+		t.Errorf("EmailSubmission/set called %d times, want 2", n)
+	}
+}
@@ -0,0 +1,298 @@
+package jmapmcp
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- jmap://outbox ---
+
+const (
+	outboxRecentWindow = 24 * time.Hour // how far back sent submissions are listed
+	outboxRecentLimit  = 20             // at most this many sent submissions
+	outboxQueryLimit   = 50             // submissions fetched, newest first
+)
+
+var outboxResource = &mcp.Resource{
+	Name:        "outbox",
+	Title:       "Outbox",
+	URI:         "jmap://outbox",
+	Description: "What is being sent: drafts awaiting approval in the local queue, submissions the server holds for a later send time, and submissions sent in the last 24 hours with each recipient's delivery status. Submissions are refetched only when EmailSubmission/changes reports a change.",
+	MIMEType:    "text/markdown",
+}
+
+// outboxSubmissions is the recent EmailSubmission objects, the emails they
+// sent, and the EmailSubmission state they reflect.
+type outboxSubmissions struct {
+	state  string
+	subs   []*emailsubmission.EmailSubmission
+	emails map[jmap.ID]*email.Email
+}
+
+func (s *Server) readOutbox(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	if uri != outboxResource.URI {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var queued []*outboxEntry
+	if s.outbox != nil {
+		queued = s.outbox.list(s.ownerOf(token))
+	}
+	var subs *outboxSubmissions
+	if _, ok := client.Session().Capabilities[emailsubmission.URI]; ok {
+		if subs, err = s.outboxSubmissions(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{
+		URI:      uri,
+		MIMEType: "text/markdown",
+		Text:     s.formatOutbox(queued, subs, time.Now()),
+	}}}, nil
+}
+
+// outboxSubmissions returns the account's recent submissions, from the
+// cache unless EmailSubmission/changes reports them changed.
+func (s *Server) outboxSubmissions(ctx context.Context, client JMAPClient) (*outboxSubmissions, error) {
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return nil, fmt.Errorf("no primary mail account")
+	}
+
+	owner := s.idOwner(ctx)
+	key := "outbox\x00" + client.Session().APIURL + "\x00" + string(accountID)
+	if v, ok := s.tenants.get(owner, key); ok {
+		cached := v.(*outboxSubmissions)
+		// Any failure to tell whether submissions changed falls through to
+		// a refetch.
+		if state, changed, err := submissionsChangedSince(ctx, client, accountID, cached.state); err == nil && !changed {
+			cached.state = state
+			return cached, nil
+		}
+	}
+	subs, err := fetchOutboxSubmissions(ctx, client, accountID)
+	if err != nil {
+		return nil, err
+	}
+	s.tenants.put(owner, key, subs, int64(256*len(subs.subs)+len(subs.state)))
+	return subs, nil
+}
+
+// submissionsChangedSince reports whether EmailSubmission/changes lists any
+// change since state, and returns the new state.
+func submissionsChangedSince(ctx context.Context, client JMAPClient, accountID jmap.ID, state string) (string, bool, error) {
+	for {
+		req := &jmap.Request{Context: ctx}
+		req.Invoke(&emailsubmission.Changes{Account: accountID, SinceState: state})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", false, err
+		}
+		if len(resp.Responses) == 0 {
+			return "", false, fmt.Errorf("empty response for EmailSubmission/changes")
+		}
+
+		switch args := resp.Responses[0].Args.(type) {
+		case *emailsubmission.ChangesResponse:
+			if len(args.Created) > 0 || len(args.Updated) > 0 || len(args.Destroyed) > 0 {
+				return args.NewState, true, nil
+			}
+			if !args.HasMoreChanges || args.NewState == state {
+				return args.NewState, false, nil
+			}
+			state = args.NewState
+		case *jmap.MethodError:
+			return "", false, args
+		default:
+			return "", false, fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+}
+
+// fetchOutboxSubmissions fetches the newest submissions and the subjects and
+// recipients of their emails in one request.
+func fetchOutboxSubmissions(ctx context.Context, client JMAPClient, accountID jmap.ID) (*outboxSubmissions, error) {
+	req := &jmap.Request{Context: ctx}
+	queryID := req.Invoke(&emailsubmission.Query{
+		Account: accountID,
+		Sort:    []*emailsubmission.SortComparator{{Property: "sentAt", IsAscending: false}},
+		Limit:   outboxQueryLimit,
+	})
+	getID := req.Invoke(&emailsubmission.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryID, Name: "EmailSubmission/query", Path: "/ids"},
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: getID, Name: "EmailSubmission/get", Path: "/list/*/emailId"},
+		Properties:   []string{"id", "subject", "to", "cc", "bcc"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 3 {
+		return nil, fmt.Errorf("expected 3 responses, got %d", len(resp.Responses))
+	}
+
+	out := &outboxSubmissions{emails: make(map[jmap.ID]*email.Email)}
+	switch args := resp.Responses[1].Args.(type) {
+	case *emailsubmission.GetResponse:
+		out.state = args.State
+		out.subs = args.List
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected submission response type: %T", args)
+	}
+	// The emails only add subjects; submissions whose email is gone are
+	// still listed.
+	if args, ok := resp.Responses[2].Args.(*email.GetResponse); ok {
+		for _, e := range args.List {
+			out.emails[e.ID] = e
+		}
+	}
+	return out, nil
+}
+
+// formatOutbox renders the outbox resource at now.
+func (s *Server) formatOutbox(queued []*outboxEntry, subs *outboxSubmissions, now time.Time) string {
+	var sb strings.Builder
+	sb.WriteString("# Outbox\n")
+
+	if s.outbox != nil {
+		fmt.Fprintf(&sb, "\n## Awaiting approval (%d)\n\n", len(queued))
+		for _, e := range queued {
+			fmt.Fprintf(&sb, "- [%s] %s to %s, queued %s", e.ID, outboxSubject(e.Subject), e.To, s.locale.dateTime(e.QueuedAt))
+			if e.Endpoint != "" {
+				fmt.Fprintf(&sb, " on %s", e.Endpoint)
+			}
+			sb.WriteString("\n")
+		}
+		if len(queued) > 0 {
+			sb.WriteString("\nA human approves these with outbox_approve.\n")
+		}
+	}
+
+	if subs == nil {
+		sb.WriteString("\nThe server does not support email submission, so nothing is sent from here.\n")
+		return sb.String()
+	}
+
+	var scheduled, sent []*emailsubmission.EmailSubmission
+	for _, sub := range subs.subs {
+		switch {
+		case sub.UndoStatus == "pending" && sub.SendAt != nil && sub.SendAt.After(now):
+			scheduled = append(scheduled, sub)
+		case sub.SendAt != nil && now.Sub(*sub.SendAt) <= outboxRecentWindow:
+			sent = append(sent, sub)
+		}
+	}
+	slices.SortStableFunc(scheduled, func(a, b *emailsubmission.EmailSubmission) int { return a.SendAt.Compare(*b.SendAt) })
+	slices.SortStableFunc(sent, func(a, b *emailsubmission.EmailSubmission) int {
+		return cmp.Or(b.SendAt.Compare(*a.SendAt), cmp.Compare(a.ID, b.ID))
+	})
+	sent = sent[:min(len(sent), outboxRecentLimit)]
+
+	fmt.Fprintf(&sb, "\n## Scheduled (%d)\n\n", len(scheduled))
+	for _, sub := range scheduled {
+		fmt.Fprintf(&sb, "- [submission: %s] %s to %s, sends %s\n", sub.ID, submissionSubject(subs, sub), submissionRecipients(subs, sub), s.locale.dateTime(*sub.SendAt))
+	}
+
+	fmt.Fprintf(&sb, "\n## Sent in the last 24 hours (%d)\n\n", len(sent))
+	for _, sub := range sent {
+		fmt.Fprintf(&sb, "- [submission: %s] %s, %s %s\n", sub.ID, submissionSubject(subs, sub), submissionVerb(sub), s.locale.dateTime(*sub.SendAt))
+		for _, rcpt := range sortedKeys(sub.DeliveryStatus) {
+			fmt.Fprintf(&sb, "  - %s: %s\n", displayAddress(rcpt), deliveryText(sub.DeliveryStatus[rcpt]))
+		}
+	}
+	return sb.String()
+}
+
+// submissionSubject returns the quoted subject of the email sub sent.
+func submissionSubject(subs *outboxSubmissions, sub *emailsubmission.EmailSubmission) string {
+	if e := subs.emails[sub.EmailID]; e != nil {
+		return outboxSubject(e.Subject)
+	}
+	return fmt.Sprintf("email %s", sub.EmailID)
+}
+
+// outboxSubject quotes subject, or says it is empty.
+func outboxSubject(subject string) string {
+	if subject == "" {
+		return "(no subject)"
+	}
+	return fmt.Sprintf("%q", subject)
+}
+
+// submissionRecipients lists who sub goes to: the email's recipients, or
+// else the envelope's.
+func submissionRecipients(subs *outboxSubmissions, sub *emailsubmission.EmailSubmission) string {
+	if e := subs.emails[sub.EmailID]; e != nil {
+		if rcpts := draftRecipients(e); len(rcpts) > 0 {
+			return formatAddresses(rcpts)
+		}
+	}
+	var rcpts []string
+	if sub.Envelope != nil {
+		for _, a := range sub.Envelope.RcptTo {
+			rcpts = append(rcpts, displayAddress(a.Email))
+		}
+	}
+	if len(rcpts) == 0 {
+		return "(recipients unknown)"
+	}
+	return strings.Join(rcpts, ", ")
+}
+
+// submissionVerb says what became of sub.
+func submissionVerb(sub *emailsubmission.EmailSubmission) string {
+	if sub.UndoStatus == "canceled" {
+		return "canceled, was due"
+	}
+	return "sent"
+}
+
+// deliveryText describes one recipient's delivery status.
+func deliveryText(ds *emailsubmission.DeliveryStatus) string {
+	var text string
+	switch ds.Delivered {
+	case "yes":
+		text = "delivered"
+	case "no":
+		text = "failed"
+	case "queued":
+		text = "queued"
+	default:
+		text = "sent, delivery unknown"
+	}
+	if ds.Displayed == "yes" {
+		text += ", read"
+	}
+	if reply := strings.TrimSpace(ds.SMTPReply); reply != "" && ds.Delivered == "no" {
+		text += " (" + reply + ")"
+	}
+	return text
+}
@@ -0,0 +1,62 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func readOutbox(t *testing.T, s *Server) string {
+	t.Helper()
+	res, err := s.readOutbox(context.Background(), &mcp.ReadResourceRequest{Params: &mcp.ReadResourceParams{URI: "jmap://outbox"}})
+	if err != nil {
+		t.Fatalf("read jmap://outbox: %v", err)
+	}
+	return res.Contents[0].Text
+}
+
+func TestOutboxResource(t *testing.T) {
+	s, fake := newFakeServer(t, WithSendQueue())
+	now := time.Now().UTC()
+	s.outbox.add(&outboxEntry{Owner: ownerKey("test"), EmailID: "d1", Subject: "Budget", To: "cfo@example.org", QueuedAt: now})
+	s.outbox.add(&outboxEntry{Owner: ownerKey("other"), EmailID: "d9", Subject: "Secret", To: "x@example.org", QueuedAt: now})
+	fake.Add("Email", map[string]any{"id": "e1", "subject": "Lunch", "to": []any{map[string]any{"email": "bob@example.org"}}})
+	fake.Add("Email", map[string]any{"id": "e2", "subject": "Report", "to": []any{map[string]any{"email": "carol@example.org"}}})
+	fake.Add("EmailSubmission", map[string]any{"id": "S1", "emailId": "e1", "undoStatus": "pending", "sendAt": now.Add(2 * time.Hour).Format(time.RFC3339)})
+	fake.Add("EmailSubmission", map[string]any{"id": "S2", "emailId": "e2", "undoStatus": "final", "sendAt": now.Add(-time.Hour).Format(time.RFC3339),
+		"deliveryStatus": map[string]any{
+			"carol@example.org": map[string]any{"delivered": "yes", "displayed": "yes"},
+			"dave@example.org":  map[string]any{"delivered": "no", "smtpReply": "550 5.1.1 no such user"},
+		}})
+	fake.Add("EmailSubmission", map[string]any{"id": "S3", "emailId": "e2", "undoStatus": "final", "sendAt": now.Add(-72 * time.Hour).Format(time.RFC3339)})
+
+	out := readOutbox(t, s)
+	for _, want := range []string{
+		"## Awaiting approval (1)", `"Budget" to cfo@example.org`,
+		"## Scheduled (1)", `[submission: S1] "Lunch" to bob@example.org, sends`,
+		"## Sent in the last 24 hours (1)", `[submission: S2] "Report", sent`,
+		"carol@example.org: delivered, read", "dave@example.org: failed (550 5.1.1 no such user)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("outbox lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Secret") || strings.Contains(out, "S3") {
+		t.Errorf("outbox shows another owner's entry or an old submission:\n%s", out)
+	}
+
+	// Unchanged: only EmailSubmission/changes is called.
+	before := len(fake.Calls())
+	readOutbox(t, s)
+	if calls := fake.Calls()[before:]; len(calls) != 1 || calls[0] != "EmailSubmission/changes" {
+		t.Errorf("calls = %v, want only EmailSubmission/changes", calls)
+	}
+
+	fake.Add("EmailSubmission", map[string]any{"id": "S1", "emailId": "e1", "undoStatus": "final", "sendAt": now.Add(-time.Minute).Format(time.RFC3339)})
+	if out := readOutbox(t, s); !strings.Contains(out, "## Scheduled (0)") || !strings.Contains(out, "## Sent in the last 24 hours (2)") {
+		t.Errorf("outbox not refreshed after the submission was sent:\n%s", out)
+	}
+}
@@ -0,0 +1,253 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- jmap://thread/{id}/summary ---
+
+var threadSummaryTemplate = &mcp.ResourceTemplate{
+	Name:        "thread_summary",
+	Title:       "Thread summary",
+	URITemplate: "jmap://thread/{id}/summary",
+	Description: "Compact chronological digest of a conversation thread: participants, one line per message, and Decisions / Open questions sections for the client to fill in. Regenerated only when Thread/changes reports the thread updated. Thread IDs are shown by email_get.",
+	MIMEType:    "text/markdown",
+}
+
+// threadSummaryURI returns the summary resource URI of a thread.
+func threadSummaryURI(id jmap.ID) string {
+	return fmt.Sprintf("jmap://thread/%s/summary", id)
+}
+
+// parseThreadSummaryURI extracts the thread ID from a summary URI.
+func parseThreadSummaryURI(uri string) (jmap.ID, bool) {
+	rest, ok := strings.CutPrefix(uri, "jmap://thread/")
+	if !ok {
+		return "", false
+	}
+	id, ok := strings.CutSuffix(rest, "/summary")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return jmap.ID(id), true
+}
+
+// threadDigest is a rendered summary and the Thread state it reflects.
+type threadDigest struct {
+	state string
+	text  string
+}
+
+func (s *Server) readThreadSummary(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	threadID, ok := parseThreadSummaryURI(uri)
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return nil, fmt.Errorf("no primary mail account")
+	}
+
+	owner := s.idOwner(ctx)
+	key := "digest\x00" + client.Session().APIURL + "\x00" + string(accountID) + "\x00" + string(threadID)
+	v, cached := s.tenants.get(owner, key)
+	digest, _ := v.(threadDigest)
+	if cached {
+		// The cached digest stands unless the thread changed since; any
+		// failure to tell falls through to a rebuild.
+		if state, changed, err := threadChangedSince(ctx, client, accountID, threadID, digest.state); err == nil && !changed {
+			digest.state = state
+			s.tenants.put(owner, key, digest, int64(len(digest.state)+len(digest.text)))
+		} else {
+			cached = false
+		}
+	}
+	if !cached {
+		digest, err = s.buildThreadDigest(ctx, client, accountID, threadID)
+		if err != nil {
+			return nil, err
+		}
+		s.tenants.put(owner, key, digest, int64(len(digest.state)+len(digest.text)))
+	}
+
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{
+		URI:      uri,
+		MIMEType: "text/markdown",
+		Text:     digest.text,
+	}}}, nil
+}
+
+// threadChangedSince reports whether Thread/changes lists threadID as
+// updated or destroyed since state, and returns the new state.
+func threadChangedSince(ctx context.Context, client JMAPClient, accountID, threadID jmap.ID, state string) (string, bool, error) {
+	for {
+		req := &jmap.Request{Context: ctx}
+		req.Invoke(&thread.Changes{Account: accountID, SinceState: state})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", false, err
+		}
+		if len(resp.Responses) == 0 {
+			return "", false, fmt.Errorf("empty response for Thread/changes")
+		}
+
+		switch args := resp.Responses[0].Args.(type) {
+		case *thread.ChangesResponse:
+			if slices.Contains(args.Updated, threadID) || slices.Contains(args.Destroyed, threadID) {
+				return args.NewState, true, nil
+			}
+			if !args.HasMoreChanges || args.NewState == state {
+				return args.NewState, false, nil
+			}
+			state = args.NewState
+		case *jmap.MethodError:
+			return "", false, args
+		default:
+			return "", false, fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+}
+
+// buildThreadDigest fetches a thread and its emails' metadata and previews
+// in one request and renders the digest.
+func (s *Server) buildThreadDigest(ctx context.Context, client JMAPClient, accountID, threadID jmap.ID) (threadDigest, error) {
+	req := &jmap.Request{Context: ctx}
+	threadCallID := req.Invoke(&thread.Get{
+		Account: accountID,
+		IDs:     []jmap.ID{threadID},
+	})
+	req.Invoke(&email.Get{
+		Account: accountID,
+		ReferenceIDs: &jmap.ResultReference{
+			ResultOf: threadCallID,
+			Name:     "Thread/get",
+			Path:     "/list/*/emailIds",
+		},
+		Properties: []string{"id", "subject", "from", "to", "cc", "receivedAt", "preview"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return threadDigest{}, err
+	}
+	if len(resp.Responses) < 2 {
+		return threadDigest{}, fmt.Errorf("missing Email/get response in thread chain")
+	}
+
+	var state string
+	switch args := resp.Responses[0].Args.(type) {
+	case *thread.GetResponse:
+		if len(args.List) == 0 {
+			return threadDigest{}, mcp.ResourceNotFoundError(threadSummaryURI(threadID))
+		}
+		state = args.State
+	case *jmap.MethodError:
+		return threadDigest{}, args
+	default:
+		return threadDigest{}, fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	var emails []*email.Email
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		emails = args.List
+	case *jmap.MethodError:
+		return threadDigest{}, args
+	default:
+		return threadDigest{}, fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	return threadDigest{state: state, text: s.formatThreadDigest(threadID, emails)}, nil
+}
+
+// formatThreadDigest renders emails oldest first as a Markdown digest.
+func (s *Server) formatThreadDigest(threadID jmap.ID, emails []*email.Email) string {
+	slices.SortStableFunc(emails, func(a, b *email.Email) int {
+		return receivedAt(a).Compare(receivedAt(b))
+	})
+
+	var sb strings.Builder
+	subject := "(no subject)"
+	if len(emails) > 0 && emails[0].Subject != "" {
+		subject = emails[0].Subject
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", subject)
+	fmt.Fprintf(&sb, "Thread %s, %d message(s)", threadID, len(emails))
+	if len(emails) > 0 {
+		fmt.Fprintf(&sb, ", %s", s.locale.rangeText(s.locale.date(receivedAt(emails[0])), s.locale.date(receivedAt(emails[len(emails)-1]))))
+	}
+	sb.WriteString("\n\n## Participants\n\n")
+	for _, p := range threadParticipants(emails) {
+		fmt.Fprintf(&sb, "- %s\n", p)
+	}
+
+	sb.WriteString("\n## Messages\n\n")
+	for i, e := range emails {
+		preview, _ := s.redactor.redact(strings.Join(strings.Fields(e.Preview), " "))
+		fmt.Fprintf(&sb, "%d. %s, %s: %s [id: %s]\n", i+1, s.locale.dateTime(receivedAt(e)), formatAddresses(e.From), preview, e.ID)
+	}
+
+	sb.WriteString("\n## Decisions\n\n_To be filled in by the client from the messages above._\n")
+	sb.WriteString("\n## Open questions\n\n_To be filled in by the client from the messages above._\n")
+	return sb.String()
+}
+
+// threadParticipants lists the senders of emails with their message
+// counts, then the recipients who never wrote, each in order of first
+// appearance.
+func threadParticipants(emails []*email.Email) []string {
+	sent := make(map[string]int)
+	names := make(map[string]string)
+	var order []string
+	note := func(a *mail.Address) {
+		key := strings.ToLower(a.Email)
+		if _, ok := names[key]; !ok {
+			names[key] = a.String()
+			order = append(order, key)
+		}
+	}
+	for _, e := range emails {
+		for _, a := range e.From {
+			note(a)
+			sent[strings.ToLower(a.Email)]++
+		}
+	}
+	for _, e := range emails {
+		for _, a := range append(append([]*mail.Address(nil), e.To...), e.CC...) {
+			note(a)
+		}
+	}
+	participants := make([]string, 0, len(order))
+	for _, key := range order {
+		if n := sent[key]; n > 0 {
+			participants = append(participants, fmt.Sprintf("%s (%d sent)", names[key], n))
+		} else {
+			participants = append(participants, names[key])
+		}
+	}
+	return participants
+}
+
+func receivedAt(e *email.Email) time.Time {
+	if e.ReceivedAt == nil {
+		return time.Time{}
+	}
+	return *e.ReceivedAt
+}
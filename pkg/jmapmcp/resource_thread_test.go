@@ -0,0 +1,96 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func TestParseThreadSummaryURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+		ok   bool
+	}{
+		{"jmap://thread/T1/summary", "T1", true},
+		{"jmap://thread//summary", "", false},
+		{"jmap://thread/T1/other", "", false},
+		{"jmap://thread/a/b/summary", "", false},
+		{"jmap://email/T1/summary", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseThreadSummaryURI(tt.uri)
+		if string(got) != tt.want || ok != tt.ok {
+			t.Errorf("parseThreadSummaryURI(%q) = %q, %v; want %q, %v", tt.uri, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// addThreadEmail seeds an email in thread T1 with a preview and recipient.
+func addThreadEmail(fake *jmapfake.Server, id, subject, preview string, day int) {
+	addEmail(fake, id, subject, "", day)
+	e := fake.Object("Email", id)
+	e["threadId"] = "T1"
+	e["preview"] = preview
+	e["to"] = []any{map[string]any{"email": "bob@example.org"}}
+	fake.Add("Email", e)
+}
+
+func readSummary(t *testing.T, s *Server, uri string) string {
+	t.Helper()
+	res, err := s.readThreadSummary(context.Background(), &mcp.ReadResourceRequest{Params: &mcp.ReadResourceParams{URI: uri}})
+	if err != nil {
+		t.Fatalf("read %s: %v", uri, err)
+	}
+	return res.Contents[0].Text
+}
+
+func TestThreadSummary(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addThreadEmail(fake, "e2", "Re: Offsite", "Tuesday works for me.", 2)
+	addThreadEmail(fake, "e1", "Offsite", "Shall we meet Tuesday?", 1)
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1", "e2"}})
+
+	out := readSummary(t, s, "jmap://thread/T1/summary")
+	for _, want := range []string{
+		"# Offsite\n",
+		"Thread T1, 2 message(s), 2025-01-01 to 2025-01-02",
+		"- Alice <alice@example.org> (2 sent)\n- bob@example.org\n",
+		"## Open questions",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "Shall we meet") > strings.Index(out, "Tuesday works") {
+		t.Errorf("messages not oldest first:\n%s", out)
+	}
+
+	// Unchanged: only Thread/changes is called and the digest is reused.
+	before := len(fake.Calls())
+	if again := readSummary(t, s, "jmap://thread/T1/summary"); again != out {
+		t.Errorf("digest changed without thread updates:\n%s", again)
+	}
+	if calls := fake.Calls()[before:]; len(calls) != 1 || calls[0] != "Thread/changes" {
+		t.Errorf("calls = %v, want only Thread/changes", calls)
+	}
+
+	// A new message updates the thread and the digest is rebuilt.
+	addThreadEmail(fake, "e3", "Re: Offsite", "Booked the room.", 3)
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1", "e2", "e3"}})
+	if out := readSummary(t, s, "jmap://thread/T1/summary"); !strings.Contains(out, "Booked the room.") {
+		t.Errorf("digest not rebuilt after update:\n%s", out)
+	}
+}
+
+func TestThreadSummaryNotFound(t *testing.T) {
+	s, _ := newFakeServer(t)
+	_, err := s.readThreadSummary(context.Background(), &mcp.ReadResourceRequest{Params: &mcp.ReadResourceParams{URI: "jmap://thread/missing/summary"}})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("err = %v, want resource not found", err)
+	}
+}
@@ -0,0 +1,265 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/mikluko/jmap"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// A JMAP response may hold more than one invocation per method call: RFC
+// 8620 implicit calls answer under the caller's call ID (the Email/set an
+// EmailSubmission/set's onSuccessUpdateEmail triggers), and servers may add
+// responses of methods go-jmap has no type for, which would otherwise fail
+// decoding of the whole response. Handlers dispatch on resp.Responses[i]
+// for call i, so every response is normalized before they see it:
+// invocations of unregistered methods are dropped and reported as warnings
+// on the tool result, and the primary response to each call is moved to
+// its call's index, with the extras after them.
+
+// unknownMethodProbe is the call ID used to test whether go-jmap can decode
+// a method's responses.
+const unknownMethodProbe = "probe"
+
+// registeredMethods caches whether go-jmap decodes responses named by key.
+var registeredMethods sync.Map
+
+// methodRegistered reports whether go-jmap has a response type for name.
+func methodRegistered(name string) bool {
+	if ok, found := registeredMethods.Load(name); found {
+		return ok.(bool)
+	}
+	raw, _ := json.Marshal([]any{name, map[string]any{}, unknownMethodProbe})
+	var inv jmap.Invocation
+	err := json.Unmarshal(raw, &inv)
+	ok := err == nil || !strings.Contains(err.Error(), "not registered")
+	registeredMethods.Store(name, ok)
+	return ok
+}
+
+// dropUnknownResponses removes the invocations of unregistered methods from
+// a JMAP response body and returns their names. Bodies without any are
+// returned unchanged.
+func dropUnknownResponses(body []byte) ([]byte, []string) {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return body, nil
+	}
+	var invocations []json.RawMessage
+	if err := json.Unmarshal(resp["methodResponses"], &invocations); err != nil {
+		return body, nil
+	}
+	kept := invocations[:0:0]
+	var dropped []string
+	for _, raw := range invocations {
+		var head []json.RawMessage
+		var name string
+		if json.Unmarshal(raw, &head) == nil && len(head) > 0 && json.Unmarshal(head[0], &name) == nil && !methodRegistered(name) {
+			dropped = append(dropped, name)
+			continue
+		}
+		kept = append(kept, raw)
+	}
+	if len(dropped) == 0 {
+		return body, nil
+	}
+	resp["methodResponses"], _ = json.Marshal(kept)
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return body, nil
+	}
+	return out, dropped
+}
+
+// responseWarnings collects the warnings of one tool call.
+type responseWarnings struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (w *responseWarnings) add(dropped []string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, name := range dropped {
+		w.warnings = append(w.warnings, fmt.Sprintf("Warning: the server sent a %s response jmap-mcp does not understand; it was ignored.", name))
+	}
+}
+
+// note adds a warning of the call's own.
+func (w *responseWarnings) note(msg string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, msg)
+}
+
+func (w *responseWarnings) list() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.warnings...)
+}
+
+var responseWarningsKey = contextKey{"jmap-response-warnings"}
+
+func responseWarningsFromContext(ctx context.Context) *responseWarnings {
+	if ctx == nil {
+		return nil
+	}
+	w, _ := ctx.Value(responseWarningsKey).(*responseWarnings)
+	return w
+}
+
+// withResponseWarnings appends the warnings raised while serving a call
+// of h to its result.
+func withResponseWarnings[In any](h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, any, error) {
+		w := &responseWarnings{}
+		res, out, err := h(context.WithValue(ctx, responseWarningsKey, w), req, in)
+		if warnings := w.list(); len(warnings) > 0 && res != nil {
+			res.Content = append(res.Content, &mcp.TextContent{Text: strings.Join(warnings, "\n")})
+		}
+		return res, out, err
+	}
+}
+
+// rawResponses keeps the raw API response bodies of one tool call, for
+// handlers that need properties go-jmap's types drop in decoding (the
+// shareWith of JMAP Sharing). Both the HTTP transport and the WebSocket
+// client hand it every body they receive under a context carrying it.
+type rawResponses struct {
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func (r *rawResponses) add(body []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, body)
+}
+
+func (r *rawResponses) list() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]byte(nil), r.bodies...)
+}
+
+var rawResponsesKey = contextKey{"jmap-raw-responses"}
+
+// withRawResponses returns a context under which the raw bodies of API
+// responses are kept in the returned rawResponses.
+func withRawResponses(ctx context.Context) (context.Context, *rawResponses) {
+	r := &rawResponses{}
+	return context.WithValue(ctx, rawResponsesKey, r), r
+}
+
+func rawResponsesFromContext(ctx context.Context) *rawResponses {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(rawResponsesKey).(*rawResponses)
+	return r
+}
+
+// unknownResponseTransport drops unregistered method responses from API
+// response bodies.
+type unknownResponseTransport struct {
+	base   http.RoundTripper
+	apiURL string
+}
+
+func (t unknownResponseTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	if err != nil || r.Method != http.MethodPost || r.URL.String() != t.apiURL || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	rawResponsesFromContext(r.Context()).add(data)
+	data, dropped := dropUnknownResponses(data)
+	responseWarningsFromContext(r.Context()).add(dropped)
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	return resp, nil
+}
+
+// tolerantClient normalizes every response to its request (see
+// normalizeResponses), decodes the RFC 2047 encoded words servers leave
+// in email headers (see decodeEmailHeaders), and answers Thread/get for
+// servers that cannot (see threadFallback).
+type tolerantClient struct {
+	JMAPClient
+	threads *threadFallback // nil leaves Thread/get to the server
+}
+
+func (c tolerantClient) Do(req *jmap.Request) (*jmap.Response, error) {
+	if c.threads != nil {
+		return c.threads.do(req, c.do)
+	}
+	return c.do(req)
+}
+
+// do sends req and normalizes the response.
+func (c tolerantClient) do(req *jmap.Request) (*jmap.Response, error) {
+	resp, err := c.JMAPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	normalizeResponses(req, resp)
+	decodeEmailHeaders(resp)
+	return resp, nil
+}
+
+// normalizeResponses puts the primary response to each of req's calls (the
+// invocation with its call ID and method name, or an error) at the call's
+// index, followed by any other invocations in server order. A call the
+// server did not answer gets a serverFail method error.
+func normalizeResponses(req *jmap.Request, resp *jmap.Response) {
+	used := make([]bool, len(resp.Responses))
+	out := make([]*jmap.Invocation, 0, len(resp.Responses)+len(req.Calls))
+	for _, call := range req.Calls {
+		found := -1
+		for i, inv := range resp.Responses {
+			if !used[i] && inv.CallID == call.CallID && (inv.Name == call.Name || inv.Name == "error") {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			desc := fmt.Sprintf("server sent no response to %s", call.Name)
+			out = append(out, &jmap.Invocation{Name: "error", CallID: call.CallID, Args: &jmap.MethodError{Type: "serverFail", Description: &desc}})
+			continue
+		}
+		used[found] = true
+		out = append(out, resp.Responses[found])
+	}
+	for i, inv := range resp.Responses {
+		if !used[i] {
+			out = append(out, inv)
+		}
+	}
+	resp.Responses = out
+}
+
+// callResponses returns the primary responses of a normalized response to
+// req, one per call, leaving out implicit and other extra invocations.
+func callResponses(req *jmap.Request, resp *jmap.Response) []*jmap.Invocation {
+	return resp.Responses[:min(len(req.Calls), len(resp.Responses))]
+}
@@ -0,0 +1,63 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestUnknownMethodResponseIgnored(t *testing.T) {
+	for _, ws := range []bool{false, true} {
+		s, fake := newFakeServer(t)
+		if ws {
+			fake.EnableWebSocket()
+		}
+		addEmail(fake, "e1", "Hello", "body", 1)
+		fake.InjectNext("Email/query", "Vendor/audit", map[string]any{"note": "x"})
+		h := withResponseWarnings(s.handleEmailQuery)
+
+		res, _, _ := h(context.Background(), nil, EmailQueryInput{})
+		out := resultText(res)
+		if res.IsError || !strings.Contains(out, "Hello") {
+			t.Fatalf("websocket %v: email_query failed:\n%s", ws, out)
+		}
+		if !strings.Contains(out, "Vendor/audit response jmap-mcp does not understand") {
+			t.Errorf("websocket %v: result lacks the warning:\n%s", ws, out)
+		}
+	}
+}
+
+func TestExtraResponseBeforePrimary(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Hello", "body", 1)
+	fake.InjectNext("Email/get", "Email/set", map[string]any{"accountId": "a1"})
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{})
+	if res.IsError || !strings.Contains(resultText(res), "Hello") {
+		t.Fatalf("email_query with an implicit response failed:\n%s", resultText(res))
+	}
+}
+
+func TestNormalizeResponses(t *testing.T) {
+	req := &jmap.Request{}
+	req.Invoke(&email.Query{})
+	req.Invoke(&email.Get{})
+	set := &jmap.Invocation{Name: "Email/set", CallID: "1", Args: &email.SetResponse{}}
+	get := &jmap.Invocation{Name: "Email/get", CallID: "1", Args: &email.GetResponse{}}
+	resp := &jmap.Response{Responses: []*jmap.Invocation{set, get}}
+
+	normalizeResponses(req, resp)
+	if len(resp.Responses) != 3 || resp.Responses[1] != get || resp.Responses[2] != set {
+		t.Fatalf("responses = %v", resp.Responses)
+	}
+	me, ok := resp.Responses[0].Args.(*jmap.MethodError)
+	if !ok || me.Type != "serverFail" {
+		t.Errorf("unanswered call = %#v, want serverFail", resp.Responses[0].Args)
+	}
+	if got := callResponses(req, resp); len(got) != 2 {
+		t.Errorf("callResponses = %d, want 2", len(got))
+	}
+}
@@ -0,0 +1,161 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Local files: in stdio mode jmap-mcp runs on the user's machine, so tools
+// can read and write files the user names by path, within the filesystem
+// roots the MCP client lists (WithLocalFiles). Roots are asked for on every
+// call, so a client that narrows them takes effect at once. A path must
+// resolve, symlinks followed, inside a root; relative paths are taken from
+// the first root. Writes never replace an existing file.
+
+const (
+	maxLocalFileBytes = 256 << 20 // a local file read or written by a tool
+	maxLocalBodyBytes = 4 << 20   // a local file read as an email body
+)
+
+// rootDirs lists the directories of the file:// roots of the client behind
+// req, with symlinks resolved.
+func rootDirs(ctx context.Context, req *mcp.CallToolRequest) ([]string, error) {
+	if req == nil || req.Session == nil {
+		return nil, invalidArgument("local file paths need an MCP client session")
+	}
+	// The initialize params cannot tell a client offering roots without
+	// change notifications from one without roots, so just ask.
+	res, err := req.Session.ListRoots(ctx, nil)
+	if err != nil {
+		return nil, invalidArgument("the MCP client does not offer filesystem roots (%v), so local file paths are unavailable", err)
+	}
+	var dirs []string
+	for _, r := range res.Roots {
+		u, err := url.Parse(r.URI)
+		if err != nil || u.Scheme != "file" || u.Path == "" {
+			continue
+		}
+		dir, err := filepath.EvalSymlinks(filepath.FromSlash(u.Path))
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	if len(dirs) == 0 {
+		return nil, invalidArgument("the MCP client lists no filesystem roots on this machine")
+	}
+	return dirs, nil
+}
+
+// withinRoots reports whether path, already resolved, is one of dirs or
+// inside one.
+func withinRoots(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// localPath resolves p, an existing file or directory, inside the roots of
+// the client behind req.
+func (s *Server) localPath(ctx context.Context, req *mcp.CallToolRequest, p string) (string, error) {
+	if !s.localFiles {
+		return "", invalidArgument("local file paths are only available in stdio mode")
+	}
+	dirs, err := rootDirs(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dirs[0], p)
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", invalidArgument("%s: %v", p, err)
+	}
+	if !withinRoots(resolved, dirs) {
+		return "", invalidArgument("%s is outside the client's roots", p)
+	}
+	return resolved, nil
+}
+
+// readLocalFile reads the regular file p inside the client's roots, at
+// most limit bytes.
+func (s *Server) readLocalFile(ctx context.Context, req *mcp.CallToolRequest, p string, limit int64) (string, []byte, error) {
+	path, err := s.localPath(ctx, req, p)
+	if err != nil {
+		return "", nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", nil, invalidArgument("%s is not a regular file", p)
+	}
+	if limit > 0 && fi.Size() > limit {
+		return "", nil, invalidArgument("%s is %s, over the %s limit", p, s.locale.size(fi.Size()), s.locale.size(limit))
+	}
+	data, err := os.ReadFile(path)
+	return path, data, err
+}
+
+// writeLocalFile creates p inside the client's roots with data, or, when p
+// is a directory, a file named name in it, and returns the path written.
+// An existing file is never replaced.
+func (s *Server) writeLocalFile(ctx context.Context, req *mcp.CallToolRequest, p, name string, data []byte) (string, error) {
+	if !s.localFiles {
+		return "", invalidArgument("local file paths are only available in stdio mode")
+	}
+	dirs, err := rootDirs(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dirs[0], p)
+	}
+	if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+		// A name from a message must not climb out of the directory.
+		name = filepath.Base(filepath.Clean("/" + name))
+		if name == "/" || name == "." {
+			return "", invalidArgument("%s is a directory; give a file path", p)
+		}
+		p = filepath.Join(p, name)
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return "", invalidArgument("%s: %v", filepath.Dir(p), err)
+	}
+	path := filepath.Join(dir, filepath.Base(p))
+	if !withinRoots(path, dirs) {
+		return "", invalidArgument("%s is outside the client's roots", p)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return "", invalidArgument("%s already exists; choose another path", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
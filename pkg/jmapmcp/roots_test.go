@@ -0,0 +1,179 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// rootsSession connects an in-memory client to s that lists dirs as its
+// filesystem roots.
+func rootsSession(t *testing.T, s *Server, dirs ...string) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil)
+	for _, dir := range dirs {
+		client.AddRoots(&mcp.Root{URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String()})
+	}
+	st, ct := mcp.NewInMemoryTransports()
+	if _, err := s.MCP().Connect(ctx, st, nil); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := client.Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+func callTool(t *testing.T, cs *mcp.ClientSession, name string, args map[string]any) (string, bool) {
+	t.Helper()
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resultText(res), res.IsError
+}
+
+func TestWithinRoots(t *testing.T) {
+	dirs := []string{"/home/me/docs"}
+	for path, want := range map[string]bool{
+		"/home/me/docs":           true,
+		"/home/me/docs/a.pdf":     true,
+		"/home/me/docs/..a/b.pdf": true,
+		"/home/me/docs2/a.pdf":    false,
+		"/home/me/a.pdf":          false,
+		"/etc/passwd":             false,
+	} {
+		if got := withinRoots(path, dirs); got != want {
+			t.Errorf("withinRoots(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestLocalFiles(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "report.pdf"), []byte("%PDF-1.4"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, fake := newFakeServer(t, WithLocalFiles())
+	blob := fake.AddBlob([]byte("a,b\n1,2\n"))
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Numbers",
+		"attachments": []any{map[string]any{"blobId": blob, "name": "../numbers.csv", "type": "text/csv", "size": 8}},
+	})
+	cs := rootsSession(t, s, root)
+
+	out, isErr := callTool(t, cs, "attachment_upload", map[string]any{"path": "report.pdf"})
+	if isErr || !strings.Contains(out, "Uploaded report.pdf (application/pdf, 8 bytes)") {
+		t.Errorf("upload by relative path = %s", out)
+	}
+	for _, p := range []string{filepath.Join(outside, "secret.txt"), filepath.Join(root, "escape", "secret.txt"), "../secret.txt"} {
+		if out, isErr := callTool(t, cs, "attachment_upload", map[string]any{"path": p}); !isErr || !strings.Contains(out, "outside the client's roots") && !strings.Contains(out, "no such file") {
+			t.Errorf("upload of %s = %s", p, out)
+		}
+	}
+
+	out, isErr = callTool(t, cs, "attachment_download", map[string]any{"email_id": "m1", "path": root})
+	want := filepath.Join(root, "numbers.csv")
+	if isErr || !strings.Contains(out, "to "+want) {
+		t.Fatalf("download to a directory = %s", out)
+	}
+	if data, _ := os.ReadFile(want); string(data) != "a,b\n1,2\n" {
+		t.Errorf("downloaded %q", data)
+	}
+	if out, isErr := callTool(t, cs, "attachment_download", map[string]any{"email_id": "m1", "path": "numbers.csv"}); !isErr || !strings.Contains(out, "already exists") {
+		t.Errorf("download over an existing file = %s", out)
+	}
+	if out, isErr := callTool(t, cs, "attachment_download", map[string]any{"email_id": "m1", "path": filepath.Join(root, "escape", "x.csv")}); !isErr || !strings.Contains(out, "outside the client's roots") {
+		t.Errorf("download through a symlink out of the roots = %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "x.csv")); err == nil {
+		t.Error("file written outside the roots")
+	}
+}
+
+func TestThreadExportSaveTo(t *testing.T) {
+	root := t.TempDir()
+	s, fake := newFakeServer(t, WithLocalFiles())
+	fake.Add("Email", map[string]any{"id": "e1", "threadId": "T1", "subject": "Budget", "receivedAt": "2025-01-02T10:00:00Z"})
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1"}})
+	cs := rootsSession(t, s, root)
+
+	out, isErr := callTool(t, cs, "thread_export", map[string]any{"thread_id": "T1", "save_to": root})
+	want := filepath.Join(root, "thread-T1.md")
+	if isErr || !strings.Contains(out, "saved to "+want) {
+		t.Fatalf("thread_export save_to = %s", out)
+	}
+	if data, _ := os.ReadFile(want); !strings.Contains(string(data), "Budget") {
+		t.Errorf("saved export = %s", data)
+	}
+
+	s, _ = newFakeServer(t)
+	res, _, _ := s.handleThreadExport(context.Background(), nil, ThreadExportInput{ThreadID: "T1", SaveTo: root})
+	if !res.IsError || !strings.Contains(resultText(res), "only available in stdio mode") {
+		t.Errorf("save_to without local files = %s", resultText(res))
+	}
+}
+
+func TestEmailCreateFromLocalFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "out"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "out", "report.pdf"), []byte("%PDF-1.4"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "note.txt"), []byte("The report is attached."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, fake := newFakeServer(t, WithLocalFiles())
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	cs := rootsSession(t, s, root)
+
+	out, isErr := callTool(t, cs, "email_create", map[string]any{
+		"to": []string{"bob@example.org"}, "subject": "Report", "body_path": "note.txt",
+		"attachments": []any{map[string]any{"path": "./out/report.pdf"}},
+	})
+	if isErr {
+		t.Fatalf("email_create = %s", out)
+	}
+	var draft map[string]any
+	for _, id := range fake.Objects("Email") {
+		if e := fake.Object("Email", id); e["subject"] == "Report" {
+			draft = e
+		}
+	}
+	parts, _ := draft["attachments"].([]any)
+	if len(parts) != 1 {
+		t.Fatalf("draft attachments = %v", draft["attachments"])
+	}
+	part := parts[0].(map[string]any)
+	if part["name"] != "report.pdf" || part["type"] != "application/pdf" || string(fake.Blob(part["blobId"].(string))) != "%PDF-1.4" {
+		t.Errorf("attachment = %v", part)
+	}
+	if values, _ := draft["bodyValues"].(map[string]any); !strings.Contains(fmt.Sprint(values), "The report is attached.") {
+		t.Errorf("body values = %v", draft["bodyValues"])
+	}
+
+	if out, isErr := callTool(t, cs, "email_create", map[string]any{
+		"to": []string{"bob@example.org"}, "subject": "Both", "body": "Hi", "body_path": "note.txt",
+	}); !isErr || !strings.Contains(out, "not both") {
+		t.Errorf("body and body_path = %s", out)
+	}
+}
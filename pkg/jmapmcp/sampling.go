@@ -0,0 +1,49 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// canSample reports whether the client behind req offers sampling, letting
+// a tool ask the client's model for a completion.
+func canSample(req *mcp.CallToolRequest) bool {
+	if req == nil || req.Session == nil {
+		return false
+	}
+	params := req.Session.InitializeParams()
+	return params != nil && params.Capabilities != nil && params.Capabilities.Sampling != nil
+}
+
+// sampleText asks the model of the client behind req to answer prompt,
+// following system, in at most maxTokens tokens, and returns the text of
+// its answer.
+func sampleText(ctx context.Context, req *mcp.CallToolRequest, system, prompt string, maxTokens int64) (string, error) {
+	if !canSample(req) {
+		return "", fmt.Errorf("the MCP client does not offer sampling")
+	}
+	res, err := req.Session.CreateMessage(ctx, &mcp.CreateMessageParams{
+		SystemPrompt: system,
+		Messages:     []*mcp.SamplingMessage{{Role: "user", Content: &mcp.TextContent{Text: prompt}}},
+		MaxTokens:    maxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("sampling: %w", err)
+	}
+	text, ok := res.Content.(*mcp.TextContent)
+	if !ok {
+		return "", fmt.Errorf("sampling: the client answered with %T, not text", res.Content)
+	}
+	return strings.TrimSpace(text.Text), nil
+}
+
+// summarizeText asks the client's model behind req to summarize text,
+// which what describes (such as "this email"), in at most words words.
+func summarizeText(ctx context.Context, req *mcp.CallToolRequest, what, text string, words int) (string, error) {
+	system := "You summarize email faithfully and briefly for a reader who will not see the original: who wants what, decisions, dates, amounts, and open questions. Write plain text with no preamble."
+	prompt := fmt.Sprintf("Summarize %s in at most %d words.\n\n%s", what, words, text)
+	return sampleText(ctx, req, system, prompt, int64(max(256, 2*words)))
+}
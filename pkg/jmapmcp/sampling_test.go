@@ -0,0 +1,41 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// samplingSession connects an in-memory client to s whose model answers
+// each sampling prompt with answer(prompt).
+func samplingSession(t *testing.T, s *Server, answer func(prompt string) string) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, &mcp.ClientOptions{
+		CreateMessageHandler: func(_ context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			prompt := req.Params.Messages[0].Content.(*mcp.TextContent).Text
+			return &mcp.CreateMessageResult{Role: "assistant", Model: "test", Content: &mcp.TextContent{Text: answer(prompt)}}, nil
+		},
+	})
+	st, ct := mcp.NewInMemoryTransports()
+	if _, err := s.MCP().Connect(ctx, st, nil); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := client.Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+func TestCanSample(t *testing.T) {
+	if canSample(nil) || canSample(&mcp.CallToolRequest{}) {
+		t.Error("sampling without a session")
+	}
+	if _, err := summarizeText(context.Background(), nil, "this email", "Hi", 50); err == nil || !strings.Contains(err.Error(), "does not offer sampling") {
+		t.Errorf("summarizeText without a session = %v", err)
+	}
+}
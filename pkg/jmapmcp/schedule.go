@@ -0,0 +1,594 @@
+package jmapmcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+// Schedules run actions at a set time, once or at a fixed interval, with
+// no MCP client attached: sending a draft later (for servers without
+// FUTURERELEASE), waking snoozed emails back into a mailbox, delivering an
+// email_digest as a message, moving old mail out of a mailbox, and
+// destroying what a staged permanent email_delete left in Trash. They are
+// created with schedule_create (destroy ones by email_delete), kept per
+// JMAP user in the store (-schedules-file), and run, like automations, by
+// the process started with -run-automations or -mode daemon, which
+// rereads the store when another process changes it.
+
+// Scheduled action kinds.
+const (
+	scheduleSend    = "send"    // submit a draft
+	scheduleWake    = "wake"    // move emails back into a mailbox, unread
+	scheduleDigest  = "digest"  // deliver an email_digest as a message
+	scheduleCleanup = "cleanup" // move mail older than some days to Trash
+	scheduleDestroy = "destroy" // destroy emails a staged email_delete left in Trash
+)
+
+// creatableScheduleKinds are the kinds schedule_create makes; destroy
+// schedules are made only by email_delete.
+var creatableScheduleKinds = []string{scheduleSend, scheduleWake, scheduleDigest, scheduleCleanup}
+
+// errScheduleKind refuses a kind schedule_create does not make.
+func errScheduleKind() error {
+	last := len(creatableScheduleKinds) - 1
+	return invalidArgument("kind must be %s, or %s", strings.Join(creatableScheduleKinds[:last], ", "), creatableScheduleKinds[last])
+}
+
+const (
+	maxSchedules           = 100 // per user
+	minScheduleInterval    = 15 * time.Minute
+	scheduleReloadInterval = time.Minute
+	maxCleanupPerRun       = 500
+)
+
+// scheduledAction is one configured schedule.
+type scheduledAction struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name,omitempty"`
+	Kind          string    `json:"kind"`
+	Next          time.Time `json:"next"`            // zero once a one-off run failed
+	Every         string    `json:"every,omitempty"` // repeat interval; empty runs once
+	EmailIDs      []jmap.ID `json:"emailIds,omitempty"`
+	MailboxID     jmap.ID   `json:"mailboxId,omitempty"`     // wake and digest: destination; cleanup: the mailbox cleaned; destroy: Trash
+	OlderThanDays int       `json:"olderThanDays,omitempty"` // cleanup
+	Created       time.Time `json:"created"`
+
+	Runs      int       `json:"runs,omitempty"`
+	LastRun   time.Time `json:"lastRun,omitzero"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// interval returns a's repeat interval, or 0 when it runs once.
+func (a *scheduledAction) interval() time.Duration {
+	d, _ := time.ParseDuration(a.Every)
+	return d
+}
+
+// Schedules keeps each JMAP user's scheduled actions, keyed by the session
+// username. When it has a store, they are saved there as JSON after every
+// change so they survive restarts and reach a daemon sharing the file; the
+// zero value keeps them in memory.
+type Schedules struct {
+	mu      sync.Mutex
+	store   store.Store
+	doc     string    // the store's document name
+	modTime time.Time // of the document as last read or written
+	users   map[string][]*scheduledAction
+	changed chan struct{} // wakes runSchedules after a change
+	running string        // user runSchedules runs for; empty when it does not run
+}
+
+// LoadSchedules reads the schedules saved in st as doc, a JSON object of
+// the form {"user": [schedule, ...]}. A missing document has none and is
+// created on the first one.
+func LoadSchedules(st store.Store, doc string) (*Schedules, error) {
+	sc := &Schedules{store: st, doc: doc}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if err := sc.reloadLocked(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// reloadLocked rereads the document when another process changed it since
+// it was last read or written, so changes are made to the latest
+// schedules. The caller holds sc.mu.
+func (sc *Schedules) reloadLocked() error {
+	if sc.store == nil {
+		return nil
+	}
+	modTime, err := sc.store.Stat(sc.doc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("schedules: %w", err)
+	}
+	if modTime.Equal(sc.modTime) {
+		return nil
+	}
+	data, modTime, err := sc.store.Read(sc.doc)
+	if err != nil {
+		return fmt.Errorf("schedules: %w", err)
+	}
+	var users map[string][]*scheduledAction
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("schedules %s: %w", sc.doc, err)
+	}
+	for user, list := range users {
+		for _, a := range list {
+			if err := checkSchedule(a); err != nil {
+				return fmt.Errorf("schedules %s: %s of %s: %w", sc.doc, a.ID, user, err)
+			}
+		}
+	}
+	sc.users, sc.modTime = users, modTime
+	return nil
+}
+
+// list returns copies of user's schedules, the next due first and failed
+// ones last.
+func (sc *Schedules) list(user string) ([]scheduledAction, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	err := sc.reloadLocked()
+	out := make([]scheduledAction, len(sc.users[user]))
+	for i, a := range sc.users[user] {
+		out[i] = *a
+	}
+	slices.SortStableFunc(out, func(a, b scheduledAction) int {
+		switch {
+		case a.Next.IsZero() != b.Next.IsZero():
+			if a.Next.IsZero() {
+				return 1
+			}
+			return -1
+		default:
+			return a.Next.Compare(b.Next)
+		}
+	})
+	return out, err
+}
+
+// add gives a an ID, appends it to user's schedules, and saves the store.
+func (sc *Schedules) add(user string, a *scheduledAction) error {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	a.ID = "sched-" + hex.EncodeToString(b[:])
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if err := sc.reloadLocked(); err != nil {
+		return err
+	}
+	current := sc.users[user]
+	if len(current) >= maxSchedules {
+		return invalidArgument("%s already has %d schedules, the limit; cancel some with schedule_cancel", user, maxSchedules)
+	}
+	if err := sc.replace(user, append(slices.Clone(current), a)); err != nil {
+		return err
+	}
+	sc.wake()
+	return nil
+}
+
+// remove deletes user's schedule id and saves the store, reporting whether
+// it existed.
+func (sc *Schedules) remove(user, id string) (bool, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if err := sc.reloadLocked(); err != nil {
+		return false, err
+	}
+	current := sc.users[user]
+	next := slices.DeleteFunc(slices.Clone(current), func(a *scheduledAction) bool { return a.ID == id })
+	if len(next) == len(current) {
+		return false, nil
+	}
+	if err := sc.replace(user, next); err != nil {
+		return false, err
+	}
+	sc.wake()
+	return true, nil
+}
+
+// due returns copies of user's schedules due at now, and when the next
+// of the others is due (zero when none is).
+func (sc *Schedules) due(user string, now time.Time) ([]scheduledAction, time.Time, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	err := sc.reloadLocked()
+	var due []scheduledAction
+	var next time.Time
+	for _, a := range sc.users[user] {
+		switch {
+		case a.Next.IsZero():
+		case !a.Next.After(now):
+			due = append(due, *a)
+		case next.IsZero() || a.Next.Before(next):
+			next = a.Next
+		}
+	}
+	return due, next, err
+}
+
+// finish records that user's schedule id ran at ran, failing with runErr,
+// and saves the store: a one-off schedule is removed when it succeeded and
+// kept, not rescheduled, when it failed; a repeating one is due again at
+// its next interval after ran.
+func (sc *Schedules) finish(user, id string, ran time.Time, runErr error) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if err := sc.reloadLocked(); err != nil {
+		return err
+	}
+	current := sc.users[user]
+	i := slices.IndexFunc(current, func(a *scheduledAction) bool { return a.ID == id })
+	if i < 0 {
+		return nil // cancelled while it ran
+	}
+	next := slices.Clone(current)
+	a := *next[i]
+	a.LastRun, a.LastError = ran.UTC(), ""
+	if runErr != nil {
+		a.LastError = runErr.Error()
+	} else {
+		a.Runs++
+	}
+	switch every := a.interval(); {
+	case every > 0:
+		for !a.Next.After(ran) {
+			a.Next = a.Next.Add(every)
+		}
+	case runErr == nil:
+		return sc.replace(user, slices.Delete(next, i, i+1))
+	default:
+		a.Next = time.Time{}
+	}
+	next[i] = &a
+	return sc.replace(user, next)
+}
+
+// runningFor reports whether runSchedules runs user's schedules in this
+// process.
+func (sc *Schedules) runningFor(user string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.running != "" && sc.running == user
+}
+
+// replace sets user's schedules and saves the store, restoring the
+// previous ones when saving fails. The caller holds sc.mu.
+func (sc *Schedules) replace(user string, list []*scheduledAction) error {
+	if sc.users == nil {
+		sc.users = make(map[string][]*scheduledAction)
+	}
+	previous, existed := sc.users[user]
+	if len(list) == 0 {
+		delete(sc.users, user)
+	} else {
+		sc.users[user] = list
+	}
+	if err := sc.save(); err != nil {
+		if existed {
+			sc.users[user] = previous
+		} else {
+			delete(sc.users, user)
+		}
+		return err
+	}
+	return nil
+}
+
+// wake tells a running runSchedules to pick up a change. The caller holds
+// sc.mu.
+func (sc *Schedules) wake() {
+	if sc.changed == nil {
+		return
+	}
+	select {
+	case sc.changed <- struct{}{}:
+	default:
+	}
+}
+
+// save writes the schedules to their document. The caller holds sc.mu.
+func (sc *Schedules) save() error {
+	if sc.store == nil {
+		return nil
+	}
+	modTime, err := store.WriteJSON(sc.store, sc.doc, sc.users)
+	if err != nil {
+		return fmt.Errorf("saving schedules: %w", err)
+	}
+	sc.modTime = modTime
+	return nil
+}
+
+// checkSchedule validates a schedule's kind and the inputs it needs.
+func checkSchedule(a *scheduledAction) error {
+	if a.Every != "" {
+		every, err := time.ParseDuration(a.Every)
+		if err != nil {
+			return invalidArgument("every: %v", err)
+		}
+		if every < minScheduleInterval {
+			return invalidArgument("every must be at least %s", minScheduleInterval)
+		}
+	}
+	switch a.Kind {
+	case scheduleSend:
+		if len(a.EmailIDs) != 1 {
+			return invalidArgument("send takes exactly one draft in email_ids")
+		}
+		if a.Every != "" {
+			return invalidArgument("send runs once; every is not allowed")
+		}
+	case scheduleWake:
+		if len(a.EmailIDs) == 0 || len(a.EmailIDs) > maxCleanupPerRun {
+			return invalidArgument("wake takes 1 to %d emails in email_ids", maxCleanupPerRun)
+		}
+		if a.Every != "" {
+			return invalidArgument("wake runs once; every is not allowed")
+		}
+		if a.MailboxID == "" {
+			return invalidArgument("mailbox_id is required")
+		}
+	case scheduleDigest:
+		if a.Every == "" {
+			return invalidArgument("digest repeats; every is required (e.g. 24h)")
+		}
+		if a.MailboxID == "" {
+			return invalidArgument("mailbox_id is required")
+		}
+	case scheduleCleanup:
+		if a.Every == "" {
+			return invalidArgument("cleanup repeats; every is required (e.g. 24h)")
+		}
+		if a.MailboxID == "" {
+			return invalidArgument("mailbox_id is required")
+		}
+		if a.OlderThanDays < 1 {
+			return invalidArgument("older_than_days must be at least 1")
+		}
+	case scheduleDestroy:
+		if len(a.EmailIDs) == 0 {
+			return invalidArgument("destroy takes at least one email in email_ids")
+		}
+		if a.Every != "" {
+			return invalidArgument("destroy runs once; every is not allowed")
+		}
+		if a.MailboxID == "" {
+			return invalidArgument("mailbox_id is required")
+		}
+	default:
+		return errScheduleKind()
+	}
+	return nil
+}
+
+// runSchedules runs user's schedules as they come due until ctx is done,
+// sleeping until the next one, a change made with the schedule tools, or
+// the minutely reload of the store.
+func (s *Server) runSchedules(ctx context.Context, user string) {
+	changed := make(chan struct{}, 1)
+	s.schedules.mu.Lock()
+	s.schedules.changed, s.schedules.running = changed, user
+	s.schedules.mu.Unlock()
+	defer func() {
+		s.schedules.mu.Lock()
+		s.schedules.changed, s.schedules.running = nil, ""
+		s.schedules.mu.Unlock()
+	}()
+
+	for {
+		due, next, err := s.schedules.due(user, time.Now())
+		if err != nil {
+			log.Printf("Schedules: %v", err)
+		}
+		for _, a := range due {
+			runErr := s.runScheduled(ctx, &a)
+			if runErr != nil {
+				log.Printf("Schedule %s (%s): %v", a.ID, a.Kind, runErr)
+			}
+			if err := s.schedules.finish(user, a.ID, time.Now(), runErr); err != nil {
+				log.Printf("Schedules: %v", err)
+			}
+		}
+		wait := scheduleReloadInterval
+		if !next.IsZero() && time.Until(next) < wait {
+			wait = max(time.Until(next), 0)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// runScheduled performs a through the tools, as the configured token's
+// user.
+func (s *Server) runScheduled(ctx context.Context, a *scheduledAction) error {
+	ids := make([]string, len(a.EmailIDs))
+	for i, id := range a.EmailIDs {
+		ids[i] = string(id)
+	}
+	switch a.Kind {
+	case scheduleSend:
+		if !s.enableEmailSubmission {
+			return fmt.Errorf("sending requires -enable-send")
+		}
+		return toolResultError(s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: ids[0]}))
+
+	case scheduleWake:
+		if err := toolResultError(s.handleEmailMove(ctx, nil, EmailMoveInput{EmailIDs: ids, MailboxID: string(a.MailboxID)})); err != nil {
+			return err
+		}
+		unseen := false
+		return toolResultError(s.handleEmailFlag(ctx, nil, EmailFlagInput{EmailIDs: ids, Seen: &unseen}))
+
+	case scheduleDigest:
+		since := a.LastRun
+		if since.IsZero() {
+			since = a.Next.Add(-a.interval())
+		}
+		res, _, _ := s.handleEmailDigest(ctx, nil, EmailDigestInput{Since: since.UTC().Format(time.RFC3339)})
+		if err := toolResultError(res, nil, nil); err != nil {
+			return err
+		}
+		subject := "Digest of mail since " + s.locale.dateTime(since)
+		if a.Name != "" {
+			subject = a.Name + ": " + subject
+		}
+		return s.deliverMessage(ctx, a.MailboxID, subject, resultTextOf(res))
+
+	case scheduleCleanup:
+		return s.cleanupMailbox(ctx, a.MailboxID, time.Now().AddDate(0, 0, -a.OlderThanDays))
+
+	case scheduleDestroy:
+		return s.destroyTrashed(ctx, a.MailboxID, a.EmailIDs)
+	}
+	return fmt.Errorf("unknown kind %q", a.Kind)
+}
+
+// deliverMessage creates an unread message from the user to themselves in
+// mailboxID, without sending it.
+func (s *Server) deliverMessage(ctx context.Context, mailboxID jmap.ID, subject, body string) error {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return err
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return fmt.Errorf("no primary mail account")
+	}
+	id, err := defaultIdentity(ctx, client, accountID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	msg := &email.Email{
+		MailboxIDs: map[jmap.ID]bool{mailboxID: true},
+		Subject:    subject,
+		SentAt:     &now,
+		BodyValues: map[string]*email.BodyValue{"body": {Value: body}},
+		TextBody:   []*email.BodyPart{{PartID: "body", Type: "text/plain"}},
+	}
+	if id != nil {
+		msg.From = []*mail.Address{{Name: id.Name, Email: id.Email}}
+		msg.To = []*mail.Address{{Name: id.Name, Email: id.Email}}
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Set{Account: accountID, Create: map[jmap.ID]*email.Email{"msg": msg}})
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses) == 0 {
+		return fmt.Errorf("empty response for Email/set")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		if se, ok := args.NotCreated["msg"]; ok {
+			return setFailure("delivering the message failed", se)
+		}
+		return nil
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// cleanupMailbox moves up to maxCleanupPerRun emails received in
+// mailboxID before cutoff to Trash; the next run takes the rest.
+func (s *Server) cleanupMailbox(ctx context.Context, mailboxID jmap.ID, cutoff time.Time) error {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return err
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return fmt.Errorf("no primary mail account")
+	}
+	cutoff = cutoff.UTC()
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Query{
+		Account: accountID,
+		Filter:  &email.FilterCondition{InMailbox: mailboxID, Before: &cutoff},
+		Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: true}},
+		Limit:   maxCleanupPerRun,
+	})
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses) == 0 {
+		return fmt.Errorf("empty response for Email/query")
+	}
+	var ids []string
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.QueryResponse:
+		for _, id := range args.IDs {
+			ids = append(ids, string(id))
+		}
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected response type: %T", args)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return toolResultError(s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: ids}))
+}
+
+// toolResultError turns what a tool handler returned into an error when
+// the call failed.
+func toolResultError(res *mcp.CallToolResult, _ any, err error) error {
+	switch {
+	case err != nil:
+		return err
+	case res == nil || !res.IsError:
+		return nil
+	}
+	if te, ok := res.StructuredContent.(*toolError); ok {
+		return errors.New(te.Message)
+	}
+	return errors.New(resultTextOf(res))
+}
+
+// resultTextOf joins the text content of a tool result.
+func resultTextOf(res *mcp.CallToolResult) string {
+	var text []string
+	for _, c := range res.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			text = append(text, tc.Text)
+		}
+	}
+	return strings.Join(text, "\n")
+}
@@ -0,0 +1,291 @@
+package jmapmcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Scripts extend the server without forking it. Each is an operator's
+// external program, listed in a JSON file (-scripts-file), that becomes a
+// tool named script_<name> and can be the action of an automation. A
+// script talks JSON lines over its stdin and stdout: it first reads its
+// input, a tool call's arguments or an automation's matches, then may call
+// the server's own tools and finally writes its result:
+//
+//	→ {"arguments": {...}}                          (or the automation payload)
+//	← {"call": "email_query", "arguments": {...}}
+//	→ {"text": "...", "isError": false}
+//	← {"result": "Filed 3 messages"}
+//
+// The calls run with the credential of whoever invoked the script, and
+// only for the tools its allow list names; without one, a script may call
+// read-only tools only. Scripts cannot call scripts.
+
+const (
+	// defaultScriptTimeout bounds one run of a script without a timeout.
+	defaultScriptTimeout = 30 * time.Second
+	// maxScriptCalls caps the tool calls of one run.
+	maxScriptCalls = 200
+	// maxScriptLineBytes caps one line a script writes.
+	maxScriptLineBytes = 4 << 20
+	// maxScriptStderr is how much of a failing script's stderr is reported.
+	maxScriptStderr = 2 << 10
+)
+
+// scriptToolPrefix starts the tool name of every script.
+const scriptToolPrefix = "script_"
+
+var scriptNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// Script is one configured script.
+type Script struct {
+	Name           string   `json:"name"`        // tool script_<name>; lower case letters, digits, and _
+	Description    string   `json:"description"` // tool description shown to clients
+	Command        []string `json:"command"`     // program and arguments
+	Allow          []string `json:"allow,omitempty"`
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"` // 0 uses defaultScriptTimeout
+}
+
+// LoadScripts reads the scripts listed at path, a JSON array of scripts.
+func LoadScripts(path string) ([]Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("scripts: %w", err)
+	}
+	var scripts []Script
+	if err := json.Unmarshal(data, &scripts); err != nil {
+		return nil, fmt.Errorf("scripts %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(scripts))
+	for _, sc := range scripts {
+		switch {
+		case !scriptNameRe.MatchString(sc.Name):
+			return nil, fmt.Errorf("scripts %s: name %q must be lower case letters, digits, and _", path, sc.Name)
+		case seen[sc.Name]:
+			return nil, fmt.Errorf("scripts %s: duplicate name %q", path, sc.Name)
+		case len(sc.Command) == 0:
+			return nil, fmt.Errorf("scripts %s: %s has no command", path, sc.Name)
+		case sc.TimeoutSeconds < 0:
+			return nil, fmt.Errorf("scripts %s: %s has a negative timeout", path, sc.Name)
+		}
+		seen[sc.Name] = true
+	}
+	return scripts, nil
+}
+
+// toolCall runs a registered tool's handler, with all its checks, on JSON
+// arguments.
+type toolCall struct {
+	tool *mcp.Tool
+	call func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error)
+}
+
+// toolCalls holds the registered tools by name.
+type toolCalls map[string]toolCall
+
+// allows reports whether sc may call t.
+func (sc *Script) allows(t *mcp.Tool) bool {
+	if strings.HasPrefix(t.Name, scriptToolPrefix) {
+		return false
+	}
+	if len(sc.Allow) == 0 {
+		return t.Annotations != nil && t.Annotations.ReadOnlyHint
+	}
+	return slices.Contains(sc.Allow, t.Name)
+}
+
+// scriptReadOnly reports whether every tool sc may call is read-only.
+func (s *Server) scriptReadOnly(sc *Script) bool {
+	for _, name := range sc.Allow {
+		if tc, ok := s.toolCalls[name]; ok && (tc.tool.Annotations == nil || !tc.tool.Annotations.ReadOnlyHint) {
+			return false
+		}
+	}
+	return true
+}
+
+// script returns the configured script named name, or nil.
+func (s *Server) script(name string) *Script {
+	for _, sc := range s.scripts {
+		if sc.Name == name {
+			return sc
+		}
+	}
+	return nil
+}
+
+// --- script_<name> ---
+
+type ScriptInput struct {
+	Arguments map[string]any `json:"arguments,omitempty" jsonschema:"Arguments passed to the script, as its description documents"`
+}
+
+// registerScripts adds a tool for each configured script. It runs after
+// the other tools are registered, so their handlers are known.
+func (s *Server) registerScripts() {
+	for _, sc := range s.scripts {
+		annotations := mutatingAnnotations
+		if s.scriptReadOnly(sc) {
+			annotations = readOnlyAnnotations
+		}
+		t := &mcp.Tool{
+			Name:        scriptToolPrefix + sc.Name,
+			Description: sc.Description,
+			Annotations: annotations,
+		}
+		if t.Description == "" {
+			t.Description = fmt.Sprintf("Run the operator's %s script.", sc.Name)
+		}
+		addTool(s, t, func(ctx context.Context, _ *mcp.CallToolRequest, in ScriptInput) (*mcp.CallToolResult, any, error) {
+			out, err := s.runScript(ctx, sc, map[string]any{"arguments": in.Arguments})
+			if err != nil {
+				return errorResult(fmt.Errorf("script %s: %w", sc.Name, err)), nil, nil
+			}
+			if out == "" {
+				out = fmt.Sprintf("Script %s finished", sc.Name)
+			}
+			return textResult(out), nil, nil
+		})
+	}
+}
+
+// scriptMessage is a line a script writes: a tool call or its result.
+type scriptMessage struct {
+	Call      string          `json:"call,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    *string         `json:"result,omitempty"`
+}
+
+// scriptReply answers a script's tool call.
+type scriptReply struct {
+	Text    string `json:"text"`
+	IsError bool   `json:"isError"`
+}
+
+// runScript runs sc with input as its first line, serves its tool calls,
+// and returns its result.
+func (s *Server) runScript(ctx context.Context, sc *Script, input any) (string, error) {
+	timeout := defaultScriptTimeout
+	if sc.TimeoutSeconds > 0 {
+		timeout = time.Duration(sc.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, sc.Command[0], sc.Command[1:]...)
+	cmd.Env = append(os.Environ(), "JMAP_MCP_SCRIPT="+sc.Name)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	result, serveErr := s.serveScript(ctx, sc, input, stdin, stdout)
+	stdin.Close()
+	if serveErr != nil {
+		// Unread output would block the script; stop it.
+		cancel()
+	}
+	err = cmd.Wait()
+	if serveErr == nil && ctx.Err() != nil {
+		return "", fmt.Errorf("timed out after %s", timeout)
+	}
+	// A script failing before it reads its input breaks the pipe; its
+	// stderr tells why.
+	if serveErr != nil {
+		err = serveErr
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > maxScriptStderr {
+			msg = msg[:maxScriptStderr] + "…"
+		}
+		if msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return result, nil
+}
+
+// serveScript writes input to a started script and answers its calls
+// until it closes its output.
+func (s *Server) serveScript(ctx context.Context, sc *Script, input any, stdin io.Writer, stdout io.Reader) (string, error) {
+	enc := json.NewEncoder(stdin)
+	if err := enc.Encode(input); err != nil {
+		return "", fmt.Errorf("writing input: %w", err)
+	}
+	lines := bufio.NewScanner(stdout)
+	lines.Buffer(make([]byte, 0, 64<<10), maxScriptLineBytes)
+	var result string
+	calls := 0
+	for lines.Scan() {
+		line := bytes.TrimSpace(lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg scriptMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return "", fmt.Errorf("invalid output line: %w", err)
+		}
+		if msg.Result != nil {
+			result = *msg.Result
+			continue
+		}
+		if msg.Call == "" {
+			return "", fmt.Errorf("output line has neither call nor result")
+		}
+		if calls++; calls > maxScriptCalls {
+			return "", fmt.Errorf("more than %d tool calls", maxScriptCalls)
+		}
+		if err := enc.Encode(s.scriptCall(ctx, sc, msg.Call, msg.Arguments)); err != nil {
+			return "", fmt.Errorf("writing reply to %s: %w", msg.Call, err)
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return "", fmt.Errorf("reading output: %w", err)
+	}
+	return result, nil
+}
+
+// scriptCall runs the tool call a script asked for.
+func (s *Server) scriptCall(ctx context.Context, sc *Script, name string, args json.RawMessage) scriptReply {
+	tc, ok := s.toolCalls[name]
+	if !ok {
+		return scriptReply{Text: fmt.Sprintf("no tool %q", name), IsError: true}
+	}
+	if !sc.allows(tc.tool) {
+		return scriptReply{Text: fmt.Sprintf("script %s may not call %s", sc.Name, name), IsError: true}
+	}
+	res, err := tc.call(ctx, args)
+	if err != nil {
+		return scriptReply{Text: err.Error(), IsError: true}
+	}
+	var text []string
+	for _, c := range res.Content {
+		if t, ok := c.(*mcp.TextContent); ok {
+			text = append(text, t.Text)
+		}
+	}
+	return scriptReply{Text: strings.Join(text, "\n"), IsError: res.IsError}
+}
@@ -0,0 +1,130 @@
+package jmapmcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeScript writes an sh script to dir and returns its command.
+func writeScript(t *testing.T, dir, body string) []string {
+	t.Helper()
+	path := filepath.Join(dir, "script.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return []string{"/bin/sh", path}
+}
+
+func TestScriptToolCallsTools(t *testing.T) {
+	dir := t.TempDir()
+	cmd := writeScript(t, dir, `read input
+printf '%s\n' "$input" > "`+dir+`/input"
+echo '{"call": "mailbox_get", "arguments": {}}'
+read reply
+printf '%s\n' "$reply" > "`+dir+`/read"
+echo '{"call": "email_flag", "arguments": {"email_ids": ["e1"], "flagged": true}}'
+read reply
+printf '%s\n' "$reply" > "`+dir+`/write"
+echo '{"result": "done"}'
+`)
+	s, fake := newFakeServer(t, WithScripts([]Script{{Name: "probe", Command: cmd}}))
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	addEmail(fake, "e1", "Hello", "", 1)
+
+	tc, ok := s.toolCalls["script_probe"]
+	if !ok {
+		t.Fatal("script_probe not registered")
+	}
+	if !tc.tool.Annotations.ReadOnlyHint {
+		t.Error("script without allow list is not read-only")
+	}
+	res, err := tc.call(context.Background(), []byte(`{"arguments": {"days": 7}}`))
+	if err != nil || res.IsError {
+		t.Fatalf("script_probe = %v, %s", err, resultText(res))
+	}
+	if out := resultText(res); out != "done" {
+		t.Errorf("result = %q", out)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if in := read("input"); !strings.Contains(in, `"days":7`) {
+		t.Errorf("input = %s", in)
+	}
+	if r := read("read"); !strings.Contains(r, "Inbox") || strings.Contains(r, `"isError":true`) {
+		t.Errorf("mailbox_get reply = %s", r)
+	}
+	if r := read("write"); !strings.Contains(r, "may not call email_flag") || !strings.Contains(r, `"isError":true`) {
+		t.Errorf("email_flag reply = %s", r)
+	}
+	if kw, _ := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["$flagged"] == true {
+		t.Error("read-only script flagged an email")
+	}
+}
+
+func TestScriptAllowList(t *testing.T) {
+	cmd := writeScript(t, t.TempDir(), `read input
+echo '{"call": "email_flag", "arguments": {"email_ids": ["e1"], "flagged": true}}'
+read reply
+echo '{"call": "script_flagger", "arguments": {}}'
+read reply
+case "$reply" in *'"isError":true'*) ;; *) exit 3 ;; esac
+`)
+	s, fake := newFakeServer(t, WithScripts([]Script{{Name: "flagger", Command: cmd, Allow: []string{"email_flag", "script_flagger"}}}))
+	addEmail(fake, "e1", "Hello", "", 1)
+
+	tc := s.toolCalls["script_flagger"]
+	if tc.tool.Annotations.ReadOnlyHint {
+		t.Error("script allowed email_flag is read-only")
+	}
+	res, err := tc.call(context.Background(), nil)
+	if err != nil || res.IsError {
+		t.Fatalf("script_flagger = %v, %s", err, resultText(res))
+	}
+	if out := resultText(res); out != "Script flagger finished" {
+		t.Errorf("result = %q", out)
+	}
+	if kw, _ := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["$flagged"] != true {
+		t.Error("allowed email_flag did not flag")
+	}
+}
+
+func TestScriptFailure(t *testing.T) {
+	cmd := writeScript(t, t.TempDir(), `echo 'no token' >&2
+exit 1
+`)
+	s, _ := newFakeServer(t, WithScripts([]Script{{Name: "broken", Command: cmd}}))
+	res, _ := s.toolCalls["script_broken"].call(context.Background(), nil)
+	if out := resultText(res); !res.IsError || !strings.Contains(out, "no token") {
+		t.Errorf("failing script = %s", out)
+	}
+}
+
+func TestLoadScripts(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		json string
+		ok   bool
+	}{
+		{`[{"name": "triage", "command": ["/bin/true"], "allow": ["email_move"]}]`, true},
+		{`[{"name": "Triage", "command": ["/bin/true"]}]`, false},
+		{`[{"name": "triage"}]`, false},
+		{`[{"name": "a", "command": ["x"]}, {"name": "a", "command": ["y"]}]`, false},
+	} {
+		path := filepath.Join(dir, "scripts.json")
+		if err := os.WriteFile(path, []byte(tc.json), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadScripts(path); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.json, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Selections are named lists of email IDs held for an MCP session, so bulk
+// tools can be handed "selection": "name" instead of the same hundreds of
+// IDs on every call. They belong to the session and tenant that made them
+// (calls outside a session share one set per tenant) and to the endpoint
+// whose IDs they hold, and are dropped when the session ends.
+
+const (
+	maxSelections       = 50    // per session
+	maxSelectionEntries = 10000 // IDs per selection
+)
+
+// selection is a named list of email IDs.
+type selection struct {
+	endpoint string
+	ids      []string
+}
+
+type selectionKey struct {
+	callSession
+	name string
+}
+
+type selections struct {
+	mu       sync.Mutex
+	m        map[selectionKey]*selection
+	sessions map[*mcp.ServerSession]bool // sessions whose end is awaited
+}
+
+// callSession is whose session state a call reaches: its MCP session, nil
+// outside one, and the caller's tenant, so neither calls outside a session
+// nor a session reached with another user's credential share state.
+type callSession struct {
+	session *mcp.ServerSession
+	owner   string
+}
+
+// callSessionOf returns the session state key of the call req.
+func (s *Server) callSessionOf(ctx context.Context, req *mcp.CallToolRequest) callSession {
+	cs := callSession{owner: s.idOwner(ctx)}
+	if req != nil {
+		cs.session = req.Session
+	}
+	return cs
+}
+
+// put stores ids under name, replacing or (add) extending an existing
+// selection, and returns the selection's size. Adding to a selection of
+// another endpoint is refused.
+func (r *selections) put(cs callSession, name, endpoint string, ids []string, add bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m == nil {
+		r.m = make(map[selectionKey]*selection)
+		r.sessions = make(map[*mcp.ServerSession]bool)
+	}
+	key := selectionKey{cs, name}
+	sel := r.m[key]
+	if sel == nil || !add {
+		if sel == nil && r.count(cs) >= maxSelections {
+			return 0, invalidArgument("this session already holds %d selections; clear some with selection_clear", maxSelections)
+		}
+		sel = &selection{endpoint: endpoint}
+	} else if sel.endpoint != endpoint {
+		return 0, invalidArgument("selection %q holds IDs of endpoint %q, not %q", name, sel.endpoint, endpoint)
+	}
+
+	merged := slices.Clone(sel.ids)
+	seen := make(map[string]bool, len(merged)+len(ids))
+	for _, id := range merged {
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			merged = append(merged, id)
+		}
+	}
+	if len(merged) > maxSelectionEntries {
+		return 0, invalidArgument("selection %q would hold %d email IDs; the limit is %d", name, len(merged), maxSelectionEntries)
+	}
+	sel.ids = merged
+	r.m[key] = sel
+
+	if ss := cs.session; ss != nil && !r.sessions[ss] {
+		r.sessions[ss] = true
+		go func() {
+			ss.Wait()
+			r.drop(ss)
+		}()
+	}
+	return len(merged), nil
+}
+
+// count returns how many selections cs holds. The caller holds r.mu.
+func (r *selections) count(cs callSession) int {
+	n := 0
+	for k := range r.m {
+		if k.callSession == cs {
+			n++
+		}
+	}
+	return n
+}
+
+// get returns a copy of the selection name of cs.
+func (r *selections) get(cs callSession, name string) (selection, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sel, ok := r.m[selectionKey{cs, name}]
+	if !ok {
+		return selection{}, false
+	}
+	return selection{endpoint: sel.endpoint, ids: slices.Clone(sel.ids)}, true
+}
+
+// clear removes the selection name of cs, or all of them when name is
+// empty, and returns the names removed.
+func (r *selections) clear(cs callSession, name string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for k := range r.m {
+		if k.callSession == cs && (name == "" || k.name == name) {
+			names = append(names, k.name)
+			delete(r.m, k)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// drop forgets every selection of an ended session.
+func (r *selections) drop(ss *mcp.ServerSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k := range r.m {
+		if k.session == ss {
+			delete(r.m, k)
+		}
+	}
+	delete(r.sessions, ss)
+}
+
+// selectedEmailIDs returns the email IDs a bulk call names: ids as given,
+// or those of the named selection. Naming both is refused, as is a
+// selection made on another endpoint.
+func (s *Server) selectedEmailIDs(ctx context.Context, req *mcp.CallToolRequest, ids []string, name string) ([]string, error) {
+	if name == "" {
+		return ids, nil
+	}
+	if len(ids) > 0 {
+		return nil, invalidArgument("give email_ids or selection, not both")
+	}
+	sel, ok := s.selections.get(s.callSessionOf(ctx, req), name)
+	if !ok {
+		return nil, &toolError{Code: codeNotFound, Message: fmt.Sprintf("no selection %q in this session; create it with selection_set", name)}
+	}
+	if ep := endpointName(ctx); sel.endpoint != ep {
+		return nil, invalidArgument("selection %q holds IDs of endpoint %q; pass endpoint=%q", name, sel.endpoint, sel.endpoint)
+	}
+	return sel.ids, nil
+}
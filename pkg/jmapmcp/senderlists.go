@@ -0,0 +1,252 @@
+package jmapmcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	netmail "net/mail"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+// Sender lists kept per JMAP user. Entries are addresses, or "*@domain"
+// for a whole domain.
+const (
+	// vipList holds VIPs (vip_add): email_digest, email_triage_suggest,
+	// and watches put their mail first, and email_query can search it
+	// alone.
+	vipList = "vip"
+	// mutedList holds muted senders (sender_mute), hidden from
+	// email_query results unless asked for.
+	mutedList = "muted"
+
+	// maxSenderListEntries caps each list, so it can be expanded into one
+	// Email/query filter.
+	maxSenderListEntries = 100
+)
+
+// SenderLists holds each JMAP user's sender lists, keyed by the session
+// username. When it has a store, the lists are saved there as JSON after
+// every change so they survive restarts; the zero value keeps them in
+// memory.
+type SenderLists struct {
+	mu    sync.Mutex
+	store store.Store
+	doc   string                         // the store's document name
+	lists map[string]map[string][]string // list → username → sorted, lowercased entries
+}
+
+// LoadSenderLists reads the sender lists saved in st as doc, a JSON
+// object of the form {"vip": {"user": ["entry", ...]}, "muted": {...}}. A
+// missing document is an empty store, created on the first change.
+func LoadSenderLists(st store.Store, doc string) (*SenderLists, error) {
+	l := &SenderLists{store: st, doc: doc, lists: make(map[string]map[string][]string)}
+	data, _, err := st.Read(doc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sender lists: %w", err)
+	}
+	var file map[string]map[string][]string
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("sender lists %s: %w", doc, err)
+	}
+	for name, users := range file {
+		if name != vipList && name != mutedList {
+			return nil, fmt.Errorf("sender lists %s: unknown list %q", doc, name)
+		}
+		l.lists[name] = make(map[string][]string, len(users))
+		for user, entries := range users {
+			normalized := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				entry, err := normalizeSender(entry)
+				if err != nil {
+					return nil, fmt.Errorf("sender lists %s: %s of %s: %w", doc, name, user, err)
+				}
+				normalized = append(normalized, entry)
+			}
+			slices.Sort(normalized)
+			l.lists[name][user] = slices.Compact(normalized)
+		}
+	}
+	return l, nil
+}
+
+// list returns user's entries of the named list, sorted. The result must
+// not be modified.
+func (l *SenderLists) list(name, user string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lists[name][user]
+}
+
+// add adds entries to user's named list and saves the store, returning the
+// entries that were not already listed. Nothing changes when an entry is
+// invalid, the list would grow past maxSenderListEntries, or the store
+// cannot be saved.
+func (l *SenderLists) add(name, user string, entries []string) ([]string, error) {
+	normalized, err := normalizeSenders(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current := l.lists[name][user]
+	var added []string
+	for _, entry := range normalized {
+		if !slices.Contains(current, entry) && !slices.Contains(added, entry) {
+			added = append(added, entry)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	if len(current)+len(added) > maxSenderListEntries {
+		return nil, invalidArgument("the %s list would have %d entries, more than the limit of %d", name, len(current)+len(added), maxSenderListEntries)
+	}
+	return added, l.replace(name, user, slices.Sorted(slices.Values(append(slices.Clone(current), added...))))
+}
+
+// remove removes entries from user's named list and saves the store,
+// returning the entries that were listed.
+func (l *SenderLists) remove(name, user string, entries []string) ([]string, error) {
+	normalized, err := normalizeSenders(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current := l.lists[name][user]
+	var removed []string
+	next := slices.DeleteFunc(slices.Clone(current), func(entry string) bool {
+		if slices.Contains(normalized, entry) {
+			removed = append(removed, entry)
+			return true
+		}
+		return false
+	})
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, l.replace(name, user, next)
+}
+
+// replace sets user's named list to entries and saves the store, restoring
+// the previous list when saving fails. The caller holds l.mu.
+func (l *SenderLists) replace(name, user string, entries []string) error {
+	if l.lists == nil {
+		l.lists = make(map[string]map[string][]string)
+	}
+	if l.lists[name] == nil {
+		l.lists[name] = make(map[string][]string)
+	}
+	previous, existed := l.lists[name][user]
+	if len(entries) == 0 {
+		delete(l.lists[name], user)
+	} else {
+		l.lists[name][user] = entries
+	}
+	if err := l.save(); err != nil {
+		if existed {
+			l.lists[name][user] = previous
+		} else {
+			delete(l.lists[name], user)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the store to its document. The caller holds l.mu.
+func (l *SenderLists) save() error {
+	if l.store == nil {
+		return nil
+	}
+	if _, err := store.WriteJSON(l.store, l.doc, l.lists); err != nil {
+		return fmt.Errorf("saving sender lists: %w", err)
+	}
+	return nil
+}
+
+func normalizeSenders(entries []string) ([]string, error) {
+	normalized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry, err := normalizeSender(entry)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, entry)
+	}
+	return normalized, nil
+}
+
+// normalizeSender validates a sender list entry and lowercases it.
+// "@domain" is accepted for "*@domain".
+func normalizeSender(entry string) (string, error) {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if domain, ok := strings.CutPrefix(strings.TrimPrefix(entry, "*"), "@"); ok {
+		if domain == "" || strings.ContainsAny(domain, "@ ") || !strings.Contains(domain, ".") {
+			return "", invalidArgument("invalid sender domain %q", entry)
+		}
+		return "*@" + domain, nil
+	}
+	a, err := netmail.ParseAddress(entry)
+	if err != nil || a.Address != entry {
+		return "", invalidArgument("invalid sender address %q (use an address or *@domain)", entry)
+	}
+	return entry, nil
+}
+
+// senderListed reports whether any of addrs matches one of entries.
+func senderListed(addrs []*mail.Address, entries []string) bool {
+	if len(entries) == 0 {
+		return false
+	}
+	for _, a := range addrs {
+		if matchesAddressPattern(strings.ToLower(strings.TrimSpace(a.Email)), entries) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromAnyFilter expands sender list entries into a filter matching mail
+// from any of them; "*@domain" matches on "@domain", and internationalized
+// domains in either form.
+func fromAnyFilter(entries []string) email.Filter {
+	var addrs []string
+	for _, entry := range entries {
+		addrs = append(addrs, addressForms(strings.TrimPrefix(entry, "*"))...)
+	}
+	return addressFilter(addrs, func(a string) *email.FilterCondition { return &email.FilterCondition{From: a} })
+}
+
+// addressFilter matches any of addrs with the condition cond makes for one.
+func addressFilter(addrs []string, cond func(addr string) *email.FilterCondition) email.Filter {
+	conds := make([]email.Filter, 0, len(addrs))
+	for _, a := range addrs {
+		conds = append(conds, cond(a))
+	}
+	return &email.FilterOperator{Operator: jmap.OperatorOR, Conditions: conds}
+}
+
+// vipsOf returns the VIP entries of client's user.
+func (s *Server) vipsOf(client JMAPClient) []string {
+	return s.senders.list(vipList, client.Session().Username)
+}
+
+// mutedOf returns the muted senders of client's user.
+func (s *Server) mutedOf(client JMAPClient) []string {
+	return s.senders.list(mutedList, client.Session().Username)
+}
@@ -0,0 +1,120 @@
+package jmapmcp
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+func TestSenderListsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "senders.json")
+	l, err := LoadSenderLists(store.Dir(""), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := l.add(vipList, "me@example.org", []string{"Boss@Example.org", "@client.example", "boss@example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"boss@example.org", "*@client.example"}; !slices.Equal(added, want) {
+		t.Errorf("added = %q, want %q", added, want)
+	}
+	if _, err := l.add(vipList, "me@example.org", []string{"not an address"}); err == nil {
+		t.Error("invalid entry accepted")
+	}
+
+	if _, err := l.add(mutedList, "me@example.org", []string{"spam@example.net"}); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := l.remove(mutedList, "me@example.org", []string{"Spam@example.net", "other@example.net"}); err != nil || !slices.Equal(removed, []string{"spam@example.net"}) {
+		t.Errorf("removed = %q, %v", removed, err)
+	}
+
+	reloaded, err := LoadSenderLists(store.Dir(""), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reloaded.list(vipList, "me@example.org"), []string{"*@client.example", "boss@example.org"}; !slices.Equal(got, want) {
+		t.Errorf("reloaded list = %q, want %q", got, want)
+	}
+	if got := reloaded.list(vipList, "other@example.org"); len(got) != 0 {
+		t.Errorf("other user's list = %q, want empty", got)
+	}
+	if got := reloaded.list(mutedList, "me@example.org"); len(got) != 0 {
+		t.Errorf("muted list = %q, want empty after unmuting", got)
+	}
+}
+
+func TestVIPWeighting(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "archive", "name": "Archive", "role": "archive"})
+	addEmail(fake, "old1", "Update", "", 1)
+	addEmail(fake, "old2", "Update", "", 2)
+	for _, id := range []string{"old1", "old2"} {
+		e := fake.Object("Email", id)
+		e["from"] = []any{map[string]any{"email": "ceo@corp.example"}}
+		e["mailboxIds"] = map[string]any{"archive": true}
+		fake.Add("Email", e)
+	}
+	addEmail(fake, "news", "Newsletter", "", 6)
+	addEmail(fake, "ceo", "Board meeting", "", 5)
+	e := fake.Object("Email", "ceo")
+	e["from"] = []any{map[string]any{"name": "CEO", "email": "ceo@corp.example"}}
+	fake.Add("Email", e)
+
+	res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{VIPOnly: true})
+	if !res.IsError {
+		t.Errorf("vip_only with an empty list accepted:\n%s", resultText(res))
+	}
+	res, _, _ = s.handleVIPAdd(ctx, nil, VIPAddInput{Addresses: []string{"*@corp.example"}})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Added 1 VIP(s): *@corp.example") {
+		t.Fatalf("vip_add:\n%s", out)
+	}
+	res, _, _ = s.handleVIPList(ctx, nil, VIPListInput{})
+	if out := resultText(res); !strings.Contains(out, "  *@corp.example") {
+		t.Errorf("vip_list:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{VIPOnly: true, MailboxID: "inbox"})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Board meeting") || strings.Contains(out, "Newsletter") {
+		t.Errorf("email_query vip_only:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailDigest(ctx, nil, EmailDigestInput{Since: "2025-01-04"})
+	if out := resultText(res); !strings.Contains(out, ", 1 from VIPs") || !strings.Contains(out, "From VIPs:\n  ceo  ") {
+		t.Errorf("email_digest:\n%s", out)
+	}
+
+	// Earlier mail from the CEO was archived, but a VIP is kept and listed
+	// first.
+	res, _, _ = s.handleEmailTriageSuggest(ctx, nil, EmailTriageSuggestInput{EmailIDs: []string{"news", "ceo"}})
+	out := resultText(res)
+	if !strings.HasPrefix(out, "ceo  CEO <ceo@corp.example>  Board meeting  [VIP]\n  Suggest: keep in inbox — sender is a VIP, though 2 of 2") {
+		t.Errorf("email_triage_suggest:\n%s", out)
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _, err := watchBaseline(ctx, client, jmapfake.AccountID, "inbox", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addEmail(fake, "ceo2", "Follow-up", "", 7)
+	e = fake.Object("Email", "ceo2")
+	e["from"] = []any{map[string]any{"email": "ceo@corp.example"}}
+	fake.Add("Email", e)
+	w := &watch{ID: "watch-1", AccountID: jmapfake.AccountID, MailboxID: "inbox"}
+	events, _, _, err := checkWatch(ctx, client, w, state, nil, s.vipsOf(client))
+	if err != nil || len(events) != 1 || !slices.Equal(events[0].VIP, events[0].EmailIDs) {
+		t.Errorf("watch events = %+v, %v; want ceo2 flagged VIP", events, err)
+	}
+}
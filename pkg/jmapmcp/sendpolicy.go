@@ -0,0 +1,180 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/identity"
+)
+
+// SendPolicy holds operator guardrails for outgoing mail. Zero values
+// disable the corresponding rule.
+type SendPolicy struct {
+	AllowedDomains   []string // recipient domains allowed (subdomains included); empty allows all
+	BlockedAddresses []string // recipients always refused; "*@domain" blocks a whole domain
+	MaxRecipients    int      // max To+CC+BCC per message
+	MaxSendsPerHour  int      // max submissions per JMAP credential in a sliding hour
+	IdentityDomains  []string // sender identity domains allowed (subdomains included); empty allows all
+}
+
+// sendPolicy enforces a SendPolicy. A nil *sendPolicy permits everything.
+type sendPolicy struct {
+	SendPolicy
+
+	mu    sync.Mutex
+	sends map[string][]time.Time // owner → submission times within the window
+}
+
+func newSendPolicy(p SendPolicy) *sendPolicy {
+	return &sendPolicy{SendPolicy: p, sends: make(map[string][]time.Time)}
+}
+
+// checkRecipients validates a message's recipients against the recipient
+// count, blocklist, and domain allowlist rules.
+func (p *sendPolicy) checkRecipients(recipients []*mail.Address) error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxRecipients > 0 && len(recipients) > p.MaxRecipients {
+		return &policyError{Policy: "send", Rule: "max_recipients",
+			Detail: fmt.Sprintf("%d recipients exceeds the limit of %d", len(recipients), p.MaxRecipients)}
+	}
+	for _, a := range recipients {
+		addr := strings.ToLower(strings.TrimSpace(a.Email))
+		if matchesAddressPattern(addr, p.BlockedAddresses) {
+			return &policyError{Policy: "send", Rule: "blocked_address",
+				Detail: fmt.Sprintf("recipient %s is blocked", a.Email)}
+		}
+		if len(p.AllowedDomains) > 0 && !domainAllowed(addr, p.AllowedDomains) {
+			return &policyError{Policy: "send", Rule: "allowed_domains",
+				Detail: fmt.Sprintf("recipient %s is outside the allowed domains (%s)", a.Email, strings.Join(p.AllowedDomains, ", "))}
+		}
+	}
+	return nil
+}
+
+// senderIdentity returns the identity a message is sent with when none is
+// named: the first of identities, or under the identity domain rule the
+// first within those domains. It returns nil when there are no identities,
+// and refuses when none is allowed.
+func (p *sendPolicy) senderIdentity(identities []*identity.Identity) (*identity.Identity, error) {
+	if p == nil || len(p.IdentityDomains) == 0 || len(identities) == 0 {
+		return firstIdentity(identities), nil
+	}
+	for _, id := range identities {
+		if p.checkSender(id.Email) == nil {
+			return id, nil
+		}
+	}
+	return nil, &policyError{Policy: "send", Rule: "identity_domains",
+		Detail: fmt.Sprintf("no sender identity is in the allowed domains (%s)", strings.Join(p.IdentityDomains, ", "))}
+}
+
+// checkSender refuses sending from addr, an identity's or a From address,
+// outside the identity domains.
+func (p *sendPolicy) checkSender(addr string) error {
+	if p == nil || len(p.IdentityDomains) == 0 {
+		return nil
+	}
+	if !domainAllowed(strings.ToLower(strings.TrimSpace(addr)), p.IdentityDomains) {
+		return &policyError{Policy: "send", Rule: "identity_domains",
+			Detail: fmt.Sprintf("sender %s is outside the allowed identity domains (%s)", addr, strings.Join(p.IdentityDomains, ", "))}
+	}
+	return nil
+}
+
+// reserveSend records a submission attempt by owner at now, refusing it when
+// the hourly limit is already reached. Attempts count even if the JMAP
+// server later rejects them.
+func (p *sendPolicy) reserveSend(owner string, now time.Time) error {
+	if p == nil || p.MaxSendsPerHour <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cutoff := now.Add(-time.Hour)
+	recent := p.sends[owner][:0]
+	for _, t := range p.sends[owner] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= p.MaxSendsPerHour {
+		p.sends[owner] = recent
+		retry := recent[0].Add(time.Hour).Sub(now).Round(time.Second)
+		return &policyError{Policy: "send", Rule: "max_sends_per_hour",
+			Detail: fmt.Sprintf("limit of %d sends per hour reached; retry in %s", p.MaxSendsPerHour, retry)}
+	}
+	p.sends[owner] = append(recent, now)
+	return nil
+}
+
+// releaseSend forgets the attempt reserveSend recorded for owner at, when
+// it turned out never to reach the JMAP server.
+func (p *sendPolicy) releaseSend(owner string, at time.Time) {
+	if p == nil || p.MaxSendsPerHour <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := slices.IndexFunc(p.sends[owner], at.Equal); i >= 0 {
+		p.sends[owner] = slices.Delete(p.sends[owner], i, i+1)
+	}
+}
+
+// remaining returns how many more submissions owner may make in the hour
+// before now, or -1 when the rate is unlimited.
+func (p *sendPolicy) remaining(owner string, now time.Time) int {
+	if p == nil || p.MaxSendsPerHour <= 0 {
+		return -1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cutoff := now.Add(-time.Hour)
+	n := 0
+	for _, t := range p.sends[owner] {
+		if t.After(cutoff) {
+			n++
+		}
+	}
+	return max(p.MaxSendsPerHour-n, 0)
+}
+
+// matchesAddressPattern reports whether addr (lowercased) equals one of
+// patterns or falls under a "*@domain" pattern, comparing internationalized
+// domains in ASCII form.
+func matchesAddressPattern(addr string, patterns []string) bool {
+	addr = asciiAddress(addr)
+	for _, pattern := range patterns {
+		pattern = asciiAddress(strings.ToLower(pattern))
+		if pattern == addr {
+			return true
+		}
+		if domain, ok := strings.CutPrefix(pattern, "*@"); ok && strings.HasSuffix(addr, "@"+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainAllowed reports whether addr's domain is one of domains or a
+// subdomain of one.
+func domainAllowed(addr string, domains []string) bool {
+	_, domain, ok := strings.Cut(addr, "@")
+	if !ok {
+		return false
+	}
+	domain = asciiDomain(domain)
+	for _, d := range domains {
+		d = asciiDomain(strings.ToLower(strings.TrimPrefix(d, "@")))
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
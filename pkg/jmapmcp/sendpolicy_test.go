@@ -0,0 +1,129 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/identity"
+)
+
+func TestSendPolicyCheckRecipients(t *testing.T) {
+	p := newSendPolicy(SendPolicy{
+		AllowedDomains:   []string{"example.com"},
+		BlockedAddresses: []string{"ceo@example.com", "*@blocked.example.com"},
+		MaxRecipients:    2,
+	})
+
+	tests := []struct {
+		name       string
+		recipients []string
+		wantRule   string
+	}{
+		{"allowed", []string{"a@example.com"}, ""},
+		{"allowed subdomain", []string{"a@eu.example.com"}, ""},
+		{"outside allowlist", []string{"a@other.org"}, "allowed_domains"},
+		{"lookalike domain", []string{"a@notexample.com"}, "allowed_domains"},
+		{"blocked address", []string{"CEO@example.com"}, "blocked_address"},
+		{"blocked domain", []string{"x@blocked.example.com"}, "blocked_address"},
+		{"too many", []string{"a@example.com", "b@example.com", "c@example.com"}, "max_recipients"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.checkRecipients(toMailAddresses(tt.recipients))
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var pe *policyError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got %v, want policy error", err)
+			}
+			if pe.Rule != tt.wantRule {
+				t.Errorf("rule = %q, want %q", pe.Rule, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestSendPolicyNil(t *testing.T) {
+	var p *sendPolicy
+	if err := p.checkRecipients([]*mail.Address{{Email: "anyone@anywhere"}}); err != nil {
+		t.Errorf("nil policy refused recipients: %v", err)
+	}
+	if err := p.reserveSend("owner", time.Now()); err != nil {
+		t.Errorf("nil policy refused send: %v", err)
+	}
+}
+
+func TestSendPolicyReserveSend(t *testing.T) {
+	p := newSendPolicy(SendPolicy{MaxSendsPerHour: 2})
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for i := range 2 {
+		if err := p.reserveSend("alice", start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if err := p.reserveSend("alice", start.Add(30*time.Minute)); err == nil {
+		t.Error("third send within the hour allowed")
+	}
+	if err := p.reserveSend("bob", start.Add(30*time.Minute)); err != nil {
+		t.Errorf("bob limited by alice's quota: %v", err)
+	}
+	if err := p.reserveSend("alice", start.Add(61*time.Minute)); err != nil {
+		t.Errorf("send after the window slid: %v", err)
+	}
+}
+
+func TestSendPolicyIdentityDomains(t *testing.T) {
+	p := newSendPolicy(SendPolicy{IdentityDomains: []string{"work.example"}})
+	personal := &identity.Identity{ID: "i1", Email: "me@home.example"}
+	work := &identity.Identity{ID: "i2", Email: "me@eu.work.example"}
+
+	if id, err := p.senderIdentity([]*identity.Identity{personal, work}); err != nil || id != work {
+		t.Errorf("senderIdentity = %v, %v, want the work identity", id, err)
+	}
+	var pe *policyError
+	if _, err := p.senderIdentity([]*identity.Identity{personal}); !errors.As(err, &pe) || pe.Rule != "identity_domains" {
+		t.Errorf("senderIdentity with no allowed identity = %v", err)
+	}
+	if err := p.checkSender("Me@Work.Example"); err != nil {
+		t.Errorf("checkSender refused an allowed sender: %v", err)
+	}
+	if err := p.checkSender(personal.Email); err == nil {
+		t.Error("checkSender allowed a sender outside the domains")
+	}
+	var none *sendPolicy
+	if id, err := none.senderIdentity([]*identity.Identity{personal, work}); err != nil || id != personal {
+		t.Errorf("nil policy senderIdentity = %v, %v, want the first", id, err)
+	}
+}
+
+func TestSubmissionIdentityDomains(t *testing.T) {
+	s, fake := newFakeServer(t, WithEmailSubmission(), WithSendPolicy(SendPolicy{IdentityDomains: []string{"work.example"}}))
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@home.example"})
+	fake.Add("Identity", map[string]any{"id": "i2", "email": "me@work.example"})
+	fake.Add("Email", map[string]any{
+		"id": "d1", "subject": "Hello", "to": []any{map[string]any{"email": "bob@example.com"}},
+		"mailboxIds": map[string]any{"drafts": true}, "keywords": map[string]any{"$draft": true},
+	})
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1", IdentityID: "i1"})
+	if te, _ := res.StructuredContent.(*toolError); !res.IsError || te == nil || te.Rule != "identity_domains" {
+		t.Fatalf("identity outside the domains not refused: %s", resultText(res))
+	}
+	if res, _, _ := s.handleEmailSubmissionSet(ctx, nil, EmailSubmissionSetInput{EmailID: "d1"}); res.IsError {
+		t.Fatalf("default identity refused: %s", resultText(res))
+	}
+	if sub := fake.Objects("EmailSubmission"); len(sub) != 1 || fake.Object("EmailSubmission", sub[0])["identityId"] != "i2" {
+		t.Errorf("submissions = %v, want one with identity i2", sub)
+	}
+}
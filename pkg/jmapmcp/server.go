@@ -0,0 +1,657 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	// Register JMAP method response types for deserialization.
+	_ "github.com/mikluko/jmap/mail/email"
+	_ "github.com/mikluko/jmap/mail/emailsubmission"
+	_ "github.com/mikluko/jmap/mail/identity"
+	_ "github.com/mikluko/jmap/mail/mailbox"
+	_ "github.com/mikluko/jmap/sieve/sievescript"
+)
+
+// Option configures optional Server behavior.
+type Option func(*Server)
+
+// WithToken sets a static JMAP auth token (used in stdio mode).
+func WithToken(token string) Option {
+	return func(s *Server) { s.token = token }
+}
+
+// WithEndpoint adds a named JMAP backend selectable per call. token is the
+// static token for stdio mode; empty reuses the default token.
+func WithEndpoint(name, sessionURL, token string) Option {
+	return func(s *Server) {
+		if s.endpoints == nil {
+			s.endpoints = make(map[string]*endpoint)
+		}
+		s.endpoints[name] = &endpoint{sessionURL: sessionURL, token: token}
+	}
+}
+
+// WithEmailSubmission enables the email_submission_set tool.
+func WithEmailSubmission() Option {
+	return func(s *Server) { s.enableEmailSubmission = true }
+}
+
+// WithSendQueue makes email_submission_set queue drafts in a local outbox
+// instead of submitting them, and enables the outbox_list, outbox_approve,
+// and outbox_reject tools. Only effective together with WithEmailSubmission.
+func WithSendQueue() Option {
+	return func(s *Server) { s.outbox = newOutbox() }
+}
+
+// WithAbuseAddress enables email_report_abuse, which sends reports to
+// address. Only effective together with WithEmailSubmission.
+func WithAbuseAddress(address string) Option {
+	return func(s *Server) { s.abuseAddress = address }
+}
+
+// WithPreSendHook has h review every outgoing message before it is
+// submitted (see PreSendHook).
+func WithPreSendHook(h PreSendHook) Option {
+	return func(s *Server) { s.preSend = h }
+}
+
+// WithDestructiveConfirmation makes permanent email deletion and mailbox
+// destruction two-phase: the first call returns a summary and a
+// confirmation token, and only a repeat call carrying the token acts.
+func WithDestructiveConfirmation() Option {
+	return func(s *Server) { s.confirmDestroy = true }
+}
+
+// WithMailMerge enables the mail_merge tool within p's bounds. Only
+// effective together with WithEmailSubmission; a BatchSize below 1 is 1.
+func WithMailMerge(p MailMergePolicy) Option {
+	return func(s *Server) {
+		p.BatchSize = max(p.BatchSize, 1)
+		s.mailMerge = &p
+	}
+}
+
+// WithSendPolicy enforces recipient and rate rules on email_create,
+// email_reply, and submissions (see SendPolicy).
+func WithSendPolicy(p SendPolicy) Option {
+	return func(s *Server) { s.sendPolicy = newSendPolicy(p) }
+}
+
+// WithAutoRecipients adds CC and BCC addresses to every draft composed by
+// email_create, email_reply, and email_forward. The sender identity's own
+// Reply-To and BCC are applied regardless.
+func WithAutoRecipients(r AutoRecipients) Option {
+	return func(s *Server) { s.autoRecipients = r }
+}
+
+// WithAttachmentPolicy restricts the types and sizes accepted by
+// attachment_upload, email_create attachments, and email_forward.
+func WithAttachmentPolicy(p AttachmentPolicy) Option {
+	return func(s *Server) { s.attachmentPolicy = newAttachmentPolicy(p) }
+}
+
+// WithAttachmentScan has sc's scanner check every attachment before
+// email_attachment_image, attachment_download, or email_attachment_url
+// hands out its content (see AttachmentScanner).
+func WithAttachmentScan(sc AttachmentScan) Option {
+	return func(s *Server) { s.scan = &sc }
+}
+
+// WithRedactor masks secrets in email bodies returned by email_get and
+// email_render (and sent to the translation endpoint) using r.
+func WithRedactor(r *Redactor) Option {
+	return func(s *Server) { s.redactor = r }
+}
+
+// WithLocalFiles lets tools read and write files on this machine inside the
+// MCP client's filesystem roots: attachment_download, and the path options
+// of attachment_upload, email_render, and thread_export. Use it only where
+// the server runs beside the client, as in stdio mode.
+func WithLocalFiles() Option {
+	return func(s *Server) { s.localFiles = true }
+}
+
+// WithSieve enables the sieve_get, sieve_set, sieve_validate, and
+// sieve_rule_learn tools.
+func WithSieve() Option {
+	return func(s *Server) { s.enableSieve = true }
+}
+
+// WithStalwartAdmin enables the stalwart_* tools, which call the Stalwart
+// management API with the caller's token. Calls fail cleanly on other
+// servers or for non-admin principals.
+func WithStalwartAdmin() Option {
+	return func(s *Server) { s.enableStalwartAdmin = true }
+}
+
+// WithLabels declares the backend label-based and enables the label_apply
+// and label_remove tools, which add and remove non-role mailbox membership
+// without disturbing Inbox/Archive.
+func WithLabels() Option {
+	return func(s *Server) { s.enableLabels = true }
+}
+
+// WithAttachmentURL enables the email_attachment_url tool and the
+// /attachments/ streaming endpoint (http mode only). secret seals URL claims;
+// empty means a random per-process key. externalURL overrides the
+// request-derived base URL when building download links.
+func WithAttachmentURL(secret string, externalURL string) Option {
+	return func(s *Server) {
+		urler, err := newAttachmentURLer(secret)
+		if err != nil {
+			// crypto/rand or AES setup failure is unrecoverable at startup
+			panic(fmt.Sprintf("attachment URL setup: %v", err))
+		}
+		s.attachmentURL = urler
+		s.externalURL = externalURL
+	}
+}
+
+// WithAttachmentURLTTL sets how long a signed attachment URL stays valid
+// (default DefaultAttachmentURLTTL). Durations outside (0,
+// MaxAttachmentURLTTL] are ignored.
+func WithAttachmentURLTTL(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 && d <= MaxAttachmentURLTTL {
+			s.attachmentURLTTL = d
+		}
+	}
+}
+
+// WithTranslator enables the translate option of email_get, which appends a
+// translation of each body produced by a LibreTranslate-compatible endpoint
+// at url. target is the ISO 639-1 language to translate into (default "en").
+func WithTranslator(url, apiKey, target string) Option {
+	return func(s *Server) { s.translator = newTranslator(url, apiKey, target) }
+}
+
+// WithClassifier enables email_classify and classify automations, which
+// sort messages into categories and record each as a category-<name>
+// keyword. url is an optional endpoint classifying messages for clients
+// without sampling, and for automations.
+func WithClassifier(categories []string, url string) Option {
+	return func(s *Server) { s.classifier = newClassifier(categories, url) }
+}
+
+// WithPDFRenderer enables PDF output of email_render. command is an external
+// program and its arguments that reads HTML on stdin and writes PDF to
+// stdout (e.g. "wkhtmltopdf --quiet - -").
+func WithPDFRenderer(command []string) Option {
+	return func(s *Server) { s.pdfRenderer = command }
+}
+
+// WithPGP decrypts and verifies PGP/MIME messages in email_get with p,
+// e.g. a PGPCommand.
+func WithPGP(p PGP) Option {
+	return func(s *Server) { s.pgp = p }
+}
+
+// WithMailboxSnapshots keeps mailbox_snapshot snapshots in m, e.g. one
+// loaded with LoadMailboxSnapshots (default: in memory only).
+func WithMailboxSnapshots(m *MailboxSnapshots) Option {
+	return func(s *Server) { s.mailboxSnapshots = m }
+}
+
+// WithSieveHistory keeps earlier Sieve script versions in h, e.g. one
+// loaded with LoadSieveHistory (default: in memory only).
+func WithSieveHistory(h *SieveHistory) Option {
+	return func(s *Server) { s.sieveHistory = h }
+}
+
+// WithSenderLists keeps the VIP and muted sender lists in l, e.g. one
+// loaded with LoadSenderLists (default: in memory only).
+func WithSenderLists(l *SenderLists) Option {
+	return func(s *Server) { s.senders = l }
+}
+
+// WithAutomations keeps the automations in a, e.g. one loaded with
+// LoadAutomations (default: in memory only).
+func WithAutomations(a *Automations) Option {
+	return func(s *Server) { s.automations = a }
+}
+
+// WithSchedules keeps the scheduled actions in sc, e.g. one loaded with
+// LoadSchedules (default: in memory only).
+func WithSchedules(sc *Schedules) Option {
+	return func(s *Server) { s.schedules = sc }
+}
+
+// WithJournals keeps the undo_last journals in j, e.g. one loaded with
+// LoadJournals (default: in memory, per MCP session).
+func WithJournals(j *Journals) Option {
+	return func(s *Server) { s.journals = j }
+}
+
+// WithScripts exposes scripts as script_<name> tools and automation
+// actions.
+func WithScripts(scripts []Script) Option {
+	return func(s *Server) {
+		for i := range scripts {
+			s.scripts = append(s.scripts, &scripts[i])
+		}
+	}
+}
+
+// WithQueryPresets exposes presets as read-only query_<name> tools.
+func WithQueryPresets(presets []QueryPreset) Option {
+	return func(s *Server) {
+		for i := range presets {
+			s.queryPresets = append(s.queryPresets, &presets[i])
+		}
+	}
+}
+
+// WithToolCosts overrides the latency class and timeout of the cost hints
+// in the named tools' metadata.
+func WithToolCosts(c ToolCosts) Option {
+	return func(s *Server) { s.toolCosts = c }
+}
+
+// WithTenantQuota bounds the results cached for each tenant (the holder of
+// a JMAP token) and how many tenants keep them (default
+// DefaultTenantCacheBytes and DefaultMaxTenants).
+func WithTenantQuota(q TenantQuota) Option {
+	return func(s *Server) { s.tenants.quota = q }
+}
+
+// WithDialer replaces how JMAP clients are opened, e.g. with an in-process
+// fake (internal/jmapfake) in handler tests.
+func WithDialer(d Dialer) Option {
+	return func(s *Server) { s.dialer = d }
+}
+
+// WithClientWrapper layers wrap over every JMAP client handed to tools
+// (e.g. caching, retries, instrumentation). Wrappers apply in the order
+// given, so the last one is outermost.
+func WithClientWrapper(wrap func(JMAPClient) JMAPClient) Option {
+	return func(s *Server) { s.clientWrappers = append(s.clientWrappers, wrap) }
+}
+
+// WithMaxFetchBytes bounds the bytes one tool call may read from the JMAP
+// server (0: unlimited; default DefaultMaxFetchBytes). Calls over the limit
+// fail with guidance to narrow the request.
+func WithMaxFetchBytes(n int64) Option {
+	return func(s *Server) { s.maxFetchBytes = n }
+}
+
+// WithQueryLimit sets the number of email_query results returned when a
+// call sets no limit (default DefaultQueryLimit).
+func WithQueryLimit(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.queryLimit = n
+		}
+	}
+}
+
+// WithMaxChars sets the email_get response budget used when a call sets no
+// max_chars (default DefaultMaxChars).
+func WithMaxChars(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxChars = n
+		}
+	}
+}
+
+// WithMaxBodyChars sets the per-email body cap email_get applies after
+// stripping quotes and signatures (default DefaultMaxBodyChars).
+func WithMaxBodyChars(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxBodyChars = n
+		}
+	}
+}
+
+// WithHeaderPatterns limits the headers email_get's full_headers returns to
+// those matching an include pattern, when there are any, and none of the
+// exclude patterns (e.g. DKIM-Signature, ARC-*). Calls can override them.
+func WithHeaderPatterns(include, exclude []string) Option {
+	return func(s *Server) {
+		s.headerFilter = headerFilter{Include: include, Exclude: exclude}
+	}
+}
+
+// WithImageThumbnails sets the longest side, in pixels, of images
+// email_attachment_image returns (default DefaultImageMaxDimension; 0
+// returns images as attached) and the JPEG quality, 1 to 100, of downscaled
+// ones (default DefaultImageJPEGQuality).
+func WithImageThumbnails(maxDimension, jpegQuality int) Option {
+	return func(s *Server) {
+		if maxDimension >= 0 {
+			s.imageMaxDimension = maxDimension
+		}
+		if jpegQuality >= 1 && jpegQuality <= 100 {
+			s.imageJPEGQuality = jpegQuality
+		}
+	}
+}
+
+// WithResendWindow sets how long after submitting an email a second
+// submission of it is refused without resend (default DefaultResendWindow;
+// 0 disables the check).
+func WithResendWindow(d time.Duration) Option {
+	return func(s *Server) {
+		if d >= 0 {
+			s.resendWindow = d
+		}
+	}
+}
+
+// WithWatchPollInterval sets how often watches poll Email/changes when the
+// server offers no push (default DefaultWatchPollInterval; 0 makes
+// watch_create require push).
+func WithWatchPollInterval(d time.Duration) Option {
+	return func(s *Server) {
+		if d >= 0 {
+			s.watchPollInterval = d
+		}
+	}
+}
+
+// WithWebSocket sets whether method calls and watch push use JMAP over
+// WebSocket (RFC 8887) when the session advertises it (default true).
+func WithWebSocket(enabled bool) Option {
+	return func(s *Server) { s.noWebSocket = !enabled }
+}
+
+// WithLocale sets how tool outputs write dates and sizes (default
+// LocaleDefault).
+func WithLocale(l Locale) Option {
+	return func(s *Server) { s.locale.Locale = l }
+}
+
+// WithStructuredOutput makes email_query, email_get, identity_get, and
+// thread_participants also return structured content with addresses as
+// {name, email} objects.
+func WithStructuredOutput() Option {
+	return func(s *Server) { s.structuredOutput = true }
+}
+
+// WithDateFormat sets how tool outputs write dates and times (default
+// DateFormatLocale), and whether each carries its age, such as "3h ago".
+func WithDateFormat(f DateFormat, relative bool) Option {
+	return func(s *Server) { s.locale.dates, s.locale.relative = f, relative }
+}
+
+// WithDebugJMAP records the raw JMAP method calls and responses of tool
+// calls and logs them or attaches them to results (default DebugJMAPOff).
+func WithDebugJMAP(mode DebugJMAP) Option {
+	return func(s *Server) { s.debugJMAP = mode }
+}
+
+// Dialer opens an authenticated JMAP client for a session URL and token.
+type Dialer interface {
+	Dial(ctx context.Context, sessionURL, token string) (*jmap.Client, error)
+}
+
+// httpDialer fetches the session over HTTP with the token as a bearer
+// credential.
+type httpDialer struct{}
+
+func (httpDialer) Dial(ctx context.Context, sessionURL, token string) (*jmap.Client, error) {
+	client := (&jmap.Client{SessionEndpoint: sessionURL}).WithAccessToken(token)
+	// Authenticate takes no context: bind the session fetch to the call's,
+	// so a cancelled call does not wait on a slow server.
+	hc := client.HttpClient
+	bound := http.Client{}
+	if hc != nil {
+		bound = *hc
+	}
+	base := bound.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	bound.Transport = fallbackContextTransport{base: base, ctx: ctx}
+	client.HttpClient = &bound
+	err := client.Authenticate()
+	client.HttpClient = hc
+	if err != nil {
+		return nil, fmt.Errorf("jmap session: %w", err)
+	}
+	return client, nil
+}
+
+// fallbackContextTransport sends requests made without a context under ctx.
+type fallbackContextTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+}
+
+func (t fallbackContextTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Context() == context.Background() {
+		r = r.WithContext(t.ctx)
+	}
+	return t.base.RoundTrip(r)
+}
+
+// Server wraps the MCP server and JMAP client.
+type Server struct {
+	mcp                   *mcp.Server
+	sessionURL            string
+	token                 string               // static token for stdio mode; empty in HTTP-only mode
+	endpoints             map[string]*endpoint // additional named backends; nil when only the default exists
+	enableEmailSubmission bool
+	outbox                *outbox           // nil unless submissions require approval
+	sendPolicy            *sendPolicy       // nil permits all recipients and rates
+	preSend               PreSendHook       // nil submits messages unreviewed
+	mailMerge             *MailMergePolicy  // nil: mail_merge is not registered
+	abuseAddress          string            // empty: email_report_abuse is not registered
+	autoRecipients        AutoRecipients    // added to every composed draft
+	attachmentPolicy      *attachmentPolicy // nil permits all attachment types and sizes
+	scan                  *AttachmentScan   // nil hands out attachments unscanned
+	enableSieve           bool
+	enableLabels          bool
+	enableStalwartAdmin   bool
+	attachmentURL         *attachmentURLer  // nil unless signed attachment URLs are enabled
+	attachmentURLTTL      time.Duration     // life of a signed attachment URL
+	externalURL           string            // explicit base URL for signed download links
+	redactor              *Redactor         // nil returns bodies unredacted
+	translator            *translator       // nil unless a translation endpoint is configured
+	classifier            *classifier       // nil unless classification categories are configured
+	pdfRenderer           []string          // external HTML-to-PDF command; empty disables pdf output
+	pgp                   PGP               // nil leaves PGP/MIME messages as delivered
+	senders               *SenderLists      // VIP and muted senders of each user
+	sieveHistory          *SieveHistory     // earlier versions of each user's Sieve scripts
+	mailboxSnapshots      *MailboxSnapshots // named snapshots of each user's mailbox hierarchy
+	automations           *Automations      // rules RunAutomations applies to new mail
+	schedules             *Schedules        // actions RunAutomations runs when they come due
+	deleteDelay           time.Duration     // permanent email_delete waits this long in Trash; 0 destroys at once
+	scripts               []*Script         // operator scripts exposed as script_<name> tools
+	toolCalls             toolCalls         // registered tools by name, for scripts
+	queryPresets          []*QueryPreset    // operator searches exposed as query_<name> tools
+	mailboxViews          mailboxViews      // email_query defaults by mailbox role
+	rotation              *tokenRotation    // rereads the static token; nil keeps it
+	recordPath            string            // fixture file JMAP traffic is recorded to; empty records nothing
+	threadFallback        ThreadFallback    // when Thread/get is answered client-side
+	toolCosts             ToolCosts         // -tool-cost overrides of the cost hints in tool metadata
+	quirks                sync.Map          // session API URL → *quirks of that backend
+	throttles             sync.Map          // owner and API URL → *throttle of that user's requests
+	tenants               tenantStore       // cached digests, query results, scans, and media probes of each tenant
+	batchCursors          batchCursors      // open email_batch_iterator cursors
+	continuations         continuations     // the rest of email_get batches cut at max_chars
+	recentIDs             recentIDs         // endpoint that returned each ID seen in results
+	selections            selections        // named email ID lists of each MCP session
+	submissions           recentSubmissions // when each owner last submitted each email
+	tombstones            tombstones        // prior mailboxes of emails email_delete trashed
+	draftOrigins          draftOrigins      // emails reply and forward drafts answer, marked once sent
+	journals              *Journals         // recent bulk mutations of each MCP session or tenant, for undo_last
+	confirmDestroy        bool              // permanent destruction needs a confirmation token
+	confirmations         confirmations     // issued, unused confirmation tokens
+	reportSnapshots       reportSnapshots   // mailbox counts of the last mailbox_report per owner
+	newsletterStates      newsletterStates  // Email state the last newsletter_digest reached per owner
+	resendWindow          time.Duration     // refuse submitting an email again this soon; 0 disables
+	watches               watchRegistry     // watch_create interests and their push listeners
+	wsConns               wsPool            // RFC 8887 connections by WebSocket URL and token
+	noWebSocket           bool              // use HTTP even when the backend offers WebSocket
+	localFiles            bool              // tools may use files inside the client's roots
+	debugJMAP             DebugJMAP         // where raw JMAP exchanges of tool calls go
+	locale                textStyle         // how tool outputs write dates and sizes
+	structuredOutput      bool              // listing tools also return structured content
+	dialer                Dialer
+	clientWrappers        []func(JMAPClient) JMAPClient
+	maxFetchBytes         int64         // per tool call; 0 is unlimited
+	queryLimit            int           // email_query results when the call sets no limit
+	maxChars              int           // email_get budget when the call sets no max_chars
+	maxBodyChars          int           // per-email body cap in email_get
+	watchPollInterval     time.Duration // watch polling without push; 0 requires push
+	imageMaxDimension     int           // longest side of returned images; 0 never downscales
+	imageJPEGQuality      int           // JPEG quality of downscaled images
+	headerFilter          headerFilter  // headers full_headers shows unless a call overrides
+
+	version string // jmap-mcp version, reported by server_info
+}
+
+// NewServer creates a new MCP server with JMAP tools.
+func NewServer(version, sessionURL string, opts ...Option) *Server {
+	mcpServer := mcp.NewServer(&mcp.Implementation{
+		Name:    "jmap-mcp",
+		Version: version,
+	}, &mcp.ServerOptions{
+		Instructions:       serverInstructions,
+		SubscribeHandler:   subscribeResource,
+		UnsubscribeHandler: unsubscribeResource,
+	})
+
+	s := &Server{
+		mcp:               mcpServer,
+		version:           version,
+		tenants:           tenantStore{quota: TenantQuota{MaxBytes: DefaultTenantCacheBytes, MaxTenants: DefaultMaxTenants}},
+		sessionURL:        sessionURL,
+		dialer:            httpDialer{},
+		senders:           &SenderLists{},
+		sieveHistory:      &SieveHistory{},
+		mailboxSnapshots:  &MailboxSnapshots{},
+		automations:       &Automations{},
+		schedules:         &Schedules{},
+		journals:          &Journals{},
+		maxFetchBytes:     DefaultMaxFetchBytes,
+		queryLimit:        DefaultQueryLimit,
+		maxChars:          DefaultMaxChars,
+		maxBodyChars:      DefaultMaxBodyChars,
+		watchPollInterval: DefaultWatchPollInterval,
+		resendWindow:      DefaultResendWindow,
+		imageMaxDimension: DefaultImageMaxDimension,
+		imageJPEGQuality:  DefaultImageJPEGQuality,
+		attachmentURLTTL:  DefaultAttachmentURLTTL,
+		mailboxViews:      mailboxViewsOf(defaultMailboxViews),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.recordPath != "" {
+		s.dialer = &recordingDialer{base: s.dialer, path: s.recordPath}
+	}
+
+	s.registerTools()
+	s.registerQueryPresets()
+	s.registerScripts()
+	s.checkToolCosts()
+	s.registerResources()
+	s.mcp.AddReceivingMiddleware(s.liveToolDescriptions)
+
+	return s
+}
+
+// MCP returns the underlying MCP server instance.
+func (s *Server) MCP() *mcp.Server {
+	return s.mcp
+}
+
+// resolveToken returns the JMAP auth token, preferring context (HTTP mode)
+// over the selected endpoint's static token and then the default static
+// token (stdio mode).
+func (s *Server) resolveToken(ctx context.Context) (string, error) {
+	if t := TokenFromContext(ctx); t != "" {
+		return t, nil
+	}
+	ep, err := s.endpointFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	if ep.token != "" {
+		return s.currentToken(ep.token), nil
+	}
+	if s.token != "" {
+		return s.staticToken(), nil
+	}
+	return "", fmt.Errorf("no JMAP auth token available")
+}
+
+// jmapClient creates a JMAP client using the resolved token, authenticates
+// the session, and returns the ready client.
+func (s *Server) jmapClient(ctx context.Context) (JMAPClient, error) {
+	ep, err := s.endpointFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.dial(ctx, ep.sessionURL, token)
+}
+
+// dial opens a client for sessionURL with token, meters it when ctx
+// carries a tool call's fetch meter, and applies the client wrappers.
+func (s *Server) dial(ctx context.Context, sessionURL, token string) (JMAPClient, error) {
+	c, err := s.dialer.Dial(ctx, sessionURL, token)
+	if err != nil {
+		return nil, err
+	}
+	return s.wrapClient(ctx, c, token), nil
+}
+
+// wrapClient notes c's account for the call's account hint, notes the
+// backend's rate-limit headers, meters c when ctx carries a tool call's
+// fetch meter, records its API exchanges when ctx carries a JMAP debug
+// trace, routes its method calls over WebSocket when the backend offers
+// it, and applies the client wrappers.
+func (s *Server) wrapClient(ctx context.Context, c *jmap.Client, token string) JMAPClient {
+	if t := jmapTraceFromContext(ctx); t != nil && c.Session != nil {
+		hc := *c.HttpClient
+		base := hc.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		hc.Transport = debugTransport{base: base, apiURL: c.Session.APIURL, trace: t}
+		c.HttpClient = &hc
+	}
+	if r := accountRecorderFromContext(ctx); r != nil && c.Session != nil {
+		r.record(ctx, c.Session)
+	}
+	if c.Session != nil {
+		hc := *c.HttpClient
+		base := hc.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		hc.Transport = rateLimitTransport{
+			base:     unknownResponseTransport{base: base, apiURL: c.Session.APIURL},
+			throttle: s.throttleOf(s.ownerOf(token), c.Session.APIURL),
+		}
+		c.HttpClient = &hc
+	}
+	if m := fetchMeterFromContext(ctx); m != nil {
+		hc := *c.HttpClient
+		base := hc.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		hc.Transport = meteredTransport{base: base, meter: m}
+		c.HttpClient = &hc
+	}
+	inner := s.websocketClient(ctx, NewJMAPClient(c), token)
+	var client JMAPClient = tolerantClient{JMAPClient: inner, threads: s.threadFallbackFor(inner, token)}
+	for _, wrap := range s.clientWrappers {
+		client = wrap(client)
+	}
+	return client
+}
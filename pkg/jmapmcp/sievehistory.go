@@ -0,0 +1,193 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/sieve/sievescript"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+// maxSieveVersions caps the versions kept of each script; the oldest are
+// dropped first.
+const maxSieveVersions = 20
+
+// sieveVersion is a Sieve script's content as it was before a change.
+type sieveVersion struct {
+	Saved   time.Time `json:"saved"`
+	Name    string    `json:"name"`
+	Content string    `json:"content"`
+	Reason  string    `json:"reason"` // the change that replaced it, e.g. "sieve_set update"
+}
+
+// SieveHistory keeps earlier versions of each JMAP user's Sieve scripts,
+// keyed by the session username and script ID, so edits can be rolled
+// back. When it has a store, the history is saved there as JSON after every
+// change so it survives restarts; the zero value keeps it in memory.
+type SieveHistory struct {
+	mu      sync.Mutex
+	store   store.Store
+	doc     string                               // the store's document name
+	scripts map[string]map[string][]sieveVersion // username → script ID → versions, oldest first
+}
+
+// LoadSieveHistory reads the history saved in st as doc, a JSON object of
+// the form {"user": {"scriptId": [version, ...]}}. A missing document is an
+// empty history, created on the first change.
+func LoadSieveHistory(st store.Store, doc string) (*SieveHistory, error) {
+	h := &SieveHistory{store: st, doc: doc, scripts: make(map[string]map[string][]sieveVersion)}
+	data, _, err := st.Read(doc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sieve history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.scripts); err != nil {
+		return nil, fmt.Errorf("sieve history %s: %w", doc, err)
+	}
+	return h, nil
+}
+
+// versions returns user's saved versions of the script, newest first.
+func (h *SieveHistory) versions(user, scriptID string) []sieveVersion {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	saved := h.scripts[user][scriptID]
+	out := make([]sieveVersion, len(saved))
+	for i, v := range saved {
+		out[len(saved)-1-i] = v
+	}
+	return out
+}
+
+// scriptIDs returns the IDs of user's scripts with saved versions.
+func (h *SieveHistory) scriptIDs(user string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]string, 0, len(h.scripts[user]))
+	for id := range h.scripts[user] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// record saves v as the latest earlier version of user's script and saves
+// the store, restoring the previous history when saving fails. A version
+// identical to the latest one is not saved again.
+func (h *SieveHistory) record(user, scriptID string, v sieveVersion) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.scripts == nil {
+		h.scripts = make(map[string]map[string][]sieveVersion)
+	}
+	if h.scripts[user] == nil {
+		h.scripts[user] = make(map[string][]sieveVersion)
+	}
+	previous := h.scripts[user][scriptID]
+	if n := len(previous); n > 0 && previous[n-1].Content == v.Content && previous[n-1].Name == v.Name {
+		return nil
+	}
+	next := append(previous[max(0, len(previous)+1-maxSieveVersions):len(previous):len(previous)], v)
+	h.scripts[user][scriptID] = next
+	if err := h.save(); err != nil {
+		if len(previous) == 0 {
+			delete(h.scripts[user], scriptID)
+		} else {
+			h.scripts[user][scriptID] = previous
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the history to its document. The caller holds h.mu.
+func (h *SieveHistory) save() error {
+	if h.store == nil {
+		return nil
+	}
+	if _, err := store.WriteJSON(h.store, h.doc, h.scripts); err != nil {
+		return fmt.Errorf("saving sieve history: %w", err)
+	}
+	return nil
+}
+
+// saveSieveVersions records the current content of the scripts with the
+// given IDs before reason changes them. Scripts the server does not know
+// are skipped. An error means the versions could not be kept, and the
+// caller must not make the change.
+func (s *Server) saveSieveVersions(ctx context.Context, client JMAPClient, accountID jmap.ID, ids []jmap.ID, reason string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&sievescript.Get{Account: accountID, IDs: ids})
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses) == 0 {
+		return fmt.Errorf("empty response for SieveScript/get")
+	}
+	var scripts []*sievescript.SieveScript
+	switch args := resp.Responses[0].Args.(type) {
+	case *sievescript.GetResponse:
+		scripts = args.List
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	now := time.Now()
+	for _, script := range scripts {
+		content, err := downloadSieveScript(ctx, client, accountID, script.BlobID)
+		if err != nil {
+			return err
+		}
+		v := sieveVersion{Saved: now, Content: content, Reason: reason}
+		if script.Name != nil {
+			v.Name = *script.Name
+		}
+		if err := s.sieveHistory.record(client.Session().Username, string(script.ID), v); err != nil {
+			return fmt.Errorf("keeping the previous version of sieve script %s: %w", script.ID, err)
+		}
+	}
+	return nil
+}
+
+// sieveScriptContent returns the content of the script with id.
+func sieveScriptContent(ctx context.Context, client JMAPClient, accountID, id jmap.ID) (string, error) {
+	scripts, err := fetchSieveScripts(ctx, client, accountID)
+	if err != nil {
+		return "", err
+	}
+	for _, script := range scripts {
+		if script.ID == id {
+			return downloadSieveScript(ctx, client, accountID, script.BlobID)
+		}
+	}
+	return "", notFoundError([]jmap.ID{id}, "sieve script not found: %s", id)
+}
+
+// downloadSieveScript returns the content of a script blob.
+func downloadSieveScript(ctx context.Context, client JMAPClient, accountID, blobID jmap.ID) (string, error) {
+	reader, err := client.Download(ctx, accountID, blobID)
+	if err != nil {
+		return "", fmt.Errorf("download sieve script: %w", err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("read sieve script: %w", err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,39 @@
+package jmapmcp
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+func TestSieveHistoryPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "sieve-history.json")
+	h, err := LoadSieveHistory(store.Dir(""), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range maxSieveVersions + 2 {
+		if err := h.record("me@example.org", "S1", sieveVersion{Saved: time.Unix(int64(i), 0), Name: "main", Content: "v" + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An unchanged script is not saved twice.
+	if err := h.record("me@example.org", "S1", sieveVersion{Name: "main", Content: "v" + strconv.Itoa(maxSieveVersions+1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadSieveHistory(store.Dir(""), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := reloaded.versions("me@example.org", "S1")
+	if len(versions) != maxSieveVersions || versions[0].Content != "v21" || versions[len(versions)-1].Content != "v2" {
+		t.Errorf("versions = %d, newest %q, oldest %q", len(versions), versions[0].Content, versions[len(versions)-1].Content)
+	}
+	if got := reloaded.versions("other@example.org", "S1"); len(got) != 0 {
+		t.Errorf("other user's history = %v", got)
+	}
+}
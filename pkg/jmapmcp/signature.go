@@ -0,0 +1,201 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// email_get strips signatures from bodies, but the contact details in them
+// ground agents that keep address books or CRM records. With signatures set,
+// it parses the sender's signature block into a contactHint shown with the
+// email's headers.
+
+// contactHint is what a signature block says about its author.
+type contactHint struct {
+	Name    string
+	Title   string
+	Company string
+	Phone   string
+	Email   string
+	Website string
+}
+
+// String renders the hint as indented "Field: value" lines.
+func (h *contactHint) String() string {
+	var sb strings.Builder
+	for _, f := range []struct{ label, value string }{
+		{"Name", h.Name}, {"Title", h.Title}, {"Company", h.Company},
+		{"Phone", h.Phone}, {"Email", h.Email}, {"Website", h.Website},
+	} {
+		if f.value != "" {
+			fmt.Fprintf(&sb, "  %s: %s\n", f.label, f.value)
+		}
+	}
+	return sb.String()
+}
+
+const (
+	// maxSignatureLines is the most lines a signature block is taken to have.
+	maxSignatureLines = 10
+	// maxSignatureLineBytes is the longest line a signature block holds;
+	// longer ones are body text.
+	maxSignatureLineBytes = 100
+)
+
+var (
+	// valedictionRE matches the closing line a signature follows.
+	valedictionRE = regexp.MustCompile(`(?i)^(best( regards| wishes)?|kind regards|warm regards|regards|thanks( again)?|thank you|many thanks|cheers|sincerely|yours( truly| sincerely)?|all the best|mit freundlichen grüßen|viele grüße|beste grüße|cordialement|bien à vous|saludos|un saludo|met vriendelijke groet)[,.!]?$`)
+	// phoneRE matches a phone number, with an optional label.
+	phoneRE = regexp.MustCompile(`(?i)^(?:(?:tel|phone|mobile|mob|cell|office|direct|[tmpo])\.?\s*:?\s*)?(\+?[\d(][\d\s().\-/]{6,}\d)(?:\s*(?:x|ext\.?)\s*\d+)?$`)
+	// sigEmailRE matches an address in a signature line.
+	sigEmailRE = regexp.MustCompile(`[\w.+\-]+@[\w\-]+(\.[\w\-]+)+`)
+	// websiteRE matches a web address in a signature line.
+	websiteRE = regexp.MustCompile(`(?i)\b(https?://\S+|www\.[\w\-]+(\.[\w\-]+)+\S*)`)
+	// companyRE matches the legal forms that mark a company name.
+	companyRE = regexp.MustCompile(`(?i)(\b(inc|llc|ltd|limited|corp|corporation|gmbh|ag|plc|s\.a|s\.r\.l|b\.v|oy)\b\.?|&)`)
+	// titleRE matches words of job titles.
+	titleRE = regexp.MustCompile(`(?i)\b(ceo|cto|cfo|coo|vp|founder|co-founder|president|director|manager|head|lead|engineer|developer|designer|consultant|analyst|officer|partner|associate|specialist|coordinator|assistant|administrator|architect|attorney|counsel|sales|marketing|recruiter|owner|principal|professor|editor)\b`)
+	// sigSeparatorRE splits a signature line holding several fields.
+	sigSeparatorRE = regexp.MustCompile(`\s+[|·•]\s+|\s*\|\s*`)
+)
+
+// signatureBlock returns the lines of the signature at the end of text: the
+// lines after a "-- " delimiter, or else after a closing such as "Best
+// regards," near the end. Quoted text is cut off first.
+func signatureBlock(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if isQuoted(line) || forwardSeparatorRE.MatchString(line) {
+			lines = lines[:i]
+			if i > 0 && isAttribution(lines[i-1]) {
+				lines = lines[:i-1]
+			}
+			break
+		}
+	}
+	start := -1
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimRight(lines[i], " ") == "--" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		for i := len(lines) - 1; i >= max(len(lines)-3*maxSignatureLines, 0); i-- {
+			if valedictionRE.MatchString(strings.TrimSpace(lines[i])) {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	var block []string
+	for _, line := range lines[start:] {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "Sent from ") {
+			continue
+		}
+		if len(line) > maxSignatureLineBytes || len(block) == maxSignatureLines {
+			break
+		}
+		block = append(block, line)
+	}
+	return block
+}
+
+// parseSignature extracts a contact hint from the signature at the end of
+// text. It returns nil when there is no signature or it names nothing but
+// a name.
+func parseSignature(text string) *contactHint {
+	var h contactHint
+	for _, line := range signatureBlock(text) {
+		for _, field := range signatureFields(line) {
+			switch {
+			case field == "":
+			case sigEmailRE.MatchString(field):
+				if h.Email == "" {
+					h.Email = sigEmailRE.FindString(field)
+				}
+			case websiteRE.MatchString(field):
+				if h.Website == "" {
+					h.Website = websiteRE.FindString(field)
+				}
+			case phoneRE.MatchString(field) && countDigits(field) >= 7:
+				if h.Phone == "" {
+					h.Phone = phoneRE.FindStringSubmatch(field)[1]
+				}
+			case h.Name == "" && looksLikeName(field):
+				h.Name = field
+			case h.Company == "" && companyRE.MatchString(field):
+				h.Company = field
+			case h.Title == "" && titleRE.MatchString(field):
+				h.Title = field
+			case h.Company == "":
+				h.Company = field
+			case h.Title == "":
+				h.Title = field
+			}
+		}
+	}
+	if h == (contactHint{Name: h.Name}) {
+		return nil
+	}
+	return &h
+}
+
+// signatureFields splits a signature line into its fields, separating
+// "Title, Company" too.
+func signatureFields(line string) []string {
+	var fields []string
+	for _, f := range sigSeparatorRE.Split(line, -1) {
+		f = strings.TrimSpace(f)
+		if title, company, ok := strings.Cut(f, ", "); ok && titleRE.MatchString(title) && !titleRE.MatchString(company) {
+			fields = append(fields, title, company)
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// looksLikeName reports whether s could be a person's name: one to five
+// words without digits or punctuation other than . ' -.
+func looksLikeName(s string) bool {
+	words := strings.Fields(s)
+	if len(words) == 0 || len(words) > 5 || titleRE.MatchString(s) || companyRE.MatchString(s) {
+		return false
+	}
+	return !strings.ContainsFunc(s, func(r rune) bool {
+		return r >= '0' && r <= '9' || strings.ContainsRune(",:;@/()!?", r)
+	})
+}
+
+// countDigits returns the number of ASCII digits in s.
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// emailSignature parses the signature of an email's rendered body, or
+// returns nil.
+func emailSignature(e *email.Email) *contactHint {
+	bv, isHTML := renderedBodyValue(e)
+	switch {
+	case bv == nil:
+		return nil
+	case isHTML:
+		return parseSignature(htmlToText(SanitizeHTML(StripBlockquotes(bv.Value))))
+	default:
+		return parseSignature(bv.Value)
+	}
+}
@@ -0,0 +1,63 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  *contactHint
+	}{
+		{
+			name:  "after valediction",
+			input: "Let's talk Tuesday.\n\nBest regards,\nAlice Smith\nHead of Sales\nExample Corp.\nT: +1 (555) 123-4567\nwww.example.com",
+			want:  &contactHint{Name: "Alice Smith", Title: "Head of Sales", Company: "Example Corp.", Phone: "+1 (555) 123-4567", Website: "www.example.com"},
+		},
+		{
+			name:  "delimiter with separators",
+			input: "See attached.\n\n-- \nBob Meyer | Senior Engineer | Acme Widgets\nbob@acme.example · Mobile: +49 170 1234567\n\nSent from my iPhone",
+			want:  &contactHint{Name: "Bob Meyer", Title: "Senior Engineer", Company: "Acme Widgets", Phone: "+49 170 1234567", Email: "bob@acme.example"},
+		},
+		{
+			name:  "quoted signature ignored",
+			input: "Thanks,\nCarol\n\nOn Mon, Dave <dave@example.com> wrote:\n> Regards,\n> Dave Jones\n> CTO, Example Inc\n> +1 555 000 1111",
+			want:  nil,
+		},
+		{
+			name:  "no signature",
+			input: "Meeting moved to 3pm.",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSignature(tt.input)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || *got != *tt.want:
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailGetSignatures(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "m1", "Quote", "Here is the quote.\n\nKind regards,\nAlice Smith\nAccount Manager, Example Ltd\n+44 20 7946 0958", 1)
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1"}, Signatures: true})
+	out := resultText(res)
+	want := "Signature:\n  Name: Alice Smith\n  Title: Account Manager\n  Company: Example Ltd\n  Phone: +44 20 7946 0958\n"
+	if res.IsError || !strings.Contains(out, want) {
+		t.Errorf("email_get:\n%s\nwant block:\n%s", out, want)
+	}
+
+	res, _, _ = s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1"}})
+	if out := resultText(res); strings.Contains(out, "Signature:") {
+		t.Errorf("signature without signatures set:\n%s", out)
+	}
+}
@@ -0,0 +1,227 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Spam filters explain their decisions in headers: SpamAssassin and Stalwart
+// in X-Spam-Status (with Stalwart's per-rule X-Spam-Result), Rspamd in
+// X-Spamd-Result and X-Rspamd-*. email_get's spam_report reads them into a
+// spamReport so an agent can say why a message was or was not junked.
+
+// maxSpamRules is the most rules a spam report lists, highest scores first.
+const maxSpamRules = 15
+
+// spamRule is one test a spam filter matched.
+type spamRule struct {
+	Name   string
+	Score  float64
+	Scored bool   // whether the header gave Score
+	Detail string // Rspamd's bracketed options
+}
+
+// spamReport is what a message's spam filter headers say.
+type spamReport struct {
+	Filter    string // SpamAssassin, Stalwart, or Rspamd
+	Spam      *bool
+	Score     *float64
+	Threshold *float64
+	Action    string
+	Rules     []spamRule
+}
+
+var (
+	// spamStatusRE matches X-Spam-Status: "Yes, score=7.2 required=5.0 tests=...".
+	spamStatusRE = regexp.MustCompile(`(?i)^(yes|no)\b,?\s*(?:(?:score|hits)=(-?[\d.]+))?(?:\s+required=(-?[\d.]+))?(?:.*?\btests=(.*?)(?:\s+\w+=|$))?`)
+	// scoredRuleRE matches Stalwart's "RULE (1.00)" and Rspamd's
+	// "RULE(1.00)[options]".
+	scoredRuleRE = regexp.MustCompile(`([A-Za-z0-9_]+)\s*\((-?[\d.]+)\)(?:\[([^\]]*)\])?`)
+	// rspamdVerdictRE matches the head of X-Spamd-Result: "default: False
+	// [-1.90 / 15.00]".
+	rspamdVerdictRE = regexp.MustCompile(`(?i)^\w+:\s*(true|false)\s*\[(-?[\d.]+)\s*/\s*(-?[\d.]+)\]`)
+)
+
+// parseSpamHeaders reads the spam filter headers among headers, returning
+// nil when there are none.
+func parseSpamHeaders(headers []*email.Header) *spamReport {
+	var r spamReport
+	found := false
+	for _, h := range headers {
+		v := strings.Join(strings.Fields(h.Value), " ")
+		switch strings.ToLower(h.Name) {
+		case "x-spam-status":
+			m := spamStatusRE.FindStringSubmatch(v)
+			if m == nil {
+				continue
+			}
+			found = true
+			if r.Filter == "" {
+				r.Filter = "SpamAssassin"
+			}
+			r.Spam = boolPtr(strings.EqualFold(m[1], "yes"))
+			r.Score, r.Threshold = parseScore(m[2], r.Score), parseScore(m[3], r.Threshold)
+			for _, t := range strings.Split(m[4], ",") {
+				if t = strings.TrimSpace(t); t != "" && t != "none" {
+					r.addRule(spamRule{Name: t})
+				}
+			}
+		case "x-spam-result":
+			found, r.Filter = true, "Stalwart"
+			for _, m := range scoredRuleRE.FindAllStringSubmatch(v, -1) {
+				r.addRule(scoredRule(m))
+			}
+		case "x-spamd-result":
+			found, r.Filter = true, "Rspamd"
+			if m := rspamdVerdictRE.FindStringSubmatch(v); m != nil {
+				r.Spam = boolPtr(strings.EqualFold(m[1], "true"))
+				r.Score, r.Threshold = parseScore(m[2], nil), parseScore(m[3], nil)
+				v = v[len(m[0]):]
+			}
+			for _, m := range scoredRuleRE.FindAllStringSubmatch(v, -1) {
+				r.addRule(scoredRule(m))
+			}
+		case "x-rspamd-action":
+			found, r.Filter, r.Action = true, "Rspamd", v
+		case "x-rspamd-score", "x-spam-score":
+			found = true
+			r.Score = parseScore(strings.Trim(v, "()"), r.Score)
+		case "x-spam-flag", "x-spam":
+			found = true
+			if r.Spam == nil {
+				r.Spam = boolPtr(strings.EqualFold(v, "yes") || strings.EqualFold(v, "true"))
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	// Highest contributions first; unscored rules keep header order.
+	slices.SortStableFunc(r.Rules, func(a, b spamRule) int {
+		switch {
+		case a.Scored != b.Scored:
+			if a.Scored {
+				return -1
+			}
+			return 1
+		case math.Abs(a.Score) > math.Abs(b.Score):
+			return -1
+		case math.Abs(a.Score) < math.Abs(b.Score):
+			return 1
+		}
+		return 0
+	})
+	return &r
+}
+
+// addRule adds rule, replacing an unscored entry of the same name.
+func (r *spamReport) addRule(rule spamRule) {
+	for i, have := range r.Rules {
+		if have.Name == rule.Name {
+			if rule.Scored {
+				r.Rules[i] = rule
+			}
+			return
+		}
+	}
+	r.Rules = append(r.Rules, rule)
+}
+
+// scoredRule builds a rule from a scoredRuleRE match.
+func scoredRule(m []string) spamRule {
+	score, err := strconv.ParseFloat(m[2], 64)
+	return spamRule{Name: m[1], Score: score, Scored: err == nil, Detail: m[3]}
+}
+
+// parseScore parses s as a score, keeping old when s is not a number.
+func parseScore(s string, old *float64) *float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return old
+	}
+	return &f
+}
+
+// String renders the report as indented lines to follow a "Spam:" heading.
+func (r *spamReport) String() string {
+	var sb strings.Builder
+	verdict := "unknown"
+	if r.Spam != nil {
+		verdict = map[bool]string{true: "spam", false: "not spam"}[*r.Spam]
+	}
+	fmt.Fprintf(&sb, "  Verdict: %s", verdict)
+	if r.Score != nil {
+		fmt.Fprintf(&sb, ", score %s", formatScore(*r.Score))
+		if r.Threshold != nil {
+			fmt.Fprintf(&sb, " (threshold %s)", formatScore(*r.Threshold))
+		}
+	}
+	if r.Filter != "" {
+		fmt.Fprintf(&sb, " by %s", r.Filter)
+	}
+	sb.WriteString("\n")
+	if r.Action != "" {
+		fmt.Fprintf(&sb, "  Action: %s\n", r.Action)
+	}
+	if len(r.Rules) > 0 {
+		rules := make([]string, 0, min(len(r.Rules), maxSpamRules))
+		for _, rule := range r.Rules[:min(len(r.Rules), maxSpamRules)] {
+			s := rule.Name
+			if rule.Scored {
+				s += " " + formatScore(rule.Score)
+			}
+			if rule.Detail != "" {
+				s += " [" + rule.Detail + "]"
+			}
+			rules = append(rules, s)
+		}
+		more := ""
+		if len(r.Rules) > maxSpamRules {
+			more = fmt.Sprintf(", and %d more", len(r.Rules)-maxSpamRules)
+		}
+		fmt.Fprintf(&sb, "  Rules: %s%s\n", strings.Join(rules, ", "), more)
+	}
+	return sb.String()
+}
+
+// formatScore renders a score without trailing zeros.
+func formatScore(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// spamSection renders the Spam block email_get's spam_report adds: the
+// filter's verdict, score, and rules, and the junk keywords of e.
+func spamSection(e *email.Email) string {
+	report := parseSpamHeaders(e.Headers)
+	note := junkKeywordNote(e)
+	if report == nil && note == "" {
+		return "Spam: no spam filter headers or junk keywords\n"
+	}
+	var sb strings.Builder
+	sb.WriteString("Spam:\n")
+	if report != nil {
+		sb.WriteString(report.String())
+	}
+	if note != "" {
+		fmt.Fprintf(&sb, "  Keyword: %s\n", note)
+	}
+	return sb.String()
+}
+
+// junkKeywordNote describes the $junk and $notjunk keywords of e, or is
+// empty when it has neither.
+func junkKeywordNote(e *email.Email) string {
+	switch {
+	case e.Keywords["$junk"]:
+		return "$junk (marked as spam)"
+	case e.Keywords["$notjunk"]:
+		return "$notjunk (marked as not spam)"
+	}
+	return ""
+}
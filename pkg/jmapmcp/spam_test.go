@@ -0,0 +1,87 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestParseSpamHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*email.Header
+		want    string
+	}{
+		{
+			name: "spamassassin",
+			headers: []*email.Header{
+				{Name: "X-Spam-Flag", Value: " YES"},
+				{Name: "X-Spam-Status", Value: " Yes, score=7.2 required=5.0 tests=BAYES_99,\r\n\tHTML_MESSAGE,URIBL_BLACK autolearn=no version=3.4.6"},
+			},
+			want: "  Verdict: spam, score 7.2 (threshold 5) by SpamAssassin\n  Rules: BAYES_99, HTML_MESSAGE, URIBL_BLACK\n",
+		},
+		{
+			name: "stalwart",
+			headers: []*email.Header{
+				{Name: "X-Spam-Result", Value: " DMARC_POLICY_ALLOW (-0.50), FROM_HAS_DN (0.00),\r\n\tBAYES_SPAM (5.10)"},
+				{Name: "X-Spam-Status", Value: " No, score=-1.20"},
+			},
+			want: "  Verdict: not spam, score -1.2 by Stalwart\n  Rules: BAYES_SPAM 5.1, DMARC_POLICY_ALLOW -0.5, FROM_HAS_DN 0\n",
+		},
+		{
+			name: "rspamd",
+			headers: []*email.Header{
+				{Name: "X-Rspamd-Action", Value: " add header"},
+				{Name: "X-Spamd-Result", Value: " default: True [9.10 / 15.00]; R_SPF_FAIL(1.00)[-all]; ABUSE_SURBL(5.50)[example.test:url]"},
+			},
+			want: "  Verdict: spam, score 9.1 (threshold 15) by Rspamd\n  Action: add header\n  Rules: ABUSE_SURBL 5.5 [example.test:url], R_SPF_FAIL 1 [-all]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := parseSpamHeaders(tt.headers)
+			if r == nil {
+				t.Fatal("no report")
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	if r := parseSpamHeaders([]*email.Header{{Name: "Subject", Value: "Hi"}}); r != nil {
+		t.Errorf("report without spam headers: %+v", r)
+	}
+}
+
+func TestEmailGetSpamReport(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Win a prize",
+		"mailboxIds": map[string]any{"junk": true},
+		"keywords":   map[string]any{"$junk": true},
+		"headers": []any{
+			map[string]any{"name": "X-Spam-Status", "value": " Yes, score=8.0 required=5.0 tests=BAYES_99"},
+		},
+	})
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"m1"}, Spam: true})
+	want := "Spam:\n  Verdict: spam, score 8 (threshold 5) by SpamAssassin\n  Rules: BAYES_99\n  Keyword: $junk (marked as spam)\n"
+	if out := resultText(res); res.IsError || !strings.Contains(out, want) {
+		t.Errorf("email_get:\n%s\nwant block:\n%s", out, want)
+	}
+}
+
+func TestEmailQueryJunkFilter(t *testing.T) {
+	for _, junk := range []bool{true, false} {
+		f, err := EmailQueryInput{Junk: &junk}.filter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if junk && f.HasKeyword != "$junk" || !junk && f.NotKeyword != "$junk" {
+			t.Errorf("junk=%v: filter %+v", junk, f)
+		}
+	}
+}
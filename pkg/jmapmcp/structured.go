@@ -0,0 +1,86 @@
+package jmapmcp
+
+import (
+	"time"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+// Structured output: with -structured-output, email_query, email_get,
+// identity_get, and thread_participants also return their results as
+// structured content, every address an {name, email} object, so an agent
+// takes the bare address for a follow-up call from a field instead of
+// parsing "Name <addr>" out of display text. The text result is unchanged.
+
+// addressObject is an address in structured content.
+type addressObject struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+}
+
+// addressObjects converts addrs, dropping empty ones.
+func addressObjects(addrs []*mail.Address) []addressObject {
+	var out []addressObject
+	for _, a := range addrs {
+		if a == nil || a.Email == "" {
+			continue
+		}
+		out = append(out, addressObject{Name: a.Name, Email: a.Email})
+	}
+	return out
+}
+
+// emailSummary is an email in structured content: the properties fetched,
+// with receivedAt in RFC 3339.
+type emailSummary struct {
+	ID         string          `json:"id"`
+	ThreadID   string          `json:"threadId,omitempty"`
+	Subject    string          `json:"subject,omitempty"`
+	From       []addressObject `json:"from,omitempty"`
+	To         []addressObject `json:"to,omitempty"`
+	CC         []addressObject `json:"cc,omitempty"`
+	BCC        []addressObject `json:"bcc,omitempty"`
+	ReplyTo    []addressObject `json:"replyTo,omitempty"`
+	ReceivedAt string          `json:"receivedAt,omitempty"`
+	Size       uint64          `json:"size,omitempty"`
+}
+
+// summarizeEmail returns the structured form of e.
+func summarizeEmail(e *email.Email) emailSummary {
+	sum := emailSummary{
+		ID:       string(e.ID),
+		ThreadID: string(e.ThreadID),
+		Subject:  e.Subject,
+		From:     addressObjects(e.From),
+		To:       addressObjects(e.To),
+		CC:       addressObjects(e.CC),
+		BCC:      addressObjects(e.BCC),
+		ReplyTo:  addressObjects(e.ReplyTo),
+		Size:     e.Size,
+	}
+	if e.ReceivedAt != nil {
+		sum.ReceivedAt = e.ReceivedAt.Format(time.RFC3339)
+	}
+	return sum
+}
+
+// emailGetStructured is the structured content of an email_get result: the
+// emails the text includes.
+type emailGetStructured struct {
+	Emails []emailSummary `json:"emails"`
+}
+
+// identityGetStructured is the structured content of an identity_get result.
+type identityGetStructured struct {
+	Identities []identityObject `json:"identities"`
+}
+
+// identityObject is a sender identity in structured content.
+type identityObject struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name,omitempty"`
+	Email   string          `json:"email"`
+	ReplyTo []addressObject `json:"replyTo,omitempty"`
+	BCC     []addressObject `json:"bcc,omitempty"`
+}
@@ -0,0 +1,51 @@
+package jmapmcp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStructuredOutput(t *testing.T) {
+	s, fake := newFakeServer(t, WithStructuredOutput())
+	fake.Add("Identity", map[string]any{"id": "i1", "name": "Me", "email": "me@example.com", "bcc": []any{map[string]any{"email": "archive@example.com"}}})
+	fake.Add("Email", map[string]any{
+		"id": "e1", "threadId": "T1", "subject": "Plans", "receivedAt": "2025-01-02T10:00:00Z",
+		"from": []any{map[string]any{"name": "Doe, Alice", "email": "alice@example.org"}},
+		"to":   []any{map[string]any{"email": "me@example.com"}},
+	})
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1"}})
+	ctx := context.Background()
+	alice := addressObject{Name: "Doe, Alice", Email: "alice@example.org"}
+
+	res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{})
+	if q, ok := res.StructuredContent.(*emailQueryStructured); !ok || len(q.Emails) != 1 || len(q.Emails[0].From) != 1 || q.Emails[0].From[0] != alice {
+		t.Errorf("email_query structured = %#v", res.StructuredContent)
+	}
+
+	res, _, _ = s.handleEmailGet(ctx, nil, EmailGetInput{EmailIDs: []string{"e1"}})
+	g, ok := res.StructuredContent.(*emailGetStructured)
+	if !ok || len(g.Emails) != 1 || g.Emails[0].From[0] != alice || g.Emails[0].To[0].Email != "me@example.com" || g.Emails[0].ReceivedAt != "2025-01-02T10:00:00Z" {
+		t.Errorf("email_get structured = %#v", res.StructuredContent)
+	}
+
+	res, _, _ = s.handleIdentityGet(ctx, nil, IdentityGetInput{})
+	if i, ok := res.StructuredContent.(*identityGetStructured); !ok || len(i.Identities) != 1 || i.Identities[0].Email != "me@example.com" || i.Identities[0].BCC[0].Email != "archive@example.com" {
+		t.Errorf("identity_get structured = %#v", res.StructuredContent)
+	}
+
+	res, _, _ = s.handleThreadParticipants(ctx, nil, ThreadParticipantsInput{ThreadID: "T1"})
+	p, ok := res.StructuredContent.(*threadParticipantsStructured)
+	if !ok || len(p.Participants) != 2 || len(p.Others) != 1 || p.Others[0] != alice {
+		t.Fatalf("thread_participants structured = %#v", res.StructuredContent)
+	}
+	for _, pp := range p.Participants {
+		if pp.You != (pp.Email == "me@example.com") {
+			t.Errorf("participant %+v", pp)
+		}
+	}
+
+	plain, _ := newFakeServer(t)
+	if res, _, _ := plain.handleIdentityGet(ctx, nil, IdentityGetInput{}); res.StructuredContent != nil {
+		t.Errorf("structured content without structured output: %#v", res.StructuredContent)
+	}
+}
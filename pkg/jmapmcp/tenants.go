@@ -0,0 +1,163 @@
+package jmapmcp
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Tenants: in http mode one process serves everyone whose credentials
+// reach it. Whatever the server keeps between calls belongs to a tenant,
+// the owner key of the caller's JMAP token (see idOwner), so no state
+// leaks from one user to another, and is bounded per tenant, so no user
+// grows it without limit or pushes out another's. Derived results that
+// are only worth keeping (thread digests, email_query results,
+// correspondents_top scans, media probes) live in tenantStore under a
+// per-tenant byte quota; the least recently active tenant is dropped
+// when there are too many. Session state (selections, the undo journal)
+// is keyed by session and tenant, and shared tables (batch cursors,
+// email_get continuations) evict from the tenant holding the most.
+
+const (
+	// DefaultTenantCacheBytes is the cache quota of each tenant.
+	DefaultTenantCacheBytes = 8 << 20
+	// DefaultMaxTenants bounds the tenants with cached results.
+	DefaultMaxTenants = 1000
+)
+
+// TenantQuota bounds the cached results kept for each tenant.
+type TenantQuota struct {
+	MaxBytes   int64 // estimated bytes per tenant; 0 or less disables caching
+	MaxTenants int   // tenants with cached results; 0 or less is unlimited
+}
+
+// tenantStore holds cached results by tenant, each tenant's least recently
+// used dropped first when its quota is reached.
+type tenantStore struct {
+	quota TenantQuota
+
+	mu      sync.Mutex
+	tenants map[string]*tenantCache
+	order   list.List // *tenantCache, least recently active first
+}
+
+// tenantCache is one tenant's share of a tenantStore.
+type tenantCache struct {
+	owner   string
+	entries map[string]*list.Element
+	order   list.List // *tenantEntry, least recently used first
+	bytes   int64
+	elem    *list.Element // in tenantStore.order
+}
+
+type tenantEntry struct {
+	key   string
+	value any
+	size  int64
+}
+
+// get returns the value owner cached under key.
+func (ts *tenantStore) get(owner, key string) (any, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tc := ts.tenants[owner]
+	if tc == nil {
+		return nil, false
+	}
+	el, ok := tc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	tc.order.MoveToBack(el)
+	ts.order.MoveToBack(tc.elem)
+	return el.Value.(*tenantEntry).value, true
+}
+
+// put caches value for owner under key, size being its estimated bytes.
+// A value larger than the whole quota is not kept.
+func (ts *tenantStore) put(owner, key string, value any, size int64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	size += int64(len(key))
+	if size > ts.quota.MaxBytes {
+		ts.dropLocked(owner, key)
+		return
+	}
+	if ts.tenants == nil {
+		ts.tenants = make(map[string]*tenantCache)
+	}
+	tc := ts.tenants[owner]
+	if tc == nil {
+		if ts.quota.MaxTenants > 0 && len(ts.tenants) >= ts.quota.MaxTenants {
+			oldest := ts.order.Remove(ts.order.Front()).(*tenantCache)
+			delete(ts.tenants, oldest.owner)
+		}
+		tc = &tenantCache{owner: owner, entries: make(map[string]*list.Element)}
+		tc.elem = ts.order.PushBack(tc)
+		ts.tenants[owner] = tc
+	}
+	ts.order.MoveToBack(tc.elem)
+	if el, ok := tc.entries[key]; ok {
+		tc.bytes -= el.Value.(*tenantEntry).size
+		tc.order.Remove(el)
+	}
+	for tc.bytes+size > ts.quota.MaxBytes {
+		e := tc.order.Remove(tc.order.Front()).(*tenantEntry)
+		delete(tc.entries, e.key)
+		tc.bytes -= e.size
+	}
+	tc.entries[key] = tc.order.PushBack(&tenantEntry{key: key, value: value, size: size})
+	tc.bytes += size
+}
+
+// drop forgets what owner cached under key.
+func (ts *tenantStore) drop(owner, key string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.dropLocked(owner, key)
+}
+
+func (ts *tenantStore) dropLocked(owner, key string) {
+	tc := ts.tenants[owner]
+	if tc == nil {
+		return
+	}
+	if el, ok := tc.entries[key]; ok {
+		tc.bytes -= el.Value.(*tenantEntry).size
+		tc.order.Remove(el)
+		delete(tc.entries, key)
+	}
+}
+
+// usage returns how many results owner has cached and their estimated
+// bytes.
+func (ts *tenantStore) usage(owner string) (int, int64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tc := ts.tenants[owner]
+	if tc == nil {
+		return 0, 0
+	}
+	return len(tc.entries), tc.bytes
+}
+
+// evictionVictim returns the key of the entry to drop from a full table
+// shared by all tenants: the least recently used entry of the tenant
+// holding the most, so a tenant filling the table only pushes out its own.
+func evictionVictim[E any](m map[string]E, owner func(E) string, used func(E) time.Time) string {
+	held := make(map[string]int)
+	for _, e := range m {
+		held[owner(e)]++
+	}
+	victim, victimOwner := "", ""
+	for k, e := range m {
+		o := owner(e)
+		switch {
+		case victim == "",
+			held[o] > held[victimOwner],
+			held[o] == held[victimOwner] && used(e).Before(used(m[victim])):
+			victim, victimOwner = k, o
+		}
+	}
+	return victim
+}
@@ -0,0 +1,112 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTenantStoreQuota(t *testing.T) {
+	ts := &tenantStore{quota: TenantQuota{MaxBytes: 100, MaxTenants: 2}}
+	ts.put("a", "k1", 1, 40-2)
+	ts.put("a", "k2", 2, 40-2)
+	ts.get("a", "k1") // k2 is now the least recently used
+	ts.put("a", "k3", 3, 40-2)
+	if _, ok := ts.get("a", "k2"); ok {
+		t.Error("least recently used entry kept over quota")
+	}
+	if v, ok := ts.get("a", "k1"); !ok || v != 1 {
+		t.Errorf("k1 = %v, %v", v, ok)
+	}
+	if n, used := ts.usage("a"); n != 2 || used != 80 {
+		t.Errorf("usage = %d entries, %d bytes", n, used)
+	}
+
+	// Another tenant's cache neither sees nor evicts a's.
+	ts.put("b", "k1", 10, 90-2)
+	if v, _ := ts.get("a", "k1"); v != 1 {
+		t.Errorf("tenant a sees %v", v)
+	}
+	if v, _ := ts.get("b", "k1"); v != 10 {
+		t.Errorf("tenant b sees %v", v)
+	}
+
+	// Over MaxTenants the least recently active tenant goes.
+	ts.get("a", "k1")
+	ts.put("c", "k1", 100, 8)
+	if _, ok := ts.get("b", "k1"); ok {
+		t.Error("least recently active tenant kept")
+	}
+	if _, ok := ts.get("a", "k1"); !ok {
+		t.Error("active tenant dropped")
+	}
+
+	// A value over the whole quota is not cached, and replaces nothing.
+	ts.put("a", "k1", 4, 200)
+	if _, ok := ts.get("a", "k1"); ok {
+		t.Error("oversized value cached")
+	}
+
+	off := &tenantStore{}
+	off.put("a", "k", 1, 1)
+	if _, ok := off.get("a", "k"); ok {
+		t.Error("cached with a zero quota")
+	}
+}
+
+func TestEvictionVictim(t *testing.T) {
+	now := time.Now()
+	m := map[string]*batchCursor{
+		"cur-1": {ID: "cur-1", Owner: "light", Used: now.Add(-time.Hour)},
+		"cur-2": {ID: "cur-2", Owner: "heavy", Used: now.Add(-time.Minute)},
+		"cur-3": {ID: "cur-3", Owner: "heavy", Used: now.Add(-2 * time.Minute)},
+		"cur-4": {ID: "cur-4", Owner: "heavy", Used: now},
+	}
+	got := evictionVictim(m,
+		func(c *batchCursor) string { return c.Owner },
+		func(c *batchCursor) time.Time { return c.Used })
+	if got != "cur-3" {
+		t.Errorf("victim = %s, want the heavy tenant's oldest cur-3", got)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "One", "Hi", 1)
+	alice := ContextWithToken(context.Background(), "alice-token")
+	bob := ContextWithToken(context.Background(), "bob-token")
+
+	if res, _, _ := s.handleSelectionSet(alice, nil, SelectionSetInput{Name: "mine", EmailIDs: []string{"e1"}}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if _, err := s.selectedEmailIDs(bob, nil, nil, "mine"); err == nil {
+		t.Error("another tenant reached the selection")
+	}
+	if ids, err := s.selectedEmailIDs(alice, nil, nil, "mine"); err != nil || len(ids) != 1 {
+		t.Errorf("own selection = %v, %v", ids, err)
+	}
+
+	seen := true
+	if res, _, _ := s.handleEmailFlag(alice, nil, EmailFlagInput{EmailIDs: []string{"e1"}, Seen: &seen}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	res, _, _ := s.handleUndoLast(bob, nil, UndoLastInput{Preview: true})
+	if out := resultText(res); !strings.Contains(out, "Nothing to undo") {
+		t.Errorf("another tenant sees the journal: %s", out)
+	}
+	res, _, _ = s.handleUndoLast(alice, nil, UndoLastInput{Preview: true})
+	if out := resultText(res); !strings.Contains(out, "Would undo email_flag") {
+		t.Errorf("own journal: %s", out)
+	}
+
+	if res, _, _ := s.handleEmailQuery(alice, nil, EmailQueryInput{}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if n, _ := s.tenants.usage(s.idOwner(alice)); n != 1 {
+		t.Errorf("alice caches %d query result(s)", n)
+	}
+	if n, _ := s.tenants.usage(s.idOwner(bob)); n != 0 {
+		t.Errorf("bob caches %d result(s)", n)
+	}
+}
@@ -0,0 +1,654 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/thread"
+)
+
+// Thread fallback: minimal JMAP servers may not implement Thread/get, or
+// may group emails into threads badly. The tools fetch conversations with
+// Thread/get, so on such servers tolerantClient answers those calls
+// itself, reconstructing each conversation from the Message-ID,
+// In-Reply-To, and References headers of its emails, or from their base
+// subjects when the server cannot filter on headers. In the default auto
+// mode this starts once the server refuses Thread/get (quirkNoThreads);
+// ThreadFallbackAlways uses it on every server and ThreadFallbackOff
+// never.
+//
+// A request with Thread/get calls then runs in segments split at them:
+// each segment goes to the server as a request of its own, back-references
+// into earlier segments are resolved here, and each Thread/get gets a
+// synthesized response. The emails of a thread are found from those the
+// server reported with its thread ID, in the same request or remembered
+// from earlier ones, and from the thread ID itself, which minimal servers
+// often derive from the first email's ID.
+
+// ThreadFallback selects when Thread/get is answered client-side.
+type ThreadFallback string
+
+const (
+	// ThreadFallbackAuto reconstructs threads once the server refuses
+	// Thread/get.
+	ThreadFallbackAuto ThreadFallback = ""
+	// ThreadFallbackAlways reconstructs every thread, adding the server's
+	// own grouping when it has one.
+	ThreadFallbackAlways ThreadFallback = "always"
+	// ThreadFallbackOff leaves Thread/get to the server.
+	ThreadFallbackOff ThreadFallback = "off"
+)
+
+// ParseThreadFallback validates a -thread-fallback value; "auto" is
+// ThreadFallbackAuto.
+func ParseThreadFallback(v string) (ThreadFallback, error) {
+	switch m := ThreadFallback(v); m {
+	case "auto":
+		return ThreadFallbackAuto, nil
+	case ThreadFallbackAuto, ThreadFallbackAlways, ThreadFallbackOff:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown -thread-fallback mode %q (want auto, always, or off)", v)
+}
+
+// WithThreadFallback sets when threads are reconstructed client-side
+// (default ThreadFallbackAuto).
+func WithThreadFallback(mode ThreadFallback) Option {
+	return func(s *Server) { s.threadFallback = mode }
+}
+
+const (
+	threadRounds         = 3   // rounds of following headers from the emails found so far
+	maxThreadEmails      = 100 // emails a reconstructed thread holds at most
+	maxThreadConditions  = 60  // header conditions per Email/query
+	threadSeedSize       = 32  // cache estimate of one remembered email ID
+	threadFallbackState  = "fallback"
+	threadSeedsKeyPrefix = "thread-seeds:"
+)
+
+// threadProperties are the Email properties reconstruction reads.
+var threadProperties = []string{"id", "threadId", "messageId", "inReplyTo", "references", "subject", "receivedAt"}
+
+// threadFallback answers the Thread/get calls of one user's requests to
+// one server.
+type threadFallback struct {
+	s      *Server
+	owner  string
+	apiURL string
+	quirks *quirks
+}
+
+// threadFallbackFor returns the thread fallback of client's requests made
+// with token, or nil when it is off.
+func (s *Server) threadFallbackFor(client JMAPClient, token string) *threadFallback {
+	if s.threadFallback == ThreadFallbackOff || client.Session() == nil {
+		return nil
+	}
+	return &threadFallback{s: s, owner: s.ownerOf(token), apiURL: client.Session().APIURL, quirks: s.quirksFor(client)}
+}
+
+// active reports whether Thread/get is answered here.
+func (f *threadFallback) active() bool {
+	return f.s.threadFallback == ThreadFallbackAlways || f.quirks.has(quirkNoThreads)
+}
+
+// threadSource says where the threads of a backend with q come from.
+func (s *Server) threadSource(q *quirks) string {
+	switch {
+	case s.threadFallback == ThreadFallbackOff && q.has(quirkNoThreads):
+		return "unavailable (Thread/get refused, fallback off)"
+	case s.threadFallback != ThreadFallbackAlways && !q.has(quirkNoThreads):
+		return "from the server (Thread/get)"
+	case q.has(quirkNoHeaderFilter):
+		return "reconstructed from subjects"
+	}
+	return "reconstructed from Message-ID, In-Reply-To, and References headers"
+}
+
+// do runs req with send, which returns normalized responses, answering
+// its Thread/get calls when the server cannot.
+func (f *threadFallback) do(req *jmap.Request, send func(*jmap.Request) (*jmap.Response, error)) (*jmap.Response, error) {
+	if !slices.ContainsFunc(req.Calls, isThreadGet) {
+		resp, err := send(req)
+		if err == nil && f.active() {
+			f.remember(resp)
+		}
+		return resp, err
+	}
+	if f.active() {
+		return f.stepwise(req, send)
+	}
+	resp, err := send(req)
+	if err != nil || !refusesThreads(req, resp) {
+		return resp, err
+	}
+	f.quirks.learn(quirkNoThreads)
+	if !isReadOnly(req) {
+		// Sending it again would repeat its changes; the next request
+		// takes the fallback.
+		return resp, nil
+	}
+	return f.stepwise(req, send)
+}
+
+func isThreadGet(call *jmap.Invocation) bool {
+	return call.Name == "Thread/get"
+}
+
+// refusesThreads reports whether the server answered a Thread/get call of
+// req as an unknown method.
+func refusesThreads(req *jmap.Request, resp *jmap.Response) bool {
+	for i, inv := range callResponses(req, resp) {
+		if me, ok := inv.Args.(*jmap.MethodError); ok && isThreadGet(req.Calls[i]) && me.Type == "unknownMethod" {
+			return true
+		}
+	}
+	return false
+}
+
+// isReadOnly reports whether every call of req only reads.
+func isReadOnly(req *jmap.Request) bool {
+	for _, call := range req.Calls {
+		_, method, _ := strings.Cut(call.Name, "/")
+		switch method {
+		case "get", "query", "changes", "queryChanges":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// stepwise runs req in segments split at its Thread/get calls, which it
+// answers itself.
+func (f *threadFallback) stepwise(req *jmap.Request, send func(*jmap.Request) (*jmap.Response, error)) (*jmap.Response, error) {
+	out := &jmap.Response{}
+	var extra []*jmap.Invocation
+	done := make(map[string]*jmap.Invocation) // call ID → its response
+	seen := make(map[string][]jmap.ID)        // account and thread → emails the server put in it
+	var segment []*jmap.Invocation
+	flush := func() error {
+		if len(segment) == 0 {
+			return nil
+		}
+		sub := &jmap.Request{Context: req.Context, Using: req.Using, Calls: segment, CreatedIDs: req.CreatedIDs}
+		resp, err := send(sub)
+		if err != nil {
+			return err
+		}
+		for i, inv := range resp.Responses {
+			if i < len(segment) {
+				out.Responses = append(out.Responses, inv)
+				done[inv.CallID] = inv
+			} else {
+				extra = append(extra, inv)
+			}
+		}
+		out.SessionState = resp.SessionState
+		out.CreatedIDs = resp.CreatedIDs
+		f.remember(resp)
+		collectThreadEmails(resp, seen)
+		segment = nil
+		return nil
+	}
+	pending := make(map[string]bool) // call IDs in segment
+	for _, call := range req.Calls {
+		if isThreadGet(call) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			clear(pending)
+		}
+		args, err := resolveReferences(call, done, pending)
+		if err != nil {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			clear(pending)
+			inv := methodErrorInvocation(call.CallID, "invalidResultReference", err.Error())
+			out.Responses = append(out.Responses, inv)
+			done[call.CallID] = inv
+			continue
+		}
+		if !isThreadGet(call) {
+			segment = append(segment, &jmap.Invocation{Name: call.Name, Args: args, CallID: call.CallID})
+			pending[call.CallID] = true
+			continue
+		}
+		inv := f.threadGet(req.Context, call.CallID, args, seen, send)
+		out.Responses = append(out.Responses, inv)
+		done[call.CallID] = inv
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	out.Responses = append(out.Responses, extra...)
+	return out, nil
+}
+
+// methodErrorInvocation is an error response to the call with callID.
+func methodErrorInvocation(callID, typ, desc string) *jmap.Invocation {
+	return &jmap.Invocation{Name: "error", CallID: callID, Args: &jmap.MethodError{Type: typ, Description: &desc}}
+}
+
+// resolveReferences returns call's arguments with its back-references to
+// calls in done replaced by their values. References to calls in pending,
+// sent along with call, are left to the server.
+func resolveReferences(call *jmap.Invocation, done map[string]*jmap.Invocation, pending map[string]bool) (json.RawMessage, error) {
+	data, err := json.Marshal(call.Args)
+	if err != nil {
+		return nil, err
+	}
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	for key, raw := range args {
+		if !strings.HasPrefix(key, "#") {
+			continue
+		}
+		var ref jmap.ResultReference
+		if err := json.Unmarshal(raw, &ref); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if pending[ref.ResultOf] {
+			continue
+		}
+		inv, ok := done[ref.ResultOf]
+		if !ok || inv.Name != ref.Name {
+			return nil, fmt.Errorf("%s: no %s response to call %s", key, ref.Name, ref.ResultOf)
+		}
+		data, err := json.Marshal(inv.Args)
+		if err != nil {
+			return nil, err
+		}
+		var result any
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+		value, err := evalPointer(result, ref.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if args[key[1:]], err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+		delete(args, key)
+	}
+	return json.Marshal(args)
+}
+
+// evalPointer evaluates a result reference path (RFC 8620 section 3.7):
+// a JSON pointer whose "*" maps the rest of the path over an array,
+// flattening arrays it yields.
+func evalPointer(v any, path string) (any, error) {
+	if path == "" {
+		return v, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %q does not start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return walkPointer(v, tokens)
+}
+
+func walkPointer(v any, tokens []string) (any, error) {
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	tok, rest := tokens[0], tokens[1:]
+	switch node := v.(type) {
+	case map[string]any:
+		child, ok := node[tok]
+		if !ok {
+			// Responses leave out empty lists.
+			if len(rest) > 0 && rest[0] == "*" {
+				return []any{}, nil
+			}
+			return nil, fmt.Errorf("no %q in result", tok)
+		}
+		return walkPointer(child, rest)
+	case []any:
+		if tok == "*" {
+			out := []any{}
+			for _, el := range node {
+				r, err := walkPointer(el, rest)
+				if err != nil {
+					return nil, err
+				}
+				if list, ok := r.([]any); ok {
+					out = append(out, list...)
+				} else {
+					out = append(out, r)
+				}
+			}
+			return out, nil
+		}
+		i, err := strconv.Atoi(tok)
+		if err != nil || i < 0 || i >= len(node) {
+			return nil, fmt.Errorf("no element %q in result", tok)
+		}
+		return walkPointer(node[i], rest)
+	}
+	return nil, fmt.Errorf("cannot follow %q in result", tok)
+}
+
+// threadGet answers a Thread/get call with reconstructed threads.
+func (f *threadFallback) threadGet(ctx context.Context, callID string, raw json.RawMessage, seen map[string][]jmap.ID, send func(*jmap.Request) (*jmap.Response, error)) *jmap.Invocation {
+	var args struct {
+		Account jmap.ID   `json:"accountId"`
+		IDs     []jmap.ID `json:"ids"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return methodErrorInvocation(callID, "invalidArguments", err.Error())
+	}
+	if args.IDs == nil {
+		return methodErrorInvocation(callID, "requestTooLarge", "Thread/get without ids is not supported by the thread fallback")
+	}
+	server := f.serverThreads(ctx, args.Account, args.IDs, send)
+	resp := &thread.GetResponse{Account: args.Account, State: threadFallbackState}
+	for _, id := range args.IDs {
+		seeds := []jmap.ID{id}
+		seeds = append(seeds, seen[threadSeedsKey(args.Account, id)]...)
+		seeds = append(seeds, f.recalled(args.Account, id)...)
+		seeds = append(seeds, server[id]...)
+		ids, err := f.reconstruct(ctx, args.Account, id, seeds, send)
+		var me *jmap.MethodError
+		if asMethodError(err, &me) {
+			return &jmap.Invocation{Name: "error", CallID: callID, Args: me}
+		}
+		if err != nil {
+			return methodErrorInvocation(callID, "serverFail", err.Error())
+		}
+		if len(ids) == 0 {
+			resp.NotFound = append(resp.NotFound, id)
+			continue
+		}
+		resp.List = append(resp.List, &thread.Thread{ID: id, EmailIDs: ids})
+	}
+	return &jmap.Invocation{Name: "Thread/get", CallID: callID, Args: resp}
+}
+
+// serverThreads returns the server's own grouping of threads in
+// ThreadFallbackAlways mode, and nothing otherwise or when it fails.
+func (f *threadFallback) serverThreads(ctx context.Context, accountID jmap.ID, ids []jmap.ID, send func(*jmap.Request) (*jmap.Response, error)) map[jmap.ID][]jmap.ID {
+	if f.s.threadFallback != ThreadFallbackAlways || f.quirks.has(quirkNoThreads) {
+		return nil
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&thread.Get{Account: accountID, IDs: ids})
+	resp, err := send(req)
+	if err != nil || len(resp.Responses) == 0 {
+		return nil
+	}
+	out := make(map[jmap.ID][]jmap.ID)
+	switch args := resp.Responses[0].Args.(type) {
+	case *thread.GetResponse:
+		for _, t := range args.List {
+			out[t.ID] = t.EmailIDs
+		}
+	case *jmap.MethodError:
+		if args.Type == "unknownMethod" {
+			f.quirks.learn(quirkNoThreads)
+		}
+	}
+	return out
+}
+
+// reconstruct returns the emails of the conversation holding seeds,
+// oldest first, following Message-ID, In-Reply-To, and References headers
+// in both directions, or base subjects when the server cannot filter on
+// headers.
+func (f *threadFallback) reconstruct(ctx context.Context, accountID, threadID jmap.ID, seeds []jmap.ID, send func(*jmap.Request) (*jmap.Response, error)) ([]jmap.ID, error) {
+	t := &threadMembers{emails: make(map[jmap.ID]*email.Email), own: make(map[string]bool), referenced: make(map[string]bool)}
+	list, err := threadFetch(ctx, send, accountID, nil, slices.Compact(sortedIDs(seeds)))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range list {
+		// A seed now in another thread is a stale memory, and the thread
+		// ID need not name an email.
+		if e.ThreadID == "" || e.ThreadID == threadID {
+			t.add(e)
+		}
+	}
+	if len(t.emails) == 0 {
+		return nil, nil
+	}
+
+	queried := make(map[string]bool)
+	for range threadRounds {
+		if f.quirks.has(quirkNoHeaderFilter) || len(t.emails) >= maxThreadEmails {
+			break
+		}
+		var conds []email.Filter
+		for _, id := range sortedKeys(t.own) {
+			if !queried[id] && len(conds)+2 <= maxThreadConditions {
+				queried[id] = true
+				conds = append(conds,
+					&email.FilterCondition{Header: []string{"References", id}},
+					&email.FilterCondition{Header: []string{"In-Reply-To", id}})
+			}
+		}
+		for _, id := range sortedKeys(t.referenced) {
+			if !t.own[id] && !queried[id] && len(conds) < maxThreadConditions {
+				queried[id] = true
+				conds = append(conds, &email.FilterCondition{Header: []string{"Message-ID", id}})
+			}
+		}
+		if len(conds) == 0 {
+			break
+		}
+		found, err := threadFetch(ctx, send, accountID, &email.FilterOperator{Operator: jmap.OperatorOR, Conditions: conds}, nil)
+		var me *jmap.MethodError
+		if asMethodError(err, &me) && isUnsupportedFilter(me) {
+			f.quirks.learn(quirkNoHeaderFilter)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The header filter matches substrings; keep exact links.
+		added := false
+		for _, e := range found {
+			if t.emails[e.ID] == nil && t.linked(e) && len(t.emails) < maxThreadEmails {
+				t.add(e)
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	if f.quirks.has(quirkNoHeaderFilter) {
+		if err := f.bySubject(ctx, accountID, t, send); err != nil {
+			return nil, err
+		}
+	}
+	return t.sorted(), nil
+}
+
+// bySubject adds the emails sharing the base subject of t's emails.
+func (f *threadFallback) bySubject(ctx context.Context, accountID jmap.ID, t *threadMembers, send func(*jmap.Request) (*jmap.Response, error)) error {
+	subjects := make(map[string]bool)
+	for _, e := range t.emails {
+		if base := baseSubject(e.Subject); base != "" {
+			subjects[base] = true
+		}
+	}
+	for _, subject := range sortedKeys(subjects) {
+		found, err := threadFetch(ctx, send, accountID, &email.FilterCondition{Subject: subject}, nil)
+		if err != nil {
+			return err
+		}
+		for _, e := range found {
+			if t.emails[e.ID] == nil && strings.EqualFold(baseSubject(e.Subject), subject) && len(t.emails) < maxThreadEmails {
+				t.add(e)
+			}
+		}
+	}
+	return nil
+}
+
+// threadMembers are the emails of a conversation being reconstructed.
+type threadMembers struct {
+	emails     map[jmap.ID]*email.Email
+	own        map[string]bool // Message-IDs of the emails
+	referenced map[string]bool // Message-IDs the emails reply to or reference
+}
+
+func (t *threadMembers) add(e *email.Email) {
+	t.emails[e.ID] = e
+	for _, id := range e.MessageID {
+		t.own[id] = true
+	}
+	for _, id := range slices.Concat(e.InReplyTo, e.References) {
+		t.referenced[id] = true
+	}
+}
+
+// linked reports whether e is referenced by or references one of t's
+// emails.
+func (t *threadMembers) linked(e *email.Email) bool {
+	for _, id := range e.MessageID {
+		if t.referenced[id] {
+			return true
+		}
+	}
+	for _, id := range slices.Concat(e.InReplyTo, e.References) {
+		if t.own[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted returns the IDs of t's emails by receivedAt, oldest first, as
+// Thread/get orders them.
+func (t *threadMembers) sorted() []jmap.ID {
+	list := make([]*email.Email, 0, len(t.emails))
+	for _, e := range t.emails {
+		list = append(list, e)
+	}
+	slices.SortFunc(list, func(a, b *email.Email) int {
+		if c := exportDate(a).Compare(exportDate(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.ID), string(b.ID))
+	})
+	ids := make([]jmap.ID, len(list))
+	for i, e := range list {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+// threadFetch gets the emails with ids, or those matching filter, with
+// threadProperties.
+func threadFetch(ctx context.Context, send func(*jmap.Request) (*jmap.Response, error), accountID jmap.ID, filter email.Filter, ids []jmap.ID) ([]*email.Email, error) {
+	req := &jmap.Request{Context: ctx}
+	get := &email.Get{Account: accountID, IDs: ids, Properties: threadProperties}
+	if filter != nil {
+		queryCallID := req.Invoke(&email.Query{Account: accountID, Filter: filter, Limit: maxThreadEmails})
+		get = &email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+			Properties:   threadProperties,
+		}
+	}
+	req.Invoke(get)
+	resp, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, inv := range callResponses(req, resp) {
+		if me, ok := inv.Args.(*jmap.MethodError); ok {
+			return nil, me
+		}
+	}
+	args, ok := resp.Responses[len(req.Calls)-1].Args.(*email.GetResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type: %T", resp.Responses[len(req.Calls)-1].Args)
+	}
+	return args.List, nil
+}
+
+// asMethodError reports whether err is a method error, setting *me.
+func asMethodError(err error, me **jmap.MethodError) bool {
+	m, ok := err.(*jmap.MethodError)
+	if ok {
+		*me = m
+	}
+	return ok
+}
+
+// collectThreadEmails adds the emails of resp's Email/get responses to
+// seen under their account and thread.
+func collectThreadEmails(resp *jmap.Response, seen map[string][]jmap.ID) {
+	for _, inv := range resp.Responses {
+		args, ok := inv.Args.(*email.GetResponse)
+		if !ok {
+			continue
+		}
+		for _, e := range args.List {
+			if e.ThreadID != "" {
+				key := threadSeedsKey(args.Account, e.ThreadID)
+				if !slices.Contains(seen[key], e.ID) {
+					seen[key] = append(seen[key], e.ID)
+				}
+			}
+		}
+	}
+}
+
+// remember keeps, in the user's cache, the emails resp reported with a
+// thread ID, to find each thread's emails later.
+func (f *threadFallback) remember(resp *jmap.Response) {
+	seen := make(map[string][]jmap.ID)
+	collectThreadEmails(resp, seen)
+	for key, ids := range seen {
+		key = threadSeedsKeyPrefix + f.apiURL + " " + key
+		var prior []jmap.ID
+		if v, ok := f.s.tenants.get(f.owner, key); ok {
+			prior = v.([]jmap.ID)
+		}
+		merged := slices.Clone(prior)
+		for _, id := range ids {
+			if !slices.Contains(merged, id) && len(merged) < maxThreadEmails {
+				merged = append(merged, id)
+			}
+		}
+		if len(merged) > len(prior) {
+			f.s.tenants.put(f.owner, key, merged, int64(len(merged))*threadSeedSize)
+		}
+	}
+}
+
+// recalled returns the emails remembered in the thread.
+func (f *threadFallback) recalled(accountID, threadID jmap.ID) []jmap.ID {
+	v, ok := f.s.tenants.get(f.owner, threadSeedsKeyPrefix+f.apiURL+" "+threadSeedsKey(accountID, threadID))
+	if !ok {
+		return nil
+	}
+	return v.([]jmap.ID)
+}
+
+func threadSeedsKey(accountID, threadID jmap.ID) string {
+	return string(accountID) + "/" + string(threadID)
+}
+
+// sortedIDs returns ids sorted.
+func sortedIDs(ids []jmap.ID) []jmap.ID {
+	out := slices.Clone(ids)
+	slices.Sort(out)
+	return out
+}
@@ -0,0 +1,124 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// addLinkedEmail adds an email that, as on servers without threads, is
+// a thread of its own, linked to others only by its headers.
+func addLinkedEmail(fake *jmapfake.Server, id, subject, messageID, inReplyTo string, references []string, day int) {
+	headers := []any{map[string]any{"name": "Message-ID", "value": "<" + messageID + ">"}}
+	var refs []any
+	for _, r := range references {
+		refs = append(refs, r)
+	}
+	obj := map[string]any{
+		"id": id, "threadId": id, "subject": subject, "messageId": []any{messageID},
+		"receivedAt": fmt.Sprintf("2025-01-%02dT10:00:00Z", day),
+		"from":       []any{map[string]any{"email": id + "@example.org"}},
+		"textBody":   []any{map[string]any{"partId": "1", "type": "text/plain"}},
+		"bodyValues": map[string]any{"1": map[string]any{"value": "Body of " + id}},
+	}
+	if inReplyTo != "" {
+		obj["inReplyTo"] = []any{inReplyTo}
+		obj["references"] = refs
+		headers = append(headers,
+			map[string]any{"name": "In-Reply-To", "value": "<" + inReplyTo + ">"},
+			map[string]any{"name": "References", "value": "<" + strings.Join(references, "> <") + ">"})
+	}
+	obj["headers"] = headers
+	fake.Add("Email", obj)
+}
+
+func threadFallbackFake(t *testing.T) (*Server, *jmapfake.Server) {
+	s, fake := newFakeServer(t)
+	addLinkedEmail(fake, "e1", "Plan", "m1@example.org", "", nil, 1)
+	addLinkedEmail(fake, "e2", "Re: Plan", "m2@example.org", "m1@example.org", []string{"m1@example.org"}, 2)
+	addLinkedEmail(fake, "e3", "Re: Plan", "m3@example.org", "m2@example.org", []string{"m1@example.org", "m2@example.org"}, 3)
+	addLinkedEmail(fake, "e4", "Plans for lunch", "m4@example.org", "", nil, 4)
+	return s, fake
+}
+
+func TestThreadFallback(t *testing.T) {
+	s, fake := threadFallbackFake(t)
+	fake.FailNext("Thread/get", "unknownMethod")
+	ctx := context.Background()
+
+	for _, in := range []ThreadExportInput{{EmailID: "e2"}, {ThreadID: "e3"}} {
+		res, _, _ := s.handleThreadExport(ctx, nil, in)
+		if res.IsError {
+			t.Fatalf("thread_export %+v: %s", in, resultText(res))
+		}
+		doc := res.Content[1].(*mcp.EmbeddedResource).Resource.Text
+		for _, want := range []string{"Body of e1", "Body of e2", "Body of e3"} {
+			if !strings.Contains(doc, want) {
+				t.Errorf("export of %+v lacks %q:\n%s", in, want, doc)
+			}
+		}
+		if strings.Contains(doc, "Body of e4") || strings.Index(doc, "Body of e1") > strings.Index(doc, "Body of e3") {
+			t.Errorf("export of %+v has the wrong emails or order:\n%s", in, doc)
+		}
+	}
+	threadGets := 0
+	for _, c := range fake.Calls() {
+		if c == "Thread/get" {
+			threadGets++
+		}
+	}
+	if threadGets != 1 {
+		t.Errorf("Thread/get sent %d times, want once before the quirk is learned", threadGets)
+	}
+}
+
+func TestThreadFallbackBySubject(t *testing.T) {
+	s, fake := threadFallbackFake(t)
+	fake.FailNext("Thread/get", "unknownMethod")
+	fake.FailNext("Email/query", "unsupportedFilter")
+
+	res, _, _ := s.handleThreadExport(context.Background(), nil, ThreadExportInput{ThreadID: "e1"})
+	if res.IsError {
+		t.Fatalf("thread_export: %s", resultText(res))
+	}
+	if got := resultText(res); !strings.Contains(got, "3 message(s)") {
+		t.Errorf("summary = %q, want 3 messages by subject", got)
+	}
+}
+
+func TestThreadFallbackOff(t *testing.T) {
+	s, fake := newFakeServer(t, WithThreadFallback(ThreadFallbackOff))
+	addLinkedEmail(fake, "e1", "Plan", "m1@example.org", "", nil, 1)
+	fake.FailNext("Thread/get", "unknownMethod")
+
+	res, _, _ := s.handleThreadExport(context.Background(), nil, ThreadExportInput{ThreadID: "e1"})
+	if !res.IsError {
+		t.Errorf("thread_export succeeded with the fallback off: %s", resultText(res))
+	}
+}
+
+func TestEvalPointer(t *testing.T) {
+	v := map[string]any{"list": []any{
+		map[string]any{"id": "a", "emailIds": []any{"1", "2"}},
+		map[string]any{"id": "b", "emailIds": []any{"3"}},
+	}}
+	for path, want := range map[string]any{
+		"/list/*/emailIds": []any{"1", "2", "3"},
+		"/list/*/id":       []any{"a", "b"},
+		"/list/1/id":       "b",
+		"/ids/*":           []any{},
+	} {
+		got, err := evalPointer(v, path)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("evalPointer(%s) = %v, %v; want %v", path, got, err, want)
+		}
+	}
+	if _, err := evalPointer(v, "/list/2/id"); err == nil {
+		t.Error("evalPointer past the end succeeded")
+	}
+}
@@ -0,0 +1,103 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+)
+
+// Image attachments are returned to the model as MCP image content, which
+// model input limits make expensive at camera resolutions. Unless a call
+// asks for the original, email_attachment_image scales images larger than
+// the configured dimension down and re-encodes them as JPEG; the original
+// stays available by blob download.
+
+const (
+	// DefaultImageMaxDimension is the longest side, in pixels, of images
+	// email_attachment_image returns when none is configured.
+	DefaultImageMaxDimension = 1568
+	// DefaultImageJPEGQuality is the JPEG quality of downscaled images.
+	DefaultImageJPEGQuality = 85
+	// maxImageBytes caps the attachment email_attachment_image downloads.
+	maxImageBytes = 32 << 20
+	// maxImagePixels caps the decoded size of an image, so a small file
+	// cannot claim gigabytes of memory.
+	maxImagePixels = 50_000_000
+)
+
+// thumbnail is a downscaled image.
+type thumbnail struct {
+	Data          []byte // JPEG
+	Width, Height int
+}
+
+// imageSize returns the dimensions of an encoded JPEG, PNG, or GIF image.
+func imageSize(data []byte) (width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// makeThumbnail scales data down so neither side exceeds maxDim, flattens
+// transparency onto white, and encodes the result as JPEG at quality. It
+// returns nil when the image already fits.
+func makeThumbnail(data []byte, maxDim, quality int) (*thumbnail, error) {
+	w, h, err := imageSize(data)
+	if err != nil {
+		return nil, err
+	}
+	if w*h > maxImagePixels {
+		return nil, fmt.Errorf("image is %dx%d, more than %d pixels", w, h, maxImagePixels)
+	}
+	if maxDim <= 0 || max(w, h) <= maxDim {
+		return nil, nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	tw, th := maxDim, max(h*maxDim/w, 1)
+	if h > w {
+		tw, th = max(w*maxDim/h, 1), maxDim
+	}
+	dst := downscale(src, tw, th)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return &thumbnail{Data: buf.Bytes(), Width: tw, Height: th}, nil
+}
+
+// downscale returns src scaled to w×h by averaging the source pixels each
+// target pixel covers, over a white background.
+func downscale(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0, y1 := b.Min.Y+y*sh/h, b.Min.Y+max((y+1)*sh/h, y*sh/h+1)
+		for x := range w {
+			x0, x1 := b.Min.X+x*sw/w, b.Min.X+max((x+1)*sw/w, x*sw/w+1)
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					// Premultiplied: adding the uncovered part of white
+					// flattens the pixel.
+					r += uint64(pr + 0xffff - pa)
+					g += uint64(pg + 0xffff - pa)
+					bl += uint64(pb + 0xffff - pa)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(bl / n >> 8), 0xff})
+		}
+	}
+	return dst
+}
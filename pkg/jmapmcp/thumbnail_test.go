@@ -0,0 +1,64 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// pngImage encodes a w×h PNG filled with c.
+func pngImage(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	data := pngImage(t, 400, 1000, color.NRGBA{R: 255, A: 255})
+	thumb, err := makeThumbnail(data, 100, 80)
+	if err != nil || thumb == nil {
+		t.Fatalf("makeThumbnail = %v, %v", thumb, err)
+	}
+	if thumb.Width != 40 || thumb.Height != 100 {
+		t.Errorf("thumbnail is %dx%d, want 40x100", thumb.Width, thumb.Height)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(thumb.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 100 {
+		t.Errorf("decoded thumbnail is %v", b)
+	}
+	if r, g, _, _ := img.At(20, 50).RGBA(); r < 0xe000 || g > 0x2000 {
+		t.Errorf("thumbnail pixel = %v, want red", img.At(20, 50))
+	}
+
+	if thumb, err := makeThumbnail(data, 1000, 80); err != nil || thumb != nil {
+		t.Errorf("image within the limit = %v, %v, want unchanged", thumb, err)
+	}
+	if thumb, err := makeThumbnail(data, 0, 80); err != nil || thumb != nil {
+		t.Errorf("limit 0 = %v, %v, want unchanged", thumb, err)
+	}
+	if _, err := makeThumbnail([]byte("not an image"), 100, 80); err == nil {
+		t.Error("garbage decoded")
+	}
+}
+
+func TestDownscaleFlattensTransparency(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4)) // fully transparent
+	if c := downscale(src, 2, 2).RGBAAt(1, 1); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("transparent pixel = %v, want white", c)
+	}
+}
@@ -0,0 +1,109 @@
+package jmapmcp
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Token rotation: the static JMAP token (stdio mode, and the automation
+// runner) may come from a file or keychain entry that is rewritten while
+// the server runs. With WithTokenReload the server rereads it when a call
+// needs the token, at most every tokenRecheckInterval, and a changed token
+// is used from that call on; no restart is needed. Sessions are fetched
+// per call, so the next call authenticates with the new token. Long-lived
+// users of the token (watch listeners, the automation runner) pick it up
+// when they next dial; pooled WebSocket connections are keyed by token, so
+// the old one idles out. The user's state (undo journal, outbox, watches,
+// cached results) stays keyed by the first token (see ownerOf), so
+// rotation does not orphan it. A reread that fails keeps the current
+// token.
+
+// tokenRecheckInterval is how often the static token's source is reread.
+const tokenRecheckInterval = 15 * time.Second
+
+// tokenRotation tracks the static token as its source changes.
+type tokenRotation struct {
+	reload func() (string, error)
+
+	mu      sync.Mutex
+	current string
+	checked time.Time
+	failed  bool            // the last reread failed; logged once
+	static  map[string]bool // owner keys of every static token seen
+}
+
+// WithTokenReload rereads the static token with reload (at most every
+// tokenRecheckInterval) so a rotated token is used without a restart.
+func WithTokenReload(reload func() (string, error)) Option {
+	return func(s *Server) {
+		if reload != nil {
+			s.rotation = &tokenRotation{reload: reload}
+		}
+	}
+}
+
+// staticToken returns the current static token, rereading its source when
+// due.
+func (s *Server) staticToken() string {
+	r := s.rotation
+	if r == nil || s.token == "" {
+		return s.token
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == "" {
+		r.current = s.token
+		r.static = map[string]bool{ownerKey(s.token): true}
+	}
+	if time.Since(r.checked) < tokenRecheckInterval {
+		return r.current
+	}
+	r.checked = time.Now()
+	token, err := r.reload()
+	switch {
+	case err != nil:
+		if !r.failed {
+			log.Printf("Rereading the JMAP token failed, keeping the current one: %v", err)
+		}
+		r.failed = true
+	case token != r.current:
+		log.Printf("JMAP token changed; using the new token from the next request")
+		r.current, r.failed = token, false
+		r.static[ownerKey(token)] = true
+	default:
+		r.failed = false
+	}
+	return r.current
+}
+
+// currentToken returns the static token in place of token when token is
+// an earlier static token, and token otherwise.
+func (s *Server) currentToken(token string) string {
+	if s.rotation == nil || !s.isStaticToken(token) {
+		return token
+	}
+	return s.staticToken()
+}
+
+// isStaticToken reports whether token is, or was, the static token.
+func (s *Server) isStaticToken(token string) bool {
+	if token == s.token {
+		return true
+	}
+	if s.rotation == nil {
+		return false
+	}
+	s.rotation.mu.Lock()
+	defer s.rotation.mu.Unlock()
+	return s.rotation.static[ownerKey(token)]
+}
+
+// ownerOf returns the owner key of token: that of the first static token
+// for any static token, so state outlives rotation.
+func (s *Server) ownerOf(token string) string {
+	if s.token != "" && s.isStaticToken(token) {
+		return ownerKey(s.token)
+	}
+	return ownerKey(token)
+}
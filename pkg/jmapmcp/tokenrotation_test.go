@@ -0,0 +1,65 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+// tokenDialer records the token of each dial.
+type tokenDialer struct {
+	*jmapfake.Server
+	tokens *[]string
+}
+
+func (d tokenDialer) Dial(ctx context.Context, sessionURL, token string) (*jmap.Client, error) {
+	*d.tokens = append(*d.tokens, token)
+	return d.Server.Dial(ctx, sessionURL, token)
+}
+
+func TestTokenRotation(t *testing.T) {
+	fake := jmapfake.New()
+	var dialed []string
+	source, sourceErr := "old", error(nil)
+	s := NewServer("test", jmapfake.SessionURL,
+		WithToken("old"),
+		WithDialer(tokenDialer{fake, &dialed}),
+		WithTokenReload(func() (string, error) { return source, sourceErr }),
+	)
+	ctx := context.Background()
+	call := func() {
+		t.Helper()
+		if res, _, _ := s.handleIdentityGet(ctx, nil, IdentityGetInput{}); res.IsError {
+			t.Fatal(resultText(res))
+		}
+	}
+
+	call()
+	source = "new"
+	call() // within tokenRecheckInterval: not reread yet
+	s.rotation.checked = time.Time{}
+	call()
+	if want := []string{"old", "old", "new"}; len(dialed) != 3 || dialed[1] != want[1] || dialed[2] != want[2] {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+	if s.ownerOf("new") != ownerKey("old") || s.idOwner(ctx) != ownerKey("old") {
+		t.Error("owner changed with the token")
+	}
+	if got := s.currentToken("old"); got != "new" {
+		t.Errorf("currentToken(old) = %q", got)
+	}
+	if got := s.currentToken("someone-else"); got != "someone-else" {
+		t.Errorf("currentToken of an HTTP token = %q", got)
+	}
+
+	source, sourceErr = "", errors.New("file is being rewritten")
+	s.rotation.checked = time.Time{}
+	if got := s.staticToken(); got != "new" {
+		t.Errorf("failed reread switched to %q", got)
+	}
+}
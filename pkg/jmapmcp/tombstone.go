@@ -0,0 +1,85 @@
+package jmapmcp
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+)
+
+// Tombstones: email_delete moving an email to Trash loses the mailboxes it
+// was in, so putting it back means guessing. Each soft delete records the
+// email's prior mailboxes here, per credential and endpoint, and
+// email_restore moves it back to them. The log is held in memory; after a
+// restart, or for mail trashed by other clients, email_restore falls back
+// to the Inbox.
+
+const (
+	maxTombstones     = 10000 // per owner; the oldest are dropped first
+	tombstoneLifetime = 30 * 24 * time.Hour
+)
+
+// tombstone records where a trashed email was.
+type tombstone struct {
+	endpoint  string
+	emailID   jmap.ID
+	mailboxes []jmap.ID
+	at        time.Time
+}
+
+type tombstones struct {
+	mu sync.Mutex
+	m  map[string][]*tombstone // owner → tombstones, oldest first
+}
+
+// record adds ts to owner's log, replacing earlier tombstones of the same
+// emails.
+func (t *tombstones) record(owner string, ts []*tombstone) {
+	if len(ts) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.m == nil {
+		t.m = make(map[string][]*tombstone)
+	}
+	cutoff := time.Now().Add(-tombstoneLifetime)
+	log := slices.DeleteFunc(t.m[owner], func(old *tombstone) bool {
+		if old.at.Before(cutoff) {
+			return true
+		}
+		return slices.ContainsFunc(ts, func(n *tombstone) bool {
+			return n.endpoint == old.endpoint && n.emailID == old.emailID
+		})
+	})
+	log = append(log, ts...)
+	if len(log) > maxTombstones {
+		log = slices.Delete(log, 0, len(log)-maxTombstones)
+	}
+	t.m[owner] = log
+}
+
+// lookup returns owner's tombstone of emailID on endpoint, or nil.
+func (t *tombstones) lookup(owner, endpoint string, emailID jmap.ID) *tombstone {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ts := range t.m[owner] {
+		if ts.endpoint == endpoint && ts.emailID == emailID {
+			return ts
+		}
+	}
+	return nil
+}
+
+// forget drops owner's tombstones of emailIDs on endpoint once restored.
+func (t *tombstones) forget(owner, endpoint string, emailIDs []jmap.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.m == nil {
+		return
+	}
+	t.m[owner] = slices.DeleteFunc(t.m[owner], func(ts *tombstone) bool {
+		return ts.endpoint == endpoint && slices.Contains(emailIDs, ts.emailID)
+	})
+}
@@ -0,0 +1,139 @@
+package jmapmcp
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Cost hints: every tool's _meta carries what a call typically costs, so
+// orchestrators can plan parallelism and budgets: a latency class with a
+// suggested timeout, the output size a budget bounds it to where one does,
+// and the bytes a call may read from JMAP. Sizes follow the configured
+// caps (-max-chars, -max-fetch-bytes); the latency class and timeout of any
+// tool can be overridden with -tool-cost.
+
+// toolCostMetaKey is the tool _meta key of the cost hints.
+const toolCostMetaKey = "jmapCost"
+
+// Latency classes.
+const (
+	latencyInstant = "instant" // answered from server memory, no JMAP round trip
+	latencyFast    = "fast"    // one or a few JMAP round trips
+	latencySlow    = "slow"    // scans many messages, pages, or waits on other services
+)
+
+// latencyTimeouts are the suggested timeouts of the latency classes, in
+// seconds.
+var latencyTimeouts = map[string]int{
+	latencyInstant: 5,
+	latencyFast:    30,
+	latencySlow:    120,
+}
+
+// instantTools need no JMAP request.
+var instantTools = map[string]bool{
+	"selection_set": true, "selection_add": true, "selection_clear": true,
+	"watch_list": true, "watch_delete": true, "automation_delete": true,
+	"outbox_list": true, "outbox_reject": true, "vip_list": true,
+	"email_ref_link": true, "sieve_history": true,
+}
+
+// slowTools scan or page through many messages, send, or call services
+// besides the JMAP server.
+var slowTools = map[string]bool{
+	"email_digest": true, "newsletter_digest": true, "mailbox_report": true,
+	"mailbox_suggest": true, "correspondents_top": true, "reply_latency": true,
+	"email_awaiting_reply": true, "email_sent_unanswered": true, "email_triage_suggest": true,
+	"email_batch_iterator": true, "inbox_unified": true, "mail_merge": true,
+	"config_export": true, "config_import": true, "email_render": true,
+	"sieve_rule_learn": true, "sender_profile": true, "email_submission_set": true,
+	"outbox_approve": true, "calendar_freebusy": true,
+}
+
+// ToolCost is the cost hint of one tool.
+type ToolCost struct {
+	Latency        string `json:"latency"`
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+	MaxOutputChars int    `json:"maxOutputChars,omitempty"` // default output budget; 0 when none bounds it
+	MaxFetchBytes  int64  `json:"maxFetchBytes,omitempty"`  // per-call JMAP read limit; 0 is unlimited
+}
+
+// ToolCosts overrides the latency class and timeout of tools by name.
+type ToolCosts map[string]ToolCost
+
+// ParseToolCosts parses -tool-cost items of the form tool=class or
+// tool=class:seconds, class being instant, fast, or slow.
+func ParseToolCosts(items []string) (ToolCosts, error) {
+	costs := make(ToolCosts, len(items))
+	for _, item := range items {
+		name, spec, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("tool cost %q: want tool=class[:seconds]", item)
+		}
+		class, secs, hasSecs := strings.Cut(spec, ":")
+		timeout, ok := latencyTimeouts[class]
+		if !ok {
+			return nil, fmt.Errorf("tool cost %q: class must be instant, fast, or slow", item)
+		}
+		if hasSecs {
+			n, err := strconv.Atoi(secs)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("tool cost %q: timeout must be a positive number of seconds", item)
+			}
+			timeout = n
+		}
+		costs[name] = ToolCost{Latency: class, TimeoutSeconds: timeout}
+	}
+	return costs, nil
+}
+
+// toolCost returns the cost hint of the tool named name.
+func (s *Server) toolCost(name string) ToolCost {
+	c := ToolCost{Latency: latencyFast}
+	switch {
+	case instantTools[name]:
+		c.Latency = latencyInstant
+	case slowTools[name], strings.HasPrefix(name, scriptToolPrefix):
+		c.Latency = latencySlow
+	}
+	c.TimeoutSeconds = latencyTimeouts[c.Latency]
+	if o, ok := s.toolCosts[name]; ok {
+		c.Latency, c.TimeoutSeconds = o.Latency, o.TimeoutSeconds
+	}
+	if c.Latency != latencyInstant {
+		c.MaxFetchBytes = s.maxFetchBytes
+	}
+	switch name {
+	case "email_get", "reply_context":
+		c.MaxOutputChars = s.maxChars
+	case "email_digest":
+		c.MaxOutputChars = defaultDigestMaxChars
+	case "newsletter_digest":
+		c.MaxOutputChars = defaultNewsletterMaxChars
+	}
+	return c
+}
+
+// withToolCost returns a copy of t whose _meta carries its cost hint.
+func (s *Server) withToolCost(t *mcp.Tool) *mcp.Tool {
+	tt := *t
+	tt.Meta = make(mcp.Meta, len(t.Meta)+1)
+	for k, v := range t.Meta {
+		tt.Meta[k] = v
+	}
+	tt.Meta[toolCostMetaKey] = s.toolCost(t.Name)
+	return &tt
+}
+
+// checkToolCosts logs -tool-cost overrides naming no registered tool.
+func (s *Server) checkToolCosts() {
+	for _, name := range sortedKeys(s.toolCosts) {
+		if _, ok := s.toolCalls[name]; !ok {
+			log.Printf("Tool cost override for %s: no such tool is enabled", name)
+		}
+	}
+}
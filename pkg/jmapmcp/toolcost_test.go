@@ -0,0 +1,50 @@
+package jmapmcp
+
+import "testing"
+
+func TestToolCostMeta(t *testing.T) {
+	costs, err := ParseToolCosts([]string{"email_query=slow:60", "watch_list=fast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newFakeServer(t, WithMaxChars(1234), WithMaxFetchBytes(1<<20), WithToolCosts(costs))
+
+	cost := func(name string) ToolCost {
+		t.Helper()
+		tc, ok := s.toolCalls[name]
+		if !ok {
+			t.Fatalf("%s not registered", name)
+		}
+		c, ok := tc.tool.Meta[toolCostMetaKey].(ToolCost)
+		if !ok {
+			t.Fatalf("%s _meta = %#v", name, tc.tool.Meta)
+		}
+		return c
+	}
+	if c := cost("email_get"); c != (ToolCost{Latency: latencyFast, TimeoutSeconds: 30, MaxOutputChars: 1234, MaxFetchBytes: 1 << 20}) {
+		t.Errorf("email_get cost = %+v", c)
+	}
+	if c := cost("selection_set"); c != (ToolCost{Latency: latencyInstant, TimeoutSeconds: 5}) {
+		t.Errorf("selection_set cost = %+v", c)
+	}
+	if c := cost("mailbox_report"); c.Latency != latencySlow || c.TimeoutSeconds != 120 {
+		t.Errorf("mailbox_report cost = %+v", c)
+	}
+	if c := cost("email_query"); c.Latency != latencySlow || c.TimeoutSeconds != 60 {
+		t.Errorf("overridden email_query cost = %+v", c)
+	}
+	if c := cost("watch_list"); c.Latency != latencyFast || c.TimeoutSeconds != 30 || c.MaxFetchBytes != 1<<20 {
+		t.Errorf("overridden watch_list cost = %+v", c)
+	}
+	if emailGetTool.Meta != nil {
+		t.Error("registration changed the shared tool definition")
+	}
+}
+
+func TestParseToolCosts(t *testing.T) {
+	for _, bad := range []string{"email_query", "=slow", "email_query=glacial", "email_query=slow:0", "email_query=slow:x"} {
+		if _, err := ParseToolCosts([]string{bad}); err == nil {
+			t.Errorf("ParseToolCosts(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Live tool descriptions: tools are registered once per process with
+// generic descriptions, while what a call can do depends on the caller's
+// account and credential. liveToolDescriptions, a receiving middleware,
+// adds a closing "On this account:" paragraph to the tools/list result:
+// which special-use mailboxes exist, whether drafts can be sent, the
+// backend's upload limits, and tools the credential may not call. The
+// account facts come from one Mailbox/get, cached per tenant and endpoint
+// for toolFactsTTL; when they cannot be fetched the list goes out as
+// registered.
+
+const (
+	toolFactsTTL     = 10 * time.Minute
+	toolFactsTimeout = 5 * time.Second
+	toolFactsSize    = 512 // cache estimate of one toolFacts
+)
+
+// standardRoles are the special-use mailboxes tool descriptions report,
+// in the order they are listed.
+var standardRoles = []string{"inbox", "archive", "drafts", "sent", "junk", "trash"}
+
+var (
+	// roleTools file mail by mailbox role or into a role's mailbox.
+	roleTools = map[string]bool{
+		"mailbox_get": true, "email_move": true, "email_delete": true, "email_restore": true,
+		"email_report_abuse": true, "respond_and_file": true, "automation_create": true,
+		"schedule_create": true, "mailbox_suggest": true,
+	}
+	// draftTools create drafts that may or may not be sendable here.
+	draftTools = map[string]bool{
+		"email_create": true, "email_reply": true, "email_forward": true,
+	}
+	// uploadTools upload blobs or attach them to drafts.
+	uploadTools = map[string]bool{
+		"email_create": true, "email_reply": true, "email_forward": true,
+		"attachment_upload": true, "mail_merge": true,
+	}
+)
+
+// toolFacts is what the caller's account offers, as tool descriptions
+// report it.
+type toolFacts struct {
+	fetched       time.Time
+	roles         []string // standard roles with a mailbox
+	missingRoles  []string // standard roles without one
+	submission    bool     // the account offers EmailSubmission
+	maxUpload     uint64   // core maxSizeUpload; 0 when not advertised
+	maxAttachment uint64   // mail maxSizeAttachmentsPerEmail; 0 when not advertised
+}
+
+// liveToolDescriptions is a receiving middleware adding the caller's
+// account facts to the descriptions in tools/list results.
+func (s *Server) liveToolDescriptions(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		res, err := next(ctx, method, req)
+		list, ok := res.(*mcp.ListToolsResult)
+		if method != "tools/list" || err != nil || !ok {
+			return res, err
+		}
+		facts := s.toolFacts(ctx)
+		perm := PermissionFromContext(ctx)
+		for i, t := range list.Tools {
+			if note := s.toolNote(t, facts, perm); note != "" {
+				tt := *t
+				tt.Description += "\n\nOn this account: " + note
+				list.Tools[i] = &tt
+			}
+		}
+		return list, nil
+	}
+}
+
+// toolNote returns what t's description should add for an account with
+// facts (nil when unknown) and a credential with perm.
+func (s *Server) toolNote(t *mcp.Tool, facts *toolFacts, perm Permission) string {
+	var notes []string
+	if !perm.allows(t) {
+		notes = append(notes, fmt.Sprintf("this credential (permission %s) may not call this tool.", perm))
+	}
+	if draftTools[t.Name] {
+		switch {
+		case !s.enableEmailSubmission:
+			notes = append(notes, "sending is disabled on this deployment, so drafts stay in Drafts for the user to send.")
+		case facts != nil && !facts.submission:
+			notes = append(notes, "the account offers no email submission, so drafts cannot be sent from here.")
+		case s.outbox != nil:
+			notes = append(notes, "sent drafts are queued for approval (outbox_approve).")
+		}
+	} else if sendingTools[t.Name] && facts != nil && !facts.submission {
+		notes = append(notes, "the account offers no email submission, so sending will fail.")
+	}
+	if facts == nil {
+		return strings.Join(notes, " ")
+	}
+	if roleTools[t.Name] {
+		note := "mailboxes exist for the roles " + listOrNone(facts.roles)
+		if len(facts.missingRoles) > 0 {
+			note += " (none for " + strings.Join(facts.missingRoles, ", ") + ")"
+		}
+		notes = append(notes, note+".")
+	}
+	if uploadTools[t.Name] {
+		var limits []string
+		if facts.maxUpload > 0 {
+			limits = append(limits, "uploads up to "+s.locale.size(int64(facts.maxUpload)))
+		}
+		if facts.maxAttachment > 0 {
+			limits = append(limits, "attachments up to "+s.locale.size(int64(facts.maxAttachment))+" per email")
+		}
+		if len(limits) > 0 {
+			notes = append(notes, "the server takes "+strings.Join(limits, " and ")+".")
+		}
+	}
+	return strings.Join(notes, " ")
+}
+
+// toolFacts returns the caller's account facts, cached, or nil when they
+// cannot be fetched.
+func (s *Server) toolFacts(ctx context.Context) *toolFacts {
+	owner, key := s.idOwner(ctx), "tool-facts:"+endpointName(ctx)
+	if v, ok := s.tenants.get(owner, key); ok {
+		if f := v.(*toolFacts); time.Since(f.fetched) < toolFactsTTL {
+			return f
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, toolFactsTimeout)
+	defer cancel()
+	f, err := s.fetchToolFacts(ctx)
+	if err != nil {
+		return nil
+	}
+	s.tenants.put(owner, key, f, toolFactsSize)
+	return f
+}
+
+// fetchToolFacts reads the account facts from the session and one
+// Mailbox/get.
+func (s *Server) fetchToolFacts(ctx context.Context) (*toolFacts, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session := client.Session()
+	accountID := session.PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return nil, fmt.Errorf("no primary mail account")
+	}
+	f := &toolFacts{fetched: time.Now()}
+	if _, ok := session.Capabilities[emailsubmission.URI]; ok {
+		f.submission = true
+	}
+	if c, ok := session.Capabilities[jmap.CoreURI].(*core.Core); ok {
+		f.maxUpload = c.MaxSizeUpload
+	}
+	if acct, ok := session.Accounts[accountID]; ok {
+		if c, ok := acct.Capabilities[mail.URI].(*mail.Mail); ok {
+			f.maxAttachment = c.MaxSizeAttachmentsPerEmail
+		}
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "role"}})
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Mailbox/get")
+	}
+	args, ok := resp.Responses[0].Args.(*mailbox.GetResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type: %T", resp.Responses[0].Args)
+	}
+	var present []string
+	for _, mb := range args.List {
+		present = append(present, string(mb.Role))
+	}
+	for _, role := range standardRoles {
+		if slices.Contains(present, role) {
+			f.roles = append(f.roles, role)
+		} else {
+			f.missingRoles = append(f.missingRoles, role)
+		}
+	}
+	return f, nil
+}
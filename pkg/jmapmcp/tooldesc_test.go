@@ -0,0 +1,36 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLiveToolDescriptions(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Mailbox", map[string]any{"id": "projects", "name": "Projects"})
+	cs := rootsSession(t, s)
+
+	res, err := cs.ListTools(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := map[string]string{}
+	for _, tool := range res.Tools {
+		desc[tool.Name] = tool.Description
+	}
+	if d := desc["mailbox_get"]; !strings.Contains(d, "On this account: mailboxes exist for the roles inbox, sent (none for archive, drafts, junk, trash).") {
+		t.Errorf("mailbox_get description:\n%s", d)
+	}
+	if d := desc["email_create"]; !strings.Contains(d, "sending is disabled on this deployment") {
+		t.Errorf("email_create description:\n%s", d)
+	}
+	if d := desc["email_query"]; strings.Contains(d, "On this account") {
+		t.Errorf("email_query description gained a note:\n%s", d)
+	}
+	if strings.Contains(mailboxGetTool.Description, "On this account") {
+		t.Error("registered tool description changed")
+	}
+}
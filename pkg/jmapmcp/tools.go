@@ -0,0 +1,329 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const serverInstructions = `JMAP MCP Server — email and Sieve script management via JMAP protocol.
+
+## Key concepts
+
+- **Mailbox**: a folder (Inbox, Drafts, Sent, Trash, etc.). Each has an ID and a role.
+- **Email**: belongs to one or more mailboxes, identified by an opaque ID.
+- **Identity**: a sender address the user may send from.
+- **Sieve script**: server-side email filtering rules (auto-filing, vacation replies, etc.).
+
+## Common workflows
+
+**Reading email**: call mailbox_get to discover mailbox IDs and roles, then email_query with filters to get matching email IDs, then email_get with those IDs to retrieve full content. For a long conversation, read the jmap://thread/{id}/summary resource (thread ID shown by email_get) for a compact digest instead of fetching every message. When triaging, add importance to the email_query fields to see which messages the sender marked high or low importance.
+
+**Catching up**: call email_digest with since (a date) for a briefing of new mail, and keep the State it returns to pass as since_state next time, so only mail that arrived in between is summarized. To see what the user still owes answers to, call email_awaiting_reply; for their own messages nobody has answered, which may need a follow-up, call email_sent_unanswered. For newsletters and mailing lists, call newsletter_digest: it briefs what arrived since its previous run, grouped by list, and with mark_read clears them from the unread count.
+
+**Sending email**: call email_create to compose a draft (saved in Drafts), or email_reply to draft a threaded reply (reply_all to include all original recipients), or email_forward to forward a message with its attachments (recipients may be contact group names, which are expanded to their members and reported), then email_submission_set with the draft ID to submit for delivery (automatically moves from Drafts to Sent). If the server runs with a send queue, submission only queues the draft: tell the user it awaits approval (outbox_approve, which asks the user to confirm) rather than claiming it was sent. The jmap://outbox resource lists what awaits approval, what the server holds for a later send time, and what was sent in the last day with each recipient's delivery status. To loop in everyone from a thread in a new message, take the addresses from thread_participants. When the user wants to answer a message and get its conversation out of the Inbox in one go, respond_and_file sends the reply and then moves the thread to the mailbox given (usually the Archive).
+
+**Scheduling**: before proposing meeting times in a reply, call calendar_freebusy for the range under discussion (with the user's time_zone) and offer only slots it lists as free. To put something on the calendar, use calendar_event_create; for a message that announces a meeting or contains an invitation, email_to_event extracts the details and links the event back to the email (pass start or time_zone when the message is ambiguous). To accept, decline, or tentatively accept an invitation, use calendar_rsvp on the invitation email; it replies to the organizer over mail and needs no calendar support.
+
+**Tasks**: when a message asks the user to do something, email_to_task turns it into a task with the due date it mentions and a link back to the email; follow up by archiving the message (email_move) to keep the inbox clear. task_list shows what is open, soonest due first; task_create adds a task not tied to a message.
+
+**Notes**: to remember something across sessions (a user preference, a decision, a summary of a long thread), save it with note_create, tagging it for later lookup; before answering a question that past work may have covered, call note_search with a query or tag.
+
+**Watching**: to follow a mailbox or thread while working on something else, call watch_create with mailbox_id (new messages) or thread_id (new messages and flag changes, optionally limited to keywords). Events arrive as log notifications from jmap-mcp.watch with the matching email IDs, and stay at jmap://watch/{id} until read; fetch the emails with email_get. Delete watches with watch_delete when done. Watches end with the session; for standing rules that should act on new mail while no client is connected (file, flag, forward, post to a webhook, or run an operator script), use automation_create instead. For actions at a later time (send a draft at 9:00, snooze emails until Monday, a daily digest message, a weekly cleanup of old mail), use schedule_create.
+
+**Managing email**: use email_move to move between mailboxes, email_flag to mark as read/flagged/answered, email_delete to trash or permanently destroy, and email_restore to move trashed emails back where they were; on Stalwart, email_undelete recovers emails destroyed or purged from Trash. When the server stages permanent deletion, email_delete moves the emails to Trash and names a schedule that destroys them later; email_delete_cancel with that schedule_id takes it back. If a move, trash, flag, or label change was a mistake, undo_last reverses the most recent one of this session. Call keyword_list to discover custom keywords (user labels) in use before filtering on them. Before acting on a large selection, call email_estimate with the same filter to see how many emails and bytes it covers and how many JMAP calls it will take. To read through a whole mailbox or a large selection (summarizing old mail, say), open a cursor with email_batch_iterator and keep calling it with the cursor until it reports the end, rather than paging email_query yourself. To act on the same long list of emails more than once, store it with selection_set and pass selection (its name) to email_move, email_flag, email_delete, label_apply, or label_remove instead of repeating email_ids. To triage new mail, call email_triage_suggest with their IDs and, after the user confirms, apply each suggestion with the email_move, email_delete, or email_reply call it names.
+
+**Attachments**: to attach a file, upload it with attachment_upload and pass the returned blob ID in email_create attachments. email_get lists each email's attachments with their blob IDs; to look at an image attachment, call email_attachment_image (large images come back downscaled). pass an email ID (and blob ID when there are several) to email_attachment_url (available in HTTP mode only) to obtain a signed download URL. The URL expires 30 seconds after issuance — fetch it immediately with any HTTP client.
+
+**Labels**: on label-based backends (server started with -label-mode), use label_apply and label_remove to add or remove non-role mailboxes as labels without dropping Inbox/Archive membership; email_move replaces all memberships and should be avoided there.
+
+**Exporting**: email_render returns a standalone sanitized HTML (or PDF, if configured) rendering of a message as an embedded resource; thread_export returns a whole conversation as one markdown or HTML document, duplicates removed and quoted replies stripped.
+
+**Bounces**: for a non-delivery report, call email_bounce_info to get per-recipient status codes, remote MTA diagnostics, and the original sent email. When a message arrived late, email_trace lists its Received hops with the delay each added.
+
+**Managing mailboxes**: use mailbox_set to create, rename, reparent, or destroy mailboxes. To file an email, call mailbox_suggest with its ID for the mailboxes where similar messages live instead of reading the whole folder tree and guessing. For an overview of how mailboxes are aging and growing, call mailbox_report; each call also shows what changed since the previous one. Before reorganizing folders, save the tree with mailbox_snapshot; mailbox_diff later shows what was created, destroyed, renamed, or moved since.
+
+**Moving accounts**: config_export writes the mailbox tree, identities, and Sieve scripts as a JSON document; config_import on the other account (e.g. another endpoint) shows what it would create, and only after the user confirms call it again with apply=true.
+
+**Masked email** (Fastmail): when a signup or site needs an address, create one with masked_email_create (for_domain set to the site) instead of handing out the user's real address; masked_email_list finds existing ones and masked_email_disable stops mail to an address.
+
+**Quotas and administration**: quota_get reports the account's storage limits. On Stalwart servers started with -enable-stalwart-admin and an admin token, stalwart_principal_list/stalwart_principal_get inspect accounts, stalwart_quota_set changes a disk quota, and stalwart_undelete_list/stalwart_undelete_restore recover deleted items.
+
+**Sieve scripts**: use sieve_get to list or read scripts, sieve_set to create/update/destroy, sieve_validate to check syntax without saving. To turn how the user files a sender's or mailing list's mail into a server-side rule, call sieve_rule_learn to see the proposed rule, and only after the user confirms call it again with install=true.
+
+## Important notes
+
+- Call server_info once to learn what this deployment offers (enabled features, limits, backend subsystems) rather than probing tools that may be disabled.
+- All tool inputs use opaque string IDs. Get IDs from other tools first (mailbox_get, email_query, identity_get, sieve_get).
+- Results that mention IDs end with a fenced "json jmap-ids" block listing them by kind (ids, emails, threads, mailboxes, blobs, ...); take IDs from it rather than parsing the lines above.
+- When several JMAP backends are configured, every tool accepts an optional endpoint parameter; IDs are only valid on the endpoint that returned them.
+- email_query returns only IDs and total count; always follow up with email_get for content.
+- When an email_get response ends with continuation=seg-N, call email_get with only that continuation to read the rest instead of fetching the emails again.
+- To locate a message another system refers to by its Message-ID, call email_find_by_message_id instead of searching. To tie emails to a ticket or issue in another system, link them with email_ref_link (e.g. jira:PROJ-123) and find them again with email_ref_query.
+- email_submission_set may not be available — it requires the server to be started with -enable-send flag.
+- sieve_get, sieve_set, sieve_validate, sieve_rule_learn may not be available — they require the -enable-sieve flag and a JMAP server that advertises urn:ietf:params:jmap:sieve.
+`
+
+// annotation helpers
+func boolPtr(v bool) *bool { return &v }
+
+var (
+	readOnlyAnnotations = &mcp.ToolAnnotations{
+		ReadOnlyHint: true,
+	}
+	idempotentAnnotations = &mcp.ToolAnnotations{
+		DestructiveHint: boolPtr(false),
+		IdempotentHint:  true,
+	}
+	destructiveAnnotations = &mcp.ToolAnnotations{
+		DestructiveHint: boolPtr(true),
+	}
+	mutatingAnnotations = &mcp.ToolAnnotations{
+		DestructiveHint: boolPtr(false),
+	}
+)
+
+// registerResources registers the MCP resource templates.
+func (s *Server) registerResources() {
+	// Thread digests (Thread/get + Email/get, refreshed via Thread/changes)
+	s.mcp.AddResourceTemplate(threadSummaryTemplate, s.readThreadSummary)
+
+	// Watch events (pending notifications of watch_create watches)
+	s.mcp.AddResourceTemplate(watchTemplate, s.readWatch)
+
+	// Outbox (approval queue + EmailSubmission/query, refreshed via EmailSubmission/changes)
+	s.mcp.AddResource(outboxResource, s.readOutbox)
+}
+
+// registerTools registers all JMAP tools with the MCP server.
+func (s *Server) registerTools() {
+	// Mailbox tools (Mailbox/get, Mailbox/set)
+	addTool(s, mailboxGetTool, s.handleMailboxGet)
+	addTool(s, mailboxSetTool, s.handleMailboxSet)
+	addTool(s, mailboxSuggestTool, s.handleMailboxSuggest)
+	addTool(s, mailboxReportTool, s.handleMailboxReport)
+	addTool(s, mailboxSnapshotTool, s.handleMailboxSnapshot)
+	addTool(s, mailboxDiffTool, s.handleMailboxDiff)
+
+	// Email tools (Email/query, Email/get, Email/set convenience wrappers)
+	addTool(s, emailQueryTool, s.handleEmailQuery)
+	addTool(s, emailGetTool, s.handleEmailGet)
+	addTool(s, emailCreateTool, s.handleEmailCreate)
+	addTool(s, emailReplyTool, s.handleEmailReply)
+	addTool(s, replyContextTool, s.handleReplyContext)
+	addTool(s, threadParticipantsTool, s.handleThreadParticipants)
+	addTool(s, threadExportTool, s.handleThreadExport)
+	addTool(s, emailAwaitingReplyTool, s.handleEmailAwaitingReply)
+	addTool(s, emailSentUnansweredTool, s.handleEmailSentUnanswered)
+	addTool(s, inboxUnifiedTool, s.handleInboxUnified)
+	addTool(s, emailForwardTool, s.handleEmailForward)
+	addTool(s, emailMoveTool, s.handleEmailMove)
+	addTool(s, emailFlagTool, s.handleEmailFlag)
+	addTool(s, emailDeleteTool, s.handleEmailDelete)
+	addTool(s, emailRestoreTool, s.handleEmailRestore)
+	addTool(s, emailUndeleteTool, s.handleEmailUndelete)
+	addTool(s, undoLastTool, s.handleUndoLast)
+	addTool(s, emailRenderTool, s.handleEmailRender)
+	addTool(s, emailBounceInfoTool, s.handleEmailBounceInfo)
+	addTool(s, emailTraceTool, s.handleEmailTrace)
+	addTool(s, emailPreflightTool, s.handleEmailPreflight)
+	addTool(s, emailFindByMessageIDTool, s.handleEmailFindByMessageID)
+	addTool(s, emailRefLinkTool, s.handleEmailRefLink)
+	addTool(s, emailRefQueryTool, s.handleEmailRefQuery)
+
+	// Attachment tools (blob upload, image download)
+	addTool(s, attachmentUploadTool, s.handleAttachmentUpload)
+	addTool(s, emailAttachmentImageTool, s.handleEmailAttachmentImage)
+
+	// Keyword tools (Email/query + Email/get aggregation)
+	addTool(s, keywordListTool, s.handleKeywordList)
+	addTool(s, emailEstimateTool, s.handleEmailEstimate)
+	addTool(s, emailDigestTool, s.handleEmailDigest)
+	addTool(s, newsletterDigestTool, s.handleNewsletterDigest)
+	addTool(s, emailBatchIteratorTool, s.handleEmailBatchIterator)
+
+	// Correspondent tools (Email/query + Email/get, ContactCard lookup)
+	addTool(s, senderProfileTool, s.handleSenderProfile)
+	addTool(s, correspondentsTopTool, s.handleCorrespondentsTop)
+	addTool(s, replyLatencyTool, s.handleReplyLatency)
+	addTool(s, emailTriageSuggestTool, s.handleEmailTriageSuggest)
+
+	// Sender list tools (local VIP and muted lists per user; sender_mute can install a Sieve rule)
+	addTool(s, vipAddTool, s.handleVIPAdd)
+	addTool(s, vipListTool, s.handleVIPList)
+	addTool(s, senderMuteTool, s.handleSenderMute)
+
+	// Selection tools (named email ID lists per MCP session, taken by bulk tools)
+	addTool(s, selectionSetTool, s.handleSelectionSet)
+	addTool(s, selectionAddTool, s.handleSelectionAdd)
+	addTool(s, selectionClearTool, s.handleSelectionClear)
+
+	// Note tools (Email/set + Email/query in the Notes mailbox)
+	addTool(s, noteCreateTool, s.handleNoteCreate)
+	addTool(s, noteSearchTool, s.handleNoteSearch)
+
+	// Watch tools (push StateChanges diffed with Email/changes)
+	addTool(s, watchCreateTool, s.handleWatchCreate)
+	addTool(s, watchListTool, s.handleWatchList)
+	addTool(s, watchStatusTool, s.handleWatchStatus)
+	addTool(s, watchDeleteTool, s.handleWatchDelete)
+	addTool(s, automationCreateTool, s.handleAutomationCreate)
+	addTool(s, automationListTool, s.handleAutomationList)
+	addTool(s, automationDeleteTool, s.handleAutomationDelete)
+
+	// Schedule tools (actions run later, once or repeatedly, by the automation runner)
+	addTool(s, scheduleCreateTool, s.handleScheduleCreate)
+	addTool(s, scheduleListTool, s.handleScheduleList)
+	addTool(s, scheduleCancelTool, s.handleScheduleCancel)
+
+	// Identity tools (Identity/get)
+	addTool(s, identityGetTool, s.handleIdentityGet)
+
+	// Configuration tools (mailboxes, identities, and with -enable-sieve Sieve scripts)
+	addTool(s, configExportTool, s.handleConfigExport)
+	addTool(s, configImportTool, s.handleConfigImport)
+
+	// Quota tools (Quota/get, RFC 9425; fail gracefully without the capability)
+	addTool(s, quotaGetTool, s.handleQuotaGet)
+
+	// Deployment tools (version, feature gates, limits, and backend)
+	addTool(s, serverInfoTool, s.handleServerInfo)
+
+	// Calendar tools (JMAP Calendars; fail gracefully without the capability)
+	addTool(s, calendarFreeBusyTool, s.handleCalendarFreeBusy)
+	addTool(s, calendarEventCreateTool, s.handleCalendarEventCreate)
+	addTool(s, emailToEventTool, s.handleEmailToEvent)
+
+	// Task tools (JMAP Tasks; fail gracefully without the capability)
+	addTool(s, taskCreateTool, s.handleTaskCreate)
+	addTool(s, taskListTool, s.handleTaskList)
+	addTool(s, emailToTaskTool, s.handleEmailToTask)
+
+	// Masked email tools (Fastmail MaskedEmail extension; fail gracefully without the capability)
+	addTool(s, maskedEmailListTool, s.handleMaskedEmailList)
+	addTool(s, maskedEmailCreateTool, s.handleMaskedEmailCreate)
+	addTool(s, maskedEmailDisableTool, s.handleMaskedEmailDisable)
+
+	// Feature-gated: attachment_download requires stdio mode (local files)
+	if s.localFiles {
+		addTool(s, attachmentDownloadTool, s.handleAttachmentDownload)
+	}
+	// Feature-gated: email_attachment_url requires http mode (signed URL endpoint)
+	if s.attachmentURL != nil {
+		addTool(s, emailAttachmentURLTool, s.handleEmailAttachmentURL)
+	}
+
+	// Feature-gated: email_classify requires -classify-categories
+	if s.classifier != nil {
+		addTool(s, emailClassifyTool, s.handleEmailClassify)
+	}
+
+	// Feature-gated: email_delete_cancel requires -delete-delay
+	if s.deleteDelay > 0 {
+		addTool(s, emailDeleteCancelTool, s.handleEmailDeleteCancel)
+	}
+
+	// Feature-gated: label tools require -label-mode flag
+	if s.enableLabels {
+		addTool(s, labelApplyTool, s.handleLabelApply)
+		addTool(s, labelRemoveTool, s.handleLabelRemove)
+	}
+
+	// Feature-gated: email_submission_set requires -enable-send flag
+	if s.enableEmailSubmission {
+		addTool(s, emailSubmissionSetTool, s.handleEmailSubmissionSet)
+		addTool(s, calendarRSVPTool, s.handleCalendarRSVP)
+		addTool(s, respondAndFileTool, s.handleRespondAndFile)
+		if s.mailMerge != nil {
+			addTool(s, mailMergeTool, s.handleMailMerge)
+		}
+		if s.abuseAddress != "" {
+			addTool(s, emailReportAbuseTool, s.handleEmailReportAbuse)
+		}
+
+		// Approval workflow: -send-queue routes submissions through the outbox
+		if s.outbox != nil {
+			addTool(s, outboxListTool, s.handleOutboxList)
+			addTool(s, outboxApproveTool, s.handleOutboxApprove)
+			addTool(s, outboxRejectTool, s.handleOutboxReject)
+		}
+	}
+
+	// Feature-gated: Sieve tools require -enable-sieve flag
+	if s.enableSieve {
+		addTool(s, sieveGetTool, s.handleSieveGet)
+		addTool(s, sieveSetTool, s.handleSieveSet)
+		addTool(s, sieveValidateTool, s.handleSieveValidate)
+		addTool(s, sieveCapabilitiesTool, s.handleSieveCapabilities)
+		addTool(s, sieveHistoryTool, s.handleSieveHistory)
+		addTool(s, sieveRollbackTool, s.handleSieveRollback)
+		addTool(s, sieveRuleLearnTool, s.handleSieveRuleLearn)
+		addTool(s, vacationViaSieveTool, s.handleVacationViaSieve)
+	}
+
+	// Feature-gated: Stalwart management API tools require -enable-stalwart-admin
+	if s.enableStalwartAdmin {
+		addTool(s, stalwartPrincipalListTool, s.handleStalwartPrincipalList)
+		addTool(s, stalwartPrincipalGetTool, s.handleStalwartPrincipalGet)
+		addTool(s, stalwartQuotaSetTool, s.handleStalwartQuotaSet)
+		addTool(s, stalwartUndeleteListTool, s.handleStalwartUndeleteList)
+		addTool(s, stalwartUndeleteRestoreTool, s.handleStalwartUndeleteRestore)
+	}
+}
+
+// --- shared helpers ---
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}
+}
+
+func errorResult(err error) *mcp.CallToolResult {
+	result := &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+	}
+	result.StructuredContent = classifyError(err)
+	return result
+}
+
+func toJMAPIDSlice(ids []string) []jmap.ID {
+	result := make([]jmap.ID, len(ids))
+	for i, id := range ids {
+		result[i] = jmap.ID(id)
+	}
+	return result
+}
+
+// findMailboxByRole fetches all mailboxes and returns the ID of the one matching the given role.
+func (s *Server) findMailboxByRole(ctx context.Context, client JMAPClient, accountID jmap.ID, role mailbox.Role) (jmap.ID, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("mailbox lookup: %w", err)
+	}
+
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for Mailbox/get")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			if mb.Role == role {
+				return mb.ID, nil
+			}
+		}
+		return "", fmt.Errorf("no mailbox with role %q found", role)
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+}
@@ -0,0 +1,100 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_report_abuse ---
+
+type EmailReportAbuseInput struct {
+	EmailID string `json:"email_id" jsonschema:"ID of the suspicious email to report"`
+	Note    string `json:"note,omitempty" jsonschema:"What is wrong with the message (e.g. asks for credentials), placed in the report body"`
+}
+
+var emailReportAbuseTool = &mcp.Tool{
+	Name:        "email_report_abuse",
+	Description: "Report a phishing, spam, or otherwise abusive email in one call: forwards it unchanged as a message/rfc822 attachment, full headers included, to the abuse address the operator configured, then moves the original to Junk and marks it $junk. The report goes through the send policy and, when sends need approval, the outbox; the original is filed either way.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailReportAbuse(ctx context.Context, _ *mcp.CallToolRequest, in EmailReportAbuseInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	note := in.Note
+	if note == "" {
+		note = "I am reporting the attached message as phishing or abuse."
+	}
+	note += "\n\nThe original message is attached unchanged, with its full headers."
+	fwd, err := s.createForward(ctx, client, EmailForwardInput{
+		EmailID:      in.EmailID,
+		To:           []string{s.abuseAddress},
+		Body:         note,
+		AsAttachment: true,
+	})
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if fwd.id == "" {
+		return errorResult(fmt.Errorf("the server did not report the abuse report draft")), nil, nil
+	}
+
+	var sb strings.Builder
+	if s.outbox != nil {
+		res, _, _ := s.enqueueSubmission(ctx, client, accountID, fwd.id, "", false)
+		if res.IsError {
+			return res, nil, nil
+		}
+		fmt.Fprintf(&sb, "Abuse report on %s to %s queued for approval [draft: %s]; it is sent once approved with outbox_approve\n", in.EmailID, s.abuseAddress, fwd.id)
+	} else {
+		if err := s.submitDraft(ctx, client, accountID, fwd.id, "", false); err != nil {
+			return errorResult(err), nil, nil
+		}
+		fmt.Fprintf(&sb, "Reported %s to %s\n", in.EmailID, s.abuseAddress)
+	}
+
+	// The report is out; filing the original is best effort from here on.
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		fmt.Fprintf(&sb, "The original was not moved to Junk: %v\n", err)
+		return textResult(sb.String()), nil, nil
+	}
+	patch := jmap.Patch{"keywords/$junk": true, "keywords/$notjunk": nil}
+	var junk *mailbox.Mailbox
+	for _, mb := range mailboxes {
+		if mb.Role == mailbox.RoleJunk {
+			junk = mb
+		}
+	}
+	if junk != nil {
+		patch["mailboxIds"] = map[jmap.ID]bool{junk.ID: true}
+	}
+	if err := updateEmails(ctx, client, accountID, map[jmap.ID]jmap.Patch{jmap.ID(in.EmailID): patch}, "filing the original"); err != nil {
+		fmt.Fprintf(&sb, "The original was not moved to Junk: %v\n", err)
+		return textResult(sb.String()), nil, nil
+	}
+	if junk != nil {
+		fmt.Fprintf(&sb, "Moved the original to %s and marked it $junk\n", mailboxPath(junk, mailboxes))
+	} else {
+		sb.WriteString("Marked the original $junk (no mailbox has the junk role)\n")
+	}
+	sb.WriteString(formatDraftDefaults(fwd.added))
+	return textResult(sb.String()), nil, nil
+}
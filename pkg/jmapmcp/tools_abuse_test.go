@@ -0,0 +1,67 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEmailReportAbuse(t *testing.T) {
+	s, fake := newFakeServer(t, WithEmailSubmission(), WithAbuseAddress("abuse@example.com"))
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Mailbox", map[string]any{"id": "junk", "name": "Junk", "role": "junk"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	blob := fake.AddBlob([]byte("Subject: Verify your account\r\n\r\nLog in here\r\n"))
+	fake.Add("Email", map[string]any{
+		"id": "e1", "subject": "Verify your account", "blobId": blob, "size": 45,
+		"mailboxIds": map[string]any{"inbox": true}, "keywords": map[string]any{"$notjunk": true},
+	})
+
+	res, _, _ := s.handleEmailReportAbuse(context.Background(), nil, EmailReportAbuseInput{EmailID: "e1", Note: "Fake bank login page."})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Reported e1 to abuse@example.com") || !strings.Contains(out, "Moved the original to Junk") {
+		t.Fatalf("email_report_abuse = %s", out)
+	}
+
+	original := fake.Object("Email", "e1")
+	if mbs := original["mailboxIds"].(map[string]any); len(mbs) != 1 || mbs["junk"] != true {
+		t.Errorf("original mailboxIds = %v, want junk", mbs)
+	}
+	if kw := original["keywords"].(map[string]any); kw["$junk"] != true || kw["$notjunk"] != nil {
+		t.Errorf("original keywords = %v", kw)
+	}
+	var report map[string]any
+	for _, id := range fake.Objects("Email") {
+		if e := fake.Object("Email", id); e["subject"] == "Fwd: Verify your account" {
+			report = e
+		}
+	}
+	if report == nil || report["mailboxIds"].(map[string]any)["sent"] != true {
+		t.Fatalf("report not sent: %v", report)
+	}
+	parts := report["attachments"].([]any)
+	if part := parts[0].(map[string]any); len(parts) != 1 || part["type"] != "message/rfc822" || part["blobId"] != blob {
+		t.Errorf("report attachments = %v", parts)
+	}
+	if body := report["bodyValues"].(map[string]any)["body"].(map[string]any)["value"].(string); !strings.HasPrefix(body, "Fake bank login page.") {
+		t.Errorf("report body = %q", body)
+	}
+}
+
+func TestEmailReportAbuseRegistration(t *testing.T) {
+	for _, tc := range []struct {
+		opts []Option
+		want bool
+	}{
+		{[]Option{WithEmailSubmission(), WithAbuseAddress("abuse@example.com")}, true},
+		{[]Option{WithAbuseAddress("abuse@example.com")}, false},
+		{[]Option{WithEmailSubmission()}, false},
+	} {
+		s, _ := newFakeServer(t, tc.opts...)
+		if _, ok := s.toolCalls["email_report_abuse"]; ok != tc.want {
+			t.Errorf("email_report_abuse registered = %v, want %v", ok, tc.want)
+		}
+	}
+}
@@ -0,0 +1,556 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/blob"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_attachment_url ---
+
+type EmailAttachmentURLInput struct {
+	EmailID string `json:"email_id" jsonschema:"ID of the email containing the attachment"`
+	BlobID  string `json:"blob_id,omitempty" jsonschema:"Blob ID of the attachment to download. Optional when the email has exactly one attachment. Blob IDs are listed by email_get."`
+}
+
+var emailAttachmentURLTool = &mcp.Tool{
+	Name:        "email_attachment_url",
+	Description: "Return a signed, disposable URL that streams an email attachment directly from the mail server, also as a resource link a client can offer the user as a download. The URL requires no authentication, works for ONE download, and EXPIRES shortly after issuance (30 seconds unless the operator set a longer life; the result says when) — fetch it or hand it to the user immediately, do not store it for later. Use email_get to list attachments and their blob IDs.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailAttachmentURL(ctx context.Context, _ *mcp.CallToolRequest, in EmailAttachmentURLInput) (*mcp.CallToolResult, any, error) {
+	client, accountID, part, err := s.fetchAttachmentPart(ctx, in.EmailID, in.BlobID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	base := s.externalURL
+	if base == "" {
+		base = BaseURLFromContext(ctx)
+	}
+	if base == "" {
+		return errorResult(fmt.Errorf("no external base URL available; signed attachment URLs require http mode")), nil, nil
+	}
+	// Blobs are immutable, so a scan now covers the download the URL
+	// serves.
+	scanNote, err := s.scanAttachment(ctx, client, accountID, part, nil)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	expiresAt := time.Now().Add(s.attachmentURLTTL).UTC()
+	opaque, err := s.attachmentURL.seal(&attachmentClaims{
+		Token:    token,
+		Endpoint: EndpointFromContext(ctx),
+		Account:  string(accountID),
+		Blob:     string(part.BlobID),
+		Name:     part.Name,
+		Type:     part.Type,
+		Exp:      expiresAt.Unix(),
+	})
+	if err != nil {
+		return errorResult(fmt.Errorf("seal attachment URL: %w", err)), nil, nil
+	}
+
+	name := part.Name
+	if name == "" {
+		name = "(unnamed)"
+	}
+	link := strings.TrimSuffix(base, "/") + "/attachments/" + opaque
+	text := fmt.Sprintf(
+		"Attachment %s (%s, %d bytes) available for one download in the next %d seconds at:\n%s\nFetch it now; the link expires at %s.",
+		name, part.Type, part.Size, int(s.attachmentURLTTL.Seconds()),
+		link, s.locale.dateTime(expiresAt),
+	)
+	if scanNote != "" {
+		text += "\n" + scanNote
+	}
+	size := int64(part.Size)
+	return &mcp.CallToolResult{Content: []mcp.Content{
+		&mcp.TextContent{Text: text},
+		&mcp.ResourceLink{URI: link, Name: name, MIMEType: part.Type, Size: &size},
+	}}, nil, nil
+}
+
+// --- email_attachment_image ---
+
+type EmailAttachmentImageInput struct {
+	EmailID  string `json:"email_id" jsonschema:"ID of the email containing the image"`
+	BlobID   string `json:"blob_id,omitempty" jsonschema:"Blob ID of the image attachment. Optional when the email has exactly one attachment. Blob IDs are listed by email_get."`
+	Original bool   `json:"original,omitempty" jsonschema:"Return the image as attached, without downscaling"`
+}
+
+var emailAttachmentImageTool = &mcp.Tool{
+	Name:        "email_attachment_image",
+	Description: "Return an image attachment (JPEG, PNG, GIF, or WebP) as image content you can look at. Images larger than the server's maximum dimension are downscaled and re-encoded as JPEG to fit model input limits; set original to get the image as attached. Use email_get to list attachments and their blob IDs.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailAttachmentImage(ctx context.Context, _ *mcp.CallToolRequest, in EmailAttachmentImageInput) (*mcp.CallToolResult, any, error) {
+	client, accountID, part, err := s.fetchAttachmentPart(ctx, in.EmailID, in.BlobID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if !strings.HasPrefix(part.Type, "image/") {
+		return errorResult(invalidArgument("attachment %s is %s, not an image", part.BlobID, part.Type)), nil, nil
+	}
+	if part.Size > maxImageBytes {
+		return errorResult(invalidArgument("image %s is %s, over the %s limit; download it with email_attachment_url", part.BlobID, s.locale.size(int64(part.Size)), s.locale.size(maxImageBytes))), nil, nil
+	}
+
+	body, err := client.Download(ctx, accountID, part.BlobID)
+	if err != nil {
+		return errorResult(fmt.Errorf("download %s: %w", part.BlobID, err)), nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxImageBytes+1))
+	body.Close()
+	if err != nil {
+		return errorResult(fmt.Errorf("download %s: %w", part.BlobID, err)), nil, nil
+	}
+	if len(data) > maxImageBytes {
+		return errorResult(invalidArgument("image %s is over the %s limit; download it with email_attachment_url", part.BlobID, s.locale.size(maxImageBytes))), nil, nil
+	}
+	scanNote, err := s.scanAttachment(ctx, client, accountID, part, data)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	name := part.Name
+	if name == "" {
+		name = "(unnamed)"
+	}
+	img := &mcp.ImageContent{Data: data, MIMEType: part.Type}
+	desc := fmt.Sprintf("Image %s (%s, %s) [blob: %s]", name, part.Type, s.locale.size(int64(len(data))), part.BlobID)
+	if w, h, err := imageSize(data); err == nil {
+		desc += fmt.Sprintf(", %dx%d", w, h)
+	}
+	if !in.Original {
+		thumb, err := makeThumbnail(data, s.imageMaxDimension, s.imageJPEGQuality)
+		switch {
+		case err != nil && part.Type != "image/webp":
+			return errorResult(fmt.Errorf("image %s: %w", part.BlobID, err)), nil, nil
+		case thumb != nil:
+			img = &mcp.ImageContent{Data: thumb.Data, MIMEType: "image/jpeg"}
+			desc += fmt.Sprintf("; downscaled to %dx%d JPEG (%s). Call with original=true, or email_attachment_url, for the full image.", thumb.Width, thumb.Height, s.locale.size(int64(len(thumb.Data))))
+		}
+	}
+	if scanNote != "" {
+		desc += "\n" + scanNote
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: desc}, img}}, nil, nil
+}
+
+// --- attachment_upload ---
+
+type AttachmentUploadInput struct {
+	Name          string `json:"name,omitempty" jsonschema:"File name of the attachment (e.g. report.pdf); with path, defaults to the file's name"`
+	Type          string `json:"type,omitempty" jsonschema:"Media type of the attachment (e.g. application/pdf); with path, defaults to the type of the file's extension or content"`
+	ContentBase64 string `json:"content_base64,omitempty" jsonschema:"File content, base64-encoded"`
+	Path          string `json:"path,omitempty" jsonschema:"Instead of content_base64: a local file inside the client's filesystem roots (stdio mode only)"`
+}
+
+var attachmentUploadTool = &mcp.Tool{
+	Name:        "attachment_upload",
+	Description: "Upload a file to the mail server as a blob for attaching to a draft, given base64-encoded or, in stdio mode, as a path inside the client's filesystem roots. Returns a blob ID to pass in the attachments of email_create. Uploads are checked against the server's size limits, the account's storage quota, and the operator's attachment policy.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleAttachmentUpload(ctx context.Context, req *mcp.CallToolRequest, in AttachmentUploadInput) (*mcp.CallToolResult, any, error) {
+	if in.Path != "" && in.ContentBase64 != "" {
+		return errorResult(invalidArgument("give content_base64 or path, not both")), nil, nil
+	}
+	if in.Path == "" && (in.Name == "" || in.Type == "") {
+		return errorResult(invalidArgument("name and type are required")), nil, nil
+	}
+
+	var data []byte
+	var err error
+	if in.Path != "" {
+		var path string
+		if path, data, err = s.readLocalFile(ctx, req, in.Path, maxLocalFileBytes); err != nil {
+			return errorResult(err), nil, nil
+		}
+		if in.Name == "" {
+			in.Name = filepath.Base(path)
+		}
+		if in.Type == "" {
+			in.Type = localFileType(path, data)
+		}
+	} else if data, err = base64.StdEncoding.DecodeString(in.ContentBase64); err != nil {
+		return errorResult(fmt.Errorf("decode content_base64: %w", err)), nil, nil
+	}
+
+	if err := s.attachmentPolicy.check(in.Name, in.Type, len(data)); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	blobID, err := uploadAttachment(ctx, client, accountID, in.Name, data)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	return textResult(fmt.Sprintf("Uploaded %s (%s, %d bytes) [blob: %s]\nPass it to email_create as {\"blob_id\": %q, \"name\": %q, \"type\": %q}.",
+		in.Name, in.Type, len(data), blobID, blobID, in.Name, in.Type)), nil, nil
+}
+
+// uploadAttachment uploads data, the attachment name, after checking it
+// against the server's upload limit and, when large, the account's quota.
+func uploadAttachment(ctx context.Context, client JMAPClient, accountID jmap.ID, name string, data []byte) (jmap.ID, error) {
+	if err := checkUploadSize(client.Session(), "attachment "+name, len(data)); err != nil {
+		return "", err
+	}
+	if len(data) >= minQuotaCheckUpload {
+		if err := checkQuota(ctx, client, accountID, mailGrowth{what: "upload of " + name, octets: uint64(len(data))}); err != nil {
+			return "", err
+		}
+	}
+	uploadResp, err := client.Upload(ctx, accountID, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("upload attachment: %w", err)
+	}
+	return uploadResp.ID, nil
+}
+
+// localFileType is the media type of the local file path holding data:
+// that of its extension, or else sniffed from its content.
+func localFileType(path string, data []byte) string {
+	t := mime.TypeByExtension(filepath.Ext(path))
+	if t == "" {
+		t = http.DetectContentType(data)
+	}
+	t, _, _ = strings.Cut(t, ";")
+	return t
+}
+
+// --- attachment_download ---
+
+type AttachmentDownloadInput struct {
+	EmailID string `json:"email_id" jsonschema:"ID of the email containing the attachment"`
+	BlobID  string `json:"blob_id,omitempty" jsonschema:"Blob ID of the attachment. Optional when the email has exactly one attachment. Blob IDs are listed by email_get."`
+	Path    string `json:"path" jsonschema:"Where to save it inside the client's filesystem roots: a new file, or an existing directory to save it in under the attachment's name"`
+}
+
+var attachmentDownloadTool = &mcp.Tool{
+	Name:        "attachment_download",
+	Description: "Save an email attachment as a file on the user's machine, inside the MCP client's filesystem roots. Existing files are never replaced. Returns the path written; the content does not enter the conversation.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleAttachmentDownload(ctx context.Context, req *mcp.CallToolRequest, in AttachmentDownloadInput) (*mcp.CallToolResult, any, error) {
+	if in.Path == "" {
+		return errorResult(invalidArgument("path is required")), nil, nil
+	}
+	client, accountID, part, err := s.fetchAttachmentPart(ctx, in.EmailID, in.BlobID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	data, err := downloadBlob(ctx, client, accountID, part.BlobID, maxLocalFileBytes)
+	if err != nil {
+		return errorResult(fmt.Errorf("download %s: %w", part.BlobID, err)), nil, nil
+	}
+	scanNote, err := s.scanAttachment(ctx, client, accountID, part, data)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	name := part.Name
+	if name == "" {
+		name = "attachment-" + string(part.BlobID)
+	}
+	path, err := s.writeLocalFile(ctx, req, in.Path, name, data)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	text := fmt.Sprintf("Saved %s (%s, %s) [blob: %s] to %s", name, part.Type, s.locale.size(int64(len(data))), part.BlobID, path)
+	if scanNote != "" {
+		text += "\n" + scanNote
+	}
+	return textResult(text), nil, nil
+}
+
+// --- shared attachment helpers ---
+
+// fetchAttachmentPart resolves an email's attachment part by blob ID (or the
+// sole attachment), returning the authenticated client and account for the
+// subsequent blob download.
+func (s *Server) fetchAttachmentPart(ctx context.Context, emailID, blobID string) (JMAPClient, jmap.ID, *email.BodyPart, error) {
+	if emailID == "" {
+		return nil, "", nil, fmt.Errorf("email_id is required")
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return nil, "", nil, fmt.Errorf("no primary mail account")
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        []jmap.ID{jmap.ID(emailID)},
+		Properties: []string{"id", "attachments"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if len(resp.Responses) == 0 {
+		return nil, "", nil, fmt.Errorf("empty response for Email/get")
+	}
+
+	var attachments []*email.BodyPart
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return nil, "", nil, notFoundError([]string{emailID}, "email not found: %s", emailID)
+		}
+		attachments = args.List[0].Attachments
+	case *jmap.MethodError:
+		return nil, "", nil, args
+	default:
+		return nil, "", nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	part, err := selectAttachment(attachments, blobID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return client, accountID, part, nil
+}
+
+// selectAttachment picks the attachment matching blobID, or the sole
+// attachment when blobID is empty.
+func selectAttachment(attachments []*email.BodyPart, blobID string) (*email.BodyPart, error) {
+	if len(attachments) == 0 {
+		return nil, fmt.Errorf("email has no attachments")
+	}
+	if blobID == "" {
+		if len(attachments) == 1 {
+			return attachments[0], nil
+		}
+		return nil, fmt.Errorf("email has %d attachments, blob_id is required:\n%s", len(attachments), formatAttachmentList(attachments, ""))
+	}
+	for _, part := range attachments {
+		if string(part.BlobID) == blobID {
+			return part, nil
+		}
+	}
+	return nil, fmt.Errorf("no attachment with blob ID %s, available:\n%s", blobID, formatAttachmentList(attachments, ""))
+}
+
+// formatAttachmentList renders one line per attachment, prefixed with indent.
+func formatAttachmentList(attachments []*email.BodyPart, indent string) string {
+	return formatAttachmentMedia(attachments, indent, nil)
+}
+
+// formatAttachmentMedia is formatAttachmentList with the media metadata of
+// attachmentMedia appended to the lines of the attachments it describes.
+func formatAttachmentMedia(attachments []*email.BodyPart, indent string, media map[jmap.ID]string) string {
+	var sb strings.Builder
+	for i, part := range attachments {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		name := part.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		fmt.Fprintf(&sb, "%s%s (%s, %d bytes) [blob: %s]", indent, name, part.Type, part.Size, part.BlobID)
+		if m := media[part.BlobID]; m != "" {
+			fmt.Fprintf(&sb, " — %s", m)
+		}
+	}
+	return sb.String()
+}
+
+// AttachmentRef references a blob to attach to a draft: an upload from
+// attachment_upload, or an attachment of an email already in the account,
+// which is attached without downloading and uploading it again.
+type AttachmentRef struct {
+	BlobID string `json:"blob_id,omitempty" jsonschema:"Blob ID returned by attachment_upload, or of an attachment shown by email_get"`
+	Path   string `json:"path,omitempty" jsonschema:"Instead of blob_id: a local file inside the client's filesystem roots, uploaded with the draft (stdio mode only)"`
+	Name   string `json:"name,omitempty" jsonschema:"File name shown to recipients (default: the name on the email the blob is attached to, or of the local file)"`
+	Type   string `json:"type,omitempty" jsonschema:"Media type, e.g. application/pdf (default: the type on the email the blob is attached to, or of the local file's extension or content)"`
+	CID    string `json:"cid,omitempty" jsonschema:"Content ID to show an image inline in the HTML body instead of as an attachment, e.g. chart1 for <img src=\"cid:chart1\">"`
+}
+
+// uploadLocalAttachments uploads the local files refs name by path, as
+// attachment_upload does, and returns refs with their blob IDs, names, and
+// types filled in.
+func (s *Server) uploadLocalAttachments(ctx context.Context, req *mcp.CallToolRequest, client JMAPClient, accountID jmap.ID, refs []AttachmentRef) ([]AttachmentRef, error) {
+	out := slices.Clone(refs)
+	for i, ref := range out {
+		if ref.Path == "" {
+			continue
+		}
+		if ref.BlobID != "" {
+			return nil, invalidArgument("attachment %s: give blob_id or path, not both", ref.Path)
+		}
+		path, data, err := s.readLocalFile(ctx, req, ref.Path, maxLocalFileBytes)
+		if err != nil {
+			return nil, err
+		}
+		if ref.Name == "" {
+			ref.Name = filepath.Base(path)
+		}
+		if ref.Type == "" {
+			ref.Type = localFileType(path, data)
+		}
+		if err := s.attachmentPolicy.check(ref.Name, ref.Type, len(data)); err != nil {
+			return nil, err
+		}
+		blobID, err := uploadAttachment(ctx, client, accountID, ref.Name, data)
+		if err != nil {
+			return nil, err
+		}
+		ref.BlobID, ref.Path = string(blobID), ""
+		out[i] = ref
+	}
+	return out, nil
+}
+
+// attachmentParts resolves refs, checks them against the attachment policy,
+// and converts them to draft body parts. On servers with Blob/lookup (RFC
+// 9404) every blob must exist, and a blob attached to an email takes its
+// missing name and type from that attachment, whose size the policy then
+// checks too; uploads are size-checked at upload.
+func (s *Server) attachmentParts(ctx context.Context, client JMAPClient, accountID jmap.ID, refs []AttachmentRef) ([]*email.BodyPart, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	for _, ref := range refs {
+		if ref.BlobID == "" {
+			return nil, invalidArgument("attachment blob_id or path is required")
+		}
+	}
+	known, err := lookupAttachmentBlobs(ctx, client, accountID, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []*email.BodyPart
+	for _, ref := range refs {
+		size := -1
+		if part := known[jmap.ID(ref.BlobID)]; part != nil {
+			if ref.Name == "" {
+				ref.Name = part.Name
+			}
+			if ref.Type == "" {
+				ref.Type = part.Type
+			}
+			size = int(part.Size)
+		}
+		if ref.Name == "" || ref.Type == "" {
+			return nil, invalidArgument("attachment %s: name and type are required unless the blob is an attachment of an email in the account", ref.BlobID)
+		}
+		if err := s.attachmentPolicy.check(ref.Name, ref.Type, size); err != nil {
+			return nil, err
+		}
+		part := &email.BodyPart{
+			BlobID:      jmap.ID(ref.BlobID),
+			Name:        ref.Name,
+			Type:        ref.Type,
+			Disposition: "attachment",
+		}
+		if ref.CID != "" {
+			part.CID, part.Disposition = inlineCID(ref.CID), "inline"
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// lookupAttachmentBlobs checks that the blobs of refs exist with
+// Blob/lookup and returns, for each blob attached to an email, that
+// attachment, fetching the emails in the same request. Without the blob
+// capability nothing is checked and the map is empty.
+func lookupAttachmentBlobs(ctx context.Context, client JMAPClient, accountID jmap.ID, refs []AttachmentRef) (map[jmap.ID]*email.BodyPart, error) {
+	known := make(map[jmap.ID]*email.BodyPart)
+	if _, ok := client.Session().RawCapabilities[blob.URI]; !ok {
+		return known, nil
+	}
+
+	ids := make([]jmap.ID, 0, len(refs))
+	for _, ref := range refs {
+		if !slices.Contains(ids, jmap.ID(ref.BlobID)) {
+			ids = append(ids, jmap.ID(ref.BlobID))
+		}
+	}
+	req := &jmap.Request{Context: ctx}
+	lookupCallID := req.Invoke(&blob.Lookup{Account: accountID, TypeNames: []string{"Email"}, IDs: ids})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: lookupCallID, Name: "Blob/lookup", Path: "/list/*/matchedIds/Email"},
+		Properties:   []string{"id", "attachments"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 2 {
+		return nil, fmt.Errorf("missing Email/get response in Blob/lookup chain")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *blob.LookupResponse:
+		if len(args.NotFound) > 0 {
+			return nil, notFoundError(args.NotFound, "attachment blob not found: %s", strings.Join(idList(args.NotFound), ", "))
+		}
+	case *jmap.MethodError:
+		// The server advertises the capability but cannot look up
+		// emails' blobs; attach unchecked as without it.
+		return known, nil
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		for _, e := range args.List {
+			for _, part := range e.Attachments {
+				if known[part.BlobID] == nil && slices.Contains(ids, part.BlobID) {
+					known[part.BlobID] = part
+				}
+			}
+		}
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+	return known, nil
+}
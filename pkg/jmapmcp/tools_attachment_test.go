@@ -0,0 +1,191 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/blob"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestSelectAttachment(t *testing.T) {
+	one := &email.BodyPart{BlobID: "b1", Name: "a.pdf", Type: "application/pdf", Size: 10}
+	two := &email.BodyPart{BlobID: "b2", Name: "b.png", Type: "image/png", Size: 20}
+
+	t.Run("no attachments", func(t *testing.T) {
+		if _, err := selectAttachment(nil, ""); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("sole attachment without blob_id", func(t *testing.T) {
+		part, err := selectAttachment([]*email.BodyPart{one}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part != one {
+			t.Fatalf("got %v, want %v", part, one)
+		}
+	})
+
+	t.Run("multiple attachments require blob_id", func(t *testing.T) {
+		_, err := selectAttachment([]*email.BodyPart{one, two}, "")
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.Contains(err.Error(), "b.png") {
+			t.Fatalf("error should list attachments, got: %v", err)
+		}
+	})
+
+	t.Run("match by blob_id", func(t *testing.T) {
+		part, err := selectAttachment([]*email.BodyPart{one, two}, "b2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part != two {
+			t.Fatalf("got %v, want %v", part, two)
+		}
+	})
+
+	t.Run("unknown blob_id", func(t *testing.T) {
+		_, err := selectAttachment([]*email.BodyPart{one, two}, "nope")
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.Contains(err.Error(), "a.pdf") {
+			t.Fatalf("error should list attachments, got: %v", err)
+		}
+	})
+}
+
+func TestFormatAttachmentList(t *testing.T) {
+	parts := []*email.BodyPart{
+		{BlobID: "b1", Name: "report.pdf", Type: "application/pdf", Size: 1234},
+		{BlobID: "b2", Type: "image/png", Size: 20},
+	}
+	got := formatAttachmentList(parts, "  ")
+	want := "  report.pdf (application/pdf, 1234 bytes) [blob: b1]\n  (unnamed) (image/png, 20 bytes) [blob: b2]"
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEmailCreateReusesReceivedAttachment(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.AddCapability(string(blob.URI), map[string]any{})
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Report",
+		"attachments": []any{map[string]any{"blobId": "Gpdf", "name": "report.pdf", "type": "application/pdf", "size": 1234}},
+	})
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailCreate(ctx, nil, EmailCreateInput{
+		To: []string{"bob@example.org"}, Subject: "Fwd: Report", Body: "Here it is.",
+		Attachments: []AttachmentRef{{BlobID: "Gpdf"}},
+	})
+	if res.IsError {
+		t.Fatalf("email_create: %s", resultText(res))
+	}
+	var draft map[string]any
+	for _, id := range fake.Objects("Email") {
+		if e := fake.Object("Email", id); e["subject"] == "Fwd: Report" {
+			draft = e
+		}
+	}
+	parts, _ := draft["attachments"].([]any)
+	if len(parts) != 1 {
+		t.Fatalf("draft attachments = %v", draft["attachments"])
+	}
+	part := parts[0].(map[string]any)
+	if part["blobId"] != "Gpdf" || part["name"] != "report.pdf" || part["type"] != "application/pdf" {
+		t.Errorf("attachment = %v, want the received part's blob, name, and type", part)
+	}
+
+	res, _, _ = s.handleEmailCreate(ctx, nil, EmailCreateInput{
+		To: []string{"bob@example.org"}, Subject: "Missing", Body: "x",
+		Attachments: []AttachmentRef{{BlobID: "Gnope", Name: "a.pdf", Type: "application/pdf"}},
+	})
+	if te, ok := res.StructuredContent.(*toolError); !ok || te.Code != codeNotFound || len(te.IDs) != 1 || te.IDs[0] != "Gnope" {
+		t.Errorf("unknown blob = %#v, want not_found [Gnope]", res.StructuredContent)
+	}
+}
+
+func TestEmailCreateAttachmentWithoutBlobLookup(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	blobID := fake.AddBlob([]byte("data"))
+
+	res, _, _ := s.handleEmailCreate(context.Background(), nil, EmailCreateInput{
+		To: []string{"bob@example.org"}, Subject: "s", Body: "b",
+		Attachments: []AttachmentRef{{BlobID: blobID}},
+	})
+	if te, ok := res.StructuredContent.(*toolError); !ok || te.Code != codeInvalidArguments {
+		t.Fatalf("attachment without name or type = %#v, want invalid_arguments", res.StructuredContent)
+	}
+
+	res, _, _ = s.handleEmailCreate(context.Background(), nil, EmailCreateInput{
+		To: []string{"bob@example.org"}, Subject: "s", Body: "b",
+		Attachments: []AttachmentRef{{BlobID: blobID, Name: "a.txt", Type: "text/plain"}},
+	})
+	if res.IsError {
+		t.Fatalf("email_create: %s", resultText(res))
+	}
+	for _, c := range fake.Calls() {
+		if c == "Blob/lookup" {
+			t.Error("Blob/lookup called without the blob capability")
+		}
+	}
+}
+
+func TestEmailAttachmentImage(t *testing.T) {
+	s, fake := newFakeServer(t, WithImageThumbnails(100, 80))
+	photo := fake.AddBlob(pngImage(t, 300, 200, color.NRGBA{B: 255, A: 255}))
+	icon := fake.AddBlob(pngImage(t, 50, 50, color.NRGBA{G: 255, A: 255}))
+	pdf := fake.AddBlob([]byte("%PDF-1.4"))
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Photos",
+		"attachments": []any{
+			map[string]any{"blobId": photo, "name": "photo.png", "type": "image/png", "size": 1000},
+			map[string]any{"blobId": icon, "name": "icon.png", "type": "image/png", "size": 100},
+			map[string]any{"blobId": pdf, "name": "a.pdf", "type": "application/pdf", "size": 8},
+		},
+	})
+	ctx := context.Background()
+
+	imageOf := func(res *mcp.CallToolResult) *mcp.ImageContent {
+		t.Helper()
+		if res.IsError || len(res.Content) != 2 {
+			t.Fatalf("result = %s", resultText(res))
+		}
+		return res.Content[1].(*mcp.ImageContent)
+	}
+
+	res, _, _ := s.handleEmailAttachmentImage(ctx, nil, EmailAttachmentImageInput{EmailID: "m1", BlobID: photo})
+	if img := imageOf(res); img.MIMEType != "image/jpeg" {
+		t.Errorf("large image MIME type = %s, want image/jpeg", img.MIMEType)
+	}
+	if out := resultText(res); !strings.Contains(out, "300x200") || !strings.Contains(out, "downscaled to 100x66") {
+		t.Errorf("description = %s", out)
+	}
+
+	res, _, _ = s.handleEmailAttachmentImage(ctx, nil, EmailAttachmentImageInput{EmailID: "m1", BlobID: photo, Original: true})
+	if img := imageOf(res); img.MIMEType != "image/png" || !bytes.Equal(img.Data, fake.Blob(photo)) {
+		t.Error("original=true did not return the attached image")
+	}
+	res, _, _ = s.handleEmailAttachmentImage(ctx, nil, EmailAttachmentImageInput{EmailID: "m1", BlobID: icon})
+	if img := imageOf(res); !bytes.Equal(img.Data, fake.Blob(icon)) {
+		t.Error("small image was re-encoded")
+	}
+
+	res, _, _ = s.handleEmailAttachmentImage(ctx, nil, EmailAttachmentImageInput{EmailID: "m1", BlobID: pdf})
+	if te, ok := res.StructuredContent.(*toolError); !ok || te.Code != codeInvalidArguments {
+		t.Errorf("pdf attachment = %s, want invalid_arguments", resultText(res))
+	}
+}
@@ -0,0 +1,216 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- automation_create ---
+
+type AutomationCreateInput struct {
+	Name      string `json:"name,omitempty" jsonschema:"Short description shown by automation_list"`
+	MailboxID string `json:"mailbox_id" jsonschema:"Mailbox whose new messages the automation acts on (e.g. the Inbox ID)"`
+	From      string `json:"from,omitempty" jsonschema:"Only messages whose sender name or address contains this (case-insensitive)"`
+	To        string `json:"to,omitempty" jsonschema:"Only messages with a To or Cc recipient containing this"`
+	Subject   string `json:"subject,omitempty" jsonschema:"Only messages whose subject contains this"`
+	Action    string `json:"action" jsonschema:"file (move to the target mailbox), flag (set the target keyword, e.g. $flagged), forward (forward to the target address and send; needs -enable-send), or webhook (POST the matches as JSON to the target URL), or script (run the operator's script named target on the matches), or classify (record the category the operator's classification endpoint picks as a category-<name> keyword, as email_classify does; no target)"`
+	Target    string `json:"target" jsonschema:"Mailbox ID, keyword, email address, http(s) URL, or script name, according to action; omitted for classify"`
+}
+
+var automationCreateTool = &mcp.Tool{
+	Name:        "automation_create",
+	Description: "Create an automation that acts on new mail on its own, with no MCP client attached: when messages arrive in mailbox_id and match the optional from/to/subject conditions, it files, flags, forwards, posts them to a webhook, runs one of the operator's scripts on them, or classifies them. Automations are saved with the server and run where jmap-mcp runs them (-run-automations or -mode daemon) with its configured token; automation_list shows whether they do.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleAutomationCreate(ctx context.Context, _ *mcp.CallToolRequest, in AutomationCreateInput) (*mcp.CallToolResult, any, error) {
+	a := &automation{
+		Name:      strings.TrimSpace(in.Name),
+		MailboxID: jmap.ID(in.MailboxID),
+		From:      strings.TrimSpace(in.From),
+		To:        strings.TrimSpace(in.To),
+		Subject:   strings.TrimSpace(in.Subject),
+		Action:    in.Action,
+		Target:    strings.TrimSpace(in.Target),
+		Created:   time.Now().UTC(),
+	}
+	if err := checkAutomation(a); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if a.Action == automationForward || a.Action == automationWebhook || a.Action == automationScript {
+		if err := checkMaySend(ctx, a.Action+" automations"); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+	if a.Action == automationForward && !s.enableEmailSubmission {
+		return errorResult(invalidArgument("forward automations need sending enabled (-enable-send)")), nil, nil
+	}
+	if a.Action == automationClassify && (s.classifier == nil || s.classifier.url == "") {
+		return errorResult(invalidArgument("classify automations need a classification endpoint (-classify-url)")), nil, nil
+	}
+	if a.Action == automationScript && s.script(a.Target) == nil {
+		return errorResult(invalidArgument("no script %q is configured (-scripts-file)", a.Target)), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	ids := []jmap.ID{a.MailboxID}
+	if a.Action == automationFile {
+		ids = append(ids, jmap.ID(a.Target))
+	}
+	for _, id := range ids {
+		if _, ok := mailboxes[id]; !ok {
+			return errorResult(notFoundError([]jmap.ID{id}, "mailbox %s not found", id)), nil, nil
+		}
+	}
+
+	user := client.Session().Username
+	if err := s.automations.add(user, a); err != nil {
+		return errorResult(err), nil, nil
+	}
+	text := fmt.Sprintf("Created %s: %s", a.ID, describeAutomation(a, mailboxes))
+	if !s.automations.runningFor(user) {
+		text += "\n" + automationsNotRunning
+	}
+	return textResult(text), nil, nil
+}
+
+// automationsNotRunning tells where automations run when this process does
+// not run them.
+const automationsNotRunning = "This server does not run automations for this account: they run where jmap-mcp is started with -run-automations or -mode daemon, sharing -automations-file, with this account's token."
+
+// describeAutomation writes a's trigger, conditions, and action, naming
+// mailboxes by path when mailboxes knows them.
+func describeAutomation(a *automation, mailboxes map[jmap.ID]*mailbox.Mailbox) string {
+	name := func(id jmap.ID) string {
+		if mb, ok := mailboxes[id]; ok {
+			return fmt.Sprintf("%s [id: %s]", mailboxPath(mb, mailboxes), id)
+		}
+		return string(id)
+	}
+	var sb strings.Builder
+	if a.Name != "" {
+		fmt.Fprintf(&sb, "%q — ", a.Name)
+	}
+	fmt.Fprintf(&sb, "new mail in %s", name(a.MailboxID))
+	var conds []string
+	if a.From != "" {
+		conds = append(conds, fmt.Sprintf("from contains %q", a.From))
+	}
+	if a.To != "" {
+		conds = append(conds, fmt.Sprintf("to contains %q", a.To))
+	}
+	if a.Subject != "" {
+		conds = append(conds, fmt.Sprintf("subject contains %q", a.Subject))
+	}
+	if len(conds) > 0 {
+		fmt.Fprintf(&sb, " where %s", strings.Join(conds, " and "))
+	}
+	switch a.Action {
+	case automationFile:
+		fmt.Fprintf(&sb, " → file into %s", name(jmap.ID(a.Target)))
+	case automationFlag:
+		fmt.Fprintf(&sb, " → flag %s", a.Target)
+	case automationForward:
+		fmt.Fprintf(&sb, " → forward to %s", a.Target)
+	case automationWebhook:
+		fmt.Fprintf(&sb, " → webhook %s", a.Target)
+	case automationScript:
+		fmt.Fprintf(&sb, " → script %s", a.Target)
+	case automationClassify:
+		sb.WriteString(" → classify")
+	}
+	return sb.String()
+}
+
+// --- automation_list ---
+
+type AutomationListInput struct{}
+
+var automationListTool = &mcp.Tool{
+	Name:        "automation_list",
+	Description: "List the account's automations with their conditions and actions, whether this server runs them, and, where it does, how many messages each acted on and its last error.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleAutomationList(ctx context.Context, _ *mcp.CallToolRequest, _ AutomationListInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	user := client.Session().Username
+	autos := s.automations.list(user)
+	if len(autos) == 0 {
+		return textResult("No automations"), nil, nil
+	}
+	var mailboxes map[jmap.ID]*mailbox.Mailbox
+	if accountID := client.Session().PrimaryAccounts[mail.URI]; accountID != "" {
+		// Without mailbox names the list still shows IDs.
+		mailboxes, _ = fetchMailboxes(ctx, client, accountID)
+	}
+
+	running := s.automations.runningFor(user)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Automations: %d\n", len(autos))
+	for _, a := range autos {
+		fmt.Fprintf(&sb, "\n[id: %s] %s\n  created %s", a.ID, describeAutomation(&a, mailboxes), s.locale.dateTime(a.Created))
+		if st := s.automations.status(a.ID); st != nil {
+			fmt.Fprintf(&sb, ", acted on %d message(s), last run %s", st.runs, s.locale.dateTime(st.last))
+			if st.lastError != "" {
+				fmt.Fprintf(&sb, "\n  last error: %s", st.lastError)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if !running {
+		sb.WriteString("\n" + automationsNotRunning + "\n")
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// --- automation_delete ---
+
+type AutomationDeleteInput struct {
+	ID string `json:"id" jsonschema:"Automation ID from automation_create or automation_list"`
+}
+
+var automationDeleteTool = &mcp.Tool{
+	Name:        "automation_delete",
+	Description: "Delete an automation; a server running it stops within a minute.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleAutomationDelete(ctx context.Context, _ *mcp.CallToolRequest, in AutomationDeleteInput) (*mcp.CallToolResult, any, error) {
+	if in.ID == "" {
+		return errorResult(invalidArgument("id is required")), nil, nil
+	}
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	removed, err := s.automations.remove(client.Session().Username, in.ID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if !removed {
+		return errorResult(notFoundError([]string{in.ID}, "no automation %q", in.ID)), nil, nil
+	}
+	return textResult(fmt.Sprintf("Deleted %s", in.ID)), nil, nil
+}
@@ -0,0 +1,142 @@
+package jmapmcp
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+func TestAutomationRunsWithoutClient(t *testing.T) {
+	s, fake := newFakeServer(t, WithWatchPollInterval(10*time.Millisecond))
+	fake.DisablePush()
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	ctx := context.Background()
+
+	res, _, _ := s.handleAutomationCreate(ctx, nil, AutomationCreateInput{MailboxID: "inbox", Subject: "invoice", Action: "flag", Target: "$flagged"})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, `new mail in Inbox [id: inbox] where subject contains "invoice" → flag $flagged`) {
+		t.Fatalf("automation_create = %s", out)
+	}
+	if !strings.Contains(out, "does not run automations") {
+		t.Errorf("not-running note missing: %s", out)
+	}
+	id := strings.Fields(strings.TrimPrefix(out, "Created "))[0]
+	id = strings.TrimSuffix(id, ":")
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- s.RunAutomations(runCtx) }()
+	owner := ownerKey("test")
+	for deadline := time.Now().Add(5 * time.Second); !s.watches.hasAutomation(owner, id); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("automation watch not registered")
+		}
+	}
+	if ws := s.watches.list(owner); len(ws) != 0 {
+		t.Errorf("watch_list shows automation watches: %+v", ws)
+	}
+
+	addEmail(fake, "e1", "Invoice 42", "", 1)
+	addEmail(fake, "e2", "Lunch?", "", 1)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if kw, _ := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["$flagged"] == true {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("matching email not flagged")
+		}
+	}
+	if kw, _ := fake.Object("Email", "e2")["keywords"].(map[string]any); kw["$flagged"] == true {
+		t.Error("email not matching the subject was flagged")
+	}
+
+	res, _, _ = s.handleAutomationList(ctx, nil, AutomationListInput{})
+	if out := resultText(res); !strings.Contains(out, "acted on 1 message(s)") || strings.Contains(out, "does not run automations") {
+		t.Errorf("automation_list = %s", out)
+	}
+
+	res, _, _ = s.handleAutomationDelete(ctx, nil, AutomationDeleteInput{ID: id})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	for deadline := time.Now().Add(5 * time.Second); s.watches.hasAutomation(owner, id); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("automation watch kept after delete")
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("RunAutomations = %v", err)
+	}
+}
+
+func TestAutomationCreateValidation(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	ctx := context.Background()
+
+	for _, in := range []AutomationCreateInput{
+		{MailboxID: "inbox", Action: "delete", Target: "x"},
+		{MailboxID: "inbox", Action: "webhook", Target: "ftp://example.org"},
+		{MailboxID: "inbox", Action: "forward", Target: "bob@example.org"}, // sending disabled
+		{MailboxID: "inbox", Action: "file"},
+	} {
+		res, _, _ := s.handleAutomationCreate(ctx, nil, in)
+		if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeInvalidArguments {
+			t.Errorf("%+v: %s", in, resultText(res))
+		}
+	}
+	res, _, _ := s.handleAutomationCreate(ctx, nil, AutomationCreateInput{MailboxID: "inbox", Action: "file", Target: "archive"})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeNotFound {
+		t.Errorf("missing target mailbox: %s", resultText(res))
+	}
+}
+
+func TestAutomationCreateNoSend(t *testing.T) {
+	s, fake := newFakeServer(t, WithEmailSubmission())
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "archive", "name": "Archive"})
+	ctx := ContextWithPermission(context.Background(), PermissionNoSend)
+
+	for _, in := range []AutomationCreateInput{
+		{MailboxID: "inbox", Action: "forward", Target: "bob@example.org"},
+		{MailboxID: "inbox", Action: "webhook", Target: "https://example.org/hook"},
+		{MailboxID: "inbox", Action: "script", Target: "archive_pdfs"},
+	} {
+		res, _, _ := s.handleAutomationCreate(ctx, nil, in)
+		if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeForbidden {
+			t.Errorf("%s automation under no-send: %s", in.Action, resultText(res))
+		}
+	}
+	if res, _, _ := s.handleAutomationCreate(ctx, nil, AutomationCreateInput{MailboxID: "inbox", Action: "file", Target: "archive"}); res.IsError {
+		t.Errorf("file automation under no-send: %s", resultText(res))
+	}
+}
+
+func TestAutomationsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "automations.json")
+	a, err := LoadAutomations(store.Dir(""), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.add("me", &automation{MailboxID: "inbox", Action: "webhook", Target: "https://example.org/hook"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process sees it on reload.
+	b, err := LoadAutomations(store.Dir(""), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := b.list("me")
+	if len(got) != 1 || got[0].Target != "https://example.org/hook" || !strings.HasPrefix(got[0].ID, "auto-") {
+		t.Fatalf("loaded %+v", got)
+	}
+	if changed, err := b.reload(); changed || err != nil {
+		t.Errorf("reload of an unchanged file = %v, %v", changed, err)
+	}
+}
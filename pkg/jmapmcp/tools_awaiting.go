@@ -0,0 +1,394 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultAwaitingHours = 24
+	defaultAwaitingDays  = 30
+	maxAwaitingDays      = 365
+	defaultAwaitingLimit = 20
+	// defaultUnansweredDays is how long the user's last message goes
+	// unanswered before email_sent_unanswered lists the thread.
+	defaultUnansweredDays = 3
+	// awaitingMaxScan bounds the mailbox messages whose threads are
+	// inspected.
+	awaitingMaxScan = 500
+)
+
+// --- email_awaiting_reply ---
+
+type EmailAwaitingReplyInput struct {
+	Hours     int    `json:"hours,omitempty" jsonschema:"Only threads whose latest message is at least this many hours old (default 24)"`
+	Days      int    `json:"days,omitempty" jsonschema:"How many days back to look for threads (default 30, max 365)"`
+	MailboxID string `json:"mailbox_id,omitempty" jsonschema:"Mailbox whose threads to check (default Inbox)"`
+	Limit     int    `json:"limit,omitempty" jsonschema:"Maximum threads to return (default 20)"`
+}
+
+var emailAwaitingReplyTool = &mcp.Tool{
+	Name:        "email_awaiting_reply",
+	Description: "Find the threads that still await the user's reply: threads with mail in the Inbox (or mailbox_id) over the last days whose latest message is from someone other than the user's identities, has not been answered, and is older than hours. Longest waiting first, with the email ID to pass to email_reply or reply_context. Changes nothing.",
+	Annotations: readOnlyAnnotations,
+}
+
+// threadLatest is the latest message of a thread.
+type threadLatest struct {
+	latest   *email.Email
+	messages int
+}
+
+func (s *Server) handleEmailAwaitingReply(ctx context.Context, _ *mcp.CallToolRequest, in EmailAwaitingReplyInput) (*mcp.CallToolResult, any, error) {
+	hours := in.Hours
+	if hours <= 0 {
+		hours = defaultAwaitingHours
+	}
+	days := in.Days
+	if days <= 0 {
+		days = defaultAwaitingDays
+	}
+	if days > maxAwaitingDays {
+		return errorResult(invalidArgument("days must be at most %d", maxAwaitingDays)), nil, nil
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultAwaitingLimit
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	target, sent, ignore := threadMailboxes(mailboxes)
+	var sentID jmap.ID
+	if sent != nil {
+		sentID = sent.ID
+	}
+	if in.MailboxID != "" {
+		if target = mailboxes[jmap.ID(in.MailboxID)]; target == nil {
+			return errorResult(notFoundError([]string{in.MailboxID}, "mailbox %s not found", in.MailboxID)), nil, nil
+		}
+	}
+	if target == nil {
+		return errorResult(fmt.Errorf("no mailbox with role %q found", mailbox.RoleInbox)), nil, nil
+	}
+
+	// Without identities (no submission capability), messages in Sent are
+	// still recognized as the user's own.
+	identities, _ := fetchIdentities(ctx, client, accountID)
+
+	pageSize := uint64(keywordPageSize)
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxObjectsInGet > 0 {
+		pageSize = min(pageSize, c.MaxObjectsInGet)
+	}
+	now := time.Now()
+	cutoff := now.Add(-time.Duration(hours) * time.Hour)
+	threadIDs, more, err := scanMailboxThreads(ctx, client, accountID, target.ID, now.AddDate(0, 0, -days), cutoff, pageSize)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	threads, err := latestInThreads(ctx, client, accountID, threadIDs, ignore, pageSize)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	waiting := slices.DeleteFunc(threads, func(t threadLatest) bool {
+		return t.latest.Keywords["$answered"] || receivedAt(t.latest).After(cutoff) || fromUser(t.latest, identities, sentID)
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d thread(s) in %s from the last %d days await your reply for at least %d hours", len(waiting), mailboxPath(target, mailboxes), days, hours)
+	if more {
+		fmt.Fprintf(&sb, " (scan stopped at the %d most recent messages)", awaitingMaxScan)
+	}
+	sb.WriteString("\n")
+	if len(waiting) == 0 {
+		return textResult(sb.String()), nil, nil
+	}
+	sb.WriteString("\n")
+	for i, w := range waiting {
+		if i >= limit {
+			fmt.Fprintf(&sb, "… and %d more; raise limit to see them\n", len(waiting)-i)
+			break
+		}
+		e := w.latest
+		subject := e.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		fmt.Fprintf(&sb, "%d. %s — %s, waiting %s since %s [email: %s, thread: %s, %d message(s)]\n",
+			i+1, subject, formatAddresses(e.From), formatLatency(now.Sub(receivedAt(e))), s.locale.dateTime(receivedAt(e)), e.ID, e.ThreadID, w.messages)
+	}
+	sb.WriteString("\nDraft an answer with email_reply (or gather context first with reply_context) using the email ID.\n")
+	return textResult(sb.String()), nil, nil
+}
+
+// --- email_sent_unanswered ---
+
+type EmailSentUnansweredInput struct {
+	OlderThanDays int `json:"older_than_days,omitempty" jsonschema:"Only threads whose latest message, sent by the user, is at least this many days old (default 3)"`
+	Days          int `json:"days,omitempty" jsonschema:"How many days of Sent mail to look through (default 30, max 365)"`
+	Limit         int `json:"limit,omitempty" jsonschema:"Maximum threads to return (default 20)"`
+}
+
+var emailSentUnansweredTool = &mcp.Tool{
+	Name:        "email_sent_unanswered",
+	Description: "Find the threads where the user sent the last message more than older_than_days ago and nobody has replied since: candidates for a follow-up. Looks through Sent mail of the last days, longest silent first, with the email ID to pass to email_reply (replying to one's own message addresses its original recipients). The inverse of email_awaiting_reply. Changes nothing.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailSentUnanswered(ctx context.Context, _ *mcp.CallToolRequest, in EmailSentUnansweredInput) (*mcp.CallToolResult, any, error) {
+	olderThan := in.OlderThanDays
+	if olderThan <= 0 {
+		olderThan = defaultUnansweredDays
+	}
+	days := in.Days
+	if days <= 0 {
+		days = defaultAwaitingDays
+	}
+	if days > maxAwaitingDays {
+		return errorResult(invalidArgument("days must be at most %d", maxAwaitingDays)), nil, nil
+	}
+	if olderThan >= days {
+		return errorResult(invalidArgument("older_than_days (%d) must be less than days (%d)", olderThan, days)), nil, nil
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultAwaitingLimit
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	_, sent, ignore := threadMailboxes(mailboxes)
+	if sent == nil {
+		return errorResult(fmt.Errorf("no mailbox with role %q found", mailbox.RoleSent)), nil, nil
+	}
+	identities, _ := fetchIdentities(ctx, client, accountID)
+
+	pageSize := uint64(keywordPageSize)
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxObjectsInGet > 0 {
+		pageSize = min(pageSize, c.MaxObjectsInGet)
+	}
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -olderThan)
+	threadIDs, more, err := scanMailboxThreads(ctx, client, accountID, sent.ID, now.AddDate(0, 0, -days), cutoff, pageSize)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	threads, err := latestInThreads(ctx, client, accountID, threadIDs, ignore, pageSize)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	silent := slices.DeleteFunc(threads, func(t threadLatest) bool {
+		return receivedAt(t.latest).After(cutoff) || !fromUser(t.latest, identities, sent.ID)
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d thread(s) from the last %d days with no reply for at least %d days after your last message", len(silent), days, olderThan)
+	if more {
+		fmt.Fprintf(&sb, " (scan stopped at the %d most recent messages)", awaitingMaxScan)
+	}
+	sb.WriteString("\n")
+	if len(silent) == 0 {
+		return textResult(sb.String()), nil, nil
+	}
+	sb.WriteString("\n")
+	for i, t := range silent {
+		if i >= limit {
+			fmt.Fprintf(&sb, "… and %d more; raise limit to see them\n", len(silent)-i)
+			break
+		}
+		e := t.latest
+		subject := e.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		fmt.Fprintf(&sb, "%d. %s — to %s, silent %s since %s [email: %s, thread: %s, %d message(s)]\n",
+			i+1, subject, formatAddresses(e.To), formatLatency(now.Sub(receivedAt(e))), s.locale.dateTime(receivedAt(e)), e.ID, e.ThreadID, t.messages)
+	}
+	sb.WriteString("\nFollow up with email_reply on the email ID; replying to your own message addresses its original recipients.\n")
+	return textResult(sb.String()), nil, nil
+}
+
+// threadMailboxes returns the Inbox and Sent mailboxes, either nil when
+// missing, and the Trash and Junk mailboxes, whose messages do not count
+// toward a thread's latest.
+func threadMailboxes(mailboxes map[jmap.ID]*mailbox.Mailbox) (inbox, sent *mailbox.Mailbox, ignore map[jmap.ID]bool) {
+	ignore = make(map[jmap.ID]bool)
+	for _, mb := range mailboxes {
+		switch mb.Role {
+		case mailbox.RoleInbox:
+			inbox = mb
+		case mailbox.RoleSent:
+			sent = mb
+		case mailbox.RoleTrash, mailbox.RoleJunk:
+			ignore[mb.ID] = true
+		}
+	}
+	return inbox, sent, ignore
+}
+
+// scanMailboxThreads returns the threads of the messages in mailboxID
+// received between after and before, newest first. more reports that the
+// scan stopped at awaitingMaxScan.
+func scanMailboxThreads(ctx context.Context, client JMAPClient, accountID, mailboxID jmap.ID, after, before time.Time, pageSize uint64) ([]jmap.ID, bool, error) {
+	var threadIDs []jmap.ID
+	seen := make(map[jmap.ID]bool)
+	for scanned := 0; scanned < awaitingMaxScan; {
+		limit := min(pageSize, uint64(awaitingMaxScan-scanned))
+
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(&email.Query{
+			Account:  accountID,
+			Filter:   &email.FilterCondition{InMailbox: mailboxID, After: &after, Before: &before},
+			Sort:     []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Position: int64(scanned),
+			Limit:    limit,
+		})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+			Properties:   []string{"id", "threadId"},
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(resp.Responses) < 2 {
+			return nil, false, fmt.Errorf("missing Email/get response in query chain")
+		}
+		if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+			return nil, false, me
+		}
+
+		var page []*email.Email
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			page = args.List
+		case *jmap.MethodError:
+			return nil, false, args
+		default:
+			return nil, false, fmt.Errorf("unexpected response type: %T", args)
+		}
+
+		scanned += len(page)
+		for _, e := range page {
+			if e.ThreadID != "" && !seen[e.ThreadID] {
+				seen[e.ThreadID] = true
+				threadIDs = append(threadIDs, e.ThreadID)
+			}
+		}
+		if uint64(len(page)) < limit {
+			return threadIDs, false, nil
+		}
+	}
+	return threadIDs, true, nil
+}
+
+// latestInThreads fetches the threads pageSize at a time and returns the
+// latest message of each, leaving out drafts and messages only in ignore
+// mailboxes, oldest first.
+func latestInThreads(ctx context.Context, client JMAPClient, accountID jmap.ID, threadIDs []jmap.ID, ignore map[jmap.ID]bool, pageSize uint64) ([]threadLatest, error) {
+	var out []threadLatest
+	for chunk := range slices.Chunk(threadIDs, int(max(pageSize, 1))) {
+		req := &jmap.Request{Context: ctx}
+		threadCallID := req.Invoke(&thread.Get{Account: accountID, IDs: chunk})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: threadCallID, Name: "Thread/get", Path: "/list/*/emailIds"},
+			Properties:   []string{"id", "threadId", "subject", "from", "to", "receivedAt", "keywords", "mailboxIds"},
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < 2 {
+			return nil, fmt.Errorf("missing Email/get response in thread chain")
+		}
+		if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+			return nil, me
+		}
+		var emails []*email.Email
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			emails = args.List
+		case *jmap.MethodError:
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+
+		byThread := make(map[jmap.ID][]*email.Email)
+		for _, e := range emails {
+			byThread[e.ThreadID] = append(byThread[e.ThreadID], e)
+		}
+		for _, id := range chunk {
+			var latest *email.Email
+			for _, e := range byThread[id] {
+				if e.Keywords["$draft"] || onlyIn(e, ignore) {
+					continue
+				}
+				if latest == nil || receivedAt(e).After(receivedAt(latest)) {
+					latest = e
+				}
+			}
+			if latest != nil {
+				out = append(out, threadLatest{latest: latest, messages: len(byThread[id])})
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b threadLatest) int {
+		return receivedAt(a.latest).Compare(receivedAt(b.latest))
+	})
+	return out, nil
+}
+
+// fromUser reports whether e is the user's own: from one of identities or
+// in the Sent mailbox sentID.
+func fromUser(e *email.Email, identities []*identity.Identity, sentID jmap.ID) bool {
+	return e.MailboxIDs[sentID] || slices.ContainsFunc(e.From, func(a *mail.Address) bool { return isOwnAddress(a.Email, identities) })
+}
+
+// onlyIn reports whether every mailbox of e is in set.
+func onlyIn(e *email.Email, set map[jmap.ID]bool) bool {
+	for mb := range e.MailboxIDs {
+		if !set[mb] {
+			return false
+		}
+	}
+	return len(e.MailboxIDs) > 0
+}
@@ -0,0 +1,128 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailAwaitingReply(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	now := time.Now().UTC()
+	add := func(id, thread, mailbox, from, subject string, ago time.Duration, keywords map[string]any) {
+		e := map[string]any{
+			"id": id, "threadId": thread, "subject": subject,
+			"mailboxIds": map[string]any{mailbox: true},
+			"from":       []any{map[string]any{"email": from}},
+			"receivedAt": now.Add(-ago).Format(time.RFC3339),
+		}
+		if keywords != nil {
+			e["keywords"] = keywords
+		}
+		fake.Add("Email", e)
+	}
+	// T1: Alice wrote last, three days ago.
+	add("a1", "T1", "inbox", "alice@example.org", "Budget", 96*time.Hour, nil)
+	add("a2", "T1", "sent", "me@example.com", "Re: Budget", 90*time.Hour, nil)
+	add("a3", "T1", "inbox", "alice@example.org", "Re: Budget", 72*time.Hour, nil)
+	// T2: the user answered.
+	add("b1", "T2", "inbox", "bob@example.org", "Lunch", 48*time.Hour, nil)
+	add("b2", "T2", "sent", "me@example.com", "Re: Lunch", 47*time.Hour, nil)
+	// T3: too recent.
+	add("c1", "T3", "inbox", "carol@example.org", "Hello", 2*time.Hour, nil)
+	// T4: marked answered from another client.
+	add("d1", "T4", "inbox", "dave@example.org", "Invoice", 50*time.Hour, map[string]any{"$answered": true})
+	// T5: a newer message in the thread was trashed; the earlier one still waits.
+	add("e1", "T5", "inbox", "erin@example.org", "Contract", 120*time.Hour, nil)
+	add("e2", "T5", "trash", "spam@example.org", "Re: Contract", 30*time.Hour, nil)
+	for id, emails := range map[string][]any{
+		"T1": {"a1", "a2", "a3"}, "T2": {"b1", "b2"}, "T3": {"c1"}, "T4": {"d1"}, "T5": {"e1", "e2"},
+	} {
+		fake.Add("Thread", map[string]any{"id": id, "emailIds": emails})
+	}
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailAwaitingReply(ctx, nil, EmailAwaitingReplyInput{})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("email_awaiting_reply: %s", out)
+	}
+	if !strings.HasPrefix(out, "2 thread(s) in Inbox from the last 30 days await your reply for at least 24 hours\n") {
+		t.Errorf("header:\n%s", out)
+	}
+	first, second := strings.Index(out, "1. Contract — erin@example.org, waiting 5.0d"), strings.Index(out, "2. Re: Budget — alice@example.org, waiting 3.0d")
+	if first < 0 || second < 0 || !strings.Contains(out, "[email: a3, thread: T1, 3 message(s)]") {
+		t.Errorf("awaiting threads:\n%s", out)
+	}
+	for _, absent := range []string{"Lunch", "Hello", "Invoice"} {
+		if strings.Contains(out, absent) {
+			t.Errorf("listed %s:\n%s", absent, out)
+		}
+	}
+
+	res, _, _ = s.handleEmailAwaitingReply(ctx, nil, EmailAwaitingReplyInput{Hours: 1})
+	if out := resultText(res); !strings.Contains(out, "3 thread(s)") || !strings.Contains(out, "Hello") {
+		t.Errorf("with hours=1:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailAwaitingReply(ctx, nil, EmailAwaitingReplyInput{MailboxID: "missing"})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeNotFound {
+		t.Errorf("missing mailbox: %s", resultText(res))
+	}
+}
+
+func TestEmailSentUnanswered(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	now := time.Now().UTC()
+	add := func(id, thread, mailbox, from, to, subject string, ago time.Duration) {
+		fake.Add("Email", map[string]any{
+			"id": id, "threadId": thread, "subject": subject,
+			"mailboxIds": map[string]any{mailbox: true},
+			"from":       []any{map[string]any{"email": from}},
+			"to":         []any{map[string]any{"email": to}},
+			"receivedAt": now.Add(-ago).Format(time.RFC3339),
+		})
+	}
+	day := 24 * time.Hour
+	add("a1", "T1", "sent", "me@example.com", "alice@example.org", "Proposal", 5*day)
+	add("b1", "T2", "sent", "me@example.com", "bob@example.org", "Question", 5*day)
+	add("b2", "T2", "inbox", "bob@example.org", "me@example.com", "Re: Question", 4*day)
+	add("c1", "T3", "sent", "me@example.com", "carol@example.org", "Today", day)
+	add("d1", "T4", "sent", "me@example.com", "dave@example.org", "Quote", 10*day)
+	add("d2", "T4", "sent", "me@example.com", "dave@example.org", "Re: Quote", 4*day)
+	for id, emails := range map[string][]any{"T1": {"a1"}, "T2": {"b1", "b2"}, "T3": {"c1"}, "T4": {"d1", "d2"}} {
+		fake.Add("Thread", map[string]any{"id": id, "emailIds": emails})
+	}
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailSentUnanswered(ctx, nil, EmailSentUnansweredInput{})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("email_sent_unanswered: %s", out)
+	}
+	for _, want := range []string{
+		"2 thread(s) from the last 30 days with no reply for at least 3 days after your last message\n",
+		"1. Proposal — to alice@example.org, silent 5.0d",
+		"2. Re: Quote — to dave@example.org, silent 4.0d",
+		"[email: d2, thread: T4, 2 message(s)]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Question") || strings.Contains(out, "Today") {
+		t.Errorf("answered or recent thread listed:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailSentUnanswered(ctx, nil, EmailSentUnansweredInput{OlderThanDays: 30})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeInvalidArguments {
+		t.Errorf("older_than_days beyond days: %s", resultText(res))
+	}
+}
@@ -0,0 +1,359 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultBatchSize = 100
+	maxBatchSize     = 500
+	// batchCursorTTL is how long an unused cursor is kept.
+	batchCursorTTL = 30 * time.Minute
+	// maxBatchCursors bounds the open cursors of all callers; the least
+	// recently used of the tenant holding the most is dropped to make room.
+	maxBatchCursors = 100
+)
+
+// batchCursor is a server-side position in an Email/query result walked
+// oldest first. Owner is a hash of the JMAP token that opened it, as for
+// outbox entries.
+type batchCursor struct {
+	ID         string
+	Owner      string
+	Endpoint   string // named endpoint the query runs on; empty for the default
+	AccountID  jmap.ID
+	Filter     *email.FilterCondition
+	BatchSize  uint64
+	QueryState string  // Email/query state of the last batch
+	LastID     jmap.ID // last email delivered; the next batch starts after it
+	Delivered  int     // emails delivered so far
+	Batches    int
+	Drifts     int // batches whose queryState differed from the previous one
+	Used       time.Time
+}
+
+// batchCursors holds the open cursors of email_batch_iterator.
+type batchCursors struct {
+	mu  sync.Mutex
+	seq int
+	m   map[string]*batchCursor
+}
+
+// add registers c under a new ID, dropping expired cursors and, when full,
+// one picked by evictionVictim.
+func (b *batchCursors) add(c *batchCursor, now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.m == nil {
+		b.m = make(map[string]*batchCursor)
+	}
+	b.expireLocked(now)
+	if len(b.m) >= maxBatchCursors {
+		delete(b.m, evictionVictim(b.m,
+			func(e *batchCursor) string { return e.Owner },
+			func(e *batchCursor) time.Time { return e.Used }))
+	}
+	b.seq++
+	c.ID = fmt.Sprintf("cur-%d", b.seq)
+	c.Used = now
+	b.m[c.ID] = c
+	return c.ID
+}
+
+// take removes and returns owner's cursor id, so two concurrent calls with
+// the same cursor cannot deliver the same batch twice.
+func (b *batchCursors) take(owner, id string, now time.Time) (*batchCursor, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireLocked(now)
+	c, ok := b.m[id]
+	if !ok || c.Owner != owner {
+		return nil, notFoundError([]string{id}, "no open batch cursor %q (it finished, expired after %s unused, or is in use by another call); start a new one", id, batchCursorTTL)
+	}
+	delete(b.m, id)
+	return c, nil
+}
+
+// put returns a cursor taken for the next batch.
+func (b *batchCursors) put(c *batchCursor, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c.Used = now
+	b.m[c.ID] = c
+}
+
+func (b *batchCursors) expireLocked(now time.Time) {
+	for id, c := range b.m {
+		if now.Sub(c.Used) > batchCursorTTL {
+			delete(b.m, id)
+		}
+	}
+}
+
+// --- email_batch_iterator ---
+
+type EmailBatchIteratorInput struct {
+	Cursor        string `json:"cursor,omitempty" jsonschema:"Cursor returned by an earlier call: fetch its next batch. Omit to start a new cursor from the filter fields"`
+	MailboxID     string `json:"mailbox_id,omitempty" jsonschema:"ID of the mailbox to walk (new cursor only)"`
+	Query         string `json:"query,omitempty" jsonschema:"Full-text search query (new cursor only)"`
+	From          string `json:"from,omitempty" jsonschema:"Filter by sender address (new cursor only)"`
+	To            string `json:"to,omitempty" jsonschema:"Filter by recipient address (new cursor only)"`
+	Subject       string `json:"subject,omitempty" jsonschema:"Filter by subject text (new cursor only)"`
+	Before        string `json:"before,omitempty" jsonschema:"Emails before this date (RFC 3339 or YYYY-MM-DD; new cursor only)"`
+	After         string `json:"after,omitempty" jsonschema:"Emails after this date (RFC 3339 or YYYY-MM-DD; new cursor only)"`
+	HasAttachment *bool  `json:"has_attachment,omitempty" jsonschema:"Filter by attachment presence (new cursor only)"`
+	BatchSize     int    `json:"batch_size,omitempty" jsonschema:"Emails per batch (new cursor only; default 100, max 500, lowered to the mail server's limit)"`
+}
+
+var emailBatchIteratorTool = &mcp.Tool{
+	Name:        "email_batch_iterator",
+	Description: "Walk a large email selection in fixed-size batches, oldest first. Call without cursor and with email_query-style filters to open a server-side cursor and get the first batch; then call with only the returned cursor for each next batch until it reports the end. Each batch continues after the last email delivered, so mail arriving meanwhile is picked up at the end and nothing is repeated; the result notes when the query results changed between batches (queryState drift). Returns the same summary lines as email_query; use email_get for content. Cursors expire after 30 minutes unused.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailBatchIterator(ctx context.Context, _ *mcp.CallToolRequest, in EmailBatchIteratorInput) (*mcp.CallToolResult, any, error) {
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	owner := s.ownerOf(token)
+	now := time.Now()
+
+	var cur *batchCursor
+	if in.Cursor != "" {
+		if in.BatchSize != 0 {
+			return errorResult(invalidArgument("batch_size is fixed when the cursor is opened; omit it with cursor")), nil, nil
+		}
+		cur, err = s.batchCursors.take(owner, in.Cursor, now)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if cur.Endpoint != EndpointFromContext(ctx) {
+			s.batchCursors.put(cur, now)
+			return errorResult(invalidArgument("cursor %s belongs to endpoint %q", cur.ID, cur.Endpoint)), nil, nil
+		}
+	} else {
+		size := in.BatchSize
+		if size <= 0 {
+			size = defaultBatchSize
+		}
+		if size > maxBatchSize {
+			return errorResult(invalidArgument("batch_size must be at most %d", maxBatchSize)), nil, nil
+		}
+		filter, err := EmailQueryInput{
+			MailboxID:     in.MailboxID,
+			Query:         in.Query,
+			From:          in.From,
+			To:            in.To,
+			Subject:       in.Subject,
+			Before:        in.Before,
+			After:         in.After,
+			HasAttachment: in.HasAttachment,
+		}.filter()
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		cur = &batchCursor{Owner: owner, Endpoint: EndpointFromContext(ctx), Filter: filter, BatchSize: uint64(size)}
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		s.returnBatchCursor(cur, now)
+		return errorResult(err), nil, nil
+	}
+	if cur.AccountID == "" {
+		cur.AccountID = client.Session().PrimaryAccounts[mail.URI]
+		if cur.AccountID == "" {
+			return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+		}
+	}
+
+	var clamped bool
+	if cur.Batches == 0 {
+		if size := uint64(s.bulkPlanFor(ctx, client).getSize); cur.BatchSize > size {
+			cur.BatchSize, clamped = size, true
+		}
+	}
+
+	b, err := s.nextBatch(ctx, client, cur)
+	if err != nil {
+		s.returnBatchCursor(cur, now)
+		return errorResult(err), nil, nil
+	}
+
+	prevState := cur.QueryState
+	cur.QueryState = b.queryState
+	cur.Batches++
+	if prevState != "" && b.queryState != prevState {
+		cur.Drifts++
+	}
+	first := cur.Delivered + 1
+	cur.Delivered += len(b.list)
+	if len(b.list) > 0 {
+		cur.LastID = b.list[len(b.list)-1].ID
+	}
+	done := uint64(len(b.list)) < cur.BatchSize
+
+	var sb strings.Builder
+	switch {
+	case !done:
+		if cur.ID == "" {
+			s.batchCursors.add(cur, now)
+		} else {
+			s.batchCursors.put(cur, now)
+		}
+		fmt.Fprintf(&sb, "Cursor: %s (call again with this cursor for the next batch)\n", cur.ID)
+	case cur.ID == "":
+		sb.WriteString("Cursor: none (everything fit in one batch)\n")
+	default:
+		fmt.Fprintf(&sb, "Cursor: %s (finished; it is closed)\n", cur.ID)
+	}
+	switch {
+	case len(b.list) == 0:
+		fmt.Fprintf(&sb, "Batch %d: no more emails (%d delivered in total)\n", cur.Batches, cur.Delivered)
+	case b.hasTotal:
+		fmt.Fprintf(&sb, "Batch %d: emails %d-%d of %d\n", cur.Batches, first, cur.Delivered, b.total)
+	default:
+		fmt.Fprintf(&sb, "Batch %d: emails %d-%d\n", cur.Batches, first, cur.Delivered)
+	}
+	fmt.Fprintf(&sb, "Query state: %s\n", b.queryState)
+	if clamped {
+		fmt.Fprintf(&sb, "Note: batches hold at most %d emails, the mail server's limit per Email/get\n", cur.BatchSize)
+	}
+	if prevState != "" && b.queryState != prevState {
+		fmt.Fprintf(&sb, "Note: the query results changed since the previous batch (state %s → %s); this batch continues after the last email delivered, so newer matches are still to come and earlier ones are not revisited\n", prevState, b.queryState)
+	}
+	if b.anchorLost {
+		sb.WriteString("Note: the last email delivered no longer matches the query (moved or deleted), so this batch continues by position; emails may have been skipped or repeated around it\n")
+	}
+	if done && cur.Drifts > 0 {
+		fmt.Fprintf(&sb, "Note: the query results changed during %d of %d batches\n", cur.Drifts, cur.Batches)
+	}
+	sb.WriteString("\n")
+	for _, e := range b.list {
+		parts := []string{string(e.ID)}
+		if e.ReceivedAt != nil {
+			parts = append(parts, s.locale.dateTime(*e.ReceivedAt))
+		}
+		if len(e.From) > 0 {
+			parts = append(parts, formatAddresses(e.From))
+		}
+		parts = append(parts, "["+s.locale.size(int64(e.Size))+"]", e.Subject)
+		fmt.Fprintf(&sb, "%s\n", strings.Join(parts, "  "))
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// returnBatchCursor puts back a cursor taken for a batch that failed, so
+// the call can be retried. New cursors are only opened by a batch.
+func (s *Server) returnBatchCursor(cur *batchCursor, now time.Time) {
+	if cur.ID != "" {
+		s.batchCursors.put(cur, now)
+	}
+}
+
+// emailBatch is one page of a batch cursor.
+type emailBatch struct {
+	list       []*email.Email
+	queryState string
+	total      uint64
+	hasTotal   bool
+	anchorLost bool // the previous batch's last email was gone; paged by position
+}
+
+// nextBatch fetches the batch after cur.LastID (from the start when nothing
+// was delivered yet). When the server no longer finds that email it pages
+// by the number delivered instead.
+func (s *Server) nextBatch(ctx context.Context, client JMAPClient, cur *batchCursor) (*emailBatch, error) {
+	q := s.quirksFor(client)
+	b := &emailBatch{}
+	for {
+		query := &email.Query{
+			Account:        cur.AccountID,
+			Filter:         cur.Filter,
+			Sort:           []*email.SortComparator{{Property: "receivedAt", IsAscending: true}},
+			Limit:          cur.BatchSize,
+			CalculateTotal: !q.has(quirkNoCalculateTotal),
+		}
+		switch {
+		case cur.LastID != "" && !b.anchorLost:
+			query.Anchor = cur.LastID
+			query.AnchorOffset = 1
+		default:
+			query.Position = int64(cur.Delivered)
+		}
+
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(query)
+		req.Invoke(&email.Get{
+			Account: cur.AccountID,
+			ReferenceIDs: &jmap.ResultReference{
+				ResultOf: queryCallID,
+				Name:     "Email/query",
+				Path:     "/ids",
+			},
+			Properties: []string{"id", "subject", "from", "receivedAt", "size"},
+		})
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < 2 {
+			return nil, fmt.Errorf("missing Email/get response in query chain")
+		}
+
+		var ids []jmap.ID
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.QueryResponse:
+			ids = args.IDs
+			b.queryState = args.QueryState
+			b.total, b.hasTotal = args.Total, query.CalculateTotal
+		case *jmap.MethodError:
+			if args.Type == "anchorNotFound" && !b.anchorLost {
+				b.anchorLost = true
+				continue
+			}
+			if isCalculateTotalRefusal(args) && query.CalculateTotal {
+				q.learn(quirkNoCalculateTotal)
+				continue
+			}
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			b.list = orderByIDs(args.List, ids)
+			return b, nil
+		case *jmap.MethodError:
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+}
+
+// orderByIDs returns list in the order of ids, which /get does not promise.
+func orderByIDs(list []*email.Email, ids []jmap.ID) []*email.Email {
+	byID := make(map[jmap.ID]*email.Email, len(list))
+	for _, e := range list {
+		byID[e.ID] = e
+	}
+	out := make([]*email.Email, 0, len(list))
+	for _, id := range ids {
+		if e, ok := byID[id]; ok {
+			out = append(out, e)
+		}
+	}
+	return out
+}
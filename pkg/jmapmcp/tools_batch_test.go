@@ -0,0 +1,86 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEmailBatchIterator(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	for i, id := range []string{"e1", "e2", "e3", "e4", "e5"} {
+		addEmail(fake, id, "Old "+id, "", i+1)
+	}
+
+	res, _, _ := s.handleEmailBatchIterator(ctx, nil, EmailBatchIteratorInput{MailboxID: "inbox", BatchSize: 2})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Cursor: cur-1 (call again") || !strings.Contains(out, "Batch 1: emails 1-2 of 5") ||
+		strings.Index(out, "Old e1") > strings.Index(out, "Old e2") || strings.Contains(out, "Old e3") {
+		t.Fatalf("first batch:\n%s", out)
+	}
+
+	// New mail changes the query state but lands after the cursor.
+	addEmail(fake, "e6", "New e6", "", 6)
+	res, _, _ = s.handleEmailBatchIterator(ctx, nil, EmailBatchIteratorInput{Cursor: "cur-1"})
+	out = resultText(res)
+	if res.IsError || !strings.Contains(out, "Batch 2: emails 3-4 of 6") || !strings.Contains(out, "Old e3") || !strings.Contains(out, "Old e4") ||
+		!strings.Contains(out, "Note: the query results changed since the previous batch") {
+		t.Fatalf("second batch:\n%s", out)
+	}
+
+	// The last delivered email leaves the mailbox: the cursor pages by position.
+	e := fake.Object("Email", "e4")
+	e["mailboxIds"] = map[string]any{"archive": true}
+	fake.Add("Email", e)
+	res, _, _ = s.handleEmailBatchIterator(ctx, nil, EmailBatchIteratorInput{Cursor: "cur-1"})
+	out = resultText(res)
+	if res.IsError || !strings.Contains(out, "continues by position") || !strings.Contains(out, "finished; it is closed") ||
+		!strings.Contains(out, "changed during 2 of 3 batches") {
+		t.Fatalf("third batch:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailBatchIterator(ctx, nil, EmailBatchIteratorInput{Cursor: "cur-1"})
+	if !res.IsError || !strings.Contains(resultText(res), "no open batch cursor") {
+		t.Errorf("closed cursor reused:\n%s", resultText(res))
+	}
+}
+
+func TestEmailBatchIteratorOneBatch(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Only", "", 1)
+	res, _, _ := s.handleEmailBatchIterator(context.Background(), nil, EmailBatchIteratorInput{})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Cursor: none") || !strings.Contains(out, "Only") {
+		t.Fatalf("single batch:\n%s", out)
+	}
+	if len(s.batchCursors.m) != 0 {
+		t.Errorf("cursor kept after a single batch: %v", s.batchCursors.m)
+	}
+}
+
+var nextCursor = regexp.MustCompile(`Cursor: (cur-\d+) \(call again`)
+
+func BenchmarkEmailBatchIterator(b *testing.B) {
+	s, fake := newFakeServer(b)
+	for i := range 1000 {
+		addEmail(fake, fmt.Sprintf("e%d", i), fmt.Sprintf("Message %d", i), "body", i%28+1)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		// Walk the whole mailbox, ten batches of 100.
+		res, _, _ := s.handleEmailBatchIterator(ctx, nil, EmailBatchIteratorInput{MailboxID: "inbox", BatchSize: 100})
+		for !res.IsError {
+			m := nextCursor.FindStringSubmatch(resultText(res))
+			if m == nil {
+				break
+			}
+			res, _, _ = s.handleEmailBatchIterator(ctx, nil, EmailBatchIteratorInput{Cursor: m[1]})
+		}
+		if res.IsError {
+			b.Fatal(resultText(res))
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	netmail "net/mail"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxDSNPartBytes caps how much of a delivery-status or returned-headers
+// part is downloaded.
+const maxDSNPartBytes = 256 << 10
+
+// --- email_bounce_info ---
+
+type EmailBounceInfoInput struct {
+	EmailID string `json:"email_id" jsonschema:"ID of the suspected bounce (non-delivery report) email"`
+}
+
+var emailBounceInfoTool = &mcp.Tool{
+	Name:        "email_bounce_info",
+	Description: "Interpret a bounce (delivery status notification, multipart/report). Parses the DSN part into per-recipient action, status code with explanation, remote MTA and diagnostic, and links back to the original sent email and its submission when they can be found.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailBounceInfo(ctx context.Context, _ *mcp.CallToolRequest, in EmailBounceInfoInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        []jmap.ID{jmap.ID(in.EmailID)},
+		Properties: []string{"id", "subject", "from", "receivedAt", "bodyStructure"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/get")), nil, nil
+	}
+
+	var bounce *email.Email
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return errorResult(notFoundError([]string{in.EmailID}, "email not found: %s", in.EmailID)), nil, nil
+		}
+		bounce = args.List[0]
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	statusPart := findBodyPart(bounce.BodyStructure, "message/delivery-status", "message/global-delivery-status")
+	if statusPart == nil {
+		return errorResult(fmt.Errorf("email %s is not a delivery status notification (no message/delivery-status part)", in.EmailID)), nil, nil
+	}
+
+	statusText, err := downloadText(ctx, client, accountID, statusPart.BlobID)
+	if err != nil {
+		return errorResult(fmt.Errorf("download delivery status: %w", err)), nil, nil
+	}
+	report := parseDSN(statusText)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Bounce: %s [id: %s]\n", bounce.Subject, bounce.ID)
+	if bounce.ReceivedAt != nil {
+		fmt.Fprintf(&sb, "Received: %s\n", s.locale.dateTime(*bounce.ReceivedAt))
+	}
+	if report.ReportingMTA != "" {
+		fmt.Fprintf(&sb, "Reporting MTA: %s\n", report.ReportingMTA)
+	}
+	if report.OriginalEnvelopeID != "" {
+		fmt.Fprintf(&sb, "Original envelope ID: %s\n", report.OriginalEnvelopeID)
+	}
+	for _, r := range report.Recipients {
+		fmt.Fprintf(&sb, "\nRecipient: %s\n", r.FinalRecipient)
+		if r.OriginalRecipient != "" && r.OriginalRecipient != r.FinalRecipient {
+			fmt.Fprintf(&sb, "  Original recipient: %s\n", r.OriginalRecipient)
+		}
+		fmt.Fprintf(&sb, "  Action: %s\n", r.Action)
+		if r.Status != "" {
+			if desc := describeDSNStatus(r.Status); desc != "" {
+				fmt.Fprintf(&sb, "  Status: %s (%s)\n", r.Status, desc)
+			} else {
+				fmt.Fprintf(&sb, "  Status: %s\n", r.Status)
+			}
+		}
+		if r.RemoteMTA != "" {
+			fmt.Fprintf(&sb, "  Remote MTA: %s\n", r.RemoteMTA)
+		}
+		if r.DiagnosticCode != "" {
+			fmt.Fprintf(&sb, "  Diagnostic: %s\n", r.DiagnosticCode)
+		}
+		if r.LastAttemptDate != "" {
+			fmt.Fprintf(&sb, "  Last attempt: %s\n", r.LastAttemptDate)
+		}
+	}
+	if len(report.Recipients) == 0 {
+		sb.WriteString("\nNo per-recipient fields found in the delivery status.\n")
+	}
+
+	// Link back to the original message via the returned headers' Message-ID.
+	headersPart := findBodyPart(bounce.BodyStructure, "text/rfc822-headers", "message/rfc822", "message/global", "message/global-headers")
+	if headersPart == nil {
+		return textResult(sb.String()), nil, nil
+	}
+	headersText, err := downloadText(ctx, client, accountID, headersPart.BlobID)
+	if err != nil {
+		return textResult(sb.String()), nil, nil
+	}
+	messageID := headerMessageID(headersText)
+	if messageID == "" {
+		return textResult(sb.String()), nil, nil
+	}
+	fmt.Fprintf(&sb, "\nOriginal Message-ID: %s\n", messageID)
+	sb.WriteString(s.describeOriginalSubmission(ctx, client, accountID, messageID))
+
+	return textResult(sb.String()), nil, nil
+}
+
+// describeOriginalSubmission finds the sent email with messageID and, when
+// the submission capability is available, its EmailSubmission record.
+// Failures degrade to an explanatory line rather than an error.
+func (s *Server) describeOriginalSubmission(ctx context.Context, client JMAPClient, accountID jmap.ID, messageID string) string {
+	q := s.quirksFor(client)
+	if q.has(quirkNoHeaderFilter) {
+		return "Original email: header search not supported by this server\n"
+	}
+
+	req := &jmap.Request{Context: ctx}
+	queryCallID := req.Invoke(&email.Query{
+		Account: accountID,
+		Filter:  &email.FilterCondition{Header: []string{"Message-ID", "<" + messageID + ">"}},
+		Limit:   1,
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+		Properties:   []string{"id", "subject", "sentAt", "to"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil || len(resp.Responses) < 2 {
+		return "Original email: lookup failed\n"
+	}
+	if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok && isUnsupportedFilter(me) {
+		q.learn(quirkNoHeaderFilter)
+		return "Original email: header search not supported by this server\n"
+	}
+	args, ok := resp.Responses[1].Args.(*email.GetResponse)
+	if !ok || len(args.List) == 0 {
+		return "Original email: not found in this account\n"
+	}
+	original := args.List[0]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Original email: %s [id: %s]\n", original.Subject, original.ID)
+	if original.SentAt != nil {
+		fmt.Fprintf(&sb, "  Sent: %s\n", s.locale.dateTime(*original.SentAt))
+	}
+	if len(original.To) > 0 {
+		fmt.Fprintf(&sb, "  To: %s\n", formatAddresses(original.To))
+	}
+
+	if _, ok := client.Session().Capabilities[emailsubmission.URI]; !ok {
+		return sb.String()
+	}
+	subReq := &jmap.Request{Context: ctx}
+	subQueryID := subReq.Invoke(&emailsubmission.Query{
+		Account: accountID,
+		Filter:  &emailsubmission.FilterCondition{EmailIDs: []jmap.ID{original.ID}},
+		Limit:   5,
+	})
+	subReq.Invoke(&emailsubmission.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: subQueryID, Name: "EmailSubmission/query", Path: "/ids"},
+	})
+	subResp, err := client.Do(subReq)
+	if err != nil || len(subResp.Responses) < 2 {
+		return sb.String()
+	}
+	if subs, ok := subResp.Responses[1].Args.(*emailsubmission.GetResponse); ok {
+		for _, sub := range subs.List {
+			fmt.Fprintf(&sb, "  Submission: %s (undo status: %s)\n", sub.ID, sub.UndoStatus)
+			for rcpt, ds := range sub.DeliveryStatus {
+				fmt.Fprintf(&sb, "    %s: delivered=%s %s\n", rcpt, ds.Delivered, strings.TrimSpace(ds.SMTPReply))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// findBodyPart returns the first part in depth-first order whose type matches
+// one of types.
+func findBodyPart(part *email.BodyPart, types ...string) *email.BodyPart {
+	if part == nil {
+		return nil
+	}
+	for _, t := range types {
+		if strings.EqualFold(part.Type, t) {
+			return part
+		}
+	}
+	for _, sub := range part.SubParts {
+		if found := findBodyPart(sub, types...); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// downloadText downloads a blob as text, capped at maxDSNPartBytes.
+func downloadText(ctx context.Context, client JMAPClient, accountID, blobID jmap.ID) (string, error) {
+	body, err := client.Download(ctx, accountID, blobID)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxDSNPartBytes))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// headerMessageID extracts the Message-ID (without angle brackets) from a
+// block of RFC 5322 headers, possibly followed by a body.
+func headerMessageID(headers string) string {
+	msg, err := netmail.ReadMessage(strings.NewReader(headers + "\r\n\r\n"))
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(msg.Header.Get("Message-ID")), "<>")
+}
@@ -0,0 +1,417 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // event and output time zones must resolve without a system zoneinfo
+
+	"github.com/mikluko/jmap"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/calendars"
+)
+
+const (
+	maxFreeBusyDays     = 31
+	defaultWorkingHours = "09:00-17:00"
+	defaultMinSlot      = 30 // minutes
+)
+
+// --- calendar_freebusy ---
+
+type CalendarFreeBusyInput struct {
+	Start           string   `json:"start" jsonschema:"Start of the range: YYYY-MM-DD (midnight in time_zone) or RFC 3339"`
+	End             string   `json:"end" jsonschema:"End of the range, exclusive: YYYY-MM-DD (midnight in time_zone) or RFC 3339; at most 31 days after start"`
+	TimeZone        string   `json:"time_zone,omitempty" jsonschema:"IANA time zone for dates, working hours, and output, e.g. Europe/Berlin (default UTC)"`
+	CalendarIDs     []string `json:"calendar_ids,omitempty" jsonschema:"Calendars to check (default: all calendars that count towards availability)"`
+	WorkingHours    string   `json:"working_hours,omitempty" jsonschema:"Daily window for free slots as HH:MM-HH:MM (default 09:00-17:00)"`
+	MinSlotMinutes  int      `json:"min_slot_minutes,omitempty" jsonschema:"Shortest free slot to list, in minutes (default 30)"`
+	IncludeWeekends bool     `json:"include_weekends,omitempty" jsonschema:"List free slots on Saturdays and Sundays too (default false)"`
+}
+
+var calendarFreeBusyTool = &mcp.Tool{
+	Name:        "calendar_freebusy",
+	Description: "Check the user's calendars over a date range (JMAP Calendars) and return merged busy blocks with event titles, plus the free slots within working hours. Cancelled events, events marked free, and calendars excluded from availability are ignored; recurring events are expanded. Use it before drafting a scheduling reply to propose times that are actually free. Requires server support for urn:ietf:params:jmap:calendars.",
+	Annotations: readOnlyAnnotations,
+}
+
+// busyBlock is a span of busy time and the events that make it up.
+type busyBlock struct {
+	start, end time.Time
+	titles     []string
+	tentative  bool // every event in the block is tentative
+}
+
+func (s *Server) handleCalendarFreeBusy(ctx context.Context, _ *mcp.CallToolRequest, in CalendarFreeBusyInput) (*mcp.CallToolResult, any, error) {
+	loc := time.UTC
+	if in.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(in.TimeZone); err != nil {
+			return errorResult(invalidArgument("invalid time_zone %q: %w", in.TimeZone, err)), nil, nil
+		}
+	}
+	if in.Start == "" || in.End == "" {
+		return errorResult(invalidArgument("start and end are required")), nil, nil
+	}
+	from, err := parseTimeIn(in.Start, loc)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	to, err := parseTimeIn(in.End, loc)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if !to.After(from) {
+		return errorResult(invalidArgument("end must be after start")), nil, nil
+	}
+	if to.Sub(from) > maxFreeBusyDays*24*time.Hour {
+		return errorResult(fmt.Errorf("range is limited to %d days", maxFreeBusyDays)), nil, nil
+	}
+	hours := in.WorkingHours
+	if hours == "" {
+		hours = defaultWorkingHours
+	}
+	dayStart, dayEnd, err := parseWorkingHours(hours)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	minSlot := time.Duration(in.MinSlotMinutes) * time.Minute
+	if minSlot <= 0 {
+		minSlot = defaultMinSlot * time.Minute
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if _, ok := client.Session().RawCapabilities[calendars.URI]; !ok {
+		return errorResult(capabilityMissing(client.Session(), calendars.URI)), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[calendars.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary calendars account")), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&calendars.CalendarGet{Account: accountID, Properties: []string{"id", "name", "includeInAvailability"}})
+	queryCallID := req.Invoke(&calendars.EventQuery{
+		Account: accountID,
+		Filter: &calendars.EventFilterCondition{
+			InCalendars: toJMAPIDSlice(in.CalendarIDs),
+			After:       from.UTC().Format(time.RFC3339),
+			Before:      to.UTC().Format(time.RFC3339),
+		},
+		ExpandRecurrences: true,
+		TimeZone:          loc.String(),
+	})
+	req.Invoke(&calendars.EventGet{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "CalendarEvent/query", Path: "/ids"},
+		Properties: []string{"id", "calendarIds", "title", "start", "duration", "timeZone", "showWithoutTime",
+			"status", "freeBusyStatus", "utcStart", "utcEnd"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) < 3 {
+		return errorResult(fmt.Errorf("expected 3 responses, got %d", len(resp.Responses))), nil, nil
+	}
+
+	counted := make(map[jmap.ID]bool)
+	switch args := resp.Responses[0].Args.(type) {
+	case *calendars.CalendarGetResponse:
+		for _, c := range args.List {
+			// Explicitly requested calendars count even when excluded
+			// from availability.
+			counted[c.ID] = c.IncludeInAvailability != "none" || slices.Contains(in.CalendarIDs, string(c.ID))
+		}
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected calendar response type: %T", args)), nil, nil
+	}
+	if me, ok := resp.Responses[1].Args.(*jmap.MethodError); ok {
+		return errorResult(me), nil, nil
+	}
+
+	var events []*calendars.Event
+	switch args := resp.Responses[2].Args.(type) {
+	case *calendars.EventGetResponse:
+		events = args.List
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected event response type: %T", args)), nil, nil
+	}
+
+	var blocks []busyBlock
+	for _, e := range events {
+		if !eventCountsAsBusy(e, counted) {
+			continue
+		}
+		start, end, err := eventInterval(e, loc)
+		if err != nil || !end.After(from) || !start.Before(to) {
+			continue
+		}
+		title := e.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		blocks = append(blocks, busyBlock{
+			start:     maxTime(start, from),
+			end:       minTime(end, to),
+			titles:    []string{title},
+			tentative: e.Status == "tentative",
+		})
+	}
+	blocks = mergeBusyBlocks(blocks)
+	free := freeSlots(blocks, from, to, loc, dayStart, dayEnd, minSlot, in.IncludeWeekends)
+
+	calendarCount := 0
+	for _, ok := range counted {
+		if ok {
+			calendarCount++
+		}
+	}
+	return textResult(formatFreeBusy(s.locale, from, to, loc, calendarCount, len(events), blocks, free, hours, minSlot)), nil, nil
+}
+
+// parseTimeIn parses YYYY-MM-DD as midnight in loc, or an RFC 3339 time.
+func parseTimeIn(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date format %q: expected YYYY-MM-DD or RFC 3339", s)
+	}
+	return t.In(loc), nil
+}
+
+// parseWorkingHours parses "HH:MM-HH:MM" into offsets from midnight.
+func parseWorkingHours(s string) (time.Duration, time.Duration, error) {
+	a, b, ok := strings.Cut(s, "-")
+	if ok {
+		start, err1 := time.Parse("15:04", strings.TrimSpace(a))
+		end, err2 := time.Parse("15:04", strings.TrimSpace(b))
+		if err1 == nil && err2 == nil && end.After(start) {
+			midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+			return start.Sub(midnight), end.Sub(midnight), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid working_hours %q: expected HH:MM-HH:MM with end after start", s)
+}
+
+// eventCountsAsBusy reports whether the event blocks time: not cancelled,
+// not marked free, and in at least one counted calendar.
+func eventCountsAsBusy(e *calendars.Event, counted map[jmap.ID]bool) bool {
+	if e.Status == "cancelled" || e.FreeBusyStatus == "free" {
+		return false
+	}
+	for id := range e.CalendarIDs {
+		if counted[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// eventInterval returns when the event starts and ends, preferring the
+// server-computed UTC bounds. Otherwise the local start is read in the
+// event's time zone (floating events in loc) and the duration added in
+// wall-clock days and clock time; all-day events last a day by default.
+func eventInterval(e *calendars.Event, loc *time.Location) (time.Time, time.Time, error) {
+	if e.UTCStart != "" && e.UTCEnd != "" {
+		start, err1 := time.Parse(time.RFC3339, e.UTCStart)
+		end, err2 := time.Parse(time.RFC3339, e.UTCEnd)
+		if err1 == nil && err2 == nil {
+			return start, end, nil
+		}
+	}
+
+	tz := loc
+	if e.TimeZone != nil {
+		if l, err := time.LoadLocation(*e.TimeZone); err == nil {
+			tz = l
+		}
+	}
+	start, err := time.ParseInLocation("2006-01-02T15:04:05", e.Start, tz)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("event %s: invalid start %q", e.ID, e.Start)
+	}
+	duration := e.Duration
+	if e.ShowWithoutTime {
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, tz)
+		if duration == "" {
+			duration = "P1D"
+		}
+	}
+	if duration == "" {
+		duration = "PT0S"
+	}
+	days, clock, err := parseISODuration(duration)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("event %s: %w", e.ID, err)
+	}
+	return start, start.AddDate(0, 0, days).Add(clock), nil
+}
+
+// parseISODuration parses an ISO 8601 / RFC 8984 duration such as P1W,
+// P1DT2H, or PT30M into whole days (weeks included) and clock time.
+func parseISODuration(s string) (int, time.Duration, error) {
+	rest, ok := strings.CutPrefix(s, "P")
+	if !ok || rest == "" {
+		return 0, 0, fmt.Errorf("invalid duration %q", s)
+	}
+	datePart, timePart, hasTime := strings.Cut(rest, "T")
+	if hasTime && timePart == "" {
+		return 0, 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var days int
+	var clock time.Duration
+	for _, part := range []struct {
+		text  string
+		units map[byte]func(int)
+	}{
+		{datePart, map[byte]func(int){
+			'W': func(n int) { days += 7 * n },
+			'D': func(n int) { days += n },
+		}},
+		{timePart, map[byte]func(int){
+			'H': func(n int) { clock += time.Duration(n) * time.Hour },
+			'M': func(n int) { clock += time.Duration(n) * time.Minute },
+			'S': func(n int) { clock += time.Duration(n) * time.Second },
+		}},
+	} {
+		num := ""
+		for i := 0; i < len(part.text); i++ {
+			c := part.text[i]
+			if c >= '0' && c <= '9' {
+				num += string(c)
+				continue
+			}
+			add, ok := part.units[c]
+			if !ok || num == "" {
+				return 0, 0, fmt.Errorf("invalid duration %q", s)
+			}
+			n, _ := strconv.Atoi(num)
+			add(n)
+			num = ""
+		}
+		if num != "" {
+			return 0, 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	return days, clock, nil
+}
+
+// mergeBusyBlocks sorts blocks and merges overlapping or touching ones.
+func mergeBusyBlocks(blocks []busyBlock) []busyBlock {
+	slices.SortFunc(blocks, func(a, b busyBlock) int {
+		if c := a.start.Compare(b.start); c != 0 {
+			return c
+		}
+		return a.end.Compare(b.end)
+	})
+	var out []busyBlock
+	for _, b := range blocks {
+		if n := len(out); n > 0 && !b.start.After(out[n-1].end) {
+			last := &out[n-1]
+			last.end = maxTime(last.end, b.end)
+			last.titles = append(last.titles, b.titles...)
+			last.tentative = last.tentative && b.tentative
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// freeSlots returns the gaps between busy blocks inside each day's working
+// window, skipping weekends unless asked, and dropping gaps under minSlot.
+func freeSlots(blocks []busyBlock, from, to time.Time, loc *time.Location, dayStart, dayEnd, minSlot time.Duration, weekends bool) [][2]time.Time {
+	var free [][2]time.Time
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !weekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		// Wall-clock offsets, so the window holds across DST changes.
+		at := func(off time.Duration) time.Time {
+			return time.Date(day.Year(), day.Month(), day.Day(), 0, int(off/time.Minute), 0, 0, loc)
+		}
+		start := maxTime(at(dayStart), from)
+		end := minTime(at(dayEnd), to)
+		for _, b := range blocks {
+			if !b.end.After(start) || !b.start.Before(end) {
+				continue
+			}
+			if b.start.Sub(start) >= minSlot {
+				free = append(free, [2]time.Time{start, b.start})
+			}
+			start = maxTime(start, b.end)
+		}
+		if end.Sub(start) >= minSlot {
+			free = append(free, [2]time.Time{start, end})
+		}
+	}
+	return free
+}
+
+func formatFreeBusy(l textStyle, from, to time.Time, loc *time.Location, calendarCount, eventCount int, blocks []busyBlock, free [][2]time.Time, hours string, minSlot time.Duration) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Free/busy %s (%s), %d calendar(s), %d event(s)\n",
+		l.rangeText(l.dateTime(from), l.dateTime(to)), loc, calendarCount, eventCount)
+
+	sb.WriteString("\nBusy:\n")
+	if len(blocks) == 0 {
+		sb.WriteString("  (none)\n")
+	}
+	for _, b := range blocks {
+		fmt.Fprintf(&sb, "  %s  %s", formatSpan(l, b.start.In(loc), b.end.In(loc)), strings.Join(b.titles, "; "))
+		if b.tentative {
+			sb.WriteString(" (tentative)")
+		}
+		sb.WriteString("\n")
+	}
+
+	fmt.Fprintf(&sb, "\nFree (%s, at least %s):\n", hours, minSlot)
+	if len(free) == 0 {
+		sb.WriteString("  (none)\n")
+	}
+	for _, f := range free {
+		fmt.Fprintf(&sb, "  %s (%s)\n", formatSpan(l, f[0].In(loc), f[1].In(loc)), f[1].Sub(f[0]))
+	}
+	return sb.String()
+}
+
+// formatSpan renders a time span, repeating the date only when it ends on
+// a later day.
+func formatSpan(l textStyle, start, end time.Time) string {
+	if start.Format("2006-01-02") == end.Format("2006-01-02") {
+		return l.weekdayDateTime(start) + "-" + l.clock(end)
+	}
+	return l.rangeText(l.weekdayDateTime(start), l.weekdayDateTime(end))
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
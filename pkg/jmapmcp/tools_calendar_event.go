@@ -0,0 +1,349 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/calendars"
+)
+
+const defaultEventDuration = time.Hour
+
+// --- calendar_event_create ---
+
+type CalendarEventCreateInput struct {
+	Title       string `json:"title" jsonschema:"Event title"`
+	Start       string `json:"start" jsonschema:"Start as local time YYYY-MM-DDTHH:MM in time_zone, RFC 3339, or YYYY-MM-DD for an all-day event"`
+	Duration    string `json:"duration,omitempty" jsonschema:"ISO 8601 duration, e.g. PT30M or P1D (default PT1H, or P1D for all-day events)"`
+	TimeZone    string `json:"time_zone,omitempty" jsonschema:"IANA time zone of the event, e.g. Europe/Berlin (default UTC)"`
+	Location    string `json:"location,omitempty" jsonschema:"Where the event takes place: room, address, or meeting link"`
+	Description string `json:"description,omitempty" jsonschema:"Event description"`
+	CalendarID  string `json:"calendar_id,omitempty" jsonschema:"Calendar to create the event in (default: the default calendar)"`
+}
+
+var calendarEventCreateTool = &mcp.Tool{
+	Name:        "calendar_event_create",
+	Description: "Create a calendar event (CalendarEvent/set) with a title, start, duration, time zone, location, and description. Requires server support for urn:ietf:params:jmap:calendars. To create an event from a message, use email_to_event instead.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleCalendarEventCreate(ctx context.Context, _ *mcp.CallToolRequest, in CalendarEventCreateInput) (*mcp.CallToolResult, any, error) {
+	if in.Title == "" || in.Start == "" {
+		return errorResult(invalidArgument("title and start are required")), nil, nil
+	}
+	loc, err := eventLocation(in.TimeZone)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	d := eventDetails{title: in.Title, location: in.Location}
+	if d.start, d.allDay, err = parseEventStart(in.Start, loc); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if in.Duration != "" {
+		if d.duration, err = eventDuration(in.Duration); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID, err := calendarsAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	text, err := createCalendarEvent(ctx, client, accountID, in.CalendarID, newCalendarEvent(d, loc, in.Description))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(text), nil, nil
+}
+
+// --- email_to_event ---
+
+type EmailToEventInput struct {
+	EmailID    string `json:"email_id" jsonschema:"ID of the email describing the event"`
+	CalendarID string `json:"calendar_id,omitempty" jsonschema:"Calendar to create the event in (default: the default calendar)"`
+	TimeZone   string `json:"time_zone,omitempty" jsonschema:"IANA time zone for times written in the message and for the event (default UTC)"`
+	Title      string `json:"title,omitempty" jsonschema:"Event title (default: from the invitation, else the email subject)"`
+	Start      string `json:"start,omitempty" jsonschema:"Override the extracted start: YYYY-MM-DDTHH:MM in time_zone, RFC 3339, or YYYY-MM-DD for all day"`
+	Duration   string `json:"duration,omitempty" jsonschema:"Override the extracted duration (ISO 8601, e.g. PT45M)"`
+	Location   string `json:"location,omitempty" jsonschema:"Override the extracted location"`
+}
+
+var emailToEventTool = &mcp.Tool{
+	Name:        "email_to_event",
+	Description: "Create a calendar event from an email. Date, time, duration, and location are taken from an attached invitation (text/calendar) if present, else extracted from the subject and text (dates like 2025-01-07, Jan 7, or 7 January; times like 14:30 or 2pm, ranges like 2pm-3pm; Location:/Where: lines or meeting links). Any field can be overridden; fails if no date is found and none is given. The event description links back to the email ID. Requires server support for urn:ietf:params:jmap:calendars.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailToEvent(ctx context.Context, _ *mcp.CallToolRequest, in EmailToEventInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	loc, err := eventLocation(in.TimeZone)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	calendarAccount, err := calendarsAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	e, err := fetchSourceEmail(ctx, client, accountID, in.EmailID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	// An attached invitation is authoritative; otherwise read the text.
+	var d eventDetails
+	found := false
+	if part := findBodyPart(e.BodyStructure, "text/calendar", "application/ics"); part != nil {
+		if text, err := downloadText(ctx, client, accountID, part.BlobID); err == nil {
+			d, found = parseICSEvent(text, loc)
+		}
+	}
+	if !found {
+		d = extractEventFromText(sourceEmailText(e), receivedAt(e), loc)
+	}
+	if d.title == "" {
+		d.title = e.Subject
+	}
+
+	if in.Title != "" {
+		d.title = in.Title
+	}
+	if in.Start != "" {
+		if d.start, d.allDay, err = parseEventStart(in.Start, loc); err != nil {
+			return errorResult(err), nil, nil
+		}
+		d.source = "given start"
+	}
+	if in.Duration != "" {
+		if d.duration, err = eventDuration(in.Duration); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+	if in.Location != "" {
+		d.location = in.Location
+	}
+	if d.start.IsZero() {
+		return errorResult(fmt.Errorf("no date found in email %s; pass start", in.EmailID)), nil, nil
+	}
+	if d.title == "" {
+		d.title = "(no subject)"
+	}
+
+	text, err := createCalendarEvent(ctx, client, calendarAccount, in.CalendarID, newCalendarEvent(d, loc, sourceEmailNote(e)))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(fmt.Sprintf("%sDetails from: %s\n", text, d.source)), nil, nil
+}
+
+// calendarsAccountID returns the primary calendars account, or an error if
+// the server does not advertise JMAP Calendars.
+func calendarsAccountID(client JMAPClient) (jmap.ID, error) {
+	if _, ok := client.Session().RawCapabilities[calendars.URI]; !ok {
+		return "", capabilityMissing(client.Session(), calendars.URI)
+	}
+	id := client.Session().PrimaryAccounts[calendars.URI]
+	if id == "" {
+		return "", fmt.Errorf("no primary calendars account")
+	}
+	return id, nil
+}
+
+func eventLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time_zone %q: %w", tz, err)
+	}
+	return loc, nil
+}
+
+// parseEventStart parses a local date-time in loc, an RFC 3339 time, or a
+// date, which makes the event all-day.
+func parseEventStart(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid start %q: expected YYYY-MM-DDTHH:MM, RFC 3339, or YYYY-MM-DD", s)
+}
+
+func eventDuration(s string) (time.Duration, error) {
+	days, clock, err := parseISODuration(s)
+	if err != nil {
+		return 0, err
+	}
+	d := time.Duration(days)*24*time.Hour + clock
+	if d <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", s)
+	}
+	return d, nil
+}
+
+// formatISODuration renders d as an ISO 8601 duration, whole days as nD.
+func formatISODuration(d time.Duration) string {
+	var sb strings.Builder
+	sb.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&sb, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		sb.WriteString("T")
+		if h := d / time.Hour; h > 0 {
+			fmt.Fprintf(&sb, "%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m > 0 {
+			fmt.Fprintf(&sb, "%dM", m)
+			d -= m * time.Minute
+		}
+		if sec := d / time.Second; sec > 0 {
+			fmt.Fprintf(&sb, "%dS", sec)
+		}
+	}
+	if sb.Len() == 1 {
+		return "PT0S"
+	}
+	return sb.String()
+}
+
+// newCalendarEvent builds a JSCalendar event from the details: all-day
+// events are floating with showWithoutTime, others local to loc.
+func newCalendarEvent(d eventDetails, loc *time.Location, description string) *calendars.Event {
+	ev := &calendars.Event{
+		Type:        "Event",
+		Title:       d.title,
+		Description: description,
+	}
+	duration := d.duration
+	if d.allDay {
+		ev.ShowWithoutTime = true
+		ev.Start = d.start.Format("2006-01-02") + "T00:00:00"
+		if duration <= 0 {
+			duration = 24 * time.Hour
+		}
+	} else {
+		tz := loc.String()
+		ev.TimeZone = &tz
+		ev.Start = d.start.In(loc).Format("2006-01-02T15:04:05")
+		if duration <= 0 {
+			duration = defaultEventDuration
+		}
+	}
+	ev.Duration = formatISODuration(duration)
+	if d.location != "" {
+		ev.Locations = map[string]*calendars.Location{"1": {Type: "Location", Name: d.location}}
+	}
+	return ev
+}
+
+// createCalendarEvent creates ev in calendarID, or in the default calendar
+// (else the first) when calendarID is empty, and describes the result.
+func createCalendarEvent(ctx context.Context, client JMAPClient, accountID jmap.ID, calendarID string, ev *calendars.Event) (string, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&calendars.CalendarGet{Account: accountID, Properties: []string{"id", "name", "isDefault"}})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for Calendar/get")
+	}
+
+	var cal *calendars.Calendar
+	switch args := resp.Responses[0].Args.(type) {
+	case *calendars.CalendarGetResponse:
+		for _, c := range args.List {
+			switch {
+			case calendarID != "":
+				if string(c.ID) == calendarID {
+					cal = c
+				}
+			case cal == nil || c.IsDefault && !cal.IsDefault:
+				cal = c
+			}
+		}
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+	if cal == nil {
+		if calendarID != "" {
+			return "", notFoundError([]string{calendarID}, "calendar %s not found", calendarID)
+		}
+		return "", fmt.Errorf("account has no calendars")
+	}
+	ev.CalendarIDs = map[jmap.ID]bool{cal.ID: true}
+
+	req = &jmap.Request{Context: ctx}
+	req.Invoke(&calendars.EventSet{Account: accountID, Create: map[jmap.ID]*calendars.Event{"event": ev}})
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for CalendarEvent/set")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *calendars.EventSetResponse:
+		if se, ok := args.NotCreated["event"]; ok {
+			return "", setFailure("event creation failed", se)
+		}
+		var sb strings.Builder
+		if created, ok := args.Created["event"]; ok {
+			fmt.Fprintf(&sb, "Created event [id: %s] in calendar %s\n", created.ID, cal.Name)
+		} else {
+			fmt.Fprintf(&sb, "Created event in calendar %s\n", cal.Name)
+		}
+		fmt.Fprintf(&sb, "Title: %s\n", ev.Title)
+		if ev.ShowWithoutTime {
+			fmt.Fprintf(&sb, "When: %s, all day (%s)\n", ev.Start[:10], ev.Duration)
+		} else {
+			fmt.Fprintf(&sb, "When: %s %s (%s)\n", strings.Replace(ev.Start[:16], "T", " ", 1), *ev.TimeZone, ev.Duration)
+		}
+		for _, l := range ev.Locations {
+			fmt.Fprintf(&sb, "Location: %s\n", l.Name)
+		}
+		return sb.String(), nil
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+}
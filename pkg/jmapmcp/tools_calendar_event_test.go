@@ -0,0 +1,163 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/calendars"
+)
+
+func TestParseICSEvent(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\nSUMMARY:Quarterly review\\, Q1\r\nDTSTART;TZID=Europe/Berlin:20250107T140000\r\n" +
+		"DTEND;TZID=Europe/Berlin:20250107T153000\r\nLOCATION:Room 4\\; 2nd floor\r\n  (east wing)\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	d, ok := parseICSEvent(ics, time.UTC)
+	if !ok {
+		t.Fatal("no event found")
+	}
+	if d.title != "Quarterly review, Q1" || d.location != "Room 4; 2nd floor (east wing)" {
+		t.Errorf("title %q, location %q", d.title, d.location)
+	}
+	if got := d.start.UTC().Format(time.RFC3339); got != "2025-01-07T13:00:00Z" || d.allDay {
+		t.Errorf("start = %s (all day %v)", got, d.allDay)
+	}
+	if d.duration != 90*time.Minute {
+		t.Errorf("duration = %v", d.duration)
+	}
+
+	if _, ok := parseICSEvent("BEGIN:VEVENT\nSUMMARY:No start\nEND:VEVENT\n", time.UTC); ok {
+		t.Error("event without DTSTART accepted")
+	}
+}
+
+func TestExtractEventFromText(t *testing.T) {
+	ref := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name, text string
+		start      string
+		allDay     bool
+		duration   time.Duration
+		location   string
+	}{
+		{"month day range", "Lunch on January 7 at 12:30pm-1:30pm\nWhere: Cafe Central",
+			"2026-01-07T12:30", false, time.Hour, "Cafe Central"},
+		{"iso with clock", "Kickoff 2025-03-10 14:00 to 15:15, join https://meet.google.com/abc-defg-hij.",
+			"2025-03-10T14:00", false, 75 * time.Minute, "https://meet.google.com/abc-defg-hij"},
+		{"day month year", "Offsite on the 4th of April 2025\nLocation: Lisbon office",
+			"2025-04-04T00:00", true, 0, "Lisbon office"},
+		{"no date", "See you soon at 3pm", "", false, 0, ""},
+	}
+	for _, tt := range tests {
+		d := extractEventFromText(tt.text, ref, time.UTC)
+		start := ""
+		if !d.start.IsZero() {
+			start = d.start.Format("2006-01-02T15:04")
+		}
+		if start != tt.start || d.allDay != tt.allDay || d.duration != tt.duration || d.location != tt.location {
+			t.Errorf("%s: got start %q all day %v duration %v location %q", tt.name, start, d.allDay, d.duration, d.location)
+		}
+	}
+}
+
+func TestFormatISODuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		time.Hour:                  "PT1H",
+		90 * time.Minute:           "PT1H30M",
+		24 * time.Hour:             "P1D",
+		26*time.Hour + time.Second: "P1DT2H1S",
+		0:                          "PT0S",
+	} {
+		if got := formatISODuration(d); got != want {
+			t.Errorf("formatISODuration(%v) = %s, want %s", d, got, want)
+		}
+	}
+}
+
+func TestCalendarEventCreate(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.AddCapability(string(calendars.URI), nil)
+	fake.Add("Calendar", map[string]any{"id": "personal", "name": "Personal"})
+	fake.Add("Calendar", map[string]any{"id": "work", "name": "Work", "isDefault": true})
+	res, _, _ := s.handleCalendarEventCreate(context.Background(), nil, CalendarEventCreateInput{
+		Title:    "Dentist",
+		Start:    "2025-01-08T09:30",
+		Duration: "PT45M",
+		TimeZone: "Europe/Berlin",
+		Location: "Main St 1",
+	})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	for _, want := range []string{"in calendar Work", "When: 2025-01-08 09:30 Europe/Berlin (PT45M)", "Location: Main St 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	ids := fake.Objects("CalendarEvent")
+	if len(ids) != 1 {
+		t.Fatalf("created %d events", len(ids))
+	}
+	ev := fake.Object("CalendarEvent", ids[0])
+	if ev["@type"] != "Event" || ev["start"] != "2025-01-08T09:30:00" || ev["timeZone"] != "Europe/Berlin" || ev["duration"] != "PT45M" {
+		t.Errorf("unexpected event: %v", ev)
+	}
+	if cals := ev["calendarIds"].(map[string]any); cals["work"] != true {
+		t.Errorf("calendarIds = %v", cals)
+	}
+
+	res, _, _ = s.handleCalendarEventCreate(context.Background(), nil, CalendarEventCreateInput{Title: "Holiday", Start: "2025-01-10", CalendarID: "personal"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	if out := resultText(res); !strings.Contains(out, "in calendar Personal") || !strings.Contains(out, "When: 2025-01-10, all day (P1D)") {
+		t.Errorf("unexpected all-day result:\n%s", out)
+	}
+
+	res, _, _ = s.handleCalendarEventCreate(context.Background(), nil, CalendarEventCreateInput{Title: "X", Start: "2025-01-10", CalendarID: "nope"})
+	if !res.IsError || !strings.Contains(resultText(res), "calendar nope not found") {
+		t.Errorf("unexpected result: %s", resultText(res))
+	}
+}
+
+func TestEmailToEvent(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.AddCapability(string(calendars.URI), nil)
+	fake.Add("Calendar", map[string]any{"id": "work", "name": "Work"})
+	addEmail(fake, "m1", "Team lunch", "Hi all,\n\nlet's meet on January 7 at 12:30pm - 1:30pm.\nWhere: Cafe Central\n", 2)
+	addEmail(fake, "m2", "Catch up", "Let's talk soon.", 3)
+
+	res, _, _ := s.handleEmailToEvent(context.Background(), nil, EmailToEventInput{EmailID: "m1", TimeZone: "Europe/Vienna"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	for _, want := range []string{"Title: Team lunch", "When: 2025-01-07 12:30 Europe/Vienna (PT1H)", "Location: Cafe Central", "Details from: message text"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	ev := fake.Object("CalendarEvent", fake.Objects("CalendarEvent")[0])
+	if desc, _ := ev["description"].(string); !strings.Contains(desc, "Created from email m1") {
+		t.Errorf("description = %q", desc)
+	}
+
+	res, _, _ = s.handleEmailToEvent(context.Background(), nil, EmailToEventInput{EmailID: "m2"})
+	if !res.IsError || !strings.Contains(resultText(res), "no date found") {
+		t.Errorf("unexpected result: %s", resultText(res))
+	}
+	res, _, _ = s.handleEmailToEvent(context.Background(), nil, EmailToEventInput{EmailID: "m2", Start: "2025-01-09T16:00"})
+	if res.IsError || !strings.Contains(resultText(res), "When: 2025-01-09 16:00 UTC (PT1H)") {
+		t.Errorf("unexpected result: %s", resultText(res))
+	}
+}
+
+func TestCalendarEventCreateWithoutCapability(t *testing.T) {
+	s, _ := newFakeServer(t)
+	res, _, _ := s.handleCalendarEventCreate(context.Background(), nil, CalendarEventCreateInput{Title: "X", Start: "2025-01-06"})
+	if !res.IsError || !strings.Contains(resultText(res), "does not support JMAP calendars") {
+		t.Errorf("unexpected result: %s", resultText(res))
+	}
+}
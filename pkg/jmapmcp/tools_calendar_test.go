@@ -0,0 +1,119 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/calendars"
+)
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		in    string
+		days  int
+		clock time.Duration
+		ok    bool
+	}{
+		{"PT1H30M", 0, 90 * time.Minute, true},
+		{"P1D", 1, 0, true},
+		{"P1W2DT15S", 9, 15 * time.Second, true},
+		{"PT0S", 0, 0, true},
+		{"P", 0, 0, false},
+		{"PT", 0, 0, false},
+		{"P1H", 0, 0, false},
+		{"1D", 0, 0, false},
+		{"PT5", 0, 0, false},
+	}
+	for _, tt := range tests {
+		days, clock, err := parseISODuration(tt.in)
+		if (err == nil) != tt.ok || days != tt.days || clock != tt.clock {
+			t.Errorf("parseISODuration(%q) = %d, %v, %v; want %d, %v, ok=%v", tt.in, days, clock, err, tt.days, tt.clock, tt.ok)
+		}
+	}
+}
+
+func TestEventInterval(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	ny := "America/New_York"
+	tests := []struct {
+		name       string
+		event      *calendars.Event
+		start, end string
+	}{
+		{"utc bounds", &calendars.Event{UTCStart: "2025-01-06T09:00:00Z", UTCEnd: "2025-01-06T10:00:00Z", Start: "bogus"},
+			"2025-01-06T09:00:00Z", "2025-01-06T10:00:00Z"},
+		{"event zone", &calendars.Event{Start: "2025-01-06T09:00:00", TimeZone: &ny, Duration: "PT45M"},
+			"2025-01-06T14:00:00Z", "2025-01-06T14:45:00Z"},
+		{"floating", &calendars.Event{Start: "2025-01-06T09:00:00", Duration: "PT1H"},
+			"2025-01-06T08:00:00Z", "2025-01-06T09:00:00Z"},
+		{"all day", &calendars.Event{Start: "2025-01-06T00:00:00", ShowWithoutTime: true},
+			"2025-01-05T23:00:00Z", "2025-01-06T23:00:00Z"},
+	}
+	for _, tt := range tests {
+		start, end, err := eventInterval(tt.event, berlin)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := start.UTC().Format(time.RFC3339); got != tt.start {
+			t.Errorf("%s: start = %s, want %s", tt.name, got, tt.start)
+		}
+		if got := end.UTC().Format(time.RFC3339); got != tt.end {
+			t.Errorf("%s: end = %s, want %s", tt.name, got, tt.end)
+		}
+	}
+}
+
+func TestCalendarFreeBusy(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.AddCapability(string(calendars.URI), nil)
+	fake.Add("Calendar", map[string]any{"id": "work", "name": "Work", "includeInAvailability": "all"})
+	fake.Add("Calendar", map[string]any{"id": "holidays", "name": "Holidays", "includeInAvailability": "none"})
+	event := func(id, cal, title, start, end string, extra map[string]any) {
+		e := map[string]any{"id": id, "calendarIds": map[string]any{cal: true}, "title": title, "utcStart": start, "utcEnd": end}
+		for k, v := range extra {
+			e[k] = v
+		}
+		fake.Add("CalendarEvent", e)
+	}
+	event("ev1", "work", "Standup", "2025-01-06T09:00:00Z", "2025-01-06T10:00:00Z", nil)
+	event("ev2", "work", "Design review", "2025-01-06T09:30:00Z", "2025-01-06T11:00:00Z", map[string]any{"status": "tentative"})
+	event("ev3", "work", "Cancelled sync", "2025-01-06T13:00:00Z", "2025-01-06T14:00:00Z", map[string]any{"status": "cancelled"})
+	event("ev4", "work", "Focus time", "2025-01-06T14:00:00Z", "2025-01-06T15:00:00Z", map[string]any{"freeBusyStatus": "free"})
+	event("ev5", "holidays", "Epiphany", "2025-01-05T23:00:00Z", "2025-01-06T23:00:00Z", nil)
+	event("ev6", "work", "Next week", "2025-01-13T09:00:00Z", "2025-01-13T10:00:00Z", nil)
+
+	res, _, _ := s.handleCalendarFreeBusy(context.Background(), nil, CalendarFreeBusyInput{
+		Start:    "2025-01-06",
+		End:      "2025-01-07",
+		TimeZone: "Europe/Berlin",
+	})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	for _, want := range []string{
+		"Free/busy 2025-01-06 00:00 to 2025-01-07 00:00 (Europe/Berlin), 1 calendar(s)",
+		"Busy:\n  Mon 2025-01-06 10:00-12:00  Standup; Design review\n",
+		"Free (09:00-17:00, at least 30m0s):\n  Mon 2025-01-06 09:00-10:00 (1h0m0s)\n  Mon 2025-01-06 12:00-17:00 (5h0m0s)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"Cancelled sync", "Focus time", "Epiphany", "Next week"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("%s counted as busy:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestCalendarFreeBusyWithoutCapability(t *testing.T) {
+	s, _ := newFakeServer(t)
+	res, _, _ := s.handleCalendarFreeBusy(context.Background(), nil, CalendarFreeBusyInput{Start: "2025-01-06", End: "2025-01-07"})
+	if !res.IsError || !strings.Contains(resultText(res), "does not support JMAP calendars") {
+		t.Errorf("unexpected result: %s", resultText(res))
+	}
+}
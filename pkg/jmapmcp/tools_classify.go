@@ -0,0 +1,213 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_classify ---
+
+type EmailClassifyInput struct {
+	EmailIDs   []string `json:"email_ids,omitempty" jsonschema:"IDs of emails to classify"`
+	Selection  string   `json:"selection,omitempty" jsonschema:"Instead of email_ids: name of a selection made with selection_set"`
+	MailboxID  string   `json:"mailbox_id,omitempty" jsonschema:"Instead of email_ids: classify the newest messages in this mailbox that have no category yet"`
+	Limit      int      `json:"limit,omitempty" jsonschema:"With mailbox_id: how many messages to classify at most (default 20, max 100)"`
+	Reclassify bool     `json:"reclassify,omitempty" jsonschema:"With email_ids or selection: classify emails that already have a category again (default: leave them as they are)"`
+}
+
+var emailClassifyTool = &mcp.Tool{
+	Name:        "email_classify",
+	Description: "Sort emails into the operator's categories and record each one's category as a keyword (category-<name>, replacing any other category), so classifications are queryable with email_query keyword filters and show as tags in other mail clients. Give email_ids or a selection, or a mailbox_id to classify its newest unclassified messages. The client's own model decides through sampling when the client offers it; otherwise the configured classification endpoint does. Undo with undo_last.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleEmailClassify(ctx context.Context, req *mcp.CallToolRequest, in EmailClassifyInput) (*mcp.CallToolResult, any, error) {
+	ids, err := s.selectedEmailIDs(ctx, req, in.EmailIDs, in.Selection)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	switch {
+	case in.MailboxID != "" && len(ids) > 0:
+		return errorResult(invalidArgument("give email_ids, selection, or mailbox_id, not several")), nil, nil
+	case in.MailboxID == "" && len(ids) == 0:
+		return errorResult(invalidArgument("email_ids, selection, or mailbox_id is required")), nil, nil
+	case len(ids) > maxClassify:
+		return errorResult(invalidArgument("at most %d emails per call, got %d", maxClassify, len(ids))), nil, nil
+	case in.Limit < 0 || in.Limit > maxClassify:
+		return errorResult(invalidArgument("limit must be 1 to %d", maxClassify)), nil, nil
+	}
+	if in.Limit == 0 {
+		in.Limit = classifyBatch
+	}
+	c := s.classifier
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	properties := []string{"id", "from", "subject", "preview", "keywords"}
+	var emails []*email.Email
+	skipped := 0
+	if in.MailboxID != "" {
+		emails, err = c.unclassified(ctx, client, accountID, jmap.ID(in.MailboxID), in.Limit, properties)
+	} else {
+		var found []*email.Email
+		found, err = getClassifyEmails(ctx, client, accountID, toJMAPIDSlice(ids), properties)
+		for _, e := range found {
+			if !in.Reclassify && c.categoryOf(e.Keywords) != "" {
+				skipped++
+				continue
+			}
+			emails = append(emails, e)
+		}
+	}
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(emails) == 0 {
+		if skipped > 0 {
+			return textResult(fmt.Sprintf("All %d email(s) already have a category; pass reclassify to classify them again", skipped)), nil, nil
+		}
+		return textResult("No emails to classify"), nil, nil
+	}
+
+	categories, source, err := s.classify(ctx, req, emails)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	updates := make(map[jmap.ID]jmap.Patch, len(categories))
+	prior := make(map[jmap.ID]map[string]bool, len(categories))
+	for id, cat := range categories {
+		updates[id] = c.patch(cat)
+	}
+	for _, e := range emails {
+		if _, ok := updates[e.ID]; ok {
+			prior[e.ID] = make(map[string]bool, len(c.categories))
+			for _, cat := range c.categories {
+				prior[e.ID][c.keyword(cat)] = e.Keywords[c.keyword(cat)]
+			}
+		}
+	}
+	var notUpdated map[jmap.ID]*jmap.SetError
+	if len(updates) > 0 {
+		setReq := &jmap.Request{Context: ctx}
+		setReq.Invoke(&email.Set{Account: accountID, Update: updates})
+		resp, err := client.Do(setReq)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if len(resp.Responses) == 0 {
+			return errorResult(fmt.Errorf("empty response for Email/set")), nil, nil
+		}
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.SetResponse:
+			notUpdated = args.NotUpdated
+			s.journal(ctx, req, &journalEntry{tool: "email_classify", accountID: accountID, keywords: prior}, notUpdated)
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Classified %d of %d email(s) with %s\n", len(categories)-len(notUpdated), len(emails), source)
+	for _, e := range emails {
+		cat, ok := categories[e.ID]
+		switch {
+		case !ok:
+			cat = "(no category given)"
+		case notUpdated[e.ID] != nil:
+			cat += " (not saved: " + setErrorText(notUpdated[e.ID]) + ")"
+		}
+		fmt.Fprintf(&sb, "- %s: %s [id: %s]\n", cat, e.Subject, e.ID)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(&sb, "Skipped %d email(s) that already have a category\n", skipped)
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// unclassified returns up to limit of the newest emails in mailboxID that
+// have none of the category keywords.
+func (c *classifier) unclassified(ctx context.Context, client JMAPClient, accountID, mailboxID jmap.ID, limit int, properties []string) ([]*email.Email, error) {
+	var tagged []email.Filter
+	for _, cat := range c.categories {
+		tagged = append(tagged, &email.FilterCondition{HasKeyword: c.keyword(cat)})
+	}
+	req := &jmap.Request{Context: ctx}
+	queryCallID := req.Invoke(&email.Query{
+		Account: accountID,
+		Filter: &email.FilterOperator{Operator: jmap.OperatorAND, Conditions: []email.Filter{
+			&email.FilterCondition{InMailbox: mailboxID},
+			&email.FilterOperator{Operator: jmap.OperatorNOT, Conditions: tagged},
+		}},
+		Sort:  []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+		Limit: uint64(limit),
+	})
+	req.Invoke(&email.Get{
+		Account: accountID,
+		ReferenceIDs: &jmap.ResultReference{
+			ResultOf: queryCallID,
+			Name:     "Email/query",
+			Path:     "/ids",
+		},
+		Properties: properties,
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 2 {
+		return nil, fmt.Errorf("missing Email/get response in query chain")
+	}
+	if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+		return nil, me
+	}
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		return args.List, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// getClassifyEmails fetches the emails email_classify names, failing when
+// any is missing.
+func getClassifyEmails(ctx context.Context, client JMAPClient, accountID jmap.ID, ids []jmap.ID, properties []string) ([]*email.Email, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{Account: accountID, IDs: ids, Properties: properties})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Email/get")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 {
+			return nil, notFoundError(args.NotFound, "emails not found: %s", strings.Join(idList(args.NotFound), ", "))
+		}
+		return args.List, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
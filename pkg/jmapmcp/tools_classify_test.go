@@ -0,0 +1,95 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestEmailClassifyEndpoint(t *testing.T) {
+	var got []classifyRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in classifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		got = append(got, in)
+		json.NewEncoder(w).Encode(classifyResponse{Categories: map[jmap.ID]string{"e1": " Work", "e2": "spam", "e3": "work"}})
+	}))
+	defer srv.Close()
+
+	s, fake := newFakeServer(t, WithClassifier([]string{"work", "personal"}, srv.URL))
+	addEmail(fake, "e1", "Quarterly plan", "", 1)
+	addEmail(fake, "e2", "Win a prize", "", 2)
+	fake.Add("Email", map[string]any{"id": "e3", "subject": "Dinner", "receivedAt": "2025-01-03T10:00:00Z",
+		"mailboxIds": map[string]any{"inbox": true}, "keywords": map[string]any{"category-personal": true}})
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailClassify(ctx, nil, EmailClassifyInput{MailboxID: "inbox"})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Classified 1 of 2 email(s) with endpoint") ||
+		!strings.Contains(out, "- work: Quarterly plan [id: e1]") || !strings.Contains(out, "- (no category given): Win a prize [id: e2]") {
+		t.Fatalf("email_classify = %s", out)
+	}
+	if len(got) != 1 || len(got[0].Emails) != 2 || strings.Join(got[0].Categories, ",") != "work,personal" {
+		t.Errorf("endpoint got %+v", got)
+	}
+	if kw := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["category-work"] != true {
+		t.Errorf("e1 keywords = %v", kw)
+	}
+
+	res, _, _ = s.handleEmailClassify(ctx, nil, EmailClassifyInput{EmailIDs: []string{"e3"}})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "already have a category") {
+		t.Errorf("email_classify of a classified email = %s", out)
+	}
+	res, _, _ = s.handleEmailClassify(ctx, nil, EmailClassifyInput{EmailIDs: []string{"e3"}, Reclassify: true})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if kw := fake.Object("Email", "e3")["keywords"].(map[string]any); kw["category-work"] != true || kw["category-personal"] != nil {
+		t.Errorf("e3 keywords after reclassifying = %v", kw)
+	}
+	res, _, _ = s.handleUndoLast(ctx, nil, UndoLastInput{})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if kw := fake.Object("Email", "e3")["keywords"].(map[string]any); kw["category-work"] != nil || kw["category-personal"] != true {
+		t.Errorf("e3 keywords after undo = %v", kw)
+	}
+}
+
+func TestEmailClassifySampling(t *testing.T) {
+	s, fake := newFakeServer(t, WithClassifier([]string{"work", "personal"}, ""))
+	addEmail(fake, "e1", "Dinner on Friday?", "", 1)
+	ctx := context.Background()
+
+	if res, _, _ := s.handleEmailClassify(ctx, nil, EmailClassifyInput{EmailIDs: []string{"e1"}}); !res.IsError || !strings.Contains(resultText(res), "does not offer sampling") {
+		t.Errorf("email_classify without sampling or endpoint = %s", resultText(res))
+	}
+
+	var prompt string
+	cs := samplingSession(t, s, func(p string) string {
+		prompt = p
+		return "Here you go:\n```json\n{\"categories\": {\"e1\": \"personal\"}}\n```"
+	})
+
+	res, err := cs.CallTool(ctx, &mcp.CallToolParams{Name: "email_classify", Arguments: map[string]any{"email_ids": []string{"e1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Classified 1 of 1 email(s) with sampling") {
+		t.Fatalf("email_classify = %s", out)
+	}
+	if !strings.Contains(prompt, "Categories: work, personal") || !strings.Contains(prompt, "Dinner on Friday?") {
+		t.Errorf("prompt = %s", prompt)
+	}
+	if kw := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["category-personal"] != true {
+		t.Errorf("e1 keywords = %v", kw)
+	}
+}
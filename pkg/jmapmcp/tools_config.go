@@ -0,0 +1,512 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/mikluko/jmap/sieve/sievescript"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Configuration documents carry what a user set up on one account to
+// another: the mailbox tree, sending identities, and Sieve scripts. Import
+// only adds what the target lacks, matching mailboxes by role or path,
+// identities by address, and scripts by name; it never changes or removes
+// anything already there.
+
+const configDocumentVersion = 1
+
+// configDocument is what config_export writes and config_import reads.
+type configDocument struct {
+	Version      int                 `json:"version"`
+	Exported     time.Time           `json:"exported"`
+	Account      string              `json:"account,omitempty"` // session username of the source
+	Mailboxes    []configMailbox     `json:"mailboxes,omitempty"`
+	Identities   []configIdentity    `json:"identities,omitempty"`
+	SieveScripts []configSieveScript `json:"sieveScripts,omitempty"`
+}
+
+type configMailbox struct {
+	Path string `json:"path"`
+	Role string `json:"role,omitempty"`
+}
+
+type configIdentity struct {
+	Name          string          `json:"name,omitempty"`
+	Email         string          `json:"email"`
+	ReplyTo       []*mail.Address `json:"replyTo,omitempty"`
+	Bcc           []*mail.Address `json:"bcc,omitempty"`
+	TextSignature string          `json:"textSignature,omitempty"`
+	HTMLSignature string          `json:"htmlSignature,omitempty"`
+}
+
+type configSieveScript struct {
+	Name    string `json:"name"`
+	Active  bool   `json:"active,omitempty"`
+	Content string `json:"content"`
+}
+
+// --- config_export ---
+
+type ConfigExportInput struct {
+	Include []string `json:"include,omitempty" jsonschema:"Sections to export: mailboxes, identities, sieve (default all; sieve needs -enable-sieve and server support)"`
+}
+
+var configExportTool = &mcp.Tool{
+	Name:        "config_export",
+	Description: "Export the account's configuration as a JSON document: the mailbox tree (paths and roles), sending identities (name, address, reply-to, bcc, signatures), and, with Sieve enabled, the Sieve scripts with their content and which one is active. Pass the document to config_import on another account to recreate it there, e.g. when moving between JMAP providers. Changes nothing.",
+	Annotations: readOnlyAnnotations,
+}
+
+// configSections are the sections a configuration document can hold.
+var configSections = []string{"mailboxes", "identities", "sieve"}
+
+func (s *Server) handleConfigExport(ctx context.Context, _ *mcp.CallToolRequest, in ConfigExportInput) (*mcp.CallToolResult, any, error) {
+	include := in.Include
+	if len(include) == 0 {
+		include = configSections
+	}
+	for _, section := range include {
+		if !slices.Contains(configSections, section) {
+			return errorResult(invalidArgument("unknown section %q (one of %s)", section, strings.Join(configSections, ", "))), nil, nil
+		}
+	}
+	if len(in.Include) > 0 && slices.Contains(include, "sieve") && !s.enableSieve {
+		return errorResult(invalidArgument("sieve needs the server started with -enable-sieve")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	doc := configDocument{Version: configDocumentVersion, Exported: time.Now().UTC(), Account: client.Session().Username}
+	if slices.Contains(include, "mailboxes") {
+		mailboxes, err := fetchMailboxes(ctx, client, accountID)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		for _, mb := range mailboxes {
+			doc.Mailboxes = append(doc.Mailboxes, configMailbox{Path: mailboxPath(mb, mailboxes), Role: string(mb.Role)})
+		}
+		slices.SortFunc(doc.Mailboxes, func(a, b configMailbox) int { return strings.Compare(a.Path, b.Path) })
+	}
+	if slices.Contains(include, "identities") {
+		identities, err := fetchIdentities(ctx, client, accountID)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		for _, id := range identities {
+			doc.Identities = append(doc.Identities, configIdentity{
+				Name:          id.Name,
+				Email:         id.Email,
+				ReplyTo:       id.ReplyTo,
+				Bcc:           id.Bcc,
+				TextSignature: id.TextSignature,
+				HTMLSignature: id.HTMLSignature,
+			})
+		}
+	}
+	if slices.Contains(include, "sieve") && s.enableSieve {
+		if sieveAccount, err := sieveAccountID(client); err == nil {
+			scripts, err := fetchSieveScripts(ctx, client, sieveAccount)
+			if err != nil {
+				return errorResult(err), nil, nil
+			}
+			for _, script := range scripts {
+				content, err := downloadSieveScript(ctx, client, sieveAccount, script.BlobID)
+				if err != nil {
+					return errorResult(err), nil, nil
+				}
+				doc.SieveScripts = append(doc.SieveScripts, configSieveScript{Name: sieveScriptName(script), Active: script.IsActive, Content: content})
+			}
+		} else if len(in.Include) > 0 {
+			return errorResult(err), nil, nil
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(string(data)), nil, nil
+}
+
+// --- config_import ---
+
+type ConfigImportInput struct {
+	Document string `json:"document" jsonschema:"Configuration document from config_export"`
+	Apply    bool   `json:"apply,omitempty" jsonschema:"Make the changes; without it only the plan is shown. Set only after the user confirms the plan"`
+}
+
+var configImportTool = &mcp.Tool{
+	Name:        "config_import",
+	Description: "Recreate a configuration exported with config_export on this account: creates the mailboxes, identities, and (with Sieve enabled) Sieve scripts it lacks. Mailboxes are matched by role or path, identities by address, and scripts by name; nothing that exists is changed or removed, and a script marked active is activated only when no script is active yet. Without apply it only shows the plan.",
+	Annotations: mutatingAnnotations,
+}
+
+// configPlan is what importing a document would create.
+type configPlan struct {
+	mailboxes  map[string]MailboxSetCreate // keyed by creation ID, as from expandMailboxPaths
+	labels     map[jmap.ID]string
+	identities []configIdentity
+	scripts    []configSieveScript
+	activate   string // name of the script to activate, or ""
+	kept       []string
+}
+
+func (s *Server) handleConfigImport(ctx context.Context, _ *mcp.CallToolRequest, in ConfigImportInput) (*mcp.CallToolResult, any, error) {
+	var doc configDocument
+	if err := json.Unmarshal([]byte(in.Document), &doc); err != nil {
+		return errorResult(invalidArgument("document is not a configuration document: %v", err)), nil, nil
+	}
+	if doc.Version != configDocumentVersion {
+		return errorResult(invalidArgument("unsupported document version %d (want %d)", doc.Version, configDocumentVersion)), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	plan := &configPlan{}
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if err := planMailboxes(plan, doc.Mailboxes, mailboxes); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(doc.Identities) > 0 {
+		identities, err := fetchIdentities(ctx, client, accountID)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		planIdentities(plan, doc.Identities, identities)
+	}
+	var sieveAccount jmap.ID
+	if len(doc.SieveScripts) > 0 {
+		if !s.enableSieve {
+			plan.kept = append(plan.kept, fmt.Sprintf("Skipped %d Sieve script(s): the server was not started with -enable-sieve", len(doc.SieveScripts)))
+		} else if sieveAccount, err = sieveAccountID(client); err != nil {
+			plan.kept = append(plan.kept, fmt.Sprintf("Skipped %d Sieve script(s): %v", len(doc.SieveScripts), err))
+		} else {
+			scripts, err := fetchSieveScripts(ctx, client, sieveAccount)
+			if err != nil {
+				return errorResult(err), nil, nil
+			}
+			planSieveScripts(plan, doc.SieveScripts, scripts)
+		}
+	}
+	for _, script := range plan.scripts {
+		if err := checkSieveMaySend(ctx, script.Content); err != nil {
+			return errorResult(fmt.Errorf("sieve script %s: %w", script.Name, err)), nil, nil
+		}
+	}
+
+	var sb strings.Builder
+	if doc.Account != "" {
+		fmt.Fprintf(&sb, "Configuration of %s exported %s\n", doc.Account, s.locale.dateTime(doc.Exported))
+	}
+	for _, cid := range sortedKeys(plan.mailboxes) {
+		fmt.Fprintf(&sb, "Create mailbox %s\n", plan.labels[jmap.ID(cid)])
+	}
+	for _, id := range plan.identities {
+		if id.Name != "" {
+			fmt.Fprintf(&sb, "Create identity %s <%s>\n", id.Name, id.Email)
+		} else {
+			fmt.Fprintf(&sb, "Create identity %s\n", id.Email)
+		}
+	}
+	for _, script := range plan.scripts {
+		activate := ""
+		if script.Name == plan.activate {
+			activate = " and activate it"
+		}
+		fmt.Fprintf(&sb, "Create Sieve script %s%s\n", script.Name, activate)
+	}
+	for _, line := range plan.kept {
+		sb.WriteString(line + "\n")
+	}
+	if len(plan.mailboxes) == 0 && len(plan.identities) == 0 && len(plan.scripts) == 0 {
+		sb.WriteString("Nothing to import: this account already has everything in the document.\n")
+		return textResult(sb.String()), nil, nil
+	}
+	if !in.Apply {
+		sb.WriteString("\nNothing changed yet. After the user confirms, call config_import again with apply=true.\n")
+		return textResult(sb.String()), nil, nil
+	}
+
+	sb.WriteString("\n")
+	var failures []string
+	if len(plan.mailboxes) > 0 {
+		if err := checkMailboxSetRights(MailboxSetInput{Create: plan.mailboxes}, mailboxes); err != nil {
+			return errorResult(err), nil, nil
+		}
+		failures = append(failures, applyConfigMailboxes(ctx, client, accountID, plan, &sb)...)
+	}
+	if len(plan.identities) > 0 {
+		failures = append(failures, applyConfigIdentities(ctx, client, accountID, plan, &sb)...)
+	}
+	if len(plan.scripts) > 0 {
+		failures = append(failures, applyConfigSieveScripts(ctx, client, sieveAccount, plan, &sb)...)
+	}
+	if len(failures) > 0 {
+		fmt.Fprintf(&sb, "Errors: %s\n", strings.Join(failures, "; "))
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{&mcp.TextContent{Text: sb.String()}},
+		}, nil, nil
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// planMailboxes adds the mailboxes of the document the account lacks to
+// plan. A mailbox with a role the account already has a mailbox for maps to
+// that one, whatever it is called.
+func planMailboxes(plan *configPlan, want []configMailbox, mailboxes map[jmap.ID]*mailbox.Mailbox) error {
+	roles := map[mailbox.Role]bool{}
+	for _, mb := range mailboxes {
+		if mb.Role != "" {
+			roles[mb.Role] = true
+		}
+	}
+	create := map[string]MailboxSetCreate{}
+	for i, m := range want {
+		if m.Role != "" && roles[mailbox.Role(m.Role)] {
+			continue
+		}
+		create[fmt.Sprintf("m%d", i)] = MailboxSetCreate{Path: m.Path}
+	}
+	var err error
+	plan.mailboxes, plan.labels, _, err = expandMailboxPaths(create, mailboxes)
+	return err
+}
+
+// planIdentities adds the identities of the document whose address the
+// account has no identity for to plan.
+func planIdentities(plan *configPlan, want []configIdentity, identities []*identity.Identity) {
+	for _, w := range want {
+		if slices.ContainsFunc(identities, func(id *identity.Identity) bool { return strings.EqualFold(id.Email, w.Email) }) {
+			plan.kept = append(plan.kept, fmt.Sprintf("Identity %s exists", w.Email))
+			continue
+		}
+		plan.identities = append(plan.identities, w)
+	}
+}
+
+// planSieveScripts adds the scripts of the document the account has no
+// script of the same name for to plan, and picks the one to activate.
+func planSieveScripts(plan *configPlan, want []configSieveScript, scripts []*sievescript.SieveScript) {
+	active := ""
+	for _, script := range scripts {
+		if script.IsActive {
+			active = sieveScriptName(script)
+		}
+	}
+	for _, w := range want {
+		if slices.ContainsFunc(scripts, func(script *sievescript.SieveScript) bool { return sieveScriptName(script) == w.Name }) {
+			plan.kept = append(plan.kept, fmt.Sprintf("Sieve script %s exists", w.Name))
+			continue
+		}
+		plan.scripts = append(plan.scripts, w)
+		if w.Active {
+			if active == "" {
+				plan.activate = w.Name
+			} else {
+				plan.kept = append(plan.kept, fmt.Sprintf("Sieve script %s is not activated: %s is active", w.Name, active))
+			}
+		}
+	}
+}
+
+func applyConfigMailboxes(ctx context.Context, client JMAPClient, accountID jmap.ID, plan *configPlan, sb *strings.Builder) []string {
+	set := &mailbox.Set{Account: accountID, Create: make(map[jmap.ID]*mailbox.Mailbox, len(plan.mailboxes))}
+	for cid, c := range plan.mailboxes {
+		set.Create[jmap.ID(cid)] = &mailbox.Mailbox{Name: c.Name, ParentID: jmap.ID(c.ParentID)}
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(set)
+	resp, err := client.Do(req)
+	if err != nil {
+		return []string{fmt.Sprintf("mailboxes: %v", err)}
+	}
+	if len(resp.Responses) == 0 {
+		return []string{"mailboxes: empty response for Mailbox/set"}
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.SetResponse:
+		for _, cid := range sortedKeys(plan.mailboxes) {
+			if mb, ok := args.Created[jmap.ID(cid)]; ok {
+				fmt.Fprintf(sb, "Created mailbox %s [id: %s]\n", plan.labels[jmap.ID(cid)], mb.ID)
+			}
+		}
+		var failures []string
+		for cid, se := range args.NotCreated {
+			failures = append(failures, fmt.Sprintf("mailbox %s: %s", plan.labels[cid], setErrorText(se)))
+		}
+		slices.Sort(failures)
+		return failures
+	case *jmap.MethodError:
+		return []string{fmt.Sprintf("mailboxes: %v", args)}
+	default:
+		return []string{fmt.Sprintf("mailboxes: unexpected response type: %T", args)}
+	}
+}
+
+func applyConfigIdentities(ctx context.Context, client JMAPClient, accountID jmap.ID, plan *configPlan, sb *strings.Builder) []string {
+	set := &identity.Set{Account: accountID, Create: make(map[jmap.ID]*identity.Identity, len(plan.identities))}
+	for i, w := range plan.identities {
+		set.Create[jmap.ID(fmt.Sprintf("i%d", i))] = &identity.Identity{
+			Name:          w.Name,
+			Email:         w.Email,
+			ReplyTo:       w.ReplyTo,
+			Bcc:           w.Bcc,
+			TextSignature: w.TextSignature,
+			HTMLSignature: w.HTMLSignature,
+		}
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(set)
+	resp, err := client.Do(req)
+	if err != nil {
+		return []string{fmt.Sprintf("identities: %v", err)}
+	}
+	if len(resp.Responses) == 0 {
+		return []string{"identities: empty response for Identity/set"}
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *identity.SetResponse:
+		var failures []string
+		for i, w := range plan.identities {
+			cid := jmap.ID(fmt.Sprintf("i%d", i))
+			if id, ok := args.Created[cid]; ok {
+				fmt.Fprintf(sb, "Created identity %s [id: %s]\n", w.Email, id.ID)
+			} else if se, ok := args.NotCreated[cid]; ok {
+				failures = append(failures, fmt.Sprintf("identity %s: %s", w.Email, setErrorText(se)))
+			}
+		}
+		return failures
+	case *jmap.MethodError:
+		return []string{fmt.Sprintf("identities: %v", args)}
+	default:
+		return []string{fmt.Sprintf("identities: unexpected response type: %T", args)}
+	}
+}
+
+func applyConfigSieveScripts(ctx context.Context, client JMAPClient, accountID jmap.ID, plan *configPlan, sb *strings.Builder) []string {
+	set := &sievescript.Set{Account: accountID, Create: make(map[jmap.ID]*sievescript.SieveScript, len(plan.scripts))}
+	var failures []string
+	for i, w := range plan.scripts {
+		if err := checkSieveScriptSize(client.Session(), len(w.Content)); err != nil {
+			failures = append(failures, fmt.Sprintf("sieve script %s: %v", w.Name, err))
+			continue
+		}
+		uploaded, err := client.Upload(ctx, accountID, strings.NewReader(w.Content))
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("sieve script %s: upload: %v", w.Name, err))
+			continue
+		}
+		cid := jmap.ID(fmt.Sprintf("s%d", i))
+		set.Create[cid] = &sievescript.SieveScript{Name: &w.Name, BlobID: uploaded.ID}
+		if w.Name == plan.activate {
+			ref := "#" + cid
+			set.OnSuccessActivateScript = &ref
+		}
+	}
+	if len(set.Create) == 0 {
+		return failures
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(set)
+	resp, err := client.Do(req)
+	if err != nil {
+		return append(failures, fmt.Sprintf("sieve scripts: %v", err))
+	}
+	if len(resp.Responses) == 0 {
+		return append(failures, "sieve scripts: empty response for SieveScript/set")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *sievescript.SetResponse:
+		for i, w := range plan.scripts {
+			cid := jmap.ID(fmt.Sprintf("s%d", i))
+			if script, ok := args.Created[cid]; ok {
+				fmt.Fprintf(sb, "Created Sieve script %s [id: %s]\n", w.Name, script.ID)
+			} else if se, ok := args.NotCreated[cid]; ok {
+				failures = append(failures, fmt.Sprintf("sieve script %s: %s", w.Name, setErrorText(se)))
+			}
+		}
+		return failures
+	case *jmap.MethodError:
+		return append(failures, fmt.Sprintf("sieve scripts: %v", args))
+	default:
+		return append(failures, fmt.Sprintf("sieve scripts: unexpected response type: %T", args))
+	}
+}
+
+// fetchIdentities returns the account's sending identities.
+func fetchIdentities(ctx context.Context, client JMAPClient, accountID jmap.ID) ([]*identity.Identity, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&identity.Get{Account: accountID})
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Identity/get")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *identity.GetResponse:
+		return args.List, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// fetchSieveScripts returns the account's Sieve scripts, without content.
+func fetchSieveScripts(ctx context.Context, client JMAPClient, accountID jmap.ID) ([]*sievescript.SieveScript, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&sievescript.Get{Account: accountID})
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for SieveScript/get")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *sievescript.GetResponse:
+		return args.List, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// sieveScriptName returns the script's name, or its ID when it has none.
+func sieveScriptName(script *sievescript.SieveScript) string {
+	if script.Name != nil {
+		return *script.Name
+	}
+	return string(script.ID)
+}
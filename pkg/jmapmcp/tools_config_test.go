@@ -0,0 +1,104 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/sieve"
+)
+
+func TestConfigExportImport(t *testing.T) {
+	src, srcFake := newFakeServer(t, WithSieve())
+	srcFake.AddCapability(string(sieve.URI), nil)
+	srcFake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	srcFake.Add("Mailbox", map[string]any{"id": "clients", "name": "Clients"})
+	srcFake.Add("Mailbox", map[string]any{"id": "acme", "name": "Acme", "parentId": "clients"})
+	srcFake.Add("Identity", map[string]any{"id": "I1", "name": "Ann", "email": "ann@example.com", "textSignature": "-- Ann"})
+	srcFake.Add("Identity", map[string]any{"id": "I2", "name": "Support", "email": "support@example.com"})
+	srcFake.Add("SieveScript", map[string]any{"id": "S1", "name": "filters", "blobId": srcFake.AddBlob([]byte("keep;\n")), "isActive": true})
+	ctx := context.Background()
+
+	res, _, _ := src.handleConfigExport(ctx, nil, ConfigExportInput{})
+	doc := resultText(res)
+	if res.IsError {
+		t.Fatalf("config_export: %s", doc)
+	}
+	var parsed configDocument
+	if err := json.Unmarshal([]byte(doc), &parsed); err != nil {
+		t.Fatalf("export is not a document: %v\n%s", err, doc)
+	}
+	if len(parsed.Mailboxes) != 3 || parsed.Mailboxes[1].Path != "Clients/Acme" || len(parsed.Identities) != 2 || len(parsed.SieveScripts) != 1 || parsed.SieveScripts[0].Content != "keep;\n" {
+		t.Fatalf("exported document:\n%s", doc)
+	}
+
+	dst, dstFake := newFakeServer(t, WithSieve())
+	dstFake.AddCapability(string(sieve.URI), nil)
+	dstFake.Add("Mailbox", map[string]any{"id": "in", "name": "INBOX", "role": "inbox"})
+	dstFake.Add("Mailbox", map[string]any{"id": "cl", "name": "Clients"})
+	dstFake.Add("Identity", map[string]any{"id": "J1", "name": "Ann", "email": "Ann@Example.com"})
+
+	res, _, _ = dst.handleConfigImport(ctx, nil, ConfigImportInput{Document: doc})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("config_import preview: %s", out)
+	}
+	for _, want := range []string{
+		"Create mailbox Clients/Acme\n",
+		"Create identity Support <support@example.com>\n",
+		"Create Sieve script filters and activate it\n",
+		"Identity ann@example.com exists\n",
+		"Nothing changed yet",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("preview lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "mailbox Inbox") || len(dstFake.Objects("Mailbox")) != 2 {
+		t.Fatalf("preview changed or mapped mailboxes wrongly:\n%s", out)
+	}
+
+	res, _, _ = dst.handleConfigImport(ctx, nil, ConfigImportInput{Document: doc, Apply: true})
+	out = resultText(res)
+	if res.IsError {
+		t.Fatalf("config_import: %s", out)
+	}
+	for _, want := range []string{"Created mailbox Clients/Acme [id: ", "Created identity support@example.com [id: ", "Created Sieve script filters [id: "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("import lacks %q:\n%s", want, out)
+		}
+	}
+	var acme map[string]any
+	for _, id := range dstFake.Objects("Mailbox") {
+		if mb := dstFake.Object("Mailbox", id); mb["name"] == "Acme" {
+			acme = mb
+		}
+	}
+	if acme == nil || acme["parentId"] != "cl" {
+		t.Errorf("Acme = %v", acme)
+	}
+	if ids := dstFake.Objects("Identity"); len(ids) != 2 {
+		t.Errorf("identities = %v", ids)
+	}
+	scripts := dstFake.Objects("SieveScript")
+	if len(scripts) != 1 {
+		t.Fatalf("scripts = %v", scripts)
+	}
+	script := dstFake.Object("SieveScript", scripts[0])
+	blobID, _ := script["blobId"].(string)
+	if script["isActive"] != true || string(dstFake.Blob(blobID)) != "keep;\n" {
+		t.Errorf("script = %v", script)
+	}
+
+	// A second import has nothing left to do.
+	res, _, _ = dst.handleConfigImport(ctx, nil, ConfigImportInput{Document: doc, Apply: true})
+	if out := resultText(res); !strings.Contains(out, "Nothing to import") {
+		t.Errorf("repeated import:\n%s", out)
+	}
+
+	res, _, _ = dst.handleConfigImport(ctx, nil, ConfigImportInput{Document: `{"version": 9}`})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeInvalidArguments {
+		t.Errorf("unsupported version: %s", resultText(res))
+	}
+}
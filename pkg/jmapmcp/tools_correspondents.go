@@ -0,0 +1,266 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultCorrespondentDays  = 90
+	maxCorrespondentDays      = 730
+	defaultCorrespondentLimit = 20
+	correspondentMaxScan      = 5000
+	// correspondentHalfLife is the age at which a sent message counts half
+	// as much toward an address's score as one sent today.
+	correspondentHalfLife = 30 * 24 * time.Hour
+	// correspondentCacheTTL is how long a scan is reused; refresh forces a
+	// new one.
+	correspondentCacheTTL = time.Hour
+)
+
+// --- correspondents_top ---
+
+type CorrespondentsTopInput struct {
+	Days    int  `json:"days,omitempty" jsonschema:"How many days of Sent mail to scan (default 90, max 730)"`
+	Limit   int  `json:"limit,omitempty" jsonschema:"Number of addresses to return (default 20)"`
+	Refresh bool `json:"refresh,omitempty" jsonschema:"Rescan Sent mail instead of using a scan cached within the last hour"`
+}
+
+var correspondentsTopTool = &mcp.Tool{
+	Name:        "correspondents_top",
+	Description: "Rank the addresses the user writes to most, from the To, Cc, and Bcc of Sent mail over the last days, by frequency weighted toward recent messages. Shows each address's name, message count, and last message date. Use to resolve \"my usual contacts\" or a first name to an address when the server has no contacts.",
+	Annotations: readOnlyAnnotations,
+}
+
+// correspondent is one address's tally over a scan.
+type correspondent struct {
+	address string
+	name    string // the most recent non-empty display name
+	count   int
+	last    time.Time
+	score   float64
+}
+
+// correspondentScan is a ranked scan of Sent mail.
+type correspondentScan struct {
+	at      time.Time
+	ranked  []*correspondent
+	scanned int
+	more    bool // the scan stopped at correspondentMaxScan
+}
+
+// size estimates the bytes scan holds in the tenant cache.
+func (scan *correspondentScan) size() int64 {
+	n := int64(64)
+	for _, c := range scan.ranked {
+		n += int64(len(c.address)+len(c.name)) + 64
+	}
+	return n
+}
+
+func (s *Server) handleCorrespondentsTop(ctx context.Context, _ *mcp.CallToolRequest, in CorrespondentsTopInput) (*mcp.CallToolResult, any, error) {
+	days := in.Days
+	if days <= 0 {
+		days = defaultCorrespondentDays
+	}
+	if days > maxCorrespondentDays {
+		return errorResult(invalidArgument("days must be at most %d", maxCorrespondentDays)), nil, nil
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultCorrespondentLimit
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	now := time.Now()
+	owner := s.idOwner(ctx)
+	key := fmt.Sprintf("scan\x00%s\x00%s\x00%d", client.Session().APIURL, accountID, days)
+	v, cached := s.tenants.get(owner, key)
+	scan, _ := v.(*correspondentScan)
+	if !cached || in.Refresh || now.Sub(scan.at) > correspondentCacheTTL {
+		sentID, err := s.findMailboxByRole(ctx, client, accountID, mailbox.RoleSent)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		pageSize := uint64(keywordPageSize)
+		if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxObjectsInGet > 0 {
+			pageSize = min(pageSize, c.MaxObjectsInGet)
+		}
+		scan, err = scanCorrespondents(ctx, client, accountID, sentID, now.AddDate(0, 0, -days), now, pageSize)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		s.tenants.put(owner, key, scan, scan.size())
+	}
+
+	return textResult(s.formatCorrespondents(scan, days, limit, now)), nil, nil
+}
+
+// scanCorrespondents pages the Sent mailbox newest first from now back to
+// after and ranks the recipients.
+func scanCorrespondents(ctx context.Context, client JMAPClient, accountID, sentID jmap.ID, after, now time.Time, pageSize uint64) (*correspondentScan, error) {
+	var sent []*email.Email
+	more := true
+	for len(sent) < correspondentMaxScan {
+		limit := min(pageSize, uint64(correspondentMaxScan-len(sent)))
+
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(&email.Query{
+			Account:  accountID,
+			Filter:   &email.FilterCondition{InMailbox: sentID, After: &after},
+			Sort:     []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Position: int64(len(sent)),
+			Limit:    limit,
+		})
+		req.Invoke(&email.Get{
+			Account: accountID,
+			ReferenceIDs: &jmap.ResultReference{
+				ResultOf: queryCallID,
+				Name:     "Email/query",
+				Path:     "/ids",
+			},
+			Properties: []string{"id", "from", "to", "cc", "bcc", "receivedAt", "sentAt"},
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < 2 {
+			return nil, fmt.Errorf("missing Email/get response in query chain")
+		}
+		if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+			return nil, me
+		}
+
+		var page []*email.Email
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			page = args.List
+		case *jmap.MethodError:
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+
+		sent = append(sent, page...)
+		if uint64(len(page)) < limit {
+			more = false
+			break
+		}
+	}
+
+	scan := &correspondentScan{at: now, ranked: rankCorrespondents(sent, now), scanned: len(sent), more: more}
+	return scan, nil
+}
+
+// rankCorrespondents tallies the recipients of sent, leaving out the
+// user's own (From) addresses, and orders them by score: each message
+// adds 1 halved for every correspondentHalfLife of its age. Ties go to
+// the more recent, then alphabetically.
+func rankCorrespondents(sent []*email.Email, now time.Time) []*correspondent {
+	own := make(map[string]bool)
+	for _, e := range sent {
+		for _, a := range e.From {
+			own[strings.ToLower(a.Email)] = true
+		}
+	}
+
+	byAddress := make(map[string]*correspondent)
+	for _, e := range sent {
+		at := receivedAt(e)
+		if e.SentAt != nil {
+			at = *e.SentAt
+		}
+		weight := math.Exp2(-float64(now.Sub(at)) / float64(correspondentHalfLife))
+		seen := make(map[string]bool)
+		for _, list := range [][]*mail.Address{e.To, e.CC, e.BCC} {
+			for _, a := range list {
+				addr := strings.ToLower(strings.TrimSpace(a.Email))
+				if addr == "" || own[addr] || seen[addr] {
+					continue
+				}
+				seen[addr] = true
+				c := byAddress[addr]
+				if c == nil {
+					c = &correspondent{address: addr}
+					byAddress[addr] = c
+				}
+				c.count++
+				c.score += weight
+				if at.After(c.last) {
+					c.last = at
+					if a.Name != "" {
+						c.name = a.Name
+					}
+				} else if c.name == "" {
+					c.name = a.Name
+				}
+			}
+		}
+	}
+
+	ranked := make([]*correspondent, 0, len(byAddress))
+	for _, c := range byAddress {
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.last.Equal(b.last) {
+			return a.last.After(b.last)
+		}
+		return a.address < b.address
+	})
+	return ranked
+}
+
+func (s *Server) formatCorrespondents(scan *correspondentScan, days, limit int, now time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Top correspondents over the last %d days: %d address(es) from %d sent message(s)", days, len(scan.ranked), scan.scanned)
+	if scan.more {
+		fmt.Fprintf(&sb, " (scan stopped at the %d most recent)", correspondentMaxScan)
+	}
+	if age := now.Sub(scan.at); age >= time.Minute {
+		fmt.Fprintf(&sb, ", cached %s ago", age.Round(time.Minute))
+	}
+	sb.WriteString("\n")
+	if len(scan.ranked) == 0 {
+		sb.WriteString("\nNo sent mail in this period.\n")
+		return sb.String()
+	}
+	sb.WriteString("\n")
+	for i, c := range scan.ranked {
+		if i >= limit {
+			break
+		}
+		addr := c.address
+		if c.name != "" {
+			addr = fmt.Sprintf("%s <%s>", c.name, c.address)
+		}
+		fmt.Fprintf(&sb, "%d. %s: %d message(s), last %s\n", i+1, addr, c.count, s.locale.dateTime(c.last))
+	}
+	return sb.String()
+}
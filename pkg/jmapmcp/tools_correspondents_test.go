@@ -0,0 +1,67 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestRankCorrespondents(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	me := []*mail.Address{{Email: "me@example.org"}}
+	sentAt := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+	sent := []*email.Email{
+		{From: me, To: []*mail.Address{{Name: "Bob", Email: "Bob@example.org"}}, SentAt: sentAt(1)},
+		{From: me, To: []*mail.Address{{Email: "bob@example.org"}}, CC: []*mail.Address{{Email: "me@example.org"}}, SentAt: sentAt(2)},
+		{From: me, To: []*mail.Address{{Name: "Carol", Email: "carol@example.org"}}, SentAt: sentAt(200)},
+		{From: me, To: []*mail.Address{{Email: "carol@example.org"}}, SentAt: sentAt(201)},
+		{From: me, To: []*mail.Address{{Email: "carol@example.org"}}, SentAt: sentAt(202)},
+	}
+
+	ranked := rankCorrespondents(sent, now)
+	if len(ranked) != 2 {
+		t.Fatalf("ranked %d addresses, want 2 (own address excluded)", len(ranked))
+	}
+	if ranked[0].address != "bob@example.org" || ranked[0].count != 2 || ranked[0].name != "Bob" {
+		t.Errorf("first = %+v, want recent bob with 2 messages", ranked[0])
+	}
+	if ranked[1].address != "carol@example.org" || ranked[1].count != 3 || !ranked[1].last.Equal(*sentAt(200)) {
+		t.Errorf("second = %+v, want older carol with 3 messages", ranked[1])
+	}
+}
+
+func TestCorrespondentsTopCached(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	fake.Add("Email", map[string]any{
+		"id":         "s1",
+		"mailboxIds": map[string]any{"sent": true},
+		"from":       []any{map[string]any{"email": "me@example.org"}},
+		"to":         []any{map[string]any{"name": "Dana", "email": "dana@example.org"}},
+		"receivedAt": recent,
+	})
+	ctx := context.Background()
+
+	res, _, _ := s.handleCorrespondentsTop(ctx, nil, CorrespondentsTopInput{})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "1. Dana <dana@example.org>: 1 message(s)") {
+		t.Fatalf("correspondents_top:\n%s", out)
+	}
+
+	calls := len(fake.Calls())
+	s.handleCorrespondentsTop(ctx, nil, CorrespondentsTopInput{})
+	if n := len(fake.Calls()); n != calls {
+		t.Errorf("second call made %d JMAP calls, want the cached scan", n-calls)
+	}
+	s.handleCorrespondentsTop(ctx, nil, CorrespondentsTopInput{Refresh: true})
+	if n := len(fake.Calls()); n == calls {
+		t.Error("refresh reused the cached scan")
+	}
+}
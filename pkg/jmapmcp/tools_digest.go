@@ -0,0 +1,477 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	digestPageSize        = 500
+	defaultDigestMaxScan  = 500
+	digestMaxScanCeiling  = 2000
+	defaultDigestMaxChars = 4000
+	digestSubjectsPerLine = 3
+	digestTopSubjects     = 10
+
+	digestSummaryEmails = 60 // newest emails the summary is written from
+	digestSummaryWords  = 150
+)
+
+// digestProperties are the Email properties a digest needs.
+var digestProperties = []string{"id", "mailboxIds", "from", "subject", "receivedAt", "keywords"}
+
+// --- email_digest ---
+
+type EmailDigestInput struct {
+	Since      string `json:"since,omitempty" jsonschema:"Summarize mail received after this time (RFC 3339 or YYYY-MM-DD)"`
+	SinceState string `json:"since_state,omitempty" jsonschema:"Summarize mail that arrived since this Email state, as returned by a previous email_digest (takes precedence over since)"`
+	MailboxID  string `json:"mailbox_id,omitempty" jsonschema:"Only summarize mail in this mailbox (default: all mailboxes)"`
+	MaxEmails  int    `json:"max_emails,omitempty" jsonschema:"Maximum number of new emails to gather (default 500, max 2000)"`
+	MaxChars   int    `json:"max_chars,omitempty" jsonschema:"Maximum briefing size in characters (default 4000); lower-priority sections are cut first"`
+	Summarize  bool   `json:"summarize,omitempty" jsonschema:"Open the briefing with a summary of the new mail, written by your own model through sampling from the messages' previews (needs a client that offers sampling)"`
+}
+
+var emailDigestTool = &mcp.Tool{
+	Name:        "email_digest",
+	Description: "Briefing of new mail since a time or a previous digest's state: counts, flagged and important items, per-mailbox and per-sender breakdowns with sample subjects, and the most common subjects, sized to max_chars. Returns the current state to pass as since_state next time. With summarize, the client's model writes a short summary of the new mail through sampling, so the previews never enter the conversation. Use instead of assembling an overview from many email_query/email_get calls.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailDigest(ctx context.Context, req *mcp.CallToolRequest, in EmailDigestInput) (*mcp.CallToolResult, any, error) {
+	if in.Since == "" && in.SinceState == "" {
+		return errorResult(invalidArgument("since or since_state is required")), nil, nil
+	}
+	if in.Summarize && !canSample(req) {
+		return errorResult(invalidArgument("summarize needs an MCP client that offers sampling")), nil, nil
+	}
+	var filter *email.FilterCondition
+	if in.SinceState == "" {
+		after, err := parseDate(in.Since, "T00:00:00Z")
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		filter = &email.FilterCondition{InMailbox: jmap.ID(in.MailboxID), After: after}
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	maxScan := in.MaxEmails
+	if maxScan <= 0 {
+		maxScan = defaultDigestMaxScan
+	}
+	maxScan = min(maxScan, digestMaxScanCeiling)
+
+	pageSize := uint64(digestPageSize)
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxObjectsInGet > 0 {
+		pageSize = min(pageSize, c.MaxObjectsInGet)
+	}
+
+	names, err := mailboxNames(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	properties := digestProperties
+	if in.Summarize {
+		properties = append(slices.Clip(properties), "preview")
+	}
+	var g *digestGather
+	if in.SinceState != "" {
+		g, err = gatherCreatedSince(ctx, client, accountID, in.SinceState, maxScan, pageSize, properties)
+	} else {
+		g, err = gatherReceivedAfter(ctx, client, accountID, filter, maxScan, pageSize, properties)
+	}
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if in.SinceState != "" && in.MailboxID != "" {
+		g.emails = slices.DeleteFunc(g.emails, func(e *email.Email) bool {
+			return !e.MailboxIDs[jmap.ID(in.MailboxID)]
+		})
+	}
+
+	since := in.Since
+	if in.SinceState != "" {
+		since = "state " + in.SinceState
+	}
+	maxChars := in.MaxChars
+	if maxChars <= 0 {
+		maxChars = defaultDigestMaxChars
+	}
+	var summary string
+	if in.Summarize && len(g.emails) > 0 {
+		if summary, err = s.digestSummary(ctx, req, g.emails); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+	return textResult(formatDigest(s.locale, g, since, names, s.vipsOf(client), summary, maxChars)), nil, nil
+}
+
+// digestSummary has the client's model summarize the newest of emails from
+// their senders, subjects, flags, and previews.
+func (s *Server) digestSummary(ctx context.Context, req *mcp.CallToolRequest, emails []*email.Email) (string, error) {
+	newest := slices.Clone(emails)
+	slices.SortStableFunc(newest, func(a, b *email.Email) int { return receivedAt(b).Compare(receivedAt(a)) })
+	var sb strings.Builder
+	for _, e := range newest[:min(len(newest), digestSummaryEmails)] {
+		preview, _ := s.redactor.redact(strings.Join(strings.Fields(e.Preview), " "))
+		fmt.Fprintf(&sb, "- From %s, subject %q", formatAddresses(e.From), e.Subject)
+		if e.Keywords["$flagged"] || e.Keywords[importantKeyword] {
+			sb.WriteString(" (flagged)")
+		}
+		fmt.Fprintf(&sb, ": %s\n", preview)
+	}
+	what := fmt.Sprintf("this new mail (%d email(s), the newest %d listed)", len(emails), min(len(emails), digestSummaryEmails))
+	return summarizeText(ctx, req, what, sb.String(), digestSummaryWords)
+}
+
+// digestGather is the mail collected for a digest.
+type digestGather struct {
+	emails []*email.Email
+	state  string // Email state to pass as since_state next time
+	more   bool   // the scan stopped at max_emails
+}
+
+// gatherCreatedSince collects emails created since state via Email/changes,
+// with properties.
+// maxChanges keeps each batch within the scan limit, so the returned state
+// never skips unseen emails.
+func gatherCreatedSince(ctx context.Context, client JMAPClient, accountID jmap.ID, state string, maxScan int, pageSize uint64, properties []string) (*digestGather, error) {
+	g := &digestGather{state: state}
+	var created []jmap.ID
+	for {
+		req := &jmap.Request{Context: ctx}
+		req.Invoke(&email.Changes{
+			Account:    accountID,
+			SinceState: g.state,
+			MaxChanges: uint64(maxScan - len(created)),
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) == 0 {
+			return nil, fmt.Errorf("empty response for Email/changes")
+		}
+
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.ChangesResponse:
+			created = append(created, args.Created...)
+			g.state = args.NewState
+			g.more = args.HasMoreChanges
+		case *jmap.MethodError:
+			if args.Type == "cannotCalculateChanges" {
+				return nil, fmt.Errorf("the server can no longer report changes since state %s; pass since instead", state)
+			}
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+		if !g.more || len(created) >= maxScan {
+			break
+		}
+	}
+
+	for start := 0; start < len(created); start += int(pageSize) {
+		batch := created[start:min(start+int(pageSize), len(created))]
+		req := &jmap.Request{Context: ctx}
+		req.Invoke(&email.Get{Account: accountID, IDs: batch, Properties: properties})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) == 0 {
+			return nil, fmt.Errorf("empty response for Email/get")
+		}
+
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.GetResponse:
+			// Emails destroyed since they were created are simply not found.
+			g.emails = append(g.emails, args.List...)
+		case *jmap.MethodError:
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+	return g, nil
+}
+
+// gatherReceivedAfter pages Email/query newest first, getting properties,
+// and returns the Email state seen on the first page.
+func gatherReceivedAfter(ctx context.Context, client JMAPClient, accountID jmap.ID, filter *email.FilterCondition, maxScan int, pageSize uint64, properties []string) (*digestGather, error) {
+	g := &digestGather{}
+	for len(g.emails) < maxScan {
+		limit := min(pageSize, uint64(maxScan-len(g.emails)))
+
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(&email.Query{
+			Account:  accountID,
+			Filter:   filter,
+			Sort:     []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Position: int64(len(g.emails)),
+			Limit:    limit,
+		})
+		req.Invoke(&email.Get{
+			Account: accountID,
+			ReferenceIDs: &jmap.ResultReference{
+				ResultOf: queryCallID,
+				Name:     "Email/query",
+				Path:     "/ids",
+			},
+			Properties: properties,
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < 2 {
+			return nil, fmt.Errorf("missing Email/get response in query chain")
+		}
+		if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+			return nil, me
+		}
+
+		var page []*email.Email
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			page = args.List
+			if g.state == "" {
+				g.state = args.State
+			}
+		case *jmap.MethodError:
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+
+		g.emails = append(g.emails, page...)
+		if uint64(len(page)) < limit {
+			return g, nil
+		}
+	}
+	g.more = true
+	return g, nil
+}
+
+// mailboxNames maps mailbox IDs to display names.
+func mailboxNames(ctx context.Context, client JMAPClient, accountID jmap.ID) (map[jmap.ID]string, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "name"}})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Mailbox/get")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		names := make(map[jmap.ID]string, len(args.List))
+		for _, mb := range args.List {
+			names[mb.ID] = mb.Name
+		}
+		return names, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// digestCount is one row of a breakdown: a name, its email count, and the
+// unread count or sample subjects.
+type digestCount struct {
+	name     string
+	count    int
+	unread   int
+	subjects []string
+}
+
+// rankCounts sorts rows by count, then name.
+func rankCounts(m map[string]*digestCount) []*digestCount {
+	rows := make([]*digestCount, 0, len(m))
+	for _, key := range sortedKeys(m) {
+		rows = append(rows, m[key])
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].count > rows[j].count })
+	return rows
+}
+
+// formatDigest renders the briefing in priority order (totals, the
+// summary when there is one, mail from VIPs, flagged and important items,
+// mailboxes, senders, subjects), stopping at maxChars.
+func formatDigest(l textStyle, g *digestGather, since string, names map[jmap.ID]string, vips []string, summary string, maxChars int) string {
+	var unread int
+	var fromVIPs, flagged []*email.Email
+	mailboxes := make(map[string]*digestCount)
+	senders := make(map[string]*digestCount)
+	subjects := make(map[string]*digestCount)
+	for _, e := range g.emails {
+		seen := e.Keywords["$seen"]
+		if !seen {
+			unread++
+		}
+		switch {
+		case senderListed(e.From, vips):
+			fromVIPs = append(fromVIPs, e)
+		case e.Keywords["$flagged"] || e.Keywords[importantKeyword]:
+			flagged = append(flagged, e)
+		}
+		for id := range e.MailboxIDs {
+			name := names[id]
+			if name == "" {
+				name = string(id)
+			}
+			c := mailboxes[name]
+			if c == nil {
+				c = &digestCount{name: name}
+				mailboxes[name] = c
+			}
+			c.count++
+			if !seen {
+				c.unread++
+			}
+		}
+		sender := "(unknown sender)"
+		if len(e.From) > 0 {
+			sender = formatAddresses(e.From[:1])
+		}
+		c := senders[strings.ToLower(sender)]
+		if c == nil {
+			c = &digestCount{name: sender}
+			senders[strings.ToLower(sender)] = c
+		}
+		c.count++
+		if len(c.subjects) < digestSubjectsPerLine && e.Subject != "" && !slices.Contains(c.subjects, e.Subject) {
+			c.subjects = append(c.subjects, e.Subject)
+		}
+		if base := baseSubject(e.Subject); base != "" {
+			c := subjects[strings.ToLower(base)]
+			if c == nil {
+				c = &digestCount{name: base}
+				subjects[strings.ToLower(base)] = c
+			}
+			c.count++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "New mail since %s: %d email(s), %d unread, %d flagged or important", since, len(g.emails), unread, len(flagged))
+	if len(vips) > 0 {
+		fmt.Fprintf(&sb, ", %d from VIPs", len(fromVIPs))
+	}
+	sb.WriteString("\n")
+	if g.more {
+		sb.WriteString("Note: stopped at max_emails; more new mail exists\n")
+	}
+	fmt.Fprintf(&sb, "State: %s (pass as since_state for the next digest)\n", g.state)
+	if len(g.emails) == 0 {
+		return sb.String()
+	}
+
+	var lines []string
+	if summary != "" {
+		lines = append(lines, "", "Summary:")
+		for _, line := range strings.Split(summary, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, "  "+line)
+			}
+		}
+	}
+	if len(fromVIPs) > 0 {
+		lines = append(lines, "", "From VIPs:")
+		for _, e := range fromVIPs {
+			line := fmt.Sprintf("  %s  %s  %s  %s", e.ID, l.dateTime(receivedAt(e)), formatAddresses(e.From), e.Subject)
+			if !e.Keywords["$seen"] {
+				line += " (unread)"
+			}
+			lines = append(lines, line)
+		}
+	}
+	if len(flagged) > 0 {
+		lines = append(lines, "", "Flagged / important:")
+		for _, e := range flagged {
+			lines = append(lines, fmt.Sprintf("  %s  %s  %s  %s", e.ID, l.dateTime(receivedAt(e)), formatAddresses(e.From), e.Subject))
+		}
+	}
+	lines = append(lines, "", "By mailbox:")
+	for _, c := range rankCounts(mailboxes) {
+		lines = append(lines, fmt.Sprintf("  %s: %d (%d unread)", c.name, c.count, c.unread))
+	}
+	lines = append(lines, "", "By sender:")
+	for _, c := range rankCounts(senders) {
+		line := fmt.Sprintf("  %s: %d", c.name, c.count)
+		if len(c.subjects) > 0 {
+			line += " — " + strings.Join(c.subjects, "; ")
+			if extra := c.count - len(c.subjects); extra > 0 {
+				line += fmt.Sprintf(" (+%d more)", extra)
+			}
+		}
+		lines = append(lines, line)
+	}
+	var top []string
+	for _, c := range rankCounts(subjects) {
+		if c.count < 2 || len(top) == digestTopSubjects {
+			break
+		}
+		top = append(top, fmt.Sprintf("  %s (%d)", c.name, c.count))
+	}
+	if len(top) > 0 {
+		lines = append(lines, "", "Top subjects:")
+		lines = append(lines, top...)
+	}
+
+	for i, line := range lines {
+		if sb.Len()+len(line)+1 > maxChars {
+			fmt.Fprintf(&sb, "\n[Digest cut at max_chars: %d more line(s) omitted]\n", len(lines)-i)
+			break
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// baseSubject strips reply and forward prefixes so a conversation's
+// messages share one subject.
+func baseSubject(subject string) string {
+	s := strings.TrimSpace(subject)
+	for {
+		lower := strings.ToLower(s)
+		trimmed := false
+		for _, prefix := range []string{"re:", "fwd:", "fw:", "aw:", "wg:"} {
+			if strings.HasPrefix(lower, prefix) {
+				s = strings.TrimSpace(s[len(prefix):])
+				trimmed = true
+				break
+			}
+		}
+		if !trimmed {
+			return s
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package jmapmcp
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestEmailDigest(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	addEmail(fake, "e0", "Old news", "", 1)
+	addEmail(fake, "e1", "Offsite", "", 5)
+	addEmail(fake, "e2", "Re: Offsite", "", 6)
+	addEmail(fake, "e3", "Invoice", "", 7)
+	e := fake.Object("Email", "e3")
+	e["keywords"] = map[string]any{"$flagged": true, "$seen": true}
+	fake.Add("Email", e)
+
+	res, _, _ := s.handleEmailDigest(context.Background(), nil, EmailDigestInput{Since: "2025-01-05"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	for _, want := range []string{
+		"New mail since 2025-01-05: 3 email(s), 2 unread, 1 flagged or important",
+		"Flagged / important:\n  e3  2025-01-07 10:00",
+		"  Inbox: 3 (2 unread)",
+		"  Alice <alice@example.org>: 3 — Invoice; Re: Offsite; Offsite",
+		"Top subjects:\n  Offsite (2)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Old news") {
+		t.Errorf("mail before since included:\n%s", out)
+	}
+
+	// The returned state picks up only mail that arrived afterwards.
+	m := regexp.MustCompile(`State: (\S+)`).FindStringSubmatch(out)
+	if m == nil {
+		t.Fatalf("no state in digest:\n%s", out)
+	}
+	addEmail(fake, "e4", "Lunch?", "", 8)
+	res, _, _ = s.handleEmailDigest(context.Background(), nil, EmailDigestInput{SinceState: m[1]})
+	out = resultText(res)
+	if !strings.Contains(out, ": 1 email(s)") || !strings.Contains(out, "Lunch?") {
+		t.Errorf("since_state digest:\n%s", out)
+	}
+}
+
+func TestFormatDigestBudget(t *testing.T) {
+	s, fake := newFakeServer(t)
+	for i := range 20 {
+		addEmail(fake, "e"+string(rune('a'+i)), strings.Repeat("subject ", 5)+string(rune('a'+i)), "", 2)
+		e := fake.Object("Email", "e"+string(rune('a'+i)))
+		e["from"] = []any{map[string]any{"email": string(rune('a'+i)) + "@example.org"}}
+		fake.Add("Email", e)
+	}
+	res, _, _ := s.handleEmailDigest(context.Background(), nil, EmailDigestInput{Since: "2025-01-01", MaxChars: 500})
+	out := resultText(res)
+	if len(out) > 600 || !strings.Contains(out, "[Digest cut at max_chars") {
+		t.Errorf("digest not cut to budget (%d chars):\n%s", len(out), out)
+	}
+}
+
+func TestEmailDigestSummarize(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Email", map[string]any{"id": "e1", "subject": "Offsite", "receivedAt": "2025-01-05T10:00:00Z",
+		"from": []any{map[string]any{"email": "alice@example.org"}}, "preview": "The offsite moves to  March 3.",
+		"mailboxIds": map[string]any{"inbox": true}, "keywords": map[string]any{}})
+
+	if res, _, _ := s.handleEmailDigest(context.Background(), nil, EmailDigestInput{Since: "2025-01-01", Summarize: true}); !res.IsError {
+		t.Errorf("summarized without sampling: %s", resultText(res))
+	}
+
+	var prompt string
+	cs := samplingSession(t, s, func(p string) string {
+		prompt = p
+		return "Alice moved the offsite.\n\nNothing else."
+	})
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: "email_digest", Arguments: map[string]any{"since": "2025-01-01", "summarize": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := resultText(res); res.IsError || !strings.Contains(out, "\nSummary:\n  Alice moved the offsite.\n  Nothing else.\n\nBy mailbox:") {
+		t.Errorf("email_digest = %s", out)
+	}
+	if !strings.Contains(prompt, `From alice@example.org, subject "Offsite": The offsite moves to March 3.`) {
+		t.Errorf("prompt = %s", prompt)
+	}
+}
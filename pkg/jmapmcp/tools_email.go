@@ -0,0 +1,1312 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_query ---
+
+type EmailQueryInput struct {
+	MailboxID     string   `json:"mailbox_id,omitempty" jsonschema:"ID of the mailbox to search in"`
+	Query         string   `json:"query,omitempty" jsonschema:"Full-text search query"`
+	From          string   `json:"from,omitempty" jsonschema:"Filter by sender address"`
+	To            string   `json:"to,omitempty" jsonschema:"Filter by recipient address"`
+	Subject       string   `json:"subject,omitempty" jsonschema:"Filter by subject text"`
+	Before        string   `json:"before,omitempty" jsonschema:"Emails before this date (RFC 3339 or YYYY-MM-DD)"`
+	After         string   `json:"after,omitempty" jsonschema:"Emails after this date (RFC 3339 or YYYY-MM-DD)"`
+	HasAttachment *bool    `json:"has_attachment,omitempty" jsonschema:"Filter by attachment presence"`
+	Header        string   `json:"header,omitempty" jsonschema:"Only emails with this header, matched by the server: a name (e.g. Auto-Submitted) or name: value (e.g. List-Id: <dev.lists.example.org>, substring match)"`
+	Limit         int      `json:"limit,omitempty" jsonschema:"Maximum number of results (default 20 unless the server is configured otherwise)"`
+	Fields        []string `json:"fields,omitempty" jsonschema:"Fields to include per result. Available: subject, from, receivedAt, size (all included by default, except in mailboxes with their own view such as Sent and Drafts), to, sentAt, and importance (high/low from the $important keyword and X-Priority/Importance headers, for ranking). ID is always included."`
+	Headers       []string `json:"headers,omitempty" jsonschema:"Header names to include in results (e.g. List-Id, Message-ID)"`
+	VIPOnly       bool     `json:"vip_only,omitempty" jsonschema:"Only emails from senders on the VIP list (see vip_add)"`
+	IncludeMuted  bool     `json:"include_muted,omitempty" jsonschema:"Include emails from muted senders (see sender_mute), which are hidden by default"`
+	Junk          *bool    `json:"junk,omitempty" jsonschema:"true: only emails with the $junk keyword (marked as spam); false: only emails without it"`
+	Facets        []string `json:"facets,omitempty" jsonschema:"Overview counts of the whole matching set: mailbox (matches per mailbox), sender_domain (sender domains among the newest 500 matches), week (matches per week for the last 8 weeks)"`
+
+	sort []*email.SortComparator // set by query presets; nil sorts newest first
+}
+
+var emailQueryTool = &mcp.Tool{
+	Name:        "email_query",
+	Description: "Search emails with filters. Returns ID plus selected fields per match (default: subject, from, receivedAt, size). Use the fields parameter to request only specific fields. Optionally include specific headers (e.g. List-Id, Message-ID) via the headers parameter. Set junk to list only emails marked as spam ($junk) or only those not marked. Use email_get to retrieve full content. Sorted by date descending. Sent, Drafts, and other mailboxes the operator configured by role have their own default fields and sort, which the result notes. When far more emails match than are returned, the result suggests date ranges and frequent senders to narrow the query by. Set facets to add counts per mailbox, sender domain, or week for the whole matching set, an overview before fetching messages. Narrowing and facets are also returned as structured content.",
+	Annotations: readOnlyAnnotations,
+}
+
+// filter builds the Email/query filter from the search fields.
+func (in EmailQueryInput) filter() (*email.FilterCondition, error) {
+	filter := &email.FilterCondition{
+		InMailbox: jmap.ID(in.MailboxID),
+		Text:      in.Query,
+		From:      in.From,
+		To:        in.To,
+		Subject:   in.Subject,
+	}
+	if in.HasAttachment != nil && *in.HasAttachment {
+		filter.HasAttachment = true
+	}
+	if in.Junk != nil {
+		if *in.Junk {
+			filter.HasKeyword = "$junk"
+		} else {
+			filter.NotKeyword = "$junk"
+		}
+	}
+	if h := strings.TrimSpace(in.Header); h != "" {
+		name, value, hasValue := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return nil, invalidArgument("header %q: want a header name or name: value", in.Header)
+		}
+		filter.Header = []string{name}
+		if value = strings.TrimSpace(value); hasValue && value != "" {
+			filter.Header = append(filter.Header, value)
+		}
+	}
+	if in.Before != "" {
+		t, err := parseDate(in.Before, "T23:59:59Z")
+		if err != nil {
+			return nil, err
+		}
+		filter.Before = t
+	}
+	if in.After != "" {
+		t, err := parseDate(in.After, "T00:00:00Z")
+		if err != nil {
+			return nil, err
+		}
+		filter.After = t
+	}
+	return filter, nil
+}
+
+// errNoHeaderSearch refuses a header filter the backend cannot evaluate.
+var errNoHeaderSearch = &toolError{Code: codeCapabilityMissing, Message: "this server does not support searching by header; filter on other fields and read headers with the headers parameter"}
+
+func (s *Server) handleEmailQuery(ctx context.Context, _ *mcp.CallToolRequest, in EmailQueryInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	filter, err := in.filter()
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if err := checkFacets(in.Facets); err != nil {
+		return errorResult(err), nil, nil
+	}
+	viewNote := s.applyMailboxView(ctx, client, accountID, &in)
+	var vipOnly email.Filter
+	if in.VIPOnly {
+		vips := s.vipsOf(client)
+		if len(vips) == 0 {
+			return errorResult(invalidArgument("vip_only: the VIP list is empty; add senders with vip_add")), nil, nil
+		}
+		vipOnly = fromAnyFilter(vips)
+	}
+	// An address with an internationalized domain matches in either form.
+	var idnFrom, idnTo email.Filter
+	if forms := addressForms(filter.From); len(forms) > 1 {
+		idnFrom, filter.From = addressFilter(forms, func(a string) *email.FilterCondition { return &email.FilterCondition{From: a} }), ""
+	}
+	if forms := addressForms(filter.To); len(forms) > 1 {
+		idnTo, filter.To = addressFilter(forms, func(a string) *email.FilterCondition { return &email.FilterCondition{To: a} }), ""
+	}
+	// Muted senders are hidden unless asked for, or searched for by name.
+	var notMuted email.Filter
+	var notes []string
+	if muted := s.mutedOf(client); len(muted) > 0 && !in.IncludeMuted &&
+		!matchesAddressPattern(strings.ToLower(strings.TrimSpace(in.From)), muted) {
+		notMuted = &email.FilterOperator{Operator: jmap.OperatorNOT, Conditions: []email.Filter{fromAnyFilter(muted)}}
+		notes = append(notes, fmt.Sprintf("mail from %d muted sender(s) is hidden; pass include_muted=true to include it", len(muted)))
+	}
+	if viewNote != "" {
+		notes = append(notes, viewNote)
+	}
+
+	limit := uint64(in.Limit)
+	if limit == 0 {
+		limit = uint64(s.queryLimit)
+	}
+	sort := in.sort
+	if sort == nil {
+		sort = []*email.SortComparator{{Property: "receivedAt", IsAscending: false}}
+	}
+
+	// Chain Email/get via back-reference to fetch summary fields in one round-trip.
+	fields := in.Fields
+	if len(fields) == 0 {
+		fields = []string{"subject", "from", "receivedAt", "size"}
+	}
+	fieldSet := make(map[string]bool, len(fields))
+	properties := []string{"id"}
+	for _, f := range fields {
+		fieldSet[f] = true
+		if f == "importance" {
+			// Derived from the $important keyword and priority headers.
+			properties = append(properties, "keywords", "headers")
+			continue
+		}
+		properties = append(properties, f)
+	}
+	if len(in.Headers) > 0 {
+		// blobId lets headers be read from the raw message on backends
+		// that do not return the headers property.
+		properties = append(properties, "headers", "blobId")
+	}
+
+	// Backends without full-text search or calculateTotal refuse the query;
+	// retry once without the feature and remember the quirk if that works.
+	// A refused header filter has no fallback.
+	q := s.quirksFor(client)
+	if len(filter.Header) > 0 && q.has(quirkNoHeaderFilter) {
+		return errorResult(errNoHeaderSearch), nil, nil
+	}
+	var resp *jmap.Response
+	var retryText, retryTotal bool
+	var total uint64
+	var list []*email.Email
+	var key string
+	var cached bool
+	var matched email.Filter
+	for {
+		textFallback := retryText || q.has(quirkNoTextSearch)
+		withTotal := !retryTotal && !q.has(quirkNoCalculateTotal)
+		f := *filter
+		if textFallback && f.Text != "" {
+			if f.Subject == "" {
+				f.Subject = f.Text
+			}
+			f.Text = ""
+		}
+
+		var query email.Filter = &f
+		if conds := slices.DeleteFunc([]email.Filter{&f, idnFrom, idnTo, vipOnly, notMuted}, func(c email.Filter) bool { return c == nil }); len(conds) > 1 {
+			query = &email.FilterOperator{Operator: jmap.OperatorAND, Conditions: conds}
+		}
+		matched = query
+		emailQuery := &email.Query{
+			Account:        accountID,
+			Filter:         query,
+			Sort:           sort,
+			Limit:          limit,
+			CalculateTotal: withTotal,
+		}
+
+		// A repeated query whose results have not changed only needs
+		// Email/queryChanges and the Email/get of the cached IDs.
+		key = queryCacheKey(client, emailQuery)
+		if total, list, cached = s.reuseQuery(ctx, client, key, emailQuery, properties); cached {
+			if textFallback && filter.Text != "" {
+				notes = append(notes, "server has no full-text search; the query was matched against subjects only")
+			}
+			break
+		}
+
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(emailQuery)
+		req.Invoke(&email.Get{
+			Account: accountID,
+			ReferenceIDs: &jmap.ResultReference{
+				ResultOf: queryCallID,
+				Name:     "Email/query",
+				Path:     "/ids",
+			},
+			Properties: properties,
+		})
+
+		resp, err = client.Do(req)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+
+		if len(resp.Responses) == 0 {
+			return errorResult(fmt.Errorf("empty response for Email/query")), nil, nil
+		}
+
+		if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+			switch {
+			case isUnsupportedFilter(me) && filter.Text != "" && !textFallback:
+				retryText = true
+				continue
+			case isCalculateTotalRefusal(me) && withTotal:
+				retryTotal = true
+				continue
+			case isUnsupportedFilter(me) && len(filter.Header) > 0:
+				q.learn(quirkNoHeaderFilter)
+				return errorResult(errNoHeaderSearch), nil, nil
+			}
+			break
+		}
+		if retryText {
+			q.learn(quirkNoTextSearch)
+		}
+		if retryTotal {
+			q.learn(quirkNoCalculateTotal)
+		}
+		if textFallback && filter.Text != "" {
+			notes = append(notes, "server has no full-text search; the query was matched against subjects only")
+		}
+		break
+	}
+
+	if !cached {
+		// First response: Email/query
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.QueryResponse:
+			total = args.Total
+			s.rememberQuery(ctx, key, args)
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+
+		// Second response: Email/get with summary properties
+		if len(resp.Responses) < 2 {
+			return errorResult(fmt.Errorf("missing Email/get response in query chain")), nil, nil
+		}
+
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			list = args.List
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+	}
+
+	if len(in.Headers) > 0 {
+		s.fillRawHeaders(ctx, client, q, accountID, list)
+	}
+	// Facets are an extra overview; failing to compute them is noted
+	// rather than failing the query.
+	var structured emailQueryStructured
+	if len(in.Facets) > 0 {
+		facets, err := queryFacetsOf(ctx, client, accountID, matched, in.Facets, !q.has(quirkNoCalculateTotal), time.Now())
+		if err != nil {
+			notes = append(notes, "facets unavailable: "+err.Error())
+		} else {
+			structured.Facets = facets
+		}
+	}
+	var sb strings.Builder
+	if q.has(quirkNoCalculateTotal) {
+		fmt.Fprintf(&sb, "Total: unknown (returning %d)\n", len(list))
+	} else {
+		fmt.Fprintf(&sb, "Total: %d (returning %d)\n", total, len(list))
+	}
+	for _, n := range notes {
+		fmt.Fprintf(&sb, "Note: %s\n", n)
+	}
+	sb.WriteString("\n")
+	for _, e := range list {
+		parts := []string{string(e.ID)}
+		if fieldSet["receivedAt"] && e.ReceivedAt != nil {
+			parts = append(parts, s.locale.dateTime(*e.ReceivedAt))
+		}
+		if fieldSet["sentAt"] && e.SentAt != nil {
+			parts = append(parts, "sent "+s.locale.dateTime(*e.SentAt))
+		}
+		if fieldSet["from"] && len(e.From) > 0 {
+			parts = append(parts, formatAddresses(e.From))
+		}
+		if fieldSet["to"] && len(e.To) > 0 {
+			parts = append(parts, "to "+formatAddresses(e.To))
+		}
+		if fieldSet["size"] {
+			parts = append(parts, "["+s.locale.size(int64(e.Size))+"]")
+		}
+		if fieldSet["importance"] {
+			if imp := emailImportance(e); imp != "" {
+				parts = append(parts, "["+imp+"]")
+			}
+		}
+		if fieldSet["subject"] {
+			parts = append(parts, e.Subject)
+		}
+		fmt.Fprintf(&sb, "%s\n", strings.Join(parts, "  "))
+		for _, h := range e.Headers {
+			for _, want := range in.Headers {
+				if strings.EqualFold(h.Name, want) {
+					fmt.Fprintf(&sb, "  %s: %s\n", h.Name, strings.TrimSpace(h.Value))
+					break
+				}
+			}
+		}
+	}
+	if structured.Facets != nil {
+		sb.WriteString(structured.Facets.format())
+	}
+	// A huge total is better narrowed than paged; guidance is best effort.
+	if !q.has(quirkNoCalculateTotal) && total > narrowThreshold && uint64(len(list)) < total {
+		if n, err := narrowQuery(ctx, client, accountID, matched, total, time.Now()); err == nil {
+			sb.WriteString(n.format())
+			structured.Narrowing = n
+		}
+	}
+	if s.structuredOutput {
+		structured.Emails = make([]emailSummary, 0, len(list))
+		for _, e := range list {
+			structured.Emails = append(structured.Emails, summarizeEmail(e))
+		}
+	}
+	result := textResult(sb.String())
+	if structured.Facets != nil || structured.Narrowing != nil || structured.Emails != nil {
+		result.StructuredContent = &structured
+	}
+	return result, nil, nil
+}
+
+// --- email_get ---
+
+type EmailGetInput struct {
+	EmailIDs    []string `json:"email_ids" jsonschema:"IDs of emails to retrieve"`
+	FullHeaders bool     `json:"full_headers,omitempty" jsonschema:"Include all raw email headers"`
+	MaxChars    int      `json:"max_chars,omitempty" jsonschema:"Maximum total response size in characters (default 50000 unless the server is configured otherwise). When exceeded, remaining emails are omitted with an advisory to fetch fewer at a time."`
+	Trackers    bool     `json:"tracker_report,omitempty" jsonschema:"Report tracking pixels, remote images, and active content found (and stripped) in each HTML body"`
+	Spam        bool     `json:"spam_report,omitempty" jsonschema:"Report each email's spam filter verdict, score, threshold, and matched rules from X-Spam-Status, Stalwart, and Rspamd headers, and its $junk/$notjunk keyword"`
+	Translate   bool     `json:"translate,omitempty" jsonschema:"Append a machine translation of each body whose detected language differs from the configured target (only when the server has a translation endpoint configured)"`
+	Truncate    string   `json:"truncate_strategy,omitempty" jsonschema:"How to fit long bodies: head (default, keep the beginning), proportional (split max_chars evenly across all emails, shortening headers to the ID line when a share is small, so none are omitted unless even those do not fit), head_tail (keep beginning and end, cut the middle)"`
+
+	FoldQuotes    bool `json:"fold_quotes,omitempty" jsonschema:"Replace each quoted reply with a one-line marker giving its length and author instead of removing it silently"`
+	IncludeQuotes bool `json:"include_quotes,omitempty" jsonschema:"Return bodies whole, with quoted replies and signatures"`
+	Signatures    bool `json:"signatures,omitempty" jsonschema:"Parse each sender's signature into name, title, company, phone, email, and website, listed as a Signature block with the headers"`
+
+	HeaderInclude []string `json:"header_include,omitempty" jsonschema:"With full_headers, only headers matching these names (case-insensitive, * wildcards, e.g. Received, Authentication-Results, X-*); replaces the server's header patterns"`
+	HeaderExclude []string `json:"header_exclude,omitempty" jsonschema:"With full_headers, leave out headers matching these names (e.g. DKIM-Signature, ARC-*), in addition to the server's exclusions"`
+
+	Continuation string `json:"continuation,omitempty" jsonschema:"Token from a response cut at max_chars: return the next segment of the same emails with the same options. Give it alone, without email_ids"`
+}
+
+// quotes returns the quoted text handling the call asks for.
+func (in EmailGetInput) quotes() string {
+	switch {
+	case in.IncludeQuotes:
+		return quotesInclude
+	case in.FoldQuotes:
+		return quotesFold
+	}
+	return quotesStrip
+}
+
+// DefaultQueryLimit is the number of email_query results returned when the
+// call sets no limit.
+const DefaultQueryLimit = 20
+
+// DefaultMaxChars is the email_get response budget used when the call sets
+// no max_chars.
+const DefaultMaxChars = 50000
+
+// truncationNoticeSize bounds the "--- TRUNCATED" notice email_get ends a
+// cut response with, besides the email ID it names.
+const truncationNoticeSize = 200
+
+var emailGetTool = &mcp.Tool{
+	Name:        "email_get",
+	Description: "Get full content of emails by ID, including body text, detected body language, flags, mailbox membership, and attachment list with blob IDs (with duration, codecs, and dimensions of audio and video files). Set full_headers to include all raw headers. Use email_query first to obtain IDs. Response is capped at max_chars (default 50000 unless the server is configured otherwise); when the emails do not fit, the response ends with a continuation token — call email_get with only continuation to get the next segment (the rest of a cut body, then the omitted emails), or set truncate_strategy=proportional to share the budget across all emails. Use truncate_strategy=head_tail to keep closing lines (action items, signatures) of long bodies. Quoted replies are removed; set fold_quotes to see a marker such as \"[> 24 quoted lines from Alice, expand with include_quotes]\" in their place, and include_quotes to read them. Set spam_report to see why the spam filter scored a message as it did. Set signatures to get the contact details of each sender's signature (name, title, company, phone) as structured lines. A \"Body truncated\" line marks bodies the mail server cut to the budget; fetch that email alone with a larger max_chars to read more.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailGet(ctx context.Context, _ *mcp.CallToolRequest, in EmailGetInput) (*mcp.CallToolResult, any, error) {
+	owner := s.idOwner(ctx)
+	skip := 0 // body bytes of the first email earlier segments returned
+	if in.Continuation != "" {
+		if len(in.EmailIDs) > 0 {
+			return errorResult(invalidArgument("give continuation alone, without email_ids")), nil, nil
+		}
+		c, err := s.continuations.take(owner, in.Continuation, time.Now())
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if c.Endpoint != EndpointFromContext(ctx) {
+			return errorResult(invalidArgument("continuation %s belongs to endpoint %q", c.ID, c.Endpoint)), nil, nil
+		}
+		in, skip = c.In, c.Skip
+	}
+	if err := validHeaderPatterns(append(slices.Clip(in.HeaderInclude), in.HeaderExclude...)); err != nil {
+		return errorResult(err), nil, nil
+	}
+	headerPatterns := s.headerFilter.forCall(in.HeaderInclude, in.HeaderExclude)
+	if len(in.EmailIDs) == 0 {
+		return errorResult(invalidArgument("email_ids is required")), nil, nil
+	}
+	if !validTruncateStrategy(in.Truncate) {
+		return errorResult(invalidArgument("unknown truncate_strategy %q: expected head, proportional, or head_tail", in.Truncate)), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	// Bodies are fetched lazily (see fetchBodies), so this first call only
+	// returns metadata and the body structure with part sizes.
+	properties := []string{
+		"id", "subject", "from", "to", "cc", "bcc", "replyTo",
+		"receivedAt", "sentAt", "preview", "hasAttachment", "keywords",
+		"mailboxIds", "size", "textBody", "htmlBody", "attachments",
+		"headers", "threadId",
+	}
+	if in.FullHeaders || in.Spam || s.pgp != nil {
+		properties = append(properties, "blobId")
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        toJMAPIDSlice(in.EmailIDs),
+		Properties: properties,
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/get")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 {
+			return errorResult(notFoundError(args.NotFound, "emails not found: %v", args.NotFound)), nil, nil
+		}
+		if len(args.List) == 0 {
+			return errorResult(fmt.Errorf("no emails found")), nil, nil
+		}
+		if in.FullHeaders || in.Spam {
+			s.fillRawHeaders(ctx, client, s.quirksFor(client), accountID, args.List)
+		}
+
+		maxChars := in.MaxChars
+		if maxChars <= 0 {
+			maxChars = s.maxChars
+		}
+		// Proportional mode caps each email at an even share of the budget,
+		// less room for the truncation notice, so the whole response stays
+		// within maxChars.
+		perEmail := maxChars
+		if in.Truncate == TruncateProportional {
+			reserve := 0
+			for _, e := range args.List {
+				reserve = max(reserve, truncationNoticeSize+len(e.ID))
+			}
+			perEmail = max(maxChars-reserve, 0) / len(args.List)
+		}
+
+		probes := maxMediaProbes // attachments left to probe for media metadata
+
+		var sb strings.Builder
+		structured := emailGetStructured{Emails: []emailSummary{}}
+		included := 0
+		fetched := 0  // emails [0, fetched) have their body values
+		next := -1    // index of the first email left for the next segment
+		nextSkip := 0 // body bytes of that email returned so far
+		for i, e := range args.List {
+			if i >= fetched && sb.Len() < maxChars {
+				limit := min(maxChars-sb.Len(), perEmail)
+				batch := bodyBatch(args.List[i:], limit, maxChars-sb.Len())
+				fetchLimit := limit
+				if i == 0 {
+					// A resumed body is fetched up to where this segment ends.
+					fetchLimit += skip
+				}
+				if err := s.fetchBodies(ctx, client, accountID, batch, bodyFetchBytes(fetchLimit, in.Truncate), in.Trackers); err != nil {
+					return errorResult(err), nil, nil
+				}
+				fetched = i + len(batch)
+			}
+
+			// Render headers into a temporary buffer.
+			var hdr strings.Builder
+			if i > 0 {
+				fmt.Fprintf(&hdr, "\n---\n\n")
+			}
+			if in.FullHeaders && len(e.Headers) > 0 {
+				kept, omitted := headerPatterns.filter(e.Headers)
+				for _, h := range kept {
+					fmt.Fprintf(&hdr, "%s: %s\n", h.Name, strings.TrimSpace(h.Value))
+				}
+				if len(omitted) > 0 {
+					fmt.Fprintf(&hdr, "Headers omitted: %s (request them with header_include)\n", strings.Join(omitted, ", "))
+				}
+			} else {
+				fmt.Fprintf(&hdr, "ID: %s\n", e.ID)
+				fmt.Fprintf(&hdr, "Subject: %s\n", e.Subject)
+				if len(e.From) > 0 {
+					fmt.Fprintf(&hdr, "From: %s\n", formatAddresses(e.From))
+				}
+				if len(e.To) > 0 {
+					fmt.Fprintf(&hdr, "To: %s\n", formatAddresses(e.To))
+				}
+				if len(e.CC) > 0 {
+					fmt.Fprintf(&hdr, "CC: %s\n", formatAddresses(e.CC))
+				}
+				if e.ReceivedAt != nil {
+					fmt.Fprintf(&hdr, "Date: %s\n", s.locale.dateTime(*e.ReceivedAt))
+				}
+			}
+			if imp := emailImportance(e); imp != "" {
+				fmt.Fprintf(&hdr, "Importance: %s\n", imp)
+			}
+			if e.ThreadID != "" {
+				fmt.Fprintf(&hdr, "Thread: %s (digest: %s)\n", e.ThreadID, threadSummaryURI(e.ThreadID))
+			}
+			if note := s.openPGP(ctx, client, accountID, e); note != "" {
+				fmt.Fprintf(&hdr, "PGP: %s\n", note)
+			}
+			body, redacted := s.redactor.redact(extractBody(e, s.maxBodyChars, in.Truncate, in.quotes()))
+			lang := detectLanguage(body)
+			if lang != "" {
+				fmt.Fprintf(&hdr, "Language: %s\n", lang)
+			}
+			if redacted > 0 {
+				fmt.Fprintf(&hdr, "Redacted: %d secret(s) masked by server policy\n", redacted)
+			}
+			resumed := 0
+			if i == 0 && skip > 0 && string(e.ID) == in.EmailIDs[0] {
+				resumed = len(body)
+				body = resumeBody(body, skip)
+				resumed -= len(body)
+				fmt.Fprintf(&hdr, "Continued: body from character %d\n", resumed)
+			}
+			if bv, _ := renderedBodyValue(e); bv != nil && bv.IsTruncated {
+				fmt.Fprintf(&hdr, "Body truncated: the mail server cut this body to fit max_chars; call email_get with only %s and a larger max_chars to read more\n", e.ID)
+			}
+			if in.Trackers {
+				if report, ok := htmlBodyReport(e); ok {
+					fmt.Fprintf(&hdr, "Trackers: %s\n", report)
+				}
+			}
+			if in.Spam {
+				hdr.WriteString(spamSection(e))
+			}
+			if in.Signatures {
+				if sig := emailSignature(e); sig != nil {
+					hint, _ := s.redactor.redact(sig.String())
+					fmt.Fprintf(&hdr, "Signature:\n%s", hint)
+				}
+			}
+			if len(e.Attachments) > 0 {
+				media := s.attachmentMedia(ctx, client, accountID, e.Attachments, &probes)
+				fmt.Fprintf(&hdr, "Attachments:\n%s\n", formatAttachmentMedia(e.Attachments, "  ", media))
+			}
+			fmt.Fprintln(&hdr)
+
+			if body == "" {
+				body = "(no body content)"
+			}
+
+			// Check if appending this email would exceed the limit.
+			room := func(header int) int {
+				remaining := maxChars - sb.Len() - header
+				if share := perEmail - header; share < remaining {
+					remaining = max(share, 0)
+				}
+				return remaining
+			}
+			remaining := room(hdr.Len())
+			fits := remaining > 0
+			if in.Truncate == TruncateProportional {
+				// Its share must hold the header and the body or at least the
+				// truncation marker. When the full header leaves too little,
+				// only the ID line is kept; when even that does not fit, this
+				// email and the rest are left for the next segment, as with
+				// the head strategy.
+				bodyFits := func(remaining int) bool {
+					return len(body) <= remaining || remaining >= len(truncationMarker)
+				}
+				if !bodyFits(remaining) {
+					hdr.Reset()
+					if i > 0 {
+						hdr.WriteString("\n---\n\n")
+					}
+					fmt.Fprintf(&hdr, "ID: %s\n\n", e.ID)
+					remaining = room(hdr.Len())
+				}
+				fits = bodyFits(remaining)
+			}
+			if !fits {
+				if included > 0 {
+					next = i
+				}
+				break
+			}
+
+			translate := in.Translate && s.translator != nil && lang != "" && lang != s.translator.target
+			partLimit := remaining
+			if translate {
+				// The translation shares the email's budget: the original
+				// gets at most half of it.
+				partLimit = remaining / 2
+			}
+			part := truncateWith(body, partLimit, in.Truncate)
+			sb.WriteString(hdr.String())
+			sb.WriteString(part)
+			if translate {
+				// Only the text shown is sent for translation, and the
+				// translation gets what the original left of the budget;
+				// one that cannot fit at all is left out.
+				left := remaining - len(part)
+				var section string
+				translated, err := s.translator.translate(ctx, strings.TrimSuffix(part, truncationMarker), lang)
+				if err != nil {
+					section = fmt.Sprintf("\n\n[Translation unavailable: %v]", err)
+				} else {
+					label := fmt.Sprintf("\n\n[Translation %s → %s]\n", lang, s.translator.target)
+					section = label + TruncateBody(translated, left-len(label))
+				}
+				if len(section) <= left {
+					sb.WriteString(section)
+				}
+			}
+			included++
+			structured.Emails = append(structured.Emails, summarizeEmail(e))
+			if (in.Truncate == "" || in.Truncate == TruncateHead) && part != body {
+				// The rest of this body opens the next segment, unless none of
+				// it fit and the next segment would be this one again.
+				if consumed := len(strings.TrimSuffix(part, truncationMarker)); consumed > 0 || i > 0 {
+					next, nextSkip = i, resumed+consumed
+				}
+				break
+			}
+		}
+
+		if omitted := len(args.List) - included; omitted > 0 || next >= 0 {
+			fmt.Fprintf(&sb, "\n\n--- TRUNCATED: %d of %d emails omitted (response would exceed %d chars).", omitted, len(args.List), maxChars)
+			if next >= 0 {
+				rest := make([]string, 0, len(args.List)-next)
+				for _, e := range args.List[next:] {
+					rest = append(rest, string(e.ID))
+				}
+				cont := in
+				cont.EmailIDs, cont.Continuation = rest, ""
+				id := s.continuations.add(&continuation{Owner: owner, Endpoint: EndpointFromContext(ctx), In: cont, Skip: nextSkip}, time.Now())
+				if nextSkip > 0 {
+					fmt.Fprintf(&sb, " Call email_get with continuation=%s for the next segment, from the rest of %s. ---\n", id, rest[0])
+				} else {
+					fmt.Fprintf(&sb, " Call email_get with continuation=%s for the next segment. ---\n", id)
+				}
+			} else {
+				sb.WriteString(" Fetch fewer emails per call. ---\n")
+			}
+		}
+
+		result := textResult(sb.String())
+		if s.structuredOutput {
+			result.StructuredContent = &structured
+		}
+		return result, nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// maxBytesPerChar bounds the UTF-8 bytes needed per character, so a body
+// value capped at limit*maxBytesPerChar bytes still fills limit characters.
+const maxBytesPerChar = 4
+
+// bodyBatch returns the leading emails whose bodies are worth fetching
+// together: their text parts, each counted up to limit characters, fit in
+// budget. At least one email is returned. Part sizes are octets, an upper
+// bound on characters, so the batch never outruns the budget; emails after
+// it are fetched later only if budget remains.
+func bodyBatch(list []*email.Email, limit, budget int) []*email.Email {
+	used := 0
+	for i, e := range list {
+		used += min(bodySize(e), max(limit, 0))
+		if used >= budget {
+			return list[:i+1]
+		}
+	}
+	return list
+}
+
+// bodySize is the decoded size of the part extractBody renders.
+func bodySize(e *email.Email) int {
+	for _, parts := range [][]*email.BodyPart{e.TextBody, e.HTMLBody} {
+		if len(parts) > 0 {
+			return int(parts[0].Size)
+		}
+	}
+	return 0
+}
+
+// bodyFetchBytes is the maxBodyValueBytes for bodies rendered into limit
+// characters. head_tail needs the end of each body, so it fetches them whole.
+func bodyFetchBytes(limit int, strategy string) uint64 {
+	if strategy == TruncateHeadTail || limit <= 0 {
+		return 0
+	}
+	return uint64(limit) * maxBytesPerChar
+}
+
+// fetchBodies loads the text body values (and HTML ones for tracker
+// reports) of list into the emails, each capped at maxBytes (0: whole).
+// Values the server failed to decode from a legacy charset are decoded
+// again from their blobs (see redecodeBodies).
+func (s *Server) fetchBodies(ctx context.Context, client JMAPClient, accountID jmap.ID, list []*email.Email, maxBytes uint64, html bool) error {
+	ids := make([]jmap.ID, len(list))
+	byID := make(map[jmap.ID]*email.Email, len(list))
+	for i, e := range list {
+		ids[i] = e.ID
+		byID[e.ID] = e
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account:             accountID,
+		IDs:                 ids,
+		Properties:          []string{"id", "bodyValues"},
+		FetchTextBodyValues: true,
+		FetchHTMLBodyValues: html,
+		MaxBodyValueBytes:   maxBytes,
+	})
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses) == 0 {
+		return fmt.Errorf("empty response for Email/get")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		for _, got := range args.List {
+			if e, ok := byID[got.ID]; ok {
+				e.BodyValues = got.BodyValues
+			}
+		}
+		redecodeBodies(ctx, client, accountID, list, maxBytes)
+		return nil
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// --- email_create ---
+
+type EmailCreateInput struct {
+	To      []string `json:"to,omitempty" jsonschema:"Recipient email addresses, contact group names, or RFC 5322 address groups such as \"Team: a@example.org, b@example.org;\""`
+	CC      []string `json:"cc,omitempty" jsonschema:"CC email addresses, contact group names, or RFC 5322 address groups"`
+	BCC     []string `json:"bcc,omitempty" jsonschema:"BCC email addresses, contact group names, or RFC 5322 address groups"`
+	Subject string   `json:"subject" jsonschema:"Email subject"`
+	Body    string   `json:"body,omitempty" jsonschema:"Plain text email body"`
+	HTML    string   `json:"html_body,omitempty" jsonschema:"Optional HTML version of the body, sent alongside the plain text one (derived from the HTML when body is empty). Scripts, forms, event handlers, and tracking pixels are removed, and links whose text is an address other than their target are unlinked; the result lists every change."`
+
+	BodyPath string `json:"body_path,omitempty" jsonschema:"Instead of body: a local UTF-8 text file inside the client's filesystem roots (stdio mode only)"`
+	HTMLPath string `json:"html_body_path,omitempty" jsonschema:"Instead of html_body: a local HTML file inside the client's filesystem roots (stdio mode only)"`
+
+	Attachments []AttachmentRef `json:"attachments,omitempty" jsonschema:"Files to attach: uploads from attachment_upload, attachments of existing emails by the blob IDs email_get shows, or in stdio mode local files by path. Give an image a cid to show it inline where html_body references cid:<that id>"`
+	Importance  string          `json:"importance,omitempty" jsonschema:"Mark the message high or low importance: sets X-Priority and Importance headers, and high also sets the $important keyword (default normal)"`
+}
+
+var emailCreateTool = &mcp.Tool{
+	Name:        "email_create",
+	Description: "Create a new email draft in the Drafts mailbox, optionally with attachments: blobs uploaded via attachment_upload, or attachments of emails already in the account (blob IDs from email_get), which are reused without downloading them. Images can be embedded inline in the HTML body (charts, screenshots) by giving the attachment a cid and referencing it as <img src=\"cid:...\">. In stdio mode the body and attachments can also be local files inside the client's filesystem roots, read and uploaded by the server. A recipient that is a contact group name rather than an address is expanded to the group's members when the server supports JMAP Contacts; the expansion is reported in the result. An RFC 5322 address group (\"Team: a@example.org, b@example.org;\") is replaced by its members, and an empty one such as \"undisclosed-recipients:;\" adds no recipient. The sender identity's Reply-To and BCC, plus any operator-configured CC/BCC, are added automatically and listed in the result. Returns the draft ID, which can be passed to email_submission_set to send it.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailCreate(ctx context.Context, mcpReq *mcp.CallToolRequest, in EmailCreateInput) (*mcp.CallToolResult, any, error) {
+	if err := checkImportance(in.Importance); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if err := s.readLocalBodies(ctx, mcpReq, &in); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if err := checkInlineImages(in.HTML, in.Attachments); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	groups, err := expandAddressGroups(&in.To, &in.CC, &in.BCC)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	expanded, err := expandContactGroups(ctx, client, &in.To, &in.CC, &in.BCC)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	recipients := toMailAddresses(append(append(append([]string(nil), in.To...), in.CC...), in.BCC...))
+	if err := s.sendPolicy.checkRecipients(recipients); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	refs, err := s.uploadLocalAttachments(ctx, mcpReq, client, accountID, in.Attachments)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	attachments, err := s.attachmentParts(ctx, client, accountID, refs)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	draftsID, id, err := s.draftTarget(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	draft := &email.Email{
+		MailboxIDs:  map[jmap.ID]bool{draftsID: true},
+		Keywords:    map[string]bool{"$draft": true},
+		To:          toMailAddresses(in.To),
+		CC:          toMailAddresses(in.CC),
+		BCC:         toMailAddresses(in.BCC),
+		Subject:     in.Subject,
+		Attachments: attachments,
+	}
+	htmlChanges := setDraftBody(draft, in.Body, in.HTML)
+	applyImportance(draft, in.Importance)
+
+	added := s.applyDraftDefaults(draft, id)
+	if len(added) > 0 {
+		if err := s.sendPolicy.checkRecipients(draftRecipients(draft)); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Set{
+		Account: accountID,
+		Create:  map[jmap.ID]*email.Email{"draft": draft},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/set")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		if se, ok := args.NotCreated["draft"]; ok {
+			return errorResult(setFailure("draft creation failed", se)), nil, nil
+		}
+		text := "Created draft"
+		if created, ok := args.Created["draft"]; ok {
+			text = fmt.Sprintf("Created draft [id: %s]", created.ID)
+		}
+		if len(groups) > 0 {
+			text += "\n" + formatAddressGroups(groups)
+		}
+		if len(expanded) > 0 {
+			text += "\n" + formatGroupExpansions(expanded)
+		}
+		if len(added) > 0 {
+			text += "\n" + formatDraftDefaults(added)
+		}
+		if len(htmlChanges) > 0 {
+			text += "\n" + formatHTMLChanges(htmlChanges)
+		}
+		return textResult(text), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// readLocalBodies replaces the body_path and html_body_path of in with the
+// text of those local files.
+func (s *Server) readLocalBodies(ctx context.Context, req *mcp.CallToolRequest, in *EmailCreateInput) error {
+	for _, f := range []struct {
+		path, name string
+		body       *string
+	}{{in.BodyPath, "body", &in.Body}, {in.HTMLPath, "html_body", &in.HTML}} {
+		if f.path == "" {
+			continue
+		}
+		if *f.body != "" {
+			return invalidArgument("give %s or %s_path, not both", f.name, f.name)
+		}
+		_, data, err := s.readLocalFile(ctx, req, f.path, maxLocalBodyBytes)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(data) {
+			return invalidArgument("%s is not UTF-8 text", f.path)
+		}
+		*f.body = string(data)
+	}
+	return nil
+}
+
+// --- email_move ---
+
+type EmailMoveInput struct {
+	EmailIDs  []string `json:"email_ids,omitempty" jsonschema:"IDs of emails to move"`
+	Selection string   `json:"selection,omitempty" jsonschema:"Instead of email_ids: name of a selection made with selection_set"`
+	MailboxID string   `json:"mailbox_id" jsonschema:"Destination mailbox ID"`
+}
+
+var emailMoveTool = &mcp.Tool{
+	Name:        "email_move",
+	Description: "Move emails to a different mailbox by ID. Replaces all current mailbox memberships. Use mailbox_get to find the destination mailbox ID.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleEmailMove(ctx context.Context, req *mcp.CallToolRequest, in EmailMoveInput) (*mcp.CallToolResult, any, error) {
+	ids, err := s.selectedEmailIDs(ctx, req, in.EmailIDs, in.Selection)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	in.EmailIDs = ids
+	if len(in.EmailIDs) == 0 {
+		return errorResult(invalidArgument("email_ids or selection is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	mailboxes, emails, err := fetchMoveRights(ctx, client, accountID, in.EmailIDs)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if err := checkMoveRights(mailboxes, emails, jmap.ID(in.MailboxID)); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	move := jmap.Patch{"mailboxIds": map[string]bool{in.MailboxID: true}}
+	set, _, err := s.bulkSet(ctx, client, accountID, toJMAPIDSlice(in.EmailIDs), func(jmap.ID) jmap.Patch { return move }, nil)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	s.journal(ctx, req, &journalEntry{tool: "email_move", accountID: accountID, mailboxes: priorMailboxes(emails)}, set.NotUpdated)
+	if err := setFailures("move failed", set.NotUpdated); err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(fmt.Sprintf("Moved %d email(s) to mailbox %s", len(in.EmailIDs), in.MailboxID)), nil, nil
+}
+
+// --- email_flag ---
+
+type EmailFlagInput struct {
+	EmailIDs      []string `json:"email_ids,omitempty" jsonschema:"IDs of emails to update"`
+	Selection     string   `json:"selection,omitempty" jsonschema:"Instead of email_ids: name of a selection made with selection_set"`
+	Seen          *bool    `json:"seen,omitempty" jsonschema:"Mark as seen (true) or unseen (false)"`
+	Flagged       *bool    `json:"flagged,omitempty" jsonschema:"Mark as flagged/starred (true) or unflagged (false)"`
+	Answered      *bool    `json:"answered,omitempty" jsonschema:"Mark as answered (true) or unanswered (false)"`
+	Draft         *bool    `json:"draft,omitempty" jsonschema:"Mark as draft (true) or not-draft (false)"`
+	SetKeywords   []string `json:"set_keywords,omitempty" jsonschema:"Other keywords to add, e.g. $Forwarded, $Phishing, or $label1 (Thunderbird's Important label); case-insensitive"`
+	ClearKeywords []string `json:"clear_keywords,omitempty" jsonschema:"Other keywords to remove; case-insensitive"`
+}
+
+var emailFlagTool = &mcp.Tool{
+	Name:        "email_flag",
+	Description: "Set or remove flags on emails. Supports: seen (read/unread), flagged (starred), answered, draft, and any other keyword through set_keywords and clear_keywords (such as $Forwarded, $Phishing, or Thunderbird's $label1 to $label5; see keyword_list). Each flag is independent — omit to leave unchanged. Use email_query to obtain IDs.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleEmailFlag(ctx context.Context, req *mcp.CallToolRequest, in EmailFlagInput) (*mcp.CallToolResult, any, error) {
+	ids, err := s.selectedEmailIDs(ctx, req, in.EmailIDs, in.Selection)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	in.EmailIDs = ids
+	if len(in.EmailIDs) == 0 {
+		return errorResult(invalidArgument("email_ids or selection is required")), nil, nil
+	}
+
+	patch := jmap.Patch{}
+	applyKeyword(patch, "keywords/$seen", in.Seen)
+	applyKeyword(patch, "keywords/$flagged", in.Flagged)
+	applyKeyword(patch, "keywords/$answered", in.Answered)
+	applyKeyword(patch, "keywords/$draft", in.Draft)
+	for _, set := range []struct {
+		keywords []string
+		value    bool
+	}{{in.SetKeywords, true}, {in.ClearKeywords, false}} {
+		for _, kw := range set.keywords {
+			kw, err := checkKeyword(kw)
+			if err != nil {
+				return errorResult(err), nil, nil
+			}
+			key := "keywords/" + kw
+			if v, ok := patch[key]; ok && (v == true) != set.value {
+				return errorResult(invalidArgument("keyword %s is both set and cleared", kw)), nil, nil
+			}
+			applyKeyword(patch, key, &set.value)
+		}
+	}
+
+	if len(patch) == 0 {
+		return errorResult(invalidArgument("at least one flag must be provided")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	// The keywords before the change, for undo_last, come from an Email/get
+	// ahead of each Email/set in the same request.
+	set, list, err := s.bulkSet(ctx, client, accountID, toJMAPIDSlice(in.EmailIDs), func(jmap.ID) jmap.Patch { return patch }, []string{"id", "keywords"})
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	prior := make(map[jmap.ID]map[string]bool, len(list))
+	for _, e := range list {
+		prior[e.ID] = make(map[string]bool, len(patch))
+		for path := range patch {
+			kw := strings.TrimPrefix(path, "keywords/")
+			prior[e.ID][kw] = e.Keywords[kw]
+		}
+	}
+	s.journal(ctx, req, &journalEntry{tool: "email_flag", accountID: accountID, keywords: prior}, set.NotUpdated)
+	if err := setFailures("flag update failed", set.NotUpdated); err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(fmt.Sprintf("Updated flags on %d email(s)", len(in.EmailIDs))), nil, nil
+}
+
+// --- email_delete ---
+
+type EmailDeleteInput struct {
+	EmailIDs  []string `json:"email_ids,omitempty" jsonschema:"IDs of emails to delete"`
+	Selection string   `json:"selection,omitempty" jsonschema:"Instead of email_ids: name of a selection made with selection_set"`
+	Permanent bool     `json:"permanent,omitempty" jsonschema:"Permanently destroy emails instead of moving to Trash (default false)"`
+	Confirm   string   `json:"confirm,omitempty" jsonschema:"Confirmation token from a previous identical call, when the server requires confirming permanent deletion"`
+}
+
+var emailDeleteTool = &mcp.Tool{
+	Name:        "email_delete",
+	Description: "Delete emails by moving to Trash (default), or permanently destroy them (permanent=true). email_restore moves trashed emails back to the mailboxes they came from; permanent destruction cannot be undone, unless the server stages it: the emails then wait in Trash for a delay and email_delete_cancel takes the deletion back. Use email_query to obtain IDs.",
+	Annotations: destructiveAnnotations,
+}
+
+func (s *Server) handleEmailDelete(ctx context.Context, req *mcp.CallToolRequest, in EmailDeleteInput) (*mcp.CallToolResult, any, error) {
+	ids, err := s.selectedEmailIDs(ctx, req, in.EmailIDs, in.Selection)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	in.EmailIDs = ids
+	if len(in.EmailIDs) == 0 {
+		return errorResult(invalidArgument("email_ids or selection is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	if in.Permanent && s.deleteDelay > 0 {
+		return s.stageEmailDestroy(ctx, req, client, accountID, in)
+	}
+	if in.Permanent {
+		if err := checkEmailMoveRights(ctx, client, accountID, in.EmailIDs, ""); err != nil {
+			return errorResult(err), nil, nil
+		}
+		gated := in
+		gated.Selection, gated.Confirm = "", ""
+		summary := fmt.Sprintf("permanently destroy %d email(s): %s", len(in.EmailIDs), listSome(in.EmailIDs))
+		if res := s.confirmDestructive(ctx, "email_delete", gated, in.Confirm, summary); res != nil {
+			return res, nil, nil
+		}
+
+		set, _, err := s.bulkSet(ctx, client, accountID, toJMAPIDSlice(in.EmailIDs), nil, nil)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if err := setFailures("destroy failed", set.NotDestroyed); err != nil {
+			return errorResult(err), nil, nil
+		}
+		return textResult(fmt.Sprintf("Permanently destroyed %d email(s)", len(in.EmailIDs))), nil, nil
+	}
+
+	// Soft delete: the rights check's Mailbox/get also finds Trash, so
+	// moving takes two round trips rather than three.
+	mailboxes, emails, err := fetchMoveRights(ctx, client, accountID, in.EmailIDs)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	trashID, err := mailboxWithRole(mailboxes, mailbox.RoleTrash)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if err := checkMoveRights(mailboxes, emails, trashID); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	trash := jmap.Patch{"mailboxIds": map[string]bool{string(trashID): true}}
+	set, _, err := s.bulkSet(ctx, client, accountID, toJMAPIDSlice(in.EmailIDs), func(jmap.ID) jmap.Patch { return trash }, nil)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	s.recordTombstones(ctx, emails, trashID, set.NotUpdated)
+	s.journal(ctx, req, &journalEntry{tool: "email_delete", accountID: accountID, mailboxes: priorMailboxes(emails)}, set.NotUpdated)
+	if err := setFailures("trash failed", set.NotUpdated); err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(fmt.Sprintf("Moved %d email(s) to Trash; email_restore moves them back", len(in.EmailIDs))), nil, nil
+}
+
+// --- email helpers ---
+
+func formatAddresses(addrs []*mail.Address) string {
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		if g, ok := formatGroupSyntax(a.Name, a.Email); ok {
+			parts[i] = g
+			continue
+		}
+		parts[i] = (&mail.Address{Name: a.Name, Email: displayAddress(a.Email)}).String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// extractBody renders an email's body as plain text, capped at maxChars
+// (0: DefaultMaxBodyChars), with quoted replies stripped, folded, or kept as
+// quotes says.
+func extractBody(e *email.Email, maxChars int, strategy, quotes string) string {
+	bv, isHTML := renderedBodyValue(e)
+	switch {
+	case bv == nil:
+		return ""
+	case isHTML && quotes == quotesInclude:
+		return prepareBody(htmlToText(SanitizeHTML(bv.Value)), maxChars, strategy, quotes)
+	case isHTML && quotes == quotesFold:
+		return prepareBody(htmlToText(SanitizeHTML(FoldBlockquotes(bv.Value))), maxChars, strategy, quotes)
+	case isHTML:
+		return prepareBody(htmlToText(SanitizeHTML(StripBlockquotes(bv.Value))), maxChars, strategy, quotes)
+	default:
+		return prepareBody(bv.Value, maxChars, strategy, quotes)
+	}
+}
+
+// renderedBodyValue returns the body value extractBody renders: the first
+// fetched text body part, else the first fetched HTML body part.
+func renderedBodyValue(e *email.Email) (bv *email.BodyValue, isHTML bool) {
+	for _, part := range e.TextBody {
+		if bv, ok := e.BodyValues[part.PartID]; ok {
+			return bv, false
+		}
+	}
+	for _, part := range e.HTMLBody {
+		if bv, ok := e.BodyValues[part.PartID]; ok {
+			return bv, true
+		}
+	}
+	return nil, false
+}
+
+// htmlBodyReport sanitizes the email's HTML body and reports trackers and
+// active content found. ok is false when the email has no HTML body.
+func htmlBodyReport(e *email.Email) (report sanitizeReport, ok bool) {
+	for _, part := range e.HTMLBody {
+		if bv, found := e.BodyValues[part.PartID]; found && part.Type == "text/html" {
+			_, report = sanitizeHTMLReport(bv.Value)
+			return report, true
+		}
+	}
+	return report, false
+}
+
+// parseDate parses a date string as RFC 3339, normalizing bare dates (YYYY-MM-DD)
+// by appending the given time suffix first.
+func parseDate(s, timeSuffix string) (*time.Time, error) {
+	if len(s) == 10 && s[4] == '-' && s[7] == '-' {
+		s = s + timeSuffix
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format %q: expected YYYY-MM-DD or RFC 3339", s)
+	}
+	return &t, nil
+}
+
+// toMailAddresses converts a slice of email strings to JMAP Address objects.
+func toMailAddresses(addrs []string) []*mail.Address {
+	if len(addrs) == 0 {
+		return nil
+	}
+	result := make([]*mail.Address, len(addrs))
+	for i, a := range addrs {
+		result[i] = &mail.Address{Email: a}
+	}
+	return result
+}
+
+// checkKeyword validates kw against the keyword syntax of RFC 8621
+// section 4.1.1 (IMAP's flag-keyword: 1 to 255 printable ASCII characters
+// other than ( ) { ] % * " \) and returns it lowercased, as JMAP
+// keywords are case-insensitive and sent in lowercase.
+func checkKeyword(kw string) (string, error) {
+	if kw == "" || len(kw) > 255 {
+		return "", invalidArgument("keyword %q must be 1 to 255 characters", kw)
+	}
+	for _, r := range kw {
+		if r < 0x21 || r > 0x7e || strings.ContainsRune(`(){]%*"\`, r) {
+			return "", invalidArgument("keyword %q contains %q, which keywords may not", kw, r)
+		}
+	}
+	return strings.ToLower(kw), nil
+}
+
+// applyKeyword sets a JMAP keyword patch entry. true adds the keyword, false removes it.
+func applyKeyword(patch jmap.Patch, key string, val *bool) {
+	if val == nil {
+		return
+	}
+	if *val {
+		patch[key] = true
+	} else {
+		patch[key] = nil
+	}
+}
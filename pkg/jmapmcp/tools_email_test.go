@@ -0,0 +1,539 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+// newFakeServer returns a Server whose JMAP calls are served by an
+// in-process fake.
+func newFakeServer(t testing.TB, opts ...Option) (*Server, *jmapfake.Server) {
+	t.Helper()
+	fake := jmapfake.New()
+	opts = append([]Option{WithToken("test"), WithDialer(fake)}, opts...)
+	return NewServer("test", jmapfake.SessionURL, opts...), fake
+}
+
+// resultText joins the text content of a tool result.
+func resultText(res *mcp.CallToolResult) string {
+	var sb strings.Builder
+	for _, c := range res.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			sb.WriteString(tc.Text)
+		}
+	}
+	return sb.String()
+}
+
+// addEmail seeds an email in the inbox received on day of January 2025.
+func addEmail(fake *jmapfake.Server, id, subject, body string, day int) {
+	fake.Add("Email", map[string]any{
+		"id":         id,
+		"subject":    subject,
+		"from":       []any{map[string]any{"name": "Alice", "email": "alice@example.org"}},
+		"receivedAt": fmt.Sprintf("2025-01-%02dT10:00:00Z", day),
+		"mailboxIds": map[string]any{"inbox": true},
+		"keywords":   map[string]any{},
+		"textBody":   []any{map[string]any{"partId": "1", "type": "text/plain", "size": len(body)}},
+		"bodyValues": map[string]any{"1": map[string]any{"value": body}},
+	})
+}
+
+func TestEmailQueryChain(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Invoice January", "", 1)
+	addEmail(fake, "e2", "Lunch?", "", 2)
+	addEmail(fake, "e3", "Invoice February", "", 3)
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{Subject: "invoice"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	if !strings.HasPrefix(out, "Total: 2 (returning 2)") {
+		t.Errorf("unexpected header:\n%s", out)
+	}
+	if strings.Index(out, "e3") > strings.Index(out, "e1") {
+		t.Errorf("results not newest first:\n%s", out)
+	}
+	if strings.Contains(out, "e2") {
+		t.Errorf("non-matching email returned:\n%s", out)
+	}
+	if got := fake.Calls(); len(got) != 2 || got[0] != "Email/query" || got[1] != "Email/get" {
+		t.Errorf("calls = %v, want one chained Email/query + Email/get", got)
+	}
+}
+
+func TestEmailQueryTextFallback(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Quarterly report", "", 1)
+	fake.FailNext("Email/query", "unsupportedFilter")
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{Query: "report"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	if !strings.Contains(out, "e1") || !strings.Contains(out, "subjects only") {
+		t.Errorf("expected subject fallback with note:\n%s", out)
+	}
+
+	// The quirk is remembered: the next query goes straight to the fallback.
+	before := len(fake.Calls())
+	s.handleEmailQuery(context.Background(), nil, EmailQueryInput{Query: "report"})
+	if got := len(fake.Calls()) - before; got != 2 {
+		t.Errorf("second query made %d calls, want 2 (no retry)", got)
+	}
+}
+
+func TestEmailQueryCache(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	addEmail(fake, "e1", "Invoice January", "", 1)
+
+	s.handleEmailQuery(ctx, nil, EmailQueryInput{Subject: "invoice"})
+	before := len(fake.Calls())
+	res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{Subject: "invoice"})
+	if out := resultText(res); !strings.HasPrefix(out, "Total: 1 (returning 1)") || !strings.Contains(out, "Invoice January") {
+		t.Errorf("cached query:\n%s", out)
+	}
+	if got := fake.Calls()[before:]; len(got) != 2 || got[0] != "Email/queryChanges" || got[1] != "Email/get" {
+		t.Errorf("repeated query calls = %v, want Email/queryChanges + Email/get", got)
+	}
+
+	// A change the server cannot rule out falls back to the full query.
+	addEmail(fake, "e2", "Invoice February", "", 2)
+	before = len(fake.Calls())
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{Subject: "invoice"})
+	if out := resultText(res); !strings.HasPrefix(out, "Total: 2 (returning 2)") {
+		t.Errorf("query after a change:\n%s", out)
+	}
+	if got := fake.Calls()[before:]; len(got) != 4 || got[0] != "Email/queryChanges" || got[2] != "Email/query" {
+		t.Errorf("calls after a change = %v", got)
+	}
+}
+
+func TestEmailQueryHeader(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	addEmail(fake, "e1", "Weekly digest", "", 1)
+	addEmail(fake, "e2", "Out of office", "", 2)
+	addEmail(fake, "e3", "Hello", "", 3)
+	for id, h := range map[string]map[string]any{
+		"e1": {"name": "List-Id", "value": "<dev.lists.example.org>"},
+		"e2": {"name": "Auto-Submitted", "value": "auto-replied"},
+	} {
+		e := fake.Object("Email", id)
+		e["headers"] = []any{h}
+		fake.Add("Email", e)
+	}
+
+	for in, want := range map[string]string{"Auto-Submitted": "Out of office", "list-id: dev.lists.example.org": "Weekly digest"} {
+		res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{Header: in})
+		if out := resultText(res); res.IsError || !strings.HasPrefix(out, "Total: 1 ") || !strings.Contains(out, want) {
+			t.Errorf("header %q:\n%s", in, out)
+		}
+	}
+
+	res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{Header: "List Id"})
+	if !res.IsError {
+		t.Errorf("malformed header name accepted:\n%s", resultText(res))
+	}
+
+	fake.FailNext("Email/query", "unsupportedFilter")
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{Header: "X-Spam-Flag"})
+	if out := resultText(res); !res.IsError || !strings.Contains(out, "does not support searching by header") {
+		t.Errorf("refused header filter:\n%s", out)
+	}
+	before := len(fake.Calls())
+	s.handleEmailQuery(ctx, nil, EmailQueryInput{Header: "X-Spam-Flag"})
+	if got := len(fake.Calls()) - before; got != 0 {
+		t.Errorf("header query after the quirk was learned made %d calls", got)
+	}
+}
+
+func TestEmailQueryMethodError(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.FailNext("Email/query", "invalidArguments")
+	fake.FailNext("Email/query", "invalidArguments")
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{})
+	if !res.IsError || !strings.Contains(resultText(res), "invalidArguments") {
+		t.Errorf("expected invalidArguments error, got %q", resultText(res))
+	}
+}
+
+func TestEmailGetTruncation(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "First", strings.Repeat("a", 3000), 1)
+	addEmail(fake, "e2", "Second", strings.Repeat("b", 3000), 2)
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e1", "e2"}, MaxChars: 2000})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	if !strings.Contains(out, "TRUNCATED: 1 of 2 emails omitted") {
+		t.Errorf("expected second email to be omitted:\n%s", out)
+	}
+	if strings.Contains(out, "bbb") {
+		t.Errorf("omitted email body present:\n%s", out)
+	}
+}
+
+func TestEmailGetProportionalWithinMaxChars(t *testing.T) {
+	s, fake := newFakeServer(t)
+	var ids []string
+	for i := range 50 {
+		id := fmt.Sprintf("e%d", i)
+		addEmail(fake, id, fmt.Sprintf("Message %d", i), strings.Repeat("x", 400), i%28+1)
+		ids = append(ids, id)
+	}
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		maxChars int
+		shown    int // emails with at least their ID line
+		cut      bool
+	}{
+		{20000, 50, false}, // full headers
+		{5000, 50, false},  // ID lines only
+		{1500, 0, true},    // not even those
+	} {
+		res, _, _ := s.handleEmailGet(ctx, nil, EmailGetInput{EmailIDs: ids, MaxChars: tc.maxChars, Truncate: TruncateProportional})
+		out := resultText(res)
+		if res.IsError {
+			t.Fatalf("max_chars %d: %s", tc.maxChars, out)
+		}
+		if len(out) > tc.maxChars {
+			t.Errorf("max_chars %d: response is %d chars", tc.maxChars, len(out))
+		}
+		if n := strings.Count(out, "ID: "); n != tc.shown || strings.Contains(out, "TRUNCATED") != tc.cut {
+			t.Errorf("max_chars %d: %d emails shown, cut %v:\n%s", tc.maxChars, n, strings.Contains(out, "TRUNCATED"), out)
+		}
+	}
+}
+
+func TestEmailGetTranslationWithinMaxChars(t *testing.T) {
+	var sent []string
+	translate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Q string `json:"q"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		sent = append(sent, in.Q)
+		// Translations run longer than their source.
+		json.NewEncoder(w).Encode(map[string]string{"translatedText": strings.Repeat("translated ", len(in.Q)/5)})
+	}))
+	defer translate.Close()
+
+	s, fake := newFakeServer(t, WithTranslator(translate.URL, "", "en"))
+	body := strings.Repeat("Der Bericht ist fertig und die Zahlen sind gut, aber wir müssen noch über das Budget sprechen.\n", 60)
+	var ids []string
+	for i := range 3 {
+		id := fmt.Sprintf("e%d", i)
+		addEmail(fake, id, "Bericht", body, i+1)
+		ids = append(ids, id)
+	}
+
+	for _, strategy := range []string{TruncateHead, TruncateProportional} {
+		sent = nil
+		res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: ids, MaxChars: 4000, Truncate: strategy, Translate: true})
+		out := resultText(res)
+		if res.IsError || !strings.Contains(out, "[Translation de → en]") {
+			t.Fatalf("%s: %s", strategy, out)
+		}
+		// The head strategy's notice of omitted emails follows the budget.
+		limit := 4000
+		if strategy == TruncateHead {
+			limit += truncationNoticeSize + len("e0")
+		}
+		if len(out) > limit {
+			t.Errorf("%s: response is %d chars for max_chars 4000", strategy, len(out))
+		}
+		for _, q := range sent {
+			if len(q) >= len(body) {
+				t.Errorf("%s: the whole body (%d chars) was sent for translation", strategy, len(q))
+			}
+		}
+	}
+}
+
+func TestEmailGetContinuation(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "First", strings.Repeat("a", 2500)+strings.Repeat("z", 500), 1)
+	addEmail(fake, "e2", "Second", strings.Repeat("b", 500), 2)
+	ctx := context.Background()
+	tokenRe := regexp.MustCompile(`continuation=(seg-\d+)`)
+
+	res, _, _ := s.handleEmailGet(ctx, nil, EmailGetInput{EmailIDs: []string{"e1", "e2"}, MaxChars: 2000})
+	out := resultText(res)
+	m := tokenRe.FindStringSubmatch(out)
+	if res.IsError || m == nil || strings.Contains(out, "zzz") {
+		t.Fatalf("first segment:\n%s", out)
+	}
+
+	first := out
+
+	res, _, _ = s.handleEmailGet(ctx, nil, EmailGetInput{Continuation: m[1], MaxChars: 10000})
+	out = resultText(res)
+	if res.IsError || !strings.Contains(out, "Continued: body from character") || !strings.Contains(out, "zzz") || !strings.Contains(out, "bbb") {
+		t.Fatalf("second segment:\n%s", out)
+	}
+	if strings.Contains(out, "TRUNCATED") {
+		t.Errorf("second segment is cut:\n%s", out)
+	}
+	if n := strings.Count(first, "a") + strings.Count(out, "a"); n < 2500 || n > 2600 {
+		t.Errorf("segments hold %d a's, want the body's 2500 once", n)
+	}
+
+	res, _, _ = s.handleEmailGet(ctx, nil, EmailGetInput{Continuation: m[1]})
+	if !res.IsError || !strings.Contains(resultText(res), "no open continuation") {
+		t.Errorf("reused continuation = %s", resultText(res))
+	}
+	res, _, _ = s.handleEmailGet(ctx, nil, EmailGetInput{Continuation: "seg-1", EmailIDs: []string{"e1"}})
+	if !res.IsError || !strings.Contains(resultText(res), "without email_ids") {
+		t.Errorf("continuation with email_ids = %s", resultText(res))
+	}
+}
+
+func TestEmailGetNotFound(t *testing.T) {
+	s, _ := newFakeServer(t)
+
+	res, _, _ := s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"missing"}})
+	if !res.IsError || !strings.Contains(resultText(res), "not found") {
+		t.Errorf("expected not found error, got %q", resultText(res))
+	}
+}
+
+func TestEmailFlag(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Hello", "", 1)
+
+	seen, flagged := true, false
+	res, _, _ := s.handleEmailFlag(context.Background(), nil, EmailFlagInput{EmailIDs: []string{"e1"}, Seen: &seen, Flagged: &flagged})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	keywords, _ := fake.Object("Email", "e1")["keywords"].(map[string]any)
+	if keywords["$seen"] != true {
+		t.Errorf("$seen not set: %v", keywords)
+	}
+	if _, ok := keywords["$flagged"]; ok {
+		t.Errorf("$flagged set: %v", keywords)
+	}
+}
+
+func TestEmailFlagKeywords(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Email", map[string]any{"id": "e1", "keywords": map[string]any{"$label1": true}})
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailFlag(ctx, nil, EmailFlagInput{EmailIDs: []string{"e1"}, SetKeywords: []string{"$Phishing", "$Forwarded"}, ClearKeywords: []string{"$label1"}})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	keywords, _ := fake.Object("Email", "e1")["keywords"].(map[string]any)
+	if keywords["$phishing"] != true || keywords["$forwarded"] != true || keywords["$label1"] != nil {
+		t.Errorf("keywords = %v", keywords)
+	}
+
+	for _, in := range []EmailFlagInput{
+		{EmailIDs: []string{"e1"}, SetKeywords: []string{"needs review"}},
+		{EmailIDs: []string{"e1"}, SetKeywords: []string{"50%"}},
+		{EmailIDs: []string{"e1"}, ClearKeywords: []string{""}},
+		{EmailIDs: []string{"e1"}, SetKeywords: []string{"$Junk"}, ClearKeywords: []string{"$junk"}},
+		{EmailIDs: []string{"e1"}, Seen: new(bool), SetKeywords: []string{"$seen"}},
+	} {
+		if res, _, _ := s.handleEmailFlag(ctx, nil, in); !res.IsError {
+			t.Errorf("email_flag %+v accepted", in)
+		}
+	}
+}
+
+func TestEmailGetLazyBodies(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Huge", strings.Repeat("a", 100000), 1)
+	addEmail(fake, "e2", "Small", "short body", 2)
+	addEmail(fake, "e3", "Omitted", strings.Repeat("c", 100000), 3)
+
+	res, _, _ := withFetchMeter(s, s.handleEmailGet)(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e1", "e2", "e3"}, MaxChars: 1000})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	if !strings.Contains(out, "TRUNCATED: 2 of 3 emails omitted") {
+		t.Errorf("expected e2 and e3 to be omitted:\n%s", out)
+	}
+	if !strings.Contains(out, "Body truncated: the mail server cut this body") {
+		t.Errorf("server-side truncation not reported:\n%s", out)
+	}
+	// Only e1's body is fetched, capped near the character budget.
+	if got := fake.Calls(); len(got) != 2 {
+		t.Errorf("calls = %v, want metadata + one body fetch", got)
+	}
+	if used, _ := res.Meta[fetchMeterMetaKey].(int64); used > 20000 {
+		t.Errorf("fetched %d bytes for a 1000 character budget", used)
+	}
+}
+
+func TestOutputLimitOptions(t *testing.T) {
+	s, fake := newFakeServer(t, WithQueryLimit(1), WithMaxBodyChars(100))
+	addEmail(fake, "e1", "First", strings.Repeat("a", 500), 1)
+	addEmail(fake, "e2", "Second", "", 2)
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{})
+	if out := resultText(res); !strings.Contains(out, "(returning 1)") {
+		t.Errorf("query limit not applied:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailGet(context.Background(), nil, EmailGetInput{EmailIDs: []string{"e1"}})
+	if out := resultText(res); strings.Contains(out, strings.Repeat("a", 101)) {
+		t.Errorf("body cap not applied:\n%s", out)
+	}
+}
+
+func TestComposeAndDeleteRoundTrips(t *testing.T) {
+	var calls int
+	s, fake := newFakeServer(t, WithClientWrapper(func(c JMAPClient) JMAPClient {
+		return countingClient{c, &calls}
+	}))
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	addEmail(fake, "e1", "Hello", "hi", 1)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		call func() *mcp.CallToolResult
+	}{
+		{"email_create", func() *mcp.CallToolResult {
+			res, _, _ := s.handleEmailCreate(ctx, nil, EmailCreateInput{To: []string{"bob@example.com"}, Subject: "Hi", Body: "Hi"})
+			return res
+		}},
+		{"email_forward", func() *mcp.CallToolResult {
+			res, _, _ := s.handleEmailForward(ctx, nil, EmailForwardInput{EmailID: "e1", To: []string{"bob@example.com"}})
+			return res
+		}},
+		{"email_delete", func() *mcp.CallToolResult {
+			res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}})
+			return res
+		}},
+	} {
+		calls = 0
+		if res := tc.call(); res.IsError {
+			t.Fatalf("%s: %s", tc.name, resultText(res))
+		}
+		if calls != 2 {
+			t.Errorf("%s made %d requests, want 2", tc.name, calls)
+		}
+	}
+	if mbs := fake.Object("Email", "e1")["mailboxIds"].(map[string]any); !mbs["trash"].(bool) {
+		t.Errorf("e1 mailboxIds = %v, want trash", mbs)
+	}
+}
+
+// addHTMLEmail adds an email to fake whose only body is html.
+func addHTMLEmail(fake *jmapfake.Server, id, subject, html string, day int) {
+	fake.Add("Email", map[string]any{
+		"id":         id,
+		"subject":    subject,
+		"from":       []any{map[string]any{"name": "Alice", "email": "alice@example.org"}},
+		"receivedAt": fmt.Sprintf("2025-01-%02dT10:00:00Z", day),
+		"mailboxIds": map[string]any{"inbox": true},
+		"keywords":   map[string]any{},
+		"htmlBody":   []any{map[string]any{"partId": "1", "type": "text/html", "size": len(html)}},
+		"bodyValues": map[string]any{"1": map[string]any{"value": html}},
+	})
+}
+
+// htmlEmail is an email whose only body is html, as email_get renders it.
+func htmlEmail(html string) *email.Email {
+	return &email.Email{
+		HTMLBody:   []*email.BodyPart{{PartID: "1", Type: "text/html"}},
+		BodyValues: map[string]*email.BodyValue{"1": {Value: html}},
+	}
+}
+
+func BenchmarkExtractBodyHTML(b *testing.B) {
+	for _, size := range []int{10 << 10, 1 << 20} {
+		e := htmlEmail(largeHTML(size))
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for b.Loop() {
+				extractBody(e, 0, TruncateHead, quotesStrip)
+			}
+		})
+	}
+}
+
+// TestExtractBodyHTMLScales guards against superlinear HTML handling: four
+// times the input may take at most eight times as long, where a quadratic
+// pass would take sixteen.
+func TestExtractBodyHTMLScales(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test")
+	}
+	measure := func(size int) time.Duration {
+		e := htmlEmail(largeHTML(size))
+		best := time.Duration(math.MaxInt64)
+		for range 3 {
+			start := time.Now()
+			extractBody(e, 0, TruncateHead, quotesStrip)
+			best = min(best, time.Since(start))
+		}
+		return best
+	}
+	small, large := measure(512<<10), measure(2<<20)
+	if large > 8*small {
+		t.Errorf("2 MiB took %s, 512 KiB %s: more than 8 times as long", large, small)
+	}
+}
+
+func BenchmarkEmailQuery(b *testing.B) {
+	s, fake := newFakeServer(b)
+	for i := range 500 {
+		addEmail(fake, fmt.Sprintf("e%d", i), fmt.Sprintf("Message %d", i), "body", i%28+1)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		if res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{Limit: 50}); res.IsError {
+			b.Fatal(resultText(res))
+		}
+	}
+}
+
+func BenchmarkEmailGet(b *testing.B) {
+	s, fake := newFakeServer(b)
+	html := largeHTML(50 << 10)
+	var ids []string
+	for i := range 20 {
+		id := fmt.Sprintf("e%d", i)
+		addHTMLEmail(fake, id, fmt.Sprintf("Newsletter %d", i), html, i+1)
+		ids = append(ids, id)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		if res, _, _ := s.handleEmailGet(ctx, nil, EmailGetInput{EmailIDs: ids, Truncate: TruncateProportional}); res.IsError {
+			b.Fatal(resultText(res))
+		}
+	}
+}
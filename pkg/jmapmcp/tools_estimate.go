@@ -0,0 +1,227 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	estimatePageSize       = 500
+	defaultEstimateMaxScan = 5000
+	estimateMaxScanCeiling = 50000
+)
+
+// estimateOperations are the bulk operations email_estimate can size.
+var estimateOperations = []string{"flag", "move", "delete", "export"}
+
+// --- email_estimate ---
+
+type EmailEstimateInput struct {
+	Operation     string `json:"operation" jsonschema:"Bulk operation to size: flag, move, delete (all via Email/set), or export (one blob download per email)"`
+	MailboxID     string `json:"mailbox_id,omitempty" jsonschema:"ID of the mailbox to search in"`
+	Query         string `json:"query,omitempty" jsonschema:"Full-text search query"`
+	From          string `json:"from,omitempty" jsonschema:"Filter by sender address"`
+	To            string `json:"to,omitempty" jsonschema:"Filter by recipient address"`
+	Subject       string `json:"subject,omitempty" jsonschema:"Filter by subject text"`
+	Before        string `json:"before,omitempty" jsonschema:"Emails before this date (RFC 3339 or YYYY-MM-DD)"`
+	After         string `json:"after,omitempty" jsonschema:"Emails after this date (RFC 3339 or YYYY-MM-DD)"`
+	HasAttachment *bool  `json:"has_attachment,omitempty" jsonschema:"Filter by attachment presence"`
+	MaxEmails     int    `json:"max_emails,omitempty" jsonschema:"Maximum number of matching emails to count sizes for (default 5000, max 50000)"`
+}
+
+var emailEstimateTool = &mcp.Tool{
+	Name:        "email_estimate",
+	Description: "Dry run for a bulk operation: counts the emails matching an email_query-style filter and reports their total size and how many JMAP calls flagging, moving, deleting, or exporting them would take. Reads only IDs and sizes; changes nothing. Use before acting on a large selection.",
+	Annotations: readOnlyAnnotations,
+}
+
+// estimate is what a bulk operation over a selection would cost.
+type estimate struct {
+	operation string
+	matched   uint64 // emails matching the filter (scanned when the total is unknown)
+	scanned   int    // emails whose sizes were counted
+	bytes     uint64 // total size of the scanned emails
+	exact     bool   // matched is the server's total, not a lower bound
+	pageSize  uint64 // IDs per Email/query page
+	setSize   uint64 // objects per Email/set call
+	batchSize uint64 // method calls per request
+}
+
+func (s *Server) handleEmailEstimate(ctx context.Context, _ *mcp.CallToolRequest, in EmailEstimateInput) (*mcp.CallToolResult, any, error) {
+	if in.Operation == "" {
+		return errorResult(invalidArgument("operation is required (one of %s)", strings.Join(estimateOperations, ", "))), nil, nil
+	}
+	if !slices.Contains(estimateOperations, in.Operation) {
+		return errorResult(invalidArgument("unknown operation %q (one of %s)", in.Operation, strings.Join(estimateOperations, ", "))), nil, nil
+	}
+
+	filter, err := EmailQueryInput{
+		MailboxID:     in.MailboxID,
+		Query:         in.Query,
+		From:          in.From,
+		To:            in.To,
+		Subject:       in.Subject,
+		Before:        in.Before,
+		After:         in.After,
+		HasAttachment: in.HasAttachment,
+	}.filter()
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	maxScan := in.MaxEmails
+	if maxScan <= 0 {
+		maxScan = defaultEstimateMaxScan
+	}
+	maxScan = min(maxScan, estimateMaxScanCeiling)
+
+	est := &estimate{operation: in.Operation, pageSize: estimatePageSize}
+	plan := s.bulkPlanFor(ctx, client)
+	est.pageSize = min(est.pageSize, uint64(plan.getSize))
+	est.setSize, est.batchSize = uint64(plan.setSize), uint64(plan.calls)
+
+	noTotal := s.quirksFor(client).has(quirkNoCalculateTotal)
+	est.exact = !noTotal
+
+	for est.scanned < maxScan {
+		limit := min(est.pageSize, uint64(maxScan-est.scanned))
+
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(&email.Query{
+			Account:        accountID,
+			Filter:         filter,
+			Sort:           []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Position:       int64(est.scanned),
+			Limit:          limit,
+			CalculateTotal: !noTotal,
+		})
+		req.Invoke(&email.Get{
+			Account: accountID,
+			ReferenceIDs: &jmap.ResultReference{
+				ResultOf: queryCallID,
+				Name:     "Email/query",
+				Path:     "/ids",
+			},
+			Properties: []string{"id", "size"},
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if len(resp.Responses) < 2 {
+			return errorResult(fmt.Errorf("missing Email/get response in query chain")), nil, nil
+		}
+
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.QueryResponse:
+			if !noTotal {
+				est.matched = args.Total
+			}
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+
+		var page []*email.Email
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			page = args.List
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+
+		for _, e := range page {
+			est.bytes += e.Size
+		}
+		est.scanned += len(page)
+		if noTotal {
+			est.matched = uint64(est.scanned)
+			if uint64(len(page)) < limit {
+				// A short page means everything was seen.
+				est.exact = true
+				break
+			}
+			continue
+		}
+		if len(page) == 0 || uint64(est.scanned) >= est.matched {
+			break
+		}
+	}
+
+	return textResult(formatEstimate(s.locale, est)), nil, nil
+}
+
+// formatEstimate renders an estimate. Bytes beyond the scanned emails are
+// extrapolated from their average size.
+func formatEstimate(l textStyle, est *estimate) string {
+	var sb strings.Builder
+	if est.exact {
+		fmt.Fprintf(&sb, "Matching emails: %d\n", est.matched)
+	} else {
+		fmt.Fprintf(&sb, "Matching emails: at least %d (the server does not report totals)\n", est.matched)
+	}
+	if est.matched == 0 {
+		sb.WriteString("Nothing to do.\n")
+		return sb.String()
+	}
+
+	bytes := est.bytes
+	if uint64(est.scanned) < est.matched && est.scanned > 0 {
+		bytes = est.bytes / uint64(est.scanned) * est.matched
+		fmt.Fprintf(&sb, "Total size: ~%s (extrapolated from the %d most recent)\n", l.size(int64(bytes)), est.scanned)
+	} else {
+		fmt.Fprintf(&sb, "Total size: %s\n", l.size(int64(bytes)))
+	}
+
+	queryCalls := ceilDiv(est.matched, est.pageSize)
+	fmt.Fprintf(&sb, "\nOperation: %s\n", est.operation)
+	fmt.Fprintf(&sb, "  Collecting IDs: %d Email/query call(s) of up to %d\n", queryCalls, est.pageSize)
+	var opCalls uint64
+	switch est.operation {
+	case "export":
+		opCalls = est.matched
+		fmt.Fprintf(&sb, "  Export: %d blob download(s), ~%s transferred\n", opCalls, l.size(int64(bytes)))
+	default:
+		opCalls = 1
+		if est.setSize > 0 {
+			opCalls = ceilDiv(est.matched, est.setSize)
+			fmt.Fprintf(&sb, "  Apply: %d Email/set call(s) of up to %d\n", opCalls, est.setSize)
+		} else {
+			sb.WriteString("  Apply: 1 Email/set call (server reports no maxObjectsInSet)\n")
+		}
+	}
+	fmt.Fprintf(&sb, "  Total: %d JMAP call(s)", queryCalls+opCalls)
+	if est.batchSize > 0 && est.operation != "export" {
+		fmt.Fprintf(&sb, ", at least %d HTTP request(s) at %d calls per request", ceilDiv(queryCalls+opCalls, est.batchSize), est.batchSize)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func ceilDiv(n, d uint64) uint64 {
+	if d == 0 {
+		return n
+	}
+	return (n + d - 1) / d
+}
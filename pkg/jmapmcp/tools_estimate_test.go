@@ -0,0 +1,67 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEmailEstimate(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "Invoice January", "", 1)
+	addEmail(fake, "e2", "Lunch?", "", 2)
+	addEmail(fake, "e3", "Invoice February", "", 3)
+	for id, size := range map[string]int{"e1": 1000, "e2": 5000, "e3": 3000} {
+		e := fake.Object("Email", id)
+		e["size"] = size
+		fake.Add("Email", e)
+	}
+
+	res, _, _ := s.handleEmailEstimate(context.Background(), nil, EmailEstimateInput{Operation: "delete", Subject: "invoice"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	for _, want := range []string{"Matching emails: 2\n", "Total size: 4000 bytes", "1 Email/set call(s) of up to 500", "Total: 2 JMAP call(s)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	for _, call := range fake.Calls() {
+		if call == "Email/set" {
+			t.Errorf("estimate changed mail: calls = %v", fake.Calls())
+		}
+	}
+
+	res, _, _ = s.handleEmailEstimate(context.Background(), nil, EmailEstimateInput{Operation: "export", Subject: "invoice", MaxEmails: 1})
+	out = resultText(res)
+	if !strings.Contains(out, "~6000 bytes (extrapolated from the 1 most recent)") || !strings.Contains(out, "2 blob download(s)") {
+		t.Errorf("unexpected export estimate:\n%s", out)
+	}
+}
+
+func TestEmailEstimateOperation(t *testing.T) {
+	s, _ := newFakeServer(t)
+	res, _, _ := s.handleEmailEstimate(context.Background(), nil, EmailEstimateInput{Operation: "archive"})
+	if !res.IsError || !strings.Contains(resultText(res), `unknown operation "archive"`) {
+		t.Errorf("expected unknown operation error, got %q", resultText(res))
+	}
+}
+
+func TestFormatEstimateBatches(t *testing.T) {
+	out := formatEstimate(textStyle{}, &estimate{
+		operation: "move",
+		matched:   1200,
+		scanned:   1200,
+		bytes:     1200 * 10,
+		exact:     true,
+		pageSize:  500,
+		setSize:   500,
+		batchSize: 4,
+	})
+	for _, want := range []string{"3 Email/query call(s)", "3 Email/set call(s)", "Total: 6 JMAP call(s), at least 2 HTTP request(s)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+}
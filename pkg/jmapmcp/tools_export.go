@@ -0,0 +1,385 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultExportBodyChars is the per-message body cap of thread_export when
+// a call sets no max_body_chars. Exports are for reading whole threads, so
+// it is well above email_get's.
+const defaultExportBodyChars = 20000
+
+// Limits of summarized exports: a thread summary is written from at most
+// exportSummaryInputChars of bodies, shared among the messages, and each
+// message summary from at most exportMessageInputChars of its body.
+const (
+	exportSummaryInputChars   = 48000
+	exportMessageInputChars   = 8000
+	exportThreadSummaryWords  = 200
+	exportMessageSummaryWords = 60
+	maxSummarizedMessages     = 50
+)
+
+// --- thread_export ---
+
+type ThreadExportInput struct {
+	ThreadID     string `json:"thread_id,omitempty" jsonschema:"ID of the thread (give this or email_id)"`
+	EmailID      string `json:"email_id,omitempty" jsonschema:"ID of any email in the thread (give this or thread_id)"`
+	Format       string `json:"format,omitempty" jsonschema:"Document format: markdown (default) or html"`
+	MaxBodyChars int    `json:"max_body_chars,omitempty" jsonschema:"Maximum characters of each message body (default 20000)"`
+	Summarize    string `json:"summarize,omitempty" jsonschema:"Have your own model summarize through sampling instead of exporting bodies: thread (one summary of the conversation at the top, bodies left out) or messages (each body replaced by its summary, at most 50 messages); needs a client that offers sampling"`
+	SaveTo       string `json:"save_to,omitempty" jsonschema:"Save the document as a file inside the client's filesystem roots instead of returning it: a new file, or an existing directory to save it in as thread-<id>.md or .html (stdio mode only)"`
+}
+
+var threadExportTool = &mcp.Tool{
+	Name:        "thread_export",
+	Description: "Export a whole conversation as one markdown or HTML document, returned as an embedded MCP resource: messages oldest first, copies of the same message (e.g. in Sent and a list folder) shown once, each with its headers, its body with quoted replies stripped, and a manifest of its attachments (name, type, size, blob ID). For archiving decisions or handing a long thread to a long-context model. With summarize, the client's model summarizes the thread or each message through sampling and the document holds the summaries instead of the bodies, keeping long text out of the conversation. With save_to, the document is written to a local file instead.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleThreadExport(ctx context.Context, req *mcp.CallToolRequest, in ThreadExportInput) (*mcp.CallToolResult, any, error) {
+	if (in.ThreadID == "") == (in.EmailID == "") {
+		return errorResult(invalidArgument("give thread_id or email_id")), nil, nil
+	}
+	format := in.Format
+	if format == "" {
+		format = "markdown"
+	}
+	if format != "markdown" && format != "html" {
+		return errorResult(invalidArgument("unknown format %q: expected markdown or html", format)), nil, nil
+	}
+	if in.SaveTo != "" && !s.localFiles {
+		return errorResult(invalidArgument("save_to is only available in stdio mode")), nil, nil
+	}
+	if in.MaxBodyChars < 0 {
+		return errorResult(invalidArgument("max_body_chars must not be negative")), nil, nil
+	}
+	switch in.Summarize {
+	case "":
+	case "thread", "messages":
+		if !canSample(req) {
+			return errorResult(invalidArgument("summarize needs an MCP client that offers sampling")), nil, nil
+		}
+	default:
+		return errorResult(invalidArgument("unknown summarize %q: expected thread or messages", in.Summarize)), nil, nil
+	}
+	maxChars := in.MaxBodyChars
+	if maxChars == 0 {
+		maxChars = defaultExportBodyChars
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	threadID, emails, err := exportThread(ctx, client, accountID, jmap.ID(in.ThreadID), jmap.ID(in.EmailID))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	emails, duplicates := dedupeThreadEmails(emails)
+	if in.Summarize == "messages" && len(emails) > maxSummarizedMessages {
+		return errorResult(invalidArgument("summarize messages takes at most %d messages and the thread has %d; summarize the thread instead", maxSummarizedMessages, len(emails))), nil, nil
+	}
+
+	messages := make([]exportMessage, len(emails))
+	for i, e := range emails {
+		body, _ := s.redactor.redact(extractBody(e, maxChars, TruncateHead, quotesStrip))
+		messages[i] = exportMessage{email: e, body: body}
+	}
+	var summary string
+	switch in.Summarize {
+	case "thread":
+		if summary, err = s.summarizeThread(ctx, req, messages); err != nil {
+			return errorResult(err), nil, nil
+		}
+		for i := range messages {
+			messages[i].body = ""
+		}
+	case "messages":
+		for i := range messages {
+			text, err := summarizeText(ctx, req, "this email", s.exportSummaryInput(messages[i], exportMessageInputChars), exportMessageSummaryWords)
+			if err != nil {
+				return errorResult(err), nil, nil
+			}
+			messages[i].body = "Summary: " + text
+		}
+	}
+
+	var doc, mimeType string
+	switch format {
+	case "html":
+		doc, mimeType = s.threadExportHTML(threadID, messages, summary), "text/html"
+	default:
+		doc, mimeType = s.threadExportMarkdown(threadID, messages, summary), "text/markdown"
+	}
+
+	ext := map[string]string{"markdown": "md", "html": "html"}[format]
+	uri := fmt.Sprintf("jmap://%s/thread/%s/export.%s", accountID, threadID, ext)
+	result := fmt.Sprintf("Exported thread %s as %s: %d message(s)", threadID, format, len(messages))
+	if duplicates > 0 {
+		result += fmt.Sprintf(", %d duplicate copy(ies) skipped", duplicates)
+	}
+	switch in.Summarize {
+	case "thread":
+		result += ", summarized as a whole by your model"
+	case "messages":
+		result += ", each summarized by your model"
+	}
+	if in.SaveTo != "" {
+		path, err := s.writeLocalFile(ctx, req, in.SaveTo, fmt.Sprintf("thread-%s.%s", threadID, ext), []byte(doc))
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		return textResult(fmt.Sprintf("%s; saved to %s", result, path)), nil, nil
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: result},
+			&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: uri, MIMEType: mimeType, Text: doc}},
+		},
+	}, nil, nil
+}
+
+// exportMessage is one email of an export with its rendered body.
+type exportMessage struct {
+	email *email.Email
+	body  string
+}
+
+// summarizeThread has the client's model summarize the conversation of
+// messages, oldest first, from at most exportSummaryInputChars of bodies.
+func (s *Server) summarizeThread(ctx context.Context, req *mcp.CallToolRequest, messages []exportMessage) (string, error) {
+	limit := max(500, exportSummaryInputChars/max(1, len(messages)))
+	var sb strings.Builder
+	for i, m := range messages {
+		fmt.Fprintf(&sb, "--- Message %d\n%s\n\n", i+1, s.exportSummaryInput(m, limit))
+	}
+	return summarizeText(ctx, req, fmt.Sprintf("this email conversation of %d message(s)", len(messages)), sb.String(), exportThreadSummaryWords)
+}
+
+// exportSummaryInput is what the model sees of m: its sender, date,
+// subject, and at most limit characters of its body.
+func (s *Server) exportSummaryInput(m exportMessage, limit int) string {
+	e := m.email
+	return fmt.Sprintf("From: %s\nDate: %s\nSubject: %s\n\n%s", formatAddresses(e.From), s.locale.dateTime(exportDate(e)), e.Subject, TruncateBody(m.body, limit))
+}
+
+// exportThread fetches the emails of a thread, given directly or through
+// one of its emails, with their bodies and attachments.
+func exportThread(ctx context.Context, client JMAPClient, accountID, threadID, emailID jmap.ID) (jmap.ID, []*email.Email, error) {
+	req := &jmap.Request{Context: ctx}
+	get := &thread.Get{Account: accountID, IDs: []jmap.ID{threadID}}
+	if emailID != "" {
+		anchorCallID := req.Invoke(&email.Get{
+			Account:    accountID,
+			IDs:        []jmap.ID{emailID},
+			Properties: []string{"id", "threadId"},
+		})
+		get = &thread.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: anchorCallID, Name: "Email/get", Path: "/list/*/threadId"},
+		}
+	}
+	threadCallID := req.Invoke(get)
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: threadCallID, Name: "Thread/get", Path: "/list/*/emailIds"},
+		Properties: []string{
+			"id", "messageId", "subject", "from", "to", "cc", "sentAt", "receivedAt",
+			"bodyValues", "textBody", "htmlBody", "attachments",
+		},
+		FetchTextBodyValues: true,
+		FetchHTMLBodyValues: true,
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	responses := resp.Responses
+	if emailID != "" {
+		if len(responses) == 0 {
+			return "", nil, fmt.Errorf("empty response for Email/get")
+		}
+		switch args := responses[0].Args.(type) {
+		case *email.GetResponse:
+			if len(args.List) == 0 {
+				return "", nil, notFoundError([]jmap.ID{emailID}, "email %s not found", emailID)
+			}
+		case *jmap.MethodError:
+			return "", nil, args
+		default:
+			return "", nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+		responses = responses[1:]
+	}
+	if len(responses) < 2 {
+		return "", nil, fmt.Errorf("missing Email/get response in thread chain")
+	}
+
+	switch args := responses[0].Args.(type) {
+	case *thread.GetResponse:
+		if len(args.List) == 0 {
+			return "", nil, notFoundError([]jmap.ID{threadID}, "thread %s not found", threadID)
+		}
+		threadID = args.List[0].ID
+	case *jmap.MethodError:
+		return "", nil, args
+	default:
+		return "", nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	switch args := responses[1].Args.(type) {
+	case *email.GetResponse:
+		return threadID, args.List, nil
+	case *jmap.MethodError:
+		return "", nil, args
+	default:
+		return "", nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// dedupeThreadEmails sorts emails oldest first and drops later copies of a
+// message already seen, by Message-ID, returning how many it dropped.
+// Emails without a Message-ID are all kept.
+func dedupeThreadEmails(emails []*email.Email) ([]*email.Email, int) {
+	slices.SortStableFunc(emails, func(a, b *email.Email) int {
+		return receivedAt(a).Compare(receivedAt(b))
+	})
+	seen := make(map[string]bool)
+	kept := emails[:0:0]
+	for _, e := range emails {
+		if len(e.MessageID) > 0 {
+			if seen[e.MessageID[0]] {
+				continue
+			}
+			seen[e.MessageID[0]] = true
+		}
+		kept = append(kept, e)
+	}
+	return kept, len(emails) - len(kept)
+}
+
+// exportSubject returns the subject of a thread export: its first
+// message's.
+func exportSubject(messages []exportMessage) string {
+	if len(messages) > 0 && messages[0].email.Subject != "" {
+		return messages[0].email.Subject
+	}
+	return "(no subject)"
+}
+
+// exportDate returns when an exported message was sent, falling back to
+// when it was received.
+func exportDate(e *email.Email) time.Time {
+	if e.SentAt != nil {
+		return *e.SentAt
+	}
+	return receivedAt(e)
+}
+
+// threadExportMarkdown renders a thread export as a Markdown document,
+// opening with summary when there is one.
+func (s *Server) threadExportMarkdown(threadID jmap.ID, messages []exportMessage, summary string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", exportSubject(messages))
+	fmt.Fprintf(&sb, "Thread %s, %d message(s)", threadID, len(messages))
+	if len(messages) > 0 {
+		first, last := messages[0].email, messages[len(messages)-1].email
+		fmt.Fprintf(&sb, ", %s", s.locale.rangeText(s.locale.date(exportDate(first)), s.locale.date(exportDate(last))))
+	}
+	sb.WriteString("\n")
+	if summary != "" {
+		fmt.Fprintf(&sb, "\n## Summary\n\n%s\n", summary)
+	}
+
+	for i, m := range messages {
+		e := m.email
+		fmt.Fprintf(&sb, "\n---\n\n## %d. %s\n\n", i+1, formatAddresses(e.From))
+		fmt.Fprintf(&sb, "- Date: %s\n", s.locale.dateTime(exportDate(e)))
+		if len(e.To) > 0 {
+			fmt.Fprintf(&sb, "- To: %s\n", formatAddresses(e.To))
+		}
+		if len(e.CC) > 0 {
+			fmt.Fprintf(&sb, "- CC: %s\n", formatAddresses(e.CC))
+		}
+		fmt.Fprintf(&sb, "- Subject: %s\n", e.Subject)
+		fmt.Fprintf(&sb, "- ID: %s\n", e.ID)
+		if body := strings.TrimSpace(m.body); body != "" {
+			fmt.Fprintf(&sb, "\n%s\n", body)
+		}
+		if len(e.Attachments) > 0 {
+			sb.WriteString("\nAttachments:\n\n")
+			for _, part := range e.Attachments {
+				fmt.Fprintf(&sb, "- %s\n", s.exportAttachment(part))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// threadExportHTML renders a thread export as a standalone HTML document.
+// Bodies and the summary are the same plain text as in the Markdown
+// export, escaped.
+func (s *Server) threadExportHTML(threadID jmap.ID, messages []exportMessage, summary string) string {
+	subject := html.EscapeString(exportSubject(messages))
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title></head><body>\n", subject)
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n<p>Thread %s, %d message(s)</p>\n", subject, html.EscapeString(string(threadID)), len(messages))
+	if summary != "" {
+		fmt.Fprintf(&sb, "<h2>Summary</h2>\n<p style=\"white-space: pre-wrap\">%s</p>\n", html.EscapeString(summary))
+	}
+
+	for i, m := range messages {
+		e := m.email
+		fmt.Fprintf(&sb, "<hr>\n<h2>%d. %s</h2>\n<table class=\"email-headers\">\n", i+1, html.EscapeString(formatAddresses(e.From)))
+		row := func(name, value string) {
+			if value != "" {
+				fmt.Fprintf(&sb, "<tr><th align=\"left\">%s</th><td>%s</td></tr>\n", name, html.EscapeString(value))
+			}
+		}
+		row("Date", s.locale.dateTime(exportDate(e)))
+		row("To", formatAddresses(e.To))
+		row("CC", formatAddresses(e.CC))
+		row("Subject", e.Subject)
+		row("ID", string(e.ID))
+		sb.WriteString("</table>\n")
+		if body := strings.TrimSpace(m.body); body != "" {
+			fmt.Fprintf(&sb, "<pre style=\"white-space: pre-wrap\">%s</pre>\n", html.EscapeString(body))
+		}
+		if len(e.Attachments) > 0 {
+			sb.WriteString("<p>Attachments:</p>\n<ul>\n")
+			for _, part := range e.Attachments {
+				fmt.Fprintf(&sb, "<li>%s</li>\n", html.EscapeString(s.exportAttachment(part)))
+			}
+			sb.WriteString("</ul>\n")
+		}
+	}
+	sb.WriteString("</body></html>\n")
+	return sb.String()
+}
+
+// exportAttachment describes an attachment in an export's manifest.
+func (s *Server) exportAttachment(part *email.BodyPart) string {
+	name := part.Name
+	if name == "" {
+		name = "(unnamed)"
+	}
+	return fmt.Sprintf("%s (%s, %s) [blob: %s]", name, part.Type, s.locale.size(int64(part.Size)), part.BlobID)
+}
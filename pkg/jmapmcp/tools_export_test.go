@@ -0,0 +1,130 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestThreadExport(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Email", map[string]any{
+		"id": "e1", "threadId": "T1", "messageId": []any{"a@example.org"}, "subject": "Budget",
+		"receivedAt": "2025-01-02T10:00:00Z",
+		"from":       []any{map[string]any{"name": "Alice", "email": "alice@example.org"}},
+		"to":         []any{map[string]any{"email": "me@example.com"}},
+		"textBody":   []any{map[string]any{"partId": "1", "type": "text/plain"}},
+		"bodyValues": map[string]any{"1": map[string]any{"value": "Can we approve <the> budget?"}},
+		"attachments": []any{map[string]any{
+			"blobId": "B1", "name": "budget.xlsx", "type": "application/vnd.ms-excel", "size": 2048,
+		}},
+	})
+	reply := map[string]any{
+		"id": "e2", "threadId": "T1", "messageId": []any{"b@example.com"}, "subject": "Re: Budget",
+		"receivedAt": "2025-01-03T10:00:00Z",
+		"from":       []any{map[string]any{"email": "me@example.com"}},
+		"textBody":   []any{map[string]any{"partId": "1", "type": "text/plain"}},
+		"bodyValues": map[string]any{"1": map[string]any{"value": "Approved.\n\nOn Thu, Jan 2, 2025 at 10:00 AM Alice <alice@example.org> wrote:\n> Can we approve the budget?"}},
+	}
+	fake.Add("Email", reply)
+	copied := map[string]any{}
+	for k, v := range reply {
+		copied[k] = v
+	}
+	copied["id"] = "e3"
+	fake.Add("Email", copied)
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e2", "e1", "e3"}})
+	ctx := context.Background()
+
+	res, _, _ := s.handleThreadExport(ctx, nil, ThreadExportInput{EmailID: "e2"})
+	if res.IsError {
+		t.Fatalf("thread_export: %s", resultText(res))
+	}
+	if got := res.Content[0].(*mcp.TextContent).Text; got != "Exported thread T1 as markdown: 2 message(s), 1 duplicate copy(ies) skipped" {
+		t.Errorf("summary = %q", got)
+	}
+	resource := res.Content[1].(*mcp.EmbeddedResource).Resource
+	if resource.MIMEType != "text/markdown" || !strings.HasSuffix(resource.URI, "/thread/T1/export.md") {
+		t.Errorf("resource %s (%s)", resource.URI, resource.MIMEType)
+	}
+	doc := resource.Text
+	for _, want := range []string{
+		"# Budget\n",
+		"## 1. Alice <alice@example.org>",
+		"- budget.xlsx (application/vnd.ms-excel, 2048 bytes) [blob: B1]",
+		"## 2. me@example.com",
+		"Approved.",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("document lacks %q:\n%s", want, doc)
+		}
+	}
+	if strings.Index(doc, "## 1.") > strings.Index(doc, "## 2.") || strings.Contains(doc, "> Can we approve") || strings.Contains(doc, "## 3.") {
+		t.Errorf("order, quotes, or duplicates:\n%s", doc)
+	}
+
+	res, _, _ = s.handleThreadExport(ctx, nil, ThreadExportInput{ThreadID: "T1", Format: "html"})
+	resource = res.Content[1].(*mcp.EmbeddedResource).Resource
+	if resource.MIMEType != "text/html" || !strings.Contains(resource.Text, "Can we approve &lt;the&gt; budget?") {
+		t.Errorf("html export (%s):\n%s", resource.MIMEType, resource.Text)
+	}
+
+	res, _, _ = s.handleThreadExport(ctx, nil, ThreadExportInput{ThreadID: "T1", Format: "pdf"})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeInvalidArguments {
+		t.Errorf("bad format: %s", resultText(res))
+	}
+}
+
+func TestThreadExportSummarize(t *testing.T) {
+	s, fake := newFakeServer(t)
+	for i, body := range []string{"Can we approve the budget?", "Approved."} {
+		id := fmt.Sprintf("e%d", i+1)
+		fake.Add("Email", map[string]any{
+			"id": id, "threadId": "T1", "subject": "Budget", "receivedAt": fmt.Sprintf("2025-01-0%dT10:00:00Z", i+2),
+			"from":       []any{map[string]any{"email": "alice@example.org"}},
+			"textBody":   []any{map[string]any{"partId": "1", "type": "text/plain"}},
+			"bodyValues": map[string]any{"1": map[string]any{"value": body}},
+		})
+	}
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1", "e2"}})
+	ctx := context.Background()
+
+	if res, _, _ := s.handleThreadExport(ctx, nil, ThreadExportInput{ThreadID: "T1", Summarize: "thread"}); !res.IsError {
+		t.Errorf("summarized without sampling: %s", resultText(res))
+	}
+
+	var prompts []string
+	cs := samplingSession(t, s, func(p string) string {
+		prompts = append(prompts, p)
+		if strings.Contains(p, "conversation") {
+			return "Alice asked for the budget and approved it."
+		}
+		return "Short."
+	})
+	export := func(summarize string) (string, string) {
+		t.Helper()
+		res, err := cs.CallTool(ctx, &mcp.CallToolParams{Name: "thread_export", Arguments: map[string]any{"thread_id": "T1", "summarize": summarize}})
+		if err != nil || res.IsError {
+			t.Fatalf("thread_export %s: %v %s", summarize, err, resultText(res))
+		}
+		return res.Content[0].(*mcp.TextContent).Text, res.Content[1].(*mcp.EmbeddedResource).Resource.Text
+	}
+
+	text, doc := export("thread")
+	if !strings.HasSuffix(text, ", summarized as a whole by your model") ||
+		!strings.Contains(doc, "## Summary\n\nAlice asked for the budget and approved it.\n") || strings.Contains(doc, "Approved.") {
+		t.Errorf("thread summary %q:\n%s", text, doc)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "--- Message 2\nFrom: alice@example.org") || !strings.Contains(prompts[0], "Approved.") {
+		t.Errorf("prompts = %q", prompts)
+	}
+
+	prompts = nil
+	_, doc = export("messages")
+	if strings.Count(doc, "Summary: Short.") != 2 || strings.Contains(doc, "Can we approve") || len(prompts) != 2 {
+		t.Errorf("message summaries (%d prompts):\n%s", len(prompts), doc)
+	}
+}
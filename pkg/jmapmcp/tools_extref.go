@@ -0,0 +1,238 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// External references are stored as keywords "ref:<system>:<id>", so they
+// stay on the server with the email, never travel with a message that is
+// sent or forwarded, and can be searched with hasKeyword. Keywords are
+// case-insensitive, so references are lowercased.
+const extRefPrefix = "ref:"
+
+const (
+	defaultExtRefLimit = 20
+	maxExtRefLimit     = 100
+)
+
+// extRefRE is what a reference must look like to be stored as a keyword:
+// no characters JMAP keywords or JSON patch paths cannot carry.
+var extRefRE = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}:[a-z0-9][a-z0-9._#-]{0,63}$`)
+
+// parseExtRef normalizes a "system:id" reference and returns its keyword.
+func parseExtRef(ref string) (string, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if !extRefRE.MatchString(ref) {
+		return "", invalidArgument("reference %q: want system:id, e.g. jira:PROJ-123 or zendesk:4521 (letters, digits, '.', '_', '#', '-')", ref)
+	}
+	return extRefPrefix + ref, nil
+}
+
+// emailExtRefs returns the references on e, sorted.
+func emailExtRefs(e *email.Email) []string {
+	var refs []string
+	for kw, set := range e.Keywords {
+		if ref, ok := strings.CutPrefix(strings.ToLower(kw), extRefPrefix); ok && set {
+			refs = append(refs, ref)
+		}
+	}
+	slices.Sort(refs)
+	return refs
+}
+
+// --- email_ref_link ---
+
+type EmailRefLinkInput struct {
+	EmailIDs []string `json:"email_ids" jsonschema:"IDs of the emails to link"`
+	Refs     []string `json:"refs" jsonschema:"External references as system:id, e.g. jira:PROJ-123 or zendesk:4521 (stored lowercase)"`
+	Unlink   bool     `json:"unlink,omitempty" jsonschema:"Remove the references instead of adding them"`
+}
+
+var emailRefLinkTool = &mcp.Tool{
+	Name:        "email_ref_link",
+	Description: "Link emails to external records (tickets, issues, CRM cases) by tagging them with references like jira:PROJ-123, or remove such links with unlink. References are stored as keywords on the mail server, so they are never sent to recipients; find the emails of a reference later with email_ref_query.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleEmailRefLink(ctx context.Context, _ *mcp.CallToolRequest, in EmailRefLinkInput) (*mcp.CallToolResult, any, error) {
+	if len(in.EmailIDs) == 0 {
+		return errorResult(invalidArgument("email_ids is required")), nil, nil
+	}
+	if len(in.Refs) == 0 {
+		return errorResult(invalidArgument("refs is required")), nil, nil
+	}
+	patch := jmap.Patch{}
+	var refs []string
+	for _, r := range in.Refs {
+		kw, err := parseExtRef(r)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		set := !in.Unlink
+		applyKeyword(patch, "keywords/"+kw, &set)
+		refs = append(refs, strings.TrimPrefix(kw, extRefPrefix))
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	updates := make(map[jmap.ID]jmap.Patch, len(in.EmailIDs))
+	for _, id := range in.EmailIDs {
+		updates[jmap.ID(id)] = patch
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Set{Account: accountID, Update: updates})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/set")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		if err := setFailures("linking references failed", args.NotUpdated); err != nil {
+			return errorResult(err), nil, nil
+		}
+		if in.Unlink {
+			return textResult(fmt.Sprintf("Unlinked %s from %d email(s)", strings.Join(refs, ", "), len(in.EmailIDs))), nil, nil
+		}
+		return textResult(fmt.Sprintf("Linked %d email(s) to %s", len(in.EmailIDs), strings.Join(refs, ", "))), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// --- email_ref_query ---
+
+type EmailRefQueryInput struct {
+	Ref      string   `json:"ref,omitempty" jsonschema:"External reference (system:id) to find the linked emails of, newest first"`
+	EmailIDs []string `json:"email_ids,omitempty" jsonschema:"Instead of ref: emails whose references to list"`
+	Limit    int      `json:"limit,omitempty" jsonschema:"Maximum number of emails to return for ref (default 20, max 100)"`
+}
+
+var emailRefQueryTool = &mcp.Tool{
+	Name:        "email_ref_query",
+	Description: "Find the emails linked to an external reference (e.g. jira:PROJ-123) with email_ref_link, newest first with thread IDs, or list the references of given emails.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailRefQuery(ctx context.Context, _ *mcp.CallToolRequest, in EmailRefQueryInput) (*mcp.CallToolResult, any, error) {
+	if (in.Ref == "") == (len(in.EmailIDs) == 0) {
+		return errorResult(invalidArgument("give either ref or email_ids")), nil, nil
+	}
+	var keyword string
+	if in.Ref != "" {
+		var err error
+		if keyword, err = parseExtRef(in.Ref); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultExtRefLimit
+	}
+	limit = min(limit, maxExtRefLimit)
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	properties := []string{"id", "threadId", "subject", "from", "receivedAt", "keywords"}
+	req := &jmap.Request{Context: ctx}
+	if keyword != "" {
+		queryCallID := req.Invoke(&email.Query{
+			Account:        accountID,
+			Filter:         &email.FilterCondition{HasKeyword: keyword},
+			Sort:           []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Limit:          uint64(limit),
+			CalculateTotal: !s.quirksFor(client).has(quirkNoCalculateTotal),
+		})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+			Properties:   properties,
+		})
+	} else {
+		req.Invoke(&email.Get{Account: accountID, IDs: toJMAPIDSlice(in.EmailIDs), Properties: properties})
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	var total uint64
+	var list []*email.Email
+	for _, inv := range callResponses(req, resp) {
+		switch args := inv.Args.(type) {
+		case *email.QueryResponse:
+			total = args.Total
+		case *email.GetResponse:
+			if keyword == "" && len(args.NotFound) > 0 {
+				return errorResult(notFoundError(args.NotFound, "emails not found: %v", args.NotFound)), nil, nil
+			}
+			list = args.List
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+	}
+
+	var sb strings.Builder
+	if keyword != "" {
+		ref := strings.TrimPrefix(keyword, extRefPrefix)
+		if len(list) == 0 {
+			return textResult(fmt.Sprintf("No emails linked to %s", ref)), nil, nil
+		}
+		if total > 0 {
+			fmt.Fprintf(&sb, "Emails linked to %s: %d (returning %d)\n\n", ref, total, len(list))
+		} else {
+			fmt.Fprintf(&sb, "Emails linked to %s (returning %d)\n\n", ref, len(list))
+		}
+	}
+	for _, e := range list {
+		parts := []string{string(e.ID), "thread " + string(e.ThreadID)}
+		if e.ReceivedAt != nil {
+			parts = append(parts, s.locale.dateTime(*e.ReceivedAt))
+		}
+		if len(e.From) > 0 {
+			parts = append(parts, formatAddresses(e.From))
+		}
+		parts = append(parts, e.Subject)
+		fmt.Fprintf(&sb, "%s\n", strings.Join(parts, "  "))
+		if keyword == "" {
+			if refs := emailExtRefs(e); len(refs) > 0 {
+				fmt.Fprintf(&sb, "  Refs: %s\n", strings.Join(refs, ", "))
+			} else {
+				sb.WriteString("  Refs: none\n")
+			}
+		}
+	}
+	return textResult(sb.String()), nil, nil
+}
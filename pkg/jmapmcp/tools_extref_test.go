@@ -0,0 +1,50 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEmailRefLinkAndQuery(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	addEmail(fake, "e1", "Printer broken", "", 1)
+	addEmail(fake, "e2", "Re: Printer broken", "", 2)
+	addEmail(fake, "e3", "Lunch", "", 3)
+
+	res, _, _ := s.handleEmailRefLink(ctx, nil, EmailRefLinkInput{EmailIDs: []string{"e1", "e2"}, Refs: []string{"JIRA:OPS-42"}})
+	if out := resultText(res); res.IsError || out != "Linked 2 email(s) to jira:ops-42" {
+		t.Fatalf("link: %s", out)
+	}
+	if kw := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["ref:jira:ops-42"] != true {
+		t.Errorf("keywords after link = %v", kw)
+	}
+
+	res, _, _ = s.handleEmailRefQuery(ctx, nil, EmailRefQueryInput{Ref: "jira:ops-42"})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Emails linked to jira:ops-42: 2 (returning 2)") || strings.Contains(out, "Lunch") ||
+		strings.Index(out, "e2") > strings.Index(out, "e1") {
+		t.Errorf("query by ref:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailRefQuery(ctx, nil, EmailRefQueryInput{EmailIDs: []string{"e1", "e3"}})
+	if out := resultText(res); !strings.Contains(out, "Refs: jira:ops-42") || !strings.Contains(out, "Refs: none") {
+		t.Errorf("refs of emails:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailRefLink(ctx, nil, EmailRefLinkInput{EmailIDs: []string{"e1"}, Refs: []string{"jira:ops-42"}, Unlink: true})
+	if res.IsError {
+		t.Fatalf("unlink: %s", resultText(res))
+	}
+	if kw := fake.Object("Email", "e1")["keywords"].(map[string]any); kw["ref:jira:ops-42"] != nil {
+		t.Errorf("keywords after unlink = %v", kw)
+	}
+
+	for _, ref := range []string{"PROJ-123", "jira:a/b", "jira:"} {
+		res, _, _ = s.handleEmailRefLink(ctx, nil, EmailRefLinkInput{EmailIDs: []string{"e1"}, Refs: []string{ref}})
+		if !res.IsError {
+			t.Errorf("malformed reference %q accepted", ref)
+		}
+	}
+}
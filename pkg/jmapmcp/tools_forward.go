@@ -0,0 +1,335 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_forward ---
+
+type EmailForwardInput struct {
+	EmailID         string   `json:"email_id" jsonschema:"ID of the email to forward"`
+	To              []string `json:"to" jsonschema:"Recipient email addresses, contact group names, or RFC 5322 address groups"`
+	CC              []string `json:"cc,omitempty" jsonschema:"CC email addresses, contact group names, or RFC 5322 address groups"`
+	BCC             []string `json:"bcc,omitempty" jsonschema:"BCC email addresses, contact group names, or RFC 5322 address groups"`
+	Body            string   `json:"body,omitempty" jsonschema:"Plain text note placed above the forwarded message"`
+	OmitAttachments bool     `json:"omit_attachments,omitempty" jsonschema:"Do not carry over the original attachments (default false)"`
+	AsAttachment    bool     `json:"as_attachment,omitempty" jsonschema:"Attach the original unchanged as a message/rfc822 part instead of quoting it inline, as abuse desks and IT departments ask for (default false)"`
+	Importance      string   `json:"importance,omitempty" jsonschema:"Mark the message high or low importance: sets X-Priority and Importance headers, and high also sets the $important keyword (default normal)"`
+}
+
+var emailForwardTool = &mcp.Tool{
+	Name:        "email_forward",
+	Description: "Create a draft forwarding an email inline, with an optional note and the original attachments (subject prefixed with Fwd:); with as_attachment the original message is attached whole as message/rfc822, headers included, and the body is only the note. Contact group names among the recipients are expanded to their members when the server supports JMAP Contacts, and RFC 5322 address groups to the addresses they list. Attachments are checked against the server's size limits and the operator's attachment policy. Returns the draft ID for email_submission_set.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailForward(ctx context.Context, _ *mcp.CallToolRequest, in EmailForwardInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	if len(in.To) == 0 {
+		return errorResult(invalidArgument("to is required")), nil, nil
+	}
+	if in.AsAttachment && in.OmitAttachments {
+		return errorResult(invalidArgument("omit_attachments does not apply with as_attachment: the original is attached whole")), nil, nil
+	}
+	if err := checkImportance(in.Importance); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	fwd, err := s.createForward(ctx, client, in)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	var sb strings.Builder
+	if fwd.id != "" {
+		fmt.Fprintf(&sb, "Created forward draft [id: %s]\n", fwd.id)
+	} else {
+		sb.WriteString("Created forward draft\n")
+	}
+	fmt.Fprintf(&sb, "To: %s\n", formatAddresses(fwd.draft.To))
+	fmt.Fprintf(&sb, "Subject: %s\n", fwd.draft.Subject)
+	fmt.Fprintf(&sb, "Attachments: %d\n", len(fwd.draft.Attachments))
+	if len(fwd.groups) > 0 {
+		sb.WriteString(formatAddressGroups(fwd.groups) + "\n")
+	}
+	if len(fwd.expanded) > 0 {
+		sb.WriteString(formatGroupExpansions(fwd.expanded) + "\n")
+	}
+	sb.WriteString(formatDraftDefaults(fwd.added))
+	return textResult(sb.String()), nil, nil
+}
+
+// forwardDraft is a forward draft createForward created.
+type forwardDraft struct {
+	id       jmap.ID // empty when the server did not report it
+	draft    *email.Email
+	groups   []addressGroup   // address groups among the recipients
+	expanded []groupExpansion // contact groups among the recipients
+	added    []string         // recipients the draft defaults added
+}
+
+// createForward creates the draft forwarding in.EmailID as in describes,
+// checking the send and attachment policies.
+func (s *Server) createForward(ctx context.Context, client JMAPClient, in EmailForwardInput) (*forwardDraft, error) {
+	groups, err := expandAddressGroups(&in.To, &in.CC, &in.BCC)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := expandContactGroups(ctx, client, &in.To, &in.CC, &in.BCC)
+	if err != nil {
+		return nil, err
+	}
+	recipients := toMailAddresses(append(append(append([]string(nil), in.To...), in.CC...), in.BCC...))
+	if err := s.sendPolicy.checkRecipients(recipients); err != nil {
+		return nil, err
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return nil, fmt.Errorf("no primary mail account")
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account: accountID,
+		IDs:     []jmap.ID{jmap.ID(in.EmailID)},
+		Properties: []string{
+			"id", "subject", "from", "to", "cc", "sentAt", "receivedAt",
+			"messageId", "references", "bodyValues", "textBody", "htmlBody", "attachments",
+			"blobId", "size",
+		},
+		FetchTextBodyValues: true,
+		FetchHTMLBodyValues: true,
+	})
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "role"}})
+	req.Invoke(&identity.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Responses) < 3 {
+		return nil, fmt.Errorf("expected 3 discovery responses, got %d", len(resp.Responses))
+	}
+
+	var original *email.Email
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return nil, notFoundError([]string{in.EmailID}, "email not found: %s", in.EmailID)
+		}
+		original = args.List[0]
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	var draftsID jmap.ID
+	switch args := resp.Responses[1].Args.(type) {
+	case *mailbox.GetResponse:
+		draftsID = draftsMailbox(args.List)
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected mailbox response type: %T", args)
+	}
+	if draftsID == "" {
+		return nil, fmt.Errorf("no mailbox with role %q found", mailbox.RoleDrafts)
+	}
+
+	// Servers without Identity/get leave the draft without identity defaults.
+	var id *identity.Identity
+	switch args := resp.Responses[2].Args.(type) {
+	case *identity.GetResponse:
+		if id, err = s.sendPolicy.senderIdentity(args.List); err != nil {
+			return nil, err
+		}
+	case *jmap.MethodError:
+	default:
+		return nil, fmt.Errorf("unexpected identity response type: %T", args)
+	}
+
+	var attachments []*email.BodyPart
+	body := forwardBody(in.Body, original)
+	switch {
+	case in.AsAttachment:
+		// The message blob is the original RFC 5322 message, which the
+		// server can attach by reference like any other blob.
+		if original.BlobID == "" {
+			return nil, fmt.Errorf("the server did not report the blob of email %s", in.EmailID)
+		}
+		part := &email.BodyPart{
+			BlobID:      original.BlobID,
+			Name:        forwardAttachmentName(original.Subject),
+			Type:        "message/rfc822",
+			Disposition: "attachment",
+		}
+		if err := s.attachmentPolicy.check(part.Name, part.Type, int(original.Size)); err != nil {
+			return nil, err
+		}
+		if err := checkAttachmentsSize(client.Session(), accountID, []int{int(original.Size)}); err != nil {
+			return nil, err
+		}
+		attachments = []*email.BodyPart{part}
+		body = in.Body
+		if body == "" {
+			body = "The forwarded message is attached."
+		}
+	case !in.OmitAttachments:
+		sizes := make([]int, 0, len(original.Attachments))
+		for _, part := range original.Attachments {
+			if err := s.attachmentPolicy.check(part.Name, part.Type, int(part.Size)); err != nil {
+				return nil, fmt.Errorf("%w (set omit_attachments to forward without them)", err)
+			}
+			sizes = append(sizes, int(part.Size))
+			attachments = append(attachments, &email.BodyPart{
+				BlobID:      part.BlobID,
+				Name:        part.Name,
+				Type:        part.Type,
+				Disposition: "attachment",
+			})
+		}
+		if err := checkAttachmentsSize(client.Session(), accountID, sizes); err != nil {
+			return nil, err
+		}
+	}
+
+	draft := &email.Email{
+		MailboxIDs: map[jmap.ID]bool{draftsID: true},
+		Keywords:   map[string]bool{"$draft": true},
+		To:         toMailAddresses(in.To),
+		CC:         toMailAddresses(in.CC),
+		BCC:        toMailAddresses(in.BCC),
+		Subject:    forwardSubject(original.Subject),
+		References: append(append([]string(nil), original.References...), original.MessageID...),
+		BodyValues: map[string]*email.BodyValue{
+			"body": {Value: body},
+		},
+		TextBody: []*email.BodyPart{
+			{PartID: "body", Type: "text/plain"},
+		},
+		Attachments: attachments,
+	}
+	applyImportance(draft, in.Importance)
+
+	added := s.applyDraftDefaults(draft, id)
+	if len(added) > 0 {
+		if err := s.sendPolicy.checkRecipients(draftRecipients(draft)); err != nil {
+			return nil, err
+		}
+	}
+
+	setReq := &jmap.Request{Context: ctx}
+	setReq.Invoke(&email.Set{
+		Account: accountID,
+		Create:  map[jmap.ID]*email.Email{"draft": draft},
+	})
+
+	setResp, err := client.Do(setReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(setResp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Email/set")
+	}
+
+	switch args := setResp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		if se, ok := args.NotCreated["draft"]; ok {
+			return nil, setFailure("forward draft creation failed", se)
+		}
+		fwd := &forwardDraft{draft: draft, groups: groups, expanded: expanded, added: added}
+		if created, ok := args.Created["draft"]; ok {
+			fwd.id = created.ID
+			s.recordDraftOrigin(ctx, created.ID, original.ID, "$forwarded")
+		}
+		return fwd, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// forwardSubject prefixes subject with "Fwd: " unless it already carries a
+// forward prefix.
+func forwardSubject(subject string) string {
+	lower := strings.ToLower(strings.TrimSpace(subject))
+	if strings.HasPrefix(lower, "fwd:") || strings.HasPrefix(lower, "fw:") {
+		return subject
+	}
+	return "Fwd: " + subject
+}
+
+// forwardAttachmentName names the message/rfc822 part of a forward sent
+// as an attachment after the original subject.
+func forwardAttachmentName(subject string) string {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return "message.eml"
+	}
+	return sanitizeFilename(subject) + ".eml"
+}
+
+// forwardBody renders the note followed by the original message's headers
+// and full text (HTML converted to text when there is no text part).
+func forwardBody(note string, original *email.Email) string {
+	var sb strings.Builder
+	if note != "" {
+		sb.WriteString(note)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("---------- Forwarded message ----------\n")
+	fmt.Fprintf(&sb, "From: %s\n", formatAddresses(original.From))
+	date := original.SentAt
+	if date == nil {
+		date = original.ReceivedAt
+	}
+	if date != nil {
+		fmt.Fprintf(&sb, "Date: %s\n", date.Format(time.RFC1123Z))
+	}
+	fmt.Fprintf(&sb, "Subject: %s\n", original.Subject)
+	fmt.Fprintf(&sb, "To: %s\n", formatAddresses(original.To))
+	if len(original.CC) > 0 {
+		fmt.Fprintf(&sb, "CC: %s\n", formatAddresses(original.CC))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(originalText(original))
+	return sb.String()
+}
+
+// originalText returns the full text of a message being forwarded or
+// quoted: its plain text part, or its HTML converted to text when it has
+// none.
+func originalText(original *email.Email) string {
+	for _, part := range original.TextBody {
+		if bv, ok := original.BodyValues[part.PartID]; ok && part.Type == "text/plain" {
+			return bv.Value
+		}
+	}
+	for _, part := range original.HTMLBody {
+		if bv, ok := original.BodyValues[part.PartID]; ok {
+			return htmlToText(SanitizeHTML(bv.Value))
+		}
+	}
+	return ""
+}
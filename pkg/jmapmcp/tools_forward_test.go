@@ -0,0 +1,99 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestForwardSubject(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Quarterly report", "Fwd: Quarterly report"},
+		{"Fwd: Quarterly report", "Fwd: Quarterly report"},
+		{"FW: Quarterly report", "FW: Quarterly report"},
+		{"Re: Quarterly report", "Fwd: Re: Quarterly report"},
+	}
+	for _, tt := range tests {
+		if got := forwardSubject(tt.in); got != tt.want {
+			t.Errorf("forwardSubject(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestForwardBody(t *testing.T) {
+	original := &email.Email{
+		Subject:    "Plans",
+		From:       addrs("alice@example.com"),
+		To:         addrs("bob@example.com"),
+		TextBody:   []*email.BodyPart{{PartID: "1", Type: "text/plain"}},
+		BodyValues: map[string]*email.BodyValue{"1": {Value: "See you at noon."}},
+	}
+
+	got := forwardBody("FYI", original)
+	for _, want := range []string{
+		"FYI\n\n---------- Forwarded message ----------\n",
+		"From: alice@example.com\n",
+		"Subject: Plans\n",
+		"To: bob@example.com\n",
+		"\nSee you at noon.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("forwardBody missing %q in:\n%s", want, got)
+		}
+	}
+
+	htmlOnly := &email.Email{
+		Subject:    "Promo",
+		HTMLBody:   []*email.BodyPart{{PartID: "1", Type: "text/html"}},
+		BodyValues: map[string]*email.BodyValue{"1": {Value: "<p>Hello<script>x()</script></p>"}},
+	}
+	got = forwardBody("", htmlOnly)
+	if !strings.HasPrefix(got, "---------- Forwarded message") {
+		t.Errorf("forwardBody without note should start with the separator:\n%s", got)
+	}
+	if !strings.Contains(got, "Hello") || strings.Contains(got, "x()") {
+		t.Errorf("forwardBody HTML fallback not sanitized text:\n%s", got)
+	}
+}
+
+func TestEmailForwardAsAttachment(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	blob := fake.AddBlob([]byte("Subject: Win a prize/now\r\n\r\nClick here\r\n"))
+	fake.Add("Email", map[string]any{
+		"id": "e1", "subject": "Win a prize/now", "blobId": blob, "size": 40,
+		"mailboxIds": map[string]any{"inbox": true},
+		"textBody":   []any{map[string]any{"partId": "1", "type": "text/plain"}},
+		"bodyValues": map[string]any{"1": map[string]any{"value": "Click here"}},
+	})
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailForward(ctx, nil, EmailForwardInput{EmailID: "e1", To: []string{"abuse@example.com"}, AsAttachment: true})
+	if res.IsError || !strings.Contains(resultText(res), "Attachments: 1") {
+		t.Fatal(resultText(res))
+	}
+	var draft map[string]any
+	for _, id := range fake.Objects("Email") {
+		if e := fake.Object("Email", id); e["subject"] == "Fwd: Win a prize/now" {
+			draft = e
+		}
+	}
+	parts, _ := draft["attachments"].([]any)
+	if len(parts) != 1 {
+		t.Fatalf("attachments = %v", draft["attachments"])
+	}
+	part := parts[0].(map[string]any)
+	if part["type"] != "message/rfc822" || part["blobId"] != blob || part["name"] != "Win a prize_now.eml" {
+		t.Errorf("attached message = %v", part)
+	}
+	if body := draft["bodyValues"].(map[string]any)["body"].(map[string]any)["value"].(string); strings.Contains(body, "Click here") {
+		t.Errorf("body quotes the attached message: %q", body)
+	}
+
+	res, _, _ = s.handleEmailForward(ctx, nil, EmailForwardInput{EmailID: "e1", To: []string{"abuse@example.com"}, AsAttachment: true, OmitAttachments: true})
+	if !res.IsError {
+		t.Error("as_attachment with omit_attachments accepted")
+	}
+}
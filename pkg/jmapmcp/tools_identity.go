@@ -1,4 +1,4 @@
-package server
+package jmapmcp
 
 import (
 	"context"
@@ -29,7 +29,7 @@ func (s *Server) handleIdentityGet(ctx context.Context, _ *mcp.CallToolRequest,
 		return errorResult(err), nil, nil
 	}
 
-	accountID := client.Session.PrimaryAccounts[mail.URI]
+	accountID := client.Session().PrimaryAccounts[mail.URI]
 	if accountID == "" {
 		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
 	}
@@ -54,7 +54,7 @@ func (s *Server) handleIdentityGet(ctx context.Context, _ *mcp.CallToolRequest,
 	switch args := resp.Responses[0].Args.(type) {
 	case *identity.GetResponse:
 		if len(args.NotFound) > 0 {
-			return errorResult(fmt.Errorf("identities not found: %v", args.NotFound)), nil, nil
+			return errorResult(notFoundError(args.NotFound, "identities not found: %v", args.NotFound)), nil, nil
 		}
 		var sb strings.Builder
 		for _, id := range args.List {
@@ -67,7 +67,21 @@ func (s *Server) handleIdentityGet(ctx context.Context, _ *mcp.CallToolRequest,
 		if len(args.List) == 0 {
 			sb.WriteString("No sender identities found.\n")
 		}
-		return textResult(sb.String()), nil, nil
+		result := textResult(sb.String())
+		if s.structuredOutput {
+			structured := identityGetStructured{Identities: make([]identityObject, 0, len(args.List))}
+			for _, id := range args.List {
+				structured.Identities = append(structured.Identities, identityObject{
+					ID:      string(id.ID),
+					Name:    id.Name,
+					Email:   id.Email,
+					ReplyTo: addressObjects(id.ReplyTo),
+					BCC:     addressObjects(id.Bcc),
+				})
+			}
+			result.StructuredContent = &structured
+		}
+		return result, nil, nil
 	case *jmap.MethodError:
 		return errorResult(args), nil, nil
 	default:
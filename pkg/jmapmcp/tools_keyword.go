@@ -0,0 +1,158 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	keywordPageSize       = 500
+	defaultKeywordMaxScan = 2000
+	keywordMaxScanCeiling = 20000
+)
+
+// --- keyword_list ---
+
+type KeywordListInput struct {
+	MailboxID string `json:"mailbox_id,omitempty" jsonschema:"ID of the mailbox to scan (omit to scan all mail)"`
+	MaxEmails int    `json:"max_emails,omitempty" jsonschema:"Maximum number of most recent emails to scan (default 2000, max 20000)"`
+}
+
+var keywordListTool = &mcp.Tool{
+	Name:        "keyword_list",
+	Description: "List distinct keywords (flags and user labels such as $Important or custom tags) in use across a mailbox, with the number of emails carrying each. Scans the most recent emails in pages. Use before filtering or flagging by a custom keyword.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleKeywordList(ctx context.Context, _ *mcp.CallToolRequest, in KeywordListInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	maxScan := in.MaxEmails
+	if maxScan <= 0 {
+		maxScan = defaultKeywordMaxScan
+	}
+	maxScan = min(maxScan, keywordMaxScanCeiling)
+
+	pageSize := uint64(keywordPageSize)
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxObjectsInGet > 0 {
+		pageSize = min(pageSize, c.MaxObjectsInGet)
+	}
+
+	// Without calculateTotal, paging stops at the first short page and the
+	// total is reported as what was scanned.
+	noTotal := s.quirksFor(client).has(quirkNoCalculateTotal)
+
+	counts := make(map[string]int)
+	var scanned int
+	var total uint64
+	for scanned < maxScan {
+		limit := min(pageSize, uint64(maxScan-scanned))
+
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(&email.Query{
+			Account:        accountID,
+			Filter:         &email.FilterCondition{InMailbox: jmap.ID(in.MailboxID)},
+			Sort:           []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Position:       int64(scanned),
+			Limit:          limit,
+			CalculateTotal: !noTotal,
+		})
+		req.Invoke(&email.Get{
+			Account: accountID,
+			ReferenceIDs: &jmap.ResultReference{
+				ResultOf: queryCallID,
+				Name:     "Email/query",
+				Path:     "/ids",
+			},
+			Properties: []string{"id", "keywords"},
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if len(resp.Responses) < 2 {
+			return errorResult(fmt.Errorf("missing Email/get response in query chain")), nil, nil
+		}
+
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.QueryResponse:
+			total = args.Total
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+
+		var page []*email.Email
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			page = args.List
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+
+		for _, e := range page {
+			for kw, set := range e.Keywords {
+				if set {
+					counts[kw]++
+				}
+			}
+		}
+		scanned += len(page)
+		if noTotal {
+			total = uint64(scanned)
+			if uint64(len(page)) < limit {
+				break
+			}
+			continue
+		}
+		if len(page) == 0 || uint64(scanned) >= total {
+			break
+		}
+	}
+
+	return textResult(formatKeywordCounts(counts, scanned, total)), nil, nil
+}
+
+// formatKeywordCounts renders keyword counts sorted by frequency, then name.
+func formatKeywordCounts(counts map[string]int, scanned int, total uint64) string {
+	keywords := make([]string, 0, len(counts))
+	for kw := range counts {
+		keywords = append(keywords, kw)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if counts[keywords[i]] != counts[keywords[j]] {
+			return counts[keywords[i]] > counts[keywords[j]]
+		}
+		return keywords[i] < keywords[j]
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Scanned %d of %d emails, %d distinct keywords\n\n", scanned, total, len(keywords))
+	for _, kw := range keywords {
+		fmt.Fprintf(&sb, "%s  %d\n", kw, counts[kw])
+	}
+	if len(keywords) == 0 {
+		sb.WriteString("No keywords found.\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,218 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Label tools treat non-role mailboxes as Gmail-style labels: membership is
+// added and removed one mailbox at a time, so an email keeps its Inbox or
+// Archive membership and any other labels. Role mailboxes are never touched.
+
+// --- label_apply ---
+
+type LabelInput struct {
+	EmailIDs   []string `json:"email_ids,omitempty" jsonschema:"IDs of emails to label"`
+	Selection  string   `json:"selection,omitempty" jsonschema:"Instead of email_ids: name of a selection made with selection_set"`
+	MailboxIDs []string `json:"mailbox_ids" jsonschema:"IDs of label mailboxes (mailboxes without a role)"`
+}
+
+var labelApplyTool = &mcp.Tool{
+	Name:        "label_apply",
+	Description: "Add labels to emails. Labels are mailboxes without a role; membership is added alongside existing ones (Inbox, Archive, other labels are kept). Use mailbox_get to find label IDs.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleLabelApply(ctx context.Context, req *mcp.CallToolRequest, in LabelInput) (*mcp.CallToolResult, any, error) {
+	return s.updateLabels(ctx, req, in, true)
+}
+
+// --- label_remove ---
+
+var labelRemoveTool = &mcp.Tool{
+	Name:        "label_remove",
+	Description: "Remove labels from emails without touching Inbox/Archive or other role mailboxes. An email left in no mailbox is kept in Archive. Use email_move or email_delete to change role mailbox membership.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleLabelRemove(ctx context.Context, req *mcp.CallToolRequest, in LabelInput) (*mcp.CallToolResult, any, error) {
+	return s.updateLabels(ctx, req, in, false)
+}
+
+// updateLabels adds (apply=true) or removes label mailbox memberships with
+// per-mailbox patches, refusing role mailboxes.
+func (s *Server) updateLabels(ctx context.Context, req *mcp.CallToolRequest, in LabelInput, apply bool) (*mcp.CallToolResult, any, error) {
+	ids, err := s.selectedEmailIDs(ctx, req, in.EmailIDs, in.Selection)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	in.EmailIDs = ids
+	if len(in.EmailIDs) == 0 {
+		return errorResult(invalidArgument("email_ids or selection is required")), nil, nil
+	}
+	if len(in.MailboxIDs) == 0 {
+		return errorResult(invalidArgument("mailbox_ids is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	// Discovery: all mailboxes (to reject roles and locate Archive) and the
+	// emails' current membership (to avoid orphaning on removal).
+	discoverReq := &jmap.Request{Context: ctx}
+	discoverReq.Invoke(&mailbox.Get{Account: accountID})
+	discoverReq.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        toJMAPIDSlice(in.EmailIDs),
+		Properties: []string{"id", "mailboxIds"},
+	})
+
+	discoverResp, err := client.Do(discoverReq)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(discoverResp.Responses) < 2 {
+		return errorResult(fmt.Errorf("expected 2 discovery responses, got %d", len(discoverResp.Responses))), nil, nil
+	}
+
+	mailboxes := make(map[jmap.ID]*mailbox.Mailbox)
+	var archiveID jmap.ID
+	switch args := discoverResp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			mailboxes[mb.ID] = mb
+			if mb.Role == mailbox.RoleArchive {
+				archiveID = mb.ID
+			}
+		}
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected mailbox response type: %T", args)), nil, nil
+	}
+
+	labels := make(map[jmap.ID]bool, len(in.MailboxIDs))
+	for _, id := range in.MailboxIDs {
+		mb, ok := mailboxes[jmap.ID(id)]
+		if !ok {
+			return errorResult(notFoundError([]string{id}, "mailbox not found: %s", id)), nil, nil
+		}
+		if mb.Role != "" {
+			return errorResult(fmt.Errorf("mailbox %s (%s) has role %q and is not a label; use email_move for role mailboxes", mb.Name, id, mb.Role)), nil, nil
+		}
+		labels[mb.ID] = true
+	}
+
+	var current map[jmap.ID]map[jmap.ID]bool
+	switch args := discoverResp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 {
+			return errorResult(notFoundError(args.NotFound, "emails not found: %v", args.NotFound)), nil, nil
+		}
+		current = make(map[jmap.ID]map[jmap.ID]bool, len(args.List))
+		for _, e := range args.List {
+			current[e.ID] = e.MailboxIDs
+		}
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected email response type: %T", args)), nil, nil
+	}
+
+	for label := range labels {
+		right := "mayAddItems"
+		if !apply {
+			right = "mayRemoveItems"
+		}
+		if err := checkMailboxRight(mailboxes[label], right); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+
+	updates := make(map[jmap.ID]jmap.Patch, len(in.EmailIDs))
+	archived := 0
+	for _, id := range in.EmailIDs {
+		patch := jmap.Patch{}
+		for label := range labels {
+			if apply {
+				patch["mailboxIds/"+string(label)] = true
+			} else {
+				patch["mailboxIds/"+string(label)] = nil
+			}
+		}
+		if !apply && !keepsMembership(current[jmap.ID(id)], labels) {
+			if archiveID == "" {
+				return errorResult(fmt.Errorf("removing these labels would leave email %s in no mailbox and there is no Archive mailbox; use email_move instead", id)), nil, nil
+			}
+			if err := checkMailboxRight(mailboxes[archiveID], "mayAddItems"); err != nil {
+				return errorResult(err), nil, nil
+			}
+			patch["mailboxIds/"+string(archiveID)] = true
+			archived++
+		}
+		updates[jmap.ID(id)] = patch
+	}
+
+	setReq := &jmap.Request{Context: ctx}
+	setReq.Invoke(&email.Set{
+		Account: accountID,
+		Update:  updates,
+	})
+
+	resp, err := client.Do(setReq)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/set")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		tool := "label_remove"
+		if apply {
+			tool = "label_apply"
+		}
+		s.journal(ctx, req, &journalEntry{tool: tool, accountID: accountID, mailboxes: current}, args.NotUpdated)
+		if err := setFailures("label update failed", args.NotUpdated); err != nil {
+			return errorResult(err), nil, nil
+		}
+		if apply {
+			return textResult(fmt.Sprintf("Applied %d label(s) to %d email(s)", len(labels), len(in.EmailIDs))), nil, nil
+		}
+		msg := fmt.Sprintf("Removed %d label(s) from %d email(s)", len(labels), len(in.EmailIDs))
+		if archived > 0 {
+			msg += fmt.Sprintf("; %d email(s) kept in Archive", archived)
+		}
+		return textResult(msg), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// keepsMembership reports whether an email in mailboxIDs still belongs to at
+// least one mailbox after the given labels are removed.
+func keepsMembership(mailboxIDs map[jmap.ID]bool, removed map[jmap.ID]bool) bool {
+	for id, in := range mailboxIDs {
+		if in && !removed[id] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package jmapmcp
+
+import (
+	"testing"
+
+	"github.com/mikluko/jmap"
+)
+
+func TestKeepsMembership(t *testing.T) {
+	removed := map[jmap.ID]bool{"l1": true, "l2": true}
+	tests := []struct {
+		name    string
+		current map[jmap.ID]bool
+		want    bool
+	}{
+		{"inbox kept", map[jmap.ID]bool{"inbox": true, "l1": true}, true},
+		{"only removed labels", map[jmap.ID]bool{"l1": true, "l2": true}, false},
+		{"other label kept", map[jmap.ID]bool{"l1": true, "l3": true}, true},
+		{"no membership", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keepsMembership(tt.current, removed); got != tt.want {
+				t.Errorf("keepsMembership() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
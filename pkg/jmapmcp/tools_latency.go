@@ -0,0 +1,261 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultLatencyDays = 90
+	maxLatencyDays     = 730
+	// latencyMaxScan bounds the sent messages inspected for replies.
+	latencyMaxScan = 1000
+	// latencyCorrespondents bounds the per-correspondent lines of an
+	// overall report.
+	latencyCorrespondents = 10
+)
+
+// --- reply_latency ---
+
+type ReplyLatencyInput struct {
+	Address string `json:"address,omitempty" jsonschema:"Correspondent to measure replies to (omit for all correspondents)"`
+	Days    int    `json:"days,omitempty" jsonschema:"How many days of Sent mail to scan (default 90, max 730)"`
+}
+
+var replyLatencyTool = &mcp.Tool{
+	Name:        "reply_latency",
+	Description: "How quickly the user replies, to one correspondent or overall: pairs Sent messages with the messages they answer via In-Reply-To over the last days and reports the median, quartiles, and share answered within an hour and a day; overall, also the median per frequent correspondent. Use to prioritize, or to judge whether a reply is overdue before following up.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleReplyLatency(ctx context.Context, _ *mcp.CallToolRequest, in ReplyLatencyInput) (*mcp.CallToolResult, any, error) {
+	days := in.Days
+	if days <= 0 {
+		days = defaultLatencyDays
+	}
+	if days > maxLatencyDays {
+		return errorResult(invalidArgument("days must be at most %d", maxLatencyDays)), nil, nil
+	}
+	address := strings.ToLower(strings.TrimSpace(in.Address))
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+	sentID, err := s.findMailboxByRole(ctx, client, accountID, mailbox.RoleSent)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	pageSize := uint64(keywordPageSize)
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxObjectsInGet > 0 {
+		pageSize = min(pageSize, c.MaxObjectsInGet)
+	}
+
+	after := time.Now().AddDate(0, 0, -days)
+	sent, more, err := scanSentReplies(ctx, client, accountID, sentID, address, after, pageSize)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	received, err := repliedThreadEmails(ctx, client, accountID, sentID, sent, pageSize)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if address != "" {
+		received = slices.DeleteFunc(received, func(e *email.Email) bool {
+			return !slices.ContainsFunc(e.From, func(a *mail.Address) bool { return strings.EqualFold(a.Email, address) })
+		})
+	}
+	pairs := pairReplies(received, sent)
+
+	var sb strings.Builder
+	who := "all correspondents"
+	if address != "" {
+		who = address
+	}
+	fmt.Fprintf(&sb, "Reply latency to %s over the last %d days: %d repl(ies) paired with the message they answer, of %d sent repl(ies)", who, days, len(pairs), len(sent))
+	if more {
+		fmt.Fprintf(&sb, " (scan stopped at the %d most recent)", latencyMaxScan)
+	}
+	sb.WriteString("\n")
+	if len(pairs) == 0 {
+		sb.WriteString("\nNo replies matched in this period.\n")
+		return textResult(sb.String()), nil, nil
+	}
+
+	latencies := make([]time.Duration, len(pairs))
+	for i, p := range pairs {
+		latencies[i] = p.latency
+	}
+	slices.Sort(latencies)
+	fmt.Fprintf(&sb, "\nMedian: %s\n", formatLatency(medianDuration(latencies)))
+	fmt.Fprintf(&sb, "Quartiles: a quarter answered within %s, three quarters within %s\n", formatLatency(latencyQuantile(latencies, 0.25)), formatLatency(latencyQuantile(latencies, 0.75)))
+	fmt.Fprintf(&sb, "Range: %s to %s\n", formatLatency(latencies[0]), formatLatency(latencies[len(latencies)-1]))
+	fmt.Fprintf(&sb, "Within an hour: %d%%; within a day: %d%%\n", shareWithin(latencies, time.Hour), shareWithin(latencies, 24*time.Hour))
+
+	if address == "" {
+		writeLatencyByCorrespondent(&sb, pairs)
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// writeLatencyByCorrespondent lists the median reply latency of the
+// correspondents answered at least twice, most answered first.
+func writeLatencyByCorrespondent(sb *strings.Builder, pairs []replyPair) {
+	by := make(map[string][]time.Duration)
+	for _, p := range pairs {
+		if len(p.original.From) > 0 {
+			addr := strings.ToLower(p.original.From[0].Email)
+			by[addr] = append(by[addr], p.latency)
+		}
+	}
+	addrs := slices.DeleteFunc(sortedKeys(by), func(addr string) bool { return len(by[addr]) < 2 })
+	if len(addrs) == 0 {
+		return
+	}
+	slices.SortStableFunc(addrs, func(a, b string) int { return len(by[b]) - len(by[a]) })
+	sb.WriteString("\nBy correspondent (answered at least twice):\n")
+	for i, addr := range addrs {
+		if i >= latencyCorrespondents {
+			fmt.Fprintf(sb, "… and %d more\n", len(addrs)-i)
+			break
+		}
+		fmt.Fprintf(sb, "- %s: median %s over %d replies\n", addr, formatLatency(medianDuration(by[addr])), len(by[addr]))
+	}
+}
+
+// latencyQuantile returns the q-quantile of sorted by nearest rank.
+func latencyQuantile(sorted []time.Duration, q float64) time.Duration {
+	i := int(q*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// shareWithin returns the percentage of latencies no longer than d.
+func shareWithin(latencies []time.Duration, d time.Duration) int {
+	n := 0
+	for _, l := range latencies {
+		if l <= d {
+			n++
+		}
+	}
+	return n * 100 / len(latencies)
+}
+
+// scanSentReplies pages the Sent mailbox newest first back to after and
+// returns the messages that reply to another (have In-Reply-To), to address
+// when given. more reports that the scan stopped at latencyMaxScan.
+func scanSentReplies(ctx context.Context, client JMAPClient, accountID, sentID jmap.ID, address string, after time.Time, pageSize uint64) ([]*email.Email, bool, error) {
+	var replies []*email.Email
+	for scanned := 0; scanned < latencyMaxScan; {
+		limit := min(pageSize, uint64(latencyMaxScan-scanned))
+
+		req := &jmap.Request{Context: ctx}
+		queryCallID := req.Invoke(&email.Query{
+			Account:  accountID,
+			Filter:   &email.FilterCondition{InMailbox: sentID, After: &after, To: address},
+			Sort:     []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Position: int64(scanned),
+			Limit:    limit,
+		})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+			Properties:   []string{"id", "threadId", "inReplyTo", "sentAt", "receivedAt"},
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(resp.Responses) < 2 {
+			return nil, false, fmt.Errorf("missing Email/get response in query chain")
+		}
+		if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+			return nil, false, me
+		}
+
+		var page []*email.Email
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			page = args.List
+		case *jmap.MethodError:
+			return nil, false, args
+		default:
+			return nil, false, fmt.Errorf("unexpected response type: %T", args)
+		}
+
+		scanned += len(page)
+		for _, e := range page {
+			if len(e.InReplyTo) > 0 {
+				replies = append(replies, e)
+			}
+		}
+		if uint64(len(page)) < limit {
+			return replies, false, nil
+		}
+	}
+	return replies, true, nil
+}
+
+// repliedThreadEmails returns the received emails of the threads of
+// replies, where the messages they answer are, leaving out the user's own
+// in sentID. Threads are fetched pageSize at a time.
+func repliedThreadEmails(ctx context.Context, client JMAPClient, accountID, sentID jmap.ID, replies []*email.Email, pageSize uint64) ([]*email.Email, error) {
+	var threadIDs []jmap.ID
+	seen := make(map[jmap.ID]bool)
+	for _, e := range replies {
+		if e.ThreadID != "" && !seen[e.ThreadID] {
+			seen[e.ThreadID] = true
+			threadIDs = append(threadIDs, e.ThreadID)
+		}
+	}
+
+	var received []*email.Email
+	for chunk := range slices.Chunk(threadIDs, int(max(pageSize, 1))) {
+		req := &jmap.Request{Context: ctx}
+		threadCallID := req.Invoke(&thread.Get{Account: accountID, IDs: chunk})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: threadCallID, Name: "Thread/get", Path: "/list/*/emailIds"},
+			Properties:   []string{"id", "messageId", "from", "mailboxIds", "receivedAt"},
+		})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < 2 {
+			return nil, fmt.Errorf("missing Email/get response in thread chain")
+		}
+		if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+			return nil, me
+		}
+		switch args := resp.Responses[1].Args.(type) {
+		case *email.GetResponse:
+			for _, e := range args.List {
+				if !e.MailboxIDs[sentID] {
+					received = append(received, e)
+				}
+			}
+		case *jmap.MethodError:
+			return nil, args
+		default:
+			return nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+	return received, nil
+}
@@ -0,0 +1,85 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplyLatency(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	base := time.Now().Add(-10 * 24 * time.Hour).UTC().Truncate(time.Hour)
+	add := func(id, thread, mailbox, from, to string, at time.Duration, messageID, inReplyTo string) {
+		e := map[string]any{
+			"id": id, "threadId": thread, "mailboxIds": map[string]any{mailbox: true},
+			"from":       []any{map[string]any{"email": from}},
+			"to":         []any{map[string]any{"email": to}},
+			"receivedAt": base.Add(at).Format(time.RFC3339),
+			"messageId":  []any{messageID},
+		}
+		if inReplyTo != "" {
+			e["inReplyTo"] = []any{inReplyTo}
+		}
+		fake.Add("Email", e)
+	}
+	thread := func(id string, emails ...string) {
+		ids := make([]any, len(emails))
+		for i, e := range emails {
+			ids[i] = e
+		}
+		fake.Add("Thread", map[string]any{"id": id, "emailIds": ids})
+	}
+	add("a1", "T1", "inbox", "alice@example.org", "me@example.com", 0, "a1@x", "")
+	add("r1", "T1", "sent", "me@example.com", "alice@example.org", 30*time.Minute, "r1@x", "a1@x")
+	add("a2", "T2", "inbox", "alice@example.org", "me@example.com", 24*time.Hour, "a2@x", "")
+	add("r2", "T2", "sent", "me@example.com", "alice@example.org", 27*time.Hour, "r2@x", "a2@x")
+	add("b1", "T3", "inbox", "bob@example.org", "me@example.com", 48*time.Hour, "b1@x", "")
+	add("r3", "T3", "sent", "me@example.com", "bob@example.org", 98*time.Hour, "r3@x", "b1@x")
+	// A follow-up to the user's own message is not a reply to anyone.
+	add("r4", "T4", "sent", "me@example.com", "carol@example.org", 0, "r4@x", "")
+	add("r5", "T4", "sent", "me@example.com", "carol@example.org", time.Hour, "r5@x", "r4@x")
+	thread("T1", "a1", "r1")
+	thread("T2", "a2", "r2")
+	thread("T3", "b1", "r3")
+	thread("T4", "r4", "r5")
+	ctx := context.Background()
+
+	res, _, _ := s.handleReplyLatency(ctx, nil, ReplyLatencyInput{})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("reply_latency: %s", out)
+	}
+	for _, want := range []string{
+		"Reply latency to all correspondents over the last 90 days: 3 repl(ies) paired with the message they answer, of 4 sent repl(ies)",
+		"Median: 3.0h\n",
+		"Range: 30m to 2.1d\n",
+		"Within an hour: 33%; within a day: 66%\n",
+		"- alice@example.org: median 1.8h over 2 replies\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("overall report lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "bob@example.org:") {
+		t.Errorf("correspondent answered once listed:\n%s", out)
+	}
+
+	res, _, _ = s.handleReplyLatency(ctx, nil, ReplyLatencyInput{Address: "Alice@example.org"})
+	out = resultText(res)
+	for _, want := range []string{"Reply latency to alice@example.org", ": 2 repl(ies) paired", "Median: 1.8h\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report for alice lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "By correspondent") {
+		t.Errorf("per-correspondent lines for one address:\n%s", out)
+	}
+
+	res, _, _ = s.handleReplyLatency(ctx, nil, ReplyLatencyInput{Days: 1000})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeInvalidArguments {
+		t.Errorf("days over the limit: %s", resultText(res))
+	}
+}
@@ -0,0 +1,390 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/principals"
+)
+
+// --- mailbox_get ---
+
+type MailboxGetInput struct {
+	IDs []string `json:"ids,omitempty" jsonschema:"Mailbox IDs to retrieve (omit to get all mailboxes)"`
+}
+
+var mailboxGetTool = &mcp.Tool{
+	Name:        "mailbox_get",
+	Description: "Get mailboxes by ID, or list all mailboxes with names, roles, and email counts. Mailboxes the user has limited rights on (typically shared ones) list what they may not do, e.g. (may not: remove, delete); on servers with JMAP Sharing, mailboxes the user shares list who they are shared with and with which rights. Use this first to discover mailbox IDs for other tools.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleMailboxGet(ctx context.Context, _ *mcp.CallToolRequest, in MailboxGetInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	get := &mailbox.Get{Account: accountID}
+	if len(in.IDs) > 0 {
+		get.IDs = toJMAPIDSlice(in.IDs)
+	}
+
+	// shareWith is read from the raw response (see mailboxShares).
+	ctx, raw := withRawResponses(ctx)
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(get)
+	// Name the principals mailboxes are shared with, where the server shares.
+	if principalAccount := client.Session().PrimaryAccounts[principals.URI]; principalAccount != "" {
+		req.Invoke(&principals.Get{Account: principalAccount, Properties: []string{"id", "name", "email"}})
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Mailbox/get")), nil, nil
+	}
+
+	shares := mailboxShares(raw.list())
+	known := map[jmap.ID]*principals.Principal{}
+	if len(resp.Responses) > 1 {
+		if pr, ok := resp.Responses[1].Args.(*principals.GetResponse); ok {
+			for _, p := range pr.List {
+				known[p.ID] = p
+			}
+		}
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		if len(args.NotFound) > 0 {
+			return errorResult(notFoundError(args.NotFound, "mailboxes not found: %v", args.NotFound)), nil, nil
+		}
+		var sb strings.Builder
+		for _, mb := range args.List {
+			role := string(mb.Role)
+			if role == "" {
+				role = "folder"
+			}
+			fmt.Fprintf(&sb, "%s (%s) — %d emails, %d unread [id: %s]%s\n",
+				mb.Name, role, mb.TotalEmails, mb.UnreadEmails, mb.ID, formatDeniedRights(mb))
+			sb.WriteString(formatShares(shares[mb.ID], known))
+		}
+		return textResult(sb.String()), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// --- mailbox_set ---
+
+type MailboxSetCreate struct {
+	Name     string `json:"name,omitempty" jsonschema:"Mailbox name"`
+	Path     string `json:"path,omitempty" jsonschema:"Instead of name: a slash-separated path such as Clients/Acme/2025, under parent_id or the top level. Mailboxes along it that exist are reused and missing ones created"`
+	ParentID string `json:"parent_id,omitempty" jsonschema:"Parent mailbox ID, or #creation-id of another mailbox created in this call (omit for top-level)"`
+}
+
+type MailboxSetUpdate struct {
+	Name     string              `json:"name,omitempty" jsonschema:"New name"`
+	ParentID *string             `json:"parent_id,omitempty" jsonschema:"New parent mailbox ID (null to move to top-level)"`
+	Share    map[string][]string `json:"share,omitempty" jsonschema:"Sharing changes keyed by principal ID or email address: the rights to grant (read, add, remove, set-seen, set-keywords, create-child, rename, delete, submit, or all), replacing that principal's current ones; an empty list revokes access. Requires a server with JMAP Sharing"`
+}
+
+type MailboxSetInput struct {
+	Create                map[string]MailboxSetCreate `json:"create,omitempty" jsonschema:"Mailboxes to create keyed by creation ID"`
+	Update                map[string]MailboxSetUpdate `json:"update,omitempty" jsonschema:"Mailboxes to update keyed by mailbox ID"`
+	Destroy               []string                   `json:"destroy,omitempty" jsonschema:"Mailbox IDs to destroy"`
+	OnDestroyRemoveEmails bool                        `json:"on_destroy_remove_emails,omitempty" jsonschema:"Also destroy emails that are only in destroyed mailboxes"`
+	Confirm               string                      `json:"confirm,omitempty" jsonschema:"Confirmation token from a previous identical call, when the server requires confirming mailbox destruction"`
+}
+
+var mailboxSetTool = &mcp.Tool{
+	Name:        "mailbox_set",
+	Description: "Create, update, or destroy mailboxes. Supports batch operations: create new folders, rename or reparent existing ones, or destroy by ID. A create can give a path like Clients/Acme/2025 instead of a name to make the whole hierarchy in one call. On servers with JMAP Sharing, update can also grant or revoke other users' access to a mailbox with share; other principals' access is left unchanged.",
+	Annotations: destructiveAnnotations,
+}
+
+func (s *Server) handleMailboxSet(ctx context.Context, _ *mcp.CallToolRequest, in MailboxSetInput) (*mcp.CallToolResult, any, error) {
+	if len(in.Create) == 0 && len(in.Update) == 0 && len(in.Destroy) == 0 {
+		return errorResult(invalidArgument("at least one of create, update, or destroy must be provided")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	create, labels, existing, err := expandMailboxPaths(in.Create, mailboxes)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(create) == 0 && len(in.Update) == 0 && len(in.Destroy) == 0 {
+		return textResult(strings.Join(existing, "\n") + "\n"), nil, nil
+	}
+	checked := in
+	checked.Create = create
+	if err := checkMailboxSetRights(checked, mailboxes); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(in.Destroy) > 0 {
+		gated := in
+		gated.Confirm = ""
+		names := make([]string, len(in.Destroy))
+		for i, id := range in.Destroy {
+			names[i] = joinMailboxNames(mailboxes, []jmap.ID{jmap.ID(id)})
+		}
+		summary := fmt.Sprintf("destroy %d mailbox(es): %s", len(in.Destroy), listSome(names))
+		if in.OnDestroyRemoveEmails {
+			summary += ", and the emails that are only in them"
+		}
+		if res := s.confirmDestructive(ctx, "mailbox_set", gated, in.Confirm, summary); res != nil {
+			return res, nil, nil
+		}
+	}
+
+	set := &mailbox.Set{
+		Account:               accountID,
+		OnDestroyRemoveEmails: in.OnDestroyRemoveEmails,
+	}
+
+	if len(create) > 0 {
+		set.Create = make(map[jmap.ID]*mailbox.Mailbox, len(create))
+		for cid, c := range create {
+			mb := &mailbox.Mailbox{Name: c.Name}
+			if c.ParentID != "" {
+				mb.ParentID = jmap.ID(c.ParentID)
+			}
+			set.Create[jmap.ID(cid)] = mb
+		}
+	}
+
+	var known map[jmap.ID]*principals.Principal
+	for _, u := range in.Update {
+		if len(u.Share) > 0 && known == nil {
+			if !sharingSupported(client.Session()) {
+				return errorResult(capabilityMissing(client.Session(), principals.URI)), nil, nil
+			}
+			if known, err = fetchPrincipals(ctx, client); err != nil {
+				return errorResult(err), nil, nil
+			}
+		}
+	}
+
+	if len(in.Update) > 0 {
+		set.Update = make(map[jmap.ID]jmap.Patch, len(in.Update))
+		for id, u := range in.Update {
+			patch := jmap.Patch{}
+			for who, names := range u.Share {
+				pid, err := resolvePrincipal(who, known)
+				if err != nil {
+					return errorResult(err), nil, nil
+				}
+				if len(names) == 0 {
+					patch["shareWith/"+string(pid)] = nil
+					continue
+				}
+				rights, err := shareRights(names)
+				if err != nil {
+					return errorResult(err), nil, nil
+				}
+				patch["shareWith/"+string(pid)] = rights
+			}
+			if u.Name != "" {
+				patch["name"] = u.Name
+			}
+			if u.ParentID != nil {
+				if *u.ParentID == "" {
+					patch["parentId"] = nil
+				} else {
+					patch["parentId"] = *u.ParentID
+				}
+			}
+			if len(patch) == 0 {
+				continue
+			}
+			set.Update[jmap.ID(id)] = patch
+		}
+	}
+
+	if len(in.Destroy) > 0 {
+		set.Destroy = toJMAPIDSlice(in.Destroy)
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(set)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Mailbox/set")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.SetResponse:
+		var sb strings.Builder
+		var errors []string
+
+		for _, line := range existing {
+			sb.WriteString(line + "\n")
+		}
+		for cid, mb := range args.Created {
+			fmt.Fprintf(&sb, "Created mailbox %s [id: %s]\n", labels[cid], mb.ID)
+		}
+		for cid, se := range args.NotCreated {
+			errors = append(errors, fmt.Sprintf("create %s: %s", labels[cid], setErrorText(se)))
+		}
+		for id := range args.Updated {
+			fmt.Fprintf(&sb, "Updated mailbox %s\n", id)
+		}
+		for id, se := range args.NotUpdated {
+			errors = append(errors, fmt.Sprintf("update %s: %s", id, setErrorText(se)))
+		}
+		for _, id := range args.Destroyed {
+			fmt.Fprintf(&sb, "Destroyed mailbox %s\n", id)
+		}
+		for id, se := range args.NotDestroyed {
+			errors = append(errors, fmt.Sprintf("destroy %s: %s", id, setErrorText(se)))
+		}
+
+		if len(errors) > 0 {
+			fmt.Fprintf(&sb, "Errors: %s\n", strings.Join(errors, "; "))
+			return &mcp.CallToolResult{
+				IsError: true,
+				Content: []mcp.Content{&mcp.TextContent{Text: sb.String()}},
+			}, nil, nil
+		}
+		return textResult(sb.String()), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// checkMailboxSetRights checks myRights for each change: mayCreateChild on
+// the parent of a new or moved mailbox, mayRename to rename or move, and
+// mayDelete to destroy.
+func checkMailboxSetRights(in MailboxSetInput, mailboxes map[jmap.ID]*mailbox.Mailbox) error {
+	for _, c := range in.Create {
+		if c.ParentID != "" {
+			if err := checkMailboxRight(mailboxes[jmap.ID(c.ParentID)], "mayCreateChild"); err != nil {
+				return err
+			}
+		}
+	}
+	for id, u := range in.Update {
+		if u.Name == "" && u.ParentID == nil {
+			continue
+		}
+		if err := checkMailboxRight(mailboxes[jmap.ID(id)], "mayRename"); err != nil {
+			return err
+		}
+		if u.ParentID != nil && *u.ParentID != "" {
+			if err := checkMailboxRight(mailboxes[jmap.ID(*u.ParentID)], "mayCreateChild"); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range in.Destroy {
+		if err := checkMailboxRight(mailboxes[jmap.ID(id)], "mayDelete"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandMailboxPaths replaces each create given as a path with creates for
+// the mailboxes missing along it, each referring to its new parent as
+// "#creation-id" so that a single Mailbox/set makes the whole hierarchy.
+// Mailboxes that exist are reused, and one missing from several paths is
+// created once. It returns the creates keyed by creation ID, what to call
+// each creation in the result, and a line for each path that exists in full.
+func expandMailboxPaths(create map[string]MailboxSetCreate, mailboxes map[jmap.ID]*mailbox.Mailbox) (map[string]MailboxSetCreate, map[jmap.ID]string, []string, error) {
+	out := make(map[string]MailboxSetCreate, len(create))
+	labels := make(map[jmap.ID]string, len(create))
+	var existing []string
+
+	byPath := make(map[string]string, len(mailboxes)) // path → mailbox ID, or #creation-id once created here
+	for id, mb := range mailboxes {
+		byPath[mailboxPath(mb, mailboxes)] = string(id)
+	}
+
+	for _, cid := range sortedKeys(create) {
+		c := create[cid]
+		if c.Path == "" {
+			if c.Name == "" {
+				return nil, nil, nil, invalidArgument("create %s: name or path is required", cid)
+			}
+			out[cid], labels[jmap.ID(cid)] = c, cid
+			continue
+		}
+		if c.Name != "" {
+			return nil, nil, nil, invalidArgument("create %s: give name or path, not both", cid)
+		}
+		names := strings.Split(strings.Trim(c.Path, "/"), "/")
+		if slices.Contains(names, "") {
+			return nil, nil, nil, invalidArgument("create %s: path %q has an empty segment", cid, c.Path)
+		}
+
+		var path string
+		parent := c.ParentID
+		if parent != "" {
+			mb := mailboxes[jmap.ID(parent)]
+			if mb == nil {
+				return nil, nil, nil, notFoundError([]jmap.ID{jmap.ID(parent)}, "create %s: parent mailbox %s not found", cid, parent)
+			}
+			path = mailboxPath(mb, mailboxes)
+		}
+		for i, name := range names {
+			if path != "" {
+				path += "/"
+			}
+			path += name
+			if id, ok := byPath[path]; ok {
+				if i == len(names)-1 && !strings.HasPrefix(id, "#") {
+					existing = append(existing, fmt.Sprintf("Mailbox %s already exists [id: %s]", path, id))
+				}
+				parent = id
+				continue
+			}
+			id := cid
+			if i < len(names)-1 {
+				id = fmt.Sprintf("%s-%d", cid, i)
+			}
+			out[id] = MailboxSetCreate{Name: name, ParentID: parent}
+			labels[jmap.ID(id)] = path
+			byPath[path] = "#" + id
+			parent = "#" + id
+		}
+	}
+	return out, labels, existing, nil
+}
@@ -0,0 +1,266 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/quota"
+)
+
+// --- mailbox_report ---
+
+type MailboxReportInput struct {
+	MailboxIDs []string `json:"mailbox_ids,omitempty" jsonschema:"Mailboxes to report on (omit for all)"`
+}
+
+var mailboxReportTool = &mcp.Tool{
+	Name:        "mailbox_report",
+	Description: "Aging report per mailbox: total and unread emails, the date and age of the oldest message, and how totals and unread counts changed since the previous mailbox_report in this account. With JMAP quotas, also the account's mail storage use (JMAP has no per-mailbox size). Changes nothing on the server.",
+	Annotations: readOnlyAnnotations,
+}
+
+// mailboxCounts is what a report snapshot keeps of a mailbox.
+type mailboxCounts struct {
+	total, unread int64
+}
+
+// reportSnapshot is what the previous mailbox_report saw.
+type reportSnapshot struct {
+	at     time.Time
+	counts map[jmap.ID]mailboxCounts
+}
+
+// reportSnapshots keeps the last report per credential and endpoint, in
+// memory, for the growth column of the next one.
+type reportSnapshots struct {
+	mu sync.Mutex
+	m  map[string]*reportSnapshot // owner and endpoint → snapshot
+}
+
+// swap stores next as the latest snapshot of owner on endpoint and returns
+// the one it replaces, or nil. Snapshots of reports narrowed to some
+// mailboxes keep the counts of the others.
+func (r *reportSnapshots) swap(owner, endpoint string, next *reportSnapshot) *reportSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m == nil {
+		r.m = make(map[string]*reportSnapshot)
+	}
+	key := owner + "\x00" + endpoint
+	prev := r.m[key]
+	if prev != nil {
+		for id, c := range prev.counts {
+			if _, ok := next.counts[id]; !ok {
+				next.counts[id] = c
+			}
+		}
+	}
+	r.m[key] = next
+	return prev
+}
+
+func (s *Server) handleMailboxReport(ctx context.Context, _ *mcp.CallToolRequest, in MailboxReportInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "name", "role", "parentId", "totalEmails", "unreadEmails"}})
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Mailbox/get")), nil, nil
+	}
+	// Paths need every mailbox, not only the reported ones.
+	mailboxes := make(map[jmap.ID]*mailbox.Mailbox)
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			mailboxes[mb.ID] = mb
+		}
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	var reported []*mailbox.Mailbox
+	if len(in.MailboxIDs) == 0 {
+		for _, mb := range mailboxes {
+			reported = append(reported, mb)
+		}
+	} else {
+		var missing []string
+		for _, id := range in.MailboxIDs {
+			if mb := mailboxes[jmap.ID(id)]; mb != nil {
+				reported = append(reported, mb)
+			} else {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			return errorResult(notFoundError(missing, "mailboxes not found: %v", missing)), nil, nil
+		}
+	}
+
+	oldest, err := oldestEmails(ctx, client, accountID, reported)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	now := time.Now()
+	next := &reportSnapshot{at: now, counts: make(map[jmap.ID]mailboxCounts, len(reported))}
+	for _, mb := range reported {
+		next.counts[mb.ID] = mailboxCounts{total: int64(mb.TotalEmails), unread: int64(mb.UnreadEmails)}
+	}
+	prev := s.reportSnapshots.swap(s.idOwner(ctx), endpointName(ctx), next)
+
+	slices.SortFunc(reported, func(a, b *mailbox.Mailbox) int {
+		return strings.Compare(mailboxPath(a, mailboxes), mailboxPath(b, mailboxes))
+	})
+	var sb strings.Builder
+	if storage := mailStorage(ctx, client, accountID, s.locale); storage != "" {
+		fmt.Fprintf(&sb, "Mail storage: %s\n", storage)
+	}
+	if prev != nil {
+		fmt.Fprintf(&sb, "Changes since the previous report at %s\n", s.locale.dateTime(prev.at))
+	} else {
+		sb.WriteString("First report in this account; the next one shows changes since now\n")
+	}
+	var total, unread uint64
+	for _, mb := range reported {
+		total += uint64(mb.TotalEmails)
+		unread += uint64(mb.UnreadEmails)
+		fmt.Fprintf(&sb, "\n%s [id: %s] — %d emails, %d unread", mailboxPath(mb, mailboxes), mb.ID, mb.TotalEmails, mb.UnreadEmails)
+		if t, ok := oldest[mb.ID]; ok {
+			fmt.Fprintf(&sb, ", oldest %s (%d days)", s.locale.date(t), int(now.Sub(t).Hours()/24))
+		}
+		if prev != nil {
+			sb.WriteString(growthSince(prev, mb.ID, next.counts[mb.ID]))
+		}
+	}
+	fmt.Fprintf(&sb, "\n\nTotal: %d emails, %d unread in %d mailboxes\n", total, unread, len(reported))
+	return textResult(sb.String()), nil, nil
+}
+
+// growthSince describes how c changed since the snapshot prev.
+func growthSince(prev *reportSnapshot, id jmap.ID, c mailboxCounts) string {
+	was, ok := prev.counts[id]
+	if !ok {
+		return "; new since the previous report"
+	}
+	if was == c {
+		return "; unchanged"
+	}
+	return fmt.Sprintf("; %+d emails, %+d unread", c.total-was.total, c.unread-was.unread)
+}
+
+// oldestEmails returns the receivedAt of the oldest email in each non-empty
+// mailbox, packing as many query/get pairs into each request as the server
+// allows.
+func oldestEmails(ctx context.Context, client JMAPClient, accountID jmap.ID, mailboxes []*mailbox.Mailbox) (map[jmap.ID]time.Time, error) {
+	var ids []jmap.ID
+	for _, mb := range mailboxes {
+		if mb.TotalEmails > 0 {
+			ids = append(ids, mb.ID)
+		}
+	}
+
+	perTrip := defaultMaxCallsInRequest
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxCallsInRequest > 0 {
+		perTrip = int(c.MaxCallsInRequest)
+	}
+	perTrip = max(perTrip/2, 1)
+
+	oldest := make(map[jmap.ID]time.Time, len(ids))
+	for start := 0; start < len(ids); start += perTrip {
+		trip := ids[start:min(start+perTrip, len(ids))]
+		req := &jmap.Request{Context: ctx}
+		for _, id := range trip {
+			queryCallID := req.Invoke(&email.Query{
+				Account: accountID,
+				Filter:  &email.FilterCondition{InMailbox: id},
+				Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: true}},
+				Limit:   1,
+			})
+			req.Invoke(&email.Get{
+				Account:      accountID,
+				ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+				Properties:   []string{"id", "receivedAt"},
+			})
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < 2*len(trip) {
+			return nil, fmt.Errorf("expected %d responses, got %d", 2*len(trip), len(resp.Responses))
+		}
+		for i, id := range trip {
+			if me, ok := resp.Responses[2*i].Args.(*jmap.MethodError); ok {
+				return nil, me
+			}
+			switch args := resp.Responses[2*i+1].Args.(type) {
+			case *email.GetResponse:
+				if len(args.List) > 0 && args.List[0].ReceivedAt != nil {
+					oldest[id] = *args.List[0].ReceivedAt
+				}
+			case *jmap.MethodError:
+				return nil, args
+			default:
+				return nil, fmt.Errorf("unexpected response type: %T", args)
+			}
+		}
+	}
+	return oldest, nil
+}
+
+// mailStorage describes the account's use of its mail storage quotas, or
+// returns "" when the server has none or Quota/get fails.
+func mailStorage(ctx context.Context, client JMAPClient, accountID jmap.ID, l textStyle) string {
+	if _, ok := client.Session().RawCapabilities[quota.URI]; !ok {
+		return ""
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&quota.Get{Account: accountID})
+	resp, err := client.Do(req)
+	if err != nil || len(resp.Responses) == 0 {
+		return ""
+	}
+	args, ok := resp.Responses[0].Args.(*quota.GetResponse)
+	if !ok {
+		return ""
+	}
+	var parts []string
+	for _, q := range args.List {
+		if q.ResourceType != "octets" || len(q.Types) > 0 && !slices.Contains(q.Types, "Mail") {
+			continue
+		}
+		if q.HardLimit > 0 {
+			parts = append(parts, fmt.Sprintf("%s of %s (%.1f%%)", l.size(int64(q.Used)), l.size(int64(q.HardLimit)), float64(q.Used)*100/float64(q.HardLimit)))
+		} else {
+			parts = append(parts, l.size(int64(q.Used)))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
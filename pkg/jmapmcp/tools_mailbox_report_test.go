@@ -0,0 +1,60 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMailboxReport(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addQuotas(fake, 7000)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox", "totalEmails": 2, "unreadEmails": 1})
+	fake.Add("Mailbox", map[string]any{"id": "clients", "name": "Clients", "totalEmails": 0, "unreadEmails": 0})
+	fake.Add("Mailbox", map[string]any{"id": "acme", "name": "Acme", "parentId": "clients", "totalEmails": 1, "unreadEmails": 0})
+	fake.Add("Email", map[string]any{"id": "e1", "receivedAt": "2024-03-01T10:00:00Z", "mailboxIds": map[string]any{"inbox": true}})
+	fake.Add("Email", map[string]any{"id": "e2", "receivedAt": "2025-06-01T10:00:00Z", "mailboxIds": map[string]any{"inbox": true}})
+	fake.Add("Email", map[string]any{"id": "e3", "receivedAt": "2023-01-15T10:00:00Z", "mailboxIds": map[string]any{"acme": true}})
+	ctx := context.Background()
+
+	res, _, _ := s.handleMailboxReport(ctx, nil, MailboxReportInput{})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("mailbox_report: %s", out)
+	}
+	for _, want := range []string{
+		"Mail storage: ", "(70.0%)",
+		"First report in this account",
+		"Clients/Acme [id: acme] — 1 emails, 0 unread, oldest " + s.locale.date(time.Date(2023, 1, 15, 10, 0, 0, 0, time.UTC)),
+		"Inbox [id: inbox] — 2 emails, 1 unread, oldest " + s.locale.date(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)),
+		"Clients [id: clients] — 0 emails, 0 unread\n",
+		"Total: 3 emails, 1 unread in 3 mailboxes",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox", "totalEmails": 5, "unreadEmails": 4})
+	fake.Add("Mailbox", map[string]any{"id": "new", "name": "New", "totalEmails": 0, "unreadEmails": 0})
+	res, _, _ = s.handleMailboxReport(ctx, nil, MailboxReportInput{})
+	out = resultText(res)
+	for _, want := range []string{
+		"Changes since the previous report at",
+		"Inbox [id: inbox] — 5 emails, 4 unread, oldest " + s.locale.date(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) + " (",
+		"; +3 emails, +3 unread",
+		"Clients/Acme [id: acme] — 1 emails, 0 unread, oldest",
+		"; unchanged",
+		"New [id: new] — 0 emails, 0 unread; new since the previous report",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("second report lacks %q:\n%s", want, out)
+		}
+	}
+
+	res, _, _ = s.handleMailboxReport(ctx, nil, MailboxReportInput{MailboxIDs: []string{"missing"}})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeNotFound {
+		t.Errorf("missing mailbox: %s", resultText(res))
+	}
+}
@@ -0,0 +1,168 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const defaultSnapshotName = "latest"
+
+// --- mailbox_snapshot ---
+
+type MailboxSnapshotInput struct {
+	Name string `json:"name,omitempty" jsonschema:"Name to save the snapshot under, replacing one of the same name (default latest)"`
+	List bool   `json:"list,omitempty" jsonschema:"List the saved snapshots instead of taking one"`
+}
+
+var mailboxSnapshotTool = &mcp.Tool{
+	Name:        "mailbox_snapshot",
+	Description: "Save the current mailbox hierarchy (names, parents, roles) under a name, to compare against later with mailbox_diff, e.g. before letting an agent or another client reorganize folders. Up to 20 named snapshots are kept per user. Use list to see them.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleMailboxSnapshot(ctx context.Context, _ *mcp.CallToolRequest, in MailboxSnapshotInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	user := client.Session().Username
+
+	if in.List {
+		names := s.mailboxSnapshots.names(user)
+		if len(names) == 0 {
+			return textResult("No saved mailbox snapshots."), nil, nil
+		}
+		var sb strings.Builder
+		for _, name := range slices.Backward(names) {
+			snap := s.mailboxSnapshots.get(user, name)
+			fmt.Fprintf(&sb, "%s  %s, %d mailboxes, endpoint %s\n", name, s.locale.dateTime(snap.Saved), len(snap.Mailboxes), snap.Endpoint)
+		}
+		return textResult(sb.String()), nil, nil
+	}
+
+	name := in.Name
+	if name == "" {
+		name = defaultSnapshotName
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	snap := &mailboxSnapshot{Saved: time.Now(), Endpoint: endpointName(ctx)}
+	for _, mb := range mailboxes {
+		snap.Mailboxes = append(snap.Mailboxes, snapshotMailbox{ID: string(mb.ID), Name: mb.Name, ParentID: string(mb.ParentID), Role: string(mb.Role)})
+	}
+	slices.SortFunc(snap.Mailboxes, func(a, b snapshotMailbox) int { return strings.Compare(a.ID, b.ID) })
+	if err := s.mailboxSnapshots.put(user, name, snap); err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(fmt.Sprintf("Saved snapshot %q of %d mailboxes; compare with mailbox_diff name=%q.", name, len(snap.Mailboxes), name)), nil, nil
+}
+
+// --- mailbox_diff ---
+
+type MailboxDiffInput struct {
+	Name string `json:"name,omitempty" jsonschema:"Snapshot to compare the live mailboxes against (default latest)"`
+}
+
+var mailboxDiffTool = &mcp.Tool{
+	Name:        "mailbox_diff",
+	Description: "Compare the live mailbox hierarchy against a snapshot saved with mailbox_snapshot: mailboxes created, destroyed, renamed, moved to another parent, or given another role since. Mailboxes are matched by ID, so one destroyed and recreated under the same name shows as both. Changes nothing.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleMailboxDiff(ctx context.Context, _ *mcp.CallToolRequest, in MailboxDiffInput) (*mcp.CallToolResult, any, error) {
+	name := in.Name
+	if name == "" {
+		name = defaultSnapshotName
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	user := client.Session().Username
+	snap := s.mailboxSnapshots.get(user, name)
+	if snap == nil {
+		return errorResult(notFoundError([]string{name}, "no mailbox snapshot %q; take one with mailbox_snapshot", name)), nil, nil
+	}
+	if ep := endpointName(ctx); snap.Endpoint != ep {
+		return errorResult(invalidArgument("snapshot %q was taken on endpoint %q; pass endpoint=%q", name, snap.Endpoint, snap.Endpoint)), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+	live, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	changes := diffMailboxes(snap, live)
+	header := fmt.Sprintf("Compared with snapshot %q of %s", name, s.locale.dateTime(snap.Saved))
+	if len(changes) == 0 {
+		return textResult(header + ": no changes.\n"), nil, nil
+	}
+	return textResult(fmt.Sprintf("%s: %d change(s)\n%s\n", header, len(changes), strings.Join(changes, "\n"))), nil, nil
+}
+
+// diffMailboxes lists what changed from snap to the live mailboxes, one
+// line per change, created first, then destroyed, then changed.
+func diffMailboxes(snap *mailboxSnapshot, live map[jmap.ID]*mailbox.Mailbox) []string {
+	then := make(map[jmap.ID]*mailbox.Mailbox, len(snap.Mailboxes))
+	for _, m := range snap.Mailboxes {
+		then[jmap.ID(m.ID)] = &mailbox.Mailbox{ID: jmap.ID(m.ID), Name: m.Name, ParentID: jmap.ID(m.ParentID), Role: mailbox.Role(m.Role)}
+	}
+
+	var created, destroyed, changed []string
+	for id, mb := range live {
+		old := then[id]
+		if old == nil {
+			created = append(created, fmt.Sprintf("Created: %s [id: %s]", mailboxPath(mb, live), id))
+			continue
+		}
+		was, now := mailboxPath(old, then), mailboxPath(mb, live)
+		switch {
+		case old.Name != mb.Name && old.ParentID != mb.ParentID:
+			changed = append(changed, fmt.Sprintf("Renamed and moved: %s → %s [id: %s]", was, now, id))
+		case old.Name != mb.Name:
+			changed = append(changed, fmt.Sprintf("Renamed: %s → %s [id: %s]", was, now, id))
+		case old.ParentID != mb.ParentID:
+			changed = append(changed, fmt.Sprintf("Moved: %s → %s [id: %s]", was, now, id))
+		}
+		if old.Role != mb.Role {
+			changed = append(changed, fmt.Sprintf("Role changed: %s from %s to %s [id: %s]", now, roleName(old.Role), roleName(mb.Role), id))
+		}
+	}
+	for id, old := range then {
+		if live[id] == nil {
+			destroyed = append(destroyed, fmt.Sprintf("Destroyed: %s [id: %s]", mailboxPath(old, then), id))
+		}
+	}
+	slices.Sort(created)
+	slices.Sort(destroyed)
+	slices.Sort(changed)
+	return slices.Concat(created, destroyed, changed)
+}
+
+// roleName names a mailbox role for a diff line.
+func roleName(r mailbox.Role) string {
+	if r == "" {
+		return "none"
+	}
+	return string(r)
+}
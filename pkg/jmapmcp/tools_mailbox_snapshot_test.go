@@ -0,0 +1,55 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMailboxSnapshotDiff(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "work", "name": "Work"})
+	fake.Add("Mailbox", map[string]any{"id": "acme", "name": "Acme", "parentId": "work"})
+	fake.Add("Mailbox", map[string]any{"id": "old", "name": "Old"})
+	fake.Add("Mailbox", map[string]any{"id": "bin", "name": "Bin"})
+	ctx := context.Background()
+
+	if res, _, _ := s.handleMailboxDiff(ctx, nil, MailboxDiffInput{}); !res.IsError || !strings.Contains(resultText(res), "no mailbox snapshot") {
+		t.Errorf("diff without snapshot: %s", resultText(res))
+	}
+	if res, _, _ := s.handleMailboxSnapshot(ctx, nil, MailboxSnapshotInput{}); res.IsError {
+		t.Fatalf("mailbox_snapshot: %s", resultText(res))
+	}
+	if res, _, _ := s.handleMailboxDiff(ctx, nil, MailboxDiffInput{}); !strings.Contains(resultText(res), "no changes") {
+		t.Errorf("diff of unchanged tree: %s", resultText(res))
+	}
+
+	fake.Add("Mailbox", map[string]any{"id": "work", "name": "Projects"})
+	fake.Add("Mailbox", map[string]any{"id": "acme", "name": "Acme"})
+	fake.Add("Mailbox", map[string]any{"id": "bin", "name": "Bin", "role": "trash"})
+	fake.Add("Mailbox", map[string]any{"id": "new", "name": "New", "parentId": "work"})
+	if res, _, _ := s.handleMailboxSet(ctx, nil, MailboxSetInput{Destroy: []string{"old"}}); res.IsError {
+		t.Fatalf("mailbox_set: %s", resultText(res))
+	}
+
+	res, _, _ := s.handleMailboxDiff(ctx, nil, MailboxDiffInput{})
+	out := resultText(res)
+	for _, want := range []string{
+		"5 change(s)",
+		"Created: Projects/New [id: new]",
+		"Destroyed: Old [id: old]",
+		"Renamed: Work → Projects [id: work]",
+		"Moved: Work/Acme → Acme [id: acme]",
+		"Role changed: Bin from none to trash [id: bin]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff lacks %q:\n%s", want, out)
+		}
+	}
+
+	res, _, _ = s.handleMailboxSnapshot(ctx, nil, MailboxSnapshotInput{List: true})
+	if out := resultText(res); !strings.Contains(out, "latest  ") || !strings.Contains(out, "5 mailboxes") {
+		t.Errorf("list: %s", out)
+	}
+}
@@ -0,0 +1,229 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultMailboxSuggestions = 3
+	maxMailboxSuggestions     = 10
+	suggestHistory            = 50 // past messages per sender or list
+)
+
+// How much one similar message counts for the mailbox it is in: the
+// thread says most about where a message belongs, the sender least.
+const (
+	suggestThreadWeight = 3
+	suggestListWeight   = 2
+	suggestSenderWeight = 1
+)
+
+// --- mailbox_suggest ---
+
+type MailboxSuggestInput struct {
+	EmailID string `json:"email_id" jsonschema:"ID of the email to find a mailbox for"`
+	Limit   int    `json:"limit,omitempty" jsonschema:"Candidates to return (default 3, max 10)"`
+}
+
+var mailboxSuggestTool = &mcp.Tool{
+	Name:        "mailbox_suggest",
+	Description: "Suggest mailboxes to file an email into, ranked by where similar messages live: others in its thread, from its mailing list (List-Id), and from its sender. Each candidate gives its path, ID, and evidence. Inbox, Drafts, Sent, Trash, and mailboxes the email is already in are never suggested. Changes nothing; file with email_move.",
+	Annotations: readOnlyAnnotations,
+}
+
+// mailboxCandidate is one suggested mailbox and what speaks for it.
+type mailboxCandidate struct {
+	mailbox              *mailbox.Mailbox
+	score                int
+	thread, list, sender int
+}
+
+func (s *Server) handleMailboxSuggest(ctx context.Context, _ *mcp.CallToolRequest, in MailboxSuggestInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultMailboxSuggestions
+	}
+	limit = min(limit, maxMailboxSuggestions)
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	anchor, threadEmails, err := suggestThread(ctx, client, accountID, jmap.ID(in.EmailID))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	q := s.quirksFor(client)
+	keys := map[jmap.ID]triageKey{}
+	if len(anchor.From) > 0 {
+		keys["from"] = triageKey{from: strings.ToLower(anchor.From[0].Email)}
+	}
+	if id := listID(anchor); id != "" && !q.has(quirkNoHeaderFilter) {
+		keys["list"] = triageKey{listID: id}
+	}
+	past, err := triageHistory(ctx, client, accountID, keys, suggestHistory)
+	if isUnsupportedFilterErr(err) {
+		// The List-Id header filter is refused: learn it and go by the
+		// sender alone.
+		q.learn(quirkNoHeaderFilter)
+		delete(keys, "list")
+		past, err = triageHistory(ctx, client, accountID, keys, suggestHistory)
+	}
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	candidates := rankMailboxes(anchor, threadEmails, past[keys["list"]], past[keys["from"]], mailboxes)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s  %s  %s\n", anchor.ID, formatAddresses(anchor.From), anchor.Subject)
+	if len(candidates) == 0 {
+		sb.WriteString("No similar messages are filed anywhere yet; pick a mailbox with mailbox_get.\n")
+		return textResult(sb.String()), nil, nil
+	}
+	for i, c := range candidates[:min(limit, len(candidates))] {
+		fmt.Fprintf(&sb, "%d. %s [mailbox: %s] — %s\n", i+1, mailboxPath(c.mailbox, mailboxes), c.mailbox.ID, describeCandidate(c, keys))
+	}
+	fmt.Fprintf(&sb, "File with: email_move email_ids=[%s] mailbox_id=%s\n", anchor.ID, candidates[0].mailbox.ID)
+	return textResult(sb.String()), nil, nil
+}
+
+// suggestThread fetches the email and the other emails of its thread in
+// one request.
+func suggestThread(ctx context.Context, client JMAPClient, accountID, emailID jmap.ID) (*email.Email, []*email.Email, error) {
+	req := &jmap.Request{Context: ctx}
+	anchorCallID := req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        []jmap.ID{emailID},
+		Properties: []string{"id", "threadId", "from", "subject", "mailboxIds", "headers"},
+	})
+	threadCallID := req.Invoke(&thread.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: anchorCallID, Name: "Email/get", Path: "/list/*/threadId"},
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: threadCallID, Name: "Thread/get", Path: "/list/*/emailIds"},
+		Properties:   []string{"id", "mailboxIds"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(resp.Responses) < 3 {
+		return nil, nil, fmt.Errorf("expected 3 responses, got %d", len(resp.Responses))
+	}
+
+	var anchor *email.Email
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.List) == 0 {
+			return nil, nil, notFoundError([]jmap.ID{emailID}, "email %s not found", emailID)
+		}
+		anchor = args.List[0]
+	case *jmap.MethodError:
+		return nil, nil, args
+	default:
+		return nil, nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+	if me, ok := resp.Responses[1].Args.(*jmap.MethodError); ok {
+		return nil, nil, me
+	}
+
+	var others []*email.Email
+	switch args := resp.Responses[2].Args.(type) {
+	case *email.GetResponse:
+		for _, e := range args.List {
+			if e.ID != emailID {
+				others = append(others, e)
+			}
+		}
+	case *jmap.MethodError:
+		return nil, nil, args
+	default:
+		return nil, nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+	return anchor, others, nil
+}
+
+// rankMailboxes scores the mailboxes the similar messages are in, best
+// first. A message found several ways counts only the strongest.
+func rankMailboxes(anchor *email.Email, threadEmails, fromList, fromSender []*email.Email, mailboxes map[jmap.ID]*mailbox.Mailbox) []*mailboxCandidate {
+	byID := make(map[jmap.ID]*mailboxCandidate)
+	seen := map[jmap.ID]bool{anchor.ID: true}
+	count := func(emails []*email.Email, weight int, tally func(*mailboxCandidate)) {
+		for _, e := range emails {
+			if seen[e.ID] {
+				continue
+			}
+			seen[e.ID] = true
+			for id := range e.MailboxIDs {
+				mb := mailboxes[id]
+				if mb == nil || anchor.MailboxIDs[id] || !filingTarget(mb) {
+					continue
+				}
+				c := byID[id]
+				if c == nil {
+					c = &mailboxCandidate{mailbox: mb}
+					byID[id] = c
+				}
+				c.score += weight
+				tally(c)
+			}
+		}
+	}
+	count(threadEmails, suggestThreadWeight, func(c *mailboxCandidate) { c.thread++ })
+	count(fromList, suggestListWeight, func(c *mailboxCandidate) { c.list++ })
+	count(fromSender, suggestSenderWeight, func(c *mailboxCandidate) { c.sender++ })
+
+	out := make([]*mailboxCandidate, 0, len(byID))
+	for _, c := range byID {
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b *mailboxCandidate) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		return strings.Compare(string(a.mailbox.ID), string(b.mailbox.ID))
+	})
+	return out
+}
+
+// describeCandidate gives the evidence for c.
+func describeCandidate(c *mailboxCandidate, keys map[jmap.ID]triageKey) string {
+	var parts []string
+	if c.thread > 0 {
+		parts = append(parts, fmt.Sprintf("%d in this thread", c.thread))
+	}
+	if c.list > 0 {
+		parts = append(parts, fmt.Sprintf("%d from %s", c.list, keys["list"]))
+	}
+	if c.sender > 0 {
+		parts = append(parts, fmt.Sprintf("%d from %s", c.sender, keys["from"]))
+	}
+	return strings.Join(parts, ", ")
+}
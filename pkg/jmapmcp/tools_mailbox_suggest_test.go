@@ -0,0 +1,45 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMailboxSuggest(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "clients", "name": "Clients"})
+	fake.Add("Mailbox", map[string]any{"id": "acme", "name": "Acme", "parentId": "clients"})
+	fake.Add("Mailbox", map[string]any{"id": "misc", "name": "Misc"})
+
+	from := []any{map[string]any{"email": "bob@acme.example"}}
+	add := func(id, thread, mailboxID string) {
+		fake.Add("Email", map[string]any{"id": id, "threadId": thread, "from": from, "subject": "Contract", "receivedAt": "2025-01-01T00:00:00Z", "mailboxIds": map[string]any{mailboxID: true}})
+	}
+	add("new", "T1", "inbox")
+	add("t1", "T1", "acme")    // same thread
+	add("old1", "T2", "misc")  // same sender only
+	add("old2", "T3", "misc")  // same sender only
+	add("old3", "T4", "inbox") // never suggested
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"t1", "new"}})
+
+	res, _, _ := s.handleMailboxSuggest(context.Background(), nil, MailboxSuggestInput{EmailID: "new"})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("mailbox_suggest: %s", out)
+	}
+	acme := strings.Index(out, "1. Clients/Acme [mailbox: acme] — 1 in this thread")
+	misc := strings.Index(out, "2. Misc [mailbox: misc] — 2 from bob@acme.example")
+	if acme < 0 || misc < 0 {
+		t.Errorf("unexpected ranking:\n%s", out)
+	}
+	if strings.Contains(out, "Inbox") || !strings.Contains(out, "email_move email_ids=[new] mailbox_id=acme") {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+
+	res, _, _ = s.handleMailboxSuggest(context.Background(), nil, MailboxSuggestInput{EmailID: "missing"})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeNotFound {
+		t.Errorf("missing email: %s", resultText(res))
+	}
+}
@@ -0,0 +1,68 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMailboxSetCreatePath(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "clients", "name": "Clients"})
+	ctx := context.Background()
+
+	res, _, _ := s.handleMailboxSet(ctx, nil, MailboxSetInput{Create: map[string]MailboxSetCreate{
+		"acme":  {Path: "Clients/Acme/2025"},
+		"acme2": {Path: "Clients/Acme/2026"},
+	}})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("mailbox_set: %s", out)
+	}
+	for _, want := range []string{"Created mailbox Clients/Acme [id:", "Created mailbox Clients/Acme/2025 [id:", "Created mailbox Clients/Acme/2026 [id:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("result lacks %q:\n%s", want, out)
+		}
+	}
+
+	byName := map[string]map[string]any{}
+	for _, id := range fake.Objects("Mailbox") {
+		mb := fake.Object("Mailbox", id)
+		byName[mb["name"].(string)] = mb
+	}
+	if len(byName) != 4 {
+		t.Fatalf("mailboxes = %v, want Clients, Acme, 2025, 2026", byName)
+	}
+	acme := byName["Acme"]
+	if acme["parentId"] != "clients" {
+		t.Errorf("Acme parentId = %v, want clients", acme["parentId"])
+	}
+	for _, year := range []string{"2025", "2026"} {
+		if byName[year]["parentId"] != acme["id"] {
+			t.Errorf("%s parentId = %v, want %v", year, byName[year]["parentId"], acme["id"])
+		}
+	}
+
+	// A path that exists in full is reported, not created again.
+	res, _, _ = s.handleMailboxSet(ctx, nil, MailboxSetInput{Create: map[string]MailboxSetCreate{"again": {Path: "Acme/2025", ParentID: "clients"}}})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Mailbox Clients/Acme/2025 already exists") {
+		t.Errorf("existing path: %s", out)
+	}
+	if n := len(fake.Objects("Mailbox")); n != 4 {
+		t.Errorf("%d mailboxes after repeating the path, want 4", n)
+	}
+}
+
+func TestMailboxSetCreateInvalid(t *testing.T) {
+	s, _ := newFakeServer(t)
+	for name, c := range map[string]MailboxSetCreate{
+		"neither":       {},
+		"both":          {Name: "A", Path: "B/C"},
+		"empty segment": {Path: "A//B"},
+	} {
+		res, _, _ := s.handleMailboxSet(context.Background(), nil, MailboxSetInput{Create: map[string]MailboxSetCreate{"x": c}})
+		if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeInvalidArguments {
+			t.Errorf("%s: %s", name, resultText(res))
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// MailMergePolicy bounds mail_merge, on top of the SendPolicy every
+// message is checked against.
+type MailMergePolicy struct {
+	MaxRecipients int           // max recipients (messages) per call
+	BatchSize     int           // messages created and submitted per JMAP request
+	BatchInterval time.Duration // pause between batches
+}
+
+// mergePlaceholder matches {{name}} in mail_merge templates.
+var mergePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// mergeHeaderBytes estimates the headers and MIME structure of one merged
+// message, for the quota check before the merge.
+const mergeHeaderBytes = 1024
+
+// --- mail_merge ---
+
+type MailMergeRecipient struct {
+	Email string            `json:"email" jsonschema:"Recipient email address, also available as {{email}}"`
+	Name  string            `json:"name,omitempty" jsonschema:"Recipient display name, also available as {{name}}"`
+	Vars  map[string]string `json:"vars,omitempty" jsonschema:"Values for this recipient's {{placeholders}}"`
+}
+
+type MailMergeInput struct {
+	Subject    string               `json:"subject" jsonschema:"Subject template; {{placeholders}} are replaced per recipient"`
+	Body       string               `json:"body" jsonschema:"Plain text body template; {{placeholders}} are replaced per recipient"`
+	Recipients []MailMergeRecipient `json:"recipients" jsonschema:"One entry per message; each recipient gets an individual message addressed only to them"`
+	IdentityID string               `json:"identity_id,omitempty" jsonschema:"Sender identity ID (default: the first identity)"`
+	Send       bool                 `json:"send,omitempty" jsonschema:"Create and submit the messages; set only after the user confirms the preview (default false: validate and preview only)"`
+}
+
+var mailMergeTool = &mcp.Tool{
+	Name:        "mail_merge",
+	Description: "Send an individually personalized message to each of a list of recipients from a subject and body template with {{placeholders}} ({{email}}, {{name}}, and each recipient's vars). Without send it validates every message against the send policy, the hourly send quota, and the account's storage quota and previews the first; with send=true (after the user confirms) it creates and submits the messages in rate-limited batches and reports each recipient's status. All recipients are validated before anything is sent.",
+	Annotations: mutatingAnnotations,
+}
+
+// mergeMessage is one rendered mail_merge message.
+type mergeMessage struct {
+	to      *mail.Address
+	subject string
+	body    string
+	draft   *email.Email
+	emailID jmap.ID
+	blobID  jmap.ID // of the created draft, for the pre-send hook
+	outcome string  // sent, queued, failed, or not sent; empty until processed
+	detail  string
+}
+
+func (s *Server) handleMailMerge(ctx context.Context, _ *mcp.CallToolRequest, in MailMergeInput) (*mcp.CallToolResult, any, error) {
+	messages, err := s.renderMerge(in)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	owner := s.ownerOf(token)
+	if left := s.sendPolicy.remaining(owner, time.Now()); s.outbox == nil && left >= 0 && left < len(messages) {
+		return errorResult(&policyError{Policy: "send", Rule: "max_sends_per_hour",
+			Detail: fmt.Sprintf("%d messages exceed the %d sends left this hour", len(messages), left)}), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	draftsID, sentID, id, err := s.mergeTarget(ctx, client, accountID, jmap.ID(in.IdentityID))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	var added []string
+	for _, m := range messages {
+		m.draft = &email.Email{
+			MailboxIDs: map[jmap.ID]bool{draftsID: true},
+			Keywords:   map[string]bool{"$draft": true},
+			To:         []*mail.Address{m.to},
+			Subject:    m.subject,
+			BodyValues: map[string]*email.BodyValue{
+				"body": {Value: m.body},
+			},
+			TextBody: []*email.BodyPart{
+				{PartID: "body", Type: "text/plain"},
+			},
+		}
+		added = s.applyDraftDefaults(m.draft, id)
+		if err := s.sendPolicy.checkRecipients(draftRecipients(m.draft)); err != nil {
+			return errorResult(fmt.Errorf("recipient %s: %w", m.to.Email, err)), nil, nil
+		}
+	}
+
+	growth := mailGrowth{what: fmt.Sprintf("mail_merge of %d messages", len(messages)), emails: uint64(len(messages))}
+	for _, m := range messages {
+		growth.octets += uint64(len(m.subject) + len(m.body) + mergeHeaderBytes)
+	}
+	if err := checkQuota(ctx, client, accountID, growth); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if !in.Send {
+		return textResult(s.formatMergePreview(messages, id, added)), nil, nil
+	}
+
+	var sendErr error
+	for start := 0; start < len(messages); start += s.mailMerge.BatchSize {
+		if start > 0 && s.mailMerge.BatchInterval > 0 {
+			select {
+			case <-time.After(s.mailMerge.BatchInterval):
+			case <-ctx.Done():
+				sendErr = ctx.Err()
+			}
+		}
+		if sendErr != nil {
+			break
+		}
+		batch := messages[start:min(start+s.mailMerge.BatchSize, len(messages))]
+		if sendErr = s.sendMergeBatch(ctx, client, accountID, draftsID, sentID, id.ID, id.Email, owner, batch); sendErr != nil {
+			break
+		}
+	}
+	return textResult(formatMergeReport(messages, sendErr)), nil, nil
+}
+
+// renderMerge validates in against the merge policy and renders each
+// recipient's message. Any missing placeholder value fails the whole call.
+func (s *Server) renderMerge(in MailMergeInput) ([]*mergeMessage, error) {
+	if in.Subject == "" || in.Body == "" {
+		return nil, invalidArgument("subject and body are required")
+	}
+	if len(in.Recipients) == 0 {
+		return nil, invalidArgument("recipients is required")
+	}
+	if limit := s.mailMerge.MaxRecipients; limit > 0 && len(in.Recipients) > limit {
+		return nil, &policyError{Policy: "mail_merge", Rule: "max_recipients",
+			Detail: fmt.Sprintf("%d recipients exceeds the limit of %d per mail_merge call", len(in.Recipients), limit)}
+	}
+
+	seen := make(map[string]bool)
+	var missing []string
+	messages := make([]*mergeMessage, len(in.Recipients))
+	for i, r := range in.Recipients {
+		addr := strings.TrimSpace(r.Email)
+		if !strings.Contains(addr, "@") {
+			return nil, invalidArgument("recipient %d: %q is not an email address", i+1, r.Email)
+		}
+		key := strings.ToLower(addr)
+		if seen[key] {
+			return nil, invalidArgument("recipient %s is listed more than once", addr)
+		}
+		seen[key] = true
+
+		vars := map[string]string{"email": addr, "name": r.Name}
+		for k, v := range r.Vars {
+			vars[k] = v
+		}
+		render := func(tmpl string) string {
+			return mergePlaceholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+				key := mergePlaceholder.FindStringSubmatch(m)[1]
+				v, ok := vars[key]
+				if !ok || v == "" {
+					missing = append(missing, fmt.Sprintf("%s: {{%s}}", addr, key))
+				}
+				return v
+			})
+		}
+		messages[i] = &mergeMessage{
+			to:      &mail.Address{Name: r.Name, Email: addr},
+			subject: render(in.Subject),
+			body:    render(in.Body),
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, invalidArgument("placeholders without a value: %s", strings.Join(slices.Compact(missing), ", "))
+	}
+	return messages, nil
+}
+
+// mergeTarget returns the Drafts and Sent mailboxes and the sender
+// identity (identityID, or the one sendPolicy.senderIdentity picks) from
+// one request.
+func (s *Server) mergeTarget(ctx context.Context, client JMAPClient, accountID, identityID jmap.ID) (jmap.ID, jmap.ID, *identity.Identity, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "role"}})
+	req.Invoke(&identity.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(resp.Responses) < 2 {
+		return "", "", nil, fmt.Errorf("expected 2 discovery responses, got %d", len(resp.Responses))
+	}
+
+	var draftsID, sentID jmap.ID
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			switch mb.Role {
+			case mailbox.RoleDrafts:
+				draftsID = mb.ID
+			case mailbox.RoleSent:
+				sentID = mb.ID
+			}
+		}
+	case *jmap.MethodError:
+		return "", "", nil, args
+	default:
+		return "", "", nil, fmt.Errorf("unexpected mailbox response type: %T", args)
+	}
+	if draftsID == "" {
+		return "", "", nil, fmt.Errorf("no Drafts mailbox found")
+	}
+	if sentID == "" {
+		return "", "", nil, fmt.Errorf("no Sent mailbox found")
+	}
+
+	switch args := resp.Responses[1].Args.(type) {
+	case *identity.GetResponse:
+		if identityID == "" {
+			id, err := s.sendPolicy.senderIdentity(args.List)
+			if err != nil {
+				return "", "", nil, err
+			}
+			if id != nil {
+				return draftsID, sentID, id, nil
+			}
+			return "", "", nil, fmt.Errorf("no sender identities available")
+		}
+		for _, id := range args.List {
+			if id.ID == identityID {
+				if err := s.sendPolicy.checkSender(id.Email); err != nil {
+					return "", "", nil, err
+				}
+				return draftsID, sentID, id, nil
+			}
+		}
+		return "", "", nil, notFoundError([]jmap.ID{identityID}, "identity not found: %s", identityID)
+	case *jmap.MethodError:
+		return "", "", nil, args
+	default:
+		return "", "", nil, fmt.Errorf("unexpected identity response type: %T", args)
+	}
+}
+
+// sendMergeBatch creates the drafts of batch in one Email/set and then
+// submits or queues them as identityID, whose address is from, recording
+// each message's status. It returns an error only when the merge must
+// stop, e.g. when the hourly send quota runs out.
+func (s *Server) sendMergeBatch(ctx context.Context, client JMAPClient, accountID, draftsID, sentID, identityID jmap.ID, from, owner string, batch []*mergeMessage) error {
+	create := make(map[jmap.ID]*email.Email, len(batch))
+	for i, m := range batch {
+		create[jmap.ID(fmt.Sprintf("m%d", i))] = m.draft
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Set{Account: accountID, Create: create})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses) == 0 {
+		return fmt.Errorf("empty response for Email/set")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		for i, m := range batch {
+			cid := jmap.ID(fmt.Sprintf("m%d", i))
+			if se, ok := args.NotCreated[cid]; ok {
+				m.outcome, m.detail = "failed", "draft creation failed: "+setErrorText(se)
+			} else if created, ok := args.Created[cid]; ok {
+				m.emailID, m.blobID = created.ID, created.BlobID
+			}
+		}
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	if s.outbox != nil {
+		for _, m := range batch {
+			if m.emailID == "" {
+				continue
+			}
+			id := s.outbox.add(&outboxEntry{
+				Owner:      owner,
+				Endpoint:   EndpointFromContext(ctx),
+				AccountID:  accountID,
+				EmailID:    m.emailID,
+				IdentityID: identityID,
+				Subject:    m.subject,
+				To:         formatAddresses(draftRecipients(m.draft)),
+				QueuedAt:   time.Now(),
+			})
+			m.outcome, m.detail = "queued", "awaiting approval as "+id
+		}
+		return nil
+	}
+
+	submissions := make(map[jmap.ID]*emailsubmission.EmailSubmission)
+	updates := make(map[jmap.ID]jmap.Patch)
+	var quotaErr error
+	for i, m := range batch {
+		if m.emailID == "" {
+			continue
+		}
+		envelope, err := submissionEnvelope(client.Session(), accountID, from, draftRecipients(m.draft))
+		if err != nil {
+			m.outcome, m.detail = "not sent", err.Error()
+			continue
+		}
+		sentAt := time.Now()
+		if quotaErr == nil {
+			quotaErr = s.sendPolicy.reserveSend(owner, sentAt)
+		}
+		if quotaErr != nil {
+			m.outcome, m.detail = "not sent", "hourly send quota reached; the draft is kept"
+			continue
+		}
+		id, err := s.applyPreSendHook(ctx, client, accountID, m.emailID, m.blobID, draftsID, draftRecipients(m.draft))
+		if err != nil {
+			s.sendPolicy.releaseSend(owner, sentAt)
+			m.outcome, m.detail = "not sent", err.Error()
+			continue
+		}
+		m.emailID = id
+		cid := jmap.ID(fmt.Sprintf("s%d", i))
+		submissions[cid] = &emailsubmission.EmailSubmission{IdentityID: identityID, EmailID: m.emailID, Envelope: envelope}
+		updates["#"+cid] = jmap.Patch{
+			"mailboxIds/" + string(draftsID): nil,
+			"mailboxIds/" + string(sentID):   true,
+			"keywords/$draft":                nil,
+		}
+	}
+	if len(submissions) == 0 {
+		return quotaErr
+	}
+
+	req = &jmap.Request{Context: ctx}
+	req.Invoke(&emailsubmission.Set{Account: accountID, Create: submissions, OnSuccessUpdateEmail: updates})
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Responses) == 0 {
+		return fmt.Errorf("empty response for EmailSubmission/set")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *emailsubmission.SetResponse:
+		for i, m := range batch {
+			cid := jmap.ID(fmt.Sprintf("s%d", i))
+			if _, ok := submissions[cid]; !ok {
+				continue
+			}
+			if se, ok := args.NotCreated[cid]; ok {
+				m.outcome, m.detail = "failed", "submission failed: "+setErrorText(se)+"; the draft is kept"
+			} else {
+				m.outcome = "sent"
+			}
+		}
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected response type: %T", args)
+	}
+	return quotaErr
+}
+
+func (s *Server) formatMergePreview(messages []*mergeMessage, id *identity.Identity, added []string) string {
+	var sb strings.Builder
+	batches := (len(messages) + s.mailMerge.BatchSize - 1) / s.mailMerge.BatchSize
+	fmt.Fprintf(&sb, "Mail merge preview: %d message(s) from %s in %d batch(es) of up to %d", len(messages), id.Email, batches, s.mailMerge.BatchSize)
+	if batches > 1 && s.mailMerge.BatchInterval > 0 {
+		fmt.Fprintf(&sb, ", %s apart", s.mailMerge.BatchInterval)
+	}
+	sb.WriteString(". All recipients pass the send policy.\n")
+	if len(added) > 0 {
+		sb.WriteString(formatDraftDefaults(added))
+	}
+	first := messages[0]
+	fmt.Fprintf(&sb, "\nFirst message:\nTo: %s\nSubject: %s\n\n%s\n", formatAddresses([]*mail.Address{first.to}), first.subject, first.body)
+	if len(messages) > 1 {
+		rest := make([]string, 0, len(messages)-1)
+		for _, m := range messages[1:] {
+			rest = append(rest, m.to.Email)
+		}
+		fmt.Fprintf(&sb, "\nAlso to: %s\n", strings.Join(rest, ", "))
+	}
+	if s.outbox != nil {
+		sb.WriteString("\nNothing created yet. After the user confirms, call mail_merge again with send=true; the messages will be queued for approval in the outbox.\n")
+	} else {
+		sb.WriteString("\nNot sent. After the user confirms, call mail_merge again with send=true to send them.\n")
+	}
+	return sb.String()
+}
+
+// formatMergeReport renders the status of each message; messages never
+// reached because the merge stopped early are listed as not sent.
+func formatMergeReport(messages []*mergeMessage, stopErr error) string {
+	counts := make(map[string]int)
+	var lines []string
+	for _, m := range messages {
+		outcome, detail := m.outcome, m.detail
+		if outcome == "" {
+			outcome, detail = "not sent", "the merge stopped before this message"
+		}
+		counts[outcome]++
+		line := fmt.Sprintf("  %s: %s", m.to.Email, outcome)
+		if detail != "" {
+			line += ", " + detail
+		}
+		if m.emailID != "" {
+			line += fmt.Sprintf(" [id: %s]", m.emailID)
+		}
+		lines = append(lines, line)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Mail merge: %d sent, %d queued, %d failed, %d not sent\n", counts["sent"], counts["queued"], counts["failed"], counts["not sent"])
+	if stopErr != nil {
+		fmt.Fprintf(&sb, "Stopped: %v\n", stopErr)
+	}
+	sb.WriteString(strings.Join(lines, "\n"))
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,105 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+// newMergeServer returns a server with mail_merge in batches of two and
+// the mailboxes and identity it needs.
+func newMergeServer(t *testing.T, opts ...Option) (*Server, *jmapfake.Server) {
+	t.Helper()
+	opts = append([]Option{WithEmailSubmission(), WithMailMerge(MailMergePolicy{MaxRecipients: 5, BatchSize: 2})}, opts...)
+	s, fake := newFakeServer(t, opts...)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	return s, fake
+}
+
+func mergeInput(send bool) MailMergeInput {
+	return MailMergeInput{
+		Subject: "Hello {{name}}",
+		Body:    "Dear {{name}}, your code is {{code}}.",
+		Recipients: []MailMergeRecipient{
+			{Email: "ann@example.org", Name: "Ann", Vars: map[string]string{"code": "A1"}},
+			{Email: "ben@example.org", Name: "Ben", Vars: map[string]string{"code": "B2"}},
+			{Email: "cat@example.org", Name: "Cat", Vars: map[string]string{"code": "C3"}},
+		},
+		Send: send,
+	}
+}
+
+func TestMailMergePreview(t *testing.T) {
+	s, fake := newMergeServer(t)
+
+	res, _, _ := s.handleMailMerge(context.Background(), nil, mergeInput(false))
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Subject: Hello Ann") || !strings.Contains(out, "your code is A1.") || !strings.Contains(out, "2 batch(es)") {
+		t.Fatalf("preview:\n%s", out)
+	}
+	if n := len(fake.Objects("Email")); n != 0 {
+		t.Errorf("preview created %d emails", n)
+	}
+}
+
+func TestMailMergeSend(t *testing.T) {
+	s, fake := newMergeServer(t)
+
+	res, _, _ := s.handleMailMerge(context.Background(), nil, mergeInput(true))
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Mail merge: 3 sent, 0 queued, 0 failed, 0 not sent") {
+		t.Fatalf("send:\n%s", out)
+	}
+	submissions := 0
+	for _, c := range fake.Calls() {
+		if c == "EmailSubmission/set" {
+			submissions++
+		}
+	}
+	if submissions != 2 {
+		t.Errorf("EmailSubmission/set calls = %d, want one per batch (2)", submissions)
+	}
+	for _, id := range fake.Objects("Email") {
+		e := fake.Object("Email", id)
+		if e["subject"] == "Hello Ben" {
+			if mb, _ := e["mailboxIds"].(map[string]any); mb["sent"] != true || mb["drafts"] != nil {
+				t.Errorf("Ben's message mailboxes = %v, want Sent only", mb)
+			}
+			return
+		}
+	}
+	t.Error("no message rendered for Ben")
+}
+
+func TestMailMergeRefusals(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newMergeServer(t, WithSendPolicy(SendPolicy{MaxSendsPerHour: 2, BlockedAddresses: []string{"*@blocked.example"}}))
+
+	in := mergeInput(false)
+	in.Recipients[1].Vars = nil
+	res, _, _ := s.handleMailMerge(ctx, nil, in)
+	if te := res.StructuredContent.(*toolError); te.Code != codeInvalidArguments || !strings.Contains(te.Message, "ben@example.org: {{code}}") {
+		t.Errorf("missing variable = %+v", te)
+	}
+
+	res, _, _ = s.handleMailMerge(ctx, nil, mergeInput(true))
+	if te := res.StructuredContent.(*toolError); te.Code != codeRateLimited {
+		t.Errorf("3 messages with 2 sends left = %+v, want rate_limited", te)
+	}
+
+	in = mergeInput(false)
+	in.Recipients = in.Recipients[:1]
+	in.Recipients[0].Email = "x@blocked.example"
+	res, _, _ = s.handleMailMerge(ctx, nil, in)
+	if te := res.StructuredContent.(*toolError); te.Code != codePolicyViolation || te.Rule != "blocked_address" {
+		t.Errorf("blocked recipient = %+v", te)
+	}
+
+	if p := PermissionNoSend; p.allows(mailMergeTool) {
+		t.Error("no-send credentials may call mail_merge")
+	}
+}
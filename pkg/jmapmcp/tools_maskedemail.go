@@ -0,0 +1,272 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/maskedemail"
+)
+
+// maskedEmailAccount returns the account holding masked emails, or an error
+// when the server does not offer the extension.
+func maskedEmailAccount(client JMAPClient) (jmap.ID, error) {
+	if _, ok := client.Session().RawCapabilities[maskedemail.URI]; !ok {
+		return "", capabilityMissing(client.Session(), maskedemail.URI)
+	}
+	accountID := client.Session().PrimaryAccounts[maskedemail.URI]
+	if accountID == "" {
+		return "", fmt.Errorf("no primary masked email account")
+	}
+	return accountID, nil
+}
+
+// --- masked_email_list ---
+
+type MaskedEmailListInput struct {
+	Domain string `json:"domain,omitempty" jsonschema:"Only addresses whose site domain or description contains this text"`
+	State  string `json:"state,omitempty" jsonschema:"Only addresses in this state: pending, enabled, disabled, deleted (default: all but deleted)"`
+	Limit  int    `json:"limit,omitempty" jsonschema:"Maximum addresses to return, newest first (default 50)"`
+}
+
+var maskedEmailListTool = &mcp.Tool{
+	Name:        "masked_email_list",
+	Description: "List Fastmail masked email addresses with their state, site, and last use. Requires a server advertising the MaskedEmail extension.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleMaskedEmailList(ctx context.Context, _ *mcp.CallToolRequest, in MaskedEmailListInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID, err := maskedEmailAccount(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&maskedemail.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for MaskedEmail/get")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *maskedemail.GetResponse:
+		list := filterMaskedEmails(args.List, in.Domain, in.State)
+		if len(list) == 0 {
+			return textResult("No masked email addresses found"), nil, nil
+		}
+		limit := in.Limit
+		if limit <= 0 {
+			limit = 50
+		}
+		var sb strings.Builder
+		if len(list) > limit {
+			fmt.Fprintf(&sb, "%d masked address(es), showing the %d newest:\n", len(list), limit)
+			list = list[:limit]
+		} else {
+			fmt.Fprintf(&sb, "%d masked address(es):\n", len(list))
+		}
+		for _, m := range list {
+			sb.WriteString("\n")
+			sb.WriteString(formatMaskedEmail(s.locale, m))
+		}
+		return textResult(sb.String()), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// filterMaskedEmails keeps addresses matching domain (case-insensitive,
+// against forDomain and description) and state, newest first. An empty state
+// hides deleted addresses.
+func filterMaskedEmails(list []*maskedemail.MaskedEmail, domain, state string) []*maskedemail.MaskedEmail {
+	domain = strings.ToLower(domain)
+	var out []*maskedemail.MaskedEmail
+	for _, m := range list {
+		if state == "" && m.State == maskedemail.StateDeleted {
+			continue
+		}
+		if state != "" && m.State != state {
+			continue
+		}
+		if domain != "" && !strings.Contains(strings.ToLower(m.ForDomain), domain) && !strings.Contains(strings.ToLower(m.Description), domain) {
+			continue
+		}
+		out = append(out, m)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].CreatedAt, out[j].CreatedAt
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.After(*b)
+	})
+	return out
+}
+
+// formatMaskedEmail renders one masked address.
+func formatMaskedEmail(l textStyle, m *maskedemail.MaskedEmail) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s [id: %s] (%s)\n", m.Email, m.ID, m.State)
+	if m.ForDomain != "" {
+		fmt.Fprintf(&sb, "  For: %s\n", m.ForDomain)
+	}
+	if m.Description != "" {
+		fmt.Fprintf(&sb, "  Description: %s\n", m.Description)
+	}
+	if m.CreatedAt != nil {
+		fmt.Fprintf(&sb, "  Created: %s\n", l.dateTime(*m.CreatedAt))
+	}
+	if m.LastMessageAt != nil {
+		fmt.Fprintf(&sb, "  Last message: %s\n", l.dateTime(*m.LastMessageAt))
+	}
+	return sb.String()
+}
+
+// --- masked_email_create ---
+
+type MaskedEmailCreateInput struct {
+	ForDomain   string `json:"for_domain" jsonschema:"Origin of the site the address is for (e.g. https://shop.example.com)"`
+	Description string `json:"description,omitempty" jsonschema:"Note shown next to the address (e.g. the service name)"`
+	EmailPrefix string `json:"email_prefix,omitempty" jsonschema:"Optional prefix for the generated address (a-z, 0-9, underscore; max 64)"`
+	Pending     bool   `json:"pending,omitempty" jsonschema:"Create in pending state: the address is removed if no mail arrives within 24 hours"`
+}
+
+var maskedEmailCreateTool = &mcp.Tool{
+	Name:        "masked_email_create",
+	Description: "Create a new Fastmail masked email address for a site or signup. Mail to it is delivered to the account until it is disabled. Requires a server advertising the MaskedEmail extension.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleMaskedEmailCreate(ctx context.Context, _ *mcp.CallToolRequest, in MaskedEmailCreateInput) (*mcp.CallToolResult, any, error) {
+	if in.ForDomain == "" && in.Description == "" {
+		return errorResult(invalidArgument("for_domain or description is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID, err := maskedEmailAccount(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	state := maskedemail.StateEnabled
+	if in.Pending {
+		state = maskedemail.StatePending
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&maskedemail.Set{
+		Account: accountID,
+		Create: map[jmap.ID]*maskedemail.MaskedEmail{
+			"new": {
+				State:       state,
+				ForDomain:   in.ForDomain,
+				Description: in.Description,
+				EmailPrefix: in.EmailPrefix,
+			},
+		},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for MaskedEmail/set")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *maskedemail.SetResponse:
+		if se, ok := args.NotCreated["new"]; ok {
+			return errorResult(setFailure("create masked email", se)), nil, nil
+		}
+		created, ok := args.Created["new"]
+		if !ok {
+			return errorResult(fmt.Errorf("masked email was not created")), nil, nil
+		}
+		return textResult(fmt.Sprintf("Created masked email %s [id: %s] (%s)", created.Email, created.ID, state)), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// --- masked_email_disable ---
+
+type MaskedEmailDisableInput struct {
+	ID     string `json:"id" jsonschema:"Masked email ID from masked_email_list"`
+	Delete bool   `json:"delete,omitempty" jsonschema:"Delete instead of disable: mail to the address bounces rather than going to Trash"`
+}
+
+var maskedEmailDisableTool = &mcp.Tool{
+	Name:        "masked_email_disable",
+	Description: "Disable a Fastmail masked email address so mail to it goes to Trash (or delete it so mail bounces). Requires a server advertising the MaskedEmail extension.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleMaskedEmailDisable(ctx context.Context, _ *mcp.CallToolRequest, in MaskedEmailDisableInput) (*mcp.CallToolResult, any, error) {
+	if in.ID == "" {
+		return errorResult(invalidArgument("id is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID, err := maskedEmailAccount(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	state := maskedemail.StateDisabled
+	if in.Delete {
+		state = maskedemail.StateDeleted
+	}
+
+	id := jmap.ID(in.ID)
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&maskedemail.Set{
+		Account: accountID,
+		Update:  map[jmap.ID]jmap.Patch{id: {"state": state}},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for MaskedEmail/set")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *maskedemail.SetResponse:
+		if se, ok := args.NotUpdated[id]; ok {
+			return errorResult(setFailure(fmt.Sprintf("update masked email %s", id), se)), nil, nil
+		}
+		return textResult(fmt.Sprintf("Masked email %s is now %s", id, state)), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
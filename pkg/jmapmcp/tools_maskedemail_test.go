@@ -0,0 +1,48 @@
+package jmapmcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/maskedemail"
+)
+
+func TestFilterMaskedEmails(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+	list := []*maskedemail.MaskedEmail{
+		{ID: "a", Email: "a@fastmail.com", State: maskedemail.StateEnabled, ForDomain: "https://shop.example.com", CreatedAt: &t1},
+		{ID: "b", Email: "b@fastmail.com", State: maskedemail.StateDisabled, Description: "Example newsletter", CreatedAt: &t2},
+		{ID: "c", Email: "c@fastmail.com", State: maskedemail.StateDeleted, ForDomain: "https://old.example.com"},
+		{ID: "d", Email: "d@fastmail.com", State: maskedemail.StatePending, ForDomain: "https://other.test"},
+	}
+
+	tests := []struct {
+		name   string
+		domain string
+		state  string
+		want   []string
+	}{
+		{"default hides deleted, newest first", "", "", []string{"b", "a", "d"}},
+		{"domain matches forDomain and description", "EXAMPLE", "", []string{"b", "a"}},
+		{"state", "", maskedemail.StateDeleted, []string{"c"}},
+		{"domain and state", "example", maskedemail.StateEnabled, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterMaskedEmails(list, tt.domain, tt.state)
+			var ids []string
+			for _, m := range got {
+				ids = append(ids, string(m.ID))
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("got %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxMessageIDMatches bounds the copies of one message listed.
+const maxMessageIDMatches = 20
+
+// --- email_find_by_message_id ---
+
+type EmailFindByMessageIDInput struct {
+	MessageID string `json:"message_id" jsonschema:"RFC 5322 Message-ID, with or without angle brackets (e.g. <CAF1234@mail.example.com>)"`
+}
+
+var emailFindByMessageIDTool = &mcp.Tool{
+	Name:        "email_find_by_message_id",
+	Description: "Resolve an RFC 5322 Message-ID (e.g. one recorded by a ticketing system or quoted in a References header) to the JMAP email IDs holding that message, with each copy's thread ID, mailboxes, subject, sender, and date. Uses the server's header search.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailFindByMessageID(ctx context.Context, _ *mcp.CallToolRequest, in EmailFindByMessageIDInput) (*mcp.CallToolResult, any, error) {
+	messageID := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(in.MessageID), "<"), ">")
+	if messageID == "" {
+		return errorResult(invalidArgument("message_id is required")), nil, nil
+	}
+	if strings.ContainsAny(messageID, "<> \t") {
+		return errorResult(invalidArgument("message_id %q: want a single Message-ID", in.MessageID)), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+	q := s.quirksFor(client)
+	if q.has(quirkNoHeaderFilter) {
+		return errorResult(errNoHeaderSearch), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "name"}})
+	queryCallID := req.Invoke(&email.Query{
+		Account: accountID,
+		Filter:  &email.FilterCondition{Header: []string{"Message-ID", "<" + messageID + ">"}},
+		Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+		Limit:   maxMessageIDMatches,
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+		Properties:   []string{"id", "threadId", "messageId", "mailboxIds", "subject", "from", "receivedAt"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) < 3 {
+		return errorResult(fmt.Errorf("missing Email/get response in query chain")), nil, nil
+	}
+
+	names := map[jmap.ID]string{}
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			names[mb.ID] = mb.Name
+		}
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	if me, ok := resp.Responses[1].Args.(*jmap.MethodError); ok {
+		if isUnsupportedFilter(me) {
+			q.learn(quirkNoHeaderFilter)
+			return errorResult(errNoHeaderSearch), nil, nil
+		}
+		return errorResult(me), nil, nil
+	}
+
+	var found []*email.Email
+	switch args := resp.Responses[2].Args.(type) {
+	case *email.GetResponse:
+		// The header filter matches substrings; keep exact Message-IDs.
+		for _, e := range args.List {
+			if slices.Contains(e.MessageID, messageID) {
+				found = append(found, e)
+			}
+		}
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+	if len(found) == 0 {
+		return errorResult(notFoundError([]string{messageID}, "no email with Message-ID <%s> in this account", messageID)), nil, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Message-ID <%s>: %d email(s)\n\n", messageID, len(found))
+	for _, e := range found {
+		fmt.Fprintf(&sb, "Email: %s\n", e.ID)
+		fmt.Fprintf(&sb, "  Thread: %s\n", e.ThreadID)
+		var boxes []string
+		for id := range e.MailboxIDs {
+			if name := names[id]; name != "" {
+				boxes = append(boxes, name)
+			} else {
+				boxes = append(boxes, string(id))
+			}
+		}
+		slices.Sort(boxes)
+		fmt.Fprintf(&sb, "  Mailboxes: %s\n", strings.Join(boxes, ", "))
+		fmt.Fprintf(&sb, "  Subject: %s\n", e.Subject)
+		if len(e.From) > 0 {
+			fmt.Fprintf(&sb, "  From: %s\n", formatAddresses(e.From))
+		}
+		if e.ReceivedAt != nil {
+			fmt.Fprintf(&sb, "  Received: %s\n", s.locale.dateTime(*e.ReceivedAt))
+		}
+	}
+	return textResult(sb.String()), nil, nil
+}
@@ -0,0 +1,33 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEmailFindByMessageID(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	for id, mid := range map[string]string{"e1": "abc@mail.example.com", "e2": "xabc@mail.example.com"} {
+		addEmail(fake, id, "Ticket "+id, "", 1)
+		e := fake.Object("Email", id)
+		e["threadId"] = "t-" + id
+		e["messageId"] = []any{mid}
+		e["headers"] = []any{map[string]any{"name": "Message-ID", "value": "<" + mid + ">"}}
+		fake.Add("Email", e)
+	}
+
+	res, _, _ := s.handleEmailFindByMessageID(ctx, nil, EmailFindByMessageIDInput{MessageID: "<abc@mail.example.com>"})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "1 email(s)") || !strings.Contains(out, "Email: e1") ||
+		!strings.Contains(out, "Thread: t-e1") || !strings.Contains(out, "Mailboxes: Inbox") || strings.Contains(out, "e2") {
+		t.Fatalf("lookup:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailFindByMessageID(ctx, nil, EmailFindByMessageIDInput{MessageID: "missing@example.com"})
+	if te, ok := res.StructuredContent.(*toolError); !ok || te.Code != codeNotFound {
+		t.Errorf("unknown Message-ID:\n%s", resultText(res))
+	}
+}
@@ -0,0 +1,172 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// mutedRuleKey names the managed Sieve rule enforcing the muted list.
+var mutedRuleKey = triageKey{from: "muted senders"}
+
+// --- sender_mute ---
+
+type SenderMuteInput struct {
+	Addresses []string `json:"addresses,omitempty" jsonschema:"Sender addresses to mute, or *@domain for everyone at a domain; omit to list the muted senders"`
+	Unmute    bool     `json:"unmute,omitempty" jsonschema:"Remove the addresses from the muted list instead of adding them"`
+	Sieve     string   `json:"sieve,omitempty" jsonschema:"Also enforce the whole muted list on the server with a Sieve rule: discard (drop on arrival) or fileinto (file into mailbox_id, default Junk). Requires -enable-sieve; set only after the user confirms"`
+	MailboxID string   `json:"mailbox_id,omitempty" jsonschema:"Mailbox the fileinto rule files into (default: the Junk mailbox)"`
+}
+
+var senderMuteTool = &mcp.Tool{
+	Name:        "sender_mute",
+	Description: "Mute or unmute senders (addresses or *@domain). The muted list is kept by this server across sessions, and email_query hides mail from muted senders unless include_muted is set or from names one. Locally, muting changes nothing on the mail server; with sieve=discard or sieve=fileinto (after the user confirms) it also installs a rule in the managed region of the active Sieve script that enforces the whole list on arrival. Without addresses, lists the muted senders.",
+	Annotations: destructiveAnnotations,
+}
+
+func (s *Server) handleSenderMute(ctx context.Context, _ *mcp.CallToolRequest, in SenderMuteInput) (*mcp.CallToolResult, any, error) {
+	switch in.Sieve {
+	case "", "discard", "fileinto":
+	default:
+		return errorResult(invalidArgument("sieve must be discard or fileinto, got %q", in.Sieve)), nil, nil
+	}
+	if in.Sieve != "" && !s.enableSieve {
+		return errorResult(invalidArgument("sieve enforcement requires the server to be started with -enable-sieve")), nil, nil
+	}
+	if in.MailboxID != "" && in.Sieve != "fileinto" {
+		return errorResult(invalidArgument("mailbox_id applies only to sieve=fileinto")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	user := client.Session().Username
+
+	var sb strings.Builder
+	switch {
+	case len(in.Addresses) == 0 && in.Unmute:
+		return errorResult(invalidArgument("addresses is required to unmute")), nil, nil
+	case len(in.Addresses) == 0:
+	case in.Unmute:
+		removed, err := s.senders.remove(mutedList, user, in.Addresses)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if len(removed) == 0 {
+			sb.WriteString("None of the addresses were muted.\n")
+		} else {
+			fmt.Fprintf(&sb, "Unmuted %d sender(s): %s\n", len(removed), strings.Join(removed, ", "))
+		}
+	default:
+		added, err := s.senders.add(mutedList, user, in.Addresses)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if len(added) == 0 {
+			sb.WriteString("Already muted.\n")
+		} else {
+			fmt.Fprintf(&sb, "Muted %d sender(s): %s\n", len(added), strings.Join(added, ", "))
+		}
+	}
+
+	muted := s.mutedOf(client)
+	if len(muted) == 0 {
+		sb.WriteString("No muted senders.\n")
+	} else {
+		fmt.Fprintf(&sb, "Muted senders (%d), hidden from email_query unless include_muted=true:\n", len(muted))
+		for _, entry := range muted {
+			fmt.Fprintf(&sb, "  %s\n", entry)
+		}
+	}
+
+	if in.Sieve == "" {
+		if len(in.Addresses) > 0 {
+			sb.WriteString("\nThe mail server is unchanged. To enforce the list on arrival, call sender_mute again with sieve=discard or sieve=fileinto after the user confirms.\n")
+		}
+		return textResult(sb.String()), nil, nil
+	}
+
+	sieveAccount, err := sieveAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	folder := ""
+	if in.Sieve == "fileinto" {
+		if folder, err = s.muteFolder(ctx, client, jmap.ID(in.MailboxID)); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+	rule := sieveMuteRule(muted, in.Sieve, folder)
+	msg, err := s.installManagedRule(ctx, client, sieveAccount, mutedRuleKey, rule)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	fmt.Fprintf(&sb, "\n%s\n\n%s\n", msg, rule)
+	return textResult(sb.String()), nil, nil
+}
+
+// muteFolder returns the Sieve folder path of the mailbox with ID id, or of
+// the Junk mailbox when id is empty.
+func (s *Server) muteFolder(ctx context.Context, client JMAPClient, id jmap.ID) (string, error) {
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return "", fmt.Errorf("no primary mail account")
+	}
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return "", err
+	}
+	if id != "" {
+		mb := mailboxes[id]
+		if mb == nil {
+			return "", notFoundError([]jmap.ID{id}, "mailbox %s not found", id)
+		}
+		return mailboxPath(mb, mailboxes), nil
+	}
+	for _, mb := range mailboxes {
+		if mb.Role == mailbox.RoleJunk {
+			return mailboxPath(mb, mailboxes), nil
+		}
+	}
+	return "", fmt.Errorf("no Junk mailbox; pass mailbox_id")
+}
+
+// sieveMuteRule renders the managed rule enforcing the muted list by
+// discarding or filing into folder. An empty list renders a rule without
+// tests, which replaces an earlier one when the last sender is unmuted.
+func sieveMuteRule(muted []string, action, folder string) string {
+	marker := ruleMarker + mutedRuleKey.String()
+	if len(muted) == 0 {
+		return marker + "\n# (no muted senders)"
+	}
+	var addrs, domains []string
+	for _, entry := range muted {
+		if domain, ok := strings.CutPrefix(entry, "*@"); ok {
+			domains = append(domains, sieveString(domain))
+		} else {
+			addrs = append(addrs, sieveString(entry))
+		}
+	}
+	var tests []string
+	if len(addrs) > 0 {
+		tests = append(tests, fmt.Sprintf(`address :is "from" [%s]`, strings.Join(addrs, ", ")))
+	}
+	if len(domains) > 0 {
+		tests = append(tests, fmt.Sprintf(`address :domain :is "from" [%s]`, strings.Join(domains, ", ")))
+	}
+	test := tests[0]
+	if len(tests) > 1 {
+		test = "anyof(" + strings.Join(tests, ", ") + ")"
+	}
+	command := "discard;"
+	if action == "fileinto" {
+		command = "fileinto " + sieveString(folder) + ";"
+	}
+	return fmt.Sprintf("%s\nif %s {\n    %s\n}", marker, test, command)
+}
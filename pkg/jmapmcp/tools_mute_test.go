@@ -0,0 +1,83 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/sieve"
+)
+
+func TestSenderMuteHidesFromQuery(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	addEmail(fake, "e1", "Hello", "", 1)
+	addEmail(fake, "e2", "Deal of the day", "", 2)
+	e := fake.Object("Email", "e2")
+	e["from"] = []any{map[string]any{"email": "promo@shop.example"}}
+	fake.Add("Email", e)
+
+	res, _, _ := s.handleSenderMute(ctx, nil, SenderMuteInput{Addresses: []string{"@shop.example"}})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Muted 1 sender(s): *@shop.example") || !strings.Contains(out, "The mail server is unchanged") {
+		t.Fatalf("sender_mute:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{})
+	out := resultText(res)
+	if strings.Contains(out, "Deal of the day") || !strings.Contains(out, "Hello") || !strings.Contains(out, "Note: mail from 1 muted sender(s) is hidden") {
+		t.Errorf("email_query with a muted sender:\n%s", out)
+	}
+	for _, in := range []EmailQueryInput{{IncludeMuted: true}, {From: "promo@shop.example"}} {
+		res, _, _ = s.handleEmailQuery(ctx, nil, in)
+		if out := resultText(res); !strings.Contains(out, "Deal of the day") {
+			t.Errorf("email_query %+v hid the muted sender:\n%s", in, out)
+		}
+	}
+
+	res, _, _ = s.handleSenderMute(ctx, nil, SenderMuteInput{Addresses: []string{"*@shop.example"}, Unmute: true})
+	if out := resultText(res); !strings.Contains(out, "Unmuted 1 sender(s)") || !strings.Contains(out, "No muted senders.") {
+		t.Errorf("unmute:\n%s", out)
+	}
+	res, _, _ = s.handleEmailQuery(ctx, nil, EmailQueryInput{})
+	if out := resultText(res); !strings.Contains(out, "Deal of the day") {
+		t.Errorf("email_query after unmuting:\n%s", out)
+	}
+}
+
+func TestSenderMuteSieve(t *testing.T) {
+	s, _ := newFakeServer(t)
+	ctx := context.Background()
+	res, _, _ := s.handleSenderMute(ctx, nil, SenderMuteInput{Addresses: []string{"a@example.org"}, Sieve: "discard"})
+	if !res.IsError {
+		t.Errorf("sieve enforcement accepted without -enable-sieve:\n%s", resultText(res))
+	}
+
+	s, fake := newFakeServer(t, WithSieve())
+	fake.AddCapability(string(sieve.URI), nil)
+	fake.Add("Mailbox", map[string]any{"id": "junk", "name": "Junk", "role": "junk"})
+	res, _, _ = s.handleSenderMute(ctx, nil, SenderMuteInput{Addresses: []string{"a@example.org", "*@spam.example"}, Sieve: "fileinto"})
+	if res.IsError {
+		t.Fatalf("sender_mute: %s", resultText(res))
+	}
+	ids := fake.Objects("SieveScript")
+	if len(ids) != 1 {
+		t.Fatalf("scripts = %v, want one", ids)
+	}
+	blobID, _ := fake.Object("SieveScript", ids[0])["blobId"].(string)
+	want := `if anyof(address :is "from" ["a@example.org"], address :domain :is "from" ["spam.example"]) {
+    fileinto "Junk";
+}`
+	if content := string(fake.Blob(blobID)); !strings.Contains(content, want) {
+		t.Errorf("installed script:\n%s", content)
+	}
+
+	// Unmuting everyone replaces the rule with an empty one.
+	res, _, _ = s.handleSenderMute(ctx, nil, SenderMuteInput{Addresses: []string{"a@example.org", "*@spam.example"}, Unmute: true, Sieve: "discard"})
+	if res.IsError {
+		t.Fatalf("unmute: %s", resultText(res))
+	}
+	blobID, _ = fake.Object("SieveScript", ids[0])["blobId"].(string)
+	if content := string(fake.Blob(blobID)); strings.Contains(content, "spam.example") || !strings.Contains(content, "# (no muted senders)") {
+		t.Errorf("script after unmuting:\n%s", content)
+	}
+}
@@ -0,0 +1,359 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	defaultNewsletterDays     = 7
+	defaultNewsletterMaxScan  = 500
+	defaultNewsletterMaxChars = 8000
+	newsletterHeadlines       = 3      // per message
+	newsletterLinks           = 5      // per message
+	newsletterBodyBytes       = 200000 // newsletters carry heavy HTML
+)
+
+// newsletterProperties are the Email properties a newsletter digest needs;
+// headers carry List-Id and Precedence.
+var newsletterProperties = []string{"id", "mailboxIds", "from", "subject", "receivedAt", "keywords", "headers", "textBody", "htmlBody"}
+
+// newsletterBoilerplate matches link texts and URLs that are about the
+// newsletter rather than its content.
+var newsletterBoilerplate = regexp.MustCompile(`(?i)unsubscribe|opt[ -]?out|preferences|manage (your )?subscription|view (it |this )?(in|on) (your |a )?(browser|web)|web version|privacy|forward to a friend|update your profile|mailto:`)
+
+// textURL matches URLs in plain text bodies.
+var textURL = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+
+// --- newsletter_digest ---
+
+type NewsletterDigestInput struct {
+	Since      string `json:"since,omitempty" jsonschema:"Digest newsletters received after this time (RFC 3339 or YYYY-MM-DD)"`
+	SinceState string `json:"since_state,omitempty" jsonschema:"Digest newsletters that arrived since this Email state (default: since the previous newsletter_digest in this account, or the last 7 days)"`
+	MarkRead   bool   `json:"mark_read,omitempty" jsonschema:"Mark the digested newsletters read (undo with undo_last)"`
+	MaxEmails  int    `json:"max_emails,omitempty" jsonschema:"Maximum number of new emails to look through (default 500, max 2000)"`
+	MaxChars   int    `json:"max_chars,omitempty" jsonschema:"Maximum briefing size in characters (default 8000)"`
+}
+
+var newsletterDigestTool = &mcp.Tool{
+	Name:        "newsletter_digest",
+	Description: "Turn newsletters and mailing list mail (List-Id or Precedence: bulk/list) that arrived since the previous run into one briefing: grouped by list, with each message's headlines and main links, leaving out unsubscribe and view-in-browser boilerplate. With mark_read, marks the digested messages read. Remembers where it stopped per account, so the next call picks up from there.",
+	Annotations: mutatingAnnotations,
+}
+
+// newsletterStates keeps the Email state each owner's last newsletter
+// digest reached per endpoint, in memory.
+type newsletterStates struct {
+	mu sync.Mutex
+	m  map[string]string // owner and endpoint → Email state
+}
+
+func (n *newsletterStates) get(owner, endpoint string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.m[owner+"\x00"+endpoint]
+}
+
+func (n *newsletterStates) put(owner, endpoint, state string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.m == nil {
+		n.m = make(map[string]string)
+	}
+	n.m[owner+"\x00"+endpoint] = state
+}
+
+// newsletterItem is what a digest shows of one message.
+type newsletterItem struct {
+	email     *email.Email
+	headlines []string
+	links     []string // "text — URL"
+}
+
+func (s *Server) handleNewsletterDigest(ctx context.Context, req *mcp.CallToolRequest, in NewsletterDigestInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	owner, endpoint := s.idOwner(ctx), endpointName(ctx)
+	state, since := in.SinceState, in.Since
+	if state == "" && since == "" {
+		state = s.newsletterStates.get(owner, endpoint)
+		if state == "" {
+			since = time.Now().AddDate(0, 0, -defaultNewsletterDays).UTC().Format(time.RFC3339)
+		}
+	}
+
+	maxScan := in.MaxEmails
+	if maxScan <= 0 {
+		maxScan = defaultNewsletterMaxScan
+	}
+	maxScan = min(maxScan, digestMaxScanCeiling)
+	pageSize := uint64(digestPageSize)
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxObjectsInGet > 0 {
+		pageSize = min(pageSize, c.MaxObjectsInGet)
+	}
+
+	var g *digestGather
+	if state != "" {
+		g, err = gatherCreatedSince(ctx, client, accountID, state, maxScan, pageSize, newsletterProperties)
+		since = "state " + state
+	} else {
+		after, perr := parseDate(since, "T00:00:00Z")
+		if perr != nil {
+			return errorResult(perr), nil, nil
+		}
+		g, err = gatherReceivedAfter(ctx, client, accountID, &email.FilterCondition{After: after}, maxScan, pageSize, newsletterProperties)
+	}
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	letters := slices.DeleteFunc(g.emails, func(e *email.Email) bool { return !isBulk(e) })
+	slices.SortFunc(letters, func(a, b *email.Email) int { return receivedAt(a).Compare(receivedAt(b)) })
+	for start := 0; start < len(letters); start += int(pageSize) {
+		if err := s.fetchBodies(ctx, client, accountID, letters[start:min(start+int(pageSize), len(letters))], newsletterBodyBytes, true); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+
+	groups := make(map[string][]*newsletterItem)
+	for _, e := range letters {
+		key := newsletterName(e)
+		headlines, links := newsletterContent(e)
+		// Headlines and link lines are body text, and links often carry
+		// tokens, so they are redacted like bodies are.
+		for _, lines := range [][]string{headlines, links} {
+			for i := range lines {
+				lines[i], _ = s.redactor.redact(lines[i])
+			}
+		}
+		groups[key] = append(groups[key], &newsletterItem{email: e, headlines: headlines, links: links})
+	}
+	names := sortedKeys(groups)
+	slices.SortStableFunc(names, func(a, b string) int { return len(groups[b]) - len(groups[a]) })
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Newsletters since %s: %d message(s) from %d list(s)\n", since, len(letters), len(groups))
+	if g.more {
+		sb.WriteString("Note: stopped at max_emails; the next call continues from here\n")
+	}
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n## %s (%d)\n", name, len(groups[name]))
+		for _, item := range groups[name] {
+			e := item.email
+			subject := e.Subject
+			if subject == "" {
+				subject = "(no subject)"
+			}
+			fmt.Fprintf(&sb, "\n%s — %s [id: %s]\n", subject, s.locale.dateTime(receivedAt(e)), e.ID)
+			for _, h := range item.headlines {
+				fmt.Fprintf(&sb, "  • %s\n", h)
+			}
+			for _, l := range item.links {
+				fmt.Fprintf(&sb, "  → %s\n", l)
+			}
+		}
+	}
+
+	var footer strings.Builder
+	if in.MarkRead {
+		n, err := s.markNewsletterRead(ctx, req, client, accountID, letters)
+		if err != nil {
+			return errorResult(fmt.Errorf("digested %d newsletter(s) but marking them read failed: %w", len(letters), err)), nil, nil
+		}
+		fmt.Fprintf(&footer, "\nMarked %d newsletter(s) read; undo_last reverts it.\n", n)
+	}
+	s.newsletterStates.put(owner, endpoint, g.state)
+	fmt.Fprintf(&footer, "State: %s (the next newsletter_digest starts here)\n", g.state)
+
+	maxChars := in.MaxChars
+	if maxChars <= 0 {
+		maxChars = defaultNewsletterMaxChars
+	}
+	return textResult(TruncateBody(sb.String(), max(maxChars-footer.Len(), 0)) + footer.String()), nil, nil
+}
+
+// markNewsletterRead sets $seen on the unread of letters, journaling the
+// change for undo_last, and returns how many it marked.
+func (s *Server) markNewsletterRead(ctx context.Context, req *mcp.CallToolRequest, client JMAPClient, accountID jmap.ID, letters []*email.Email) (int, error) {
+	updates := make(map[jmap.ID]jmap.Patch)
+	prior := make(map[jmap.ID]map[string]bool)
+	for _, e := range letters {
+		if !e.Keywords["$seen"] {
+			updates[e.ID] = jmap.Patch{"keywords/$seen": true}
+			prior[e.ID] = map[string]bool{"$seen": false}
+		}
+	}
+	if len(updates) == 0 {
+		return 0, nil
+	}
+
+	setReq := &jmap.Request{Context: ctx}
+	setReq.Invoke(&email.Set{Account: accountID, Update: updates})
+	resp, err := client.Do(setReq)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Responses) == 0 {
+		return 0, fmt.Errorf("empty response for Email/set")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		s.journal(ctx, req, &journalEntry{tool: "newsletter_digest", accountID: accountID, keywords: prior}, args.NotUpdated)
+		if err := setFailures("mark read failed", args.NotUpdated); err != nil {
+			return 0, err
+		}
+		return len(updates), nil
+	case *jmap.MethodError:
+		return 0, args
+	default:
+		return 0, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// isBulk reports whether e is list or bulk mail: it has a List-Id header or
+// Precedence: bulk or list.
+func isBulk(e *email.Email) bool {
+	if listID(e) != "" {
+		return true
+	}
+	for _, h := range e.Headers {
+		if strings.EqualFold(h.Name, "Precedence") {
+			switch strings.ToLower(strings.TrimSpace(h.Value)) {
+			case "bulk", "list":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newsletterName names the list e belongs to: the List-Id header's phrase
+// or ID, else the sender.
+func newsletterName(e *email.Email) string {
+	for _, h := range e.Headers {
+		if !strings.EqualFold(h.Name, "List-Id") {
+			continue
+		}
+		v := strings.TrimSpace(h.Value)
+		if i := strings.LastIndexByte(v, '<'); i > 0 {
+			if phrase := strings.Trim(strings.TrimSpace(v[:i]), `"`); phrase != "" {
+				return phrase
+			}
+		}
+		return listID(e)
+	}
+	if len(e.From) > 0 {
+		return formatAddresses(e.From[:1])
+	}
+	return "(unknown sender)"
+}
+
+// newsletterContent extracts the headlines and main links of e's body:
+// from HTML, the h1–h3 headings and the links with text; from plain text,
+// the URLs with the line before each as their text.
+func newsletterContent(e *email.Email) (headlines, links []string) {
+	for _, part := range e.HTMLBody {
+		if bv, ok := e.BodyValues[part.PartID]; ok && part.Type == "text/html" {
+			return htmlNewsletterContent(bv.Value)
+		}
+	}
+	bv, _ := renderedBodyValue(e)
+	if bv == nil {
+		return nil, nil
+	}
+	return nil, textNewsletterLinks(bv.Value)
+}
+
+func htmlNewsletterContent(body string) (headlines, links []string) {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, nil
+	}
+	seen := make(map[string]bool)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.H1, atom.H2, atom.H3:
+				if text := collapseSpace(nodeText(n)); text != "" && len(headlines) < newsletterHeadlines && !seen["h:"+text] {
+					seen["h:"+text] = true
+					headlines = append(headlines, text)
+				}
+				return
+			case atom.A:
+				text, href := collapseSpace(nodeText(n)), strings.TrimSpace(attrValue(n, "href"))
+				if contentLink(text, href) && len(links) < newsletterLinks && !seen["a:"+href] {
+					seen["a:"+href] = true
+					links = append(links, text+" — "+href)
+				}
+				return
+			case atom.Style, atom.Script, atom.Head:
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return headlines, links
+}
+
+func textNewsletterLinks(body string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	prev := ""
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		for _, href := range textURL.FindAllString(line, -1) {
+			text := strings.TrimSpace(strings.Replace(line, href, "", 1))
+			if text == "" {
+				text = prev
+			}
+			if contentLink(text, href) && len(links) < newsletterLinks && !seen[href] {
+				seen[href] = true
+				links = append(links, text+" — "+href)
+			}
+		}
+		if line != "" {
+			prev = line
+		}
+	}
+	return links
+}
+
+// contentLink reports whether a link with text to href is worth listing:
+// a web link with a few characters of text that is not boilerplate.
+func contentLink(text, href string) bool {
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	return len([]rune(text)) >= 4 && !newsletterBoilerplate.MatchString(text) && !newsletterBoilerplate.MatchString(href)
+}
+
+// collapseSpace trims s and collapses its runs of whitespace to one space.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,312 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// notesMailboxName is the top-level mailbox holding notes, as used by
+	// IMAP notes clients such as Apple Notes.
+	notesMailboxName = "Notes"
+
+	// noteTypeIdentifier marks a message as a note for IMAP notes clients.
+	noteTypeIdentifier = "com.apple.mail-note"
+
+	defaultNoteSearchLimit = 10
+	maxNoteSearchLimit     = 100
+	defaultNoteMaxChars    = 2000
+)
+
+// noteTagRE is what a tag must look like to be stored as a keyword.
+var noteTagRE = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// --- note_create ---
+
+type NoteCreateInput struct {
+	Title string   `json:"title" jsonschema:"Note title, stored as the subject"`
+	Body  string   `json:"body" jsonschema:"Plain text note content"`
+	Tags  []string `json:"tags,omitempty" jsonschema:"Lowercase tags stored as keywords, for note_search (letters, digits, '.', '_', '-')"`
+}
+
+var noteCreateTool = &mcp.Tool{
+	Name:        "note_create",
+	Description: "Save a note as a message in the Notes mailbox (created if missing), readable by IMAP notes clients. Notes persist with the mail account, so they can hold facts and decisions to recall in later sessions with note_search.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleNoteCreate(ctx context.Context, _ *mcp.CallToolRequest, in NoteCreateInput) (*mcp.CallToolResult, any, error) {
+	if strings.TrimSpace(in.Title) == "" {
+		return errorResult(invalidArgument("title is required")), nil, nil
+	}
+	keywords := map[string]bool{"$seen": true}
+	for _, tag := range in.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !noteTagRE.MatchString(tag) {
+			return errorResult(invalidArgument("invalid tag %q: use letters, digits, '.', '_', and '-'", tag)), nil, nil
+		}
+		keywords[tag] = true
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	notesID, created, err := ensureNotesMailbox(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	id, err := defaultIdentity(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	now := time.Now()
+	note := &email.Email{
+		MailboxIDs: map[jmap.ID]bool{notesID: true},
+		Keywords:   keywords,
+		Subject:    in.Title,
+		SentAt:     &now,
+		BodyValues: map[string]*email.BodyValue{
+			"body": {Value: in.Body},
+		},
+		TextBody: []*email.BodyPart{
+			{PartID: "body", Type: "text/plain"},
+		},
+		Headers: []*email.Header{
+			{Name: "X-Uniform-Type-Identifier", Value: " " + noteTypeIdentifier},
+			{Name: "X-Mail-Created-Date", Value: " " + now.Format(time.RFC1123Z)},
+		},
+	}
+	if id != nil {
+		note.From = []*mail.Address{{Name: id.Name, Email: id.Email}}
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Set{
+		Account: accountID,
+		Create:  map[jmap.ID]*email.Email{"note": note},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/set")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		if se, ok := args.NotCreated["note"]; ok {
+			return errorResult(setFailure("note creation failed", se)), nil, nil
+		}
+		text := "Saved note"
+		if c, ok := args.Created["note"]; ok {
+			text = fmt.Sprintf("Saved note [id: %s]", c.ID)
+		}
+		text += fmt.Sprintf(" in %s", notesMailboxName)
+		if created {
+			text += fmt.Sprintf(" (created mailbox [id: %s])", notesID)
+		}
+		return textResult(text), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// --- note_search ---
+
+type NoteSearchInput struct {
+	Query    string `json:"query,omitempty" jsonschema:"Full-text search over note titles and content (default: all notes)"`
+	Tag      string `json:"tag,omitempty" jsonschema:"Only notes with this tag"`
+	Limit    int    `json:"limit,omitempty" jsonschema:"Maximum number of notes to return, newest first (default 10, max 100)"`
+	MaxChars int    `json:"max_chars,omitempty" jsonschema:"Maximum characters of each note's content (default 2000)"`
+}
+
+var noteSearchTool = &mcp.Tool{
+	Name:        "note_search",
+	Description: "Search notes in the Notes mailbox by text and tag, newest first, returning each note's ID, date, title, tags, and content. Use it to recall what was saved with note_create.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleNoteSearch(ctx context.Context, _ *mcp.CallToolRequest, in NoteSearchInput) (*mcp.CallToolResult, any, error) {
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultNoteSearchLimit
+	}
+	limit = min(limit, maxNoteSearchLimit)
+	maxChars := in.MaxChars
+	if maxChars <= 0 {
+		maxChars = defaultNoteMaxChars
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	notesID := findNotesMailbox(mailboxes)
+	if notesID == "" {
+		return textResult(fmt.Sprintf("No notes: there is no %s mailbox yet.", notesMailboxName)), nil, nil
+	}
+
+	filter := &email.FilterCondition{InMailbox: notesID, Text: in.Query}
+	if in.Tag != "" {
+		filter.HasKeyword = strings.ToLower(in.Tag)
+	}
+
+	req := &jmap.Request{Context: ctx}
+	queryCallID := req.Invoke(&email.Query{
+		Account:        accountID,
+		Filter:         filter,
+		Sort:           []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+		Limit:          uint64(limit),
+		CalculateTotal: true,
+	})
+	req.Invoke(&email.Get{
+		Account:             accountID,
+		ReferenceIDs:        &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+		Properties:          []string{"id", "subject", "receivedAt", "keywords", "textBody", "htmlBody", "bodyValues"},
+		FetchTextBodyValues: true,
+		FetchHTMLBodyValues: true,
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	var total uint64
+	var notes []*email.Email
+	for _, inv := range callResponses(req, resp) {
+		switch args := inv.Args.(type) {
+		case *email.QueryResponse:
+			total = args.Total
+		case *email.GetResponse:
+			notes = args.List
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Notes: %d (returning %d)\n", total, len(notes))
+	for _, n := range notes {
+		fmt.Fprintf(&sb, "\n[id: %s] %s", n.ID, n.Subject)
+		if n.ReceivedAt != nil {
+			fmt.Fprintf(&sb, " (%s)", s.locale.dateTime(*n.ReceivedAt))
+		}
+		if tags := noteTags(n); len(tags) > 0 {
+			fmt.Fprintf(&sb, " tags: %s", strings.Join(tags, ", "))
+		}
+		sb.WriteString("\n")
+		if body := noteContent(n, maxChars); body != "" {
+			sb.WriteString(body)
+			sb.WriteString("\n")
+		}
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// findNotesMailbox returns the top-level mailbox named Notes, matched
+// case-insensitively, or "".
+func findNotesMailbox(mailboxes map[jmap.ID]*mailbox.Mailbox) jmap.ID {
+	for id, mb := range mailboxes {
+		if mb.ParentID == "" && strings.EqualFold(mb.Name, notesMailboxName) {
+			return id
+		}
+	}
+	return ""
+}
+
+// ensureNotesMailbox returns the Notes mailbox, creating it when missing.
+func ensureNotesMailbox(ctx context.Context, client JMAPClient, accountID jmap.ID) (jmap.ID, bool, error) {
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return "", false, err
+	}
+	if id := findNotesMailbox(mailboxes); id != "" {
+		return id, false, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Set{
+		Account: accountID,
+		Create:  map[jmap.ID]*mailbox.Mailbox{"notes": {Name: notesMailboxName}},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	if len(resp.Responses) == 0 {
+		return "", false, fmt.Errorf("empty response for Mailbox/set")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.SetResponse:
+		if se, ok := args.NotCreated["notes"]; ok {
+			return "", false, setFailure(fmt.Sprintf("creating %s mailbox failed", notesMailboxName), se)
+		}
+		if c, ok := args.Created["notes"]; ok {
+			return c.ID, true, nil
+		}
+		return "", false, fmt.Errorf("creating %s mailbox returned no ID", notesMailboxName)
+	case *jmap.MethodError:
+		return "", false, args
+	default:
+		return "", false, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// noteTags returns a note's tags: its keywords other than system flags.
+func noteTags(e *email.Email) []string {
+	var tags []string
+	for _, kw := range sortedKeys(e.Keywords) {
+		if e.Keywords[kw] && !strings.HasPrefix(kw, "$") {
+			tags = append(tags, kw)
+		}
+	}
+	return tags
+}
+
+// noteContent renders a note's body as text, keeping quoted lines that
+// extractBody would strip from a message.
+func noteContent(e *email.Email, maxChars int) string {
+	bv, isHTML := renderedBodyValue(e)
+	if bv == nil {
+		return ""
+	}
+	text := bv.Value
+	if isHTML {
+		text = htmlToText(SanitizeHTML(text))
+	}
+	return TruncateBody(strings.TrimSpace(text), maxChars)
+}
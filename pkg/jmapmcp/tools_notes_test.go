@@ -0,0 +1,100 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNoteCreate(t *testing.T) {
+	s, fake := newFakeServer(t)
+
+	res, _, _ := s.handleNoteCreate(context.Background(), nil, NoteCreateInput{
+		Title: "Travel preferences",
+		Body:  "Aisle seat, no red-eye flights.",
+		Tags:  []string{"Travel", "prefs"},
+	})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	if out := resultText(res); !strings.Contains(out, "in Notes (created mailbox") {
+		t.Errorf("unexpected result: %s", out)
+	}
+	var notesID string
+	for _, id := range fake.Objects("Mailbox") {
+		if fake.Object("Mailbox", id)["name"] == "Notes" {
+			notesID = id
+		}
+	}
+	if notesID == "" {
+		t.Fatal("Notes mailbox not created")
+	}
+	emails := fake.Objects("Email")
+	if len(emails) != 1 {
+		t.Fatalf("created %d emails", len(emails))
+	}
+	note := fake.Object("Email", emails[0])
+	if boxes := note["mailboxIds"].(map[string]any); boxes[notesID] != true {
+		t.Errorf("mailboxIds = %v", boxes)
+	}
+	if kw := note["keywords"].(map[string]any); kw["travel"] != true || kw["prefs"] != true || kw["$seen"] != true {
+		t.Errorf("keywords = %v", kw)
+	}
+
+	// The second note reuses the mailbox.
+	res, _, _ = s.handleNoteCreate(context.Background(), nil, NoteCreateInput{Title: "Second", Body: "x"})
+	if out := resultText(res); res.IsError || strings.Contains(out, "created mailbox") {
+		t.Errorf("unexpected result: %s", out)
+	}
+
+	res, _, _ = s.handleNoteCreate(context.Background(), nil, NoteCreateInput{Title: "Bad", Tags: []string{"two words"}})
+	if !res.IsError || !strings.Contains(resultText(res), "invalid tag") {
+		t.Errorf("unexpected result: %s", resultText(res))
+	}
+}
+
+func TestNoteSearch(t *testing.T) {
+	s, fake := newFakeServer(t)
+
+	res, _, _ := s.handleNoteSearch(context.Background(), nil, NoteSearchInput{})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "no Notes mailbox") {
+		t.Errorf("unexpected result: %s", out)
+	}
+
+	fake.Add("Mailbox", map[string]any{"id": "notes", "name": "Notes"})
+	note := func(id, title, body, day string, tags ...string) {
+		kw := map[string]any{"$seen": true}
+		for _, tag := range tags {
+			kw[tag] = true
+		}
+		fake.Add("Email", map[string]any{
+			"id":         id,
+			"subject":    title,
+			"receivedAt": "2025-01-" + day + "T10:00:00Z",
+			"mailboxIds": map[string]any{"notes": true},
+			"keywords":   kw,
+			"textBody":   []any{map[string]any{"partId": "1", "type": "text/plain"}},
+			"bodyValues": map[string]any{"1": map[string]any{"value": body}},
+		})
+	}
+	note("n1", "Travel preferences", "> Aisle seat\nNo red-eye flights.", "02", "travel")
+	note("n2", "Project Falcon decisions", "Ship in March.", "05", "work")
+	addEmail(fake, "e1", "Travel newsletter", "Deals", 6)
+
+	res, _, _ = s.handleNoteSearch(context.Background(), nil, NoteSearchInput{Query: "travel"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	if !strings.HasPrefix(out, "Notes: 1 (returning 1)") || !strings.Contains(out, "[id: n1] Travel preferences (2025-01-02 10:00) tags: travel\n> Aisle seat") {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	if strings.Contains(out, "newsletter") {
+		t.Errorf("searched outside Notes:\n%s", out)
+	}
+
+	res, _, _ = s.handleNoteSearch(context.Background(), nil, NoteSearchInput{Tag: "work"})
+	if out := resultText(res); !strings.Contains(out, "Project Falcon") || strings.Contains(out, "Travel") {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
@@ -0,0 +1,254 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// enqueueSubmission places a draft in the local outbox instead of submitting
+// it. The draft is fetched first so the queue entry can show what is being
+// approved, and so a bad email ID fails now rather than at approval.
+func (s *Server) enqueueSubmission(ctx context.Context, client JMAPClient, accountID, emailID, identityID jmap.ID, resend bool) (*mcp.CallToolResult, any, error) {
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if _, ok := client.Session().Capabilities[emailsubmission.URI]; !ok {
+		return errorResult(capabilityMissing(client.Session(), emailsubmission.URI)), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        []jmap.ID{emailID},
+		Properties: []string{"id", "subject", "to", "cc", "bcc", "keywords"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/get")), nil, nil
+	}
+
+	var draft *email.Email
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return errorResult(notFoundError([]jmap.ID{emailID}, "email not found: %s", emailID)), nil, nil
+		}
+		draft = args.List[0]
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	if !resend {
+		if err := checkStillDraft(draft); err != nil {
+			return errorResult(err), nil, nil
+		}
+		if s.submissions.submittedWithin(s.ownerOf(token), emailID, s.resendWindow, time.Now()) {
+			return errorResult(resendError(emailID, "it was submitted moments ago")), nil, nil
+		}
+	}
+	recipients := draftRecipients(draft)
+	if err := s.sendPolicy.checkRecipients(recipients); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	id := s.outbox.add(&outboxEntry{
+		Owner:      s.ownerOf(token),
+		Endpoint:   EndpointFromContext(ctx),
+		AccountID:  accountID,
+		EmailID:    emailID,
+		IdentityID: identityID,
+		Subject:    draft.Subject,
+		To:         formatAddresses(recipients),
+		QueuedAt:   time.Now(),
+		Resend:     resend,
+	})
+
+	return textResult(fmt.Sprintf("Email %s queued for approval as %s (not sent yet). It is sent once the user confirms it through outbox_approve.", emailID, id)), nil, nil
+}
+
+// --- outbox_list ---
+
+type OutboxListInput struct{}
+
+var outboxListTool = &mcp.Tool{
+	Name:        "outbox_list",
+	Description: "List drafts queued by email_submission_set that are awaiting approval. Only entries queued with the caller's own credentials are shown.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleOutboxList(ctx context.Context, _ *mcp.CallToolRequest, _ OutboxListInput) (*mcp.CallToolResult, any, error) {
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	entries := s.outbox.list(s.ownerOf(token))
+	if len(entries) == 0 {
+		return textResult("Outbox is empty"), nil, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d pending submission(s):\n", len(entries))
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "\n[%s] email %s\n", e.ID, e.EmailID)
+		if e.Endpoint != "" {
+			fmt.Fprintf(&sb, "  Endpoint: %s\n", e.Endpoint)
+		}
+		fmt.Fprintf(&sb, "  Subject: %s\n", e.Subject)
+		fmt.Fprintf(&sb, "  Recipients: %s\n", e.To)
+		if e.IdentityID != "" {
+			fmt.Fprintf(&sb, "  Identity: %s\n", e.IdentityID)
+		}
+		fmt.Fprintf(&sb, "  Queued: %s\n", s.locale.dateTime(e.QueuedAt))
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// --- outbox_approve ---
+
+type OutboxApproveInput struct {
+	ID string `json:"id" jsonschema:"Outbox entry ID from outbox_list (e.g. out-3)"`
+}
+
+var outboxApproveTool = &mcp.Tool{
+	Name:        "outbox_approve",
+	Description: "Approve a queued submission: asks the user of the MCP client to confirm, then submits the draft for delivery (EmailSubmission/set) and moves it from Drafts to Sent. Needs a client that supports elicitation; the send only happens if the user confirms.",
+	Annotations: mutatingAnnotations,
+}
+
+// canElicit reports whether the client behind req can put a form to its
+// user.
+func canElicit(req *mcp.CallToolRequest) bool {
+	if req == nil || req.Session == nil {
+		return false
+	}
+	params := req.Session.InitializeParams()
+	return params != nil && params.Capabilities != nil && params.Capabilities.Elicitation != nil
+}
+
+// approvalSchema is the form outbox_approve puts to the user: a single
+// checkbox that must be ticked for the send to go ahead.
+var approvalSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"send": map[string]any{
+			"type":        "boolean",
+			"title":       "Send this email",
+			"description": "Tick to send the email now; leave unticked or decline to keep it queued.",
+		},
+	},
+}
+
+// confirmApproval asks the user of the client behind req, through
+// elicitation, to confirm sending e. The answer comes from the client's
+// user rather than from the agent calling the tool, so an agent cannot
+// approve what it queued by itself.
+func confirmApproval(ctx context.Context, req *mcp.CallToolRequest, e *outboxEntry) error {
+	if !canElicit(req) {
+		return &policyError{
+			Policy: "send",
+			Rule:   "approval",
+			Detail: "outbox_approve needs an MCP client that supports elicitation, so that its user confirms the send",
+		}
+	}
+	res, err := req.Session.Elicit(ctx, &mcp.ElicitParams{
+		Message:         fmt.Sprintf("Send the queued email %s?\nSubject: %s\nRecipients: %s", e.EmailID, e.Subject, e.To),
+		RequestedSchema: approvalSchema,
+	})
+	if err != nil {
+		return fmt.Errorf("asking for approval: %w", err)
+	}
+	if res.Action != "accept" || res.Content["send"] != true {
+		return &policyError{
+			Policy: "send",
+			Rule:   "approval",
+			Detail: fmt.Sprintf("the user did not confirm sending %s", e.ID),
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleOutboxApprove(ctx context.Context, req *mcp.CallToolRequest, in OutboxApproveInput) (*mcp.CallToolResult, any, error) {
+	if in.ID == "" {
+		return errorResult(invalidArgument("id is required")), nil, nil
+	}
+
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	entry, err := s.outbox.take(s.ownerOf(token), in.ID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if err := confirmApproval(ctx, req, entry); err != nil {
+		s.outbox.restore(entry)
+		return errorResult(fmt.Errorf("%s left in outbox: %w", entry.ID, err)), nil, nil
+	}
+
+	// Submit on the endpoint the draft was queued from, whichever is selected now.
+	ctx = ContextWithEndpoint(ctx, entry.Endpoint)
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		s.outbox.restore(entry)
+		return errorResult(err), nil, nil
+	}
+
+	if err := s.submitDraft(ctx, client, entry.AccountID, entry.EmailID, entry.IdentityID, entry.Resend); err != nil {
+		s.outbox.restore(entry)
+		return errorResult(fmt.Errorf("%s left in outbox: %w", entry.ID, err)), nil, nil
+	}
+
+	return textResult(fmt.Sprintf("Approved %s: email %s submitted for delivery", entry.ID, entry.EmailID)), nil, nil
+}
+
+// --- outbox_reject ---
+
+type OutboxRejectInput struct {
+	ID     string `json:"id" jsonschema:"Outbox entry ID from outbox_list (e.g. out-3)"`
+	Reason string `json:"reason,omitempty" jsonschema:"Optional reason, echoed back for the requesting agent"`
+}
+
+var outboxRejectTool = &mcp.Tool{
+	Name:        "outbox_reject",
+	Description: "Reject a queued submission: removes it from the outbox without sending. The draft itself stays in the Drafts mailbox.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleOutboxReject(ctx context.Context, _ *mcp.CallToolRequest, in OutboxRejectInput) (*mcp.CallToolResult, any, error) {
+	if in.ID == "" {
+		return errorResult(invalidArgument("id is required")), nil, nil
+	}
+
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	entry, err := s.outbox.take(s.ownerOf(token), in.ID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	msg := fmt.Sprintf("Rejected %s: email %s will not be sent (draft kept)", entry.ID, entry.EmailID)
+	if in.Reason != "" {
+		msg += "\nReason: " + in.Reason
+	}
+	return textResult(msg), nil, nil
+}
@@ -0,0 +1,253 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- thread_participants ---
+
+type ThreadParticipantsInput struct {
+	ThreadID string `json:"thread_id,omitempty" jsonschema:"ID of the thread (give this or email_id)"`
+	EmailID  string `json:"email_id,omitempty" jsonschema:"ID of any email in the thread (give this or thread_id)"`
+}
+
+var threadParticipantsTool = &mcp.Tool{
+	Name:        "thread_participants",
+	Description: "List everyone in a thread: each distinct address with its display name, how many of the thread's messages it appears on as from, to, and cc, and whether it is one of the user's identities. Ends with the addresses to copy when looping everyone else in, ready for email_create to or cc.",
+	Annotations: readOnlyAnnotations,
+}
+
+// participant is one distinct address in a thread.
+type participant struct {
+	addr         *mail.Address // first seen with a display name, if any
+	from, to, cc int
+	first        int // order of first appearance
+}
+
+func (s *Server) handleThreadParticipants(ctx context.Context, _ *mcp.CallToolRequest, in ThreadParticipantsInput) (*mcp.CallToolResult, any, error) {
+	if (in.ThreadID == "") == (in.EmailID == "") {
+		return errorResult(invalidArgument("give thread_id or email_id")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	threadID, emails, identities, err := participantThread(ctx, client, accountID, jmap.ID(in.ThreadID), jmap.ID(in.EmailID))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	people := countParticipants(emails)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Thread %s: %d message(s), %d participant(s)\n\n", threadID, len(emails), len(people))
+	var others []string
+	structured := threadParticipantsStructured{ThreadID: string(threadID), Messages: len(emails), Participants: make([]participantObject, 0, len(people))}
+	for _, p := range people {
+		var roles []string
+		for _, r := range []struct {
+			name string
+			n    int
+		}{{"from", p.from}, {"to", p.to}, {"cc", p.cc}} {
+			if r.n > 0 {
+				roles = append(roles, fmt.Sprintf("%s %d", r.name, r.n))
+			}
+		}
+		you := ""
+		own := isOwnAddress(p.addr.Email, identities)
+		if own {
+			you = " (you)"
+		} else {
+			others = append(others, p.addr.String())
+			structured.Others = append(structured.Others, addressObject{Name: p.addr.Name, Email: p.addr.Email})
+		}
+		structured.Participants = append(structured.Participants, participantObject{
+			addressObject: addressObject{Name: p.addr.Name, Email: p.addr.Email},
+			From:          p.from,
+			To:            p.to,
+			CC:            p.cc,
+			You:           own,
+		})
+		fmt.Fprintf(&sb, "- %s%s — %s\n", p.addr, you, strings.Join(roles, ", "))
+	}
+	if len(others) > 0 {
+		fmt.Fprintf(&sb, "\nEveryone else: %s\n", strings.Join(others, ", "))
+	}
+	result := textResult(sb.String())
+	if s.structuredOutput {
+		result.StructuredContent = &structured
+	}
+	return result, nil, nil
+}
+
+// threadParticipantsStructured is the structured content of a
+// thread_participants result.
+type threadParticipantsStructured struct {
+	ThreadID     string              `json:"threadId"`
+	Messages     int                 `json:"messages"`
+	Participants []participantObject `json:"participants"`
+	Others       []addressObject     `json:"others,omitempty"` // everyone but the user
+}
+
+// participantObject is a participant in structured content.
+type participantObject struct {
+	addressObject
+	From int  `json:"from,omitempty"`
+	To   int  `json:"to,omitempty"`
+	CC   int  `json:"cc,omitempty"`
+	You  bool `json:"you,omitempty"`
+}
+
+// countParticipants tallies the addresses on emails, most frequent first
+// and then in order of first appearance. Addresses compare case-insensitively.
+func countParticipants(emails []*email.Email) []*participant {
+	slices.SortStableFunc(emails, func(a, b *email.Email) int {
+		return receivedAt(a).Compare(receivedAt(b))
+	})
+	byAddr := make(map[string]*participant)
+	note := func(a *mail.Address) *participant {
+		key := strings.ToLower(a.Email)
+		p := byAddr[key]
+		if p == nil {
+			p = &participant{addr: a, first: len(byAddr)}
+			byAddr[key] = p
+		} else if p.addr.Name == "" && a.Name != "" {
+			p.addr = a
+		}
+		return p
+	}
+	for _, e := range emails {
+		for _, a := range distinctAddresses(e.From) {
+			note(a).from++
+		}
+		for _, a := range distinctAddresses(e.To) {
+			note(a).to++
+		}
+		for _, a := range distinctAddresses(e.CC) {
+			note(a).cc++
+		}
+	}
+
+	people := make([]*participant, 0, len(byAddr))
+	for _, p := range byAddr {
+		people = append(people, p)
+	}
+	slices.SortFunc(people, func(a, b *participant) int {
+		if n := (b.from + b.to + b.cc) - (a.from + a.to + a.cc); n != 0 {
+			return n
+		}
+		return a.first - b.first
+	})
+	return people
+}
+
+// distinctAddresses drops empty addresses and repeats of one address, so
+// an address listed twice on a message counts once.
+func distinctAddresses(addrs []*mail.Address) []*mail.Address {
+	var out []*mail.Address
+	seen := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		key := strings.ToLower(a.Email)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, a)
+	}
+	return out
+}
+
+// participantThread fetches the addresses of every email in a thread, given
+// the thread or one of its emails, and the identities, in one request.
+// identities is nil when the server offers none.
+func participantThread(ctx context.Context, client JMAPClient, accountID, threadID, emailID jmap.ID) (jmap.ID, []*email.Email, []*identity.Identity, error) {
+	req := &jmap.Request{Context: ctx}
+	get := &thread.Get{Account: accountID, IDs: []jmap.ID{threadID}}
+	if emailID != "" {
+		anchorCallID := req.Invoke(&email.Get{
+			Account:    accountID,
+			IDs:        []jmap.ID{emailID},
+			Properties: []string{"id", "threadId"},
+		})
+		get = &thread.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: anchorCallID, Name: "Email/get", Path: "/list/*/threadId"},
+		}
+	}
+	threadCallID := req.Invoke(get)
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: threadCallID, Name: "Thread/get", Path: "/list/*/emailIds"},
+		Properties:   []string{"id", "from", "to", "cc", "receivedAt"},
+	})
+	req.Invoke(&identity.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	want := 3
+	if emailID != "" {
+		want = 4
+	}
+	if len(resp.Responses) < want {
+		return "", nil, nil, fmt.Errorf("expected %d responses, got %d", want, len(resp.Responses))
+	}
+	responses := resp.Responses
+	if emailID != "" {
+		switch args := responses[0].Args.(type) {
+		case *email.GetResponse:
+			if len(args.List) == 0 {
+				return "", nil, nil, notFoundError([]jmap.ID{emailID}, "email %s not found", emailID)
+			}
+		case *jmap.MethodError:
+			return "", nil, nil, args
+		default:
+			return "", nil, nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+		responses = responses[1:]
+	}
+
+	switch args := responses[0].Args.(type) {
+	case *thread.GetResponse:
+		if len(args.List) == 0 {
+			return "", nil, nil, notFoundError([]jmap.ID{threadID}, "thread %s not found", threadID)
+		}
+		threadID = args.List[0].ID
+	case *jmap.MethodError:
+		return "", nil, nil, args
+	default:
+		return "", nil, nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	var emails []*email.Email
+	switch args := responses[1].Args.(type) {
+	case *email.GetResponse:
+		emails = args.List
+	case *jmap.MethodError:
+		return "", nil, nil, args
+	default:
+		return "", nil, nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	var identities []*identity.Identity
+	if args, ok := responses[2].Args.(*identity.GetResponse); ok {
+		identities = args.List
+	}
+	return threadID, emails, identities, nil
+}
@@ -0,0 +1,63 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestThreadParticipants(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	fake.Add("Email", map[string]any{
+		"id": "e1", "threadId": "T1", "receivedAt": "2025-01-02T10:00:00Z",
+		"from": []any{map[string]any{"email": "alice@example.org"}},
+		"to":   []any{map[string]any{"email": "me@example.com"}, map[string]any{"email": "ME@example.com"}},
+		"cc":   []any{map[string]any{"name": "Bob", "email": "bob@example.org"}},
+	})
+	fake.Add("Email", map[string]any{
+		"id": "e2", "threadId": "T1", "receivedAt": "2025-01-03T10:00:00Z",
+		"from": []any{map[string]any{"email": "me@example.com"}},
+		"to":   []any{map[string]any{"name": "Alice", "email": "alice@example.org"}},
+		"cc":   []any{map[string]any{"email": "bob@example.org"}, map[string]any{"email": "carol@example.org"}},
+	})
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1", "e2"}})
+	ctx := context.Background()
+
+	res, _, _ := s.handleThreadParticipants(ctx, nil, ThreadParticipantsInput{EmailID: "e2"})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("thread_participants: %s", out)
+	}
+	lines := strings.Split(out, "\n")
+	if lines[0] != "Thread T1: 2 message(s), 4 participant(s)" {
+		t.Errorf("header = %q", lines[0])
+	}
+	for i, want := range []string{
+		"alice@example.org> — from 1, to 1",
+		"me@example.com (you) — from 1, to 1",
+		"<bob@example.org> — cc 2",
+		"- carol@example.org — cc 1",
+	} {
+		if len(lines) < i+3 || !strings.HasSuffix(lines[i+2], want) {
+			t.Errorf("line %d lacks %q:\n%s", i+1, want, out)
+		}
+	}
+	if !strings.Contains(out, "Everyone else: ") || strings.Contains(out[strings.Index(out, "Everyone else"):], "me@example.com") {
+		t.Errorf("loop-in line:\n%s", out)
+	}
+
+	res, _, _ = s.handleThreadParticipants(ctx, nil, ThreadParticipantsInput{ThreadID: "T1"})
+	if out2 := resultText(res); out2 != out {
+		t.Errorf("by thread_id:\n%s\nby email_id:\n%s", out2, out)
+	}
+
+	res, _, _ = s.handleThreadParticipants(ctx, nil, ThreadParticipantsInput{ThreadID: "T9"})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeNotFound {
+		t.Errorf("missing thread: %s", resultText(res))
+	}
+	res, _, _ = s.handleThreadParticipants(ctx, nil, ThreadParticipantsInput{})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeInvalidArguments {
+		t.Errorf("no ID: %s", resultText(res))
+	}
+}
@@ -0,0 +1,315 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_preflight ---
+
+type EmailPreflightInput struct {
+	EmailID    string `json:"email_id" jsonschema:"ID of the draft to check"`
+	IdentityID string `json:"identity_id,omitempty" jsonschema:"Identity the draft will be sent with (default: the one email_submission_set picks, the first)"`
+}
+
+var emailPreflightTool = &mcp.Tool{
+	Name:        "email_preflight",
+	Description: "Check a draft for deliverability problems before sending: missing subject or body, no or malformed recipients, recipients the send policy refuses, attachments over the server's size limits or the attachment policy, a From address that does not match the sending identity's domain, and a Reply-To that loops back to a recipient. Returns a checklist of FAIL, WARN, and OK items; fix the FAILs (e.g. recreate the draft with email_create) before email_submission_set.",
+	Annotations: readOnlyAnnotations,
+}
+
+// preflightCheck is one checklist item.
+type preflightCheck struct {
+	status string // "FAIL", "WARN", "OK", or "SKIP"
+	name   string
+	detail string
+}
+
+func (s *Server) handleEmailPreflight(ctx context.Context, _ *mcp.CallToolRequest, in EmailPreflightInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account: accountID,
+		IDs:     []jmap.ID{jmap.ID(in.EmailID)},
+		Properties: []string{
+			"id", "keywords", "subject", "from", "to", "cc", "bcc", "replyTo",
+			"textBody", "htmlBody", "attachments", "size",
+		},
+	})
+	req.Invoke(&identity.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) < 2 {
+		return errorResult(fmt.Errorf("expected 2 responses, got %d", len(resp.Responses))), nil, nil
+	}
+
+	var draft *email.Email
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.List) == 0 {
+			return errorResult(notFoundError([]string{in.EmailID}, "email %s not found", in.EmailID)), nil, nil
+		}
+		draft = args.List[0]
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	// Without the submission capability Identity/get fails; the identity
+	// checks are then skipped.
+	var identities []*identity.Identity
+	identitiesKnown := false
+	switch args := resp.Responses[1].Args.(type) {
+	case *identity.GetResponse:
+		identities, identitiesKnown = args.List, true
+	case *jmap.MethodError:
+	default:
+		return errorResult(fmt.Errorf("unexpected identity response type: %T", args)), nil, nil
+	}
+
+	var id *identity.Identity
+	if in.IdentityID != "" {
+		for _, candidate := range identities {
+			if candidate.ID == jmap.ID(in.IdentityID) {
+				id = candidate
+			}
+		}
+		if identitiesKnown && id == nil {
+			return errorResult(notFoundError([]string{in.IdentityID}, "identity %s not found", in.IdentityID)), nil, nil
+		}
+	} else if id, err = s.sendPolicy.senderIdentity(identities); err != nil {
+		id = firstIdentity(identities)
+	}
+
+	checks := s.preflight(client.Session(), accountID, draft, id, identitiesKnown)
+	return textResult(formatPreflight(draft.ID, checks)), nil, nil
+}
+
+// preflight runs the checklist on draft, to be sent as id (nil when the
+// identities are unknown or there are none).
+func (s *Server) preflight(session *jmap.Session, accountID jmap.ID, draft *email.Email, id *identity.Identity, identitiesKnown bool) []preflightCheck {
+	var checks []preflightCheck
+	add := func(status, name, format string, args ...any) {
+		checks = append(checks, preflightCheck{status: status, name: name, detail: fmt.Sprintf(format, args...)})
+	}
+
+	if draft.Keywords["$draft"] {
+		add("OK", "Draft", "marked $draft")
+	} else {
+		add("WARN", "Draft", "not marked $draft; it may already have been sent")
+	}
+
+	if subject := strings.TrimSpace(draft.Subject); subject == "" {
+		add("FAIL", "Subject", "empty; many filters treat subjectless mail as spam")
+	} else {
+		add("OK", "Subject", "%q", subject)
+	}
+
+	if bodyEmpty(draft) {
+		add("WARN", "Body", "empty")
+	} else {
+		add("OK", "Body", "present")
+	}
+
+	recipients := draftRecipients(draft)
+	switch {
+	case len(recipients) == 0:
+		add("FAIL", "Recipients", "none in To, CC, or BCC")
+	case len(draft.To) == 0 && len(draft.CC) == 0:
+		add("WARN", "Recipients", "%d, all in BCC; an empty To is often scored as spam", len(recipients))
+	default:
+		add("OK", "Recipients", "%d", len(recipients))
+	}
+	var malformed []string
+	for _, a := range recipients {
+		if _, err := netmail.ParseAddress(a.Email); err != nil {
+			malformed = append(malformed, fmt.Sprintf("%q", a.Email))
+		}
+	}
+	if len(malformed) > 0 {
+		add("FAIL", "Addresses", "malformed: %s", strings.Join(malformed, ", "))
+	}
+
+	if len(recipients) > 0 {
+		if err := s.sendPolicy.checkRecipients(recipients); err != nil {
+			add("FAIL", "Send policy", "%v", err)
+		} else if s.sendPolicy != nil {
+			add("OK", "Send policy", "recipients allowed")
+		}
+	}
+	senders := draft.From
+	if id != nil {
+		senders = append([]*mail.Address{{Email: id.Email}}, senders...)
+	}
+	for _, a := range senders {
+		if err := s.sendPolicy.checkSender(a.Email); err != nil {
+			add("FAIL", "Send policy", "%v", err)
+			break
+		}
+	}
+
+	if id != nil {
+		if _, err := submissionEnvelope(session, accountID, id.Email, recipients); err != nil {
+			add("FAIL", "International addresses", "%v", err)
+		}
+	}
+
+	checks = append(checks, s.preflightAttachments(session, accountID, draft)...)
+
+	switch {
+	case !identitiesKnown:
+		add("SKIP", "Identity", "the server does not offer identities (no submission capability)")
+	case id == nil:
+		add("FAIL", "Identity", "the account has no sending identities")
+	default:
+		checks = append(checks, preflightIdentity(draft, id))
+	}
+
+	checks = append(checks, preflightReplyTo(draft, recipients))
+	return checks
+}
+
+// bodyEmpty reports whether draft has no text or HTML body content.
+func bodyEmpty(draft *email.Email) bool {
+	for _, parts := range [][]*email.BodyPart{draft.TextBody, draft.HTMLBody} {
+		for _, part := range parts {
+			if part.Size > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// preflightAttachments checks the attachments against the server's upload
+// and per-email limits and the attachment policy.
+func (s *Server) preflightAttachments(session *jmap.Session, accountID jmap.ID, draft *email.Email) []preflightCheck {
+	if len(draft.Attachments) == 0 {
+		return []preflightCheck{{status: "OK", name: "Attachments", detail: "none"}}
+	}
+	var checks []preflightCheck
+	sizes := make([]int, 0, len(draft.Attachments))
+	total := 0
+	for _, part := range draft.Attachments {
+		sizes = append(sizes, int(part.Size))
+		total += int(part.Size)
+		if err := s.attachmentPolicy.check(part.Name, part.Type, int(part.Size)); err != nil {
+			checks = append(checks, preflightCheck{status: "FAIL", name: "Attachment policy", detail: err.Error()})
+		}
+	}
+	if err := checkAttachmentsSize(session, accountID, sizes); err != nil {
+		checks = append(checks, preflightCheck{status: "FAIL", name: "Attachments", detail: err.Error()})
+	} else {
+		checks = append(checks, preflightCheck{status: "OK", name: "Attachments",
+			detail: fmt.Sprintf("%d, %s in total, within the server limits", len(sizes), s.locale.size(int64(total)))})
+	}
+	return checks
+}
+
+// preflightIdentity checks the draft's From against the sending identity:
+// another domain is refused by most servers or fails DMARC, another
+// address at the same domain may be.
+func preflightIdentity(draft *email.Email, id *identity.Identity) preflightCheck {
+	check := preflightCheck{name: "Identity"}
+	if len(draft.From) == 0 {
+		check.status, check.detail = "OK", fmt.Sprintf("no From; the server fills in %s", id.Email)
+		return check
+	}
+	var mismatched []string
+	sameDomain := true
+	for _, a := range draft.From {
+		if isOwnAddress(a.Email, []*identity.Identity{id}) {
+			continue
+		}
+		mismatched = append(mismatched, a.Email)
+		if addressDomain(a.Email) != addressDomain(id.Email) {
+			sameDomain = false
+		}
+	}
+	switch {
+	case len(mismatched) == 0:
+		check.status, check.detail = "OK", fmt.Sprintf("From matches identity %s", id.Email)
+	case !sameDomain:
+		check.status, check.detail = "FAIL", fmt.Sprintf("From %s is not in the domain of identity %s; the server may refuse it or recipients reject it (SPF/DMARC)", strings.Join(mismatched, ", "), id.Email)
+	default:
+		check.status, check.detail = "WARN", fmt.Sprintf("From %s differs from identity %s", strings.Join(mismatched, ", "), id.Email)
+	}
+	return check
+}
+
+// addressDomain returns the lowercased domain of addr in ASCII form, or "".
+func addressDomain(addr string) string {
+	_, domain, _ := strings.Cut(strings.ToLower(strings.TrimSpace(addr)), "@")
+	return asciiDomain(domain)
+}
+
+// preflightReplyTo warns when replies would go back to a recipient: a
+// Reply-To naming one of them makes "reply" answer the list itself.
+func preflightReplyTo(draft *email.Email, recipients []*mail.Address) preflightCheck {
+	check := preflightCheck{name: "Reply-To"}
+	if len(draft.ReplyTo) == 0 {
+		check.status, check.detail = "OK", "not set; replies go to From"
+		return check
+	}
+	var loops []string
+	for _, r := range draft.ReplyTo {
+		for _, a := range recipients {
+			if strings.EqualFold(strings.TrimSpace(r.Email), strings.TrimSpace(a.Email)) {
+				loops = append(loops, r.Email)
+				break
+			}
+		}
+	}
+	if len(loops) > 0 {
+		check.status, check.detail = "WARN", fmt.Sprintf("%s is also a recipient; replies will loop back to it", strings.Join(loops, ", "))
+		return check
+	}
+	check.status, check.detail = "OK", formatAddresses(draft.ReplyTo)
+	return check
+}
+
+func formatPreflight(emailID jmap.ID, checks []preflightCheck) string {
+	fails, warns := 0, 0
+	for _, c := range checks {
+		switch c.status {
+		case "FAIL":
+			fails++
+		case "WARN":
+			warns++
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Preflight of draft %s: %d problem(s), %d warning(s)\n", emailID, fails, warns)
+	for _, c := range checks {
+		fmt.Fprintf(&sb, "[%s] %s: %s\n", c.status, c.name, c.detail)
+	}
+	if fails == 0 {
+		sb.WriteString("\nReady to send.\n")
+	}
+	return sb.String()
+}
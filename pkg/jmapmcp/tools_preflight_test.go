@@ -0,0 +1,53 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEmailPreflight(t *testing.T) {
+	s, fake := newFakeServer(t, WithSendPolicy(SendPolicy{BlockedAddresses: []string{"*@blocked.example"}}))
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	fake.Add("Email", map[string]any{
+		"id": "d1", "keywords": map[string]any{"$draft": true},
+		"from":     []any{map[string]any{"email": "me@other.example"}},
+		"to":       []any{map[string]any{"email": "list@example.org"}, map[string]any{"email": "x@blocked.example"}},
+		"replyTo":  []any{map[string]any{"email": "list@example.org"}},
+		"textBody": []any{map[string]any{"partId": "1", "type": "text/plain", "size": 12}},
+	})
+	fake.Add("Email", map[string]any{
+		"id": "d2", "subject": "Lunch", "keywords": map[string]any{"$draft": true},
+		"from":     []any{map[string]any{"email": "me@example.com"}},
+		"to":       []any{map[string]any{"email": "bob@example.org"}},
+		"textBody": []any{map[string]any{"partId": "1", "type": "text/plain", "size": 20}},
+	})
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailPreflight(ctx, nil, EmailPreflightInput{EmailID: "d1"})
+	out := resultText(res)
+	for _, want := range []string{
+		"Preflight of draft d1: 3 problem(s), 1 warning(s)",
+		"[FAIL] Subject: empty",
+		"[FAIL] Send policy: ",
+		"[FAIL] Identity: From me@other.example is not in the domain of identity me@example.com",
+		"[WARN] Reply-To: list@example.org is also a recipient",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Ready to send") {
+		t.Errorf("failing draft reported ready:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailPreflight(ctx, nil, EmailPreflightInput{EmailID: "d2"})
+	if out := resultText(res); !strings.Contains(out, "0 problem(s), 0 warning(s)") || !strings.Contains(out, "[OK] Identity: From matches identity me@example.com") || !strings.Contains(out, "Ready to send.") {
+		t.Errorf("clean draft:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailPreflight(ctx, nil, EmailPreflightInput{EmailID: "d2", IdentityID: "nope"})
+	if te, ok := res.StructuredContent.(*toolError); !ok || te.Code != codeNotFound {
+		t.Errorf("unknown identity = %#v, want not_found", res.StructuredContent)
+	}
+}
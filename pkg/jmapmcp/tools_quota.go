@@ -0,0 +1,105 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/quota"
+)
+
+// --- quota_get ---
+
+type QuotaGetInput struct{}
+
+var quotaGetTool = &mcp.Tool{
+	Name:        "quota_get",
+	Description: "Get storage and message-count quotas of the account (Quota/get, RFC 9425): used amount, hard/soft/warn limits, and scope. Requires server support for urn:ietf:params:jmap:quota.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleQuotaGet(ctx context.Context, _ *mcp.CallToolRequest, _ QuotaGetInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if _, ok := client.Session().RawCapabilities[quota.URI]; !ok {
+		return errorResult(capabilityMissing(client.Session(), quota.URI)), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[quota.URI]
+	if accountID == "" {
+		accountID = client.Session().PrimaryAccounts[mail.URI]
+	}
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary quota account")), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&quota.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Quota/get")), nil, nil
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *quota.GetResponse:
+		if len(args.List) == 0 {
+			return textResult("No quotas apply to this account"), nil, nil
+		}
+		var sb strings.Builder
+		for _, q := range args.List {
+			sb.WriteString(formatQuota(q))
+		}
+		return textResult(sb.String()), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// formatQuota renders one quota with its usage percentage.
+func formatQuota(q *quota.Quota) string {
+	var sb strings.Builder
+	name := q.Name
+	if name == "" {
+		name = string(q.ID)
+	}
+	unit := ""
+	if q.ResourceType == "octets" {
+		unit = " bytes"
+	}
+	fmt.Fprintf(&sb, "%s [id: %s]\n", name, q.ID)
+	if q.HardLimit > 0 {
+		fmt.Fprintf(&sb, "  Used: %d of %d%s (%.1f%%)\n", q.Used, q.HardLimit, unit, float64(q.Used)*100/float64(q.HardLimit))
+	} else {
+		fmt.Fprintf(&sb, "  Used: %d%s (no hard limit)\n", q.Used, unit)
+	}
+	if q.SoftLimit != nil {
+		fmt.Fprintf(&sb, "  Soft limit: %d%s\n", *q.SoftLimit, unit)
+	}
+	if q.WarnLimit != nil {
+		fmt.Fprintf(&sb, "  Warn limit: %d%s\n", *q.WarnLimit, unit)
+	}
+	if q.Scope != "" {
+		fmt.Fprintf(&sb, "  Scope: %s\n", q.Scope)
+	}
+	if len(q.Types) > 0 {
+		fmt.Fprintf(&sb, "  Applies to: %s\n", strings.Join(q.Types, ", "))
+	}
+	if q.Description != "" {
+		fmt.Fprintf(&sb, "  %s\n", q.Description)
+	}
+	return sb.String()
+}
@@ -0,0 +1,301 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// inlineImageBudget caps the total bytes of inline images embedded into a
+// rendered document; images beyond it are left as unresolved cid: links.
+const inlineImageBudget = 5 << 20
+
+// pdfRenderTimeout bounds the external PDF renderer.
+const pdfRenderTimeout = 60 * time.Second
+
+// --- email_render ---
+
+type EmailRenderInput struct {
+	EmailID string `json:"email_id" jsonschema:"ID of the email to render"`
+	Format  string `json:"format,omitempty" jsonschema:"Output format: html (default) or pdf (requires the server to be configured with a PDF renderer)"`
+	SaveTo  string `json:"save_to,omitempty" jsonschema:"Save the document as a file inside the client's filesystem roots instead of returning it: a new file, or an existing directory to save it in as email-<id>.<format> (stdio mode only)"`
+}
+
+var emailRenderTool = &mcp.Tool{
+	Name:        "email_render",
+	Description: "Render an email as a standalone, sanitized HTML document (scripts, active content, and tracking pixels removed, inline images embedded) or as PDF when a renderer is configured. Returned as an embedded MCP resource for archiving or sharing outside the mail system, or with save_to written to a local file.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailRender(ctx context.Context, mcpReq *mcp.CallToolRequest, in EmailRenderInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	format := in.Format
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "pdf" {
+		return errorResult(invalidArgument("unknown format %q: expected html or pdf", format)), nil, nil
+	}
+	if in.SaveTo != "" && !s.localFiles {
+		return errorResult(invalidArgument("save_to is only available in stdio mode")), nil, nil
+	}
+	if format == "pdf" && len(s.pdfRenderer) == 0 {
+		return errorResult(fmt.Errorf("pdf output requires the server to be started with -pdf-renderer")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	getReq := &jmap.Request{Context: ctx}
+	getReq.Invoke(&email.Get{
+		Account: accountID,
+		IDs:     []jmap.ID{jmap.ID(in.EmailID)},
+		Properties: []string{
+			"id", "subject", "from", "to", "cc", "receivedAt",
+			"bodyValues", "textBody", "htmlBody", "attachments",
+		},
+		FetchAllBodyValues: true,
+	})
+
+	resp, err := client.Do(getReq)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/get")), nil, nil
+	}
+
+	var e *email.Email
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return errorResult(notFoundError([]string{in.EmailID}, "email not found: %s", in.EmailID)), nil, nil
+		}
+		e = args.List[0]
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	images := s.fetchInlineImages(ctx, client, accountID, e)
+	doc := renderEmailDocument(e, images, s.redactor)
+
+	uri := fmt.Sprintf("jmap://%s/email/%s/render.%s", accountID, e.ID, format)
+	resource := &mcp.ResourceContents{URI: uri, MIMEType: "text/html", Text: doc}
+	if format == "pdf" {
+		pdf, err := s.renderPDF(ctx, doc)
+		if err != nil {
+			return errorResult(fmt.Errorf("render pdf: %w", err)), nil, nil
+		}
+		resource = &mcp.ResourceContents{URI: uri, MIMEType: "application/pdf", Blob: pdf}
+	}
+
+	result := fmt.Sprintf("Rendered email %s as %s (%d inline image(s) embedded)", e.ID, format, len(images))
+	if in.SaveTo != "" {
+		data := resource.Blob
+		if data == nil {
+			data = []byte(resource.Text)
+		}
+		path, err := s.writeLocalFile(ctx, mcpReq, in.SaveTo, fmt.Sprintf("email-%s.%s", e.ID, format), data)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		return textResult(fmt.Sprintf("%s and saved it to %s", result, path)), nil, nil
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: result},
+			&mcp.EmbeddedResource{Resource: resource},
+		},
+	}, nil, nil
+}
+
+// fetchInlineImages downloads image parts referenced by Content-ID and
+// returns them as data: URIs keyed by CID. Failures and parts beyond
+// inlineImageBudget are skipped; the document still renders without them.
+func (s *Server) fetchInlineImages(ctx context.Context, client JMAPClient, accountID jmap.ID, e *email.Email) map[string]string {
+	images := make(map[string]string)
+	budget := inlineImageBudget
+	parts := append(append([]*email.BodyPart(nil), e.HTMLBody...), e.Attachments...)
+	for _, part := range parts {
+		if part.CID == "" || !strings.HasPrefix(part.Type, "image/") || int(part.Size) > budget {
+			continue
+		}
+		cid := strings.Trim(part.CID, "<>")
+		if _, ok := images[cid]; ok {
+			continue
+		}
+		body, err := client.Download(ctx, accountID, part.BlobID)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(body, int64(budget)+1))
+		body.Close()
+		if err != nil || len(data) > budget {
+			continue
+		}
+		budget -= len(data)
+		images[cid] = "data:" + part.Type + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	return images
+}
+
+// renderEmailDocument builds a standalone HTML document: an escaped header
+// block followed by the sanitized HTML body (or the text body as <pre>),
+// with cid: image references replaced by the given data URIs and body text
+// passed through the redactor.
+func renderEmailDocument(e *email.Email, images map[string]string, r *Redactor) string {
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>")
+	sb.WriteString(html.EscapeString(e.Subject))
+	sb.WriteString("</title></head><body>\n<table class=\"email-headers\">\n")
+	row := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&sb, "<tr><th align=\"left\">%s</th><td>%s</td></tr>\n", name, html.EscapeString(value))
+		}
+	}
+	row("From", formatAddresses(e.From))
+	row("To", formatAddresses(e.To))
+	row("CC", formatAddresses(e.CC))
+	if e.ReceivedAt != nil {
+		row("Date", e.ReceivedAt.Format(time.RFC1123Z))
+	}
+	row("Subject", e.Subject)
+	sb.WriteString("</table>\n<hr>\n")
+
+	for _, part := range e.HTMLBody {
+		if bv, ok := e.BodyValues[part.PartID]; ok && part.Type == "text/html" {
+			sb.WriteString(sanitizedBodyFragment(bv.Value, images, r))
+			sb.WriteString("\n</body></html>\n")
+			return sb.String()
+		}
+	}
+	for _, part := range e.TextBody {
+		if bv, ok := e.BodyValues[part.PartID]; ok {
+			text, _ := r.redact(bv.Value)
+			fmt.Fprintf(&sb, "<pre style=\"white-space: pre-wrap\">%s</pre>", html.EscapeString(text))
+			break
+		}
+	}
+	sb.WriteString("\n</body></html>\n")
+	return sb.String()
+}
+
+// sanitizedBodyFragment sanitizes an HTML body, resolves cid: images,
+// redacts text nodes, and returns the inner content of its <body> for
+// embedding in another document.
+func sanitizedBodyFragment(rawHTML string, images map[string]string, r *Redactor) string {
+	doc, err := xhtml.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		text, _ := r.redact(rawHTML)
+		return "<pre>" + html.EscapeString(text) + "</pre>"
+	}
+	sanitizeNode(doc, &sanitizeReport{})
+	resolveCIDImages(doc, images)
+	redactTextNodes(doc, r)
+
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	var buf bytes.Buffer
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := xhtml.Render(&buf, c); err != nil {
+			text, _ := r.redact(rawHTML)
+			return "<pre>" + html.EscapeString(text) + "</pre>"
+		}
+	}
+	return buf.String()
+}
+
+// resolveCIDImages rewrites src="cid:..." attributes to the matching data URI.
+func resolveCIDImages(n *xhtml.Node, images map[string]string) {
+	if n.Type == xhtml.ElementNode {
+		for i, a := range n.Attr {
+			if a.Key != "src" {
+				continue
+			}
+			if cid, ok := strings.CutPrefix(a.Val, "cid:"); ok {
+				if data, ok := images[cid]; ok {
+					n.Attr[i].Val = data
+				}
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		resolveCIDImages(c, images)
+	}
+}
+
+// redactTextNodes masks secrets in every text node below n.
+func redactTextNodes(n *xhtml.Node, r *Redactor) {
+	if r == nil {
+		return
+	}
+	if n.Type == xhtml.TextNode {
+		n.Data, _ = r.redact(n.Data)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		redactTextNodes(c, r)
+	}
+}
+
+// findElement returns the first element with the given atom in document order.
+func findElement(n *xhtml.Node, a atom.Atom) *xhtml.Node {
+	if n.Type == xhtml.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// renderPDF pipes the HTML document through the configured external
+// renderer (HTML on stdin, PDF on stdout).
+func (s *Server) renderPDF(ctx context.Context, doc string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, pdfRenderTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.pdfRenderer[0], s.pdfRenderer[1:]...)
+	cmd.Stdin = strings.NewReader(doc)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("renderer produced no output")
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,340 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_reply ---
+
+type EmailReplyInput struct {
+	EmailID    string `json:"email_id" jsonschema:"ID of the email being replied to"`
+	Body       string `json:"body,omitempty" jsonschema:"Plain text reply body"`
+	HTML       string `json:"html_body,omitempty" jsonschema:"Optional HTML version of the body, sent alongside the plain text one (derived from the HTML when body is empty). Scripts, forms, event handlers, and tracking pixels are removed, and links whose text is an address other than their target are unlinked; the result lists every change."`
+	ReplyAll   bool   `json:"reply_all,omitempty" jsonschema:"Reply to all original recipients (To and CC) instead of only the sender (default false). The user's own addresses are always excluded."`
+	Importance string `json:"importance,omitempty" jsonschema:"Mark the message high or low importance: sets X-Priority and Importance headers, and high also sets the $important keyword (default normal)"`
+	Quote      bool   `json:"quote,omitempty" jsonschema:"Include the original below the reply: an 'On <date>, <sender> wrote:' line followed by its text with '> ' prefixes, and in the HTML body as a blockquote"`
+}
+
+var emailReplyTool = &mcp.Tool{
+	Name:        "email_reply",
+	Description: "Create a reply draft to an email in the Drafts mailbox, with threading headers (In-Reply-To, References) and a Re: subject. Replies to the sender (Reply-To if set) by default; set reply_all to include the original To and CC recipients. Set quote to include the original message, quoted, below the reply. The user's own identities are never added as recipients. Returns the draft ID for email_submission_set.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailReply(ctx context.Context, _ *mcp.CallToolRequest, in EmailReplyInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	if err := checkImportance(in.Importance); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	reply, err := s.createReply(ctx, client, in)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(reply.format()), nil, nil
+}
+
+// replyDraft is a reply draft createReply created.
+type replyDraft struct {
+	id          jmap.ID // empty when the server did not report it
+	draft       *email.Email
+	original    *email.Email
+	added       []string // recipients the draft defaults added
+	htmlChanges []string // what the outbound HTML sanitizer changed
+}
+
+// format describes the draft for a tool result.
+func (r *replyDraft) format() string {
+	var sb strings.Builder
+	if r.id != "" {
+		fmt.Fprintf(&sb, "Created reply draft [id: %s]\n", r.id)
+	} else {
+		sb.WriteString("Created reply draft\n")
+	}
+	fmt.Fprintf(&sb, "To: %s\n", formatAddresses(r.draft.To))
+	if len(r.draft.CC) > 0 {
+		fmt.Fprintf(&sb, "CC: %s\n", formatAddresses(r.draft.CC))
+	}
+	fmt.Fprintf(&sb, "Subject: %s\n", r.draft.Subject)
+	sb.WriteString(formatDraftDefaults(r.added))
+	sb.WriteString(formatHTMLChanges(r.htmlChanges))
+	return sb.String()
+}
+
+// createReply creates the draft replying to in.EmailID as in describes,
+// checking the send policy.
+func (s *Server) createReply(ctx context.Context, client JMAPClient, in EmailReplyInput) (*replyDraft, error) {
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return nil, fmt.Errorf("no primary mail account")
+	}
+
+	// Discovery request: original email, Drafts mailbox, and own identities.
+	discoverReq := &jmap.Request{Context: ctx}
+	originalGet := &email.Get{
+		Account: accountID,
+		IDs:     []jmap.ID{jmap.ID(in.EmailID)},
+		Properties: []string{
+			"id", "threadId", "subject", "from", "to", "cc", "replyTo",
+			"messageId", "references",
+		},
+	}
+	if in.Quote {
+		originalGet.Properties = append(originalGet.Properties, "sentAt", "receivedAt", "bodyValues", "textBody", "htmlBody")
+		originalGet.FetchTextBodyValues = true
+		originalGet.FetchHTMLBodyValues = true
+	}
+	discoverReq.Invoke(originalGet)
+	discoverReq.Invoke(&mailbox.Get{Account: accountID})
+	discoverReq.Invoke(&identity.Get{Account: accountID})
+
+	discoverResp, err := client.Do(discoverReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(discoverResp.Responses) < 3 {
+		return nil, fmt.Errorf("expected 3 discovery responses, got %d", len(discoverResp.Responses))
+	}
+
+	var original *email.Email
+	switch args := discoverResp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return nil, notFoundError([]string{in.EmailID}, "email not found: %s", in.EmailID)
+		}
+		original = args.List[0]
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected email response type: %T", args)
+	}
+
+	var draftsID jmap.ID
+	switch args := discoverResp.Responses[1].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			if mb.Role == mailbox.RoleDrafts {
+				draftsID = mb.ID
+			}
+		}
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected mailbox response type: %T", args)
+	}
+	if draftsID == "" {
+		return nil, fmt.Errorf("no Drafts mailbox found")
+	}
+
+	var identities []*identity.Identity
+	switch args := discoverResp.Responses[2].Args.(type) {
+	case *identity.GetResponse:
+		identities = args.List
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected identity response type: %T", args)
+	}
+
+	to, cc := replyRecipients(original, identities, in.ReplyAll)
+	if len(to) == 0 && len(cc) == 0 {
+		return nil, fmt.Errorf("no recipients left after excluding your own addresses")
+	}
+	if err := s.sendPolicy.checkRecipients(append(append([]*mail.Address(nil), to...), cc...)); err != nil {
+		return nil, err
+	}
+
+	draft := &email.Email{
+		MailboxIDs: map[jmap.ID]bool{draftsID: true},
+		Keywords:   map[string]bool{"$draft": true},
+		To:         to,
+		CC:         cc,
+		Subject:    replySubject(original.Subject),
+		InReplyTo:  original.MessageID,
+		References: append(append([]string(nil), original.References...), original.MessageID...),
+	}
+	htmlChanges := setDraftBody(draft, in.Body, in.HTML)
+	if in.Quote {
+		s.appendQuote(draft, original)
+	}
+	applyImportance(draft, in.Importance)
+	sender, err := s.sendPolicy.senderIdentity(identities)
+	if err != nil {
+		return nil, err
+	}
+	added := s.applyDraftDefaults(draft, sender)
+	if len(added) > 0 {
+		if err := s.sendPolicy.checkRecipients(draftRecipients(draft)); err != nil {
+			return nil, err
+		}
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Set{
+		Account: accountID,
+		Create:  map[jmap.ID]*email.Email{"draft": draft},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Email/set")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		if se, ok := args.NotCreated["draft"]; ok {
+			return nil, setFailure("reply draft creation failed", se)
+		}
+		reply := &replyDraft{draft: draft, original: original, added: added, htmlChanges: htmlChanges}
+		if created, ok := args.Created["draft"]; ok {
+			reply.id = created.ID
+			s.recordDraftOrigin(ctx, created.ID, original.ID, "$answered")
+		}
+		return reply, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// appendQuote adds original, quoted, below the reply in draft: after an
+// attribution line, its text with "> " prefixes in the plain body and in a
+// blockquote in the HTML body, when there is one.
+func (s *Server) appendQuote(draft *email.Email, original *email.Email) {
+	sender := "someone"
+	if len(original.From) > 0 {
+		sender = formatAddresses(original.From[:1])
+	}
+	attribution := sender + " wrote:"
+	date := original.SentAt
+	if date == nil {
+		date = original.ReceivedAt
+	}
+	if date != nil {
+		attribution = fmt.Sprintf("On %s, %s", s.locale.dateTime(*date), attribution)
+	}
+	text := strings.TrimRight(strings.ReplaceAll(originalText(original), "\r\n", "\n"), "\n")
+
+	body := draft.BodyValues["body"]
+	if reply := strings.TrimRight(body.Value, "\n"); reply != "" {
+		body.Value = reply + "\n\n"
+	}
+	body.Value += attribution + "\n" + quoteLines(text) + "\n"
+	if bv, ok := draft.BodyValues["html"]; ok {
+		quoted := fmt.Sprintf(`<div>%s</div><blockquote type="cite" style="margin:0 0 0 .8ex;border-left:1px solid #ccc;padding-left:1ex">%s</blockquote>`,
+			html.EscapeString(attribution), strings.ReplaceAll(html.EscapeString(text), "\n", "<br>"))
+		if i := strings.LastIndex(bv.Value, "</body>"); i >= 0 {
+			bv.Value = bv.Value[:i] + quoted + bv.Value[i:]
+		} else {
+			bv.Value += quoted
+		}
+	}
+}
+
+// quoteLines prefixes each line of text for quoting: "> ", or ">" before a
+// line already quoted, so nested quotes read ">>".
+func quoteLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, ">"):
+			lines[i] = ">" + line
+		case line == "":
+			lines[i] = ">"
+		default:
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replyRecipients computes To and CC for a reply. The sender (or Reply-To)
+// goes to To; with replyAll the original To and CC go to CC. Addresses
+// belonging to the user's identities are dropped and duplicates collapsed.
+// When the original was sent by the user, the reply goes to its recipients.
+func replyRecipients(original *email.Email, identities []*identity.Identity, replyAll bool) (to, cc []*mail.Address) {
+	own := func(a *mail.Address) bool { return isOwnAddress(a.Email, identities) }
+
+	primary := original.ReplyTo
+	if len(primary) == 0 {
+		primary = original.From
+	}
+	fromSelf := len(primary) > 0
+	for _, a := range primary {
+		if !own(a) {
+			fromSelf = false
+		}
+	}
+	if fromSelf {
+		primary = original.To
+	}
+
+	seen := make(map[string]bool)
+	add := func(dst []*mail.Address, src []*mail.Address) []*mail.Address {
+		for _, a := range src {
+			key := strings.ToLower(a.Email)
+			if a.Email == "" || seen[key] || own(a) {
+				continue
+			}
+			seen[key] = true
+			dst = append(dst, a)
+		}
+		return dst
+	}
+
+	to = add(to, primary)
+	if replyAll {
+		if !fromSelf {
+			cc = add(cc, original.To)
+		}
+		cc = add(cc, original.CC)
+	}
+	return to, cc
+}
+
+// isOwnAddress reports whether addr matches one of the user's identities,
+// including wildcard identities of the form *@domain.
+func isOwnAddress(addr string, identities []*identity.Identity) bool {
+	addr = strings.ToLower(addr)
+	for _, id := range identities {
+		pattern := strings.ToLower(id.Email)
+		if pattern == addr {
+			return true
+		}
+		if domain, ok := strings.CutPrefix(pattern, "*@"); ok && strings.HasSuffix(addr, "@"+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// replySubject prefixes subject with "Re: " unless it already carries a
+// reply prefix.
+func replySubject(subject string) string {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(subject)), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
@@ -0,0 +1,138 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+)
+
+func addrs(emails ...string) []*mail.Address {
+	out := make([]*mail.Address, len(emails))
+	for i, e := range emails {
+		out[i] = &mail.Address{Email: e}
+	}
+	return out
+}
+
+func emailsOf(as []*mail.Address) []string {
+	out := make([]string, len(as))
+	for i, a := range as {
+		out[i] = a.Email
+	}
+	return out
+}
+
+func TestReplyRecipients(t *testing.T) {
+	identities := []*identity.Identity{
+		{Email: "me@example.com"},
+		{Email: "*@me.dev"},
+	}
+	original := &email.Email{
+		From: addrs("alice@example.org"),
+		To:   addrs("me@example.com", "bob@example.org"),
+		CC:   addrs("alias@me.dev", "carol@example.org", "BOB@example.org"),
+	}
+
+	t.Run("sender only", func(t *testing.T) {
+		to, cc := replyRecipients(original, identities, false)
+		if got := emailsOf(to); len(got) != 1 || got[0] != "alice@example.org" {
+			t.Fatalf("to = %v", got)
+		}
+		if len(cc) != 0 {
+			t.Fatalf("cc = %v, want empty", emailsOf(cc))
+		}
+	})
+
+	t.Run("reply all excludes own identities and duplicates", func(t *testing.T) {
+		to, cc := replyRecipients(original, identities, true)
+		if got := emailsOf(to); len(got) != 1 || got[0] != "alice@example.org" {
+			t.Fatalf("to = %v", got)
+		}
+		got := emailsOf(cc)
+		want := []string{"bob@example.org", "carol@example.org"}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("cc = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("reply-to takes precedence", func(t *testing.T) {
+		withReplyTo := &email.Email{From: addrs("alice@example.org"), ReplyTo: addrs("list@example.org")}
+		to, _ := replyRecipients(withReplyTo, identities, false)
+		if got := emailsOf(to); len(got) != 1 || got[0] != "list@example.org" {
+			t.Fatalf("to = %v", got)
+		}
+	})
+
+	t.Run("replying to own message targets its recipients", func(t *testing.T) {
+		sent := &email.Email{From: addrs("me@example.com"), To: addrs("dave@example.org")}
+		to, _ := replyRecipients(sent, identities, true)
+		if got := emailsOf(to); len(got) != 1 || got[0] != "dave@example.org" {
+			t.Fatalf("to = %v", got)
+		}
+	})
+}
+
+func TestReplySubject(t *testing.T) {
+	tests := map[string]string{
+		"Hello":        "Re: Hello",
+		"Re: Hello":    "Re: Hello",
+		"RE: Hello":    "RE: Hello",
+		"":             "Re: ",
+		"Regarding it": "Re: Regarding it",
+	}
+	for in, want := range tests {
+		if got := replySubject(in); got != want {
+			t.Errorf("replySubject(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestQuoteLines(t *testing.T) {
+	got := quoteLines("Hi Bob,\n\n> earlier\nThanks")
+	if want := "> Hi Bob,\n>\n>> earlier\n> Thanks"; got != want {
+		t.Errorf("quoteLines = %q, want %q", got, want)
+	}
+}
+
+func TestEmailReplyQuote(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	addEmail(fake, "e1", "Lunch", "Are you free Friday?\n\n> from last week", 2)
+
+	res, _, _ := s.handleEmailReply(context.Background(), nil, EmailReplyInput{EmailID: "e1", Body: "Yes, noon works.", HTML: "<p>Yes, noon works.</p>", Quote: true})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	var draft map[string]any
+	for _, id := range fake.Objects("Email") {
+		if e := fake.Object("Email", id); e["subject"] == "Re: Lunch" {
+			draft = e
+		}
+	}
+	values, _ := draft["bodyValues"].(map[string]any)
+	text, _ := values["body"].(map[string]any)["value"].(string)
+	if want := "Yes, noon works.\n\nOn " + s.locale.dateTime(time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)) +
+		", Alice <alice@example.org> wrote:\n> Are you free Friday?\n>\n>> from last week\n"; text != want {
+		t.Errorf("quoted text body = %q, want %q", text, want)
+	}
+	htmlBody, _ := values["html"].(map[string]any)["value"].(string)
+	if !strings.Contains(htmlBody, `<p>Yes, noon works.</p><div>On `) || !strings.Contains(htmlBody, `Are you free Friday?<br><br>&gt; from last week</blockquote></body>`) {
+		t.Errorf("quoted HTML body = %q", htmlBody)
+	}
+
+	if res, _, _ = s.handleEmailReply(context.Background(), nil, EmailReplyInput{EmailID: "e1", Body: "No quote."}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	for _, id := range fake.Objects("Email") {
+		if e := fake.Object("Email", id); e["subject"] == "Re: Lunch" && strings.Contains(fmt.Sprint(e["bodyValues"]), "No quote.") && strings.Contains(fmt.Sprint(e["bodyValues"]), "wrote:") {
+			t.Errorf("reply without quote = %v", e["bodyValues"])
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultReplyContextMessages = 5
+	maxReplyContextMessages     = 20
+	defaultReplyContextPrior    = 3
+	maxReplyContextPrior        = 10
+)
+
+// --- reply_context ---
+
+type ReplyContextInput struct {
+	EmailID      string `json:"email_id" jsonschema:"ID of the email being replied to"`
+	Messages     int    `json:"messages,omitempty" jsonschema:"How many of the thread's latest messages to include (default 5, max 20); the email being replied to is always included"`
+	PriorReplies int    `json:"prior_replies,omitempty" jsonschema:"How many of the user's earlier messages to the same correspondent, outside this thread, to include as examples of tone (default 3, max 10; 0 uses the default, -1 omits them)"`
+	MaxChars     int    `json:"max_chars,omitempty" jsonschema:"Size budget of the whole packet in characters (default: the server's email_get budget)"`
+}
+
+var replyContextTool = &mcp.Tool{
+	Name:        "reply_context",
+	Description: "Assemble the context for drafting a reply to an email in one call: the thread's latest messages oldest first with quoted text and signatures stripped, the participant list, and the user's own earlier messages to the same correspondent as examples of tone, all within a character budget. Follow with email_reply to create the draft.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleReplyContext(ctx context.Context, _ *mcp.CallToolRequest, in ReplyContextInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	messages := in.Messages
+	if messages <= 0 {
+		messages = defaultReplyContextMessages
+	}
+	if messages > maxReplyContextMessages {
+		return errorResult(invalidArgument("messages must be at most %d", maxReplyContextMessages)), nil, nil
+	}
+	prior := in.PriorReplies
+	switch {
+	case prior == 0:
+		prior = defaultReplyContextPrior
+	case prior < 0:
+		prior = 0
+	case prior > maxReplyContextPrior:
+		return errorResult(invalidArgument("prior_replies must be at most %d", maxReplyContextPrior)), nil, nil
+	}
+	maxChars := in.MaxChars
+	if maxChars <= 0 {
+		maxChars = s.maxChars
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	anchor, threadEmails, identities, err := replyThread(ctx, client, accountID, jmap.ID(in.EmailID))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	slices.SortStableFunc(threadEmails, func(a, b *email.Email) int {
+		return receivedAt(a).Compare(receivedAt(b))
+	})
+	shown := latestWith(threadEmails, anchor.ID, messages)
+
+	// Each body gets an even share of the budget, leaving a quarter of it
+	// for headers and the participant list.
+	perBody := max(maxChars*3/4/(len(shown)+prior), 200)
+	fetchBytes := bodyFetchBytes(perBody, TruncateHead)
+	if err := s.fetchBodies(ctx, client, accountID, shown, fetchBytes, false); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	// Earlier messages only set the tone; failing to find them (no Sent
+	// mailbox, say) leaves a note rather than failing the call.
+	correspondent := replyCorrespondent(anchor, identities)
+	var priorEmails []*email.Email
+	var priorErr error
+	if prior > 0 && correspondent != "" {
+		priorEmails, priorErr = s.priorReplies(ctx, client, accountID, correspondent, anchor.ThreadID, prior, fetchBytes)
+	}
+
+	var sb strings.Builder
+	subject := anchor.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	fmt.Fprintf(&sb, "# Reply context: %s\n\n", subject)
+	fmt.Fprintf(&sb, "Replying to %s from %s in thread %s (%d message(s), %d shown)\n", anchor.ID, formatAddresses(anchor.From), anchor.ThreadID, len(threadEmails), len(shown))
+
+	sb.WriteString("\n## Participants\n\n")
+	for _, p := range threadParticipants(threadEmails) {
+		fmt.Fprintf(&sb, "- %s\n", p)
+	}
+
+	sb.WriteString("\n## Thread, oldest first\n")
+	for _, e := range shown {
+		s.writeReplyContextMessage(&sb, e, identities, e.ID == anchor.ID, perBody)
+	}
+
+	if prior > 0 && correspondent != "" {
+		fmt.Fprintf(&sb, "\n## Your earlier messages to %s\n", correspondent)
+		switch {
+		case priorErr != nil:
+			fmt.Fprintf(&sb, "\nUnavailable: %v\n", priorErr)
+		case len(priorEmails) == 0:
+			sb.WriteString("\nNone outside this thread.\n")
+		}
+		for _, e := range priorEmails {
+			s.writeReplyContextMessage(&sb, e, identities, false, perBody)
+		}
+	}
+
+	return textResult(TruncateBody(sb.String(), maxChars)), nil, nil
+}
+
+// replyThread fetches the email, its thread's emails with their body
+// structure, and the identities in one request. identities is nil when
+// the server offers none.
+func replyThread(ctx context.Context, client JMAPClient, accountID, emailID jmap.ID) (*email.Email, []*email.Email, []*identity.Identity, error) {
+	req := &jmap.Request{Context: ctx}
+	anchorCallID := req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        []jmap.ID{emailID},
+		Properties: []string{"id", "threadId"},
+	})
+	threadCallID := req.Invoke(&thread.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: anchorCallID, Name: "Email/get", Path: "/list/*/threadId"},
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: threadCallID, Name: "Thread/get", Path: "/list/*/emailIds"},
+		Properties:   []string{"id", "threadId", "subject", "from", "to", "cc", "replyTo", "receivedAt", "textBody", "htmlBody"},
+	})
+	req.Invoke(&identity.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(resp.Responses) < 4 {
+		return nil, nil, nil, fmt.Errorf("expected 4 responses, got %d", len(resp.Responses))
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.List) == 0 {
+			return nil, nil, nil, notFoundError([]jmap.ID{emailID}, "email %s not found", emailID)
+		}
+	case *jmap.MethodError:
+		return nil, nil, nil, args
+	default:
+		return nil, nil, nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+	if me, ok := resp.Responses[1].Args.(*jmap.MethodError); ok {
+		return nil, nil, nil, me
+	}
+
+	var emails []*email.Email
+	switch args := resp.Responses[2].Args.(type) {
+	case *email.GetResponse:
+		emails = args.List
+	case *jmap.MethodError:
+		return nil, nil, nil, args
+	default:
+		return nil, nil, nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+	var anchor *email.Email
+	for _, e := range emails {
+		if e.ID == emailID {
+			anchor = e
+		}
+	}
+	if anchor == nil {
+		return nil, nil, nil, fmt.Errorf("email %s missing from its thread", emailID)
+	}
+
+	var identities []*identity.Identity
+	if args, ok := resp.Responses[3].Args.(*identity.GetResponse); ok {
+		identities = args.List
+	}
+	return anchor, emails, identities, nil
+}
+
+// latestWith returns the last n of emails (sorted oldest first), replacing
+// the oldest of them with the email with ID id when it would be left out.
+func latestWith(emails []*email.Email, id jmap.ID, n int) []*email.Email {
+	if len(emails) <= n {
+		return emails
+	}
+	shown := slices.Clone(emails[len(emails)-n:])
+	if slices.ContainsFunc(shown, func(e *email.Email) bool { return e.ID == id }) {
+		return shown
+	}
+	for _, e := range emails {
+		if e.ID == id {
+			return append([]*email.Email{e}, shown[1:]...)
+		}
+	}
+	return shown
+}
+
+// replyCorrespondent is the address the reply goes to: the sender of
+// anchor or, when the user sent it, its first recipient.
+func replyCorrespondent(anchor *email.Email, identities []*identity.Identity) string {
+	for _, a := range anchor.From {
+		if !isOwnAddress(a.Email, identities) {
+			return strings.ToLower(a.Email)
+		}
+	}
+	for _, a := range anchor.To {
+		if !isOwnAddress(a.Email, identities) {
+			return strings.ToLower(a.Email)
+		}
+	}
+	return ""
+}
+
+// priorReplies returns the user's latest n messages to correspondent in
+// the Sent mailbox outside threadID, with their bodies.
+func (s *Server) priorReplies(ctx context.Context, client JMAPClient, accountID jmap.ID, correspondent string, threadID jmap.ID, n int, fetchBytes uint64) ([]*email.Email, error) {
+	sentID, err := s.findMailboxByRole(ctx, client, accountID, mailbox.RoleSent)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &jmap.Request{Context: ctx}
+	queryCallID := req.Invoke(&email.Query{
+		Account: accountID,
+		Filter:  &email.FilterCondition{InMailbox: sentID, To: correspondent},
+		Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+		// Messages of this thread are skipped, so look a little further.
+		Limit: uint64(n + maxReplyContextMessages),
+	})
+	req.Invoke(&email.Get{
+		Account:             accountID,
+		ReferenceIDs:        &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+		Properties:          []string{"id", "threadId", "subject", "from", "to", "cc", "receivedAt", "textBody", "htmlBody", "bodyValues"},
+		FetchTextBodyValues: true,
+		MaxBodyValueBytes:   fetchBytes,
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 2 {
+		return nil, fmt.Errorf("missing Email/get response in query chain")
+	}
+	if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+		return nil, me
+	}
+
+	var list []*email.Email
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		list = args.List
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+	var out []*email.Email
+	for _, e := range list {
+		if e.ThreadID != threadID && len(out) < n {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// writeReplyContextMessage writes one message with its de-quoted body cut
+// to limit characters.
+func (s *Server) writeReplyContextMessage(sb *strings.Builder, e *email.Email, identities []*identity.Identity, replyingTo bool, limit int) {
+	fmt.Fprintf(sb, "\n### %s, %s", s.locale.dateTime(receivedAt(e)), formatAddresses(e.From))
+	for _, a := range e.From {
+		if isOwnAddress(a.Email, identities) {
+			sb.WriteString(" (you)")
+			break
+		}
+	}
+	if replyingTo {
+		sb.WriteString(" (replying to this)")
+	}
+	fmt.Fprintf(sb, " [id: %s]\n", e.ID)
+	if len(e.To) > 0 {
+		fmt.Fprintf(sb, "To: %s\n", formatAddresses(e.To))
+	}
+	if len(e.CC) > 0 {
+		fmt.Fprintf(sb, "CC: %s\n", formatAddresses(e.CC))
+	}
+	body, _ := s.redactor.redact(extractBody(e, limit, TruncateHead, quotesStrip))
+	if body == "" {
+		body = "(no body content)"
+	}
+	fmt.Fprintf(sb, "\n%s\n", strings.TrimSpace(body))
+}
@@ -0,0 +1,79 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func addReplyContextEmail(fake *jmapfake.Server, id, thread, from, to, mailbox, body string, day int) {
+	fake.Add("Email", map[string]any{
+		"id":         id,
+		"threadId":   thread,
+		"subject":    "Offsite",
+		"from":       []any{map[string]any{"email": from}},
+		"to":         []any{map[string]any{"email": to}},
+		"receivedAt": fmt.Sprintf("2025-01-%02dT10:00:00Z", day),
+		"mailboxIds": map[string]any{mailbox: true},
+		"textBody":   []any{map[string]any{"partId": "1", "type": "text/plain", "size": len(body)}},
+		"bodyValues": map[string]any{"1": map[string]any{"value": body}},
+	})
+}
+
+func TestReplyContext(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	addReplyContextEmail(fake, "e1", "T1", "alice@example.org", "me@example.com", "inbox", "Shall we meet Tuesday?", 2)
+	addReplyContextEmail(fake, "e2", "T1", "me@example.com", "alice@example.org", "sent", "Tuesday works.\n\nOn Wed, Jan 1, 2025 at 10:00 AM Alice <alice@example.org> wrote:\n> Shall we meet Tuesday?", 3)
+	addReplyContextEmail(fake, "e3", "T1", "alice@example.org", "me@example.com", "inbox", "Great, which room?\n\nOn Thu, Jan 2, 2025 at 10:00 AM Me <me@example.com> wrote:\n> Tuesday works.", 4)
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1", "e2", "e3"}})
+	addReplyContextEmail(fake, "p1", "T0", "me@example.com", "alice@example.org", "sent", "Cheers, see you soon!", 1)
+
+	res, _, _ := s.handleReplyContext(context.Background(), nil, ReplyContextInput{EmailID: "e3", Messages: 2})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("reply_context: %s", out)
+	}
+	for _, want := range []string{
+		"# Reply context: Offsite",
+		"Replying to e3 from alice@example.org in thread T1 (3 message(s), 2 shown)",
+		"- alice@example.org (2 sent)\n- me@example.com (1 sent)\n",
+		"me@example.com (you) [id: e2]",
+		"alice@example.org (replying to this) [id: e3]",
+		"Great, which room?",
+		"## Your earlier messages to alice@example.org",
+		"Cheers, see you soon!",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Shall we meet") || strings.Contains(out, "> Tuesday works.") {
+		t.Errorf("older message or quoted text included:\n%s", out)
+	}
+	if strings.Count(out, "[id: e2]") != 1 {
+		t.Errorf("thread message repeated among earlier messages:\n%s", out)
+	}
+}
+
+func TestReplyContextKeepsOlderAnchor(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addReplyContextEmail(fake, "e1", "T1", "alice@example.org", "me@example.com", "inbox", "First", 1)
+	addReplyContextEmail(fake, "e2", "T1", "bob@example.org", "me@example.com", "inbox", "Second", 2)
+	addReplyContextEmail(fake, "e3", "T1", "carol@example.org", "me@example.com", "inbox", "Third", 3)
+	fake.Add("Thread", map[string]any{"id": "T1", "emailIds": []any{"e1", "e2", "e3"}})
+
+	res, _, _ := s.handleReplyContext(context.Background(), nil, ReplyContextInput{EmailID: "e1", Messages: 2, PriorReplies: -1})
+	out := resultText(res)
+	if !strings.Contains(out, "(replying to this) [id: e1]") || !strings.Contains(out, "Third") || strings.Contains(out, "Second") {
+		t.Errorf("want e1 and the latest message:\n%s", out)
+	}
+	if strings.Contains(out, "earlier messages") {
+		t.Errorf("prior replies included with -1:\n%s", out)
+	}
+}
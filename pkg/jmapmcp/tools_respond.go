@@ -0,0 +1,216 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- respond_and_file ---
+
+type RespondAndFileInput struct {
+	EmailID    string `json:"email_id" jsonschema:"ID of the email being replied to"`
+	Body       string `json:"body,omitempty" jsonschema:"Plain text reply body"`
+	HTML       string `json:"html_body,omitempty" jsonschema:"Optional HTML version of the body, sanitized as in email_reply"`
+	ReplyAll   bool   `json:"reply_all,omitempty" jsonschema:"Reply to all original recipients (To and CC) instead of only the sender (default false)"`
+	Quote      bool   `json:"quote,omitempty" jsonschema:"Include the original, quoted, below the reply"`
+	Importance string `json:"importance,omitempty" jsonschema:"Mark the reply high or low importance (default normal)"`
+	MailboxID  string `json:"mailbox_id" jsonschema:"Mailbox to file the conversation in once the reply is sent, e.g. the Archive mailbox's ID"`
+	Seen       *bool  `json:"seen,omitempty" jsonschema:"Also mark the filed emails seen (true) or unseen (false)"`
+	Flagged    *bool  `json:"flagged,omitempty" jsonschema:"Also flag (true) or unflag (false) the filed emails"`
+}
+
+var respondAndFileTool = &mcp.Tool{
+	Name:        "respond_and_file",
+	Description: "Reply to an email and, once the reply is sent, file its conversation: every email of the thread that shares a mailbox with the original (usually the Inbox) leaves that mailbox for mailbox_id, optionally marked seen or flagged. The reply is built as email_reply builds it and sent as email_submission_set sends it (send policy, pre-send review); the move is checked before anything is sent, and nothing is filed when sending fails. With a send queue the reply is queued and the conversation stays in place. undo_last moves the conversation back.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleRespondAndFile(ctx context.Context, req *mcp.CallToolRequest, in RespondAndFileInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	if in.MailboxID == "" {
+		return errorResult(invalidArgument("mailbox_id is required")), nil, nil
+	}
+	if err := checkImportance(in.Importance); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	// Plan the filing first, so a missing mailbox or right stops the call
+	// before the reply goes out.
+	plan, err := planThreadFiling(ctx, client, accountID, jmap.ID(in.EmailID), jmap.ID(in.MailboxID))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	for _, e := range plan.emails {
+		applyKeyword(plan.updates[e.ID], "keywords/$seen", in.Seen)
+		applyKeyword(plan.updates[e.ID], "keywords/$flagged", in.Flagged)
+	}
+
+	reply, err := s.createReply(ctx, client, EmailReplyInput{
+		EmailID:    in.EmailID,
+		Body:       in.Body,
+		HTML:       in.HTML,
+		ReplyAll:   in.ReplyAll,
+		Importance: in.Importance,
+		Quote:      in.Quote,
+	})
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if reply.id == "" {
+		return errorResult(fmt.Errorf("the server did not report the reply draft")), nil, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(reply.format())
+	if s.outbox != nil {
+		res, _, _ := s.enqueueSubmission(ctx, client, accountID, reply.id, "", false)
+		if res.IsError {
+			return res, nil, nil
+		}
+		fmt.Fprintf(&sb, "Reply queued for approval with outbox_approve; the conversation was not filed, move it with email_move once the reply is sent\n")
+		return textResult(sb.String()), nil, nil
+	}
+	if err := s.submitDraft(ctx, client, accountID, reply.id, "", false); err != nil {
+		return errorResult(fmt.Errorf("reply draft %s not sent, nothing filed: %w", reply.id, err)), nil, nil
+	}
+	sb.WriteString("Reply sent\n")
+
+	setReq := &jmap.Request{Context: ctx}
+	setReq.Invoke(&email.Set{Account: accountID, Update: plan.updates})
+	resp, err := client.Do(setReq)
+	if err != nil {
+		fmt.Fprintf(&sb, "Filing failed: %v\n", err)
+		return textResult(sb.String()), nil, nil
+	}
+	if len(resp.Responses) == 0 {
+		fmt.Fprintf(&sb, "Filing failed: empty response for Email/set\n")
+		return textResult(sb.String()), nil, nil
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		s.journal(ctx, req, &journalEntry{tool: "respond_and_file", accountID: accountID, mailboxes: priorMailboxes(plan.emails)}, args.NotUpdated)
+		if err := setFailures("filing failed", args.NotUpdated); err != nil {
+			fmt.Fprintf(&sb, "%v\n", err)
+			return textResult(sb.String()), nil, nil
+		}
+		fmt.Fprintf(&sb, "Filed %d email(s) of the conversation in %s\n", len(plan.emails), mailboxPath(plan.target, plan.mailboxes))
+	case *jmap.MethodError:
+		fmt.Fprintf(&sb, "Filing failed: %v\n", args)
+	default:
+		fmt.Fprintf(&sb, "Filing failed: unexpected response type: %T\n", args)
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// threadFiling is what filing an email's conversation in target changes.
+type threadFiling struct {
+	target    *mailbox.Mailbox
+	mailboxes map[jmap.ID]*mailbox.Mailbox
+	emails    []*email.Email // the emails filed, with their mailboxIds before
+	updates   map[jmap.ID]jmap.Patch
+}
+
+// planThreadFiling fetches emailID's thread in one request chain and plans
+// moving it to target: each email of the thread that is in one of the
+// mailboxes emailID is in leaves those mailboxes for target, so the user's
+// own messages in Sent stay there. The mailbox rights are checked.
+func planThreadFiling(ctx context.Context, client JMAPClient, accountID, emailID, target jmap.ID) (*threadFiling, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "name", "role", "parentId", "myRights"}})
+	originalCallID := req.Invoke(&email.Get{Account: accountID, IDs: []jmap.ID{emailID}, Properties: []string{"id", "threadId", "mailboxIds"}})
+	threadCallID := req.Invoke(&thread.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: originalCallID, Name: "Email/get", Path: "/list/*/threadId"},
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: threadCallID, Name: "Thread/get", Path: "/list/*/emailIds"},
+		Properties:   []string{"id", "mailboxIds"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 4 {
+		return nil, fmt.Errorf("expected 4 responses in thread chain, got %d", len(resp.Responses))
+	}
+
+	plan := &threadFiling{mailboxes: map[jmap.ID]*mailbox.Mailbox{}, updates: map[jmap.ID]jmap.Patch{}}
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			plan.mailboxes[mb.ID] = mb
+		}
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected mailbox response type: %T", args)
+	}
+	plan.target = plan.mailboxes[target]
+	if plan.target == nil {
+		return nil, notFoundError([]jmap.ID{target}, "mailbox not found: %s", target)
+	}
+	if err := checkMailboxRight(plan.target, "mayAddItems"); err != nil {
+		return nil, err
+	}
+
+	var original *email.Email
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		if len(args.List) == 0 {
+			return nil, notFoundError([]jmap.ID{emailID}, "email not found: %s", emailID)
+		}
+		original = args.List[0]
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected email response type: %T", args)
+	}
+	if me, ok := resp.Responses[2].Args.(*jmap.MethodError); ok {
+		return nil, me
+	}
+	// Without the thread only the original is filed.
+	emails := []*email.Email{original}
+	if args, ok := resp.Responses[3].Args.(*email.GetResponse); ok && len(args.List) > 0 {
+		emails = args.List
+	}
+
+	for _, e := range emails {
+		patch := jmap.Patch{}
+		for id := range e.MailboxIDs {
+			if id != target && original.MailboxIDs[id] {
+				if err := checkMailboxRight(plan.mailboxes[id], "mayRemoveItems"); err != nil {
+					return nil, fmt.Errorf("email %s: %w", e.ID, err)
+				}
+				patch["mailboxIds/"+string(id)] = nil
+			}
+		}
+		if len(patch) == 0 && e.ID != original.ID {
+			continue
+		}
+		patch["mailboxIds/"+string(target)] = true
+		plan.emails = append(plan.emails, e)
+		plan.updates[e.ID] = patch
+	}
+	return plan, nil
+}
@@ -0,0 +1,60 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func newRespondServer(t *testing.T) (*Server, *jmapfake.Server) {
+	t.Helper()
+	s, fake := newFakeServer(t, WithEmailSubmission())
+	for _, mb := range []struct{ id, role string }{{"inbox", "inbox"}, {"drafts", "drafts"}, {"sent", "sent"}, {"archive", "archive"}} {
+		fake.Add("Mailbox", map[string]any{"id": mb.id, "name": strings.ToUpper(mb.id[:1]) + mb.id[1:], "role": mb.role})
+	}
+	fake.Add("Identity", map[string]any{"id": "i1", "email": "me@example.com"})
+	thread := []struct{ id, mailbox string }{{"e1", "inbox"}, {"e2", "sent"}, {"e3", "inbox"}}
+	for _, e := range thread {
+		fake.Add("Email", map[string]any{
+			"id": e.id, "threadId": "t1", "subject": "Plans", "mailboxIds": map[string]any{e.mailbox: true},
+			"from": []any{map[string]any{"email": "alice@example.org"}}, "keywords": map[string]any{},
+		})
+	}
+	fake.Add("Thread", map[string]any{"id": "t1", "emailIds": []any{"e1", "e2", "e3"}})
+	return s, fake
+}
+
+func TestRespondAndFile(t *testing.T) {
+	s, fake := newRespondServer(t)
+	seen := true
+	res, _, _ := s.handleRespondAndFile(context.Background(), nil, RespondAndFileInput{EmailID: "e3", Body: "Sounds good.", MailboxID: "archive", Seen: &seen})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Reply sent") || !strings.Contains(out, "Filed 2 email(s) of the conversation in Archive") {
+		t.Fatalf("respond_and_file = %s", out)
+	}
+	for id, want := range map[string]string{"e1": "archive", "e2": "sent", "e3": "archive"} {
+		e := fake.Object("Email", id)
+		if mbs := e["mailboxIds"].(map[string]any); len(mbs) != 1 || mbs[want] != true {
+			t.Errorf("%s mailboxIds = %v, want %s", id, mbs, want)
+		}
+		if kw := e["keywords"].(map[string]any); (want == "archive") != (kw["$seen"] == true) {
+			t.Errorf("%s keywords = %v", id, kw)
+		}
+	}
+	if kw := fake.Object("Email", "e3")["keywords"].(map[string]any); kw["$answered"] != true {
+		t.Errorf("e3 not marked answered: %v", kw)
+	}
+}
+
+func TestRespondAndFileChecksBeforeSending(t *testing.T) {
+	s, fake := newRespondServer(t)
+	res, _, _ := s.handleRespondAndFile(context.Background(), nil, RespondAndFileInput{EmailID: "e3", Body: "Sounds good.", MailboxID: "nowhere"})
+	if !res.IsError || !strings.Contains(resultText(res), "mailbox not found") {
+		t.Fatalf("respond_and_file to a missing mailbox = %s", resultText(res))
+	}
+	if n := len(fake.Objects("Email")); n != 3 {
+		t.Errorf("%d emails after a refused call, want 3 (no reply draft)", n)
+	}
+}
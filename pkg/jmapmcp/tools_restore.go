@@ -0,0 +1,259 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_restore ---
+
+type EmailRestoreInput struct {
+	EmailIDs []string `json:"email_ids,omitempty" jsonschema:"Trashed emails to move back; omit to list the most recently trashed emails instead"`
+	Limit    int      `json:"limit,omitempty" jsonschema:"How many trashed emails to list when email_ids is omitted (default 20)"`
+}
+
+var emailRestoreTool = &mcp.Tool{
+	Name:        "email_restore",
+	Description: "Undo email_delete: move trashed emails back to the mailboxes they were in when email_delete trashed them (the Inbox when that is unknown, e.g. for mail trashed by another client). Without email_ids, lists the newest emails in Trash with where each came from, changing nothing. Permanently destroyed emails cannot be restored.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailRestore(ctx context.Context, _ *mcp.CallToolRequest, in EmailRestoreInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	if len(in.EmailIDs) == 0 {
+		out, err := s.listTrashed(ctx, client, accountID, in.Limit)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		return textResult(out), nil, nil
+	}
+	out, err := s.restoreTrashed(ctx, client, accountID, in.EmailIDs)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(out), nil, nil
+}
+
+// recordTombstones notes the prior mailboxes of the emails an email_delete
+// moved to trashID, except those the server did not update.
+func (s *Server) recordTombstones(ctx context.Context, emails []*email.Email, trashID jmap.ID, notUpdated map[jmap.ID]*jmap.SetError) {
+	now := time.Now()
+	var ts []*tombstone
+	for _, e := range emails {
+		if _, failed := notUpdated[e.ID]; failed {
+			continue
+		}
+		var prior []jmap.ID
+		for _, id := range sortedKeys(idStrings(e.MailboxIDs)) {
+			if jmap.ID(id) != trashID {
+				prior = append(prior, jmap.ID(id))
+			}
+		}
+		if len(prior) > 0 {
+			ts = append(ts, &tombstone{endpoint: endpointName(ctx), emailID: e.ID, mailboxes: prior, at: now})
+		}
+	}
+	s.tombstones.record(s.idOwner(ctx), ts)
+}
+
+// listTrashed describes the limit newest emails in Trash.
+func (s *Server) listTrashed(ctx context.Context, client JMAPClient, accountID jmap.ID, limit int) (string, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return "", err
+	}
+	trashID, err := mailboxWithRole(mailboxes, mailbox.RoleTrash)
+	if err != nil {
+		return "", err
+	}
+
+	req := &jmap.Request{Context: ctx}
+	queryCallID := req.Invoke(&email.Query{
+		Account:        accountID,
+		Filter:         &email.FilterCondition{InMailbox: trashID},
+		Sort:           []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+		Limit:          uint64(limit),
+		CalculateTotal: true,
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+		Properties:   []string{"id", "subject", "from", "receivedAt"},
+	})
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) < 2 {
+		return "", fmt.Errorf("incomplete response for Email/query and Email/get")
+	}
+	var total uint64
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.QueryResponse:
+		total = args.Total
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+	var list []*email.Email
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		list = args.List
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	if len(list) == 0 {
+		return "Trash is empty", nil
+	}
+	owner, endpoint := s.idOwner(ctx), endpointName(ctx)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Trash: %d email(s), newest %d first\n\n", total, len(list))
+	for _, e := range list {
+		parts := []string{string(e.ID)}
+		if e.ReceivedAt != nil {
+			parts = append(parts, s.locale.dateTime(*e.ReceivedAt))
+		}
+		if len(e.From) > 0 {
+			parts = append(parts, formatAddresses(e.From))
+		}
+		parts = append(parts, e.Subject)
+		fmt.Fprintf(&sb, "%s\n", strings.Join(parts, "  "))
+		if ts := s.tombstones.lookup(owner, endpoint, e.ID); ts != nil {
+			fmt.Fprintf(&sb, "  Trashed %s from %s\n", s.locale.dateTime(ts.at), joinMailboxNames(mailboxes, ts.mailboxes))
+		} else {
+			sb.WriteString("  Origin unknown; restores to Inbox\n")
+		}
+	}
+	sb.WriteString("\nPass the IDs to email_restore as email_ids to move them back.")
+	return sb.String(), nil
+}
+
+// restoreTrashed moves the trashed emails ids back to their recorded
+// mailboxes, or the Inbox, and reports what happened to each.
+func (s *Server) restoreTrashed(ctx context.Context, client JMAPClient, accountID jmap.ID, ids []string) (string, error) {
+	mailboxes, emails, err := fetchMoveRights(ctx, client, accountID, ids)
+	if err != nil {
+		return "", err
+	}
+	trashID, err := mailboxWithRole(mailboxes, mailbox.RoleTrash)
+	if err != nil {
+		return "", err
+	}
+	inboxID, _ := mailboxWithRole(mailboxes, mailbox.RoleInbox)
+
+	owner, endpoint := s.idOwner(ctx), endpointName(ctx)
+	found := make(map[jmap.ID]bool, len(emails))
+	targets := make(map[jmap.ID][]jmap.ID, len(emails))
+	var lines []string
+	updates := make(map[jmap.ID]jmap.Patch)
+	for _, e := range emails {
+		found[e.ID] = true
+		if !e.MailboxIDs[trashID] {
+			lines = append(lines, fmt.Sprintf("%s: not in Trash; left where it is", e.ID))
+			continue
+		}
+		var dest []jmap.ID
+		if ts := s.tombstones.lookup(owner, endpoint, e.ID); ts != nil {
+			for _, id := range ts.mailboxes {
+				if mailboxes[id] != nil {
+					dest = append(dest, id)
+				}
+			}
+		}
+		if len(dest) == 0 {
+			if inboxID == "" {
+				lines = append(lines, fmt.Sprintf("%s: origin unknown and no Inbox to restore to; move it with email_move", e.ID))
+				continue
+			}
+			dest = []jmap.ID{inboxID}
+		}
+		if err := checkMailboxRight(mailboxes[trashID], "mayRemoveItems"); err != nil {
+			return "", err
+		}
+		patch := map[string]bool{}
+		for _, id := range dest {
+			if err := checkMailboxRight(mailboxes[id], "mayAddItems"); err != nil {
+				return "", err
+			}
+			patch[string(id)] = true
+		}
+		targets[e.ID] = dest
+		updates[e.ID] = jmap.Patch{"mailboxIds": patch}
+	}
+	for _, id := range ids {
+		if !found[jmap.ID(id)] {
+			lines = append(lines, fmt.Sprintf("%s: not found; it was destroyed (Trash emptied or expired) and cannot be restored", id))
+		}
+	}
+
+	var restored []jmap.ID
+	if len(updates) > 0 {
+		setReq := &jmap.Request{Context: ctx}
+		setReq.Invoke(&email.Set{Account: accountID, Update: updates})
+		resp, err := client.Do(setReq)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Responses) == 0 {
+			return "", fmt.Errorf("empty response for Email/set")
+		}
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.SetResponse:
+			for id, dest := range targets {
+				if se, ok := args.NotUpdated[id]; ok {
+					lines = append(lines, fmt.Sprintf("%s: restore failed: %s", id, setErrorText(se)))
+					continue
+				}
+				restored = append(restored, id)
+				lines = append(lines, fmt.Sprintf("%s: restored to %s", id, joinMailboxNames(mailboxes, dest)))
+			}
+		case *jmap.MethodError:
+			return "", args
+		default:
+			return "", fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+	s.tombstones.forget(owner, endpoint, restored)
+
+	slices.Sort(lines)
+	return fmt.Sprintf("Restored %d of %d email(s)\n%s", len(restored), len(ids), strings.Join(lines, "\n")), nil
+}
+
+// joinMailboxNames lists the names of ids, falling back to the ID of a mailbox
+// no longer in mailboxes.
+func joinMailboxNames(mailboxes map[jmap.ID]*mailbox.Mailbox, ids []jmap.ID) string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		if mb := mailboxes[id]; mb != nil && mb.Name != "" {
+			names[i] = mb.Name
+		} else {
+			names[i] = string(id)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,72 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap"
+)
+
+func TestTombstones(t *testing.T) {
+	var ts tombstones
+	now := time.Now()
+	ts.record("alice", []*tombstone{{endpoint: "default", emailID: "e1", mailboxes: []jmap.ID{"work"}, at: now}})
+	ts.record("alice", []*tombstone{{endpoint: "default", emailID: "e1", mailboxes: []jmap.ID{"inbox"}, at: now}})
+	if got := ts.lookup("alice", "default", "e1"); got == nil || got.mailboxes[0] != "inbox" {
+		t.Errorf("lookup = %+v, want the latest tombstone", got)
+	}
+	if ts.lookup("bob", "default", "e1") != nil || ts.lookup("alice", "other", "e1") != nil {
+		t.Error("tombstone visible to another owner or endpoint")
+	}
+	ts.forget("alice", "default", []jmap.ID{"e1"})
+	if ts.lookup("alice", "default", "e1") != nil {
+		t.Error("tombstone kept after forget")
+	}
+}
+
+func TestEmailRestore(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "work", "name": "Work"})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	fake.Add("Email", map[string]any{"id": "e1", "subject": "Budget", "receivedAt": "2025-01-01T10:00:00Z", "mailboxIds": map[string]any{"work": true}})
+	fake.Add("Email", map[string]any{"id": "e2", "subject": "Trashed elsewhere", "receivedAt": "2025-01-02T10:00:00Z", "mailboxIds": map[string]any{"trash": true}})
+	ctx := context.Background()
+
+	if res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}}); res.IsError {
+		t.Fatalf("email_delete: %s", resultText(res))
+	}
+
+	res, _, _ := s.handleEmailRestore(ctx, nil, EmailRestoreInput{})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Trash: 2 email(s)") || !strings.Contains(out, "from Work") || !strings.Contains(out, "Origin unknown; restores to Inbox") {
+		t.Fatalf("listing:\n%s", out)
+	}
+	if mbs := fake.Object("Email", "e1")["mailboxIds"].(map[string]any); mbs["trash"] != true {
+		t.Errorf("listing changed e1: %v", mbs)
+	}
+
+	res, _, _ = s.handleEmailRestore(ctx, nil, EmailRestoreInput{EmailIDs: []string{"e1", "e2", "gone"}})
+	out = resultText(res)
+	if res.IsError {
+		t.Fatalf("restore: %s", out)
+	}
+	for _, want := range []string{"Restored 2 of 3", "e1: restored to Work", "e2: restored to Inbox", "gone: not found"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("restore output lacks %q:\n%s", want, out)
+		}
+	}
+	if mbs := fake.Object("Email", "e1")["mailboxIds"].(map[string]any); mbs["work"] != true || mbs["trash"] != nil {
+		t.Errorf("e1 mailboxIds = %v, want Work only", mbs)
+	}
+	if s.tombstones.lookup(s.idOwner(ctx), DefaultEndpoint, "e1") != nil {
+		t.Error("tombstone of the restored email kept")
+	}
+
+	res, _, _ = s.handleEmailRestore(ctx, nil, EmailRestoreInput{EmailIDs: []string{"e1"}})
+	if out := resultText(res); !strings.Contains(out, "e1: not in Trash") {
+		t.Errorf("restoring an email not in Trash:\n%s", out)
+	}
+}
@@ -0,0 +1,416 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// rsvpPartStats maps calendar_rsvp responses to iCalendar PARTSTAT values
+// and the subject prefix clients use for them.
+var rsvpPartStats = map[string]struct{ partstat, verb string }{
+	"accepted":  {"ACCEPTED", "Accepted"},
+	"tentative": {"TENTATIVE", "Tentative"},
+	"declined":  {"DECLINED", "Declined"},
+}
+
+// --- calendar_rsvp ---
+
+type CalendarRSVPInput struct {
+	EmailID   string `json:"email_id" jsonschema:"ID of the email carrying the invitation (a text/calendar part with METHOD:REQUEST)"`
+	Response  string `json:"response" jsonschema:"accepted, tentative, or declined"`
+	Comment   string `json:"comment,omitempty" jsonschema:"Optional note to the organizer, sent as the message body and the reply's COMMENT"`
+	DraftOnly bool   `json:"draft_only,omitempty" jsonschema:"Create the reply draft without submitting it (default false)"`
+}
+
+var calendarRSVPTool = &mcp.Tool{
+	Name:        "calendar_rsvp",
+	Description: "Answer a meeting invitation over plain email (iMIP), on any server with or without JMAP Calendars: reads the invitation's METHOD:REQUEST calendar part, builds a METHOD:REPLY with the user's participation status, attaches it to a threaded reply draft addressed to the organizer, and submits it (queued for approval when the server requires it). Use draft_only to review the reply first.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleCalendarRSVP(ctx context.Context, _ *mcp.CallToolRequest, in CalendarRSVPInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	ps, ok := rsvpPartStats[strings.ToLower(in.Response)]
+	if !ok {
+		return errorResult(invalidArgument("response must be accepted, tentative, or declined")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        []jmap.ID{jmap.ID(in.EmailID)},
+		Properties: []string{"id", "subject", "messageId", "references", "bodyStructure"},
+	})
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "role"}})
+	req.Invoke(&identity.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) < 3 {
+		return errorResult(fmt.Errorf("expected 3 discovery responses, got %d", len(resp.Responses))), nil, nil
+	}
+
+	var original *email.Email
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return errorResult(notFoundError([]string{in.EmailID}, "email not found: %s", in.EmailID)), nil, nil
+		}
+		original = args.List[0]
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	var draftsID jmap.ID
+	switch args := resp.Responses[1].Args.(type) {
+	case *mailbox.GetResponse:
+		draftsID = draftsMailbox(args.List)
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected mailbox response type: %T", args)), nil, nil
+	}
+	if draftsID == "" {
+		return errorResult(fmt.Errorf("no mailbox with role %q found", mailbox.RoleDrafts)), nil, nil
+	}
+
+	var identities []*identity.Identity
+	switch args := resp.Responses[2].Args.(type) {
+	case *identity.GetResponse:
+		identities = args.List
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected identity response type: %T", args)), nil, nil
+	}
+	if len(identities) == 0 {
+		return errorResult(fmt.Errorf("no sender identities available")), nil, nil
+	}
+
+	part := findBodyPart(original.BodyStructure, "text/calendar", "application/ics")
+	if part == nil {
+		return errorResult(invalidArgument("email %s has no calendar invitation", in.EmailID)), nil, nil
+	}
+	text, err := downloadText(ctx, client, accountID, part.BlobID)
+	if err != nil {
+		return errorResult(fmt.Errorf("download invitation: %w", err)), nil, nil
+	}
+	inv, err := parseICSInvite(text)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	// Answer as the identity the invitation names; otherwise as the first
+	// the send policy allows.
+	var notes []string
+	sender, err := s.sendPolicy.senderIdentity(identities)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if found := inviteeIdentity(inv, identities); found != nil {
+		sender = found
+	} else {
+		notes = append(notes, fmt.Sprintf("none of your addresses is listed as an attendee; replying as %s", sender.Email))
+	}
+	if err := s.sendPolicy.checkSender(sender.Email); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	organizer := []*mail.Address{{Email: inv.organizer}}
+	if err := s.sendPolicy.checkRecipients(organizer); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	ics := inv.reply(sender.Email, sender.Name, ps.partstat, in.Comment, time.Now())
+	if err := checkUploadSize(client.Session(), "calendar reply", len(ics)); err != nil {
+		return errorResult(err), nil, nil
+	}
+	upload, err := client.Upload(ctx, accountID, bytes.NewReader(ics))
+	if err != nil {
+		return errorResult(fmt.Errorf("upload calendar reply: %w", err)), nil, nil
+	}
+
+	title := inv.summary
+	if title == "" {
+		title = original.Subject
+	}
+	body := in.Comment
+	if body == "" {
+		who := sender.Name
+		if who == "" {
+			who = sender.Email
+		}
+		body = fmt.Sprintf("%s has %s this invitation.", who, strings.ToLower(ps.verb))
+	}
+	draft := &email.Email{
+		MailboxIDs: map[jmap.ID]bool{draftsID: true},
+		Keywords:   map[string]bool{"$draft": true},
+		From:       []*mail.Address{{Name: sender.Name, Email: sender.Email}},
+		To:         organizer,
+		Subject:    ps.verb + ": " + title,
+		InReplyTo:  original.MessageID,
+		References: append(append([]string(nil), original.References...), original.MessageID...),
+		Attachments: []*email.BodyPart{{
+			BlobID:      upload.ID,
+			Type:        "text/calendar",
+			Name:        "reply.ics",
+			Disposition: "attachment",
+		}},
+	}
+	setDraftBody(draft, body, "")
+
+	setReq := &jmap.Request{Context: ctx}
+	setReq.Invoke(&email.Set{Account: accountID, Create: map[jmap.ID]*email.Email{"rsvp": draft}})
+	setResp, err := client.Do(setReq)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(setResp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/set")), nil, nil
+	}
+	var draftID jmap.ID
+	switch args := setResp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		if se, ok := args.NotCreated["rsvp"]; ok {
+			return errorResult(setFailure("reply draft creation failed", se)), nil, nil
+		}
+		if created, ok := args.Created["rsvp"]; ok {
+			draftID = created.ID
+		}
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+	if draftID == "" {
+		return errorResult(fmt.Errorf("reply draft creation returned no ID")), nil, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Created %s reply [id: %s] to %s for %q\n", strings.ToLower(ps.verb), draftID, inv.organizer, title)
+	for _, n := range notes {
+		fmt.Fprintf(&sb, "Note: %s\n", n)
+	}
+	switch {
+	case in.DraftOnly:
+		sb.WriteString("Not sent: submit it with email_submission_set.\n")
+	case s.outbox != nil:
+		res, _, _ := s.enqueueSubmission(ctx, client, accountID, draftID, sender.ID, false)
+		if res.IsError {
+			return res, nil, nil
+		}
+		for _, c := range res.Content {
+			if tc, ok := c.(*mcp.TextContent); ok {
+				sb.WriteString(tc.Text + "\n")
+			}
+		}
+	default:
+		if err := s.submitDraft(ctx, client, accountID, draftID, sender.ID, false); err != nil {
+			return errorResult(fmt.Errorf("reply draft %s created but not sent: %w", draftID, err)), nil, nil
+		}
+		sb.WriteString("Submitted for delivery.\n")
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// inviteeIdentity returns the identity an invitation lists as an attendee.
+func inviteeIdentity(inv *icsInvite, identities []*identity.Identity) *identity.Identity {
+	for _, a := range inv.attendees {
+		for _, id := range identities {
+			if strings.EqualFold(a, id.Email) {
+				return id
+			}
+		}
+	}
+	return nil
+}
+
+// icsInvite is what a reply repeats of a METHOD:REQUEST invitation
+// (RFC 5546): the first VEVENT's identifying properties, verbatim, and the
+// time zones they refer to.
+type icsInvite struct {
+	organizer string   // ORGANIZER address
+	summary   string   // unescaped SUMMARY
+	attendees []string // ATTENDEE addresses
+	props     []string // UID, SEQUENCE, RECURRENCE-ID, DTSTART, DTEND, DURATION, SUMMARY, ORGANIZER lines
+	timezones []string // VTIMEZONE component lines
+}
+
+// icsReplyProps are the VEVENT properties copied into a reply.
+var icsReplyProps = map[string]bool{
+	"UID": true, "SEQUENCE": true, "RECURRENCE-ID": true, "DTSTART": true,
+	"DTEND": true, "DURATION": true, "SUMMARY": true, "ORGANIZER": true,
+}
+
+// parseICSInvite reads the invitation in an iCalendar object, refusing
+// anything but METHOD:REQUEST.
+func parseICSInvite(text string) (*icsInvite, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.NewReplacer("\n ", "", "\n\t", "").Replace(text) // unfold
+
+	inv := &icsInvite{}
+	var method string
+	var stack []string // open components below VCALENDAR
+	events := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		nameParams, value := icsSplit(line)
+		name, _, _ := strings.Cut(strings.ToUpper(nameParams), ";")
+		switch name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(value))
+			if strings.EqualFold(value, "VEVENT") && len(stack) == 2 {
+				events++
+			}
+		case "END":
+			if len(stack) > 0 {
+				if len(stack) >= 2 && stack[1] == "VTIMEZONE" {
+					inv.timezones = append(inv.timezones, line)
+				}
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		if len(stack) >= 2 && stack[1] == "VTIMEZONE" {
+			inv.timezones = append(inv.timezones, line)
+			continue
+		}
+		switch {
+		case len(stack) == 1 && name == "METHOD":
+			method = strings.ToUpper(strings.TrimSpace(value))
+		case len(stack) == 2 && stack[1] == "VEVENT" && events == 1:
+			if icsReplyProps[name] {
+				inv.props = append(inv.props, line)
+			}
+			switch name {
+			case "ORGANIZER":
+				inv.organizer = icsAddress(value)
+			case "ATTENDEE":
+				if a := icsAddress(value); a != "" {
+					inv.attendees = append(inv.attendees, a)
+				}
+			case "SUMMARY":
+				inv.summary = icsUnescape(value)
+			}
+		}
+	}
+
+	switch {
+	case events == 0:
+		return nil, invalidArgument("the calendar part has no event")
+	case method != "REQUEST":
+		if method == "" {
+			method = "none"
+		}
+		return nil, invalidArgument("the calendar part is not an invitation (METHOD %s, want REQUEST)", method)
+	case inv.organizer == "":
+		return nil, invalidArgument("the invitation names no organizer to reply to")
+	}
+	return inv, nil
+}
+
+// icsSplit splits a content line at the colon ending its name and
+// parameters, skipping colons in quoted parameter values.
+func icsSplit(line string) (nameParams, value string) {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ':' && !quoted:
+			return line[:i], line[i+1:]
+		}
+	}
+	return line, ""
+}
+
+// icsAddress returns the address of a mailto: calendar user value.
+func icsAddress(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 7 && strings.EqualFold(value[:7], "mailto:") {
+		return value[7:]
+	}
+	return ""
+}
+
+// reply renders the METHOD:REPLY answering the invitation as attendee with
+// partstat, with CRLF line endings and lines folded at 75 octets.
+func (inv *icsInvite) reply(attendee, name, partstat, comment string, now time.Time) []byte {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//jmap-mcp//calendar_rsvp//EN",
+		"METHOD:REPLY",
+	}
+	lines = append(lines, inv.timezones...)
+	lines = append(lines, "BEGIN:VEVENT", "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+	lines = append(lines, inv.props...)
+	att := "ATTENDEE;PARTSTAT=" + partstat
+	if name != "" {
+		att += `;CN="` + strings.NewReplacer(`"`, "'", "\n", " ").Replace(name) + `"`
+	}
+	lines = append(lines, att+":mailto:"+attendee)
+	if comment != "" {
+		lines = append(lines, "COMMENT:"+icsEscape(comment))
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var b bytes.Buffer
+	for _, l := range lines {
+		b.WriteString(icsFold(l))
+	}
+	return b.Bytes()
+}
+
+// icsEscape is the inverse of icsUnescape for TEXT values.
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icsFold splits line into CRLF-terminated chunks of at most 75 octets,
+// continuation lines starting with a space, without splitting a UTF-8
+// sequence.
+func icsFold(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line + "\r\n")
+	return b.String()
+}
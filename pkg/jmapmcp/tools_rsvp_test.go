@@ -0,0 +1,150 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+const testInvite = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//Cal//EN\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Berlin\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:19701025T030000\r\n" +
+	"TZOFFSETFROM:+0200\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:evt-42@example.org\r\n" +
+	"SEQUENCE:2\r\n" +
+	"DTSTAMP:20261001T090000Z\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20261020T100000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20261020T110000\r\n" +
+	"SUMMARY:Quarterly\\, planning\r\n" +
+	"ORGANIZER;CN=\"Olga: Ops\":mailto:olga@example.org\r\n" +
+	"ATTENDEE;CN=Someone Else;PARTSTAT=NEEDS-ACTION:mailto:other@example.org\r\n" +
+	"ATTENDEE;CN=Me;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:\r\n" +
+	" mailto:me@example.com\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"SUMMARY:Not the event\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICSInviteReply(t *testing.T) {
+	inv, err := parseICSInvite(testInvite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.organizer != "olga@example.org" || inv.summary != "Quarterly, planning" || len(inv.attendees) != 2 || inv.attendees[1] != "me@example.com" {
+		t.Fatalf("parsed %+v", inv)
+	}
+
+	out := string(inv.reply("me@example.com", "Me", "ACCEPTED", "See you there; bring slides", time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)))
+	for _, want := range []string{
+		"METHOD:REPLY\r\n",
+		"TZID:Europe/Berlin\r\n",
+		"UID:evt-42@example.org\r\n",
+		"SEQUENCE:2\r\n",
+		"DTSTAMP:20261016T080000Z\r\n",
+		"DTSTART;TZID=Europe/Berlin:20261020T100000\r\n",
+		"ORGANIZER;CN=\"Olga: Ops\":mailto:olga@example.org\r\n",
+		"END:STANDARD\r\nEND:VTIMEZONE\r\n",
+		`ATTENDEE;PARTSTAT=ACCEPTED;CN="Me":mailto:me@example.com` + "\r\n",
+		"COMMENT:See you there\\; bring slides\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("reply lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "other@example.org") || strings.Contains(out, "VALARM") || strings.Contains(out, "DTSTAMP:20261001") {
+		t.Errorf("reply repeats invitation-only content:\n%s", out)
+	}
+
+	long := icsFold("COMMENT:" + strings.Repeat("ü", 60))
+	for _, l := range strings.Split(strings.TrimSuffix(long, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("folded line of %d octets: %q", len(l), l)
+		}
+	}
+
+	if _, err := parseICSInvite(strings.Replace(testInvite, "METHOD:REQUEST", "METHOD:CANCEL", 1)); err == nil || !strings.Contains(err.Error(), "METHOD CANCEL") {
+		t.Errorf("cancel accepted as an invitation: %v", err)
+	}
+}
+
+// newRSVPServer returns a sending server holding invitation email "inv".
+func newRSVPServer(t *testing.T, opts ...Option) (*Server, *jmapfake.Server) {
+	t.Helper()
+	s, fake := newFakeServer(t, append([]Option{WithEmailSubmission()}, opts...)...)
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Mailbox", map[string]any{"id": "sent", "name": "Sent", "role": "sent"})
+	fake.Add("Identity", map[string]any{"id": "i1", "name": "Me", "email": "me@example.com"})
+	fake.Add("Email", map[string]any{
+		"id": "inv", "subject": "Invitation: Quarterly, planning", "messageId": []any{"inv-1@example.org"},
+		"mailboxIds": map[string]any{"inbox": true},
+		"bodyStructure": map[string]any{"type": "multipart/mixed", "subParts": []any{
+			map[string]any{"partId": "1", "type": "text/plain"},
+			map[string]any{"partId": "2", "type": "text/calendar", "blobId": fake.AddBlob([]byte(testInvite))},
+		}},
+	})
+	return s, fake
+}
+
+func TestCalendarRSVP(t *testing.T) {
+	s, fake := newRSVPServer(t)
+
+	res, _, _ := s.handleCalendarRSVP(context.Background(), nil, CalendarRSVPInput{EmailID: "inv", Response: "Accepted"})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Created accepted reply") || !strings.Contains(out, "olga@example.org") || !strings.Contains(out, "Submitted for delivery") {
+		t.Fatalf("rsvp:\n%s", out)
+	}
+
+	var draft map[string]any
+	for _, id := range fake.Objects("Email") {
+		if id != "inv" {
+			draft = fake.Object("Email", id)
+		}
+	}
+	if draft == nil || draft["subject"] != "Accepted: Quarterly, planning" {
+		t.Fatalf("reply draft: %v", draft)
+	}
+	if irt, _ := draft["inReplyTo"].([]any); len(irt) != 1 || irt[0] != "inv-1@example.org" {
+		t.Errorf("inReplyTo = %v", draft["inReplyTo"])
+	}
+	atts, _ := draft["attachments"].([]any)
+	if len(atts) != 1 {
+		t.Fatalf("attachments = %v", draft["attachments"])
+	}
+	blobID, _ := atts[0].(map[string]any)["blobId"].(string)
+	if ics := string(fake.Blob(blobID)); !strings.Contains(ics, "METHOD:REPLY") || !strings.Contains(ics, "PARTSTAT=ACCEPTED") {
+		t.Errorf("uploaded reply:\n%s", ics)
+	}
+}
+
+func TestCalendarRSVPDraftOnly(t *testing.T) {
+	s, fake := newRSVPServer(t)
+
+	res, _, _ := s.handleCalendarRSVP(context.Background(), nil, CalendarRSVPInput{EmailID: "inv", Response: "declined", Comment: "Clashes with travel", DraftOnly: true})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Created declined reply") || !strings.Contains(out, "Not sent") {
+		t.Fatalf("draft only:\n%s", out)
+	}
+	for _, c := range fake.Calls() {
+		if c == "EmailSubmission/set" {
+			t.Errorf("draft_only submitted the reply")
+		}
+	}
+
+	res, _, _ = s.handleCalendarRSVP(context.Background(), nil, CalendarRSVPInput{EmailID: "inv", Response: "maybe"})
+	if !res.IsError || !strings.Contains(resultText(res), "accepted, tentative, or declined") {
+		t.Errorf("bad response accepted:\n%s", resultText(res))
+	}
+}
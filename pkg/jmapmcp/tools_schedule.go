@@ -0,0 +1,233 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- schedule_create ---
+
+type ScheduleCreateInput struct {
+	Kind          string   `json:"kind" jsonschema:"send (submit the draft in email_ids; needs -enable-send), wake (move email_ids into mailbox_id and mark them unread, ending a snooze), digest (deliver an email_digest of the mail since the previous run as an unread message in mailbox_id), or cleanup (move mail in mailbox_id received more than older_than_days ago to Trash)"`
+	Name          string   `json:"name,omitempty" jsonschema:"Short description shown by schedule_list"`
+	At            string   `json:"at,omitempty" jsonschema:"When to run first: RFC 3339, or YYYY-MM-DD for midnight UTC"`
+	In            string   `json:"in,omitempty" jsonschema:"Instead of at: how long from now, e.g. 90m or 72h (default: now)"`
+	Every         string   `json:"every,omitempty" jsonschema:"Repeat at this interval, e.g. 24h or 168h, at least 15m; required for digest and cleanup, not allowed for send and wake"`
+	EmailIDs      []string `json:"email_ids,omitempty" jsonschema:"send: the draft; wake: the emails to bring back"`
+	MailboxID     string   `json:"mailbox_id,omitempty" jsonschema:"wake and digest: where to put the emails or the digest (default: the Inbox); cleanup: the mailbox to clean"`
+	OlderThanDays int      `json:"older_than_days,omitempty" jsonschema:"cleanup: move mail received more than this many days ago"`
+}
+
+var scheduleCreateTool = &mcp.Tool{
+	Name:        "schedule_create",
+	Description: "Schedule an action for later, with no MCP client attached: send a draft at a set time (for servers that cannot hold a submission), wake snoozed emails back into the Inbox unread, deliver a recurring email_digest as a message, or regularly move old mail out of a mailbox to Trash. Schedules are saved with the server and run where jmap-mcp runs automations (-run-automations or -mode daemon) with its configured token; schedule_list shows whether they do.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleScheduleCreate(ctx context.Context, _ *mcp.CallToolRequest, in ScheduleCreateInput) (*mcp.CallToolResult, any, error) {
+	now := time.Now().UTC()
+	a := &scheduledAction{
+		Name:          strings.TrimSpace(in.Name),
+		Kind:          in.Kind,
+		Next:          now,
+		Every:         strings.TrimSpace(in.Every),
+		MailboxID:     jmap.ID(in.MailboxID),
+		OlderThanDays: in.OlderThanDays,
+		Created:       now,
+	}
+	for _, id := range in.EmailIDs {
+		a.EmailIDs = append(a.EmailIDs, jmap.ID(id))
+	}
+	switch {
+	case in.At != "" && in.In != "":
+		return errorResult(invalidArgument("at and in are mutually exclusive")), nil, nil
+	case in.At != "":
+		at, err := parseDate(in.At, "T00:00:00Z")
+		if err != nil {
+			return errorResult(invalidArgument("at: %v", err)), nil, nil
+		}
+		a.Next = at.UTC()
+	case in.In != "":
+		d, err := time.ParseDuration(in.In)
+		if err != nil || d < 0 {
+			return errorResult(invalidArgument("in: expected a duration such as 90m or 72h")), nil, nil
+		}
+		a.Next = now.Add(d)
+	}
+	if !slices.Contains(creatableScheduleKinds, a.Kind) {
+		return errorResult(errScheduleKind()), nil, nil
+	}
+	if a.Kind == scheduleSend && !s.enableEmailSubmission {
+		return errorResult(invalidArgument("scheduled sends need sending enabled (-enable-send)")), nil, nil
+	}
+	if a.Kind == scheduleSend {
+		if err := checkMaySend(ctx, "send schedules"); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if a.MailboxID == "" && (a.Kind == scheduleWake || a.Kind == scheduleDigest) {
+		if a.MailboxID, err = mailboxWithRole(mailboxes, mailbox.RoleInbox); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+	if err := checkSchedule(a); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if a.MailboxID != "" {
+		mb, ok := mailboxes[a.MailboxID]
+		if !ok {
+			return errorResult(notFoundError([]jmap.ID{a.MailboxID}, "mailbox %s not found", a.MailboxID)), nil, nil
+		}
+		if a.Kind == scheduleCleanup && mb.Role == mailbox.RoleTrash {
+			return errorResult(invalidArgument("cleanup moves mail to Trash; clean another mailbox")), nil, nil
+		}
+	}
+
+	user := client.Session().Username
+	if err := s.schedules.add(user, a); err != nil {
+		return errorResult(err), nil, nil
+	}
+	text := fmt.Sprintf("Scheduled %s: %s, next run %s", a.ID, describeSchedule(a, mailboxes), s.locale.dateTime(a.Next))
+	if !s.schedules.runningFor(user) {
+		text += "\n" + schedulesNotRunning
+	}
+	return textResult(text), nil, nil
+}
+
+// schedulesNotRunning tells where schedules run when this process does not
+// run them.
+const schedulesNotRunning = "This server does not run schedules for this account: they run where jmap-mcp is started with -run-automations or -mode daemon, sharing -schedules-file, with this account's token."
+
+// describeSchedule writes what a does and how often, naming mailboxes by
+// path when mailboxes knows them.
+func describeSchedule(a *scheduledAction, mailboxes map[jmap.ID]*mailbox.Mailbox) string {
+	name := func(id jmap.ID) string {
+		if mb, ok := mailboxes[id]; ok {
+			return fmt.Sprintf("%s [id: %s]", mailboxPath(mb, mailboxes), id)
+		}
+		return string(id)
+	}
+	var sb strings.Builder
+	if a.Name != "" {
+		fmt.Fprintf(&sb, "%q — ", a.Name)
+	}
+	switch a.Kind {
+	case scheduleSend:
+		fmt.Fprintf(&sb, "send draft %s", a.EmailIDs[0])
+	case scheduleWake:
+		fmt.Fprintf(&sb, "wake %d email(s) into %s", len(a.EmailIDs), name(a.MailboxID))
+	case scheduleDigest:
+		fmt.Fprintf(&sb, "digest into %s", name(a.MailboxID))
+	case scheduleCleanup:
+		fmt.Fprintf(&sb, "move mail in %s older than %d day(s) to Trash", name(a.MailboxID), a.OlderThanDays)
+	case scheduleDestroy:
+		fmt.Fprintf(&sb, "permanently destroy %d email(s) still in %s", len(a.EmailIDs), name(a.MailboxID))
+	}
+	if every := a.interval(); every > 0 {
+		fmt.Fprintf(&sb, ", every %s", every)
+	}
+	return sb.String()
+}
+
+// --- schedule_list ---
+
+type ScheduleListInput struct{}
+
+var scheduleListTool = &mcp.Tool{
+	Name:        "schedule_list",
+	Description: "List the account's scheduled actions, the next due first: what each does, when it runs next, how often it ran and its last error, and whether this server runs them.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleScheduleList(ctx context.Context, _ *mcp.CallToolRequest, _ ScheduleListInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	user := client.Session().Username
+	list, err := s.schedules.list(user)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(list) == 0 {
+		return textResult("No schedules"), nil, nil
+	}
+	var mailboxes map[jmap.ID]*mailbox.Mailbox
+	if accountID := client.Session().PrimaryAccounts[mail.URI]; accountID != "" {
+		// Without mailbox names the list still shows IDs.
+		mailboxes, _ = fetchMailboxes(ctx, client, accountID)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Schedules: %d\n", len(list))
+	for _, a := range list {
+		fmt.Fprintf(&sb, "\n[id: %s] %s\n  ", a.ID, describeSchedule(&a, mailboxes))
+		if a.Next.IsZero() {
+			sb.WriteString("failed, not rescheduled")
+		} else {
+			fmt.Fprintf(&sb, "next run %s", s.locale.dateTime(a.Next))
+		}
+		if !a.LastRun.IsZero() {
+			fmt.Fprintf(&sb, ", ran %d time(s), last %s", a.Runs, s.locale.dateTime(a.LastRun))
+		}
+		if a.LastError != "" {
+			fmt.Fprintf(&sb, "\n  last error: %s", a.LastError)
+		}
+		sb.WriteString("\n")
+	}
+	if !s.schedules.runningFor(user) {
+		sb.WriteString("\n" + schedulesNotRunning + "\n")
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// --- schedule_cancel ---
+
+type ScheduleCancelInput struct {
+	ID string `json:"id" jsonschema:"Schedule ID from schedule_create or schedule_list"`
+}
+
+var scheduleCancelTool = &mcp.Tool{
+	Name:        "schedule_cancel",
+	Description: "Cancel a scheduled action before it runs, or stop a repeating one.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleScheduleCancel(ctx context.Context, _ *mcp.CallToolRequest, in ScheduleCancelInput) (*mcp.CallToolResult, any, error) {
+	if in.ID == "" {
+		return errorResult(invalidArgument("id is required")), nil, nil
+	}
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	removed, err := s.schedules.remove(client.Session().Username, in.ID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if !removed {
+		return errorResult(notFoundError([]string{in.ID}, "no schedule %q", in.ID)), nil, nil
+	}
+	return textResult(fmt.Sprintf("Cancelled %s", in.ID)), nil, nil
+}
@@ -0,0 +1,172 @@
+package jmapmcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+func TestScheduleRuns(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "archive", "name": "Archive", "role": "archive"})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	fake.Add("Email", map[string]any{"id": "e1", "subject": "Snoozed", "receivedAt": time.Now().UTC().Format(time.RFC3339),
+		"mailboxIds": map[string]any{"archive": true}, "keywords": map[string]any{"$seen": true}})
+	fake.Add("Email", map[string]any{"id": "e2", "subject": "Old news", "receivedAt": "2025-01-02T10:00:00Z",
+		"mailboxIds": map[string]any{"archive": true}, "keywords": map[string]any{}})
+	ctx := context.Background()
+
+	for _, in := range []ScheduleCreateInput{
+		{Kind: "cleanup", MailboxID: "archive", OlderThanDays: 30},
+		{Kind: "cleanup", MailboxID: "trash", OlderThanDays: 30, Every: "24h"},
+		{Kind: "wake", EmailIDs: []string{"e1"}, Every: "1h"},
+		{Kind: "digest", Every: "1m"},
+		{Kind: "send", EmailIDs: []string{"d1"}},
+		{Kind: "snooze"},
+	} {
+		if res, _, _ := s.handleScheduleCreate(ctx, nil, in); !res.IsError {
+			t.Errorf("%+v accepted: %s", in, resultText(res))
+		}
+	}
+
+	res, _, _ := s.handleScheduleCreate(ctx, nil, ScheduleCreateInput{Kind: "wake", EmailIDs: []string{"e1"}})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "wake 1 email(s) into Inbox [id: inbox], next run") || !strings.Contains(out, "does not run schedules") {
+		t.Fatalf("schedule_create wake = %s", out)
+	}
+	res, _, _ = s.handleScheduleCreate(ctx, nil, ScheduleCreateInput{Kind: "cleanup", Name: "tidy", MailboxID: "archive", OlderThanDays: 30, Every: "24h"})
+	if out := resultText(res); res.IsError || !strings.Contains(out, `"tidy" — move mail in Archive [id: archive] older than 30 day(s) to Trash, every 24h0m0s`) {
+		t.Fatalf("schedule_create cleanup = %s", out)
+	}
+	cleanupID := strings.TrimSuffix(strings.Fields(resultText(res))[1], ":")
+	if res, _, _ := s.handleScheduleCreate(ctx, nil, ScheduleCreateInput{Kind: "digest", Every: "24h"}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.runSchedules(runCtx, "test@example.org")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		e1, e2 := fake.Object("Email", "e1"), fake.Object("Email", "e2")
+		woken := e1["mailboxIds"].(map[string]any)["inbox"] == true && e1["keywords"].(map[string]any)["$seen"] != true
+		if woken && e2["mailboxIds"].(map[string]any)["trash"] == true {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("schedules did not run: e1 %v, e2 %v", e1, e2)
+		}
+	}
+	if e1 := fake.Object("Email", "e1"); e1["mailboxIds"].(map[string]any)["trash"] == true {
+		t.Error("cleanup took a recent email")
+	}
+
+	var list []scheduledAction
+	for deadline := time.Now().Add(5 * time.Second); len(list) != 2 || list[0].Runs != 1 || list[1].Runs != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("schedules after running: %+v", list)
+		}
+		list, _ = s.schedules.list("test@example.org")
+	}
+	for _, a := range list {
+		if time.Until(a.Next) < 23*time.Hour {
+			t.Errorf("%s not rescheduled: %+v", a.Kind, a)
+		}
+	}
+	digests := 0
+	for _, id := range fake.Objects("Email") {
+		if e := fake.Object("Email", id); strings.HasPrefix(e["subject"].(string), "Digest of mail since ") && e["mailboxIds"].(map[string]any)["inbox"] == true {
+			digests++
+		}
+	}
+	if digests != 1 {
+		t.Errorf("%d digest message(s) delivered", digests)
+	}
+	res, _, _ = s.handleScheduleList(ctx, nil, ScheduleListInput{})
+	if out := resultText(res); !strings.Contains(out, "Schedules: 2\n") || !strings.Contains(out, "ran 1 time(s)") || strings.Contains(out, "does not run schedules") {
+		t.Errorf("schedule_list = %s", out)
+	}
+
+	res, _, _ = s.handleScheduleCancel(ctx, nil, ScheduleCancelInput{ID: cleanupID})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	res, _, _ = s.handleScheduleCancel(ctx, nil, ScheduleCancelInput{ID: cleanupID})
+	if !res.IsError {
+		t.Error("cancelled twice")
+	}
+	list, _ = s.schedules.list("test@example.org")
+	if len(list) != 1 || list[0].Kind != scheduleDigest {
+		t.Errorf("schedules after cancel = %+v", list)
+	}
+}
+
+func TestScheduleCreateNoSend(t *testing.T) {
+	s, fake := newFakeServer(t, WithEmailSubmission())
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "drafts", "name": "Drafts", "role": "drafts"})
+	fake.Add("Email", map[string]any{"id": "d1", "subject": "Later",
+		"mailboxIds": map[string]any{"drafts": true}, "keywords": map[string]any{"$draft": true}})
+	ctx := ContextWithPermission(context.Background(), PermissionNoSend)
+
+	res, _, _ := s.handleScheduleCreate(ctx, nil, ScheduleCreateInput{Kind: "send", EmailIDs: []string{"d1"}, In: "1h"})
+	if te, _ := res.StructuredContent.(*toolError); te == nil || te.Code != codeForbidden {
+		t.Errorf("send schedule under no-send: %s", resultText(res))
+	}
+	if res, _, _ := s.handleScheduleCreate(ctx, nil, ScheduleCreateInput{Kind: "wake", EmailIDs: []string{"d1"}, MailboxID: "inbox", In: "1h"}); res.IsError {
+		t.Errorf("wake schedule under no-send: %s", resultText(res))
+	}
+}
+
+func TestSchedulesFinish(t *testing.T) {
+	st := &store.Memory{}
+	sc, err := LoadSchedules(st, "schedules.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-90 * time.Minute)
+	once := &scheduledAction{Kind: scheduleSend, Next: start, EmailIDs: []jmap.ID{"d1"}}
+	every := &scheduledAction{Kind: scheduleDigest, Next: start, Every: "1h", MailboxID: "inbox"}
+	for _, a := range []*scheduledAction{once, every} {
+		if err := sc.add("me", a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another process sharing the store sees them and records the runs.
+	other, err := LoadSchedules(st, "schedules.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, _, _ := other.due("me", time.Now())
+	if len(due) != 2 {
+		t.Fatalf("due = %+v", due)
+	}
+	now := time.Now()
+	if err := other.finish("me", once.ID, now, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.finish("me", every.ID, now, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := sc.list("me")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+	if got := list[0]; got.ID != every.ID || got.Runs != 1 || !got.Next.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("repeating schedule after a run = %+v", got)
+	}
+	if got := list[1]; got.ID != once.ID || !got.Next.IsZero() || got.LastError != "boom" {
+		t.Errorf("failed one-off schedule = %+v", got)
+	}
+	if due, next, _ := sc.due("me", now); len(due) != 0 || !next.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("due after the runs = %+v, next %v", due, next)
+	}
+}
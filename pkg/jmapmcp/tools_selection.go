@@ -0,0 +1,75 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- selection_set / selection_add ---
+
+type SelectionSetInput struct {
+	Name     string   `json:"name" jsonschema:"Selection name, e.g. newsletters"`
+	EmailIDs []string `json:"email_ids" jsonschema:"Email IDs to hold under the name"`
+}
+
+var selectionSetTool = &mcp.Tool{
+	Name:        "selection_set",
+	Description: "Hold a list of email IDs under a name for this session, replacing any selection of that name. Bulk tools (email_move, email_flag, email_delete, label_apply, label_remove) then take selection instead of email_ids, so a long ID list is sent once. Selections end with the session.",
+	Annotations: idempotentAnnotations,
+}
+
+var selectionAddTool = &mcp.Tool{
+	Name:        "selection_add",
+	Description: "Add email IDs to a named selection of this session, creating it if needed. IDs already in it are kept once.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleSelectionSet(ctx context.Context, req *mcp.CallToolRequest, in SelectionSetInput) (*mcp.CallToolResult, any, error) {
+	return s.putSelection(ctx, req, in, false)
+}
+
+func (s *Server) handleSelectionAdd(ctx context.Context, req *mcp.CallToolRequest, in SelectionSetInput) (*mcp.CallToolResult, any, error) {
+	return s.putSelection(ctx, req, in, true)
+}
+
+func (s *Server) putSelection(ctx context.Context, req *mcp.CallToolRequest, in SelectionSetInput, add bool) (*mcp.CallToolResult, any, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return errorResult(invalidArgument("name is required")), nil, nil
+	}
+	if len(in.EmailIDs) == 0 {
+		return errorResult(invalidArgument("email_ids is required")), nil, nil
+	}
+	n, err := s.selections.put(s.callSessionOf(ctx, req), name, endpointName(ctx), in.EmailIDs, add)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(fmt.Sprintf("Selection %q: %d email(s). Pass selection=%q to bulk tools instead of email_ids.", name, n, name)), nil, nil
+}
+
+// --- selection_clear ---
+
+type SelectionClearInput struct {
+	Name string `json:"name,omitempty" jsonschema:"Selection to drop (omit to drop every selection of this session)"`
+}
+
+var selectionClearTool = &mcp.Tool{
+	Name:        "selection_clear",
+	Description: "Drop a named selection of this session, or all of them. The emails themselves are not touched.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleSelectionClear(ctx context.Context, req *mcp.CallToolRequest, in SelectionClearInput) (*mcp.CallToolResult, any, error) {
+	name := strings.TrimSpace(in.Name)
+	names := s.selections.clear(s.callSessionOf(ctx, req), name)
+	switch {
+	case len(names) == 0 && name != "":
+		return errorResult(&toolError{Code: codeNotFound, Message: fmt.Sprintf("no selection %q in this session", name)}), nil, nil
+	case len(names) == 0:
+		return textResult("No selections to clear."), nil, nil
+	}
+	return textResult(fmt.Sprintf("Cleared selection(s): %s", strings.Join(names, ", "))), nil, nil
+}
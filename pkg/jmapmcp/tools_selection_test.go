@@ -0,0 +1,68 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSelectionDrivesBulkTools(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addEmail(fake, "e1", "One", "Hi", 1)
+	addEmail(fake, "e2", "Two", "Hi", 2)
+	addEmail(fake, "e3", "Three", "Hi", 3)
+	ctx := context.Background()
+
+	res, _, _ := s.handleSelectionSet(ctx, nil, SelectionSetInput{Name: "old", EmailIDs: []string{"e1", "e2"}})
+	if out := resultText(res); res.IsError || !strings.Contains(out, `Selection "old": 2 email(s)`) {
+		t.Fatalf("selection_set: %s", out)
+	}
+	res, _, _ = s.handleSelectionAdd(ctx, nil, SelectionSetInput{Name: "old", EmailIDs: []string{"e2", "e3"}})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "3 email(s)") {
+		t.Fatalf("selection_add: %s", out)
+	}
+
+	seen := true
+	res, _, _ = s.handleEmailFlag(ctx, nil, EmailFlagInput{Selection: "old", Seen: &seen})
+	if res.IsError {
+		t.Fatalf("email_flag with selection: %s", resultText(res))
+	}
+	for _, id := range []string{"e1", "e2", "e3"} {
+		if keywords, _ := fake.Object("Email", id)["keywords"].(map[string]any); keywords["$seen"] != true {
+			t.Errorf("%s not marked seen: %v", id, keywords)
+		}
+	}
+
+	res, _, _ = s.handleEmailFlag(ctx, nil, EmailFlagInput{EmailIDs: []string{"e1"}, Selection: "old", Seen: &seen})
+	if te, _ := res.StructuredContent.(*toolError); !res.IsError || te.Code != codeInvalidArguments {
+		t.Errorf("email_ids and selection together accepted: %s", resultText(res))
+	}
+
+	res, _, _ = s.handleSelectionClear(ctx, nil, SelectionClearInput{Name: "old"})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Cleared selection(s): old") {
+		t.Fatalf("selection_clear: %s", out)
+	}
+	res, _, _ = s.handleEmailDelete(ctx, nil, EmailDeleteInput{Selection: "old"})
+	if te, _ := res.StructuredContent.(*toolError); !res.IsError || te.Code != codeNotFound {
+		t.Errorf("cleared selection still usable: %s", resultText(res))
+	}
+}
+
+func TestSelectionBoundToEndpoint(t *testing.T) {
+	s, _ := newFakeServer(t, WithEndpoint("work", "https://work.example/session", ""))
+	work := ContextWithEndpoint(context.Background(), "work")
+	if res, _, _ := s.handleSelectionSet(work, nil, SelectionSetInput{Name: "w", EmailIDs: []string{"e1"}}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if _, err := s.selectedEmailIDs(context.Background(), nil, nil, "w"); err == nil || !strings.Contains(err.Error(), `pass endpoint="work"`) {
+		t.Errorf("selection used on another endpoint: %v", err)
+	}
+	res, _, _ := s.handleSelectionAdd(context.Background(), nil, SelectionSetInput{Name: "w", EmailIDs: []string{"e2"}})
+	if !res.IsError {
+		t.Errorf("added default IDs to a work selection: %s", resultText(res))
+	}
+	ids, err := s.selectedEmailIDs(work, nil, nil, "w")
+	if err != nil || len(ids) != 1 || ids[0] != "e1" {
+		t.Errorf("selection = %v, %v", ids, err)
+	}
+}
@@ -0,0 +1,327 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/contacts"
+)
+
+// senderHistoryWindow is how many of the sender's messages and of the
+// user's replies are inspected for reply latency.
+const senderHistoryWindow = 50
+
+// --- sender_profile ---
+
+type SenderProfileInput struct {
+	Address string `json:"address" jsonschema:"Sender email address to profile"`
+	Limit   int    `json:"limit,omitempty" jsonschema:"Number of recent messages to list (default 10)"`
+}
+
+var senderProfileTool = &mcp.Tool{
+	Name:        "sender_profile",
+	Description: "Profile a correspondent by email address: total and recent messages from them, how quickly the user usually replies, and their contact card (name, organization, title, phones, photo) when the server supports JMAP Contacts. Use to answer \"who is this person\" questions.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleSenderProfile(ctx context.Context, _ *mcp.CallToolRequest, in SenderProfileInput) (*mcp.CallToolResult, any, error) {
+	if in.Address == "" {
+		return errorResult(invalidArgument("address is required")), nil, nil
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	// Sent mailbox is optional: without it the profile just omits latency.
+	sentID, _ := s.findMailboxByRole(ctx, client, accountID, mailbox.RoleSent)
+
+	noTotal := s.quirksFor(client).has(quirkNoCalculateTotal)
+
+	req := &jmap.Request{Context: ctx}
+	fromCallID := req.Invoke(&email.Query{
+		Account:        accountID,
+		Filter:         &email.FilterCondition{From: in.Address},
+		Sort:           []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+		Limit:          senderHistoryWindow,
+		CalculateTotal: !noTotal,
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: fromCallID, Name: "Email/query", Path: "/ids"},
+		Properties:   []string{"id", "subject", "from", "receivedAt", "messageId"},
+	})
+	if sentID != "" {
+		sentCallID := req.Invoke(&email.Query{
+			Account: accountID,
+			Filter:  &email.FilterCondition{InMailbox: sentID, To: in.Address},
+			Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+			Limit:   senderHistoryWindow,
+		})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: sentCallID, Name: "Email/query", Path: "/ids"},
+			Properties:   []string{"id", "sentAt", "receivedAt", "inReplyTo"},
+		})
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) < 2 {
+		return errorResult(fmt.Errorf("missing Email/get response in query chain")), nil, nil
+	}
+
+	var total uint64
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.QueryResponse:
+		total = args.Total
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	var received []*email.Email
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		received = args.List
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	var sent []*email.Email
+	if len(resp.Responses) >= 4 {
+		if args, ok := resp.Responses[3].Args.(*email.GetResponse); ok {
+			sent = args.List
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Sender: %s\n", in.Address)
+	if len(received) > 0 && len(received[0].From) > 0 && received[0].From[0].Name != "" {
+		fmt.Fprintf(&sb, "Display name: %s\n", received[0].From[0].Name)
+	}
+	if noTotal {
+		fmt.Fprintf(&sb, "Messages from sender: at least %d\n", len(received))
+	} else {
+		fmt.Fprintf(&sb, "Messages from sender: %d\n", total)
+	}
+	if len(received) > 0 && received[0].ReceivedAt != nil {
+		fmt.Fprintf(&sb, "Last message: %s\n", s.locale.dateTime(*received[0].ReceivedAt))
+	}
+
+	if sentID != "" {
+		latencies := replyLatencies(received, sent)
+		fmt.Fprintf(&sb, "Emails sent to them: %d (recent window)\n", len(sent))
+		if len(latencies) > 0 {
+			fmt.Fprintf(&sb, "Reply latency: median %s over %d replies\n", formatLatency(medianDuration(latencies)), len(latencies))
+		} else {
+			sb.WriteString("Reply latency: no replies matched in recent window\n")
+		}
+	}
+
+	if card, err := s.lookupContactCard(ctx, client, in.Address); err != nil {
+		fmt.Fprintf(&sb, "\nContact: lookup failed: %v\n", err)
+	} else if card != nil {
+		fmt.Fprintf(&sb, "\nContact:\n%s", formatContactCard(card, "  "))
+	}
+
+	if len(received) > 0 {
+		sb.WriteString("\nRecent messages:\n")
+		for i, e := range received {
+			if i >= limit {
+				break
+			}
+			date := ""
+			if e.ReceivedAt != nil {
+				date = s.locale.dateTime(*e.ReceivedAt)
+			}
+			fmt.Fprintf(&sb, "  %s  %s  %s\n", e.ID, date, e.Subject)
+		}
+	}
+
+	return textResult(sb.String()), nil, nil
+}
+
+// lookupContactCard returns the first contact card holding address, or nil
+// when the server does not advertise JMAP Contacts or has no match.
+func (s *Server) lookupContactCard(ctx context.Context, client JMAPClient, address string) (*contacts.Card, error) {
+	accountID := client.Session().PrimaryAccounts[contacts.URI]
+	if accountID == "" {
+		return nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	queryCallID := req.Invoke(&contacts.Query{
+		Account: accountID,
+		Filter:  &contacts.FilterCondition{Email: address},
+		Limit:   1,
+	})
+	req.Invoke(&contacts.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "ContactCard/query", Path: "/ids"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 2 {
+		return nil, fmt.Errorf("missing ContactCard/get response in query chain")
+	}
+
+	switch args := resp.Responses[1].Args.(type) {
+	case *contacts.GetResponse:
+		if len(args.List) == 0 {
+			return nil, nil
+		}
+		return args.List[0], nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// formatContactCard renders the card's populated properties, one per line.
+func formatContactCard(card *contacts.Card, indent string) string {
+	var sb strings.Builder
+	if card.Name != nil && card.Name.Full != "" {
+		fmt.Fprintf(&sb, "%sName: %s\n", indent, card.Name.Full)
+	}
+	for _, key := range sortedKeys(card.Organizations) {
+		fmt.Fprintf(&sb, "%sOrganization: %s\n", indent, card.Organizations[key].Name)
+	}
+	for _, key := range sortedKeys(card.Titles) {
+		fmt.Fprintf(&sb, "%sTitle: %s\n", indent, card.Titles[key].Name)
+	}
+	for _, key := range sortedKeys(card.Emails) {
+		fmt.Fprintf(&sb, "%sEmail: %s\n", indent, card.Emails[key].Address)
+	}
+	for _, key := range sortedKeys(card.Phones) {
+		fmt.Fprintf(&sb, "%sPhone: %s\n", indent, card.Phones[key].Number)
+	}
+	for _, key := range sortedKeys(card.Media) {
+		m := card.Media[key]
+		if m.Kind != "photo" {
+			continue
+		}
+		switch {
+		case m.URI != "" && !strings.HasPrefix(m.URI, "data:"):
+			fmt.Fprintf(&sb, "%sPhoto: %s\n", indent, m.URI)
+		case m.BlobID != "":
+			fmt.Fprintf(&sb, "%sPhoto: [blob: %s]\n", indent, m.BlobID)
+		default:
+			fmt.Fprintf(&sb, "%sPhoto: (inline)\n", indent)
+		}
+	}
+	for _, key := range sortedKeys(card.Notes) {
+		fmt.Fprintf(&sb, "%sNote: %s\n", indent, card.Notes[key].Note)
+	}
+	fmt.Fprintf(&sb, "%s[contact id: %s]\n", indent, card.ID)
+	return sb.String()
+}
+
+// replyPair is a sent reply paired with the received message it answers.
+type replyPair struct {
+	original *email.Email
+	latency  time.Duration
+}
+
+// pairReplies pairs the user's sent messages with the received messages
+// they reply to (via In-Reply-To), with the time taken to respond.
+func pairReplies(received, sent []*email.Email) []replyPair {
+	byMessageID := make(map[string]*email.Email, len(received))
+	for _, e := range received {
+		if e.ReceivedAt == nil {
+			continue
+		}
+		for _, id := range e.MessageID {
+			byMessageID[id] = e
+		}
+	}
+	var out []replyPair
+	for _, e := range sent {
+		at := e.SentAt
+		if at == nil {
+			at = e.ReceivedAt
+		}
+		if at == nil {
+			continue
+		}
+		for _, id := range e.InReplyTo {
+			if original, ok := byMessageID[id]; ok && at.After(*original.ReceivedAt) {
+				out = append(out, replyPair{original: original, latency: at.Sub(*original.ReceivedAt)})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// replyLatencies returns the time taken to respond of each of pairReplies.
+func replyLatencies(received, sent []*email.Email) []time.Duration {
+	pairs := pairReplies(received, sent)
+	out := make([]time.Duration, len(pairs))
+	for i, p := range pairs {
+		out[i] = p.latency
+	}
+	return out
+}
+
+// medianDuration returns the median of ds, which must be non-empty.
+func medianDuration(ds []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// formatLatency renders a duration at a human granularity (minutes, hours,
+// or days).
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%.1fh", d.Hours())
+	default:
+		return fmt.Sprintf("%.1fd", d.Hours()/24)
+	}
+}
+
+// sortedKeys returns the keys of m in lexical order for stable output.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
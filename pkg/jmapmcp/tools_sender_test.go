@@ -0,0 +1,50 @@
+package jmapmcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestReplyLatencies(t *testing.T) {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { v := base.Add(d); return &v }
+
+	received := []*email.Email{
+		{MessageID: []string{"m1@example.com"}, ReceivedAt: at(0)},
+		{MessageID: []string{"m2@example.com"}, ReceivedAt: at(time.Hour)},
+	}
+	sent := []*email.Email{
+		{InReplyTo: []string{"m1@example.com"}, SentAt: at(30 * time.Minute)},
+		{InReplyTo: []string{"m2@example.com"}, SentAt: at(4 * time.Hour)},
+		{InReplyTo: []string{"unrelated@example.com"}, SentAt: at(5 * time.Hour)},
+	}
+
+	got := replyLatencies(received, sent)
+	if len(got) != 2 {
+		t.Fatalf("got %d latencies, want 2", len(got))
+	}
+	if got[0] != 30*time.Minute || got[1] != 3*time.Hour {
+		t.Fatalf("got %v", got)
+	}
+	if m := medianDuration(got); m != 105*time.Minute {
+		t.Fatalf("median = %v, want 1h45m", m)
+	}
+}
+
+func TestFormatLatency(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{45 * time.Minute, "45m"},
+		{90 * time.Minute, "1.5h"},
+		{72 * time.Hour, "3.0d"},
+	}
+	for _, tt := range tests {
+		if got := formatLatency(tt.in); got != tt.want {
+			t.Errorf("formatLatency(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
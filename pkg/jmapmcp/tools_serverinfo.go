@@ -0,0 +1,208 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+	"github.com/mikluko/jmap/sieve"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/calendars"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/contacts"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/maskedemail"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/principals"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/quota"
+	"github.com/mikluko/jmap-mcp/internal/jmapext/tasks"
+)
+
+// serverSubsystem is an optional JMAP capability tools depend on.
+type serverSubsystem struct {
+	name string
+	uri  jmap.URI
+}
+
+// serverSubsystems are the subsystems server_info reports, in order.
+var serverSubsystems = []serverSubsystem{
+	{"mail", mail.URI},
+	{"submission", emailsubmission.URI},
+	{"vacation response", vacationResponseURI},
+	{"sieve", sieve.URI},
+	{"quota", quota.URI},
+	{"calendars", calendars.URI},
+	{"contacts", contacts.URI},
+	{"tasks", tasks.URI},
+	{"masked email", maskedemail.URI},
+	{"principals", principals.URI},
+	{"websocket", websocketURI},
+}
+
+// --- server_info ---
+
+type ServerInfoInput struct{}
+
+var serverInfoTool = &mcp.Tool{
+	Name:        "server_info",
+	Description: "Describe this deployment: the jmap-mcp version, which optional features the operator enabled (sending, send queue, Sieve, labels, Stalwart administration, ...), the configured limits (query limit, response budgets, fetch cap, send policy), and the JMAP backend behind the call: its detected implementation, core limits, and which optional subsystems (submission, Sieve, calendars, contacts, tasks, quota, ...) it offers. Call it once to plan around what this deployment can do instead of finding out by failed calls.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleServerInfo(ctx context.Context, _ *mcp.CallToolRequest, _ ServerInfoInput) (*mcp.CallToolResult, any, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "jmap-mcp %s\n", s.version)
+
+	onOff := map[bool]string{true: "on", false: "off"}
+	sb.WriteString("\nFeatures:\n")
+	for _, f := range []struct {
+		name string
+		on   bool
+	}{
+		{"sending (-enable-send)", s.enableEmailSubmission},
+		{"send queue (-send-queue)", s.enableEmailSubmission && s.outbox != nil},
+		{"mail merge (-enable-mail-merge)", s.enableEmailSubmission && s.mailMerge != nil},
+		{"abuse reports (-abuse-address)", s.enableEmailSubmission && s.abuseAddress != ""},
+		{"pre-send review (-pre-send-command, -pre-send-url)", s.preSend != nil},
+		{"destructive confirmation (-confirm-destructive)", s.confirmDestroy},
+		{"sieve (-enable-sieve)", s.enableSieve},
+		{"labels (-label-mode)", s.enableLabels},
+		{"stalwart administration (-enable-stalwart-admin)", s.enableStalwartAdmin},
+		{"attachment URLs (http mode)", s.attachmentURL != nil},
+		{"local files in client roots (stdio mode)", s.localFiles},
+		{"attachment virus scanning (-scan-clamd, -scan-command)", s.scan != nil},
+		{"translation (-translate-url)", s.translator != nil},
+		{"classification (-classify-categories)", s.classifier != nil},
+		{"pdf rendering (-pdf-renderer)", len(s.pdfRenderer) > 0},
+		{"pgp (-pgp-command)", s.pgp != nil},
+		{"redaction (-redact, -redact-regex)", s.redactor != nil},
+		{"websocket (-websocket)", !s.noWebSocket},
+	} {
+		fmt.Fprintf(&sb, "  %s: %s\n", f.name, onOff[f.on])
+	}
+	if s.debugJMAP != DebugJMAPOff {
+		fmt.Fprintf(&sb, "  debug-jmap: %s\n", s.debugJMAP)
+	}
+	if s.locale.Locale != LocaleDefault {
+		fmt.Fprintf(&sb, "  locale: %s\n", s.locale.Locale)
+	}
+	if s.locale.dates != DateFormatLocale {
+		fmt.Fprintf(&sb, "  date format: %s\n", s.locale.dates)
+	}
+	if s.locale.relative {
+		sb.WriteString("  relative dates: on\n")
+	}
+	if len(s.scripts) > 0 {
+		names := make([]string, len(s.scripts))
+		for i, sc := range s.scripts {
+			names[i] = scriptToolPrefix + sc.Name
+		}
+		fmt.Fprintf(&sb, "  scripts: %s\n", strings.Join(names, ", "))
+	}
+	if len(s.queryPresets) > 0 {
+		names := make([]string, len(s.queryPresets))
+		for i, p := range s.queryPresets {
+			names[i] = queryPresetPrefix + p.Name
+		}
+		fmt.Fprintf(&sb, "  query presets: %s\n", strings.Join(names, ", "))
+	}
+	if len(s.endpoints) > 0 {
+		fmt.Fprintf(&sb, "  endpoints: %s\n", strings.Join(s.endpointNames(), ", "))
+	}
+	fmt.Fprintf(&sb, "  tools: %d registered\n", len(s.toolCalls))
+
+	sb.WriteString("\nLimits:\n")
+	fmt.Fprintf(&sb, "  email_query default limit: %d\n", s.queryLimit)
+	fmt.Fprintf(&sb, "  email_get budget: %d chars, %d per body\n", s.maxChars, s.maxBodyChars)
+	fetch := "unlimited"
+	if s.maxFetchBytes > 0 {
+		fetch = s.locale.size(s.maxFetchBytes)
+	}
+	fmt.Fprintf(&sb, "  fetch per call: %s\n", fetch)
+	if s.enableEmailSubmission {
+		resend := "off"
+		if s.resendWindow > 0 {
+			resend = s.resendWindow.String()
+		}
+		fmt.Fprintf(&sb, "  resend window: %s\n", resend)
+		if p := s.sendPolicy; p != nil {
+			if p.MaxRecipients > 0 {
+				fmt.Fprintf(&sb, "  recipients per message: %d\n", p.MaxRecipients)
+			}
+			if p.MaxSendsPerHour > 0 {
+				fmt.Fprintf(&sb, "  sends per hour: %d\n", p.MaxSendsPerHour)
+			}
+			if len(p.AllowedDomains) > 0 {
+				fmt.Fprintf(&sb, "  recipient domains: %s\n", strings.Join(p.AllowedDomains, ", "))
+			}
+		}
+		if s.mailMerge != nil {
+			fmt.Fprintf(&sb, "  mail_merge recipients: %d\n", s.mailMerge.MaxRecipients)
+		}
+	}
+	if p := s.attachmentPolicy; p != nil && p.MaxSize > 0 {
+		fmt.Fprintf(&sb, "  attachment size: %s\n", s.locale.size(int64(p.MaxSize)))
+	}
+	watch := "push only"
+	if s.watchPollInterval > 0 {
+		watch = "poll every " + s.watchPollInterval.String()
+	}
+	fmt.Fprintf(&sb, "  watches: %s, at most %d per tenant\n", watch, maxTenantWatches)
+	cache := "off"
+	if q := s.tenants.quota; q.MaxBytes > 0 {
+		entries, used := s.tenants.usage(s.idOwner(ctx))
+		cache = fmt.Sprintf("%s per tenant (yours: %d result(s), %s)", s.locale.size(q.MaxBytes), entries, s.locale.size(used))
+	}
+	fmt.Fprintf(&sb, "  result cache: %s\n", cache)
+
+	sb.WriteString("\nBackend:\n")
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		fmt.Fprintf(&sb, "  unavailable: %v\n", err)
+		return textResult(sb.String()), nil, nil
+	}
+	session := client.Session()
+	fmt.Fprintf(&sb, "  implementation: %s\n", s.quirksFor(client).backend)
+	if c, ok := session.Capabilities[sieve.URI].(*sieve.Capability); ok && c.Implementation != "" {
+		fmt.Fprintf(&sb, "  sieve implementation: %s\n", c.Implementation)
+	}
+	if c, ok := session.Capabilities[jmap.CoreURI].(*core.Core); ok {
+		fmt.Fprintf(&sb, "  max upload: %s\n", s.locale.size(int64(c.MaxSizeUpload)))
+		fmt.Fprintf(&sb, "  max calls per request: %d, objects per get: %d, per set: %d\n", c.MaxCallsInRequest, c.MaxObjectsInGet, c.MaxObjectsInSet)
+	}
+	plan := s.bulkPlanFor(ctx, client)
+	fmt.Fprintf(&sb, "  bulk changes: %d email(s) per Email/set, %d call(s) per request, %d request(s) at a time\n", plan.setSize, plan.calls, plan.concurrency)
+	fmt.Fprintf(&sb, "  threads: %s\n", s.threadSource(s.quirksFor(client)))
+	var available, missing []string
+	for _, sub := range serverSubsystems {
+		if _, ok := session.RawCapabilities[sub.uri]; ok {
+			available = append(available, sub.name)
+		} else {
+			missing = append(missing, sub.name)
+		}
+	}
+	fmt.Fprintf(&sb, "  subsystems: %s\n", listOrNone(available))
+	fmt.Fprintf(&sb, "  not offered: %s\n", listOrNone(missing))
+	var other []string
+	for uri := range session.RawCapabilities {
+		if uri != jmap.CoreURI && !slices.ContainsFunc(serverSubsystems, func(sub serverSubsystem) bool { return sub.uri == uri }) {
+			other = append(other, string(uri))
+		}
+	}
+	if len(other) > 0 {
+		slices.Sort(other)
+		fmt.Fprintf(&sb, "  other capabilities: %s\n", strings.Join(other, ", "))
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// listOrNone joins items, or says none.
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
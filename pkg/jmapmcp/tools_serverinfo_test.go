@@ -0,0 +1,48 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func TestServerInfo(t *testing.T) {
+	s, fake := newFakeServer(t, WithSieve(), WithQueryLimit(35), WithSendPolicy(SendPolicy{MaxSendsPerHour: 12}), WithEmailSubmission())
+	fake.AddCapability("urn:ietf:params:jmap:sieve", map[string]any{"implementation": "Stalwart Sieve v0.11"})
+	fake.AddCapability("https://example.com/jmap/x-vendor", nil)
+
+	res, _, _ := s.handleServerInfo(context.Background(), nil, ServerInfoInput{})
+	out := resultText(res)
+	for _, want := range []string{
+		"jmap-mcp test\n",
+		"  sending (-enable-send): on\n",
+		"  sieve (-enable-sieve): on\n",
+		"  labels (-label-mode): off\n",
+		"  email_query default limit: 35\n",
+		"  sends per hour: 12\n",
+		"  implementation: stalwart\n",
+		"  sieve implementation: Stalwart Sieve v0.11\n",
+		"  max calls per request: 16, objects per get: 500, per set: 500\n",
+		"  bulk changes: 500 email(s) per Email/set, 16 call(s) per request, 2 request(s) at a time\n",
+		"  threads: from the server (Thread/get)\n",
+		"  subsystems: mail, submission, sieve\n",
+		"  other capabilities: https://example.com/jmap/x-vendor\n",
+	} {
+		if res.IsError || !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(out, "not offered: vacation response, quota,") {
+		t.Errorf("missing subsystems not listed:\n%s", out)
+	}
+
+	// Without a reachable backend the deployment half is still reported.
+	s = NewServer("1.2.3", jmapfake.SessionURL, WithDialer(jmapfake.New()))
+	res, _, _ = s.handleServerInfo(context.Background(), nil, ServerInfoInput{})
+	out = resultText(res)
+	if res.IsError || !strings.Contains(out, "jmap-mcp 1.2.3") || !strings.Contains(out, "  unavailable: no JMAP auth token available") {
+		t.Errorf("without token:\n%s", out)
+	}
+}
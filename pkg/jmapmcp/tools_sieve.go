@@ -1,9 +1,10 @@
-package server
+package jmapmcp
 
 import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 
 	"github.com/mikluko/jmap"
@@ -14,14 +15,109 @@ import (
 
 // sieveAccountID returns the primary account ID for the Sieve capability,
 // or an error if the server does not advertise it.
-func sieveAccountID(client *jmap.Client) (jmap.ID, error) {
-	id := client.Session.PrimaryAccounts[sieve.URI]
+func sieveAccountID(client JMAPClient) (jmap.ID, error) {
+	id := client.Session().PrimaryAccounts[sieve.URI]
 	if id == "" {
-		return "", fmt.Errorf("Sieve capability not available: server does not advertise %s", sieve.URI)
+		return "", capabilityMissing(client.Session(), sieve.URI)
 	}
 	return id, nil
 }
 
+// sieveExtensions returns the Sieve extensions the server advertises, and
+// false when it does not list them.
+func sieveExtensions(session *jmap.Session) ([]string, bool) {
+	c, ok := session.Capabilities[sieve.URI].(*sieve.Capability)
+	if !ok || len(c.SieveExtensions) == 0 {
+		return nil, false
+	}
+	return c.SieveExtensions, true
+}
+
+// sieveSendingCommands are the Sieve actions that send mail: redirect
+// (RFC 5228), reject and ereject (RFC 5429, which answer the sender),
+// vacation (RFC 5230), and notify (RFC 5435, which may send by mailto).
+var sieveSendingCommands = []string{"redirect", "reject", "ereject", "vacation", "notify"}
+
+// sieveSends returns the commands of sieveSendingCommands used in script,
+// in the order listed there. Comments, strings, multi-line text, and tagged
+// arguments are skipped, so a quoted "redirect" or a :redirect tag does not
+// count.
+func sieveSends(script string) []string {
+	used := map[string]bool{}
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case c == '#':
+			i = lineEnd(script, i)
+		case strings.HasPrefix(script[i:], "/*"):
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += 2 + end + 2
+			} else {
+				i = len(script)
+			}
+		case c == '"':
+			i++
+			for i < len(script) && script[i] != '"' {
+				if script[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+		case strings.HasPrefix(script[i:], "text:"):
+			// A multi-line literal runs to a line holding only a dot.
+			i = lineEnd(script, i)
+			for i < len(script) {
+				next := lineEnd(script, i)
+				if strings.TrimRight(script[i:next], "\r\n") == "." {
+					i = next
+					break
+				}
+				i = next
+			}
+		case c == ':' || isSieveIdentChar(c):
+			start := i
+			for i++; i < len(script) && isSieveIdentChar(script[i]); i++ {
+			}
+			if word := strings.ToLower(script[start:i]); slices.Contains(sieveSendingCommands, word) {
+				used[word] = true
+			}
+		default:
+			i++
+		}
+	}
+	var out []string
+	for _, cmd := range sieveSendingCommands {
+		if used[cmd] {
+			out = append(out, cmd)
+		}
+	}
+	return out
+}
+
+// lineEnd returns the index just past the end of the line holding i.
+func lineEnd(s string, i int) int {
+	if n := strings.IndexByte(s[i:], '\n'); n >= 0 {
+		return i + n + 1
+	}
+	return len(s)
+}
+
+func isSieveIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// checkSieveMaySend refuses, under PermissionNoSend, a Sieve script that
+// sends mail, so a script cannot carry mail out of the account for a
+// credential that may not send.
+func checkSieveMaySend(ctx context.Context, script string) error {
+	cmds := sieveSends(script)
+	if len(cmds) == 0 {
+		return nil
+	}
+	return checkMaySend(ctx, "Sieve scripts using "+strings.Join(cmds, ", "))
+}
+
 // --- sieve_get ---
 
 type SieveGetInput struct {
@@ -65,16 +161,16 @@ func (s *Server) handleSieveGet(ctx context.Context, _ *mcp.CallToolRequest, in
 	switch args := resp.Responses[0].Args.(type) {
 	case *sievescript.GetResponse:
 		if len(args.NotFound) > 0 {
-			return errorResult(fmt.Errorf("sieve scripts not found: %v", args.NotFound)), nil, nil
+			return errorResult(notFoundError(args.NotFound, "sieve scripts not found: %v", args.NotFound)), nil, nil
 		}
 
 		// Single script with ID: return metadata + blob content.
 		if in.ID != "" {
 			if len(args.List) == 0 {
-				return errorResult(fmt.Errorf("sieve script %s not found", in.ID)), nil, nil
+				return errorResult(notFoundError([]string{in.ID}, "sieve script %s not found", in.ID)), nil, nil
 			}
 			script := args.List[0]
-			reader, err := client.DownloadWithContext(ctx, accountID, script.BlobID)
+			reader, err := client.Download(ctx, accountID, script.BlobID)
 			if err != nil {
 				return errorResult(fmt.Errorf("download sieve script: %w", err)), nil, nil
 			}
@@ -134,7 +230,7 @@ type SieveSetInput struct {
 
 var sieveSetTool = &mcp.Tool{
 	Name:        "sieve_set",
-	Description: "Create, update, or destroy Sieve scripts. Supports activation on create/update. Use sieve_validate first to check script syntax before saving.",
+	Description: "Create, update, or destroy Sieve scripts. Supports activation on create/update. Use sieve_validate first to check script syntax before saving. The previous content of updated and destroyed scripts is kept; see sieve_history and sieve_rollback.",
 	Annotations: destructiveAnnotations,
 }
 
@@ -157,12 +253,31 @@ func (s *Server) handleSieveSet(ctx context.Context, _ *mcp.CallToolRequest, in
 		return errorResult(err), nil, nil
 	}
 
+	// A script that sends is refused for no-send credentials, including an
+	// existing one being activated without new content.
+	if in.Content != "" {
+		if err := checkSieveMaySend(ctx, in.Content); err != nil {
+			return errorResult(err), nil, nil
+		}
+	} else if isUpdate && in.Activate != nil && *in.Activate && PermissionFromContext(ctx) == PermissionNoSend {
+		content, err := sieveScriptContent(ctx, client, accountID, jmap.ID(in.ID))
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		if err := checkSieveMaySend(ctx, content); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+
 	set := &sievescript.Set{Account: accountID}
 
 	// Upload blob if content is provided (for create or update).
 	var blobID jmap.ID
 	if in.Content != "" {
-		uploadResp, err := client.UploadWithContext(ctx, accountID, strings.NewReader(in.Content))
+		if err := checkSieveScriptSize(client.Session(), len(in.Content)); err != nil {
+			return errorResult(err), nil, nil
+		}
+		uploadResp, err := client.Upload(ctx, accountID, strings.NewReader(in.Content))
 		if err != nil {
 			return errorResult(fmt.Errorf("upload sieve script: %w", err)), nil, nil
 		}
@@ -171,7 +286,7 @@ func (s *Server) handleSieveSet(ctx context.Context, _ *mcp.CallToolRequest, in
 
 	if isCreate {
 		if in.Content == "" {
-			return errorResult(fmt.Errorf("content is required for create")), nil, nil
+			return errorResult(invalidArgument("content is required for create")), nil, nil
 		}
 		set.Create = map[jmap.ID]*sievescript.SieveScript{
 			"new": {Name: &in.Name, BlobID: blobID},
@@ -205,6 +320,16 @@ func (s *Server) handleSieveSet(ctx context.Context, _ *mcp.CallToolRequest, in
 		set.Destroy = toJMAPIDSlice(in.Destroy)
 	}
 
+	// Keep the scripts being replaced or destroyed for sieve_rollback.
+	if len(set.Update) > 0 {
+		if err := s.saveSieveVersions(ctx, client, accountID, []jmap.ID{jmap.ID(in.ID)}, "sieve_set update"); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+	if err := s.saveSieveVersions(ctx, client, accountID, set.Destroy, "sieve_set destroy"); err != nil {
+		return errorResult(err), nil, nil
+	}
+
 	req := &jmap.Request{Context: ctx}
 	req.Invoke(set)
 
@@ -226,19 +351,19 @@ func (s *Server) handleSieveSet(ctx context.Context, _ *mcp.CallToolRequest, in
 			fmt.Fprintf(&sb, "Created sieve script %s [id: %s]\n", cid, script.ID)
 		}
 		for cid, se := range args.NotCreated {
-			errors = append(errors, fmt.Sprintf("create %s: %s", cid, se.Type))
+			errors = append(errors, fmt.Sprintf("create %s: %s", cid, setErrorText(se)))
 		}
 		for id := range args.Updated {
 			fmt.Fprintf(&sb, "Updated sieve script %s\n", id)
 		}
 		for id, se := range args.NotUpdated {
-			errors = append(errors, fmt.Sprintf("update %s: %s", id, se.Type))
+			errors = append(errors, fmt.Sprintf("update %s: %s", id, setErrorText(se)))
 		}
 		for _, id := range args.Destroyed {
 			fmt.Fprintf(&sb, "Destroyed sieve script %s\n", id)
 		}
 		for id, se := range args.NotDestroyed {
-			errors = append(errors, fmt.Sprintf("destroy %s: %s", id, se.Type))
+			errors = append(errors, fmt.Sprintf("destroy %s: %s", id, setErrorText(se)))
 		}
 
 		if len(errors) > 0 {
@@ -279,7 +404,11 @@ func (s *Server) handleSieveValidate(ctx context.Context, _ *mcp.CallToolRequest
 		return errorResult(err), nil, nil
 	}
 
-	uploadResp, err := client.UploadWithContext(ctx, accountID, strings.NewReader(in.Content))
+	if err := checkSieveScriptSize(client.Session(), len(in.Content)); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	uploadResp, err := client.Upload(ctx, accountID, strings.NewReader(in.Content))
 	if err != nil {
 		return errorResult(fmt.Errorf("upload sieve script: %w", err)), nil, nil
 	}
@@ -315,3 +444,65 @@ func (s *Server) handleSieveValidate(ctx context.Context, _ *mcp.CallToolRequest
 		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
 	}
 }
+
+// --- sieve_capabilities ---
+
+type SieveCapabilitiesInput struct{}
+
+var sieveCapabilitiesTool = &mcp.Tool{
+	Name:        "sieve_capabilities",
+	Description: "Report what the server's Sieve implementation accepts: advertised extensions (usable in require), maximum script size, maximum number of scripts, and maximum redirects. Check it before writing a script for sieve_set or sieve_validate so only supported constructs are used.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleSieveCapabilities(ctx context.Context, _ *mcp.CallToolRequest, _ SieveCapabilitiesInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if _, err := sieveAccountID(client); err != nil {
+		return errorResult(err), nil, nil
+	}
+	c, ok := client.Session().Capabilities[sieve.URI].(*sieve.Capability)
+	if !ok {
+		return textResult(fmt.Sprintf("The server advertises %s without details; limits and extensions are unknown, so check scripts with sieve_validate.", sieve.URI)), nil, nil
+	}
+
+	limit := func(n *uint64, format func(uint64) string) string {
+		if n == nil {
+			return "no limit advertised"
+		}
+		return format(*n)
+	}
+	count := func(n uint64) string { return fmt.Sprintf("%d", n) }
+
+	var sb strings.Builder
+	if c.Implementation != "" {
+		fmt.Fprintf(&sb, "Implementation: %s\n", c.Implementation)
+	}
+	fmt.Fprintf(&sb, "Max script size: %s\n", limit(c.MaxSizeScript, func(n uint64) string { return s.locale.size(int64(n)) }))
+	fmt.Fprintf(&sb, "Max scripts: %s\n", limit(c.MaxNumberScripts, count))
+	fmt.Fprintf(&sb, "Max redirects per message: %s\n", limit(c.MaxNumberRedirects, count))
+	if len(c.SieveExtensions) == 0 {
+		sb.WriteString("Extensions: not advertised (check scripts with sieve_validate)\n")
+		return textResult(sb.String()), nil, nil
+	}
+	exts := slices.Sorted(slices.Values(c.SieveExtensions))
+	fmt.Fprintf(&sb, "Extensions (%d): %s\n", len(exts), strings.Join(exts, ", "))
+
+	// What the rule-writing tools of this server need.
+	var missing []string
+	for _, need := range []struct{ tool, ext string }{
+		{"sieve_rule_learn", "fileinto"},
+		{"vacation_via_sieve", "vacation"},
+		{"vacation_via_sieve date windows", "date"},
+	} {
+		if !slices.Contains(exts, need.ext) {
+			missing = append(missing, fmt.Sprintf("%s (needs %s)", need.tool, need.ext))
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(&sb, "Unavailable: %s\n", strings.Join(missing, ", "))
+	}
+	return textResult(sb.String()), nil, nil
+}
@@ -0,0 +1,188 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/sieve/sievescript"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- sieve_history ---
+
+type SieveHistoryInput struct {
+	ID      string `json:"id,omitempty" jsonschema:"Script ID to list the saved versions of (omit to list the scripts with saved versions)"`
+	Version int    `json:"version,omitempty" jsonschema:"With id: show the content of this version (1 is the most recently replaced)"`
+}
+
+var sieveHistoryTool = &mcp.Tool{
+	Name:        "sieve_history",
+	Description: "List the earlier versions of Sieve scripts kept by this server whenever sieve_set, sieve_rule_learn, sender_mute, vacation_via_sieve, or sieve_rollback replaced or destroyed a script. Without id: scripts with saved versions. With id: the versions, newest first; add version to read one before restoring it with sieve_rollback.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleSieveHistory(ctx context.Context, _ *mcp.CallToolRequest, in SieveHistoryInput) (*mcp.CallToolResult, any, error) {
+	if in.Version != 0 && in.ID == "" {
+		return errorResult(invalidArgument("version requires id")), nil, nil
+	}
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if _, err := sieveAccountID(client); err != nil {
+		return errorResult(err), nil, nil
+	}
+	user := client.Session().Username
+
+	var sb strings.Builder
+	if in.ID == "" {
+		ids := s.sieveHistory.scriptIDs(user)
+		if len(ids) == 0 {
+			return textResult("No saved Sieve script versions."), nil, nil
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			versions := s.sieveHistory.versions(user, id)
+			fmt.Fprintf(&sb, "%s [id: %s]: %d version(s), last replaced %s\n", versions[0].Name, id, len(versions), s.locale.dateTime(versions[0].Saved))
+		}
+		return textResult(sb.String()), nil, nil
+	}
+
+	versions := s.sieveHistory.versions(user, in.ID)
+	if len(versions) == 0 {
+		return errorResult(notFoundError([]string{in.ID}, "no saved versions of sieve script %s", in.ID)), nil, nil
+	}
+	if in.Version != 0 {
+		if in.Version < 0 || in.Version > len(versions) {
+			return errorResult(invalidArgument("version must be between 1 and %d", len(versions))), nil, nil
+		}
+		v := versions[in.Version-1]
+		fmt.Fprintf(&sb, "Version %d of %s [id: %s], replaced %s by %s\n\n%s", in.Version, v.Name, in.ID, s.locale.dateTime(v.Saved), v.Reason, v.Content)
+		return textResult(sb.String()), nil, nil
+	}
+	fmt.Fprintf(&sb, "Saved versions of sieve script %s, newest first:\n", in.ID)
+	for i, v := range versions {
+		fmt.Fprintf(&sb, "  %d. %s  %s, %s (replaced by %s)\n", i+1, s.locale.dateTime(v.Saved), v.Name, s.locale.size(int64(len(v.Content))), v.Reason)
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// --- sieve_rollback ---
+
+type SieveRollbackInput struct {
+	ID      string `json:"id" jsonschema:"ID of the script to restore"`
+	Version int    `json:"version,omitempty" jsonschema:"Version to restore, as numbered by sieve_history (default 1, the most recently replaced)"`
+}
+
+var sieveRollbackTool = &mcp.Tool{
+	Name:        "sieve_rollback",
+	Description: "Restore a Sieve script to an earlier version listed by sieve_history. The current content is kept as a new version first, so a rollback can itself be undone. A destroyed script is recreated under its old name (inactive; activate it with sieve_set).",
+	Annotations: destructiveAnnotations,
+}
+
+func (s *Server) handleSieveRollback(ctx context.Context, _ *mcp.CallToolRequest, in SieveRollbackInput) (*mcp.CallToolResult, any, error) {
+	if in.ID == "" {
+		return errorResult(invalidArgument("id is required")), nil, nil
+	}
+	version := in.Version
+	if version == 0 {
+		version = 1
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID, err := sieveAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	versions := s.sieveHistory.versions(client.Session().Username, in.ID)
+	if len(versions) == 0 {
+		return errorResult(notFoundError([]string{in.ID}, "no saved versions of sieve script %s", in.ID)), nil, nil
+	}
+	if version < 0 || version > len(versions) {
+		return errorResult(invalidArgument("version must be between 1 and %d", len(versions))), nil, nil
+	}
+	v := versions[version-1]
+	if err := checkSieveMaySend(ctx, v.Content); err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	// Does the script still exist? Keep its current content if so.
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&sievescript.Get{Account: accountID, IDs: []jmap.ID{jmap.ID(in.ID)}, Properties: []string{"id"}})
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for SieveScript/get")), nil, nil
+	}
+	var exists bool
+	switch args := resp.Responses[0].Args.(type) {
+	case *sievescript.GetResponse:
+		exists = len(args.List) > 0
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+	if exists {
+		if err := s.saveSieveVersions(ctx, client, accountID, []jmap.ID{jmap.ID(in.ID)}, fmt.Sprintf("sieve_rollback to version %d", version)); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+
+	if err := checkSieveScriptSize(client.Session(), len(v.Content)); err != nil {
+		return errorResult(err), nil, nil
+	}
+	upload, err := client.Upload(ctx, accountID, strings.NewReader(v.Content))
+	if err != nil {
+		return errorResult(fmt.Errorf("upload sieve script: %w", err)), nil, nil
+	}
+	set := &sievescript.Set{Account: accountID}
+	if exists {
+		patch := jmap.Patch{"blobId": upload.ID}
+		if v.Name != "" {
+			patch["name"] = v.Name
+		}
+		set.Update = map[jmap.ID]jmap.Patch{jmap.ID(in.ID): patch}
+	} else {
+		restored := &sievescript.SieveScript{BlobID: upload.ID}
+		if v.Name != "" {
+			restored.Name = &v.Name
+		}
+		set.Create = map[jmap.ID]*sievescript.SieveScript{"restored": restored}
+	}
+
+	req = &jmap.Request{Context: ctx}
+	req.Invoke(set)
+	resp, err = client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for SieveScript/set")), nil, nil
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *sievescript.SetResponse:
+		if se, ok := args.NotUpdated[jmap.ID(in.ID)]; ok {
+			return errorResult(setFailure("restore sieve script", se)), nil, nil
+		}
+		if se, ok := args.NotCreated["restored"]; ok {
+			return errorResult(setFailure("recreate sieve script", se)), nil, nil
+		}
+		if created, ok := args.Created["restored"]; ok {
+			return textResult(fmt.Sprintf("Recreated destroyed sieve script %s from version %d as [id: %s] (inactive; activate it with sieve_set).", v.Name, version, created.ID)), nil, nil
+		}
+		return textResult(fmt.Sprintf("Restored sieve script %s [id: %s] to version %d (replaced %s by %s).", v.Name, in.ID, version, s.locale.dateTime(v.Saved), v.Reason)), nil, nil
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
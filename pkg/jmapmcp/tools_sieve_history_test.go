@@ -0,0 +1,57 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/sieve"
+)
+
+func TestSieveRollback(t *testing.T) {
+	s, fake := newFakeServer(t, WithSieve())
+	fake.AddCapability(string(sieve.URI), nil)
+	fake.Add("SieveScript", map[string]any{"id": "S1", "name": "main", "blobId": fake.AddBlob([]byte("keep;\n")), "isActive": false})
+	ctx := context.Background()
+
+	res, _, _ := s.handleSieveSet(ctx, nil, SieveSetInput{ID: "S1", Content: "discard;\n"})
+	if res.IsError {
+		t.Fatalf("sieve_set: %s", resultText(res))
+	}
+	res, _, _ = s.handleSieveHistory(ctx, nil, SieveHistoryInput{ID: "S1"})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "1. ") || !strings.Contains(out, "main, 6 bytes (replaced by sieve_set update)") {
+		t.Fatalf("sieve_history:\n%s", out)
+	}
+
+	res, _, _ = s.handleSieveRollback(ctx, nil, SieveRollbackInput{ID: "S1"})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Restored sieve script main [id: S1] to version 1") {
+		t.Fatalf("sieve_rollback:\n%s", out)
+	}
+	blobID, _ := fake.Object("SieveScript", "S1")["blobId"].(string)
+	if got := string(fake.Blob(blobID)); got != "keep;\n" {
+		t.Errorf("restored content = %q", got)
+	}
+	// The rollback kept the content it replaced.
+	res, _, _ = s.handleSieveHistory(ctx, nil, SieveHistoryInput{ID: "S1", Version: 1})
+	if out := resultText(res); !strings.Contains(out, "by sieve_rollback to version 1") || !strings.HasSuffix(out, "discard;\n") {
+		t.Errorf("version after rollback:\n%s", out)
+	}
+
+	// A destroyed script is recreated.
+	res, _, _ = s.handleSieveSet(ctx, nil, SieveSetInput{Destroy: []string{"S1"}})
+	if res.IsError {
+		t.Fatalf("destroy: %s", resultText(res))
+	}
+	res, _, _ = s.handleSieveRollback(ctx, nil, SieveRollbackInput{ID: "S1"})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Recreated destroyed sieve script main") {
+		t.Fatalf("rollback of destroyed script:\n%s", out)
+	}
+	ids := fake.Objects("SieveScript")
+	if len(ids) != 1 {
+		t.Fatalf("scripts = %v", ids)
+	}
+	blobID, _ = fake.Object("SieveScript", ids[0])["blobId"].(string)
+	if got := string(fake.Blob(blobID)); got != "keep;\n" {
+		t.Errorf("recreated content = %q", got)
+	}
+}
@@ -0,0 +1,391 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/mikluko/jmap/sieve/sievescript"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultLearnHistory = 50
+	minLearnMessages    = 3 // filed messages needed before a rule is proposed
+
+	// managedScriptName names the script created to hold the managed
+	// region when no script is active.
+	managedScriptName = "jmap-mcp"
+
+	managedBegin   = "# BEGIN jmap-mcp managed rules (maintained by sieve_rule_learn; edits inside are overwritten)"
+	managedEnd     = "# END jmap-mcp managed rules"
+	managedRequire = `require ["fileinto"];`
+	ruleMarker     = "# rule: "
+)
+
+// --- sieve_rule_learn ---
+
+type SieveRuleLearnInput struct {
+	From      string `json:"from,omitempty" jsonschema:"Sender address to learn a rule for"`
+	ListID    string `json:"list_id,omitempty" jsonschema:"Mailing list identifier (List-Id) to learn a rule for, instead of from"`
+	History   int    `json:"history,omitempty" jsonschema:"Past messages to learn from (default 50, max 100)"`
+	MailboxID string `json:"mailbox_id,omitempty" jsonschema:"File into this mailbox instead of the one learned from history"`
+	Install   bool   `json:"install,omitempty" jsonschema:"Install the proposed rule into the active Sieve script; set only after the user confirms the proposal"`
+}
+
+var sieveRuleLearnTool = &mcp.Tool{
+	Name:        "sieve_rule_learn",
+	Description: "Learn a server-side filing rule from history: looks at where past messages from a sender or mailing list (List-Id) were filed and proposes a Sieve fileinto rule. Without install it only reports the evidence and the rule; with install=true (after the user confirms) it adds or replaces the rule in a managed region of the active Sieve script, creating and activating a script if none is active. Rules outside the managed region are left untouched.",
+	Annotations: destructiveAnnotations,
+}
+
+func (s *Server) handleSieveRuleLearn(ctx context.Context, _ *mcp.CallToolRequest, in SieveRuleLearnInput) (*mcp.CallToolResult, any, error) {
+	if (in.From == "") == (in.ListID == "") {
+		return errorResult(invalidArgument("provide exactly one of from or list_id")), nil, nil
+	}
+	history := in.History
+	if history <= 0 {
+		history = defaultLearnHistory
+	}
+	history = min(history, triageHistoryCeiling)
+	key := triageKey{listID: in.ListID, from: strings.ToLower(in.From)}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	sieveAccount, err := sieveAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	mailboxes, err := fetchMailboxes(ctx, client, accountID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	past, err := triageHistory(ctx, client, accountID, map[jmap.ID]triageKey{"": key}, history)
+	if err != nil {
+		if key.listID != "" && isUnsupportedFilterErr(err) {
+			return errorResult(fmt.Errorf("server cannot filter on List-Id; learn a rule by from instead")), nil, nil
+		}
+		return errorResult(err), nil, nil
+	}
+	prior := past[key]
+
+	target, filed := learnFilingTarget(prior, mailboxes)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "History: %d earlier messages from %s", len(prior), key)
+	if target != nil {
+		fmt.Fprintf(&sb, ", %d filed in %s", filed, target.Name)
+	}
+	sb.WriteString("\n")
+
+	if in.MailboxID != "" {
+		target = mailboxes[jmap.ID(in.MailboxID)]
+		if target == nil {
+			return errorResult(notFoundError([]string{in.MailboxID}, "mailbox %s not found", in.MailboxID)), nil, nil
+		}
+	} else if target == nil || filed < minLearnMessages || float64(filed)/float64(len(prior)) < triageDominantShare {
+		sb.WriteString("No consistent filing pattern to learn a rule from; pass mailbox_id to propose one anyway.\n")
+		return textResult(sb.String()), nil, nil
+	}
+
+	rule := sieveFileintoRule(key, mailboxPath(target, mailboxes))
+	fmt.Fprintf(&sb, "\nProposed rule:\n\n%s\n", rule)
+	if !in.Install {
+		sb.WriteString("\nNot installed. After the user confirms, call sieve_rule_learn again with install=true to add it to the managed rules region of the active Sieve script.\n")
+		return textResult(sb.String()), nil, nil
+	}
+
+	msg, err := s.installManagedRule(ctx, client, sieveAccount, key, rule)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	fmt.Fprintf(&sb, "\n%s\n", msg)
+	return textResult(sb.String()), nil, nil
+}
+
+// fetchMailboxes returns the account's mailboxes by ID.
+func fetchMailboxes(ctx context.Context, client JMAPClient, accountID jmap.ID) (map[jmap.ID]*mailbox.Mailbox, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "name", "role", "parentId", "myRights"}})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Mailbox/get")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		mailboxes := make(map[jmap.ID]*mailbox.Mailbox, len(args.List))
+		for _, mb := range args.List {
+			mailboxes[mb.ID] = mb
+		}
+		return mailboxes, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// learnFilingTarget returns the mailbox holding most of the prior messages
+// outside the inbox and the system mailboxes a rule should never file into,
+// and how many messages it holds.
+func learnFilingTarget(prior []*email.Email, mailboxes map[jmap.ID]*mailbox.Mailbox) (*mailbox.Mailbox, int) {
+	where := make(map[jmap.ID]int)
+	for _, p := range prior {
+		for id := range p.MailboxIDs {
+			if mb := mailboxes[id]; mb != nil && filingTarget(mb) {
+				where[id]++
+			}
+		}
+	}
+	var top jmap.ID
+	for id, k := range where {
+		if k > where[top] || k == where[top] && id < top {
+			top = id
+		}
+	}
+	return mailboxes[top], where[top]
+}
+
+// filingTarget reports whether mail may be filed into mb: the inbox and
+// the system mailboxes are no place to file to.
+func filingTarget(mb *mailbox.Mailbox) bool {
+	switch mb.Role {
+	case mailbox.RoleInbox, mailbox.RoleDrafts, mailbox.RoleSent, mailbox.RoleTrash:
+		return false
+	}
+	return true
+}
+
+// mailboxPath returns the mailbox's name prefixed by its parents', joined
+// with "/", the form Sieve fileinto expects on JMAP servers.
+func mailboxPath(mb *mailbox.Mailbox, mailboxes map[jmap.ID]*mailbox.Mailbox) string {
+	parts := []string{mb.Name}
+	seen := map[jmap.ID]bool{mb.ID: true}
+	for p := mailboxes[mb.ParentID]; p != nil && !seen[p.ID]; p = mailboxes[p.ParentID] {
+		seen[p.ID] = true
+		parts = append(parts, p.Name)
+	}
+	slices.Reverse(parts)
+	return strings.Join(parts, "/")
+}
+
+// sieveFileintoRule renders a rule filing the key's messages into folder,
+// headed by the marker comment that identifies it in the managed region.
+func sieveFileintoRule(key triageKey, folder string) string {
+	var test string
+	if key.listID != "" {
+		test = fmt.Sprintf(`header :contains "List-Id" %s`, sieveString("<"+key.listID+">"))
+	} else {
+		test = fmt.Sprintf(`address :is "from" %s`, sieveString(key.from))
+	}
+	return fmt.Sprintf("%s%s\nif %s {\n    fileinto %s;\n}", ruleMarker, key, test, sieveString(folder))
+}
+
+// sieveString quotes s as a Sieve quoted string.
+func sieveString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// mergeManagedRule adds rule to the managed region of script, replacing a
+// rule with the same marker, and returns the new script. A missing region
+// is inserted after the script's leading require statements and comments,
+// so its own require stays ahead of any command.
+func mergeManagedRule(script, rule string) string {
+	marker, _, _ := strings.Cut(rule, "\n")
+	lines := strings.Split(strings.TrimRight(script, "\n"), "\n")
+	if script == "" {
+		lines = nil
+	}
+
+	begin, end := slices.Index(lines, managedBegin), slices.Index(lines, managedEnd)
+	if begin < 0 || end < begin {
+		at := leadingRequires(lines)
+		region := []string{managedBegin, managedRequire, managedEnd}
+		lines = slices.Insert(lines, at, region...)
+		begin, end = at, at+2
+	}
+
+	// Split the region body into rules, each starting at its marker.
+	var rules [][]string
+	for i, line := range lines[begin+1 : end] {
+		switch {
+		case i == 0 && strings.HasPrefix(line, "require "):
+		case strings.HasPrefix(line, ruleMarker):
+			rules = append(rules, []string{line})
+		case len(rules) > 0:
+			rules[len(rules)-1] = append(rules[len(rules)-1], line)
+		}
+	}
+	newRule := strings.Split(rule, "\n")
+	if i := slices.IndexFunc(rules, func(r []string) bool { return r[0] == marker }); i >= 0 {
+		rules[i] = newRule
+	} else {
+		rules = append(rules, newRule)
+	}
+
+	body := []string{managedBegin, managedRequireFor(rules)}
+	for _, r := range rules {
+		body = append(body, r...)
+	}
+	body = append(body, managedEnd)
+	lines = slices.Replace(lines, begin, end+1, body...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// managedRequireFor returns the managed region's require statement: the
+// fileinto every region has required so far, and the extensions of any
+// vacation rule (RFC 5230) and date windows (RFC 5260, RFC 5231).
+func managedRequireFor(rules [][]string) string {
+	var vacation, dates bool
+	for _, r := range rules {
+		for _, line := range r {
+			t := strings.TrimSpace(line)
+			vacation = vacation || strings.HasPrefix(t, "vacation ")
+			dates = dates || strings.Contains(t, "currentdate ")
+		}
+	}
+	if !vacation && !dates {
+		return managedRequire
+	}
+	exts := []string{`"fileinto"`}
+	if dates {
+		exts = append(exts, `"date"`, `"relational"`)
+	}
+	if vacation {
+		exts = append(exts, `"vacation"`)
+	}
+	return "require [" + strings.Join(exts, ", ") + "];"
+}
+
+// leadingRequires returns the index of the first line after the script's
+// opening run of require statements, comments, and blank lines.
+func leadingRequires(lines []string) int {
+	inRequire := false
+	for i, line := range lines {
+		t := strings.TrimSpace(line)
+		switch {
+		case inRequire:
+		case t == "" || strings.HasPrefix(t, "#"):
+			continue
+		case strings.HasPrefix(t, "require"):
+			inRequire = true
+		default:
+			return i
+		}
+		if strings.Contains(t, ";") {
+			inRequire = false
+		}
+	}
+	return len(lines)
+}
+
+// installManagedRule merges rule into the active script, or creates and
+// activates a script holding it when none is active.
+func (s *Server) installManagedRule(ctx context.Context, client JMAPClient, accountID jmap.ID, key triageKey, rule string) (string, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&sievescript.Get{Account: accountID})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for SieveScript/get")
+	}
+
+	var active *sievescript.SieveScript
+	switch args := resp.Responses[0].Args.(type) {
+	case *sievescript.GetResponse:
+		for _, script := range args.List {
+			if script.IsActive {
+				active = script
+			}
+		}
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+
+	var current string
+	if active != nil {
+		if current, err = downloadSieveScript(ctx, client, accountID, active.BlobID); err != nil {
+			return "", err
+		}
+		v := sieveVersion{Saved: time.Now(), Content: current, Reason: "managed rule for " + key.String()}
+		if active.Name != nil {
+			v.Name = *active.Name
+		}
+		if err := s.sieveHistory.record(client.Session().Username, string(active.ID), v); err != nil {
+			return "", fmt.Errorf("keeping the previous version of sieve script %s: %w", active.ID, err)
+		}
+	}
+
+	content := mergeManagedRule(current, rule)
+	if err := checkSieveScriptSize(client.Session(), len(content)); err != nil {
+		return "", err
+	}
+	upload, err := client.Upload(ctx, accountID, strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("upload sieve script: %w", err)
+	}
+
+	set := &sievescript.Set{Account: accountID}
+	var msg string
+	if active != nil {
+		set.Update = map[jmap.ID]jmap.Patch{active.ID: {"blobId": upload.ID}}
+		name := "(unnamed)"
+		if active.Name != nil {
+			name = *active.Name
+		}
+		msg = fmt.Sprintf("Installed rule for %s in active script %s [id: %s].", key, name, active.ID)
+	} else {
+		name := managedScriptName
+		set.Create = map[jmap.ID]*sievescript.SieveScript{"new": {Name: &name, BlobID: upload.ID}}
+		id := jmap.ID("#new")
+		set.OnSuccessActivateScript = &id
+		msg = fmt.Sprintf("Installed rule for %s in new active script %s.", key, managedScriptName)
+	}
+
+	req = &jmap.Request{Context: ctx}
+	req.Invoke(set)
+	resp, err = client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for SieveScript/set")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *sievescript.SetResponse:
+		for _, se := range args.NotCreated {
+			return "", setFailure("create sieve script", se)
+		}
+		for _, se := range args.NotUpdated {
+			return "", setFailure("update sieve script", se)
+		}
+		return msg, nil
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+}
@@ -0,0 +1,141 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/sieve"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+func TestMergeManagedRule(t *testing.T) {
+	shop := sieveFileintoRule(triageKey{from: "orders@shop.example"}, "Receipts")
+	list := sieveFileintoRule(triageKey{listID: "golang-nuts.googlegroups.com"}, "Lists/Go")
+
+	got := mergeManagedRule("", shop)
+	want := managedBegin + "\n" + managedRequire + "\n" +
+		"# rule: orders@shop.example\nif address :is \"from\" \"orders@shop.example\" {\n    fileinto \"Receipts\";\n}\n" +
+		managedEnd + "\n"
+	if got != want {
+		t.Errorf("empty script:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The region goes after the user's requires and ahead of their rules.
+	user := "# my rules\nrequire [\"vacation\",\n  \"envelope\"];\n\nif true { keep; }\n"
+	got = mergeManagedRule(user, shop)
+	begin, rules := strings.Index(got, managedBegin), strings.Index(got, "if true")
+	if !strings.HasPrefix(got, "# my rules\nrequire [\"vacation\",\n  \"envelope\"];\n\n") || begin < 0 || begin > rules {
+		t.Errorf("region misplaced:\n%s", got)
+	}
+
+	// A second rule is appended; relearning a rule replaces it in place.
+	got = mergeManagedRule(got, list)
+	got = mergeManagedRule(got, sieveFileintoRule(triageKey{from: "orders@shop.example"}, "Archive"))
+	if strings.Count(got, managedBegin) != 1 || strings.Count(got, "# rule: orders@shop.example") != 1 {
+		t.Errorf("rule duplicated:\n%s", got)
+	}
+	if strings.Contains(got, `"Receipts"`) || !strings.Contains(got, `fileinto "Archive"`) {
+		t.Errorf("rule not replaced:\n%s", got)
+	}
+	if strings.Index(got, "orders@shop.example") > strings.Index(got, "List-Id") {
+		t.Errorf("rule order changed:\n%s", got)
+	}
+	if !strings.Contains(got, `header :contains "List-Id" "<golang-nuts.googlegroups.com>"`) || !strings.HasSuffix(got, "if true { keep; }\n") {
+		t.Errorf("unexpected script:\n%s", got)
+	}
+}
+
+func TestSieveString(t *testing.T) {
+	if got := sieveString(`a "b" \c`); got != `"a \"b\" \\c"` {
+		t.Errorf("sieveString = %s", got)
+	}
+}
+
+// addFiledHistory seeds mailboxes and three read receipts from the shop
+// filed under Shopping/Receipts.
+func addFiledHistory(fake *jmapfake.Server) {
+	fake.AddCapability(string(sieve.URI), nil)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "shopping", "name": "Shopping"})
+	fake.Add("Mailbox", map[string]any{"id": "receipts", "name": "Receipts", "parentId": "shopping"})
+	for i, id := range []string{"p1", "p2", "p3"} {
+		addEmail(fake, id, "Your order", "", i+1)
+		e := fake.Object("Email", id)
+		e["from"] = []any{map[string]any{"email": "orders@shop.example"}}
+		e["mailboxIds"] = map[string]any{"receipts": true}
+		fake.Add("Email", e)
+	}
+}
+
+func TestSieveRuleLearn(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addFiledHistory(fake)
+
+	res, _, _ := s.handleSieveRuleLearn(context.Background(), nil, SieveRuleLearnInput{From: "orders@shop.example"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	for _, want := range []string{
+		"History: 3 earlier messages from orders@shop.example, 3 filed in Receipts",
+		`fileinto "Shopping/Receipts";`,
+		"Not installed.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if len(fake.Objects("SieveScript")) != 0 {
+		t.Errorf("proposal installed a script")
+	}
+
+	// Installing with no active script creates and activates one.
+	res, _, _ = s.handleSieveRuleLearn(context.Background(), nil, SieveRuleLearnInput{From: "orders@shop.example", Install: true})
+	if res.IsError {
+		t.Fatalf("install error: %s", resultText(res))
+	}
+	ids := fake.Objects("SieveScript")
+	if len(ids) != 1 {
+		t.Fatalf("scripts = %v, want one", ids)
+	}
+	script := fake.Object("SieveScript", ids[0])
+	if script["name"] != managedScriptName || script["isActive"] != true {
+		t.Errorf("script = %v, want active %s", script, managedScriptName)
+	}
+	blobID, _ := script["blobId"].(string)
+	if content := string(fake.Blob(blobID)); !strings.Contains(content, `fileinto "Shopping/Receipts";`) {
+		t.Errorf("installed script:\n%s", content)
+	}
+}
+
+func TestSieveRuleLearnMergesActiveScript(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addFiledHistory(fake)
+	user := "require [\"vacation\"];\nvacation \"Away\";\n"
+	fake.Add("SieveScript", map[string]any{"id": "S1", "name": "main", "blobId": fake.AddBlob([]byte(user)), "isActive": true})
+
+	res, _, _ := s.handleSieveRuleLearn(context.Background(), nil, SieveRuleLearnInput{From: "orders@shop.example", Install: true})
+	if res.IsError {
+		t.Fatalf("install error: %s", resultText(res))
+	}
+	if !strings.Contains(resultText(res), "active script main [id: S1]") {
+		t.Errorf("unexpected result:\n%s", resultText(res))
+	}
+	blobID, _ := fake.Object("SieveScript", "S1")["blobId"].(string)
+	content := string(fake.Blob(blobID))
+	if !strings.HasPrefix(content, "require [\"vacation\"];\n"+managedBegin) || !strings.HasSuffix(content, managedEnd+"\nvacation \"Away\";\n") {
+		t.Errorf("merged script:\n%s", content)
+	}
+}
+
+func TestSieveRuleLearnNoPattern(t *testing.T) {
+	s, fake := newFakeServer(t)
+	addFiledHistory(fake)
+
+	res, _, _ := s.handleSieveRuleLearn(context.Background(), nil, SieveRuleLearnInput{From: "alice@example.org"})
+	if res.IsError || !strings.Contains(resultText(res), "No consistent filing pattern") {
+		t.Errorf("unexpected result:\n%s", resultText(res))
+	}
+}
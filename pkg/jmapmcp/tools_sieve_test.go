@@ -0,0 +1,112 @@
+package jmapmcp
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/sieve"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestSieveCapabilities(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.AddCapability(string(sieve.URI), map[string]any{
+		"implementation":   "Stalwart v0.11",
+		"maxSizeScript":    65536,
+		"maxNumberScripts": 10,
+		"sieveExtensions":  []any{"vacation", "fileinto", "envelope"},
+	})
+
+	res, _, _ := s.handleSieveCapabilities(context.Background(), nil, SieveCapabilitiesInput{})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("error: %s", out)
+	}
+	for _, want := range []string{
+		"Implementation: Stalwart v0.11",
+		"Max script size: 65536 bytes",
+		"Max scripts: 10",
+		"Max redirects per message: no limit advertised",
+		"Extensions (3): envelope, fileinto, vacation",
+		"Unavailable: vacation_via_sieve date windows (needs date)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSieveCapabilitiesNoSieve(t *testing.T) {
+	s, _ := newFakeServer(t)
+	res, _, _ := s.handleSieveCapabilities(context.Background(), nil, SieveCapabilitiesInput{})
+	if !res.IsError || !strings.Contains(resultText(res), "server does not support Sieve") {
+		t.Fatalf("unexpected result:\n%s", resultText(res))
+	}
+	te := res.StructuredContent.(*toolError)
+	if te.Code != codeCapabilityMissing || te.Capability != string(sieve.URI) ||
+		!slices.Contains(te.Advertised, string(mail.URI)) || len(te.Alternatives) == 0 {
+		t.Errorf("structured content = %+v", te)
+	}
+}
+
+func TestSieveSends(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{"require \"fileinto\";\nfileinto \"Archive\";\n", nil},
+		{"if address :is \"from\" \"boss@example.com\" {\n  redirect \"me@example.org\";\n}\n", []string{"redirect"}},
+		{"require [\"vacation\", \"reject\"];\nVacation \"Away\";\nreject \"no\";\n", []string{"reject", "vacation"}},
+		{"# redirect \"x@example.com\";\n/* vacation \"away\"; */\nkeep;\n", nil},
+		{"fileinto \"redirect\";\nnotify :method \"mailto:\" \"x\";\n", []string{"notify"}},
+		{"keep :redirect;\n", nil},
+		{"require \"reject\";\nreject text:\nredirect me\n.\n;\nkeep;\n", []string{"reject"}},
+	}
+	for _, tt := range tests {
+		if got := sieveSends(tt.script); !slices.Equal(got, tt.want) {
+			t.Errorf("sieveSends(%q) = %v, want %v", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestSieveToolsRefuseSendingUnderNoSend(t *testing.T) {
+	s, fake := newFakeServer(t, WithSieve())
+	fake.AddCapability(string(sieve.URI), map[string]any{"sieveExtensions": []any{"fileinto", "vacation"}})
+	fake.Add("SieveScript", map[string]any{"id": "S1", "name": "away", "blobId": fake.AddBlob([]byte("vacation \"Away\";\n")), "isActive": false})
+	fake.Add("SieveScript", map[string]any{"id": "S2", "name": "forward", "blobId": fake.AddBlob([]byte("redirect \"me@example.org\";\n")), "isActive": false})
+	// Replace S2 while sending is allowed, so its redirect is a saved version.
+	if res, _, _ := s.handleSieveSet(context.Background(), nil, SieveSetInput{ID: "S2", Content: "keep;\n"}); res.IsError {
+		t.Fatalf("sieve_set: %s", resultText(res))
+	}
+	ctx := ContextWithPermission(context.Background(), PermissionNoSend)
+	forbidden := func(name string, res *mcp.CallToolResult) {
+		t.Helper()
+		if te, _ := res.StructuredContent.(*toolError); !res.IsError || te == nil || te.Code != codeForbidden {
+			t.Errorf("%s not refused: %s", name, resultText(res))
+		}
+	}
+
+	res, _, _ := s.handleSieveSet(ctx, nil, SieveSetInput{Name: "fwd", Content: "redirect \"me@example.org\";\n"})
+	forbidden("sieve_set with redirect", res)
+	activate := true
+	res, _, _ = s.handleSieveSet(ctx, nil, SieveSetInput{ID: "S1", Activate: &activate})
+	forbidden("activating a vacation script", res)
+	res, _, _ = s.handleSieveRollback(ctx, nil, SieveRollbackInput{ID: "S2"})
+	forbidden("rollback to a redirect", res)
+	res, _, _ = s.handleVacationViaSieve(ctx, nil, VacationViaSieveInput{Message: "Away"})
+	forbidden("vacation_via_sieve", res)
+	doc := `{"version": 1, "sieveScripts": [{"name": "fwd", "content": "redirect \"me@example.org\";\n"}]}`
+	res, _, _ = s.handleConfigImport(ctx, nil, ConfigImportInput{Document: doc, Apply: true})
+	forbidden("config_import with redirect", res)
+	if n := len(fake.Objects("SieveScript")); n != 2 {
+		t.Errorf("%d scripts after refused calls, want 2", n)
+	}
+
+	res, _, _ = s.handleSieveSet(ctx, nil, SieveSetInput{Name: "filing", Content: "require \"fileinto\";\nfileinto \"Archive\";\n"})
+	if res.IsError {
+		t.Errorf("script without sending refused: %s", resultText(res))
+	}
+}
@@ -0,0 +1,296 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/stalwart"
+)
+
+// maxUndeleteScan bounds how many deleted items stalwart_undelete_restore
+// searches for the requested hashes.
+const maxUndeleteScan = 1000
+
+// stalwartClient returns a management API client for the selected endpoint,
+// authenticated with the caller's JMAP token.
+func (s *Server) stalwartClient(ctx context.Context) (*stalwart.Client, error) {
+	ep, err := s.endpointFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stalwart.NewClient(ep.sessionURL, token)
+}
+
+// --- stalwart_principal_list ---
+
+type StalwartPrincipalListInput struct {
+	Filter string `json:"filter,omitempty" jsonschema:"Match principal names and email addresses containing this text"`
+	Type   string `json:"type,omitempty" jsonschema:"Principal type: individual (default), group, list, domain, tenant, role"`
+	Page   int    `json:"page,omitempty" jsonschema:"Page number, starting at 1"`
+	Limit  int    `json:"limit,omitempty" jsonschema:"Principals per page (default 50)"`
+}
+
+var stalwartPrincipalListTool = &mcp.Tool{
+	Name:        "stalwart_principal_list",
+	Description: "List accounts and other principals on a Stalwart server with their email addresses and disk quota usage. Requires a Stalwart backend and an admin token.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleStalwartPrincipalList(ctx context.Context, _ *mcp.CallToolRequest, in StalwartPrincipalListInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.stalwartClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	typ := in.Type
+	if typ == "" {
+		typ = "individual"
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	list, err := client.ListPrincipals(ctx, []string{typ}, in.Filter, in.Page, limit)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(list.Items) == 0 {
+		return textResult("No principals found"), nil, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of %d %s principal(s):\n", len(list.Items), list.Total, typ)
+	for _, p := range list.Items {
+		sb.WriteString("\n")
+		sb.WriteString(formatPrincipal(p))
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// --- stalwart_principal_get ---
+
+type StalwartPrincipalGetInput struct {
+	Name string `json:"name" jsonschema:"Principal (account) name"`
+}
+
+var stalwartPrincipalGetTool = &mcp.Tool{
+	Name:        "stalwart_principal_get",
+	Description: "Get one Stalwart principal: type, email addresses, quota and usage, group memberships, and roles. Requires an admin token.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleStalwartPrincipalGet(ctx context.Context, _ *mcp.CallToolRequest, in StalwartPrincipalGetInput) (*mcp.CallToolResult, any, error) {
+	if in.Name == "" {
+		return errorResult(invalidArgument("name is required")), nil, nil
+	}
+
+	client, err := s.stalwartClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	p, err := client.GetPrincipal(ctx, in.Name)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(formatPrincipal(p)), nil, nil
+}
+
+// --- stalwart_quota_set ---
+
+type StalwartQuotaSetInput struct {
+	Name  string `json:"name" jsonschema:"Principal (account) name"`
+	Bytes int64  `json:"bytes" jsonschema:"New disk quota in bytes; 0 removes the limit"`
+}
+
+var stalwartQuotaSetTool = &mcp.Tool{
+	Name:        "stalwart_quota_set",
+	Description: "Set the disk quota of a Stalwart account. Requires an admin token.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleStalwartQuotaSet(ctx context.Context, _ *mcp.CallToolRequest, in StalwartQuotaSetInput) (*mcp.CallToolResult, any, error) {
+	if in.Name == "" {
+		return errorResult(invalidArgument("name is required")), nil, nil
+	}
+	if in.Bytes < 0 {
+		return errorResult(invalidArgument("bytes must not be negative")), nil, nil
+	}
+
+	client, err := s.stalwartClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if err := client.SetQuota(ctx, in.Name, in.Bytes); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if in.Bytes == 0 {
+		return textResult(fmt.Sprintf("Removed disk quota of %s", in.Name)), nil, nil
+	}
+	return textResult(fmt.Sprintf("Set disk quota of %s to %d bytes", in.Name, in.Bytes)), nil, nil
+}
+
+// --- stalwart_undelete_list ---
+
+type StalwartUndeleteListInput struct {
+	Account string `json:"account" jsonschema:"Account name whose deleted items to list"`
+	Limit   int    `json:"limit,omitempty" jsonschema:"Maximum items to return (default 50)"`
+}
+
+var stalwartUndeleteListTool = &mcp.Tool{
+	Name:        "stalwart_undelete_list",
+	Description: "List deleted items a Stalwart server still holds for recovery in an account, with size and deletion time. Requires Stalwart Enterprise undelete and an admin token.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleStalwartUndeleteList(ctx context.Context, _ *mcp.CallToolRequest, in StalwartUndeleteListInput) (*mcp.CallToolResult, any, error) {
+	if in.Account == "" {
+		return errorResult(invalidArgument("account is required")), nil, nil
+	}
+	limit := in.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	client, err := s.stalwartClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	list, err := client.ListDeleted(ctx, in.Account, 0, limit)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(list.Items) == 0 {
+		return textResult(fmt.Sprintf("No recoverable deleted items for %s", in.Account)), nil, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of %d recoverable item(s) for %s:\n", len(list.Items), list.Total, in.Account)
+	for _, it := range list.Items {
+		fmt.Fprintf(&sb, "\n[%s] %s, %d bytes\n", it.Hash, it.Collection, it.Size)
+		fmt.Fprintf(&sb, "  Deleted: %s\n", s.locale.dateTime(time.Unix(it.DeletedAt, 0).UTC()))
+		if it.ExpiresAt > 0 {
+			fmt.Fprintf(&sb, "  Purged after: %s\n", s.locale.dateTime(time.Unix(it.ExpiresAt, 0).UTC()))
+		}
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// --- stalwart_undelete_restore ---
+
+type StalwartUndeleteRestoreInput struct {
+	Account string   `json:"account" jsonschema:"Account name to restore into"`
+	Hashes  []string `json:"hashes" jsonschema:"Item hashes from stalwart_undelete_list"`
+}
+
+var stalwartUndeleteRestoreTool = &mcp.Tool{
+	Name:        "stalwart_undelete_restore",
+	Description: "Restore deleted items listed by stalwart_undelete_list back into the account. Requires Stalwart Enterprise undelete and an admin token.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleStalwartUndeleteRestore(ctx context.Context, _ *mcp.CallToolRequest, in StalwartUndeleteRestoreInput) (*mcp.CallToolResult, any, error) {
+	if in.Account == "" {
+		return errorResult(invalidArgument("account is required")), nil, nil
+	}
+	if len(in.Hashes) == 0 {
+		return errorResult(invalidArgument("hashes is required")), nil, nil
+	}
+
+	client, err := s.stalwartClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	text, err := restoreDeleted(ctx, client, in.Account, in.Hashes, nil)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(text), nil, nil
+}
+
+// restoreDeleted restores the deleted items of account with hashes, of
+// those keep accepts (all when nil), and describes the outcome per hash.
+func restoreDeleted(ctx context.Context, client *stalwart.Client, account string, hashes []string, keep func(*stalwart.DeletedItem) bool) (string, error) {
+	// Restoring needs each item's collection and timestamps, so look the
+	// hashes up in the deleted-items list first.
+	list, err := client.ListDeleted(ctx, account, 0, maxUndeleteScan)
+	if err != nil {
+		return "", err
+	}
+	byHash := make(map[string]*stalwart.DeletedItem, len(list.Items))
+	for _, it := range list.Items {
+		if keep == nil || keep(it) {
+			byHash[it.Hash] = it
+		}
+	}
+	var items []*stalwart.DeletedItem
+	var missing []string
+	for _, h := range hashes {
+		if it, ok := byHash[h]; ok {
+			items = append(items, it)
+		} else {
+			missing = append(missing, h)
+		}
+	}
+	if len(items) == 0 {
+		return "", notFoundError(missing, "no recoverable items match: %s", strings.Join(missing, ", "))
+	}
+
+	results, err := client.Restore(ctx, account, items)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i, it := range items {
+		status := "unknown"
+		if i < len(results) {
+			status = results[i].Type
+			if results[i].Reason != "" {
+				status += ": " + results[i].Reason
+			}
+		}
+		fmt.Fprintf(&sb, "%s (%s): %s\n", it.Hash, it.Collection, status)
+	}
+	for _, h := range missing {
+		fmt.Fprintf(&sb, "%s: not found among recoverable items\n", h)
+	}
+	return sb.String(), nil
+}
+
+// formatPrincipal renders a principal with its quota usage.
+func formatPrincipal(p *stalwart.Principal) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (%s)\n", p.Name, p.Type)
+	if p.Description != "" {
+		fmt.Fprintf(&sb, "  Description: %s\n", p.Description)
+	}
+	if len(p.Emails) > 0 {
+		fmt.Fprintf(&sb, "  Emails: %s\n", strings.Join(p.Emails, ", "))
+	}
+	switch {
+	case p.Quota > 0:
+		fmt.Fprintf(&sb, "  Quota: %d of %d bytes used (%.1f%%)\n", p.UsedQuota, p.Quota, float64(p.UsedQuota)*100/float64(p.Quota))
+	case p.UsedQuota > 0:
+		fmt.Fprintf(&sb, "  Quota: %d bytes used (unlimited)\n", p.UsedQuota)
+	}
+	if len(p.MemberOf) > 0 {
+		fmt.Fprintf(&sb, "  Member of: %s\n", strings.Join(p.MemberOf, ", "))
+	}
+	if len(p.Roles) > 0 {
+		fmt.Fprintf(&sb, "  Roles: %s\n", strings.Join(p.Roles, ", "))
+	}
+	return sb.String()
+}
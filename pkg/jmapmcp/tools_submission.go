@@ -0,0 +1,257 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/emailsubmission"
+	"github.com/mikluko/jmap/mail/identity"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_submission_set ---
+
+type EmailSubmissionSetInput struct {
+	EmailID    string `json:"email_id" jsonschema:"ID of the email to submit for delivery"`
+	IdentityID string `json:"identity_id,omitempty" jsonschema:"Sender identity ID (auto-detected if omitted)"`
+	Resend     bool   `json:"resend,omitempty" jsonschema:"Submit even though the email is no longer a draft or was submitted moments ago (default false)"`
+}
+
+var emailSubmissionSetTool = &mcp.Tool{
+	Name:        "email_submission_set",
+	Description: "Submit a draft email for delivery. Automatically moves it from Drafts to Sent and removes the draft flag. Create the draft first with email_create. Identity is auto-detected if omitted. An email that is no longer a draft, or that was submitted within the last few minutes, is refused unless resend is set, so a retried call cannot send it twice. If the server requires approval (send queue), the draft is queued instead; check outbox_list.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailSubmissionSet(ctx context.Context, _ *mcp.CallToolRequest, in EmailSubmissionSetInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	if s.outbox != nil {
+		return s.enqueueSubmission(ctx, client, accountID, jmap.ID(in.EmailID), jmap.ID(in.IdentityID), in.Resend)
+	}
+
+	if err := s.submitDraft(ctx, client, accountID, jmap.ID(in.EmailID), jmap.ID(in.IdentityID), in.Resend); err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(fmt.Sprintf("Email %s submitted for delivery", in.EmailID)), nil, nil
+}
+
+// submitDraft submits a draft via EmailSubmission/set, moving it from Drafts
+// to Sent on success. An empty identityID selects the first identity. The
+// send policy is checked against the draft's recipients and the caller's
+// hourly quota before anything is submitted, and unless resend, an email
+// that is no longer a draft or was submitted within the resend window is
+// refused. The pre-send hook, if any, may then refuse or replace the
+// message. Once submitted, the email a reply or forward draft answers is
+// marked $answered or $forwarded.
+func (s *Server) submitDraft(ctx context.Context, client JMAPClient, accountID, emailID, identityID jmap.ID, resend bool) (err error) {
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := client.Session().Capabilities[emailsubmission.URI]; !ok {
+		return capabilityMissing(client.Session(), emailsubmission.URI)
+	}
+
+	// Discovery request: fetch mailboxes (for Drafts + Sent), identities,
+	// and the draft's recipients. It cannot share a request with the
+	// submission: the send policy must see the recipients before anything
+	// is sent, and onSuccessUpdateEmail patch keys such as
+	// mailboxIds/<sentID> cannot be back-references.
+	discoverReq := &jmap.Request{Context: ctx}
+	discoverReq.Invoke(&mailbox.Get{Account: accountID})
+	discoverReq.Invoke(&identity.Get{Account: accountID})
+	discoverReq.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        []jmap.ID{emailID},
+		Properties: []string{"id", "blobId", "from", "to", "cc", "bcc", "keywords"},
+	})
+
+	discoverResp, err := client.Do(discoverReq)
+	if err != nil {
+		return err
+	}
+
+	if len(discoverResp.Responses) < 3 {
+		return fmt.Errorf("expected 3 discovery responses, got %d", len(discoverResp.Responses))
+	}
+
+	// Find Drafts and Sent mailbox IDs.
+	var draftsID, sentID jmap.ID
+	switch args := discoverResp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			switch mb.Role {
+			case mailbox.RoleDrafts:
+				draftsID = mb.ID
+			case mailbox.RoleSent:
+				sentID = mb.ID
+			}
+		}
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected mailbox response type: %T", args)
+	}
+
+	if draftsID == "" {
+		return fmt.Errorf("no Drafts mailbox found")
+	}
+	if sentID == "" {
+		return fmt.Errorf("no Sent mailbox found")
+	}
+
+	// Resolve sender identity.
+	var identityEmail string
+	switch args := discoverResp.Responses[1].Args.(type) {
+	case *identity.GetResponse:
+		if identityID == "" {
+			if len(args.List) == 0 {
+				return fmt.Errorf("no sender identities available")
+			}
+			sender, err := s.sendPolicy.senderIdentity(args.List)
+			if err != nil {
+				return err
+			}
+			identityID = sender.ID
+		}
+		for _, id := range args.List {
+			if id.ID == identityID {
+				identityEmail = id.Email
+				if err := s.sendPolicy.checkSender(id.Email); err != nil {
+					return err
+				}
+			}
+		}
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected identity response type: %T", args)
+	}
+
+	var draft *email.Email
+	switch args := discoverResp.Responses[2].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return notFoundError([]jmap.ID{emailID}, "email not found: %s", emailID)
+		}
+		draft = args.List[0]
+		if !resend {
+			if err := checkStillDraft(draft); err != nil {
+				return err
+			}
+		}
+		if err := s.sendPolicy.checkRecipients(draftRecipients(draft)); err != nil {
+			return err
+		}
+		for _, a := range draft.From {
+			if err := s.sendPolicy.checkSender(a.Email); err != nil {
+				return err
+			}
+		}
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected email response type: %T", args)
+	}
+
+	envelope, err := submissionEnvelope(client.Session(), accountID, identityEmail, draftRecipients(draft))
+	if err != nil {
+		return err
+	}
+
+	owner := s.ownerOf(token)
+	if err := s.submissions.reserve(owner, emailID, s.resendWindow, time.Now(), resend); err != nil {
+		return err
+	}
+	defer func(reserved jmap.ID) {
+		if err != nil {
+			s.submissions.release(owner, reserved)
+		}
+	}(emailID)
+	// The rate limit is checked before the hook, which may replace the
+	// draft: a refused send must leave the draft as it was.
+	sentAt := time.Now()
+	if err := s.sendPolicy.reserveSend(owner, sentAt); err != nil {
+		return err
+	}
+	draftID := emailID
+	if emailID, err = s.applyPreSendHook(ctx, client, accountID, emailID, draft.BlobID, draftsID, draftRecipients(draft)); err != nil {
+		// Nothing was submitted, so the attempt does not count.
+		s.sendPolicy.releaseSend(owner, sentAt)
+		return err
+	}
+	if emailID != draftID {
+		// The hook destroyed draft draftID; a failure from here on must
+		// name the replacement left in Drafts.
+		defer func(replacement jmap.ID) {
+			if err != nil {
+				err = fmt.Errorf("%w (the pre-send hook replaced draft %s with %s, which is still in Drafts)", err, draftID, replacement)
+			}
+		}(emailID)
+	}
+
+	// Submit the email for delivery.
+	submitReq := &jmap.Request{Context: ctx}
+	submitReq.Invoke(&emailsubmission.Set{
+		Account: accountID,
+		Create: map[jmap.ID]*emailsubmission.EmailSubmission{
+			"send": {
+				IdentityID: identityID,
+				EmailID:    emailID,
+				Envelope:   envelope,
+			},
+		},
+		OnSuccessUpdateEmail: map[jmap.ID]jmap.Patch{
+			"#send": {
+				"mailboxIds/" + string(draftsID): nil,
+				"mailboxIds/" + string(sentID):   true,
+				"keywords/$draft":                nil,
+			},
+		},
+	})
+
+	submitResp, err := client.Do(submitReq)
+	if err != nil {
+		return err
+	}
+
+	if len(submitResp.Responses) == 0 {
+		return fmt.Errorf("empty response for EmailSubmission/set")
+	}
+
+	switch args := submitResp.Responses[0].Args.(type) {
+	case *emailsubmission.SetResponse:
+		if se, ok := args.NotCreated["send"]; ok {
+			return setFailure("submission failed", se)
+		}
+		s.markDraftOrigin(ctx, client, accountID, draftID)
+		return nil
+	case *jmap.MethodError:
+		return args
+	default:
+		return fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// draftRecipients returns all envelope recipients of a draft (To, CC, BCC).
+func draftRecipients(e *email.Email) []*mail.Address {
+	return append(append(append([]*mail.Address(nil), e.To...), e.CC...), e.BCC...)
+}
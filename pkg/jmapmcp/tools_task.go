@@ -0,0 +1,401 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/tasks"
+)
+
+const (
+	defaultTaskListLimit = 50
+	maxTaskListLimit     = 500
+)
+
+// --- task_create ---
+
+type TaskCreateInput struct {
+	Title       string `json:"title" jsonschema:"Task title"`
+	Due         string `json:"due,omitempty" jsonschema:"Due as local time YYYY-MM-DDTHH:MM in time_zone, RFC 3339, or YYYY-MM-DD for a due date"`
+	TimeZone    string `json:"time_zone,omitempty" jsonschema:"IANA time zone of the due time, e.g. Europe/Berlin (default UTC)"`
+	Priority    int    `json:"priority,omitempty" jsonschema:"Priority from 1 (highest) to 9 (lowest); 0 leaves it undefined"`
+	Description string `json:"description,omitempty" jsonschema:"Task description"`
+	TaskListID  string `json:"task_list_id,omitempty" jsonschema:"Task list to create the task in (default: the default task list)"`
+}
+
+var taskCreateTool = &mcp.Tool{
+	Name:        "task_create",
+	Description: "Create a task (Task/set) with a title, optional due date or time, priority, and description. Requires server support for urn:ietf:params:jmap:tasks. To turn a message into a task, use email_to_task instead.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleTaskCreate(ctx context.Context, _ *mcp.CallToolRequest, in TaskCreateInput) (*mcp.CallToolResult, any, error) {
+	if in.Title == "" {
+		return errorResult(invalidArgument("title is required")), nil, nil
+	}
+	if in.Priority < 0 || in.Priority > 9 {
+		return errorResult(invalidArgument("priority must be between 0 and 9")), nil, nil
+	}
+	loc, err := eventLocation(in.TimeZone)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	var due time.Time
+	allDay := false
+	if in.Due != "" {
+		if due, allDay, err = parseEventStart(in.Due, loc); err != nil {
+			return errorResult(err), nil, nil
+		}
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID, err := tasksAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	text, err := createTask(ctx, client, accountID, in.TaskListID, newTask(in.Title, in.Description, in.Priority, due, allDay, loc))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(text), nil, nil
+}
+
+// --- task_list ---
+
+type TaskListInput struct {
+	TaskListID       string `json:"task_list_id,omitempty" jsonschema:"Only tasks in this task list (default: all lists)"`
+	IncludeCompleted bool   `json:"include_completed,omitempty" jsonschema:"Also list completed, failed, and cancelled tasks"`
+	Limit            int    `json:"limit,omitempty" jsonschema:"Maximum number of tasks to return (default 50, max 500)"`
+}
+
+var taskListTool = &mcp.Tool{
+	Name:        "task_list",
+	Description: "List tasks (Task/query + Task/get), open ones only unless include_completed is set, ordered by due date with undated tasks last. Each line shows the task ID, title, due, priority, progress, and task list. Requires server support for urn:ietf:params:jmap:tasks.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleTaskList(ctx context.Context, _ *mcp.CallToolRequest, in TaskListInput) (*mcp.CallToolResult, any, error) {
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultTaskListLimit
+	}
+	if limit > maxTaskListLimit {
+		limit = maxTaskListLimit
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID, err := tasksAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	// Progress and due ordering are applied here: servers differ in the
+	// Task/query filters and sorts they support.
+	query := &tasks.Query{Account: accountID}
+	if in.TaskListID != "" {
+		query.Filter = &tasks.FilterCondition{InTaskLists: []jmap.ID{jmap.ID(in.TaskListID)}}
+	}
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&tasks.TaskListGet{Account: accountID, Properties: []string{"id", "name", "isDefault"}})
+	queryCallID := req.Invoke(query)
+	req.Invoke(&tasks.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Task/query", Path: "/ids"},
+		Properties:   []string{"id", "taskListId", "title", "due", "timeZone", "showWithoutTime", "progress", "priority"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	listNames := make(map[jmap.ID]string)
+	var list []*tasks.Task
+	for _, inv := range callResponses(req, resp) {
+		switch args := inv.Args.(type) {
+		case *tasks.TaskListGetResponse:
+			for _, l := range args.List {
+				listNames[l.ID] = l.Name
+			}
+		case *tasks.QueryResponse:
+		case *tasks.GetResponse:
+			list = args.List
+		case *jmap.MethodError:
+			return errorResult(args), nil, nil
+		default:
+			return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+		}
+	}
+
+	var open []*tasks.Task
+	for _, t := range list {
+		if in.IncludeCompleted || !taskDone(t) {
+			open = append(open, t)
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		a, b := open[i], open[j]
+		if (a.Due == "") != (b.Due == "") {
+			return b.Due == ""
+		}
+		if a.Due != b.Due {
+			return a.Due < b.Due
+		}
+		return a.Title < b.Title
+	})
+
+	var sb strings.Builder
+	kind := "open"
+	if in.IncludeCompleted {
+		kind = "all"
+	}
+	if len(open) > limit {
+		fmt.Fprintf(&sb, "Tasks (%s): %d, showing %d\n", kind, len(open), limit)
+		open = open[:limit]
+	} else {
+		fmt.Fprintf(&sb, "Tasks (%s): %d\n", kind, len(open))
+	}
+	for _, t := range open {
+		fmt.Fprintf(&sb, "- [id: %s] %s", t.ID, t.Title)
+		if t.Due != "" {
+			fmt.Fprintf(&sb, "  due %s", formatTaskDue(t))
+		}
+		if t.Priority > 0 {
+			fmt.Fprintf(&sb, "  priority %d", t.Priority)
+		}
+		if t.Progress != "" && t.Progress != "needs-action" {
+			fmt.Fprintf(&sb, "  %s", t.Progress)
+		}
+		if name := listNames[t.TaskListID]; name != "" {
+			fmt.Fprintf(&sb, "  (%s)", name)
+		}
+		sb.WriteString("\n")
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// --- email_to_task ---
+
+type EmailToTaskInput struct {
+	EmailID    string `json:"email_id" jsonschema:"ID of the email to turn into a task"`
+	TaskListID string `json:"task_list_id,omitempty" jsonschema:"Task list to create the task in (default: the default task list)"`
+	TimeZone   string `json:"time_zone,omitempty" jsonschema:"IANA time zone for times written in the message and for the due time (default UTC)"`
+	Title      string `json:"title,omitempty" jsonschema:"Task title (default: the email subject)"`
+	Due        string `json:"due,omitempty" jsonschema:"Override the extracted due: YYYY-MM-DDTHH:MM in time_zone, RFC 3339, or YYYY-MM-DD"`
+	NoDue      bool   `json:"no_due,omitempty" jsonschema:"Create the task without a due date even if the message mentions one"`
+	Priority   int    `json:"priority,omitempty" jsonschema:"Priority from 1 (highest) to 9 (lowest)"`
+}
+
+var emailToTaskTool = &mcp.Tool{
+	Name:        "email_to_task",
+	Description: "Turn an email into a task. The title defaults to the subject; the due date is the first date after a cue like \"due\", \"by\", or \"deadline\" in the subject or text, else the first date mentioned (a time next to it makes it a due time). Without any date the task has no due date. The task description links back to the email ID. Requires server support for urn:ietf:params:jmap:tasks.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailToTask(ctx context.Context, _ *mcp.CallToolRequest, in EmailToTaskInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+	if in.Priority < 0 || in.Priority > 9 {
+		return errorResult(invalidArgument("priority must be between 0 and 9")), nil, nil
+	}
+	loc, err := eventLocation(in.TimeZone)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	taskAccount, err := tasksAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	e, err := fetchSourceEmail(ctx, client, accountID, in.EmailID)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	title := in.Title
+	if title == "" {
+		title = e.Subject
+	}
+	if title == "" {
+		title = "(no subject)"
+	}
+
+	var due time.Time
+	allDay, source := false, "none found"
+	switch {
+	case in.NoDue:
+		source = "not set"
+	case in.Due != "":
+		if due, allDay, err = parseEventStart(in.Due, loc); err != nil {
+			return errorResult(err), nil, nil
+		}
+		source = "given"
+	default:
+		var ok bool
+		if due, allDay, ok = extractDueFromText(sourceEmailText(e), receivedAt(e), loc); ok {
+			source = "message text"
+		}
+	}
+
+	text, err := createTask(ctx, client, taskAccount, in.TaskListID, newTask(title, sourceEmailNote(e), in.Priority, due, allDay, loc))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	return textResult(fmt.Sprintf("%sDue from: %s\n", text, source)), nil, nil
+}
+
+// tasksAccountID returns the primary tasks account, or an error if the
+// server does not advertise JMAP Tasks.
+func tasksAccountID(client JMAPClient) (jmap.ID, error) {
+	if _, ok := client.Session().RawCapabilities[tasks.URI]; !ok {
+		return "", capabilityMissing(client.Session(), tasks.URI)
+	}
+	id := client.Session().PrimaryAccounts[tasks.URI]
+	if id == "" {
+		return "", fmt.Errorf("no primary tasks account")
+	}
+	return id, nil
+}
+
+func taskDone(t *tasks.Task) bool {
+	switch t.Progress {
+	case "completed", "failed", "cancelled":
+		return true
+	}
+	return false
+}
+
+// newTask builds a JSCalendar task. A zero due leaves it undated; an
+// allDay due is a floating date shown without time.
+func newTask(title, description string, priority int, due time.Time, allDay bool, loc *time.Location) *tasks.Task {
+	t := &tasks.Task{
+		Type:        "Task",
+		Title:       title,
+		Description: description,
+		Priority:    priority,
+		Progress:    "needs-action",
+	}
+	switch {
+	case due.IsZero():
+	case allDay:
+		t.Due = due.Format("2006-01-02") + "T00:00:00"
+		t.ShowWithoutTime = true
+	default:
+		tz := loc.String()
+		t.TimeZone = &tz
+		t.Due = due.In(loc).Format("2006-01-02T15:04:05")
+	}
+	return t
+}
+
+func formatTaskDue(t *tasks.Task) string {
+	if t.ShowWithoutTime || len(t.Due) < 16 {
+		return t.Due[:min(10, len(t.Due))]
+	}
+	due := strings.Replace(t.Due[:16], "T", " ", 1)
+	if t.TimeZone != nil {
+		due += " " + *t.TimeZone
+	}
+	return due
+}
+
+// createTask creates t in taskListID, or in the default task list (else
+// the first) when taskListID is empty, and describes the result.
+func createTask(ctx context.Context, client JMAPClient, accountID jmap.ID, taskListID string, t *tasks.Task) (string, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&tasks.TaskListGet{Account: accountID, Properties: []string{"id", "name", "isDefault"}})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for TaskList/get")
+	}
+
+	var list *tasks.TaskList
+	switch args := resp.Responses[0].Args.(type) {
+	case *tasks.TaskListGetResponse:
+		for _, l := range args.List {
+			switch {
+			case taskListID != "":
+				if string(l.ID) == taskListID {
+					list = l
+				}
+			case list == nil || l.IsDefault && !list.IsDefault:
+				list = l
+			}
+		}
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+	if list == nil {
+		if taskListID != "" {
+			return "", notFoundError([]string{taskListID}, "task list %s not found", taskListID)
+		}
+		return "", fmt.Errorf("account has no task lists")
+	}
+	t.TaskListID = list.ID
+
+	req = &jmap.Request{Context: ctx}
+	req.Invoke(&tasks.Set{Account: accountID, Create: map[jmap.ID]*tasks.Task{"task": t}})
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 {
+		return "", fmt.Errorf("empty response for Task/set")
+	}
+
+	switch args := resp.Responses[0].Args.(type) {
+	case *tasks.SetResponse:
+		if se, ok := args.NotCreated["task"]; ok {
+			return "", setFailure("task creation failed", se)
+		}
+		var sb strings.Builder
+		if created, ok := args.Created["task"]; ok {
+			fmt.Fprintf(&sb, "Created task [id: %s] in task list %s\n", created.ID, list.Name)
+		} else {
+			fmt.Fprintf(&sb, "Created task in task list %s\n", list.Name)
+		}
+		fmt.Fprintf(&sb, "Title: %s\n", t.Title)
+		if t.Due != "" {
+			fmt.Fprintf(&sb, "Due: %s\n", formatTaskDue(t))
+		}
+		if t.Priority > 0 {
+			fmt.Fprintf(&sb, "Priority: %d\n", t.Priority)
+		}
+		return sb.String(), nil
+	case *jmap.MethodError:
+		return "", args
+	default:
+		return "", fmt.Errorf("unexpected response type: %T", args)
+	}
+}
@@ -0,0 +1,121 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapext/tasks"
+)
+
+func TestExtractDueFromText(t *testing.T) {
+	ref := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name, text string
+		due        string
+		allDay     bool
+		ok         bool
+	}{
+		{"cue wins", "Meeting notes from January 3.\nPlease send the report by January 10 5pm.", "2025-01-10T17:00", false, true},
+		{"deadline date", "Budget review\nDeadline: 2025-02-01", "2025-02-01T00:00", true, true},
+		{"first date", "Conference on 14 March; slides welcome", "2025-03-14T00:00", true, true},
+		{"none", "Could you have a look when you have time?", "", false, false},
+	}
+	for _, tt := range tests {
+		due, allDay, ok := extractDueFromText(tt.text, ref, time.UTC)
+		got := ""
+		if ok {
+			got = due.Format("2006-01-02T15:04")
+		}
+		if ok != tt.ok || got != tt.due || allDay != tt.allDay {
+			t.Errorf("%s: got %q all day %v ok %v", tt.name, got, allDay, ok)
+		}
+	}
+}
+
+func TestTaskCreateAndList(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.AddCapability(string(tasks.URI), nil)
+	fake.Add("TaskList", map[string]any{"id": "inbox", "name": "Inbox", "isDefault": true})
+	fake.Add("TaskList", map[string]any{"id": "home", "name": "Home"})
+	fake.Add("Task", map[string]any{"id": "t1", "taskListId": "home", "title": "Water plants", "progress": "needs-action"})
+	fake.Add("Task", map[string]any{"id": "t2", "taskListId": "home", "title": "Pay rent", "due": "2025-01-31T00:00:00", "showWithoutTime": true})
+	fake.Add("Task", map[string]any{"id": "t3", "taskListId": "inbox", "title": "Old chore", "progress": "completed"})
+
+	res, _, _ := s.handleTaskCreate(context.Background(), nil, TaskCreateInput{
+		Title:    "Send slides",
+		Due:      "2025-01-08T17:00",
+		TimeZone: "Europe/Berlin",
+		Priority: 1,
+	})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	if out := resultText(res); !strings.Contains(out, "in task list Inbox") || !strings.Contains(out, "Due: 2025-01-08 17:00 Europe/Berlin") {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	var created map[string]any
+	for _, id := range fake.Objects("Task") {
+		if obj := fake.Object("Task", id); obj["title"] == "Send slides" {
+			created = obj
+		}
+	}
+	if created["@type"] != "Task" || created["taskListId"] != "inbox" || created["due"] != "2025-01-08T17:00:00" || created["progress"] != "needs-action" {
+		t.Errorf("unexpected task: %v", created)
+	}
+
+	res, _, _ = s.handleTaskList(context.Background(), nil, TaskListInput{})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	sendAt, rentAt, plantsAt := strings.Index(out, "Send slides"), strings.Index(out, "Pay rent"), strings.Index(out, "Water plants")
+	if !strings.HasPrefix(out, "Tasks (open): 3\n") || sendAt < 0 || !(sendAt < rentAt && rentAt < plantsAt) {
+		t.Errorf("unexpected listing:\n%s", out)
+	}
+	if !strings.Contains(out, "Pay rent  due 2025-01-31  (Home)") || strings.Contains(out, "Old chore") {
+		t.Errorf("unexpected listing:\n%s", out)
+	}
+
+	res, _, _ = s.handleTaskList(context.Background(), nil, TaskListInput{TaskListID: "inbox", IncludeCompleted: true})
+	if out := resultText(res); !strings.HasPrefix(out, "Tasks (all): 2\n") || !strings.Contains(out, "Old chore  completed  (Inbox)") {
+		t.Errorf("unexpected listing:\n%s", out)
+	}
+}
+
+func TestEmailToTask(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.AddCapability(string(tasks.URI), nil)
+	fake.Add("TaskList", map[string]any{"id": "inbox", "name": "Inbox"})
+	addEmail(fake, "m1", "Expense report", "Hi,\n\nplease submit your expenses by January 15.\n", 6)
+	addEmail(fake, "m2", "Ideas", "Some thoughts for later.", 7)
+
+	res, _, _ := s.handleEmailToTask(context.Background(), nil, EmailToTaskInput{EmailID: "m1"})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	for _, want := range []string{"Title: Expense report", "Due: 2025-01-15", "Due from: message text"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	task := fake.Object("Task", fake.Objects("Task")[0])
+	if desc, _ := task["description"].(string); !strings.Contains(desc, "Created from email m1") {
+		t.Errorf("description = %q", desc)
+	}
+
+	res, _, _ = s.handleEmailToTask(context.Background(), nil, EmailToTaskInput{EmailID: "m2", Title: "Read ideas"})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Title: Read ideas") || strings.Contains(out, "Due: 2") || !strings.Contains(out, "Due from: none found") {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+}
+
+func TestTaskToolsWithoutCapability(t *testing.T) {
+	s, _ := newFakeServer(t)
+	res, _, _ := s.handleTaskList(context.Background(), nil, TaskListInput{})
+	if !res.IsError || !strings.Contains(resultText(res), "does not support JMAP tasks") {
+		t.Errorf("unexpected result: %s", resultText(res))
+	}
+}
@@ -0,0 +1,140 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- email_trace ---
+
+type EmailTraceInput struct {
+	EmailID string `json:"email_id" jsonschema:"ID of the email whose delivery to trace"`
+}
+
+var emailTraceTool = &mcp.Tool{
+	Name:        "email_trace",
+	Description: "Trace how an email was delivered: its Received headers as an ordered hop list (sending host and IP, receiving host, protocol, timestamp) with the delay each hop added, from the Date header to arrival in the mailbox, and the slowest hop. Use it to answer why a message arrived late.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleEmailTrace(ctx context.Context, _ *mcp.CallToolRequest, in EmailTraceInput) (*mcp.CallToolResult, any, error) {
+	if in.EmailID == "" {
+		return errorResult(invalidArgument("email_id is required")), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        []jmap.ID{jmap.ID(in.EmailID)},
+		Properties: []string{"id", "blobId", "subject", "from", "sentAt", "receivedAt", "headers"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(resp.Responses) == 0 {
+		return errorResult(fmt.Errorf("empty response for Email/get")), nil, nil
+	}
+
+	var e *email.Email
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 || len(args.List) == 0 {
+			return errorResult(notFoundError([]string{in.EmailID}, "email not found: %s", in.EmailID)), nil, nil
+		}
+		e = args.List[0]
+		s.fillRawHeaders(ctx, client, s.quirksFor(client), accountID, args.List)
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+
+	hops := deliveryChain(e.Headers)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Delivery of %q [id: %s]", e.Subject, e.ID)
+	if len(e.From) > 0 {
+		fmt.Fprintf(&sb, " from %s", formatAddresses(e.From))
+	}
+	fmt.Fprintf(&sb, ": %d hop(s)\n\n", len(hops))
+
+	// Walk sent, each hop, and arrival, measuring from the last known time.
+	var prev time.Time
+	var slowest time.Duration
+	slowestAt := ""
+	step := func(label string, t time.Time) {
+		delay := ""
+		if !t.IsZero() && !prev.IsZero() {
+			d := t.Sub(prev)
+			delay = " (+" + formatHopDelay(d) + ")"
+			if d > slowest {
+				slowest, slowestAt = d, label
+			}
+		}
+		when := "time unknown"
+		if !t.IsZero() {
+			when = t.UTC().Format(time.RFC3339)
+			prev = t
+		}
+		fmt.Fprintf(&sb, "%s%s: %s\n", when, delay, label)
+	}
+	if e.SentAt != nil {
+		step("sent (Date header)", *e.SentAt)
+	}
+	for i, h := range hops {
+		label := fmt.Sprintf("hop %d", i+1)
+		switch {
+		case h.Raw != "":
+			label += ": " + h.Raw
+		default:
+			if h.By != "" {
+				label += " by " + h.By
+			}
+			if h.From != "" {
+				label += " from " + h.From
+				if h.IP != "" && h.IP != strings.Trim(h.From, "[]") {
+					label += " [" + h.IP + "]"
+				}
+			}
+			if h.With != "" {
+				label += " with " + h.With
+			}
+		}
+		step(label, h.Time)
+	}
+	if e.ReceivedAt != nil {
+		step("in mailbox", *e.ReceivedAt)
+	}
+
+	switch {
+	case len(hops) == 0:
+		sb.WriteString("\nNo Received headers: the message was delivered locally or the server strips them.\n")
+	case e.SentAt != nil && e.ReceivedAt != nil:
+		fmt.Fprintf(&sb, "\nTotal: %s from Date header to mailbox", formatHopDelay(e.ReceivedAt.Sub(*e.SentAt)))
+		if slowestAt != "" {
+			fmt.Fprintf(&sb, "; slowest step %s, ending at %s", formatHopDelay(slowest), slowestAt)
+		}
+		sb.WriteString("\n")
+	}
+	return textResult(sb.String()), nil, nil
+}
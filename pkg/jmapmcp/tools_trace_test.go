@@ -0,0 +1,41 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEmailTrace(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Invoice",
+		"mailboxIds": map[string]any{"inbox": true},
+		"sentAt":     "2026-01-06T09:00:00Z",
+		"receivedAt": "2026-01-06T11:03:10Z",
+		"headers": []any{
+			map[string]any{"name": "Received", "value": " from relay.example.net (relay.example.net [198.51.100.7]) by mx.example.org with ESMTPS; Tue, 6 Jan 2026 11:03:05 +0000"},
+			map[string]any{"name": "Received", "value": " from laptop ([192.0.2.10]) by smtp.example.com with ESMTPSA; Tue, 6 Jan 2026 09:00:02 +0000"},
+			map[string]any{"name": "Subject", "value": " Invoice"},
+		},
+	})
+
+	res, _, _ := s.handleEmailTrace(context.Background(), nil, EmailTraceInput{EmailID: "m1"})
+	out := resultText(res)
+	for _, want := range []string{
+		"2 hop(s)",
+		"2026-01-06T09:00:02Z (+2s): hop 1 by smtp.example.com from laptop [192.0.2.10] with ESMTPSA",
+		"2026-01-06T11:03:05Z (+2h03m): hop 2 by mx.example.org from relay.example.net [198.51.100.7] with ESMTPS",
+		"2026-01-06T11:03:10Z (+5s): in mailbox",
+		"Total: 2h03m from Date header to mailbox; slowest step 2h03m, ending at hop 2",
+	} {
+		if res.IsError || !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	res, _, _ = s.handleEmailTrace(context.Background(), nil, EmailTraceInput{EmailID: "nope"})
+	if !res.IsError {
+		t.Errorf("unknown email: %s", resultText(res))
+	}
+}
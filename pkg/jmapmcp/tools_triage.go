@@ -0,0 +1,396 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	maxTriageBatch           = 50
+	defaultTriageHistory     = 20
+	triageHistoryCeiling     = 100
+	triageDominantShare      = 0.6 // share of history in one place to suggest filing there
+	triageReplyShare         = 0.3 // share of history answered to suggest a reply
+	triageUnreadShare        = 0.8 // share of history left unread to suggest archiving
+	defaultMaxCallsInRequest = 16  // when the server does not advertise maxCallsInRequest
+)
+
+// --- email_triage_suggest ---
+
+type EmailTriageSuggestInput struct {
+	EmailIDs []string `json:"email_ids" jsonschema:"IDs of the new emails to triage (max 50)"`
+	History  int      `json:"history,omitempty" jsonschema:"Past messages per sender or mailing list to learn from (default 20, max 100)"`
+}
+
+var emailTriageSuggestTool = &mcp.Tool{
+	Name:        "email_triage_suggest",
+	Description: "Suggest an action for each email in a batch from how past messages of the same mailing list (List-Id) or sender were handled: archive, delete, file to a mailbox, reply, or keep. Each suggestion gives its evidence and the tool call that applies it (email_move, email_delete, email_reply). Changes nothing; review and apply the suggestions with those tools.",
+	Annotations: readOnlyAnnotations,
+}
+
+// triageKey identifies whose history informs a suggestion: a mailing list
+// when the email carries List-Id and the server can filter on it, else the
+// sender.
+type triageKey struct {
+	listID string
+	from   string
+}
+
+func (k triageKey) String() string {
+	switch {
+	case k.listID != "":
+		return "list " + k.listID
+	case k.from != "":
+		return k.from
+	}
+	return "(unknown sender)"
+}
+
+func (k triageKey) filter() *email.FilterCondition {
+	if k.listID != "" {
+		return &email.FilterCondition{Header: []string{"List-Id", k.listID}}
+	}
+	return &email.FilterCondition{From: k.from}
+}
+
+// triageSuggestion is the action proposed for one email.
+type triageSuggestion struct {
+	action   string // archive, delete, file, reply, keep, review
+	target   *mailbox.Mailbox
+	evidence string
+}
+
+func (s *Server) handleEmailTriageSuggest(ctx context.Context, _ *mcp.CallToolRequest, in EmailTriageSuggestInput) (*mcp.CallToolResult, any, error) {
+	if len(in.EmailIDs) == 0 {
+		return errorResult(invalidArgument("email_ids is required")), nil, nil
+	}
+	if len(in.EmailIDs) > maxTriageBatch {
+		return errorResult(fmt.Errorf("at most %d email_ids per call", maxTriageBatch)), nil, nil
+	}
+	history := in.History
+	if history <= 0 {
+		history = defaultTriageHistory
+	}
+	history = min(history, triageHistoryCeiling)
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&mailbox.Get{Account: accountID, Properties: []string{"id", "name", "role"}})
+	req.Invoke(&email.Get{
+		Account:    accountID,
+		IDs:        toJMAPIDSlice(in.EmailIDs),
+		Properties: []string{"id", "from", "subject", "receivedAt", "mailboxIds", "headers"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) < 2 {
+		return errorResult(fmt.Errorf("expected 2 responses, got %d", len(resp.Responses))), nil, nil
+	}
+
+	mailboxes := make(map[jmap.ID]*mailbox.Mailbox)
+	switch args := resp.Responses[0].Args.(type) {
+	case *mailbox.GetResponse:
+		for _, mb := range args.List {
+			mailboxes[mb.ID] = mb
+		}
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected mailbox response type: %T", args)), nil, nil
+	}
+
+	var batch []*email.Email
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		if len(args.NotFound) > 0 {
+			return errorResult(notFoundError(args.NotFound, "emails not found: %v", args.NotFound)), nil, nil
+		}
+		batch = args.List
+	case *jmap.MethodError:
+		return errorResult(args), nil, nil
+	default:
+		return errorResult(fmt.Errorf("unexpected email response type: %T", args)), nil, nil
+	}
+
+	q := s.quirksFor(client)
+	keys := triageKeys(batch, !q.has(quirkNoHeaderFilter))
+	past, err := triageHistory(ctx, client, accountID, keys, history)
+	if isUnsupportedFilterErr(err) {
+		// The List-Id header filter is refused: learn it and fall back to
+		// sender history.
+		q.learn(quirkNoHeaderFilter)
+		keys = triageKeys(batch, false)
+		past, err = triageHistory(ctx, client, accountID, keys, history)
+	}
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	inBatch := make(map[jmap.ID]bool, len(batch))
+	for _, e := range batch {
+		inBatch[e.ID] = true
+	}
+
+	// Mail from VIPs comes first and is never suggested for clearing away.
+	vips := s.vipsOf(client)
+	slices.SortStableFunc(batch, func(a, b *email.Email) int { return vipFirst(a.From, b.From, vips) })
+
+	var sb strings.Builder
+	for i, e := range batch {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		key := keys[e.ID]
+		var prior []*email.Email
+		for _, p := range past[key] {
+			if !inBatch[p.ID] {
+				prior = append(prior, p)
+			}
+		}
+		sug := suggestTriage(key, prior, mailboxes)
+		vip := ""
+		if senderListed(e.From, vips) {
+			vip = "  [VIP]"
+			sug = keepVIP(sug)
+		}
+		fmt.Fprintf(&sb, "%s  %s  %s%s\n", e.ID, formatAddresses(e.From), e.Subject, vip)
+		fmt.Fprintf(&sb, "  Suggest: %s — %s\n", describeTriage(sug), sug.evidence)
+		if apply := triageApply(sug, e.ID, mailboxes); apply != "" {
+			fmt.Fprintf(&sb, "  Apply: %s\n", apply)
+		}
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// triageKeys picks the history key of each email.
+func triageKeys(batch []*email.Email, useLists bool) map[jmap.ID]triageKey {
+	keys := make(map[jmap.ID]triageKey, len(batch))
+	for _, e := range batch {
+		var k triageKey
+		if useLists {
+			k.listID = listID(e)
+		}
+		if k.listID == "" && len(e.From) > 0 {
+			k.from = strings.ToLower(e.From[0].Email)
+		}
+		keys[e.ID] = k
+	}
+	return keys
+}
+
+// listID returns the email's List-Id identifier (the part in angle
+// brackets), or "".
+func listID(e *email.Email) string {
+	for _, h := range e.Headers {
+		if !strings.EqualFold(h.Name, "List-Id") {
+			continue
+		}
+		v := strings.TrimSpace(h.Value)
+		if i := strings.LastIndexByte(v, '<'); i >= 0 {
+			if j := strings.IndexByte(v[i:], '>'); j > 0 {
+				return v[i+1 : i+j]
+			}
+		}
+		return v
+	}
+	return ""
+}
+
+// triageHistory fetches the most recent messages for each key, packing as
+// many query/get pairs into each request as the server allows.
+func triageHistory(ctx context.Context, client JMAPClient, accountID jmap.ID, keys map[jmap.ID]triageKey, history int) (map[triageKey][]*email.Email, error) {
+	var unique []triageKey
+	for _, k := range keys {
+		if k != (triageKey{}) && !slices.Contains(unique, k) {
+			unique = append(unique, k)
+		}
+	}
+	slices.SortFunc(unique, func(a, b triageKey) int { return strings.Compare(a.String(), b.String()) })
+
+	perTrip := defaultMaxCallsInRequest
+	if c, ok := client.Session().Capabilities[jmap.CoreURI].(*core.Core); ok && c.MaxCallsInRequest > 0 {
+		perTrip = int(c.MaxCallsInRequest)
+	}
+	perTrip = max(perTrip/2, 1)
+
+	past := make(map[triageKey][]*email.Email, len(unique))
+	for start := 0; start < len(unique); start += perTrip {
+		trip := unique[start:min(start+perTrip, len(unique))]
+		req := &jmap.Request{Context: ctx}
+		for _, k := range trip {
+			queryCallID := req.Invoke(&email.Query{
+				Account: accountID,
+				Filter:  k.filter(),
+				Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+				Limit:   uint64(history),
+			})
+			req.Invoke(&email.Get{
+				Account:      accountID,
+				ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+				Properties:   []string{"id", "mailboxIds", "keywords"},
+			})
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Responses) < 2*len(trip) {
+			return nil, fmt.Errorf("expected %d history responses, got %d", 2*len(trip), len(resp.Responses))
+		}
+		for i, k := range trip {
+			if me, ok := resp.Responses[2*i].Args.(*jmap.MethodError); ok {
+				return nil, me
+			}
+			switch args := resp.Responses[2*i+1].Args.(type) {
+			case *email.GetResponse:
+				past[k] = args.List
+			case *jmap.MethodError:
+				return nil, args
+			default:
+				return nil, fmt.Errorf("unexpected response type: %T", args)
+			}
+		}
+	}
+	return past, nil
+}
+
+// isUnsupportedFilterErr reports whether err is an unsupportedFilter
+// method error.
+func isUnsupportedFilterErr(err error) bool {
+	me, ok := err.(*jmap.MethodError)
+	return ok && isUnsupportedFilter(me)
+}
+
+// suggestTriage proposes an action from where prior messages ended up and
+// whether they were read or answered. Filing wins when most of the history
+// sits in one place; otherwise frequent replies suggest replying and mostly
+// unread history suggests archiving.
+func suggestTriage(key triageKey, prior []*email.Email, mailboxes map[jmap.ID]*mailbox.Mailbox) triageSuggestion {
+	n := len(prior)
+	if n == 0 {
+		return triageSuggestion{action: "review", evidence: fmt.Sprintf("no earlier messages from %s", key)}
+	}
+
+	var answered, unread int
+	where := make(map[jmap.ID]int)
+	for _, p := range prior {
+		if p.Keywords["$answered"] {
+			answered++
+		}
+		if !p.Keywords["$seen"] {
+			unread++
+		}
+		for id := range p.MailboxIDs {
+			where[id]++
+		}
+	}
+	var top jmap.ID
+	for id, k := range where {
+		if k > where[top] || k == where[top] && id < top {
+			top = id
+		}
+	}
+	share := float64(where[top]) / float64(n)
+	topBox := mailboxes[top]
+	of := func(k int, what string) string {
+		return fmt.Sprintf("%d of %d earlier messages from %s %s", k, n, key, what)
+	}
+
+	switch {
+	case float64(answered)/float64(n) >= triageReplyShare:
+		return triageSuggestion{action: "reply", evidence: of(answered, "were answered")}
+	case topBox != nil && share >= triageDominantShare:
+		switch topBox.Role {
+		case mailbox.RoleTrash:
+			return triageSuggestion{action: "delete", evidence: of(where[top], "were deleted")}
+		case mailbox.RoleJunk:
+			return triageSuggestion{action: "file", target: topBox, evidence: of(where[top], "are in Junk")}
+		case mailbox.RoleArchive:
+			return triageSuggestion{action: "archive", target: topBox, evidence: of(where[top], "were archived")}
+		case mailbox.RoleInbox:
+			if float64(unread)/float64(n) >= triageUnreadShare {
+				return triageSuggestion{action: "archive", evidence: of(unread, "were left unread")}
+			}
+			return triageSuggestion{action: "keep", evidence: of(where[top], "stayed in the inbox")}
+		default:
+			return triageSuggestion{action: "file", target: topBox, evidence: of(where[top], "are in "+topBox.Name)}
+		}
+	case float64(unread)/float64(n) >= triageUnreadShare:
+		return triageSuggestion{action: "archive", evidence: of(unread, "were left unread")}
+	}
+	return triageSuggestion{action: "review", evidence: fmt.Sprintf("no clear pattern in %d earlier messages from %s", n, key)}
+}
+
+// keepVIP turns a suggestion to archive, delete, or junk mail from a VIP
+// into keeping it.
+func keepVIP(sug triageSuggestion) triageSuggestion {
+	switch {
+	case sug.action == "archive", sug.action == "delete",
+		sug.action == "file" && sug.target.Role == mailbox.RoleJunk:
+		return triageSuggestion{action: "keep", evidence: "sender is a VIP, though " + sug.evidence}
+	}
+	return sug
+}
+
+func describeTriage(sug triageSuggestion) string {
+	switch sug.action {
+	case "file":
+		return fmt.Sprintf("file to %s [mailbox: %s]", sug.target.Name, sug.target.ID)
+	case "reply":
+		return "needs reply"
+	case "keep":
+		return "keep in inbox"
+	case "review":
+		return "review"
+	}
+	return sug.action
+}
+
+// triageApply renders the tool call applying a suggestion, or "" when there
+// is nothing to do.
+func triageApply(sug triageSuggestion, id jmap.ID, mailboxes map[jmap.ID]*mailbox.Mailbox) string {
+	switch sug.action {
+	case "file":
+		return fmt.Sprintf("email_move email_ids=[%s] mailbox_id=%s", id, sug.target.ID)
+	case "archive":
+		target := sug.target
+		if target == nil {
+			for _, mb := range mailboxes {
+				if mb.Role == mailbox.RoleArchive {
+					target = mb
+				}
+			}
+		}
+		if target == nil {
+			return "no Archive mailbox; email_flag email_ids=[" + string(id) + "] seen=true to mark it read instead"
+		}
+		return fmt.Sprintf("email_move email_ids=[%s] mailbox_id=%s", id, target.ID)
+	case "delete":
+		return fmt.Sprintf("email_delete email_ids=[%s]", id)
+	case "reply":
+		return fmt.Sprintf("email_reply email_id=%s", id)
+	}
+	return ""
+}
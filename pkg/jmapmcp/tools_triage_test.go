@@ -0,0 +1,70 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestListID(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Go Nuts <golang-nuts.googlegroups.com>", "golang-nuts.googlegroups.com"},
+		{"<announce.example.org>", "announce.example.org"},
+		{"announce.example.org", "announce.example.org"},
+	}
+	for _, tt := range tests {
+		e := &email.Email{Headers: []*email.Header{{Name: "list-id", Value: tt.value}}}
+		if got := listID(e); got != tt.want {
+			t.Errorf("listID(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+	if got := listID(&email.Email{From: []*mail.Address{{Email: "a@example.org"}}}); got != "" {
+		t.Errorf("listID without header = %q, want empty", got)
+	}
+}
+
+func TestEmailTriageSuggest(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "archive", "name": "Archive", "role": "archive"})
+	fake.Add("Mailbox", map[string]any{"id": "receipts", "name": "Receipts"})
+
+	// Past receipts from the shop were all filed.
+	for i, id := range []string{"p1", "p2", "p3"} {
+		addEmail(fake, id, "Your order", "", i+1)
+		e := fake.Object("Email", id)
+		e["from"] = []any{map[string]any{"email": "orders@shop.example"}}
+		e["mailboxIds"] = map[string]any{"receipts": true}
+		e["keywords"] = map[string]any{"$seen": true}
+		fake.Add("Email", e)
+	}
+	addEmail(fake, "new1", "Your order", "", 9)
+	e := fake.Object("Email", "new1")
+	e["from"] = []any{map[string]any{"email": "Orders@Shop.example"}}
+	fake.Add("Email", e)
+	addEmail(fake, "new2", "Hello", "", 9)
+	e = fake.Object("Email", "new2")
+	e["from"] = []any{map[string]any{"email": "stranger@example.net"}}
+	fake.Add("Email", e)
+
+	res, _, _ := s.handleEmailTriageSuggest(context.Background(), nil, EmailTriageSuggestInput{EmailIDs: []string{"new1", "new2"}})
+	if res.IsError {
+		t.Fatalf("error: %s", resultText(res))
+	}
+	out := resultText(res)
+	for _, want := range []string{
+		"Suggest: file to Receipts [mailbox: receipts]",
+		"Apply: email_move email_ids=[new1] mailbox_id=receipts",
+		"Suggest: review — no earlier messages from stranger@example.net",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package jmapmcp
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/stalwart"
+)
+
+// email_undelete reaches past Trash on Stalwart: its undelete store
+// (Enterprise) keeps the blobs of destroyed emails for a retention period,
+// and the management API lists and restores them. The caller's own
+// account is used, with the caller's token, so it works for self-hosters
+// whose account may use the API; other servers get capability_missing.
+
+// --- email_undelete ---
+
+type EmailUndeleteInput struct {
+	Hashes []string `json:"hashes,omitempty" jsonschema:"Item hashes of purged emails to restore, from a listing; omit to list what can be recovered"`
+	Limit  int      `json:"limit,omitempty" jsonschema:"Listing: maximum emails to show, most recently deleted first (default 50)"`
+}
+
+var emailUndeleteTool = &mcp.Tool{
+	Name:        "email_undelete",
+	Description: "Recover emails that were permanently destroyed or purged from Trash, on Stalwart servers with undelete (Enterprise) and a token allowed to use it. Without hashes, lists the recoverable emails with size, deletion time, and when they are purged for good; with hashes, restores them into the account. For emails still in Trash use email_restore instead.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleEmailUndelete(ctx context.Context, _ *mcp.CallToolRequest, in EmailUndeleteInput) (*mcp.CallToolResult, any, error) {
+	jc, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if s.quirksFor(jc).backend != "stalwart" {
+		return errorResult(errNoUndelete), nil, nil
+	}
+	account := jc.Session().Username
+	client, err := s.stalwartClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	if len(in.Hashes) > 0 {
+		text, err := restoreDeleted(ctx, client, account, in.Hashes, isDeletedEmail)
+		if err != nil {
+			return errorResult(undeleteError(err)), nil, nil
+		}
+		return textResult(text + "Find the restored emails with email_query."), nil, nil
+	}
+
+	limit := in.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	list, err := client.ListDeleted(ctx, account, 0, maxUndeleteScan)
+	if err != nil {
+		return errorResult(undeleteError(err)), nil, nil
+	}
+	items := slices.DeleteFunc(list.Items, func(it *stalwart.DeletedItem) bool { return !isDeletedEmail(it) })
+	if len(items) == 0 {
+		return textResult("No recoverable deleted emails"), nil, nil
+	}
+	slices.SortStableFunc(items, func(a, b *stalwart.DeletedItem) int { return cmp.Compare(b.DeletedAt, a.DeletedAt) })
+	total := len(items)
+	items = items[:min(limit, total)]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Recoverable deleted emails: %d of %d\n", len(items), total)
+	for _, it := range items {
+		fmt.Fprintf(&sb, "\n[hash: %s] %s, deleted %s", it.Hash, s.locale.size(it.Size), s.locale.dateTime(time.Unix(it.DeletedAt, 0).UTC()))
+		if it.ExpiresAt > 0 {
+			fmt.Fprintf(&sb, ", purged %s", s.locale.dateTime(time.Unix(it.ExpiresAt, 0).UTC()))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nThe store keeps message contents only; restore by hash to see them again.")
+	return textResult(sb.String()), nil, nil
+}
+
+// errNoUndelete refuses email_undelete on servers other than Stalwart.
+var errNoUndelete = &toolError{
+	Code:         codeCapabilityMissing,
+	Message:      "email_undelete needs a Stalwart server with undelete; here only emails still in Trash can be recovered, with email_restore",
+	Capability:   "stalwart undelete",
+	Alternatives: []string{"email_restore for emails still in Trash"},
+}
+
+// isDeletedEmail reports whether it is a deleted email rather than
+// another kind of blob.
+func isDeletedEmail(it *stalwart.DeletedItem) bool {
+	return strings.EqualFold(it.Collection, "email")
+}
+
+// undeleteError explains the management API errors a user's token meets.
+func undeleteError(err error) error {
+	switch {
+	case errors.Is(err, stalwart.ErrForbidden):
+		return &toolError{Code: codeForbidden, Message: "this account may not use Stalwart's undelete API; an administrator can recover the emails with stalwart_undelete_restore", err: err}
+	case errors.Is(err, stalwart.ErrNotStalwart):
+		return &toolError{Code: codeCapabilityMissing, Message: "this Stalwart server does not expose undelete (an Enterprise feature)", Capability: "stalwart undelete", err: err}
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+	"github.com/mikluko/jmap/sieve"
+)
+
+func TestEmailUndelete(t *testing.T) {
+	var restored []map[string]any
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/store/undelete/test@example.org" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			_ = json.NewDecoder(r.Body).Decode(&restored)
+			_, _ = w.Write([]byte(`{"data": [{"type": "success"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"total": 3, "items": [
+			{"hash": "h1", "size": 2048, "deletedAt": 1735725600, "expiresAt": 1738404000, "collection": "email"},
+			{"hash": "h2", "size": 100, "deletedAt": 1735812000, "collection": "sieve"},
+			{"hash": "h3", "size": 4096, "deletedAt": 1735898400, "collection": "email"}]}}`))
+	}))
+	defer api.Close()
+
+	fake := jmapfake.New()
+	s := NewServer("test", api.URL+"/session", WithToken("test"), WithDialer(fake))
+	ctx := context.Background()
+
+	res, _, _ := s.handleEmailUndelete(ctx, nil, EmailUndeleteInput{})
+	if !res.IsError || res.StructuredContent.(*toolError).Code != codeCapabilityMissing {
+		t.Fatalf("email_undelete off Stalwart: %s", resultText(res))
+	}
+
+	fake.AddCapability(string(sieve.URI), map[string]any{"implementation": "Stalwart v0.11"})
+	s = NewServer("test", api.URL+"/session", WithToken("test"), WithDialer(fake))
+	res, _, _ = s.handleEmailUndelete(ctx, nil, EmailUndeleteInput{})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "Recoverable deleted emails: 2 of 2") || strings.Contains(out, "h2") ||
+		strings.Index(out, "h3") > strings.Index(out, "h1") {
+		t.Fatalf("listing:\n%s", out)
+	}
+
+	res, _, _ = s.handleEmailUndelete(ctx, nil, EmailUndeleteInput{Hashes: []string{"h1", "h2"}})
+	out = resultText(res)
+	if res.IsError || !strings.Contains(out, "h1 (email): success") || !strings.Contains(out, "h2: not found") {
+		t.Fatalf("restore:\n%s", out)
+	}
+	if len(restored) != 1 || restored[0]["hash"] != "h1" {
+		t.Errorf("restore request = %v, want h1 only", restored)
+	}
+}
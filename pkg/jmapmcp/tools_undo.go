@@ -0,0 +1,134 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- undo_last ---
+
+type UndoLastInput struct {
+	Preview bool `json:"preview,omitempty" jsonschema:"Describe what would be undone without changing anything"`
+}
+
+var undoLastTool = &mcp.Tool{
+	Name:        "undo_last",
+	Description: "Undo the most recent email_move, email_delete (to Trash), email_flag, label_apply, or label_remove of this session: emails go back to the mailboxes they were in, or get back the flags they had. Call repeatedly to step further back (up to 20 changes). Other changes and permanent deletion cannot be undone. Use preview to see what would be undone first.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleUndoLast(ctx context.Context, req *mcp.CallToolRequest, in UndoLastInput) (*mcp.CallToolResult, any, error) {
+	cs := s.callSessionOf(ctx, req)
+	entry, err := s.journals.last(cs)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if entry == nil {
+		return textResult("Nothing to undo in this session."), nil, nil
+	}
+	if ep := endpointName(ctx); entry.endpoint != ep {
+		return errorResult(invalidArgument("the last change (%s) was made on endpoint %q; pass endpoint=%q", entry.tool, entry.endpoint, entry.endpoint)), nil, nil
+	}
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if accountID := client.Session().PrimaryAccounts[mail.URI]; accountID != entry.accountID {
+		return errorResult(fmt.Errorf("the last change (%s) was made in account %s, not %s", entry.tool, entry.accountID, accountID)), nil, nil
+	}
+
+	summary := fmt.Sprintf("%s of %d email(s) at %s", entry.tool, entry.emailCount(), s.locale.dateTime(entry.at))
+	if in.Preview {
+		return textResult("Would undo " + summary + "\n" + describeUndo(entry)), nil, nil
+	}
+
+	taken, err := s.journals.take(cs, entry)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if !taken {
+		return errorResult(fmt.Errorf("the last change (%s) is already being undone by another call", entry.tool)), nil, nil
+	}
+	updates := make(map[jmap.ID]jmap.Patch, entry.emailCount())
+	for id, mailboxIDs := range entry.mailboxes {
+		updates[id] = jmap.Patch{"mailboxIds": idStrings(mailboxIDs)}
+	}
+	for id, keywords := range entry.keywords {
+		patch := updates[id]
+		if patch == nil {
+			patch = jmap.Patch{}
+			updates[id] = patch
+		}
+		for kw, set := range keywords {
+			if set {
+				patch["keywords/"+kw] = true
+			} else {
+				patch["keywords/"+kw] = nil
+			}
+		}
+	}
+
+	setReq := &jmap.Request{Context: ctx}
+	setReq.Invoke(&email.Set{Account: entry.accountID, Update: updates})
+	resp, err := client.Do(setReq)
+	if err != nil {
+		s.journals.restore(cs, entry)
+		return errorResult(err), nil, nil
+	}
+	if len(resp.Responses) == 0 {
+		s.journals.restore(cs, entry)
+		return errorResult(fmt.Errorf("empty response for Email/set")), nil, nil
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.SetResponse:
+		if entry.tool == "email_delete" {
+			var restored []jmap.ID
+			for id := range entry.mailboxes {
+				if _, failed := args.NotUpdated[id]; !failed {
+					restored = append(restored, id)
+				}
+			}
+			s.tombstones.forget(s.idOwner(ctx), entry.endpoint, restored)
+		}
+		msg := "Undid " + summary
+		if err := setFailures("could not undo", args.NotUpdated); err != nil {
+			msg += fmt.Sprintf("\n%d email(s) left as they are: %v", len(args.NotUpdated), err)
+		}
+		return textResult(msg), nil, nil
+	case *jmap.MethodError:
+		s.journals.restore(cs, entry)
+		return errorResult(args), nil, nil
+	default:
+		s.journals.restore(cs, entry)
+		return errorResult(fmt.Errorf("unexpected response type: %T", args)), nil, nil
+	}
+}
+
+// describeUndo lists what undoing entry would set on each email.
+func describeUndo(entry *journalEntry) string {
+	var lines []string
+	for id, mailboxIDs := range entry.mailboxes {
+		lines = append(lines, fmt.Sprintf("%s  back to mailboxes %s", id, strings.Join(sortedKeys(idStrings(mailboxIDs)), ", ")))
+	}
+	for id, keywords := range entry.keywords {
+		var parts []string
+		for _, kw := range sortedKeys(keywords) {
+			if keywords[kw] {
+				parts = append(parts, "+"+kw)
+			} else {
+				parts = append(parts, "-"+kw)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s  keywords %s", id, strings.Join(parts, " ")))
+	}
+	slices.Sort(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,159 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/mikluko/jmap-mcp/internal/store"
+)
+
+func TestUndoLast(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Mailbox", map[string]any{"id": "archive", "name": "Archive", "role": "archive"})
+	fake.Add("Email", map[string]any{"id": "e1", "subject": "One", "mailboxIds": map[string]any{"inbox": true}})
+	fake.Add("Email", map[string]any{"id": "e2", "subject": "Two", "mailboxIds": map[string]any{"inbox": true}, "keywords": map[string]any{"$flagged": true}})
+	ctx := context.Background()
+
+	if res, _, _ := s.handleEmailMove(ctx, nil, EmailMoveInput{EmailIDs: []string{"e1", "e2"}, MailboxID: "archive"}); res.IsError {
+		t.Fatalf("email_move: %s", resultText(res))
+	}
+	seen, flagged := true, false
+	if res, _, _ := s.handleEmailFlag(ctx, nil, EmailFlagInput{EmailIDs: []string{"e2"}, Seen: &seen, Flagged: &flagged}); res.IsError {
+		t.Fatalf("email_flag: %s", resultText(res))
+	}
+
+	res, _, _ := s.handleUndoLast(ctx, nil, UndoLastInput{Preview: true})
+	if out := resultText(res); !strings.Contains(out, "Would undo email_flag of 1 email(s)") || !strings.Contains(out, "e2  keywords +$flagged -$seen") {
+		t.Errorf("preview:\n%s", out)
+	}
+	if kw, _ := fake.Object("Email", "e2")["keywords"].(map[string]any); kw["$seen"] != true {
+		t.Errorf("preview changed keywords: %v", kw)
+	}
+
+	res, _, _ = s.handleUndoLast(ctx, nil, UndoLastInput{})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Undid email_flag") {
+		t.Fatalf("undo flag: %s", out)
+	}
+	if kw, _ := fake.Object("Email", "e2")["keywords"].(map[string]any); kw["$flagged"] != true || kw["$seen"] != nil {
+		t.Errorf("e2 keywords after undo = %v, want only $flagged", kw)
+	}
+
+	res, _, _ = s.handleUndoLast(ctx, nil, UndoLastInput{})
+	if out := resultText(res); res.IsError || !strings.Contains(out, "Undid email_move of 2 email(s)") {
+		t.Fatalf("undo move: %s", out)
+	}
+	for _, id := range []string{"e1", "e2"} {
+		if mbs, _ := fake.Object("Email", id)["mailboxIds"].(map[string]any); mbs["inbox"] != true || mbs["archive"] != nil {
+			t.Errorf("%s mailboxIds after undo = %v, want Inbox", id, mbs)
+		}
+	}
+
+	res, _, _ = s.handleUndoLast(ctx, nil, UndoLastInput{})
+	if out := resultText(res); out != "Nothing to undo in this session." {
+		t.Errorf("empty journal: %s", out)
+	}
+}
+
+func TestUndoLastDelete(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "work", "name": "Work"})
+	fake.Add("Mailbox", map[string]any{"id": "trash", "name": "Trash", "role": "trash"})
+	fake.Add("Email", map[string]any{"id": "e1", "subject": "One", "mailboxIds": map[string]any{"work": true}})
+	ctx := context.Background()
+
+	if res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}}); res.IsError {
+		t.Fatalf("email_delete: %s", resultText(res))
+	}
+	if res, _, _ := s.handleUndoLast(ctx, nil, UndoLastInput{}); res.IsError {
+		t.Fatalf("undo: %s", resultText(res))
+	}
+	if mbs, _ := fake.Object("Email", "e1")["mailboxIds"].(map[string]any); mbs["work"] != true || mbs["trash"] != nil {
+		t.Errorf("e1 mailboxIds after undo = %v, want Work", mbs)
+	}
+	if s.tombstones.lookup(s.idOwner(ctx), DefaultEndpoint, "e1") != nil {
+		t.Error("tombstone kept after the delete was undone")
+	}
+
+	// Permanent deletion is not journaled.
+	if res, _, _ := s.handleEmailDelete(ctx, nil, EmailDeleteInput{EmailIDs: []string{"e1"}, Permanent: true}); res.IsError {
+		t.Fatalf("permanent delete: %s", resultText(res))
+	}
+	if res, _, _ := s.handleUndoLast(ctx, nil, UndoLastInput{}); resultText(res) != "Nothing to undo in this session." {
+		t.Errorf("permanent delete journaled: %s", resultText(res))
+	}
+}
+
+func TestJournalTakeRestore(t *testing.T) {
+	var j Journals
+	cs := callSession{owner: "o"}
+	now := time.Now()
+	older := &journalEntry{tool: "email_move", at: now.Add(-time.Minute)}
+	newer := &journalEntry{tool: "email_flag", at: now}
+	j.push(cs, older)
+	j.push(cs, newer)
+
+	if taken, err := j.take(cs, newer); !taken || err != nil {
+		t.Fatalf("take of the last entry = %v, %v", taken, err)
+	}
+	if taken, _ := j.take(cs, newer); taken {
+		t.Error("the same entry was taken twice")
+	}
+	if e, _ := j.last(cs); e != older {
+		t.Errorf("last after take = %+v", e)
+	}
+	j.restore(cs, newer)
+	if e, _ := j.last(cs); e != newer {
+		t.Errorf("last after restore = %+v", e)
+	}
+}
+
+func TestJournalsStore(t *testing.T) {
+	st := &store.Memory{}
+	j, err := LoadJournals(st, "journals.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now()
+	e := &journalEntry{tool: "email_move", endpoint: "work", accountID: "a1", at: at,
+		mailboxes: map[jmap.ID]map[jmap.ID]bool{"e1": {"inbox": true}}}
+	j.push(callSession{owner: "o"}, e)
+
+	// Another session of the tenant, in another process, sees the batch and
+	// takes it, so the first process cannot undo it again.
+	other, err := LoadJournals(st, "journals.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := other.last(callSession{session: &mcp.ServerSession{}, owner: "o"})
+	if err != nil || got == nil || got.tool != "email_move" || got.endpoint != "work" || !got.at.Equal(at) || !got.mailboxes["e1"]["inbox"] {
+		t.Fatalf("last in another process = %+v, %v", got, err)
+	}
+	if e, _ := other.last(callSession{owner: "someone else"}); e != nil {
+		t.Errorf("another tenant sees %+v", e)
+	}
+	if taken, err := other.take(callSession{owner: "o"}, got); !taken || err != nil {
+		t.Fatalf("take = %v, %v", taken, err)
+	}
+	if taken, err := j.take(callSession{owner: "o"}, e); taken || err != nil {
+		t.Errorf("take of a batch another process took = %v, %v", taken, err)
+	}
+
+	// When the document cannot be reread, nothing stale is saved over it.
+	if _, err := st.Write("journals.json", []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	j.push(callSession{owner: "o"}, &journalEntry{tool: "email_flag", at: time.Now()})
+	j.restore(callSession{owner: "o"}, e)
+	if data, _, _ := st.Read("journals.json"); string(data) != "not json" {
+		t.Errorf("journals saved over an unreadable document: %s", data)
+	}
+	if _, err := j.last(callSession{owner: "o"}); err == nil {
+		t.Error("last read an unreadable document")
+	}
+}
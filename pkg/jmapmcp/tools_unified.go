@@ -0,0 +1,212 @@
+package jmapmcp
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/mailbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	defaultUnifiedLimit = 20
+	maxUnifiedLimit     = 100
+)
+
+// --- inbox_unified ---
+
+type InboxUnifiedInput struct {
+	Limit      int  `json:"limit,omitempty" jsonschema:"Maximum number of emails to return across all accounts (default 20, max 100)"`
+	UnreadOnly bool `json:"unread_only,omitempty" jsonschema:"Only include emails not marked $seen"`
+}
+
+var inboxUnifiedTool = &mcp.Tool{
+	Name:        "inbox_unified",
+	Description: "List the latest emails in the Inbox of every mail account this server can reach, across all configured endpoints, newest first. Each line is tagged with its account (and endpoint, when several are configured). Accounts are queried in parallel; an account that cannot be read is noted rather than failing the call.",
+	Annotations: readOnlyAnnotations,
+}
+
+// unifiedSource is one mail account of one endpoint.
+type unifiedSource struct {
+	endpoint  string
+	accountID jmap.ID
+	tag       string
+	emails    []*email.Email
+	err       error
+}
+
+func (s *Server) handleInboxUnified(ctx context.Context, _ *mcp.CallToolRequest, in InboxUnifiedInput) (*mcp.CallToolResult, any, error) {
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultUnifiedLimit
+	}
+	if limit > maxUnifiedLimit {
+		return errorResult(invalidArgument("limit must be at most %d", maxUnifiedLimit)), nil, nil
+	}
+
+	names := s.endpointNames()
+	perEndpoint := make([][]*unifiedSource, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Go(func() {
+			perEndpoint[i] = s.unifiedEndpoint(ContextWithEndpoint(ctx, name), name, len(names) > 1, limit, in.UnreadOnly)
+		})
+	}
+	wg.Wait()
+
+	var sources []*unifiedSource
+	for _, list := range perEndpoint {
+		sources = append(sources, list...)
+	}
+
+	type tagged struct {
+		tag string
+		e   *email.Email
+	}
+	var all []tagged
+	var notes []string
+	read := 0
+	for _, src := range sources {
+		if src.err != nil {
+			notes = append(notes, fmt.Sprintf("%s: %v", src.tag, src.err))
+			continue
+		}
+		read++
+		for _, e := range src.emails {
+			all = append(all, tagged{src.tag, e})
+		}
+	}
+	if read == 0 && len(notes) > 0 {
+		return errorResult(fmt.Errorf("no inbox could be read: %s", strings.Join(notes, "; "))), nil, nil
+	}
+	slices.SortStableFunc(all, func(a, b tagged) int {
+		return receivedAt(b.e).Compare(receivedAt(a.e))
+	})
+	if len(all) > limit {
+		all = all[:limit]
+	}
+
+	var sb strings.Builder
+	what := "email(s)"
+	if in.UnreadOnly {
+		what = "unread email(s)"
+	}
+	fmt.Fprintf(&sb, "Unified inbox: %d %s from %d account(s)\n", len(all), what, read)
+	for _, n := range notes {
+		fmt.Fprintf(&sb, "Note: %s\n", n)
+	}
+	if len(names) > 1 {
+		sb.WriteString("Note: IDs are only valid on their endpoint; pass it as endpoint to other tools.\n")
+	}
+	sb.WriteString("\n")
+	for _, t := range all {
+		subject := t.e.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		unread := ""
+		if !t.e.Keywords["$seen"] {
+			unread = " (unread)"
+		}
+		fmt.Fprintf(&sb, "[%s] %s  %s  %s%s [id: %s]\n", t.tag, s.locale.dateTime(receivedAt(t.e)), formatAddresses(t.e.From), subject, unread, t.e.ID)
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// unifiedEndpoint queries the Inbox of every mail account of the endpoint
+// selected in ctx, in parallel. A failure to connect is returned as a
+// single failed source.
+func (s *Server) unifiedEndpoint(ctx context.Context, name string, qualify bool, limit int, unreadOnly bool) []*unifiedSource {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return []*unifiedSource{{endpoint: name, tag: name, err: err}}
+	}
+	session := client.Session()
+
+	var sources []*unifiedSource
+	for id, account := range session.Accounts {
+		if _, ok := account.Capabilities[mail.URI]; !ok {
+			continue
+		}
+		tag := cmp.Or(account.Name, string(id))
+		if qualify {
+			tag = name + "/" + tag
+		}
+		sources = append(sources, &unifiedSource{endpoint: name, accountID: id, tag: tag})
+	}
+	if len(sources) == 0 {
+		return []*unifiedSource{{endpoint: name, tag: name, err: fmt.Errorf("no mail accounts")}}
+	}
+	// The primary account first, the others by tag, so ties in date keep
+	// a stable order.
+	primary := session.PrimaryAccounts[mail.URI]
+	slices.SortFunc(sources, func(a, b *unifiedSource) int {
+		if (a.accountID == primary) != (b.accountID == primary) {
+			if a.accountID == primary {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.tag, b.tag)
+	})
+
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		wg.Go(func() {
+			src.emails, src.err = s.unifiedInbox(ctx, client, src.accountID, limit, unreadOnly)
+		})
+	}
+	wg.Wait()
+	return sources
+}
+
+// unifiedInbox returns the latest limit emails of the account's Inbox.
+func (s *Server) unifiedInbox(ctx context.Context, client JMAPClient, accountID jmap.ID, limit int, unreadOnly bool) ([]*email.Email, error) {
+	inboxID, err := s.findMailboxByRole(ctx, client, accountID, mailbox.RoleInbox)
+	if err != nil {
+		return nil, err
+	}
+	filter := &email.FilterCondition{InMailbox: inboxID}
+	if unreadOnly {
+		filter.NotKeyword = "$seen"
+	}
+
+	req := &jmap.Request{Context: ctx}
+	queryCallID := req.Invoke(&email.Query{
+		Account: accountID,
+		Filter:  filter,
+		Sort:    []*email.SortComparator{{Property: "receivedAt", IsAscending: false}},
+		Limit:   uint64(limit),
+	})
+	req.Invoke(&email.Get{
+		Account:      accountID,
+		ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+		Properties:   []string{"id", "subject", "from", "receivedAt", "keywords"},
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) < 2 {
+		return nil, fmt.Errorf("missing Email/get response in query chain")
+	}
+	if me, ok := resp.Responses[0].Args.(*jmap.MethodError); ok {
+		return nil, me
+	}
+	switch args := resp.Responses[1].Args.(type) {
+	case *email.GetResponse:
+		return args.List, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
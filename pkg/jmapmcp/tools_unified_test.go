@@ -0,0 +1,82 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap"
+
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+)
+
+// routingDialer dials the fake configured for each session URL.
+type routingDialer map[string]*jmapfake.Server
+
+func (d routingDialer) Dial(ctx context.Context, sessionURL, token string) (*jmap.Client, error) {
+	return d[sessionURL].Dial(ctx, sessionURL, token)
+}
+
+func TestInboxUnifiedInterleavesEndpoints(t *testing.T) {
+	personal, work := jmapfake.New(), jmapfake.New()
+	for _, fake := range []*jmapfake.Server{personal, work} {
+		fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	}
+	addEmail(personal, "p1", "Dinner", "Hi", 3)
+	addEmail(personal, "p2", "Holiday", "Hi", 1)
+	addEmail(work, "w1", "Budget", "Hi", 2)
+	addEmail(work, "w2", "Standup", "Hi", 4)
+	work.Add("Email", map[string]any{
+		"id": "w3", "subject": "Archived", "receivedAt": "2025-01-05T10:00:00Z",
+		"mailboxIds": map[string]any{"archive": true},
+	})
+
+	s := NewServer("test", "https://personal.example/session",
+		WithToken("test"),
+		WithEndpoint("work", "https://work.example/session", ""),
+		WithDialer(routingDialer{
+			"https://personal.example/session": personal,
+			"https://work.example/session":     work,
+		}),
+	)
+
+	res, _, _ := s.handleInboxUnified(context.Background(), nil, InboxUnifiedInput{Limit: 3})
+	out := resultText(res)
+	if res.IsError {
+		t.Fatalf("inbox_unified: %s", out)
+	}
+	if !strings.Contains(out, "Unified inbox: 3 email(s) from 2 account(s)") {
+		t.Errorf("summary missing:\n%s", out)
+	}
+	standup := strings.Index(out, "[work/test@example.org]")
+	dinner := strings.Index(out, "[default/test@example.org]")
+	budget := strings.Index(out, "Budget")
+	if standup < 0 || dinner < 0 || budget < 0 || !(standup < dinner && dinner < budget) {
+		t.Errorf("emails not interleaved newest first:\n%s", out)
+	}
+	if strings.Contains(out, "Holiday") || strings.Contains(out, "Archived") {
+		t.Errorf("limit or inbox filter not applied:\n%s", out)
+	}
+}
+
+func TestInboxUnifiedUnreadOnly(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	fake.Add("Email", map[string]any{
+		"id": "m1", "subject": "Hello", "receivedAt": "2025-01-01T10:00:00Z",
+		"mailboxIds": map[string]any{"inbox": true}, "keywords": map[string]any{"$seen": true},
+	})
+	addEmail(fake, "m2", "Unread", "Hi", 2)
+
+	res, _, _ := s.handleInboxUnified(context.Background(), nil, InboxUnifiedInput{UnreadOnly: true})
+	out := resultText(res)
+	if res.IsError || !strings.Contains(out, "[test@example.org]") || !strings.Contains(out, "Unread (unread) [id: m2]") || strings.Contains(out, "m1") {
+		t.Errorf("inbox_unified unread:\n%s", out)
+	}
+
+	fake.FailNext("Mailbox/get", "serverFail")
+	res, _, _ = s.handleInboxUnified(context.Background(), nil, InboxUnifiedInput{})
+	if !res.IsError {
+		t.Errorf("expected an error when no inbox can be read:\n%s", resultText(res))
+	}
+}
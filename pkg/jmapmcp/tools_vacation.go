@@ -0,0 +1,130 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// vacationResponseURI is the RFC 8621 VacationResponse capability; servers
+// advertising it have their own out-of-office setting.
+const vacationResponseURI = jmap.URI("urn:ietf:params:jmap:vacationresponse")
+
+// vacationRuleKey names the managed Sieve rule holding the vacation reply.
+var vacationRuleKey = triageKey{from: "vacation"}
+
+const (
+	defaultVacationDays = 7 // RFC 5230 default
+	maxVacationDays     = 30
+)
+
+// --- vacation_via_sieve ---
+
+type VacationViaSieveInput struct {
+	Subject string `json:"subject,omitempty" jsonschema:"Subject of the automatic reply (default: the server's, usually Auto: plus the original subject)"`
+	Message string `json:"message,omitempty" jsonschema:"Text of the automatic reply; required unless off"`
+	Start   string `json:"start,omitempty" jsonschema:"First day to reply, YYYY-MM-DD (server time zone); omit to start now"`
+	End     string `json:"end,omitempty" jsonschema:"Last day to reply, YYYY-MM-DD (server time zone); omit to reply until turned off"`
+	Days    int    `json:"days,omitempty" jsonschema:"Reply to the same sender at most once per this many days (default 7, max 30)"`
+	Off     bool   `json:"off,omitempty" jsonschema:"Turn the vacation reply off"`
+}
+
+var vacationViaSieveTool = &mcp.Tool{
+	Name:        "vacation_via_sieve",
+	Description: "Set or turn off an out-of-office reply as a Sieve vacation rule, for servers without the JMAP VacationResponse capability but with the Sieve vacation extension. The rule (subject, message, optional start/end dates) lives in the managed region of the active Sieve script, creating and activating a script if none is active; calling again replaces it. Rules outside the managed region are left untouched.",
+	Annotations: destructiveAnnotations,
+}
+
+func (s *Server) handleVacationViaSieve(ctx context.Context, _ *mcp.CallToolRequest, in VacationViaSieveInput) (*mcp.CallToolResult, any, error) {
+	if err := checkMaySend(ctx, "vacation replies"); err != nil {
+		return errorResult(err), nil, nil
+	}
+	if !in.Off && strings.TrimSpace(in.Message) == "" {
+		return errorResult(invalidArgument("message is required unless off")), nil, nil
+	}
+	if in.Off && (in.Message != "" || in.Subject != "" || in.Start != "" || in.End != "") {
+		return errorResult(invalidArgument("off takes no other arguments")), nil, nil
+	}
+	for _, d := range []string{in.Start, in.End} {
+		if _, err := time.Parse("2006-01-02", d); d != "" && err != nil {
+			return errorResult(invalidArgument("date %q: want YYYY-MM-DD", d)), nil, nil
+		}
+	}
+	if in.Start != "" && in.End != "" && in.End < in.Start {
+		return errorResult(invalidArgument("end %s is before start %s", in.End, in.Start)), nil, nil
+	}
+	days := in.Days
+	if days <= 0 {
+		days = defaultVacationDays
+	}
+	days = min(days, maxVacationDays)
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	sieveAccount, err := sieveAccountID(client)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if exts, ok := sieveExtensions(client.Session()); ok && !in.Off {
+		need := []string{"vacation"}
+		if in.Start != "" || in.End != "" {
+			need = append(need, "date", "relational")
+		}
+		for _, ext := range need {
+			if !slices.Contains(exts, ext) {
+				return errorResult(&toolError{Code: codeCapabilityMissing,
+					Message: fmt.Sprintf("the server's Sieve implementation lacks the %q extension", ext)}), nil, nil
+			}
+		}
+	}
+
+	rule := sieveVacationRule(in.Subject, in.Message, in.Start, in.End, days, in.Off)
+	msg, err := s.installManagedRule(ctx, client, sieveAccount, vacationRuleKey, rule)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n\n%s\n", msg, rule)
+	if _, ok := client.Session().Capabilities[vacationResponseURI]; ok && !in.Off {
+		sb.WriteString("\nNote: this server also has a JMAP vacation response; if that is enabled too, senders may get two replies.\n")
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// sieveVacationRule renders the managed vacation rule, limited to the
+// dates from start to end (inclusive) when given. Turning it off renders a
+// rule without commands, which replaces the earlier one.
+func sieveVacationRule(subject, message, start, end string, days int, off bool) string {
+	marker := ruleMarker + vacationRuleKey.String()
+	if off {
+		return marker + "\n# (vacation reply off)"
+	}
+	command := fmt.Sprintf("vacation :days %d", days)
+	if subject != "" {
+		command += " :subject " + sieveString(subject)
+	}
+	command += " " + sieveString(message) + ";"
+
+	var tests []string
+	if start != "" {
+		tests = append(tests, fmt.Sprintf(`currentdate :value "ge" "date" %s`, sieveString(start)))
+	}
+	if end != "" {
+		tests = append(tests, fmt.Sprintf(`currentdate :value "le" "date" %s`, sieveString(end)))
+	}
+	switch len(tests) {
+	case 0:
+		return marker + "\n" + command
+	case 1:
+		return fmt.Sprintf("%s\nif %s {\n    %s\n}", marker, tests[0], command)
+	default:
+		return fmt.Sprintf("%s\nif allof(%s) {\n    %s\n}", marker, strings.Join(tests, ", "), command)
+	}
+}
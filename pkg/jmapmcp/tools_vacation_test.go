@@ -0,0 +1,60 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap/sieve"
+)
+
+func TestSieveVacationRule(t *testing.T) {
+	got := mergeManagedRule("", sieveVacationRule("Away", `Back on the "21st"`, "2026-10-12", "2026-10-20", 7, false))
+	want := managedBegin + "\n" + `require ["fileinto", "date", "relational", "vacation"];` + "\n" +
+		"# rule: vacation\n" +
+		`if allof(currentdate :value "ge" "date" "2026-10-12", currentdate :value "le" "date" "2026-10-20") {` + "\n" +
+		`    vacation :days 7 :subject "Away" "Back on the \"21st\"";` + "\n}\n" +
+		managedEnd + "\n"
+	if got != want {
+		t.Errorf("vacation rule:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Turning it off drops the extensions it required.
+	got = mergeManagedRule(got, sieveVacationRule("", "", "", "", 7, true))
+	if !strings.Contains(got, managedRequire+"\n# rule: vacation\n# (vacation reply off)\n") || strings.Contains(got, "vacation :days") {
+		t.Errorf("vacation off:\n%s", got)
+	}
+
+	if rule := sieveVacationRule("", "Away", "", "", 3, false); rule != "# rule: vacation\nvacation :days 3 \"Away\";" {
+		t.Errorf("undated rule:\n%s", rule)
+	}
+}
+
+func TestVacationViaSieve(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.AddCapability(string(sieve.URI), map[string]any{"sieveExtensions": []any{"fileinto", "vacation"}})
+
+	res, _, _ := s.handleVacationViaSieve(context.Background(), nil, VacationViaSieveInput{Message: "Away", Start: "2026-10-12"})
+	if !res.IsError || !strings.Contains(resultText(res), `lacks the "date" extension`) {
+		t.Fatalf("date window without the date extension:\n%s", resultText(res))
+	}
+
+	res, _, _ = s.handleVacationViaSieve(context.Background(), nil, VacationViaSieveInput{Subject: "Out of office", Message: "Away until Monday."})
+	if res.IsError {
+		t.Fatalf("install error: %s", resultText(res))
+	}
+	ids := fake.Objects("SieveScript")
+	if len(ids) != 1 {
+		t.Fatalf("scripts = %v, want one", ids)
+	}
+	blobID, _ := fake.Object("SieveScript", ids[0])["blobId"].(string)
+	if content := string(fake.Blob(blobID)); !strings.Contains(content, `vacation :days 7 :subject "Out of office" "Away until Monday.";`) ||
+		!strings.Contains(content, `require ["fileinto", "vacation"];`) {
+		t.Errorf("installed script:\n%s", content)
+	}
+
+	res, _, _ = s.handleVacationViaSieve(context.Background(), nil, VacationViaSieveInput{Start: "2026-10-20", End: "2026-10-12", Message: "Away"})
+	if !res.IsError || !strings.Contains(resultText(res), "before start") {
+		t.Errorf("reversed window accepted:\n%s", resultText(res))
+	}
+}
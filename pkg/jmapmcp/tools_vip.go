@@ -0,0 +1,81 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mikluko/jmap/mail"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- vip_add ---
+
+type VIPAddInput struct {
+	Addresses []string `json:"addresses" jsonschema:"Sender addresses to add, or *@domain for everyone at a domain"`
+}
+
+var vipAddTool = &mcp.Tool{
+	Name:        "vip_add",
+	Description: "Add senders to the user's VIP list, kept by this server across sessions. Mail from VIPs is listed first by email_digest, email_triage_suggest never suggests archiving or deleting it, watch notifications flag it, and email_query can search it alone with vip_only.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleVIPAdd(ctx context.Context, _ *mcp.CallToolRequest, in VIPAddInput) (*mcp.CallToolResult, any, error) {
+	if len(in.Addresses) == 0 {
+		return errorResult(invalidArgument("addresses is required")), nil, nil
+	}
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	user := client.Session().Username
+
+	added, err := s.senders.add(vipList, user, in.Addresses)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	list := s.senders.list(vipList, user)
+	if len(added) == 0 {
+		return textResult(fmt.Sprintf("Already VIPs; the list has %d entr(ies)", len(list))), nil, nil
+	}
+	return textResult(fmt.Sprintf("Added %d VIP(s): %s\nThe list has %d entr(ies)", len(added), strings.Join(added, ", "), len(list))), nil, nil
+}
+
+// --- vip_list ---
+
+type VIPListInput struct{}
+
+var vipListTool = &mcp.Tool{
+	Name:        "vip_list",
+	Description: "List the user's VIP senders (addresses and *@domain entries) added with vip_add.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleVIPList(ctx context.Context, _ *mcp.CallToolRequest, _ VIPListInput) (*mcp.CallToolResult, any, error) {
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	list := s.vipsOf(client)
+	if len(list) == 0 {
+		return textResult("No VIPs. Add some with vip_add."), nil, nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "VIPs (%d):\n", len(list))
+	for _, entry := range list {
+		fmt.Fprintf(&sb, "  %s\n", entry)
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// vipFirst orders senders a before b when only a is a VIP.
+func vipFirst(a, b []*mail.Address, vips []string) int {
+	switch av, bv := senderListed(a, vips), senderListed(b, vips); {
+	case av && !bv:
+		return -1
+	case bv && !av:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,278 @@
+package jmapmcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/mail"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// --- watch_create ---
+
+type WatchCreateInput struct {
+	MailboxID string   `json:"mailbox_id,omitempty" jsonschema:"Notify on new messages arriving in this mailbox"`
+	ThreadID  string   `json:"thread_id,omitempty" jsonschema:"Notify on new messages and keyword (flag) changes in this thread"`
+	Keywords  []string `json:"keywords,omitempty" jsonschema:"Thread watches only: report changes to these keywords only (e.g. $flagged, $seen)"`
+
+	QuietHours string `json:"quiet_hours,omitempty" jsonschema:"Daily window as HH:MM-HH:MM, which may cross midnight (e.g. 22:00-07:00), during which notifications are held and then sent combined"`
+	TimeZone   string `json:"time_zone,omitempty" jsonschema:"IANA time zone of quiet_hours, e.g. Europe/Berlin (default UTC)"`
+	MaxPerHour int    `json:"max_per_hour,omitempty" jsonschema:"At most this many notifications per rolling hour; events beyond it are held and sent combined once the limit allows (default: no limit)"`
+}
+
+var watchCreateTool = &mcp.Tool{
+	Name:        "watch_create",
+	Description: "Watch a mailbox for new messages, or a thread for new messages and flag/keyword changes. Matches arrive as notifications/message log notifications (logger jmap-mcp.watch) with the matching email IDs, and are kept at the jmap://watch/{id} resource until read. Give exactly one of mailbox_id or thread_id. quiet_hours and max_per_hour hold notifications at night or during a mail storm and send them combined, one per kind, later; events stay readable at the resource meanwhile. Watches last for the session; on servers without push they poll for changes periodically.",
+	Annotations: mutatingAnnotations,
+}
+
+func (s *Server) handleWatchCreate(ctx context.Context, req *mcp.CallToolRequest, in WatchCreateInput) (*mcp.CallToolResult, any, error) {
+	if (in.MailboxID == "") == (in.ThreadID == "") {
+		return errorResult(invalidArgument("give exactly one of mailbox_id or thread_id")), nil, nil
+	}
+	if in.MailboxID != "" && len(in.Keywords) > 0 {
+		return errorResult(invalidArgument("keywords apply to thread watches only")), nil, nil
+	}
+	if in.MaxPerHour < 0 {
+		return errorResult(invalidArgument("max_per_hour must not be negative")), nil, nil
+	}
+	var quiet *quietHours
+	if in.QuietHours != "" {
+		var err error
+		if quiet, err = parseQuietHours(in.QuietHours, in.TimeZone); err != nil {
+			return errorResult(invalidArgument("%w", err)), nil, nil
+		}
+	} else if in.TimeZone != "" {
+		return errorResult(invalidArgument("time_zone applies to quiet_hours only")), nil, nil
+	}
+
+	ep, err := s.endpointFor(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	if n := len(s.watches.list(s.ownerOf(token))); n >= maxTenantWatches {
+		return errorResult(invalidArgument("you already have %d watches, the most allowed; remove some with watch_delete", n)), nil, nil
+	}
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	accountID := client.Session().PrimaryAccounts[mail.URI]
+	if accountID == "" {
+		return errorResult(fmt.Errorf("no primary mail account")), nil, nil
+	}
+	if !s.hasPush(client.Session()) && s.watchPollInterval == 0 {
+		return errorResult(fmt.Errorf("server does not support push (no eventSourceUrl or WebSocket push) and watch polling is disabled")), nil, nil
+	}
+
+	target := fmt.Sprintf("thread %s", in.ThreadID)
+	if in.MailboxID != "" {
+		mailboxes, err := fetchMailboxes(ctx, client, accountID)
+		if err != nil {
+			return errorResult(err), nil, nil
+		}
+		mb, ok := mailboxes[jmap.ID(in.MailboxID)]
+		if !ok {
+			return errorResult(notFoundError([]string{in.MailboxID}, "mailbox %s not found", in.MailboxID)), nil, nil
+		}
+		if err := checkMailboxRight(mb, "mayReadItems"); err != nil {
+			return errorResult(err), nil, nil
+		}
+		target = fmt.Sprintf("mailbox %s [id: %s]", mb.Name, mb.ID)
+	}
+
+	state, snapshot, err := watchBaseline(ctx, client, accountID, jmap.ID(in.MailboxID), jmap.ID(in.ThreadID))
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+
+	w := &watch{
+		Owner:      s.ownerOf(token),
+		Endpoint:   EndpointFromContext(ctx),
+		AccountID:  accountID,
+		MailboxID:  jmap.ID(in.MailboxID),
+		ThreadID:   jmap.ID(in.ThreadID),
+		Keywords:   in.Keywords,
+		Quiet:      quiet,
+		MaxPerHour: in.MaxPerHour,
+		CreatedAt:  time.Now(),
+		state:      state,
+		snapshot:   snapshot,
+	}
+	if req != nil && req.Session != nil {
+		w.session = req.Session
+		go func(ss *mcp.ServerSession) {
+			ss.Wait()
+			s.watches.remove(func(w *watch) bool { return w.session == ss })
+		}(req.Session)
+	}
+	id, start := s.watches.add(w)
+	if start {
+		s.startWatchListener(w.listenerKey(), ep.sessionURL, token)
+	}
+
+	text := fmt.Sprintf("Created %s on %s", id, target)
+	if len(in.Keywords) > 0 {
+		text += fmt.Sprintf(" (keywords: %s)", strings.Join(in.Keywords, ", "))
+	}
+	if limits := watchLimits(w); limits != "" {
+		text += "\nNotifications: " + limits
+	}
+	text += fmt.Sprintf("\nEvents: notifications/message from %s, pending at %s", watchLoggerName, watchURI(id))
+	if !s.hasPush(client.Session()) {
+		text += fmt.Sprintf("\nThe server has no push: checking every %s", s.watchPollInterval)
+	}
+	return textResult(text), nil, nil
+}
+
+// --- watch_list ---
+
+type WatchListInput struct{}
+
+var watchListTool = &mcp.Tool{
+	Name:        "watch_list",
+	Description: "List this session's watches with their target, push connection status, and delivered and pending event counts.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleWatchList(ctx context.Context, _ *mcp.CallToolRequest, _ WatchListInput) (*mcp.CallToolResult, any, error) {
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	watches := s.watches.list(s.ownerOf(token))
+	if len(watches) == 0 {
+		return textResult("No watches"), nil, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Watches: %d\n", len(watches))
+	for _, w := range watches {
+		fmt.Fprintf(&sb, "\n[id: %s] ", w.ID)
+		if w.MailboxID != "" {
+			fmt.Fprintf(&sb, "new messages in mailbox %s", w.MailboxID)
+		} else {
+			fmt.Fprintf(&sb, "thread %s", w.ThreadID)
+			if len(w.Keywords) > 0 {
+				fmt.Fprintf(&sb, " (keywords: %s)", strings.Join(w.Keywords, ", "))
+			}
+		}
+		if w.Endpoint != "" {
+			fmt.Fprintf(&sb, " on %s", w.Endpoint)
+		}
+		fmt.Fprintf(&sb, "\n  created %s, delivered %d, pending %d", s.locale.dateTime(w.CreatedAt), w.delivered, len(w.pending))
+		if mode := s.watches.mode(w.listenerKey()); mode != "" {
+			fmt.Fprintf(&sb, ", push: %s", mode)
+		}
+		if limits := watchLimits(&w); limits != "" {
+			fmt.Fprintf(&sb, "\n  notifications: %s", limits)
+			if len(w.held) > 0 {
+				fmt.Fprintf(&sb, ", %d event(s) held", len(w.held))
+			}
+		}
+		if w.lastError != "" {
+			fmt.Fprintf(&sb, "\n  last error: %s", w.lastError)
+		}
+		sb.WriteString("\n")
+	}
+	return textResult(sb.String()), nil, nil
+}
+
+// --- watch_status ---
+
+type WatchStatusInput struct{}
+
+var watchStatusTool = &mcp.Tool{
+	Name:        "watch_status",
+	Description: "Summarize the notification subsystem for this credential: each push connection's status and, per watch, the Email state last seen, delivered, pending (unread at the resource), and held events, and the last error. Lighter than watch_list, and also includes watches that run automations.",
+	Annotations: readOnlyAnnotations,
+}
+
+func (s *Server) handleWatchStatus(ctx context.Context, _ *mcp.CallToolRequest, _ WatchStatusInput) (*mcp.CallToolResult, any, error) {
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	st := s.watches.status(s.ownerOf(token), time.Now())
+	if st.Watches == 0 {
+		return textResult("No watches"), nil, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Watches: %d, pending %d, held %d, failing %d\n", st.Watches, st.Pending, st.Held, st.Failing)
+	for _, l := range st.Listeners {
+		mode := l.Mode
+		if mode == "" {
+			mode = "stopped"
+		}
+		fmt.Fprintf(&sb, "\n%s: %s\n", l.Endpoint, mode)
+		for _, w := range l.Watches {
+			fmt.Fprintf(&sb, "  %s ", w.ID)
+			switch {
+			case w.Automation != "":
+				fmt.Fprintf(&sb, "automation %s, mailbox %s", w.Automation, w.MailboxID)
+			case w.MailboxID != "":
+				fmt.Fprintf(&sb, "mailbox %s", w.MailboxID)
+			default:
+				fmt.Fprintf(&sb, "thread %s", w.ThreadID)
+			}
+			fmt.Fprintf(&sb, ": state %s, delivered %d, pending %d, held %d", w.State, w.Delivered, w.Pending, w.Held)
+			if w.LastError != "" {
+				fmt.Fprintf(&sb, ", error: %s", w.LastError)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	result := textResult(sb.String())
+	if s.structuredOutput {
+		result.StructuredContent = st
+	}
+	return result, nil, nil
+}
+
+// --- watch_delete ---
+
+type WatchDeleteInput struct {
+	ID string `json:"id" jsonschema:"Watch ID from watch_create or watch_list (e.g. watch-3)"`
+}
+
+var watchDeleteTool = &mcp.Tool{
+	Name:        "watch_delete",
+	Description: "Delete a watch, dropping its pending events. The push connection closes with the last watch.",
+	Annotations: idempotentAnnotations,
+}
+
+func (s *Server) handleWatchDelete(ctx context.Context, _ *mcp.CallToolRequest, in WatchDeleteInput) (*mcp.CallToolResult, any, error) {
+	if in.ID == "" {
+		return errorResult(invalidArgument("id is required")), nil, nil
+	}
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return errorResult(err), nil, nil
+	}
+	owner := s.ownerOf(token)
+	removed := s.watches.remove(func(w *watch) bool { return w.ID == in.ID && w.Owner == owner })
+	if len(removed) == 0 {
+		return errorResult(fmt.Errorf("no watch %q", in.ID)), nil, nil
+	}
+	return textResult(fmt.Sprintf("Deleted %s", in.ID)), nil, nil
+}
+
+// watchLimits describes w's quiet hours and rate limit, or returns "".
+func watchLimits(w *watch) string {
+	var parts []string
+	if w.Quiet != nil {
+		parts = append(parts, "quiet "+w.Quiet.String())
+	}
+	if w.MaxPerHour > 0 {
+		parts = append(parts, fmt.Sprintf("at most %d per hour", w.MaxPerHour))
+	}
+	return strings.Join(parts, ", ")
+}
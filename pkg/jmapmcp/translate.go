@@ -0,0 +1,75 @@
+package jmapmcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// translateTimeout bounds a single translation call so a slow endpoint
+// cannot stall email_get.
+const translateTimeout = 20 * time.Second
+
+// translator calls a LibreTranslate-compatible endpoint (POST JSON
+// {q, source, target, format, api_key} → {translatedText}).
+type translator struct {
+	url    string
+	apiKey string
+	target string // ISO 639-1 code bodies are translated into
+	client *http.Client
+}
+
+func newTranslator(url, apiKey, target string) *translator {
+	if target == "" {
+		target = "en"
+	}
+	return &translator{
+		url:    url,
+		apiKey: apiKey,
+		target: target,
+		client: &http.Client{Timeout: translateTimeout},
+	}
+}
+
+// translate renders text from source (ISO 639-1, or "auto") into the
+// configured target language.
+func (t *translator) translate(ctx context.Context, text, source string) (string, error) {
+	if source == "" {
+		source = "auto"
+	}
+	body, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  source,
+		"target":  t.target,
+		"format":  "text",
+		"api_key": t.apiKey,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translation endpoint returned %s", resp.Status)
+	}
+
+	var out struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode translation: %w", err)
+	}
+	return out.TranslatedText, nil
+}
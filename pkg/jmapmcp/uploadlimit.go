@@ -0,0 +1,75 @@
+package jmapmcp
+
+import (
+	"fmt"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/sieve"
+)
+
+// checkUploadSize rejects a blob upload of size bytes that would exceed the
+// server's advertised maxSizeUpload (RFC 8620 §2). Checking up front turns an
+// opaque mid-operation rejection into an error that names the limit.
+// A missing or zero limit means the server did not advertise one.
+func checkUploadSize(session *jmap.Session, what string, size int) error {
+	if session == nil {
+		return nil
+	}
+	c, ok := session.Capabilities[jmap.CoreURI].(*core.Core)
+	if !ok || c.MaxSizeUpload == 0 {
+		return nil
+	}
+	if uint64(size) > c.MaxSizeUpload {
+		return fmt.Errorf("%s is %d bytes, exceeding the server upload limit of %d bytes (maxSizeUpload); reduce its size or split it into smaller parts", what, size, c.MaxSizeUpload)
+	}
+	return nil
+}
+
+// checkSieveScriptSize applies the generic upload limit plus the Sieve
+// capability's maxSizeScript (RFC 9661 §2), whichever is stricter.
+func checkSieveScriptSize(session *jmap.Session, size int) error {
+	if err := checkUploadSize(session, "sieve script", size); err != nil {
+		return err
+	}
+	if session == nil {
+		return nil
+	}
+	c, ok := session.Capabilities[sieve.URI].(*sieve.Capability)
+	if !ok || c.MaxSizeScript == nil {
+		return nil
+	}
+	if uint64(size) > *c.MaxSizeScript {
+		return fmt.Errorf("sieve script is %d bytes, exceeding the server script limit of %d bytes (maxSizeScript); move rules into a separate script and use the include extension", size, *c.MaxSizeScript)
+	}
+	return nil
+}
+
+// checkAttachmentsSize rejects a set of attachments whose combined size
+// would exceed the account's maxSizeAttachmentsPerEmail (RFC 8621 §1.3.1),
+// and each attachment individually against maxSizeUpload.
+func checkAttachmentsSize(session *jmap.Session, accountID jmap.ID, sizes []int) error {
+	if session == nil {
+		return nil
+	}
+	total := 0
+	for i, size := range sizes {
+		if err := checkUploadSize(session, fmt.Sprintf("attachment %d", i+1), size); err != nil {
+			return err
+		}
+		total += size
+	}
+	acct, ok := session.Accounts[accountID]
+	if !ok {
+		return nil
+	}
+	c, ok := acct.Capabilities[mail.URI].(*mail.Mail)
+	if !ok || c.MaxSizeAttachmentsPerEmail == 0 {
+		return nil
+	}
+	if uint64(total) > c.MaxSizeAttachmentsPerEmail {
+		return fmt.Errorf("attachments total %d bytes, exceeding the server limit of %d bytes per email (maxSizeAttachmentsPerEmail); send them across several emails or share a link instead", total, c.MaxSizeAttachmentsPerEmail)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package jmapmcp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core"
+	"github.com/mikluko/jmap/mail"
+	"github.com/mikluko/jmap/sieve"
+)
+
+func TestCheckUploadSize(t *testing.T) {
+	session := &jmap.Session{Capabilities: map[jmap.URI]jmap.Capability{
+		jmap.CoreURI: &core.Core{MaxSizeUpload: 100},
+	}}
+
+	if err := checkUploadSize(session, "blob", 100); err != nil {
+		t.Fatalf("at limit: unexpected error %v", err)
+	}
+	err := checkUploadSize(session, "blob", 101)
+	if err == nil {
+		t.Fatal("expected error above limit")
+	}
+	if !strings.Contains(err.Error(), "100 bytes") {
+		t.Fatalf("error should name the limit, got: %v", err)
+	}
+	if err := checkUploadSize(&jmap.Session{}, "blob", 1<<30); err != nil {
+		t.Fatalf("no advertised limit: unexpected error %v", err)
+	}
+}
+
+func TestCheckSieveScriptSize(t *testing.T) {
+	limit := uint64(10)
+	session := &jmap.Session{Capabilities: map[jmap.URI]jmap.Capability{
+		jmap.CoreURI: &core.Core{MaxSizeUpload: 100},
+		sieve.URI:    &sieve.Capability{MaxSizeScript: &limit},
+	}}
+
+	if err := checkSieveScriptSize(session, 10); err != nil {
+		t.Fatalf("at limit: unexpected error %v", err)
+	}
+	err := checkSieveScriptSize(session, 11)
+	if err == nil || !strings.Contains(err.Error(), "maxSizeScript") {
+		t.Fatalf("expected maxSizeScript error, got: %v", err)
+	}
+	err = checkSieveScriptSize(session, 101)
+	if err == nil || !strings.Contains(err.Error(), "maxSizeUpload") {
+		t.Fatalf("expected maxSizeUpload error, got: %v", err)
+	}
+}
+
+func TestCheckAttachmentsSize(t *testing.T) {
+	session := &jmap.Session{
+		Capabilities: map[jmap.URI]jmap.Capability{
+			jmap.CoreURI: &core.Core{MaxSizeUpload: 60},
+		},
+		Accounts: map[jmap.ID]jmap.Account{
+			"a1": {Capabilities: map[jmap.URI]jmap.Capability{
+				mail.URI: &mail.Mail{MaxSizeAttachmentsPerEmail: 100},
+			}},
+		},
+	}
+
+	if err := checkAttachmentsSize(session, "a1", []int{50, 50}); err != nil {
+		t.Fatalf("at limit: unexpected error %v", err)
+	}
+	err := checkAttachmentsSize(session, "a1", []int{50, 51})
+	if err == nil || !strings.Contains(err.Error(), "maxSizeAttachmentsPerEmail") {
+		t.Fatalf("expected per-email error, got: %v", err)
+	}
+	err = checkAttachmentsSize(session, "a1", []int{61})
+	if err == nil || !strings.Contains(err.Error(), "attachment 1") {
+		t.Fatalf("expected per-upload error naming the attachment, got: %v", err)
+	}
+}
@@ -0,0 +1,919 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap/core/push"
+	"github.com/mikluko/jmap/mail/email"
+	"github.com/mikluko/jmap/mail/thread"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Watches turn JMAP push StateChanges, which only say that some Email
+// changed, into notifications about what the client asked to follow: new
+// messages in a mailbox, or new messages and keyword changes in a thread.
+// Each watch keeps the Email state it has seen; on every StateChange the
+// listener diffs from there with Email/changes and Email/get, and delivers
+// matches as notifications/message (logger "jmap-mcp.watch") to the session
+// that created the watch, and as notifications/resources/updated to
+// subscribers of jmap://watch/{id}. Reading that resource drains the
+// pending events, so a client that drops notifications loses nothing.
+//
+// One listener per owner and endpoint holds the event source (or, when the
+// backend offers it, an RFC 8887 WebSocket with push) open while the owner
+// has watches. On servers without push (no eventSourceUrl) the
+// listener polls Email/changes every watchPollInterval instead. Watches live
+// in memory and end with their session.
+//
+// A watch may hold its notifications during quiet hours and cap them per
+// hour. Held events stay readable at the resource; when the window opens,
+// they are sent combined, one notification per kind.
+
+// DefaultWatchPollInterval is how often watches poll on servers without
+// push unless WithWatchPollInterval says otherwise.
+const DefaultWatchPollInterval = time.Minute
+
+const (
+	maxWatchPending    = 100 // undrained events kept per watch; older ones are dropped
+	maxTenantWatches   = 50  // watch_create watches one tenant may hold at a time
+	maxWatchChanges    = 256 // Email/changes page size
+	watchRetryMin      = time.Second
+	watchRetryMax      = time.Minute
+	watchLoggerName    = "jmap-mcp.watch"
+	watchURIPrefix     = "jmap://watch/"
+	watchNewMessages   = "new_messages"
+	watchKeywordChange = "keywords_changed"
+)
+
+// watchEvent is one notification: the emails that matched a watch since
+// the previous one.
+type watchEvent struct {
+	Watch     string               `json:"watch"`
+	Kind      string               `json:"kind"`
+	MailboxID jmap.ID              `json:"mailboxId,omitempty"`
+	ThreadID  jmap.ID              `json:"threadId,omitempty"`
+	EmailIDs  []jmap.ID            `json:"emailIds"`
+	VIP       []jmap.ID            `json:"vipEmailIds,omitempty"` // new_messages: those of EmailIDs from VIPs
+	Keywords  map[jmap.ID][]string `json:"keywords,omitempty"`    // keywords_changed: each email's keywords now
+	At        time.Time            `json:"at"`
+}
+
+// watch is one registered interest. Owner is a hash of the JMAP token, as
+// for the outbox.
+type watch struct {
+	ID         string
+	Owner      string
+	Endpoint   string
+	AccountID  jmap.ID
+	MailboxID  jmap.ID     // new messages in this mailbox; empty for a thread watch
+	ThreadID   jmap.ID     // new messages and keyword changes in this thread
+	Keywords   []string    // thread watch: only changes to these keywords; empty for any
+	Quiet      *quietHours // hold notifications in this daily window; nil for none
+	MaxPerHour int         // notifications per rolling hour; 0 for no limit
+	CreatedAt  time.Time
+
+	automation string // ID of the automation the watch runs instead of notifying; empty for watch_create
+
+	session   *mcp.ServerSession // nil when created outside a session
+	state     string             // Email state the next diff starts from
+	snapshot  map[jmap.ID][]string
+	delivered int
+	pending   []watchEvent
+	lastError string
+	held      []watchEvent // events whose notification waits for quiet hours or the rate
+	notified  []time.Time  // notifications of the last hour, with MaxPerHour
+	releasing bool         // a timer will send the held events
+}
+
+// listenerKey groups watches that share an event source connection.
+func (w *watch) listenerKey() string {
+	return w.Owner + "\x00" + w.Endpoint
+}
+
+// watchListener is the event source connection of one owner and endpoint.
+type watchListener struct {
+	cancel context.CancelFunc
+	mode   string // shown by watch_list
+}
+
+// watchRegistry holds the watches and their listeners. The zero value is
+// ready to use.
+type watchRegistry struct {
+	mu        sync.Mutex
+	seq       int
+	watches   map[string]*watch
+	listeners map[string]*watchListener
+}
+
+// add registers w and reports whether its listener must be started.
+func (r *watchRegistry) add(w *watch) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watches == nil {
+		r.watches = make(map[string]*watch)
+		r.listeners = make(map[string]*watchListener)
+	}
+	r.seq++
+	w.ID = fmt.Sprintf("watch-%d", r.seq)
+	r.watches[w.ID] = w
+	key := w.listenerKey()
+	if _, ok := r.listeners[key]; ok {
+		return w.ID, false
+	}
+	r.listeners[key] = &watchListener{mode: "connecting"}
+	return w.ID, true
+}
+
+// setCancel records how to stop the listener for key, or stops it at once
+// when its watches were removed before it started.
+func (r *watchRegistry) setCancel(key string, cancel context.CancelFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.listeners[key]
+	if !ok {
+		cancel()
+		return
+	}
+	l.cancel = cancel
+}
+
+func (r *watchRegistry) setMode(key, mode string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.listeners[key]; ok {
+		l.mode = mode
+	}
+}
+
+func (r *watchRegistry) mode(key string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.listeners[key]; ok {
+		return l.mode
+	}
+	return ""
+}
+
+// list returns copies of owner's watches, oldest first.
+func (r *watchRegistry) list(owner string) []watch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []watch
+	for _, w := range r.watches {
+		if w.Owner == owner && w.automation == "" {
+			out = append(out, *w)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// hasAutomation reports whether owner has a watch running automation id.
+func (r *watchRegistry) hasAutomation(owner, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range r.watches {
+		if w.Owner == owner && w.automation == id {
+			return true
+		}
+	}
+	return false
+}
+
+// forKey returns the watches served by the listener for key.
+func (r *watchRegistry) forKey(key string) []*watch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*watch
+	for _, w := range r.watches {
+		if w.listenerKey() == key {
+			out = append(out, w)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// remove deletes the watches match selects and stops listeners left
+// without watches. It returns the removed watches.
+func (r *watchRegistry) remove(match func(*watch) bool) []*watch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var removed []*watch
+	for id, w := range r.watches {
+		if match(w) {
+			delete(r.watches, id)
+			removed = append(removed, w)
+		}
+	}
+	inUse := make(map[string]bool)
+	for _, w := range r.watches {
+		inUse[w.listenerKey()] = true
+	}
+	for key, l := range r.listeners {
+		if inUse[key] {
+			continue
+		}
+		if l.cancel != nil {
+			l.cancel()
+		}
+		delete(r.listeners, key)
+	}
+	return removed
+}
+
+// baseline returns w's diff state and snapshot.
+func (r *watchRegistry) baseline(w *watch) (string, map[jmap.ID][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return w.state, w.snapshot
+}
+
+// advance stores the diff state and snapshot after a check, and queues
+// events.
+func (r *watchRegistry) advance(w *watch, state string, snapshot map[jmap.ID][]string, events []watchEvent, checkErr error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if checkErr != nil {
+		w.lastError = checkErr.Error()
+		return
+	}
+	w.lastError = ""
+	w.state = state
+	w.snapshot = snapshot
+	w.delivered += len(events)
+	w.pending = append(w.pending, events...)
+	if over := len(w.pending) - maxWatchPending; over > 0 {
+		w.pending = slices.Delete(w.pending, 0, over)
+	}
+}
+
+// release queues events for notification on w and returns those to send
+// now with the session to send them to. When quiet hours or the rate hold
+// them, wait is how long until they may go out, if no timer is waiting for
+// that yet; timer marks the call of that timer.
+func (r *watchRegistry) release(w *watch, events []watchEvent, now time.Time, timer bool) (send []watchEvent, session *mcp.ServerSession, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watches[w.ID] != w {
+		return nil, nil, 0
+	}
+	if timer {
+		w.releasing = false
+	}
+	w.held = append(w.held, events...)
+	if len(w.held) == 0 {
+		return nil, nil, 0
+	}
+
+	w.notified = slices.DeleteFunc(w.notified, func(t time.Time) bool { return !t.After(now.Add(-time.Hour)) })
+	at := now
+	if w.Quiet != nil && w.Quiet.contains(now) {
+		at = w.Quiet.endAfter(now)
+	}
+	if w.MaxPerHour > 0 && len(w.notified) >= w.MaxPerHour {
+		if next := w.notified[len(w.notified)-w.MaxPerHour].Add(time.Hour); next.After(at) {
+			at = next
+		}
+	}
+	if at.After(now) {
+		if w.releasing {
+			return nil, nil, 0
+		}
+		w.releasing = true
+		return nil, nil, at.Sub(now)
+	}
+
+	send = combineWatchEvents(w.held)
+	w.held = nil
+	if w.MaxPerHour > 0 {
+		w.notified = append(w.notified, now)
+	}
+	return send, w.session, 0
+}
+
+// combineWatchEvents merges events into one per kind, in order of first
+// appearance, keeping each email once with its latest keywords.
+func combineWatchEvents(events []watchEvent) []watchEvent {
+	var out []watchEvent
+	for _, ev := range events {
+		i := slices.IndexFunc(out, func(o watchEvent) bool { return o.Kind == ev.Kind })
+		if i < 0 {
+			ev.EmailIDs = slices.Clone(ev.EmailIDs)
+			ev.VIP = slices.Clone(ev.VIP)
+			if ev.Keywords != nil {
+				ev.Keywords = maps.Clone(ev.Keywords)
+			}
+			out = append(out, ev)
+			continue
+		}
+		o := &out[i]
+		for _, id := range ev.EmailIDs {
+			if !slices.Contains(o.EmailIDs, id) {
+				o.EmailIDs = append(o.EmailIDs, id)
+			}
+		}
+		for _, id := range ev.VIP {
+			if !slices.Contains(o.VIP, id) {
+				o.VIP = append(o.VIP, id)
+			}
+		}
+		for id, kws := range ev.Keywords {
+			if o.Keywords == nil {
+				o.Keywords = make(map[jmap.ID][]string)
+			}
+			o.Keywords[id] = kws
+		}
+		o.At = ev.At
+	}
+	return out
+}
+
+// drain returns and clears owner's pending events on watch id.
+func (r *watchRegistry) drain(owner, id string) ([]watchEvent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.watches[id]
+	if !ok || w.Owner != owner {
+		return nil, false
+	}
+	events := w.pending
+	w.pending = nil
+	return events, true
+}
+
+// quietHours is a daily window, possibly across midnight, in a time zone.
+type quietHours struct {
+	start, end time.Duration // offsets from midnight
+	loc        *time.Location
+}
+
+// parseQuietHours parses "HH:MM-HH:MM" in the IANA time zone tz (UTC when
+// empty); the window may cross midnight, as in 22:00-07:00.
+func parseQuietHours(s, tz string) (*quietHours, error) {
+	loc, err := eventLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	a, b, ok := strings.Cut(s, "-")
+	if ok {
+		start, err1 := time.Parse("15:04", strings.TrimSpace(a))
+		end, err2 := time.Parse("15:04", strings.TrimSpace(b))
+		if err1 == nil && err2 == nil && !end.Equal(start) {
+			midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+			return &quietHours{start: start.Sub(midnight), end: end.Sub(midnight), loc: loc}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid quiet_hours %q: expected HH:MM-HH:MM with different ends", s)
+}
+
+// sinceMidnight returns t's offset from its local midnight in q's zone,
+// with that midnight.
+func (q *quietHours) sinceMidnight(t time.Time) (time.Duration, time.Time) {
+	t = t.In(q.loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, q.loc)
+	return t.Sub(midnight), midnight
+}
+
+// contains reports whether t falls in the window.
+func (q *quietHours) contains(t time.Time) bool {
+	d, _ := q.sinceMidnight(t)
+	if q.start < q.end {
+		return d >= q.start && d < q.end
+	}
+	return d >= q.start || d < q.end
+}
+
+// endAfter returns when the window that contains t ends.
+func (q *quietHours) endAfter(t time.Time) time.Time {
+	d, midnight := q.sinceMidnight(t)
+	if d >= q.end {
+		midnight = midnight.AddDate(0, 0, 1)
+	}
+	return midnight.Add(q.end)
+}
+
+func (q *quietHours) String() string {
+	clock := func(d time.Duration) string { return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60) }
+	return fmt.Sprintf("%s-%s %s", clock(q.start), clock(q.end), q.loc)
+}
+
+// watchURI returns the resource URI of a watch's pending events.
+func watchURI(id string) string {
+	return watchURIPrefix + id
+}
+
+// --- baseline and diff ---
+
+// watchBaseline returns the current Email state and, for a thread watch,
+// the keywords of each email in the thread.
+func watchBaseline(ctx context.Context, client JMAPClient, accountID, mailboxID, threadID jmap.ID) (string, map[jmap.ID][]string, error) {
+	req := &jmap.Request{Context: ctx}
+	if threadID != "" {
+		threadCallID := req.Invoke(&thread.Get{Account: accountID, IDs: []jmap.ID{threadID}})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: threadCallID, Name: "Thread/get", Path: "/list/*/emailIds"},
+			Properties:   []string{"id", "keywords"},
+		})
+	} else {
+		// Email/get needs some IDs to return a state without listing
+		// the whole account.
+		queryCallID := req.Invoke(&email.Query{
+			Account: accountID,
+			Filter:  &email.FilterCondition{InMailbox: mailboxID},
+			Limit:   1,
+		})
+		req.Invoke(&email.Get{
+			Account:      accountID,
+			ReferenceIDs: &jmap.ResultReference{ResultOf: queryCallID, Name: "Email/query", Path: "/ids"},
+			Properties:   []string{"id"},
+		})
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var state string
+	var snapshot map[jmap.ID][]string
+	for _, inv := range callResponses(req, resp) {
+		switch args := inv.Args.(type) {
+		case *thread.GetResponse:
+			if len(args.List) == 0 {
+				return "", nil, notFoundError([]jmap.ID{threadID}, "thread %s not found", threadID)
+			}
+		case *email.QueryResponse:
+		case *email.GetResponse:
+			state = args.State
+			if threadID != "" {
+				snapshot = make(map[jmap.ID][]string, len(args.List))
+				for _, e := range args.List {
+					snapshot[e.ID] = setKeywords(e.Keywords)
+				}
+			}
+		case *jmap.MethodError:
+			return "", nil, args
+		default:
+			return "", nil, fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+	if state == "" {
+		return "", nil, fmt.Errorf("server returned no Email state")
+	}
+	return state, snapshot, nil
+}
+
+// checkWatch diffs w from its state and returns the events to deliver with
+// the new state and snapshot; new messages from vips are flagged. When the
+// server can no longer calculate changes from the state, the watch is
+// rebased without events.
+func checkWatch(ctx context.Context, client JMAPClient, w *watch, state string, snapshot map[jmap.ID][]string, vips []string) ([]watchEvent, string, map[jmap.ID][]string, error) {
+	created, updated, destroyed, newState, err := emailChangesSince(ctx, client, w.AccountID, state)
+	if me, ok := err.(*jmap.MethodError); ok && me.Type == "cannotCalculateChanges" {
+		newState, snapshot, err := watchBaseline(ctx, client, w.AccountID, w.MailboxID, w.ThreadID)
+		return nil, newState, snapshot, err
+	}
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if len(created)+len(updated) == 0 && len(destroyed) == 0 {
+		return nil, newState, snapshot, nil
+	}
+
+	isCreated := make(map[jmap.ID]bool, len(created))
+	for _, id := range created {
+		isCreated[id] = true
+	}
+	var emails []*email.Email
+	if ids := append(slices.Clone(created), updated...); len(ids) > 0 {
+		emails, err = getWatchedEmails(ctx, client, w.AccountID, ids)
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+
+	now := time.Now()
+	var events []watchEvent
+	if w.MailboxID != "" {
+		var ids, vip []jmap.ID
+		for _, e := range emails {
+			if isCreated[e.ID] && e.MailboxIDs[w.MailboxID] {
+				ids = append(ids, e.ID)
+				if senderListed(e.From, vips) {
+					vip = append(vip, e.ID)
+				}
+			}
+		}
+		if len(ids) > 0 {
+			events = append(events, watchEvent{Watch: w.ID, Kind: watchNewMessages, MailboxID: w.MailboxID, EmailIDs: ids, VIP: vip, At: now})
+		}
+		return events, newState, nil, nil
+	}
+
+	next := make(map[jmap.ID][]string, len(snapshot))
+	for id, kws := range snapshot {
+		next[id] = kws
+	}
+	for _, id := range destroyed {
+		delete(next, id)
+	}
+	var added, addedVIP []jmap.ID
+	var changed []jmap.ID
+	keywords := map[jmap.ID][]string{}
+	for _, e := range emails {
+		if e.ThreadID != w.ThreadID {
+			continue
+		}
+		kws := setKeywords(e.Keywords)
+		old, known := next[e.ID]
+		next[e.ID] = kws
+		switch {
+		case !known:
+			added = append(added, e.ID)
+			if senderListed(e.From, vips) {
+				addedVIP = append(addedVIP, e.ID)
+			}
+		case keywordsDiffer(old, kws, w.Keywords):
+			changed = append(changed, e.ID)
+			keywords[e.ID] = kws
+		}
+	}
+	if len(added) > 0 {
+		events = append(events, watchEvent{Watch: w.ID, Kind: watchNewMessages, ThreadID: w.ThreadID, EmailIDs: added, VIP: addedVIP, At: now})
+	}
+	if len(changed) > 0 {
+		events = append(events, watchEvent{Watch: w.ID, Kind: watchKeywordChange, ThreadID: w.ThreadID, EmailIDs: changed, Keywords: keywords, At: now})
+	}
+	return events, newState, next, nil
+}
+
+// emailChangesSince pages through Email/changes from state.
+func emailChangesSince(ctx context.Context, client JMAPClient, accountID jmap.ID, state string) (created, updated, destroyed []jmap.ID, newState string, err error) {
+	for {
+		req := &jmap.Request{Context: ctx}
+		req.Invoke(&email.Changes{Account: accountID, SinceState: state, MaxChanges: maxWatchChanges})
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, nil, nil, "", err
+		}
+		if len(resp.Responses) == 0 {
+			return nil, nil, nil, "", fmt.Errorf("empty response for Email/changes")
+		}
+		switch args := resp.Responses[0].Args.(type) {
+		case *email.ChangesResponse:
+			created = append(created, args.Created...)
+			updated = append(updated, args.Updated...)
+			destroyed = append(destroyed, args.Destroyed...)
+			state = args.NewState
+			if !args.HasMoreChanges {
+				return created, updated, destroyed, state, nil
+			}
+		case *jmap.MethodError:
+			return nil, nil, nil, "", args
+		default:
+			return nil, nil, nil, "", fmt.Errorf("unexpected response type: %T", args)
+		}
+	}
+}
+
+// getWatchedEmails fetches the properties checkWatch matches on.
+func getWatchedEmails(ctx context.Context, client JMAPClient, accountID jmap.ID, ids []jmap.ID) ([]*email.Email, error) {
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{Account: accountID, IDs: ids, Properties: []string{"id", "mailboxIds", "threadId", "keywords", "from"}})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("empty response for Email/get")
+	}
+	switch args := resp.Responses[0].Args.(type) {
+	case *email.GetResponse:
+		return args.List, nil
+	case *jmap.MethodError:
+		return nil, args
+	default:
+		return nil, fmt.Errorf("unexpected response type: %T", args)
+	}
+}
+
+// setKeywords returns the keywords set in kws, sorted.
+func setKeywords(kws map[string]bool) []string {
+	var out []string
+	for _, kw := range sortedKeys(kws) {
+		if kws[kw] {
+			out = append(out, kw)
+		}
+	}
+	return out
+}
+
+// keywordsDiffer reports whether old and now differ, looking only at only
+// when it is not empty.
+func keywordsDiffer(old, now, only []string) bool {
+	if len(only) == 0 {
+		return !slices.Equal(old, now)
+	}
+	for _, kw := range only {
+		if slices.Contains(old, kw) != slices.Contains(now, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- listener ---
+
+// startWatchListener opens the event source for key in the background. The
+// listener dials with token for as long as the owner has watches there.
+func (s *Server) startWatchListener(key, sessionURL, token string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.watches.setCancel(key, cancel)
+	go s.runWatchListener(ctx, key, sessionURL, token)
+}
+
+// runWatchListener reconnects the event source until ctx is cancelled,
+// backing off while connections fail or close at once without events.
+// Each connection uses the current token, should the static one rotate.
+func (s *Server) runWatchListener(ctx context.Context, key, sessionURL, token string) {
+	backoff := watchRetryMin
+	for {
+		token = s.currentToken(token)
+		started := time.Now()
+		got, err := s.listenWatches(ctx, key, sessionURL, token)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == errNoEventSource {
+			if s.watchPollInterval > 0 {
+				s.pollWatches(ctx, key, sessionURL, token)
+				return
+			}
+			s.watches.setMode(key, "unavailable: server advertises no eventSourceUrl")
+			return
+		}
+		if err == nil && (got || time.Since(started) >= watchRetryMin) {
+			backoff = watchRetryMin
+			continue
+		}
+		if err != nil {
+			s.watches.setMode(key, "reconnecting: "+err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, watchRetryMax)
+	}
+}
+
+var errNoEventSource = fmt.Errorf("no event source")
+
+// hasPush reports whether session offers push the listener can use: an
+// event source, or WebSocket push when WebSocket is enabled.
+func (s *Server) hasPush(session *jmap.Session) bool {
+	if session == nil {
+		return false
+	}
+	if cap, ok := sessionWebSocket(session); ok && cap.SupportsPush && !s.noWebSocket {
+		return true
+	}
+	return session.EventSourceURL != ""
+}
+
+// listenWatches runs one event source connection: it catches the watches
+// up, then checks them on every Email StateChange until the stream ends.
+// It reports whether any StateChange arrived.
+func (s *Server) listenWatches(ctx context.Context, key, sessionURL, token string) (bool, error) {
+	c, err := s.dialer.Dial(ctx, sessionURL, token)
+	if err != nil {
+		return false, err
+	}
+	if !s.hasPush(c.Session) {
+		return false, errNoEventSource
+	}
+	hc := *c.HttpClient
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hc.Transport = contextTransport{base: base, ctx: ctx}
+	c.HttpClient = &hc
+	client := s.wrapClient(ctx, c, token)
+	if cap, ok := sessionWebSocket(c.Session); ok && cap.SupportsPush && !s.noWebSocket {
+		return s.listenWatchesWebSocket(ctx, key, client, cap, token)
+	}
+
+	s.checkWatches(ctx, client, key)
+	s.watches.setMode(key, "push")
+
+	got := false
+	es := &push.EventSource{
+		Client: c,
+		Events: []jmap.EventType{"Email"},
+		Handler: func(sc *jmap.StateChange) {
+			got = true
+			for _, types := range sc.Changed {
+				if _, ok := types["Email"]; ok {
+					s.checkWatches(ctx, client, key)
+					return
+				}
+			}
+		},
+	}
+	return got, es.Listen()
+}
+
+// pollWatches checks the watches for key every watchPollInterval until ctx
+// is cancelled, for servers without push. A failed dial is retried on the
+// next tick, and a rotated token dials again.
+func (s *Server) pollWatches(ctx context.Context, key, sessionURL, token string) {
+	mode := fmt.Sprintf("polling every %s (server has no push)", s.watchPollInterval)
+	s.watches.setMode(key, mode)
+	ticker := time.NewTicker(s.watchPollInterval)
+	defer ticker.Stop()
+
+	var client JMAPClient
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if t := s.currentToken(token); t != token {
+			token, client = t, nil
+		}
+		if client == nil {
+			c, err := s.dialer.Dial(ctx, sessionURL, token)
+			if err != nil {
+				s.watches.setMode(key, mode+": "+err.Error())
+				continue
+			}
+			s.watches.setMode(key, mode)
+			client = s.wrapClient(ctx, c, token)
+		}
+		s.checkWatches(ctx, client, key)
+	}
+}
+
+// listenWatchesWebSocket is listenWatches over a dedicated RFC 8887
+// connection with push enabled for Email.
+func (s *Server) listenWatchesWebSocket(ctx context.Context, key string, client JMAPClient, cap *websocketCapability, token string) (bool, error) {
+	conn, err := s.dialWebSocket(ctx, cap.URL, client.Session().APIURL, token)
+	if err != nil {
+		return false, err
+	}
+	defer conn.close()
+	if err := conn.enablePush([]string{"Email"}); err != nil {
+		return false, err
+	}
+
+	s.checkWatches(ctx, client, key)
+	s.watches.setMode(key, "push (websocket)")
+
+	got := false
+	for {
+		select {
+		case <-conn.pushes:
+			got = true
+			s.checkWatches(ctx, client, key)
+		case <-conn.done:
+			conn.mu.Lock()
+			defer conn.mu.Unlock()
+			return got, conn.err
+		case <-ctx.Done():
+			return got, nil
+		}
+	}
+}
+
+// checkWatches diffs every watch for key and delivers what matched.
+func (s *Server) checkWatches(ctx context.Context, client JMAPClient, key string) {
+	vips := s.vipsOf(client)
+	for _, w := range s.watches.forKey(key) {
+		state, snapshot := s.watches.baseline(w)
+		events, state, snapshot, err := checkWatch(ctx, client, w, state, snapshot, vips)
+		if w.automation != "" {
+			s.watches.advance(w, state, snapshot, nil, err)
+			if err == nil {
+				s.runAutomation(ctx, client, w, events)
+			}
+			continue
+		}
+		s.watches.advance(w, state, snapshot, events, err)
+		s.releaseWatch(ctx, w, events, false)
+	}
+}
+
+// releaseWatch notifies the events of w that quiet hours and the rate let
+// through, and otherwise arranges to send them when they do.
+func (s *Server) releaseWatch(ctx context.Context, w *watch, events []watchEvent, timer bool) {
+	send, session, wait := s.watches.release(w, events, time.Now(), timer)
+	for _, ev := range send {
+		s.notifyWatch(ctx, session, ev)
+	}
+	if wait > 0 {
+		time.AfterFunc(wait, func() { s.releaseWatch(context.Background(), w, nil, true) })
+	}
+}
+
+// notifyWatch sends ev to the session that created the watch, at warning
+// level when it holds mail from VIPs, and tells resource subscribers that
+// the watch has pending events. Delivery errors are ignored: the events
+// stay pending until read.
+func (s *Server) notifyWatch(ctx context.Context, session *mcp.ServerSession, ev watchEvent) {
+	if session != nil {
+		level := mcp.LoggingLevel("notice")
+		if len(ev.VIP) > 0 {
+			level = "warning"
+		}
+		_ = session.Log(ctx, &mcp.LoggingMessageParams{
+			Level:  level,
+			Logger: watchLoggerName,
+			Data:   ev,
+		})
+	}
+	_ = s.mcp.ResourceUpdated(ctx, &mcp.ResourceUpdatedNotificationParams{URI: watchURI(ev.Watch)})
+}
+
+// contextTransport binds requests to ctx, so cancelling it closes the
+// event source stream.
+type contextTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+}
+
+func (t contextTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(r.WithContext(t.ctx))
+}
+
+// --- jmap://watch/{id} ---
+
+var watchTemplate = &mcp.ResourceTemplate{
+	Name:        "watch_events",
+	Title:       "Watch events",
+	URITemplate: "jmap://watch/{id}",
+	Description: "Pending events of a watch created with watch_create, as a JSON array; reading drains them. Subscribe to be notified when events arrive.",
+	MIMEType:    "application/json",
+}
+
+func (s *Server) readWatch(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	id, ok := strings.CutPrefix(uri, watchURIPrefix)
+	if !ok || id == "" {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	token, err := s.resolveToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events, ok := s.watches.drain(s.ownerOf(token), id)
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	if events == nil {
+		events = []watchEvent{}
+	}
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{{
+		URI:      uri,
+		MIMEType: "application/json",
+		Text:     string(data),
+	}}}, nil
+}
+
+// subscribeResource accepts subscriptions to watch resources; the SDK
+// tracks subscribers and ResourceUpdated notifies them.
+func subscribeResource(_ context.Context, req *mcp.SubscribeRequest) error {
+	if !strings.HasPrefix(req.Params.URI, watchURIPrefix) {
+		return fmt.Errorf("subscriptions are supported for %s{id} resources only", watchURIPrefix)
+	}
+	return nil
+}
+
+func unsubscribeResource(context.Context, *mcp.UnsubscribeRequest) error {
+	return nil
+}
@@ -0,0 +1,311 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestCheckWatchMailboxReportsNewMessages(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	addEmail(fake, "e1", "Old", "old", 1)
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, _, err := watchBaseline(ctx, client, jmapfake.AccountID, "inbox", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addEmail(fake, "e2", "New", "new", 2)
+	fake.Add("Email", map[string]any{"id": "e3", "mailboxIds": map[string]any{"archive": true}})
+
+	w := &watch{ID: "watch-1", AccountID: jmapfake.AccountID, MailboxID: "inbox"}
+	events, next, _, err := checkWatch(ctx, client, w, state, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Kind != watchNewMessages || len(events[0].EmailIDs) != 1 || events[0].EmailIDs[0] != "e2" {
+		t.Fatalf("events = %+v, want new_messages [e2]", events)
+	}
+	if next == state {
+		t.Error("state did not advance")
+	}
+
+	events, _, _, err = checkWatch(ctx, client, w, next, nil, nil)
+	if err != nil || len(events) != 0 {
+		t.Fatalf("recheck = %+v, %v; want no events", events, err)
+	}
+}
+
+func TestCheckWatchThreadReportsKeywordChanges(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	fake.Add("Thread", map[string]any{"id": "t1", "emailIds": []any{"e1"}})
+	fake.Add("Email", map[string]any{"id": "e1", "threadId": "t1", "mailboxIds": map[string]any{"inbox": true}, "keywords": map[string]any{}})
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, snapshot, err := watchBaseline(ctx, client, jmapfake.AccountID, "", "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flagged, seen := true, true
+	if res, _, _ := s.handleEmailFlag(ctx, nil, EmailFlagInput{EmailIDs: []string{"e1"}, Flagged: &flagged}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	fake.Add("Email", map[string]any{"id": "e2", "threadId": "t1", "mailboxIds": map[string]any{"inbox": true}})
+
+	w := &watch{ID: "watch-1", AccountID: jmapfake.AccountID, ThreadID: "t1"}
+	events, state, snapshot, err := checkWatch(ctx, client, w, state, snapshot, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want new_messages and keywords_changed", events)
+	}
+	if events[0].Kind != watchNewMessages || events[0].EmailIDs[0] != "e2" {
+		t.Errorf("events[0] = %+v, want new_messages [e2]", events[0])
+	}
+	if ev := events[1]; ev.Kind != watchKeywordChange || ev.EmailIDs[0] != "e1" || strings.Join(ev.Keywords["e1"], ",") != "$flagged" {
+		t.Errorf("events[1] = %+v, want keywords_changed e1 [$flagged]", ev)
+	}
+
+	// A watch restricted to $flagged ignores $seen.
+	w.Keywords = []string{"$flagged"}
+	if res, _, _ := s.handleEmailFlag(ctx, nil, EmailFlagInput{EmailIDs: []string{"e1"}, Seen: &seen}); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	events, _, _, err = checkWatch(ctx, client, w, state, snapshot, nil)
+	if err != nil || len(events) != 0 {
+		t.Fatalf("events = %+v, %v; want none for $seen", events, err)
+	}
+}
+
+func TestCheckWatchRebasesWhenChangesUnavailable(t *testing.T) {
+	s, fake := newFakeServer(t)
+	ctx := context.Background()
+	addEmail(fake, "e1", "Hello", "hi", 1)
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &watch{ID: "watch-1", AccountID: jmapfake.AccountID, MailboxID: "inbox"}
+	events, state, _, err := checkWatch(ctx, client, w, "bogus", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 || state == "" || state == "bogus" {
+		t.Fatalf("events = %+v, state = %q; want rebase without events", events, state)
+	}
+}
+
+func TestWatchCreateValidation(t *testing.T) {
+	s, _ := newFakeServer(t)
+	ctx := context.Background()
+
+	for _, in := range []WatchCreateInput{
+		{},
+		{MailboxID: "inbox", ThreadID: "t1"},
+		{MailboxID: "inbox", Keywords: []string{"$flagged"}},
+		{MailboxID: "nope"},
+	} {
+		res, _, _ := s.handleWatchCreate(ctx, nil, in)
+		if !res.IsError {
+			t.Errorf("%+v: want error, got %s", in, resultText(res))
+		}
+	}
+}
+
+func TestWatchNotifiesSession(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notes := make(chan *mcp.LoggingMessageParams, 10)
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, &mcp.ClientOptions{
+		LoggingMessageHandler: func(_ context.Context, req *mcp.LoggingMessageRequest) {
+			notes <- req.Params
+		},
+	})
+	st, ct := mcp.NewInMemoryTransports()
+	if _, err := s.MCP().Connect(ctx, st, nil); err != nil {
+		t.Fatal(err)
+	}
+	cs, err := client.Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	if err := cs.SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: "info"}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := cs.CallTool(ctx, &mcp.CallToolParams{Name: "watch_create", Arguments: map[string]any{"mailbox_id": "inbox"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.IsError || !strings.Contains(resultText(res), "Created watch-1 on mailbox Inbox") {
+		t.Fatalf("watch_create = %s", resultText(res))
+	}
+
+	addEmail(fake, "e1", "Hello", "hi", 1)
+
+	select {
+	case p := <-notes:
+		if p.Logger != watchLoggerName {
+			t.Errorf("logger = %q", p.Logger)
+		}
+		data, _ := json.Marshal(p.Data)
+		var ev watchEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Watch != "watch-1" || ev.Kind != watchNewMessages || len(ev.EmailIDs) != 1 || ev.EmailIDs[0] != jmap.ID("e1") {
+			t.Errorf("event = %+v, want new_messages [e1] on watch-1", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch notification")
+	}
+
+	read, err := cs.ReadResource(ctx, &mcp.ReadResourceParams{URI: "jmap://watch/watch-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := read.Contents[0].Text; !strings.Contains(text, `"e1"`) {
+		t.Errorf("pending = %s, want e1", text)
+	}
+	read, err = cs.ReadResource(ctx, &mcp.ReadResourceParams{URI: "jmap://watch/watch-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := read.Contents[0].Text; text != "[]" {
+		t.Errorf("pending after drain = %s, want []", text)
+	}
+
+	res, err = cs.CallTool(ctx, &mcp.CallToolParams{Name: "watch_delete", Arguments: map[string]any{"id": "watch-1"}})
+	if err != nil || res.IsError {
+		t.Fatalf("watch_delete = %v %v", res, err)
+	}
+	if mode := s.watches.mode((&watch{Owner: ownerKey("test")}).listenerKey()); mode != "" {
+		t.Errorf("listener still registered after the last watch was deleted (mode %q)", mode)
+	}
+}
+
+func TestWatchPollsWithoutPush(t *testing.T) {
+	s, fake := newFakeServer(t, WithWatchPollInterval(10*time.Millisecond))
+	fake.DisablePush()
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	ctx := context.Background()
+
+	res, _, _ := s.handleWatchCreate(ctx, nil, WatchCreateInput{MailboxID: "inbox"})
+	if res.IsError || !strings.Contains(resultText(res), "no push: checking every 10ms") {
+		t.Fatalf("watch_create = %s", resultText(res))
+	}
+	defer s.handleWatchDelete(ctx, nil, WatchDeleteInput{ID: "watch-1"})
+
+	addEmail(fake, "e1", "Hello", "hi", 1)
+
+	owner := ownerKey("test")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if events, _ := s.watches.drain(owner, "watch-1"); len(events) > 0 {
+			if events[0].Kind != watchNewMessages || events[0].EmailIDs[0] != "e1" {
+				t.Fatalf("events = %+v, want new_messages [e1]", events)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("polling delivered no event")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	res, _, _ = s.handleWatchList(ctx, nil, WatchListInput{})
+	if !strings.Contains(resultText(res), "push: polling every 10ms") {
+		t.Errorf("watch_list = %s, want polling mode", resultText(res))
+	}
+}
+
+func TestWatchCreateWithoutPushOrPolling(t *testing.T) {
+	s, fake := newFakeServer(t, WithWatchPollInterval(0))
+	fake.DisablePush()
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+
+	res, _, _ := s.handleWatchCreate(context.Background(), nil, WatchCreateInput{MailboxID: "inbox"})
+	if !res.IsError || !strings.Contains(resultText(res), "polling is disabled") {
+		t.Fatalf("watch_create = %s, want push-unavailable error", resultText(res))
+	}
+}
+
+func TestWatchReleaseQuietHoursAndRate(t *testing.T) {
+	quiet, err := parseQuietHours("22:00-07:00", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r watchRegistry
+	w := &watch{Owner: "o", Quiet: quiet, MaxPerHour: 2}
+	r.add(w)
+	berlin := quiet.loc
+	ev := func(kind string, ids ...jmap.ID) []watchEvent {
+		return []watchEvent{{Watch: w.ID, Kind: kind, EmailIDs: ids}}
+	}
+
+	// At 03:00 events are held until 07:00, with one timer.
+	night := time.Date(2025, 3, 10, 3, 0, 0, 0, berlin)
+	if send, _, wait := r.release(w, ev(watchNewMessages, "e1"), night, false); send != nil || wait != 4*time.Hour {
+		t.Fatalf("at night: send %+v, wait %s; want held for 4h", send, wait)
+	}
+	if _, _, wait := r.release(w, ev(watchNewMessages, "e2", "e1"), night.Add(time.Minute), false); wait != 0 {
+		t.Errorf("second held event started another timer (wait %s)", wait)
+	}
+
+	// The timer at 07:00 sends them combined.
+	morning := time.Date(2025, 3, 10, 7, 0, 0, 0, berlin)
+	send, _, _ := r.release(w, nil, morning, true)
+	if len(send) != 1 || len(send[0].EmailIDs) != 2 || send[0].EmailIDs[0] != "e1" || send[0].EmailIDs[1] != "e2" {
+		t.Fatalf("at 07:00 = %+v, want one new_messages [e1 e2]", send)
+	}
+
+	// The rate lets one more notification through this hour, then holds
+	// the rest until the first one is an hour old.
+	if send, _, _ := r.release(w, ev(watchNewMessages, "e3"), morning.Add(10*time.Minute), false); len(send) != 1 {
+		t.Fatalf("second notification held: %+v", send)
+	}
+	if send, _, wait := r.release(w, ev(watchNewMessages, "e4"), morning.Add(20*time.Minute), false); send != nil || wait != 40*time.Minute {
+		t.Fatalf("over the rate: send %+v, wait %s; want held for 40m", send, wait)
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	q, err := parseQuietHours("09:00-17:30", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for at, want := range map[string]bool{"08:59": false, "09:00": true, "17:29": true, "17:30": false} {
+		ts, _ := time.Parse("2006-01-02 15:04", "2025-01-01 "+at)
+		if got := q.contains(ts); got != want {
+			t.Errorf("contains(%s) = %v, want %v", at, got, want)
+		}
+	}
+	for _, bad := range []string{"22:00", "07:00-07:00", "25:00-07:00"} {
+		if _, err := parseQuietHours(bad, ""); err == nil {
+			t.Errorf("parseQuietHours(%q) accepted", bad)
+		}
+	}
+	if _, err := parseQuietHours("22:00-07:00", "Mars/Olympus"); err == nil {
+		t.Error("unknown time zone accepted")
+	}
+}
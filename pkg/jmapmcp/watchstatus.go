@@ -0,0 +1,121 @@
+package jmapmcp
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mikluko/jmap"
+)
+
+// Watch status is a summary of the notification subsystem for monitoring:
+// each listener's connection mode and, per watch, the Email state it last
+// saw and how many events wait undrained or held for quiet hours. The
+// watch_status tool shows the caller's own; WatchStatusHandler serves all
+// of them as JSON for operators' dashboards. Neither includes anything
+// from the emails themselves, and owners appear only as token hashes.
+
+// watchStatus summarizes watches grouped by listener.
+type watchStatus struct {
+	At        time.Time        `json:"at"`
+	Watches   int              `json:"watches"`
+	Pending   int              `json:"pending"` // undrained events at jmap://watch/{id}
+	Held      int              `json:"held"`    // events whose notification waits
+	Failing   int              `json:"failing"` // watches whose last check failed
+	Listeners []listenerStatus `json:"listeners"`
+}
+
+// listenerStatus is one owner's connection to one endpoint.
+type listenerStatus struct {
+	Owner    string          `json:"owner"`
+	Endpoint string          `json:"endpoint"`
+	Mode     string          `json:"mode"` // as watch_list shows it, e.g. push or polling
+	Watches  []watchSnapshot `json:"watches"`
+}
+
+// watchSnapshot is the state of one watch.
+type watchSnapshot struct {
+	ID         string    `json:"id"`
+	MailboxID  jmap.ID   `json:"mailboxId,omitempty"`
+	ThreadID   jmap.ID   `json:"threadId,omitempty"`
+	Automation string    `json:"automation,omitempty"` // the automation the watch runs
+	State      string    `json:"state"`                // Email state last seen
+	Created    time.Time `json:"created"`
+	Delivered  int       `json:"delivered"`
+	Pending    int       `json:"pending"`
+	Held       int       `json:"held"`
+	LastError  string    `json:"lastError,omitempty"`
+}
+
+// status summarizes the watches of owner, or of every owner when owner is
+// empty.
+func (r *watchRegistry) status(owner string, now time.Time) *watchStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := &watchStatus{At: now.UTC(), Listeners: []listenerStatus{}}
+	byKey := make(map[string]int)
+	for _, w := range r.watches {
+		if owner != "" && w.Owner != owner {
+			continue
+		}
+		key := w.listenerKey()
+		i, ok := byKey[key]
+		if !ok {
+			i = len(st.Listeners)
+			byKey[key] = i
+			endpoint := w.Endpoint
+			if endpoint == "" {
+				endpoint = DefaultEndpoint
+			}
+			var mode string
+			if l := r.listeners[key]; l != nil {
+				mode = l.mode
+			}
+			st.Listeners = append(st.Listeners, listenerStatus{Owner: w.Owner, Endpoint: endpoint, Mode: mode})
+		}
+		st.Listeners[i].Watches = append(st.Listeners[i].Watches, watchSnapshot{
+			ID:         w.ID,
+			MailboxID:  w.MailboxID,
+			ThreadID:   w.ThreadID,
+			Automation: w.automation,
+			State:      w.state,
+			Created:    w.CreatedAt.UTC(),
+			Delivered:  w.delivered,
+			Pending:    len(w.pending),
+			Held:       len(w.held),
+			LastError:  w.lastError,
+		})
+		st.Watches++
+		st.Pending += len(w.pending)
+		st.Held += len(w.held)
+		if w.lastError != "" {
+			st.Failing++
+		}
+	}
+	slices.SortFunc(st.Listeners, func(a, b listenerStatus) int {
+		return cmp.Or(cmp.Compare(a.Owner, b.Owner), cmp.Compare(a.Endpoint, b.Endpoint))
+	})
+	for _, l := range st.Listeners {
+		slices.SortFunc(l.Watches, func(a, b watchSnapshot) int {
+			return cmp.Or(a.Created.Compare(b.Created), cmp.Compare(a.ID, b.ID))
+		})
+	}
+	return st
+}
+
+// WatchStatusHandler serves the status of all watches as JSON. Mount it
+// where operators, not MCP clients, can reach it, or behind
+// AuthMiddleware.
+func (s *Server) WatchStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(s.watches.status("", time.Now()))
+	})
+}
@@ -0,0 +1,50 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchStatus(t *testing.T) {
+	s, _ := newFakeServer(t)
+	ctx := context.Background()
+	mine := &watch{Owner: ownerKey("test"), MailboxID: "inbox", CreatedAt: time.Now()}
+	theirs := &watch{Owner: ownerKey("other"), ThreadID: "T1", Endpoint: "work", CreatedAt: time.Now()}
+	s.watches.add(mine)
+	s.watches.add(theirs)
+	s.watches.advance(mine, "s7", nil, []watchEvent{{Kind: watchNewMessages}, {Kind: watchNewMessages}}, nil)
+	s.watches.advance(theirs, "", nil, nil, errors.New("session expired"))
+
+	res, _, _ := s.handleWatchStatus(ctx, nil, WatchStatusInput{})
+	out := resultText(res)
+	for _, want := range []string{"Watches: 1, pending 2, held 0, failing 0", "default: connecting", "watch-1 mailbox inbox: state s7, delivered 2, pending 2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("watch_status lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "T1") {
+		t.Errorf("watch_status shows another owner's watch:\n%s", out)
+	}
+
+	rec := httptest.NewRecorder()
+	s.WatchStatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/watch-status", nil))
+	var st watchStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("status JSON: %v\n%s", err, rec.Body)
+	}
+	if st.Watches != 2 || st.Pending != 2 || st.Failing != 1 || len(st.Listeners) != 2 {
+		t.Errorf("status = %+v, want 2 watches on 2 listeners, 2 pending, 1 failing", st)
+	}
+
+	rec = httptest.NewRecorder()
+	s.WatchStatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/watch-status", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
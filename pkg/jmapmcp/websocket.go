@@ -0,0 +1,414 @@
+package jmapmcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"golang.org/x/net/websocket"
+)
+
+// JMAP over WebSocket (RFC 8887). When the session advertises
+// urn:ietf:params:jmap:websocket, method calls go over one persistent
+// connection per backend and token instead of an HTTP request each, and
+// watches take push from the same kind of connection. Uploads and
+// downloads stay on HTTP. A connection that cannot be opened or breaks
+// falls back to HTTP for that call; the next call dials again.
+
+const (
+	websocketURI = jmap.URI("urn:ietf:params:jmap:websocket")
+
+	// wsIdleTimeout closes pooled connections no call has used for this long.
+	wsIdleTimeout = 5 * time.Minute
+
+	// wsDialTimeout bounds opening a connection, handshake included.
+	wsDialTimeout = 10 * time.Second
+
+	// wsRetryAfter is how long calls use HTTP after a failed dial before
+	// WebSocket is tried again.
+	wsRetryAfter = time.Minute
+)
+
+// websocketCapability is the session's RFC 8887 capability object.
+type websocketCapability struct {
+	URL          string `json:"url"`
+	SupportsPush bool   `json:"supportsPush"`
+}
+
+// sessionWebSocket returns the WebSocket capability of session, if any.
+func sessionWebSocket(session *jmap.Session) (*websocketCapability, bool) {
+	if session == nil {
+		return nil, false
+	}
+	raw, ok := session.RawCapabilities[websocketURI]
+	if !ok {
+		return nil, false
+	}
+	var cap websocketCapability
+	if err := json.Unmarshal(raw, &cap); err != nil || cap.URL == "" {
+		return nil, false
+	}
+	return &cap, true
+}
+
+// WebSocketDialer is implemented by Dialers that open WebSocket
+// connections themselves, such as in-process fakes; the handshake is still
+// done by the server. Other Dialers connect over the network.
+type WebSocketDialer interface {
+	DialWebSocket(ctx context.Context, url string) (net.Conn, error)
+}
+
+// wsConn is one RFC 8887 connection. Responses are matched to requests by
+// ID; StateChanges go to pushes, coalesced, since the listener only needs
+// to know that it should diff.
+type wsConn struct {
+	ws     *websocket.Conn
+	sendMu sync.Mutex
+
+	mu       sync.Mutex
+	seq      int
+	waiting  map[string]chan wsReply
+	err      error // set once the connection has failed
+	lastUsed time.Time
+
+	pushes chan struct{}
+	done   chan struct{}
+}
+
+// wsReply is a Response or RequestError for one request.
+type wsReply struct {
+	data []byte
+	err  error
+}
+
+// dialWebSocket opens a connection to url authenticated with token.
+func (s *Server) dialWebSocket(ctx context.Context, url, origin, token string) (*wsConn, error) {
+	cfg, err := websocket.NewConfig(url, origin)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Protocol = []string{"jmap"}
+	cfg.Header.Set("Authorization", "Bearer "+token)
+
+	var ws *websocket.Conn
+	if d, ok := s.dialer.(WebSocketDialer); ok {
+		rwc, err := d.DialWebSocket(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		if ws, err = websocket.NewClient(cfg, rwc); err != nil {
+			rwc.Close()
+			return nil, err
+		}
+	} else if ws, err = cfg.DialContext(ctx); err != nil {
+		return nil, err
+	}
+
+	c := &wsConn{
+		ws:       ws,
+		waiting:  make(map[string]chan wsReply),
+		lastUsed: time.Now(),
+		pushes:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// readLoop dispatches incoming messages until the connection fails.
+func (c *wsConn) readLoop() {
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(c.ws, &data); err != nil {
+			c.fail(fmt.Errorf("websocket: %w", err))
+			return
+		}
+		var head struct {
+			Type      string `json:"@type"`
+			RequestID string `json:"requestId"`
+			Status    int    `json:"status"`
+			Detail    string `json:"detail"`
+			Problem   string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &head); err != nil {
+			continue
+		}
+		switch head.Type {
+		case "Response":
+			c.reply(head.RequestID, wsReply{data: data})
+		case "RequestError":
+			c.reply(head.RequestID, wsReply{err: fmt.Errorf("jmap request error %d %s: %s", head.Status, head.Problem, head.Detail)})
+		case "StateChange":
+			select {
+			case c.pushes <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (c *wsConn) reply(id string, r wsReply) {
+	c.mu.Lock()
+	ch, ok := c.waiting[id]
+	delete(c.waiting, id)
+	c.mu.Unlock()
+	if ok {
+		ch <- r
+	}
+}
+
+// fail closes the connection and fails pending requests with err.
+func (c *wsConn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.ws.Close()
+	close(c.done)
+	for id, ch := range c.waiting {
+		ch <- wsReply{err: err}
+		delete(c.waiting, id)
+	}
+}
+
+func (c *wsConn) close() {
+	c.fail(errors.New("websocket: closed"))
+}
+
+// alive reports whether the connection can take requests.
+func (c *wsConn) alive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err == nil
+}
+
+// send writes v as one text message.
+func (c *wsConn) send(v any) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return websocket.JSON.Send(c.ws, v)
+}
+
+// errWebSocketUnsent marks a request that never reached the server, which
+// is safe to retry over HTTP.
+var errWebSocketUnsent = errors.New("websocket: request not sent")
+
+// do sends req and waits for its response. It also returns the raw
+// response for metering and debugging.
+func (c *wsConn) do(req *jmap.Request) (*jmap.Response, []byte, error) {
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ch := make(chan wsReply, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, nil, fmt.Errorf("%w: %v", errWebSocketUnsent, c.err)
+	}
+	c.seq++
+	id := strconv.Itoa(c.seq)
+	c.waiting[id] = ch
+	c.lastUsed = time.Now()
+	c.mu.Unlock()
+
+	msg := struct {
+		Type string `json:"@type"`
+		ID   string `json:"id"`
+		*jmap.Request
+	}{"Request", id, req}
+	if err := c.send(msg); err != nil {
+		c.fail(fmt.Errorf("websocket: %w", err))
+		return nil, nil, fmt.Errorf("%w: %v", errWebSocketUnsent, err)
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+		rawResponsesFromContext(ctx).add(r.data)
+		data, dropped := dropUnknownResponses(r.data)
+		responseWarningsFromContext(ctx).add(dropped)
+		resp := &jmap.Response{}
+		if err := json.Unmarshal(data, resp); err != nil {
+			return nil, r.data, fmt.Errorf("decode websocket response: %w", err)
+		}
+		return resp, r.data, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.waiting, id)
+		c.mu.Unlock()
+		return nil, nil, ctx.Err()
+	}
+}
+
+// enablePush asks the server to send StateChanges for types.
+func (c *wsConn) enablePush(types []string) error {
+	return c.send(struct {
+		Type      string   `json:"@type"`
+		DataTypes []string `json:"dataTypes"`
+	}{"WebSocketPushEnable", types})
+}
+
+// wsPool keeps one connection per WebSocket URL and token. The zero value
+// is ready to use.
+type wsPool struct {
+	mu      sync.Mutex
+	conns   map[string]*wsConn
+	failed  map[string]time.Time // keys whose last dial failed, and when
+	dialing map[string]*wsDial   // dials in progress, shared by concurrent calls
+}
+
+// wsDial is a connection being dialed; done closes once conn or err is set.
+type wsDial struct {
+	done chan struct{}
+	conn *wsConn
+	err  error
+}
+
+// get returns a live pooled connection for key, dialing one if needed.
+// Connections idle for wsIdleTimeout are closed on the way. The dial runs
+// without the pool lock, so a slow handshake holds up only the calls
+// waiting for that connection.
+func (p *wsPool) get(key string, dial func() (*wsConn, error)) (*wsConn, error) {
+	p.mu.Lock()
+	if p.conns == nil {
+		p.conns = make(map[string]*wsConn)
+		p.failed = make(map[string]time.Time)
+		p.dialing = make(map[string]*wsDial)
+	}
+	if at, ok := p.failed[key]; ok && time.Since(at) < wsRetryAfter {
+		p.mu.Unlock()
+		return nil, errors.New("websocket: recent dial failed")
+	}
+	for k, c := range p.conns {
+		c.mu.Lock()
+		idle := time.Since(c.lastUsed) > wsIdleTimeout
+		c.mu.Unlock()
+		if idle {
+			c.close()
+		}
+		if !c.alive() {
+			delete(p.conns, k)
+		}
+	}
+	if c, ok := p.conns[key]; ok {
+		p.mu.Unlock()
+		return c, nil
+	}
+	if d, ok := p.dialing[key]; ok {
+		p.mu.Unlock()
+		<-d.done
+		return d.conn, d.err
+	}
+	d := &wsDial{done: make(chan struct{})}
+	p.dialing[key] = d
+	p.mu.Unlock()
+
+	d.conn, d.err = dial()
+
+	p.mu.Lock()
+	delete(p.dialing, key)
+	if d.err != nil {
+		p.failed[key] = time.Now()
+	} else {
+		delete(p.failed, key)
+		p.conns[key] = d.conn
+	}
+	p.mu.Unlock()
+	close(d.done)
+	return d.conn, d.err
+}
+
+// wsClient sends Do over a WebSocket connection and everything else, and
+// requests the connection could not send, through base.
+type wsClient struct {
+	JMAPClient
+	conn  *wsConn
+	meter *fetchMeter // nil when the call is not metered
+	trace *jmapTrace  // nil unless JMAP debugging records the call
+}
+
+func (w wsClient) Do(req *jmap.Request) (*jmap.Response, error) {
+	if err := checkUsing(w.Session(), req); err != nil {
+		return nil, err
+	}
+	if m := w.meter; m != nil && m.limit > 0 && m.used.Load() > m.limit {
+		return nil, fetchLimitError(m.limit)
+	}
+	resp, data, err := w.conn.do(req)
+	if errors.Is(err, errWebSocketUnsent) {
+		return w.JMAPClient.Do(req)
+	}
+	if w.trace != nil {
+		sent, _ := json.Marshal(req)
+		e := jmapExchange{Transport: "websocket", Request: string(sent), Response: string(data)}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		w.trace.add(e)
+	}
+	if m := w.meter; m != nil {
+		if used := m.used.Add(int64(len(data))); m.limit > 0 && used > m.limit {
+			return nil, fetchLimitError(m.limit)
+		}
+	}
+	return resp, err
+}
+
+// checkUsing adds the core capability to req and checks that the session
+// supports every capability it uses, as the HTTP client does.
+func checkUsing(session *jmap.Session, req *jmap.Request) error {
+	found := false
+	for _, uri := range req.Using {
+		if uri == jmap.CoreURI {
+			found = true
+			break
+		}
+	}
+	if !found {
+		req.Using = append(req.Using, jmap.CoreURI)
+	}
+	for _, uri := range req.Using {
+		if _, ok := session.RawCapabilities[uri]; !ok {
+			return fmt.Errorf("server doesn't support required capability '%s'", uri)
+		}
+	}
+	return nil
+}
+
+// websocketClient returns client routed over the pooled WebSocket
+// connection of its backend and token, or client itself when WebSocket is
+// disabled, not advertised, or cannot be opened.
+func (s *Server) websocketClient(ctx context.Context, client JMAPClient, token string) JMAPClient {
+	if s.noWebSocket {
+		return client
+	}
+	session := client.Session()
+	cap, ok := sessionWebSocket(session)
+	if !ok {
+		return client
+	}
+	key := cap.URL + "\x00" + ownerKey(token)
+	conn, err := s.wsConns.get(key, func() (*wsConn, error) {
+		// The pooled connection outlives this call, so it must not
+		// inherit the call's cancellation.
+		dialCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), wsDialTimeout)
+		defer cancel()
+		return s.dialWebSocket(dialCtx, cap.URL, session.APIURL, token)
+	})
+	if err != nil {
+		return client
+	}
+	return wsClient{JMAPClient: client, conn: conn, meter: fetchMeterFromContext(ctx), trace: jmapTraceFromContext(ctx)}
+}
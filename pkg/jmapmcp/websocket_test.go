@@ -0,0 +1,166 @@
+package jmapmcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/jmap"
+	"github.com/mikluko/jmap-mcp/internal/jmapfake"
+	"github.com/mikluko/jmap/mail/email"
+)
+
+func TestWebSocketCarriesMethodCalls(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.EnableWebSocket()
+	addEmail(fake, "e1", "Hello", "body", 1)
+	ctx := context.Background()
+
+	for range 2 {
+		res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{})
+		if res.IsError || !strings.Contains(resultText(res), "Hello") {
+			t.Fatalf("email_query = %s", resultText(res))
+		}
+	}
+	if n := fake.WebSocketRequests(); n != 2 {
+		t.Errorf("WebSocket requests = %d, want 2", n)
+	}
+	if n := len(s.wsConns.conns); n != 1 {
+		t.Errorf("pooled connections = %d, want 1 reused", n)
+	}
+}
+
+func TestWebSocketDisabled(t *testing.T) {
+	s, fake := newFakeServer(t, WithWebSocket(false))
+	fake.EnableWebSocket()
+	addEmail(fake, "e1", "Hello", "body", 1)
+
+	res, _, _ := s.handleEmailQuery(context.Background(), nil, EmailQueryInput{})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if n := fake.WebSocketRequests(); n != 0 {
+		t.Errorf("WebSocket requests = %d, want 0", n)
+	}
+}
+
+func TestWebSocketFallsBackToHTTP(t *testing.T) {
+	s, fake := newFakeServer(t)
+	fake.EnableWebSocket()
+	addEmail(fake, "e1", "Hello", "body", 1)
+	ctx := context.Background()
+
+	client, err := s.jmapClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, ok := client.(tolerantClient)
+	if !ok {
+		t.Fatalf("client is %T, want tolerantClient", client)
+	}
+	wc, ok := tc.JMAPClient.(wsClient)
+	if !ok {
+		t.Fatalf("client wraps %T, want wsClient", tc.JMAPClient)
+	}
+	wc.conn.close()
+
+	req := &jmap.Request{Context: ctx}
+	req.Invoke(&email.Get{Account: jmapfake.AccountID, IDs: []jmap.ID{"e1"}, Properties: []string{"subject"}})
+	resp, err := wc.Do(req)
+	if err != nil {
+		t.Fatalf("Do over a closed connection: %v", err)
+	}
+	if got, ok := resp.Responses[0].Args.(*email.GetResponse); !ok || len(got.List) != 1 {
+		t.Fatalf("response = %#v", resp.Responses[0].Args)
+	}
+	if n := fake.WebSocketRequests(); n != 0 {
+		t.Errorf("WebSocket requests = %d, want 0", n)
+	}
+
+	// The next client replaces the dead pooled connection.
+	res, _, _ := s.handleEmailQuery(ctx, nil, EmailQueryInput{})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	if n := fake.WebSocketRequests(); n != 1 {
+		t.Errorf("WebSocket requests = %d, want 1 after redial", n)
+	}
+}
+
+func TestWatchPushOverWebSocket(t *testing.T) {
+	s, fake := newFakeServer(t, WithWatchPollInterval(0))
+	fake.DisablePush()
+	fake.EnableWebSocket()
+	fake.Add("Mailbox", map[string]any{"id": "inbox", "name": "Inbox", "role": "inbox"})
+	ctx := context.Background()
+
+	res, _, _ := s.handleWatchCreate(ctx, nil, WatchCreateInput{MailboxID: "inbox"})
+	if res.IsError {
+		t.Fatal(resultText(res))
+	}
+	defer s.handleWatchDelete(ctx, nil, WatchDeleteInput{ID: "watch-1"})
+
+	// Wait for the listener to enable push, so the email is a push.
+	key := (&watch{Owner: ownerKey("test")}).listenerKey()
+	waitFor(t, func() bool { return s.watches.mode(key) == "push (websocket)" })
+	addEmail(fake, "e1", "Hello", "hi", 1)
+
+	waitFor(t, func() bool {
+		ws := s.watches.list(ownerKey("test"))
+		return len(ws) == 1 && ws[0].delivered == 1
+	})
+}
+
+// waitFor polls cond for up to five seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWSPoolDialsWithoutLock(t *testing.T) {
+	var p wsPool
+	release := make(chan struct{})
+	dials := 0
+	slow := func() (*wsConn, error) {
+		dials++
+		<-release
+		return &wsConn{lastUsed: time.Now(), done: make(chan struct{})}, nil
+	}
+
+	got := make(chan *wsConn, 2)
+	for range 2 {
+		go func() {
+			c, _ := p.get("slow", slow)
+			got <- c
+		}()
+	}
+	// Another connection is not held up by the slow handshake.
+	done := make(chan struct{})
+	go func() {
+		p.get("fast", func() (*wsConn, error) {
+			return &wsConn{lastUsed: time.Now(), done: make(chan struct{})}, nil
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dial of another key waited for the slow dial")
+	}
+
+	close(release)
+	a, b := <-got, <-got
+	if a == nil || a != b {
+		t.Errorf("concurrent gets = %p, %p, want one shared connection", a, b)
+	}
+	if dials != 1 {
+		t.Errorf("dials = %d, want 1", dials)
+	}
+}